@@ -47,6 +47,14 @@ type TestConfig struct {
 
 	UseKind bool
 
+	// ProvisionClusters is the tool, kind or k3d, the clusters of the
+	// ClusterTopology are created with before the tests run. The clusters of
+	// the kube context flags are used if it is empty.
+	ProvisionClusters string
+	ClusterTopology   string
+	ClusterImage      string
+	KeepClusters      bool
+
 	helmChartPath string
 }
 
@@ -0,0 +1,208 @@
+// Package envoy contains helpers that fetch the configuration of the Envoy
+// proxy of a pod and assert on it. It parses the configuration with the same
+// code as the consul-k8s CLI so that tests check the typed summary instead of
+// the raw JSON of the config dump.
+package envoy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	terratestLogger "github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/hashicorp/consul-k8s/acceptance/framework/logger"
+	cliEnvoy "github.com/hashicorp/consul-k8s/cli/envoy"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+// AdminPort is the port of the admin API of the Envoy proxies injected by the
+// connect injector.
+const AdminPort = 19000
+
+// Config is the summarized configuration of the Envoy proxy of a pod.
+type Config struct {
+	Pod     string
+	Summary *cliEnvoy.Summary
+}
+
+// FetchConfig fetches the configuration of the Envoy proxy of the pod through a
+// port forward to its admin API. It fails the test if the configuration can't
+// be fetched.
+func FetchConfig(t *testing.T, options *terratestk8s.KubectlOptions, pod string) *Config {
+	t.Helper()
+
+	localPort := terratestk8s.GetAvailablePort(t)
+	tunnel := terratestk8s.NewTunnelWithLogger(
+		options,
+		terratestk8s.ResourceTypePod,
+		pod,
+		localPort,
+		AdminPort,
+		terratestLogger.New(logger.TestLogger{}))
+
+	// Retry creating the port forward since it can fail occasionally.
+	retry.RunWith(&retry.Counter{Wait: 1 * time.Second, Count: 3}, t, func(r *retry.R) {
+		// NOTE: It's okay to pass in `t` to ForwardPortE despite being in a retry
+		// because we're using ForwardPortE (not ForwardPort) so the `t` won't
+		// get used to fail the test, just for logging.
+		require.NoError(r, tunnel.ForwardPortE(t))
+	})
+	defer tunnel.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	raw, err := cliEnvoy.FetchConfigDump(ctx, fmt.Sprintf("127.0.0.1:%d", localPort))
+	require.NoError(t, err)
+	return ParseConfig(t, pod, raw)
+}
+
+// ParseConfig parses the JSON of the config dump of the Envoy proxy of the
+// pod.
+func ParseConfig(t *testing.T, pod string, raw []byte) *Config {
+	t.Helper()
+
+	dump, err := cliEnvoy.ParseConfigDump(raw)
+	require.NoError(t, err)
+	return &Config{Pod: pod, Summary: cliEnvoy.Summarize(dump, cliEnvoy.SummaryOptions{})}
+}
+
+// Cluster returns the cluster with the name, or nil if there is none. Envoy
+// clusters of upstreams are named by their SNI, e.g.
+// "static-server.default.dc1.internal.<trust domain>.consul", so the name
+// matches a cluster if it is the cluster name or a prefix of it up to a dot.
+func (c *Config) Cluster(name string) *cliEnvoy.Cluster {
+	for i, cluster := range c.Summary.Clusters {
+		if cluster.Name == name || strings.HasPrefix(cluster.Name, name+".") {
+			return &c.Summary.Clusters[i]
+		}
+	}
+	return nil
+}
+
+// Listener returns the listener with the name, or nil if there is none.
+// Listener names include their address, e.g.
+// "public_listener:10.0.0.5:20000", so the name matches a listener if it is
+// the listener name or a prefix of it up to a colon.
+func (c *Config) Listener(name string) *cliEnvoy.Listener {
+	for i, listener := range c.Summary.Listeners {
+		if listener.Name == name || strings.HasPrefix(listener.Name, name+":") {
+			return &c.Summary.Listeners[i]
+		}
+	}
+	return nil
+}
+
+// HealthyEndpoints returns the addresses of the healthy endpoints of the
+// cluster with the name, matched like Cluster does.
+func (c *Config) HealthyEndpoints(name string) []string {
+	cluster := c.Cluster(name)
+	if cluster == nil {
+		return nil
+	}
+	var addresses []string
+	for _, endpoint := range c.Summary.Endpoints {
+		if endpoint.Cluster == cluster.Name && endpoint.Health == "HEALTHY" {
+			addresses = append(addresses, endpoint.Address)
+		}
+	}
+	return addresses
+}
+
+// ExpectUpstreamCluster fails the test unless the Envoy proxy of the pod has
+// a cluster for the upstream, e.g. "static-server.default.dc1", that
+// connects over TLS. It retries since the proxy may not have received its
+// configuration yet, and returns the last fetched configuration.
+func ExpectUpstreamCluster(t *testing.T, options *terratestk8s.KubectlOptions, pod, upstream string) *Config {
+	t.Helper()
+	return eventually(t, options, pod, func(r *retry.R, config *Config) {
+		cluster := config.Cluster(upstream)
+		if cluster == nil {
+			r.Errorf("pod %s has no cluster for upstream %s, clusters: %s", pod, upstream, config.clusterNames())
+			return
+		}
+		if !cluster.TLS {
+			r.Errorf("cluster %s of pod %s does not use TLS", cluster.Name, pod)
+		}
+	})
+}
+
+// ExpectNoUpstreamCluster fails the test if the Envoy proxy of the pod has a
+// cluster for the upstream.
+func ExpectNoUpstreamCluster(t *testing.T, options *terratestk8s.KubectlOptions, pod, upstream string) *Config {
+	t.Helper()
+	return eventually(t, options, pod, func(r *retry.R, config *Config) {
+		if cluster := config.Cluster(upstream); cluster != nil {
+			r.Errorf("pod %s has cluster %s for upstream %s", pod, cluster.Name, upstream)
+		}
+	})
+}
+
+// ExpectListener fails the test unless the Envoy proxy of the pod has the
+// listener, e.g. "public_listener" or "outbound_listener", and it has the
+// network filter, e.g. "envoy.filters.network.tcp_proxy", if one is given.
+func ExpectListener(t *testing.T, options *terratestk8s.KubectlOptions, pod, listener, filter string) *Config {
+	t.Helper()
+	return eventually(t, options, pod, func(r *retry.R, config *Config) {
+		l := config.Listener(listener)
+		if l == nil {
+			r.Errorf("pod %s has no listener %s, listeners: %s", pod, listener, config.listenerNames())
+			return
+		}
+		if filter != "" && !contains(l.Filters, filter) {
+			r.Errorf("listener %s of pod %s has no filter %s, filters: %s", l.Name, pod, filter, strings.Join(l.Filters, ", "))
+		}
+	})
+}
+
+// ExpectHealthyEndpoints fails the test unless the cluster of the upstream in
+// the Envoy proxy of the pod has the number of healthy endpoints.
+func ExpectHealthyEndpoints(t *testing.T, options *terratestk8s.KubectlOptions, pod, upstream string, count int) *Config {
+	t.Helper()
+	return eventually(t, options, pod, func(r *retry.R, config *Config) {
+		if endpoints := config.HealthyEndpoints(upstream); len(endpoints) != count {
+			r.Errorf("upstream %s of pod %s has %d healthy endpoints, expected %d: %s",
+				upstream, pod, len(endpoints), count, strings.Join(endpoints, ", "))
+		}
+	})
+}
+
+// eventually fetches the configuration of the proxy of the pod until check
+// doesn't fail, and returns the last configuration.
+func eventually(t *testing.T, options *terratestk8s.KubectlOptions, pod string, check func(r *retry.R, config *Config)) *Config {
+	t.Helper()
+	var config *Config
+	retry.RunWith(&retry.Counter{Wait: 2 * time.Second, Count: 30}, t, func(r *retry.R) {
+		config = FetchConfig(t, options, pod)
+		check(r, config)
+	})
+	return config
+}
+
+func (c *Config) clusterNames() string {
+	var names []string
+	for _, cluster := range c.Summary.Clusters {
+		names = append(names, cluster.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func (c *Config) listenerNames() string {
+	var names []string
+	for _, listener := range c.Summary.Listeners {
+		names = append(names, listener.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func contains(list []string, s string) bool {
+	for _, elem := range list {
+		if elem == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package envoy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const configDump = `{
+  "configs": [
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.ListenersConfigDump",
+      "dynamic_listeners": [
+        {"name": "public_listener:10.0.0.5:20000", "active_state": {"listener": {
+          "name": "public_listener:10.0.0.5:20000",
+          "address": {"socket_address": {"address": "10.0.0.5", "port_value": 20000}},
+          "filter_chains": [{"filters": [{"name": "envoy.filters.network.rbac"}, {"name": "envoy.filters.network.tcp_proxy"}]}]
+        }}},
+        {"name": "static-server:127.0.0.1:1234", "active_state": {"listener": {
+          "name": "static-server:127.0.0.1:1234",
+          "address": {"socket_address": {"address": "127.0.0.1", "port_value": 1234}},
+          "filter_chains": [{"filters": [{"name": "envoy.filters.network.tcp_proxy"}]}]
+        }}}
+      ]
+    },
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.ClustersConfigDump",
+      "static_clusters": [{"cluster": {"name": "local_app", "type": "STATIC"}}],
+      "dynamic_active_clusters": [{"cluster": {
+        "name": "static-server.default.dc1.internal.11111111-2222-3333-4444-555555555555.consul",
+        "type": "EDS",
+        "transport_socket": {"name": "tls", "typed_config": {
+          "@type": "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.UpstreamTlsContext",
+          "sni": "static-server.default.dc1.internal.11111111-2222-3333-4444-555555555555.consul"
+        }}
+      }}]
+    },
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.EndpointsConfigDump",
+      "dynamic_endpoint_configs": [{"endpoint_config": {
+        "cluster_name": "static-server.default.dc1.internal.11111111-2222-3333-4444-555555555555.consul",
+        "endpoints": [{"lb_endpoints": [
+          {"endpoint": {"address": {"socket_address": {"address": "10.0.0.6", "port_value": 20000}}}, "health_status": "HEALTHY"},
+          {"endpoint": {"address": {"socket_address": {"address": "10.0.0.7", "port_value": 20000}}}, "health_status": "UNHEALTHY"}
+        ]}]
+      }}]
+    }
+  ]
+}`
+
+func TestConfig(t *testing.T) {
+	config := ParseConfig(t, "static-client", []byte(configDump))
+
+	cluster := config.Cluster("static-server.default.dc1")
+	require.NotNil(t, cluster)
+	require.True(t, cluster.TLS)
+	require.NotNil(t, config.Cluster("local_app"))
+	require.Nil(t, config.Cluster("static-server.default.dc2"))
+	// Names only match up to a dot.
+	require.Nil(t, config.Cluster("static-serv"))
+
+	listener := config.Listener("public_listener")
+	require.NotNil(t, listener)
+	require.Equal(t, []string{"envoy.filters.network.rbac", "envoy.filters.network.tcp_proxy"}, listener.Filters)
+	require.NotNil(t, config.Listener("static-server"))
+	require.Nil(t, config.Listener("outbound_listener"))
+
+	require.Equal(t, []string{"10.0.0.6:20000"}, config.HealthyEndpoints("static-server.default.dc1"))
+	require.Empty(t, config.HealthyEndpoints("static-server.default.dc2"))
+}
@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/hashicorp/consul-k8s/acceptance/framework/config"
+	"github.com/hashicorp/consul-k8s/acceptance/framework/localcluster"
 )
 
 type TestFlags struct {
@@ -37,6 +38,11 @@ type TestFlags struct {
 
 	flagUseKind bool
 
+	flagProvisionClusters string
+	flagClusterTopology   string
+	flagClusterImage      string
+	flagKeepClusters      bool
+
 	once sync.Once
 }
 
@@ -92,13 +98,38 @@ func (t *TestFlags) init() {
 	flag.BoolVar(&t.flagUseKind, "use-kind", false,
 		"If true, the tests will assume they are running against a local kind cluster(s).")
 
+	flag.StringVar(&t.flagProvisionClusters, "provision-clusters", "",
+		"If set to kind or k3d, the clusters of -cluster-topology are created with that tool before the tests run "+
+			"and deleted after. Clusters that already exist are reused.")
+	flag.StringVar(&t.flagClusterTopology, "cluster-topology", string(localcluster.Single),
+		"The clusters created with -provision-clusters: single, primary-secondary or peered. "+
+			"primary-secondary and peered enable the multi-cluster tests.")
+	flag.StringVar(&t.flagClusterImage, "cluster-image", "",
+		"The node image of the clusters created with -provision-clusters. If this is blank, the default image of the tool is used.")
+	flag.BoolVar(&t.flagKeepClusters, "keep-clusters", false,
+		"If true, the clusters created with -provision-clusters are not deleted after the tests, "+
+			"so that the next test run, e.g. of another test package, reuses them.")
+
 	if t.flagEnterpriseLicense == "" {
 		t.flagEnterpriseLicense = os.Getenv("CONSUL_ENT_LICENSE")
 	}
 }
 
 func (t *TestFlags) Validate() error {
-	if t.flagEnableMultiCluster {
+	if t.flagProvisionClusters != "" {
+		if err := localcluster.ValidateProvider(t.flagProvisionClusters); err != nil {
+			return err
+		}
+		if err := localcluster.ValidateTopology(t.flagClusterTopology); err != nil {
+			return err
+		}
+		if t.flagKubeconfig != "" || t.flagKubecontext != "" || t.flagSecondaryKubeconfig != "" || t.flagSecondaryKubecontext != "" {
+			return errors.New("the kubeconfig and kubecontext flags cannot be set with -provision-clusters")
+		}
+		if t.flagEnableMultiCluster && !localcluster.Topology(t.flagClusterTopology).MultiCluster() {
+			return errors.New("-enable-multi-cluster requires a multi-cluster -cluster-topology with -provision-clusters")
+		}
+	} else if t.flagEnableMultiCluster {
 		if t.flagSecondaryKubecontext == "" && t.flagSecondaryKubeconfig == "" {
 			return errors.New("at least one of -secondary-kubecontext or -secondary-kubeconfig flags must be provided if -enable-multi-cluster is set")
 		}
@@ -138,5 +169,10 @@ func (t *TestFlags) TestConfigFromFlags() *config.TestConfig {
 		NoCleanupOnFailure: t.flagNoCleanupOnFailure,
 		DebugDirectory:     tempDir,
 		UseKind:            t.flagUseKind,
+
+		ProvisionClusters: t.flagProvisionClusters,
+		ClusterTopology:   t.flagClusterTopology,
+		ClusterImage:      t.flagClusterImage,
+		KeepClusters:      t.flagKeepClusters,
 	}
 }
@@ -14,6 +14,10 @@ func TestFlags_validate(t *testing.T) {
 
 		flagEnableEnt  bool
 		flagEntLicense string
+
+		flagKubecontext       string
+		flagProvisionClusters string
+		flagClusterTopology   string
 	}
 	tests := []struct {
 		name       string
@@ -98,6 +102,45 @@ func TestFlags_validate(t *testing.T) {
 			false,
 			"",
 		},
+		{
+			"provision clusters: no error with a multi-cluster topology",
+			fields{
+				flagEnableMultiCluster: true,
+				flagProvisionClusters:  "kind",
+				flagClusterTopology:    "peered",
+			},
+			false,
+			"",
+		},
+		{
+			"provision clusters: errors with an unsupported tool",
+			fields{
+				flagProvisionClusters: "minikube",
+				flagClusterTopology:   "single",
+			},
+			true,
+			`unsupported cluster provider "minikube", must be one of: kind, k3d`,
+		},
+		{
+			"provision clusters: errors when a kubecontext is provided",
+			fields{
+				flagKubecontext:       "foo",
+				flagProvisionClusters: "k3d",
+				flagClusterTopology:   "single",
+			},
+			true,
+			"the kubeconfig and kubecontext flags cannot be set with -provision-clusters",
+		},
+		{
+			"provision clusters: errors when -enable-multi-cluster is set with a single cluster",
+			fields{
+				flagEnableMultiCluster: true,
+				flagProvisionClusters:  "kind",
+				flagClusterTopology:    "single",
+			},
+			true,
+			"-enable-multi-cluster requires a multi-cluster -cluster-topology with -provision-clusters",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -107,6 +150,9 @@ func TestFlags_validate(t *testing.T) {
 				flagSecondaryKubecontext: tt.fields.flagSecondaryKubecontext,
 				flagEnableEnterprise:     tt.fields.flagEnableEnt,
 				flagEnterpriseLicense:    tt.fields.flagEntLicense,
+				flagKubecontext:          tt.fields.flagKubecontext,
+				flagProvisionClusters:    tt.fields.flagProvisionClusters,
+				flagClusterTopology:      tt.fields.flagClusterTopology,
 			}
 			err := tf.Validate()
 			if tt.wantErr {
@@ -0,0 +1,271 @@
+// Package localcluster creates local Kubernetes clusters with kind or k3d for
+// the acceptance tests, so that the tests can be run without a cluster set up
+// beforehand.
+package localcluster
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Provider is the tool the clusters are created with.
+type Provider string
+
+const (
+	Kind Provider = "kind"
+	K3d  Provider = "k3d"
+)
+
+// Topology is the set of clusters a test run needs.
+type Topology string
+
+const (
+	// Single is a single cluster.
+	Single Topology = "single"
+	// PrimarySecondary is a primary and a secondary cluster, e.g. for
+	// federated datacenters.
+	PrimarySecondary Topology = "primary-secondary"
+	// Peered is a pair of clusters whose nodes can reach each other, e.g. for
+	// peered or partitioned clusters that talk through mesh gateways.
+	Peered Topology = "peered"
+)
+
+const (
+	// PrimaryName and SecondaryName are the names of the clusters. They match
+	// the names of the clusters created in CI.
+	PrimaryName   = "dc1"
+	SecondaryName = "dc2"
+
+	// k3dNetwork is the Docker network the k3d clusters of a multi-cluster
+	// topology share so that their nodes can reach each other. kind clusters
+	// always share the "kind" network.
+	k3dNetwork = "consul-acceptance"
+)
+
+// ValidateProvider returns an error if the provider is not supported.
+func ValidateProvider(p string) error {
+	switch Provider(p) {
+	case Kind, K3d:
+		return nil
+	}
+	return fmt.Errorf("unsupported cluster provider %q, must be one of: %s, %s", p, Kind, K3d)
+}
+
+// ValidateTopology returns an error if the topology is not supported.
+func ValidateTopology(t string) error {
+	switch Topology(t) {
+	case Single, PrimarySecondary, Peered:
+		return nil
+	}
+	return fmt.Errorf("unsupported cluster topology %q, must be one of: %s, %s, %s", t, Single, PrimarySecondary, Peered)
+}
+
+// MultiCluster returns true if the topology has more than one cluster.
+func (t Topology) MultiCluster() bool {
+	return t == PrimarySecondary || t == Peered
+}
+
+// Cluster is a local cluster.
+type Cluster struct {
+	Name string
+	// Kubeconfig is the path to the kubeconfig file of the cluster and
+	// KubeContext the name of its context in that file.
+	Kubeconfig  string
+	KubeContext string
+
+	// created is true if the cluster was created rather than reused, and so
+	// is deleted on teardown.
+	created bool
+}
+
+// Provisioner creates and deletes the clusters of a topology.
+type Provisioner struct {
+	Provider Provider
+	// Image is the node image of the clusters, e.g. "kindest/node:v1.22.4".
+	// The default image of the provider is used if it is empty.
+	Image string
+	// KeepClusters keeps the clusters on teardown so that they are reused by
+	// the next test run.
+	KeepClusters bool
+	// KubeconfigDir is the directory the kubeconfig files of the clusters are
+	// written to. A temporary directory is used if it is empty.
+	KubeconfigDir string
+
+	// run runs the command and returns its combined output. It runs the
+	// command with os/exec if nil, and is only set in tests.
+	run func(name string, args ...string) (string, error)
+
+	// mu guards clusters since Teardown may be called when the tests are
+	// interrupted while it is already running.
+	mu       sync.Mutex
+	clusters []*Cluster
+}
+
+// Provision creates the clusters of the topology and returns them, the
+// primary first. Clusters that already exist are reused. If a cluster fails to
+// be created, the clusters created so far are deleted.
+func (p *Provisioner) Provision(topology Topology) ([]*Cluster, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.KubeconfigDir == "" {
+		dir, err := ioutil.TempDir("", "consul-acceptance-kubeconfig")
+		if err != nil {
+			return nil, err
+		}
+		p.KubeconfigDir = dir
+	}
+
+	names := []string{PrimaryName}
+	if topology.MultiCluster() {
+		names = append(names, SecondaryName)
+	}
+	for _, name := range names {
+		cluster, err := p.create(name, topology.MultiCluster())
+		if err != nil {
+			if teardownErr := p.teardown(); teardownErr != nil {
+				err = fmt.Errorf("%s; tearing down: %s", err, teardownErr)
+			}
+			return nil, err
+		}
+		p.clusters = append(p.clusters, cluster)
+	}
+	return p.clusters, nil
+}
+
+// Teardown deletes the clusters created by Provision unless KeepClusters is
+// set. It tries to delete all of them even if deleting one fails. It is safe
+// to call more than once.
+func (p *Provisioner) Teardown() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.teardown()
+}
+
+func (p *Provisioner) teardown() error {
+	var errs []string
+	for _, cluster := range p.clusters {
+		if !cluster.created || p.KeepClusters {
+			continue
+		}
+		if _, err := p.exec(p.deleteArgs(cluster.Name)...); err != nil {
+			errs = append(errs, fmt.Sprintf("deleting cluster %s: %s", cluster.Name, err))
+			continue
+		}
+		cluster.created = false
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// create creates the cluster if it doesn't exist and writes its kubeconfig.
+func (p *Provisioner) create(name string, multiCluster bool) (*Cluster, error) {
+	cluster := &Cluster{
+		Name:        name,
+		Kubeconfig:  filepath.Join(p.KubeconfigDir, name+".yaml"),
+		KubeContext: fmt.Sprintf("%s-%s", p.Provider, name),
+	}
+
+	exists, err := p.exists(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if _, err := p.exec(p.createArgs(name, multiCluster)...); err != nil {
+			return nil, fmt.Errorf("creating cluster %s: %s", name, err)
+		}
+		cluster.created = true
+	}
+
+	if _, err := p.exec(p.kubeconfigArgs(name, cluster.Kubeconfig)...); err != nil {
+		// Keep track of the cluster so that it is deleted on teardown.
+		if cluster.created {
+			p.clusters = append(p.clusters, cluster)
+		}
+		return nil, fmt.Errorf("writing kubeconfig of cluster %s: %s", name, err)
+	}
+	return cluster, nil
+}
+
+// exists returns true if the cluster already exists.
+func (p *Provisioner) exists(name string) (bool, error) {
+	switch p.Provider {
+	case Kind:
+		out, err := p.exec("kind", "get", "clusters")
+		if err != nil {
+			return false, err
+		}
+		for _, line := range strings.Split(out, "\n") {
+			if strings.TrimSpace(line) == name {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		// k3d fails to get a cluster that doesn't exist.
+		_, err := p.exec("k3d", "cluster", "get", name)
+		return err == nil, nil
+	}
+}
+
+func (p *Provisioner) createArgs(name string, multiCluster bool) []string {
+	switch p.Provider {
+	case Kind:
+		args := []string{"kind", "create", "cluster", "--name", name, "--wait", "5m"}
+		if p.Image != "" {
+			args = append(args, "--image", p.Image)
+		}
+		return args
+	default:
+		args := []string{"k3d", "cluster", "create", name, "--wait",
+			"--kubeconfig-update-default=false", "--kubeconfig-switch-context=false"}
+		if multiCluster {
+			args = append(args, "--network", k3dNetwork)
+		}
+		if p.Image != "" {
+			args = append(args, "--image", p.Image)
+		}
+		return args
+	}
+}
+
+func (p *Provisioner) kubeconfigArgs(name, path string) []string {
+	if p.Provider == Kind {
+		return []string{"kind", "export", "kubeconfig", "--name", name, "--kubeconfig", path}
+	}
+	return []string{"k3d", "kubeconfig", "write", name, "--output", path}
+}
+
+func (p *Provisioner) deleteArgs(name string) []string {
+	if p.Provider == Kind {
+		return []string{"kind", "delete", "cluster", "--name", name}
+	}
+	return []string{"k3d", "cluster", "delete", name}
+}
+
+// exec runs the command and returns its output.
+func (p *Provisioner) exec(args ...string) (string, error) {
+	if p.run != nil {
+		return p.run(args[0], args[1:]...)
+	}
+
+	var out bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	// Creating a cluster takes a while, so the commands are shown.
+	fmt.Fprintf(os.Stderr, "Running %s\n", strings.Join(args, " "))
+	if err := cmd.Run(); err != nil {
+		return out.String(), fmt.Errorf("%s: %s: %s", strings.Join(args, " "), err, strings.TrimSpace(out.String()))
+	}
+	return out.String(), nil
+}
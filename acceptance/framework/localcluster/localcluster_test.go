@@ -0,0 +1,127 @@
+package localcluster
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvisioner(t *testing.T) {
+	cases := map[string]struct {
+		provider    Provider
+		topology    Topology
+		existing    []string
+		failCreate  string
+		expCommands []string
+		expErr      string
+	}{
+		"kind single": {
+			provider: Kind,
+			topology: Single,
+			expCommands: []string{
+				"kind get clusters",
+				"kind create cluster --name dc1 --wait 5m --image kindest/node:v1.22.4",
+				"kind export kubeconfig --name dc1 --kubeconfig /tmp/kube/dc1.yaml",
+				"kind delete cluster --name dc1",
+			},
+		},
+		"kind peered reuses existing cluster": {
+			provider: Kind,
+			topology: Peered,
+			existing: []string{"dc1"},
+			expCommands: []string{
+				"kind get clusters",
+				"kind export kubeconfig --name dc1 --kubeconfig /tmp/kube/dc1.yaml",
+				"kind get clusters",
+				"kind create cluster --name dc2 --wait 5m --image kindest/node:v1.22.4",
+				"kind export kubeconfig --name dc2 --kubeconfig /tmp/kube/dc2.yaml",
+				"kind delete cluster --name dc2",
+			},
+		},
+		"k3d primary-secondary": {
+			provider: K3d,
+			topology: PrimarySecondary,
+			expCommands: []string{
+				"k3d cluster get dc1",
+				"k3d cluster create dc1 --wait --kubeconfig-update-default=false --kubeconfig-switch-context=false --network consul-acceptance --image kindest/node:v1.22.4",
+				"k3d kubeconfig write dc1 --output /tmp/kube/dc1.yaml",
+				"k3d cluster get dc2",
+				"k3d cluster create dc2 --wait --kubeconfig-update-default=false --kubeconfig-switch-context=false --network consul-acceptance --image kindest/node:v1.22.4",
+				"k3d kubeconfig write dc2 --output /tmp/kube/dc2.yaml",
+				"k3d cluster delete dc1",
+				"k3d cluster delete dc2",
+			},
+		},
+		"failure tears down created clusters": {
+			provider:   Kind,
+			topology:   PrimarySecondary,
+			failCreate: "dc2",
+			expCommands: []string{
+				"kind get clusters",
+				"kind create cluster --name dc1 --wait 5m --image kindest/node:v1.22.4",
+				"kind export kubeconfig --name dc1 --kubeconfig /tmp/kube/dc1.yaml",
+				"kind get clusters",
+				"kind create cluster --name dc2 --wait 5m --image kindest/node:v1.22.4",
+				"kind delete cluster --name dc1",
+			},
+			expErr: "creating cluster dc2: no space left",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var commands []string
+			p := &Provisioner{
+				Provider:      c.provider,
+				Image:         "kindest/node:v1.22.4",
+				KubeconfigDir: "/tmp/kube",
+				run: func(name string, args ...string) (string, error) {
+					command := strings.Join(append([]string{name}, args...), " ")
+					commands = append(commands, command)
+					switch {
+					case command == "kind get clusters":
+						return strings.Join(c.existing, "\n"), nil
+					case strings.HasPrefix(command, "k3d cluster get "):
+						return "", errors.New("not found")
+					case c.failCreate != "" && strings.Contains(command, "create") && strings.Contains(command, c.failCreate):
+						return "", errors.New("no space left")
+					}
+					return "", nil
+				},
+			}
+
+			clusters, err := p.Provision(c.topology)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, "dc1", clusters[0].Name)
+				require.Equal(t, string(c.provider)+"-dc1", clusters[0].KubeContext)
+				require.Equal(t, "/tmp/kube/dc1.yaml", clusters[0].Kubeconfig)
+				require.NoError(t, p.Teardown())
+				// A second teardown doesn't delete the clusters again.
+				require.NoError(t, p.Teardown())
+			}
+			require.Equal(t, c.expCommands, commands)
+		})
+	}
+}
+
+func TestProvisioner_KeepClusters(t *testing.T) {
+	var commands []string
+	p := &Provisioner{
+		Provider:      Kind,
+		KeepClusters:  true,
+		KubeconfigDir: "/tmp/kube",
+		run: func(name string, args ...string) (string, error) {
+			commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+			return "", nil
+		},
+	}
+	_, err := p.Provision(Single)
+	require.NoError(t, err)
+	require.NoError(t, p.Teardown())
+	require.NotContains(t, commands, "kind delete cluster --name dc1")
+}
@@ -4,11 +4,15 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
 	"testing"
 
 	"github.com/hashicorp/consul-k8s/acceptance/framework/config"
 	"github.com/hashicorp/consul-k8s/acceptance/framework/environment"
 	"github.com/hashicorp/consul-k8s/acceptance/framework/flags"
+	"github.com/hashicorp/consul-k8s/acceptance/framework/localcluster"
 )
 
 type suite struct {
@@ -56,9 +60,64 @@ func (s *suite) Run() int {
 		}
 	}
 
+	if s.cfg.ProvisionClusters != "" {
+		provisioner, err := s.provisionClusters()
+		if err != nil {
+			fmt.Printf("Failed to provision clusters: %s\n", err)
+			return 1
+		}
+		defer teardownClusters(provisioner)
+
+		// Tear the clusters down if the tests are interrupted, since the
+		// deferred teardown doesn't run then.
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signals)
+		go func() {
+			if _, ok := <-signals; ok {
+				teardownClusters(provisioner)
+				os.Exit(1)
+			}
+		}()
+	}
+
 	return s.m.Run()
 }
 
+// provisionClusters creates the clusters of the configured topology and
+// points the test environment at them.
+func (s *suite) provisionClusters() (*localcluster.Provisioner, error) {
+	provisioner := &localcluster.Provisioner{
+		Provider:     localcluster.Provider(s.cfg.ProvisionClusters),
+		Image:        s.cfg.ClusterImage,
+		KeepClusters: s.cfg.KeepClusters,
+	}
+	topology := localcluster.Topology(s.cfg.ClusterTopology)
+	clusters, err := provisioner.Provision(topology)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cfg.Kubeconfig = clusters[0].Kubeconfig
+	s.cfg.KubeContext = clusters[0].KubeContext
+	if topology.MultiCluster() {
+		s.cfg.EnableMultiCluster = true
+		s.cfg.SecondaryKubeconfig = clusters[1].Kubeconfig
+		s.cfg.SecondaryKubeContext = clusters[1].KubeContext
+	}
+	// kind and k3d clusters have no load balancers and their nodes share a
+	// Docker network, which the tests handle the same way.
+	s.cfg.UseKind = true
+	s.env = environment.NewKubernetesEnvironmentFromConfig(s.cfg)
+	return provisioner, nil
+}
+
+func teardownClusters(provisioner *localcluster.Provisioner) {
+	if err := provisioner.Teardown(); err != nil {
+		fmt.Printf("Failed to tear down clusters: %s\n", err)
+	}
+}
+
 func (s *suite) Environment() environment.TestEnvironment {
 	return s.env
 }
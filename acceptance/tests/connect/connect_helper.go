@@ -8,6 +8,7 @@ import (
 	"github.com/hashicorp/consul-k8s/acceptance/framework/config"
 	"github.com/hashicorp/consul-k8s/acceptance/framework/consul"
 	"github.com/hashicorp/consul-k8s/acceptance/framework/environment"
+	"github.com/hashicorp/consul-k8s/acceptance/framework/envoy"
 	"github.com/hashicorp/consul-k8s/acceptance/framework/helpers"
 	"github.com/hashicorp/consul-k8s/acceptance/framework/k8s"
 	"github.com/hashicorp/consul-k8s/acceptance/framework/logger"
@@ -122,6 +123,13 @@ func (c *ConnectHelper) DeployClientAndServer(t *testing.T) {
 		require.NoError(t, err)
 		require.Len(t, podList.Items, 1)
 		require.Len(t, podList.Items[0].Spec.Containers, 2)
+
+		// Check that the proxy of static-client has a cluster for its
+		// explicit static-server upstream. Upstreams are only inferred from
+		// intentions with transparent proxy, which are created later.
+		if labelSelector == "app=static-client" && !c.Cfg.EnableTransparentProxy {
+			envoy.ExpectUpstreamCluster(t, c.Ctx.KubectlOptions(t), podList.Items[0].Name, staticServerName)
+		}
 	}
 }
 
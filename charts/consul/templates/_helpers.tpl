@@ -59,6 +59,83 @@ as well as the global.name setting.
 {{- if .Values.global.tls -}}{{- if .Values.global.tls.serverAdditionalIPSANs -}}{{- range $ipsan := .Values.global.tls.serverAdditionalIPSANs }},{{ $ipsan }} {{- end -}}{{- end -}}{{- end -}}
 {{- end -}}
 
+{{/*
+Outputs the DNS names of the webhook service of a component as a YAML list.
+It takes a list of the root context and the name of the service without the
+release prefix, e.g. (list . "connect-injector").
+*/}}
+{{- define "consul.webhookDNSNames" -}}
+{{- $root := index . 0 -}}
+{{- $name := printf "%s-%s" (include "consul.fullname" $root) (index . 1) -}}
+- {{ $name }}
+- {{ $name }}.{{ $root.Release.Namespace }}
+- {{ $name }}.{{ $root.Release.Namespace }}.svc
+- {{ $name }}.{{ $root.Release.Namespace }}.svc.cluster.local
+{{- end -}}
+
+{{/*
+Vault agent templates of the webhook certificate of a component when
+webhookCertManager.source is "vault". They take the same arguments as
+consul.webhookDNSNames.
+*/}}
+{{- define "consul.webhookTLSCertTemplate" -}}
+{{- $root := index . 0 -}}
+{{- $name := printf "%s-%s" (include "consul.fullname" $root) (index . 1) -}}
+ |
+            {{ "{{" }}- with secret "{{ $root.Values.webhookCertManager.vault.pkiPath }}" "{{ printf "common_name=%s.%s.svc" $name $root.Release.Namespace }}"
+            "{{ printf "alt_names=%s,%s.%s,%s.%s.svc.cluster.local" $name $name $root.Release.Namespace $name $root.Release.Namespace }}" -{{ "}}" }}
+            {{ "{{" }}- .Data.certificate -{{ "}}" }}
+            {{ "{{" }}- end -{{ "}}" }}
+{{- end -}}
+
+{{- define "consul.webhookTLSKeyTemplate" -}}
+{{- $root := index . 0 -}}
+{{- $name := printf "%s-%s" (include "consul.fullname" $root) (index . 1) -}}
+ |
+            {{ "{{" }}- with secret "{{ $root.Values.webhookCertManager.vault.pkiPath }}" "{{ printf "common_name=%s.%s.svc" $name $root.Release.Namespace }}"
+            "{{ printf "alt_names=%s,%s.%s,%s.%s.svc.cluster.local" $name $name $root.Release.Namespace $name $root.Release.Namespace }}" -{{ "}}" }}
+            {{ "{{" }}- .Data.private_key -{{ "}}" }}
+            {{ "{{" }}- end -{{ "}}" }}
+{{- end -}}
+
+{{- define "consul.webhookCATemplate" -}}
+ |
+            {{ "{{" }}- with secret "{{ .Values.webhookCertManager.vault.caPath }}" -{{ "}}" }}
+            {{ "{{" }}- .Data.certificate -{{ "}}" }}
+            {{ "{{" }}- end -{{ "}}" }}
+{{- end -}}
+
+{{/*
+Sets the flags of the tls-init command that generate the server certificate.
+The shell that runs the command must set $NAMESPACE and disable globbing.
+*/}}
+{{- define "consul.tlsInitFlags" -}}
+-log-level={{ .Values.global.logLevel }} \
+-log-json={{ .Values.global.logJSON }} \
+-domain={{ .Values.global.domain }} \
+-days=730 \
+-name-prefix={{ template "consul.fullname" . }} \
+-k8s-namespace=${NAMESPACE} \
+{{- if (and .Values.global.tls.caCert.secretName .Values.global.tls.caKey.secretName) }}
+-ca=/consul/tls/ca/cert/tls.crt \
+-key=/consul/tls/ca/key/tls.key \
+{{- end }}
+-additional-dnsname="{{ template "consul.fullname" . }}-server" \
+-additional-dnsname="*.{{ template "consul.fullname" . }}-server" \
+-additional-dnsname="*.{{ template "consul.fullname" . }}-server.${NAMESPACE}" \
+-additional-dnsname="{{ template "consul.fullname" . }}-server.${NAMESPACE}" \
+-additional-dnsname="*.{{ template "consul.fullname" . }}-server.${NAMESPACE}.svc" \
+-additional-dnsname="{{ template "consul.fullname" . }}-server.${NAMESPACE}.svc" \
+-additional-dnsname="*.server.{{ .Values.global.datacenter }}.{{ .Values.global.domain }}" \
+{{- range .Values.global.tls.serverAdditionalIPSANs }}
+-additional-ipaddress={{ . }} \
+{{- end }}
+{{- range .Values.global.tls.serverAdditionalDNSSANs }}
+-additional-dnsname={{ . }} \
+{{- end }}
+-dc={{ .Values.global.datacenter }}
+{{- end -}}
+
 {{- define "consul.vaultReplicationTokenTemplate" -}}
 |
           {{ "{{" }}- with secret "{{ .Values.global.acls.replicationToken.secretName }}" -{{ "}}" }}
@@ -120,6 +197,21 @@ Expand the name of the chart.
 {{- default .Chart.Name .Values.nameOverride | trunc 63 | trimSuffix "-" -}}
 {{- end -}}
 
+{{/*
+The objectSelector of the connect injector webhook. The pods of this chart are
+always excluded so that the webhook can't block them from being scheduled,
+including the connect injector itself.
+*/}}
+{{- define "consul.connectInjectObjectSelector" -}}
+{{- $selector := dict -}}
+{{- if .Values.connectInject.objectSelector -}}
+{{- $selector = tpl .Values.connectInject.objectSelector . | fromYaml -}}
+{{- end -}}
+{{- $exclude := dict "key" "app" "operator" "NotIn" "values" (list (include "consul.name" .)) -}}
+{{- $_ := set $selector "matchExpressions" (append (default (list) $selector.matchExpressions) $exclude) -}}
+{{- toYaml $selector -}}
+{{- end -}}
+
 {{/*
 Compute the maximum number of unavailable replicas for the PodDisruptionBudget.
 This defaults to (n/2)-1 where n is the number of members of the server cluster.
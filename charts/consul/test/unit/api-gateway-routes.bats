@@ -0,0 +1,112 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "apiGateway/Routes: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/api-gateway-routes.yaml  \
+      --set 'apiGateway.enabled=true' \
+      --set 'apiGateway.image=foo' \
+      --set 'global.openshift.enabled=true' \
+      .
+}
+
+@test "apiGateway/Routes: disabled with global.openshift.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/api-gateway-routes.yaml  \
+      --set 'apiGateway.enabled=true' \
+      --set 'apiGateway.image=foo' \
+      --set 'apiGateway.routes[0].gateway=gateway' \
+      --set 'apiGateway.routes[0].port=https' \
+      .
+}
+
+@test "apiGateway/Routes: creates a Route for the Service of the Gateway" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/api-gateway-routes.yaml  \
+      --set 'apiGateway.enabled=true' \
+      --set 'apiGateway.image=foo' \
+      --set 'global.openshift.enabled=true' \
+      --set 'apiGateway.routes[0].gateway=gateway' \
+      --set 'apiGateway.routes[0].namespace=apps' \
+      --set 'apiGateway.routes[0].host=gateway.apps.example.com' \
+      --set 'apiGateway.routes[0].port=https' \
+      . | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.metadata.namespace' | tee /dev/stderr)
+  [ "${actual}" = "apps" ]
+
+  local actual=$(echo "$object" | yq -r '.spec.to.name' | tee /dev/stderr)
+  [ "${actual}" = "gateway" ]
+
+  local actual=$(echo "$object" | yq -r '.spec.host' | tee /dev/stderr)
+  [ "${actual}" = "gateway.apps.example.com" ]
+
+  local actual=$(echo "$object" | yq -r '.spec.port.targetPort' | tee /dev/stderr)
+  [ "${actual}" = "https" ]
+
+  local actual=$(echo "$object" | yq -r '.spec.tls.termination' | tee /dev/stderr)
+  [ "${actual}" = "passthrough" ]
+}
+
+@test "apiGateway/Routes: namespace defaults to the release namespace" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/api-gateway-routes.yaml  \
+      --namespace foo \
+      --set 'apiGateway.enabled=true' \
+      --set 'apiGateway.image=foo' \
+      --set 'global.openshift.enabled=true' \
+      --set 'apiGateway.routes[0].gateway=gateway' \
+      --set 'apiGateway.routes[0].port=https' \
+      . | tee /dev/stderr |
+      yq -r '.metadata.namespace' | tee /dev/stderr)
+  [ "${actual}" = "foo" ]
+}
+
+@test "apiGateway/Routes: no TLS with termination none" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/api-gateway-routes.yaml  \
+      --set 'apiGateway.enabled=true' \
+      --set 'apiGateway.image=foo' \
+      --set 'global.openshift.enabled=true' \
+      --set 'apiGateway.routes[0].gateway=gateway' \
+      --set 'apiGateway.routes[0].port=http' \
+      --set 'apiGateway.routes[0].termination=none' \
+      . | tee /dev/stderr |
+      yq -r '.spec.tls' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "apiGateway/Routes: creates a Route per entry" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/api-gateway-routes.yaml  \
+      --set 'apiGateway.enabled=true' \
+      --set 'apiGateway.image=foo' \
+      --set 'global.openshift.enabled=true' \
+      --set 'apiGateway.routes[0].gateway=first' \
+      --set 'apiGateway.routes[0].port=https' \
+      --set 'apiGateway.routes[1].gateway=second' \
+      --set 'apiGateway.routes[1].port=https' \
+      . | tee /dev/stderr |
+      yq -s -r '[.[].metadata.name] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "first,second" ]
+}
+
+@test "apiGateway/Routes: fails without port" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/api-gateway-routes.yaml  \
+      --set 'apiGateway.enabled=true' \
+      --set 'apiGateway.image=foo' \
+      --set 'global.openshift.enabled=true' \
+      --set 'apiGateway.routes[0].gateway=gateway' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "apiGateway.routes must set port" ]]
+}
@@ -0,0 +1,52 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "apiProxy/APIService: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/api-proxy-apiservice.yaml  \
+      .
+}
+
+@test "apiProxy/APIService: registers the Consul API group" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/api-proxy-apiservice.yaml  \
+      --set 'apiProxy.enabled=true' \
+      . | tee /dev/stderr |
+      yq -s '.[] | select(.kind == "APIService") | .spec' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.group' | tee /dev/stderr)
+  [ "${actual}" = "api.consul.hashicorp.com" ]
+
+  actual=$(echo "$object" | yq -r '.service.name' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-api-proxy" ]
+
+  actual=$(echo "$object" | yq '.caBundle != null' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "apiProxy/APIService: creates the serving certificate" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/api-proxy-apiservice.yaml  \
+      --set 'apiProxy.enabled=true' \
+      . | tee /dev/stderr |
+      yq -s -r '.[] | select(.kind == "Secret") | .data | keys | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "ca.crt,tls.crt,tls.key" ]
+}
+
+@test "apiProxy/APIService: the CA bundle is the CA of the serving certificate" {
+  cd `chart_dir`
+  local objects=$(helm template \
+      -s templates/api-proxy-apiservice.yaml  \
+      --set 'apiProxy.enabled=true' \
+      . | tee /dev/stderr)
+
+  local ca=$(echo "$objects" |
+      yq -s -r '.[] | select(.kind == "Secret") | .data["ca.crt"]' | tee /dev/stderr)
+  local bundle=$(echo "$objects" |
+      yq -s -r '.[] | select(.kind == "APIService") | .spec.caBundle' | tee /dev/stderr)
+  [ "${ca}" = "${bundle}" ]
+}
@@ -0,0 +1,164 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "apiProxy/Deployment: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/api-proxy-deployment.yaml  \
+      .
+}
+
+@test "apiProxy/Deployment: enabled with apiProxy.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/api-proxy-deployment.yaml  \
+      --set 'apiProxy.enabled=true' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "apiProxy/Deployment: serves with the generated certificate" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/api-proxy-deployment.yaml  \
+      --set 'apiProxy.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$object" |
+      yq -r '.volumes[] | select(.name == "tls") | .secret.secretName' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-api-proxy-cert" ]
+
+  actual=$(echo "$object" |
+      yq -r '.containers[0].command | any(contains("-tls-cert-file=/consul/api-proxy/tls/tls.crt"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# global.tls.enabled
+
+@test "apiProxy/Deployment: connects to the servers over HTTP by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/api-proxy-deployment.yaml  \
+      --set 'apiProxy.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].env[] | select(.name == "CONSUL_HTTP_ADDR") | .value' | tee /dev/stderr)
+  [ "${actual}" = "http://release-name-consul-server:8500" ]
+}
+
+@test "apiProxy/Deployment: connects to the servers over HTTPS with global.tls.enabled" {
+  cd `chart_dir`
+  local env=$(helm template \
+      -s templates/api-proxy-deployment.yaml  \
+      --set 'apiProxy.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].env[]' | tee /dev/stderr)
+
+  local actual=$(echo "$env" |
+      jq -r '. | select(.name == "CONSUL_HTTP_ADDR") | .value' | tee /dev/stderr)
+  [ "${actual}" = "https://release-name-consul-server:8501" ]
+
+  actual=$(echo "$env" |
+      jq -r '. | select(.name == "CONSUL_CACERT") | .value' | tee /dev/stderr)
+  [ "${actual}" = "/consul/tls/ca/tls.crt" ]
+}
+
+#--------------------------------------------------------------------
+# externalServers
+
+@test "apiProxy/Deployment: connects to externalServers.hosts" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/api-proxy-deployment.yaml  \
+      --set 'apiProxy.enabled=true' \
+      --set 'server.enabled=false' \
+      --set 'externalServers.enabled=true' \
+      --set 'externalServers.hosts[0]=consul.example.com' \
+      --set 'externalServers.hosts[1]=consul2.example.com' \
+      --set 'externalServers.httpsPort=443' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].env[] | select(.name == "CONSUL_HTTP_ADDR") | .value' | tee /dev/stderr)
+  [ "${actual}" = "http://consul.example.com:443" ]
+}
+
+@test "apiProxy/Deployment: connects to externalServers.hosts over HTTPS with global.tls.enabled" {
+  cd `chart_dir`
+  local env=$(helm template \
+      -s templates/api-proxy-deployment.yaml  \
+      --set 'apiProxy.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'server.enabled=false' \
+      --set 'externalServers.enabled=true' \
+      --set 'externalServers.hosts[0]=consul.example.com' \
+      --set 'externalServers.tlsServerName=server.dc1.consul' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].env[]' | tee /dev/stderr)
+
+  local actual=$(echo "$env" |
+      jq -r '. | select(.name == "CONSUL_HTTP_ADDR") | .value' | tee /dev/stderr)
+  [ "${actual}" = "https://consul.example.com:8501" ]
+
+  actual=$(echo "$env" |
+      jq -r '. | select(.name == "CONSUL_TLS_SERVER_NAME") | .value' | tee /dev/stderr)
+  [ "${actual}" = "server.dc1.consul" ]
+
+  actual=$(echo "$env" |
+      jq -r '. | select(.name == "CONSUL_CACERT") | .value' | tee /dev/stderr)
+  [ "${actual}" = "/consul/tls/ca/tls.crt" ]
+}
+
+@test "apiProxy/Deployment: does not set CONSUL_CACERT with externalServers.useSystemRoots" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/api-proxy-deployment.yaml  \
+      --set 'apiProxy.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'server.enabled=false' \
+      --set 'externalServers.enabled=true' \
+      --set 'externalServers.hosts[0]=consul.example.com' \
+      --set 'externalServers.useSystemRoots=true' \
+      . | tee /dev/stderr |
+      yq '[.spec.template.spec.containers[0].env[].name] | any(contains("CONSUL_CACERT"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "apiProxy/Deployment: fails if externalServers.hosts is not set" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/api-proxy-deployment.yaml  \
+      --set 'apiProxy.enabled=true' \
+      --set 'server.enabled=false' \
+      --set 'externalServers.enabled=true' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "externalServers.hosts must be set if externalServers.enabled is true" ]]
+}
+
+#--------------------------------------------------------------------
+# aclToken
+
+@test "apiProxy/Deployment: CONSUL_HTTP_TOKEN is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/api-proxy-deployment.yaml  \
+      --set 'apiProxy.enabled=true' \
+      . | tee /dev/stderr |
+      yq '[.spec.template.spec.containers[0].env[].name] | any(contains("CONSUL_HTTP_TOKEN"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "apiProxy/Deployment: CONSUL_HTTP_TOKEN is set from apiProxy.aclToken" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/api-proxy-deployment.yaml  \
+      --set 'apiProxy.enabled=true' \
+      --set 'apiProxy.aclToken.secretName=foo' \
+      --set 'apiProxy.aclToken.secretKey=bar' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].env[] | select(.name == "CONSUL_HTTP_TOKEN") | .valueFrom.secretKeyRef | "\(.name)/\(.key)"' | tee /dev/stderr)
+  [ "${actual}" = "foo/bar" ]
+}
@@ -30,6 +30,15 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+@test "client/SnapshotAgentDeployment: disabled with client.snapshotAgent.schedules.enabled=true" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/client-snapshot-agent-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.schedules.enabled=true' \
+      .
+}
+
 @test "client/SnapshotAgentDeployment: disabled with client=false and client.snapshotAgent.enabled=true" {
   cd `chart_dir`
   assert_empty helm template \
@@ -0,0 +1,78 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "client/SnapshotAgentPodTemplate: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/client-snapshot-agent-podtemplate.yaml  \
+      .
+}
+
+@test "client/SnapshotAgentPodTemplate: disabled with client.snapshotAgent.enabled=true" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/client-snapshot-agent-podtemplate.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      .
+}
+
+@test "client/SnapshotAgentPodTemplate: enabled with client.snapshotAgent.schedules.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-snapshot-agent-podtemplate.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.schedules.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.template.spec.containers[0].name' | tee /dev/stderr)
+  [ "${actual}" = '"consul-snapshot-agent"' ]
+}
+
+@test "client/SnapshotAgentPodTemplate: disabled with client=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/client-snapshot-agent-podtemplate.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.schedules.enabled=true' \
+      --set 'client.enabled=false' \
+      .
+}
+
+@test "client/SnapshotAgentPodTemplate: fails without controller.enabled=true" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/client-snapshot-agent-podtemplate.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.schedules.enabled=true' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "controller.enabled must be true when client.snapshotAgent.schedules.enabled is true" ]]
+}
+
+@test "client/SnapshotAgentPodTemplate: passes the job arguments to the snapshot agent" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-snapshot-agent-podtemplate.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.schedules.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.template.spec.containers[0].command[2]' | tee /dev/stderr)
+  [[ "${actual}" =~ '/bin/consul snapshot agent "$@"' ]]
+  [[ ! "${actual}" =~ 'exec /bin/consul' ]]
+}
+
+@test "client/SnapshotAgentPodTemplate: logs out after the snapshot with global.acls.manageSystemACLs=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-snapshot-agent-podtemplate.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.schedules.enabled=true' \
+      --set 'global.acls.manageSystemACLs=true' \
+      . | tee /dev/stderr |
+      yq -r '.template.spec.containers[0].command[2]' | tee /dev/stderr)
+  [[ "${actual}" =~ '/bin/consul logout || true' ]]
+}
@@ -53,3 +53,148 @@ load _helpers
       yq -r '.rules | map(select(.resources[0] == "podsecuritypolicies")) | length' | tee /dev/stderr)
   [ "${actual}" = "1" ]
 }
+
+#--------------------------------------------------------------------
+# dns.coreDNS and dns.nodeLocalDNS
+
+@test "connectInject/ClusterRole: no configmaps access by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "configmaps")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "connectInject/ClusterRole: allows updating the CoreDNS and node-local-dns configmaps" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'dns.coreDNS.enabled=true' \
+      --set 'dns.nodeLocalDNS.enabled=true' \
+      . | tee /dev/stderr |
+      yq -c '.rules | map(select(.resources[0] == "configmaps"))[0]' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -c '.resourceNames' | tee /dev/stderr)
+  [ "${actual}" = '["coredns","node-local-dns"]' ]
+
+  local actual=$(echo $object | yq -c '.verbs' | tee /dev/stderr)
+  [ "${actual}" = '["get","update"]' ]
+}
+
+#--------------------------------------------------------------------
+# connectInject.namespaceSidecarConfig
+
+@test "connectInject/ClusterRole: allows getting the namespace sidecar config configmaps" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.namespaceSidecarConfig.enabled=true' \
+      . | tee /dev/stderr |
+      yq -c '.rules | map(select(.resources[0] == "configmaps"))[0]' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -c '.resourceNames' | tee /dev/stderr)
+  [ "${actual}" = '["consul-sidecar-config"]' ]
+
+  local actual=$(echo $object | yq -c '.verbs' | tee /dev/stderr)
+  [ "${actual}" = '["get"]' ]
+}
+
+#--------------------------------------------------------------------
+# connectInject.envoyBootstrapConfigMaps
+
+@test "connectInject/ClusterRole: no configmaps access by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "configmaps")) | length' | tee /dev/stderr)
+
+  [ "${actual}" = "0" ]
+}
+
+@test "connectInject/ClusterRole: allows getting the Envoy bootstrap configmaps of namespaces" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.envoyBootstrapConfigMaps.enabled=true' \
+      . | tee /dev/stderr |
+      yq -c '.rules | map(select(.resources[0] == "configmaps"))[0]' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.resourceNames' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+
+  local actual=$(echo $object | yq -c '.verbs' | tee /dev/stderr)
+  [ "${actual}" = '["get"]' ]
+}
+
+#--------------------------------------------------------------------
+# connectInject.networkPolicies
+
+@test "connectInject/ClusterRole: no networkpolicies access by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "networkpolicies")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "connectInject/ClusterRole: allows managing networkpolicies with connectInject.networkPolicies.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.networkPolicies.enabled=true' \
+      . | tee /dev/stderr |
+      yq -c '.rules | map(select(.resources[0] == "networkpolicies"))[0].verbs' | tee /dev/stderr)
+  [ "${actual}" = '["create","delete","get","list","update","watch"]' ]
+}
+
+#--------------------------------------------------------------------
+# connectInject.rollouts
+
+@test "connectInject/ClusterRole: no rollouts or canaries access by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "rollouts" or .resources[0] == "canaries")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "connectInject/ClusterRole: allows getting rollouts and canaries with connectInject.rollouts.enabled=true" {
+  cd `chart_dir`
+  local rules=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.rollouts.enabled=true' \
+      . | tee /dev/stderr |
+      yq -c '.rules' | tee /dev/stderr)
+
+  local actual=$(echo "$rules" | yq -c 'map(select(.resources[0] == "rollouts"))[0]' | tee /dev/stderr)
+  [ "${actual}" = '{"apiGroups":["argoproj.io"],"resources":["rollouts"],"verbs":["get"]}' ]
+
+  local actual=$(echo "$rules" | yq -c 'map(select(.resources[0] == "canaries"))[0]' | tee /dev/stderr)
+  [ "${actual}" = '{"apiGroups":["flagger.app"],"resources":["canaries"],"verbs":["get"]}' ]
+}
+
+#--------------------------------------------------------------------
+# nodes
+
+@test "connectInject/ClusterRole: allows reading nodes for service locality" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -c '.rules | map(select(.resources[0] == "nodes"))[0]' | tee /dev/stderr)
+  [ "${actual}" = '{"apiGroups":[""],"resources":["nodes"],"verbs":["get","list","watch"]}' ]
+}
@@ -390,6 +390,90 @@ EOF
 }
 
 
+#--------------------------------------------------------------------
+# global.clusterID
+
+@test "connectInject/Deployment: -cluster-id is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-cluster-id"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: -cluster-id is set when global.clusterID is set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.clusterID=us-east-1-prod' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-cluster-id=us-east-1-prod"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# global.audit
+
+@test "connectInject/Deployment: -enable-audit is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-audit"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: -enable-audit is set when global.audit.enabled is true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.audit.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | (any(contains("-enable-audit=true")) and (any(contains("-audit-events")) | not) and (any(contains("-audit-webhook-url")) | not))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: -audit-events is set when global.audit.events is true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.audit.enabled=true' \
+      --set 'global.audit.events=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-audit-events=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: -audit-webhook-url is set when global.audit.webhookURL is set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.audit.enabled=true' \
+      --set 'global.audit.webhookURL=https://audit.example.com/consul' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-audit-webhook-url") and contains("https://audit.example.com/consul"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: audit flags are not set when global.audit.enabled is false" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.audit.events=true' \
+      --set 'global.audit.webhookURL=https://audit.example.com/consul' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | (any(contains("-enable-audit")) or any(contains("-audit-")))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
 #--------------------------------------------------------------------
 # affinity
 
@@ -519,6 +603,61 @@ EOF
   [ "${actual}" = "true" ]
 }
 
+#--------------------------------------------------------------------
+# projectedServiceAccountToken
+
+@test "connectInject/Deployment: projected service account tokens are disabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-projected-service-account-token"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: projected service account token flags are set when connectInject.projectedServiceAccountToken.enabled=true" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.projectedServiceAccountToken.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-enable-projected-service-account-token=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-projected-service-account-token-expiration=1h"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-projected-service-account-token-audience"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: projected service account token audience and expiration can be set" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.projectedServiceAccountToken.enabled=true' \
+      --set 'connectInject.projectedServiceAccountToken.audience=consul' \
+      --set 'connectInject.projectedServiceAccountToken.expiration=20m' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-projected-service-account-token-audience=\"consul\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-projected-service-account-token-expiration=20m"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
 #--------------------------------------------------------------------
 # DNS
 
@@ -553,6 +692,56 @@ EOF
   [ "${actual}" = "true" ]
 }
 
+@test "connectInject/Deployment: CoreDNS and node-local-dns flags unset by default" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -c -r '.spec.template.spec.containers[0].command | join(" ")' | tee /dev/stderr)
+
+  local actual=$(echo $cmd | yq 'contains("-coredns-config-map")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+
+  local actual=$(echo $cmd | yq 'contains("-node-local-dns-config-map")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: -coredns-config-map set when dns.coreDNS.enabled=true" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml \
+      --set 'connectInject.enabled=true' \
+      --set 'dns.coreDNS.enabled=true' \
+      --set 'dns.coreDNS.configMapName=custom-coredns' \
+      --set 'global.domain=mesh' \
+      . | tee /dev/stderr |
+      yq -c -r '.spec.template.spec.containers[0].command | join(" ")' | tee /dev/stderr)
+
+  local actual=$(echo $cmd | yq 'contains("-coredns-config-map=kube-system/custom-coredns")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $cmd | yq 'contains("-consul-dns-domain=mesh")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: -node-local-dns-config-map set when dns.nodeLocalDNS.enabled=true" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml \
+      --set 'connectInject.enabled=true' \
+      --set 'dns.nodeLocalDNS.enabled=true' \
+      --set 'dns.nodeLocalDNS.bindAddress=169.254.0.10' \
+      . | tee /dev/stderr |
+      yq -c -r '.spec.template.spec.containers[0].command | join(" ")' | tee /dev/stderr)
+
+  local actual=$(echo $cmd | yq 'contains("-node-local-dns-config-map=kube-system/node-local-dns")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $cmd | yq 'contains("-node-local-dns-bind-address=169.254.0.10")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
 #--------------------------------------------------------------------
 # global.tls.enabled
 
@@ -879,6 +1068,35 @@ EOF
   [ "${actual}" = "true" ]
 }
 
+@test "connectInject/Deployment: mirroring rules can be set with connectInject.consulNamespaces.mirroringK8SRules" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.enableConsulNamespaces=true' \
+      --set 'connectInject.consulNamespaces.mirroringK8S=true' \
+      --set 'connectInject.consulNamespaces.mirroringK8SRules[0].match=team-(.*)' \
+      --set 'connectInject.consulNamespaces.mirroringK8SRules[0].replace=$1' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-k8s-namespace-mirroring-rules=") and contains("{\"match\":\"team-(.*)\",\"replace\":\"$1\"}"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: fails if mirroring rules are set with global.acls.manageSystemACLs" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.enableConsulNamespaces=true' \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'connectInject.consulNamespaces.mirroringK8S=true' \
+      --set 'connectInject.consulNamespaces.mirroringK8SRules[0].match=team-(.*)' \
+      --set 'connectInject.consulNamespaces.mirroringK8SRules[0].replace=$1' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "connectInject.consulNamespaces.mirroringK8SRules is not supported with global.acls.manageSystemACLs" ]]
+}
+
 #--------------------------------------------------------------------
 # acl tokens
 
@@ -1699,110 +1917,726 @@ EOF
 }
 
 #--------------------------------------------------------------------
-# openshift
+# transparentProxy.initMode
 
-@test "connectInject/Deployment: openshift is is not set by default" {
+@test "connectInject/Deployment: transparent proxy init mode is auto by default" {
   cd `chart_dir`
-  local actual=$(helm template \
+  local cmd=$(helm template \
       -s templates/connect-inject-deployment.yaml  \
       --set 'connectInject.enabled=true' \
       . | tee /dev/stderr |
-      yq '.spec.template.spec.containers[0].command | any(contains("-enable-openshift"))' | tee /dev/stderr)
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-transparent-proxy-init-mode=auto"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
 
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-enable-transparent-proxy-node-helper"))' | tee /dev/stderr)
   [ "${actual}" = "false" ]
 }
 
-@test "connectInject/Deployment: -enable-openshift is set when global.openshift.enabled is true" {
+@test "connectInject/Deployment: transparent proxy init mode can be set" {
   cd `chart_dir`
   local actual=$(helm template \
       -s templates/connect-inject-deployment.yaml  \
       --set 'connectInject.enabled=true' \
-      --set 'global.openshift.enabled=true' \
+      --set 'connectInject.transparentProxy.initMode=net-admin' \
       . | tee /dev/stderr |
-      yq '.spec.template.spec.containers[0].command | any(contains("-enable-openshift"))' | tee /dev/stderr)
+      yq '.spec.template.spec.containers[0].command | any(contains("-transparent-proxy-init-mode=net-admin"))' | tee /dev/stderr)
 
   [ "${actual}" = "true" ]
 }
 
+@test "connectInject/Deployment: fails with invalid transparent proxy init mode" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.transparentProxy.initMode=foo' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "connectInject.transparentProxy.initMode must be one of" ]]
+}
 
-#--------------------------------------------------------------------
-# replicas
+@test "connectInject/Deployment: fails with node-helper init mode if the node helper is disabled" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.transparentProxy.initMode=node-helper' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "connectInject.transparentProxy.nodeHelper.enabled must be true if connectInject.transparentProxy.initMode is node-helper" ]]
+}
 
-@test "connectInject/Deployment: replicas defaults to 2" {
+@test "connectInject/Deployment: node helper is enabled with connectInject.transparentProxy.nodeHelper.enabled" {
   cd `chart_dir`
   local actual=$(helm template \
       -s templates/connect-inject-deployment.yaml  \
       --set 'connectInject.enabled=true' \
+      --set 'connectInject.transparentProxy.initMode=node-helper' \
+      --set 'connectInject.transparentProxy.nodeHelper.enabled=true' \
       . | tee /dev/stderr |
-      yq '.spec.replicas' | tee /dev/stderr)
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-transparent-proxy-node-helper=true"))' | tee /dev/stderr)
 
-  [ "${actual}" = "2" ]
+  [ "${actual}" = "true" ]
 }
 
-@test "connectInject/Deployment: replicas can be set" {
+#--------------------------------------------------------------------
+# nodeProxy
+
+@test "connectInject/Deployment: node proxy is disabled by default" {
   cd `chart_dir`
   local actual=$(helm template \
       -s templates/connect-inject-deployment.yaml  \
       --set 'connectInject.enabled=true' \
-      --set 'connectInject.replicas=3' \
       . | tee /dev/stderr |
-      yq '.spec.replicas' | tee /dev/stderr)
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-node-proxy"))' | tee /dev/stderr)
 
-  [ "${actual}" = "3" ]
+  [ "${actual}" = "false" ]
 }
 
-#--------------------------------------------------------------------
-# Vault
+@test "connectInject/Deployment: fails with node proxy enabled if the node helper is disabled" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.nodeProxy.enabled=true' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "connectInject.transparentProxy.nodeHelper.enabled must be true if connectInject.nodeProxy.enabled is true" ]]
+}
 
-@test "connectInject/Deployment: vault CA is not configured by default" {
+@test "connectInject/Deployment: node proxy can be enabled" {
   cd `chart_dir`
-  local object=$(helm template \
-    -s templates/connect-inject-deployment.yaml  \
-    --set 'connectInject.enabled=true' \
-    --set 'global.tls.enabled=true' \
-    --set 'global.tls.enableAutoEncrypt=true' \
-    --set 'global.tls.caCert.secretName=foo' \
-    --set 'global.secretsBackend.vault.enabled=true' \
-    --set 'global.secretsBackend.vault.consulClientRole=foo' \
-    --set 'global.secretsBackend.vault.consulServerRole=test' \
-    --set 'global.secretsBackend.vault.consulCARole=test' \
-    . | tee /dev/stderr |
-      yq -r '.spec.template' | tee /dev/stderr)
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.transparentProxy.nodeHelper.enabled=true' \
+      --set 'connectInject.nodeProxy.enabled=true' \
+      --set 'connectInject.nodeProxy.inboundPort=21000' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
 
-  local actual=$(echo $object | yq -r '.metadata.annotations | has("vault.hashicorp.com/agent-extra-secret")')
-  [ "${actual}" = "false" ]
-  local actual=$(echo $object | yq -r '.metadata.annotations | has("vault.hashicorp.com/ca-cert")')
-  [ "${actual}" = "false" ]
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-enable-node-proxy=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-node-proxy-inbound-port=21000"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
 }
 
-@test "connectInject/Deployment: vault CA is not configured when secretName is set but secretKey is not" {
+#--------------------------------------------------------------------
+# holdApplicationUntilProxyStarts
+
+@test "connectInject/Deployment: hold application until proxy starts is not set by default" {
   cd `chart_dir`
-  local object=$(helm template \
-    -s templates/connect-inject-deployment.yaml  \
-    --set 'connectInject.enabled=true' \
-    --set 'global.tls.enabled=true' \
-    --set 'global.tls.enableAutoEncrypt=true' \
-    --set 'global.tls.caCert.secretName=foo' \
-    --set 'global.secretsBackend.vault.enabled=true' \
-    --set 'global.secretsBackend.vault.consulClientRole=foo' \
-    --set 'global.secretsBackend.vault.consulServerRole=test' \
-    --set 'global.secretsBackend.vault.consulCARole=test' \
-    --set 'global.secretsBackend.vault.ca.secretName=ca' \
-    . | tee /dev/stderr |
-      yq -r '.spec.template' | tee /dev/stderr)
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-default-hold-application-until-proxy-starts"))' | tee /dev/stderr)
 
-  local actual=$(echo $object | yq -r '.metadata.annotations | has("vault.hashicorp.com/agent-extra-secret")')
-  [ "${actual}" = "false" ]
-  local actual=$(echo $object | yq -r '.metadata.annotations | has("vault.hashicorp.com/ca-cert")')
   [ "${actual}" = "false" ]
 }
 
-@test "connectInject/Deployment: vault CA is not configured when secretKey is set but secretName is not" {
+@test "connectInject/Deployment: hold application until proxy starts can be enabled" {
   cd `chart_dir`
-  local object=$(helm template \
-    -s templates/connect-inject-deployment.yaml  \
-    --set 'connectInject.enabled=true' \
-    --set 'global.tls.enabled=true' \
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.holdApplicationUntilProxyStarts=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-default-hold-application-until-proxy-starts=true"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# namespaceSidecarConfig
+
+@test "connectInject/Deployment: namespace sidecar config is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-namespace-sidecar-config-map"))' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: namespace sidecar config can be enabled" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.namespaceSidecarConfig.enabled=true' \
+      --set 'connectInject.namespaceSidecarConfig.configMapName=sidecar-defaults' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-namespace-sidecar-config-map=sidecar-defaults"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# envoyBootstrapConfigMaps
+
+@test "connectInject/Deployment: Envoy bootstrap config maps are not enabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-envoy-bootstrap-config-maps"))' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: Envoy bootstrap config maps can be enabled" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.envoyBootstrapConfigMaps.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-envoy-bootstrap-config-maps=true"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# probeHealthChecks
+
+@test "connectInject/Deployment: probe health checks are not enabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-probe-health-checks"))' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: probe health checks can be enabled" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.probeHealthChecks=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-probe-health-checks=true"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# rollouts
+
+@test "connectInject/Deployment: rollout subsets are disabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-rollout-subsets"))' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: rollout subsets can be enabled" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.rollouts.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-rollout-subsets=true"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# peerFailover
+
+@test "connectInject/Deployment: peer failover is disabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-peer-failover"))' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: peer failover can be enabled" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.peerFailover.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-peer-failover=true"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# serviceLocality
+
+@test "connectInject/Deployment: service locality is disabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-service-locality"))' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: service locality can be enabled" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.serviceLocality.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-service-locality=true"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# envoyAccessLogs
+
+@test "connectInject/Deployment: envoy access logs are disabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("envoy-access-log"))' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: envoy access logs can be enabled with a JSON format" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.envoyAccessLogs.enabled=true' \
+      --set 'connectInject.envoyAccessLogs.jsonFormat=\{"code": "%RESPONSE_CODE%"\}' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-default-enable-envoy-access-logs=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  actual=$(echo "$cmd" |
+    yq 'any(contains("-default-envoy-access-log-json-format='"'"'{\"code\": \"%RESPONSE_CODE%\"}'"'"'"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: envoy access logs can have a text format" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.envoyAccessLogs.textFormat=%START_TIME% %RESPONSE_CODE%' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-default-envoy-access-log-text-format='"'"'%START_TIME% %RESPONSE_CODE%'"'"'"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: envoy access logs fail with both formats" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.envoyAccessLogs.jsonFormat=\{\}' \
+      --set 'connectInject.envoyAccessLogs.textFormat=%START_TIME%' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "only one of connectInject.envoyAccessLogs.jsonFormat and connectInject.envoyAccessLogs.textFormat may be set" ]]
+}
+
+#--------------------------------------------------------------------
+# deregistration
+
+@test "connectInject/Deployment: deregistration durations default to 0s" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-not-ready-grace-period=0s"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-deregister-not-ready-after=0s"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-deregister-terminating-after=0s"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: deregistration durations can be set" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.deregistration.notReadyGracePeriod=10s' \
+      --set 'connectInject.deregistration.deregisterNotReadyAfter=5m' \
+      --set 'connectInject.deregistration.deregisterTerminatingAfter=30s' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-not-ready-grace-period=10s"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-deregister-not-ready-after=5m"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-deregister-terminating-after=30s"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# telemetryCollector
+
+@test "connectInject/Deployment: proxies don't forward metrics to the telemetry collector by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-telemetry-collector"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: proxies forward metrics to the telemetry collector with telemetryCollector.enabled=true" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.namespaces.allow[0]=web' \
+      --set 'telemetryCollector.namespaces.deny[0]=batch' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-enable-telemetry-collector=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-telemetry-collector-allow-k8s-namespace=\"web\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-telemetry-collector-deny-k8s-namespace=\"batch\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# xdsWatchdog
+
+@test "connectInject/Deployment: xDS watchdog is disabled by default" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-default-enable-xds-watchdog"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-xds-watchdog-threshold=2m"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-xds-watchdog-policy=log"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: xDS watchdog can be enabled" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.xdsWatchdog.enabled=true' \
+      --set 'connectInject.xdsWatchdog.threshold=5m' \
+      --set 'connectInject.xdsWatchdog.policy=restart' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-default-enable-xds-watchdog=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-xds-watchdog-threshold=5m"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-xds-watchdog-policy=restart"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# jobCompletion
+
+@test "connectInject/Deployment: job completion is disabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-default-enable-job-completion"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: job completion can be enabled" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.jobCompletion.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-default-enable-job-completion=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# networkPolicies
+
+@test "connectInject/Deployment: network policies are disabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("network-polic"))' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: network policies can be enabled" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.networkPolicies.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-enable-network-policies=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-network-policy-default-allow=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: network policies deny by default with global.acls.manageSystemACLs=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.networkPolicies.enabled=true' \
+      --set 'global.acls.manageSystemACLs=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-network-policy-default-allow=false"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: network policies default can be overridden" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.networkPolicies.enabled=true' \
+      --set 'connectInject.networkPolicies.defaultAllow=false' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-network-policy-default-allow=false"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# openshift
+
+@test "connectInject/Deployment: openshift is is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-openshift"))' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: -enable-openshift is set when global.openshift.enabled is true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.openshift.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-openshift"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# sharding
+
+@test "connectInject/Deployment: -enable-sharding is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-sharding"))' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: -enable-sharding is set when connectInject.sharding.enabled is true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.sharding.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-sharding=true"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+
+#--------------------------------------------------------------------
+# serviceCache
+
+@test "connectInject/Deployment: -enable-service-cache is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-service-cache"))' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: -enable-service-cache is set when connectInject.serviceCache.enabled is true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.serviceCache.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-service-cache=true"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# replicas
+
+@test "connectInject/Deployment: replicas defaults to 2" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.replicas' | tee /dev/stderr)
+
+  [ "${actual}" = "2" ]
+}
+
+@test "connectInject/Deployment: replicas can be set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.replicas=3' \
+      . | tee /dev/stderr |
+      yq '.spec.replicas' | tee /dev/stderr)
+
+  [ "${actual}" = "3" ]
+}
+
+#--------------------------------------------------------------------
+# Vault
+
+@test "connectInject/Deployment: vault CA is not configured by default" {
+  cd `chart_dir`
+  local object=$(helm template \
+    -s templates/connect-inject-deployment.yaml  \
+    --set 'connectInject.enabled=true' \
+    --set 'global.tls.enabled=true' \
+    --set 'global.tls.enableAutoEncrypt=true' \
+    --set 'global.tls.caCert.secretName=foo' \
+    --set 'global.secretsBackend.vault.enabled=true' \
+    --set 'global.secretsBackend.vault.consulClientRole=foo' \
+    --set 'global.secretsBackend.vault.consulServerRole=test' \
+    --set 'global.secretsBackend.vault.consulCARole=test' \
+    . | tee /dev/stderr |
+      yq -r '.spec.template' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.metadata.annotations | has("vault.hashicorp.com/agent-extra-secret")')
+  [ "${actual}" = "false" ]
+  local actual=$(echo $object | yq -r '.metadata.annotations | has("vault.hashicorp.com/ca-cert")')
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: vault CA is not configured when secretName is set but secretKey is not" {
+  cd `chart_dir`
+  local object=$(helm template \
+    -s templates/connect-inject-deployment.yaml  \
+    --set 'connectInject.enabled=true' \
+    --set 'global.tls.enabled=true' \
+    --set 'global.tls.enableAutoEncrypt=true' \
+    --set 'global.tls.caCert.secretName=foo' \
+    --set 'global.secretsBackend.vault.enabled=true' \
+    --set 'global.secretsBackend.vault.consulClientRole=foo' \
+    --set 'global.secretsBackend.vault.consulServerRole=test' \
+    --set 'global.secretsBackend.vault.consulCARole=test' \
+    --set 'global.secretsBackend.vault.ca.secretName=ca' \
+    . | tee /dev/stderr |
+      yq -r '.spec.template' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.metadata.annotations | has("vault.hashicorp.com/agent-extra-secret")')
+  [ "${actual}" = "false" ]
+  local actual=$(echo $object | yq -r '.metadata.annotations | has("vault.hashicorp.com/ca-cert")')
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: vault CA is not configured when secretKey is set but secretName is not" {
+  cd `chart_dir`
+  local object=$(helm template \
+    -s templates/connect-inject-deployment.yaml  \
+    --set 'connectInject.enabled=true' \
+    --set 'global.tls.enabled=true' \
     --set 'global.tls.enableAutoEncrypt=true' \
     --set 'global.tls.caCert.secretName=foo' \
     --set 'global.secretsBackend.vault.enabled=true' \
@@ -1916,6 +2750,88 @@ EOF
   [ "${actual}" = "bar" ]
 }
 
+#--------------------------------------------------------------------
+# webhookCertManager.source
+
+@test "connectInject/Deployment: webhook certificates are mounted from the secret by default" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.volumes[] | select(.name == "certs") | .secret.secretName' | tee /dev/stderr)
+  [ "${actual}" = "RELEASE-NAME-consul-connect-inject-webhook-cert" ]
+  local actual=$(echo $object | yq -r '.containers[0].command | any(contains("-tls-cert-dir=/etc/connect-injector/certs"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo $object | yq -r '.containers[0].command | any(contains("-webhook-ca-cert-file"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: webhook certificates are issued by the vault agent with webhookCertManager.source=vault" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulClientRole=foo' \
+      --set 'global.secretsBackend.vault.consulServerRole=bar' \
+      --set 'webhookCertManager.source=vault' \
+      --set 'webhookCertManager.vault.role=webhooks' \
+      --set 'webhookCertManager.vault.pkiPath=pki_int/issue/webhooks' \
+      --set 'webhookCertManager.vault.caPath=pki_int/cert/ca' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.metadata.annotations["vault.hashicorp.com/role"]' | tee /dev/stderr)
+  [ "${actual}" = "webhooks" ]
+  local actual=$(echo $object | yq -r '.metadata.annotations["vault.hashicorp.com/agent-inject-secret-tls.crt"]' | tee /dev/stderr)
+  [ "${actual}" = "pki_int/issue/webhooks" ]
+  local actual=$(echo $object | yq -r '.metadata.annotations["vault.hashicorp.com/agent-inject-template-tls.crt"]' | tee /dev/stderr)
+  local expected=$'{{- with secret \"pki_int/issue/webhooks\" \"common_name=RELEASE-NAME-consul-connect-injector.default.svc\"\n\"alt_names=RELEASE-NAME-consul-connect-injector,RELEASE-NAME-consul-connect-injector.default,RELEASE-NAME-consul-connect-injector.default.svc.cluster.local\" -}}\n{{- .Data.certificate -}}\n{{- end -}}'
+  [ "${actual}" = "${expected}" ]
+  local actual=$(echo $object | yq -r '.metadata.annotations["vault.hashicorp.com/agent-inject-secret-tls.key"]' | tee /dev/stderr)
+  [ "${actual}" = "pki_int/issue/webhooks" ]
+  local actual=$(echo $object | yq -r '.metadata.annotations["vault.hashicorp.com/agent-inject-secret-ca.crt"]' | tee /dev/stderr)
+  [ "${actual}" = "pki_int/cert/ca" ]
+  local actual=$(echo $object | yq -r '.metadata.annotations | has("vault.hashicorp.com/agent-inject-secret-serverca.crt")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+
+  local actual=$(echo $object | yq -r '.spec.volumes | map(select(.name == "certs")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+  local actual=$(echo $object | yq -r '.spec.containers[0].command | any(contains("-tls-cert-dir=/vault/secrets"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo $object | yq -r '.spec.containers[0].command | any(contains("-webhook-ca-cert-file=/vault/secrets/ca.crt"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo $object | yq -r '.spec.containers[0].command | any(contains("-webhook-config-name=RELEASE-NAME-consul-connect-injector"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: the server CA is read with the webhook role with webhookCertManager.source=vault and TLS" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.caCert.secretName=pki/cert/ca' \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulClientRole=foo' \
+      --set 'global.secretsBackend.vault.consulServerRole=bar' \
+      --set 'global.secretsBackend.vault.consulCARole=carole' \
+      --set 'webhookCertManager.source=vault' \
+      --set 'webhookCertManager.vault.role=webhooks' \
+      --set 'webhookCertManager.vault.pkiPath=pki_int/issue/webhooks' \
+      --set 'webhookCertManager.vault.caPath=pki_int/cert/ca' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.["vault.hashicorp.com/role"]' | tee /dev/stderr)
+  [ "${actual}" = "webhooks" ]
+  local actual=$(echo $object | yq -r '.["vault.hashicorp.com/agent-inject-secret-serverca.crt"]' | tee /dev/stderr)
+  [ "${actual}" = "pki/cert/ca" ]
+}
+
 # consulDestinationNamespace reserved name
 
 @test "connectInject/Deployment: fails when consulDestinationNamespace=system" {
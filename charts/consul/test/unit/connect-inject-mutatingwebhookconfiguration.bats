@@ -47,3 +47,78 @@ load _helpers
       yq '.webhooks[0].clientConfig.service.namespace' | tee /dev/stderr)
   [ "${actual}" = "\"foo\"" ]
 }
+
+#--------------------------------------------------------------------
+# objectSelector
+
+@test "connectInject/MutatingWebhookConfiguration: objectSelector excludes the consul pods by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-mutatingwebhookconfiguration.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -c '.webhooks[0].objectSelector' | tee /dev/stderr)
+  [ "${actual}" = '{"matchExpressions":[{"key":"app","operator":"NotIn","values":["consul"]}]}' ]
+}
+
+@test "connectInject/MutatingWebhookConfiguration: objectSelector can be set and still excludes the consul pods" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-mutatingwebhookconfiguration.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.objectSelector=matchLabels:
+  inject-sidecar: "true"
+matchExpressions:
+- key: tier
+  operator: In
+  values: [web]' \
+      . | tee /dev/stderr |
+      yq -c '.webhooks[0].objectSelector' | tee /dev/stderr)
+  [ "${actual}" = '{"matchExpressions":[{"key":"tier","operator":"In","values":["web"]},{"key":"app","operator":"NotIn","values":["consul"]}],"matchLabels":{"inject-sidecar":"true"}}' ]
+}
+
+#--------------------------------------------------------------------
+# webhookTimeoutSeconds
+
+@test "connectInject/MutatingWebhookConfiguration: timeoutSeconds is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-mutatingwebhookconfiguration.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.webhooks[0] | has("timeoutSeconds")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/MutatingWebhookConfiguration: timeoutSeconds can be set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-mutatingwebhookconfiguration.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.webhookTimeoutSeconds=5' \
+      . | tee /dev/stderr |
+      yq '.webhooks[0].timeoutSeconds' | tee /dev/stderr)
+  [ "${actual}" = "5" ]
+}
+
+@test "connectInject/MutatingWebhookConfiguration: cert-manager CA injection is not configured by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-mutatingwebhookconfiguration.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.metadata | has("annotations")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/MutatingWebhookConfiguration: cert-manager injects the CA with webhookCertManager.source=cert-manager" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-mutatingwebhookconfiguration.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'webhookCertManager.source=cert-manager' \
+      --set 'webhookCertManager.certManager.issuerRef.name=consul-ca' \
+      . | tee /dev/stderr |
+      yq -r '.metadata.annotations["cert-manager.io/inject-ca-from"]' | tee /dev/stderr)
+  [ "${actual}" = "default/RELEASE-NAME-consul-connect-injector" ]
+}
@@ -43,3 +43,115 @@ load _helpers
       yq '.rules | map(select(.resources[0] == "podsecuritypolicies")) | length' | tee /dev/stderr)
   [ "${actual}" = "1" ]
 }
+
+#--------------------------------------------------------------------
+# global.gossipEncryption.syncKeyring
+
+@test "controller/ClusterRole: no secrets access by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-clusterrole.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.rules | map(select(.resources[0] == "secrets")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "controller/ClusterRole: allows getting the gossip key secret with global.gossipEncryption.syncKeyring=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-clusterrole.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.gossipEncryption.autoGenerate=true' \
+      --set 'global.gossipEncryption.syncKeyring=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "secrets")) | .[0].resourceNames[0]' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-gossip-encryption-key" ]
+}
+
+#--------------------------------------------------------------------
+# controller.externalServices
+
+@test "controller/ClusterRole: no configmaps access by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-clusterrole.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.rules | map(select(.resources[0] == "configmaps")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "controller/ClusterRole: allows getting the external services configmap with controller.externalServices.configMapName" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-clusterrole.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'controller.externalServices.configMapName=external-services' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "configmaps")) | .[0].resourceNames[0]' | tee /dev/stderr)
+  [ "${actual}" = "external-services" ]
+}
+
+@test "controller/ClusterRole: no jobs access by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-clusterrole.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'client.snapshotAgent.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.rules | map(select(.resources[0] == "cronjobs" or .resources[0] == "jobs" or .resources[0] == "podtemplates")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "controller/ClusterRole: allows managing snapshot jobs with client.snapshotAgent.schedules.enabled=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/controller-clusterrole.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.schedules.enabled=true' \
+      . | tee /dev/stderr |
+      yq -c '.rules' | tee /dev/stderr)
+
+  local actual=$(echo $object |
+      yq -r 'map(select(.resources[0] == "cronjobs")) | .[0].verbs | index("create") != null' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $object |
+      yq -r 'map(select(.resources[0] == "jobs")) | .[0].verbs | index("watch") != null' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $object |
+      yq -r 'map(select(.resources[0] == "podtemplates")) | .[0].verbs | index("get") != null' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $object |
+      yq -r 'map(select(.resources[0] == "events")) | .[0].verbs | index("create") != null' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# terminatingGateways
+
+@test "controller/ClusterRole: no deployments access by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-clusterrole.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.rules | map(select(.resources[0] == "deployments")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "controller/ClusterRole: allows updating deployments with terminatingGateways.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-clusterrole.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "deployments")) | .[0].verbs | index("update") != null' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
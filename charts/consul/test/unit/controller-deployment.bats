@@ -520,6 +520,20 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+@test "controller/Deployment: mirroring rules can be set with connectInject.consulNamespaces.mirroringK8SRules" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.enableConsulNamespaces=true' \
+      --set 'connectInject.consulNamespaces.mirroringK8S=true' \
+      --set 'connectInject.consulNamespaces.mirroringK8SRules[0].match=team-(.*)' \
+      --set 'connectInject.consulNamespaces.mirroringK8SRules[0].replace=$1' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-k8s-namespace-mirroring-rules=") and contains("{\"match\":\"team-(.*)\",\"replace\":\"$1\"}"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
 @test "controller/Deployment: cross namespace policy is not added when global.acls.manageSystemACLs=false" {
   cd `chart_dir`
   local actual=$(helm template \
@@ -871,6 +885,108 @@ load _helpers
   [ "${actual}" = "test" ]
 }
 
+#--------------------------------------------------------------------
+# controller.externalServices
+
+@test "controller/Deployment: external services are not registered by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-external-services-config-map-name"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "controller/Deployment: external services are registered from the configmap" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'controller.externalServices.configMapName=external-services' \
+      --set 'controller.externalServices.nodeName=lambdas' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-external-services-config-map-name=external-services"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-external-services-config-map-namespace=default"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-external-services-node-name=lambdas"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# global.gossipEncryption.syncKeyring
+
+@test "controller/Deployment: gossip keyring is not synced by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-gossip-key-secret-name"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "controller/Deployment: gossip keyring is synced with the autogenerated secret" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.gossipEncryption.autoGenerate=true' \
+      --set 'global.gossipEncryption.syncKeyring=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-gossip-key-secret-name=release-name-consul-gossip-encryption-key"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-gossip-key-secret-key=key"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-gossip-key-secret-namespace=default"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "controller/Deployment: gossip keyring is synced with a user-provided secret" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.gossipEncryption.secretName=foo' \
+      --set 'global.gossipEncryption.secretKey=bar' \
+      --set 'global.gossipEncryption.syncKeyring=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-gossip-key-secret-name=foo"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-gossip-key-secret-key=bar"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "controller/Deployment: fails if global.gossipEncryption.syncKeyring=true without a gossip key" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.gossipEncryption.syncKeyring=true' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.gossipEncryption.syncKeyring requires global.gossipEncryption.autoGenerate or global.gossipEncryption.secretName to be set" ]]
+}
+
 #--------------------------------------------------------------------
 # Vault agent annotations
 
@@ -908,3 +1024,204 @@ load _helpers
 }
 
 
+
+#--------------------------------------------------------------------
+# webhookCertManager.source
+
+@test "controller/Deployment: webhook certificates are issued by the vault agent with webhookCertManager.source=vault" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulClientRole=foo' \
+      --set 'global.secretsBackend.vault.consulServerRole=bar' \
+      --set 'webhookCertManager.source=vault' \
+      --set 'webhookCertManager.vault.role=webhooks' \
+      --set 'webhookCertManager.vault.pkiPath=pki_int/issue/webhooks' \
+      --set 'webhookCertManager.vault.caPath=pki_int/cert/ca' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.metadata.annotations["vault.hashicorp.com/role"]' | tee /dev/stderr)
+  [ "${actual}" = "webhooks" ]
+  local actual=$(echo $object | yq -r '.metadata.annotations["vault.hashicorp.com/agent-inject-template-tls.key"] | contains("common_name=RELEASE-NAME-consul-controller-webhook.default.svc")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo $object | yq -r '.metadata.annotations["vault.hashicorp.com/agent-inject-secret-ca.crt"]' | tee /dev/stderr)
+  [ "${actual}" = "pki_int/cert/ca" ]
+
+  local actual=$(echo $object | yq -r '.spec.volumes | map(select(.name == "cert")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+  local actual=$(echo $object | yq -r '.spec.containers[0].command | any(contains("-webhook-tls-cert-dir=/vault/secrets"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo $object | yq -r '.spec.containers[0].command | any(contains("-webhook-config-name=RELEASE-NAME-consul-controller"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# snapshot schedules
+
+@test "controller/Deployment: no snapshot pod template by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'client.snapshotAgent.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-snapshot-pod-template"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "controller/Deployment: sets the snapshot pod template with client.snapshotAgent.schedules.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.schedules.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-snapshot-pod-template=release-name-consul-snapshot-agent"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# terminatingGateways
+
+@test "controller/Deployment: no terminating gateway namespace by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-terminating-gateway-namespace"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "controller/Deployment: sets the terminating gateway namespace with terminatingGateways.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --namespace foo \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-terminating-gateway-namespace=foo"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# configEntryWorkers
+
+@test "controller/Deployment: config entry workers are not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-config-entry-workers"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "controller/Deployment: sets the config entry workers of each kind" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'controller.configEntryWorkers.serviceintentions=4' \
+      --set 'controller.configEntryWorkers.servicedefaults=2' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-config-entry-workers=serviceintentions=4"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-config-entry-workers=servicedefaults=2"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# global.clusterID
+
+@test "controller/Deployment: -cluster-id is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-cluster-id"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "controller/Deployment: -cluster-id is set when global.clusterID is set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.clusterID=us-east-1-prod' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-cluster-id=us-east-1-prod"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# global.audit
+
+@test "controller/Deployment: -enable-audit is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-audit"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "controller/Deployment: -enable-audit is set when global.audit.enabled is true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.audit.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | (any(contains("-enable-audit=true")) and (any(contains("-audit-events")) | not) and (any(contains("-audit-webhook-url")) | not))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "controller/Deployment: -audit-events is set when global.audit.events is true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.audit.enabled=true' \
+      --set 'global.audit.events=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-audit-events=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "controller/Deployment: -audit-webhook-url is set when global.audit.webhookURL is set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.audit.enabled=true' \
+      --set 'global.audit.webhookURL=https://audit.example.com/consul' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-audit-webhook-url") and contains("https://audit.example.com/consul"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "controller/Deployment: audit flags are not set when global.audit.enabled is false" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.audit.events=true' \
+      --set 'global.audit.webhookURL=https://audit.example.com/consul' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | (any(contains("-enable-audit")) or any(contains("-audit-")))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
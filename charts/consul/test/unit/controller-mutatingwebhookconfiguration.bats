@@ -18,3 +18,15 @@ load _helpers
       yq 'length > 0' | tee /dev/stderr)
   [ "${actual}" = "true" ]
 }
+
+@test "controller/MutatingWebhookConfiguration: cert-manager injects the CA with webhookCertManager.source=cert-manager" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-mutatingwebhookconfiguration.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'webhookCertManager.source=cert-manager' \
+      --set 'webhookCertManager.certManager.issuerRef.name=consul-ca' \
+      . | tee /dev/stderr |
+      yq -r '.metadata.annotations["cert-manager.io/inject-ca-from"]' | tee /dev/stderr)
+  [ "${actual}" = "default/RELEASE-NAME-consul-controller-webhook" ]
+}
@@ -459,6 +459,19 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+@test "ingressGateways/Deployment: when telemetryCollector.enabled=true, forwards metrics to the collector" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ingress-gateways-deployment.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'telemetryCollector.enabled=true'  \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.initContainers[1].command | join(" ") | contains("envoy_telemetry_collector_bind_socket_dir = \"/consul/service\"")' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
 @test "ingressGateways/Deployment: when global.metrics.enableGatewayMetrics=false, does not set proxy setting" {
   cd `chart_dir`
   local object=$(helm template \
@@ -170,6 +170,19 @@ key2: value2' \
   [ "${actual}" = "null" ]
 }
 
+@test "meshGateway/Deployment: when telemetryCollector.enabled=true, forwards metrics to the collector" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'telemetryCollector.enabled=true'  \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.initContainers[1].command | join(" ") | contains("envoy_telemetry_collector_bind_socket_dir = \"/consul/service\"")' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
 @test "meshGateway/Deployment: when global.metrics.enabled=false, does not set proxy setting" {
   cd `chart_dir`
   local object=$(helm template \
@@ -1902,3 +1915,72 @@ EOF
       yq -r '.spec.template.metadata.annotations.foo' | tee /dev/stderr)
   [ "${actual}" = "bar" ]
 }
+
+#--------------------------------------------------------------------
+# wanAddress.watchLoadBalancer
+
+@test "meshGateway/Deployment: consul-sidecar does not watch the WAN address by default" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers | map(select(.name == "consul-sidecar"))[0]' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq '.command | any(contains("-wan-address-service"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+
+  actual=$(echo "$object" | yq '.volumeMounts | map(select(.name == "consul-service"))[0].readOnly' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "meshGateway/Deployment: consul-sidecar watches the WAN address with wanAddress.watchLoadBalancer=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.wanAddress.watchLoadBalancer=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers | map(select(.name == "consul-sidecar"))[0]' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq '.command | any(. == "-wan-address-service=release-name-consul-mesh-gateway")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  actual=$(echo "$object" | yq '.command | any(. == "-wan-address-namespace=default")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  actual=$(echo "$object" | yq '.command | any(contains("-wan-address-resolve-hostnames"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+
+  actual=$(echo "$object" | yq '.volumeMounts | map(select(.name == "consul-service"))[0].readOnly' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "meshGateway/Deployment: consul-sidecar resolves hostnames with wanAddress.resolveHostnames=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.wanAddress.watchLoadBalancer=true' \
+      --set 'meshGateway.wanAddress.resolveHostnames=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers | map(select(.name == "consul-sidecar"))[0].command | any(. == "-wan-address-resolve-hostnames=true")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "meshGateway/Deployment: consul-sidecar does not watch the WAN address if the service is not a LoadBalancer" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.wanAddress.watchLoadBalancer=true' \
+      --set 'meshGateway.service.type=NodePort' \
+      --set 'meshGateway.service.nodePort=30443' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers | map(select(.name == "consul-sidecar"))[0].command | any(contains("-wan-address-service"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
@@ -1924,3 +1924,53 @@ load _helpers
   local actual="$(echo $object | yq -r '.spec.containers[] | select(.name=="consul").command | any(contains("-config-file=/vault/secrets/replication-token-config.hcl"))' | tee /dev/stderr)"
   [ "${actual}" = "true" ]
 }
+
+#--------------------------------------------------------------------
+# global.tls.serverCertRenewal
+
+@test "server/StatefulSet: does not reload the server certificate by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-statefulset.yaml  \
+      --set 'global.tls.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command | join(" ") | contains("kill -HUP 1")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "server/StatefulSet: reloads the server certificate with global.tls.serverCertRenewal.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-statefulset.yaml  \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.serverCertRenewal.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command | join(" ") | contains("kill -HUP 1")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "server/StatefulSet: global.tls.serverCertRenewal.enabled=true fails without connectInject or controller" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-statefulset.yaml  \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.serverCertRenewal.enabled=true' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.tls.serverCertRenewal.enabled requires connectInject.enabled or controller.enabled" ]]
+}
+
+@test "server/StatefulSet: global.tls.serverCertRenewal.enabled=true fails with server.serverCert.secretName" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-statefulset.yaml  \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.serverCertRenewal.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.caCert.secretName=ca-cert' \
+      --set 'server.serverCert.secretName=server-cert' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.tls.serverCertRenewal.enabled is not supported with server.serverCert.secretName" ]]
+}
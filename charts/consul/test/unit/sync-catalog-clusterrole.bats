@@ -56,7 +56,7 @@ load _helpers
       --set 'syncCatalog.enabled=true' \
       --set 'global.enablePodSecurityPolicies=true' \
       . | tee /dev/stderr |
-      yq -r '.rules[2].resources[0]' | tee /dev/stderr)
+      yq -r '.rules[3].resources[0]' | tee /dev/stderr)
   [ "${actual}" = "podsecuritypolicies" ]
 }
 
@@ -84,3 +84,37 @@ load _helpers
       yq -c '.rules[0].verbs' | tee /dev/stderr)
   [ "${actual}" = '["get","list","watch","update","patch","delete","create"]' ]
 }
+
+#--------------------------------------------------------------------
+# syncCatalog.filterConfigMap
+
+@test "syncCatalog/ClusterRole: allows namespaces access" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/sync-catalog-clusterrole.yaml  \
+      --set 'syncCatalog.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules[2].resources[0]' | tee /dev/stderr)
+  [ "${actual}" = "namespaces" ]
+}
+
+@test "syncCatalog/ClusterRole: no configmaps access by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/sync-catalog-clusterrole.yaml  \
+      --set 'syncCatalog.enabled=true' \
+      . | tee /dev/stderr |
+      yq '[.rules[].resources[]] | any(. == "configmaps")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "syncCatalog/ClusterRole: allows configmaps access with syncCatalog.filterConfigMap" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/sync-catalog-clusterrole.yaml  \
+      --set 'syncCatalog.enabled=true' \
+      --set 'syncCatalog.filterConfigMap=sync-filter' \
+      . | tee /dev/stderr |
+      yq -r '.rules[3].resources[0]' | tee /dev/stderr)
+  [ "${actual}" = "configmaps" ]
+}
@@ -0,0 +1,31 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "telemetryCollector/ConfigMap: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/telemetry-collector-configmap.yaml  \
+      .
+}
+
+@test "telemetryCollector/ConfigMap: disabled without telemetryCollector.customExporterConfig" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/telemetry-collector-configmap.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'telemetryCollector.enabled=true' \
+      .
+}
+
+@test "telemetryCollector/ConfigMap: contains telemetryCollector.customExporterConfig" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/telemetry-collector-configmap.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.customExporterConfig="{\"http_collector_endpoint\": \"http://otel:4318\"}"' \
+      . | tee /dev/stderr |
+      yq -r '.data["config.json"]' | yq -r '.http_collector_endpoint' | tee /dev/stderr)
+  [ "${actual}" = "http://otel:4318" ]
+}
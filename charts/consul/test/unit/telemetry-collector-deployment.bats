@@ -0,0 +1,133 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "telemetryCollector/Deployment: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/telemetry-collector-deployment.yaml  \
+      .
+}
+
+@test "telemetryCollector/Deployment: enabled with telemetryCollector.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/telemetry-collector-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'telemetryCollector.enabled=true' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "telemetryCollector/Deployment: fails if connectInject.enabled=false" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/telemetry-collector-deployment.yaml  \
+      --set 'telemetryCollector.enabled=true' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "connectInject.enabled must be true if telemetryCollector.enabled=true" ]]
+}
+
+@test "telemetryCollector/Deployment: is injected as the consul-telemetry-collector service" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/telemetry-collector-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'telemetryCollector.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template' | tee /dev/stderr)
+
+  local actual=$(echo "$object" |
+      yq -r '.metadata.annotations."consul.hashicorp.com/connect-inject"' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  actual=$(echo "$object" |
+      yq -r '.metadata.annotations."consul.hashicorp.com/connect-service"' | tee /dev/stderr)
+  [ "${actual}" = "consul-telemetry-collector" ]
+
+  actual=$(echo "$object" |
+      yq -r '.spec.serviceAccountName' | tee /dev/stderr)
+  [ "${actual}" = "consul-telemetry-collector" ]
+}
+
+#--------------------------------------------------------------------
+# customExporterConfig
+
+@test "telemetryCollector/Deployment: no config file by default" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/telemetry-collector-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'telemetryCollector.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$object" |
+      yq '.volumes' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+
+  actual=$(echo "$object" |
+      yq '.containers[0].command | any(contains("-config-file-path"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "telemetryCollector/Deployment: uses the config file with telemetryCollector.customExporterConfig" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/telemetry-collector-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.customExporterConfig="{\"http_collector_endpoint\": \"http://otel:4318\"}"' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$object" |
+      yq -r '.volumes[] | select(.name == "config") | .configMap.name' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-telemetry-collector" ]
+
+  actual=$(echo "$object" |
+      yq '.containers[0].command | any(contains("-config-file-path=/consul/config/config.json"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# logLevel
+
+@test "telemetryCollector/Deployment: logLevel defaults to global.logLevel" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/telemetry-collector-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'telemetryCollector.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-log-level=info"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "telemetryCollector/Deployment: logLevel can be overridden" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/telemetry-collector-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.logLevel=debug' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-log-level=debug"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# nodeSelector
+
+@test "telemetryCollector/Deployment: nodeSelector can be set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/telemetry-collector-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.nodeSelector=testing' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.nodeSelector' | tee /dev/stderr)
+  [ "${actual}" = "testing" ]
+}
@@ -25,6 +25,18 @@ load _helpers
   [ "${actual}" = "release-name-consul-terminating-gateway" ]
 }
 
+@test "terminatingGateways/Deployment: labels the deployment with the gateway service name" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/terminating-gateways-deployment.yaml \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'terminatingGateways.gateways[0].name=gateway1' \
+      . | tee /dev/stderr |
+      yq -r '.metadata.labels["terminating-gateway-service-name"]' | tee /dev/stderr)
+  [ "${actual}" = "gateway1" ]
+}
+
 @test "terminatingGateways/Deployment: Adds consul service volumeMount to gateway container" {
   cd `chart_dir`
   local object=$(helm template \
@@ -463,6 +475,19 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+@test "terminatingGateways/Deployment: when telemetryCollector.enabled=true, forwards metrics to the collector" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/terminating-gateways-deployment.yaml  \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'telemetryCollector.enabled=true'  \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.initContainers[1].command | join(" ") | contains("envoy_telemetry_collector_bind_socket_dir = \"/consul/service\"")' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
 @test "terminatingGateways/Deployment: when global.metrics.enableGatewayMetrics=false, does not set proxy setting" {
   cd `chart_dir`
   local object=$(helm template \
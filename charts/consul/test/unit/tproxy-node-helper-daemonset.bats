@@ -0,0 +1,125 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "tproxyNodeHelper/DaemonSet: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/tproxy-node-helper-daemonset.yaml  \
+      --set 'connectInject.enabled=true' \
+      .
+}
+
+@test "tproxyNodeHelper/DaemonSet: disabled with connectInject.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/tproxy-node-helper-daemonset.yaml  \
+      --set 'connectInject.enabled=false' \
+      --set 'connectInject.transparentProxy.nodeHelper.enabled=true' \
+      .
+}
+
+@test "tproxyNodeHelper/DaemonSet: enabled with connectInject.transparentProxy.nodeHelper.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/tproxy-node-helper-daemonset.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.transparentProxy.nodeHelper.enabled=true' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "tproxyNodeHelper/DaemonSet: runs privileged in the host PID namespace" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s templates/tproxy-node-helper-daemonset.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.transparentProxy.nodeHelper.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$spec" | yq '.hostPID' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$spec" | yq '.containers[0].securityContext.privileged' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$spec" | yq '.containers[0].command[2] | contains("consul-k8s-control-plane tproxy-node-helper")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# tolerations
+
+@test "tproxyNodeHelper/DaemonSet: tolerations not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/tproxy-node-helper-daemonset.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.transparentProxy.nodeHelper.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec | .tolerations? == null' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "tproxyNodeHelper/DaemonSet: tolerations can be set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/tproxy-node-helper-daemonset.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.transparentProxy.nodeHelper.enabled=true' \
+      --set 'connectInject.transparentProxy.nodeHelper.tolerations=foobar' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.tolerations == "foobar"' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# priorityClassName
+
+@test "tproxyNodeHelper/DaemonSet: priorityClassName can be set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/tproxy-node-helper-daemonset.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.transparentProxy.nodeHelper.enabled=true' \
+      --set 'connectInject.transparentProxy.nodeHelper.priorityClassName=testing' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.priorityClassName' | tee /dev/stderr)
+  [ "${actual}" = "testing" ]
+}
+
+#--------------------------------------------------------------------
+# nodeProxy
+
+@test "tproxyNodeHelper/DaemonSet: node proxy is disabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/tproxy-node-helper-daemonset.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.transparentProxy.nodeHelper.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-node-proxy"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "tproxyNodeHelper/DaemonSet: node proxy can be enabled" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/tproxy-node-helper-daemonset.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.transparentProxy.nodeHelper.enabled=true' \
+      --set 'connectInject.nodeProxy.enabled=true' \
+      --set 'connectInject.nodeProxy.outboundPort=16000' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-enable-node-proxy=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-node-proxy-outbound-port=16000"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
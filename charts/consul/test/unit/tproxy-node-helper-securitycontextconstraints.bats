@@ -0,0 +1,60 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "tproxyNodeHelper/SecurityContextConstraints: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/tproxy-node-helper-securitycontextconstraints.yaml  \
+      .
+}
+
+@test "tproxyNodeHelper/SecurityContextConstraints: disabled with the node helper disabled and global.openshift.enabled=true" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/tproxy-node-helper-securitycontextconstraints.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.openshift.enabled=true' \
+      .
+}
+
+@test "tproxyNodeHelper/SecurityContextConstraints: disabled with connectInject disabled" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/tproxy-node-helper-securitycontextconstraints.yaml  \
+      --set 'connectInject.enabled=false' \
+      --set 'connectInject.transparentProxy.nodeHelper.enabled=true' \
+      --set 'global.openshift.enabled=true' \
+      .
+}
+
+@test "tproxyNodeHelper/SecurityContextConstraints: enabled with the node helper and global.openshift.enabled=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/tproxy-node-helper-securitycontextconstraints.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.transparentProxy.nodeHelper.enabled=true' \
+      --set 'global.openshift.enabled=true' \
+      . | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.allowHostPID' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$object" | yq -r '.allowPrivilegedContainer' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# ClusterRole
+
+@test "tproxyNodeHelper/ClusterRole: allows the SecurityContextConstraints with global.openshift.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/tproxy-node-helper-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.transparentProxy.nodeHelper.enabled=true' \
+      --set 'global.openshift.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules[] | select(.resources[0] == "securitycontextconstraints") | .resourceNames[0]' | tee /dev/stderr)
+  [ "${actual}" = "RELEASE-NAME-consul-tproxy-node-helper" ]
+}
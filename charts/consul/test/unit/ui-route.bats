@@ -0,0 +1,127 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "ui/Route: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/ui-route.yaml  \
+      .
+}
+
+@test "ui/Route: disabled with ui.route.enabled=true and global.openshift.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/ui-route.yaml  \
+      --set 'ui.route.enabled=true' \
+      .
+}
+
+@test "ui/Route: disabled with ui.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/ui-route.yaml  \
+      --set 'global.openshift.enabled=true' \
+      --set 'ui.route.enabled=true' \
+      --set 'ui.enabled=false' \
+      .
+}
+
+@test "ui/Route: enabled with ui.route.enabled=true and global.openshift.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ui-route.yaml  \
+      --set 'global.openshift.enabled=true' \
+      --set 'ui.route.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.to.name' | tee /dev/stderr)
+  [ "${actual}" = "RELEASE-NAME-consul-ui" ]
+}
+
+#--------------------------------------------------------------------
+# host
+
+@test "ui/Route: no host by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ui-route.yaml  \
+      --set 'global.openshift.enabled=true' \
+      --set 'ui.route.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.host' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "ui/Route: host can be set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ui-route.yaml  \
+      --set 'global.openshift.enabled=true' \
+      --set 'ui.route.enabled=true' \
+      --set 'ui.route.host=consul.apps.example.com' \
+      . | tee /dev/stderr |
+      yq -r '.spec.host' | tee /dev/stderr)
+  [ "${actual}" = "consul.apps.example.com" ]
+}
+
+#--------------------------------------------------------------------
+# tls
+
+@test "ui/Route: exposes the http port without TLS when global.tls.enabled=false" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/ui-route.yaml  \
+      --set 'global.openshift.enabled=true' \
+      --set 'ui.route.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.port.targetPort' | tee /dev/stderr)
+  [ "${actual}" = "http" ]
+
+  local actual=$(echo $object | yq -r '.tls' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "ui/Route: exposes the https port with TLS passthrough when global.tls.enabled=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/ui-route.yaml  \
+      --set 'global.openshift.enabled=true' \
+      --set 'ui.route.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.port.targetPort' | tee /dev/stderr)
+  [ "${actual}" = "https" ]
+
+  local actual=$(echo $object | yq -r '.tls.termination' | tee /dev/stderr)
+  [ "${actual}" = "passthrough" ]
+}
+
+#--------------------------------------------------------------------
+# annotations
+
+@test "ui/Route: no annotations by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ui-route.yaml  \
+      --set 'global.openshift.enabled=true' \
+      --set 'ui.route.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.metadata.annotations' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "ui/Route: annotations can be set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ui-route.yaml  \
+      --set 'global.openshift.enabled=true' \
+      --set 'ui.route.enabled=true' \
+      --set 'ui.route.annotations=foo: bar' \
+      . | tee /dev/stderr |
+      yq -r '.metadata.annotations.foo' | tee /dev/stderr)
+  [ "${actual}" = "bar" ]
+}
@@ -53,3 +53,23 @@ load _helpers
       yq -r '.rules[3].resources[0]' | tee /dev/stderr)
   [ "${actual}" = "podsecuritypolicies" ]
 }
+
+@test "webhookCertManager/ClusterRole: allows getting the connect injector deployment with connectInject.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/webhook-cert-manager-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.rules[2].resourceNames | index("release-name-consul-connect-injector") != null' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "webhookCertManager/ClusterRole: allows watching the deployments to fail open during rollouts" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/webhook-cert-manager-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -c '.rules | map(select(.resources[0] == "deployments"))[0].verbs' | tee /dev/stderr)
+  [ "${actual}" = '["get","list","watch"]' ]
+}
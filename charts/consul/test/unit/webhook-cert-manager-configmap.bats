@@ -90,4 +90,44 @@ load _helpers
 
   local actual=$(echo $cfg | jq '.[1].name | contains("controller")')
   [ "${actual}" = "true" ]
-}
\ No newline at end of file
+}
+
+@test "webhookCertManager/Configmap: connectInject webhook settings are set" {
+  cd `chart_dir`
+  local cfg=$(helm template \
+      -s templates/webhook-cert-manager-configmap.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.failurePolicy=Fail' \
+      --set 'connectInject.webhookTimeoutSeconds=5' \
+      . | tee /dev/stderr |
+      yq -r '.data["webhook-config.json"]' | tee /dev/stderr)
+
+  local actual=$(echo $cfg | jq -r '.[0].failurePolicy')
+  [ "${actual}" = "Fail" ]
+
+  local actual=$(echo $cfg | jq '.[0].timeoutSeconds')
+  [ "${actual}" = "5" ]
+
+  local actual=$(echo $cfg | jq -r '.[0].namespaceSelector.matchExpressions[0].key')
+  [ "${actual}" = "kubernetes.io/metadata.name" ]
+
+  local actual=$(echo $cfg | jq -r '.[0].objectSelector.matchExpressions[0].values[0]')
+  [ "${actual}" = "consul" ]
+
+  local actual=$(echo $cfg | jq '.[0].failOpenDuringRollout')
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $cfg | jq -r '.[0].deploymentName')
+  [ "${actual}" = "release-name-consul-connect-injector" ]
+}
+
+@test "webhookCertManager/Configmap: failOpenDuringRollout can be disabled" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/webhook-cert-manager-configmap.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.failOpenDuringUpgrade=false' \
+      . | tee /dev/stderr |
+      yq -r '.data["webhook-config.json"]' | jq '.[0].failOpenDuringRollout' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
@@ -62,3 +62,99 @@ load _helpers
       yq -r '.spec.template.spec.tolerations[0].key' | tee /dev/stderr)
   [ "${actual}" = "value" ]
 }
+
+#--------------------------------------------------------------------
+# global.tls.serverCertRenewal
+
+@test "webhookCertManager/Deployment: no server-cert-renewer container by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/webhook-cert-manager-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers | length' | tee /dev/stderr)
+  [ "${actual}" = "1" ]
+}
+
+@test "webhookCertManager/Deployment: server-cert-renewer container renews the server certificate with global.tls.serverCertRenewal.enabled=true" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/webhook-cert-manager-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.serverCertRenewal.enabled=true' \
+      --set 'global.tls.serverCertRenewal.renewWithin=48h' \
+      --set 'global.tls.serverCertRenewal.checkInterval=5m' \
+      --set 'global.tls.serverAdditionalDNSSANs[0]=consul.example.com' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[1].command | join(" ")' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" | yq 'contains("-renew-within=48h")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'contains("-check-interval=5m")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'contains("-additional-dnsname=consul.example.com")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'contains("-metrics-addr")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "webhookCertManager/Deployment: server-cert-renewer container mounts the provided CA" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/webhook-cert-manager-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.serverCertRenewal.enabled=true' \
+      --set 'global.tls.caCert.secretName=foo-ca-cert' \
+      --set 'global.tls.caKey.secretName=foo-ca-key' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.volumes[] | select(.name=="consul-ca-key") | .secret.secretName' | tee /dev/stderr)
+  [ "${actual}" = "foo-ca-key" ]
+
+  local actual=$(echo "$object" | yq -r '.containers[1].volumeMounts | length' | tee /dev/stderr)
+  [ "${actual}" = "2" ]
+
+  local actual=$(echo "$object" | yq -r '.containers[1].command | join(" ") | contains("-key=/consul/tls/ca/key/tls.key")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "webhookCertManager/Deployment: certificate expiry metrics are served with global.metrics.enabled=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/webhook-cert-manager-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.serverCertRenewal.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.[0].command | join(" ") | contains("-metrics-addr=:9101")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$object" | yq -r '.[0].ports[0].containerPort' | tee /dev/stderr)
+  [ "${actual}" = "9101" ]
+
+  local actual=$(echo "$object" | yq -r '.[1].command | join(" ") | contains("-metrics-addr=:9102")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$object" | yq -r '.[1].ports[0].containerPort' | tee /dev/stderr)
+  [ "${actual}" = "9102" ]
+}
+
+@test "webhookCertManager/Deployment: disabled when the certificates come from another source" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/webhook-cert-manager-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'webhookCertManager.source=cert-manager' \
+      --set 'webhookCertManager.certManager.issuerRef.name=consul-ca' \
+      .
+}
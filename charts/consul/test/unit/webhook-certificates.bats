@@ -0,0 +1,108 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "webhookCertificates/Certificate: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/webhook-certificates.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'controller.enabled=true' \
+      .
+}
+
+@test "webhookCertificates/Certificate: fails with an invalid source" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/webhook-certificates.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'webhookCertManager.source=foo' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "webhookCertManager.source must be one of \"webhook-cert-manager\", \"cert-manager\" or \"vault\"" ]]
+}
+
+@test "webhookCertificates/Certificate: fails with cert-manager if no issuer is set" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/webhook-certificates.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'webhookCertManager.source=cert-manager' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "webhookCertManager.certManager.issuerRef.name must be set if webhookCertManager.source is \"cert-manager\"" ]]
+}
+
+@test "webhookCertificates/Certificate: fails with vault if the vault secrets backend is disabled" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/webhook-certificates.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'webhookCertManager.source=vault' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.secretsBackend.vault.enabled must be true if webhookCertManager.source is \"vault\"" ]]
+}
+
+@test "webhookCertificates/Certificate: fails with vault if the pki path is not set" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/webhook-certificates.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulClientRole=foo' \
+      --set 'global.secretsBackend.vault.consulServerRole=bar' \
+      --set 'webhookCertManager.source=vault' \
+      --set 'webhookCertManager.vault.role=webhooks' \
+      --set 'webhookCertManager.vault.caPath=pki_int/cert/ca' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "webhookCertManager.vault.role, pkiPath and caPath must be set if webhookCertManager.source is \"vault\"" ]]
+}
+
+@test "webhookCertificates/Certificate: certificates are issued by the issuer with cert-manager" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/webhook-certificates.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'controller.enabled=true' \
+      --set 'webhookCertManager.source=cert-manager' \
+      --set 'webhookCertManager.certManager.issuerRef.name=consul-ca' \
+      --set 'webhookCertManager.certManager.issuerRef.kind=ClusterIssuer' \
+      --set 'webhookCertManager.certManager.duration=720h' \
+      . | tee /dev/stderr |
+      yq -s '.' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.[0].spec.secretName' | tee /dev/stderr)
+  [ "${actual}" = "RELEASE-NAME-consul-connect-inject-webhook-cert" ]
+  local actual=$(echo $object | yq -r '.[0].spec.dnsNames | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "RELEASE-NAME-consul-connect-injector,RELEASE-NAME-consul-connect-injector.default,RELEASE-NAME-consul-connect-injector.default.svc,RELEASE-NAME-consul-connect-injector.default.svc.cluster.local" ]
+  local actual=$(echo $object | yq -r '.[0].spec.duration' | tee /dev/stderr)
+  [ "${actual}" = "720h" ]
+  local actual=$(echo $object | yq -r '.[0].spec | has("renewBefore")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+  local actual=$(echo $object | yq -r '.[0].spec.issuerRef.name' | tee /dev/stderr)
+  [ "${actual}" = "consul-ca" ]
+  local actual=$(echo $object | yq -r '.[0].spec.issuerRef.kind' | tee /dev/stderr)
+  [ "${actual}" = "ClusterIssuer" ]
+
+  local actual=$(echo $object | yq -r '.[1].spec.secretName' | tee /dev/stderr)
+  [ "${actual}" = "RELEASE-NAME-consul-controller-webhook-cert" ]
+  local actual=$(echo $object | yq -r '.[1].spec.dnsNames[2]' | tee /dev/stderr)
+  [ "${actual}" = "RELEASE-NAME-consul-controller-webhook.default.svc" ]
+}
+
+@test "webhookCertificates/Certificate: no certificates with vault" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/webhook-certificates.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulClientRole=foo' \
+      --set 'global.secretsBackend.vault.consulServerRole=bar' \
+      --set 'webhookCertManager.source=vault' \
+      --set 'webhookCertManager.vault.role=webhooks' \
+      --set 'webhookCertManager.vault.pkiPath=pki_int/issue/webhooks' \
+      --set 'webhookCertManager.vault.caPath=pki_int/cert/ca' \
+      .
+}
@@ -0,0 +1,204 @@
+// Package config contains the commands that read and change the Helm values
+// of the installed Consul release, and that print the sidecar settings pods
+// are injected with.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/helm"
+	"github.com/posener/complete"
+	"helm.sh/helm/v3/pkg/action"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+const (
+	flagNameAutoApprove = "auto-approve"
+	defaultAutoApprove  = false
+
+	flagNameTimeout = "timeout"
+	defaultTimeout  = "10m"
+
+	flagNameWait = "wait"
+	defaultWait  = true
+)
+
+// kubeFlags are the flags that select the Kubernetes cluster.
+type kubeFlags struct {
+	kubeConfig  string
+	kubeContext string
+}
+
+func (k *kubeFlags) addFlags(set *flag.Sets) {
+	f := set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &k.kubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &k.kubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+}
+
+// settings returns the Helm settings for the cluster selected by the flags.
+func (k *kubeFlags) settings() *helmCLI.EnvSettings {
+	// helmCLI.New() will create a settings object which is used by the Helm Go SDK calls.
+	settings := helmCLI.New()
+	if k.kubeConfig != "" {
+		settings.KubeConfig = k.kubeConfig
+	}
+	if k.kubeContext != "" {
+		settings.KubeContext = k.kubeContext
+	}
+	return settings
+}
+
+// applyFlags are the flags of the commands that apply new values as an
+// upgrade of the release.
+type applyFlags struct {
+	autoApprove bool
+	timeout     string
+	wait        bool
+}
+
+func (a *applyFlags) addFlags(f *flag.Set) {
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameAutoApprove,
+		Target:  &a.autoApprove,
+		Default: defaultAutoApprove,
+		Usage:   "Skip confirmation prompt.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameTimeout,
+		Target:  &a.timeout,
+		Default: defaultTimeout,
+		Usage:   "Set a timeout to wait for the upgrade to be ready.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameWait,
+		Target:  &a.wait,
+		Default: defaultWait,
+		Usage:   "Wait for Kubernetes resources in the upgrade to be ready before exiting command.",
+	})
+}
+
+func (a *applyFlags) validate() error {
+	if _, err := time.ParseDuration(a.timeout); err != nil {
+		return fmt.Errorf("unable to parse -%s: %s", flagNameTimeout, err)
+	}
+	return nil
+}
+
+// fetchRelease finds the installed Consul release.
+func fetchRelease(settings *helmCLI.EnvSettings, uiLogger action.DebugLog) (*release.Release, error) {
+	name, namespace, err := common.CheckForInstallations(settings, uiLogger)
+	if err != nil {
+		return nil, fmt.Errorf("existing Consul installation not found: %s", err)
+	}
+	return helm.FetchRelease(namespace, name, settings, uiLogger)
+}
+
+// applyValues prints the difference between the current and the new
+// user-supplied values of the release and, once confirmed, upgrades the
+// release to the new values. The release keeps the chart it was installed
+// from so that only the values change.
+func applyValues(base *common.BaseCommand, flags applyFlags, settings *helmCLI.EnvSettings, rel *release.Release, vals map[string]interface{}) int {
+	vals, err := normalizeValues(vals)
+	if err != nil {
+		base.UI.Output("Invalid values: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+	if (len(rel.Config) == 0 && len(vals) == 0) || reflect.DeepEqual(rel.Config, vals) {
+		base.UI.Output("The values are unchanged. No upgrade is needed.", terminal.WithInfoStyle())
+		return 0
+	}
+
+	diff, err := common.Diff(rel.Config, vals)
+	if err != nil {
+		base.UI.Output("Could not print the difference between the current and new values: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+
+	base.UI.Output("Difference between the current and new values", terminal.WithHeaderStyle())
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "+") {
+			base.UI.Output("%s", line, terminal.WithDiffAddedStyle())
+		} else if strings.HasPrefix(line, "-") {
+			base.UI.Output("%s", line, terminal.WithDiffRemovedStyle())
+		} else {
+			base.UI.Output("%s", line, terminal.WithDiffUnchangedStyle())
+		}
+	}
+
+	if !flags.autoApprove {
+		confirmation, err := base.UI.Input(&terminal.Input{
+			Prompt: "Apply the new values? (y/N)",
+			Style:  terminal.InfoStyle,
+			Secret: false,
+		})
+		if err != nil {
+			base.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+		if common.Abort(confirmation) {
+			base.UI.Output("Values not applied.", terminal.WithInfoStyle())
+			return 1
+		}
+	}
+
+	base.UI.Output("Upgrading Consul", terminal.WithHeaderStyle())
+	uiLogger := func(s string, args ...interface{}) {
+		base.UI.Output(s, append(args, terminal.WithLibraryStyle())...)
+	}
+	actionConfig := new(action.Configuration)
+	actionConfig, err = helm.InitActionConfig(actionConfig, rel.Namespace, settings, uiLogger)
+	if err != nil {
+		base.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	// The timeout has been validated with the flags.
+	timeout, _ := time.ParseDuration(flags.timeout)
+	upgrade := action.NewUpgrade(actionConfig)
+	upgrade.Namespace = rel.Namespace
+	upgrade.Wait = flags.wait
+	upgrade.Timeout = timeout
+	if _, err := upgrade.Run(rel.Name, rel.Chart, vals); err != nil {
+		base.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	base.UI.Output("Consul upgraded with the new values in namespace %q.", rel.Namespace, terminal.WithSuccessStyle())
+	return 0
+}
+
+// normalizeValues converts the values to the types they have once stored with
+// the release, e.g. numbers to float64, so that they can be compared with the
+// values of the release.
+func normalizeValues(vals map[string]interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(vals)
+	if err != nil {
+		return nil, err
+	}
+	var normalized map[string]interface{}
+	if err := json.Unmarshal(raw, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateFlags tests the validate flags functions of the commands.
+func TestValidateFlags(t *testing.T) {
+	// The following cases should all error, if they fail to this test fails.
+	testCases := []struct {
+		description string
+		validate    func(args []string) error
+		input       []string
+	}{
+		{
+			"read: Should disallow non-flag arguments.",
+			func(args []string) error { return newReadCommand(t).validateFlags(args) },
+			[]string{"foo"},
+		},
+		{
+			"read: Should disallow an unknown output format.",
+			func(args []string) error { return newReadCommand(t).validateFlags(args) },
+			[]string{"-output", "xml"},
+		},
+		{
+			"effective: Should require a pod.",
+			func(args []string) error { return newEffectiveCommand(t).validateFlags(args) },
+			[]string{"-namespace", "apps"},
+		},
+		{
+			"effective: Should disallow non-flag arguments.",
+			func(args []string) error { return newEffectiveCommand(t).validateFlags(args) },
+			[]string{"-pod", "web", "foo"},
+		},
+		{
+			"write: Should require a values file.",
+			func(args []string) error { return newWriteCommand(t).validateFlags(args) },
+			[]string{},
+		},
+		{
+			"write: Should disallow an invalid timeout.",
+			func(args []string) error { return newWriteCommand(t).validateFlags(args) },
+			[]string{"-f", "values.yaml", "-timeout", "10"},
+		},
+		{
+			"set: Should require a value.",
+			func(args []string) error {
+				_, err := newSetCommand(t).validateFlags(args)
+				return err
+			},
+			[]string{"-auto-approve"},
+		},
+		{
+			"set: Should disallow values after the flags.",
+			func(args []string) error {
+				_, err := newSetCommand(t).validateFlags(args)
+				return err
+			},
+			[]string{"-auto-approve", "connectInject.replicas=3"},
+		},
+		{
+			"set: Should disallow invalid values.",
+			func(args []string) error {
+				_, err := newSetCommand(t).validateFlags(args)
+				return err
+			},
+			[]string{"connectInject.replicas"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.description, func(t *testing.T) {
+			if err := testCase.validate(testCase.input); err == nil {
+				t.Errorf("Test case should have failed.")
+			}
+		})
+	}
+}
+
+func TestSetCommand_ParsesValues(t *testing.T) {
+	c := newSetCommand(t)
+	overrides, err := c.validateFlags([]string{"connectInject.replicas=3", "global.name=consul", "-auto-approve"})
+	require.NoError(t, err)
+	require.True(t, c.autoApprove)
+	require.Equal(t, map[string]interface{}{
+		"connectInject": map[string]interface{}{"replicas": int64(3)},
+		"global":        map[string]interface{}{"name": "consul"},
+	}, overrides)
+
+	// The overrides keep the other user-supplied values.
+	current := map[string]interface{}{
+		"connectInject": map[string]interface{}{"enabled": true, "replicas": float64(2)},
+	}
+	vals, err := normalizeValues(common.MergeMaps(current, overrides))
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"connectInject": map[string]interface{}{"enabled": true, "replicas": float64(3)},
+		"global":        map[string]interface{}{"name": "consul"},
+	}, vals)
+	require.Equal(t, map[string]interface{}{"enabled": true, "replicas": float64(2)}, current["connectInject"])
+}
+
+func TestReadValuesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "values.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("connectInject:\n  enabled: true\n"), 0600))
+
+	vals, err := readValuesFile(path)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"connectInject": map[string]interface{}{"enabled": true}}, vals)
+
+	require.NoError(t, os.WriteFile(path, []byte("connectInject: ["), 0600))
+	_, err = readValuesFile(path)
+	require.Error(t, err)
+}
+
+func TestFormatValues(t *testing.T) {
+	vals := map[string]interface{}{"global": map[string]interface{}{"name": "consul"}}
+
+	out, err := formatValues(vals, outputYAML)
+	require.NoError(t, err)
+	require.Equal(t, "global:\n  name: consul\n", out)
+
+	out, err = formatValues(vals, outputJSON)
+	require.NoError(t, err)
+	require.Equal(t, "{\n  \"global\": {\n    \"name\": \"consul\"\n  }\n}", out)
+
+	out, err = formatValues(nil, outputYAML)
+	require.NoError(t, err)
+	require.Equal(t, "{}\n", out)
+}
+
+func newReadCommand(t *testing.T) *ReadCommand {
+	c := &ReadCommand{BaseCommand: getBaseCommand(t)}
+	c.init()
+	return c
+}
+
+func newWriteCommand(t *testing.T) *WriteCommand {
+	c := &WriteCommand{BaseCommand: getBaseCommand(t)}
+	c.init()
+	return c
+}
+
+func newSetCommand(t *testing.T) *SetCommand {
+	c := &SetCommand{BaseCommand: getBaseCommand(t)}
+	c.init()
+	return c
+}
+
+// getBaseCommand sets up the base command for tests.
+func getBaseCommand(t *testing.T) *common.BaseCommand {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	return &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+	}
+}
@@ -0,0 +1,274 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/posener/complete"
+	"helm.sh/helm/v3/pkg/chartutil"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	flagNamePod = "pod"
+
+	flagNameNamespace = "namespace"
+	defaultNamespace  = "default"
+
+	// annotationPrefix is the prefix of the sidecar annotations. The keys of the
+	// namespace sidecar config ConfigMap are the annotations without it.
+	annotationPrefix = "consul.hashicorp.com/"
+
+	// annotationOriginalPod is set by the injector to the pod before it was
+	// injected, which has the annotations set on the pod itself.
+	annotationOriginalPod = "consul.hashicorp.com/original-pod"
+
+	sourcePod       = "pod annotation"
+	sourceNamespace = "namespace ConfigMap %s/%s"
+	sourceHelm      = "Helm value %s"
+	sourceUnset     = "not set"
+)
+
+// sidecarSettings are the sidecar settings that the connect injector resolves
+// from the pod annotations, the namespace sidecar config ConfigMap and the Helm
+// values, in that order. helmValue is empty for the settings that have no
+// default in the Helm values.
+var sidecarSettings = []struct {
+	key       string
+	helmValue string
+}{
+	{"sidecar-proxy-cpu-request", "connectInject.sidecarProxy.resources.requests.cpu"},
+	{"sidecar-proxy-cpu-limit", "connectInject.sidecarProxy.resources.limits.cpu"},
+	{"sidecar-proxy-memory-request", "connectInject.sidecarProxy.resources.requests.memory"},
+	{"sidecar-proxy-memory-limit", "connectInject.sidecarProxy.resources.limits.memory"},
+	{"consul-sidecar-cpu-request", "global.consulSidecarContainer.resources.requests.cpu"},
+	{"consul-sidecar-cpu-limit", "global.consulSidecarContainer.resources.limits.cpu"},
+	{"consul-sidecar-memory-request", "global.consulSidecarContainer.resources.requests.memory"},
+	{"consul-sidecar-memory-limit", "global.consulSidecarContainer.resources.limits.memory"},
+	{"envoy-extra-args", "connectInject.envoyExtraArgs"},
+	{"envoy-concurrency", ""},
+	{"envoy-overload-max-heap-size", ""},
+	{"envoy-overload-shrink-heap-threshold", ""},
+	{"envoy-overload-stop-accepting-requests-threshold", ""},
+	{"envoy-bootstrap-extra-config", ""},
+	{"hold-application-until-proxy-starts", "connectInject.holdApplicationUntilProxyStarts"},
+}
+
+// sidecarSetting is a resolved sidecar setting of a pod.
+type sidecarSetting struct {
+	Name   string
+	Value  string
+	Source string
+}
+
+// EffectiveCommand prints the sidecar settings of a Connect injected pod and
+// where each of them comes from.
+type EffectiveCommand struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+
+	set *flag.Sets
+
+	flagPod       string
+	flagNamespace string
+	kubeFlags
+
+	once sync.Once
+	help string
+}
+
+func (c *EffectiveCommand) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNamePod,
+		Aliases: []string{"p"},
+		Target:  &c.flagPod,
+		Default: "",
+		Usage:   "The name of the pod to print the sidecar settings of.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       flagNameNamespace,
+		Aliases:    []string{"n"},
+		Target:     &c.flagNamespace,
+		Default:    defaultNamespace,
+		Usage:      "The namespace of the pod.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	c.kubeFlags.addFlags(c.set)
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run prints the sidecar settings of the pod resolved from its annotations,
+// the sidecar config of its namespace and the Helm values.
+func (c *EffectiveCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("config effective")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+
+	settings := c.settings()
+	rel, err := fetchRelease(settings, func(string, ...interface{}) {})
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	vals, err := chartutil.CoalesceValues(rel.Chart, rel.Config)
+	if err != nil {
+		c.UI.Output("Error computing values: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+
+	if c.kubernetes == nil {
+		var restConfig *rest.Config
+		if _, err := common.InitKubernetes(c.kubeConfig, c.kubeContext, &restConfig, &c.kubernetes); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+	}
+
+	pod, err := c.kubernetes.CoreV1().Pods(c.flagNamespace).Get(c.Ctx, c.flagPod, metav1.GetOptions{})
+	if err != nil {
+		c.UI.Output("Error getting pod %s/%s: %v", c.flagNamespace, c.flagPod, err, terminal.WithErrorStyle())
+		return 1
+	}
+	if _, ok := pod.Annotations[common.InjectStatusAnnotation]; !ok {
+		c.UI.Output("Pod %s/%s is not Connect injected. Its sidecars would be injected with these settings.",
+			c.flagNamespace, c.flagPod, terminal.WithWarningStyle())
+	}
+
+	resolved, err := c.effectiveSettings(pod, vals)
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	c.UI.Output("Sidecar settings of pod %s/%s", c.flagNamespace, c.flagPod, terminal.WithHeaderStyle())
+	tbl := terminal.NewTable("Setting", "Value", "Source")
+	for _, s := range resolved {
+		tbl.Rich([]string{s.Name, s.Value, s.Source}, nil)
+	}
+	c.UI.Table(tbl)
+	return 0
+}
+
+// effectiveSettings resolves the sidecar settings of the pod. Since the
+// injector adds the settings of the namespace to the annotations of the pods
+// it injects, the annotations set on the pod itself are read from the pod
+// before injection.
+func (c *EffectiveCommand) effectiveSettings(pod *corev1.Pod, vals chartutil.Values) ([]sidecarSetting, error) {
+	podAnnotations, err := originalPodAnnotations(pod)
+	if err != nil {
+		return nil, err
+	}
+
+	var configMap *corev1.ConfigMap
+	if enabled, _ := vals.PathValue("connectInject.namespaceSidecarConfig.enabled"); enabled == true {
+		name, _ := vals.PathValue("connectInject.namespaceSidecarConfig.configMapName")
+		configMap, err = c.kubernetes.CoreV1().ConfigMaps(pod.Namespace).Get(c.Ctx, fmt.Sprint(name), metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			configMap = nil
+		} else if err != nil {
+			return nil, fmt.Errorf("error getting the sidecar config of namespace %s: %v", pod.Namespace, err)
+		}
+	}
+
+	return resolveSidecarSettings(podAnnotations, configMap, vals), nil
+}
+
+// originalPodAnnotations returns the annotations of the pod before it was
+// injected, or its annotations if it isn't injected.
+func originalPodAnnotations(pod *corev1.Pod) (map[string]string, error) {
+	raw, ok := pod.Annotations[annotationOriginalPod]
+	if !ok {
+		return pod.Annotations, nil
+	}
+	var original corev1.Pod
+	if err := json.Unmarshal([]byte(raw), &original); err != nil {
+		return nil, fmt.Errorf("error parsing annotation %s of pod %s/%s: %v", annotationOriginalPod, pod.Namespace, pod.Name, err)
+	}
+	return original.Annotations, nil
+}
+
+// resolveSidecarSettings resolves each sidecar setting from the pod
+// annotations, then from the namespace sidecar config ConfigMap if it exists,
+// and then from the Helm values.
+func resolveSidecarSettings(podAnnotations map[string]string, configMap *corev1.ConfigMap, vals chartutil.Values) []sidecarSetting {
+	var namespaceConfig map[string]string
+	if configMap != nil {
+		namespaceConfig = configMap.Data
+	}
+	resolved := make([]sidecarSetting, 0, len(sidecarSettings))
+	for _, setting := range sidecarSettings {
+		s := sidecarSetting{Name: setting.key, Source: sourceUnset}
+		if value, ok := podAnnotations[annotationPrefix+setting.key]; ok {
+			s.Value, s.Source = value, sourcePod
+		} else if value, ok := namespaceConfig[setting.key]; ok {
+			s.Value, s.Source = value, fmt.Sprintf(sourceNamespace, configMap.Namespace, configMap.Name)
+		} else if setting.helmValue != "" {
+			if value, err := vals.PathValue(setting.helmValue); err == nil && value != nil {
+				s.Value, s.Source = fmt.Sprint(value), fmt.Sprintf(sourceHelm, setting.helmValue)
+			}
+		}
+		resolved = append(resolved, s)
+	}
+	return resolved
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *EffectiveCommand) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if c.flagPod == "" {
+		return fmt.Errorf("-%s must be set", flagNamePod)
+	}
+	return nil
+}
+
+// Help returns a description of the command and how it is used.
+func (c *EffectiveCommand) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s config effective -pod <name> [flags]\n\n" +
+		"Settings are resolved from the annotations of the pod, then from the sidecar config\n" +
+		"ConfigMap of its namespace, and then from the Helm values of the installation.\n\n" + c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *EffectiveCommand) Synopsis() string {
+	return "Print the effective sidecar settings of a pod and where they come from."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *EffectiveCommand) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *EffectiveCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
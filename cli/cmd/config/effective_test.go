@@ -0,0 +1,121 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEffectiveCommand_ResolvesSettingsInOrder(t *testing.T) {
+	vals := chartutil.Values{
+		"connectInject": map[string]interface{}{
+			"namespaceSidecarConfig": map[string]interface{}{
+				"enabled":       true,
+				"configMapName": "consul-sidecar-config",
+			},
+			"holdApplicationUntilProxyStarts": false,
+			"sidecarProxy": map[string]interface{}{
+				"resources": map[string]interface{}{
+					"requests": map[string]interface{}{"cpu": "50m", "memory": nil},
+					"limits":   map[string]interface{}{"cpu": "100m", "memory": "128Mi"},
+				},
+			},
+		},
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-sidecar-config", Namespace: "apps"},
+		Data: map[string]string{
+			"sidecar-proxy-cpu-limit":    "200m",
+			"sidecar-proxy-memory-limit": "256Mi",
+			"envoy-concurrency":          "2",
+		},
+	}
+	// The injector added the settings of the namespace to the annotations of
+	// the pod, so only the original pod tells which ones the pod sets itself.
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web",
+			Namespace: "apps",
+			Annotations: map[string]string{
+				common.InjectStatusAnnotation:                   "injected",
+				annotationPrefix + "sidecar-proxy-cpu-limit":    "300m",
+				annotationPrefix + "sidecar-proxy-memory-limit": "256Mi",
+				annotationPrefix + "envoy-concurrency":          "2",
+				annotationOriginalPod:                           `{"metadata":{"annotations":{"consul.hashicorp.com/sidecar-proxy-cpu-limit":"300m"}}}`,
+			},
+		},
+	}
+
+	c := newEffectiveCommand(t)
+	c.kubernetes = fake.NewSimpleClientset(configMap)
+	resolved, err := c.effectiveSettings(pod, vals)
+	require.NoError(t, err)
+
+	actual := make(map[string]sidecarSetting)
+	for _, s := range resolved {
+		actual[s.Name] = s
+	}
+	require.Len(t, actual, len(sidecarSettings))
+	require.Equal(t, sidecarSetting{"sidecar-proxy-cpu-limit", "300m", "pod annotation"}, actual["sidecar-proxy-cpu-limit"])
+	require.Equal(t, sidecarSetting{"sidecar-proxy-memory-limit", "256Mi", "namespace ConfigMap apps/consul-sidecar-config"}, actual["sidecar-proxy-memory-limit"])
+	require.Equal(t, sidecarSetting{"envoy-concurrency", "2", "namespace ConfigMap apps/consul-sidecar-config"}, actual["envoy-concurrency"])
+	require.Equal(t, sidecarSetting{"sidecar-proxy-cpu-request", "50m", "Helm value connectInject.sidecarProxy.resources.requests.cpu"}, actual["sidecar-proxy-cpu-request"])
+	require.Equal(t, sidecarSetting{"hold-application-until-proxy-starts", "false", "Helm value connectInject.holdApplicationUntilProxyStarts"}, actual["hold-application-until-proxy-starts"])
+	require.Equal(t, sidecarSetting{"sidecar-proxy-memory-request", "", "not set"}, actual["sidecar-proxy-memory-request"])
+	require.Equal(t, sidecarSetting{"envoy-extra-args", "", "not set"}, actual["envoy-extra-args"])
+}
+
+func TestEffectiveCommand_IgnoresNamespaceConfigUnlessEnabled(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-sidecar-config", Namespace: "default"},
+		Data:       map[string]string{"envoy-concurrency": "2"},
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+
+	c := newEffectiveCommand(t)
+	c.kubernetes = fake.NewSimpleClientset(configMap)
+	for _, vals := range []chartutil.Values{
+		{},
+		{"connectInject": map[string]interface{}{"namespaceSidecarConfig": map[string]interface{}{
+			"enabled": false, "configMapName": "consul-sidecar-config",
+		}}},
+	} {
+		resolved, err := c.effectiveSettings(pod, vals)
+		require.NoError(t, err)
+		for _, s := range resolved {
+			require.Equal(t, "not set", s.Source, s.Name)
+		}
+	}
+
+	// A missing ConfigMap means that the namespace doesn't override anything.
+	c.kubernetes = fake.NewSimpleClientset()
+	resolved, err := c.effectiveSettings(pod, chartutil.Values{"connectInject": map[string]interface{}{
+		"namespaceSidecarConfig": map[string]interface{}{"enabled": true, "configMapName": "consul-sidecar-config"},
+	}})
+	require.NoError(t, err)
+	require.Len(t, resolved, len(sidecarSettings))
+}
+
+func TestOriginalPodAnnotations(t *testing.T) {
+	annotations, err := originalPodAnnotations(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{"foo": "bar"},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"foo": "bar"}, annotations)
+
+	_, err = originalPodAnnotations(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{annotationOriginalPod: "{"},
+	}})
+	require.Error(t, err)
+}
+
+func newEffectiveCommand(t *testing.T) *EffectiveCommand {
+	c := &EffectiveCommand{BaseCommand: getBaseCommand(t)}
+	c.init()
+	return c
+}
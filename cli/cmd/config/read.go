@@ -0,0 +1,149 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/posener/complete"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	flagNameAll = "all"
+	defaultAll  = false
+
+	flagNameOutput = "output"
+	outputYAML     = "yaml"
+	outputJSON     = "json"
+	defaultOutput  = outputYAML
+)
+
+// ReadCommand prints the Helm values of the installed release.
+type ReadCommand struct {
+	*common.BaseCommand
+
+	set *flag.Sets
+
+	flagAll    bool
+	flagOutput string
+	kubeFlags
+
+	once sync.Once
+	help string
+}
+
+func (c *ReadCommand) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameAll,
+		Target:  &c.flagAll,
+		Default: defaultAll,
+		Usage:   "Print the computed values, i.e. the user-supplied values merged with the chart defaults.",
+	})
+	f.EnumSingleVar(&flag.EnumSingleVar{
+		Name:    flagNameOutput,
+		Aliases: []string{"o"},
+		Target:  &c.flagOutput,
+		Default: defaultOutput,
+		Values:  []string{outputYAML, outputJSON},
+		Usage:   "Set the format the values are printed in.",
+	})
+	c.kubeFlags.addFlags(c.set)
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run prints the user-supplied or computed values of the installed release.
+func (c *ReadCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("config read")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+
+	rel, err := fetchRelease(c.settings(), func(string, ...interface{}) {})
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	vals := rel.Config
+	if c.flagAll {
+		vals, err = chartutil.CoalesceValues(rel.Chart, rel.Config)
+		if err != nil {
+			c.UI.Output("Error computing values: %v", err, terminal.WithErrorStyle())
+			return 1
+		}
+	}
+
+	out, err := formatValues(vals, c.flagOutput)
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	c.UI.Output("%s", out)
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *ReadCommand) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	return nil
+}
+
+// formatValues marshals the values to the given output format.
+func formatValues(vals map[string]interface{}, output string) (string, error) {
+	if vals == nil {
+		vals = map[string]interface{}{}
+	}
+	var out []byte
+	var err error
+	switch output {
+	case outputJSON:
+		out, err = json.MarshalIndent(vals, "", "  ")
+	default:
+		out, err = yaml.Marshal(vals)
+	}
+	return string(out), err
+}
+
+// Help returns a description of the command and how it is used.
+func (c *ReadCommand) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s config read [flags]\n\n" + c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *ReadCommand) Synopsis() string {
+	return "Print the Helm values of the Consul installation."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *ReadCommand) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *ReadCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
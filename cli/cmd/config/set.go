@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/posener/complete"
+	"helm.sh/helm/v3/pkg/strvals"
+)
+
+// SetCommand changes individual Helm values of the installed release.
+type SetCommand struct {
+	*common.BaseCommand
+
+	set *flag.Sets
+
+	applyFlags
+	kubeFlags
+
+	once sync.Once
+	help string
+}
+
+func (c *SetCommand) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	c.applyFlags.addFlags(f)
+	c.kubeFlags.addFlags(c.set)
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run sets the values given as key=value arguments on top of the user-supplied
+// values of the installed release and applies them as an upgrade.
+func (c *SetCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("config set")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	overrides, err := c.validateFlags(args)
+	if err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+
+	settings := c.settings()
+	rel, err := fetchRelease(settings, func(string, ...interface{}) {})
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	return applyValues(c.BaseCommand, c.applyFlags, settings, rel, common.MergeMaps(rel.Config, overrides))
+}
+
+// validateFlags checks the command line flags and values for errors and
+// returns the values set by the key=value arguments.
+func (c *SetCommand) validateFlags(args []string) (map[string]interface{}, error) {
+	// The values to set come before the flags.
+	var pairs []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		pairs = append(pairs, args[0])
+		args = args[1:]
+	}
+	if err := c.set.Parse(args); err != nil {
+		return nil, err
+	}
+	if len(c.set.Args()) > 0 {
+		return nil, errors.New("values must be set before the flags")
+	}
+	if len(pairs) == 0 {
+		return nil, errors.New("at least one key=value pair must be set")
+	}
+	if err := c.applyFlags.validate(); err != nil {
+		return nil, err
+	}
+
+	overrides := map[string]interface{}{}
+	for _, pair := range pairs {
+		if err := strvals.ParseInto(pair, overrides); err != nil {
+			return nil, fmt.Errorf("invalid value %q: %s", pair, err)
+		}
+	}
+	return overrides, nil
+}
+
+// Help returns a description of the command and how it is used.
+func (c *SetCommand) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s config set <key>=<value> [<key>=<value> ...] [flags]\n\n" +
+		"The values are set on top of the user-supplied values of the installation, e.g.\n" +
+		"`consul-k8s config set connectInject.replicas=3`, using the same syntax as -set of\n" +
+		"`consul-k8s upgrade`. The installation is upgraded with the new values using the\n" +
+		"chart it was installed from.\n\n" + c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *SetCommand) Synopsis() string {
+	return "Change Helm values of the Consul installation."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *SetCommand) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *SetCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/posener/complete"
+	"sigs.k8s.io/yaml"
+)
+
+const flagNameConfigFile = "config-file"
+
+// WriteCommand replaces the user-supplied Helm values of the installed release
+// with the values of a file.
+type WriteCommand struct {
+	*common.BaseCommand
+
+	set *flag.Sets
+
+	flagConfigFile string
+	applyFlags
+	kubeFlags
+
+	once sync.Once
+	help string
+}
+
+func (c *WriteCommand) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:       flagNameConfigFile,
+		Aliases:    []string{"f"},
+		Target:     &c.flagConfigFile,
+		Usage:      "Set the path to the Helm values file that replaces the user-supplied values of the installation.",
+		Completion: complete.PredictFiles("*.yaml"),
+	})
+	c.applyFlags.addFlags(f)
+	c.kubeFlags.addFlags(c.set)
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run replaces the user-supplied values of the installed release with the
+// values from the file and applies them as an upgrade.
+func (c *WriteCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("config write")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+
+	vals, err := readValuesFile(c.flagConfigFile)
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	settings := c.settings()
+	rel, err := fetchRelease(settings, func(string, ...interface{}) {})
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	return applyValues(c.BaseCommand, c.applyFlags, settings, rel, vals)
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *WriteCommand) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if c.flagConfigFile == "" {
+		return fmt.Errorf("-%s must be set", flagNameConfigFile)
+	}
+	return c.applyFlags.validate()
+}
+
+// readValuesFile reads Helm values from a YAML file.
+func readValuesFile(path string) (map[string]interface{}, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading values file: %s", err)
+	}
+	vals := map[string]interface{}{}
+	if err := yaml.Unmarshal(raw, &vals); err != nil {
+		return nil, fmt.Errorf("error parsing values file %s: %s", path, err)
+	}
+	return vals, nil
+}
+
+// Help returns a description of the command and how it is used.
+func (c *WriteCommand) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s config write -f <values-file> [flags]\n\n" +
+		"The values in the file replace all user-supplied values of the installation. The\n" +
+		"installation is upgraded with the new values using the chart it was installed from.\n\n" + c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *WriteCommand) Synopsis() string {
+	return "Replace the Helm values of the Consul installation with a values file."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *WriteCommand) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *WriteCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
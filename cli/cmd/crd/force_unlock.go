@@ -0,0 +1,528 @@
+// Package crd contains the commands that manage the config entry custom
+// resources.
+package crd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/posener/complete"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	flagNameNamespace    = "namespace"
+	flagNameCRDNamespace = "crd-namespace"
+	flagNameToken        = "token"
+	flagNameCAFile       = "ca-file"
+
+	flagNameAll = "all"
+
+	flagNameAutoApprove = "auto-approve"
+	defaultAutoApprove  = false
+
+	flagNameUnreachableFor = "unreachable-for"
+	defaultUnreachableFor  = time.Minute
+
+	// finalizerName is the finalizer the controller adds to the config entry
+	// CRDs so that it can delete the config entries from Consul.
+	finalizerName = "finalizers.consul.hashicorp.com"
+
+	defaultPollInterval = 5 * time.Second
+)
+
+// configEntryResources are the plural resource names of the config entry
+// CRDs.
+var configEntryResources = []string{
+	"servicedefaults",
+	"proxydefaults",
+	"meshes",
+	"serviceresolvers",
+	"servicerouters",
+	"servicesplitters",
+	"serviceintentions",
+	"ingressgateways",
+	"terminatinggateways",
+	"exportedservices",
+	"jwtproviders",
+}
+
+func groupVersionResource(resource string) schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: "consul.hashicorp.com", Version: "v1alpha1", Resource: resource}
+}
+
+// ForceUnlockCommand removes the finalizer from config entry CRDs that are
+// being deleted when the Consul servers are gone.
+type ForceUnlockCommand struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+	dynamic    dynamic.Interface
+	restConfig *rest.Config
+
+	set *flag.Sets
+
+	flagNamespace      string
+	flagCRDNamespace   string
+	flagToken          string
+	flagCAFile         string
+	flagAll            bool
+	flagAutoApprove    bool
+	flagUnreachableFor time.Duration
+
+	flagKubeConfig  string
+	flagKubeContext string
+
+	// targets are the <resource>/<name> arguments.
+	targets []target
+
+	// openServer returns a client for the HTTP API of the server pod and a
+	// function that closes the connection. It port forwards to the pod if it
+	// is not set, which lets tests replace it.
+	openServer consul.ServerOpener
+	// pollInterval is how often the servers are checked while verifying that
+	// they are unreachable. It defaults to defaultPollInterval.
+	pollInterval time.Duration
+
+	once sync.Once
+	help string
+}
+
+// target is a CRD selected on the command line.
+type target struct {
+	Resource string
+	Name     string
+}
+
+func (c *ForceUnlockCommand) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameNamespace,
+		Aliases: []string{"n"},
+		Target:  &c.flagNamespace,
+		Usage: "Set the namespace of the Consul installation. " +
+			"If not set, the namespace of the installed Helm release is used.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       flagNameCRDNamespace,
+		Target:     &c.flagCRDNamespace,
+		Default:    "",
+		Usage:      "Set the namespace of the custom resources. If not set, all namespaces are searched.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameAll,
+		Target:  &c.flagAll,
+		Default: false,
+		Usage:   "Unlock all config entry custom resources that are being deleted instead of the given ones.",
+	})
+	f.DurationVar(&flag.DurationVar{
+		Name:    flagNameUnreachableFor,
+		Target:  &c.flagUnreachableFor,
+		Default: defaultUnreachableFor,
+		Usage:   "Set how long the Consul servers must be unreachable before the finalizers are removed.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameToken,
+		Target:  &c.flagToken,
+		Default: "",
+		Usage: fmt.Sprintf("Set the ACL token used to call the Consul API. "+
+			"If not set, the %s environment variable is used.", common.TokenEnvVar),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameCAFile,
+		Target:  &c.flagCAFile,
+		Default: "",
+		Usage: fmt.Sprintf("Set the path to the CA certificate of the Consul servers. If set, the servers are called "+
+			"over HTTPS on port %d instead of HTTP on port %d.", consul.HTTPSPort, consul.HTTPPort),
+		Completion: complete.PredictFiles("*"),
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameAutoApprove,
+		Target:  &c.flagAutoApprove,
+		Default: defaultAutoApprove,
+		Usage:   "Skip confirmation prompt.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run removes the finalizer from the selected config entry CRDs once the
+// Consul servers have been unreachable for the configured duration.
+func (c *ForceUnlockCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("crd force-unlock")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+
+	if err := c.setup(); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	locked, err := c.lockedCRDs()
+	if err != nil {
+		c.UI.Output("Unable to list the custom resources: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+	if len(locked) == 0 {
+		c.UI.Output("No config entry custom resources are waiting to be deleted.", terminal.WithInfoStyle())
+		return 0
+	}
+
+	c.UI.Output("Verifying that the Consul servers are unreachable for %s", c.flagUnreachableFor, terminal.WithHeaderStyle())
+	if err := c.verifyUnreachable(); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		c.UI.Output("No finalizers were removed. The controller deletes the config entries while Consul is reachable.", terminal.WithErrorStyle())
+		return 1
+	}
+	c.UI.Output("The Consul servers are unreachable.", terminal.WithSuccessStyle())
+
+	for _, crd := range locked {
+		c.UI.Output("%s/%s in namespace %s", crd.resource, crd.obj.GetName(), crd.obj.GetNamespace(), terminal.WithInfoStyle())
+	}
+	if !c.flagAutoApprove {
+		confirmation, err := c.UI.Input(&terminal.Input{
+			Prompt: fmt.Sprintf("Remove the finalizer from the %d custom resources? Their config entries will not be deleted from Consul. (y/N)", len(locked)),
+			Style:  terminal.InfoStyle,
+			Secret: false,
+		})
+		if err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+		if common.Abort(confirmation) {
+			c.UI.Output("No finalizers were removed.", terminal.WithInfoStyle())
+			return 1
+		}
+	}
+
+	for _, crd := range locked {
+		if err := c.unlock(crd); err != nil {
+			c.UI.Output("Error removing the finalizer from %s/%s: %v", crd.resource, crd.obj.GetName(), err, terminal.WithErrorStyle())
+			return 1
+		}
+	}
+	c.UI.Output("Removed the finalizer from %d custom resources.", len(locked), terminal.WithSuccessStyle())
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *ForceUnlockCommand) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if c.flagAll == (len(c.set.Args()) > 0) {
+		return fmt.Errorf("either -%s or the custom resources to unlock must be given as <resource>/<name>", flagNameAll)
+	}
+	c.targets = nil
+	for _, arg := range c.set.Args() {
+		t, err := parseTarget(arg)
+		if err != nil {
+			return err
+		}
+		c.targets = append(c.targets, t)
+	}
+	if c.flagUnreachableFor <= 0 {
+		return fmt.Errorf("-%s must be greater than zero", flagNameUnreachableFor)
+	}
+	return nil
+}
+
+// parseTarget parses a <resource>/<name> argument, e.g.
+// "servicedefaults/web". The resource may also be given as the singular
+// resource name, e.g. "servicedefault".
+func parseTarget(arg string) (target, error) {
+	parts := strings.Split(arg, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return target{}, fmt.Errorf("%q must be of the form <resource>/<name>", arg)
+	}
+	resource := strings.ToLower(parts[0])
+	for _, r := range configEntryResources {
+		if resource == r || resource+"s" == r || resource+"es" == r {
+			return target{Resource: r, Name: parts[1]}, nil
+		}
+	}
+	return target{}, fmt.Errorf("%q is not a config entry resource, must be one of: %s", parts[0], strings.Join(configEntryResources, ", "))
+}
+
+// setup creates the Kubernetes clients and finds the namespace of the Consul
+// installation if -namespace is not set.
+func (c *ForceUnlockCommand) setup() error {
+	if c.flagToken == "" {
+		c.flagToken = os.Getenv(common.TokenEnvVar)
+	}
+	if c.pollInterval == 0 {
+		c.pollInterval = defaultPollInterval
+	}
+
+	settings, err := common.InitKubernetes(c.flagKubeConfig, c.flagKubeContext, &c.restConfig, &c.kubernetes)
+	if err != nil {
+		return err
+	}
+	if c.dynamic == nil {
+		var err error
+		c.dynamic, err = dynamic.NewForConfig(c.restConfig)
+		if err != nil {
+			return fmt.Errorf("error initializing Kubernetes client:\n%v", err)
+		}
+	}
+
+	if c.flagNamespace == "" {
+		uiLogger := func(msg string, args ...interface{}) {
+			c.Log.Debug(fmt.Sprintf(msg, args...))
+		}
+		_, namespace, err := common.CheckForInstallations(settings, uiLogger)
+		if err != nil {
+			return err
+		}
+		c.flagNamespace = namespace
+	}
+	return nil
+}
+
+// lockedCRD is a config entry CRD that is being deleted and still has the
+// finalizer of the controller.
+type lockedCRD struct {
+	resource string
+	obj      unstructured.Unstructured
+}
+
+// lockedCRDs returns the selected CRDs that are being deleted and have the
+// finalizer, sorted by resource, namespace and name. It returns an error if a
+// CRD given on the command line doesn't exist or isn't being deleted, so that
+// the finalizer is never removed from a CRD that is still in use.
+func (c *ForceUnlockCommand) lockedCRDs() ([]lockedCRD, error) {
+	var locked []lockedCRD
+	if c.flagAll {
+		for _, resource := range configEntryResources {
+			list, err := c.dynamic.Resource(groupVersionResource(resource)).Namespace(c.flagCRDNamespace).List(c.Ctx, metav1.ListOptions{})
+			if err != nil {
+				// The CRDs of newer config entries may not be installed.
+				c.Log.Debug("listing custom resources", "resource", resource, "error", err)
+				continue
+			}
+			for _, obj := range list.Items {
+				if isLocked(obj) {
+					locked = append(locked, lockedCRD{resource: resource, obj: obj})
+				}
+			}
+		}
+	} else {
+		for _, t := range c.targets {
+			matches, err := c.findTarget(t)
+			if err != nil {
+				return nil, err
+			}
+			locked = append(locked, matches...)
+		}
+	}
+
+	sort.SliceStable(locked, func(i, j int) bool {
+		a, b := locked[i], locked[j]
+		if a.resource != b.resource {
+			return a.resource < b.resource
+		}
+		if a.obj.GetNamespace() != b.obj.GetNamespace() {
+			return a.obj.GetNamespace() < b.obj.GetNamespace()
+		}
+		return a.obj.GetName() < b.obj.GetName()
+	})
+	return locked, nil
+}
+
+// findTarget returns the CRDs named by the target in the CRD namespace, or in
+// any namespace if it is not set.
+func (c *ForceUnlockCommand) findTarget(t target) ([]lockedCRD, error) {
+	list, err := c.dynamic.Resource(groupVersionResource(t.Resource)).Namespace(c.flagCRDNamespace).List(c.Ctx, metav1.ListOptions{
+		FieldSelector: "metadata.name=" + t.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []lockedCRD
+	for _, obj := range list.Items {
+		if obj.GetName() != t.Name {
+			continue
+		}
+		if !isLocked(obj) {
+			return nil, fmt.Errorf("%s/%s in namespace %s is not being deleted or has no finalizer to remove", t.Resource, t.Name, obj.GetNamespace())
+		}
+		matches = append(matches, lockedCRD{resource: t.Resource, obj: obj})
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%s/%s not found", t.Resource, t.Name)
+	}
+	return matches, nil
+}
+
+// isLocked returns true if the CRD is being deleted and has the finalizer.
+func isLocked(obj unstructured.Unstructured) bool {
+	return obj.GetDeletionTimestamp() != nil && hasFinalizer(obj)
+}
+
+func hasFinalizer(obj unstructured.Unstructured) bool {
+	for _, f := range obj.GetFinalizers() {
+		if f == finalizerName {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyUnreachable checks the Consul servers every poll interval and returns
+// an error as soon as any of them has a leader. It returns nil once they were
+// unreachable for the whole -unreachable-for duration.
+func (c *ForceUnlockCommand) verifyUnreachable() error {
+	deadline := time.Now().Add(c.flagUnreachableFor)
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+	for {
+		if name, ok := c.reachableServer(); ok {
+			return fmt.Errorf("the Consul server %s is reachable and has a leader", name)
+		}
+		if !time.Now().Before(deadline) {
+			return nil
+		}
+		select {
+		case <-c.Ctx.Done():
+			return errors.New("interrupted while verifying that the Consul servers are unreachable")
+		case <-ticker.C:
+		}
+	}
+}
+
+// reachableServer returns the name of a running server pod whose HTTP API
+// responds and reports a leader. A cluster without a leader is considered
+// unreachable since it can't delete config entries either.
+func (c *ForceUnlockCommand) reachableServer() (string, bool) {
+	pods, err := c.kubernetes.CoreV1().Pods(c.flagNamespace).List(c.Ctx, metav1.ListOptions{LabelSelector: consul.ServerLabelSelector})
+	if err != nil {
+		c.Log.Debug("listing server pods", "error", err)
+		return "", false
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		if c.serverHasLeader(pod) {
+			return pod.Name, true
+		}
+	}
+	return "", false
+}
+
+func (c *ForceUnlockCommand) serverHasLeader(pod *corev1.Pod) bool {
+	open := consul.ServerConfig{
+		KubeClient: c.kubernetes,
+		RestConfig: c.restConfig,
+		Token:      c.flagToken,
+		CAFile:     c.flagCAFile,
+	}.Opener(c.openServer)
+	client, closeClient, err := open(pod)
+	if err != nil {
+		c.Log.Debug("connecting to server", "pod", pod.Name, "error", err)
+		return false
+	}
+	defer closeClient()
+
+	ctx, cancel := context.WithTimeout(c.Ctx, c.pollInterval)
+	defer cancel()
+	if _, err := client.Leader(ctx); err != nil {
+		c.Log.Debug("checking leader", "pod", pod.Name, "error", err)
+		return false
+	}
+	return true
+}
+
+// unlock removes the finalizer of the controller from the CRD, keeping any
+// other finalizers.
+func (c *ForceUnlockCommand) unlock(crd lockedCRD) error {
+	obj := crd.obj.DeepCopy()
+	var finalizers []string
+	for _, f := range obj.GetFinalizers() {
+		if f != finalizerName {
+			finalizers = append(finalizers, f)
+		}
+	}
+	obj.SetFinalizers(finalizers)
+	_, err := c.dynamic.Resource(groupVersionResource(crd.resource)).Namespace(obj.GetNamespace()).Update(c.Ctx, obj, metav1.UpdateOptions{})
+	return err
+}
+
+// Help returns a description of the command and how it is used.
+func (c *ForceUnlockCommand) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s crd force-unlock [flags] [<resource>/<name> ...]\n\n" +
+		"The controller keeps its finalizer on a config entry custom resource that is being deleted\n" +
+		"until it has deleted the config entry from Consul, and retries while Consul is unreachable.\n" +
+		"If the Consul servers are gone for good, this command removes the finalizer so that the\n" +
+		"custom resources can be deleted. It only acts on custom resources that are being deleted,\n" +
+		"and only after no Consul server had a leader for the -unreachable-for duration. The config\n" +
+		"entries are not deleted from Consul.\n\n" +
+		"Examples:\n" +
+		"  $ consul-k8s crd force-unlock servicedefaults/web serviceintentions/web\n" +
+		"  $ consul-k8s crd force-unlock -all -crd-namespace default\n\n" + c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *ForceUnlockCommand) Synopsis() string {
+	return "Remove the finalizer from config entry custom resources when Consul is gone."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *ForceUnlockCommand) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *ForceUnlockCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
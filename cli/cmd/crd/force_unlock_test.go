@@ -0,0 +1,203 @@
+package crd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+// TestValidateFlags tests the validate flags function.
+func TestValidateFlags(t *testing.T) {
+	// The following cases should all error, if they fail to this test fails.
+	testCases := []struct {
+		description string
+		input       []string
+	}{
+		{
+			"Should require -all or a custom resource.",
+			[]string{},
+		},
+		{
+			"Should disallow -all with a custom resource.",
+			[]string{"-all", "servicedefaults/web"},
+		},
+		{
+			"Should disallow an argument without a name.",
+			[]string{"servicedefaults"},
+		},
+		{
+			"Should disallow resources that are not config entries.",
+			[]string{"deployments/web"},
+		},
+		{
+			"Should disallow a zero duration.",
+			[]string{"-all", "-unreachable-for", "0s"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		c := getInitializedCommand(t)
+		t.Run(testCase.description, func(t *testing.T) {
+			if err := c.validateFlags(testCase.input); err == nil {
+				t.Errorf("Test case should have failed.")
+			}
+		})
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	for arg, exp := range map[string]target{
+		"servicedefaults/web": {Resource: "servicedefaults", Name: "web"},
+		"ServiceDefault/web":  {Resource: "servicedefaults", Name: "web"},
+		"mesh/mesh":           {Resource: "meshes", Name: "mesh"},
+	} {
+		actual, err := parseTarget(arg)
+		require.NoError(t, err, arg)
+		require.Equal(t, exp, actual, arg)
+	}
+}
+
+// TestRun tests that the finalizer is only removed from custom resources that
+// are being deleted and only when no server has a leader.
+func TestRun(t *testing.T) {
+	cases := map[string]struct {
+		args        []string
+		reachable   bool
+		expCode     int
+		expUnlocked []string
+		expLocked   []string
+	}{
+		"servers unreachable": {
+			args:        []string{"-all"},
+			expCode:     0,
+			expUnlocked: []string{"web"},
+			expLocked:   []string{"api"},
+		},
+		"servers reachable": {
+			args:      []string{"-all"},
+			reachable: true,
+			expCode:   1,
+			expLocked: []string{"api", "web"},
+		},
+		"selected resource": {
+			args:        []string{"servicedefaults/web"},
+			expCode:     0,
+			expUnlocked: []string{"web"},
+			expLocked:   []string{"api"},
+		},
+		"selected resource not being deleted": {
+			args:      []string{"servicedefaults/api"},
+			expCode:   1,
+			expLocked: []string{"api", "web"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`"10.0.0.2:8300"`))
+			}))
+			defer srv.Close()
+
+			cmd := getInitializedCommand(t)
+			cmd.kubernetes = fake.NewSimpleClientset(&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "consul-server-0", Namespace: "consul", Labels: map[string]string{"app": "consul", "chart": "consul-helm", "component": "server"}},
+				Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+			})
+			cmd.dynamic = fakeDynamicClient()
+			cmd.restConfig = &rest.Config{}
+			cmd.pollInterval = time.Millisecond
+			cmd.openServer = func(pod *corev1.Pod) (*consul.Client, func(), error) {
+				if !c.reachable {
+					return nil, nil, errors.New("connection refused")
+				}
+				return &consul.Client{Addr: strings.TrimPrefix(srv.URL, "http://")}, func() {}, nil
+			}
+
+			gvr := groupVersionResource("servicedefaults")
+			now := metav1.Now()
+			web := serviceDefaults("web")
+			web.SetDeletionTimestamp(&now)
+			for _, obj := range []*unstructured.Unstructured{web, serviceDefaults("api")} {
+				_, err := cmd.dynamic.Resource(gvr).Namespace("default").Create(context.Background(), obj, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+
+			args := append([]string{"-n", "consul", "-auto-approve", "-unreachable-for", "5ms"}, c.args...)
+			require.Equal(t, c.expCode, cmd.Run(args))
+
+			for _, name := range c.expUnlocked {
+				obj, err := cmd.dynamic.Resource(gvr).Namespace("default").Get(context.Background(), name, metav1.GetOptions{})
+				require.NoError(t, err)
+				require.Equal(t, []string{"other-finalizer"}, obj.GetFinalizers())
+			}
+			for _, name := range c.expLocked {
+				obj, err := cmd.dynamic.Resource(gvr).Namespace("default").Get(context.Background(), name, metav1.GetOptions{})
+				require.NoError(t, err)
+				require.Contains(t, obj.GetFinalizers(), finalizerName)
+			}
+		})
+	}
+}
+
+func serviceDefaults(name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "consul.hashicorp.com/v1alpha1",
+		"kind":       "ServiceDefaults",
+		"spec":       map[string]interface{}{"protocol": "http"},
+	}}
+	obj.SetName(name)
+	obj.SetNamespace("default")
+	obj.SetFinalizers([]string{finalizerName, "other-finalizer"})
+	return obj
+}
+
+// fakeDynamicClient returns a dynamic client that can list the config entry
+// CRDs. Objects must be created through it since it can't guess the resources
+// of the CRD kinds.
+func fakeDynamicClient() *dynamicfake.FakeDynamicClient {
+	listKinds := make(map[schema.GroupVersionResource]string)
+	for _, resource := range configEntryResources {
+		listKinds[groupVersionResource(resource)] = resource + "List"
+	}
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds)
+}
+
+// getInitializedCommand sets up a command struct for tests.
+func getInitializedCommand(t *testing.T) *ForceUnlockCommand {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+	}
+
+	c := &ForceUnlockCommand{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
@@ -0,0 +1,654 @@
+// Package expose contains the command that routes traffic from an ingress
+// gateway to a service in the mesh.
+package expose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/posener/complete"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	flagNameService = "service"
+
+	flagNameNamespace = "namespace"
+	defaultNamespace  = "default"
+
+	flagNameHostname = "hostname"
+
+	flagNameGateway = "gateway"
+	defaultGateway  = "ingress-gateway"
+
+	flagNamePort = "port"
+	defaultPort  = 8080
+
+	flagNameProtocol = "protocol"
+	defaultProtocol  = "http"
+
+	flagNameConsulNamespace = "consul-namespace"
+
+	flagNameAutoApprove = "auto-approve"
+	defaultAutoApprove  = false
+
+	flagNameTimeout = "timeout"
+	defaultTimeout  = 5 * time.Minute
+
+	defaultPollInterval = 2 * time.Second
+)
+
+var (
+	// ingressGatewayResource is the resource of the IngressGateway CRD.
+	ingressGatewayResource = schema.GroupVersionResource{Group: "consul.hashicorp.com", Version: "v1alpha1", Resource: "ingressgateways"}
+	// serviceDefaultsResource is the resource of the ServiceDefaults CRD.
+	serviceDefaultsResource = schema.GroupVersionResource{Group: "consul.hashicorp.com", Version: "v1alpha1", Resource: "servicedefaults"}
+)
+
+// Command routes requests for a hostname that reach an ingress gateway of the
+// installation to a service in the mesh.
+type Command struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+	dynamic    dynamic.Interface
+	restConfig *rest.Config
+
+	set *flag.Sets
+
+	flagService         string
+	flagNamespace       string
+	flagHostname        string
+	flagGateway         string
+	flagPort            int
+	flagProtocol        string
+	flagConsulNamespace string
+	flagAutoApprove     bool
+	flagTimeout         time.Duration
+
+	flagKubeConfig  string
+	flagKubeContext string
+
+	// releaseNamespace is the namespace of the Consul installation.
+	releaseNamespace string
+	// pollInterval is how often the resources are checked while waiting. It
+	// defaults to defaultPollInterval.
+	pollInterval time.Duration
+
+	once sync.Once
+	help string
+}
+
+// change is a resource that is created or updated.
+type change struct {
+	resource schema.GroupVersionResource
+	object   *unstructured.Unstructured
+	create   bool
+	summary  string
+}
+
+func (c *Command) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameService,
+		Aliases: []string{"s"},
+		Target:  &c.flagService,
+		Default: "",
+		Usage:   "The Kubernetes service to expose. Its Consul service has the same name.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       flagNameNamespace,
+		Aliases:    []string{"n"},
+		Target:     &c.flagNamespace,
+		Default:    defaultNamespace,
+		Usage:      "The namespace of the service.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameHostname,
+		Target:  &c.flagHostname,
+		Default: "",
+		Usage:   "The hostname the service is exposed at, e.g. shop.example.com. Requests are routed by their Host header.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameGateway,
+		Target:  &c.flagGateway,
+		Default: defaultGateway,
+		Usage:   "The name of the ingress gateway in the ingressGateways.gateways Helm value.",
+	})
+	f.IntVar(&flag.IntVar{
+		Name:    flagNamePort,
+		Target:  &c.flagPort,
+		Default: defaultPort,
+		Usage:   "The port of the ingress gateway the service is exposed on. It must be a port of the gateway Service.",
+	})
+	f.EnumSingleVar(&flag.EnumSingleVar{
+		Name:    flagNameProtocol,
+		Target:  &c.flagProtocol,
+		Default: defaultProtocol,
+		Values:  []string{"http", "http2", "grpc"},
+		Usage:   "The protocol of the service. It is set in the ServiceDefaults of the service if they don't exist yet.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameConsulNamespace,
+		Target:  &c.flagConsulNamespace,
+		Default: "",
+		Usage:   "The Consul namespace of the service, if Consul namespaces are enabled. Consul Enterprise only.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameAutoApprove,
+		Target:  &c.flagAutoApprove,
+		Default: defaultAutoApprove,
+		Usage:   "Skip confirmation prompt.",
+	})
+	f.DurationVar(&flag.DurationVar{
+		Name:    flagNameTimeout,
+		Target:  &c.flagTimeout,
+		Default: defaultTimeout,
+		Usage:   "Set how long to wait for the route to be synced to Consul and the gateway to have an external address.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run applies the ServiceDefaults and IngressGateway resources that route the
+// hostname to the service, waits for them to be synced to Consul and prints
+// the address the service can be reached at.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("expose")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+
+	if err := c.setup(); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	gatewayService, changes, err := c.plan()
+	if err != nil {
+		c.UI.Output("Unable to expose service %s/%s: %v", c.flagNamespace, c.flagService, err, terminal.WithErrorStyle())
+		return 1
+	}
+
+	if len(changes) == 0 {
+		c.UI.Output("Service %s/%s is already exposed at %s.", c.flagNamespace, c.flagService, c.url(), terminal.WithInfoStyle())
+	} else {
+		c.UI.Output("Resources to apply", terminal.WithHeaderStyle())
+		for _, ch := range changes {
+			c.UI.Output(ch.summary, terminal.WithInfoStyle())
+		}
+		if !c.flagAutoApprove {
+			confirmation, err := c.UI.Input(&terminal.Input{
+				Prompt: "Apply these resources? (y/N)",
+				Style:  terminal.InfoStyle,
+				Secret: false,
+			})
+			if err != nil {
+				c.UI.Output(err.Error(), terminal.WithErrorStyle())
+				return 1
+			}
+			if common.Abort(confirmation) {
+				c.UI.Output("Service not exposed.", terminal.WithInfoStyle())
+				return 1
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(c.Ctx, c.flagTimeout)
+		defer cancel()
+		for _, ch := range changes {
+			if err := c.apply(ctx, ch); err != nil {
+				c.UI.Output("Error applying %s %s/%s: %v", ch.object.GetKind(), ch.object.GetNamespace(), ch.object.GetName(),
+					err, terminal.WithErrorStyle())
+				return 1
+			}
+		}
+		c.UI.Output("Service %s/%s exposed at %s.", c.flagNamespace, c.flagService, c.url(), terminal.WithSuccessStyle())
+	}
+
+	ctx, cancel := context.WithTimeout(c.Ctx, c.flagTimeout)
+	defer cancel()
+	c.printAddress(ctx, gatewayService)
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *Command) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if c.flagService == "" {
+		return fmt.Errorf("-%s must be set", flagNameService)
+	}
+	if c.flagHostname == "" {
+		return fmt.Errorf("-%s must be set", flagNameHostname)
+	}
+	// Consul allows a wildcard as the first label of the hosts of ingress gateways.
+	if errs := validation.IsDNS1123Subdomain(strings.TrimPrefix(c.flagHostname, "*.")); len(errs) > 0 {
+		return fmt.Errorf("-%s %q is invalid: %s", flagNameHostname, c.flagHostname, strings.Join(errs, ", "))
+	}
+	if c.flagPort < 1 || c.flagPort > 65535 {
+		return fmt.Errorf("-%s must be between 1 and 65535", flagNamePort)
+	}
+	if c.flagTimeout <= 0 {
+		return fmt.Errorf("-%s must be greater than zero", flagNameTimeout)
+	}
+	return nil
+}
+
+// setup creates the Kubernetes clients and finds the namespace of the Consul
+// installation.
+func (c *Command) setup() error {
+	if c.pollInterval == 0 {
+		c.pollInterval = defaultPollInterval
+	}
+
+	settings, err := common.InitKubernetes(c.flagKubeConfig, c.flagKubeContext, &c.restConfig, &c.kubernetes)
+	if err != nil {
+		return err
+	}
+	if c.dynamic == nil {
+		var err error
+		c.dynamic, err = dynamic.NewForConfig(c.restConfig)
+		if err != nil {
+			return fmt.Errorf("error initializing Kubernetes client:\n%v", err)
+		}
+	}
+
+	if c.releaseNamespace == "" {
+		uiLogger := func(msg string, args ...interface{}) {
+			c.Log.Debug(fmt.Sprintf(msg, args...))
+		}
+		_, namespace, err := common.CheckForInstallations(settings, uiLogger)
+		if err != nil {
+			return err
+		}
+		c.releaseNamespace = namespace
+	}
+	return nil
+}
+
+// plan checks that the service and the ingress gateway exist and returns the
+// Service of the gateway and the resources that need to be created or updated
+// to route the hostname to the service.
+func (c *Command) plan() (*corev1.Service, []change, error) {
+	if _, err := c.kubernetes.CoreV1().Services(c.flagNamespace).Get(c.Ctx, c.flagService, metav1.GetOptions{}); err != nil {
+		return nil, nil, err
+	}
+
+	gatewayService, err := c.gatewayService()
+	if err != nil {
+		return nil, nil, err
+	}
+	hasPort := false
+	for _, port := range gatewayService.Spec.Ports {
+		hasPort = hasPort || int(port.Port) == c.flagPort
+	}
+	if !hasPort {
+		return nil, nil, fmt.Errorf("the ingress gateway Service %s/%s has no port %d, set -%s to one of its ports",
+			gatewayService.Namespace, gatewayService.Name, c.flagPort, flagNamePort)
+	}
+
+	var changes []change
+	serviceDefaults, err := c.serviceDefaultsChange()
+	if err != nil {
+		return nil, nil, err
+	}
+	if serviceDefaults != nil {
+		changes = append(changes, *serviceDefaults)
+	}
+	ingressGateway, err := c.ingressGatewayChange()
+	if err != nil {
+		return nil, nil, err
+	}
+	if ingressGateway != nil {
+		changes = append(changes, *ingressGateway)
+	}
+	return gatewayService, changes, nil
+}
+
+// gatewayService returns the Service of the ingress gateway, which the Helm
+// chart names <fullname>-<gateway>.
+func (c *Command) gatewayService() (*corev1.Service, error) {
+	services, err := c.kubernetes.CoreV1().Services(c.releaseNamespace).List(c.Ctx, metav1.ListOptions{LabelSelector: "component=ingress-gateway"})
+	if err != nil {
+		return nil, err
+	}
+	for i := range services.Items {
+		if strings.HasSuffix(services.Items[i].Name, "-"+c.flagGateway) {
+			return &services.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("ingress gateway %q not found in namespace %q, it must be in the ingressGateways.gateways "+
+		"Helm value and ingressGateways.enabled must be true", c.flagGateway, c.releaseNamespace)
+}
+
+// serviceDefaultsChange returns the ServiceDefaults to create for the service,
+// or nil if they exist with a protocol the ingress gateway can route by host.
+func (c *Command) serviceDefaultsChange() (*change, error) {
+	existing, err := c.dynamic.Resource(serviceDefaultsResource).Namespace(c.flagNamespace).Get(c.Ctx, c.flagService, metav1.GetOptions{})
+	if err == nil {
+		protocol, _, _ := unstructured.NestedString(existing.Object, "spec", "protocol")
+		if protocol != c.flagProtocol {
+			return nil, fmt.Errorf("the ServiceDefaults %s/%s have protocol %q, but the service is exposed with -%s=%s",
+				c.flagNamespace, c.flagService, protocol, flagNameProtocol, c.flagProtocol)
+		}
+		return nil, nil
+	}
+	if !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("error getting ServiceDefaults %s/%s: %v", c.flagNamespace, c.flagService, err)
+	}
+
+	serviceDefaults := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"protocol": c.flagProtocol},
+	}}
+	serviceDefaults.SetAPIVersion(serviceDefaultsResource.GroupVersion().String())
+	serviceDefaults.SetKind("ServiceDefaults")
+	serviceDefaults.SetNamespace(c.flagNamespace)
+	serviceDefaults.SetName(c.flagService)
+	return &change{
+		resource: serviceDefaultsResource,
+		object:   serviceDefaults,
+		create:   true,
+		summary:  fmt.Sprintf("create ServiceDefaults %s/%s with protocol %s", c.flagNamespace, c.flagService, c.flagProtocol),
+	}, nil
+}
+
+// ingressGatewayChange returns the IngressGateway with a route from the
+// hostname to the service, or nil if the gateway already has it.
+func (c *Command) ingressGatewayChange() (*change, error) {
+	create := false
+	gateway, err := c.dynamic.Resource(ingressGatewayResource).Namespace(c.releaseNamespace).Get(c.Ctx, c.flagGateway, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		create = true
+		gateway = &unstructured.Unstructured{Object: map[string]interface{}{}}
+		gateway.SetAPIVersion(ingressGatewayResource.GroupVersion().String())
+		gateway.SetKind("IngressGateway")
+		gateway.SetNamespace(c.releaseNamespace)
+		gateway.SetName(c.flagGateway)
+	} else if err != nil {
+		return nil, fmt.Errorf("error getting IngressGateway %s/%s: %v", c.releaseNamespace, c.flagGateway, err)
+	}
+
+	changed, err := addRoute(gateway, c.flagPort, c.flagProtocol, c.flagService, c.flagConsulNamespace, c.flagHostname)
+	if err != nil {
+		return nil, fmt.Errorf("IngressGateway %s/%s: %v", c.releaseNamespace, c.flagGateway, err)
+	}
+	if !changed {
+		return nil, nil
+	}
+	verb := "update"
+	if create {
+		verb = "create"
+	}
+	return &change{
+		resource: ingressGatewayResource,
+		object:   gateway,
+		create:   create,
+		summary: fmt.Sprintf("%s IngressGateway %s/%s to route %s on port %d to service %s",
+			verb, c.releaseNamespace, c.flagGateway, c.flagHostname, c.flagPort, c.flagService),
+	}, nil
+}
+
+// addRoute adds the hostname to the hosts of the service in the listener of
+// the ingress gateway on the port, adding the service and the listener if
+// needed. It returns whether the gateway changed.
+func addRoute(gateway *unstructured.Unstructured, port int, protocol, service, consulNamespace, hostname string) (bool, error) {
+	listeners, _, err := unstructured.NestedSlice(gateway.Object, "spec", "listeners")
+	if err != nil {
+		return false, err
+	}
+
+	listenerIndex := -1
+	for i, l := range listeners {
+		listener, ok := l.(map[string]interface{})
+		if !ok {
+			return false, fmt.Errorf("listener %d is invalid", i)
+		}
+		listenerPort, _, _ := unstructured.NestedFieldNoCopy(listener, "port")
+		if fmt.Sprint(listenerPort) != fmt.Sprint(port) {
+			continue
+		}
+		listenerProtocol, _, _ := unstructured.NestedString(listener, "protocol")
+		if listenerProtocol == "" {
+			listenerProtocol = "tcp"
+		}
+		if listenerProtocol != protocol {
+			return false, fmt.Errorf("the listener on port %d has protocol %q, but the service is exposed with protocol %q",
+				port, listenerProtocol, protocol)
+		}
+		listenerIndex = i
+	}
+	if listenerIndex == -1 {
+		listeners = append(listeners, map[string]interface{}{
+			"port":     int64(port),
+			"protocol": protocol,
+		})
+		listenerIndex = len(listeners) - 1
+	}
+
+	listener := listeners[listenerIndex].(map[string]interface{})
+	services, _, err := unstructured.NestedSlice(listener, "services")
+	if err != nil {
+		return false, err
+	}
+	serviceIndex := -1
+	for i, s := range services {
+		svc, ok := s.(map[string]interface{})
+		if !ok {
+			return false, fmt.Errorf("service %d of the listener on port %d is invalid", i, port)
+		}
+		name, _, _ := unstructured.NestedString(svc, "name")
+		namespace, _, _ := unstructured.NestedString(svc, "namespace")
+		if name == service && namespace == consulNamespace {
+			serviceIndex = i
+			continue
+		}
+		hosts, _, _ := unstructured.NestedStringSlice(svc, "hosts")
+		for _, host := range hosts {
+			if host == hostname {
+				return false, fmt.Errorf("the listener on port %d already routes %s to service %q", port, hostname, name)
+			}
+		}
+	}
+	if serviceIndex == -1 {
+		svc := map[string]interface{}{"name": service}
+		if consulNamespace != "" {
+			svc["namespace"] = consulNamespace
+		}
+		services = append(services, svc)
+		serviceIndex = len(services) - 1
+	}
+
+	svc := services[serviceIndex].(map[string]interface{})
+	hosts, _, _ := unstructured.NestedStringSlice(svc, "hosts")
+	for _, host := range hosts {
+		if host == hostname {
+			return false, nil
+		}
+	}
+	if err := unstructured.SetNestedStringSlice(svc, append(hosts, hostname), "hosts"); err != nil {
+		return false, err
+	}
+	if err := unstructured.SetNestedSlice(listener, services, "services"); err != nil {
+		return false, err
+	}
+	if err := unstructured.SetNestedSlice(gateway.Object, listeners, "spec", "listeners"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// apply creates or updates the resource and waits until the controller has
+// synced it to Consul.
+func (c *Command) apply(ctx context.Context, ch change) error {
+	client := c.dynamic.Resource(ch.resource).Namespace(ch.object.GetNamespace())
+	var applied *unstructured.Unstructured
+	var err error
+	if ch.create {
+		applied, err = client.Create(ctx, ch.object, metav1.CreateOptions{})
+	} else {
+		applied, err = client.Update(ctx, ch.object, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+
+	// The controller updates the status of the resource once it has synced it,
+	// so the resource is synced once its version changed and it is marked synced.
+	return c.poll(ctx, "the resource to be synced to Consul; is controller.enabled true?", func() (bool, error) {
+		current, err := client.Get(ctx, applied.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if current.GetResourceVersion() == applied.GetResourceVersion() {
+			return false, nil
+		}
+		conditions, _, _ := unstructured.NestedSlice(current.Object, "status", "conditions")
+		for _, cond := range conditions {
+			condition, ok := cond.(map[string]interface{})
+			if !ok || condition["type"] != "Synced" {
+				continue
+			}
+			switch condition["status"] {
+			case string(corev1.ConditionTrue):
+				return true, nil
+			case string(corev1.ConditionFalse):
+				return false, fmt.Errorf("not synced to Consul: %v: %v", condition["reason"], condition["message"])
+			}
+		}
+		return false, nil
+	})
+}
+
+// printAddress prints the address the service can be reached at once the
+// gateway Service has an external address, or how to reach it otherwise.
+func (c *Command) printAddress(ctx context.Context, gatewayService *corev1.Service) {
+	if gatewayService.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		c.UI.Output("The ingress gateway Service %s/%s has type %s and no external address. To try the route, run:",
+			gatewayService.Namespace, gatewayService.Name, gatewayService.Spec.Type, terminal.WithInfoStyle())
+		c.UI.Output("kubectl port-forward -n %s svc/%s %d", gatewayService.Namespace, gatewayService.Name, c.flagPort)
+		c.UI.Output("curl -H \"Host: %s\" http://localhost:%d/", c.flagHostname, c.flagPort)
+		return
+	}
+
+	var address string
+	err := c.poll(ctx, "the ingress gateway to have an external address", func() (bool, error) {
+		svc, err := c.kubernetes.CoreV1().Services(gatewayService.Namespace).Get(ctx, gatewayService.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			if ingress.Hostname != "" {
+				address = ingress.Hostname
+			} else if ingress.IP != "" {
+				address = ingress.IP
+			}
+		}
+		return address != "", nil
+	})
+	if err != nil {
+		c.UI.Output("The ingress gateway Service %s/%s has no external address yet: %v",
+			gatewayService.Namespace, gatewayService.Name, err, terminal.WithWarningStyle())
+		return
+	}
+	c.UI.Output("The external address of the ingress gateway is %s.", address, terminal.WithSuccessStyle())
+	c.UI.Output("Point %s to it in DNS. Until then, try the route with:", c.flagHostname, terminal.WithInfoStyle())
+	c.UI.Output("curl -H \"Host: %s\" http://%s:%d/", c.flagHostname, address, c.flagPort)
+}
+
+// url returns the URL the service is exposed at.
+func (c *Command) url() string {
+	return fmt.Sprintf("http://%s:%d", c.flagHostname, c.flagPort)
+}
+
+// poll calls condition until it returns true or an error, or the context is
+// done.
+func (c *Command) poll(ctx context.Context, waitingFor string, condition func() (bool, error)) error {
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+	for {
+		done, err := condition()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for %s", waitingFor)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Help returns a description of the command and how it is used.
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s expose -service <name> -hostname <host> [flags]\n\n" +
+		"Creates the ServiceDefaults of the service if they don't exist and adds a route from the hostname\n" +
+		"to the service to the IngressGateway of an ingress gateway installed by the Helm chart. Requires\n" +
+		"ingressGateways.enabled and controller.enabled to be true.\n\n" + c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *Command) Synopsis() string {
+	return "Expose a service in the mesh through an ingress gateway."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *Command) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *Command) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
@@ -0,0 +1,295 @@
+package expose
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func TestValidateFlags(t *testing.T) {
+	testCases := map[string][]string{
+		"no service":        {"-hostname", "shop.example.com"},
+		"no hostname":       {"-service", "web"},
+		"invalid hostname":  {"-service", "web", "-hostname", "Shop_Example"},
+		"invalid port":      {"-service", "web", "-hostname", "shop.example.com", "-port", "0"},
+		"invalid protocol":  {"-service", "web", "-hostname", "shop.example.com", "-protocol", "tcp"},
+		"invalid timeout":   {"-service", "web", "-hostname", "shop.example.com", "-timeout", "0s"},
+		"non-flag argument": {"-service", "web", "-hostname", "shop.example.com", "foo"},
+	}
+	for name, args := range testCases {
+		t.Run(name, func(t *testing.T) {
+			require.Error(t, getInitializedCommand(t).validateFlags(args))
+		})
+	}
+
+	c := getInitializedCommand(t)
+	require.NoError(t, c.validateFlags([]string{"-service", "web", "-hostname", "*.example.com"}))
+	require.Equal(t, defaultPort, c.flagPort)
+	require.Equal(t, defaultProtocol, c.flagProtocol)
+}
+
+func TestAddRoute(t *testing.T) {
+	cases := map[string]struct {
+		listeners   []interface{}
+		expected    []interface{}
+		expChanged  bool
+		expErr      string
+		consulNS    string
+		protocol    string
+		serviceName string
+	}{
+		"no listeners": {
+			expected: []interface{}{
+				map[string]interface{}{"port": int64(8080), "protocol": "http", "services": []interface{}{
+					map[string]interface{}{"name": "web", "hosts": []interface{}{"shop.example.com"}},
+				}},
+			},
+			expChanged: true,
+		},
+		"adds the service to the listener of the port": {
+			listeners: []interface{}{
+				map[string]interface{}{"port": int64(8080), "protocol": "http", "services": []interface{}{
+					map[string]interface{}{"name": "api", "hosts": []interface{}{"api.example.com"}},
+				}},
+				map[string]interface{}{"port": int64(8443), "protocol": "tcp"},
+			},
+			expected: []interface{}{
+				map[string]interface{}{"port": int64(8080), "protocol": "http", "services": []interface{}{
+					map[string]interface{}{"name": "api", "hosts": []interface{}{"api.example.com"}},
+					map[string]interface{}{"name": "web", "hosts": []interface{}{"shop.example.com"}},
+				}},
+				map[string]interface{}{"port": int64(8443), "protocol": "tcp"},
+			},
+			expChanged: true,
+		},
+		"adds the hostname to the service": {
+			listeners: []interface{}{
+				map[string]interface{}{"port": int64(8080), "protocol": "http", "services": []interface{}{
+					map[string]interface{}{"name": "web", "hosts": []interface{}{"www.example.com"}},
+				}},
+			},
+			expected: []interface{}{
+				map[string]interface{}{"port": int64(8080), "protocol": "http", "services": []interface{}{
+					map[string]interface{}{"name": "web", "hosts": []interface{}{"www.example.com", "shop.example.com"}},
+				}},
+			},
+			expChanged: true,
+		},
+		"sets the Consul namespace": {
+			consulNS: "apps",
+			expected: []interface{}{
+				map[string]interface{}{"port": int64(8080), "protocol": "http", "services": []interface{}{
+					map[string]interface{}{"name": "web", "namespace": "apps", "hosts": []interface{}{"shop.example.com"}},
+				}},
+			},
+			expChanged: true,
+		},
+		"already routed": {
+			listeners: []interface{}{
+				map[string]interface{}{"port": int64(8080), "protocol": "http", "services": []interface{}{
+					map[string]interface{}{"name": "web", "hosts": []interface{}{"shop.example.com"}},
+				}},
+			},
+			expected: []interface{}{
+				map[string]interface{}{"port": int64(8080), "protocol": "http", "services": []interface{}{
+					map[string]interface{}{"name": "web", "hosts": []interface{}{"shop.example.com"}},
+				}},
+			},
+		},
+		"hostname routed to another service": {
+			listeners: []interface{}{
+				map[string]interface{}{"port": int64(8080), "protocol": "http", "services": []interface{}{
+					map[string]interface{}{"name": "api", "hosts": []interface{}{"shop.example.com"}},
+				}},
+			},
+			expErr: `the listener on port 8080 already routes shop.example.com to service "api"`,
+		},
+		"listener with another protocol": {
+			listeners: []interface{}{
+				map[string]interface{}{"port": int64(8080)},
+			},
+			expErr: `the listener on port 8080 has protocol "tcp", but the service is exposed with protocol "http"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			gateway := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			if c.listeners != nil {
+				gateway.Object["spec"] = map[string]interface{}{"listeners": c.listeners}
+			}
+			changed, err := addRoute(gateway, 8080, "http", "web", c.consulNS, "shop.example.com")
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expChanged, changed)
+			listeners, _, err := unstructured.NestedSlice(gateway.Object, "spec", "listeners")
+			require.NoError(t, err)
+			require.Equal(t, c.expected, listeners)
+		})
+	}
+}
+
+// TestRun tests that the command creates the ServiceDefaults and IngressGateway,
+// waits for them to be synced and for the gateway to have an external address.
+func TestRun(t *testing.T) {
+	c := getInitializedCommand(t)
+	c.kubernetes = fake.NewSimpleClientset(
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "apps"}},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "consul-ingress-gateway",
+				Namespace: "consul",
+				Labels:    map[string]string{"component": "ingress-gateway"},
+			},
+			Spec: corev1.ServiceSpec{
+				Type:  corev1.ServiceTypeLoadBalancer,
+				Ports: []corev1.ServicePort{{Port: 8080}, {Port: 8443}},
+			},
+			Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: "1.2.3.4"}},
+			}},
+		},
+	)
+	c.dynamic = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			ingressGatewayResource:  "IngressGatewayList",
+			serviceDefaultsResource: "ServiceDefaultsList",
+		})
+	c.restConfig = &rest.Config{}
+	c.releaseNamespace = "consul"
+	c.pollInterval = 10 * time.Millisecond
+
+	// Mark the resources synced like the controller does.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for ctx.Err() == nil {
+			for _, r := range []struct {
+				resource  schema.GroupVersionResource
+				namespace string
+				name      string
+			}{
+				{serviceDefaultsResource, "apps", "web"},
+				{ingressGatewayResource, "consul", "ingress-gateway"},
+			} {
+				obj, err := c.dynamic.Resource(r.resource).Namespace(r.namespace).Get(ctx, r.name, metav1.GetOptions{})
+				if err != nil || obj.GetResourceVersion() == "synced" {
+					continue
+				}
+				obj.SetResourceVersion("synced")
+				_ = unstructured.SetNestedSlice(obj.Object, []interface{}{
+					map[string]interface{}{"type": "Synced", "status": "True"},
+				}, "status", "conditions")
+				_, _ = c.dynamic.Resource(r.resource).Namespace(r.namespace).Update(ctx, obj, metav1.UpdateOptions{})
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	args := []string{"-service", "web", "-namespace", "apps", "-hostname", "shop.example.com", "-auto-approve"}
+	require.Equal(t, 0, c.Run(args))
+
+	serviceDefaults, err := c.dynamic.Resource(serviceDefaultsResource).Namespace("apps").Get(ctx, "web", metav1.GetOptions{})
+	require.NoError(t, err)
+	protocol, _, _ := unstructured.NestedString(serviceDefaults.Object, "spec", "protocol")
+	require.Equal(t, "http", protocol)
+
+	gateway, err := c.dynamic.Resource(ingressGatewayResource).Namespace("consul").Get(ctx, "ingress-gateway", metav1.GetOptions{})
+	require.NoError(t, err)
+	listeners, _, _ := unstructured.NestedSlice(gateway.Object, "spec", "listeners")
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"port": int64(8080), "protocol": "http", "services": []interface{}{
+			map[string]interface{}{"name": "web", "hosts": []interface{}{"shop.example.com"}},
+		}},
+	}, listeners)
+
+	// Exposing the service again changes nothing.
+	c.flagAutoApprove = false
+	require.Equal(t, 0, c.Run(args[:len(args)-1]))
+}
+
+// TestRun_Errors tests that the command fails without changing anything if the
+// service can't be exposed.
+func TestRun_Errors(t *testing.T) {
+	cases := map[string]struct {
+		args     []string
+		services []runtime.Object
+	}{
+		"service not found": {
+			args: []string{"-service", "web", "-hostname", "shop.example.com", "-auto-approve"},
+		},
+		"gateway not found": {
+			args:     []string{"-service", "web", "-hostname", "shop.example.com", "-auto-approve"},
+			services: []runtime.Object{&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}},
+		},
+		"gateway port not found": {
+			args: []string{"-service", "web", "-hostname", "shop.example.com", "-port", "9090", "-auto-approve"},
+			services: []runtime.Object{
+				&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}},
+				&corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "consul-ingress-gateway",
+						Namespace: "consul",
+						Labels:    map[string]string{"component": "ingress-gateway"},
+					},
+					Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 8080}}},
+				},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := getInitializedCommand(t)
+			c.kubernetes = fake.NewSimpleClientset(tc.services...)
+			c.dynamic = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{
+					ingressGatewayResource:  "IngressGatewayList",
+					serviceDefaultsResource: "ServiceDefaultsList",
+				})
+			c.restConfig = &rest.Config{}
+			c.releaseNamespace = "consul"
+
+			require.Equal(t, 1, c.Run(tc.args))
+			list, err := c.dynamic.Resource(ingressGatewayResource).Namespace("consul").List(context.Background(), metav1.ListOptions{})
+			require.NoError(t, err)
+			require.Empty(t, list.Items)
+		})
+	}
+}
+
+func getInitializedCommand(t *testing.T) *Command {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+		UI:  terminal.NewBasicUI(context.Background()),
+	}
+
+	c := &Command{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
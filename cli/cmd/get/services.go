@@ -0,0 +1,619 @@
+package get
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/posener/complete"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	flagNameNamespace       = "namespace"
+	flagNameConsulNamespace = "consul-namespace"
+	flagNamePartition       = "partition"
+	flagNamePeers           = "peers"
+	flagNameOutput          = "output"
+	flagNameToken           = "token"
+	flagNameCAFile          = "ca-file"
+	flagNameAPIProxy        = "api-proxy"
+
+	outputTable = "table"
+	outputJSON  = "json"
+
+	// defaultProtocol is the protocol of services that no config entry sets
+	// a protocol for.
+	defaultProtocol = "tcp"
+
+	// The meta keys the connect injector and catalog sync record the
+	// Kubernetes origin of the service instances they register in.
+	metaKeyKubeNS          = "k8s-namespace"
+	metaKeyKubeServiceName = "k8s-service-name"
+	metaKeySyncSource      = "external-source"
+	metaKeySyncKubeNS      = "external-k8s-ns"
+
+	managedByConnectInject = "connect-inject"
+	managedByCatalogSync   = "catalog-sync"
+)
+
+// service is the inventory entry of a Consul service.
+type service struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Partition string `json:"partition,omitempty"`
+	// Datacenter is the datacenter the service is registered in, and Peer
+	// the peer it is imported from, if any.
+	Datacenter string `json:"datacenter,omitempty"`
+	Peer       string `json:"peer,omitempty"`
+	Instances  int    `json:"instances"`
+	// InMesh is the number of instances with a sidecar proxy, that are
+	// Connect native or that are gateways. It is not known for services
+	// imported from peers.
+	InMesh   *int   `json:"inMesh,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+	// Kubernetes lists the Kubernetes services the instances were
+	// registered for.
+	Kubernetes []kubeOwner `json:"kubernetes,omitempty"`
+}
+
+// kubeOwner is a Kubernetes service that instances of a Consul service were
+// registered for. Name is not known for services registered by catalog sync.
+type kubeOwner struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name,omitempty"`
+	ManagedBy string `json:"managedBy"`
+	// Missing is true if the Kubernetes service no longer exists, which
+	// usually means that the registrations are stale.
+	Missing bool `json:"missing,omitempty"`
+}
+
+// ServicesCommand lists the services of the mesh with their instances,
+// sidecar coverage, protocol and Kubernetes origin.
+type ServicesCommand struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+	restConfig *rest.Config
+
+	// openServer opens the connection to the server pod. It port forwards to
+	// the pod if it is not set, which lets tests replace it.
+	openServer consul.ServerOpener
+
+	set *flag.Sets
+
+	flagNamespace       string
+	flagConsulNamespace string
+	flagPartition       string
+	flagPeers           bool
+	flagOutput          string
+	flagToken           string
+	flagCAFile          string
+	flagAPIProxy        bool
+
+	flagKubeConfig  string
+	flagKubeContext string
+
+	table common.TableFlags
+
+	once sync.Once
+	help string
+}
+
+func (c *ServicesCommand) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameNamespace,
+		Aliases: []string{"n"},
+		Target:  &c.flagNamespace,
+		Usage: "Set the namespace of the Consul installation. " +
+			"If not set, the namespace of the installed Helm release is used.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameConsulNamespace,
+		Target:  &c.flagConsulNamespace,
+		Default: "",
+		Usage:   "The Consul namespace to list services in, or \"*\" for all namespaces. Requires Consul Enterprise.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNamePartition,
+		Target:  &c.flagPartition,
+		Default: "",
+		Usage:   "The admin partition to list services in. Requires Consul Enterprise.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNamePeers,
+		Target:  &c.flagPeers,
+		Default: true,
+		Usage:   "Also list the services imported from cluster peers.",
+	})
+	f.EnumSingleVar(&flag.EnumSingleVar{
+		Name:    flagNameOutput,
+		Aliases: []string{"o"},
+		Target:  &c.flagOutput,
+		Default: outputTable,
+		Values:  []string{outputTable, outputJSON},
+		Usage:   "Set the format the services are printed in.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameToken,
+		Target:  &c.flagToken,
+		Default: "",
+		Usage: fmt.Sprintf("Set the ACL token used to read the catalog. It needs service and node read permissions. "+
+			"If not set, the %s environment variable is used.", common.TokenEnvVar),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameCAFile,
+		Target:  &c.flagCAFile,
+		Default: "",
+		Usage: fmt.Sprintf("Set the path to the CA certificate of the Consul servers. If set, the servers are called "+
+			"over HTTPS on port %d instead of HTTP on port %d.", consul.HTTPSPort, consul.HTTPPort),
+		Completion: complete.PredictFiles("*"),
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameAPIProxy,
+		Target:  &c.flagAPIProxy,
+		Default: false,
+		Usage: fmt.Sprintf("Read from Consul through the API proxy the Helm chart deploys with apiProxy.enabled, with the "+
+			"Kubernetes credentials instead of a port forward to a server pod. -%s and -%s are not used.", flagNameToken, flagNameCAFile),
+	})
+	c.table.Flags(f)
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run reads the services from the catalog of a Consul server and prints
+// the inventory.
+func (c *ServicesCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("get services")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+
+	if err := c.setup(); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	client, closeClient, err := c.openAnyServer()
+	if err != nil {
+		c.UI.Output("Error connecting to Consul: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+	defer closeClient()
+
+	services, err := c.inventory(client)
+	if err != nil {
+		c.UI.Output("Error reading the services: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+	if err := c.markMissingKubeOwners(services); err != nil {
+		c.UI.Output("Error reading the Kubernetes services: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+
+	switch c.flagOutput {
+	case outputJSON:
+		out, err := json.MarshalIndent(services, "", "  ")
+		if err != nil {
+			c.UI.Output("Error formatting services: %v", err, terminal.WithErrorStyle())
+			return 1
+		}
+		c.UI.Output("%s", out)
+	default:
+		if err := c.printTable(services); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+	}
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *ServicesCommand) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	return c.table.Validate()
+}
+
+// setup creates the Kubernetes client and finds the namespace of the Consul
+// installation if -namespace is not set.
+func (c *ServicesCommand) setup() error {
+	if c.flagToken == "" {
+		c.flagToken = os.Getenv(common.TokenEnvVar)
+	}
+
+	settings, err := common.InitKubernetes(c.flagKubeConfig, c.flagKubeContext, &c.restConfig, &c.kubernetes)
+	if err != nil {
+		return err
+	}
+
+	if c.flagNamespace == "" {
+		uiLogger := func(msg string, args ...interface{}) {
+			c.Log.Debug(fmt.Sprintf(msg, args...))
+		}
+		_, namespace, err := common.CheckForInstallations(settings, uiLogger)
+		if err != nil {
+			return err
+		}
+		c.flagNamespace = namespace
+	}
+	return nil
+}
+
+// openAnyServer returns a client for the HTTP API of a running Consul server
+// and a function that closes the connection.
+func (c *ServicesCommand) openAnyServer() (*consul.Client, func(), error) {
+	if c.flagAPIProxy {
+		client, err := consul.OpenAPIProxy(c.restConfig)
+		return client, func() {}, err
+	}
+	open := consul.ServerConfig{
+		KubeClient: c.kubernetes,
+		RestConfig: c.restConfig,
+		Token:      c.flagToken,
+		CAFile:     c.flagCAFile,
+	}.Opener(c.openServer)
+	return consul.OpenRunningServer(c.Ctx, c.kubernetes, c.flagNamespace, open)
+}
+
+// inventory returns the services of the local catalog, and of the catalogs
+// of the peers if -peers is set, sorted by peer, partition, namespace and
+// name.
+func (c *ServicesCommand) inventory(client *consul.Client) ([]*service, error) {
+	query := consul.CatalogQuery{Namespace: c.flagConsulNamespace, Partition: c.flagPartition}
+	instances, err := catalogInstances(c.Ctx, client, query)
+	if err != nil {
+		return nil, err
+	}
+	protocols, err := newProtocolResolver(c.Ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	services := summarize(instances, true)
+	for _, svc := range services {
+		svc.Protocol = protocols.protocol(svc.Name, svc.Namespace, svc.Partition)
+	}
+
+	if c.flagPeers {
+		// Peerings are only supported by Consul 1.13+, so older servers
+		// are only missing the peered services.
+		peerings, err := client.Peerings(c.Ctx)
+		if err != nil {
+			c.UI.Output("Unable to list peerings, so services imported from peers are not listed: %v", err, terminal.WithWarningStyle())
+		}
+		for _, peering := range peerings {
+			query.Peer = peering.Name
+			instances, err := catalogInstances(c.Ctx, client, query)
+			if err != nil {
+				return nil, fmt.Errorf("error reading the services of peer %s: %s", peering.Name, err)
+			}
+			services = append(services, summarize(instances, false)...)
+		}
+	}
+
+	sort.SliceStable(services, func(i, j int) bool {
+		a, b := services[i], services[j]
+		if a.Peer != b.Peer {
+			return a.Peer < b.Peer
+		}
+		if a.Partition != b.Partition {
+			return a.Partition < b.Partition
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return services, nil
+}
+
+// catalogInstances returns the instances of all services of the catalog
+// the query selects.
+func catalogInstances(ctx context.Context, client *consul.Client, query consul.CatalogQuery) ([]consul.CatalogService, error) {
+	names, err := client.CatalogServices(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	var instances []consul.CatalogService
+	for _, name := range names {
+		svcInstances, err := client.CatalogService(ctx, name, query)
+		if err != nil {
+			return nil, fmt.Errorf("error reading service %s: %s", name, err)
+		}
+		instances = append(instances, svcInstances...)
+	}
+	return instances, nil
+}
+
+// summarize groups the instances by service. Sidecar proxies are not listed
+// as services of their own but counted towards the coverage of the services
+// they are the sidecars of, if local is set. Services imported from peers
+// only list the instances in the mesh, so their coverage is not known.
+func summarize(instances []consul.CatalogService, local bool) []*service {
+	// sidecars has the instances that have a sidecar proxy, by their node
+	// and ID. Sidecars are registered on the same node as their instance.
+	sidecars := make(map[string]bool)
+	for _, inst := range instances {
+		if inst.ServiceKind == consul.ServiceKindConnectProxy && inst.ServiceProxy != nil {
+			sidecars[instanceKey(inst.Partition, inst.Namespace, inst.Node, inst.ServiceProxy.DestinationServiceID)] = true
+		}
+	}
+
+	byService := make(map[string]*service)
+	var services []*service
+	for _, inst := range instances {
+		if local && inst.ServiceKind == consul.ServiceKindConnectProxy {
+			continue
+		}
+		key := strings.Join([]string{inst.PeerName, inst.Partition, inst.Namespace, inst.ServiceName}, "/")
+		svc, ok := byService[key]
+		if !ok {
+			svc = &service{
+				Name:       inst.ServiceName,
+				Kind:       inst.ServiceKind,
+				Namespace:  inst.Namespace,
+				Partition:  inst.Partition,
+				Datacenter: inst.Datacenter,
+				Peer:       inst.PeerName,
+			}
+			if local {
+				svc.InMesh = new(int)
+			}
+			byService[key] = svc
+			services = append(services, svc)
+		}
+		svc.Instances++
+		if local && (inst.ServiceKind != consul.ServiceKindTypical ||
+			(inst.ServiceConnect != nil && inst.ServiceConnect.Native) ||
+			sidecars[instanceKey(inst.Partition, inst.Namespace, inst.Node, inst.ServiceID)]) {
+			*svc.InMesh++
+		}
+		svc.addKubeOwner(inst.ServiceMeta)
+	}
+	return services
+}
+
+// instanceKey identifies a service instance in the catalog.
+func instanceKey(partition, namespace, node, id string) string {
+	return strings.Join([]string{partition, namespace, node, id}, "/")
+}
+
+// addKubeOwner adds the Kubernetes service recorded in the meta of an
+// instance to the owners of the service, unless it is already listed.
+func (s *service) addKubeOwner(meta map[string]string) {
+	var owner kubeOwner
+	switch {
+	case meta[metaKeyKubeNS] != "" && meta[metaKeyKubeServiceName] != "":
+		owner = kubeOwner{Namespace: meta[metaKeyKubeNS], Name: meta[metaKeyKubeServiceName], ManagedBy: managedByConnectInject}
+	case meta[metaKeySyncSource] == "kubernetes" && meta[metaKeySyncKubeNS] != "":
+		owner = kubeOwner{Namespace: meta[metaKeySyncKubeNS], ManagedBy: managedByCatalogSync}
+	default:
+		return
+	}
+	for _, o := range s.Kubernetes {
+		if o == owner {
+			return
+		}
+	}
+	s.Kubernetes = append(s.Kubernetes, owner)
+}
+
+// markMissingKubeOwners marks the Kubernetes services that the services were
+// registered for but that no longer exist.
+func (c *ServicesCommand) markMissingKubeOwners(services []*service) error {
+	missing := make(map[string]bool)
+	for _, svc := range services {
+		for i := range svc.Kubernetes {
+			owner := &svc.Kubernetes[i]
+			if owner.Name == "" {
+				continue
+			}
+			key := owner.Namespace + "/" + owner.Name
+			gone, ok := missing[key]
+			if !ok {
+				_, err := c.kubernetes.CoreV1().Services(owner.Namespace).Get(c.Ctx, owner.Name, metav1.GetOptions{})
+				if err != nil && !k8serrors.IsNotFound(err) {
+					return err
+				}
+				gone = k8serrors.IsNotFound(err)
+				missing[key] = gone
+			}
+			owner.Missing = gone
+		}
+	}
+	return nil
+}
+
+// protocolResolver resolves the protocol of services from the
+// service-defaults config entries, falling back to the global proxy-defaults
+// and then to tcp like Consul does.
+type protocolResolver struct {
+	// services has the protocols of the service-defaults by partition,
+	// namespace and name.
+	services map[string]string
+	global   string
+}
+
+func newProtocolResolver(ctx context.Context, client *consul.Client) (*protocolResolver, error) {
+	r := &protocolResolver{services: make(map[string]string), global: defaultProtocol}
+	entries, err := client.ConfigEntries(ctx, "service-defaults")
+	if err != nil {
+		return nil, fmt.Errorf("error reading service-defaults: %s", err)
+	}
+	for _, entry := range entries {
+		if protocol, _ := entry["Protocol"].(string); protocol != "" {
+			partition, _ := entry["Partition"].(string)
+			namespace, _ := entry["Namespace"].(string)
+			r.services[protocolKey(entry.Name(), namespace, partition)] = protocol
+		}
+	}
+	defaults, err := client.ConfigEntries(ctx, "proxy-defaults")
+	if err != nil {
+		return nil, fmt.Errorf("error reading proxy-defaults: %s", err)
+	}
+	for _, entry := range defaults {
+		config, _ := entry["Config"].(map[string]interface{})
+		if protocol, _ := config["protocol"].(string); protocol != "" && entry.Name() == "global" {
+			r.global = protocol
+		}
+	}
+	return r, nil
+}
+
+// protocol returns the protocol of the service.
+func (r *protocolResolver) protocol(name, namespace, partition string) string {
+	if protocol, ok := r.services[protocolKey(name, namespace, partition)]; ok {
+		return protocol
+	}
+	return r.global
+}
+
+// protocolKey identifies the service-defaults of a service. Consul OSS
+// returns no namespace and partition, and Consul Enterprise returns
+// "default" for them, so both are treated the same.
+func protocolKey(name, namespace, partition string) string {
+	if namespace == "default" {
+		namespace = ""
+	}
+	if partition == "default" {
+		partition = ""
+	}
+	return strings.Join([]string{partition, namespace, name}, "/")
+}
+
+// printTable prints the services as a table. The namespace and partition
+// columns are only printed if any service has them.
+func (c *ServicesCommand) printTable(services []*service) error {
+	if len(services) == 0 {
+		c.UI.Output("No services found.")
+		return nil
+	}
+	var enterprise bool
+	for _, svc := range services {
+		if svc.Namespace != "" || svc.Partition != "" {
+			enterprise = true
+		}
+	}
+
+	headers := []string{"Name"}
+	if enterprise {
+		headers = append(headers, "Partition", "Namespace")
+	}
+	headers = append(headers, "Kind", "Source", "Instances", "In Mesh", "Protocol", "Kubernetes")
+	tbl := terminal.NewTable(headers...)
+	for _, svc := range services {
+		row := []string{svc.Name}
+		if enterprise {
+			row = append(row, svc.Partition, svc.Namespace)
+		}
+		source := svc.Datacenter
+		if svc.Peer != "" {
+			source = "peer " + svc.Peer
+		}
+		inMesh := "-"
+		if svc.InMesh != nil {
+			inMesh = fmt.Sprintf("%d/%d", *svc.InMesh, svc.Instances)
+		}
+		var owners []string
+		for _, o := range svc.Kubernetes {
+			owner := o.Namespace
+			if o.Name != "" {
+				owner += "/" + o.Name
+			}
+			if o.Missing {
+				owner += " (missing)"
+			}
+			owners = append(owners, owner)
+		}
+		row = append(row, svc.Kind, source, strconv.Itoa(svc.Instances), inMesh, svc.Protocol, strings.Join(owners, ", "))
+
+		color := ""
+		if svc.InMesh != nil && *svc.InMesh < svc.Instances {
+			color = terminal.Yellow
+		}
+		colors := make([]string, len(row))
+		for i := range colors {
+			colors[i] = color
+		}
+		tbl.Rich(row, colors)
+	}
+	return c.table.Print(c.UI, tbl)
+}
+
+// Help returns a description of the command and how it is used.
+func (c *ServicesCommand) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s get services [flags]\n\n" +
+		"Lists the services in the Consul catalog with their number of instances, how many of the\n" +
+		"instances are in the mesh, where they are registered, their protocol from the service-defaults\n" +
+		"and proxy-defaults config entries, and the Kubernetes services they were registered for.\n" +
+		"Services imported from cluster peers are listed as well.\n\n" +
+		"Examples:\n" +
+		"  $ consul-k8s get services\n" +
+		"  $ consul-k8s get services -consul-namespace '*' -o json\n" +
+		"  $ consul-k8s get services -api-proxy\n\n" +
+		c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *ServicesCommand) Synopsis() string {
+	return "List the services of the mesh."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *ServicesCommand) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *ServicesCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
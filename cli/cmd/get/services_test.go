@@ -0,0 +1,162 @@
+package get
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+// TestServicesValidateFlags tests the validate flags function.
+func TestServicesValidateFlags(t *testing.T) {
+	c := getInitializedServicesCommand(t)
+	require.Error(t, c.validateFlags([]string{"extra"}))
+	require.Error(t, c.validateFlags([]string{"-o", "yaml"}))
+	require.NoError(t, c.validateFlags([]string{"-o", "json"}))
+}
+
+func TestServicesRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		peer := r.URL.Query().Get("peer")
+		switch {
+		case r.URL.Path == "/v1/catalog/services" && peer == "":
+			w.Write([]byte(`{"consul": [], "web": [], "web-sidecar-proxy": [], "api": [], "api-sidecar-proxy": [], "mesh-gateway": []}`))
+		case r.URL.Path == "/v1/catalog/services" && peer == "dc2":
+			w.Write([]byte(`{"billing": []}`))
+		case r.URL.Path == "/v1/catalog/service/consul":
+			w.Write([]byte(`[{"Datacenter": "dc1", "Node": "server-0", "ServiceID": "consul", "ServiceName": "consul"}]`))
+		case r.URL.Path == "/v1/catalog/service/web":
+			w.Write([]byte(`[
+  {"Datacenter": "dc1", "Node": "node-1", "ServiceID": "web-1", "ServiceName": "web", "ServiceMeta": {"k8s-namespace": "default", "k8s-service-name": "web"}},
+  {"Datacenter": "dc1", "Node": "node-2", "ServiceID": "web-2", "ServiceName": "web", "ServiceMeta": {"k8s-namespace": "default", "k8s-service-name": "web"}}]`))
+		case r.URL.Path == "/v1/catalog/service/web-sidecar-proxy":
+			w.Write([]byte(`[{"Datacenter": "dc1", "Node": "node-1", "ServiceID": "web-1-sidecar-proxy", "ServiceName": "web-sidecar-proxy",
+  "ServiceKind": "connect-proxy", "ServiceProxy": {"DestinationServiceName": "web", "DestinationServiceID": "web-1"}}]`))
+		case r.URL.Path == "/v1/catalog/service/api":
+			w.Write([]byte(`[{"Datacenter": "dc1", "Node": "node-1", "ServiceID": "api-1", "ServiceName": "api", "ServiceMeta": {"k8s-namespace": "backend", "k8s-service-name": "api"}}]`))
+		case r.URL.Path == "/v1/catalog/service/api-sidecar-proxy":
+			w.Write([]byte(`[{"Datacenter": "dc1", "Node": "node-1", "ServiceID": "api-1-sidecar-proxy", "ServiceName": "api-sidecar-proxy",
+  "ServiceKind": "connect-proxy", "ServiceProxy": {"DestinationServiceName": "api", "DestinationServiceID": "api-1"}}]`))
+		case r.URL.Path == "/v1/catalog/service/mesh-gateway":
+			w.Write([]byte(`[{"Datacenter": "dc1", "Node": "node-2", "ServiceID": "mesh-gateway", "ServiceName": "mesh-gateway", "ServiceKind": "mesh-gateway",
+  "ServiceMeta": {"external-source": "kubernetes", "external-k8s-ns": "consul"}}]`))
+		case r.URL.Path == "/v1/catalog/service/billing":
+			require.Equal(t, "dc2", peer)
+			w.Write([]byte(`[{"Node": "node-9", "ServiceID": "billing-1", "ServiceName": "billing", "PeerName": "dc2"}]`))
+		case r.URL.Path == "/v1/config/service-defaults":
+			w.Write([]byte(`[{"Kind": "service-defaults", "Name": "api", "Protocol": "grpc"}]`))
+		case r.URL.Path == "/v1/config/proxy-defaults":
+			w.Write([]byte(`[{"Kind": "proxy-defaults", "Name": "global", "Config": {"protocol": "http"}}]`))
+		case r.URL.Path == "/v1/peerings":
+			w.Write([]byte(`[{"Name": "dc2", "State": "ACTIVE"}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := getInitializedServicesCommand(t)
+	c.kubernetes = fake.NewSimpleClientset(
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "consul-server-0",
+				Namespace: "consul",
+				Labels:    map[string]string{"app": "consul", "chart": "consul-helm", "component": "server"},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+		// The api service was deleted, so the registrations of api are stale.
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}},
+	)
+	c.restConfig = &rest.Config{}
+	c.openServer = func(pod *corev1.Pod) (*consul.Client, func(), error) {
+		return &consul.Client{Addr: strings.TrimPrefix(srv.URL, "http://")}, func() {}, nil
+	}
+	require.Equal(t, 0, c.Run([]string{"-n", "consul", "-o", "json"}))
+
+	client := &consul.Client{Addr: strings.TrimPrefix(srv.URL, "http://")}
+	services, err := c.inventory(client)
+	require.NoError(t, err)
+	require.NoError(t, c.markMissingKubeOwners(services))
+
+	intPtr := func(i int) *int { return &i }
+	require.Equal(t, []*service{
+		{
+			Name: "api", Datacenter: "dc1", Instances: 1, InMesh: intPtr(1), Protocol: "grpc",
+			Kubernetes: []kubeOwner{{Namespace: "backend", Name: "api", ManagedBy: managedByConnectInject, Missing: true}},
+		},
+		{Name: "consul", Datacenter: "dc1", Instances: 1, InMesh: intPtr(0), Protocol: "http"},
+		{
+			Name: "mesh-gateway", Kind: "mesh-gateway", Datacenter: "dc1", Instances: 1, InMesh: intPtr(1), Protocol: "http",
+			Kubernetes: []kubeOwner{{Namespace: "consul", ManagedBy: managedByCatalogSync}},
+		},
+		{
+			Name: "web", Datacenter: "dc1", Instances: 2, InMesh: intPtr(1), Protocol: "http",
+			Kubernetes: []kubeOwner{{Namespace: "default", Name: "web", ManagedBy: managedByConnectInject}},
+		},
+		{Name: "billing", Peer: "dc2", Instances: 1},
+	}, services)
+}
+
+// TestServicesRun_APIProxy tests that the services are read through the API
+// proxy of the Kubernetes API server instead of a port forward with -api-proxy.
+func TestServicesRun_APIProxy(t *testing.T) {
+	var paths []string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch strings.TrimPrefix(r.URL.Path, "/apis/api.consul.hashicorp.com/v1alpha1/consul") {
+		case "/v1/catalog/services":
+			w.Write([]byte(`{"web": []}`))
+		case "/v1/catalog/service/web":
+			w.Write([]byte(`[{"Datacenter": "dc1", "Node": "node-1", "ServiceID": "web-1", "ServiceName": "web"}]`))
+		case "/v1/config/service-defaults", "/v1/config/proxy-defaults":
+			w.Write([]byte(`[]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := getInitializedServicesCommand(t)
+	// There are no server pods to port forward to.
+	c.kubernetes = fake.NewSimpleClientset()
+	c.restConfig = &rest.Config{Host: srv.URL, TLSClientConfig: rest.TLSClientConfig{Insecure: true}}
+	require.Equal(t, 0, c.Run([]string{"-n", "consul", "-api-proxy", "-peers=false"}))
+	require.Contains(t, paths, "/apis/api.consul.hashicorp.com/v1alpha1/consul/v1/catalog/service/web")
+}
+
+func TestProtocolKey(t *testing.T) {
+	require.Equal(t, protocolKey("web", "", ""), protocolKey("web", "default", "default"))
+	require.NotEqual(t, protocolKey("web", "", ""), protocolKey("web", "team", ""))
+}
+
+func getInitializedServicesCommand(t *testing.T) *ServicesCommand {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+	}
+
+	c := &ServicesCommand{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
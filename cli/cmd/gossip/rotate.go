@@ -0,0 +1,433 @@
+// Package gossip contains the commands that manage gossip encryption.
+package gossip
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/posener/complete"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	flagNameNamespace = "namespace"
+	flagNameToken     = "token"
+	flagNameCAFile    = "ca-file"
+
+	flagNameAutoApprove = "auto-approve"
+	defaultAutoApprove  = false
+
+	flagNameTimeout = "timeout"
+	defaultTimeout  = 5 * time.Minute
+
+	// gossipKeyEnvVar is the environment variable of the server containers
+	// that the chart sets from the secret with the gossip key.
+	gossipKeyEnvVar = "GOSSIP_KEY"
+
+	defaultPollInterval = 2 * time.Second
+)
+
+// RotateCommand rotates the gossip encryption key.
+type RotateCommand struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+	restConfig *rest.Config
+
+	set *flag.Sets
+
+	flagNamespace   string
+	flagToken       string
+	flagCAFile      string
+	flagAutoApprove bool
+	flagTimeout     time.Duration
+
+	flagKubeConfig  string
+	flagKubeContext string
+
+	// openServer returns a client for the HTTP API of the server pod and a
+	// function that closes the connection. It port forwards to the pod if it
+	// is not set, which lets tests replace it.
+	openServer consul.ServerOpener
+	// pollInterval is how often the keyring is checked while waiting for a
+	// change to reach all agents. It defaults to defaultPollInterval.
+	pollInterval time.Duration
+
+	once sync.Once
+	help string
+}
+
+func (c *RotateCommand) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameNamespace,
+		Aliases: []string{"n"},
+		Target:  &c.flagNamespace,
+		Usage: "Set the namespace of the Consul installation. " +
+			"If not set, the namespace of the installed Helm release is used.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameToken,
+		Target:  &c.flagToken,
+		Default: "",
+		Usage: fmt.Sprintf("Set the ACL token used to call the Consul API. It needs keyring write permissions. "+
+			"If not set, the %s environment variable is used.", common.TokenEnvVar),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameCAFile,
+		Target:  &c.flagCAFile,
+		Default: "",
+		Usage: fmt.Sprintf("Set the path to the CA certificate of the Consul servers. If set, the servers are called "+
+			"over HTTPS on port %d instead of HTTP on port %d.", consul.HTTPSPort, consul.HTTPPort),
+		Completion: complete.PredictFiles("*"),
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameAutoApprove,
+		Target:  &c.flagAutoApprove,
+		Default: defaultAutoApprove,
+		Usage:   "Skip confirmation prompt.",
+	})
+	f.DurationVar(&flag.DurationVar{
+		Name:    flagNameTimeout,
+		Target:  &c.flagTimeout,
+		Default: defaultTimeout,
+		Usage:   "Set how long to wait for each step of the rotation to reach all agents.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run generates a new gossip key, stores it in the secret the Consul pods read
+// their key from and rotates the keyring of the running agents to it.
+func (c *RotateCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("gossip rotate")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+
+	if err := c.setup(); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	ref, err := c.gossipKeySecret()
+	if err != nil {
+		c.UI.Output("Unable to rotate the gossip key: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+
+	if !c.flagAutoApprove {
+		confirmation, err := c.UI.Input(&terminal.Input{
+			Prompt: fmt.Sprintf("Rotate the gossip encryption key stored in secret %q in namespace %q? (y/N)", ref.Name, c.flagNamespace),
+			Style:  terminal.InfoStyle,
+			Secret: false,
+		})
+		if err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+		if common.Abort(confirmation) {
+			c.UI.Output("Gossip encryption key not rotated.", terminal.WithInfoStyle())
+			return 1
+		}
+	}
+
+	key, err := generateKey()
+	if err != nil {
+		c.UI.Output("Error generating gossip key: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+
+	c.UI.Output("Rotating gossip encryption key", terminal.WithHeaderStyle())
+
+	// The secret is updated first so that pods started during the rotation
+	// already join with the new key, and so that the controller, if it syncs
+	// the keyring, converges on the same key instead of reverting it.
+	if err := c.updateSecret(ref, key); err != nil {
+		c.UI.Output("Error updating secret %q: %v", ref.Name, err, terminal.WithErrorStyle())
+		return 1
+	}
+	c.UI.Output("Stored the new gossip key in secret %q.", ref.Name, terminal.WithSuccessStyle())
+
+	if err := c.rotate(key); err != nil {
+		c.UI.Output("Error rotating the gossip keyring: %v", err, terminal.WithErrorStyle())
+		c.UI.Output("The secret already has the new key. Run the command again, or let the controller finish "+
+			"the rotation if global.gossipEncryption.syncKeyring is enabled.", terminal.WithErrorStyle())
+		return 1
+	}
+	c.UI.Output("The gossip encryption key was rotated on all agents.", terminal.WithSuccessStyle())
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *RotateCommand) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if c.flagTimeout <= 0 {
+		return fmt.Errorf("-%s must be greater than zero", flagNameTimeout)
+	}
+	return nil
+}
+
+// setup creates the Kubernetes client and finds the namespace of the Consul
+// installation if -namespace is not set.
+func (c *RotateCommand) setup() error {
+	if c.flagToken == "" {
+		c.flagToken = os.Getenv(common.TokenEnvVar)
+	}
+	if c.pollInterval == 0 {
+		c.pollInterval = defaultPollInterval
+	}
+
+	settings, err := common.InitKubernetes(c.flagKubeConfig, c.flagKubeContext, &c.restConfig, &c.kubernetes)
+	if err != nil {
+		return err
+	}
+
+	if c.flagNamespace == "" {
+		uiLogger := func(msg string, args ...interface{}) {
+			c.Log.Debug(fmt.Sprintf(msg, args...))
+		}
+		_, namespace, err := common.CheckForInstallations(settings, uiLogger)
+		if err != nil {
+			return err
+		}
+		c.flagNamespace = namespace
+	}
+	return nil
+}
+
+// gossipKeySecret returns the secret key the server StatefulSet reads the
+// gossip key from, which is the same secret the client agents use.
+func (c *RotateCommand) gossipKeySecret() (*corev1.SecretKeySelector, error) {
+	sets, err := c.kubernetes.AppsV1().StatefulSets(c.flagNamespace).List(c.Ctx, metav1.ListOptions{LabelSelector: consul.ServerLabelSelector})
+	if err != nil {
+		return nil, err
+	}
+	if len(sets.Items) == 0 {
+		return nil, fmt.Errorf("no Consul server StatefulSet found in namespace %q", c.flagNamespace)
+	}
+	for _, container := range sets.Items[0].Spec.Template.Spec.Containers {
+		for _, env := range container.Env {
+			if env.Name == gossipKeyEnvVar && env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				return env.ValueFrom.SecretKeyRef, nil
+			}
+		}
+	}
+	return nil, errors.New("gossip encryption is not enabled or its key is not stored in a Kubernetes secret")
+}
+
+// updateSecret stores the gossip key in the secret.
+func (c *RotateCommand) updateSecret(ref *corev1.SecretKeySelector, key string) error {
+	secrets := c.kubernetes.CoreV1().Secrets(c.flagNamespace)
+	secret, err := secrets.Get(c.Ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	secret.Data[ref.Key] = []byte(key)
+	_, err = secrets.Update(c.Ctx, secret, metav1.UpdateOptions{})
+	return err
+}
+
+// rotate installs the key on all agents, makes it the primary key once every
+// agent has it and then removes the other keys. The keyring API of the servers
+// forwards each operation to all agents of the LAN and WAN gossip pools,
+// including the client agents.
+func (c *RotateCommand) rotate(key string) error {
+	pods, err := c.kubernetes.CoreV1().Pods(c.flagNamespace).List(c.Ctx, metav1.ListOptions{LabelSelector: consul.ServerLabelSelector})
+	if err != nil {
+		return err
+	}
+	var pod *corev1.Pod
+	for i := range pods.Items {
+		if common.PodReady(pods.Items[i]) {
+			pod = &pods.Items[i]
+			break
+		}
+	}
+	if pod == nil {
+		return errors.New("no ready server pod to connect to")
+	}
+	open := consul.ServerConfig{
+		KubeClient: c.kubernetes,
+		RestConfig: c.restConfig,
+		Token:      c.flagToken,
+		CAFile:     c.flagCAFile,
+	}.Opener(c.openServer)
+	client, closeClient, err := open(pod)
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
+	c.UI.Output("Installing the new key", terminal.WithInfoStyle())
+	if err := client.KeyringInstall(c.Ctx, key); err != nil {
+		return fmt.Errorf("installing key: %s", err)
+	}
+	if err := c.waitForKeyring(client, "the key to be installed on all agents", func(ring consul.Keyring) bool {
+		return ring.Keys[key] >= ring.NumNodes
+	}); err != nil {
+		return err
+	}
+
+	c.UI.Output("Making the new key the primary key", terminal.WithInfoStyle())
+	if err := client.KeyringUse(c.Ctx, key); err != nil {
+		return fmt.Errorf("making key primary: %s", err)
+	}
+	// Consul versions that don't report the primary keys apply the change
+	// synchronously, so there is nothing to wait for.
+	if err := c.waitForKeyring(client, "the key to be the primary key of all agents", func(ring consul.Keyring) bool {
+		return ring.PrimaryKeys == nil || ring.PrimaryKeys[key] >= ring.NumNodes
+	}); err != nil {
+		return err
+	}
+
+	c.UI.Output("Removing the old keys", terminal.WithInfoStyle())
+	rings, err := client.KeyringList(c.Ctx)
+	if err != nil {
+		return fmt.Errorf("listing keyring: %s", err)
+	}
+	for _, old := range oldKeys(rings, key) {
+		if err := client.KeyringRemove(c.Ctx, old); err != nil {
+			return fmt.Errorf("removing old key: %s", err)
+		}
+	}
+	return c.waitForKeyring(client, "the old keys to be removed from all agents", func(ring consul.Keyring) bool {
+		return len(ring.Keys) == 1
+	})
+}
+
+// waitForKeyring lists the keyrings every poll interval until done returns
+// true for all of them.
+func (c *RotateCommand) waitForKeyring(client *consul.Client, waitingFor string, done func(ring consul.Keyring) bool) error {
+	ctx, cancel := context.WithTimeout(c.Ctx, c.flagTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+	for {
+		rings, err := client.KeyringList(ctx)
+		if err != nil {
+			c.Log.Debug("listing keyring", "error", err)
+		} else if allRings(rings, done) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for %s", waitingFor)
+		case <-ticker.C:
+		}
+	}
+}
+
+// generateKey returns a new random gossip key, which is 32 bytes encoded in
+// base64 like `consul keygen` generates.
+func generateKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// allRings returns true if done returns true for all keyrings.
+func allRings(rings []consul.Keyring, done func(ring consul.Keyring) bool) bool {
+	for _, ring := range rings {
+		if !done(ring) {
+			return false
+		}
+	}
+	return true
+}
+
+// oldKeys returns the keys other than key in any of the keyrings, sorted.
+func oldKeys(rings []consul.Keyring, key string) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, ring := range rings {
+		for k := range ring.Keys {
+			if k != key && !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Help returns a description of the command and how it is used.
+func (c *RotateCommand) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s gossip rotate [flags]\n\n" +
+		"A new gossip key is generated and stored in the Kubernetes secret the Consul pods read their\n" +
+		"key from, so that new pods use it. The key is then installed on all agents, made the primary\n" +
+		"key once every agent has it, and the old keys are removed once every agent uses the new key.\n" +
+		"Gossip encryption must be enabled with global.gossipEncryption.autoGenerate or\n" +
+		"global.gossipEncryption.secretName.\n\n" + c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *RotateCommand) Synopsis() string {
+	return "Rotate the gossip encryption key of the Consul agents."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *RotateCommand) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *RotateCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
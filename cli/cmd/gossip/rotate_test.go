@@ -0,0 +1,228 @@
+package gossip
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/hashicorp/consul-k8s/cli/helper/test"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+const oldKey = "6Ypk7MBp4c+6HR6/r9Czs1Cr0OfANRUzGA2E6UXbZs4="
+
+// TestValidateFlags tests the validate flags function.
+func TestValidateFlags(t *testing.T) {
+	// The following cases should all error, if they fail to this test fails.
+	testCases := []struct {
+		description string
+		input       []string
+	}{
+		{
+			"Should disallow non-flag arguments.",
+			[]string{"key"},
+		},
+		{
+			"Should disallow a zero timeout.",
+			[]string{"-timeout", "0s"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		c := getInitializedCommand(t)
+		t.Run(testCase.description, func(t *testing.T) {
+			if err := c.validateFlags(testCase.input); err == nil {
+				t.Errorf("Test case should have failed.")
+			}
+		})
+	}
+}
+
+// TestRun tests that the secret is updated with a new key and the keyring is
+// rotated to it.
+func TestRun(t *testing.T) {
+	cases := map[string]struct {
+		gossipEnabled bool
+		expCode       int
+	}{
+		"gossip encryption enabled": {
+			gossipEnabled: true,
+			expCode:       0,
+		},
+		"gossip encryption disabled": {
+			gossipEnabled: false,
+			expCode:       1,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			keyring := newFakeKeyring(oldKey)
+			srv := httptest.NewServer(keyring)
+			defer srv.Close()
+
+			client := fake.NewSimpleClientset(
+				serverStatefulSet(c.gossipEnabled),
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "consul-gossip-encryption-key", Namespace: "consul"},
+					Data:       map[string][]byte{"key": []byte(oldKey)},
+				},
+				test.ServerPod("consul-server-0", "consul", ""),
+			)
+
+			cmd := getInitializedCommand(t)
+			cmd.kubernetes = client
+			cmd.restConfig = &rest.Config{}
+			cmd.pollInterval = time.Millisecond
+			cmd.openServer = func(pod *corev1.Pod) (*consul.Client, func(), error) {
+				return &consul.Client{Addr: strings.TrimPrefix(srv.URL, "http://")}, func() {}, nil
+			}
+
+			code := cmd.Run([]string{"-n", "consul", "-auto-approve", "-timeout", "1s"})
+			require.Equal(t, c.expCode, code)
+
+			secret, err := client.CoreV1().Secrets("consul").Get(context.Background(), "consul-gossip-encryption-key", metav1.GetOptions{})
+			require.NoError(t, err)
+			newKey := string(secret.Data["key"])
+			if !c.gossipEnabled {
+				require.Equal(t, oldKey, newKey)
+				require.Equal(t, []string{oldKey}, keyring.keys())
+				return
+			}
+			require.NotEqual(t, oldKey, newKey)
+			require.Equal(t, []string{newKey}, keyring.keys())
+			require.Equal(t, newKey, keyring.primary)
+		})
+	}
+}
+
+func TestOldKeys(t *testing.T) {
+	rings := []consul.Keyring{
+		{Keys: map[string]int{"new": 3, "b": 3}},
+		{WAN: true, Keys: map[string]int{"new": 1, "a": 1, "b": 1}},
+	}
+	require.Equal(t, []string{"a", "b"}, oldKeys(rings, "new"))
+}
+
+// fakeKeyring is a Consul keyring API of a single agent.
+type fakeKeyring struct {
+	mu        sync.Mutex
+	installed map[string]bool
+	primary   string
+}
+
+func newFakeKeyring(key string) *fakeKeyring {
+	return &fakeKeyring{installed: map[string]bool{key: true}, primary: key}
+}
+
+func (k *fakeKeyring) keys() []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var keys []string
+	for key := range k.installed {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (k *fakeKeyring) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if r.URL.Path != "/v1/operator/keyring" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Method == http.MethodGet {
+		keys := make(map[string]int)
+		for key := range k.installed {
+			keys[key] = 1
+		}
+		json.NewEncoder(w).Encode([]consul.Keyring{
+			{Datacenter: "dc1", Keys: keys, PrimaryKeys: map[string]int{k.primary: 1}, NumNodes: 1},
+		})
+		return
+	}
+
+	var body struct{ Key string }
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		k.installed[body.Key] = true
+	case http.MethodPut:
+		k.primary = body.Key
+	case http.MethodDelete:
+		if body.Key == k.primary {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Removing the primary key is not allowed"))
+			return
+		}
+		delete(k.installed, body.Key)
+	}
+}
+
+func serverStatefulSet(gossipEnabled bool) *appsv1.StatefulSet {
+	var env []corev1.EnvVar
+	if gossipEnabled {
+		env = append(env, corev1.EnvVar{
+			Name: "GOSSIP_KEY",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "consul-gossip-encryption-key"},
+					Key:                  "key",
+				},
+			},
+		})
+	}
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "consul-server",
+			Namespace: "consul",
+			Labels:    map[string]string{"app": "consul", "chart": "consul-helm", "component": "server"},
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "consul", Env: env}},
+				},
+			},
+		},
+	}
+}
+
+// getInitializedCommand sets up a command struct for tests.
+func getInitializedCommand(t *testing.T) *RotateCommand {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+	}
+
+	c := &RotateCommand{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
@@ -1,9 +1,11 @@
 package install
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -15,13 +17,17 @@ import (
 	"github.com/hashicorp/consul-k8s/cli/config"
 	"github.com/hashicorp/consul-k8s/cli/helm"
 	"github.com/hashicorp/consul-k8s/cli/release"
+	"github.com/hashicorp/consul-k8s/cli/rollout"
 	"github.com/hashicorp/consul-k8s/cli/validation"
+	"github.com/posener/complete"
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
 	helmCLI "helm.sh/helm/v3/pkg/cli"
 	"helm.sh/helm/v3/pkg/cli/values"
 	"helm.sh/helm/v3/pkg/getter"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"sigs.k8s.io/yaml"
@@ -39,6 +45,12 @@ const (
 	flagNameDryRun = "dry-run"
 	defaultDryRun  = false
 
+	flagNameDryRunOutput  = "dry-run-output"
+	dryRunOutputSummary   = "summary"
+	dryRunOutputManifests = "manifests"
+	dryRunOutputDiff      = "diff"
+	defaultDryRunOutput   = dryRunOutputSummary
+
 	flagNameAutoApprove = "auto-approve"
 	defaultAutoApprove  = false
 
@@ -52,6 +64,21 @@ const (
 
 	flagNameWait = "wait"
 	defaultWait  = true
+
+	flagNameWaitStrategy = "wait-strategy"
+	waitStrategyRollout  = "rollout"
+	waitStrategyHelm     = "helm"
+	defaultWaitStrategy  = waitStrategyRollout
+
+	flagNameChartArchive = "chart-archive"
+
+	flagNameImageRegistryMirror = "image-registry-mirror"
+
+	flagNameOutput  = "output"
+	outputCluster   = "cluster"
+	outputTerraform = "terraform"
+	outputManifests = "manifests"
+	defaultOutput   = outputCluster
 )
 
 type Command struct {
@@ -64,6 +91,7 @@ type Command struct {
 	flagPreset          string
 	flagNamespace       string
 	flagDryRun          bool
+	flagDryRunOutput    string
 	flagAutoApprove     bool
 	flagValueFiles      []string
 	flagSetStringValues []string
@@ -73,6 +101,11 @@ type Command struct {
 	timeoutDuration     time.Duration
 	flagVerbose         bool
 	flagWait            bool
+	flagWaitStrategy    string
+
+	flagChartArchive        string
+	flagImageRegistryMirror string
+	flagOutput              string
 
 	flagKubeConfig  string
 	flagKubeContext string
@@ -87,6 +120,7 @@ func (c *Command) init() {
 	for name := range config.Presets {
 		presetList = append(presetList, name)
 	}
+	sort.Strings(presetList)
 
 	c.set = flag.NewSets()
 	f := c.set.NewSet("Command Options")
@@ -102,6 +136,14 @@ func (c *Command) init() {
 		Default: defaultDryRun,
 		Usage:   "Perform pre-install checks and display a summary of the installation.",
 	})
+	f.EnumSingleVar(&flag.EnumSingleVar{
+		Name:    flagNameDryRunOutput,
+		Target:  &c.flagDryRunOutput,
+		Default: defaultDryRunOutput,
+		Values:  []string{dryRunOutputSummary, dryRunOutputManifests, dryRunOutputDiff},
+		Usage: "Set what is displayed by a dry run: only the summary, the full Kubernetes manifests rendered from the chart, " +
+			"or the diff of those manifests against the live objects in the cluster. Requires -dry-run.",
+	})
 	f.StringSliceVar(&flag.StringSliceVar{
 		Name:    flagNameConfigFile,
 		Aliases: []string{"f"},
@@ -109,10 +151,11 @@ func (c *Command) init() {
 		Usage:   "Set the path to a file to customize the installation, such as Consul Helm chart values file. Can be specified multiple times.",
 	})
 	f.StringVar(&flag.StringVar{
-		Name:    flagNameNamespace,
-		Target:  &c.flagNamespace,
-		Default: common.DefaultReleaseNamespace,
-		Usage:   "Set the namespace for the Consul installation.",
+		Name:       flagNameNamespace,
+		Target:     &c.flagNamespace,
+		Default:    common.DefaultReleaseNamespace,
+		Usage:      "Set the namespace for the Consul installation.",
+		Completion: common.PredictKubeNamespaces,
 	})
 	f.StringVar(&flag.StringVar{
 		Name:    flagNamePreset,
@@ -155,20 +198,54 @@ func (c *Command) init() {
 		Default: defaultWait,
 		Usage:   "Wait for Kubernetes resources in installation to be ready before exiting command.",
 	})
+	f.EnumSingleVar(&flag.EnumSingleVar{
+		Name:    flagNameWaitStrategy,
+		Target:  &c.flagWaitStrategy,
+		Default: defaultWaitStrategy,
+		Values:  []string{waitStrategyRollout, waitStrategyHelm},
+		Usage: "Set how the command waits for the installation to be ready: rollout watches each component and prints " +
+			"its progress, e.g. the servers that joined the Raft cluster, and prints the logs and events of the pods that " +
+			"aren't ready if the timeout is reached. helm uses the wait of Helm, which only reports when all resources are ready.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameChartArchive,
+		Target: &c.flagChartArchive,
+		Usage: "Set the path to a local Consul Helm chart archive or directory to install instead of the chart bundled " +
+			"with the CLI. When set, no network calls are made to fetch the chart or remote values files, for use in air-gapped environments.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameImageRegistryMirror,
+		Target: &c.flagImageRegistryMirror,
+		Usage: "Set a registry mirror prefix, such as registry.internal, to pull all Consul, control plane and " +
+			"Envoy images from. Any registry in the image references is replaced by the mirror.",
+	})
+
+	f.EnumSingleVar(&flag.EnumSingleVar{
+		Name:    flagNameOutput,
+		Aliases: []string{"o"},
+		Target:  &c.flagOutput,
+		Default: defaultOutput,
+		Values:  []string{outputCluster, outputTerraform, outputManifests},
+		Usage: "Set what the installation produces: install Consul into the cluster, or print an equivalent Terraform " +
+			"helm_release resource or the plain Kubernetes manifests instead. The terraform and manifests outputs don't " +
+			"connect to the cluster, so none of the pre-install checks are run.",
+	})
 
 	f = c.set.NewSet("Global Options")
 	f.StringVar(&flag.StringVar{
-		Name:    "kubeconfig",
-		Aliases: []string{"c"},
-		Target:  &c.flagKubeConfig,
-		Default: "",
-		Usage:   "Set the path to kubeconfig file.",
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
 	})
 	f.StringVar(&flag.StringVar{
-		Name:    "context",
-		Target:  &c.flagKubeContext,
-		Default: "",
-		Usage:   "Set the Kubernetes context to use.",
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
 	})
 
 	c.help = c.set.Help()
@@ -208,6 +285,15 @@ func (c *Command) Run(args []string) int {
 		settings.KubeContext = c.flagKubeContext
 	}
 
+	// The Terraform and manifests outputs are printed without connecting to the cluster.
+	if c.flagOutput != outputCluster {
+		if err := c.printInstallation(settings); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+		return 0
+	}
+
 	// Setup logger to stream Helm library logs
 	var uiLogger = func(s string, args ...interface{}) {
 		logMsg := fmt.Sprintf(s, args...)
@@ -256,12 +342,31 @@ func (c *Command) Run(args []string) int {
 	}
 	c.UI.Output("No existing Consul persistent volume claims found", terminal.WithSuccessStyle())
 
-	// Handle preset, value files, and set values logic.
-	vals, err := c.mergeValuesFlagsWithPrecedence(settings)
+	// Load the Helm chart.
+	chart, err := c.loadChart()
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	vals, err := c.installValues(settings, chart)
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	vals, err = c.enableOpenShift(vals)
 	if err != nil {
 		c.UI.Output(err.Error(), terminal.WithErrorStyle())
 		return 1
 	}
+	// The values can only be validated against the chart bundled with the CLI since
+	// the Values struct is generated from its values.yaml.
+	if c.flagChartArchive == "" {
+		if err := helm.ValidateValues(chart, vals); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+	}
 	valuesYaml, err := yaml.Marshal(vals)
 	if err != nil {
 		c.UI.Output(err.Error(), terminal.WithErrorStyle())
@@ -316,6 +421,12 @@ func (c *Command) Run(args []string) int {
 	vals = common.MergeMaps(config.Convert(config.GlobalNameConsul), vals)
 
 	if c.flagDryRun {
+		if c.flagDryRunOutput != dryRunOutputSummary {
+			if err := c.printDryRunManifests(settings, chart, vals, uiLogger); err != nil {
+				c.UI.Output(err.Error(), terminal.WithErrorStyle())
+				return 1
+			}
+		}
 		c.UI.Output("Dry run complete. No changes were made to the Kubernetes cluster.\n"+
 			"Installation can proceed with this configuration.", terminal.WithInfoStyle())
 		return 0
@@ -354,23 +465,52 @@ func (c *Command) Run(args []string) int {
 	install.ReleaseName = common.DefaultReleaseName
 	install.Namespace = c.flagNamespace
 	install.CreateNamespace = true
-	install.Wait = c.flagWait
+	install.Wait = c.flagWait && c.flagWaitStrategy == waitStrategyHelm
 	install.Timeout = c.timeoutDuration
 
-	// Load the Helm chart.
-	chart, err := helm.LoadChart(consulChart.ConsulHelmChart, common.TopLevelChartDirName)
-	if err != nil {
-		c.UI.Output(err.Error(), terminal.WithErrorStyle())
-		return 1
+	if c.flagChartArchive != "" {
+		c.UI.Output("Loaded chart from %s", c.flagChartArchive, terminal.WithSuccessStyle())
+	} else {
+		c.UI.Output("Downloaded charts", terminal.WithSuccessStyle())
+	}
+
+	// Watch the components roll out while Helm installs them, since Helm
+	// doesn't return before its hooks, e.g. the ACL bootstrap, are done.
+	var watcher *rollout.Watcher
+	var watch *rollout.Watch
+	if c.flagWait && c.flagWaitStrategy == waitStrategyRollout {
+		components, err := c.rolloutComponents(chart, vals)
+		if err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+		watcher = &rollout.Watcher{
+			Kubernetes: c.kubernetes,
+			Namespace:  c.flagNamespace,
+			Progress:   rollout.PrintProgress(c.UI),
+		}
+		ctx, cancel := context.WithTimeout(c.Ctx, c.timeoutDuration)
+		defer cancel()
+		watch = watcher.Start(ctx, components)
 	}
-	c.UI.Output("Downloaded charts", terminal.WithSuccessStyle())
 
 	// Run the install.
 	if _, err = install.Run(chart, vals); err != nil {
 		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		if watch != nil {
+			c.diagnose(watcher, watch.Stop())
+		}
 		return 1
 	}
 
+	if watch != nil {
+		if statuses, err := watch.Wait(); err != nil {
+			c.UI.Output("Consul was installed but is not ready: %s", err, terminal.WithErrorStyle())
+			c.diagnose(watcher, statuses)
+			return 1
+		}
+	}
+
 	c.UI.Output("Consul installed in namespace %q.", c.flagNamespace, terminal.WithSuccessStyle())
 	return 0
 }
@@ -386,6 +526,18 @@ func (c *Command) Synopsis() string {
 	return "Install Consul on Kubernetes."
 }
 
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *Command) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *Command) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
 // checkForPreviousPVCs checks for existing Kubernetes persistent volume claims with a name containing "consul-server"
 // and returns an error with a list of PVCs it finds if any match.
 func (c *Command) checkForPreviousPVCs() error {
@@ -448,6 +600,63 @@ func (c *Command) checkForPreviousSecrets(release release.Release) (string, erro
 	return "No existing Consul secrets found.", nil
 }
 
+// installValues returns the values to install the chart with, which are the
+// values flags, the preset and the cluster-specific values the preset prompts
+// for, with every image pointed at the registry mirror.
+func (c *Command) installValues(settings *helmCLI.EnvSettings, chart *chart.Chart) (map[string]interface{}, error) {
+	// Handle preset, value files, and set values logic.
+	vals, err := c.mergeValuesFlagsWithPrecedence(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prompt for the cluster-specific values the preset requires.
+	vals, err = c.promptForPresetInputs(vals)
+	if err != nil {
+		return nil, err
+	}
+
+	// Point every image at the registry mirror before the values are shown in the summary.
+	if c.flagImageRegistryMirror != "" {
+		vals = helm.RewriteImages(vals, chart.Values, c.flagImageRegistryMirror)
+	}
+	return vals, nil
+}
+
+// enableOpenShift sets global.openshift.enabled when installing on OpenShift,
+// so that the chart creates the SecurityContextConstraints its components need
+// and the injected containers run under the restricted-v2 profile. Values
+// that set global.openshift.enabled explicitly are kept.
+func (c *Command) enableOpenShift(vals map[string]interface{}) (map[string]interface{}, error) {
+	if _, ok, _ := unstructured.NestedFieldNoCopy(vals, "global", "openshift", "enabled"); ok {
+		return vals, nil
+	}
+	openShift, err := isOpenShift(c.kubernetes)
+	if err != nil {
+		return nil, fmt.Errorf("error detecting whether the cluster is OpenShift: %s", err)
+	}
+	if !openShift {
+		return vals, nil
+	}
+	c.UI.Output("OpenShift detected, setting global.openshift.enabled=true", terminal.WithInfoStyle())
+	return common.MergeMaps(vals, config.Convert(config.GlobalOpenShiftEnabled)), nil
+}
+
+// isOpenShift returns whether the cluster serves the security.openshift.io API
+// group of the SecurityContextConstraints, which only OpenShift does.
+func isOpenShift(client kubernetes.Interface) (bool, error) {
+	groups, err := client.Discovery().ServerGroups()
+	if err != nil {
+		return false, err
+	}
+	for _, group := range groups.Groups {
+		if group.Name == "security.openshift.io" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // mergeValuesFlagsWithPrecedence is responsible for merging all the values to determine the values file for the
 // installation based on the following precedence order from lowest to highest:
 // 1. -preset
@@ -459,6 +668,11 @@ func (c *Command) checkForPreviousSecrets(release release.Release) (string, erro
 // Within each of these groups the rightmost flag value has the highest precedence.
 func (c *Command) mergeValuesFlagsWithPrecedence(settings *helmCLI.EnvSettings) (map[string]interface{}, error) {
 	p := getter.All(settings)
+	if c.flagChartArchive != "" {
+		// Installing from a local chart archive means we may not have network access,
+		// so only allow values files to be read from the local filesystem.
+		p = getter.Providers{}
+	}
 	v := &values.Options{
 		ValueFiles:   c.flagValueFiles,
 		StringValues: c.flagSetStringValues,
@@ -477,6 +691,42 @@ func (c *Command) mergeValuesFlagsWithPrecedence(settings *helmCLI.EnvSettings)
 	return vals, err
 }
 
+// promptForPresetInputs prompts for the values required by the preset that
+// weren't set by the other values flags, and returns vals with them set. With
+// -auto-approve there is no one to prompt, so the missing values are an error.
+func (c *Command) promptForPresetInputs(vals map[string]interface{}) (map[string]interface{}, error) {
+	missing := config.MissingPresetInputs(c.flagPreset, vals)
+	if len(missing) == 0 {
+		return vals, nil
+	}
+	if c.flagAutoApprove {
+		var paths []string
+		for _, input := range missing {
+			paths = append(paths, input.Paths[0])
+		}
+		return nil, fmt.Errorf("preset '%s' requires values for %s; set them with -%s when using -%s",
+			c.flagPreset, strings.Join(paths, ", "), flagNameSetValues, flagNameAutoApprove)
+	}
+
+	c.UI.Output("Preset %s requires cluster-specific values", c.flagPreset, terminal.WithHeaderStyle())
+	for _, input := range missing {
+		var raw string
+		for strings.TrimSpace(raw) == "" {
+			var err error
+			raw, err = c.UI.Input(&terminal.Input{
+				Prompt: input.Prompt + ":",
+				Style:  terminal.InfoStyle,
+				Secret: false,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		vals = common.MergeMaps(vals, input.Values(raw))
+	}
+	return vals, nil
+}
+
 // validateFlags checks the command line flags and values for errors.
 func (c *Command) validateFlags(args []string) error {
 	if err := c.set.Parse(args); err != nil {
@@ -507,10 +757,76 @@ func (c *Command) validateFlags(args []string) error {
 			}
 		}
 	}
+	if c.flagChartArchive != "" {
+		if _, err := os.Stat(c.flagChartArchive); err != nil && os.IsNotExist(err) {
+			return fmt.Errorf("chart archive '%s' does not exist", c.flagChartArchive)
+		}
+	}
+	if c.flagDryRunOutput != dryRunOutputSummary && !c.flagDryRun {
+		return fmt.Errorf("-%s can only be set with -%s", flagNameDryRunOutput, flagNameDryRun)
+	}
+	if c.flagOutput != outputCluster && c.flagDryRun {
+		return fmt.Errorf("cannot set both -%s and -%s", flagNameOutput, flagNameDryRun)
+	}
+	if strings.Contains(c.flagImageRegistryMirror, "://") {
+		return fmt.Errorf("-%s must be a registry prefix without a scheme, e.g. registry.internal", flagNameImageRegistryMirror)
+	}
 
 	return nil
 }
 
+// printDryRunManifests renders the chart with the given values without installing it and prints
+// either the rendered manifests or their diff against the live objects in the cluster. Resources
+// that don't exist yet are shown as added. Hooks are left out of the diff since Helm creates them
+// anew on every install.
+func (c *Command) printDryRunManifests(settings *helmCLI.EnvSettings, chart *chart.Chart, vals map[string]interface{}, uiLogger action.DebugLog) error {
+	actionConfig := new(action.Configuration)
+	actionConfig, err := helm.InitActionConfig(actionConfig, c.flagNamespace, settings, uiLogger)
+	if err != nil {
+		return err
+	}
+
+	install := action.NewInstall(actionConfig)
+	install.ReleaseName = common.DefaultReleaseName
+	install.Namespace = c.flagNamespace
+	install.DryRun = true
+	rel, err := install.Run(chart, vals)
+	if err != nil {
+		return fmt.Errorf("error rendering manifests: %s", err)
+	}
+
+	if c.flagDryRunOutput == dryRunOutputManifests {
+		c.UI.Output("Rendered Kubernetes manifests", terminal.WithHeaderStyle())
+		c.UI.Output("%s", helm.ReleaseManifests(rel))
+		return nil
+	}
+
+	diff, err := helm.DiffLiveManifests(actionConfig.KubeClient, "", rel.Manifest)
+	if err != nil {
+		return err
+	}
+	c.UI.Output("Difference between the cluster and the rendered Kubernetes manifests", terminal.WithHeaderStyle())
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "+") {
+			c.UI.Output("%s", line, terminal.WithDiffAddedStyle())
+		} else if strings.HasPrefix(line, "-") {
+			c.UI.Output("%s", line, terminal.WithDiffRemovedStyle())
+		} else {
+			c.UI.Output("%s", line, terminal.WithDiffUnchangedStyle())
+		}
+	}
+	return nil
+}
+
+// loadChart loads the Helm chart to install. The chart bundled with the CLI is used
+// unless a local chart archive was provided.
+func (c *Command) loadChart() (*chart.Chart, error) {
+	if c.flagChartArchive != "" {
+		return helm.LoadChartArchive(c.flagChartArchive)
+	}
+	return helm.LoadChart(consulChart.ConsulHelmChart, common.TopLevelChartDirName)
+}
+
 // checkValidEnterprise checks and validates an enterprise installation.
 // When an enterprise license secret is provided, check that the secret exists in the "consul" namespace.
 func (c *Command) checkValidEnterprise(secretName string) error {
@@ -3,15 +3,21 @@ package install
 import (
 	"context"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/config"
 	"github.com/hashicorp/consul-k8s/cli/helm"
 	"github.com/hashicorp/consul-k8s/cli/release"
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
 	"k8s.io/client-go/kubernetes/fake"
 )
 
@@ -186,6 +192,30 @@ func TestValidateFlags(t *testing.T) {
 			"Should have errored on a non-existant file.",
 			[]string{"-f=\"does_not_exist.txt\""},
 		},
+		{
+			"Should have errored on a non-existant chart archive.",
+			[]string{"-chart-archive=does_not_exist.tgz"},
+		},
+		{
+			"Should error on a dry run output without a dry run.",
+			[]string{"-dry-run-output=manifests"},
+		},
+		{
+			"Should error on an invalid dry run output.",
+			[]string{"-dry-run", "-dry-run-output=foo"},
+		},
+		{
+			"Should error on an image registry mirror with a scheme.",
+			[]string{"-image-registry-mirror=https://registry.internal"},
+		},
+		{
+			"Should error on an invalid output.",
+			[]string{"-output=json"},
+		},
+		{
+			"Should error on an output with a dry run.",
+			[]string{"-output=terraform", "-dry-run"},
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -248,3 +278,127 @@ func TestCheckValidEnterprise(t *testing.T) {
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "please make sure that the secret exists")
 }
+
+func TestPromptForPresetInputs(t *testing.T) {
+	c := getInitializedCommand(t)
+	c.flagPreset = config.PresetExternalServers
+	c.flagAutoApprove = true
+
+	// There is no one to prompt with -auto-approve.
+	_, err := c.promptForPresetInputs(map[string]interface{}{})
+	require.EqualError(t, err, "preset 'external-servers' requires values for global.datacenter, externalServers.hosts; "+
+		"set them with -set when using -auto-approve")
+
+	// Values that are already set aren't prompted for.
+	vals := config.Convert(`
+global:
+  datacenter: dc2
+externalServers:
+  hosts: ["1.2.3.4"]
+`)
+	actual, err := c.promptForPresetInputs(vals)
+	require.NoError(t, err)
+	require.Equal(t, vals, actual)
+}
+
+func TestEnableOpenShift(t *testing.T) {
+	openShift := func() *fake.Clientset {
+		client := fake.NewSimpleClientset()
+		client.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+			{GroupVersion: "security.openshift.io/v1"},
+		}
+		return client
+	}
+
+	cases := map[string]struct {
+		client  *fake.Clientset
+		vals    map[string]interface{}
+		expVals map[string]interface{}
+	}{
+		"not OpenShift": {
+			client:  fake.NewSimpleClientset(),
+			vals:    config.Convert(`global: {name: consul}`),
+			expVals: config.Convert(`global: {name: consul}`),
+		},
+		"OpenShift": {
+			client:  openShift(),
+			vals:    config.Convert(`global: {name: consul}`),
+			expVals: config.Convert(`global: {name: consul, openshift: {enabled: true}}`),
+		},
+		"OpenShift with global.openshift.enabled=false": {
+			client:  openShift(),
+			vals:    config.Convert(`global: {openshift: {enabled: false}}`),
+			expVals: config.Convert(`global: {openshift: {enabled: false}}`),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := getInitializedCommand(t)
+			c.UI = terminal.NewBasicUI(context.Background())
+			c.kubernetes = tc.client
+			vals, err := c.enableOpenShift(tc.vals)
+			require.NoError(t, err)
+			require.Equal(t, tc.expVals, vals)
+		})
+	}
+}
+
+func TestTerraformRelease(t *testing.T) {
+	chart := &chart.Chart{Metadata: &chart.Metadata{Name: "consul", Version: "0.42.0"}}
+	vals := config.Convert(`
+global:
+  name: consul
+server:
+  extraConfig: '{"log_level": "${LOG_LEVEL}"}'
+  annotations: |
+    EOT
+`)
+
+	actual, err := terraformRelease(chart, "", "consul", vals, true, 10*time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, `resource "helm_release" "consul" {
+  name             = "consul"
+  namespace        = "consul"
+  create_namespace = true
+  repository       = "https://helm.releases.hashicorp.com"
+  chart            = "consul"
+  version          = "0.42.0"
+  wait             = true
+  timeout          = 600
+
+  values = [
+    <<-EOT1
+    global:
+      name: consul
+    server:
+      annotations: |
+        EOT
+      extraConfig: '{"log_level": "$${LOG_LEVEL}"}'
+    EOT1
+  ]
+}
+`, actual)
+
+	// A local chart is referenced by its path.
+	actual, err = terraformRelease(chart, "/charts/consul-%{x}", "mesh", nil, false, time.Minute)
+	require.NoError(t, err)
+	require.Contains(t, actual, `  chart            = "/charts/consul-%%{x}"
+  wait             = false
+  timeout          = 60
+`)
+	require.NotContains(t, actual, "repository")
+}
+
+func TestRenderManifests(t *testing.T) {
+	c := getInitializedCommand(t)
+	c.flagNamespace = "mesh"
+	chart, err := c.loadChart()
+	require.NoError(t, err)
+
+	manifests, err := c.renderManifests(chart, config.Convert(config.GlobalNameConsul))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(manifests, "---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: mesh\n---\n"))
+	require.Contains(t, manifests, "name: consul-server\n  namespace: mesh\n")
+	// Test hooks are not part of the installation.
+	require.NotContains(t, manifests, "templates/tests/")
+}
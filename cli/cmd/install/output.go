@@ -0,0 +1,147 @@
+package install
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/config"
+	"github.com/hashicorp/consul-k8s/cli/helm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	"sigs.k8s.io/yaml"
+)
+
+// chartRepository is the Helm repository the Consul chart is published to.
+const chartRepository = "https://helm.releases.hashicorp.com"
+
+// printInstallation prints the installation as a Terraform helm_release
+// resource or as plain Kubernetes manifests, instead of installing it. The
+// chart and values are the ones an install would use, but the cluster is not
+// contacted.
+func (c *Command) printInstallation(settings *helmCLI.EnvSettings) error {
+	chart, err := c.loadChart()
+	if err != nil {
+		return err
+	}
+	vals, err := c.installValues(settings, chart)
+	if err != nil {
+		return err
+	}
+	// Like an install, default global.name to consul so that resources aren't prefixed with "consul-consul-".
+	vals = common.MergeMaps(config.Convert(config.GlobalNameConsul), vals)
+
+	var out string
+	switch c.flagOutput {
+	case outputTerraform:
+		chartPath := ""
+		if c.flagChartArchive != "" {
+			if chartPath, err = filepath.Abs(c.flagChartArchive); err != nil {
+				return err
+			}
+		}
+		out, err = terraformRelease(chart, chartPath, c.flagNamespace, vals, c.flagWait, c.timeoutDuration)
+	case outputManifests:
+		out, err = c.renderManifests(chart, vals)
+	}
+	if err != nil {
+		return err
+	}
+
+	stdout, _, err := c.UI.OutputWriters()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(stdout, out)
+	return err
+}
+
+// renderManifests renders the chart with the values like "helm template"
+// does, without contacting the cluster, and returns the manifests in the order
+// an install applies them, preceded by the release namespace.
+func (c *Command) renderManifests(chart *chart.Chart, vals map[string]interface{}) (string, error) {
+	install := action.NewInstall(&action.Configuration{
+		Log: func(format string, v ...interface{}) { c.Log.Debug(fmt.Sprintf(format, v...)) },
+	})
+	install.ReleaseName = common.DefaultReleaseName
+	install.Namespace = c.flagNamespace
+	install.DryRun = true
+	install.ClientOnly = true
+	rel, err := install.Run(chart, vals)
+	if err != nil {
+		return "", fmt.Errorf("error rendering manifests: %s", err)
+	}
+
+	namespace := fmt.Sprintf("---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: %s\n", c.flagNamespace)
+	return namespace + helm.InstallManifests(rel), nil
+}
+
+// terraformRelease returns a Terraform helm_release resource of the Helm
+// provider that installs the chart with the values. The chart is installed
+// from chartPath if it is set, and from the HashiCorp Helm repository
+// otherwise.
+func terraformRelease(chart *chart.Chart, chartPath, namespace string, vals map[string]interface{}, wait bool, timeout time.Duration) (string, error) {
+	valuesYaml, err := yaml.Marshal(vals)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("resource \"helm_release\" \"consul\" {\n")
+	b.WriteString(fmt.Sprintf("  name             = %s\n", strconv.Quote(common.DefaultReleaseName)))
+	b.WriteString(fmt.Sprintf("  namespace        = %s\n", strconv.Quote(namespace)))
+	b.WriteString("  create_namespace = true\n")
+	if chartPath != "" {
+		b.WriteString(fmt.Sprintf("  chart            = %s\n", terraformString(chartPath)))
+	} else {
+		b.WriteString(fmt.Sprintf("  repository       = %s\n", strconv.Quote(chartRepository)))
+		b.WriteString(fmt.Sprintf("  chart            = %s\n", strconv.Quote(chart.Name())))
+		b.WriteString(fmt.Sprintf("  version          = %s\n", strconv.Quote(chart.Metadata.Version)))
+	}
+	b.WriteString(fmt.Sprintf("  wait             = %t\n", wait))
+	b.WriteString(fmt.Sprintf("  timeout          = %d\n", int(timeout.Seconds())))
+
+	delimiter := heredocDelimiter(string(valuesYaml))
+	b.WriteString("\n  values = [\n")
+	b.WriteString(fmt.Sprintf("    <<-%s\n", delimiter))
+	for _, line := range strings.Split(strings.TrimSuffix(string(valuesYaml), "\n"), "\n") {
+		b.WriteString("    " + escapeTemplate(line) + "\n")
+	}
+	b.WriteString(fmt.Sprintf("    %s\n", delimiter))
+	b.WriteString("  ]\n")
+	b.WriteString("}\n")
+	return b.String(), nil
+}
+
+// terraformString returns s as a quoted Terraform string.
+func terraformString(s string) string {
+	return escapeTemplate(strconv.Quote(s))
+}
+
+// escapeTemplate escapes the template sequences of Terraform strings, so that
+// "${" and "%{" in values are kept as they are instead of being interpolated.
+func escapeTemplate(s string) string {
+	return strings.NewReplacer("${", "$${", "%{", "%%{").Replace(s)
+}
+
+// heredocDelimiter returns a heredoc delimiter that is not a line of s.
+func heredocDelimiter(s string) string {
+	delimiter := "EOT"
+	for i := 1; ; i++ {
+		conflict := false
+		for _, line := range strings.Split(s, "\n") {
+			if strings.TrimSpace(line) == delimiter {
+				conflict = true
+				break
+			}
+		}
+		if !conflict {
+			return delimiter
+		}
+		delimiter = fmt.Sprintf("EOT%d", i)
+	}
+}
@@ -0,0 +1,40 @@
+package install
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/rollout"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// diagnoseTimeout bounds the time spent collecting the logs and events of the
+// components that aren't ready.
+const diagnoseTimeout = 30 * time.Second
+
+// rolloutComponents returns the components the installation is waited for.
+func (c *Command) rolloutComponents(chart *chart.Chart, vals map[string]interface{}) ([]rollout.Component, error) {
+	manifests, err := c.renderManifests(chart, vals)
+	if err != nil {
+		return nil, err
+	}
+	return rollout.Components(manifests, rollout.Install)
+}
+
+// diagnose prints the logs and events of the pods of the components that
+// aren't ready.
+func (c *Command) diagnose(watcher *rollout.Watcher, statuses []rollout.Status) {
+	ctx, cancel := context.WithTimeout(c.Ctx, diagnoseTimeout)
+	defer cancel()
+	diagnostics, err := watcher.Diagnose(ctx, statuses)
+	if err != nil {
+		c.UI.Output("Unable to collect diagnostics: %s", err, terminal.WithErrorStyle())
+		return
+	}
+	if len(diagnostics) == 0 {
+		return
+	}
+	c.UI.Output("Components that are not ready", terminal.WithHeaderStyle())
+	rollout.PrintDiagnostics(c.UI, diagnostics)
+}
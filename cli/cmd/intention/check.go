@@ -0,0 +1,373 @@
+package intention
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/hashicorp/consul-k8s/cli/helm"
+	"github.com/posener/complete"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	flagNameSource               = "source"
+	flagNameDestination          = "destination"
+	flagNameSourceNamespace      = "source-namespace"
+	flagNameDestinationNamespace = "destination-namespace"
+
+	// wildcard matches any service or namespace in an intention.
+	wildcard = "*"
+
+	// exitCodeDenied is returned if the traffic is denied, like the exit code
+	// of `consul intention check`.
+	exitCodeDenied = 2
+)
+
+// CheckCommand evaluates whether traffic between two Kubernetes workloads is
+// allowed by intentions.
+type CheckCommand struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+	restConfig *rest.Config
+
+	// values are the Helm values of the installation. They are read from the
+	// release if not set, which lets tests replace them.
+	values map[string]interface{}
+
+	// openServer opens the connection to the server pod. It port forwards to
+	// the pod if it is not set, which lets tests replace it.
+	openServer consul.ServerOpener
+
+	set *flag.Sets
+
+	flagSource               string
+	flagDestination          string
+	flagSourceNamespace      string
+	flagDestinationNamespace string
+	flagNamespace            string
+	flagToken                string
+	flagCAFile               string
+	flagAPIProxy             bool
+
+	flagKubeConfig  string
+	flagKubeContext string
+
+	once sync.Once
+	help string
+}
+
+func (c *CheckCommand) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameSource,
+		Target: &c.flagSource,
+		Usage:  "The Kubernetes workload the traffic is sent from, e.g. deployment/web.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameDestination,
+		Target: &c.flagDestination,
+		Usage:  "The Kubernetes workload the traffic is sent to, e.g. service/api.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       flagNameSourceNamespace,
+		Target:     &c.flagSourceNamespace,
+		Default:    "default",
+		Usage:      "The Kubernetes namespace of the source.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       flagNameDestinationNamespace,
+		Target:     &c.flagDestinationNamespace,
+		Default:    "default",
+		Usage:      "The Kubernetes namespace of the destination.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameNamespace,
+		Aliases: []string{"n"},
+		Target:  &c.flagNamespace,
+		Usage: "Set the namespace of the Consul installation. " +
+			"If not set, the namespace of the installed Helm release is used.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameToken,
+		Target:  &c.flagToken,
+		Default: "",
+		Usage: fmt.Sprintf("Set the ACL token used to read the intentions. It needs intentions read permissions on the "+
+			"destination. If not set, the %s environment variable is used.", common.TokenEnvVar),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameCAFile,
+		Target:  &c.flagCAFile,
+		Default: "",
+		Usage: fmt.Sprintf("Set the path to the CA certificate of the Consul servers. If set, the servers are called "+
+			"over HTTPS on port %d instead of HTTP on port %d.", consul.HTTPSPort, consul.HTTPPort),
+		Completion: complete.PredictFiles("*"),
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameAPIProxy,
+		Target:  &c.flagAPIProxy,
+		Default: false,
+		Usage: fmt.Sprintf("Read from Consul through the API proxy the Helm chart deploys with apiProxy.enabled, with the "+
+			"Kubernetes credentials instead of a port forward to a server pod. -%s and -%s are not used.", flagNameToken, flagNameCAFile),
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run resolves the source and destination to Consul services and prints
+// whether intentions allow traffic between them.
+func (c *CheckCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("intention check")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+
+	if err := c.setup(); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	mapping, err := newNamespaceMapping(c.values)
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	r := resolver{ctx: c.Ctx, kubernetes: c.kubernetes, mapping: mapping}
+	source, err := r.resolve(c.flagSource, c.flagSourceNamespace)
+	if err != nil {
+		c.UI.Output("Error resolving the source: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+	destination, err := r.resolve(c.flagDestination, c.flagDestinationNamespace)
+	if err != nil {
+		c.UI.Output("Error resolving the destination: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+	c.UI.Output("Source:      %s", source.label())
+	c.UI.Output("Destination: %s", destination.label())
+
+	client, closeClient, err := c.openAnyServer()
+	if err != nil {
+		c.UI.Output("Error connecting to Consul: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+	defer closeClient()
+
+	v, err := c.evaluate(client, source, destination)
+	if err != nil {
+		c.UI.Output("Error evaluating intentions: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+
+	if v.intention == nil {
+		c.UI.Output("No intention matches, so the default ACL policy applies.")
+	} else {
+		c.UI.Output("Matching intention: %s => %s (precedence %d)",
+			newNode(v.intention.SourceName, v.intention.SourceNS, v.intention.SourcePartition, v.intention.SourcePeer).label(),
+			newNode(v.intention.DestinationName, v.intention.DestinationNS, v.intention.DestinationPartition, "").label(),
+			v.intention.Precedence)
+	}
+	switch {
+	case v.l7:
+		c.UI.Output("Allowed for requests that match the permissions of the intention.", terminal.WithWarningStyle())
+	case v.allowed:
+		c.UI.Output("Allowed", terminal.WithSuccessStyle())
+	default:
+		c.UI.Output("Denied", terminal.WithErrorStyle())
+		return exitCodeDenied
+	}
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *CheckCommand) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if c.flagSource == "" {
+		return fmt.Errorf("-%s must be set", flagNameSource)
+	}
+	if c.flagDestination == "" {
+		return fmt.Errorf("-%s must be set", flagNameDestination)
+	}
+	return nil
+}
+
+// setup creates the Kubernetes client and reads the Helm values of the
+// installation, and its namespace if -namespace is not set.
+func (c *CheckCommand) setup() error {
+	if c.flagToken == "" {
+		c.flagToken = os.Getenv(common.TokenEnvVar)
+	}
+
+	settings, err := common.InitKubernetes(c.flagKubeConfig, c.flagKubeContext, &c.restConfig, &c.kubernetes)
+	if err != nil {
+		return err
+	}
+
+	if c.values == nil {
+		uiLogger := func(msg string, args ...interface{}) {
+			c.Log.Debug(fmt.Sprintf(msg, args...))
+		}
+		name, namespace, err := common.CheckForInstallations(settings, uiLogger)
+		if err != nil {
+			return err
+		}
+		release, err := helm.FetchRelease(namespace, name, settings, uiLogger)
+		if err != nil {
+			return fmt.Errorf("error reading the Helm values of the installation: %s", err)
+		}
+		c.values = release.Config
+		if release.Chart != nil {
+			c.values = common.MergeMaps(release.Chart.Values, release.Config)
+		}
+		if c.flagNamespace == "" {
+			c.flagNamespace = namespace
+		}
+	}
+	return nil
+}
+
+// openAnyServer returns a client for the HTTP API of a running Consul server
+// and a function that closes the connection.
+func (c *CheckCommand) openAnyServer() (*consul.Client, func(), error) {
+	if c.flagAPIProxy {
+		return openAPIProxy(c.restConfig)
+	}
+	open := consul.ServerConfig{
+		KubeClient: c.kubernetes,
+		RestConfig: c.restConfig,
+		Token:      c.flagToken,
+		CAFile:     c.flagCAFile,
+	}.Opener(c.openServer)
+	return consul.OpenRunningServer(c.Ctx, c.kubernetes, c.flagNamespace, open)
+}
+
+// verdict is the result of evaluating the intentions between two services.
+type verdict struct {
+	// intention is the highest precedence intention that matches, or nil if
+	// none does and the default ACL policy applies.
+	intention *consul.Intention
+	allowed   bool
+	// l7 is true if the intention has permissions, so the traffic is allowed
+	// or denied per request.
+	l7 bool
+}
+
+// evaluate returns the verdict of the intentions for traffic from the source
+// to the destination. Consul returns the intentions of the destination ordered
+// by precedence, so the first one whose source matches decides.
+func (c *CheckCommand) evaluate(client *consul.Client, source, destination node) (verdict, error) {
+	intentions, err := client.IntentionMatch(c.Ctx, destination.Name, destination.Namespace, destination.Partition)
+	if err != nil {
+		return verdict{}, err
+	}
+	for i := range intentions {
+		ixn := intentions[i]
+		if !matchesSource(ixn, source) {
+			continue
+		}
+		if len(ixn.Permissions) > 0 {
+			return verdict{intention: &ixn, l7: true}, nil
+		}
+		return verdict{intention: &ixn, allowed: ixn.Action == actionAllow}, nil
+	}
+
+	allowed, err := client.IntentionCheck(c.Ctx, qualifiedName(source), qualifiedName(destination))
+	if err != nil {
+		return verdict{}, err
+	}
+	return verdict{allowed: allowed}, nil
+}
+
+// matchesSource returns true if the source of the intention is the service.
+func matchesSource(ixn consul.Intention, source node) bool {
+	src := newNode(ixn.SourceName, ixn.SourceNS, ixn.SourcePartition, ixn.SourcePeer)
+	if src.Peer != source.Peer || src.Partition != source.Partition {
+		return false
+	}
+	if src.Namespace != wildcard && src.Namespace != source.Namespace {
+		return false
+	}
+	return src.Name == wildcard || src.Name == source.Name
+}
+
+// qualifiedName returns the name of the service in the format of the intention
+// check endpoint, i.e. "namespace/name" if it is not in the default namespace.
+func qualifiedName(n node) string {
+	if n.Namespace == "" {
+		return n.Name
+	}
+	return n.Namespace + "/" + n.Name
+}
+
+// Help returns a description of the command and how it is used.
+func (c *CheckCommand) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s intention check -source <kind>/<name> -destination <kind>/<name> [flags]\n\n" +
+		"Resolves the source and destination Kubernetes workloads to the Consul services the connect injector\n" +
+		"registers for them, including their Consul namespace and partition, and prints whether intentions\n" +
+		"allow traffic between them and the intention that decides it. The kind is one of deployment,\n" +
+		"statefulset, daemonset, pod or service. The exit code is 0 if the traffic is allowed and 2 if it\n" +
+		"is denied.\n\n" +
+		"Examples:\n" +
+		"  $ consul-k8s intention check -source deployment/web -destination service/api\n" +
+		"  $ consul-k8s intention check -source pod/web-0 -source-namespace frontend -destination svc/api\n\n" +
+		c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *CheckCommand) Synopsis() string {
+	return "Check whether intentions allow traffic between two Kubernetes workloads."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *CheckCommand) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *CheckCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
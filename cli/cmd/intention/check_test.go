@@ -0,0 +1,178 @@
+package intention
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+// TestCheckValidateFlags tests the validate flags function.
+func TestCheckValidateFlags(t *testing.T) {
+	// The following cases should all error, if they fail to this test fails.
+	testCases := []struct {
+		description string
+		input       []string
+	}{
+		{
+			"Should disallow non-flag arguments.",
+			[]string{"-source", "deployment/web", "-destination", "service/api", "extra"},
+		},
+		{
+			"Should require a source.",
+			[]string{"-destination", "service/api"},
+		},
+		{
+			"Should require a destination.",
+			[]string{"-source", "deployment/web"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		c := getInitializedCheckCommand(t)
+		t.Run(testCase.description, func(t *testing.T) {
+			if err := c.validateFlags(testCase.input); err == nil {
+				t.Errorf("Test case should have failed.")
+			}
+		})
+	}
+}
+
+// TestCheckRun tests the exit code for allowed and denied traffic.
+func TestCheckRun(t *testing.T) {
+	cases := map[string]struct {
+		match   string
+		check   string
+		expCode int
+	}{
+		"allowed by intention": {
+			match:   `{"api": [{"SourceName": "web", "SourceNS": "k8s-frontend", "DestinationName": "api", "DestinationNS": "k8s-backend", "Action": "allow", "Precedence": 9}]}`,
+			expCode: 0,
+		},
+		"denied by intention": {
+			match:   `{"api": [{"SourceName": "*", "SourceNS": "*", "DestinationName": "api", "DestinationNS": "k8s-backend", "Action": "deny", "Precedence": 6}]}`,
+			expCode: exitCodeDenied,
+		},
+		"denied by default": {
+			match:   `{"api": [{"SourceName": "web", "SourceNS": "other", "DestinationName": "api", "DestinationNS": "k8s-backend", "Action": "allow", "Precedence": 9}]}`,
+			check:   `{"Allowed": false}`,
+			expCode: exitCodeDenied,
+		},
+		"allowed by default": {
+			match:   `{"api": []}`,
+			check:   `{"Allowed": true}`,
+			expCode: 0,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/connect/intentions/match":
+					require.Equal(t, "k8s-backend", r.URL.Query().Get("ns"))
+					w.Write([]byte(tc.match))
+				case "/v1/connect/intentions/check":
+					require.Equal(t, "k8s-frontend/web", r.URL.Query().Get("source"))
+					require.Equal(t, "k8s-backend/api", r.URL.Query().Get("destination"))
+					w.Write([]byte(tc.check))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer srv.Close()
+
+			c := getInitializedCheckCommand(t)
+			c.kubernetes = fake.NewSimpleClientset(
+				&corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "consul-server-0",
+						Namespace: "consul",
+						Labels:    map[string]string{"app": "consul", "chart": "consul-helm", "component": "server"},
+					},
+					Status: corev1.PodStatus{Phase: corev1.PodRunning},
+				},
+				&appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "frontend"},
+					Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{annotationService: "web"}},
+					}},
+				},
+				&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "backend"}},
+			)
+			c.restConfig = &rest.Config{}
+			c.values = map[string]interface{}{
+				"global": map[string]interface{}{"enableConsulNamespaces": true},
+				"connectInject": map[string]interface{}{"consulNamespaces": map[string]interface{}{
+					"mirroringK8S":       true,
+					"mirroringK8SPrefix": "k8s-",
+				}},
+			}
+			c.openServer = func(pod *corev1.Pod) (*consul.Client, func(), error) {
+				return &consul.Client{Addr: strings.TrimPrefix(srv.URL, "http://")}, func() {}, nil
+			}
+
+			code := c.Run([]string{
+				"-n", "consul",
+				"-source", "deployment/web", "-source-namespace", "frontend",
+				"-destination", "service/api", "-destination-namespace", "backend",
+			})
+			require.Equal(t, tc.expCode, code)
+		})
+	}
+}
+
+func TestMatchesSource(t *testing.T) {
+	source := node{Name: "web", Namespace: "team-a"}
+	cases := map[string]struct {
+		intention consul.Intention
+		exp       bool
+	}{
+		"exact":                    {consul.Intention{SourceName: "web", SourceNS: "team-a"}, true},
+		"wildcard name":            {consul.Intention{SourceName: "*", SourceNS: "team-a"}, true},
+		"wildcard namespace":       {consul.Intention{SourceName: "*", SourceNS: "*"}, true},
+		"other name":               {consul.Intention{SourceName: "api", SourceNS: "team-a"}, false},
+		"other namespace":          {consul.Intention{SourceName: "web", SourceNS: "default"}, false},
+		"peer":                     {consul.Intention{SourceName: "web", SourceNS: "team-a", SourcePeer: "cluster-2"}, false},
+		"default partition":        {consul.Intention{SourceName: "web", SourceNS: "team-a", SourcePartition: "default"}, true},
+		"other partition":          {consul.Intention{SourceName: "web", SourceNS: "team-a", SourcePartition: "team"}, false},
+		"wildcard in other peer":   {consul.Intention{SourceName: "*", SourceNS: "*", SourcePeer: "cluster-2"}, false},
+		"default namespace source": {consul.Intention{SourceName: "web"}, false},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.exp, matchesSource(c.intention, source))
+		})
+	}
+}
+
+func getInitializedCheckCommand(t *testing.T) *CheckCommand {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+	}
+
+	c := &CheckCommand{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
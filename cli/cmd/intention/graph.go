@@ -0,0 +1,310 @@
+// Package intention contains the commands that inspect service intentions.
+package intention
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/posener/complete"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	flagNameOutput    = "output"
+	flagNameNamespace = "namespace"
+	flagNameToken     = "token"
+	flagNameCAFile    = "ca-file"
+	flagNameAPIProxy  = "api-proxy"
+
+	outputDOT     = "dot"
+	outputMermaid = "mermaid"
+	outputJSON    = "json"
+
+	serviceIntentionsKind = "service-intentions"
+)
+
+// serviceIntentionsResource is the resource of the ServiceIntentions CRD.
+var serviceIntentionsResource = schema.GroupVersionResource{Group: "consul.hashicorp.com", Version: "v1alpha1", Resource: "serviceintentions"}
+
+// GraphCommand prints the service dependency graph described by intentions.
+type GraphCommand struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+	dynamic    dynamic.Interface
+	restConfig *rest.Config
+
+	// openServer opens the connection to the server pod. It port forwards to
+	// the pod if it is not set, which lets tests replace it.
+	openServer consul.ServerOpener
+
+	set *flag.Sets
+
+	flagOutput    string
+	flagNamespace string
+	flagToken     string
+	flagCAFile    string
+	flagAPIProxy  bool
+
+	flagKubeConfig  string
+	flagKubeContext string
+
+	once sync.Once
+	help string
+}
+
+func (c *GraphCommand) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.EnumSingleVar(&flag.EnumSingleVar{
+		Name:    flagNameOutput,
+		Aliases: []string{"o"},
+		Target:  &c.flagOutput,
+		Default: outputDOT,
+		Values:  []string{outputDOT, outputMermaid, outputJSON},
+		Usage:   "Set the format the graph is printed in.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameNamespace,
+		Aliases: []string{"n"},
+		Target:  &c.flagNamespace,
+		Usage: "Set the namespace of the Consul installation. " +
+			"If not set, the namespace of the installed Helm release is used.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameToken,
+		Target:  &c.flagToken,
+		Default: "",
+		Usage: fmt.Sprintf("Set the ACL token used to read the intentions. It needs read permissions on all "+
+			"services. If not set, the %s environment variable is used.", common.TokenEnvVar),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameCAFile,
+		Target:  &c.flagCAFile,
+		Default: "",
+		Usage: fmt.Sprintf("Set the path to the CA certificate of the Consul servers. If set, the servers are called "+
+			"over HTTPS on port %d instead of HTTP on port %d.", consul.HTTPSPort, consul.HTTPPort),
+		Completion: complete.PredictFiles("*"),
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameAPIProxy,
+		Target:  &c.flagAPIProxy,
+		Default: false,
+		Usage: fmt.Sprintf("Read from Consul through the API proxy the Helm chart deploys with apiProxy.enabled, with the "+
+			"Kubernetes credentials instead of a port forward to a server pod. -%s and -%s are not used.", flagNameToken, flagNameCAFile),
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run reads the intentions from Consul and the ServiceIntentions CRDs and
+// prints the graph they describe.
+func (c *GraphCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("intention graph")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+
+	if err := c.setup(); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	g, err := c.readGraph()
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	var out string
+	switch c.flagOutput {
+	case outputMermaid:
+		out = renderMermaid(g)
+	case outputJSON:
+		if out, err = renderJSON(g); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+	default:
+		out = renderDOT(g)
+	}
+	c.UI.Output("%s", strings.TrimSuffix(out, "\n"))
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *GraphCommand) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	return nil
+}
+
+// setup creates the Kubernetes clients and finds the namespace of the Consul
+// installation if -namespace is not set.
+func (c *GraphCommand) setup() error {
+	if c.flagToken == "" {
+		c.flagToken = os.Getenv(common.TokenEnvVar)
+	}
+
+	settings, err := common.InitKubernetes(c.flagKubeConfig, c.flagKubeContext, &c.restConfig, &c.kubernetes)
+	if err != nil {
+		return err
+	}
+	if c.dynamic == nil {
+		var err error
+		c.dynamic, err = dynamic.NewForConfig(c.restConfig)
+		if err != nil {
+			return fmt.Errorf("error initializing Kubernetes client:\n%v", err)
+		}
+	}
+
+	if c.flagNamespace == "" {
+		uiLogger := func(msg string, args ...interface{}) {
+			c.Log.Debug(fmt.Sprintf(msg, args...))
+		}
+		_, namespace, err := common.CheckForInstallations(settings, uiLogger)
+		if err != nil {
+			return err
+		}
+		c.flagNamespace = namespace
+	}
+	return nil
+}
+
+// readGraph reads the intentions from Consul and the CRDs and returns the graph
+// they describe.
+func (c *GraphCommand) readGraph() (graph, error) {
+	builder := newGraphBuilder()
+	if err := c.addConsulIntentions(builder); err != nil {
+		return graph{}, fmt.Errorf("error reading intentions from Consul: %s", err)
+	}
+	if err := c.addCRDIntentions(builder); err != nil {
+		return graph{}, fmt.Errorf("error reading ServiceIntentions resources: %s", err)
+	}
+	return builder.build(), nil
+}
+
+// addConsulIntentions adds the intentions of the default Consul namespace and
+// partition.
+func (c *GraphCommand) addConsulIntentions(builder *graphBuilder) error {
+	client, closeClient, err := c.openAnyServer()
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
+	entries, err := client.ConfigEntries(c.Ctx, serviceIntentionsKind)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		builder.addConfigEntry(entry)
+	}
+	return nil
+}
+
+// addCRDIntentions adds the intentions of the ServiceIntentions resources in
+// all Kubernetes namespaces.
+func (c *GraphCommand) addCRDIntentions(builder *graphBuilder) error {
+	crds, err := c.dynamic.Resource(serviceIntentionsResource).List(c.Ctx, metav1.ListOptions{})
+	if k8serrors.IsNotFound(err) {
+		// The CRD isn't installed.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, crd := range crds.Items {
+		builder.addCRD(crd)
+	}
+	return nil
+}
+
+// openAnyServer returns a client for the HTTP API of a running Consul server
+// and a function that closes the connection.
+func (c *GraphCommand) openAnyServer() (*consul.Client, func(), error) {
+	if c.flagAPIProxy {
+		return openAPIProxy(c.restConfig)
+	}
+	open := consul.ServerConfig{
+		KubeClient: c.kubernetes,
+		RestConfig: c.restConfig,
+		Token:      c.flagToken,
+		CAFile:     c.flagCAFile,
+	}.Opener(c.openServer)
+	return consul.OpenRunningServer(c.Ctx, c.kubernetes, c.flagNamespace, open)
+}
+
+// Help returns a description of the command and how it is used.
+func (c *GraphCommand) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s intention graph [flags]\n\n" +
+		"Reads the intentions of the default Consul namespace and partition, and the ServiceIntentions resources in\n" +
+		"all Kubernetes namespaces, and prints the services and the intentions between them as a DOT or Mermaid\n" +
+		"graph, or as JSON. Deny intentions are highlighted in red, and intentions that are only in a resource,\n" +
+		"e.g. because it failed to sync, are dashed. If Consul and a resource disagree, the live intention is shown.\n\n" +
+		"Examples:\n" +
+		"  $ consul-k8s intention graph | dot -Tsvg > intentions.svg\n" +
+		"  $ consul-k8s intention graph -o mermaid\n" +
+		"  $ consul-k8s intention graph -api-proxy\n\n" +
+		c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *GraphCommand) Synopsis() string {
+	return "Print the service dependency graph described by intentions."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *GraphCommand) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *GraphCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
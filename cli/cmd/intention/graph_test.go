@@ -0,0 +1,120 @@
+package intention
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+// TestValidateFlags tests the validate flags function.
+func TestValidateFlags(t *testing.T) {
+	// The following cases should all error, if they fail to this test fails.
+	testCases := []struct {
+		description string
+		input       []string
+	}{
+		{
+			"Should disallow non-flag arguments.",
+			[]string{"web"},
+		},
+		{
+			"Should disallow an unknown output format.",
+			[]string{"-output", "png"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		c := getInitializedCommand(t)
+		t.Run(testCase.description, func(t *testing.T) {
+			if err := c.validateFlags(testCase.input); err == nil {
+				t.Errorf("Test case should have failed.")
+			}
+		})
+	}
+}
+
+// TestRun tests that the intentions from Consul and the CRDs are read into a graph.
+func TestRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/config/service-intentions" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`[{"Kind": "service-intentions", "Name": "web", "Sources": [{"Name": "api", "Action": "allow"}]}]`))
+	}))
+	defer srv.Close()
+
+	crd := serviceIntentionsCRD("db", map[string]interface{}{"name": "web", "action": "deny"})
+	c := getInitializedCommand(t)
+	c.kubernetes = fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "consul-server-0",
+			Namespace: "consul",
+			Labels:    map[string]string{"app": "consul", "chart": "consul-helm", "component": "server"},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+	c.dynamic = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{serviceIntentionsResource: "ServiceIntentionsList"})
+	_, err := c.dynamic.Resource(serviceIntentionsResource).Namespace("default").Create(context.Background(), &crd, metav1.CreateOptions{})
+	require.NoError(t, err)
+	c.restConfig = &rest.Config{}
+	c.openServer = func(pod *corev1.Pod) (*consul.Client, func(), error) {
+		return &consul.Client{Addr: strings.TrimPrefix(srv.URL, "http://")}, func() {}, nil
+	}
+
+	require.Equal(t, 0, c.Run([]string{"-n", "consul", "-o", "mermaid"}))
+
+	g, err := c.readGraph()
+	require.NoError(t, err)
+	require.Equal(t, []edge{
+		{Source: node{Name: "web"}, Destination: node{Name: "db"}, Action: actionDeny, Origins: []string{originKubernetes}},
+		{Source: node{Name: "api"}, Destination: node{Name: "web"}, Action: actionAllow, Origins: []string{originConsul}},
+	}, g.Edges)
+}
+
+// TestRun_NoServers tests that the command fails if Consul can't be reached.
+func TestRun_NoServers(t *testing.T) {
+	c := getInitializedCommand(t)
+	c.kubernetes = fake.NewSimpleClientset()
+	c.dynamic = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{serviceIntentionsResource: "ServiceIntentionsList"})
+	c.restConfig = &rest.Config{}
+
+	require.Equal(t, 1, c.Run([]string{"-n", "consul"}))
+}
+
+func getInitializedCommand(t *testing.T) *GraphCommand {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+	}
+
+	c := &GraphCommand{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
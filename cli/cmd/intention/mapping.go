@@ -0,0 +1,238 @@
+package intention
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// annotationService is the pod annotation that overrides the name of the
+// Consul service, like it does for the connect injector.
+const annotationService = "consul.hashicorp.com/connect-service"
+
+// namespaceMapping maps Kubernetes namespaces to the Consul namespace and
+// partition that the connect injector registers services in. It is read from
+// the Helm values of the installation and follows the same rules as the
+// injector's -enable-namespaces, -consul-destination-namespace,
+// -enable-k8s-namespace-mirroring, -k8s-namespace-mirroring-prefix,
+// -k8s-namespace-mirroring-rules and -partition flags.
+type namespaceMapping struct {
+	enableNamespaces     bool
+	destinationNamespace string
+	enableMirroring      bool
+	mirroringPrefix      string
+	mirroringRules       []mirroringRule
+	partition            string
+}
+
+// mirroringRule is a connectInject.consulNamespaces.mirroringK8SRules entry.
+type mirroringRule struct {
+	Match   string `json:"match"`
+	Replace string `json:"replace"`
+
+	re *regexp.Regexp
+}
+
+// newNamespaceMapping returns the mapping of the installation with the Helm
+// values.
+func newNamespaceMapping(values map[string]interface{}) (namespaceMapping, error) {
+	m := namespaceMapping{
+		enableNamespaces:     lookupBool(values, "global", "enableConsulNamespaces"),
+		destinationNamespace: lookupString(values, "connectInject", "consulNamespaces", "consulDestinationNamespace"),
+		enableMirroring:      lookupBool(values, "connectInject", "consulNamespaces", "mirroringK8S"),
+		mirroringPrefix:      lookupString(values, "connectInject", "consulNamespaces", "mirroringK8SPrefix"),
+	}
+	if m.destinationNamespace == "" {
+		m.destinationNamespace = "default"
+	}
+	if lookupBool(values, "global", "adminPartitions", "enabled") {
+		m.partition = lookupString(values, "global", "adminPartitions", "name")
+	}
+
+	if rules := lookup(values, "connectInject", "consulNamespaces", "mirroringK8SRules"); rules != nil {
+		// Round trip the rules through JSON, which is how the chart passes them
+		// to the injector.
+		raw, err := json.Marshal(rules)
+		if err != nil {
+			return namespaceMapping{}, err
+		}
+		if err := json.Unmarshal(raw, &m.mirroringRules); err != nil {
+			return namespaceMapping{}, fmt.Errorf("unable to parse namespace mirroring rules: %s", err)
+		}
+		for i := range m.mirroringRules {
+			// Anchor the expression so that rules always match the whole namespace name.
+			re, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", m.mirroringRules[i].Match))
+			if err != nil {
+				return namespaceMapping{}, fmt.Errorf("namespace mirroring rule %d: %s", i, err)
+			}
+			m.mirroringRules[i].re = re
+		}
+	}
+	return m, nil
+}
+
+// consulNamespace returns the Consul namespace that services in the
+// Kubernetes namespace are registered in, or an empty string if namespaces
+// aren't enabled.
+func (m namespaceMapping) consulNamespace(k8sNamespace string) string {
+	if !m.enableNamespaces {
+		return ""
+	}
+	if !m.enableMirroring {
+		return m.destinationNamespace
+	}
+	for _, rule := range m.mirroringRules {
+		if rule.re.MatchString(k8sNamespace) {
+			return m.mirroringPrefix + rule.re.ReplaceAllString(k8sNamespace, rule.Replace)
+		}
+	}
+	return m.mirroringPrefix + k8sNamespace
+}
+
+// resolver resolves Kubernetes references, e.g. "deployment/web", to the
+// Consul services the connect injector registers for them.
+type resolver struct {
+	ctx        context.Context
+	kubernetes kubernetes.Interface
+	mapping    namespaceMapping
+}
+
+// resolve returns the Consul service of the reference in the Kubernetes
+// namespace. The reference is "<kind>/<name>", where kind is a deployment,
+// statefulset, daemonset, pod or service.
+func (r resolver) resolve(ref, k8sNamespace string) (node, error) {
+	parts := strings.SplitN(ref, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return node{}, fmt.Errorf("%q must be of the form <kind>/<name>, e.g. deployment/web", ref)
+	}
+	kind, name := strings.ToLower(parts[0]), parts[1]
+
+	var template metav1.ObjectMeta
+	switch kind {
+	case "service", "services", "svc":
+		return r.resolveService(name, k8sNamespace)
+	case "deployment", "deployments", "deploy":
+		d, err := r.kubernetes.AppsV1().Deployments(k8sNamespace).Get(r.ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return node{}, err
+		}
+		template = d.Spec.Template.ObjectMeta
+	case "statefulset", "statefulsets", "sts":
+		s, err := r.kubernetes.AppsV1().StatefulSets(k8sNamespace).Get(r.ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return node{}, err
+		}
+		template = s.Spec.Template.ObjectMeta
+	case "daemonset", "daemonsets", "ds":
+		d, err := r.kubernetes.AppsV1().DaemonSets(k8sNamespace).Get(r.ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return node{}, err
+		}
+		template = d.Spec.Template.ObjectMeta
+	case "pod", "pods", "po":
+		p, err := r.kubernetes.CoreV1().Pods(k8sNamespace).Get(r.ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return node{}, err
+		}
+		template = p.ObjectMeta
+	default:
+		return node{}, fmt.Errorf("unsupported kind %q: must be one of deployment, statefulset, daemonset, pod or service", parts[0])
+	}
+	return r.resolvePods(ref, template, k8sNamespace)
+}
+
+// resolvePods returns the service of the pods with the metadata. Like the
+// injector, the connect-service annotation takes precedence over the name of
+// the Kubernetes service that selects the pods.
+func (r resolver) resolvePods(ref string, pod metav1.ObjectMeta, k8sNamespace string) (node, error) {
+	if name := pod.Annotations[annotationService]; name != "" {
+		if strings.Contains(name, ",") {
+			return node{}, fmt.Errorf("%s has multiple services (%s): use service/<name> to choose one", ref, name)
+		}
+		return r.node(name, k8sNamespace), nil
+	}
+
+	services, err := r.kubernetes.CoreV1().Services(k8sNamespace).List(r.ctx, metav1.ListOptions{})
+	if err != nil {
+		return node{}, err
+	}
+	var selecting []string
+	for _, svc := range services.Items {
+		if len(svc.Spec.Selector) > 0 && labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			selecting = append(selecting, svc.Name)
+		}
+	}
+	switch len(selecting) {
+	case 0:
+		return node{}, fmt.Errorf("no service in namespace %q selects the pods of %s", k8sNamespace, ref)
+	case 1:
+		return r.node(selecting[0], k8sNamespace), nil
+	default:
+		return node{}, fmt.Errorf("%s is selected by multiple services (%s): use service/<name> to choose one", ref, strings.Join(selecting, ", "))
+	}
+}
+
+// resolveService returns the service of the Kubernetes service. Its name is
+// overridden by the connect-service annotation of the pods it selects.
+func (r resolver) resolveService(name, k8sNamespace string) (node, error) {
+	svc, err := r.kubernetes.CoreV1().Services(k8sNamespace).Get(r.ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return node{}, err
+	}
+	if len(svc.Spec.Selector) > 0 {
+		pods, err := r.kubernetes.CoreV1().Pods(k8sNamespace).List(r.ctx, metav1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(svc.Spec.Selector).String(),
+		})
+		if err != nil {
+			return node{}, err
+		}
+		if override := serviceNameOverride(pods.Items); override != "" {
+			return r.node(override, k8sNamespace), nil
+		}
+	}
+	return r.node(svc.Name, k8sNamespace), nil
+}
+
+// serviceNameOverride returns the service name of the connect-service
+// annotation of the pods. It is ignored for pods with multiple services.
+func serviceNameOverride(pods []corev1.Pod) string {
+	for _, pod := range pods {
+		if name := pod.Annotations[annotationService]; name != "" && !strings.Contains(name, ",") {
+			return name
+		}
+	}
+	return ""
+}
+
+func (r resolver) node(name, k8sNamespace string) node {
+	return newNode(name, r.mapping.consulNamespace(k8sNamespace), r.mapping.partition, "")
+}
+
+func lookup(values map[string]interface{}, path ...string) interface{} {
+	var v interface{} = values
+	for _, key := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+func lookupString(values map[string]interface{}, path ...string) string {
+	s, _ := lookup(values, path...).(string)
+	return s
+}
+
+func lookupBool(values map[string]interface{}, path ...string) bool {
+	b, _ := lookup(values, path...).(bool)
+	return b
+}
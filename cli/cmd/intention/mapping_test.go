@@ -0,0 +1,180 @@
+package intention
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNamespaceMapping(t *testing.T) {
+	cases := map[string]struct {
+		values       map[string]interface{}
+		expNamespace string
+		expPartition string
+	}{
+		"namespaces disabled": {
+			values:       map[string]interface{}{},
+			expNamespace: "",
+		},
+		"destination namespace": {
+			values: map[string]interface{}{
+				"global":        map[string]interface{}{"enableConsulNamespaces": true},
+				"connectInject": map[string]interface{}{"consulNamespaces": map[string]interface{}{"consulDestinationNamespace": "k8s"}},
+			},
+			expNamespace: "k8s",
+		},
+		"destination namespace defaults to default": {
+			values: map[string]interface{}{
+				"global": map[string]interface{}{"enableConsulNamespaces": true},
+			},
+			expNamespace: "default",
+		},
+		"mirroring with prefix": {
+			values: map[string]interface{}{
+				"global": map[string]interface{}{"enableConsulNamespaces": true},
+				"connectInject": map[string]interface{}{"consulNamespaces": map[string]interface{}{
+					"mirroringK8S":       true,
+					"mirroringK8SPrefix": "k8s-",
+				}},
+			},
+			expNamespace: "k8s-team-a",
+		},
+		"mirroring rules": {
+			values: map[string]interface{}{
+				"global": map[string]interface{}{"enableConsulNamespaces": true},
+				"connectInject": map[string]interface{}{"consulNamespaces": map[string]interface{}{
+					"mirroringK8S": true,
+					"mirroringK8SRules": []interface{}{
+						map[string]interface{}{"match": "team", "replace": "unused"},
+						map[string]interface{}{"match": "team-(.*)", "replace": "$1"},
+					},
+				}},
+			},
+			expNamespace: "a",
+		},
+		"partition": {
+			values: map[string]interface{}{
+				"global": map[string]interface{}{
+					"enableConsulNamespaces": true,
+					"adminPartitions":        map[string]interface{}{"enabled": true, "name": "team"},
+				},
+			},
+			expNamespace: "default",
+			expPartition: "team",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			m, err := newNamespaceMapping(c.values)
+			require.NoError(t, err)
+			require.Equal(t, c.expNamespace, m.consulNamespace("team-a"))
+			require.Equal(t, c.expPartition, m.partition)
+		})
+	}
+}
+
+func TestNamespaceMapping_InvalidRule(t *testing.T) {
+	_, err := newNamespaceMapping(map[string]interface{}{
+		"connectInject": map[string]interface{}{"consulNamespaces": map[string]interface{}{
+			"mirroringK8SRules": []interface{}{map[string]interface{}{"match": "(", "replace": "x"}},
+		}},
+	})
+	require.EqualError(t, err, "namespace mirroring rule 0: error parsing regexp: missing closing ): `^(?:()$`")
+}
+
+func TestResolve(t *testing.T) {
+	template := func(labels, annotations map[string]string) corev1.PodTemplateSpec {
+		return corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: labels, Annotations: annotations}}
+	}
+	service := func(name string, selector map[string]string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a"},
+			Spec:       corev1.ServiceSpec{Selector: selector},
+		}
+	}
+	k8s := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-a"},
+			Spec:       appsv1.DeploymentSpec{Template: template(map[string]string{"app": "web"}, nil)},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "team-a"},
+			Spec:       appsv1.StatefulSetSpec{Template: template(map[string]string{"app": "db"}, map[string]string{annotationService: "postgres"})},
+		},
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "multiport", Namespace: "team-a"},
+			Spec:       appsv1.DaemonSetSpec{Template: template(map[string]string{"app": "multiport"}, map[string]string{annotationService: "multiport,multiport-admin"})},
+		},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "shared-0", Namespace: "team-a", Labels: map[string]string{"app": "shared"}}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "team-a", Labels: map[string]string{"app": "db"}, Annotations: map[string]string{annotationService: "postgres"}}},
+		service("web", map[string]string{"app": "web"}),
+		service("db", map[string]string{"app": "db"}),
+		service("shared-a", map[string]string{"app": "shared"}),
+		service("shared-b", map[string]string{"app": "shared"}),
+		service("external", nil),
+	)
+	r := resolver{
+		ctx:        context.Background(),
+		kubernetes: k8s,
+		mapping:    namespaceMapping{enableNamespaces: true, enableMirroring: true, mirroringPrefix: "k8s-"},
+	}
+
+	cases := map[string]struct {
+		ref    string
+		exp    node
+		expErr string
+	}{
+		"deployment selected by a service": {
+			ref: "deployment/web",
+			exp: node{Name: "web", Namespace: "k8s-team-a"},
+		},
+		"statefulset with the service annotation": {
+			ref: "sts/db",
+			exp: node{Name: "postgres", Namespace: "k8s-team-a"},
+		},
+		"service of pods with the service annotation": {
+			ref: "service/db",
+			exp: node{Name: "postgres", Namespace: "k8s-team-a"},
+		},
+		"service without a selector": {
+			ref: "svc/external",
+			exp: node{Name: "external", Namespace: "k8s-team-a"},
+		},
+		"multi-port daemonset": {
+			ref:    "daemonset/multiport",
+			expErr: "daemonset/multiport has multiple services (multiport,multiport-admin): use service/<name> to choose one",
+		},
+		"pod selected by multiple services": {
+			ref:    "pod/shared-0",
+			expErr: "pod/shared-0 is selected by multiple services (shared-a, shared-b): use service/<name> to choose one",
+		},
+		"unsupported kind": {
+			ref:    "job/web",
+			expErr: `unsupported kind "job": must be one of deployment, statefulset, daemonset, pod or service`,
+		},
+		"invalid reference": {
+			ref:    "web",
+			expErr: `"web" must be of the form <kind>/<name>, e.g. deployment/web`,
+		},
+		"not found": {
+			ref:    "deployment/missing",
+			expErr: `deployments.apps "missing" not found`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			n, err := r.resolve(c.ref, "team-a")
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, n)
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package intention
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	actionAllow = "allow"
+	actionDeny  = "deny"
+	// actionL7 is the action of intentions whose permissions decide per request.
+	actionL7 = "l7"
+
+	originConsul     = "consul"
+	originKubernetes = "kubernetes"
+)
+
+// node is a service in the graph.
+type node struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Partition string `json:"partition,omitempty"`
+	Peer      string `json:"peer,omitempty"`
+}
+
+// newNode returns the node of the service, leaving out the default namespace
+// and partition.
+func newNode(name, namespace, partition, peer string) node {
+	if namespace == "default" {
+		namespace = ""
+	}
+	if partition == "default" {
+		partition = ""
+	}
+	return node{Name: name, Namespace: namespace, Partition: partition, Peer: peer}
+}
+
+// label returns the name of the service with the namespace, partition and peer
+// it is in if they are not the default ones, e.g. "api (ns: team-a)".
+func (n node) label() string {
+	var qualifiers []string
+	if n.Namespace != "" {
+		qualifiers = append(qualifiers, "ns: "+n.Namespace)
+	}
+	if n.Partition != "" {
+		qualifiers = append(qualifiers, "partition: "+n.Partition)
+	}
+	if n.Peer != "" {
+		qualifiers = append(qualifiers, "peer: "+n.Peer)
+	}
+	if len(qualifiers) == 0 {
+		return n.Name
+	}
+	return fmt.Sprintf("%s (%s)", n.Name, strings.Join(qualifiers, ", "))
+}
+
+// edge is an intention from a source to a destination service.
+type edge struct {
+	Source      node   `json:"source"`
+	Destination node   `json:"destination"`
+	Action      string `json:"action"`
+	// Origins are where the intention was read from: "consul" for the live
+	// intentions and "kubernetes" for the ServiceIntentions CRDs.
+	Origins []string `json:"origins"`
+}
+
+// graph is the service dependency graph that intentions describe.
+type graph struct {
+	Nodes []node `json:"nodes"`
+	Edges []edge `json:"edges"`
+}
+
+// graphBuilder merges the intentions from Consul and the CRDs into a graph.
+// If both have an intention for the same pair of services, the action of the
+// live intention is used.
+type graphBuilder struct {
+	edges map[[2]string]*edge
+}
+
+func newGraphBuilder() *graphBuilder {
+	return &graphBuilder{edges: make(map[[2]string]*edge)}
+}
+
+// add adds an intention to the graph.
+func (b *graphBuilder) add(source, destination node, action, origin string) {
+	key := [2]string{source.label(), destination.label()}
+	e, ok := b.edges[key]
+	if !ok {
+		e = &edge{Source: source, Destination: destination, Action: action}
+		b.edges[key] = e
+	} else if origin == originConsul {
+		e.Action = action
+	}
+	for _, o := range e.Origins {
+		if o == origin {
+			return
+		}
+	}
+	e.Origins = append(e.Origins, origin)
+	sort.Strings(e.Origins)
+}
+
+// addConfigEntry adds the intentions of a service-intentions config entry.
+func (b *graphBuilder) addConfigEntry(entry consul.ConfigEntry) {
+	namespace, _ := entry["Namespace"].(string)
+	partition, _ := entry["Partition"].(string)
+	destination := newNode(entry.Name(), namespace, partition, "")
+	sources, _ := entry["Sources"].([]interface{})
+	for _, s := range sources {
+		src, _ := s.(map[string]interface{})
+		name, _ := src["Name"].(string)
+		namespace, _ := src["Namespace"].(string)
+		partition, _ := src["Partition"].(string)
+		peer, _ := src["Peer"].(string)
+		act, _ := src["Action"].(string)
+		permissions, _ := src["Permissions"].([]interface{})
+		b.add(newNode(name, namespace, partition, peer), destination, sourceAction(act, len(permissions) > 0), originConsul)
+	}
+}
+
+// addCRD adds the intentions of a ServiceIntentions resource.
+func (b *graphBuilder) addCRD(crd unstructured.Unstructured) {
+	name, _, _ := unstructured.NestedString(crd.Object, "spec", "destination", "name")
+	namespace, _, _ := unstructured.NestedString(crd.Object, "spec", "destination", "namespace")
+	destination := newNode(name, namespace, "", "")
+	sources, _, _ := unstructured.NestedSlice(crd.Object, "spec", "sources")
+	for _, s := range sources {
+		src, _ := s.(map[string]interface{})
+		name, _, _ := unstructured.NestedString(src, "name")
+		namespace, _, _ := unstructured.NestedString(src, "namespace")
+		partition, _, _ := unstructured.NestedString(src, "partition")
+		peer, _, _ := unstructured.NestedString(src, "peer")
+		act, _, _ := unstructured.NestedString(src, "action")
+		permissions, _, _ := unstructured.NestedSlice(src, "permissions")
+		b.add(newNode(name, namespace, partition, peer), destination, sourceAction(act, len(permissions) > 0), originKubernetes)
+	}
+}
+
+// sourceAction returns the action of an intention source.
+func sourceAction(action string, hasPermissions bool) string {
+	if hasPermissions {
+		return actionL7
+	}
+	if strings.EqualFold(action, actionDeny) {
+		return actionDeny
+	}
+	return actionAllow
+}
+
+// build returns the graph with the nodes sorted by label and the edges sorted
+// by destination and source.
+func (b *graphBuilder) build() graph {
+	g := graph{Nodes: []node{}, Edges: []edge{}}
+	nodes := make(map[string]node)
+	for _, e := range b.edges {
+		g.Edges = append(g.Edges, *e)
+		nodes[e.Source.label()] = e.Source
+		nodes[e.Destination.label()] = e.Destination
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		di, dj := g.Edges[i].Destination.label(), g.Edges[j].Destination.label()
+		if di != dj {
+			return di < dj
+		}
+		return g.Edges[i].Source.label() < g.Edges[j].Source.label()
+	})
+	for _, n := range nodes {
+		g.Nodes = append(g.Nodes, n)
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].label() < g.Nodes[j].label() })
+	return g
+}
+
+// kubernetesOnly returns true if the intention is only in a CRD, e.g. because
+// the CRD failed to sync.
+func (e edge) kubernetesOnly() bool {
+	return len(e.Origins) == 1 && e.Origins[0] == originKubernetes
+}
+
+// edgeLabel returns the label of the edge in DOT and Mermaid graphs.
+func (e edge) edgeLabel() string {
+	if e.kubernetesOnly() {
+		return e.Action + " (not in Consul)"
+	}
+	return e.Action
+}
+
+// renderDOT renders the graph in the Graphviz DOT language. Deny edges are red
+// and intentions that are only in a CRD are dashed.
+func renderDOT(g graph) string {
+	var sb strings.Builder
+	sb.WriteString("digraph intentions {\n")
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [shape=box];\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&sb, "  %s;\n", dotQuote(n.label()))
+	}
+	for _, e := range g.Edges {
+		attrs := []string{"label=" + dotQuote(e.edgeLabel())}
+		if e.Action == actionDeny {
+			attrs = append(attrs, `color="red"`, `fontcolor="red"`, "penwidth=2")
+		}
+		if e.kubernetesOnly() {
+			attrs = append(attrs, `style="dashed"`)
+		}
+		fmt.Fprintf(&sb, "  %s -> %s [%s];\n", dotQuote(e.Source.label()), dotQuote(e.Destination.label()), strings.Join(attrs, ", "))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// renderMermaid renders the graph as a Mermaid flowchart. Deny edges are red
+// and intentions that are only in a CRD are dotted.
+func renderMermaid(g graph) string {
+	ids := make(map[string]string)
+	var sb strings.Builder
+	sb.WriteString("flowchart LR\n")
+	for i, n := range g.Nodes {
+		ids[n.label()] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(&sb, "  n%d[%s]\n", i, mermaidQuote(n.label()))
+	}
+	var denied []string
+	for i, e := range g.Edges {
+		arrow := "-->"
+		if e.kubernetesOnly() {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&sb, "  %s %s|%s| %s\n", ids[e.Source.label()], arrow, mermaidQuote(e.edgeLabel()), ids[e.Destination.label()])
+		if e.Action == actionDeny {
+			denied = append(denied, fmt.Sprint(i))
+		}
+	}
+	if len(denied) > 0 {
+		fmt.Fprintf(&sb, "  linkStyle %s stroke:red,stroke-width:2px,color:red\n", strings.Join(denied, ","))
+	}
+	return sb.String()
+}
+
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
+
+// renderJSON renders the graph as JSON.
+func renderJSON(g graph) (string, error) {
+	out, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out) + "\n", nil
+}
@@ -0,0 +1,103 @@
+package intention
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGraphBuilder(t *testing.T) {
+	b := newGraphBuilder()
+	b.addConfigEntry(consul.ConfigEntry{
+		"Kind":      "service-intentions",
+		"Name":      "web",
+		"Namespace": "default",
+		"Sources": []interface{}{
+			map[string]interface{}{"Name": "api", "Namespace": "default", "Action": "allow"},
+			map[string]interface{}{"Name": "db", "Namespace": "team-a", "Action": "deny"},
+			map[string]interface{}{"Name": "admin", "Permissions": []interface{}{map[string]interface{}{"Action": "allow"}}},
+			map[string]interface{}{"Name": "frontend", "Peer": "cluster-2", "Action": "allow"},
+		},
+	})
+	b.addCRD(serviceIntentionsCRD("web", map[string]interface{}{"name": "api", "action": "deny"}))
+	b.addCRD(serviceIntentionsCRD("db", map[string]interface{}{"name": "*", "action": "deny"}))
+
+	g := b.build()
+	require.Equal(t, []node{
+		{Name: "*"},
+		{Name: "admin"},
+		{Name: "api"},
+		{Name: "db"},
+		{Name: "db", Namespace: "team-a"},
+		{Name: "frontend", Peer: "cluster-2"},
+		{Name: "web"},
+	}, g.Nodes)
+	require.Equal(t, []edge{
+		{Source: node{Name: "*"}, Destination: node{Name: "db"}, Action: actionDeny, Origins: []string{originKubernetes}},
+		{Source: node{Name: "admin"}, Destination: node{Name: "web"}, Action: actionL7, Origins: []string{originConsul}},
+		// The live intention wins over the CRD.
+		{Source: node{Name: "api"}, Destination: node{Name: "web"}, Action: actionAllow, Origins: []string{originConsul, originKubernetes}},
+		{Source: node{Name: "db", Namespace: "team-a"}, Destination: node{Name: "web"}, Action: actionDeny, Origins: []string{originConsul}},
+		{Source: node{Name: "frontend", Peer: "cluster-2"}, Destination: node{Name: "web"}, Action: actionAllow, Origins: []string{originConsul}},
+	}, g.Edges)
+}
+
+func TestRender(t *testing.T) {
+	g := graph{
+		Nodes: []node{{Name: "*"}, {Name: "api", Namespace: "team-a"}, {Name: "web"}},
+		Edges: []edge{
+			{Source: node{Name: "*"}, Destination: node{Name: "web"}, Action: actionDeny, Origins: []string{originConsul}},
+			{Source: node{Name: "api", Namespace: "team-a"}, Destination: node{Name: "web"}, Action: actionAllow, Origins: []string{originKubernetes}},
+		},
+	}
+
+	require.Equal(t, `digraph intentions {
+  rankdir=LR;
+  node [shape=box];
+  "*";
+  "api (ns: team-a)";
+  "web";
+  "*" -> "web" [label="deny", color="red", fontcolor="red", penwidth=2];
+  "api (ns: team-a)" -> "web" [label="allow (not in Consul)", style="dashed"];
+}
+`, renderDOT(g))
+
+	require.Equal(t, `flowchart LR
+  n0["*"]
+  n1["api (ns: team-a)"]
+  n2["web"]
+  n0 -->|"deny"| n2
+  n1 -.->|"allow (not in Consul)"| n2
+  linkStyle 0 stroke:red,stroke-width:2px,color:red
+`, renderMermaid(g))
+
+	out, err := renderJSON(graph{Nodes: []node{{Name: "api"}, {Name: "web"}}, Edges: []edge{
+		{Source: node{Name: "api"}, Destination: node{Name: "web"}, Action: actionAllow, Origins: []string{originConsul}},
+	}})
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+  "nodes": [{"name": "api"}, {"name": "web"}],
+  "edges": [{"source": {"name": "api"}, "destination": {"name": "web"}, "action": "allow", "origins": ["consul"]}]
+}`, out)
+}
+
+func TestNodeLabel(t *testing.T) {
+	require.Equal(t, "web", newNode("web", "default", "default", "").label())
+	require.Equal(t, `web (ns: team-a, partition: ap1, peer: cluster-2)`, newNode("web", "team-a", "ap1", "cluster-2").label())
+	require.Equal(t, `"a\"b"`, dotQuote(`a"b`))
+	require.Equal(t, `"a#quot;b"`, mermaidQuote(`a"b`))
+}
+
+func serviceIntentionsCRD(destination string, sources ...interface{}) unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "consul.hashicorp.com/v1alpha1",
+		"kind":       "ServiceIntentions",
+		"metadata":   map[string]interface{}{"name": destination, "namespace": "default"},
+		"spec": map[string]interface{}{
+			"destination": map[string]interface{}{"name": destination},
+			"sources":     sources,
+		},
+	}}
+}
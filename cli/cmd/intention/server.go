@@ -0,0 +1,14 @@
+package intention
+
+import (
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"k8s.io/client-go/rest"
+)
+
+// openAPIProxy returns a client for the HTTP API of the servers through the
+// API proxy of the chart, and a function that does nothing since there is no
+// port forward to close.
+func openAPIProxy(config *rest.Config) (*consul.Client, func(), error) {
+	client, err := consul.OpenAPIProxy(config)
+	return client, func() {}, err
+}
@@ -0,0 +1,221 @@
+package maintenance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/posener/complete"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// maintenanceModeLabel is the namespace label that pauses the deregistration of
+	// service instances in the namespace by the endpoints controller.
+	maintenanceModeLabel = "consul.hashicorp.com/maintenance-mode"
+
+	flagNameNamespace = "namespace"
+
+	flagNameDisable = "disable"
+	defaultDisable  = false
+)
+
+type Command struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+
+	set *flag.Sets
+
+	flagNamespaces []string
+	flagDisable    bool
+
+	flagKubeConfig  string
+	flagKubeContext string
+
+	once sync.Once
+	help string
+}
+
+func (c *Command) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringSliceVar(&flag.StringSliceVar{
+		Name:    flagNameNamespace,
+		Aliases: []string{"n"},
+		Target:  &c.flagNamespaces,
+		Usage: "Set a Kubernetes namespace to put into maintenance mode. Can be specified multiple times. " +
+			"If no namespace is set, the namespaces in maintenance mode are listed.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameDisable,
+		Target:  &c.flagDisable,
+		Default: defaultDisable,
+		Usage:   "Take the namespaces out of maintenance mode instead.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run puts namespaces into or takes them out of maintenance mode, or lists the
+// namespaces in maintenance mode.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("maintenance")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+
+	if c.kubernetes == nil {
+		var restConfig *rest.Config
+		if _, err := common.InitKubernetes(c.flagKubeConfig, c.flagKubeContext, &restConfig, &c.kubernetes); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+	}
+
+	if len(c.flagNamespaces) == 0 {
+		return c.listNamespaces()
+	}
+
+	for _, namespace := range c.flagNamespaces {
+		if err := c.setMaintenanceMode(namespace, !c.flagDisable); err != nil {
+			c.UI.Output("Error updating namespace %q: %v", namespace, err, terminal.WithErrorStyle())
+			return 1
+		}
+		if c.flagDisable {
+			c.UI.Output("Namespace %q taken out of maintenance mode.", namespace, terminal.WithSuccessStyle())
+		} else {
+			c.UI.Output("Namespace %q put into maintenance mode.", namespace, terminal.WithSuccessStyle())
+		}
+	}
+	if !c.flagDisable {
+		c.UI.Output("Service instances in these namespaces will not be deregistered from Consul until maintenance mode is disabled "+
+			"with the command `consul-k8s maintenance -disable`.", terminal.WithInfoStyle())
+	}
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *Command) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if c.flagDisable && len(c.flagNamespaces) == 0 {
+		return fmt.Errorf("-%s must be set with -%s", flagNameNamespace, flagNameDisable)
+	}
+	return nil
+}
+
+// setMaintenanceMode adds the maintenance mode label to the namespace or removes it.
+func (c *Command) setMaintenanceMode(namespace string, enabled bool) error {
+	var value interface{}
+	if enabled {
+		value = "true"
+	}
+	// Setting a label to null in a merge patch removes it.
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{maintenanceModeLabel: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.kubernetes.CoreV1().Namespaces().Patch(c.Ctx, namespace, types.MergePatchType, patch, metav1.PatchOptions{})
+	if k8serrors.IsNotFound(err) {
+		return errors.New("namespace does not exist")
+	}
+	return err
+}
+
+// listNamespaces prints the namespaces that are in maintenance mode.
+func (c *Command) listNamespaces() int {
+	namespaces, err := c.kubernetes.CoreV1().Namespaces().List(c.Ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=true", maintenanceModeLabel),
+	})
+	if err != nil {
+		c.UI.Output("Error listing namespaces: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+	if len(namespaces.Items) == 0 {
+		c.UI.Output("No namespaces are in maintenance mode.", terminal.WithInfoStyle())
+		return 0
+	}
+
+	var names []string
+	for _, namespace := range namespaces.Items {
+		names = append(names, namespace.Name)
+	}
+	sort.Strings(names)
+
+	c.UI.Output("Namespaces in maintenance mode", terminal.WithHeaderStyle())
+	for _, name := range names {
+		c.UI.Output(name)
+	}
+	return 0
+}
+
+// Help returns a description of the command and how it is used.
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s maintenance [flags]\n\n" +
+		"Namespaces in maintenance mode keep the Consul service instances of pods that are removed,\n" +
+		"e.g. by evictions during node maintenance, until maintenance mode is disabled. New pods\n" +
+		"are still registered.\n\n" + c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *Command) Synopsis() string {
+	return "Pause the deregistration of services in namespaces during maintenance."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *Command) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *Command) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
@@ -0,0 +1,232 @@
+// Package operator contains the command that runs the consul-k8s operator.
+package operator
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	consulChart "github.com/hashicorp/consul-k8s/charts"
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/helm"
+	"github.com/hashicorp/consul-k8s/cli/operator"
+	"github.com/posener/complete"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	flagNameWatchNamespace = "watch-namespace"
+
+	flagNameResyncPeriod = "resync-period"
+	defaultResyncPeriod  = 5 * time.Minute
+
+	flagNameSkipCRD = "skip-crd"
+	defaultSkipCRD  = false
+)
+
+// Command runs the operator, which installs, upgrades and uninstalls Consul
+// according to ConsulCluster custom resources.
+type Command struct {
+	*common.BaseCommand
+
+	dynamic    dynamic.Interface
+	restConfig *rest.Config
+
+	set *flag.Sets
+
+	flagWatchNamespace string
+	flagResyncPeriod   time.Duration
+	flagSkipCRD        bool
+
+	flagKubeConfig  string
+	flagKubeContext string
+
+	once sync.Once
+	help string
+}
+
+func (c *Command) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:       flagNameWatchNamespace,
+		Target:     &c.flagWatchNamespace,
+		Default:    "",
+		Usage:      "Set the namespace of the ConsulCluster resources to watch. If not set, all namespaces are watched.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.DurationVar(&flag.DurationVar{
+		Name:    flagNameResyncPeriod,
+		Target:  &c.flagResyncPeriod,
+		Default: defaultResyncPeriod,
+		Usage: "Set how often every ConsulCluster is reconciled even if it didn't change, which reverts " +
+			"changes made to the Consul installation without the operator.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameSkipCRD,
+		Target:  &c.flagSkipCRD,
+		Default: defaultSkipCRD,
+		Usage:   "Don't create or update the ConsulCluster CRD on startup, e.g. when it is managed by a GitOps tool.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run runs the operator until the command is interrupted.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("operator")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+
+	// helmCLI.New() will create a settings object which is used by the Helm Go SDK calls. In a pod, it
+	// uses the service account of the pod.
+	settings := helmCLI.New()
+	if c.flagKubeConfig != "" {
+		settings.KubeConfig = c.flagKubeConfig
+	}
+	if c.flagKubeContext != "" {
+		settings.KubeContext = c.flagKubeContext
+	}
+
+	if c.dynamic == nil {
+		var err error
+		if c.restConfig == nil {
+			if c.restConfig, err = settings.RESTClientGetter().ToRESTConfig(); err != nil {
+				c.UI.Output("Error retrieving Kubernetes authentication:\n%v", err, terminal.WithErrorStyle())
+				return 1
+			}
+		}
+		if c.dynamic, err = dynamic.NewForConfig(c.restConfig); err != nil {
+			c.UI.Output("Error initializing Kubernetes client:\n%v", err, terminal.WithErrorStyle())
+			return 1
+		}
+	}
+
+	chart, err := helm.LoadChart(consulChart.ConsulHelmChart, common.TopLevelChartDirName)
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	if !c.flagSkipCRD {
+		if err := operator.ApplyCRD(c.Ctx, c.dynamic); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+		c.UI.Output("Applied the ConsulCluster CRD", terminal.WithSuccessStyle())
+	}
+
+	namespaces := "all namespaces"
+	if c.flagWatchNamespace != "" {
+		namespaces = fmt.Sprintf("namespace %s", c.flagWatchNamespace)
+	}
+	c.UI.Output("Watching ConsulClusters in %s with chart version %s", namespaces, chart.Metadata.Version, terminal.WithHeaderStyle())
+
+	controller := &operator.Controller{
+		Reconciler: &operator.Reconciler{
+			Dynamic: c.dynamic,
+			Releaser: &operator.HelmReleaser{
+				Settings: settings,
+				Log: func(msg string, args ...interface{}) {
+					c.Log.Debug(fmt.Sprintf(msg, args...))
+				},
+			},
+			Chart: chart,
+			Log:   c.Log,
+		},
+		Namespace:    c.flagWatchNamespace,
+		ResyncPeriod: c.flagResyncPeriod,
+		Log:          c.Log,
+	}
+	if err := controller.Run(c.Ctx); err != nil && !errors.Is(err, c.Ctx.Err()) {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *Command) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if c.flagResyncPeriod <= 0 {
+		return fmt.Errorf("-%s must be greater than zero", flagNameResyncPeriod)
+	}
+	return nil
+}
+
+// Help returns a description of the command and how it is used.
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s operator [flags]\n\n" +
+		"The operator installs Consul in the namespace of a ConsulCluster custom resource with the\n" +
+		"Helm values and preset of its spec, upgrades the installation when the spec or the chart\n" +
+		"of the operator change, and uninstalls it when the ConsulCluster is deleted. This lets\n" +
+		"GitOps tools manage the Consul installation by applying a ConsulCluster. Like with\n" +
+		"consul-k8s install, only one Consul installation is allowed in the cluster.\n\n" +
+		"The operator is meant to run in a pod of the cluster whose service account may manage\n" +
+		"all the resources of the Consul Helm chart.\n\n" +
+		"Example ConsulCluster:\n\n" +
+		"  apiVersion: consul.hashicorp.com/v1alpha1\n" +
+		"  kind: ConsulCluster\n" +
+		"  metadata:\n" +
+		"    name: consul\n" +
+		"    namespace: consul\n" +
+		"  spec:\n" +
+		"    preset: secure\n" +
+		"    values:\n" +
+		"      connectInject:\n" +
+		"        enabled: true\n\n" + c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *Command) Synopsis() string {
+	return "Run an operator that installs, upgrades and uninstalls Consul according to ConsulCluster resources."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *Command) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *Command) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
@@ -0,0 +1,695 @@
+// Package peering contains the commands that manage cluster peering.
+package peering
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/posener/complete"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	flagNamePeer      = "peer"
+	flagNameNamespace = "namespace"
+	flagNameToken     = "token"
+	flagNameCAFile    = "ca-file"
+
+	flagNamePeerContext   = "peer-context"
+	flagNamePeerNamespace = "peer-namespace"
+	flagNamePeerToken     = "peer-token"
+	flagNamePeerCAFile    = "peer-ca-file"
+
+	flagNameTestService   = "test-service"
+	flagNameTestNamespace = "test-namespace"
+	defaultTestNamespace  = "default"
+	flagNameTestImage     = "test-image"
+	defaultTestImage      = "curlimages/curl:7.85.0"
+	flagNameTestPath      = "test-path"
+	defaultTestPath       = "/"
+
+	flagNameTimeout = "timeout"
+	defaultTimeout  = 3 * time.Minute
+
+	// peeringStateActive is the state of a peering whose replication stream
+	// is established.
+	peeringStateActive = "ACTIVE"
+	// heartbeatThreshold is how old the last heartbeat of the replication
+	// stream may be. Consul sends heartbeats every 15 seconds.
+	heartbeatThreshold = time.Minute
+	// meshGatewayService is the name of the mesh gateway service, which
+	// carries the traffic to and from peers.
+	meshGatewayService = "mesh-gateway"
+
+	// testContainerName is the name of the container of the test pod that
+	// sends the request to the exported service.
+	testContainerName = "peering-verify"
+	// testUpstreamPort is the local port of the upstream of the test pod.
+	testUpstreamPort = 1234
+
+	defaultPollInterval = 2 * time.Second
+)
+
+// VerifyCommand verifies that a cluster peering works.
+type VerifyCommand struct {
+	*common.BaseCommand
+
+	kubernetes     kubernetes.Interface
+	restConfig     *rest.Config
+	peerKubernetes kubernetes.Interface
+	peerRestConfig *rest.Config
+
+	set *flag.Sets
+
+	flagPeer      string
+	flagNamespace string
+	flagToken     string
+	flagCAFile    string
+
+	flagPeerContext   string
+	flagPeerNamespace string
+	flagPeerToken     string
+	flagPeerCAFile    string
+
+	flagTestService   string
+	flagTestNamespace string
+	flagTestImage     string
+	flagTestPath      string
+	flagTimeout       time.Duration
+
+	flagKubeConfig  string
+	flagKubeContext string
+
+	// openServer returns a client for the HTTP API of the server pod and a
+	// function that closes the connection. It port forwards to the pod if it
+	// is not set, which lets tests replace it.
+	openServer consul.ServerOpener
+	// pollInterval is how often the test pod is checked. It defaults to
+	// defaultPollInterval.
+	pollInterval time.Duration
+
+	once sync.Once
+	help string
+}
+
+// cluster is one side of the peering.
+type cluster struct {
+	// name describes the cluster in the report.
+	name       string
+	kubernetes kubernetes.Interface
+	restConfig *rest.Config
+	namespace  string
+	token      string
+	caFile     string
+}
+
+// check is the result of one check of the peering.
+type check struct {
+	Cluster string
+	Name    string
+	Result  string
+	OK      bool
+}
+
+func (c *VerifyCommand) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:   flagNamePeer,
+		Target: &c.flagPeer,
+		Usage:  "The name of the peering to verify, as listed in this cluster.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameNamespace,
+		Aliases: []string{"n"},
+		Target:  &c.flagNamespace,
+		Usage: "Set the namespace of the Consul installation. " +
+			"If not set, the namespace of the installed Helm release is used.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameToken,
+		Target: &c.flagToken,
+		Usage: fmt.Sprintf("Set the ACL token used to call the Consul API. It needs peering, service and node read "+
+			"permissions. If not set, the %s environment variable is used.", common.TokenEnvVar),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameCAFile,
+		Target: &c.flagCAFile,
+		Usage: fmt.Sprintf("Set the path to the CA certificate of the Consul servers. If set, the servers are called "+
+			"over HTTPS on port %d instead of HTTP on port %d.", consul.HTTPSPort, consul.HTTPPort),
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNamePeerContext,
+		Target: &c.flagPeerContext,
+		Usage: "The Kubernetes context of the peer cluster. If set, the peering is also checked from the peer, " +
+			"including whether the exported services of each side are visible on the other.",
+		Completion: common.PredictKubeContexts,
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNamePeerNamespace,
+		Target: &c.flagPeerNamespace,
+		Usage: "The namespace of the Consul installation of the peer cluster. " +
+			"If not set, the namespace of the Helm release installed in the peer cluster is used.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNamePeerToken,
+		Target: &c.flagPeerToken,
+		Usage:  "Set the ACL token used to call the Consul API of the peer cluster.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       flagNamePeerCAFile,
+		Target:     &c.flagPeerCAFile,
+		Usage:      "Set the path to the CA certificate of the Consul servers of the peer cluster.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameTestService,
+		Target: &c.flagTestService,
+		Usage: "A service exported by the peer. If set, a short-lived pod is started in the mesh that sends an HTTP " +
+			"request to the service through the peering.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       flagNameTestNamespace,
+		Target:     &c.flagTestNamespace,
+		Default:    defaultTestNamespace,
+		Usage:      "The Kubernetes namespace to start the test pod in.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameTestImage,
+		Target:  &c.flagTestImage,
+		Default: defaultTestImage,
+		Usage:   "The image of the test pod. It must have sh and curl.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameTestPath,
+		Target:  &c.flagTestPath,
+		Default: defaultTestPath,
+		Usage:   "The HTTP path that the test pod requests.",
+	})
+	f.DurationVar(&flag.DurationVar{
+		Name:    flagNameTimeout,
+		Target:  &c.flagTimeout,
+		Default: defaultTimeout,
+		Usage:   "Set how long to wait for the test pod to complete its request.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run checks the peering in this cluster and, if -peer-context is set, in the
+// peer cluster, optionally sends a request through it and prints a report.
+func (c *VerifyCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("peering verify")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+
+	local, peer, err := c.setup()
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	checks, err := c.verify(local, peer)
+	if err != nil {
+		c.UI.Output("Unable to verify peering %q: %v", c.flagPeer, err, terminal.WithErrorStyle())
+		return 1
+	}
+
+	c.UI.Output("Peering %s:", c.flagPeer, terminal.WithHeaderStyle())
+	tbl := terminal.NewTable("Cluster", "Check", "Result")
+	failed := 0
+	for _, ch := range checks {
+		color := terminal.Green
+		if !ch.OK {
+			color = terminal.Red
+			failed++
+		}
+		tbl.Rich([]string{ch.Cluster, ch.Name, ch.Result}, []string{"", "", color})
+	}
+	c.UI.Table(tbl)
+
+	if failed > 0 {
+		c.UI.Output("FAIL: %d checks failed.", failed, terminal.WithErrorStyle())
+		return 1
+	}
+	c.UI.Output("PASS: peering %q is healthy.", c.flagPeer, terminal.WithSuccessStyle())
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *VerifyCommand) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if c.flagPeer == "" {
+		return fmt.Errorf("-%s must be set", flagNamePeer)
+	}
+	if c.flagPeerContext == "" && (c.flagPeerNamespace != "" || c.flagPeerToken != "" || c.flagPeerCAFile != "") {
+		return fmt.Errorf("-%s, -%s and -%s require -%s", flagNamePeerNamespace, flagNamePeerToken, flagNamePeerCAFile, flagNamePeerContext)
+	}
+	if c.flagTimeout <= 0 {
+		return fmt.Errorf("-%s must be greater than zero", flagNameTimeout)
+	}
+	return nil
+}
+
+// setup creates the Kubernetes clients of this cluster and, if -peer-context
+// is set, of the peer cluster, and finds the namespaces of their Consul
+// installations if they are not set.
+func (c *VerifyCommand) setup() (*cluster, *cluster, error) {
+	if c.flagToken == "" {
+		c.flagToken = os.Getenv(common.TokenEnvVar)
+	}
+	if c.pollInterval == 0 {
+		c.pollInterval = defaultPollInterval
+	}
+
+	local := &cluster{name: "local", namespace: c.flagNamespace, token: c.flagToken, caFile: c.flagCAFile}
+	if c.flagKubeContext != "" {
+		local.name = c.flagKubeContext
+	}
+	var err error
+	local.kubernetes, local.restConfig, err = c.kubeClients(c.flagKubeContext, c.kubernetes, c.restConfig, &local.namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.flagPeerContext == "" {
+		return local, nil, nil
+	}
+
+	peer := &cluster{name: c.flagPeerContext, namespace: c.flagPeerNamespace, token: c.flagPeerToken, caFile: c.flagPeerCAFile}
+	peer.kubernetes, peer.restConfig, err = c.kubeClients(c.flagPeerContext, c.peerKubernetes, c.peerRestConfig, &peer.namespace)
+	if err != nil {
+		return nil, nil, fmt.Errorf("peer cluster: %s", err)
+	}
+	return local, peer, nil
+}
+
+// kubeClients returns the Kubernetes clients of the context unless they are
+// already set, and sets the namespace to the namespace of the Consul
+// installation if it is empty.
+func (c *VerifyCommand) kubeClients(kubeContext string, kube kubernetes.Interface, restConfig *rest.Config, namespace *string) (kubernetes.Interface, *rest.Config, error) {
+	settings, err := common.InitKubernetes(c.flagKubeConfig, kubeContext, &restConfig, &kube)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if *namespace == "" {
+		uiLogger := func(msg string, args ...interface{}) {
+			c.Log.Debug(fmt.Sprintf(msg, args...))
+		}
+		_, ns, err := common.CheckForInstallations(settings, uiLogger)
+		if err != nil {
+			return nil, nil, err
+		}
+		*namespace = ns
+	}
+	return kube, restConfig, nil
+}
+
+// verify runs the checks of the peering. It only returns an error if the
+// peering can't be read from this cluster.
+func (c *VerifyCommand) verify(local, peer *cluster) ([]check, error) {
+	client, closeClient, err := c.open(local)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to the Consul servers: %s", err)
+	}
+	defer closeClient()
+
+	peering, err := client.Peering(c.Ctx, c.flagPeer)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	checks := peeringChecks(local.name, peering, now)
+	checks = append(checks, c.meshGatewayCheck(local.name, client))
+
+	exported, err := c.exportedServices(client, c.flagPeer)
+	if err != nil {
+		return nil, err
+	}
+	imported, err := client.PeerServices(c.Ctx, c.flagPeer)
+	if err != nil {
+		return nil, fmt.Errorf("error listing the services imported from the peer: %s", err)
+	}
+	checks = append(checks,
+		check{Cluster: local.name, Name: "Exported services", Result: listOrNone(exported), OK: true},
+		check{Cluster: local.name, Name: "Imported services", Result: listOrNone(imported), OK: true},
+	)
+
+	if peer != nil {
+		checks = append(checks, c.verifyPeer(local, peer, peering, exported, imported, now)...)
+	}
+
+	if c.flagTestService != "" {
+		checks = append(checks, c.trafficCheck(local, imported))
+	}
+	return checks, nil
+}
+
+// verifyPeer checks the peering from the peer cluster, and that the services
+// exported by each side are visible on the other.
+func (c *VerifyCommand) verifyPeer(local, peer *cluster, peering *consul.Peering, exported, imported []string, now time.Time) []check {
+	failed := func(name, result string) []check {
+		return []check{{Cluster: peer.name, Name: name, Result: result}}
+	}
+	client, closeClient, err := c.open(peer)
+	if err != nil {
+		return failed("Consul servers", fmt.Sprintf("unreachable: %s", err))
+	}
+	defer closeClient()
+
+	peerings, err := client.Peerings(c.Ctx)
+	if err != nil {
+		return failed("Peering", fmt.Sprintf("unable to list peerings: %s", err))
+	}
+	var remote *consul.Peering
+	for i := range peerings {
+		if peerings[i].PeerID == peering.ID {
+			remote = &peerings[i]
+		}
+	}
+	if remote == nil {
+		return failed("Peering", fmt.Sprintf("no peering with peer ID %s", peering.ID))
+	}
+
+	checks := peeringChecks(peer.name, remote, now)
+	checks = append(checks, c.meshGatewayCheck(peer.name, client))
+
+	remoteImported, err := client.PeerServices(c.Ctx, remote.Name)
+	if err != nil {
+		checks = append(checks, check{Cluster: peer.name, Name: "Services exported by " + local.name,
+			Result: fmt.Sprintf("unable to list imported services: %s", err)})
+	} else {
+		checks = append(checks, visibilityCheck(peer.name, "Services exported by "+local.name, exported, remoteImported))
+	}
+	remoteExported, err := c.exportedServices(client, remote.Name)
+	if err != nil {
+		checks = append(checks, check{Cluster: local.name, Name: "Services exported by " + peer.name,
+			Result: fmt.Sprintf("unable to list exported services: %s", err)})
+	} else {
+		checks = append(checks, visibilityCheck(local.name, "Services exported by "+peer.name, remoteExported, imported))
+	}
+	return checks
+}
+
+// peeringChecks checks the state of the peering and, if Consul reports it,
+// the last heartbeat of its replication stream.
+func peeringChecks(clusterName string, peering *consul.Peering, now time.Time) []check {
+	checks := []check{{Cluster: clusterName, Name: "Peering state", Result: peering.State, OK: peering.State == peeringStateActive}}
+	if peering.StreamStatus != nil {
+		heartbeat := check{Cluster: clusterName, Name: "Stream heartbeat", Result: "never received"}
+		if last := peering.StreamStatus.LastHeartbeat; last != nil && !last.IsZero() {
+			age := now.Sub(*last).Round(time.Second)
+			heartbeat.Result = fmt.Sprintf("%s ago", age)
+			heartbeat.OK = age <= heartbeatThreshold
+		}
+		checks = append(checks, heartbeat)
+	}
+	return checks
+}
+
+// meshGatewayCheck checks that the cluster has a healthy mesh gateway to carry
+// the traffic of the peering.
+func (c *VerifyCommand) meshGatewayCheck(clusterName string, client *consul.Client) check {
+	result := check{Cluster: clusterName, Name: "Mesh gateways"}
+	healthy, err := client.HealthyInstances(c.Ctx, meshGatewayService)
+	if err != nil {
+		result.Result = fmt.Sprintf("unable to read health: %s", err)
+		return result
+	}
+	result.Result = fmt.Sprintf("%d healthy", healthy)
+	result.OK = healthy > 0
+	return result
+}
+
+// exportedServices returns the services that the exported-services config
+// entries of the cluster export to the peer.
+func (c *VerifyCommand) exportedServices(client *consul.Client, peer string) ([]string, error) {
+	entries, err := client.ConfigEntries(c.Ctx, "exported-services")
+	if err != nil {
+		return nil, fmt.Errorf("error listing exported-services config entries: %s", err)
+	}
+	return exportedTo(entries, peer), nil
+}
+
+// exportedTo returns the sorted names of the services that the
+// exported-services config entries export to the peer. The name "*" means
+// that all services are exported.
+func exportedTo(entries []consul.ConfigEntry, peer string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, entry := range entries {
+		services, _ := entry["Services"].([]interface{})
+		for _, s := range services {
+			service, _ := s.(map[string]interface{})
+			name, _ := service["Name"].(string)
+			consumers, _ := service["Consumers"].([]interface{})
+			for _, co := range consumers {
+				consumer, _ := co.(map[string]interface{})
+				// Consul 1.13 names the field PeerName and 1.14+ names it Peer.
+				consumerPeer, _ := consumer["Peer"].(string)
+				if consumerPeer == "" {
+					consumerPeer, _ = consumer["PeerName"].(string)
+				}
+				if consumerPeer == peer && name != "" && !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// visibilityCheck checks that the exported services are visible on the other
+// side of the peering.
+func visibilityCheck(clusterName, name string, exported, visible []string) check {
+	result := check{Cluster: clusterName, Name: name, OK: true}
+	isVisible := make(map[string]bool)
+	for _, s := range visible {
+		isVisible[s] = true
+	}
+	var missing []string
+	for _, s := range exported {
+		if s == "*" {
+			result.Result = fmt.Sprintf("all services exported, %d visible", len(visible))
+			return result
+		}
+		if !isVisible[s] {
+			missing = append(missing, s)
+		}
+	}
+	switch {
+	case len(exported) == 0:
+		result.Result = "none exported"
+	case len(missing) > 0:
+		result.Result = "not visible: " + strings.Join(missing, ", ")
+		result.OK = false
+	default:
+		result.Result = fmt.Sprintf("all %d visible", len(exported))
+	}
+	return result
+}
+
+// trafficCheck starts a pod in the mesh with the test service of the peer as
+// an upstream, waits for it to request the service and reports the result.
+func (c *VerifyCommand) trafficCheck(local *cluster, imported []string) check {
+	result := check{Cluster: local.name, Name: fmt.Sprintf("Request to %s", c.flagTestService)}
+	found := false
+	for _, s := range imported {
+		found = found || s == c.flagTestService
+	}
+	if !found {
+		result.Result = fmt.Sprintf("%s is not imported from %s", c.flagTestService, c.flagPeer)
+		return result
+	}
+
+	c.UI.Output("Starting a test pod that requests %s through peering %s", c.flagTestService, c.flagPeer, terminal.WithInfoStyle())
+	exitCode, err := c.runTestPod(local.kubernetes)
+	switch {
+	case err != nil:
+		result.Result = err.Error()
+	case exitCode != 0:
+		result.Result = fmt.Sprintf("request failed (curl exit code %d), check the intentions of %s on the peer", exitCode, c.flagTestService)
+	default:
+		result.Result = "succeeded"
+		result.OK = true
+	}
+	return result
+}
+
+// runTestPod creates the test pod with its service account and service, which
+// the mesh needs to register it, waits for its request to complete and
+// deletes them. It returns the exit code of the request.
+func (c *VerifyCommand) runTestPod(kube kubernetes.Interface) (int32, error) {
+	name := "peering-verify-" + rand.String(5)
+	namespace := c.flagTestNamespace
+	labels := map[string]string{"app": name}
+	ctx := c.Ctx
+
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels}}
+	if _, err := kube.CoreV1().ServiceAccounts(namespace).Create(ctx, sa, metav1.CreateOptions{}); err != nil {
+		return 0, fmt.Errorf("error creating the test service account: %s", err)
+	}
+	defer kube.CoreV1().ServiceAccounts(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Spec: corev1.ServiceSpec{
+			Selector: labels,
+			Ports:    []corev1.ServicePort{{Name: "http", Port: 80}},
+		},
+	}
+	if _, err := kube.CoreV1().Services(namespace).Create(ctx, svc, metav1.CreateOptions{}); err != nil {
+		return 0, fmt.Errorf("error creating the test service: %s", err)
+	}
+	defer kube.CoreV1().Services(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+
+	// The request is retried since the upstream listener of the sidecar
+	// isn't ready as soon as the container starts.
+	request := fmt.Sprintf("for i in $(seq 1 30); do curl -sSf -o /dev/null --max-time 5 http://127.0.0.1:%d%s && exit 0; sleep 2; done; exit 1",
+		testUpstreamPort, c.flagTestPath)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+			Annotations: map[string]string{
+				"consul.hashicorp.com/connect-inject":            "true",
+				"consul.hashicorp.com/connect-service-upstreams": fmt.Sprintf("%s.svc.%s.peer:%d", c.flagTestService, c.flagPeer, testUpstreamPort),
+			},
+		},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: name,
+			RestartPolicy:      corev1.RestartPolicyNever,
+			Containers: []corev1.Container{{
+				Name:    testContainerName,
+				Image:   c.flagTestImage,
+				Command: []string{"/bin/sh", "-c", request},
+			}},
+		},
+	}
+	if _, err := kube.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		return 0, fmt.Errorf("error creating the test pod: %s", err)
+	}
+	defer kube.CoreV1().Pods(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+
+	ctx, cancel := context.WithTimeout(ctx, c.flagTimeout)
+	defer cancel()
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+	for {
+		pod, err := kube.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			c.Log.Debug("getting test pod", "error", err)
+		} else {
+			for _, status := range pod.Status.ContainerStatuses {
+				if status.Name == testContainerName && status.State.Terminated != nil {
+					return status.State.Terminated.ExitCode, nil
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("timed out waiting for the test pod %s/%s", namespace, name)
+		case <-ticker.C:
+		}
+	}
+}
+
+// open returns a client for the HTTP API of a running server pod of the
+// cluster.
+func (c *VerifyCommand) open(cl *cluster) (*consul.Client, func(), error) {
+	open := consul.ServerConfig{
+		KubeClient: cl.kubernetes,
+		RestConfig: cl.restConfig,
+		Token:      cl.token,
+		CAFile:     cl.caFile,
+	}.Opener(c.openServer)
+	return consul.OpenRunningServer(c.Ctx, cl.kubernetes, cl.namespace, open)
+}
+
+func listOrNone(list []string) string {
+	if len(list) == 0 {
+		return "none"
+	}
+	return strings.Join(list, ", ")
+}
+
+// Help returns a description of the command and how it is used.
+func (c *VerifyCommand) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s peering verify -peer <name> [flags]\n\n" +
+		"The peering is checked to be active with a recent heartbeat on its replication stream, and the\n" +
+		"cluster to have healthy mesh gateways. The services exported to and imported from the peer are listed.\n\n" +
+		"With -peer-context, the peering is also checked from the peer cluster, as well as that the services\n" +
+		"exported by each side are visible on the other.\n\n" +
+		"With -test-service, a short-lived pod is started in the mesh with the service of the peer as an\n" +
+		"upstream, and it sends an HTTP request to it through the mesh gateways:\n\n" +
+		"  $ consul-k8s peering verify -peer dc2 -peer-context dc2 -test-service api\n\n" + c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *VerifyCommand) Synopsis() string {
+	return "Verify that a cluster peering works."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *VerifyCommand) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *VerifyCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
@@ -0,0 +1,244 @@
+package peering
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/hashicorp/consul-k8s/cli/helper/test"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// TestValidateFlags tests the validate flags function.
+func TestValidateFlags(t *testing.T) {
+	// The following cases should all error, if they fail to this test fails.
+	testCases := []struct {
+		description string
+		input       []string
+	}{
+		{
+			"Should require a peer.",
+			[]string{},
+		},
+		{
+			"Should disallow non-flag arguments.",
+			[]string{"-peer", "dc2", "dc3"},
+		},
+		{
+			"Should require a peer context for the peer namespace.",
+			[]string{"-peer", "dc2", "-peer-namespace", "consul"},
+		},
+		{
+			"Should disallow a zero timeout.",
+			[]string{"-peer", "dc2", "-timeout", "0s"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		c := getInitializedCommand(t)
+		t.Run(testCase.description, func(t *testing.T) {
+			if err := c.validateFlags(testCase.input); err == nil {
+				t.Errorf("Test case should have failed.")
+			}
+		})
+	}
+}
+
+func TestPeeringChecks(t *testing.T) {
+	now := time.Date(2026, 10, 15, 10, 0, 0, 0, time.UTC)
+	recent := now.Add(-10 * time.Second)
+	old := now.Add(-5 * time.Minute)
+
+	require.Equal(t, []check{{Cluster: "local", Name: "Peering state", Result: "ACTIVE", OK: true}},
+		peeringChecks("local", &consul.Peering{State: "ACTIVE"}, now))
+	require.Equal(t, []check{
+		{Cluster: "local", Name: "Peering state", Result: "ACTIVE", OK: true},
+		{Cluster: "local", Name: "Stream heartbeat", Result: "10s ago", OK: true},
+	}, peeringChecks("local", &consul.Peering{State: "ACTIVE", StreamStatus: &consul.PeeringStreamStatus{LastHeartbeat: &recent}}, now))
+	require.Equal(t, []check{
+		{Cluster: "local", Name: "Peering state", Result: "FAILING"},
+		{Cluster: "local", Name: "Stream heartbeat", Result: "5m0s ago"},
+	}, peeringChecks("local", &consul.Peering{State: "FAILING", StreamStatus: &consul.PeeringStreamStatus{LastHeartbeat: &old}}, now))
+	require.Equal(t, []check{
+		{Cluster: "local", Name: "Peering state", Result: "PENDING"},
+		{Cluster: "local", Name: "Stream heartbeat", Result: "never received"},
+	}, peeringChecks("local", &consul.Peering{State: "PENDING", StreamStatus: &consul.PeeringStreamStatus{}}, now))
+}
+
+func TestExportedTo(t *testing.T) {
+	entries := []consul.ConfigEntry{
+		{"Kind": "exported-services", "Name": "default", "Services": []interface{}{
+			map[string]interface{}{"Name": "web", "Consumers": []interface{}{
+				map[string]interface{}{"Peer": "dc2"},
+			}},
+			map[string]interface{}{"Name": "api", "Consumers": []interface{}{
+				map[string]interface{}{"Partition": "other"},
+				map[string]interface{}{"PeerName": "dc2"},
+			}},
+			map[string]interface{}{"Name": "db", "Consumers": []interface{}{
+				map[string]interface{}{"Peer": "dc3"},
+			}},
+		}},
+	}
+	require.Equal(t, []string{"api", "web"}, exportedTo(entries, "dc2"))
+	require.Empty(t, exportedTo(entries, "dc4"))
+}
+
+func TestVisibilityCheck(t *testing.T) {
+	require.Equal(t, check{Cluster: "dc2", Name: "n", Result: "all 2 visible", OK: true},
+		visibilityCheck("dc2", "n", []string{"api", "web"}, []string{"api", "db", "web"}))
+	require.Equal(t, check{Cluster: "dc2", Name: "n", Result: "not visible: web"},
+		visibilityCheck("dc2", "n", []string{"api", "web"}, []string{"api"}))
+	require.Equal(t, check{Cluster: "dc2", Name: "n", Result: "none exported", OK: true},
+		visibilityCheck("dc2", "n", nil, []string{"api"}))
+	require.Equal(t, check{Cluster: "dc2", Name: "n", Result: "all services exported, 1 visible", OK: true},
+		visibilityCheck("dc2", "n", []string{"*"}, []string{"api"}))
+}
+
+func TestRun(t *testing.T) {
+	cases := map[string]struct {
+		args []string
+		// peerImports are the services imported by the peer cluster.
+		peerImports string
+		// testExitCode is the exit code of the request of the test pod.
+		testExitCode int32
+		expCode      int
+	}{
+		"local checks": {
+			args:    []string{"-peer", "dc2"},
+			expCode: 0,
+		},
+		"peer checks": {
+			args:        []string{"-peer", "dc2", "-peer-context", "dc2"},
+			peerImports: `{"web": []}`,
+			expCode:     0,
+		},
+		"exported service not visible on the peer": {
+			args:        []string{"-peer", "dc2", "-peer-context", "dc2"},
+			peerImports: `{}`,
+			expCode:     1,
+		},
+		"request succeeds": {
+			args:    []string{"-peer", "dc2", "-test-service", "api"},
+			expCode: 0,
+		},
+		"request fails": {
+			args:         []string{"-peer", "dc2", "-test-service", "api"},
+			testExitCode: 22,
+			expCode:      1,
+		},
+		"test service not imported": {
+			args:    []string{"-peer", "dc2", "-test-service", "db"},
+			expCode: 1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			heartbeat := time.Now().UTC().Format(time.RFC3339)
+			local := fakeConsul(t, fmt.Sprintf(`{"ID": "a", "Name": "dc2", "State": "ACTIVE", "PeerID": "b", "StreamStatus": {"LastHeartbeat": %q}}`, heartbeat),
+				`[{"Kind": "exported-services", "Name": "default", "Services": [{"Name": "web", "Consumers": [{"Peer": "dc2"}]}]}]`,
+				`{"api": []}`)
+			defer local.Close()
+			peer := fakeConsul(t, fmt.Sprintf(`[{"ID": "b", "Name": "dc1", "State": "ACTIVE", "PeerID": "a", "StreamStatus": {"LastHeartbeat": %q}}]`, heartbeat),
+				`[{"Kind": "exported-services", "Name": "default", "Services": [{"Name": "api", "Consumers": [{"Peer": "dc1"}]}]}]`,
+				tc.peerImports)
+			defer peer.Close()
+
+			kube := fake.NewSimpleClientset(test.ServerPod("consul-server-0", "consul", ""))
+			// The test pod completes as soon as it is created.
+			kube.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				pod := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+				require.Equal(t, "api.svc.dc2.peer:1234", pod.Annotations["consul.hashicorp.com/connect-service-upstreams"])
+				pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+					Name:  testContainerName,
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: tc.testExitCode}},
+				}}
+				return false, nil, nil
+			})
+
+			c := getInitializedCommand(t)
+			c.kubernetes = kube
+			c.restConfig = &rest.Config{}
+			c.peerKubernetes = fake.NewSimpleClientset(test.ServerPod("consul-server-0", "consul-dc2", ""))
+			c.peerRestConfig = &rest.Config{}
+			c.pollInterval = time.Millisecond
+			c.openServer = func(pod *corev1.Pod) (*consul.Client, func(), error) {
+				srv := local
+				if pod.Namespace == "consul-dc2" {
+					srv = peer
+				}
+				return &consul.Client{Addr: strings.TrimPrefix(srv.URL, "http://")}, func() {}, nil
+			}
+
+			args := append([]string{"-n", "consul"}, tc.args...)
+			if tc.peerImports != "" {
+				args = append(args, "-peer-namespace", "consul-dc2")
+			}
+			require.Equal(t, tc.expCode, c.Run(args))
+
+			// The test pod and its service and service account are deleted.
+			pods, err := kube.CoreV1().Pods("default").List(context.Background(), metav1.ListOptions{})
+			require.NoError(t, err)
+			require.Empty(t, pods.Items)
+			services, err := kube.CoreV1().Services("default").List(context.Background(), metav1.ListOptions{})
+			require.NoError(t, err)
+			require.Empty(t, services.Items)
+		})
+	}
+}
+
+// fakeConsul serves the peering API of a Consul server. peering is the
+// response of the peering of the local cluster, or of the list of peerings of
+// the peer cluster.
+func fakeConsul(t *testing.T, peering, exportedServices, imported string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/peering/dc2", "/v1/peerings":
+			w.Write([]byte(peering))
+		case "/v1/config/exported-services":
+			w.Write([]byte(exportedServices))
+		case "/v1/catalog/services":
+			w.Write([]byte(imported))
+		case "/v1/health/service/mesh-gateway":
+			w.Write([]byte(`[{}]`))
+		default:
+			t.Logf("unexpected request %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func getInitializedCommand(t *testing.T) *VerifyCommand {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+	}
+
+	c := &VerifyCommand{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
@@ -0,0 +1,104 @@
+package plugin
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	cliplugin "github.com/hashicorp/consul-k8s/cli/plugin"
+	"github.com/posener/complete"
+)
+
+// ListCommand lists the plugins found on the PATH.
+type ListCommand struct {
+	*common.BaseCommand
+
+	// Plugins are the plugins that are run as subcommands and Shadowed are
+	// the ones that are not because they would replace built-in commands.
+	Plugins  []cliplugin.Plugin
+	Shadowed []cliplugin.Plugin
+
+	set *flag.Sets
+
+	once sync.Once
+	help string
+}
+
+func (c *ListCommand) init() {
+	c.set = flag.NewSets()
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run prints the plugins and the subcommands they are run as.
+func (c *ListCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("plugin list")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+
+	if len(c.Plugins) == 0 && len(c.Shadowed) == 0 {
+		c.UI.Output("No plugins found. Plugins are executables on the PATH whose names start with %q.",
+			cliplugin.Prefix, terminal.WithInfoStyle())
+		return 0
+	}
+
+	if len(c.Plugins) > 0 {
+		tbl := terminal.NewTable("Command", "Path")
+		for _, p := range c.Plugins {
+			tbl.Rich([]string{"consul-k8s " + p.Name, p.Path}, nil)
+		}
+		c.UI.Table(tbl)
+	}
+	for _, p := range c.Shadowed {
+		c.UI.Output("Plugin %s is ignored because %q conflicts with built-in commands.",
+			p.Path, "consul-k8s "+p.Name, terminal.WithWarningStyle())
+	}
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *ListCommand) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	return nil
+}
+
+// Help returns a description of the command and how it is used.
+func (c *ListCommand) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s plugin list\n\n" +
+		"Plugins are executables on the PATH named consul-k8s-<name>. They are run as the subcommand\n" +
+		"<name>, where dashes separate subcommands, e.g. consul-k8s-foo-bar is run as \"consul-k8s foo bar\".\n" +
+		"If several directories have a plugin with the same name, the first one on the PATH is used.\n\n" + c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *ListCommand) Synopsis() string {
+	return "List the plugins found on the PATH."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *ListCommand) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *ListCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
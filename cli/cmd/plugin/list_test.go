@@ -0,0 +1,55 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	cliplugin "github.com/hashicorp/consul-k8s/cli/plugin"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListRun(t *testing.T) {
+	cases := map[string]struct {
+		args    []string
+		expCode int
+	}{
+		"lists the plugins": {
+			expCode: 0,
+		},
+		"rejects arguments": {
+			args:    []string{"foo"},
+			expCode: 1,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := getInitializedListCommand(t)
+			c.Plugins = []cliplugin.Plugin{{Name: "foo", Path: "/bin/consul-k8s-foo"}}
+			c.Shadowed = []cliplugin.Plugin{{Name: "install", Path: "/bin/consul-k8s-install"}}
+			require.Equal(t, tc.expCode, c.Run(tc.args))
+		})
+	}
+}
+
+func getInitializedListCommand(t *testing.T) *ListCommand {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+	}
+
+	c := &ListCommand{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
@@ -0,0 +1,219 @@
+package accesslogs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/envoy"
+	"github.com/posener/complete"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	flagNameNamespace = "namespace"
+
+	flagNameFollow = "follow"
+
+	flagNameTail = "tail"
+	defaultTail  = 100
+)
+
+// columnFormat lays out the columns of the access log entries.
+const columnFormat = "%-24s  %-7s  %-40s  %4s  %-5s  %8s  %s"
+
+type Command struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+
+	set *flag.Sets
+
+	flagPodName   string
+	flagNamespace string
+	flagFollow    bool
+	flagTail      int64
+
+	flagKubeConfig  string
+	flagKubeContext string
+
+	once sync.Once
+	help string
+}
+
+func (c *Command) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:       flagNameNamespace,
+		Aliases:    []string{"n"},
+		Target:     &c.flagNamespace,
+		Default:    "default",
+		Usage:      "The namespace of the pod.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameFollow,
+		Aliases: []string{"f"},
+		Target:  &c.flagFollow,
+		Default: false,
+		Usage:   "Keep printing the requests as they are logged.",
+	})
+	f.Int64Var(&flag.Int64Var{
+		Name:    flagNameTail,
+		Target:  &c.flagTail,
+		Default: defaultTail,
+		Usage:   "The number of lines of the log of the proxy to print the requests of before following it. Set it to -1 to print the whole log.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run prints the requests in the access log of the proxy in a pod.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("proxy accesslogs")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+	if c.kubernetes == nil {
+		var restConfig *rest.Config
+		if _, err := common.InitKubernetes(c.flagKubeConfig, c.flagKubeContext, &restConfig, &c.kubernetes); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+	}
+
+	opts := &corev1.PodLogOptions{
+		Container: common.EnvoyContainerName,
+		Follow:    c.flagFollow,
+	}
+	if c.flagTail >= 0 {
+		opts.TailLines = &c.flagTail
+	}
+	logs, err := c.kubernetes.CoreV1().Pods(c.flagNamespace).GetLogs(c.flagPodName, opts).Stream(c.Ctx)
+	if err != nil {
+		c.UI.Output("Error getting the logs of pod %s/%s: %v", c.flagNamespace, c.flagPodName, err, terminal.WithErrorStyle())
+		return 1
+	}
+	defer logs.Close()
+
+	c.UI.Output(fmt.Sprintf(columnFormat, "TIME", "METHOD", "PATH", "CODE", "FLAGS", "DURATION", "UPSTREAM"))
+	err = readAccessLogs(logs, func(entry envoy.AccessLogEntry) {
+		c.UI.Output(formatEntry(entry))
+	})
+	// The stream is closed when the command is interrupted while following.
+	if err != nil && c.Ctx.Err() == nil {
+		c.UI.Output("Error reading the logs of pod %s/%s: %v", c.flagNamespace, c.flagPodName, err, terminal.WithErrorStyle())
+		return 1
+	}
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *Command) validateFlags(args []string) error {
+	// The pod name comes before the flags.
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		c.flagPodName = args[0]
+		args = args[1:]
+	}
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments after the pod name")
+	}
+	if c.flagPodName == "" {
+		return errors.New("a pod name must be set")
+	}
+	if c.flagTail < -1 {
+		return fmt.Errorf("-%s must be -1 or greater", flagNameTail)
+	}
+	return nil
+}
+
+// readAccessLogs calls fn with each access log entry read from r until it is
+// exhausted, skipping the other lines of the log of the proxy.
+func readAccessLogs(r io.Reader, fn func(envoy.AccessLogEntry)) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if entry, ok := envoy.ParseAccessLog(scanner.Text()); ok {
+			fn(entry)
+		}
+	}
+	return scanner.Err()
+}
+
+// formatEntry lays out the entry in columns.
+func formatEntry(entry envoy.AccessLogEntry) string {
+	duration := entry.Duration
+	if duration != "-" {
+		duration += "ms"
+	}
+	return fmt.Sprintf(columnFormat, entry.StartTime, entry.Method, entry.Path,
+		entry.ResponseCode, entry.ResponseFlags, duration, entry.UpstreamHost)
+}
+
+// Help returns a description of the command and how it is used.
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s proxy accesslogs <pod-name> [flags]\n\n" +
+		"The log of the Envoy container of the pod is read through the Kubernetes API and the requests\n" +
+		"in it are printed in columns. Access logs in Envoy's default format and in JSON are supported;\n" +
+		"the other lines of the log are skipped. Access logging must be enabled for the proxy, with the\n" +
+		"consul.hashicorp.com/envoy-access-logs annotation of the pod or connectInject.envoyAccessLogs.\n\n" +
+		"Examples:\n" +
+		"  $ consul-k8s proxy accesslogs web-6d7b8c9f5-x2x4p\n" +
+		"  $ consul-k8s proxy accesslogs web-6d7b8c9f5-x2x4p -f -tail 0\n\n" +
+		c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *Command) Synopsis() string {
+	return "Print the requests in the access log of a proxy."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *Command) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command, which
+// predicts the names of the pods with an injected proxy.
+func (c *Command) AutocompleteArgs() complete.Predictor {
+	return common.PredictKubePods(common.InjectedPodSelector)
+}
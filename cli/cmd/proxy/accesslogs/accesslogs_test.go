@@ -0,0 +1,91 @@
+package accesslogs
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/envoy"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestValidateFlags tests the validate flags function.
+func TestValidateFlags(t *testing.T) {
+	// The following cases should all error, if they fail to this test fails.
+	testCases := []struct {
+		description string
+		input       []string
+	}{
+		{
+			"Should require a pod name.",
+			[]string{},
+		},
+		{
+			"Should disallow non-flag arguments after the pod name.",
+			[]string{"web", "-namespace", "default", "api"},
+		},
+		{
+			"Should disallow a tail less than -1.",
+			[]string{"web", "-tail", "-2"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		c := getInitializedCommand(t)
+		t.Run(testCase.description, func(t *testing.T) {
+			if err := c.validateFlags(testCase.input); err == nil {
+				t.Errorf("Test case should have failed.")
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	c := getInitializedCommand(t)
+	c.kubernetes = fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}})
+	require.Equal(t, 0, c.Run([]string{"web", "-f"}))
+}
+
+func TestReadAccessLogs(t *testing.T) {
+	logs := strings.Join([]string{
+		`[2022-04-20 10:15:30.001][1][info][main] [source/server/server.cc:803] starting main dispatch loop`,
+		`[2022-04-20T10:15:32.123Z] "GET /orders HTTP/1.1" 200 - 0 512 13 12 "-" "curl/7.79.1" "6a9f3c2e" "api:8080" "10.0.0.7:20000"`,
+		`{"start_time":"2022-04-20T10:15:33.456Z","method":"POST","path":"/orders","protocol":"HTTP/2","response_code":503,"response_flags":"UH","duration":2,"authority":"api","upstream_host":null}`,
+	}, "\n")
+
+	var rows []string
+	require.NoError(t, readAccessLogs(strings.NewReader(logs), func(entry envoy.AccessLogEntry) {
+		rows = append(rows, strings.Join(strings.Fields(formatEntry(entry)), " "))
+	}))
+	require.Equal(t, []string{
+		"2022-04-20T10:15:32.123Z GET /orders 200 - 13ms 10.0.0.7:20000",
+		"2022-04-20T10:15:33.456Z POST /orders 503 UH 2ms -",
+	}, rows)
+}
+
+// getInitializedCommand sets up a command struct for tests.
+func getInitializedCommand(t *testing.T) *Command {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+	}
+
+	c := &Command{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
@@ -0,0 +1,791 @@
+package analyze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/hashicorp/consul-k8s/cli/envoy"
+	"github.com/posener/complete"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	flagNameNamespace = "namespace"
+
+	flagNameSelector  = "selector"
+	flagNameAggregate = "aggregate"
+
+	// maxConcurrentFetches bounds the number of pods whose config dumps are
+	// fetched at the same time with -selector.
+	maxConcurrentFetches = 5
+	// fetchTimeout bounds the time spent fetching the config dump of a single
+	// pod with -selector.
+	fetchTimeout = 30 * time.Second
+
+	flagNameFile = "file"
+	// stdinFile is the value of -file that reads the config dump from stdin.
+	stdinFile = "-"
+
+	flagNameCerts = "certs"
+
+	flagNameRoutes = "routes"
+
+	flagNameDiscoveryChain  = "discovery-chain"
+	flagNameConsulNamespace = "consul-namespace"
+	flagNameToken           = "token"
+	flagNameCAFile          = "ca-file"
+
+	flagNameCertExpiryWarning = "cert-expiry-warning"
+	// defaultCertExpiryWarning is below the 72h TTL of Consul's leaf
+	// certificates so that leaf certificates are only highlighted when they
+	// are not rotated in time.
+	defaultCertExpiryWarning = 24 * time.Hour
+
+	// redirectTrafficCommand is run by the init container when transparent
+	// proxy is enabled for the pod.
+	redirectTrafficCommand = "consul connect redirect-traffic"
+)
+
+type Command struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+	restConfig *rest.Config
+
+	// stdin is read when -file is "-". It defaults to os.Stdin.
+	stdin io.Reader
+
+	// openAdmin returns the address of the admin API of the proxy in the pod
+	// and a function that closes the connection. It port forwards to the pod
+	// if it is not set, which lets tests replace it.
+	openAdmin common.PortOpener
+	// openServer returns a client for the HTTP API of the server pod and a
+	// function that closes the connection. It port forwards to the pod if it
+	// is not set, which lets tests replace it.
+	openServer consul.ServerOpener
+
+	set *flag.Sets
+
+	flagPodName   string
+	flagNamespace string
+	flagSelector  string
+	flagAggregate bool
+	flagFile      string
+	flagAdminPort int
+
+	flagCerts             bool
+	flagCertExpiryWarning time.Duration
+
+	flagRoutes bool
+
+	flagDiscoveryChain  string
+	flagConsulNamespace string
+	flagToken           string
+	flagCAFile          string
+
+	flagKubeConfig  string
+	flagKubeContext string
+
+	once sync.Once
+	help string
+}
+
+func (c *Command) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:       flagNameNamespace,
+		Aliases:    []string{"n"},
+		Target:     &c.flagNamespace,
+		Default:    "default",
+		Usage:      "The namespace of the pod.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameSelector,
+		Target: &c.flagSelector,
+		Usage:  "Analyze the proxies in all pods in the namespace matching this label selector instead of a single pod.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:   flagNameAggregate,
+		Target: &c.flagAggregate,
+		Usage:  "With -selector, check that the proxies have converged to the same xDS version of each resource and report the pods lagging behind, instead of checking them for known issues.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       flagNameFile,
+		Target:     &c.flagFile,
+		Usage:      "Analyze the Envoy configuration dump in this file instead of fetching it from a pod. Use \"-\" to read it from stdin.",
+		Completion: complete.PredictFiles("*.json"),
+	})
+	f.IntVar(&flag.IntVar{
+		Name:    common.FlagNameAdminPort,
+		Target:  &c.flagAdminPort,
+		Default: common.DefaultAdminPort,
+		Usage:   "The port of the Envoy admin API in the pod.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:   flagNameCerts,
+		Target: &c.flagCerts,
+		Usage:  "Show the leaf and root certificates of the proxy instead of checking the configuration for known issues.",
+	})
+	f.DurationVar(&flag.DurationVar{
+		Name:    flagNameCertExpiryWarning,
+		Target:  &c.flagCertExpiryWarning,
+		Default: defaultCertExpiryWarning,
+		Usage:   "Highlight the certificates that expire within this duration when -certs is set.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:   flagNameRoutes,
+		Target: &c.flagRoutes,
+		Usage:  "Show the route table of the proxy instead of checking the configuration for known issues.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameDiscoveryChain,
+		Target: &c.flagDiscoveryChain,
+		Usage: "Compare the clusters and routes of the proxy with the compiled discovery chains of these comma-separated " +
+			"upstream services, read from the Consul servers, instead of checking the configuration for known issues.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameConsulNamespace,
+		Target: &c.flagConsulNamespace,
+		Usage: "Set the namespace of the Consul installation whose servers the discovery chains are read from with " +
+			"-discovery-chain. If not set, the namespace of the installed Helm release is used.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameToken,
+		Target: &c.flagToken,
+		Usage: fmt.Sprintf("Set the ACL token used to read the discovery chains with -discovery-chain. "+
+			"If not set, the %s environment variable is used.", common.TokenEnvVar),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameCAFile,
+		Target: &c.flagCAFile,
+		Usage: fmt.Sprintf("Set the path to the CA certificate of the Consul servers. If set, the servers are called "+
+			"over HTTPS on port %d instead of HTTP on port %d.", consul.HTTPSPort, consul.HTTPPort),
+		Completion: complete.PredictFiles("*"),
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run analyzes the Envoy configuration of a pod, or of a configuration dump
+// read from a file, and prints the known problematic patterns it contains.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("proxy analyze")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+
+	if c.flagSelector != "" {
+		if _, err := common.InitKubernetes(c.flagKubeConfig, c.flagKubeContext, &c.restConfig, &c.kubernetes); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+		return c.runSelector()
+	}
+
+	var raw []byte
+	var opts envoy.Options
+	if c.flagFile != "" {
+		var err error
+		raw, err = c.readConfigDump()
+		if err != nil {
+			c.UI.Output("Error reading config dump: %v", err, terminal.WithErrorStyle())
+			return 1
+		}
+	} else {
+		if _, err := common.InitKubernetes(c.flagKubeConfig, c.flagKubeContext, &c.restConfig, &c.kubernetes); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+		if c.flagPodName == "" {
+			if err := c.pickPod(); err != nil {
+				c.UI.Output("Error selecting a pod: %v", err, terminal.WithErrorStyle())
+				return 1
+			}
+		}
+		var err error
+		raw, opts, err = c.fetchConfigDump()
+		if err != nil {
+			c.UI.Output("Error fetching config dump from pod %s/%s: %v", c.flagNamespace, c.flagPodName, err, terminal.WithErrorStyle())
+			return 1
+		}
+	}
+
+	dump, err := envoy.ParseConfigDump(raw)
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	if c.flagRoutes {
+		c.printRoutes(envoy.RouteTable(dump))
+		return 0
+	}
+
+	if c.flagDiscoveryChain != "" {
+		return c.checkDiscoveryChains(dump)
+	}
+
+	if c.flagCerts {
+		certs, err := envoy.Certificates(dump)
+		if err != nil {
+			c.UI.Output("Error reading certificates: %v", err, terminal.WithErrorStyle())
+			return 1
+		}
+		// Certificates delivered through SDS are not inlined in the dump,
+		// but the /certs endpoint of a live proxy has their details.
+		if len(certs) == 0 && c.flagFile == "" {
+			certs, err = c.fetchCertificates()
+			if err != nil {
+				c.UI.Output("Error fetching certificates from pod %s/%s: %v", c.flagNamespace, c.flagPodName, err, terminal.WithErrorStyle())
+				return 1
+			}
+		}
+		c.printCertificates(certs, time.Now())
+		return 0
+	}
+
+	c.printWarnings(envoy.Analyze(dump, opts))
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *Command) validateFlags(args []string) error {
+	// The pod name comes before the flags.
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		c.flagPodName = args[0]
+		args = args[1:]
+	}
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments after the pod name")
+	}
+	// The pod is picked interactively if none is set and the terminal
+	// allows it.
+	if c.flagPodName == "" && c.flagFile == "" && c.flagSelector == "" && !c.UI.Interactive() {
+		return fmt.Errorf("a pod name, -%s or -%s must be set", flagNameSelector, flagNameFile)
+	}
+	if c.flagPodName != "" && c.flagFile != "" {
+		return fmt.Errorf("a pod name and -%s cannot both be set", flagNameFile)
+	}
+	if c.flagSelector != "" && (c.flagPodName != "" || c.flagFile != "") {
+		return fmt.Errorf("-%s cannot be set with a pod name or -%s", flagNameSelector, flagNameFile)
+	}
+	if c.flagAggregate && c.flagSelector == "" {
+		return fmt.Errorf("-%s requires -%s", flagNameAggregate, flagNameSelector)
+	}
+	if c.flagCerts && c.flagSelector != "" {
+		return fmt.Errorf("-%s cannot be set with -%s", flagNameCerts, flagNameSelector)
+	}
+	if c.flagRoutes && c.flagSelector != "" {
+		return fmt.Errorf("-%s cannot be set with -%s", flagNameRoutes, flagNameSelector)
+	}
+	if c.flagRoutes && c.flagCerts {
+		return fmt.Errorf("-%s and -%s cannot both be set", flagNameRoutes, flagNameCerts)
+	}
+	if c.flagDiscoveryChain != "" && (c.flagSelector != "" || c.flagCerts || c.flagRoutes) {
+		return fmt.Errorf("-%s cannot be set with -%s, -%s or -%s", flagNameDiscoveryChain, flagNameSelector, flagNameCerts, flagNameRoutes)
+	}
+	if c.flagDiscoveryChain == "" && (c.flagConsulNamespace != "" || c.flagToken != "" || c.flagCAFile != "") {
+		return fmt.Errorf("-%s, -%s and -%s require -%s", flagNameConsulNamespace, flagNameToken, flagNameCAFile, flagNameDiscoveryChain)
+	}
+	if c.flagCertExpiryWarning < 0 {
+		return fmt.Errorf("-%s must not be negative", flagNameCertExpiryWarning)
+	}
+	return nil
+}
+
+// readConfigDump reads the config dump from the file, or from stdin if the
+// file is "-".
+func (c *Command) readConfigDump() ([]byte, error) {
+	if c.flagFile != stdinFile {
+		return os.ReadFile(c.flagFile)
+	}
+	if c.stdin == nil {
+		c.stdin = os.Stdin
+	}
+	return io.ReadAll(c.stdin)
+}
+
+// pickPod asks the user to pick one of the pods with an injected proxy in the
+// namespace.
+func (c *Command) pickPod() error {
+	pods, err := c.kubernetes.CoreV1().Pods(c.flagNamespace).List(c.Ctx, metav1.ListOptions{LabelSelector: common.InjectedPodSelector})
+	if err != nil {
+		return err
+	}
+	if len(pods.Items) == 0 {
+		return fmt.Errorf("no pods with a Consul proxy found in namespace %q", c.flagNamespace)
+	}
+	names := make([]string, 0, len(pods.Items))
+	for _, pod := range pods.Items {
+		names = append(names, pod.Name)
+	}
+	sort.Strings(names)
+
+	c.flagPodName, err = terminal.Pick(c.UI, "pod", names)
+	return err
+}
+
+// fetchConfigDump fetches the config dump from the Envoy admin API of the pod
+// through a port forward, and returns it with the options describing the pod.
+func (c *Command) fetchConfigDump() ([]byte, envoy.Options, error) {
+	pod, err := c.kubernetes.CoreV1().Pods(c.flagNamespace).Get(c.Ctx, c.flagPodName, metav1.GetOptions{})
+	if err != nil {
+		return nil, envoy.Options{}, err
+	}
+	tproxy := transparentProxyEnabled(pod)
+	opts := envoy.Options{TransparentProxy: &tproxy}
+
+	pf := common.PortForward{
+		Namespace:  c.flagNamespace,
+		PodName:    c.flagPodName,
+		RemotePort: c.flagAdminPort,
+		KubeClient: c.kubernetes,
+		RestConfig: c.restConfig,
+	}
+	adminAddr, err := pf.Open()
+	if err != nil {
+		return nil, opts, err
+	}
+	defer pf.Close()
+
+	raw, err := envoy.FetchConfigDump(c.Ctx, adminAddr)
+	return raw, opts, err
+}
+
+// fetchCertificates fetches the certificates from the /certs endpoint of the
+// Envoy admin API of the pod through a port forward.
+func (c *Command) fetchCertificates() ([]envoy.Certificate, error) {
+	pf := common.PortForward{
+		Namespace:  c.flagNamespace,
+		PodName:    c.flagPodName,
+		RemotePort: c.flagAdminPort,
+		KubeClient: c.kubernetes,
+		RestConfig: c.restConfig,
+	}
+	adminAddr, err := pf.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer pf.Close()
+	return envoy.FetchCertificates(c.Ctx, adminAddr)
+}
+
+// checkDiscoveryChains compares the clusters and routes of the proxy with the
+// compiled discovery chains of the upstream services.
+func (c *Command) checkDiscoveryChains(dump *envoy.ConfigDump) int {
+	settings, err := common.InitKubernetes(c.flagKubeConfig, c.flagKubeContext, &c.restConfig, &c.kubernetes)
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	client, closeClient, err := c.openConsulServer(settings)
+	if err != nil {
+		c.UI.Output("Error connecting to the Consul servers: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+	defer closeClient()
+
+	code := 0
+	mismatched := false
+	for _, service := range strings.Split(c.flagDiscoveryChain, ",") {
+		service = strings.TrimSpace(service)
+		if service == "" {
+			continue
+		}
+		chain, err := client.DiscoveryChain(c.Ctx, service)
+		if err != nil {
+			c.UI.Output("Error reading the discovery chain of %s: %v", service, err, terminal.WithErrorStyle())
+			code = 1
+			continue
+		}
+		mismatches := compareDiscoveryChain(dump, chain)
+		c.printChainMismatches(service, mismatches)
+		mismatched = mismatched || len(mismatches) > 0
+	}
+	if mismatched {
+		c.UI.Output("Differences between the proxy and the discovery chains usually mean that the proxy hasn't "+
+			"received the latest configuration from Consul yet, or that Consul can't generate it because the ACL "+
+			"token of the proxy isn't allowed to read the target services.", terminal.WithInfoStyle())
+	}
+	return code
+}
+
+// openConsulServer returns a client for the HTTP API of a running server of
+// the Consul installation.
+func (c *Command) openConsulServer(settings *helmCLI.EnvSettings) (*consul.Client, func(), error) {
+	if c.flagToken == "" {
+		c.flagToken = os.Getenv(common.TokenEnvVar)
+	}
+	namespace := c.flagConsulNamespace
+	if namespace == "" {
+		uiLogger := func(msg string, args ...interface{}) {
+			c.Log.Debug(fmt.Sprintf(msg, args...))
+		}
+		var err error
+		if _, namespace, err = common.CheckForInstallations(settings, uiLogger); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	open := consul.ServerConfig{
+		KubeClient: c.kubernetes,
+		RestConfig: c.restConfig,
+		Token:      c.flagToken,
+		CAFile:     c.flagCAFile,
+	}.Opener(c.openServer)
+	return consul.OpenRunningServer(c.Ctx, c.kubernetes, namespace, open)
+}
+
+// podConfigDump is the config dump of the proxy in a pod, or the error
+// fetching or parsing it.
+type podConfigDump struct {
+	pod  *corev1.Pod
+	dump *envoy.ConfigDump
+	err  error
+}
+
+// runSelector analyzes the proxies in the pods matching the selector, or
+// reports whether their xDS versions have converged with -aggregate.
+func (c *Command) runSelector() int {
+	list, err := c.kubernetes.CoreV1().Pods(c.flagNamespace).List(c.Ctx, metav1.ListOptions{LabelSelector: c.flagSelector})
+	if err != nil {
+		c.UI.Output("Error listing pods: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+	if len(list.Items) == 0 {
+		c.UI.Output("No pods matching %q found in namespace %q.", c.flagSelector, c.flagNamespace, terminal.WithErrorStyle())
+		return 1
+	}
+	pods := list.Items
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+
+	results := c.fetchConfigDumps(pods)
+
+	code := 0
+	dumps := make(map[string]*envoy.ConfigDump)
+	for _, result := range results {
+		if result.err != nil {
+			c.UI.Output("Error fetching config dump from pod %s/%s: %v", result.pod.Namespace, result.pod.Name, result.err, terminal.WithErrorStyle())
+			code = 1
+			continue
+		}
+		if c.flagAggregate {
+			dumps[result.pod.Name] = result.dump
+			continue
+		}
+		tproxy := transparentProxyEnabled(result.pod)
+		c.UI.Output("Pod %s/%s", result.pod.Namespace, result.pod.Name, terminal.WithHeaderStyle())
+		c.printWarnings(envoy.Analyze(result.dump, envoy.Options{TransparentProxy: &tproxy}))
+	}
+	if c.flagAggregate && len(dumps) > 0 {
+		c.printConvergence(envoy.CompareVersions(dumps))
+	}
+	return code
+}
+
+// fetchConfigDumps fetches and parses the config dumps of the proxies in the
+// pods in parallel, at most maxConcurrentFetches at a time. The results are in
+// the order of the pods.
+func (c *Command) fetchConfigDumps(pods []corev1.Pod) []podConfigDump {
+	results := make([]podConfigDump, len(pods))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < maxConcurrentFetches && w < len(pods); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = c.fetchPodConfigDump(&pods[i])
+			}
+		}()
+	}
+	for i := range pods {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+func (c *Command) fetchPodConfigDump(pod *corev1.Pod) podConfigDump {
+	result := podConfigDump{pod: pod}
+	open := common.PortForwarder{KubeClient: c.kubernetes, RestConfig: c.restConfig}.Opener(c.openAdmin)
+	adminAddr, closeAdmin, err := open(pod, c.flagAdminPort)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	defer closeAdmin()
+
+	ctx, cancel := context.WithTimeout(c.Ctx, fetchTimeout)
+	defer cancel()
+	raw, err := envoy.FetchConfigDump(ctx, adminAddr)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	result.dump, result.err = envoy.ParseConfigDump(raw)
+	return result
+}
+
+// printConvergence prints how many resources of each type have converged to
+// the same xDS version in all of the proxies, followed by the resources that
+// have not with the pods lagging behind.
+func (c *Command) printConvergence(convergence []envoy.TypeConvergence, mismatches []envoy.VersionMismatch) {
+	c.UI.Output("xDS versions", terminal.WithHeaderStyle())
+	tbl := terminal.NewTable("Type", "Resources", "Converged", "Mismatched")
+	for _, conv := range convergence {
+		color := ""
+		if conv.Mismatched > 0 {
+			color = terminal.Yellow
+		}
+		tbl.Rich(
+			[]string{conv.Type, strconv.Itoa(conv.Resources), strconv.Itoa(conv.Resources - conv.Mismatched), strconv.Itoa(conv.Mismatched)},
+			[]string{"", "", "", color},
+		)
+	}
+	c.UI.Table(tbl)
+
+	if len(mismatches) == 0 {
+		c.UI.Output("All proxies have the same xDS version of every resource.", terminal.WithSuccessStyle())
+		return
+	}
+
+	c.UI.Output("Resources not at the same version in all proxies", terminal.WithHeaderStyle())
+	tbl = terminal.NewTable("Type", "Name", "Expected Version", "Lagging Pods")
+	for _, m := range mismatches {
+		pods := make([]string, 0, len(m.Lagging))
+		for pod, version := range m.Lagging {
+			if version == "" {
+				version = "missing"
+			}
+			pods = append(pods, fmt.Sprintf("%s (%s)", pod, version))
+		}
+		sort.Strings(pods)
+		tbl.Rich([]string{m.Type, m.Name, m.Version, strings.Join(pods, ", ")}, nil)
+	}
+	c.UI.Table(tbl)
+}
+
+// transparentProxyEnabled returns whether the connect injector set up the
+// redirection of the pod's traffic to the proxy.
+func transparentProxyEnabled(pod *corev1.Pod) bool {
+	for _, container := range pod.Spec.InitContainers {
+		if !strings.HasPrefix(container.Name, common.InitContainerName) {
+			continue
+		}
+		if strings.Contains(strings.Join(container.Command, " "), redirectTrafficCommand) {
+			return true
+		}
+	}
+	return false
+}
+
+// printWarnings prints the warnings with links to their remediation.
+func (c *Command) printWarnings(warnings []envoy.Warning) {
+	if len(warnings) == 0 {
+		c.UI.Output("No known issues found in the Envoy configuration.", terminal.WithSuccessStyle())
+		return
+	}
+
+	c.UI.Output("Found %d known issues in the Envoy configuration", len(warnings), terminal.WithHeaderStyle())
+	for _, w := range warnings {
+		c.UI.Output("%s: %s", w.Rule, w.Message, terminal.WithWarningStyle())
+		c.UI.Output("See %s", w.Link, terminal.WithInfoStyle())
+	}
+}
+
+// printCertificates prints the certificates as a table, highlighting the ones
+// that expire within the warning duration, followed by a warning for each of
+// them.
+func (c *Command) printCertificates(certs []envoy.Certificate, now time.Time) {
+	if len(certs) == 0 {
+		c.UI.Output("No certificates found in the Envoy configuration.")
+		return
+	}
+
+	// Certificates are referred to by their position in the table in the
+	// Issued By column, since fingerprints are too long to show.
+	index := make(map[string]int)
+	for i, cert := range certs {
+		if cert.Fingerprint != "" {
+			index[cert.Fingerprint] = i + 1
+		}
+	}
+
+	c.UI.Output("Certificates", terminal.WithHeaderStyle())
+	tbl := terminal.NewTable("#", "Kind", "Identity", "Serial", "Valid From", "Expires", "Issued By", "Used By")
+	var expiring []string
+	for i, cert := range certs {
+		identity := cert.SPIFFEID
+		if identity == "" {
+			identity = cert.Subject
+		}
+		issuedBy := "-"
+		if n, ok := index[cert.IssuedBy]; ok {
+			issuedBy = fmt.Sprintf("#%d", n)
+		} else if cert.Issuer != "" && cert.Kind != envoy.CertRoot {
+			issuedBy = cert.Issuer
+		}
+		expiryColor := ""
+		if cert.ExpiresWithin(c.flagCertExpiryWarning, now) {
+			expiryColor = terminal.Red
+			expiring = append(expiring, fmt.Sprintf("The %s certificate #%d (%s) expires at %s.", cert.Kind, i+1, identity, cert.NotAfter.Format(time.RFC3339)))
+		}
+		tbl.Rich(
+			[]string{strconv.Itoa(i + 1), cert.Kind, identity, cert.SerialNumber, formatTime(cert.NotBefore), formatTime(cert.NotAfter), issuedBy, strings.Join(cert.UsedBy, ", ")},
+			[]string{"", "", "", "", "", expiryColor},
+		)
+	}
+	c.UI.Table(tbl)
+
+	for _, message := range expiring {
+		c.UI.Output(message, terminal.WithWarningStyle())
+	}
+}
+
+// printRoutes prints a table of the routes of each route configuration in the
+// order in which Envoy matches them.
+func (c *Command) printRoutes(routes []envoy.RouteTableEntry) {
+	if len(routes) == 0 {
+		c.UI.Output("No routes found in the Envoy configuration.")
+		return
+	}
+
+	var tbl *terminal.Table
+	for i, route := range routes {
+		if i == 0 || route.RouteConfig != routes[i-1].RouteConfig {
+			if tbl != nil {
+				c.UI.Table(tbl)
+			}
+			c.UI.Output("Route configuration %s", route.RouteConfig, terminal.WithHeaderStyle())
+			tbl = terminal.NewTable("Virtual Host", "Domains", "Match", "Headers", "Clusters")
+		}
+		clusters := route.Action
+		if len(route.Clusters) == 1 {
+			clusters = route.Clusters[0].Name
+		} else if len(route.Clusters) > 1 {
+			var weighted []string
+			for _, cluster := range route.Clusters {
+				weighted = append(weighted, fmt.Sprintf("%s (%s%%)", cluster.Name, strconv.FormatFloat(cluster.Weight, 'f', -1, 64)))
+			}
+			clusters = strings.Join(weighted, ", ")
+		}
+		tbl.Rich([]string{route.VirtualHost, strings.Join(route.Domains, ", "), route.Match, strings.Join(route.Headers, ", "), clusters}, nil)
+	}
+	c.UI.Table(tbl)
+}
+
+// printChainMismatches prints the differences between the proxy and the
+// discovery chain of the upstream service.
+func (c *Command) printChainMismatches(service string, mismatches []string) {
+	c.UI.Output("Discovery chain of %s", service, terminal.WithHeaderStyle())
+	if len(mismatches) == 0 {
+		c.UI.Output("The clusters and routes of the proxy match the discovery chain.", terminal.WithSuccessStyle())
+		return
+	}
+	for _, m := range mismatches {
+		c.UI.Output(m, terminal.WithWarningStyle())
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// Help returns a description of the command and how it is used.
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s proxy analyze <pod-name> [flags]\n" +
+		"       consul-k8s proxy analyze -selector <label-selector> [-aggregate] [flags]\n" +
+		"       consul-k8s proxy analyze -file <config-dump> [flags]\n\n" +
+		"A config dump captured earlier, e.g. with curl localhost:19000/config_dump, can be analyzed offline\n" +
+		"with -file, or piped to the command with -file -.\n\n" +
+		"If the pod name is omitted in an interactive terminal, a pod with a Consul proxy in the namespace can be picked.\n\n" +
+		"The Envoy configuration is checked for deprecated filters, TLS clusters without SAN matchers,\n" +
+		"use of the original destination without transparent proxy and listeners without filter chains.\n\n" +
+		"With -certs, the leaf, intermediate and root certificates of the proxy are shown instead, with their\n" +
+		"SPIFFE IDs, validity and the certificates that issued them. Certificates that expire within\n" +
+		"-cert-expiry-warning are highlighted.\n\n" +
+		"With -routes, the route table of the proxy is shown instead: the virtual hosts and their domains, the\n" +
+		"path, header and query parameter matches of each route in the order Envoy evaluates them, and the\n" +
+		"clusters that the route sends requests to with their traffic split weights.\n\n" +
+		"With -discovery-chain, the compiled discovery chains of the given upstream services are read from the\n" +
+		"Consul servers and compared with the proxy: it should have a cluster for every target, including failover\n" +
+		"targets, and a route for every service splitter with the same weights. Differences usually point to xDS\n" +
+		"updates that haven't reached the proxy yet or to ACL tokens that can't read the targets:\n\n" +
+		"  $ consul-k8s proxy analyze web-5d8f7b-abcde -discovery-chain api,billing\n\n" +
+		"With -selector, the proxies in all matching pods are analyzed. Adding -aggregate checks instead that\n" +
+		"they have converged to the same xDS version of each listener, cluster, route, endpoint and secret,\n" +
+		"e.g. after applying a config entry, and lists the pods lagging behind:\n\n" +
+		"  $ consul-k8s proxy analyze -selector app=web -aggregate\n\n" +
+		c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *Command) Synopsis() string {
+	return "Check the Envoy configuration of a pod for known issues."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *Command) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command, which
+// predicts the names of the pods with an injected proxy.
+func (c *Command) AutocompleteArgs() complete.Predictor {
+	return common.PredictKubePods(common.InjectedPodSelector)
+}
@@ -0,0 +1,322 @@
+package analyze
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+// TestValidateFlags tests the validate flags function.
+func TestValidateFlags(t *testing.T) {
+	// The following cases should all error, if they fail to this test fails.
+	testCases := []struct {
+		description string
+		input       []string
+	}{
+		{
+			"Should require a pod name or a file.",
+			[]string{},
+		},
+		{
+			"Should disallow a pod name with a file.",
+			[]string{"web", "-file", "config_dump.json"},
+		},
+		{
+			"Should disallow non-flag arguments after the pod name.",
+			[]string{"web", "-namespace", "default", "api"},
+		},
+		{
+			"Should disallow a negative certificate expiry warning.",
+			[]string{"web", "-certs", "-cert-expiry-warning", "-1h"},
+		},
+		{
+			"Should disallow a pod name with a selector.",
+			[]string{"web", "-selector", "app=web"},
+		},
+		{
+			"Should disallow a file with a selector.",
+			[]string{"-file", "config_dump.json", "-selector", "app=web"},
+		},
+		{
+			"Should require a selector to aggregate.",
+			[]string{"web", "-aggregate"},
+		},
+		{
+			"Should disallow certificates with a selector.",
+			[]string{"-selector", "app=web", "-certs"},
+		},
+		{
+			"Should disallow routes with a selector.",
+			[]string{"-selector", "app=web", "-routes"},
+		},
+		{
+			"Should disallow routes with certificates.",
+			[]string{"web", "-routes", "-certs"},
+		},
+		{
+			"Should disallow discovery chains with a selector.",
+			[]string{"-selector", "app=web", "-discovery-chain", "api"},
+		},
+		{
+			"Should disallow discovery chains with routes.",
+			[]string{"web", "-routes", "-discovery-chain", "api"},
+		},
+		{
+			"Should require discovery chains for a token.",
+			[]string{"web", "-token", "secret"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		c := getInitializedCommand(t)
+		t.Run(testCase.description, func(t *testing.T) {
+			if err := c.validateFlags(testCase.input); err == nil {
+				t.Errorf("Test case should have failed.")
+			}
+		})
+	}
+}
+
+func TestRun_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config_dump.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+  "configs": [{
+    "@type": "type.googleapis.com/envoy.admin.v3.ListenersConfigDump",
+    "dynamic_listeners": [{"name": "empty", "active_state": {"listener": {"name": "empty"}}}]
+  }]
+}`), 0600))
+
+	c := getInitializedCommand(t)
+	require.Equal(t, 0, c.Run([]string{"-file", path}))
+
+	c = getInitializedCommand(t)
+	require.Equal(t, 0, c.Run([]string{"-file", path, "-certs"}))
+
+	c = getInitializedCommand(t)
+	require.Equal(t, 0, c.Run([]string{"-file", path, "-routes"}))
+
+	c = getInitializedCommand(t)
+	require.Equal(t, 1, c.Run([]string{"-file", filepath.Join(t.TempDir(), "does-not-exist.json")}))
+}
+
+func TestRun_Stdin(t *testing.T) {
+	c := getInitializedCommand(t)
+	c.stdin = strings.NewReader(`{"configs": []}`)
+	require.Equal(t, 0, c.Run([]string{"-file", "-"}))
+
+	c = getInitializedCommand(t)
+	c.stdin = strings.NewReader("not json")
+	require.Equal(t, 1, c.Run([]string{"-file", "-"}))
+}
+
+func TestRun_Selector(t *testing.T) {
+	configDump := func(clusterVersion string) string {
+		return `{
+  "configs": [{
+    "@type": "type.googleapis.com/envoy.admin.v3.ClustersConfigDump",
+    "dynamic_active_clusters": [{"version_info": "` + clusterVersion + `", "cluster": {"name": "api"}}]
+  }]
+}`
+	}
+	cases := map[string]struct {
+		args    []string
+		dumps   map[string]string
+		expCode int
+	}{
+		"analyze the pods matching a selector": {
+			args:    []string{"-selector", "app=web"},
+			dumps:   map[string]string{"web-1": configDump("2"), "web-2": configDump("2")},
+			expCode: 0,
+		},
+		"aggregate the pods matching a selector": {
+			args:    []string{"-selector", "app=web", "-aggregate"},
+			dumps:   map[string]string{"web-1": configDump("2"), "web-2": configDump("1")},
+			expCode: 0,
+		},
+		"a config dump can't be fetched": {
+			args:    []string{"-selector", "app=web", "-aggregate"},
+			dumps:   map[string]string{"web-1": configDump("2")},
+			expCode: 1,
+		},
+		"no pods matching the selector": {
+			args:    []string{"-selector", "app=api"},
+			expCode: 1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var servers []*httptest.Server
+			defer func() {
+				for _, srv := range servers {
+					srv.Close()
+				}
+			}()
+
+			c := getInitializedCommand(t)
+			c.kubernetes = fake.NewSimpleClientset(webPod("web-1"), webPod("web-2"))
+			c.restConfig = &rest.Config{}
+			var mu sync.Mutex
+			c.openAdmin = func(pod *corev1.Pod, port int) (string, func(), error) {
+				dump, ok := tc.dumps[pod.Name]
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if !ok || r.URL.Path != "/config_dump" {
+						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					w.Write([]byte(dump))
+				}))
+				mu.Lock()
+				servers = append(servers, srv)
+				mu.Unlock()
+				return strings.TrimPrefix(srv.URL, "http://"), func() {}, nil
+			}
+
+			require.Equal(t, tc.expCode, c.Run(tc.args))
+		})
+	}
+}
+
+func TestRun_DiscoveryChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config_dump.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+  "configs": [{
+    "@type": "type.googleapis.com/envoy.admin.v3.ClustersConfigDump",
+    "dynamic_active_clusters": [{"version_info": "1", "cluster": {"name": "api.default.dc1.internal.11111111.consul"}}]
+  }]
+}`), 0600))
+
+	var tokens []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.Header.Get("X-Consul-Token"))
+		switch r.URL.Path {
+		case "/v1/discovery-chain/api":
+			w.Write([]byte(`{"Chain": {"ServiceName": "api", "StartNode": "resolver:api",
+  "Nodes": {"resolver:api": {"Type": "resolver", "Resolver": {"Target": "api"}}},
+  "Targets": {"api": {"ID": "api", "Name": "api.default.dc1.internal.11111111.consul"}}}}`))
+		case "/v1/discovery-chain/billing":
+			w.Write([]byte(`{"Chain": {"ServiceName": "billing", "StartNode": "resolver:billing",
+  "Nodes": {"resolver:billing": {"Type": "resolver", "Resolver": {"Target": "billing"}}},
+  "Targets": {"billing": {"ID": "billing", "Name": "billing.default.dc1.internal.11111111.consul"}}}}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	serverPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "consul-server-0",
+			Namespace: "consul",
+			Labels:    map[string]string{"app": "consul", "chart": "consul-helm", "component": "server"},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+
+	cases := map[string]struct {
+		services string
+		expCode  int
+	}{
+		"matching chain": {
+			services: "api",
+			expCode:  0,
+		},
+		"mismatched chains are reported": {
+			services: "api,billing",
+			expCode:  0,
+		},
+		"chain can't be read": {
+			services: "api,payments",
+			expCode:  1,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			tokens = nil
+			c := getInitializedCommand(t)
+			c.kubernetes = fake.NewSimpleClientset(serverPod)
+			c.restConfig = &rest.Config{}
+			c.openServer = func(pod *corev1.Pod) (*consul.Client, func(), error) {
+				require.Equal(t, "consul-server-0", pod.Name)
+				return &consul.Client{Addr: strings.TrimPrefix(srv.URL, "http://"), Token: c.flagToken}, func() {}, nil
+			}
+			require.Equal(t, tc.expCode, c.Run([]string{"-file", path, "-discovery-chain", tc.services, "-consul-namespace", "consul", "-token", "secret"}))
+			for _, token := range tokens {
+				require.Equal(t, "secret", token)
+			}
+		})
+	}
+}
+
+func TestRun_DiscoveryChainNoServers(t *testing.T) {
+	c := getInitializedCommand(t)
+	c.kubernetes = fake.NewSimpleClientset()
+	c.restConfig = &rest.Config{}
+	c.stdin = strings.NewReader(`{"configs": []}`)
+	require.Equal(t, 1, c.Run([]string{"-file", "-", "-discovery-chain", "api", "-consul-namespace", "consul"}))
+}
+
+func webPod(name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{"app": "web"},
+		},
+	}
+}
+
+func TestTransparentProxyEnabled(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{InitContainers: []corev1.Container{{
+		Name:    common.InitContainerName,
+		Command: []string{"/bin/sh", "-ec", "consul-k8s-control-plane connect-init\n/consul/connect-inject/consul connect redirect-traffic \\\n  -proxy-id=\"$(cat /consul/connect-inject/proxyid)\""},
+	}}}}
+	require.True(t, transparentProxyEnabled(pod))
+
+	pod.Spec.InitContainers[0].Command = []string{"/bin/sh", "-ec", "consul-k8s-control-plane connect-init"}
+	require.False(t, transparentProxyEnabled(pod))
+}
+
+// getInitializedCommand sets up a command struct for tests.
+func getInitializedCommand(t *testing.T) *Command {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+	}
+
+	c := &Command{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
+
+func TestAutocompleteFlags(t *testing.T) {
+	c := getInitializedCommand(t)
+	flags := c.AutocompleteFlags()
+	for _, name := range []string{"-namespace", "-context", "-kubeconfig", "-file"} {
+		require.Contains(t, flags, name)
+		require.NotNil(t, flags[name], name)
+	}
+}
@@ -0,0 +1,219 @@
+package analyze
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/hashicorp/consul-k8s/cli/envoy"
+)
+
+// weightTolerance is how far apart the weights of a split in the discovery
+// chain and in the route of the proxy may be. Consul converts the weights to
+// integers out of 10000 for Envoy, which rounds them to 0.01%.
+const weightTolerance = 0.01
+
+// compareDiscoveryChain checks that the proxy has a cluster for every target
+// that the discovery chain can send traffic to, and a route that splits the
+// traffic between the targets of every splitter with the same weights. It
+// returns a message for every difference.
+func compareDiscoveryChain(dump *envoy.ConfigDump, chain *consul.CompiledDiscoveryChain) []string {
+	clusters := make(map[string]bool)
+	for _, cluster := range dump.Clusters {
+		if name, ok := cluster["name"].(string); ok {
+			clusters[name] = true
+		}
+	}
+
+	var mismatches []string
+	for _, target := range chainTargets(chain) {
+		if !hasTargetCluster(clusters, target.ClusterName()) {
+			mismatches = append(mismatches, fmt.Sprintf("The proxy has no cluster for target %s (%s).", target.ID, target.ClusterName()))
+		}
+	}
+
+	routes := envoy.RouteTable(dump)
+	for _, nodeName := range sortedNodeNames(chain) {
+		node := chain.Nodes[nodeName]
+		if node.Type != consul.DiscoveryGraphNodeTypeSplitter {
+			continue
+		}
+		expected := splitterClusters(chain, node)
+		if len(expected) == 0 {
+			continue
+		}
+		route, found := findSplitRoute(routes, expected)
+		switch {
+		case !found:
+			mismatches = append(mismatches, fmt.Sprintf("The proxy has no route that splits the traffic of splitter %s between %s.",
+				node.Name, formatWeights(expected)))
+		case !sameWeights(route.Clusters, expected):
+			mismatches = append(mismatches, fmt.Sprintf("The route %q of route configuration %s splits the traffic of splitter %s between %s instead of %s.",
+				route.Match, route.RouteConfig, node.Name, formatWeights(route.Clusters), formatWeights(expected)))
+		}
+	}
+	return mismatches
+}
+
+// chainTargets returns the targets that the discovery chain can send traffic
+// to, including failover targets, sorted by ID.
+func chainTargets(chain *consul.CompiledDiscoveryChain) []*consul.DiscoveryTarget {
+	targetIDs := make(map[string]bool)
+	visited := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		node, ok := chain.Nodes[name]
+		if !ok || visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, route := range node.Routes {
+			visit(route.NextNode)
+		}
+		for _, split := range node.Splits {
+			visit(split.NextNode)
+		}
+		if node.Resolver != nil {
+			targetIDs[node.Resolver.Target] = true
+			if node.Resolver.Failover != nil {
+				for _, id := range node.Resolver.Failover.Targets {
+					targetIDs[id] = true
+				}
+			}
+		}
+	}
+	visit(chain.StartNode)
+
+	var targets []*consul.DiscoveryTarget
+	for id := range targetIDs {
+		if target, ok := chain.Targets[id]; ok {
+			targets = append(targets, target)
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].ID < targets[j].ID })
+	return targets
+}
+
+// hasTargetCluster returns whether the proxy has a cluster for the target.
+// The clusters of failover targets are prefixed with "failover-target~<n>~"
+// by recent Consul versions.
+func hasTargetCluster(clusters map[string]bool, name string) bool {
+	if clusters[name] {
+		return true
+	}
+	for cluster := range clusters {
+		if strings.HasSuffix(cluster, "~"+name) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitterClusters returns the clusters that the splitter sends traffic to
+// and their weights. Splits without weight are left out, like Consul does
+// when it configures the route.
+func splitterClusters(chain *consul.CompiledDiscoveryChain, node *consul.DiscoveryGraphNode) []envoy.WeightedCluster {
+	weights := make(map[string]float64)
+	for _, split := range node.Splits {
+		if split.Weight == 0 {
+			continue
+		}
+		next, ok := chain.Nodes[split.NextNode]
+		if !ok || next.Resolver == nil {
+			continue
+		}
+		target, ok := chain.Targets[next.Resolver.Target]
+		if !ok {
+			continue
+		}
+		weights[target.ClusterName()] += split.Weight
+	}
+
+	clusters := make([]envoy.WeightedCluster, 0, len(weights))
+	for name, weight := range weights {
+		clusters = append(clusters, envoy.WeightedCluster{Name: name, Weight: weight})
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Name < clusters[j].Name })
+	return clusters
+}
+
+// findSplitRoute returns the first route that sends traffic to exactly the
+// expected clusters, preferring one with the expected weights.
+func findSplitRoute(routes []envoy.RouteTableEntry, expected []envoy.WeightedCluster) (envoy.RouteTableEntry, bool) {
+	var candidate *envoy.RouteTableEntry
+	for i := range routes {
+		if !sameClusters(routes[i].Clusters, expected) {
+			continue
+		}
+		if sameWeights(routes[i].Clusters, expected) {
+			return routes[i], true
+		}
+		if candidate == nil {
+			candidate = &routes[i]
+		}
+	}
+	if candidate == nil {
+		return envoy.RouteTableEntry{}, false
+	}
+	return *candidate, true
+}
+
+// sameClusters returns whether the route sends traffic to exactly the
+// expected clusters, regardless of their weights.
+func sameClusters(actual, expected []envoy.WeightedCluster) bool {
+	weights := clusterWeights(actual)
+	if len(weights) != len(expected) {
+		return false
+	}
+	for _, cluster := range expected {
+		if _, ok := weights[cluster.Name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// sameWeights returns whether the route sends the expected share of the
+// traffic to each of the expected clusters.
+func sameWeights(actual, expected []envoy.WeightedCluster) bool {
+	weights := clusterWeights(actual)
+	if len(weights) != len(expected) {
+		return false
+	}
+	for _, cluster := range expected {
+		weight, ok := weights[cluster.Name]
+		if !ok || math.Abs(weight-cluster.Weight) > weightTolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// clusterWeights sums up the weights of the clusters by name.
+func clusterWeights(clusters []envoy.WeightedCluster) map[string]float64 {
+	weights := make(map[string]float64)
+	for _, cluster := range clusters {
+		weights[cluster.Name] += cluster.Weight
+	}
+	return weights
+}
+
+func formatWeights(clusters []envoy.WeightedCluster) string {
+	var parts []string
+	for _, cluster := range clusters {
+		parts = append(parts, fmt.Sprintf("%s (%s%%)", cluster.Name, strconv.FormatFloat(cluster.Weight, 'f', -1, 64)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func sortedNodeNames(chain *consul.CompiledDiscoveryChain) []string {
+	names := make([]string, 0, len(chain.Nodes))
+	for name := range chain.Nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
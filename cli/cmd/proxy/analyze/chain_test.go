@@ -0,0 +1,95 @@
+package analyze
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/hashicorp/consul-k8s/cli/envoy"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	v1Cluster     = "v1.api.default.dc1.internal.11111111.consul"
+	v2Cluster     = "v2.api.default.dc1.internal.11111111.consul"
+	dc2Cluster    = "api.default.dc2.internal.11111111.consul"
+	splitterRoute = `{"name": "api", "virtual_hosts": [{"name": "api", "domains": ["*"], "routes": [
+  {"match": {"prefix": "/"}, "route": {"weighted_clusters": {"total_weight": 10000, "clusters": [
+    {"name": "v1.api.default.dc1.internal.11111111.consul", "weight": %d},
+    {"name": "v2.api.default.dc1.internal.11111111.consul", "weight": %d}]}}}]}]}`
+)
+
+func TestCompareDiscoveryChain(t *testing.T) {
+	// The chain splits the traffic to api between the v1 and v2 subsets, and
+	// fails over from v1 to dc2.
+	chain := &consul.CompiledDiscoveryChain{
+		ServiceName: "api",
+		StartNode:   "splitter:api",
+		Nodes: map[string]*consul.DiscoveryGraphNode{
+			"splitter:api": {Type: consul.DiscoveryGraphNodeTypeSplitter, Name: "api", Splits: []*consul.DiscoverySplit{
+				{Weight: 90, NextNode: "resolver:v1"},
+				{Weight: 10, NextNode: "resolver:v2"},
+			}},
+			"resolver:v1": {Type: consul.DiscoveryGraphNodeTypeResolver, Resolver: &consul.DiscoveryResolver{
+				Target:   "v1",
+				Failover: &consul.DiscoveryFailover{Targets: []string{"dc2"}},
+			}},
+			"resolver:v2": {Type: consul.DiscoveryGraphNodeTypeResolver, Resolver: &consul.DiscoveryResolver{Target: "v2"}},
+		},
+		Targets: map[string]*consul.DiscoveryTarget{
+			"v1":  {ID: "v1", Name: v1Cluster},
+			"v2":  {ID: "v2", SNI: v2Cluster},
+			"dc2": {ID: "dc2", Name: dc2Cluster},
+		},
+	}
+
+	cases := map[string]struct {
+		clusters      []string
+		route         string
+		expMismatches []string
+	}{
+		"matches the chain": {
+			clusters: []string{v1Cluster, v2Cluster, "failover-target~1~" + dc2Cluster},
+			route:    fmt.Sprintf(splitterRoute, 9000, 1000),
+		},
+		"missing target cluster": {
+			clusters: []string{v1Cluster, v2Cluster},
+			route:    fmt.Sprintf(splitterRoute, 9000, 1000),
+			expMismatches: []string{
+				"The proxy has no cluster for target dc2 (" + dc2Cluster + ").",
+			},
+		},
+		"different weights": {
+			clusters: []string{v1Cluster, v2Cluster, dc2Cluster},
+			route:    fmt.Sprintf(splitterRoute, 10000, 0),
+			expMismatches: []string{
+				`The route "prefix /" of route configuration api splits the traffic of splitter api between ` +
+					v1Cluster + " (100%), " + v2Cluster + " (0%) instead of " + v1Cluster + " (90%), " + v2Cluster + " (10%).",
+			},
+		},
+		"no split route": {
+			clusters: []string{v1Cluster, v2Cluster, dc2Cluster},
+			expMismatches: []string{
+				"The proxy has no route that splits the traffic of splitter api between " +
+					v1Cluster + " (90%), " + v2Cluster + " (10%).",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dump := &envoy.ConfigDump{}
+			for _, cluster := range tc.clusters {
+				dump.Clusters = append(dump.Clusters, map[string]interface{}{"name": cluster})
+			}
+			if tc.route != "" {
+				parsed, err := envoy.ParseConfigDump([]byte(`{"configs": [{
+  "@type": "type.googleapis.com/envoy.admin.v3.RoutesConfigDump",
+  "dynamic_route_configs": [{"route_config": ` + tc.route + `}]}]}`))
+				require.NoError(t, err)
+				dump.Routes = parsed.Routes
+			}
+			require.Equal(t, tc.expMismatches, compareDiscoveryChain(dump, chain))
+		})
+	}
+}
@@ -0,0 +1,538 @@
+package certs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/hashicorp/consul-k8s/cli/envoy"
+	"github.com/posener/complete"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	flagNameNamespace = "namespace"
+	defaultNamespace  = "default"
+
+	flagNameAllNamespaces = "all-namespaces"
+	defaultAllNamespaces  = false
+
+	flagNameRotateStatus = "rotate-status"
+
+	flagNameConsulNamespace = "consul-namespace"
+	flagNameToken           = "token"
+	flagNameCAFile          = "ca-file"
+)
+
+// Rotation statuses of a proxy.
+const (
+	// statusRotated means the leaf certificate of the proxy is signed by the
+	// active root and the proxy trusts the active root.
+	statusRotated = "rotated"
+	// statusOldRoot means the leaf certificate of the proxy is still signed
+	// by a root that is no longer active.
+	statusOldRoot = "old root"
+	// statusNotTrusted means the leaf certificate of the proxy is signed by
+	// the active root but the proxy doesn't trust the active root yet, so it
+	// rejects the certificates of rotated proxies.
+	statusNotTrusted = "active root not trusted"
+	// statusUnknownRoot means the leaf certificate of the proxy isn't signed
+	// by any of the roots of the CA.
+	statusUnknownRoot = "unknown root"
+)
+
+// proxy is the certificates of the proxy of a pod.
+type proxy struct {
+	Namespace string
+	Name      string
+	// Leaf is the service's leaf certificate the proxy presents. If there
+	// are several, it is the one that expires first.
+	Leaf *envoy.Certificate
+	// Roots are the root certificates the proxy trusts.
+	Roots []envoy.Certificate
+
+	// Error is why the certificates of the proxy could not be read.
+	Error string
+}
+
+type Command struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+	restConfig *rest.Config
+
+	// fetchDump fetches the config dump of the proxy in a pod. It port
+	// forwards to the admin API of the proxy if nil, and is only set in
+	// tests.
+	fetchDump func(ctx context.Context, pod *corev1.Pod) ([]byte, error)
+	// openServer returns a client for the HTTP API of the server pod and a
+	// function that closes the connection. It port forwards to the pod if it
+	// is not set, which lets tests replace it.
+	openServer consul.ServerOpener
+
+	set *flag.Sets
+
+	flagNamespace       string
+	flagAllNamespaces   bool
+	flagRotateStatus    bool
+	flagConsulNamespace string
+	flagToken           string
+	flagCAFile          string
+	flagAdminPort       int
+
+	flagKubeConfig  string
+	flagKubeContext string
+
+	table common.TableFlags
+
+	once sync.Once
+	help string
+}
+
+func (c *Command) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:       flagNameNamespace,
+		Aliases:    []string{"n"},
+		Target:     &c.flagNamespace,
+		Default:    defaultNamespace,
+		Usage:      "The namespace of the proxies.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameAllNamespaces,
+		Aliases: []string{"A"},
+		Target:  &c.flagAllNamespaces,
+		Default: defaultAllNamespaces,
+		Usage:   "Show the proxies in all namespaces.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:   flagNameRotateStatus,
+		Target: &c.flagRotateStatus,
+		Usage: "Compare the certificates of the proxies with the roots of the Connect CA, to show which proxies " +
+			"still serve certificates from an old root during a CA rotation.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameConsulNamespace,
+		Target: &c.flagConsulNamespace,
+		Usage: "Set the namespace of the Consul installation whose servers the CA roots are read from with " +
+			"-rotate-status. If not set, the namespace of the installed Helm release is used.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameToken,
+		Target: &c.flagToken,
+		Usage: fmt.Sprintf("Set the ACL token used to read the CA roots with -rotate-status. "+
+			"If not set, the %s environment variable is used.", common.TokenEnvVar),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameCAFile,
+		Target: &c.flagCAFile,
+		Usage: fmt.Sprintf("Set the path to the CA certificate of the Consul servers. If set, the servers are called "+
+			"over HTTPS on port %d instead of HTTP on port %d.", consul.HTTPSPort, consul.HTTPPort),
+		Completion: complete.PredictFiles("*"),
+	})
+	f.IntVar(&flag.IntVar{
+		Name:    common.FlagNameAdminPort,
+		Target:  &c.flagAdminPort,
+		Default: common.DefaultAdminPort,
+		Usage:   "The port of the Envoy admin API in the pods.",
+	})
+	c.table.Flags(f)
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run prints the certificates of the proxies, or with -rotate-status whether
+// they have picked up the active root of the Connect CA.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("proxy certs")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+	settings, err := common.InitKubernetes(c.flagKubeConfig, c.flagKubeContext, &c.restConfig, &c.kubernetes)
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	// The roots are read before the proxies so that a rotation that starts
+	// in between shows as proxies on an old root rather than as proxies on an
+	// unknown root.
+	var roots *consul.CARoots
+	if c.flagRotateStatus {
+		roots, err = c.fetchCARoots(settings)
+		if err != nil {
+			c.UI.Output("Error reading the roots of the Connect CA: %v", err, terminal.WithErrorStyle())
+			return 1
+		}
+	}
+
+	namespace := c.flagNamespace
+	if c.flagAllNamespaces {
+		namespace = metav1.NamespaceAll
+	}
+	pods, err := c.kubernetes.CoreV1().Pods(namespace).List(c.Ctx, metav1.ListOptions{LabelSelector: common.InjectedPodSelector})
+	if err != nil {
+		c.UI.Output("Error listing pods: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+	proxies := c.probe(pods.Items)
+	if len(proxies) == 0 {
+		if c.flagAllNamespaces {
+			c.UI.Output("No proxies found.")
+		} else {
+			c.UI.Output("No proxies found in namespace %q.", c.flagNamespace)
+		}
+		return 0
+	}
+
+	if c.flagRotateStatus {
+		err = c.printRotateStatus(proxies, roots)
+	} else {
+		err = c.printCertificates(proxies, time.Now())
+	}
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	for _, p := range proxies {
+		if p.Error != "" {
+			c.UI.Output("Could not read the certificates of the proxy of %s/%s: %s", p.Namespace, p.Name, p.Error, terminal.WithWarningStyle())
+		}
+	}
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *Command) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if c.flagAllNamespaces && c.flagNamespace != defaultNamespace {
+		return fmt.Errorf("-%s and -%s cannot both be set", flagNameNamespace, flagNameAllNamespaces)
+	}
+	if !c.flagRotateStatus && (c.flagConsulNamespace != "" || c.flagToken != "" || c.flagCAFile != "") {
+		return fmt.Errorf("-%s, -%s and -%s require -%s", flagNameConsulNamespace, flagNameToken, flagNameCAFile, flagNameRotateStatus)
+	}
+	return c.table.Validate()
+}
+
+// fetchCARoots reads the roots of the Connect CA from a running server of the
+// Consul installation.
+func (c *Command) fetchCARoots(settings *helmCLI.EnvSettings) (*consul.CARoots, error) {
+	if c.flagToken == "" {
+		c.flagToken = os.Getenv(common.TokenEnvVar)
+	}
+	namespace := c.flagConsulNamespace
+	if namespace == "" {
+		uiLogger := func(msg string, args ...interface{}) {
+			c.Log.Debug(fmt.Sprintf(msg, args...))
+		}
+		var err error
+		if _, namespace, err = common.CheckForInstallations(settings, uiLogger); err != nil {
+			return nil, err
+		}
+	}
+
+	open := consul.ServerConfig{
+		KubeClient: c.kubernetes,
+		RestConfig: c.restConfig,
+		Token:      c.flagToken,
+		CAFile:     c.flagCAFile,
+	}.Opener(c.openServer)
+	client, closeClient, err := consul.OpenRunningServer(c.Ctx, c.kubernetes, namespace, open)
+	if err != nil {
+		return nil, err
+	}
+	defer closeClient()
+	return client.CARoots(c.Ctx)
+}
+
+// probe reads the certificates of the proxies of the pods, from several
+// proxies at once. The proxies are sorted by namespace and name.
+func (c *Command) probe(pods []corev1.Pod) []proxy {
+	proxies := make([]proxy, len(pods))
+	sem := make(chan struct{}, common.ProbeConcurrency)
+	var wg sync.WaitGroup
+	for i := range pods {
+		pod := &pods[i]
+		proxies[i] = proxy{Namespace: pod.Namespace, Name: pod.Name}
+		if pod.Status.Phase != corev1.PodRunning {
+			proxies[i].Error = fmt.Sprintf("pod is %s", strings.ToLower(string(pod.Status.Phase)))
+			continue
+		}
+
+		wg.Add(1)
+		go func(p *proxy, pod *corev1.Pod) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			ctx, cancel := context.WithTimeout(c.Ctx, common.ProbeTimeout)
+			defer cancel()
+			if err := c.readCertificates(ctx, p, pod); err != nil {
+				p.Error = err.Error()
+			}
+		}(&proxies[i], pod)
+	}
+	wg.Wait()
+
+	sort.Slice(proxies, func(i, j int) bool {
+		if proxies[i].Namespace != proxies[j].Namespace {
+			return proxies[i].Namespace < proxies[j].Namespace
+		}
+		return proxies[i].Name < proxies[j].Name
+	})
+	return proxies
+}
+
+// readCertificates reads the leaf and root certificates of the proxy from its
+// config dump. Unlike the /certs endpoint, the dump has the certificates
+// themselves, which are needed to tell which key signed the leaf certificate.
+func (c *Command) readCertificates(ctx context.Context, p *proxy, pod *corev1.Pod) error {
+	raw, err := c.fetchConfigDump(ctx, pod)
+	if err != nil {
+		return err
+	}
+	dump, err := envoy.ParseConfigDump(raw)
+	if err != nil {
+		return err
+	}
+	certs, err := envoy.Certificates(dump)
+	if err != nil {
+		return err
+	}
+	// The certificates are sorted by expiry, so the first leaf certificate
+	// is the oldest.
+	for i := range certs {
+		switch certs[i].Kind {
+		case envoy.CertLeaf:
+			if p.Leaf == nil && strings.Contains(certs[i].SPIFFEID, "/svc/") {
+				p.Leaf = &certs[i]
+			}
+		case envoy.CertRoot:
+			p.Roots = append(p.Roots, certs[i])
+		}
+	}
+	if p.Leaf == nil {
+		return errors.New("no leaf certificate found in the Envoy configuration")
+	}
+	return nil
+}
+
+// fetchConfigDump fetches the config dump of the proxy of the pod through a
+// port forward to its admin API.
+func (c *Command) fetchConfigDump(ctx context.Context, pod *corev1.Pod) ([]byte, error) {
+	if c.fetchDump != nil {
+		return c.fetchDump(ctx, pod)
+	}
+
+	adminAddr, closeAdmin, err := common.PortForwarder{KubeClient: c.kubernetes, RestConfig: c.restConfig}.Open(pod, c.flagAdminPort)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAdmin()
+	return envoy.FetchConfigDump(ctx, adminAddr)
+}
+
+// rotateStatus returns the rotation status of the proxy and the name of the
+// root that signed its leaf certificate.
+func rotateStatus(p proxy, roots *consul.CARoots) (string, string) {
+	var signer *consul.CARoot
+	for i := range roots.Roots {
+		if roots.Roots[i].SigningKeyID != "" && strings.EqualFold(roots.Roots[i].SigningKeyID, p.Leaf.AuthorityKeyID) {
+			signer = &roots.Roots[i]
+			break
+		}
+	}
+	if signer == nil {
+		return statusUnknownRoot, ""
+	}
+	if signer.ID != roots.ActiveRootID {
+		return statusOldRoot, signer.Name
+	}
+	if !trusts(p, roots.ActiveRoot()) {
+		return statusNotTrusted, signer.Name
+	}
+	return statusRotated, signer.Name
+}
+
+// trusts returns whether the root is one of the roots the proxy trusts.
+func trusts(p proxy, root *consul.CARoot) bool {
+	fingerprint := fingerprint(root.RootCert)
+	for _, r := range p.Roots {
+		if r.Fingerprint != "" && r.Fingerprint == fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+// fingerprint returns the SHA-256 fingerprint of the first certificate in the
+// PEM data, like the envoy package computes them.
+func fingerprint(pemData string) string {
+	block, _ := pem.Decode([]byte(pemData))
+	if block == nil {
+		return ""
+	}
+	sum := sha256.Sum256(block.Bytes)
+	return hex.EncodeToString(sum[:])
+}
+
+// printCertificates prints the leaf certificate of each proxy and the number
+// of roots it trusts, highlighting the leaf certificates that expire soon.
+func (c *Command) printCertificates(proxies []proxy, now time.Time) error {
+	tbl := terminal.NewTable("Namespace", "Name", "Service", "Leaf Serial", "Leaf Expiry", "Trusted Roots")
+	for _, p := range proxies {
+		if p.Leaf == nil {
+			continue
+		}
+		expiryColor := ""
+		if p.Leaf.ExpiresWithin(common.CertExpiryWarning, now) {
+			expiryColor = terminal.Red
+		}
+		tbl.Rich(
+			[]string{p.Namespace, p.Name, service(p.Leaf.SPIFFEID), p.Leaf.SerialNumber, p.Leaf.NotAfter.Format(time.RFC3339), strconv.Itoa(len(p.Roots))},
+			[]string{"", "", "", "", expiryColor, ""},
+		)
+	}
+	if len(tbl.Rows) == 0 {
+		return nil
+	}
+	return c.table.Print(c.UI, tbl)
+}
+
+// printRotateStatus prints whether each proxy serves a leaf certificate from
+// the active root of the CA and trusts it, followed by a summary.
+func (c *Command) printRotateStatus(proxies []proxy, roots *consul.CARoots) error {
+	tbl := terminal.NewTable("Namespace", "Name", "Service", "Signed By", "Leaf Expiry", "Status")
+	var pending, total int
+	for _, p := range proxies {
+		if p.Leaf == nil {
+			continue
+		}
+		total++
+		status, signer := rotateStatus(p, roots)
+		statusColor := terminal.Green
+		if status != statusRotated {
+			statusColor = terminal.Red
+			pending++
+		}
+		tbl.Rich(
+			[]string{p.Namespace, p.Name, service(p.Leaf.SPIFFEID), valueOrDash(signer), p.Leaf.NotAfter.Format(time.RFC3339), status},
+			[]string{"", "", "", "", "", statusColor},
+		)
+	}
+	if total == 0 {
+		return nil
+	}
+	if err := c.table.Print(c.UI, tbl); err != nil {
+		return err
+	}
+
+	switch {
+	case len(roots.Roots) < 2 && pending == 0:
+		c.UI.Output("No CA rotation in progress, all %d proxies serve certificates from the active root.", total, terminal.WithSuccessStyle())
+	case pending == 0:
+		c.UI.Output("All %d proxies serve certificates from the active root.", total, terminal.WithSuccessStyle())
+	default:
+		c.UI.Output("%d of %d proxies do not serve certificates from the active root yet.", pending, total, terminal.WithWarningStyle())
+	}
+	return nil
+}
+
+// service returns the name of the service in the SPIFFE ID of a service's
+// leaf certificate.
+func service(spiffeID string) string {
+	idx := strings.LastIndex(spiffeID, "/svc/")
+	if idx < 0 {
+		return "-"
+	}
+	return spiffeID[idx+len("/svc/"):]
+}
+
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Help returns a description of the command and how it is used.
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s proxy certs [flags]\n\n" +
+		"The certificates are read from the Envoy admin API of each pod with a proxy through a port forward.\n" +
+		"Consul delivers new certificates to the proxies over xDS when the Connect CA is rotated, and Envoy\n" +
+		"uses them for new connections without restarting. With -rotate-status, the leaf certificate of each\n" +
+		"proxy is compared with the roots of the CA, to show the proxies that still serve certificates from\n" +
+		"the old root or don't trust the new one yet.\n\n" +
+		"Examples:\n" +
+		"  $ consul-k8s proxy certs -n web\n" +
+		"  $ consul-k8s proxy certs -A -rotate-status\n\n" +
+		c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *Command) Synopsis() string {
+	return "Show the certificates of the proxies and their progress during a CA rotation."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *Command) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *Command) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
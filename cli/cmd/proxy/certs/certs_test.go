@@ -0,0 +1,365 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/hashicorp/consul-k8s/cli/helper/test"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+// TestValidateFlags tests the validate flags function.
+func TestValidateFlags(t *testing.T) {
+	// The following cases should all error, if they fail to this test fails.
+	testCases := []struct {
+		description string
+		input       []string
+	}{
+		{
+			"Should disallow non-flag arguments.",
+			[]string{"web"},
+		},
+		{
+			"Should disallow -namespace with -all-namespaces.",
+			[]string{"-namespace", "web", "-all-namespaces"},
+		},
+		{
+			"Should disallow -token without -rotate-status.",
+			[]string{"-token", "secret"},
+		},
+		{
+			"Should disallow -consul-namespace without -rotate-status.",
+			[]string{"-consul-namespace", "consul"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		c := getInitializedCommand(t)
+		t.Run(testCase.description, func(t *testing.T) {
+			if err := c.validateFlags(testCase.input); err == nil {
+				t.Errorf("Test case should have failed.")
+			}
+		})
+	}
+}
+
+// Test that the proxies are compared with the roots of the CA during a
+// rotation from an old to a new root.
+func TestRun_RotateStatus(t *testing.T) {
+	ca := newTestCA(t)
+
+	c := getInitializedCommand(t)
+	c.kubernetes = fake.NewSimpleClientset(
+		test.ServerPod("consul-server-0", "consul", ""),
+		injectedPod("web", "default", corev1.PodRunning),
+		injectedPod("api", "default", corev1.PodRunning),
+		injectedPod("db", "default", corev1.PodPending),
+	)
+	c.restConfig = &rest.Config{}
+	c.fetchDump = func(_ context.Context, pod *corev1.Pod) ([]byte, error) {
+		switch pod.Name {
+		case "web":
+			return configDump(t, ca.newLeaf, ca.oldRoot, ca.newRoot), nil
+		case "api":
+			return configDump(t, ca.oldLeaf, ca.oldRoot), nil
+		}
+		t.Fatalf("unexpected probe of pod %s", pod.Name)
+		return nil, nil
+	}
+	c.openServer = func(pod *corev1.Pod) (*consul.Client, func(), error) {
+		require.Equal(t, "consul-server-0", pod.Name)
+		return ca.server(t), func() {}, nil
+	}
+
+	require.Equal(t, 0, c.Run([]string{"-rotate-status", "-consul-namespace", "consul"}))
+}
+
+func TestRun_NoRunningServer(t *testing.T) {
+	c := getInitializedCommand(t)
+	c.kubernetes = fake.NewSimpleClientset(injectedPod("web", "default", corev1.PodRunning))
+	c.restConfig = &rest.Config{}
+	require.Equal(t, 1, c.Run([]string{"-rotate-status", "-consul-namespace", "consul"}))
+}
+
+func TestProbe(t *testing.T) {
+	ca := newTestCA(t)
+
+	c := getInitializedCommand(t)
+	c.fetchDump = func(_ context.Context, pod *corev1.Pod) ([]byte, error) {
+		switch pod.Name {
+		case "web":
+			return configDump(t, ca.newLeaf, ca.oldRoot, ca.newRoot), nil
+		case "api":
+			return nil, errors.New("connection refused")
+		case "gateway":
+			return configDump(t, nil, ca.newRoot), nil
+		}
+		t.Fatalf("unexpected probe of pod %s", pod.Name)
+		return nil, nil
+	}
+
+	proxies := c.probe([]corev1.Pod{
+		*injectedPod("web", "default", corev1.PodRunning),
+		*injectedPod("api", "default", corev1.PodRunning),
+		*injectedPod("gateway", "default", corev1.PodRunning),
+		*injectedPod("db", "backend", corev1.PodPending),
+	})
+	require.Len(t, proxies, 4)
+
+	require.Equal(t, "backend", proxies[0].Namespace)
+	require.Equal(t, "db", proxies[0].Name)
+	require.Equal(t, "pod is pending", proxies[0].Error)
+
+	require.Equal(t, "api", proxies[1].Name)
+	require.Equal(t, "connection refused", proxies[1].Error)
+
+	require.Equal(t, "gateway", proxies[2].Name)
+	require.Equal(t, "no leaf certificate found in the Envoy configuration", proxies[2].Error)
+
+	require.Equal(t, "web", proxies[3].Name)
+	require.Empty(t, proxies[3].Error)
+	require.Equal(t, "web", service(proxies[3].Leaf.SPIFFEID))
+	require.Len(t, proxies[3].Roots, 2)
+}
+
+func TestRotateStatus(t *testing.T) {
+	ca := newTestCA(t)
+	roots := ca.roots()
+	otherCA := newTestCA(t)
+
+	cases := map[string]struct {
+		leaf      *x509.Certificate
+		roots     []*x509.Certificate
+		expStatus string
+		expSigner string
+	}{
+		"rotated": {
+			leaf:      ca.newLeaf,
+			roots:     []*x509.Certificate{ca.oldRoot, ca.newRoot},
+			expStatus: statusRotated,
+			expSigner: "Consul CA 2",
+		},
+		"leaf from the old root": {
+			leaf:      ca.oldLeaf,
+			roots:     []*x509.Certificate{ca.oldRoot, ca.newRoot},
+			expStatus: statusOldRoot,
+			expSigner: "Consul CA 1",
+		},
+		"new root not trusted": {
+			leaf:      ca.newLeaf,
+			roots:     []*x509.Certificate{ca.oldRoot},
+			expStatus: statusNotTrusted,
+			expSigner: "Consul CA 2",
+		},
+		"leaf from another CA": {
+			leaf:      otherCA.newLeaf,
+			roots:     []*x509.Certificate{otherCA.newRoot},
+			expStatus: statusUnknownRoot,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			cmd := getInitializedCommand(t)
+			cmd.fetchDump = func(context.Context, *corev1.Pod) ([]byte, error) {
+				return configDump(t, c.leaf, c.roots...), nil
+			}
+			proxies := cmd.probe([]corev1.Pod{*injectedPod("web", "default", corev1.PodRunning)})
+			require.Empty(t, proxies[0].Error)
+
+			status, signer := rotateStatus(proxies[0], roots)
+			require.Equal(t, c.expStatus, status)
+			require.Equal(t, c.expSigner, signer)
+		})
+	}
+}
+
+// testCA is a Connect CA in the middle of a rotation from an old root to a
+// new root, with a leaf certificate from each.
+type testCA struct {
+	oldRoot, newRoot *x509.Certificate
+	oldLeaf, newLeaf *x509.Certificate
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	oldRoot, oldKey := generateCert(t, "Consul CA 1", "", nil, nil)
+	newRoot, newKey := generateCert(t, "Consul CA 2", "", nil, nil)
+	spiffeID := "spiffe://11111111.consul/ns/default/dc/dc1/svc/web"
+	oldLeaf, _ := generateCert(t, "web", spiffeID, oldRoot, oldKey)
+	newLeaf, _ := generateCert(t, "web", spiffeID, newRoot, newKey)
+	return &testCA{oldRoot: oldRoot, newRoot: newRoot, oldLeaf: oldLeaf, newLeaf: newLeaf}
+}
+
+// roots returns the roots of the CA like Consul's /v1/connect/ca/roots
+// endpoint does.
+func (ca *testCA) roots() *consul.CARoots {
+	root := func(id string, cert *x509.Certificate) consul.CARoot {
+		return consul.CARoot{
+			ID:           id,
+			Name:         cert.Subject.CommonName,
+			SigningKeyID: colonHex(cert.SubjectKeyId),
+			NotAfter:     cert.NotAfter,
+			RootCert:     encodePEM(cert),
+			Active:       id == "new",
+		}
+	}
+	return &consul.CARoots{
+		ActiveRootID: "new",
+		TrustDomain:  "11111111.consul",
+		Roots:        []consul.CARoot{root("old", ca.oldRoot), root("new", ca.newRoot)},
+	}
+}
+
+// server returns a client for a Consul HTTP API that serves the roots of the
+// CA.
+func (ca *testCA) server(t *testing.T) *consul.Client {
+	t.Helper()
+	raw, err := json.Marshal(ca.roots())
+	require.NoError(t, err)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/connect/ca/roots", r.URL.Path)
+		w.Write(raw)
+	}))
+	t.Cleanup(srv.Close)
+	return &consul.Client{Addr: strings.TrimPrefix(srv.URL, "http://")}
+}
+
+// configDump returns a config dump with a public listener that presents the
+// leaf certificate, if it is set, and trusts the roots.
+func configDump(t *testing.T, leaf *x509.Certificate, roots ...*x509.Certificate) []byte {
+	t.Helper()
+	var rootsPEM string
+	for _, root := range roots {
+		rootsPEM += encodePEM(root)
+	}
+	tlsContext := map[string]interface{}{
+		"validation_context": map[string]interface{}{
+			"trusted_ca": map[string]interface{}{"inline_string": rootsPEM},
+		},
+	}
+	if leaf != nil {
+		tlsContext["tls_certificates"] = []interface{}{map[string]interface{}{
+			"certificate_chain": map[string]interface{}{"inline_string": encodePEM(leaf)},
+			"private_key":       map[string]interface{}{"inline_string": "[redacted]"},
+		}}
+	}
+	dump := map[string]interface{}{
+		"configs": []interface{}{
+			map[string]interface{}{
+				"@type": "type.googleapis.com/envoy.admin.v3.ListenersConfigDump",
+				"dynamic_listeners": []interface{}{map[string]interface{}{"active_state": map[string]interface{}{"listener": map[string]interface{}{
+					"name": "public_listener",
+					"filter_chains": []interface{}{map[string]interface{}{"transport_socket": map[string]interface{}{
+						"typed_config": map[string]interface{}{"common_tls_context": tlsContext},
+					}}},
+				}}}},
+			},
+		},
+	}
+	raw, err := json.Marshal(dump)
+	require.NoError(t, err)
+	return raw
+}
+
+// generateCert generates a CA certificate, or a leaf certificate with the
+// SPIFFE ID signed by the parent if it is set.
+func generateCert(t *testing.T, cn, spiffeID string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(72 * time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+	}
+	if parent == nil {
+		template.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+	if spiffeID != "" {
+		uri, err := url.Parse(spiffeID)
+		require.NoError(t, err)
+		template.URIs = []*url.URL{uri}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func encodePEM(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
+
+// colonHex formats the key ID like Consul formats signing key IDs.
+func colonHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i := range b {
+		parts[i] = fmt.Sprintf("%02x", b[i])
+	}
+	return strings.Join(parts, ":")
+}
+
+func injectedPod(name, namespace string, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"consul.hashicorp.com/connect-inject-status": "injected"},
+		},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+}
+
+// getInitializedCommand sets up a command struct for tests.
+func getInitializedCommand(t *testing.T) *Command {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+		UI:  terminal.NewBasicUI(context.Background()),
+	}
+
+	c := &Command{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
@@ -0,0 +1,356 @@
+package list
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/envoy"
+	"github.com/posener/complete"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	flagNameNamespace = "namespace"
+	defaultNamespace  = "default"
+
+	flagNameAllNamespaces = "all-namespaces"
+	defaultAllNamespaces  = false
+
+	flagNameOutput = "output"
+	outputTable    = "table"
+	outputJSON     = "json"
+	defaultOutput  = outputTable
+)
+
+// proxy is the summary of a pod with an injected proxy.
+type proxy struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Injection is the injection status set by the connect injector.
+	Injection string `json:"injection"`
+	// DataplaneVersion is the version of consul-k8s-control-plane that set
+	// up the proxy.
+	DataplaneVersion string `json:"dataplaneVersion,omitempty"`
+
+	// The following fields are read from the admin API of the proxy and are
+	// not set if it could not be reached.
+	EnvoyVersion string     `json:"envoyVersion,omitempty"`
+	CertExpiry   *time.Time `json:"certExpiry,omitempty"`
+	XDSConnected *bool      `json:"xdsConnected,omitempty"`
+
+	// Error is why the admin API of the proxy could not be reached.
+	Error string `json:"error,omitempty"`
+}
+
+type Command struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+	restConfig *rest.Config
+
+	// fetchStatus fetches the status of the proxy in a pod. It port forwards
+	// to the admin API of the proxy if nil, and is only set in tests.
+	fetchStatus func(ctx context.Context, pod *corev1.Pod) (*envoy.Status, error)
+
+	set *flag.Sets
+
+	flagNamespace     string
+	flagAllNamespaces bool
+	flagOutput        string
+	flagAdminPort     int
+
+	flagKubeConfig  string
+	flagKubeContext string
+
+	table common.TableFlags
+
+	once sync.Once
+	help string
+}
+
+func (c *Command) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:       flagNameNamespace,
+		Aliases:    []string{"n"},
+		Target:     &c.flagNamespace,
+		Default:    defaultNamespace,
+		Usage:      "The namespace to list proxies in.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameAllNamespaces,
+		Aliases: []string{"A"},
+		Target:  &c.flagAllNamespaces,
+		Default: defaultAllNamespaces,
+		Usage:   "List proxies in all namespaces.",
+	})
+	f.EnumSingleVar(&flag.EnumSingleVar{
+		Name:    flagNameOutput,
+		Aliases: []string{"o"},
+		Target:  &c.flagOutput,
+		Default: defaultOutput,
+		Values:  []string{outputTable, outputJSON},
+		Usage:   "Set the format the proxies are printed in.",
+	})
+	f.IntVar(&flag.IntVar{
+		Name:    common.FlagNameAdminPort,
+		Target:  &c.flagAdminPort,
+		Default: common.DefaultAdminPort,
+		Usage:   "The port of the Envoy admin API in the pods.",
+	})
+	c.table.Flags(f)
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run lists the pods with injected proxies and the health of each proxy.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("proxy list")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+	if _, err := common.InitKubernetes(c.flagKubeConfig, c.flagKubeContext, &c.restConfig, &c.kubernetes); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	namespace := c.flagNamespace
+	if c.flagAllNamespaces {
+		namespace = metav1.NamespaceAll
+	}
+	pods, err := c.kubernetes.CoreV1().Pods(namespace).List(c.Ctx, metav1.ListOptions{LabelSelector: common.InjectedPodSelector})
+	if err != nil {
+		c.UI.Output("Error listing pods: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+
+	proxies := c.probe(pods.Items)
+
+	switch c.flagOutput {
+	case outputJSON:
+		out, err := json.MarshalIndent(proxies, "", "  ")
+		if err != nil {
+			c.UI.Output("Error formatting proxies: %v", err, terminal.WithErrorStyle())
+			return 1
+		}
+		c.UI.Output("%s", out)
+	default:
+		if err := c.printTable(proxies); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+	}
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *Command) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if c.flagAllNamespaces && c.flagNamespace != defaultNamespace {
+		return fmt.Errorf("-%s and -%s cannot both be set", flagNameNamespace, flagNameAllNamespaces)
+	}
+	return c.table.Validate()
+}
+
+// probe summarizes the proxies of the pods, fetching the status of several
+// proxies at once. The proxies are sorted by namespace and name.
+func (c *Command) probe(pods []corev1.Pod) []proxy {
+	proxies := make([]proxy, len(pods))
+	sem := make(chan struct{}, common.ProbeConcurrency)
+	var wg sync.WaitGroup
+	for i := range pods {
+		pod := &pods[i]
+		proxies[i] = proxy{
+			Namespace:        pod.Namespace,
+			Name:             pod.Name,
+			Injection:        pod.Annotations[common.InjectStatusAnnotation],
+			DataplaneVersion: dataplaneVersion(pod),
+		}
+		if pod.Status.Phase != corev1.PodRunning {
+			proxies[i].Error = fmt.Sprintf("pod is %s", strings.ToLower(string(pod.Status.Phase)))
+			continue
+		}
+
+		wg.Add(1)
+		go func(p *proxy, pod *corev1.Pod) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			ctx, cancel := context.WithTimeout(c.Ctx, common.ProbeTimeout)
+			defer cancel()
+			status, err := c.fetchProxyStatus(ctx, pod)
+			if err != nil {
+				p.Error = err.Error()
+				return
+			}
+			p.EnvoyVersion = status.Version
+			if !status.CertExpiry.IsZero() {
+				expiry := status.CertExpiry
+				p.CertExpiry = &expiry
+			}
+			connected := status.XDSConnected
+			p.XDSConnected = &connected
+		}(&proxies[i], pod)
+	}
+	wg.Wait()
+
+	sort.Slice(proxies, func(i, j int) bool {
+		if proxies[i].Namespace != proxies[j].Namespace {
+			return proxies[i].Namespace < proxies[j].Namespace
+		}
+		return proxies[i].Name < proxies[j].Name
+	})
+	return proxies
+}
+
+// fetchProxyStatus fetches the status of the proxy of the pod through a port
+// forward to its admin API.
+func (c *Command) fetchProxyStatus(ctx context.Context, pod *corev1.Pod) (*envoy.Status, error) {
+	if c.fetchStatus != nil {
+		return c.fetchStatus(ctx, pod)
+	}
+
+	adminAddr, closeAdmin, err := common.PortForwarder{KubeClient: c.kubernetes, RestConfig: c.restConfig}.Open(pod, c.flagAdminPort)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAdmin()
+	return envoy.FetchStatus(ctx, adminAddr)
+}
+
+// printTable prints the proxies as a table, followed by the errors reaching
+// the proxies that could not be probed.
+func (c *Command) printTable(proxies []proxy) error {
+	if len(proxies) == 0 {
+		if c.flagAllNamespaces {
+			c.UI.Output("No proxies found.")
+		} else {
+			c.UI.Output("No proxies found in namespace %q.", c.flagNamespace)
+		}
+		return nil
+	}
+
+	tbl := terminal.NewTable("Namespace", "Name", "Injection", "Envoy Version", "Dataplane Version", "Cert Expiry", "xDS")
+	now := time.Now()
+	for _, p := range proxies {
+		expiry, expiryColor := "-", ""
+		if p.CertExpiry != nil {
+			expiry = p.CertExpiry.Format(time.RFC3339)
+			if p.CertExpiry.Sub(now) < common.CertExpiryWarning {
+				expiryColor = terminal.Red
+			}
+		}
+		xds, xdsColor := "-", ""
+		if p.XDSConnected != nil {
+			if *p.XDSConnected {
+				xds, xdsColor = "connected", terminal.Green
+			} else {
+				xds, xdsColor = "disconnected", terminal.Red
+			}
+		}
+		tbl.Rich(
+			[]string{p.Namespace, p.Name, p.Injection, valueOrDash(p.EnvoyVersion), valueOrDash(p.DataplaneVersion), expiry, xds},
+			[]string{"", "", "", "", "", expiryColor, xdsColor},
+		)
+	}
+	if err := c.table.Print(c.UI, tbl); err != nil {
+		return err
+	}
+
+	for _, p := range proxies {
+		if p.Error != "" {
+			c.UI.Output("Could not reach the proxy of %s/%s: %s", p.Namespace, p.Name, p.Error, terminal.WithWarningStyle())
+		}
+	}
+	return nil
+}
+
+// dataplaneVersion returns the version of consul-k8s-control-plane that set up
+// the proxy of the pod, i.e. the tag of the image of the connect injector's
+// init container.
+func dataplaneVersion(pod *corev1.Pod) string {
+	for _, container := range pod.Spec.InitContainers {
+		if container.Name == common.InitContainerName {
+			return common.ImageTag(container.Image)
+		}
+	}
+	return ""
+}
+
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Help returns a description of the command and how it is used.
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s proxy list [flags]\n\n" +
+		"The injection status and dataplane version are read from the pods. The Envoy version, the earliest\n" +
+		"certificate expiry and whether Envoy is connected to its xDS server are read from the Envoy admin API.\n\n" +
+		c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *Command) Synopsis() string {
+	return "List the pods with a Consul proxy and the health of each proxy."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *Command) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *Command) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
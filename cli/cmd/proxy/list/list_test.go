@@ -0,0 +1,130 @@
+package list
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/envoy"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestValidateFlags tests the validate flags function.
+func TestValidateFlags(t *testing.T) {
+	// The following cases should all error, if they fail to this test fails.
+	testCases := []struct {
+		description string
+		input       []string
+	}{
+		{
+			"Should disallow non-flag arguments.",
+			[]string{"web"},
+		},
+		{
+			"Should disallow a namespace with all namespaces.",
+			[]string{"-n", "consul", "-A"},
+		},
+		{
+			"Should disallow an unknown output format.",
+			[]string{"-o", "yaml"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		c := getInitializedCommand(t)
+		t.Run(testCase.description, func(t *testing.T) {
+			if err := c.validateFlags(testCase.input); err == nil {
+				t.Errorf("Test case should have failed.")
+			}
+		})
+	}
+}
+
+func TestProbe(t *testing.T) {
+	expiry := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	c := getInitializedCommand(t)
+	c.fetchStatus = func(_ context.Context, pod *corev1.Pod) (*envoy.Status, error) {
+		switch pod.Name {
+		case "web":
+			return &envoy.Status{Version: "1.20.1", CertExpiry: expiry, XDSConnected: true}, nil
+		case "api":
+			return nil, errors.New("connection refused")
+		}
+		t.Fatalf("unexpected probe of pod %s", pod.Name)
+		return nil, nil
+	}
+
+	pods := []corev1.Pod{
+		injectedPod("web", "default", corev1.PodRunning, "hashicorp/consul-k8s-control-plane:0.42.0"),
+		injectedPod("api", "default", corev1.PodRunning, "registry.local:5000/consul-k8s-control-plane@sha256:abc"),
+		injectedPod("db", "backend", corev1.PodPending, "hashicorp/consul-k8s-control-plane"),
+	}
+
+	connected := true
+	require.Equal(t, []proxy{
+		{
+			Namespace:        "backend",
+			Name:             "db",
+			Injection:        "injected",
+			DataplaneVersion: "latest",
+			Error:            "pod is pending",
+		},
+		{
+			Namespace:        "default",
+			Name:             "api",
+			Injection:        "injected",
+			DataplaneVersion: "sha256:abc",
+			Error:            "connection refused",
+		},
+		{
+			Namespace:        "default",
+			Name:             "web",
+			Injection:        "injected",
+			DataplaneVersion: "0.42.0",
+			EnvoyVersion:     "1.20.1",
+			CertExpiry:       &expiry,
+			XDSConnected:     &connected,
+		},
+	}, c.probe(pods))
+}
+
+func injectedPod(name, namespace string, phase corev1.PodPhase, initImage string) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Annotations: map[string]string{common.InjectStatusAnnotation: "injected"},
+		},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: common.InitContainerName, Image: initImage}},
+		},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+}
+
+// getInitializedCommand sets up a command struct for tests.
+func getInitializedCommand(t *testing.T) *Command {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+	}
+
+	c := &Command{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
@@ -0,0 +1,304 @@
+package loglevel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/envoy"
+	"github.com/posener/complete"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	flagNameNamespace = "namespace"
+
+	flagNameSelector = "selector"
+
+	flagNameUpdateLevel = "update-level"
+
+	// requestTimeout bounds the time spent on the admin API of a single proxy.
+	requestTimeout = 10 * time.Second
+)
+
+type Command struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+	restConfig *rest.Config
+
+	// openAdmin returns the address of the admin API of the proxy in the pod
+	// and a function that closes the connection. It port forwards to the pod
+	// if it is not set, which lets tests replace it.
+	openAdmin common.PortOpener
+
+	set *flag.Sets
+
+	flagPodName     string
+	flagNamespace   string
+	flagSelector    string
+	flagUpdateLevel []string
+	flagAdminPort   int
+
+	flagKubeConfig  string
+	flagKubeContext string
+
+	// levels are the logger levels to set, parsed from flagUpdateLevel. The
+	// level keyed by "" is set on all loggers.
+	levels map[string]string
+
+	once sync.Once
+	help string
+}
+
+func (c *Command) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:       flagNameNamespace,
+		Aliases:    []string{"n"},
+		Target:     &c.flagNamespace,
+		Default:    "default",
+		Usage:      "The namespace of the pods.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameSelector,
+		Aliases: []string{"l"},
+		Target:  &c.flagSelector,
+		Usage:   "Get or update the log levels of the proxies in all pods in the namespace matching this label selector instead of a single pod.",
+	})
+	f.StringSliceVar(&flag.StringSliceVar{
+		Name:    flagNameUpdateLevel,
+		Aliases: []string{"u"},
+		Target:  &c.flagUpdateLevel,
+		Usage: fmt.Sprintf("Update the log levels, either of all loggers, e.g. \"debug\", or of single loggers, e.g. \"grpc:debug,http:info\". "+
+			"Valid levels are %s.", strings.Join(envoy.LogLevels, ", ")),
+	})
+	f.IntVar(&flag.IntVar{
+		Name:    common.FlagNameAdminPort,
+		Target:  &c.flagAdminPort,
+		Default: common.DefaultAdminPort,
+		Usage:   "The port of the Envoy admin API in the pods.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run prints the log levels of the proxies in the selected pods, updating
+// them first if levels are given.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("proxy log")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+	if _, err := common.InitKubernetes(c.flagKubeConfig, c.flagKubeContext, &c.restConfig, &c.kubernetes); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	var pods []corev1.Pod
+	if c.flagPodName != "" {
+		pod, err := c.kubernetes.CoreV1().Pods(c.flagNamespace).Get(c.Ctx, c.flagPodName, metav1.GetOptions{})
+		if err != nil {
+			c.UI.Output("Error getting pod %s/%s: %v", c.flagNamespace, c.flagPodName, err, terminal.WithErrorStyle())
+			return 1
+		}
+		pods = append(pods, *pod)
+	} else {
+		list, err := c.kubernetes.CoreV1().Pods(c.flagNamespace).List(c.Ctx, metav1.ListOptions{LabelSelector: c.flagSelector})
+		if err != nil {
+			c.UI.Output("Error listing pods: %v", err, terminal.WithErrorStyle())
+			return 1
+		}
+		if len(list.Items) == 0 {
+			c.UI.Output("No pods matching %q found in namespace %q.", c.flagSelector, c.flagNamespace, terminal.WithErrorStyle())
+			return 1
+		}
+		pods = list.Items
+		sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	}
+
+	code := 0
+	for i := range pods {
+		pod := &pods[i]
+		levels, err := c.logLevels(pod)
+		if err != nil {
+			c.UI.Output("Error with the log levels of the proxy in pod %s/%s: %v", pod.Namespace, pod.Name, err, terminal.WithErrorStyle())
+			code = 1
+			continue
+		}
+		c.printLevels(pod, levels)
+	}
+	return code
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *Command) validateFlags(args []string) error {
+	// The pod name comes before the flags.
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		c.flagPodName = args[0]
+		args = args[1:]
+	}
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments after the pod name")
+	}
+	if c.flagPodName == "" && c.flagSelector == "" {
+		return fmt.Errorf("a pod name or -%s must be set", flagNameSelector)
+	}
+	if c.flagPodName != "" && c.flagSelector != "" {
+		return fmt.Errorf("a pod name and -%s cannot both be set", flagNameSelector)
+	}
+
+	levels, err := parseLevels(c.flagUpdateLevel)
+	if err != nil {
+		return err
+	}
+	c.levels = levels
+	return nil
+}
+
+// parseLevels parses the levels to set, each either a level for all loggers
+// or a "<logger>:<level>" pair.
+func parseLevels(values []string) (map[string]string, error) {
+	levels := make(map[string]string)
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		var logger, level string
+		if parts := strings.SplitN(value, ":", 2); len(parts) == 2 {
+			logger, level = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+			if logger == "" {
+				return nil, fmt.Errorf("-%s %q is missing the logger name", flagNameUpdateLevel, value)
+			}
+		} else {
+			level = value
+		}
+		if !validLevel(level) {
+			return nil, fmt.Errorf("-%s %q has an invalid level, must be one of %s", flagNameUpdateLevel, value, strings.Join(envoy.LogLevels, ", "))
+		}
+		if _, ok := levels[logger]; ok {
+			if logger == "" {
+				return nil, fmt.Errorf("-%s has more than one level for all loggers", flagNameUpdateLevel)
+			}
+			return nil, fmt.Errorf("-%s has more than one level for logger %q", flagNameUpdateLevel, logger)
+		}
+		levels[logger] = level
+	}
+	return levels, nil
+}
+
+func validLevel(level string) bool {
+	for _, l := range envoy.LogLevels {
+		if level == l {
+			return true
+		}
+	}
+	return false
+}
+
+// logLevels updates the log levels of the proxy in the pod if levels are
+// given, and returns the levels of all of its loggers.
+func (c *Command) logLevels(pod *corev1.Pod) (map[string]string, error) {
+	open := common.PortForwarder{KubeClient: c.kubernetes, RestConfig: c.restConfig}.Opener(c.openAdmin)
+	adminAddr, closeAdmin, err := open(pod, c.flagAdminPort)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAdmin()
+
+	ctx, cancel := context.WithTimeout(c.Ctx, requestTimeout)
+	defer cancel()
+	if len(c.levels) > 0 {
+		return envoy.SetLogLevels(ctx, adminAddr, c.levels)
+	}
+	return envoy.FetchLogLevels(ctx, adminAddr)
+}
+
+// printLevels prints the log levels of the proxy in the pod as a table sorted
+// by logger name.
+func (c *Command) printLevels(pod *corev1.Pod, levels map[string]string) {
+	loggers := make([]string, 0, len(levels))
+	for logger := range levels {
+		loggers = append(loggers, logger)
+	}
+	sort.Strings(loggers)
+
+	c.UI.Output("Envoy log levels of pod %s/%s", pod.Namespace, pod.Name, terminal.WithHeaderStyle())
+	tbl := terminal.NewTable("Logger", "Level")
+	for _, logger := range loggers {
+		tbl.Rich([]string{logger, levels[logger]}, nil)
+	}
+	c.UI.Table(tbl)
+}
+
+// Help returns a description of the command and how it is used.
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s proxy log <pod-name> [flags]\n" +
+		"       consul-k8s proxy log -selector <label-selector> [flags]\n\n" +
+		"The log levels are read from and updated through the Envoy admin API of each pod,\n" +
+		"which is reached through a port forward.\n\n" +
+		"Examples:\n" +
+		"  $ consul-k8s proxy log web-6d7b8c9f5-x2x4p -update-level debug\n" +
+		"  $ consul-k8s proxy log -selector app=web -update-level grpc:debug,http:info\n\n" +
+		c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *Command) Synopsis() string {
+	return "Get or update the Envoy log levels of proxies."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *Command) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command, which
+// predicts the names of the pods with an injected proxy.
+func (c *Command) AutocompleteArgs() complete.Predictor {
+	return common.PredictKubePods(common.InjectedPodSelector)
+}
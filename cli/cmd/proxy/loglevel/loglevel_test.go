@@ -0,0 +1,210 @@
+package loglevel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+// TestValidateFlags tests the validate flags function.
+func TestValidateFlags(t *testing.T) {
+	// The following cases should all error, if they fail to this test fails.
+	testCases := []struct {
+		description string
+		input       []string
+	}{
+		{
+			"Should require a pod name or a selector.",
+			[]string{},
+		},
+		{
+			"Should disallow a pod name with a selector.",
+			[]string{"web", "-selector", "app=web"},
+		},
+		{
+			"Should disallow non-flag arguments after the pod name.",
+			[]string{"web", "-namespace", "default", "api"},
+		},
+		{
+			"Should disallow an invalid level.",
+			[]string{"web", "-update-level", "verbose"},
+		},
+		{
+			"Should disallow an invalid logger level.",
+			[]string{"web", "-update-level", "grpc:verbose"},
+		},
+		{
+			"Should disallow a level without a logger name.",
+			[]string{"web", "-update-level", ":debug"},
+		},
+		{
+			"Should disallow two levels for a logger.",
+			[]string{"web", "-update-level", "grpc:debug,grpc:info"},
+		},
+		{
+			"Should disallow two levels for all loggers.",
+			[]string{"web", "-update-level", "debug,info"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		c := getInitializedCommand(t)
+		t.Run(testCase.description, func(t *testing.T) {
+			if err := c.validateFlags(testCase.input); err == nil {
+				t.Errorf("Test case should have failed.")
+			}
+		})
+	}
+}
+
+func TestParseLevels(t *testing.T) {
+	levels, err := parseLevels([]string{"info", "grpc:debug", " http : trace "})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"": "info", "grpc": "debug", "http": "trace"}, levels)
+}
+
+func TestRun(t *testing.T) {
+	cases := map[string]struct {
+		args      []string
+		expCode   int
+		expLevels map[string]map[string]string
+	}{
+		"get levels of a pod": {
+			args:    []string{"web-1"},
+			expCode: 0,
+			expLevels: map[string]map[string]string{
+				"web-1": {"grpc": "info", "http": "info"},
+				"web-2": {"grpc": "info", "http": "info"},
+			},
+		},
+		"update levels of a pod": {
+			args:    []string{"web-1", "-update-level", "grpc:debug"},
+			expCode: 0,
+			expLevels: map[string]map[string]string{
+				"web-1": {"grpc": "debug", "http": "info"},
+				"web-2": {"grpc": "info", "http": "info"},
+			},
+		},
+		"update levels of the pods matching a selector": {
+			args:    []string{"-selector", "app=web", "-update-level", "warning,http:trace"},
+			expCode: 0,
+			expLevels: map[string]map[string]string{
+				"web-1": {"grpc": "warning", "http": "trace"},
+				"web-2": {"grpc": "warning", "http": "trace"},
+			},
+		},
+		"no pods matching the selector": {
+			args:    []string{"-selector", "app=api"},
+			expCode: 1,
+		},
+		"pod not found": {
+			args:    []string{"api-1"},
+			expCode: 1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			proxies := map[string]*fakeEnvoy{
+				"web-1": newFakeEnvoy(),
+				"web-2": newFakeEnvoy(),
+			}
+			var servers []*httptest.Server
+			defer func() {
+				for _, srv := range servers {
+					srv.Close()
+				}
+			}()
+
+			c := getInitializedCommand(t)
+			c.kubernetes = fake.NewSimpleClientset(webPod("web-1"), webPod("web-2"))
+			c.restConfig = &rest.Config{}
+			c.openAdmin = func(pod *corev1.Pod, port int) (string, func(), error) {
+				srv := httptest.NewServer(proxies[pod.Name])
+				servers = append(servers, srv)
+				return strings.TrimPrefix(srv.URL, "http://"), func() {}, nil
+			}
+
+			require.Equal(t, tc.expCode, c.Run(tc.args))
+			for pod, levels := range tc.expLevels {
+				require.Equal(t, levels, proxies[pod].levels, pod)
+			}
+		})
+	}
+}
+
+// fakeEnvoy is the /logging endpoint of the Envoy admin API.
+type fakeEnvoy struct {
+	mu     sync.Mutex
+	levels map[string]string
+}
+
+func newFakeEnvoy() *fakeEnvoy {
+	return &fakeEnvoy{levels: map[string]string{"grpc": "info", "http": "info"}}
+}
+
+func (e *fakeEnvoy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if r.URL.Path != "/logging" || r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	for key, values := range r.URL.Query() {
+		if key == "level" {
+			for logger := range e.levels {
+				e.levels[logger] = values[0]
+			}
+		} else {
+			e.levels[key] = values[0]
+		}
+	}
+	fmt.Fprintln(w, "active loggers:")
+	for logger, level := range e.levels {
+		fmt.Fprintf(w, "  %s: %s\n", logger, level)
+	}
+}
+
+func webPod(name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{"app": "web"},
+		},
+	}
+}
+
+// getInitializedCommand sets up a command struct for tests.
+func getInitializedCommand(t *testing.T) *Command {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+	}
+
+	c := &Command{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
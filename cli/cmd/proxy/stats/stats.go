@@ -0,0 +1,297 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/envoy"
+	"github.com/posener/complete"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	flagNameNamespace = "namespace"
+
+	flagNameFilter = "filter"
+	// defaultFilter matches the counters of requests, connection failures and
+	// TLS handshakes and errors.
+	defaultFilter = `upstream_rq_total$|upstream_cx_connect_fail$|ssl\.(handshake|connection_error|fail_verify_.*)$`
+
+	flagNameInterval = "interval"
+	defaultInterval  = 10 * time.Second
+
+	flagNameAll = "all"
+
+	// requestTimeout bounds the time spent fetching a single sample.
+	requestTimeout = 10 * time.Second
+)
+
+// stat is the change of a stat between two samples.
+type stat struct {
+	Name  string
+	Value int64
+	Delta int64
+	// Rate is the change per second.
+	Rate float64
+}
+
+type Command struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+	restConfig *rest.Config
+
+	// openAdmin returns the address of the admin API of the proxy in the pod
+	// and a function that closes the connection. It port forwards to the pod
+	// if it is not set, which lets tests replace it.
+	openAdmin common.PortOpener
+
+	set *flag.Sets
+
+	flagPodName   string
+	flagNamespace string
+	flagFilter    string
+	flagInterval  time.Duration
+	flagAll       bool
+	flagAdminPort int
+
+	flagKubeConfig  string
+	flagKubeContext string
+
+	once sync.Once
+	help string
+}
+
+func (c *Command) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:       flagNameNamespace,
+		Aliases:    []string{"n"},
+		Target:     &c.flagNamespace,
+		Default:    "default",
+		Usage:      "The namespace of the pod.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameFilter,
+		Aliases: []string{"f"},
+		Target:  &c.flagFilter,
+		Default: defaultFilter,
+		Usage:   "Only sample the stats whose name matches this regular expression. Set it to \"\" to sample all stats.",
+	})
+	f.DurationVar(&flag.DurationVar{
+		Name:    flagNameInterval,
+		Aliases: []string{"i"},
+		Target:  &c.flagInterval,
+		Default: defaultInterval,
+		Usage:   "The time between the two samples the rates of change are computed from.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameAll,
+		Target:  &c.flagAll,
+		Default: false,
+		Usage:   "Also print the stats that didn't change between the samples.",
+	})
+	f.IntVar(&flag.IntVar{
+		Name:    common.FlagNameAdminPort,
+		Target:  &c.flagAdminPort,
+		Default: common.DefaultAdminPort,
+		Usage:   "The port of the Envoy admin API in the pod.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run samples the stats of the proxy in a pod twice and prints how much they
+// changed in between.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("proxy stats")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+	if _, err := common.InitKubernetes(c.flagKubeConfig, c.flagKubeContext, &c.restConfig, &c.kubernetes); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	pod, err := c.kubernetes.CoreV1().Pods(c.flagNamespace).Get(c.Ctx, c.flagPodName, metav1.GetOptions{})
+	if err != nil {
+		c.UI.Output("Error getting pod %s/%s: %v", c.flagNamespace, c.flagPodName, err, terminal.WithErrorStyle())
+		return 1
+	}
+	open := common.PortForwarder{KubeClient: c.kubernetes, RestConfig: c.restConfig}.Opener(c.openAdmin)
+	adminAddr, closeAdmin, err := open(pod, c.flagAdminPort)
+	if err != nil {
+		c.UI.Output("Error connecting to the proxy in pod %s/%s: %v", c.flagNamespace, c.flagPodName, err, terminal.WithErrorStyle())
+		return 1
+	}
+	defer closeAdmin()
+
+	first, err := c.sample(adminAddr)
+	if err != nil {
+		c.UI.Output("Error fetching stats: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+	start := time.Now()
+	c.UI.Output("Sampling stats for %s...", c.flagInterval)
+	select {
+	case <-c.Ctx.Done():
+		return 1
+	case <-time.After(c.flagInterval):
+	}
+	second, err := c.sample(adminAddr)
+	if err != nil {
+		c.UI.Output("Error fetching stats: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+
+	c.printStats(delta(first, second, time.Since(start)))
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *Command) validateFlags(args []string) error {
+	// The pod name comes before the flags.
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		c.flagPodName = args[0]
+		args = args[1:]
+	}
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments after the pod name")
+	}
+	if c.flagPodName == "" {
+		return errors.New("a pod name must be set")
+	}
+	if c.flagInterval <= 0 {
+		return fmt.Errorf("-%s must be positive", flagNameInterval)
+	}
+	if _, err := regexp.Compile(c.flagFilter); err != nil {
+		return fmt.Errorf("-%s is not a valid regular expression: %s", flagNameFilter, err)
+	}
+	return nil
+}
+
+// sample fetches the stats matching the filter.
+func (c *Command) sample(adminAddr string) (map[string]int64, error) {
+	ctx, cancel := context.WithTimeout(c.Ctx, requestTimeout)
+	defer cancel()
+	return envoy.FetchStats(ctx, adminAddr, c.flagFilter)
+}
+
+// delta returns the change of the stats in the second sample since the first,
+// sorted by decreasing rate of change and then by name. Stats that are only in
+// one of the samples are skipped.
+func delta(first, second map[string]int64, elapsed time.Duration) []stat {
+	var stats []stat
+	for name, value := range second {
+		prev, ok := first[name]
+		if !ok {
+			continue
+		}
+		s := stat{Name: name, Value: value, Delta: value - prev}
+		if elapsed > 0 {
+			s.Rate = float64(s.Delta) / elapsed.Seconds()
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Rate != stats[j].Rate {
+			return stats[i].Rate > stats[j].Rate
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+// printStats prints the stats as a table, skipping the ones that didn't change
+// unless -all is set.
+func (c *Command) printStats(stats []stat) {
+	tbl := terminal.NewTable("Stat", "Value", "Delta", "Rate (/s)")
+	for _, s := range stats {
+		if s.Delta == 0 && !c.flagAll {
+			continue
+		}
+		tbl.Rich([]string{
+			s.Name,
+			strconv.FormatInt(s.Value, 10),
+			fmt.Sprintf("%+d", s.Delta),
+			strconv.FormatFloat(s.Rate, 'f', 2, 64),
+		}, nil)
+	}
+	if len(tbl.Rows) == 0 {
+		c.UI.Output("No matching stats changed in %s.", c.flagInterval)
+		return
+	}
+	c.UI.Table(tbl)
+}
+
+// Help returns a description of the command and how it is used.
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s proxy stats <pod-name> [flags]\n\n" +
+		"The stats are sampled twice, -interval apart, from the Envoy admin API of the pod through a port forward,\n" +
+		"and the change of each stat is printed with its rate per second, highest rate first.\n\n" +
+		"Examples:\n" +
+		"  $ consul-k8s proxy stats web-6d7b8c9f5-x2x4p\n" +
+		"  $ consul-k8s proxy stats web-6d7b8c9f5-x2x4p -filter 'cluster\\.api\\..*upstream_rq_[0-9]xx$' -interval 30s\n\n" +
+		c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *Command) Synopsis() string {
+	return "Print the rates of change of the Envoy stats of a proxy."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *Command) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command, which
+// predicts the names of the pods with an injected proxy.
+func (c *Command) AutocompleteArgs() complete.Predictor {
+	return common.PredictKubePods(common.InjectedPodSelector)
+}
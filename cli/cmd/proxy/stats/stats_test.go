@@ -0,0 +1,117 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+// TestValidateFlags tests the validate flags function.
+func TestValidateFlags(t *testing.T) {
+	// The following cases should all error, if they fail to this test fails.
+	testCases := []struct {
+		description string
+		input       []string
+	}{
+		{
+			"Should require a pod name.",
+			[]string{},
+		},
+		{
+			"Should disallow non-flag arguments after the pod name.",
+			[]string{"web", "-namespace", "default", "api"},
+		},
+		{
+			"Should disallow a zero interval.",
+			[]string{"web", "-interval", "0s"},
+		},
+		{
+			"Should disallow an invalid filter.",
+			[]string{"web", "-filter", "upstream_rq_(total"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		c := getInitializedCommand(t)
+		t.Run(testCase.description, func(t *testing.T) {
+			if err := c.validateFlags(testCase.input); err == nil {
+				t.Errorf("Test case should have failed.")
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	var mu sync.Mutex
+	var samples int
+	var filter string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		samples++
+		filter = r.URL.Query().Get("filter")
+		fmt.Fprintf(w, `{"stats": [{"name": "cluster.api.upstream_rq_total", "value": %d}]}`, samples*10)
+	}))
+	defer server.Close()
+
+	c := getInitializedCommand(t)
+	c.kubernetes = fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}})
+	c.restConfig = &rest.Config{}
+	c.openAdmin = func(pod *corev1.Pod, port int) (string, func(), error) {
+		return strings.TrimPrefix(server.URL, "http://"), func() {}, nil
+	}
+
+	require.Equal(t, 0, c.Run([]string{"web", "-interval", "10ms"}))
+	require.Equal(t, 2, samples)
+	require.Equal(t, defaultFilter, filter)
+
+	c = getInitializedCommand(t)
+	c.kubernetes = fake.NewSimpleClientset()
+	c.restConfig = &rest.Config{}
+	require.Equal(t, 1, c.Run([]string{"web", "-interval", "10ms"}))
+}
+
+func TestDelta(t *testing.T) {
+	first := map[string]int64{"a": 10, "b": 5, "c": 7, "removed": 1}
+	second := map[string]int64{"a": 30, "b": 25, "c": 7, "added": 1}
+	require.Equal(t, []stat{
+		{Name: "a", Value: 30, Delta: 20, Rate: 10},
+		{Name: "b", Value: 25, Delta: 20, Rate: 10},
+		{Name: "c", Value: 7, Delta: 0, Rate: 0},
+	}, delta(first, second, 2*time.Second))
+}
+
+// getInitializedCommand sets up a command struct for tests.
+func getInitializedCommand(t *testing.T) *Command {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+	}
+
+	c := &Command{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
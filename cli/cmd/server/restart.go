@@ -0,0 +1,446 @@
+// Package server contains the commands that manage the Consul server pods.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/posener/complete"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	flagNameNamespace = "namespace"
+	flagNameToken     = "token"
+	flagNameCAFile    = "ca-file"
+
+	flagNameAutoApprove = "auto-approve"
+	defaultAutoApprove  = false
+
+	flagNameTimeout = "timeout"
+	defaultTimeout  = 10 * time.Minute
+
+	flagNameStablePeriod = "stable-period"
+	defaultStablePeriod  = 10 * time.Second
+
+	defaultPollInterval = 2 * time.Second
+)
+
+// RestartCommand restarts the Consul servers one at a time.
+type RestartCommand struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+	restConfig *rest.Config
+
+	set *flag.Sets
+
+	flagNamespace    string
+	flagToken        string
+	flagCAFile       string
+	flagAutoApprove  bool
+	flagTimeout      time.Duration
+	flagStablePeriod time.Duration
+
+	flagKubeConfig  string
+	flagKubeContext string
+
+	// openServer returns a client for the HTTP API of the server pod and a
+	// function that closes the connection. It port forwards to the pod if it
+	// is not set, which lets tests replace it.
+	openServer consul.ServerOpener
+	// pollInterval is how often the pods and the health of the cluster are
+	// checked while waiting. It defaults to defaultPollInterval.
+	pollInterval time.Duration
+
+	once sync.Once
+	help string
+}
+
+func (c *RestartCommand) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameNamespace,
+		Aliases: []string{"n"},
+		Target:  &c.flagNamespace,
+		Usage: "Set the namespace of the Consul installation. " +
+			"If not set, the namespace of the installed Helm release is used.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameToken,
+		Target:  &c.flagToken,
+		Default: "",
+		Usage: fmt.Sprintf("Set the ACL token used to call the Consul API. It needs operator read permissions. "+
+			"If not set, the %s environment variable is used.", common.TokenEnvVar),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameCAFile,
+		Target:  &c.flagCAFile,
+		Default: "",
+		Usage: fmt.Sprintf("Set the path to the CA certificate of the Consul servers. If set, the servers are called "+
+			"over HTTPS on port %d instead of HTTP on port %d.", consul.HTTPSPort, consul.HTTPPort),
+		Completion: complete.PredictFiles("*"),
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameAutoApprove,
+		Target:  &c.flagAutoApprove,
+		Default: defaultAutoApprove,
+		Usage:   "Skip confirmation prompt.",
+	})
+	f.DurationVar(&flag.DurationVar{
+		Name:    flagNameTimeout,
+		Target:  &c.flagTimeout,
+		Default: defaultTimeout,
+		Usage:   "Set how long to wait for each server to be restarted and the cluster to be healthy again.",
+	})
+	f.DurationVar(&flag.DurationVar{
+		Name:    flagNameStablePeriod,
+		Target:  &c.flagStablePeriod,
+		Default: defaultStablePeriod,
+		Usage: "Set how long the cluster must have a leader and all servers must be healthy " +
+			"before the next server is restarted.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run restarts the Consul server pods one at a time, waiting for the cluster
+// to be healthy before restarting the next one.
+func (c *RestartCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("server restart")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+
+	if err := c.setup(); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	pods, err := c.restartOrder()
+	if err != nil {
+		c.UI.Output("Unable to restart the Consul servers: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+
+	if !c.flagAutoApprove {
+		confirmation, err := c.UI.Input(&terminal.Input{
+			Prompt: fmt.Sprintf("Restart the %d Consul servers in namespace %q one at a time? (y/N)", len(pods), c.flagNamespace),
+			Style:  terminal.InfoStyle,
+			Secret: false,
+		})
+		if err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+		if common.Abort(confirmation) {
+			c.UI.Output("Consul servers not restarted.", terminal.WithInfoStyle())
+			return 1
+		}
+	}
+
+	c.UI.Output("Restarting Consul servers", terminal.WithHeaderStyle())
+	for _, pod := range pods {
+		c.UI.Output("Restarting %s", pod.Name, terminal.WithInfoStyle())
+		if err := c.restart(pod, len(pods)); err != nil {
+			c.UI.Output("Error restarting %s: %v", pod.Name, err, terminal.WithErrorStyle())
+			c.UI.Output("The remaining servers were not restarted.", terminal.WithErrorStyle())
+			return 1
+		}
+		c.UI.Output("%s restarted and the cluster is healthy.", pod.Name, terminal.WithSuccessStyle())
+	}
+	c.UI.Output("All %d Consul servers were restarted.", len(pods), terminal.WithSuccessStyle())
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *RestartCommand) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if c.flagTimeout <= 0 {
+		return fmt.Errorf("-%s must be greater than zero", flagNameTimeout)
+	}
+	if c.flagStablePeriod < 0 {
+		return fmt.Errorf("-%s must not be negative", flagNameStablePeriod)
+	}
+	return nil
+}
+
+// setup creates the Kubernetes client and finds the namespace of the Consul
+// installation if -namespace is not set.
+func (c *RestartCommand) setup() error {
+	if c.flagToken == "" {
+		c.flagToken = os.Getenv(common.TokenEnvVar)
+	}
+	if c.pollInterval == 0 {
+		c.pollInterval = defaultPollInterval
+	}
+
+	settings, err := common.InitKubernetes(c.flagKubeConfig, c.flagKubeContext, &c.restConfig, &c.kubernetes)
+	if err != nil {
+		return err
+	}
+
+	if c.flagNamespace == "" {
+		uiLogger := func(msg string, args ...interface{}) {
+			c.Log.Debug(fmt.Sprintf(msg, args...))
+		}
+		_, namespace, err := common.CheckForInstallations(settings, uiLogger)
+		if err != nil {
+			return err
+		}
+		c.flagNamespace = namespace
+	}
+	return nil
+}
+
+// restartOrder checks that all servers are ready and the cluster is healthy,
+// and returns the server pods in the order they are restarted. The leader is
+// restarted last so that there is only a single leader election.
+func (c *RestartCommand) restartOrder() ([]corev1.Pod, error) {
+	pods, err := c.serverPods()
+	if err != nil {
+		return nil, err
+	}
+	if len(pods) == 0 {
+		return nil, fmt.Errorf("no Consul server pods found in namespace %q", c.flagNamespace)
+	}
+	for _, pod := range pods {
+		if !common.PodReady(pod) {
+			return nil, fmt.Errorf("server pod %s is not ready", pod.Name)
+		}
+	}
+
+	var leaderIP string
+	err = c.withServer(pods, func(client *consul.Client) error {
+		health, err := client.AutopilotHealth(c.Ctx)
+		if err != nil {
+			return err
+		}
+		if !health.Healthy {
+			return errors.New("the cluster is not healthy")
+		}
+		leader, err := client.Leader(c.Ctx)
+		if err != nil {
+			return err
+		}
+		leaderIP, _, err = net.SplitHostPort(leader)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(pods, func(i, j int) bool {
+		iLeader, jLeader := pods[i].Status.PodIP == leaderIP, pods[j].Status.PodIP == leaderIP
+		if iLeader != jLeader {
+			return jLeader
+		}
+		return pods[i].Name < pods[j].Name
+	})
+	return pods, nil
+}
+
+// restart deletes the server pod, waits for the StatefulSet to recreate it and
+// for the cluster of the given number of servers to be stable again.
+func (c *RestartCommand) restart(pod corev1.Pod, servers int) error {
+	ctx, cancel := context.WithTimeout(c.Ctx, c.flagTimeout)
+	defer cancel()
+
+	uid := pod.UID
+	err := c.kubernetes.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &uid},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.poll(ctx, "the pod to be recreated and ready", func() (bool, error) {
+		newPod, err := c.kubernetes.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		return newPod.UID != uid && common.PodReady(*newPod), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The cluster must stay healthy for the stable period, so that a server
+	// that is flapping or a leader election in progress is not missed.
+	var healthySince time.Time
+	return c.poll(ctx, "the cluster to be healthy", func() (bool, error) {
+		healthy, err := c.clusterHealthy(servers)
+		if err != nil {
+			c.Log.Debug("checking cluster health", "error", err)
+		}
+		if !healthy {
+			healthySince = time.Time{}
+			return false, nil
+		}
+		if healthySince.IsZero() {
+			healthySince = time.Now()
+		}
+		return time.Since(healthySince) >= c.flagStablePeriod, nil
+	})
+}
+
+// clusterHealthy returns true if the cluster has a leader and autopilot
+// reports all of the expected number of servers as healthy, i.e. alive and
+// caught up with the leader's Raft log.
+func (c *RestartCommand) clusterHealthy(servers int) (bool, error) {
+	pods, err := c.serverPods()
+	if err != nil {
+		return false, err
+	}
+
+	var healthy bool
+	err = c.withServer(pods, func(client *consul.Client) error {
+		if _, err := client.Leader(c.Ctx); err != nil {
+			return err
+		}
+		health, err := client.AutopilotHealth(c.Ctx)
+		if err != nil {
+			return err
+		}
+		if !health.Healthy || len(health.Servers) != servers {
+			return nil
+		}
+		for _, server := range health.Servers {
+			if !server.Healthy {
+				return nil
+			}
+		}
+		healthy = true
+		return nil
+	})
+	return healthy, err
+}
+
+// poll calls condition every poll interval until it returns true or an error,
+// or the context is done.
+func (c *RestartCommand) poll(ctx context.Context, waitingFor string, condition func() (bool, error)) error {
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+	for {
+		done, err := condition()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for %s", waitingFor)
+		case <-ticker.C:
+		}
+	}
+}
+
+// serverPods lists the Consul server pods.
+func (c *RestartCommand) serverPods() ([]corev1.Pod, error) {
+	pods, err := c.kubernetes.CoreV1().Pods(c.flagNamespace).List(c.Ctx, metav1.ListOptions{LabelSelector: consul.ServerLabelSelector})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+// withServer calls fn with a client for the HTTP API of the first ready server
+// pod.
+func (c *RestartCommand) withServer(pods []corev1.Pod, fn func(client *consul.Client) error) error {
+	open := consul.ServerConfig{
+		KubeClient: c.kubernetes,
+		RestConfig: c.restConfig,
+		Token:      c.flagToken,
+		CAFile:     c.flagCAFile,
+	}.Opener(c.openServer)
+	for i := range pods {
+		if !common.PodReady(pods[i]) {
+			continue
+		}
+		client, closeClient, err := open(&pods[i])
+		if err != nil {
+			return err
+		}
+		defer closeClient()
+		return fn(client)
+	}
+	return errors.New("no ready server pod to connect to")
+}
+
+// Help returns a description of the command and how it is used.
+func (c *RestartCommand) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s server restart [flags]\n\n" +
+		"The server pods are deleted one at a time, followers first and the leader last. After each\n" +
+		"pod is recreated by the StatefulSet, the command waits until the cluster has a leader and\n" +
+		"autopilot reports all servers as healthy, i.e. caught up with the Raft log, for the stable\n" +
+		"period before restarting the next server. If the StatefulSet uses the OnDelete update\n" +
+		"strategy, the recreated pods use its updated spec, which rolls out an upgrade safely.\n\n" + c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *RestartCommand) Synopsis() string {
+	return "Restart the Consul servers one at a time without losing quorum."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *RestartCommand) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *RestartCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/hashicorp/consul-k8s/cli/helper/test"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// TestValidateFlags tests the validate flags function.
+func TestValidateFlags(t *testing.T) {
+	// The following cases should all error, if they fail to this test fails.
+	testCases := []struct {
+		description string
+		input       []string
+	}{
+		{
+			"Should disallow non-flag arguments.",
+			[]string{"consul-server-0"},
+		},
+		{
+			"Should disallow a zero timeout.",
+			[]string{"-timeout", "0s"},
+		},
+		{
+			"Should disallow a negative stable period.",
+			[]string{"-stable-period", "-1s"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		c := getInitializedCommand(t)
+		t.Run(testCase.description, func(t *testing.T) {
+			if err := c.validateFlags(testCase.input); err == nil {
+				t.Errorf("Test case should have failed.")
+			}
+		})
+	}
+}
+
+// TestRun tests that the followers are restarted before the leader and that
+// the command stops when the cluster is unhealthy.
+func TestRun(t *testing.T) {
+	cases := map[string]struct {
+		healthy    bool
+		expCode    int
+		expDeleted []string
+	}{
+		"healthy cluster": {
+			healthy:    true,
+			expCode:    0,
+			expDeleted: []string{"consul-server-0", "consul-server-2", "consul-server-1"},
+		},
+		"unhealthy cluster": {
+			healthy: false,
+			expCode: 1,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/status/leader":
+					w.Write([]byte(`"10.0.0.2:8300"`))
+				case "/v1/operator/autopilot/health":
+					if !c.healthy {
+						w.WriteHeader(http.StatusTooManyRequests)
+						w.Write([]byte(`{"Healthy": false, "Servers": [{"Name": "consul-server-0"}]}`))
+						return
+					}
+					w.Write([]byte(`{"Healthy": true, "FailureTolerance": 1, "Servers": [
+  {"Name": "consul-server-0", "Healthy": true},
+  {"Name": "consul-server-1", "Healthy": true, "Leader": true},
+  {"Name": "consul-server-2", "Healthy": true}
+]}`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer srv.Close()
+
+			client := fake.NewSimpleClientset(
+				test.ServerPod("consul-server-0", "consul", "10.0.0.1"),
+				test.ServerPod("consul-server-1", "consul", "10.0.0.2"),
+				test.ServerPod("consul-server-2", "consul", "10.0.0.3"),
+			)
+			// Deleting a pod recreates it with a new UID, like the StatefulSet controller does.
+			var deleted []string
+			client.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				name := action.(k8stesting.DeleteAction).GetName()
+				deleted = append(deleted, name)
+				pod := test.ServerPod(name, "consul", "10.0.1.1")
+				pod.UID = types.UID(name + "-recreated")
+				return true, nil, client.Tracker().Update(corev1.SchemeGroupVersion.WithResource("pods"), pod, "consul")
+			})
+
+			cmd := getInitializedCommand(t)
+			cmd.kubernetes = client
+			cmd.restConfig = &rest.Config{}
+			cmd.pollInterval = time.Millisecond
+			cmd.openServer = func(pod *corev1.Pod) (*consul.Client, func(), error) {
+				return &consul.Client{Addr: strings.TrimPrefix(srv.URL, "http://")}, func() {}, nil
+			}
+
+			code := cmd.Run([]string{"-n", "consul", "-auto-approve", "-stable-period", "0s", "-timeout", "1s"})
+			require.Equal(t, c.expCode, code)
+			require.Equal(t, c.expDeleted, deleted)
+		})
+	}
+}
+
+// getInitializedCommand sets up a command struct for tests.
+func getInitializedCommand(t *testing.T) *RestartCommand {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+	}
+
+	c := &RestartCommand{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
@@ -0,0 +1,373 @@
+package setloglevel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/posener/complete"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	flagNameNamespace = "namespace"
+
+	flagNameComponent = "component"
+
+	flagNamePort = "port"
+
+	// resetLevel removes the level of a component so that it inherits the
+	// level of the deployment again.
+	resetLevel = "reset"
+
+	// requestTimeout bounds the time spent on the log level API of a single pod.
+	requestTimeout = 10 * time.Second
+)
+
+// levels are the log levels the control plane components accept.
+var levels = []string{"debug", "info", "warn", "error"}
+
+// metricsPorts are the ports of the metrics servers that serve the log level
+// API, keyed by the name of the container of the component.
+var metricsPorts = map[string]int{
+	"sidecar-injector": 9444,
+	"controller":       8080,
+}
+
+// logLevels is the body of the requests to and responses of the log level
+// API of the control plane components.
+type logLevels struct {
+	Component  string            `json:"component,omitempty"`
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components,omitempty"`
+}
+
+type Command struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+	restConfig *rest.Config
+
+	// openAPI returns the address of the log level API of the pod and a
+	// function that closes the connection. It port forwards to the pod if it
+	// is not set, which lets tests replace it.
+	openAPI common.PortOpener
+
+	set *flag.Sets
+
+	flagNamespace string
+	flagComponent string
+	flagPort      int
+
+	flagKubeConfig  string
+	flagKubeContext string
+
+	// kind and name are the kind, "deployment" or "pod", and the name of
+	// the target, and level the level to set, which is empty to only print
+	// the levels.
+	kind, name, level string
+
+	once sync.Once
+	help string
+}
+
+func (c *Command) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:       flagNameNamespace,
+		Aliases:    []string{"n"},
+		Target:     &c.flagNamespace,
+		Default:    common.DefaultReleaseNamespace,
+		Usage:      "The namespace of the deployment or pod.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameComponent,
+		Target: &c.flagComponent,
+		Usage: "Set the level of a single component instead of the whole deployment, e.g. \"controller.endpoints\". " +
+			"Components inherit the level of the deployment unless they have their own level, which the level \"reset\" removes.",
+	})
+	f.IntVar(&flag.IntVar{
+		Name:   flagNamePort,
+		Target: &c.flagPort,
+		Usage:  "The port of the metrics server that serves the log level API in the pods. It is detected from the container of the component if not set.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run sets the log level of the pods of a deployment, or of a single pod,
+// and prints their levels.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("set-log-level")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+	if _, err := common.InitKubernetes(c.flagKubeConfig, c.flagKubeContext, &c.restConfig, &c.kubernetes); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	pods, err := c.pods()
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	code := 0
+	for i := range pods {
+		pod := &pods[i]
+		levels, err := c.logLevels(pod)
+		if err != nil {
+			c.UI.Output("Error with the log levels of pod %s/%s: %v", pod.Namespace, pod.Name, err, terminal.WithErrorStyle())
+			code = 1
+			continue
+		}
+		c.printLevels(pod, levels)
+	}
+	return code
+}
+
+// validateFlags checks the command line arguments and flags for errors.
+func (c *Command) validateFlags(args []string) error {
+	// The target and the level come before the flags.
+	var positional []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments after the target and level")
+	}
+	if len(positional) == 0 || len(positional) > 2 {
+		return errors.New("a target, e.g. deployment/consul-connect-injector, and optionally a level must be given")
+	}
+
+	parts := strings.SplitN(positional[0], "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return fmt.Errorf("target %q must be deployment/<name> or pod/<name>", positional[0])
+	}
+	switch parts[0] {
+	case "deployment", "deployments", "deploy":
+		c.kind = "deployment"
+	case "pod", "pods", "po":
+		c.kind = "pod"
+	default:
+		return fmt.Errorf("target %q must be deployment/<name> or pod/<name>", positional[0])
+	}
+	c.name = parts[1]
+
+	if len(positional) == 2 {
+		c.level = positional[1]
+		if !validLevel(c.level) && !(c.level == resetLevel && c.flagComponent != "") {
+			return fmt.Errorf("level %q is invalid, must be one of %s, or %s with -%s", c.level, strings.Join(levels, ", "), resetLevel, flagNameComponent)
+		}
+	}
+	if c.flagPort < 0 || c.flagPort > 65535 {
+		return fmt.Errorf("-%s %d is not a valid port", flagNamePort, c.flagPort)
+	}
+	return nil
+}
+
+func validLevel(level string) bool {
+	for _, l := range levels {
+		if level == l {
+			return true
+		}
+	}
+	return false
+}
+
+// pods returns the running pods of the target sorted by name.
+func (c *Command) pods() ([]corev1.Pod, error) {
+	if c.kind == "pod" {
+		pod, err := c.kubernetes.CoreV1().Pods(c.flagNamespace).Get(c.Ctx, c.name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error getting pod %s/%s: %v", c.flagNamespace, c.name, err)
+		}
+		return []corev1.Pod{*pod}, nil
+	}
+
+	deployment, err := c.kubernetes.AppsV1().Deployments(c.flagNamespace).Get(c.Ctx, c.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting deployment %s/%s: %v", c.flagNamespace, c.name, err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector of deployment %s/%s: %v", c.flagNamespace, c.name, err)
+	}
+	list, err := c.kubernetes.CoreV1().Pods(c.flagNamespace).List(c.Ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("error listing pods of deployment %s/%s: %v", c.flagNamespace, c.name, err)
+	}
+	var pods []corev1.Pod
+	for _, pod := range list.Items {
+		if pod.Status.Phase == corev1.PodRunning {
+			pods = append(pods, pod)
+		}
+	}
+	if len(pods) == 0 {
+		return nil, fmt.Errorf("deployment %s/%s has no running pods", c.flagNamespace, c.name)
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	return pods, nil
+}
+
+// port returns the port of the log level API of the pod.
+func (c *Command) port(pod *corev1.Pod) (int, error) {
+	if c.flagPort != 0 {
+		return c.flagPort, nil
+	}
+	for _, container := range pod.Spec.Containers {
+		if port, ok := metricsPorts[container.Name]; ok {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("unable to detect the port of the log level API, set -%s", flagNamePort)
+}
+
+// logLevels sets the log level of the pod if a level is given, and returns
+// its levels.
+func (c *Command) logLevels(pod *corev1.Pod) (*logLevels, error) {
+	port, err := c.port(pod)
+	if err != nil {
+		return nil, err
+	}
+	open := common.PortForwarder{KubeClient: c.kubernetes, RestConfig: c.restConfig}.Opener(c.openAPI)
+	addr, closeAPI, err := open(pod, port)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAPI()
+
+	ctx, cancel := context.WithTimeout(c.Ctx, requestTimeout)
+	defer cancel()
+	method, body := http.MethodGet, []byte(nil)
+	if c.level != "" {
+		req := logLevels{Component: c.flagComponent, Level: c.level}
+		if c.level == resetLevel {
+			req.Level = ""
+		}
+		method = http.MethodPut
+		if body, err = json.Marshal(req); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("http://%s/log-level", addr), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errors.New("the pod does not serve the log level API, it may run an older version")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response code %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	var levels logLevels
+	if err := json.Unmarshal(respBody, &levels); err != nil {
+		return nil, fmt.Errorf("invalid response: %s", err)
+	}
+	return &levels, nil
+}
+
+// printLevels prints the log levels of the pod as a table, with the level of
+// the deployment first and then the levels of the components sorted by name.
+func (c *Command) printLevels(pod *corev1.Pod, levels *logLevels) {
+	components := make([]string, 0, len(levels.Components))
+	for component := range levels.Components {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	c.UI.Output("Log levels of pod %s/%s", pod.Namespace, pod.Name, terminal.WithHeaderStyle())
+	tbl := terminal.NewTable("Component", "Level")
+	tbl.Rich([]string{"(all)", levels.Level}, nil)
+	for _, component := range components {
+		tbl.Rich([]string{component, levels.Components[component]}, nil)
+	}
+	c.UI.Table(tbl)
+}
+
+// Help returns a description of the command and how it is used.
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s set-log-level <deployment|pod>/<name> [level] [flags]\n\n" +
+		"The log levels of the connect injector and the controller are changed at runtime,\n" +
+		"without restarting their pods, through the API of their metrics servers, which is\n" +
+		"reached through a port forward. Levels are not persisted: restarted pods log at the\n" +
+		"level of their configuration. Without a level, the current levels are printed.\n\n" +
+		"Examples:\n" +
+		"  $ consul-k8s set-log-level deployment/consul-connect-injector debug\n" +
+		"  $ consul-k8s set-log-level deployment/consul-controller debug -component controller.servicedefaults\n" +
+		"  $ consul-k8s set-log-level deployment/consul-controller reset -component controller.servicedefaults\n\n" +
+		c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *Command) Synopsis() string {
+	return "Get or set the log levels of control plane components at runtime."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *Command) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *Command) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
@@ -0,0 +1,227 @@
+package setloglevel
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+// TestValidateFlags tests the validate flags function.
+func TestValidateFlags(t *testing.T) {
+	// The following cases should all error, if they fail to this test fails.
+	testCases := []struct {
+		description string
+		input       []string
+	}{
+		{
+			"Should require a target.",
+			[]string{},
+		},
+		{
+			"Should disallow a target without a kind.",
+			[]string{"consul-connect-injector"},
+		},
+		{
+			"Should disallow an unsupported kind.",
+			[]string{"statefulset/consul-server"},
+		},
+		{
+			"Should disallow an invalid level.",
+			[]string{"deployment/consul-connect-injector", "verbose"},
+		},
+		{
+			"Should disallow reset without a component.",
+			[]string{"deployment/consul-connect-injector", "reset"},
+		},
+		{
+			"Should disallow non-flag arguments after the flags.",
+			[]string{"deployment/consul-connect-injector", "-namespace", "consul", "debug"},
+		},
+		{
+			"Should disallow an invalid port.",
+			[]string{"deployment/consul-connect-injector", "-port", "70000"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		c := getInitializedCommand(t)
+		t.Run(testCase.description, func(t *testing.T) {
+			if err := c.validateFlags(testCase.input); err == nil {
+				t.Errorf("Test case should have failed.")
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	cases := map[string]struct {
+		args      []string
+		expCode   int
+		expLevels map[string]logLevels
+	}{
+		"get levels of a deployment": {
+			args:    []string{"deployment/consul-connect-injector"},
+			expCode: 0,
+			expLevels: map[string]logLevels{
+				"injector-1": {Level: "info"},
+				"injector-2": {Level: "info"},
+			},
+		},
+		"set level of a deployment": {
+			args:    []string{"deployment/consul-connect-injector", "debug"},
+			expCode: 0,
+			expLevels: map[string]logLevels{
+				"injector-1": {Level: "debug"},
+				"injector-2": {Level: "debug"},
+			},
+		},
+		"set level of a component of a pod": {
+			args:    []string{"pod/injector-2", "error", "-component", "controller.endpoints"},
+			expCode: 0,
+			expLevels: map[string]logLevels{
+				"injector-1": {Level: "info"},
+				"injector-2": {Level: "info", Components: map[string]string{"controller.endpoints": "error"}},
+			},
+		},
+		"deployment not found": {
+			args:    []string{"deployment/consul-controller"},
+			expCode: 1,
+		},
+		"pod not found": {
+			args:    []string{"pod/injector-3"},
+			expCode: 1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			apis := map[string]*fakeLogLevelAPI{
+				"injector-1": {levels: logLevels{Level: "info"}},
+				"injector-2": {levels: logLevels{Level: "info"}},
+			}
+			var servers []*httptest.Server
+			defer func() {
+				for _, srv := range servers {
+					srv.Close()
+				}
+			}()
+
+			c := getInitializedCommand(t)
+			c.kubernetes = fake.NewSimpleClientset(injectorDeployment(), injectorPod("injector-1"), injectorPod("injector-2"))
+			c.restConfig = &rest.Config{}
+			c.openAPI = func(pod *corev1.Pod, port int) (string, func(), error) {
+				require.Equal(t, 9444, port)
+				srv := httptest.NewServer(apis[pod.Name])
+				servers = append(servers, srv)
+				return strings.TrimPrefix(srv.URL, "http://"), func() {}, nil
+			}
+
+			require.Equal(t, tc.expCode, c.Run(tc.args))
+			for pod, levels := range tc.expLevels {
+				require.Equal(t, levels, apis[pod].levels, pod)
+			}
+		})
+	}
+}
+
+func TestPort(t *testing.T) {
+	c := getInitializedCommand(t)
+	port, err := c.port(&corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "controller"}}}})
+	require.NoError(t, err)
+	require.Equal(t, 8080, port)
+
+	_, err = c.port(&corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "consul"}}}})
+	require.Error(t, err)
+
+	c.flagPort = 9000
+	port, err = c.port(&corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "consul"}}}})
+	require.NoError(t, err)
+	require.Equal(t, 9000, port)
+}
+
+// fakeLogLevelAPI is the /log-level endpoint of a control plane component.
+type fakeLogLevelAPI struct {
+	mu     sync.Mutex
+	levels logLevels
+}
+
+func (a *fakeLogLevelAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if r.URL.Path != "/log-level" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Method == http.MethodPut {
+		var req logLevels
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.Component == "" {
+			a.levels.Level = req.Level
+		} else {
+			if a.levels.Components == nil {
+				a.levels.Components = make(map[string]string)
+			}
+			a.levels.Components[req.Component] = req.Level
+		}
+	}
+	json.NewEncoder(w).Encode(a.levels)
+}
+
+func injectorDeployment() *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-connect-injector", Namespace: "consul"},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"component": "connect-injector"}},
+		},
+	}
+}
+
+func injectorPod(name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "consul",
+			Labels:    map[string]string{"component": "connect-injector"},
+		},
+		Spec:   corev1.PodSpec{Containers: []corev1.Container{{Name: "sidecar-injector"}}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+// getInitializedCommand sets up a command struct for tests.
+func getInitializedCommand(t *testing.T) *Command {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+	}
+
+	c := &Command{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
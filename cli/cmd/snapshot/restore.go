@@ -0,0 +1,159 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/posener/complete"
+)
+
+const (
+	flagNameAutoApprove = "auto-approve"
+	defaultAutoApprove  = false
+)
+
+// RestoreCommand restores the state of the Consul servers from a snapshot.
+type RestoreCommand struct {
+	*common.BaseCommand
+
+	set *flag.Sets
+
+	server
+	flagAutoApprove bool
+	source          string
+
+	once sync.Once
+	help string
+}
+
+func (c *RestoreCommand) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameAutoApprove,
+		Target:  &c.flagAutoApprove,
+		Default: defaultAutoApprove,
+		Usage:   "Skip confirmation prompt.",
+	})
+	c.server.addFlags(f, c.set)
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run restores a snapshot through the leader of the Consul servers.
+func (c *RestoreCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("snapshot restore")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+
+	if err := c.server.setup(c.BaseCommand); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	if !c.flagAutoApprove {
+		confirmation, err := c.UI.Input(&terminal.Input{
+			Prompt: fmt.Sprintf("Replace the state of the Consul servers in namespace %q with the snapshot? (y/N)", c.server.flagNamespace),
+			Style:  terminal.InfoStyle,
+			Secret: false,
+		})
+		if err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+		if common.Abort(confirmation) {
+			c.UI.Output("Snapshot not restored.", terminal.WithInfoStyle())
+			return 1
+		}
+	}
+
+	snap, err := c.open()
+	if err != nil {
+		c.UI.Output("Error reading snapshot: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+	defer snap.Close()
+
+	client, closeClient, err := c.server.leader(c.Ctx)
+	if err != nil {
+		c.UI.Output("Error connecting to the Consul servers: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+	defer closeClient()
+
+	if err := client.RestoreSnapshot(c.Ctx, snap); err != nil {
+		c.UI.Output("Error restoring snapshot: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+	c.UI.Output("Restored snapshot to the Consul servers in namespace %q.", c.server.flagNamespace, terminal.WithSuccessStyle())
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *RestoreCommand) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) != 1 {
+		return errors.New("must specify exactly one source for the snapshot")
+	}
+	c.source = c.set.Args()[0]
+	// The confirmation prompt would read from stdin as well.
+	if c.source == stdio && !c.flagAutoApprove {
+		return fmt.Errorf("-%s must be set to read the snapshot from stdin", flagNameAutoApprove)
+	}
+	return validateLocation(c.source)
+}
+
+// open returns a reader for the snapshot from the source file, stdin or URL.
+func (c *RestoreCommand) open() (io.ReadCloser, error) {
+	switch {
+	case c.source == stdio:
+		return io.NopCloser(os.Stdin), nil
+	case isURL(c.source):
+		return download(c.Ctx, c.source)
+	default:
+		return os.Open(c.source)
+	}
+}
+
+// Help returns a description of the command and how it is used.
+func (c *RestoreCommand) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s snapshot restore [flags] <source>\n\n" +
+		"The snapshot is restored through the leader of the Consul servers, replacing their current\n" +
+		"state. The source is a local file, \"-\" to read the snapshot from stdin, or a pre-signed\n" +
+		"HTTPS URL to download it from object storage.\n\n" + c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *RestoreCommand) Synopsis() string {
+	return "Restore the state of the Consul servers from a snapshot."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *RestoreCommand) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *RestoreCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*")
+}
@@ -0,0 +1,171 @@
+package snapshot
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/posener/complete"
+)
+
+// SaveCommand saves a snapshot of the state of the Consul servers.
+type SaveCommand struct {
+	*common.BaseCommand
+
+	set *flag.Sets
+
+	server
+	destination string
+
+	once sync.Once
+	help string
+}
+
+func (c *SaveCommand) init() {
+	c.set = flag.NewSets()
+	c.server.addFlags(c.set.NewSet("Command Options"), c.set)
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run saves a snapshot from the leader of the Consul servers.
+func (c *SaveCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("snapshot save")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+
+	if err := c.server.setup(c.BaseCommand); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	client, closeClient, err := c.server.leader(c.Ctx)
+	if err != nil {
+		c.UI.Output("Error connecting to the Consul servers: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+	defer closeClient()
+
+	snap, err := client.SaveSnapshot(c.Ctx)
+	if err != nil {
+		c.UI.Output("Error saving snapshot: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+	defer snap.Close()
+
+	// Nothing else is printed to stdout so that the snapshot can be piped, e.g. to a cloud CLI.
+	if c.destination == stdio {
+		if _, err := io.Copy(os.Stdout, snap); err != nil {
+			c.UI.Output("Error writing snapshot: %v", err, terminal.WithErrorStyle())
+			return 1
+		}
+		return 0
+	}
+
+	size, err := c.write(snap)
+	if err != nil {
+		c.UI.Output("Error writing snapshot: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+	location := c.destination
+	if isURL(location) {
+		location = redactURL(location)
+	}
+	c.UI.Output("Saved snapshot of the Consul servers in namespace %q to %s (%d bytes).",
+		c.server.flagNamespace, location, size, terminal.WithSuccessStyle())
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *SaveCommand) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) != 1 {
+		return errors.New("must specify exactly one destination for the snapshot")
+	}
+	c.destination = c.set.Args()[0]
+	return validateLocation(c.destination)
+}
+
+// write writes the snapshot to the destination file or uploads it to the
+// destination URL and returns its size.
+func (c *SaveCommand) write(snap io.Reader) (int64, error) {
+	if !isURL(c.destination) {
+		// Write to a temporary file first so that an incomplete snapshot never
+		// replaces an existing one.
+		f, err := os.CreateTemp(filepath.Dir(c.destination), filepath.Base(c.destination)+".*.tmp")
+		if err != nil {
+			return 0, err
+		}
+		defer os.Remove(f.Name())
+		size, err := io.Copy(f, snap)
+		if err != nil {
+			f.Close()
+			return 0, err
+		}
+		if err := f.Close(); err != nil {
+			return 0, err
+		}
+		return size, os.Rename(f.Name(), c.destination)
+	}
+
+	f, err := os.CreateTemp("", "consul-snapshot-*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	size, err := io.Copy(f, snap)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if err := upload(c.Ctx, c.destination, f, size); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// Help returns a description of the command and how it is used.
+func (c *SaveCommand) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s snapshot save [flags] <destination>\n\n" +
+		"The snapshot is requested from the leader of the Consul servers through a port forward.\n" +
+		"The destination is a local file, \"-\" to write the snapshot to stdout, or a pre-signed\n" +
+		"HTTPS URL to upload it to object storage, e.g. an S3 pre-signed URL, a GCS signed URL or\n" +
+		"an Azure Blob Storage SAS URL.\n\n" + c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *SaveCommand) Synopsis() string {
+	return "Save a snapshot of the state of the Consul servers."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *SaveCommand) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *SaveCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*")
+}
@@ -0,0 +1,257 @@
+// Package snapshot contains the commands that save and restore snapshots of
+// the state of the Consul servers.
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/posener/complete"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	flagNameNamespace = "namespace"
+	flagNameToken     = "token"
+	flagNameCAFile    = "ca-file"
+
+	// stdio is the snapshot location that stands for stdin or stdout.
+	stdio = "-"
+)
+
+// server finds the leader of the Consul servers installed in the cluster and
+// connects to its HTTP API.
+type server struct {
+	kubernetes kubernetes.Interface
+	restConfig *rest.Config
+
+	flagNamespace   string
+	flagToken       string
+	flagCAFile      string
+	flagKubeConfig  string
+	flagKubeContext string
+
+	// openServer returns a client for the HTTP API of the server pod and a
+	// function that closes the connection. It port forwards to the pod if it
+	// is not set, which lets tests replace it.
+	openServer consul.ServerOpener
+}
+
+func (s *server) addFlags(f *flag.Set, set *flag.Sets) {
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameNamespace,
+		Aliases: []string{"n"},
+		Target:  &s.flagNamespace,
+		Usage: "Set the namespace of the Consul installation. " +
+			"If not set, the namespace of the installed Helm release is used.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameToken,
+		Target:  &s.flagToken,
+		Default: "",
+		Usage: fmt.Sprintf("Set the ACL token used to call the Consul API. It needs operator write permissions "+
+			"to save or restore snapshots. If not set, the %s environment variable is used.", common.TokenEnvVar),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameCAFile,
+		Target:  &s.flagCAFile,
+		Default: "",
+		Usage: fmt.Sprintf("Set the path to the CA certificate of the Consul servers. If set, the servers are called "+
+			"over HTTPS on port %d instead of HTTP on port %d.", consul.HTTPSPort, consul.HTTPPort),
+		Completion: complete.PredictFiles("*"),
+	})
+
+	f = set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &s.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &s.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+}
+
+// setup creates the Kubernetes client and finds the namespace of the Consul
+// installation if -namespace is not set.
+func (s *server) setup(base *common.BaseCommand) error {
+	if s.flagToken == "" {
+		s.flagToken = os.Getenv(common.TokenEnvVar)
+	}
+
+	settings, err := common.InitKubernetes(s.flagKubeConfig, s.flagKubeContext, &s.restConfig, &s.kubernetes)
+	if err != nil {
+		return err
+	}
+
+	if s.flagNamespace == "" {
+		uiLogger := func(msg string, args ...interface{}) {
+			base.Log.Debug(fmt.Sprintf(msg, args...))
+		}
+		_, namespace, err := common.CheckForInstallations(settings, uiLogger)
+		if err != nil {
+			return err
+		}
+		s.flagNamespace = namespace
+	}
+	return nil
+}
+
+// leader returns a client for the HTTP API of the leader of the Consul
+// servers and a function that closes the connection. The leader is found by
+// asking any running server and matching its address to the IP of a pod.
+func (s *server) leader(ctx context.Context) (*consul.Client, func(), error) {
+	pods, err := s.kubernetes.CoreV1().Pods(s.flagNamespace).List(ctx, metav1.ListOptions{LabelSelector: consul.ServerLabelSelector})
+	if err != nil {
+		return nil, nil, err
+	}
+	var running []corev1.Pod
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning {
+			running = append(running, pod)
+		}
+	}
+	if len(running) == 0 {
+		return nil, nil, fmt.Errorf("no running Consul server pods found in namespace %q", s.flagNamespace)
+	}
+
+	open := consul.ServerConfig{
+		KubeClient: s.kubernetes,
+		RestConfig: s.restConfig,
+		Token:      s.flagToken,
+		CAFile:     s.flagCAFile,
+	}.Opener(s.openServer)
+	client, closeClient, err := open(&running[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	leaderAddr, err := client.Leader(ctx)
+	if err != nil {
+		closeClient()
+		return nil, nil, fmt.Errorf("error finding the leader: %s", err)
+	}
+	leaderIP, _, err := net.SplitHostPort(leaderAddr)
+	if err != nil {
+		closeClient()
+		return nil, nil, fmt.Errorf("invalid leader address %q: %s", leaderAddr, err)
+	}
+
+	for i := range running {
+		if running[i].Status.PodIP != leaderIP {
+			continue
+		}
+		if i == 0 {
+			return client, closeClient, nil
+		}
+		closeClient()
+		return open(&running[i])
+	}
+	closeClient()
+	return nil, nil, fmt.Errorf("leader %s is not a running server pod in namespace %q", leaderAddr, s.flagNamespace)
+}
+
+// validateLocation checks that the snapshot location is stdin or stdout, an
+// HTTP(S) URL or a local path.
+func validateLocation(location string) error {
+	if location == "" {
+		return errors.New("snapshot location must not be empty")
+	}
+	if strings.Contains(location, "://") && !isURL(location) {
+		return fmt.Errorf("unsupported snapshot location %q: use a pre-signed HTTPS URL to store snapshots in object storage", location)
+	}
+	return nil
+}
+
+// isURL returns true if the snapshot location is an HTTP(S) URL, e.g. a
+// pre-signed S3 or GCS URL or an Azure Blob Storage SAS URL.
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "https://") || strings.HasPrefix(location, "http://")
+}
+
+// redactURL removes the query from the URL, which holds the signature of
+// pre-signed URLs, so that it can be printed.
+func redactURL(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return "<invalid URL>"
+	}
+	u.RawQuery = ""
+	u.User = nil
+	return u.String()
+}
+
+// upload puts the snapshot of the given size to a pre-signed URL of an object
+// storage service. Object storage needs the size of the object up front, which
+// is why the snapshot is read from a file rather than streamed.
+func upload(ctx context.Context, location string, snap io.Reader, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, location, snap)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	// Azure Blob Storage requires the type of the blob to be created.
+	if strings.HasSuffix(req.URL.Hostname(), ".blob.core.windows.net") {
+		req.Header.Set("x-ms-blob-type", "BlockBlob")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error uploading snapshot to %s: %s", redactURL(location), unwrapURLError(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status %s uploading snapshot: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// download gets the snapshot from a pre-signed URL of an object storage
+// service. The returned reader must be closed.
+func download(ctx context.Context, location string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading snapshot from %s: %s", redactURL(location), unwrapURLError(err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("unexpected status %s downloading snapshot: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp.Body, nil
+}
+
+// unwrapURLError returns the cause of errors of the HTTP client, which would
+// otherwise print the URL including its signature.
+func unwrapURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
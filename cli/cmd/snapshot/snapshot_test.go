@@ -0,0 +1,212 @@
+package snapshot
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/hashicorp/consul-k8s/cli/helper/test"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+// TestValidateFlags tests the validate flags functions of both commands.
+func TestValidateFlags(t *testing.T) {
+	// The following cases should all error, if they fail to this test fails.
+	testCases := []struct {
+		description string
+		restore     bool
+		input       []string
+	}{
+		{
+			"Should require a destination.",
+			false,
+			[]string{},
+		},
+		{
+			"Should disallow multiple destinations.",
+			false,
+			[]string{"a.snap", "b.snap"},
+		},
+		{
+			"Should disallow object storage URLs that aren't pre-signed.",
+			false,
+			[]string{"s3://bucket/consul.snap"},
+		},
+		{
+			"Should require a source.",
+			true,
+			[]string{"-auto-approve"},
+		},
+		{
+			"Should require auto approve to read from stdin.",
+			true,
+			[]string{"-"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.description, func(t *testing.T) {
+			var err error
+			if testCase.restore {
+				c := &RestoreCommand{BaseCommand: getBaseCommand()}
+				c.init()
+				err = c.validateFlags(testCase.input)
+			} else {
+				c := &SaveCommand{BaseCommand: getBaseCommand()}
+				c.init()
+				err = c.validateFlags(testCase.input)
+			}
+			if err == nil {
+				t.Errorf("Test case should have failed.")
+			}
+		})
+	}
+}
+
+func TestLeader(t *testing.T) {
+	cases := map[string]struct {
+		leader     string
+		expPod     string
+		expErr     string
+		notRunning bool
+	}{
+		"first pod is the leader": {
+			leader: "10.0.0.1:8300",
+			expPod: "consul-server-0",
+		},
+		"other pod is the leader": {
+			leader: "10.0.0.2:8300",
+			expPod: "consul-server-1",
+		},
+		"leader is not a pod": {
+			leader: "10.0.0.9:8300",
+			expErr: `leader 10.0.0.9:8300 is not a running server pod in namespace "consul"`,
+		},
+		"no running servers": {
+			notRunning: true,
+			expErr:     `no running Consul server pods found in namespace "consul"`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := leaderServer(t, c.leader)
+			defer srv.Close()
+
+			server0 := test.ServerPod("consul-server-0", "consul", "10.0.0.1")
+			server1 := test.ServerPod("consul-server-1", "consul", "10.0.0.2")
+			if c.notRunning {
+				server0.Status.Phase = corev1.PodPending
+				server1.Status.Phase = corev1.PodPending
+			}
+			s := &server{
+				kubernetes:    fake.NewSimpleClientset(server0, server1),
+				flagNamespace: "consul",
+			}
+			var opened []string
+			s.openServer = func(pod *corev1.Pod) (*consul.Client, func(), error) {
+				opened = append(opened, pod.Name)
+				return &consul.Client{Addr: strings.TrimPrefix(srv.URL, "http://")}, func() {}, nil
+			}
+
+			_, closeClient, err := s.leader(context.Background())
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			closeClient()
+			require.Equal(t, c.expPod, opened[len(opened)-1])
+		})
+	}
+}
+
+func TestSaveAndRestore(t *testing.T) {
+	var restored string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/status/leader":
+			w.Write([]byte(`"10.0.0.1:8300"`))
+		case r.URL.Path == "/v1/snapshot" && r.Method == http.MethodGet:
+			w.Write([]byte("snapshot-data"))
+		case r.URL.Path == "/v1/snapshot" && r.Method == http.MethodPut:
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			restored = string(body)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	s := server{
+		kubernetes: fake.NewSimpleClientset(test.ServerPod("consul-server-0", "consul", "10.0.0.1")),
+		restConfig: &rest.Config{},
+		openServer: func(pod *corev1.Pod) (*consul.Client, func(), error) {
+			return &consul.Client{Addr: strings.TrimPrefix(srv.URL, "http://")}, func() {}, nil
+		},
+	}
+	file := filepath.Join(t.TempDir(), "consul.snap")
+
+	save := &SaveCommand{BaseCommand: getBaseCommand(), server: s}
+	require.Equal(t, 0, save.Run([]string{"-n", "consul", file}))
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, "snapshot-data", string(data))
+
+	restore := &RestoreCommand{BaseCommand: getBaseCommand(), server: s}
+	require.Equal(t, 0, restore.Run([]string{"-n", "consul", "-auto-approve", file}))
+	require.Equal(t, "snapshot-data", restored)
+}
+
+func TestUpload(t *testing.T) {
+	var uploaded string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPut, r.Method)
+		require.Equal(t, int64(len("snapshot-data")), r.ContentLength)
+		require.Equal(t, "sig", r.URL.Query().Get("X-Amz-Signature"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		uploaded = string(body)
+	}))
+	defer srv.Close()
+
+	location := srv.URL + "/bucket/consul.snap?X-Amz-Signature=sig"
+	require.NoError(t, upload(context.Background(), location, strings.NewReader("snapshot-data"), int64(len("snapshot-data"))))
+	require.Equal(t, "snapshot-data", uploaded)
+	require.Equal(t, srv.URL+"/bucket/consul.snap", redactURL(location))
+}
+
+// leaderServer returns a Consul API server that reports the given leader.
+func leaderServer(t *testing.T, leader string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/status/leader", r.URL.Path)
+		w.Write([]byte(`"` + leader + `"`))
+	}))
+}
+
+// getBaseCommand sets up a base command for tests.
+func getBaseCommand() *common.BaseCommand {
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	return &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+	}
+}
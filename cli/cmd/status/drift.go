@@ -0,0 +1,356 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// crdGroupVersion is the API group and version of the config entry CRDs.
+	crdGroupVersion = "consul.hashicorp.com/v1alpha1"
+
+	// datacenterMetaKey is the meta key the controller sets on the config
+	// entries it creates from CRDs.
+	datacenterMetaKey = "consul.hashicorp.com/source-datacenter"
+	// migrateEntryAnnotation lets the controller take over a config entry that
+	// it didn't create.
+	migrateEntryAnnotation = "consul.hashicorp.com/migrate-entry"
+)
+
+// configEntryKind is a kind of config entry and the CRD it is managed with.
+type configEntryKind struct {
+	// Kind is the kind of the config entry in Consul, e.g. "service-defaults".
+	Kind string
+	// CRDKind and Resource are the kind and plural resource name of the CRD.
+	CRDKind  string
+	Resource string
+}
+
+var configEntryKinds = []configEntryKind{
+	{Kind: "service-defaults", CRDKind: "ServiceDefaults", Resource: "servicedefaults"},
+	{Kind: "proxy-defaults", CRDKind: "ProxyDefaults", Resource: "proxydefaults"},
+	{Kind: "mesh", CRDKind: "Mesh", Resource: "meshes"},
+	{Kind: "service-resolver", CRDKind: "ServiceResolver", Resource: "serviceresolvers"},
+	{Kind: "service-router", CRDKind: "ServiceRouter", Resource: "servicerouters"},
+	{Kind: "service-splitter", CRDKind: "ServiceSplitter", Resource: "servicesplitters"},
+	{Kind: "service-intentions", CRDKind: "ServiceIntentions", Resource: "serviceintentions"},
+	{Kind: "ingress-gateway", CRDKind: "IngressGateway", Resource: "ingressgateways"},
+	{Kind: "terminating-gateway", CRDKind: "TerminatingGateway", Resource: "terminatinggateways"},
+	{Kind: "exported-services", CRDKind: "ExportedServices", Resource: "exportedservices"},
+	{Kind: "jwt-provider", CRDKind: "JWTProvider", Resource: "jwtproviders"},
+}
+
+// GroupVersionResource returns the resource of the CRD.
+func (k configEntryKind) GroupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: "consul.hashicorp.com", Version: "v1alpha1", Resource: k.Resource}
+}
+
+// consulName returns the name of the config entry the CRD is synced to.
+func (k configEntryKind) consulName(crd unstructured.Unstructured) string {
+	if k.Kind == "service-intentions" {
+		name, _, _ := unstructured.NestedString(crd.Object, "spec", "destination", "name")
+		return name
+	}
+	return crd.GetName()
+}
+
+// drift is a config entry that doesn't match the CRD it is managed with, or
+// that isn't managed by a CRD.
+type drift struct {
+	Kind   string
+	Name   string
+	Reason string
+
+	// entry is the config entry if it isn't managed by a CRD, which means it
+	// can be adopted.
+	entry consul.ConfigEntry
+}
+
+// detectDrift matches the config entries of the kind to the CRDs and returns
+// the entries created out-of-band, the CRDs without an entry and the entries
+// that differ from their CRD, sorted by name.
+func detectDrift(kind configEntryKind, crds []unstructured.Unstructured, entries []consul.ConfigEntry) []drift {
+	byName := make(map[string][]unstructured.Unstructured)
+	for _, crd := range crds {
+		name := kind.consulName(crd)
+		byName[name] = append(byName[name], crd)
+	}
+
+	var drifts []drift
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		name := entry.Name()
+		seen[name] = true
+		matching, ok := byName[name]
+		if !ok {
+			reason := "not managed by a CRD"
+			if dc := entry.Meta()[datacenterMetaKey]; dc != "" {
+				reason = fmt.Sprintf("not managed by a CRD, created from Kubernetes in datacenter %q", dc)
+			}
+			drifts = append(drifts, drift{Kind: kind.Kind, Name: name, Reason: reason, entry: entry})
+			continue
+		}
+
+		// Entries in Consul namespaces can share a name, so the entry matches
+		// if any of the CRDs with its name matches.
+		var diff []string
+		for _, crd := range matching {
+			spec, _ := normalize(crd.Object["spec"]).(map[string]interface{})
+			diff = specDiff(spec, entrySpec(kind, entry), "spec")
+			if len(diff) == 0 {
+				break
+			}
+		}
+		if len(diff) > 0 {
+			drifts = append(drifts, drift{Kind: kind.Kind, Name: name, Reason: "differs from the CRD: " + strings.Join(diff, ", ")})
+		}
+	}
+
+	for name, matching := range byName {
+		if seen[name] {
+			continue
+		}
+		reason := "CRD has no config entry"
+		if msg := syncedMessage(matching[0]); msg != "" {
+			reason += ": " + msg
+		}
+		drifts = append(drifts, drift{Kind: kind.Kind, Name: name, Reason: reason})
+	}
+
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].Name < drifts[j].Name })
+	return drifts
+}
+
+// syncedMessage returns the message of the Synced condition of the CRD if it
+// failed to sync.
+func syncedMessage(crd unstructured.Unstructured) string {
+	conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, _ := c.(map[string]interface{})
+		if condition["type"] == "Synced" && condition["status"] == "False" {
+			msg, _ := condition["message"].(string)
+			return msg
+		}
+	}
+	return ""
+}
+
+// adoptedCRD returns a CRD for the config entry in the namespace that the
+// controller takes the entry over with.
+func adoptedCRD(kind configEntryKind, entry consul.ConfigEntry, namespace string) (*unstructured.Unstructured, error) {
+	name := entry.Name()
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return nil, fmt.Errorf("%q is not a valid Kubernetes name: %s", name, strings.Join(errs, ", "))
+	}
+	crd := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": crdGroupVersion,
+		"kind":       kind.CRDKind,
+		"spec":       entrySpec(kind, entry),
+	}}
+	crd.SetName(name)
+	crd.SetNamespace(namespace)
+	crd.SetAnnotations(map[string]string{migrateEntryAnnotation: "true"})
+	return crd, nil
+}
+
+// readOnlyFields are the fields of config entries that are not in the spec of
+// the CRDs.
+var readOnlyFields = map[string]bool{
+	"Kind":        true,
+	"Name":        true,
+	"Namespace":   true,
+	"Partition":   true,
+	"Meta":        true,
+	"CreateIndex": true,
+	"ModifyIndex": true,
+}
+
+// entrySpec converts the config entry to the spec of its CRD.
+func entrySpec(kind configEntryKind, entry consul.ConfigEntry) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for k, v := range entry {
+		if !readOnlyFields[k] {
+			fields[k] = v
+		}
+	}
+	spec := specValue(fields).(map[string]interface{})
+	if kind.Kind == "service-intentions" {
+		spec["destination"] = map[string]interface{}{"name": entry.Name()}
+	}
+	return spec
+}
+
+// rawFields are copied to the spec as is because their contents are not
+// fields of the CRD, e.g. the opaque proxy config.
+var rawFields = map[string]bool{
+	"Config": true,
+	"Meta":   true,
+}
+
+// keyedFields are maps whose keys are names chosen by the user, e.g. the names
+// of subsets or headers, and so are kept as is.
+var keyedFields = map[string]bool{
+	"Subsets":  true,
+	"Failover": true,
+	"Add":      true,
+	"Set":      true,
+}
+
+// specValue converts the keys of the fields of a config entry from the
+// PascalCase of the Consul API to the camelCase of the CRDs.
+func specValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			switch inner, isMap := elem.(map[string]interface{}); {
+			case rawFields[k]:
+				m[camelCase(k)] = elem
+			case keyedFields[k] && isMap:
+				keyed := make(map[string]interface{}, len(inner))
+				for name, value := range inner {
+					keyed[name] = specValue(value)
+				}
+				m[camelCase(k)] = keyed
+			default:
+				m[camelCase(k)] = specValue(elem)
+			}
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, elem := range v {
+			list[i] = specValue(elem)
+		}
+		return list
+	default:
+		return v
+	}
+}
+
+// camelCase lowercases the leading upper case letters of the field name,
+// keeping the last one if it starts the next word, e.g. "CAFile" becomes
+// "caFile" and "TLS" becomes "tls".
+func camelCase(s string) string {
+	runes := []rune(s)
+	n := 0
+	for n < len(runes) && unicode.IsUpper(runes[n]) {
+		n++
+	}
+	if n > 1 && n < len(runes) && unicode.IsLower(runes[n]) {
+		n--
+	}
+	for i := 0; i < n; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// specDiff returns the paths of the fields set in the spec of the CRD whose
+// values differ in the spec converted from the config entry. Fields that are
+// only set in the config entry are ignored since Consul fills in defaults.
+func specDiff(spec, entry interface{}, path string) []string {
+	if isZero(spec) {
+		return nil
+	}
+	switch s := spec.(type) {
+	case map[string]interface{}:
+		e, ok := entry.(map[string]interface{})
+		if !ok {
+			return []string{path}
+		}
+		keys := make([]string, 0, len(s))
+		for k := range s {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var diff []string
+		for _, k := range keys {
+			diff = append(diff, specDiff(s[k], e[k], path+"."+k)...)
+		}
+		return diff
+	case []interface{}:
+		e, ok := entry.([]interface{})
+		if !ok || len(e) != len(s) {
+			return []string{path}
+		}
+		var diff []string
+		for i := range s {
+			diff = append(diff, specDiff(s[i], e[i], fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return diff
+	default:
+		if !scalarEqual(spec, entry) {
+			return []string{path}
+		}
+		return nil
+	}
+}
+
+// scalarEqual returns true if the values are equal, comparing durations by
+// their length since Consul may format them differently, e.g. "1m0s" for
+// "60s", or return them in nanoseconds.
+func scalarEqual(spec, entry interface{}) bool {
+	if reflect.DeepEqual(spec, entry) {
+		return true
+	}
+	s, ok := spec.(string)
+	if !ok {
+		return false
+	}
+	specDuration, err := time.ParseDuration(s)
+	if err != nil {
+		return false
+	}
+	switch e := entry.(type) {
+	case string:
+		entryDuration, err := time.ParseDuration(e)
+		return err == nil && specDuration == entryDuration
+	case float64:
+		return float64(specDuration) == e
+	}
+	return false
+}
+
+// isZero returns true for values that are not set in a CRD spec.
+func isZero(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// normalize converts the value to the types encoding/json decodes into, e.g.
+// the int64 numbers of unstructured objects to float64.
+func normalize(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return v
+	}
+	return out
+}
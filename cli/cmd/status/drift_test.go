@@ -0,0 +1,232 @@
+package status
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDetectDrift(t *testing.T) {
+	serviceDefaults := configEntryKinds[0]
+	crds := []unstructured.Unstructured{
+		crd("ServiceDefaults", "web", map[string]interface{}{"protocol": "http"}),
+		crd("ServiceDefaults", "api", map[string]interface{}{
+			"protocol":    "grpc",
+			"meshGateway": map[string]interface{}{"mode": "local"},
+		}),
+		crd("ServiceDefaults", "billing", map[string]interface{}{"protocol": "http"}),
+	}
+	crds[2].Object["status"] = map[string]interface{}{
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Synced", "status": "False", "message": "permission denied"},
+		},
+	}
+	entries := []consul.ConfigEntry{
+		{"Kind": "service-defaults", "Name": "web", "Protocol": "http", "MeshGateway": map[string]interface{}{}},
+		{"Kind": "service-defaults", "Name": "api", "Protocol": "http", "MeshGateway": map[string]interface{}{"Mode": "local"}},
+		{"Kind": "service-defaults", "Name": "legacy", "Protocol": "tcp"},
+	}
+
+	drifts := detectDrift(serviceDefaults, crds, entries)
+	require.Equal(t, []drift{
+		{Kind: "service-defaults", Name: "api", Reason: "differs from the CRD: spec.protocol"},
+		{Kind: "service-defaults", Name: "billing", Reason: "CRD has no config entry: permission denied"},
+		{Kind: "service-defaults", Name: "legacy", Reason: "not managed by a CRD", entry: entries[2]},
+	}, drifts)
+}
+
+func TestDetectDrift_ServiceIntentions(t *testing.T) {
+	intentions := configEntryKinds[6]
+	require.Equal(t, "service-intentions", intentions.Kind)
+
+	crds := []unstructured.Unstructured{
+		crd("ServiceIntentions", "web-intentions", map[string]interface{}{
+			"destination": map[string]interface{}{"name": "web"},
+			"sources": []interface{}{
+				map[string]interface{}{"name": "frontend", "action": "allow"},
+			},
+		}),
+	}
+	entries := []consul.ConfigEntry{
+		{"Kind": "service-intentions", "Name": "web", "Sources": []interface{}{
+			map[string]interface{}{"Name": "frontend", "Action": "allow", "Precedence": float64(9), "Type": "consul"},
+		}},
+	}
+	require.Empty(t, detectDrift(intentions, crds, entries))
+
+	entries[0]["Sources"] = []interface{}{
+		map[string]interface{}{"Name": "frontend", "Action": "deny"},
+	}
+	require.Equal(t, []drift{
+		{Kind: "service-intentions", Name: "web", Reason: "differs from the CRD: spec.sources[0].action"},
+	}, detectDrift(intentions, crds, entries))
+}
+
+func TestSpecDiff(t *testing.T) {
+	cases := map[string]struct {
+		spec    interface{}
+		entry   interface{}
+		expDiff []string
+	}{
+		"equal": {
+			spec:  map[string]interface{}{"protocol": "http"},
+			entry: map[string]interface{}{"protocol": "http", "mode": "transparent"},
+		},
+		"unset fields are ignored": {
+			spec:  map[string]interface{}{"protocol": "", "expose": map[string]interface{}{}},
+			entry: map[string]interface{}{"protocol": "http"},
+		},
+		"durations": {
+			spec:  map[string]interface{}{"connectTimeout": "60s", "requestTimeout": "1s"},
+			entry: map[string]interface{}{"connectTimeout": "1m0s", "requestTimeout": float64(1e9)},
+		},
+		"different list lengths": {
+			spec:    map[string]interface{}{"routes": []interface{}{"a", "b"}},
+			entry:   map[string]interface{}{"routes": []interface{}{"a"}},
+			expDiff: []string{"spec.routes"},
+		},
+		"nested": {
+			spec:    map[string]interface{}{"loadBalancer": map[string]interface{}{"policy": "maglev"}},
+			entry:   map[string]interface{}{"loadBalancer": map[string]interface{}{"policy": "random"}},
+			expDiff: []string{"spec.loadBalancer.policy"},
+		},
+		"missing in entry": {
+			spec:    map[string]interface{}{"loadBalancer": map[string]interface{}{"policy": "maglev"}},
+			entry:   map[string]interface{}{},
+			expDiff: []string{"spec.loadBalancer"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.expDiff, specDiff(c.spec, c.entry, "spec"))
+		})
+	}
+}
+
+func TestEntrySpec(t *testing.T) {
+	resolver := configEntryKinds[3]
+	require.Equal(t, "service-resolver", resolver.Kind)
+
+	spec := entrySpec(resolver, consul.ConfigEntry{
+		"Kind":          "service-resolver",
+		"Name":          "web",
+		"ModifyIndex":   float64(10),
+		"DefaultSubset": "V1",
+		"Subsets": map[string]interface{}{
+			"V1": map[string]interface{}{"Filter": "Service.Meta.version == v1", "OnlyPassing": true},
+		},
+		"LoadBalancer": map[string]interface{}{"Policy": "ring_hash"},
+	})
+	require.Equal(t, map[string]interface{}{
+		"defaultSubset": "V1",
+		"subsets": map[string]interface{}{
+			"V1": map[string]interface{}{"filter": "Service.Meta.version == v1", "onlyPassing": true},
+		},
+		"loadBalancer": map[string]interface{}{"policy": "ring_hash"},
+	}, spec)
+}
+
+func TestCamelCase(t *testing.T) {
+	for in, exp := range map[string]string{
+		"Protocol":         "protocol",
+		"MeshGateway":      "meshGateway",
+		"TLS":              "tls",
+		"SNI":              "sni",
+		"CAFile":           "caFile",
+		"TLSMinVersion":    "tlsMinVersion",
+		"protocol":         "protocol",
+		"OutboundListener": "outboundListener",
+		"":                 "",
+	} {
+		require.Equal(t, exp, camelCase(in), in)
+	}
+}
+
+func TestAdoptedCRD(t *testing.T) {
+	serviceDefaults := configEntryKinds[0]
+	adopted, err := adoptedCRD(serviceDefaults, consul.ConfigEntry{"Kind": "service-defaults", "Name": "web", "Protocol": "http"}, "consul-config")
+	require.NoError(t, err)
+	require.Equal(t, "ServiceDefaults", adopted.GetKind())
+	require.Equal(t, "web", adopted.GetName())
+	require.Equal(t, "consul-config", adopted.GetNamespace())
+	require.Equal(t, map[string]string{migrateEntryAnnotation: "true"}, adopted.GetAnnotations())
+	require.Equal(t, map[string]interface{}{"protocol": "http"}, adopted.Object["spec"])
+
+	intentions := configEntryKinds[6]
+	_, err = adoptedCRD(intentions, consul.ConfigEntry{"Kind": "service-intentions", "Name": "*"}, "consul-config")
+	require.Error(t, err)
+}
+
+func TestCheckConfigDrift(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/config/service-defaults":
+			w.Write([]byte(`[{"Kind": "service-defaults", "Name": "web", "Protocol": "http"}, {"Kind": "service-defaults", "Name": "legacy", "Protocol": "tcp"}]`))
+		case "/v1/config/exported-services":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid config entry kind: exported-services"))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer srv.Close()
+
+	c := getInitializedCommand(t)
+	c.Ctx = context.Background()
+	c.kubernetes = fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-server-0", Namespace: "consul", Labels: map[string]string{"app": "consul", "chart": "consul-helm", "component": "server"}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+	c.dynamic = fakeDynamicClient()
+	web := crd("ServiceDefaults", "web", map[string]interface{}{"protocol": "http"})
+	_, err := c.dynamic.Resource(configEntryKinds[0].GroupVersionResource()).Namespace("default").Create(c.Ctx, &web, metav1.CreateOptions{})
+	require.NoError(t, err)
+	c.openServer = func(pod *corev1.Pod) (*consul.Client, func(), error) {
+		return &consul.Client{Addr: strings.TrimPrefix(srv.URL, "http://")}, func() {}, nil
+	}
+	c.flagAdoptNamespace = "default"
+
+	err = c.checkConfigDrift("consul")
+	require.EqualError(t, err, "1 config entries drifted from the CRDs")
+
+	c.flagAdopt = true
+	require.NoError(t, c.checkConfigDrift("consul"))
+	adopted, err := c.dynamic.Resource(configEntryKinds[0].GroupVersionResource()).Namespace("default").Get(c.Ctx, "legacy", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "true", adopted.GetAnnotations()[migrateEntryAnnotation])
+}
+
+func crd(kind, name string, spec map[string]interface{}) unstructured.Unstructured {
+	obj := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": crdGroupVersion,
+		"kind":       kind,
+		"spec":       spec,
+	}}
+	obj.SetName(name)
+	obj.SetNamespace("default")
+	return obj
+}
+
+// fakeDynamicClient returns a dynamic client that can list the config entry
+// CRDs. Objects must be created through it since it can't guess the resources
+// of the CRD kinds.
+func fakeDynamicClient() *dynamicfake.FakeDynamicClient {
+	listKinds := make(map[schema.GroupVersionResource]string)
+	for _, kind := range configEntryKinds {
+		listKinds[kind.GroupVersionResource()] = kind.CRDKind + "List"
+	}
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds)
+}
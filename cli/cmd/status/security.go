@@ -0,0 +1,242 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+)
+
+// securityPosture is the security related configuration of a Consul cluster.
+type securityPosture struct {
+	config consul.SecurityConfig
+	// wildcardIntention is the action of the intention from all services to
+	// all services. It is empty if there is none.
+	wildcardIntention string
+	// anonymousPolicies are the policies of the anonymous token.
+	anonymousPolicies []consul.ACLPolicy
+	// globalManagementTokens are the tokens that have the global-management
+	// policy, directly or through a role.
+	globalManagementTokens []consul.ACLToken
+}
+
+// securityCheck is the result of checking one aspect of the security posture.
+type securityCheck struct {
+	Name   string
+	Result string
+	OK     bool
+}
+
+// checkSecurity reports the security posture of the Consul cluster: whether
+// ACLs and intentions deny by default, whether TLS is enforced, what the
+// anonymous token may do and which tokens have the global-management policy.
+// It returns an error if any check fails.
+func (c *Command) checkSecurity(namespace string) error {
+	client, closeClient, err := c.openAnyServer(namespace)
+	if err != nil {
+		return fmt.Errorf("error connecting to the Consul servers: %s", err)
+	}
+	defer closeClient()
+
+	posture, err := readSecurityPosture(c.Ctx, client)
+	if err != nil {
+		return err
+	}
+
+	c.UI.Output("Security Posture:", terminal.WithHeaderStyle())
+	checks := posture.checks()
+	tbl := terminal.NewTable("Check", "Result")
+	failed := 0
+	for _, check := range checks {
+		color := terminal.Green
+		if !check.OK {
+			color = terminal.Red
+			failed++
+		}
+		tbl.Rich([]string{check.Name, check.Result}, []string{"", color})
+	}
+	c.UI.Table(tbl)
+
+	for _, policy := range posture.anonymousPolicies {
+		c.UI.Output("Anonymous token policy %q:", policy.Name, terminal.WithHeaderStyle())
+		c.UI.Output(strings.TrimSpace(policy.Rules))
+	}
+
+	if len(posture.globalManagementTokens) > 0 {
+		c.UI.Output("Tokens with the global-management policy:", terminal.WithHeaderStyle())
+		tbl := terminal.NewTable("Accessor ID", "Description", "Local")
+		for _, token := range posture.globalManagementTokens {
+			tbl.Rich([]string{token.AccessorID, token.Description, fmt.Sprint(token.Local)}, nil)
+		}
+		c.UI.Table(tbl)
+		c.UI.Output("%d tokens have the global-management policy. Make sure that only the bootstrap token does.",
+			len(posture.globalManagementTokens), terminal.WithWarningStyle())
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d security checks failed", failed)
+	}
+	return nil
+}
+
+// readSecurityPosture reads the security posture from the Consul server. The
+// ACL tokens and policies are only read if ACLs are enabled.
+func readSecurityPosture(ctx context.Context, client *consul.Client) (*securityPosture, error) {
+	config, err := client.SecurityConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the Consul server configuration: %s", err)
+	}
+	posture := &securityPosture{config: *config}
+
+	entries, err := client.ConfigEntries(ctx, "service-intentions")
+	// Consul versions before 1.9 don't support service-intentions config
+	// entries.
+	if err != nil && !strings.Contains(err.Error(), "invalid config entry kind") {
+		return nil, fmt.Errorf("error listing service-intentions config entries: %s", err)
+	}
+	posture.wildcardIntention = wildcardIntentionAction(entries)
+
+	if !config.ACLsEnabled {
+		return posture, nil
+	}
+
+	anonymous, err := client.ACLToken(ctx, consul.AnonymousTokenID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the anonymous token: %s", err)
+	}
+	for _, link := range anonymous.Policies {
+		policy, err := client.ACLPolicy(ctx, link.ID)
+		if err != nil {
+			return nil, fmt.Errorf("error reading ACL policy %q: %s", link.Name, err)
+		}
+		posture.anonymousPolicies = append(posture.anonymousPolicies, *policy)
+	}
+
+	tokens, err := client.ACLTokens(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing ACL tokens: %s", err)
+	}
+	roles, err := client.ACLRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing ACL roles: %s", err)
+	}
+	posture.globalManagementTokens = globalManagementTokens(tokens, roles)
+	return posture, nil
+}
+
+// checks returns the result of each check of the security posture.
+func (p *securityPosture) checks() []securityCheck {
+	aclsDeny := p.config.ACLsEnabled && p.config.ACLDefaultPolicy == "deny"
+
+	checks := []securityCheck{
+		{Name: "ACLs enabled", Result: yesNo(p.config.ACLsEnabled), OK: p.config.ACLsEnabled},
+	}
+	if p.config.ACLsEnabled {
+		checks = append(checks, securityCheck{Name: "ACL default policy", Result: p.config.ACLDefaultPolicy, OK: aclsDeny})
+	} else {
+		checks = append(checks, securityCheck{Name: "ACL default policy", Result: "allow (ACLs disabled)"})
+	}
+
+	// Intentions default to the ACL default policy unless an intention from
+	// all services to all services overrides it.
+	intentions := securityCheck{Name: "Intentions default"}
+	switch {
+	case p.wildcardIntention != "":
+		intentions.Result = fmt.Sprintf("%s (* => * intention)", p.wildcardIntention)
+		intentions.OK = p.wildcardIntention == "deny"
+	case aclsDeny:
+		intentions.Result = "deny (ACL default policy)"
+		intentions.OK = true
+	default:
+		intentions.Result = "allow (ACL default policy)"
+	}
+	checks = append(checks,
+		intentions,
+		securityCheck{Name: "TLS verify_incoming", Result: yesNo(p.config.VerifyIncoming), OK: p.config.VerifyIncoming},
+		securityCheck{Name: "TLS verify_outgoing", Result: yesNo(p.config.VerifyOutgoing), OK: p.config.VerifyOutgoing},
+	)
+
+	if p.config.ACLsEnabled {
+		anonymous := securityCheck{Name: "Anonymous token", Result: "no policies", OK: true}
+		if len(p.anonymousPolicies) > 0 {
+			var names, writable []string
+			for _, policy := range p.anonymousPolicies {
+				names = append(names, policy.Name)
+				// Rules grant write access with "write" both in HCL and in JSON.
+				if policy.ID == consul.GlobalManagementPolicyID || strings.Contains(policy.Rules, `"write"`) {
+					writable = append(writable, policy.Name)
+				}
+			}
+			anonymous.Result = "policies " + strings.Join(names, ", ")
+			if len(writable) > 0 {
+				anonymous.Result += fmt.Sprintf("; write access granted by %s", strings.Join(writable, ", "))
+				anonymous.OK = false
+			}
+		}
+		checks = append(checks, anonymous)
+	}
+	return checks
+}
+
+// wildcardIntentionAction returns the action of the intention from all services
+// to all services in the service-intentions config entries, or "" if there is
+// none.
+func wildcardIntentionAction(entries []consul.ConfigEntry) string {
+	for _, entry := range entries {
+		if entry.Name() != "*" {
+			continue
+		}
+		sources, _ := entry["Sources"].([]interface{})
+		for _, s := range sources {
+			source, _ := s.(map[string]interface{})
+			if name, _ := source["Name"].(string); name != "*" {
+				continue
+			}
+			action, _ := source["Action"].(string)
+			return action
+		}
+	}
+	return ""
+}
+
+// globalManagementTokens returns the tokens that have the global-management
+// policy, directly or through one of their roles, sorted by accessor ID.
+func globalManagementTokens(tokens []consul.ACLToken, roles []consul.ACLRole) []consul.ACLToken {
+	managementRoles := make(map[string]bool)
+	for _, role := range roles {
+		if hasGlobalManagement(role.Policies) {
+			managementRoles[role.ID] = true
+		}
+	}
+	var matches []consul.ACLToken
+	for _, token := range tokens {
+		management := hasGlobalManagement(token.Policies)
+		for _, role := range token.Roles {
+			management = management || managementRoles[role.ID]
+		}
+		if management {
+			matches = append(matches, token)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].AccessorID < matches[j].AccessorID })
+	return matches
+}
+
+func hasGlobalManagement(policies []consul.ACLLink) bool {
+	for _, policy := range policies {
+		if policy.ID == consul.GlobalManagementPolicyID {
+			return true
+		}
+	}
+	return false
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
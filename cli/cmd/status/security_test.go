@@ -0,0 +1,175 @@
+package status
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSecurityPostureChecks(t *testing.T) {
+	secure := consul.SecurityConfig{ACLsEnabled: true, ACLDefaultPolicy: "deny", VerifyIncoming: true, VerifyOutgoing: true}
+
+	cases := map[string]struct {
+		posture  securityPosture
+		expected []securityCheck
+	}{
+		"secure": {
+			posture: securityPosture{
+				config:            secure,
+				anonymousPolicies: []consul.ACLPolicy{{ID: "a", Name: "anonymous-token-policy", Rules: `service_prefix "" { policy = "read" }`}},
+			},
+			expected: []securityCheck{
+				{Name: "ACLs enabled", Result: "yes", OK: true},
+				{Name: "ACL default policy", Result: "deny", OK: true},
+				{Name: "Intentions default", Result: "deny (ACL default policy)", OK: true},
+				{Name: "TLS verify_incoming", Result: "yes", OK: true},
+				{Name: "TLS verify_outgoing", Result: "yes", OK: true},
+				{Name: "Anonymous token", Result: "policies anonymous-token-policy", OK: true},
+			},
+		},
+		"ACLs and TLS disabled": {
+			posture: securityPosture{config: consul.SecurityConfig{ACLDefaultPolicy: "allow"}},
+			expected: []securityCheck{
+				{Name: "ACLs enabled", Result: "no"},
+				{Name: "ACL default policy", Result: "allow (ACLs disabled)"},
+				{Name: "Intentions default", Result: "allow (ACL default policy)"},
+				{Name: "TLS verify_incoming", Result: "no"},
+				{Name: "TLS verify_outgoing", Result: "no"},
+			},
+		},
+		"wildcard intention allows and anonymous token can write": {
+			posture: securityPosture{
+				config:            secure,
+				wildcardIntention: "allow",
+				anonymousPolicies: []consul.ACLPolicy{
+					{ID: "a", Name: "read", Rules: `node_prefix "" { policy = "read" }`},
+					{ID: "b", Name: "kv", Rules: `{"key_prefix": {"": {"policy": "write"}}}`},
+				},
+			},
+			expected: []securityCheck{
+				{Name: "ACLs enabled", Result: "yes", OK: true},
+				{Name: "ACL default policy", Result: "deny", OK: true},
+				{Name: "Intentions default", Result: "allow (* => * intention)"},
+				{Name: "TLS verify_incoming", Result: "yes", OK: true},
+				{Name: "TLS verify_outgoing", Result: "yes", OK: true},
+				{Name: "Anonymous token", Result: "policies read, kv; write access granted by kv"},
+			},
+		},
+		"wildcard intention denies with ACL default allow": {
+			posture: securityPosture{
+				config:            consul.SecurityConfig{ACLsEnabled: true, ACLDefaultPolicy: "allow", VerifyIncoming: true, VerifyOutgoing: true},
+				wildcardIntention: "deny",
+			},
+			expected: []securityCheck{
+				{Name: "ACLs enabled", Result: "yes", OK: true},
+				{Name: "ACL default policy", Result: "allow"},
+				{Name: "Intentions default", Result: "deny (* => * intention)", OK: true},
+				{Name: "TLS verify_incoming", Result: "yes", OK: true},
+				{Name: "TLS verify_outgoing", Result: "yes", OK: true},
+				{Name: "Anonymous token", Result: "no policies", OK: true},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.expected, c.posture.checks())
+		})
+	}
+}
+
+func TestWildcardIntentionAction(t *testing.T) {
+	require.Equal(t, "", wildcardIntentionAction(nil))
+	require.Equal(t, "", wildcardIntentionAction([]consul.ConfigEntry{
+		{"Kind": "service-intentions", "Name": "web", "Sources": []interface{}{
+			map[string]interface{}{"Name": "*", "Action": "allow"},
+		}},
+	}))
+	require.Equal(t, "deny", wildcardIntentionAction([]consul.ConfigEntry{
+		{"Kind": "service-intentions", "Name": "web", "Sources": []interface{}{
+			map[string]interface{}{"Name": "*", "Action": "allow"},
+		}},
+		{"Kind": "service-intentions", "Name": "*", "Sources": []interface{}{
+			map[string]interface{}{"Name": "frontend", "Action": "allow"},
+			map[string]interface{}{"Name": "*", "Action": "deny"},
+		}},
+	}))
+}
+
+func TestGlobalManagementTokens(t *testing.T) {
+	management := []consul.ACLLink{{ID: consul.GlobalManagementPolicyID, Name: "global-management"}}
+	tokens := []consul.ACLToken{
+		{AccessorID: "d", Policies: []consul.ACLLink{{ID: "p", Name: "read"}}},
+		{AccessorID: "c", Roles: []consul.ACLLink{{ID: "admin", Name: "admin"}}},
+		{AccessorID: "b", Policies: management},
+		{AccessorID: "a", Roles: []consul.ACLLink{{ID: "reader", Name: "reader"}}},
+	}
+	roles := []consul.ACLRole{
+		{ID: "admin", Name: "admin", Policies: management},
+		{ID: "reader", Name: "reader", Policies: []consul.ACLLink{{ID: "p", Name: "read"}}},
+	}
+	require.Equal(t, []consul.ACLToken{tokens[2], tokens[1]}, globalManagementTokens(tokens, roles))
+}
+
+func TestCheckSecurity(t *testing.T) {
+	cases := map[string]struct {
+		agentSelf string
+		expErr    string
+	}{
+		"secure": {
+			agentSelf: `{"DebugConfig": {"ACLsEnabled": true, "ACLResolverSettings": {"ACLDefaultPolicy": "deny"},
+  "TLS": {"InternalRPC": {"VerifyIncoming": true, "VerifyOutgoing": true}}}}`,
+		},
+		"TLS not enforced": {
+			agentSelf: `{"DebugConfig": {"ACLsEnabled": true, "ACLResolverSettings": {"ACLDefaultPolicy": "deny"}}}`,
+			expErr:    "2 security checks failed",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/agent/self":
+					w.Write([]byte(tc.agentSelf))
+				case "/v1/config/service-intentions":
+					w.Write([]byte(`[]`))
+				case "/v1/acl/token/" + consul.AnonymousTokenID:
+					w.Write([]byte(`{"AccessorID": "00000000-0000-0000-0000-000000000002", "Policies": [{"ID": "a", "Name": "anonymous-token-policy"}]}`))
+				case "/v1/acl/policy/a":
+					w.Write([]byte(`{"ID": "a", "Name": "anonymous-token-policy", "Rules": "service_prefix \"\" { policy = \"read\" }"}`))
+				case "/v1/acl/tokens":
+					w.Write([]byte(`[{"AccessorID": "b", "Description": "Bootstrap Token", "Policies": [{"ID": "00000000-0000-0000-0000-000000000001"}]}]`))
+				case "/v1/acl/roles":
+					w.Write([]byte(`[]`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer srv.Close()
+
+			c := getInitializedCommand(t)
+			c.Ctx = context.Background()
+			c.kubernetes = fake.NewSimpleClientset(&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "consul-server-0", Namespace: "consul", Labels: map[string]string{"app": "consul", "chart": "consul-helm", "component": "server"}},
+				Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+			})
+			c.openServer = func(pod *corev1.Pod) (*consul.Client, func(), error) {
+				return &consul.Client{Addr: strings.TrimPrefix(srv.URL, "http://")}, func() {}, nil
+			}
+
+			err := c.checkSecurity("consul")
+			if tc.expErr != "" {
+				require.EqualError(t, err, tc.expErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -3,29 +3,60 @@ package status
 import (
 	"errors"
 	"fmt"
+	"os"
 	"strconv"
+	"strings"
 	"sync"
 
+	"github.com/posener/complete"
 	"helm.sh/helm/v3/pkg/release"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	"github.com/hashicorp/consul-k8s/cli/common"
 	"github.com/hashicorp/consul-k8s/cli/common/flag"
 	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/consul"
 	"github.com/hashicorp/consul-k8s/cli/helm"
 	"helm.sh/helm/v3/pkg/action"
 	helmCLI "helm.sh/helm/v3/pkg/cli"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/yaml"
 )
 
+const (
+	flagNameConfigDrift    = "config-drift"
+	flagNameSecurity       = "security"
+	flagNameAdopt          = "adopt"
+	flagNameAdoptNamespace = "adopt-namespace"
+	flagNameToken          = "token"
+	flagNameCAFile         = "ca-file"
+)
+
 type Command struct {
 	*common.BaseCommand
 
 	kubernetes kubernetes.Interface
+	dynamic    dynamic.Interface
+	restConfig *rest.Config
+
+	// openServer returns a client for the HTTP API of the server pod and a
+	// function that closes the connection. It port forwards to the pod if it
+	// is not set, which lets tests replace it.
+	openServer consul.ServerOpener
 
 	set *flag.Sets
 
+	flagConfigDrift    bool
+	flagSecurity       bool
+	flagAdopt          bool
+	flagAdoptNamespace string
+	flagToken          string
+	flagCAFile         string
+
 	flagKubeConfig  string
 	flagKubeContext string
 
@@ -36,19 +67,69 @@ type Command struct {
 func (c *Command) init() {
 	c.set = flag.NewSets()
 
-	f := c.set.NewSet("Global Options")
+	f := c.set.NewSet("Command Options")
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameConfigDrift,
+		Target:  &c.flagConfigDrift,
+		Default: false,
+		Usage: "Compare the config entries in Consul with the config entry CRDs in the cluster and report the entries " +
+			"created outside of Kubernetes, the CRDs without an entry and the entries that differ from their CRD.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameAdopt,
+		Target:  &c.flagAdopt,
+		Default: false,
+		Usage: fmt.Sprintf("With -%s, create a CRD for each config entry that isn't managed by one, so that the "+
+			"controller takes the entry over.", flagNameConfigDrift),
+	})
 	f.StringVar(&flag.StringVar{
-		Name:    "kubeconfig",
-		Aliases: []string{"c"},
-		Target:  &c.flagKubeConfig,
+		Name:       flagNameAdoptNamespace,
+		Target:     &c.flagAdoptNamespace,
+		Default:    "default",
+		Usage:      "The Kubernetes namespace the CRDs of adopted config entries are created in.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameSecurity,
+		Target:  &c.flagSecurity,
+		Default: false,
+		Usage: "Report the security posture of the Consul cluster: whether ACLs and intentions deny by default, " +
+			"whether TLS verify_incoming and verify_outgoing are enforced, the policies of the anonymous token and " +
+			"the tokens with the global-management policy.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameToken,
+		Target:  &c.flagToken,
 		Default: "",
-		Usage:   "Path to kubeconfig file.",
+		Usage: fmt.Sprintf("Set the ACL token used to read the config entries with -%s and the configuration "+
+			"with -%s. It needs read permissions on all services and operator read permissions, and with -%s also "+
+			"agent and ACL read permissions. If not set, the %s environment variable is used.",
+			flagNameConfigDrift, flagNameSecurity, flagNameSecurity, common.TokenEnvVar),
 	})
 	f.StringVar(&flag.StringVar{
-		Name:    "context",
-		Target:  &c.flagKubeContext,
+		Name:    flagNameCAFile,
+		Target:  &c.flagCAFile,
 		Default: "",
-		Usage:   "Kubernetes context to use.",
+		Usage: fmt.Sprintf("Set the path to the CA certificate of the Consul servers. If set, the servers are called "+
+			"over HTTPS on port %d instead of HTTP on port %d.", consul.HTTPSPort, consul.HTTPPort),
+		Completion: complete.PredictFiles("*"),
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
 	})
 
 	c.help = c.set.Help()
@@ -76,16 +157,8 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
-	// helmCLI.New() will create a settings object which is used by the Helm Go SDK calls.
-	settings := helmCLI.New()
-	if c.flagKubeConfig != "" {
-		settings.KubeConfig = c.flagKubeConfig
-	}
-	if c.flagKubeContext != "" {
-		settings.KubeContext = c.flagKubeContext
-	}
-
-	if err := c.setupKubeClient(settings); err != nil {
+	settings, err := c.setupKubeClient()
+	if err != nil {
 		c.UI.Output(err.Error(), terminal.WithErrorStyle())
 		return 1
 	}
@@ -123,6 +196,27 @@ func (c *Command) Run(args []string) int {
 		c.UI.Output(s, terminal.WithSuccessStyle())
 	}
 
+	if s, err := c.checkSnapshotAgent(namespace); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	} else if s != "" {
+		c.UI.Output(s, terminal.WithSuccessStyle())
+	}
+
+	if c.flagConfigDrift {
+		if err := c.checkConfigDrift(namespace); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+	}
+
+	if c.flagSecurity {
+		if err := c.checkSecurity(namespace); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+	}
+
 	return 0
 }
 
@@ -131,6 +225,12 @@ func (c *Command) validateFlags(args []string) error {
 	if len(c.set.Args()) > 0 {
 		return errors.New("should have no non-flag arguments")
 	}
+	if c.flagAdopt && !c.flagConfigDrift {
+		return fmt.Errorf("-%s requires -%s", flagNameAdopt, flagNameConfigDrift)
+	}
+	if c.flagToken == "" {
+		c.flagToken = os.Getenv(common.TokenEnvVar)
+	}
 	return nil
 }
 
@@ -258,33 +358,167 @@ func (c *Command) checkConsulClients(namespace string) (string, error) {
 	return fmt.Sprintf("Consul clients healthy (%d/%d)", readyReplicas, desiredReplicas), nil
 }
 
-// setupKubeClient to use for non Helm SDK calls to the Kubernetes API The Helm SDK will use
-// settings.RESTClientGetter for its calls as well, so this will use a consistent method to
-// target the right cluster for both Helm SDK and non Helm SDK calls.
-func (c *Command) setupKubeClient(settings *helmCLI.EnvSettings) error {
-	if c.kubernetes == nil {
-		restConfig, err := settings.RESTClientGetter().ToRESTConfig()
+// checkSnapshotAgent uses the Kubernetes list function to report if the snapshot agents are healthy.
+// The snapshot agent is optional, so an empty string is returned if it is not installed.
+func (c *Command) checkSnapshotAgent(namespace string) (string, error) {
+	agents, err := c.kubernetes.AppsV1().Deployments(namespace).List(c.Ctx,
+		metav1.ListOptions{LabelSelector: "app=consul,chart=consul-helm,component=client-snapshot-agent"})
+	if err != nil {
+		return "", err
+	} else if len(agents.Items) == 0 {
+		return "", nil
+	} else if len(agents.Items) > 1 {
+		return "", errors.New("found multiple snapshot agent deployments")
+	}
+
+	desiredReplicas := 1
+	if agents.Items[0].Spec.Replicas != nil {
+		desiredReplicas = int(*agents.Items[0].Spec.Replicas)
+	}
+	readyReplicas := int(agents.Items[0].Status.ReadyReplicas)
+	if readyReplicas < desiredReplicas {
+		return "", fmt.Errorf("%d/%d Consul snapshot agents unhealthy", desiredReplicas-readyReplicas, desiredReplicas)
+	}
+	return fmt.Sprintf("Consul snapshot agents healthy (%d/%d)", readyReplicas, desiredReplicas), nil
+}
+
+// checkConfigDrift compares the config entries in Consul with the CRDs they are
+// managed with and prints the differences. The config entries that aren't
+// managed by a CRD are adopted if -adopt is set. It returns an error if any
+// drift remains.
+func (c *Command) checkConfigDrift(namespace string) error {
+	client, closeClient, err := c.openAnyServer(namespace)
+	if err != nil {
+		return fmt.Errorf("error connecting to the Consul servers: %s", err)
+	}
+	defer closeClient()
+
+	var drifts []drift
+	for _, kind := range configEntryKinds {
+		entries, err := client.ConfigEntries(c.Ctx, kind.Kind)
 		if err != nil {
-			c.UI.Output("Error retrieving Kubernetes authentication: %v", err, terminal.WithErrorStyle())
-			return err
+			// Older Consul versions don't support every kind of config entry.
+			if strings.Contains(err.Error(), "invalid config entry kind") {
+				continue
+			}
+			return fmt.Errorf("error listing %s config entries: %s", kind.Kind, err)
+		}
+		crds, err := c.dynamic.Resource(kind.GroupVersionResource()).List(c.Ctx, metav1.ListOptions{})
+		if k8serrors.IsNotFound(err) {
+			// The CRD isn't installed.
+			crds = &unstructured.UnstructuredList{}
+		} else if err != nil {
+			return fmt.Errorf("error listing %s resources: %s", kind.CRDKind, err)
+		}
+		drifts = append(drifts, detectDrift(kind, crds.Items, entries)...)
+	}
+
+	if len(drifts) == 0 {
+		c.UI.Output("Consul config entries match the CRDs", terminal.WithSuccessStyle())
+		return nil
+	}
+
+	c.UI.Output("Config Entry Drift:", terminal.WithHeaderStyle())
+	tbl := terminal.NewTable("Kind", "Name", "Drift")
+	for _, d := range drifts {
+		tbl.Rich([]string{d.Kind, d.Name, d.Reason}, nil)
+	}
+	c.UI.Table(tbl)
+
+	remaining := len(drifts)
+	if c.flagAdopt {
+		for _, d := range drifts {
+			if d.entry == nil {
+				continue
+			}
+			if err := c.adopt(d); err != nil {
+				c.UI.Output("Unable to adopt %s %q: %s", d.Kind, d.Name, err, terminal.WithErrorStyle())
+				continue
+			}
+			c.UI.Output("Adopted %s %q into a CRD in namespace %q", d.Kind, d.Name, c.flagAdoptNamespace, terminal.WithSuccessStyle())
+			remaining--
+		}
+	}
+	if remaining > 0 {
+		return fmt.Errorf("%d config entries drifted from the CRDs", remaining)
+	}
+	return nil
+}
+
+// adopt creates a CRD for the config entry that isn't managed by one.
+func (c *Command) adopt(d drift) error {
+	for _, kind := range configEntryKinds {
+		if kind.Kind != d.Kind {
+			continue
 		}
-		c.kubernetes, err = kubernetes.NewForConfig(restConfig)
+		crd, err := adoptedCRD(kind, d.entry, c.flagAdoptNamespace)
 		if err != nil {
-			c.UI.Output("Error initializing Kubernetes client: %v", err, terminal.WithErrorStyle())
 			return err
 		}
+		_, err = c.dynamic.Resource(kind.GroupVersionResource()).Namespace(c.flagAdoptNamespace).Create(c.Ctx, crd, metav1.CreateOptions{})
+		return err
 	}
+	return fmt.Errorf("unknown config entry kind %q", d.Kind)
+}
 
-	return nil
+// openAnyServer returns a client for the HTTP API of a running Consul server
+// in the namespace and a function that closes the connection.
+func (c *Command) openAnyServer(namespace string) (*consul.Client, func(), error) {
+	open := consul.ServerConfig{
+		KubeClient: c.kubernetes,
+		RestConfig: c.restConfig,
+		Token:      c.flagToken,
+		CAFile:     c.flagCAFile,
+	}.Opener(c.openServer)
+	return consul.OpenRunningServer(c.Ctx, c.kubernetes, namespace, open)
+}
+
+// setupKubeClient to use for non Helm SDK calls to the Kubernetes API and returns the settings
+// the Helm SDK uses for its calls, so that both Helm SDK and non Helm SDK calls target the
+// same cluster.
+func (c *Command) setupKubeClient() (*helmCLI.EnvSettings, error) {
+	settings, err := common.InitKubernetes(c.flagKubeConfig, c.flagKubeContext, &c.restConfig, &c.kubernetes)
+	if err != nil {
+		return nil, err
+	}
+	if c.dynamic == nil {
+		c.dynamic, err = dynamic.NewForConfig(c.restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error initializing Kubernetes client:\n%v", err)
+		}
+	}
+	return settings, nil
 }
 
 // Help returns a description of the command and how it is used.
 func (c *Command) Help() string {
 	c.once.Do(c.init)
-	return c.Synopsis() + "\n\nUsage: consul-k8s status [flags]\n\n" + c.help
+	return c.Synopsis() + "\n\nUsage: consul-k8s status [flags]\n\n" +
+		"With -config-drift, the config entries in the default Consul namespace and partition are compared with the\n" +
+		"config entry CRDs in all Kubernetes namespaces, and the command fails if any of them drifted.\n\n" +
+		"With -security, the security posture of the Consul cluster is checked, and the command fails if ACLs or\n" +
+		"intentions allow by default, TLS isn't enforced or the anonymous token has write access.\n\n" +
+		"Examples:\n" +
+		"  $ consul-k8s status\n" +
+		"  $ consul-k8s status -config-drift\n" +
+		"  $ consul-k8s status -security\n" +
+		"  $ consul-k8s status -config-drift -adopt -adopt-namespace consul-config\n\n" +
+		c.help
 }
 
 // Synopsis returns a one-line command summary.
 func (c *Command) Synopsis() string {
 	return "Check the status of a Consul installation on Kubernetes."
 }
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *Command) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *Command) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
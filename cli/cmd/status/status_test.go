@@ -165,6 +165,45 @@ func TestCheckConsulClients(t *testing.T) {
 	require.Contains(t, err.Error(), fmt.Sprintf("%d/%d Consul clients unhealthy", 1, desired))
 }
 
+// TestCheckSnapshotAgent creates a fake deployment and tests the checkSnapshotAgent function.
+func TestCheckSnapshotAgent(t *testing.T) {
+	c := getInitializedCommand(t)
+	c.kubernetes = fake.NewSimpleClientset()
+
+	// The snapshot agent is optional, so no deployment is not an error.
+	s, err := c.checkSnapshotAgent("default")
+	require.NoError(t, err)
+	require.Equal(t, "", s)
+
+	var replicas int32 = 2
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "consul-snapshot-agent",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "consul", "chart": "consul-helm", "component": "client-snapshot-agent"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+		},
+		Status: appsv1.DeploymentStatus{
+			ReadyReplicas: replicas,
+		},
+	}
+	c.kubernetes.AppsV1().Deployments("default").Create(context.Background(), deployment, metav1.CreateOptions{})
+
+	s, err = c.checkSnapshotAgent("default")
+	require.NoError(t, err)
+	require.Equal(t, "Consul snapshot agents healthy (2/2)", s)
+
+	// An agent that isn't ready should cause an error.
+	deployment.Status.ReadyReplicas = replicas - 1
+	c.kubernetes.AppsV1().Deployments("default").UpdateStatus(context.Background(), deployment, metav1.UpdateOptions{})
+
+	_, err = c.checkSnapshotAgent("default")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), fmt.Sprintf("%d/%d Consul snapshot agents unhealthy", 1, replicas))
+}
+
 // getInitializedCommand sets up a command struct for tests.
 func getInitializedCommand(t *testing.T) *Command {
 	t.Helper()
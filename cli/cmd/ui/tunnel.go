@@ -0,0 +1,108 @@
+package ui
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"sync"
+)
+
+// tokenHeader is the header the Consul UI sends the ACL token in.
+const tokenHeader = "X-Consul-Token"
+
+// tunnel keeps a port forward to a pod of the UI service open. It is opened on
+// first use and reopened, possibly to another pod, after it breaks, e.g.
+// because the pod was restarted.
+type tunnel struct {
+	// open port forwards to a ready pod of the UI service and returns the
+	// local address and a function that stops forwarding.
+	open func() (string, func(), error)
+
+	mu    sync.Mutex
+	addr  string
+	close func()
+}
+
+// get returns the local address of the port forward, opening it if needed.
+func (t *tunnel) get() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.addr != "" {
+		return t.addr, nil
+	}
+	addr, closeFunc, err := t.open()
+	if err != nil {
+		return "", err
+	}
+	t.addr, t.close = addr, closeFunc
+	return addr, nil
+}
+
+// reset closes the port forward to addr so that the next get opens a new
+// one. It does nothing if the port forward was already reopened.
+func (t *tunnel) reset(addr string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.addr == addr {
+		t.closeLocked()
+	}
+}
+
+// Close stops forwarding.
+func (t *tunnel) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closeLocked()
+}
+
+func (t *tunnel) closeLocked() {
+	if t.close != nil {
+		t.close()
+	}
+	t.addr, t.close = "", nil
+}
+
+// tunnelTransport sends requests through the tunnel. A request that fails to
+// be sent is retried once through a new port forward if it has no body, so
+// that the browser doesn't notice when the pod behind the tunnel changes.
+type tunnelTransport struct {
+	tunnel *tunnel
+	base   http.RoundTripper
+}
+
+func (t *tunnelTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		addr, err := t.tunnel.get()
+		if err != nil {
+			return nil, err
+		}
+		out := req.Clone(req.Context())
+		out.URL.Host = addr
+		resp, err := t.base.RoundTrip(out)
+		if err == nil {
+			return resp, nil
+		}
+		t.tunnel.reset(addr)
+		if attempt > 0 || (req.Body != nil && req.Body != http.NoBody) {
+			return nil, err
+		}
+	}
+}
+
+// newProxy returns a reverse proxy to the UI through the tunnel. The token is
+// added to the requests that the browser sends without one, which logs the
+// browser in without pasting the token into the UI.
+func newProxy(t *tunnel, scheme, token string, base http.RoundTripper) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = scheme
+			// The host is replaced with the local address of the port forward
+			// by the transport.
+			req.URL.Host = "consul-ui"
+			req.Host = ""
+			if token != "" && req.Header.Get(tokenHeader) == "" {
+				req.Header.Set(tokenHeader, token)
+			}
+		},
+		Transport: &tunnelTransport{tunnel: t, base: base},
+	}
+}
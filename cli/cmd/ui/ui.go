@@ -0,0 +1,455 @@
+// Package ui contains the command that opens the Consul UI through a port
+// forward.
+package ui
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/posener/complete"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	flagNameNamespace = "namespace"
+
+	flagNamePort = "port"
+	defaultPort  = 0
+
+	flagNameTokenSecret = "token-secret"
+
+	flagNameTokenSecretKey = "token-secret-key"
+	defaultTokenSecretKey  = "token"
+
+	flagNameCAFile = "ca-file"
+
+	flagNameNoBrowser = "no-browser"
+	defaultNoBrowser  = false
+
+	// uiSelector selects the UI service installed by the chart.
+	uiSelector = "app=consul,component=ui"
+
+	// caCertKey is the key of the CA certificate in the CA secret that the
+	// chart creates, which is named "<prefix>-ca-cert".
+	caCertKey = "tls.crt"
+
+	shutdownTimeout = 5 * time.Second
+)
+
+// Command opens the Consul UI through a port forward to the UI service.
+type Command struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+	restConfig *rest.Config
+
+	// openPod returns the local address of a port forward to the port of the
+	// pod and a function that stops forwarding. It port forwards to the pod if
+	// it is not set, which lets tests replace it.
+	openPod func(pod *corev1.Pod, port int) (string, func(), error)
+	// openBrowser opens the URL in the default browser. It runs the browser
+	// of the OS if it is not set, which lets tests replace it.
+	openBrowser func(url string) error
+	// ready is called with the local URL of the UI once it is served.
+	ready func(url string)
+
+	set *flag.Sets
+
+	flagNamespace      string
+	flagPort           int
+	flagTokenSecret    string
+	flagTokenSecretKey string
+	flagCAFile         string
+	flagNoBrowser      bool
+
+	flagKubeConfig  string
+	flagKubeContext string
+
+	once sync.Once
+	help string
+}
+
+// uiTarget is where the UI is served in the cluster.
+type uiTarget struct {
+	service *corev1.Service
+	// scheme is "https" if the UI service exposes the HTTPS port of the
+	// servers, which it does when TLS is enabled, and "http" otherwise.
+	scheme     string
+	targetPort intstr.IntOrString
+}
+
+func (c *Command) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameNamespace,
+		Aliases: []string{"n"},
+		Target:  &c.flagNamespace,
+		Usage: "Set the namespace of the Consul installation. " +
+			"If not set, the namespace of the installed Helm release is used.",
+		Completion: common.PredictKubeNamespaces,
+	})
+	f.IntVar(&flag.IntVar{
+		Name:    flagNamePort,
+		Target:  &c.flagPort,
+		Default: defaultPort,
+		Usage:   "Set the local port the UI is served on. If 0, a free port is picked.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameTokenSecret,
+		Target:  &c.flagTokenSecret,
+		Default: "",
+		Usage: "Set the name of a Kubernetes secret in the namespace of the Consul installation that contains " +
+			"an ACL token. The token is added to the requests of the browser, which logs it in to the UI.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameTokenSecretKey,
+		Target:  &c.flagTokenSecretKey,
+		Default: defaultTokenSecretKey,
+		Usage:   "Set the key of the ACL token in the secret set with -token-secret.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameCAFile,
+		Target:  &c.flagCAFile,
+		Default: "",
+		Usage: "Set the path to the CA certificate of the Consul servers when TLS is enabled. " +
+			"If not set, the CA certificate is read from the CA secret that the chart creates.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameNoBrowser,
+		Target:  &c.flagNoBrowser,
+		Default: defaultNoBrowser,
+		Usage:   "Don't open the UI in the browser.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run serves the UI on a local port until the command is interrupted.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("ui")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+	if err := c.initKubernetes(); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	target, err := c.findUI()
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	transport := &http.Transport{}
+	if target.scheme == "https" {
+		pool, err := c.caCertPool(target)
+		if err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+		// The certificates of the servers are valid for localhost, which is
+		// the address of the port forward.
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	token, err := c.readToken()
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	t := &tunnel{open: func() (string, func(), error) { return c.openTarget(target) }}
+	defer t.Close()
+	// Open the tunnel before serving so that errors are reported right away.
+	if _, err := t.get(); err != nil {
+		c.UI.Output("Error port forwarding to the UI: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", c.flagPort))
+	if err != nil {
+		c.UI.Output("Error listening on port %d: %v", c.flagPort, err, terminal.WithErrorStyle())
+		return 1
+	}
+	server := &http.Server{Handler: newProxy(t, target.scheme, token, transport)}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(listener)
+	}()
+
+	url := fmt.Sprintf("http://%s/ui/", listener.Addr().String())
+	c.UI.Output("Consul UI of service %s/%s is available at %s", target.service.Namespace, target.service.Name, url, terminal.WithSuccessStyle())
+	if token != "" {
+		c.UI.Output("Requests are sent with the ACL token of secret %s.", c.flagTokenSecret, terminal.WithInfoStyle())
+	}
+	c.UI.Output("Press Ctrl-C to stop.", terminal.WithInfoStyle())
+	if !c.flagNoBrowser {
+		if err := c.browse(url); err != nil {
+			c.UI.Output("Unable to open the browser: %v", err, terminal.WithWarningStyle())
+		}
+	}
+	if c.ready != nil {
+		c.ready(url)
+	}
+
+	select {
+	case err := <-errCh:
+		c.UI.Output("Error serving the UI: %v", err, terminal.WithErrorStyle())
+		return 1
+	case <-c.Ctx.Done():
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	_ = server.Shutdown(ctx)
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *Command) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if c.flagPort < 0 || c.flagPort > 65535 {
+		return fmt.Errorf("-%s must be between 0 and 65535", flagNamePort)
+	}
+	if c.flagTokenSecret != "" && c.flagTokenSecretKey == "" {
+		return fmt.Errorf("-%s must be set with -%s", flagNameTokenSecretKey, flagNameTokenSecret)
+	}
+	return nil
+}
+
+// initKubernetes creates the Kubernetes client and REST config if they are
+// not already set, and finds the namespace of the Consul installation if
+// -namespace is not set.
+func (c *Command) initKubernetes() error {
+	settings, err := common.InitKubernetes(c.flagKubeConfig, c.flagKubeContext, &c.restConfig, &c.kubernetes)
+	if err != nil {
+		return err
+	}
+
+	if c.flagNamespace == "" {
+		uiLogger := func(msg string, args ...interface{}) {
+			c.Log.Debug(fmt.Sprintf(msg, args...))
+		}
+		_, namespace, err := common.CheckForInstallations(settings, uiLogger)
+		if err != nil {
+			return err
+		}
+		c.flagNamespace = namespace
+	}
+	return nil
+}
+
+// findUI returns the UI service of the Consul installation and the port of
+// the servers it exposes.
+func (c *Command) findUI() (uiTarget, error) {
+	services, err := c.kubernetes.CoreV1().Services(c.flagNamespace).List(c.Ctx, metav1.ListOptions{LabelSelector: uiSelector})
+	if err != nil {
+		return uiTarget{}, fmt.Errorf("error listing services in namespace %s: %s", c.flagNamespace, err)
+	}
+	if len(services.Items) == 0 {
+		return uiTarget{}, fmt.Errorf("no UI service found in namespace %s: the UI must be enabled with ui.enabled and ui.service.enabled", c.flagNamespace)
+	}
+	svc := services.Items[0]
+
+	// The HTTPS port is preferred since the HTTP port may be disabled when TLS is enabled.
+	for _, scheme := range []string{"https", "http"} {
+		for _, port := range svc.Spec.Ports {
+			if port.Name == scheme {
+				return uiTarget{service: &svc, scheme: scheme, targetPort: port.TargetPort}, nil
+			}
+		}
+	}
+	return uiTarget{}, fmt.Errorf("UI service %s/%s has neither an http nor an https port", svc.Namespace, svc.Name)
+}
+
+// openTarget port forwards to the target port of a ready pod of the UI
+// service.
+func (c *Command) openTarget(target uiTarget) (string, func(), error) {
+	selector := labels.SelectorFromSet(target.service.Spec.Selector).String()
+	pods, err := c.kubernetes.CoreV1().Pods(target.service.Namespace).List(c.Ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return "", nil, fmt.Errorf("error listing the pods of service %s/%s: %s", target.service.Namespace, target.service.Name, err)
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !common.PodReady(*pod) {
+			continue
+		}
+		port, ok := containerPort(pod, target.targetPort)
+		if !ok {
+			continue
+		}
+		c.Log.Debug("port forwarding to the UI", "pod", pod.Name, "port", port)
+		if c.openPod != nil {
+			return c.openPod(pod, port)
+		}
+		pf := common.PortForward{
+			Namespace:  pod.Namespace,
+			PodName:    pod.Name,
+			RemotePort: port,
+			KubeClient: c.kubernetes,
+			RestConfig: c.restConfig,
+		}
+		addr, err := pf.Open()
+		if err != nil {
+			return "", nil, err
+		}
+		return addr, pf.Close, nil
+	}
+	return "", nil, fmt.Errorf("no ready pods of service %s/%s", target.service.Namespace, target.service.Name)
+}
+
+// caCertPool returns the CA certificate of the servers, which is read from
+// -ca-file or from the CA secret of the installation.
+func (c *Command) caCertPool(target uiTarget) (*x509.CertPool, error) {
+	var caPEM []byte
+	if c.flagCAFile != "" {
+		var err error
+		if caPEM, err = os.ReadFile(c.flagCAFile); err != nil {
+			return nil, fmt.Errorf("error reading CA file: %s", err)
+		}
+	} else {
+		// The UI service and the CA secret are both named after the prefix of the installation.
+		name := strings.TrimSuffix(target.service.Name, "-ui") + "-ca-cert"
+		secret, err := c.kubernetes.CoreV1().Secrets(c.flagNamespace).Get(c.Ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error reading the CA certificate from secret %s/%s, set -%s instead: %s",
+				c.flagNamespace, name, flagNameCAFile, err)
+		}
+		caPEM = secret.Data[caCertKey]
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("no certificates found in the CA certificate of the servers")
+	}
+	return pool, nil
+}
+
+// readToken returns the ACL token of -token-secret, or "" if it isn't set.
+func (c *Command) readToken() (string, error) {
+	if c.flagTokenSecret == "" {
+		return "", nil
+	}
+	secret, err := c.kubernetes.CoreV1().Secrets(c.flagNamespace).Get(c.Ctx, c.flagTokenSecret, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("error reading the ACL token from secret %s/%s: %s", c.flagNamespace, c.flagTokenSecret, err)
+	}
+	token := strings.TrimSpace(string(secret.Data[c.flagTokenSecretKey]))
+	if token == "" {
+		return "", fmt.Errorf("secret %s/%s has no %q key", c.flagNamespace, c.flagTokenSecret, c.flagTokenSecretKey)
+	}
+	return token, nil
+}
+
+// browse opens the URL in the default browser.
+func (c *Command) browse(url string) error {
+	if c.openBrowser != nil {
+		return c.openBrowser(url)
+	}
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
+
+// containerPort resolves the target port of a service to a port of the pod.
+func containerPort(pod *corev1.Pod, targetPort intstr.IntOrString) (int, bool) {
+	if targetPort.Type == intstr.Int {
+		return targetPort.IntValue(), true
+	}
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Name == targetPort.StrVal {
+				return int(port.ContainerPort), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Help returns a description of the command and how it is used.
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s ui [flags]\n\n" +
+		"Serves the Consul UI on a local port by port forwarding to a ready pod of the UI service,\n" +
+		"and opens it in the browser. The port forward is reopened, to another pod if needed, when it\n" +
+		"breaks. When TLS is enabled, the UI is still served over HTTP locally, and the connection to\n" +
+		"the servers is verified with their CA certificate.\n\n" +
+		"With -token-secret, the ACL token of the secret is added to the requests of the browser. The\n" +
+		"bootstrap token of an installation with ACLs enabled is in the secret consul-bootstrap-acl-token.\n\n" +
+		"Examples:\n" +
+		"  $ consul-k8s ui\n" +
+		"  $ consul-k8s ui -port 8500 -token-secret consul-bootstrap-acl-token\n\n" + c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *Command) Synopsis() string {
+	return "Open the Consul UI through a port forward."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *Command) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *Command) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
@@ -0,0 +1,232 @@
+package ui
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestValidateFlags(t *testing.T) {
+	// The following cases should all error, if they fail to this test fails.
+	testCases := []struct {
+		description string
+		input       []string
+	}{
+		{
+			"Should disallow non-flag arguments.",
+			[]string{"consul"},
+		},
+		{
+			"Should disallow a negative port.",
+			[]string{"-port", "-1"},
+		},
+		{
+			"Should disallow a port above 65535.",
+			[]string{"-port", "65536"},
+		},
+		{
+			"Should require a key with a token secret.",
+			[]string{"-token-secret", "consul-bootstrap-acl-token", "-token-secret-key", ""},
+		},
+	}
+
+	for _, testCase := range testCases {
+		c := getInitializedCommand(t)
+		t.Run(testCase.description, func(t *testing.T) {
+			if err := c.validateFlags(testCase.input); err == nil {
+				t.Errorf("Test case should have failed.")
+			}
+		})
+	}
+}
+
+func TestFindUI(t *testing.T) {
+	cases := map[string]struct {
+		ports         []corev1.ServicePort
+		expScheme     string
+		expTargetPort int
+		expErr        string
+	}{
+		"http": {
+			ports:         []corev1.ServicePort{{Name: "http", Port: 80, TargetPort: intstr.FromInt(8500)}},
+			expScheme:     "http",
+			expTargetPort: 8500,
+		},
+		"https is preferred": {
+			ports: []corev1.ServicePort{
+				{Name: "http", Port: 80, TargetPort: intstr.FromInt(8500)},
+				{Name: "https", Port: 443, TargetPort: intstr.FromInt(8501)},
+			},
+			expScheme:     "https",
+			expTargetPort: 8501,
+		},
+		"no ports": {
+			expErr: "UI service consul/consul-ui has neither an http nor an https port",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := getInitializedCommand(t)
+			c.kubernetes = fake.NewSimpleClientset(uiService(tc.ports...))
+			c.flagNamespace = "consul"
+
+			target, err := c.findUI()
+			if tc.expErr != "" {
+				require.EqualError(t, err, tc.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expScheme, target.scheme)
+			require.Equal(t, tc.expTargetPort, target.targetPort.IntValue())
+		})
+	}
+
+	t.Run("no UI service", func(t *testing.T) {
+		c := getInitializedCommand(t)
+		c.kubernetes = fake.NewSimpleClientset()
+		c.flagNamespace = "consul"
+		_, err := c.findUI()
+		require.EqualError(t, err, "no UI service found in namespace consul: the UI must be enabled with ui.enabled and ui.service.enabled")
+	})
+}
+
+func TestRun(t *testing.T) {
+	// The server pod serves the HTTPS API and records the token of the requests.
+	tokens := make(chan string, 10)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens <- r.Header.Get(tokenHeader)
+		_, _ = io.WriteString(w, "consul ui "+r.URL.Path)
+	}))
+	defer server.Close()
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	// The first port forward breaks, e.g. because the pod restarted.
+	broken, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	brokenAddr := broken.Addr().String()
+	require.NoError(t, broken.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := getInitializedCommand(t)
+	c.Ctx = ctx
+	c.kubernetes = fake.NewSimpleClientset(
+		uiService(corev1.ServicePort{Name: "https", Port: 443, TargetPort: intstr.FromInt(8501)}),
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "consul-server-0", Namespace: "consul", Labels: map[string]string{"component": "server"}},
+			Status: corev1.PodStatus{
+				Phase:      corev1.PodRunning,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "consul-server-1", Namespace: "consul", Labels: map[string]string{"component": "server"}},
+			Status:     corev1.PodStatus{Phase: corev1.PodPending},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "consul-ca-cert", Namespace: "consul"},
+			Data:       map[string][]byte{"tls.crt": caPEM},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "consul-bootstrap-acl-token", Namespace: "consul"},
+			Data:       map[string][]byte{"token": []byte("root-token\n")},
+		},
+	)
+	var opened []string
+	c.openPod = func(pod *corev1.Pod, port int) (string, func(), error) {
+		opened = append(opened, pod.Name)
+		require.Equal(t, 8501, port)
+		if len(opened) == 1 {
+			return brokenAddr, func() {}, nil
+		}
+		return server.Listener.Addr().String(), func() {}, nil
+	}
+	c.openBrowser = func(string) error { return nil }
+	urls := make(chan string, 1)
+	c.ready = func(url string) { urls <- url }
+
+	exitCode := make(chan int, 1)
+	go func() {
+		exitCode <- c.Run([]string{"-namespace", "consul", "-token-secret", "consul-bootstrap-acl-token"})
+	}()
+
+	var url string
+	select {
+	case url = <-urls:
+	case code := <-exitCode:
+		t.Fatalf("command exited with %d", code)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the UI to be served")
+	}
+
+	// The request is retried through a new port forward, with the token.
+	resp, err := http.Get(url + "services")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, "consul ui /ui/services", string(body))
+	require.Equal(t, "root-token", <-tokens)
+	require.Equal(t, []string{"consul-server-0", "consul-server-0"}, opened)
+
+	// Tokens sent by the browser are kept.
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	req.Header.Set(tokenHeader, "user-token")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, "user-token", <-tokens)
+
+	cancel()
+	require.Equal(t, 0, <-exitCode)
+}
+
+func uiService(ports ...corev1.ServicePort) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "consul-ui",
+			Namespace: "consul",
+			Labels:    map[string]string{"app": "consul", "component": "ui"},
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"component": "server"},
+			Ports:    ports,
+		},
+	}
+}
+
+// getInitializedCommand sets up a command struct for tests.
+func getInitializedCommand(t *testing.T) *Command {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+	}
+
+	c := &Command{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
@@ -0,0 +1,367 @@
+package uninstall
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// consulCRDGroup is the API group of the Consul custom resources.
+	consulCRDGroup = "consul.hashicorp.com"
+)
+
+// crdGVR identifies CustomResourceDefinitions for the dynamic client.
+var crdGVR = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+// resourceClass is a class of resources that can be left behind by a Consul installation.
+// The resources of each class are listed in the inventory and deleted together.
+type resourceClass struct {
+	// name is the plural name of the resources, e.g. "PVCs".
+	name string
+	// list returns the resources of the class formatted for display.
+	list func() ([]string, error)
+	// delete deletes the resources of the class.
+	delete func() error
+}
+
+// inventoryEntry is a resource class with the resources that were found for it.
+type inventoryEntry struct {
+	class     resourceClass
+	resources []string
+}
+
+// resourceClasses returns the classes of resources that belong to the installation,
+// in the order they should be deleted. Custom resources are deleted before their CRDs.
+func (c *Command) resourceClasses(releaseName, namespace string) []resourceClass {
+	releaseSelector := metav1.ListOptions{LabelSelector: fmt.Sprintf("release=%s", releaseName)}
+	return []resourceClass{
+		{
+			name: "PVCs",
+			list: func() ([]string, error) {
+				pvcs, err := c.kubernetes.CoreV1().PersistentVolumeClaims(namespace).List(c.Ctx, releaseSelector)
+				if err != nil {
+					return nil, err
+				}
+				var names []string
+				for _, pvc := range pvcs.Items {
+					names = append(names, pvc.Name)
+				}
+				return names, nil
+			},
+			delete: func() error { return c.deletePVCs(releaseName, namespace) },
+		},
+		{
+			// Secrets created by Consul components, such as ACL tokens, have the CLI label.
+			name: "Secrets",
+			list: func() ([]string, error) {
+				secrets, err := c.kubernetes.CoreV1().Secrets(namespace).List(c.Ctx, metav1.ListOptions{
+					LabelSelector: common.CLILabelKey + "=" + common.CLILabelValue,
+				})
+				if err != nil {
+					return nil, err
+				}
+				var names []string
+				for _, secret := range secrets.Items {
+					names = append(names, secret.Name)
+				}
+				return names, nil
+			},
+			delete: func() error { return c.deleteSecrets(releaseName, namespace) },
+		},
+		{
+			name: "Service Accounts",
+			list: func() ([]string, error) {
+				sas, err := c.kubernetes.CoreV1().ServiceAccounts(namespace).List(c.Ctx, releaseSelector)
+				if err != nil {
+					return nil, err
+				}
+				var names []string
+				for _, sa := range sas.Items {
+					names = append(names, sa.Name)
+				}
+				return names, nil
+			},
+			delete: func() error { return c.deleteServiceAccounts(releaseName, namespace) },
+		},
+		{
+			name: "Roles",
+			list: func() ([]string, error) {
+				roles, err := c.kubernetes.RbacV1().Roles(namespace).List(c.Ctx, releaseSelector)
+				if err != nil {
+					return nil, err
+				}
+				var names []string
+				for _, role := range roles.Items {
+					names = append(names, role.Name)
+				}
+				return names, nil
+			},
+			delete: func() error { return c.deleteRoles(releaseName, namespace) },
+		},
+		{
+			name: "Role Bindings",
+			list: func() ([]string, error) {
+				rolebindings, err := c.kubernetes.RbacV1().RoleBindings(namespace).List(c.Ctx, releaseSelector)
+				if err != nil {
+					return nil, err
+				}
+				var names []string
+				for _, rolebinding := range rolebindings.Items {
+					names = append(names, rolebinding.Name)
+				}
+				return names, nil
+			},
+			delete: func() error { return c.deleteRoleBindings(releaseName, namespace) },
+		},
+		{
+			name: "Jobs",
+			list: func() ([]string, error) {
+				jobs, err := c.kubernetes.BatchV1().Jobs(namespace).List(c.Ctx, releaseSelector)
+				if err != nil {
+					return nil, err
+				}
+				var names []string
+				for _, job := range jobs.Items {
+					names = append(names, job.Name)
+				}
+				return names, nil
+			},
+			delete: func() error { return c.deleteJobs(releaseName, namespace) },
+		},
+		{
+			name: "Cluster Roles",
+			list: func() ([]string, error) {
+				clusterRoles, err := c.kubernetes.RbacV1().ClusterRoles().List(c.Ctx, releaseSelector)
+				if err != nil {
+					return nil, err
+				}
+				var names []string
+				for _, clusterRole := range clusterRoles.Items {
+					names = append(names, clusterRole.Name)
+				}
+				return names, nil
+			},
+			delete: func() error { return c.deleteClusterRoles(releaseName) },
+		},
+		{
+			name: "Cluster Role Bindings",
+			list: func() ([]string, error) {
+				clusterRoleBindings, err := c.kubernetes.RbacV1().ClusterRoleBindings().List(c.Ctx, releaseSelector)
+				if err != nil {
+					return nil, err
+				}
+				var names []string
+				for _, clusterRoleBinding := range clusterRoleBindings.Items {
+					names = append(names, clusterRoleBinding.Name)
+				}
+				return names, nil
+			},
+			delete: func() error { return c.deleteClusterRoleBindings(releaseName) },
+		},
+		{
+			name: "Mutating Webhook Configurations",
+			list: func() ([]string, error) {
+				webhooks, err := c.kubernetes.AdmissionregistrationV1().MutatingWebhookConfigurations().List(c.Ctx, releaseSelector)
+				if err != nil {
+					return nil, err
+				}
+				var names []string
+				for _, webhook := range webhooks.Items {
+					names = append(names, webhook.Name)
+				}
+				return names, nil
+			},
+			delete: func() error { return c.deleteMutatingWebhookConfigurations(releaseName) },
+		},
+		{
+			name: "Consul custom resources",
+			list: func() ([]string, error) {
+				resources, err := c.listCustomResources()
+				if err != nil {
+					return nil, err
+				}
+				var names []string
+				for _, cr := range resources {
+					resource := cr.object
+					name := fmt.Sprintf("%s %s/%s", resource.GetKind(), resource.GetNamespace(), resource.GetName())
+					if finalizers := resource.GetFinalizers(); len(finalizers) > 0 {
+						name += fmt.Sprintf(" (finalizers: %s)", strings.Join(finalizers, ", "))
+					}
+					names = append(names, name)
+				}
+				return names, nil
+			},
+			delete: c.deleteCustomResources,
+		},
+		{
+			name: "Consul CRDs",
+			list: func() ([]string, error) {
+				crds, err := c.listConsulCRDs()
+				if err != nil {
+					return nil, err
+				}
+				var names []string
+				for _, crd := range crds {
+					names = append(names, crd.GetName())
+				}
+				return names, nil
+			},
+			delete: c.deleteConsulCRDs,
+		},
+	}
+}
+
+// inventory lists the resources that belong to the installation and returns the
+// resource classes that have resources.
+func (c *Command) inventory(releaseName, namespace string) ([]inventoryEntry, error) {
+	var entries []inventoryEntry
+	for _, class := range c.resourceClasses(releaseName, namespace) {
+		resources, err := class.list()
+		if err != nil {
+			return nil, fmt.Errorf("error listing %s: %s", class.name, err)
+		}
+		if len(resources) > 0 {
+			entries = append(entries, inventoryEntry{class: class, resources: resources})
+		}
+	}
+	return entries, nil
+}
+
+// printInventory prints the resources of each class in the inventory.
+func (c *Command) printInventory(entries []inventoryEntry) {
+	for _, entry := range entries {
+		c.UI.Output("%s (%d):", entry.class.name, len(entry.resources), terminal.WithInfoStyle())
+		for _, resource := range entry.resources {
+			c.UI.Output("  - %s", resource)
+		}
+	}
+}
+
+// deleteMutatingWebhookConfigurations deletes mutating webhook configurations that have the label release={{foundReleaseName}}.
+func (c *Command) deleteMutatingWebhookConfigurations(foundReleaseName string) error {
+	selector := metav1.ListOptions{LabelSelector: fmt.Sprintf("release=%s", foundReleaseName)}
+	webhooks, err := c.kubernetes.AdmissionregistrationV1().MutatingWebhookConfigurations().List(c.Ctx, selector)
+	if err != nil {
+		return fmt.Errorf("deleteMutatingWebhookConfigurations: %s", err)
+	}
+	for _, webhook := range webhooks.Items {
+		err := c.kubernetes.AdmissionregistrationV1().MutatingWebhookConfigurations().Delete(c.Ctx, webhook.Name, metav1.DeleteOptions{})
+		if err != nil {
+			return fmt.Errorf("deleteMutatingWebhookConfigurations: error deleting mutating webhook configuration %q: %s", webhook.Name, err)
+		}
+		c.UI.Output("Deleted mutating webhook configuration => %s", webhook.Name, terminal.WithSuccessStyle())
+	}
+	c.UI.Output("Consul mutating webhook configurations deleted.", terminal.WithSuccessStyle())
+	return nil
+}
+
+// listConsulCRDs returns the CRDs of the Consul custom resources.
+func (c *Command) listConsulCRDs() ([]unstructured.Unstructured, error) {
+	crds, err := c.dynamic.Resource(crdGVR).List(c.Ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var consulCRDs []unstructured.Unstructured
+	for _, crd := range crds.Items {
+		if group, _, _ := unstructured.NestedString(crd.Object, "spec", "group"); group == consulCRDGroup {
+			consulCRDs = append(consulCRDs, crd)
+		}
+	}
+	return consulCRDs, nil
+}
+
+// customResource is a Consul custom resource and the resource used to access it.
+type customResource struct {
+	gvr    schema.GroupVersionResource
+	object unstructured.Unstructured
+}
+
+// listCustomResources returns the Consul custom resources in all namespaces.
+func (c *Command) listCustomResources() ([]customResource, error) {
+	crds, err := c.listConsulCRDs()
+	if err != nil {
+		return nil, err
+	}
+	var resources []customResource
+	for _, crd := range crds {
+		gvr, ok := servedResource(crd)
+		if !ok {
+			continue
+		}
+		list, err := c.dynamic.Resource(gvr).Namespace(metav1.NamespaceAll).List(c.Ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list.Items {
+			resources = append(resources, customResource{gvr: gvr, object: item})
+		}
+	}
+	return resources, nil
+}
+
+// deleteCustomResources deletes the Consul custom resources in all namespaces. The finalizers
+// of the resources are removed first because the controller that would remove them after
+// deleting the config entries from Consul has been uninstalled.
+func (c *Command) deleteCustomResources() error {
+	resources, err := c.listCustomResources()
+	if err != nil {
+		return fmt.Errorf("deleteCustomResources: %s", err)
+	}
+	for _, cr := range resources {
+		resource := cr.object
+		client := c.dynamic.Resource(cr.gvr).Namespace(resource.GetNamespace())
+		if len(resource.GetFinalizers()) > 0 {
+			_, err := client.Patch(c.Ctx, resource.GetName(), types.MergePatchType, []byte(`{"metadata":{"finalizers":null}}`), metav1.PatchOptions{})
+			if err != nil {
+				return fmt.Errorf("deleteCustomResources: error removing finalizers from %s %q: %s", resource.GetKind(), resource.GetName(), err)
+			}
+		}
+		if err := client.Delete(c.Ctx, resource.GetName(), metav1.DeleteOptions{}); err != nil {
+			return fmt.Errorf("deleteCustomResources: error deleting %s %q: %s", resource.GetKind(), resource.GetName(), err)
+		}
+		c.UI.Output("Deleted %s => %s/%s", resource.GetKind(), resource.GetNamespace(), resource.GetName(), terminal.WithSuccessStyle())
+	}
+	c.UI.Output("Consul custom resources deleted.", terminal.WithSuccessStyle())
+	return nil
+}
+
+// deleteConsulCRDs deletes the CRDs of the Consul custom resources.
+func (c *Command) deleteConsulCRDs() error {
+	crds, err := c.listConsulCRDs()
+	if err != nil {
+		return fmt.Errorf("deleteConsulCRDs: %s", err)
+	}
+	for _, crd := range crds {
+		if err := c.dynamic.Resource(crdGVR).Delete(c.Ctx, crd.GetName(), metav1.DeleteOptions{}); err != nil {
+			return fmt.Errorf("deleteConsulCRDs: error deleting CRD %q: %s", crd.GetName(), err)
+		}
+		c.UI.Output("Deleted CRD => %s", crd.GetName(), terminal.WithSuccessStyle())
+	}
+	c.UI.Output("Consul CRDs deleted.", terminal.WithSuccessStyle())
+	return nil
+}
+
+// servedResource returns the resource of a CRD for its first served version.
+func servedResource(crd unstructured.Unstructured) (schema.GroupVersionResource, bool) {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	plural, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "plural")
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if served, _ := version["served"].(bool); served {
+			name, _ := version["name"].(string)
+			return schema.GroupVersionResource{Group: group, Version: name, Resource: plural}, true
+		}
+	}
+	return schema.GroupVersionResource{}, false
+}
@@ -0,0 +1,117 @@
+package uninstall
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var serviceRouterGVR = schema.GroupVersionResource{Group: consulCRDGroup, Version: "v1alpha1", Resource: "servicerouters"}
+
+func TestInventory(t *testing.T) {
+	c := getInitializedCommand(t)
+	c.kubernetes = fake.NewSimpleClientset(
+		&v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "consul-server-test1", Namespace: "default", Labels: map[string]string{"release": "consul"}}},
+		&v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "unrelated-pvc", Namespace: "default", Labels: map[string]string{"release": "unrelated"}}},
+		&admissionv1.MutatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "consul-connect-injector", Labels: map[string]string{"release": "consul"}}},
+	)
+	c.dynamic = newFakeDynamicClient(
+		consulCRD("servicerouters.consul.hashicorp.com", "servicerouters"),
+		crd("widgets.example.com", "example.com", "widgets"),
+		serviceRouter("web", "default", []string{"finalizers.consul.hashicorp.com"}),
+	)
+
+	inventory, err := c.inventory("consul", "default")
+	require.NoError(t, err)
+
+	found := make(map[string][]string)
+	for _, entry := range inventory {
+		found[entry.class.name] = entry.resources
+	}
+	require.Equal(t, map[string][]string{
+		"PVCs":                            {"consul-server-test1"},
+		"Mutating Webhook Configurations": {"consul-connect-injector"},
+		"Consul custom resources":         {"ServiceRouter default/web (finalizers: finalizers.consul.hashicorp.com)"},
+		"Consul CRDs":                     {"servicerouters.consul.hashicorp.com"},
+	}, found)
+}
+
+func TestDeleteMutatingWebhookConfigurations(t *testing.T) {
+	c := getInitializedCommand(t)
+	c.kubernetes = fake.NewSimpleClientset(
+		&admissionv1.MutatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "consul-connect-injector", Labels: map[string]string{"release": "consul"}}},
+		&admissionv1.MutatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Labels: map[string]string{"release": "unrelated"}}},
+	)
+	err := c.deleteMutatingWebhookConfigurations("consul")
+	require.NoError(t, err)
+	webhooks, err := c.kubernetes.AdmissionregistrationV1().MutatingWebhookConfigurations().List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, webhooks.Items, 1)
+	require.Equal(t, "unrelated", webhooks.Items[0].Name)
+}
+
+func TestDeleteCustomResourcesAndCRDs(t *testing.T) {
+	c := getInitializedCommand(t)
+	c.dynamic = newFakeDynamicClient(
+		consulCRD("servicerouters.consul.hashicorp.com", "servicerouters"),
+		crd("widgets.example.com", "example.com", "widgets"),
+		serviceRouter("web", "default", []string{"finalizers.consul.hashicorp.com"}),
+		serviceRouter("api", "other", nil),
+	)
+
+	require.NoError(t, c.deleteCustomResources())
+	resources, err := c.dynamic.Resource(serviceRouterGVR).Namespace(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Empty(t, resources.Items)
+
+	require.NoError(t, c.deleteConsulCRDs())
+	crds, err := c.dynamic.Resource(crdGVR).List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, crds.Items, 1)
+	require.Equal(t, "widgets.example.com", crds.Items[0].GetName())
+}
+
+func newFakeDynamicClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		crdGVR:           "CustomResourceDefinitionList",
+		serviceRouterGVR: "ServiceRouterList",
+	}, objects...)
+}
+
+func consulCRD(name, plural string) *unstructured.Unstructured {
+	return crd(name, consulCRDGroup, plural)
+}
+
+func crd(name, group, plural string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec": map[string]interface{}{
+			"group": group,
+			"names": map[string]interface{}{"plural": plural},
+			"versions": []interface{}{
+				map[string]interface{}{"name": "v1alpha1", "served": true},
+			},
+		},
+	}}
+}
+
+func serviceRouter(name, namespace string, finalizers []string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "consul.hashicorp.com/v1alpha1",
+		"kind":       "ServiceRouter",
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+	}}
+	u.SetFinalizers(finalizers)
+	return u
+}
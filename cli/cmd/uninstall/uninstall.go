@@ -11,9 +11,11 @@ import (
 	"github.com/hashicorp/consul-k8s/cli/common/flag"
 	"github.com/hashicorp/consul-k8s/cli/common/terminal"
 	"github.com/hashicorp/consul-k8s/cli/helm"
+	"github.com/posener/complete"
 	"helm.sh/helm/v3/pkg/action"
 	helmCLI "helm.sh/helm/v3/pkg/cli"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -38,6 +40,7 @@ type Command struct {
 	*common.BaseCommand
 
 	kubernetes kubernetes.Interface
+	dynamic    dynamic.Interface
 
 	set *flag.Sets
 
@@ -68,13 +71,14 @@ func (c *Command) init() {
 		Name:    flagWipeData,
 		Target:  &c.flagWipeData,
 		Default: defaultWipeData,
-		Usage:   "When used in combination with -auto-approve, all persisted data (PVCs and Secrets) from previous installations will be deleted. Only set this to true when data from previous installations is no longer necessary.",
+		Usage:   "When used in combination with -auto-approve, all persisted data and resources left behind by previous installations (PVCs, Secrets, CRDs and custom resources, etc.) will be deleted. Only set this to true when data from previous installations is no longer necessary.",
 	})
 	f.StringVar(&flag.StringVar{
-		Name:    flagNamespace,
-		Target:  &c.flagNamespace,
-		Default: defaultAllNamespaces,
-		Usage:   "Namespace for the Consul installation.",
+		Name:       flagNamespace,
+		Target:     &c.flagNamespace,
+		Default:    defaultAllNamespaces,
+		Usage:      "Namespace for the Consul installation.",
+		Completion: common.PredictKubeNamespaces,
 	})
 	f.StringVar(&flag.StringVar{
 		Name:    flagReleaseName,
@@ -91,17 +95,19 @@ func (c *Command) init() {
 
 	f = c.set.NewSet("Global Options")
 	f.StringVar(&flag.StringVar{
-		Name:    "kubeconfig",
-		Aliases: []string{"c"},
-		Target:  &c.flagKubeConfig,
-		Default: "",
-		Usage:   "Path to kubeconfig file.",
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
 	})
 	f.StringVar(&flag.StringVar{
-		Name:    "context",
-		Target:  &c.flagKubeContext,
-		Default: "",
-		Usage:   "Kubernetes context to use.",
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
 	})
 
 	c.help = c.set.Help()
@@ -154,7 +160,7 @@ func (c *Command) Run(args []string) int {
 	// Set up the kubernetes client to use for non Helm SDK calls to the Kubernetes API
 	// The Helm SDK will use settings.RESTClientGetter for its calls as well, so this will
 	// use a consistent method to target the right cluster for both Helm SDK and non Helm SDK calls.
-	if c.kubernetes == nil {
+	if c.kubernetes == nil || c.dynamic == nil {
 		restConfig, err := settings.RESTClientGetter().ToRESTConfig()
 		if err != nil {
 			c.UI.Output("retrieving Kubernetes auth: %v", err, terminal.WithErrorStyle())
@@ -165,6 +171,12 @@ func (c *Command) Run(args []string) int {
 			c.UI.Output("initializing Kubernetes client: %v", err, terminal.WithErrorStyle())
 			return 1
 		}
+		// The dynamic client is used for the CRDs and Consul custom resources.
+		c.dynamic, err = dynamic.NewForConfig(restConfig)
+		if err != nil {
+			c.UI.Output("initializing Kubernetes client: %v", err, terminal.WithErrorStyle())
+			return 1
+		}
 	}
 
 	// Setup logger to stream Helm library logs.
@@ -257,62 +269,43 @@ func (c *Command) Run(args []string) int {
 		c.UI.Output("Name: %s", foundReleaseName, terminal.WithInfoStyle())
 		c.UI.Output("Namespace %s", foundReleaseNamespace, terminal.WithInfoStyle())
 	}
-	// Prompt with a warning for approval before deleting PVCs, Secrets, Service Accounts, Roles, Role Bindings,
-	// Jobs, Cluster Roles, and Cluster Role Bindings.
-	if !c.flagAutoApprove {
-		confirmation, err := c.UI.Input(&terminal.Input{
-			Prompt: fmt.Sprintf("WARNING: Proceed with deleting PVCs, Secrets, Service Accounts, Roles, Role Bindings, Jobs, Cluster Roles, and Cluster Role Bindings for the following installation? \n\n   Name: %s \n   Namespace: %s \n\n   Only approve if all data from this installation can be deleted. (y/N)", foundReleaseName, foundReleaseNamespace),
-			Style:  terminal.WarningStyle,
-			Secret: false,
-		})
-		if err != nil {
-			c.UI.Output(err.Error(), terminal.WithErrorStyle())
-			return 1
-		}
-		if common.Abort(confirmation) {
-			c.UI.Output("Uninstall aborted without deleting PVCs and Secrets.", terminal.WithInfoStyle())
-			return 1
-		}
-	}
-
-	if err := c.deletePVCs(foundReleaseName, foundReleaseNamespace); err != nil {
-		c.UI.Output(err.Error(), terminal.WithErrorStyle())
-		return 1
-	}
-
-	if err := c.deleteSecrets(foundReleaseName, foundReleaseNamespace); err != nil {
-		c.UI.Output(err.Error(), terminal.WithErrorStyle())
-		return 1
-	}
-
-	if err := c.deleteServiceAccounts(foundReleaseName, foundReleaseNamespace); err != nil {
-		c.UI.Output(err.Error(), terminal.WithErrorStyle())
-		return 1
-	}
 
-	if err := c.deleteRoles(foundReleaseName, foundReleaseNamespace); err != nil {
-		c.UI.Output(err.Error(), terminal.WithErrorStyle())
-		return 1
-	}
-
-	if err := c.deleteRoleBindings(foundReleaseName, foundReleaseNamespace); err != nil {
+	// Find the resources that were left behind, e.g. because they were created outside of Helm
+	// or because finalizers stopped them from being deleted, and present them as an inventory.
+	inventory, err := c.inventory(foundReleaseName, foundReleaseNamespace)
+	if err != nil {
 		c.UI.Output(err.Error(), terminal.WithErrorStyle())
 		return 1
 	}
-
-	if err := c.deleteJobs(foundReleaseName, foundReleaseNamespace); err != nil {
-		c.UI.Output(err.Error(), terminal.WithErrorStyle())
-		return 1
+	if len(inventory) == 0 {
+		c.UI.Output("No other Consul resources found.", terminal.WithSuccessStyle())
+		return 0
 	}
+	c.printInventory(inventory)
 
-	if err := c.deleteClusterRoles(foundReleaseName); err != nil {
-		c.UI.Output(err.Error(), terminal.WithErrorStyle())
-		return 1
-	}
+	// Prompt with a warning for approval before deleting each class of resources.
+	for _, entry := range inventory {
+		if !c.flagAutoApprove {
+			confirmation, err := c.UI.Input(&terminal.Input{
+				Prompt: fmt.Sprintf("WARNING: Proceed with deleting the %d %s listed above for the following installation? \n\n   Name: %s \n   Namespace: %s \n\n   Only approve if all data from this installation can be deleted. (y/N)",
+					len(entry.resources), entry.class.name, foundReleaseName, foundReleaseNamespace),
+				Style:  terminal.WarningStyle,
+				Secret: false,
+			})
+			if err != nil {
+				c.UI.Output(err.Error(), terminal.WithErrorStyle())
+				return 1
+			}
+			if common.Abort(confirmation) {
+				c.UI.Output("Skipped deleting %s.", entry.class.name, terminal.WithInfoStyle())
+				continue
+			}
+		}
 
-	if err := c.deleteClusterRoleBindings(foundReleaseName); err != nil {
-		c.UI.Output(err.Error(), terminal.WithErrorStyle())
-		return 1
+		if err := entry.class.delete(); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
 	}
 
 	return 0
@@ -328,6 +321,18 @@ func (c *Command) Synopsis() string {
 	return "Uninstall Consul deployment."
 }
 
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *Command) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *Command) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
 func (c *Command) findExistingInstallation(settings *helmCLI.EnvSettings, uiLogger action.DebugLog) (bool, string, string, error) {
 	releaseName, namespace, err := common.CheckForInstallations(settings, uiLogger)
 	if err != nil {
@@ -0,0 +1,173 @@
+package upgrade
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/helm"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// crdGVR identifies CustomResourceDefinitions for the dynamic client.
+var crdGVR = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+// crdMigration rewrites a custom resource stored in an older version of its CRD so
+// that it is valid in the storage version of the target CRD, e.g. by moving a renamed
+// field. It returns true if it changed the resource.
+type crdMigration func(cr *unstructured.Unstructured) bool
+
+// crdMigrations are the migrations of the custom resources of each CRD, keyed by the
+// name of the CRD, that are run when the CRD has stored versions other than its target
+// storage version. Resources of CRDs without migrations are rewritten unchanged, which
+// is enough for the API server to store them in the storage version.
+var crdMigrations = map[string][]crdMigration{}
+
+// upgradeCRDs applies the CRDs rendered in the upgraded manifests before the rest of the
+// release is upgraded, since the upgraded controller may depend on them. The custom
+// resources of CRDs whose storage version changed are then migrated to the new storage
+// version so that older versions can be removed from the CRDs in future upgrades. During
+// a dry run, only the changes that would be made are reported.
+func (c *Command) upgradeCRDs(manifests string) error {
+	c.UI.Output("Upgrading CRDs", terminal.WithHeaderStyle())
+
+	docs, err := helm.ManifestsOfKind(manifests, "CustomResourceDefinition")
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		c.UI.Output("No CRDs to upgrade.", terminal.WithInfoStyle())
+		return nil
+	}
+
+	for _, doc := range docs {
+		var target unstructured.Unstructured
+		if err := yaml.Unmarshal([]byte(doc), &target.Object); err != nil {
+			return fmt.Errorf("error parsing CRD: %s", err)
+		}
+		if err := c.upgradeCRD(&target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// upgradeCRD creates or updates the CRD and migrates its custom resources if needed.
+func (c *Command) upgradeCRD(target *unstructured.Unstructured) error {
+	name := target.GetName()
+	client := c.dynamic.Resource(crdGVR)
+
+	current, err := client.Get(c.Ctx, name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		if !c.flagDryRun {
+			if _, err := client.Create(c.Ctx, target, metav1.CreateOptions{}); err != nil {
+				return fmt.Errorf("error creating CRD %q: %s", name, err)
+			}
+		}
+		c.UI.Output("Created CRD => %s", name, terminal.WithSuccessStyle())
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error getting CRD %q: %s", name, err)
+	}
+
+	// Only the spec and the labels are replaced so that the metadata added by Helm and
+	// the API server, which identifies the release owning the CRD, is kept.
+	storedVersions, _, _ := unstructured.NestedStringSlice(current.Object, "status", "storedVersions")
+	updated := current.DeepCopy()
+	updated.Object["spec"] = target.Object["spec"]
+	labels := updated.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	for k, v := range target.GetLabels() {
+		labels[k] = v
+	}
+	updated.SetLabels(labels)
+	if !c.flagDryRun {
+		if updated, err = client.Update(c.Ctx, updated, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("error updating CRD %q: %s", name, err)
+		}
+	}
+	c.UI.Output("Updated CRD => %s", name, terminal.WithSuccessStyle())
+
+	storageVersion, ok := storageVersion(target)
+	if !ok {
+		return fmt.Errorf("CRD %q has no storage version", name)
+	}
+	var staleVersions []string
+	for _, v := range storedVersions {
+		if v != storageVersion {
+			staleVersions = append(staleVersions, v)
+		}
+	}
+	if len(staleVersions) == 0 {
+		return nil
+	}
+	c.UI.Output("CRD %s has resources stored in versions %v that must be migrated to %s",
+		name, staleVersions, storageVersion, terminal.WithInfoStyle())
+	return c.migrateCustomResources(updated, storageVersion)
+}
+
+// migrateCustomResources rewrites every custom resource of the CRD in the storage version,
+// running the migrations of the CRD on them, and then records the storage version as the
+// only stored version of the CRD.
+func (c *Command) migrateCustomResources(crd *unstructured.Unstructured, storageVersion string) error {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	plural, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "plural")
+	gvr := schema.GroupVersionResource{Group: group, Version: storageVersion, Resource: plural}
+
+	list, err := c.dynamic.Resource(gvr).Namespace(metav1.NamespaceAll).List(c.Ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error listing %s: %s", plural, err)
+	}
+	for i := range list.Items {
+		cr := &list.Items[i]
+		changed := false
+		for _, migrate := range crdMigrations[crd.GetName()] {
+			if migrate(cr) {
+				changed = true
+			}
+		}
+		if !c.flagDryRun {
+			if _, err := c.dynamic.Resource(gvr).Namespace(cr.GetNamespace()).Update(c.Ctx, cr, metav1.UpdateOptions{}); err != nil {
+				return fmt.Errorf("error migrating %s %s/%s: %s", cr.GetKind(), cr.GetNamespace(), cr.GetName(), err)
+			}
+		}
+		if changed {
+			c.UI.Output("Migrated %s => %s/%s", cr.GetKind(), cr.GetNamespace(), cr.GetName(), terminal.WithSuccessStyle())
+		} else {
+			c.UI.Output("Rewrote %s in %s => %s/%s", cr.GetKind(), storageVersion, cr.GetNamespace(), cr.GetName(), terminal.WithSuccessStyle())
+		}
+	}
+
+	if c.flagDryRun {
+		return nil
+	}
+	if err := unstructured.SetNestedStringSlice(crd.Object, []string{storageVersion}, "status", "storedVersions"); err != nil {
+		return err
+	}
+	if _, err := c.dynamic.Resource(crdGVR).UpdateStatus(c.Ctx, crd, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating the stored versions of CRD %q: %s", crd.GetName(), err)
+	}
+	c.UI.Output("Migrated %d resources of CRD %s to %s", len(list.Items), crd.GetName(), storageVersion, terminal.WithSuccessStyle())
+	return nil
+}
+
+// storageVersion returns the name of the version of the CRD that resources are stored in.
+func storageVersion(crd *unstructured.Unstructured) (string, bool) {
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if storage, _ := version["storage"].(bool); storage {
+			name, _ := version["name"].(string)
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,140 @@
+package upgrade
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+var (
+	serviceRouterV1alpha1GVR = schema.GroupVersionResource{Group: "consul.hashicorp.com", Version: "v1alpha1", Resource: "servicerouters"}
+	serviceRouterV1beta1GVR  = schema.GroupVersionResource{Group: "consul.hashicorp.com", Version: "v1beta1", Resource: "servicerouters"}
+)
+
+const serviceRouterCRDManifest = `---
+# Source: consul/templates/crd-servicerouters.yaml
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: servicerouters.consul.hashicorp.com
+  labels:
+    chart: consul-helm
+spec:
+  group: consul.hashicorp.com
+  names:
+    plural: servicerouters
+  versions:
+  - name: v1alpha1
+    served: true
+    storage: false
+  - name: v1beta1
+    served: true
+    storage: true
+---
+# Source: consul/templates/server-service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: consul-server
+`
+
+func TestUpgradeCRDs_CreatesCRD(t *testing.T) {
+	c := getInitializedCommand(t)
+	c.UI = terminal.NewBasicUI(context.Background())
+	c.dynamic = newFakeDynamicClient()
+
+	require.NoError(t, c.upgradeCRDs(serviceRouterCRDManifest))
+
+	crd, err := c.dynamic.Resource(crdGVR).Get(context.Background(), "servicerouters.consul.hashicorp.com", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "consul-helm", crd.GetLabels()["chart"])
+}
+
+func TestUpgradeCRDs_MigratesStoredVersions(t *testing.T) {
+	c := getInitializedCommand(t)
+	c.UI = terminal.NewBasicUI(context.Background())
+
+	current := serviceRouterCRD("v1alpha1")
+	current.SetAnnotations(map[string]string{"meta.helm.sh/release-name": "consul"})
+	c.dynamic = newFakeDynamicClient(current, serviceRouter("foo", "default"))
+
+	migrated := false
+	crdMigrations["servicerouters.consul.hashicorp.com"] = []crdMigration{
+		func(cr *unstructured.Unstructured) bool {
+			migrated = true
+			return unstructured.SetNestedField(cr.Object, "bar", "spec", "renamed") == nil
+		},
+	}
+	defer delete(crdMigrations, "servicerouters.consul.hashicorp.com")
+
+	require.NoError(t, c.upgradeCRDs(serviceRouterCRDManifest))
+	require.True(t, migrated)
+
+	crd, err := c.dynamic.Resource(crdGVR).Get(context.Background(), "servicerouters.consul.hashicorp.com", metav1.GetOptions{})
+	require.NoError(t, err)
+	// The metadata of the current CRD is kept.
+	require.Equal(t, "consul", crd.GetAnnotations()["meta.helm.sh/release-name"])
+	storedVersions, _, _ := unstructured.NestedStringSlice(crd.Object, "status", "storedVersions")
+	require.Equal(t, []string{"v1beta1"}, storedVersions)
+
+	router, err := c.dynamic.Resource(serviceRouterV1beta1GVR).Namespace("default").Get(context.Background(), "foo", metav1.GetOptions{})
+	require.NoError(t, err)
+	renamed, _, _ := unstructured.NestedString(router.Object, "spec", "renamed")
+	require.Equal(t, "bar", renamed)
+}
+
+func TestUpgradeCRDs_DryRun(t *testing.T) {
+	c := getInitializedCommand(t)
+	c.UI = terminal.NewBasicUI(context.Background())
+	c.flagDryRun = true
+	c.dynamic = newFakeDynamicClient(serviceRouterCRD("v1alpha1"))
+
+	require.NoError(t, c.upgradeCRDs(serviceRouterCRDManifest))
+
+	crd, err := c.dynamic.Resource(crdGVR).Get(context.Background(), "servicerouters.consul.hashicorp.com", metav1.GetOptions{})
+	require.NoError(t, err)
+	storedVersions, _, _ := unstructured.NestedStringSlice(crd.Object, "status", "storedVersions")
+	require.Equal(t, []string{"v1alpha1"}, storedVersions)
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	require.Len(t, versions, 1)
+}
+
+func newFakeDynamicClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		crdGVR:                   "CustomResourceDefinitionList",
+		serviceRouterV1alpha1GVR: "ServiceRouterList",
+		serviceRouterV1beta1GVR:  "ServiceRouterList",
+	}, objects...)
+}
+
+func serviceRouterCRD(storedVersions ...string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": "servicerouters.consul.hashicorp.com"},
+		"spec": map[string]interface{}{
+			"group": "consul.hashicorp.com",
+			"names": map[string]interface{}{"plural": "servicerouters"},
+			"versions": []interface{}{
+				map[string]interface{}{"name": "v1alpha1", "served": true},
+			},
+		},
+	}}
+	_ = unstructured.SetNestedStringSlice(u.Object, storedVersions, "status", "storedVersions")
+	return u
+}
+
+func serviceRouter(name, namespace string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "consul.hashicorp.com/v1beta1",
+		"kind":       "ServiceRouter",
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+	}}
+}
@@ -1,6 +1,7 @@
 package upgrade
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -14,10 +15,16 @@ import (
 	"github.com/hashicorp/consul-k8s/cli/common/terminal"
 	"github.com/hashicorp/consul-k8s/cli/config"
 	"github.com/hashicorp/consul-k8s/cli/helm"
+	"github.com/hashicorp/consul-k8s/cli/preflight"
+	"github.com/hashicorp/consul-k8s/cli/rollout"
+	"github.com/posener/complete"
 	"helm.sh/helm/v3/pkg/action"
+	helmChart "helm.sh/helm/v3/pkg/chart"
 	helmCLI "helm.sh/helm/v3/pkg/cli"
 	"helm.sh/helm/v3/pkg/cli/values"
 	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -33,9 +40,18 @@ const (
 	flagNameDryRun = "dry-run"
 	defaultDryRun  = false
 
+	flagNameDryRunOutput  = "dry-run-output"
+	dryRunOutputSummary   = "summary"
+	dryRunOutputManifests = "manifests"
+	dryRunOutputDiff      = "diff"
+	defaultDryRunOutput   = dryRunOutputSummary
+
 	flagNameAutoApprove = "auto-approve"
 	defaultAutoApprove  = false
 
+	flagNameForce = "force"
+	defaultForce  = false
+
 	flagNameTimeout = "timeout"
 	defaultTimeout  = "10m"
 
@@ -44,18 +60,33 @@ const (
 
 	flagNameWait = "wait"
 	defaultWait  = true
+
+	flagNameWaitStrategy = "wait-strategy"
+	waitStrategyRollout  = "rollout"
+	waitStrategyHelm     = "helm"
+	defaultWaitStrategy  = waitStrategyRollout
+
+	flagNameCRDsOnly = "crds-only"
+	defaultCRDsOnly  = false
+
+	// diagnoseTimeout bounds the time spent collecting the logs and events of
+	// the components that aren't ready.
+	diagnoseTimeout = 30 * time.Second
 )
 
 type Command struct {
 	*common.BaseCommand
 
 	kubernetes kubernetes.Interface
+	dynamic    dynamic.Interface
 
 	set *flag.Sets
 
 	flagPreset          string
 	flagDryRun          bool
+	flagDryRunOutput    string
 	flagAutoApprove     bool
+	flagForce           bool
 	flagValueFiles      []string
 	flagSetStringValues []string
 	flagSetValues       []string
@@ -63,7 +94,9 @@ type Command struct {
 	flagTimeout         string
 	timeoutDuration     time.Duration
 	flagVerbose         bool
+	flagCRDsOnly        bool
 	flagWait            bool
+	flagWaitStrategy    string
 
 	flagKubeConfig  string
 	flagKubeContext string
@@ -93,6 +126,20 @@ func (c *Command) init() {
 		Default: defaultDryRun,
 		Usage:   "Perform pre-upgrade checks and display summary of upgrade.",
 	})
+	f.EnumSingleVar(&flag.EnumSingleVar{
+		Name:    flagNameDryRunOutput,
+		Target:  &c.flagDryRunOutput,
+		Default: defaultDryRunOutput,
+		Values:  []string{dryRunOutputSummary, dryRunOutputManifests, dryRunOutputDiff},
+		Usage: "Set what is displayed by a dry run: only the summary, the full Kubernetes manifests rendered from the chart, " +
+			"or the diff of those manifests against the live objects in the cluster. Requires -dry-run.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameForce,
+		Target:  &c.flagForce,
+		Default: defaultForce,
+		Usage:   "Upgrade even if the pre-upgrade checks find breaking changes.",
+	})
 	f.StringSliceVar(&flag.StringSliceVar{
 		Name:    flagNameConfigFile,
 		Aliases: []string{"f"},
@@ -140,20 +187,38 @@ func (c *Command) init() {
 		Default: defaultWait,
 		Usage:   "Wait for Kubernetes resources in upgrade to be ready before exiting command.",
 	})
+	f.EnumSingleVar(&flag.EnumSingleVar{
+		Name:    flagNameWaitStrategy,
+		Target:  &c.flagWaitStrategy,
+		Default: defaultWaitStrategy,
+		Values:  []string{waitStrategyRollout, waitStrategyHelm},
+		Usage: "Set how the command waits for the upgrade to be ready: rollout watches each component and prints " +
+			"its progress, e.g. the servers that joined the Raft cluster, and prints the logs and events of the pods that " +
+			"aren't ready if the timeout is reached. helm uses the wait of Helm, which only reports when all resources are ready.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameCRDsOnly,
+		Target:  &c.flagCRDsOnly,
+		Default: defaultCRDsOnly,
+		Usage: "Only upgrade the CRDs to the versions of the target chart and migrate the existing custom resources " +
+			"to their new storage versions, without upgrading the rest of the installation.",
+	})
 
 	f = c.set.NewSet("Global Options")
 	f.StringVar(&flag.StringVar{
-		Name:    "kubeconfig",
-		Aliases: []string{"c"},
-		Target:  &c.flagKubeConfig,
-		Default: "",
-		Usage:   "Set the path to kubeconfig file.",
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
 	})
 	f.StringVar(&flag.StringVar{
-		Name:    "context",
-		Target:  &c.flagKubeContext,
-		Default: "",
-		Usage:   "Set the Kubernetes context to use.",
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
 	})
 
 	c.help = c.set.Help()
@@ -200,7 +265,7 @@ func (c *Command) Run(args []string) int {
 	// Set up the kubernetes client to use for non Helm SDK calls to the Kubernetes API
 	// The Helm SDK will use settings.RESTClientGetter for its calls as well, so this will
 	// use a consistent method to target the right cluster for both Helm SDK and non Helm SDK calls.
-	if c.kubernetes == nil {
+	if c.kubernetes == nil || c.dynamic == nil {
 		restConfig, err := settings.RESTClientGetter().ToRESTConfig()
 		if err != nil {
 			c.UI.Output("Error retrieving Kubernetes authentication:\n%v", err, terminal.WithErrorStyle())
@@ -211,6 +276,12 @@ func (c *Command) Run(args []string) int {
 			c.UI.Output("Error initializing Kubernetes client:\n%v", err, terminal.WithErrorStyle())
 			return 1
 		}
+		// The dynamic client is used for the CRDs and Consul custom resources.
+		c.dynamic, err = dynamic.NewForConfig(restConfig)
+		if err != nil {
+			c.UI.Output("Error initializing Kubernetes client:\n%v", err, terminal.WithErrorStyle())
+			return 1
+		}
 	}
 
 	c.UI.Output("Checking if Consul can be upgraded", terminal.WithHeaderStyle())
@@ -230,11 +301,12 @@ func (c *Command) Run(args []string) int {
 	}
 	c.UI.Output("Loaded charts", terminal.WithSuccessStyle())
 
-	currentChartValues, err := helm.FetchChartValues(namespace, name, settings, uiLogger)
+	currentRelease, err := helm.FetchRelease(namespace, name, settings, uiLogger)
 	if err != nil {
 		c.UI.Output(err.Error(), terminal.WithErrorStyle())
 		return 1
 	}
+	currentChartValues := currentRelease.Config
 
 	// Handle preset, value files, and set values logic.
 	chartValues, err := c.mergeValuesFlagsWithPrecedence(settings)
@@ -247,6 +319,10 @@ func (c *Command) Run(args []string) int {
 	// the release name, and since that is hardcoded to "consul", setting global.name to "consul" makes it so resources
 	// aren't double prefixed with "consul-consul-...".
 	chartValues = common.MergeMaps(config.Convert(config.GlobalNameConsul), chartValues)
+	if err = helm.ValidateValues(chart, chartValues); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
 
 	// Print out the upgrade summary.
 	if err = c.printDiff(currentChartValues, chartValues); err != nil {
@@ -254,6 +330,15 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
+	// Check for known breaking changes before asking the user to proceed. They don't apply
+	// when only the CRDs are upgraded.
+	if !c.flagCRDsOnly {
+		if err = c.checkBreakingChanges(currentRelease.Chart, chart, chartValues); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+	}
+
 	// Check if the user is OK with the upgrade unless the auto approve or dry run flags are true.
 	if !c.flagAutoApprove && !c.flagDryRun {
 		confirmation, err := c.UI.Input(&terminal.Input{
@@ -287,30 +372,98 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
+	// Apply the CRDs of the target chart and migrate the stored custom resources before
+	// upgrading the rest of the release, so that the upgraded controller never sees
+	// resources in a version it no longer serves.
+	manifests, err := c.renderManifests(actionConfig, namespace, chart, chartValues)
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	if err = c.upgradeCRDs(manifests); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	if c.flagCRDsOnly {
+		if c.flagDryRun {
+			c.UI.Output("Dry run complete. No changes were made to the Kubernetes cluster.", terminal.WithInfoStyle())
+		} else {
+			c.UI.Output("Consul CRDs upgraded.", terminal.WithSuccessStyle())
+		}
+		return 0
+	}
+
 	// Setup the upgrade action.
 	upgrade := action.NewUpgrade(actionConfig)
 	upgrade.Namespace = namespace
 	upgrade.DryRun = c.flagDryRun
-	upgrade.Wait = c.flagWait
+	upgrade.Wait = c.flagWait && c.flagWaitStrategy == waitStrategyHelm
 	upgrade.Timeout = c.timeoutDuration
+	waitCtx, cancel := context.WithTimeout(c.Ctx, c.timeoutDuration)
+	defer cancel()
 
 	// Run the upgrade. Note that the dry run config is passed into the upgrade action, so upgrade.Run is called even during a dry run.
-	_, err = upgrade.Run(common.DefaultReleaseName, chart, chartValues)
+	rel, err := upgrade.Run(common.DefaultReleaseName, chart, chartValues)
 	if err != nil {
 		c.UI.Output(err.Error(), terminal.WithErrorStyle())
 		return 1
 	}
 
 	if c.flagDryRun {
+		if err = c.printDryRunManifests(actionConfig, rel); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+
 		c.UI.Output("Dry run complete. No changes were made to the Kubernetes cluster.\n"+
 			"Upgrade can proceed with this configuration.", terminal.WithInfoStyle())
 		return 0
 	}
 
+	// Unlike an install, the components are only watched once Helm applied the
+	// upgrade, since until then they report the rollout of the current release.
+	if c.flagWait && c.flagWaitStrategy == waitStrategyRollout {
+		if err = c.waitForRollout(waitCtx, namespace, manifests); err != nil {
+			c.UI.Output("Consul was upgraded but is not ready: %s", err, terminal.WithErrorStyle())
+			return 1
+		}
+	}
+
 	c.UI.Output("Consul upgraded in namespace %q.", namespace, terminal.WithSuccessStyle())
 	return 0
 }
 
+// waitForRollout waits for the components of the upgraded release to roll
+// out, printing their progress, and prints the logs and events of the pods of
+// the components that aren't ready if they don't roll out in time.
+func (c *Command) waitForRollout(ctx context.Context, namespace, manifests string) error {
+	components, err := rollout.Components(manifests, rollout.Upgrade)
+	if err != nil {
+		return err
+	}
+	watcher := &rollout.Watcher{
+		Kubernetes: c.kubernetes,
+		Namespace:  namespace,
+		AfterApply: true,
+		Progress:   rollout.PrintProgress(c.UI),
+	}
+	statuses, err := watcher.Wait(ctx, components)
+	if err == nil {
+		return nil
+	}
+
+	diagnoseCtx, cancel := context.WithTimeout(c.Ctx, diagnoseTimeout)
+	defer cancel()
+	diagnostics, diagnoseErr := watcher.Diagnose(diagnoseCtx, statuses)
+	if diagnoseErr != nil {
+		c.UI.Output("Unable to collect diagnostics: %s", diagnoseErr, terminal.WithErrorStyle())
+	} else if len(diagnostics) > 0 {
+		c.UI.Output("Components that are not ready", terminal.WithHeaderStyle())
+		rollout.PrintDiagnostics(c.UI, diagnostics)
+	}
+	return err
+}
+
 // validateFlags checks that the user's provided flags are valid.
 func (c *Command) validateFlags(args []string) error {
 	if err := c.set.Parse(args); err != nil {
@@ -325,6 +478,12 @@ func (c *Command) validateFlags(args []string) error {
 	if _, ok := config.Presets[c.flagPreset]; c.flagPreset != defaultPreset && !ok {
 		return fmt.Errorf("'%s' is not a valid preset", c.flagPreset)
 	}
+	if c.flagDryRunOutput != dryRunOutputSummary && !c.flagDryRun {
+		return fmt.Errorf("-%s can only be set with -%s", flagNameDryRunOutput, flagNameDryRun)
+	}
+	if c.flagCRDsOnly && c.flagDryRunOutput != dryRunOutputSummary {
+		return fmt.Errorf("-%s can't be set with -%s", flagNameDryRunOutput, flagNameCRDsOnly)
+	}
 	if _, err := time.ParseDuration(c.flagTimeout); err != nil {
 		return fmt.Errorf("unable to parse -%s: %s", flagNameTimeout, err)
 	}
@@ -379,6 +538,18 @@ func (c *Command) Synopsis() string {
 	return "Upgrade Consul on Kubernetes from an existing installation."
 }
 
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *Command) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *Command) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
 // createUILogger creates a logger that will write to the UI.
 func (c *Command) createUILogger() func(string, ...interface{}) {
 	return func(s string, args ...interface{}) {
@@ -396,6 +567,85 @@ func (c *Command) createUILogger() func(string, ...interface{}) {
 	}
 }
 
+// checkBreakingChanges runs the pre-upgrade checks for breaking changes between the current and the
+// target charts and prints any that are found. An error is returned if breaking changes are found,
+// unless the -force flag is set.
+func (c *Command) checkBreakingChanges(current, target *helmChart.Chart, vals map[string]interface{}) error {
+	c.UI.Output("Checking for breaking changes", terminal.WithHeaderStyle())
+	issues, err := preflight.Run(c.Ctx, &preflight.Upgrade{
+		Current:    current,
+		Target:     target,
+		Values:     vals,
+		Kubernetes: c.kubernetes,
+	})
+	if err != nil {
+		return err
+	}
+	if len(issues) == 0 {
+		c.UI.Output("No breaking changes found.", terminal.WithSuccessStyle())
+		return nil
+	}
+
+	for _, issue := range issues {
+		c.UI.Output("%s: %s", issue.Check, issue.Message, terminal.WithWarningStyle())
+	}
+	if c.flagForce {
+		c.UI.Output("Proceeding with %d breaking changes because -%s is set.", len(issues), flagNameForce, terminal.WithWarningStyle())
+		return nil
+	}
+	return fmt.Errorf("upgrade blocked by %d breaking changes: address them or use -%s to upgrade anyway", len(issues), flagNameForce)
+}
+
+// renderManifests renders the manifests of the upgraded release without changing the cluster.
+func (c *Command) renderManifests(actionConfig *action.Configuration, namespace string, chart *helmChart.Chart, vals map[string]interface{}) (string, error) {
+	upgrade := action.NewUpgrade(actionConfig)
+	upgrade.Namespace = namespace
+	upgrade.DryRun = true
+	rel, err := upgrade.Run(common.DefaultReleaseName, chart, vals)
+	if err != nil {
+		return "", fmt.Errorf("error rendering manifests: %s", err)
+	}
+	return helm.ReleaseManifests(rel), nil
+}
+
+// printDryRunManifests prints either the manifests rendered by a dry run upgrade or their diff
+// against the live objects in the cluster, depending on the -dry-run-output flag. The diff shows
+// what applying the upgraded manifests over those of the current release would change, as computed
+// by a server-side dry run. Hooks are left out of the diff since Helm creates them anew on every
+// upgrade.
+func (c *Command) printDryRunManifests(actionConfig *action.Configuration, upgraded *release.Release) error {
+	switch c.flagDryRunOutput {
+	case dryRunOutputManifests:
+		c.UI.Output("Rendered Kubernetes manifests", terminal.WithHeaderStyle())
+		c.UI.Output("%s", helm.ReleaseManifests(upgraded))
+	case dryRunOutputDiff:
+		current, err := action.NewGet(actionConfig).Run(common.DefaultReleaseName)
+		if err != nil {
+			return fmt.Errorf("error getting the current release: %s", err)
+		}
+		diff, err := helm.DiffLiveManifests(actionConfig.KubeClient, current.Manifest, upgraded.Manifest)
+		if err != nil {
+			return err
+		}
+
+		c.UI.Output("Difference between the cluster and the upgraded Kubernetes manifests", terminal.WithHeaderStyle())
+		if diff == "" {
+			c.UI.Output("No changes to the Kubernetes resources.", terminal.WithInfoStyle())
+			return nil
+		}
+		for _, line := range strings.Split(diff, "\n") {
+			if strings.HasPrefix(line, "+") {
+				c.UI.Output("%s", line, terminal.WithDiffAddedStyle())
+			} else if strings.HasPrefix(line, "-") {
+				c.UI.Output("%s", line, terminal.WithDiffRemovedStyle())
+			} else {
+				c.UI.Output("%s", line, terminal.WithDiffUnchangedStyle())
+			}
+		}
+	}
+	return nil
+}
+
 // printDiff marshals both maps to YAML and prints the diff between the two.
 func (c *Command) printDiff(old, new map[string]interface{}) error {
 	diff, err := common.Diff(old, new)
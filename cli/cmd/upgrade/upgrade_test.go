@@ -35,6 +35,14 @@ func TestValidateFlags(t *testing.T) {
 			"Should have errored on a non-existant file.",
 			[]string{"-f=\"does_not_exist.txt\""},
 		},
+		{
+			"Should error on a dry run output without a dry run.",
+			[]string{"-dry-run-output=diff"},
+		},
+		{
+			"Should error on a dry run output when only upgrading CRDs.",
+			[]string{"-crds-only", "-dry-run", "-dry-run-output=diff"},
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -0,0 +1,216 @@
+// Package validate contains the commands that validate configuration before
+// it is applied to a cluster.
+package validate
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	controlplanecommon "github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	"github.com/posener/complete"
+)
+
+const (
+	flagNamePath            = "path"
+	flagNameDefaultProtocol = "default-protocol"
+	defaultDefaultProtocol  = "tcp"
+
+	flagNameEnableNamespaces           = "enable-namespaces"
+	flagNameConsulDestinationNamespace = "consul-destination-namespace"
+	defaultConsulDestinationNamespace  = "default"
+	flagNameEnableNSMirroring          = "enable-k8s-namespace-mirroring"
+	flagNameNSMirroringPrefix          = "k8s-namespace-mirroring-prefix"
+	flagNameNSMirroringRules           = "k8s-namespace-mirroring-rules"
+	flagNameEnablePartitions           = "enable-partitions"
+	flagNamePartition                  = "partition"
+)
+
+// CRDsCommand validates Consul custom resource manifests offline, without a
+// Kubernetes cluster or Consul servers, so they can be checked before they
+// are applied, e.g. in CI pipelines.
+type CRDsCommand struct {
+	*common.BaseCommand
+
+	set *flag.Sets
+
+	flagPath                       string
+	flagDefaultProtocol            string
+	flagEnableNamespaces           bool
+	flagConsulDestinationNamespace string
+	flagEnableNSMirroring          bool
+	flagNSMirroringPrefix          string
+	flagNSMirroringRules           string
+	flagEnablePartitions           bool
+	flagPartition                  string
+
+	consulMeta controlplanecommon.ConsulMeta
+
+	once sync.Once
+	help string
+}
+
+func (c *CRDsCommand) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:       flagNamePath,
+		Aliases:    []string{"p"},
+		Target:     &c.flagPath,
+		Usage:      "Set the path to a manifest file or to a directory that is searched recursively for .yaml and .yml files.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameDefaultProtocol,
+		Target:  &c.flagDefaultProtocol,
+		Default: defaultDefaultProtocol,
+		Usage: "Set the protocol of services that are not configured with a protocol by a ServiceDefaults or " +
+			"global ProxyDefaults resource in the manifests.",
+	})
+
+	f = c.set.NewSet("Enterprise Options")
+	f.BoolVar(&flag.BoolVar{
+		Name:   flagNameEnableNamespaces,
+		Target: &c.flagEnableNamespaces,
+		Usage:  "Validate as if Consul Enterprise namespaces are enabled, in either a single Consul namespace or mirrored.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameConsulDestinationNamespace,
+		Target:  &c.flagConsulDestinationNamespace,
+		Default: defaultConsulDestinationNamespace,
+		Usage: fmt.Sprintf("Set the Consul namespace config entries are created in, regardless of their source Kubernetes namespace. "+
+			"Not used if -%s is set.", flagNameEnableNSMirroring),
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:   flagNameEnableNSMirroring,
+		Target: &c.flagEnableNSMirroring,
+		Usage:  "Validate as if Kubernetes namespaces are mirrored into Consul.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameNSMirroringPrefix,
+		Target: &c.flagNSMirroringPrefix,
+		Usage:  "Set the prefix that is added to the Kubernetes namespaces mirrored into Consul.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameNSMirroringRules,
+		Target: &c.flagNSMirroringRules,
+		Usage: "Set the JSON list of rules that rewrite the names of Kubernetes namespaces mirrored into Consul, e.g. " +
+			`'[{"match": "team-(.*)", "replace": "$1"}]'.`,
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:   flagNameEnablePartitions,
+		Target: &c.flagEnablePartitions,
+		Usage:  "Validate as if Admin Partitions are enabled.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNamePartition,
+		Target: &c.flagPartition,
+		Usage:  "Set the name of the Admin Partition config entries are created in.",
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
+	c.Init()
+}
+
+// Run validates the custom resources in the manifests at -path.
+func (c *CRDsCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("validate crds")
+
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.validateFlags(args); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	manifests, err := readManifests(c.flagPath)
+	if err != nil {
+		c.UI.Output("Error reading manifests: %v", err, terminal.WithErrorStyle())
+		return 1
+	}
+	if len(manifests) == 0 {
+		c.UI.Output("No Consul custom resources found in %s", c.flagPath, terminal.WithErrorStyle())
+		return 1
+	}
+
+	errs := validateManifests(manifests, c.consulMeta, c.flagDefaultProtocol)
+	for _, e := range errs {
+		c.UI.Output(e, terminal.WithErrorStyle())
+	}
+	if len(errs) > 0 {
+		c.UI.Output("Found %d errors in %d resources", len(errs), len(manifests), terminal.WithErrorStyle())
+		return 1
+	}
+
+	c.UI.Output("%d resources are valid", len(manifests), terminal.WithSuccessStyle())
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *CRDsCommand) validateFlags(args []string) error {
+	if err := c.set.Parse(args); err != nil {
+		return err
+	}
+	if len(c.set.Args()) > 0 {
+		return fmt.Errorf("should have no non-flag arguments")
+	}
+	if c.flagPath == "" {
+		return fmt.Errorf("-%s must be set", flagNamePath)
+	}
+	if c.flagEnablePartitions && c.flagPartition == "" {
+		return fmt.Errorf("-%s must be set if -%s is set", flagNamePartition, flagNameEnablePartitions)
+	}
+	rules, err := namespaces.ParseMirroringRules(c.flagNSMirroringRules)
+	if err != nil {
+		return fmt.Errorf("-%s is invalid: %s", flagNameNSMirroringRules, err)
+	}
+
+	c.consulMeta = controlplanecommon.ConsulMeta{
+		PartitionsEnabled:    c.flagEnablePartitions,
+		Partition:            c.flagPartition,
+		NamespacesEnabled:    c.flagEnableNamespaces,
+		DestinationNamespace: c.flagConsulDestinationNamespace,
+		Mirroring:            c.flagEnableNSMirroring,
+		Prefix:               c.flagNSMirroringPrefix,
+		MirroringRules:       rules,
+	}
+	return nil
+}
+
+// Help returns a description of the command and how it is used.
+func (c *CRDsCommand) Help() string {
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s validate crds -path <file or directory> [flags]\n\n" +
+		"Validates the Consul custom resources in a manifest file or directory without connecting\n" +
+		"to Kubernetes or Consul. Each resource is validated the same way as by the admission\n" +
+		"webhooks, and the references among the resources are checked: resource names must be\n" +
+		"unique, the subsets referenced by routers and splitters must be defined by the service's\n" +
+		"resolver, and routers, splitters and intentions with L7 permissions must only reference\n" +
+		"services with a compatible protocol. Exits with a non-zero status if any resource is invalid.\n\n" +
+		"Examples:\n" +
+		"  $ consul-k8s validate crds -path ./manifests\n" +
+		"  $ consul-k8s validate crds -path crds.yaml -enable-namespaces -enable-k8s-namespace-mirroring\n\n" + c.help
+}
+
+// Synopsis returns a one-line command summary.
+func (c *CRDsCommand) Synopsis() string {
+	return "Validate Consul custom resource manifests offline."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *CRDsCommand) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *CRDsCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
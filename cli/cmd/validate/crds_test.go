@@ -0,0 +1,269 @@
+package validate
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_FlagValidation(t *testing.T) {
+	cases := []struct {
+		flags  []string
+		expErr string
+	}{
+		{
+			flags:  []string{},
+			expErr: "-path must be set",
+		},
+		{
+			flags:  []string{"-path", "foo", "-enable-partitions"},
+			expErr: "-partition must be set if -enable-partitions is set",
+		},
+		{
+			flags:  []string{"-path", "foo", "-k8s-namespace-mirroring-rules", `[{"match": "(", "replace": "foo"}]`},
+			expErr: "-k8s-namespace-mirroring-rules is invalid: namespace mirroring rule 0: error parsing regexp",
+		},
+		{
+			flags:  []string{"-path", "does-not-exist"},
+			expErr: "Error reading manifests",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			out, exitCode := run(t, c.flags...)
+			require.Equal(t, 1, exitCode, out)
+			require.Contains(t, out, c.expErr)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	cases := map[string]struct {
+		files   map[string]string
+		expCode int
+		expErrs []string
+	}{
+		"no consul resources": {
+			files: map[string]string{
+				"deployment.yaml": deployment,
+			},
+			expCode: 1,
+			expErrs: []string{"No Consul custom resources found"},
+		},
+		"valid resources": {
+			files: map[string]string{
+				"defaults.yaml":       httpDefaults("web") + "\n---\n" + httpDefaults("web-v2"),
+				"nested/router.yml":   router,
+				"nested/resolver.yml": resolver,
+				"deployment.yaml":     deployment,
+				"README.md":           "not a manifest",
+			},
+			expCode: 0,
+		},
+		"global proxy defaults protocol": {
+			files: map[string]string{
+				"proxydefaults.yaml": proxyDefaultsHTTP,
+				"splitter.yaml":      splitter,
+			},
+			expCode: 0,
+		},
+		"unknown field": {
+			files: map[string]string{
+				"defaults.yaml": `apiVersion: consul.hashicorp.com/v1alpha1
+kind: ServiceDefaults
+metadata:
+  name: web
+spec:
+  protocol: http
+  protocl: http
+`,
+			},
+			expCode: 1,
+			expErrs: []string{`unknown field "protocl"`},
+		},
+		"webhook validation": {
+			files: map[string]string{
+				"defaults.yaml": `apiVersion: consul.hashicorp.com/v1alpha1
+kind: ServiceDefaults
+metadata:
+  name: web
+spec:
+  mode: invalid
+`,
+			},
+			expCode: 1,
+			expErrs: []string{"defaults.yaml[0]: servicedefaults.consul.hashicorp.com \"web\" is invalid"},
+		},
+		"duplicate names": {
+			files: map[string]string{
+				"a.yaml": httpDefaults("web"),
+				"b.yaml": httpDefaults("web"),
+			},
+			expCode: 1,
+			expErrs: []string{`b.yaml[0]: servicedefaults resource with name "web" is already defined in`},
+		},
+		"router requires L7 protocol": {
+			files: map[string]string{
+				"router.yaml":   router,
+				"resolver.yaml": resolver,
+			},
+			expCode: 1,
+			expErrs: []string{`servicerouter "web" requires service "web" to use protocol http, http2 or grpc but it uses "tcp"`},
+		},
+		"splitter protocol mismatch": {
+			files: map[string]string{
+				"defaults.yaml": httpDefaults("web"),
+				"splitter.yaml": splitter,
+			},
+			expCode: 1,
+			expErrs: []string{`servicesplitter "web" references service "web-v2" with protocol "tcp" which does not match protocol "http" of service "web"`},
+		},
+		"missing subset": {
+			files: map[string]string{
+				"defaults.yaml": httpDefaults("web"),
+				"router.yaml": `apiVersion: consul.hashicorp.com/v1alpha1
+kind: ServiceRouter
+metadata:
+  name: web
+spec:
+  routes:
+    - destination:
+        serviceSubset: v3
+`,
+				"resolver.yaml": resolver,
+			},
+			expCode: 1,
+			expErrs: []string{`servicerouter "web" references subset "v3" which is not defined by ServiceResolver "web"`},
+		},
+		"intentions with L7 permissions": {
+			files: map[string]string{
+				"intentions.yaml": `apiVersion: consul.hashicorp.com/v1alpha1
+kind: ServiceIntentions
+metadata:
+  name: web
+spec:
+  destination:
+    name: web
+  sources:
+    - name: frontend
+      permissions:
+        - action: allow
+          http:
+            pathPrefix: /admin
+`,
+			},
+			expCode: 1,
+			expErrs: []string{`serviceintentions "web" requires service "web" to use protocol http, http2 or grpc but it uses "tcp"`},
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			for file, contents := range c.files {
+				path := filepath.Join(dir, file)
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+				require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+			}
+
+			out, exitCode := run(t, "-path", dir)
+			require.Equal(t, c.expCode, exitCode, out)
+			for _, expErr := range c.expErrs {
+				require.Contains(t, out, expErr)
+			}
+		})
+	}
+}
+
+// run runs the command with the arguments and returns its output and exit
+// code.
+func run(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	output := color.Output
+	color.Output = buf
+	defer func() { color.Output = output }()
+
+	c := &CRDsCommand{
+		BaseCommand: &common.BaseCommand{
+			Ctx: context.Background(),
+			Log: hclog.NewNullLogger(),
+		},
+	}
+	exitCode := c.Run(args)
+	return buf.String(), exitCode
+}
+
+func httpDefaults(name string) string {
+	return `apiVersion: consul.hashicorp.com/v1alpha1
+kind: ServiceDefaults
+metadata:
+  name: ` + name + `
+spec:
+  protocol: http
+`
+}
+
+const router = `apiVersion: consul.hashicorp.com/v1alpha1
+kind: ServiceRouter
+metadata:
+  name: web
+spec:
+  routes:
+    - match:
+        http:
+          pathPrefix: /v2
+      destination:
+        service: web-v2
+    - match:
+        http:
+          pathPrefix: /canary
+      destination:
+        serviceSubset: canary
+`
+
+const resolver = `apiVersion: consul.hashicorp.com/v1alpha1
+kind: ServiceResolver
+metadata:
+  name: web
+spec:
+  subsets:
+    canary:
+      filter: Service.Meta.version == canary
+`
+
+const splitter = `apiVersion: consul.hashicorp.com/v1alpha1
+kind: ServiceSplitter
+metadata:
+  name: web
+spec:
+  splits:
+    - weight: 50
+    - weight: 50
+      service: web-v2
+`
+
+const proxyDefaultsHTTP = `apiVersion: consul.hashicorp.com/v1alpha1
+kind: ProxyDefaults
+metadata:
+  name: global
+spec:
+  config:
+    protocol: http
+`
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`
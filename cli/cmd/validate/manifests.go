@@ -0,0 +1,253 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"sigs.k8s.io/yaml"
+)
+
+// newResource returns an empty custom resource for each Kubernetes kind that
+// can be validated.
+var newResource = map[string]func() common.ConfigEntryResource{
+	"ExportedServices":   func() common.ConfigEntryResource { return &v1alpha1.ExportedServices{} },
+	"IngressGateway":     func() common.ConfigEntryResource { return &v1alpha1.IngressGateway{} },
+	"JWTProvider":        func() common.ConfigEntryResource { return &v1alpha1.JWTProvider{} },
+	"Mesh":               func() common.ConfigEntryResource { return &v1alpha1.Mesh{} },
+	"ProxyDefaults":      func() common.ConfigEntryResource { return &v1alpha1.ProxyDefaults{} },
+	"ServiceDefaults":    func() common.ConfigEntryResource { return &v1alpha1.ServiceDefaults{} },
+	"ServiceIntentions":  func() common.ConfigEntryResource { return &v1alpha1.ServiceIntentions{} },
+	"ServiceResolver":    func() common.ConfigEntryResource { return &v1alpha1.ServiceResolver{} },
+	"ServiceRouter":      func() common.ConfigEntryResource { return &v1alpha1.ServiceRouter{} },
+	"ServiceSplitter":    func() common.ConfigEntryResource { return &v1alpha1.ServiceSplitter{} },
+	"TerminatingGateway": func() common.ConfigEntryResource { return &v1alpha1.TerminatingGateway{} },
+}
+
+// manifest is a custom resource read from a file.
+type manifest struct {
+	// source is the file and document index the resource was read from.
+	source string
+	entry  common.ConfigEntryResource
+}
+
+// typeMeta is used to find the kind of a YAML document before decoding it.
+type typeMeta struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+}
+
+// readManifests reads every Consul custom resource from the YAML files in path,
+// which can be a file or a directory that is walked recursively. Documents that
+// are not Consul custom resources are skipped. Unknown fields are an error.
+func readManifests(path string) ([]manifest, error) {
+	var files []string
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && (strings.HasSuffix(p, ".yaml") || strings.HasSuffix(p, ".yml")) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var manifests []manifest
+	for _, file := range files {
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for i, doc := range bytes.Split(contents, []byte("\n---")) {
+			source := fmt.Sprintf("%s[%d]", file, i)
+			var meta typeMeta
+			if err := yaml.Unmarshal(doc, &meta); err != nil {
+				return nil, fmt.Errorf("%s: %s", source, err)
+			}
+			if !strings.HasPrefix(meta.APIVersion, v1alpha1.ConsulHashicorpGroup+"/") {
+				continue
+			}
+			newEntry, ok := newResource[meta.Kind]
+			if !ok {
+				return nil, fmt.Errorf("%s: unsupported kind %q", source, meta.Kind)
+			}
+			entry := newEntry()
+			if err := yaml.UnmarshalStrict(doc, entry); err != nil {
+				return nil, fmt.Errorf("%s: %s", source, err)
+			}
+			manifests = append(manifests, manifest{source: source, entry: entry})
+		}
+	}
+	return manifests, nil
+}
+
+// validateManifests runs the same validation as the webhooks on each resource and
+// then validates the references among the resources. It returns one error
+// message per problem found.
+func validateManifests(manifests []manifest, consulMeta common.ConsulMeta, defaultProtocol string) []string {
+	var errs []string
+
+	// Like the webhooks, resources of the same kind must have unique names
+	// unless they are mirrored into separate Consul namespaces.
+	singleConsulDestNS := !(consulMeta.NamespacesEnabled && consulMeta.Mirroring)
+	seen := make(map[string]string)
+	for _, m := range manifests {
+		m.entry.DefaultNamespaceFields(consulMeta)
+		if err := m.entry.Validate(consulMeta); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", m.source, err))
+		}
+
+		key := m.entry.KubeKind() + "/" + m.entry.KubernetesName()
+		if !singleConsulDestNS {
+			key = m.entry.GetNamespace() + "/" + key
+		}
+		if previous, ok := seen[key]; ok {
+			errs = append(errs, fmt.Sprintf("%s: %s resource with name %q is already defined in %s – all %s resources must have unique names across namespaces",
+				m.source, m.entry.KubeKind(), m.entry.KubernetesName(), previous, m.entry.KubeKind()))
+			continue
+		}
+		seen[key] = m.source
+	}
+
+	errs = append(errs, validateSubsets(manifests)...)
+	return append(errs, validateProtocols(manifests, defaultProtocol)...)
+}
+
+// validateSubsets checks that the service subsets referenced by routers and
+// splitters are defined by the ServiceResolver of the service if one is among
+// the resources.
+func validateSubsets(manifests []manifest) []string {
+	subsets := make(map[string]v1alpha1.ServiceResolverSubsetMap)
+	for _, m := range manifests {
+		if resolver, ok := m.entry.(*v1alpha1.ServiceResolver); ok {
+			subsets[resolver.KubernetesName()] = resolver.Spec.Subsets
+		}
+	}
+
+	var errs []string
+	checkSubset := func(m manifest, service, subset string) {
+		if subset == "" {
+			return
+		}
+		defined, ok := subsets[service]
+		if !ok {
+			return
+		}
+		if _, ok := defined[subset]; !ok {
+			errs = append(errs, fmt.Sprintf("%s: %s %q references subset %q which is not defined by ServiceResolver %q",
+				m.source, m.entry.KubeKind(), m.entry.KubernetesName(), subset, service))
+		}
+	}
+
+	for _, m := range manifests {
+		switch entry := m.entry.(type) {
+		case *v1alpha1.ServiceRouter:
+			for _, route := range entry.Spec.Routes {
+				if route.Destination == nil {
+					continue
+				}
+				service := route.Destination.Service
+				if service == "" {
+					service = entry.KubernetesName()
+				}
+				checkSubset(m, service, route.Destination.ServiceSubset)
+			}
+		case *v1alpha1.ServiceSplitter:
+			for _, split := range entry.Spec.Splits {
+				service := split.Service
+				if service == "" {
+					service = entry.KubernetesName()
+				}
+				checkSubset(m, service, split.ServiceSubset)
+			}
+		}
+	}
+	return errs
+}
+
+// validateProtocols checks that the resources which require L7 protocols only
+// reference services that are configured with a compatible protocol, using the
+// ServiceDefaults and global ProxyDefaults among the resources.
+func validateProtocols(manifests []manifest, defaultProtocol string) []string {
+	protocols := make(map[string]string)
+	for _, m := range manifests {
+		switch entry := m.entry.(type) {
+		case *v1alpha1.ProxyDefaults:
+			var config map[string]interface{}
+			if len(entry.Spec.Config) > 0 && json.Unmarshal(entry.Spec.Config, &config) == nil {
+				if protocol, ok := config["protocol"].(string); ok {
+					defaultProtocol = protocol
+				}
+			}
+		case *v1alpha1.ServiceDefaults:
+			if entry.Spec.Protocol != "" {
+				protocols[entry.KubernetesName()] = entry.Spec.Protocol
+			}
+		}
+	}
+	protocolOf := func(service string) string {
+		if protocol, ok := protocols[service]; ok {
+			return protocol
+		}
+		return defaultProtocol
+	}
+
+	var errs []string
+	requireL7 := func(m manifest, service string) {
+		if protocol := protocolOf(service); !isL7(protocol) {
+			errs = append(errs, fmt.Sprintf("%s: %s %q requires service %q to use protocol http, http2 or grpc but it uses %q",
+				m.source, m.entry.KubeKind(), m.entry.KubernetesName(), service, protocol))
+		}
+	}
+	requireSameProtocol := func(m manifest, service, target string) {
+		if target == "" || target == service {
+			return
+		}
+		if protocolOf(service) != protocolOf(target) {
+			errs = append(errs, fmt.Sprintf("%s: %s %q references service %q with protocol %q which does not match protocol %q of service %q",
+				m.source, m.entry.KubeKind(), m.entry.KubernetesName(), target, protocolOf(target), protocolOf(service), service))
+		}
+	}
+
+	for _, m := range manifests {
+		switch entry := m.entry.(type) {
+		case *v1alpha1.ServiceRouter:
+			service := entry.KubernetesName()
+			requireL7(m, service)
+			for _, route := range entry.Spec.Routes {
+				if route.Destination != nil {
+					requireSameProtocol(m, service, route.Destination.Service)
+				}
+			}
+		case *v1alpha1.ServiceSplitter:
+			service := entry.KubernetesName()
+			requireL7(m, service)
+			for _, split := range entry.Spec.Splits {
+				requireSameProtocol(m, service, split.Service)
+			}
+		case *v1alpha1.ServiceIntentions:
+			for _, source := range entry.Spec.Sources {
+				if len(source.Permissions) > 0 && entry.Spec.Destination.Name != common.WildcardNamespace {
+					requireL7(m, entry.Spec.Destination.Name)
+					break
+				}
+			}
+		}
+	}
+	return errs
+}
+
+func isL7(protocol string) bool {
+	return protocol == "http" || protocol == "http2" || protocol == "grpc"
+}
@@ -0,0 +1,118 @@
+package version
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// compatibilityJSON lists the Consul versions supported by each minor version
+// of the Helm chart and the Envoy versions supported by each minor version of
+// Consul.
+//
+//go:embed compatibility.json
+var compatibilityJSON []byte
+
+// compatibility is the compatibility matrix. Versions are keyed by their
+// major and minor version, e.g. "1.11".
+type compatibility struct {
+	Charts map[string]struct {
+		Consul []string `json:"consul"`
+	} `json:"charts"`
+	Consul map[string]struct {
+		Envoy []string `json:"envoy"`
+	} `json:"consul"`
+}
+
+// loadCompatibility parses the embedded compatibility matrix.
+func loadCompatibility() (*compatibility, error) {
+	var c compatibility
+	if err := json.Unmarshal(compatibilityJSON, &c); err != nil {
+		return nil, fmt.Errorf("invalid compatibility matrix: %s", err)
+	}
+	return &c, nil
+}
+
+var minorVersionRe = regexp.MustCompile(`^v?(\d+)\.(\d+)(\.|-|$)`)
+
+// minorVersion returns the major and minor version of a version or image
+// tag, e.g. "1.11" for "1.11.4-ent" or "v1.11.4". It returns "" if the
+// version can't be parsed, e.g. for "latest" or an image digest.
+func minorVersion(version string) string {
+	m := minorVersionRe.FindStringSubmatch(version)
+	if m == nil {
+		return ""
+	}
+	return m[1] + "." + m[2]
+}
+
+// check evaluates the versions of the report against the compatibility
+// matrix. It returns the unsupported combinations, and warnings for the
+// versions that could not be checked.
+func (c *compatibility) check(r *report) (issues, warnings []string) {
+	if cli, chart := minorVersion(r.CLI), minorVersion(r.Chart); cli != "" && chart != "" && cli != chart {
+		warnings = append(warnings, fmt.Sprintf("The CLI version %s does not match the chart version %s.", r.CLI, r.Chart))
+	}
+
+	chart := minorVersion(r.Chart)
+	supported, ok := c.Charts[chart]
+	if !ok && r.Chart != "" {
+		warnings = append(warnings, fmt.Sprintf("The chart version %s is not in the compatibility matrix of this CLI.", r.Chart))
+	}
+
+	for _, server := range r.ConsulServers {
+		consul := minorVersion(server.Version)
+		switch {
+		case consul == "":
+			warnings = append(warnings, fmt.Sprintf("The Consul server version %q can't be checked.", server.Version))
+		case ok && !contains(supported.Consul, consul):
+			issues = append(issues, fmt.Sprintf("Consul %s is not supported by chart %s, which supports Consul %s.",
+				server.Version, r.Chart, strings.Join(supported.Consul, ", ")))
+		}
+	}
+
+	// The connect injector and the dataplane are released with the chart and
+	// must have its version.
+	for _, injector := range r.ConnectInjector {
+		if v := minorVersion(injector.Version); v != "" && chart != "" && v != chart {
+			issues = append(issues, fmt.Sprintf("The connect injector version %s does not match chart %s.", injector.Version, r.Chart))
+		}
+	}
+	for _, dataplane := range r.Dataplanes {
+		if v := minorVersion(dataplane.Version); v != "" && chart != "" && v != chart {
+			issues = append(issues, fmt.Sprintf("%d pods were injected with dataplane %s, which does not match chart %s. Restart them to update their dataplane.",
+				dataplane.Pods, dataplane.Version, r.Chart))
+		}
+	}
+
+	for _, envoy := range r.Envoy {
+		v := minorVersion(envoy.Version)
+		if v == "" {
+			warnings = append(warnings, fmt.Sprintf("The Envoy version %q can't be checked.", envoy.Version))
+			continue
+		}
+		for _, server := range r.ConsulServers {
+			consul := minorVersion(server.Version)
+			supportedEnvoy, known := c.Consul[consul]
+			if !known {
+				continue
+			}
+			if !contains(supportedEnvoy.Envoy, v) {
+				issues = append(issues, fmt.Sprintf("%d pods run Envoy %s, which is not supported by Consul %s. Consul %s supports Envoy %s.",
+					envoy.Pods, envoy.Version, server.Version, consul, strings.Join(supportedEnvoy.Envoy, ", ")))
+			}
+		}
+	}
+	return issues, warnings
+}
+
+func contains(list []string, s string) bool {
+	for _, elem := range list {
+		if elem == s {
+			return true
+		}
+	}
+	return false
+}
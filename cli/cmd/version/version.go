@@ -1,10 +1,35 @@
 package version
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
 	"sync"
 
 	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
 	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/consul"
+	"github.com/posener/complete"
+	"helm.sh/helm/v3/pkg/action"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	flagNameOutput = "output"
+	outputText     = "text"
+	outputJSON     = "json"
+	defaultOutput  = outputText
+
+	flagNameLocal = "local"
+
+	// connectInjectorSelector selects the pods of the connect injector.
+	connectInjectorSelector = "app=consul,component=connect-injector"
 )
 
 type Command struct {
@@ -13,26 +38,311 @@ type Command struct {
 	// Version is the Consul on Kubernetes CLI version.
 	Version string
 
+	kubernetes kubernetes.Interface
+
+	// findChart returns the version and namespace of the installed Consul
+	// chart. It uses Helm if it is not set, which lets tests replace it.
+	findChart func() (version, namespace string, err error)
+
+	set *flag.Sets
+
+	flagOutput string
+	flagLocal  bool
+
+	flagKubeConfig  string
+	flagKubeContext string
+
 	once sync.Once
+	help string
+}
+
+// report is the versions of the CLI and of the components installed in the
+// cluster.
+type report struct {
+	CLI string `json:"cli"`
+
+	// The following fields are not set if the cluster could not be read.
+	Chart           string         `json:"chart,omitempty"`
+	Namespace       string         `json:"namespace,omitempty"`
+	ConsulServers   []versionCount `json:"consulServers,omitempty"`
+	ConnectInjector []versionCount `json:"connectInjector,omitempty"`
+	Dataplanes      []versionCount `json:"dataplanes,omitempty"`
+	Envoy           []versionCount `json:"envoy,omitempty"`
+
+	// Issues are the unsupported combinations of versions and warnings are
+	// the versions that could not be checked.
+	Issues   []string `json:"issues,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Error is why the versions in the cluster could not be read.
+	Error string `json:"error,omitempty"`
+}
+
+// versionCount is a version of a component and the number of pods running
+// it.
+type versionCount struct {
+	Version string `json:"version"`
+	Pods    int    `json:"pods"`
 }
 
 func (c *Command) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+	f.EnumSingleVar(&flag.EnumSingleVar{
+		Name:    flagNameOutput,
+		Aliases: []string{"o"},
+		Target:  &c.flagOutput,
+		Default: defaultOutput,
+		Values:  []string{outputText, outputJSON},
+		Usage:   "Set the format the versions are printed in.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameLocal,
+		Target:  &c.flagLocal,
+		Default: false,
+		Usage:   "Only print the version of the CLI without reading the versions installed in the cluster.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:       "kubeconfig",
+		Aliases:    []string{"c"},
+		Target:     &c.flagKubeConfig,
+		Default:    "",
+		Usage:      "Set the path to kubeconfig file.",
+		Completion: complete.PredictFiles("*"),
+	})
+	f.StringVar(&flag.StringVar{
+		Name:       "context",
+		Target:     &c.flagKubeContext,
+		Default:    "",
+		Usage:      "Set the Kubernetes context to use.",
+		Completion: common.PredictKubeContexts,
+	})
+
+	c.help = c.set.Help()
+
+	// c.Init() calls the embedded BaseCommand's initialization function.
 	c.Init()
 }
 
-// Run prints the version of the Consul on Kubernetes CLI.
-func (c *Command) Run(_ []string) int {
+// Run prints the version of the Consul on Kubernetes CLI and of the components
+// installed in the cluster, and checks them against the compatibility matrix.
+func (c *Command) Run(args []string) int {
 	c.once.Do(c.init)
+
+	if err := c.set.Parse(args); err != nil {
+		c.UI.Output(err.Error())
+		return 1
+	}
+	if len(c.set.Args()) > 0 {
+		c.UI.Output("should have no non-flag arguments")
+		return 1
+	}
+
+	r := &report{CLI: c.Version}
+	if !c.flagLocal {
+		if err := c.readCluster(r); err != nil {
+			r.Error = err.Error()
+		} else {
+			matrix, err := loadCompatibility()
+			if err != nil {
+				c.UI.Output(err.Error(), terminal.WithErrorStyle())
+				return 1
+			}
+			r.Issues, r.Warnings = matrix.check(r)
+		}
+	}
+
+	if c.flagOutput == outputJSON {
+		out, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			c.UI.Output("Error formatting versions: %v", err, terminal.WithErrorStyle())
+			return 1
+		}
+		c.UI.Output("%s", out)
+		return 0
+	}
+
 	c.UI.Output("consul-k8s %s", c.Version, terminal.WithInfoStyle())
+	if c.flagLocal {
+		return 0
+	}
+	if r.Error != "" {
+		c.UI.Output("Unable to read the versions installed in the cluster: %s", r.Error, terminal.WithWarningStyle())
+		return 0
+	}
+	c.printReport(r)
 	return 0
 }
 
+// printReport prints the versions in the cluster as a table, followed by the
+// issues and warnings of the compatibility check.
+func (c *Command) printReport(r *report) {
+	c.UI.Output("Installed Versions", terminal.WithHeaderStyle())
+	tbl := terminal.NewTable("Component", "Version", "Pods")
+	tbl.Rich([]string{"chart", r.Chart, ""}, nil)
+	for _, component := range []struct {
+		name     string
+		versions []versionCount
+	}{
+		{"consul server", r.ConsulServers},
+		{"connect injector", r.ConnectInjector},
+		{"dataplane", r.Dataplanes},
+		{"envoy", r.Envoy},
+	} {
+		for _, v := range component.versions {
+			tbl.Rich([]string{component.name, v.Version, fmt.Sprint(v.Pods)}, nil)
+		}
+	}
+	c.UI.Table(tbl)
+
+	for _, warning := range r.Warnings {
+		c.UI.Output(warning, terminal.WithWarningStyle())
+	}
+	for _, issue := range r.Issues {
+		c.UI.Output(issue, terminal.WithErrorStyle())
+	}
+	if len(r.Issues) == 0 {
+		c.UI.Output("The installed versions are compatible.", terminal.WithSuccessStyle())
+	}
+}
+
+// readCluster reads the version of the installed chart and the versions of
+// the images of the Consul servers, the connect injector and the injected
+// proxies.
+func (c *Command) readCluster(r *report) error {
+	// helmCLI.New() will create a settings object which is used to find the Kubernetes cluster.
+	settings := helmCLI.New()
+	if c.flagKubeConfig != "" {
+		settings.KubeConfig = c.flagKubeConfig
+	}
+	if c.flagKubeContext != "" {
+		settings.KubeContext = c.flagKubeContext
+	}
+
+	if c.kubernetes == nil {
+		restConfig, err := settings.RESTClientGetter().ToRESTConfig()
+		if err != nil {
+			return fmt.Errorf("error retrieving Kubernetes authentication: %v", err)
+		}
+		c.kubernetes, err = kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			return fmt.Errorf("error initializing Kubernetes client: %v", err)
+		}
+	}
+	if c.findChart == nil {
+		c.findChart = func() (string, string, error) {
+			return findChart(settings, func(msg string, args ...interface{}) {
+				c.Log.Debug(fmt.Sprintf(msg, args...))
+			})
+		}
+	}
+
+	var err error
+	r.Chart, r.Namespace, err = c.findChart()
+	if err != nil {
+		return err
+	}
+
+	servers, err := c.kubernetes.CoreV1().Pods(r.Namespace).List(c.Ctx, metav1.ListOptions{LabelSelector: consul.ServerLabelSelector})
+	if err != nil {
+		return err
+	}
+	r.ConsulServers = countImages(servers.Items, func(pod corev1.Pod) string { return containerTag(pod.Spec.Containers, "consul") })
+
+	injectors, err := c.kubernetes.CoreV1().Pods(r.Namespace).List(c.Ctx, metav1.ListOptions{LabelSelector: connectInjectorSelector})
+	if err != nil {
+		return err
+	}
+	r.ConnectInjector = countImages(injectors.Items, func(pod corev1.Pod) string { return containerTag(pod.Spec.Containers, "sidecar-injector") })
+
+	pods, err := c.kubernetes.CoreV1().Pods("").List(c.Ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	var injected []corev1.Pod
+	for _, pod := range pods.Items {
+		if pod.Annotations[common.InjectStatusAnnotation] == "injected" {
+			injected = append(injected, pod)
+		}
+	}
+	r.Dataplanes = countImages(injected, func(pod corev1.Pod) string { return containerTag(pod.Spec.InitContainers, common.InitContainerName) })
+	r.Envoy = countImages(injected, func(pod corev1.Pod) string { return containerTag(pod.Spec.Containers, common.EnvoyContainerName) })
+	return nil
+}
+
+// findChart returns the version and namespace of the installed Consul chart.
+func findChart(settings *helmCLI.EnvSettings, uiLogger action.DebugLog) (string, string, error) {
+	listConfig := new(action.Configuration)
+	if err := listConfig.Init(settings.RESTClientGetter(), "", os.Getenv("HELM_DRIVER"), uiLogger); err != nil {
+		return "", "", fmt.Errorf("couldn't initialize helm config: %s", err)
+	}
+
+	lister := action.NewList(listConfig)
+	lister.AllNamespaces = true
+	res, err := lister.Run()
+	if err != nil {
+		return "", "", fmt.Errorf("couldn't check for installations: %s", err)
+	}
+	for _, rel := range res {
+		if rel.Chart.Metadata.Name == "consul" {
+			return rel.Chart.Metadata.Version, rel.Namespace, nil
+		}
+	}
+	return "", "", errors.New("couldn't find consul installation")
+}
+
+// countImages counts the pods by the image tag returned by tag, skipping the
+// pods it returns "" for. The versions are sorted.
+func countImages(pods []corev1.Pod, tag func(pod corev1.Pod) string) []versionCount {
+	counts := make(map[string]int)
+	for _, pod := range pods {
+		if t := tag(pod); t != "" {
+			counts[t]++
+		}
+	}
+	var versions []versionCount
+	for version, pods := range counts {
+		versions = append(versions, versionCount{Version: version, Pods: pods})
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	return versions
+}
+
+// containerTag returns the image tag of the named container, or "" if there
+// is no such container.
+func containerTag(containers []corev1.Container, name string) string {
+	for _, container := range containers {
+		if container.Name == name {
+			return common.ImageTag(container.Image)
+		}
+	}
+	return ""
+}
+
 // Help returns a description of the command and how it is used.
 func (c *Command) Help() string {
-	return "Usage: consul-k8s version\n\n" + c.Synopsis()
+	c.once.Do(c.init)
+	return c.Synopsis() + "\n\nUsage: consul-k8s version [flags]\n\n" +
+		"Unless -local is set, the versions of the installed chart, the Consul servers, the connect\n" +
+		"injector and the dataplanes and Envoy proxies of the injected pods are read from the cluster\n" +
+		"and checked against the compatibility matrix built into the CLI.\n\n" + c.help
 }
 
 // Synopsis returns a one-line command summary.
 func (c *Command) Synopsis() string {
-	return "Print the version of the Consul on Kubernetes CLI."
+	return "Print the version of the Consul on Kubernetes CLI and the installed components."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command.
+func (c *Command) AutocompleteFlags() complete.Flags {
+	c.once.Do(c.init)
+	return c.set.Completions()
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+func (c *Command) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
 }
@@ -0,0 +1,155 @@
+package version
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMinorVersion(t *testing.T) {
+	for in, exp := range map[string]string{
+		"1.11.4":          "1.11",
+		"v1.20.2":         "1.20",
+		"1.11.4-ent":      "1.11",
+		"0.42.0-dev":      "0.42",
+		"0.42":            "0.42",
+		"latest":          "",
+		"sha256:0123abcd": "",
+		"":                "",
+	} {
+		require.Equal(t, exp, minorVersion(in), in)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	matrix, err := loadCompatibility()
+	require.NoError(t, err)
+
+	cases := map[string]struct {
+		report      report
+		expIssues   int
+		expWarnings int
+	}{
+		"compatible": {
+			report: report{
+				CLI:             "0.42.0",
+				Chart:           "0.42.0",
+				ConsulServers:   []versionCount{{Version: "1.11.4", Pods: 3}},
+				ConnectInjector: []versionCount{{Version: "0.42.0", Pods: 1}},
+				Dataplanes:      []versionCount{{Version: "0.42.0", Pods: 5}},
+				Envoy:           []versionCount{{Version: "v1.20.2", Pods: 5}},
+			},
+		},
+		"unsupported consul and envoy": {
+			report: report{
+				CLI:           "0.42.0",
+				Chart:         "0.42.0",
+				ConsulServers: []versionCount{{Version: "1.10.0", Pods: 3}},
+				Envoy:         []versionCount{{Version: "v1.20.2", Pods: 5}},
+			},
+			// Envoy 1.20 is not supported by Consul 1.10.
+			expIssues: 2,
+		},
+		"outdated dataplanes": {
+			report: report{
+				CLI:        "0.42.0",
+				Chart:      "0.42.0",
+				Dataplanes: []versionCount{{Version: "0.41.1", Pods: 2}, {Version: "0.42.0", Pods: 3}},
+			},
+			expIssues: 1,
+		},
+		"unknown versions": {
+			report: report{
+				CLI:           "0.41.0",
+				Chart:         "0.99.0",
+				ConsulServers: []versionCount{{Version: "latest", Pods: 1}},
+			},
+			expWarnings: 3,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			issues, warnings := matrix.check(&c.report)
+			require.Len(t, issues, c.expIssues, issues)
+			require.Len(t, warnings, c.expWarnings, warnings)
+		})
+	}
+}
+
+func TestReadCluster(t *testing.T) {
+	c := getInitializedCommand(t)
+	c.kubernetes = fake.NewSimpleClientset(
+		pod("consul", "consul-server-0", map[string]string{"app": "consul", "chart": "consul-helm", "component": "server"}, nil,
+			corev1.Container{Name: "consul", Image: "hashicorp/consul:1.11.4"}),
+		pod("consul", "consul-connect-injector-0", map[string]string{"app": "consul", "component": "connect-injector"}, nil,
+			corev1.Container{Name: "sidecar-injector", Image: "hashicorp/consul-k8s-control-plane:0.42.0"}),
+		pod("default", "web", nil, map[string]string{common.InjectStatusAnnotation: "injected"},
+			corev1.Container{Name: common.InitContainerName, Image: "hashicorp/consul-k8s-control-plane:0.41.0"},
+			corev1.Container{Name: common.EnvoyContainerName, Image: "envoyproxy/envoy-alpine:v1.20.2"}),
+		pod("default", "api", nil, map[string]string{common.InjectStatusAnnotation: "injected"},
+			corev1.Container{Name: common.InitContainerName, Image: "hashicorp/consul-k8s-control-plane:0.42.0"},
+			corev1.Container{Name: common.EnvoyContainerName, Image: "envoyproxy/envoy-alpine:v1.20.2"}),
+		pod("default", "batch", nil, nil, corev1.Container{Name: "batch", Image: "busybox"}),
+	)
+	c.findChart = func() (string, string, error) {
+		return "0.42.0", "consul", nil
+	}
+
+	r := &report{CLI: "0.42.0"}
+	require.NoError(t, c.readCluster(r))
+	require.Equal(t, &report{
+		CLI:             "0.42.0",
+		Chart:           "0.42.0",
+		Namespace:       "consul",
+		ConsulServers:   []versionCount{{Version: "1.11.4", Pods: 1}},
+		ConnectInjector: []versionCount{{Version: "0.42.0", Pods: 1}},
+		Dataplanes:      []versionCount{{Version: "0.41.0", Pods: 1}, {Version: "0.42.0", Pods: 1}},
+		Envoy:           []versionCount{{Version: "v1.20.2", Pods: 2}},
+	}, r)
+
+	require.Equal(t, 0, c.Run([]string{"-o", "json"}))
+}
+
+// pod returns a pod whose containers named common.InitContainerName are init
+// containers.
+func pod(namespace, name string, labels, annotations map[string]string, containers ...corev1.Container) *corev1.Pod {
+	p := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels, Annotations: annotations}}
+	for _, container := range containers {
+		if container.Name == common.InitContainerName {
+			p.Spec.InitContainers = append(p.Spec.InitContainers, container)
+		} else {
+			p.Spec.Containers = append(p.Spec.Containers, container)
+		}
+	}
+	return p
+}
+
+// getInitializedCommand sets up a command struct for tests.
+func getInitializedCommand(t *testing.T) *Command {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+	}
+
+	c := &Command{
+		BaseCommand: baseCommand,
+		Version:     "0.42.0",
+	}
+	c.init()
+	return c
+}
@@ -2,13 +2,36 @@ package main
 
 import (
 	"context"
+	"os"
 
+	cmdconfig "github.com/hashicorp/consul-k8s/cli/cmd/config"
+	"github.com/hashicorp/consul-k8s/cli/cmd/crd"
+	"github.com/hashicorp/consul-k8s/cli/cmd/expose"
+	"github.com/hashicorp/consul-k8s/cli/cmd/get"
+	"github.com/hashicorp/consul-k8s/cli/cmd/gossip"
 	"github.com/hashicorp/consul-k8s/cli/cmd/install"
+	"github.com/hashicorp/consul-k8s/cli/cmd/intention"
+	"github.com/hashicorp/consul-k8s/cli/cmd/maintenance"
+	"github.com/hashicorp/consul-k8s/cli/cmd/operator"
+	"github.com/hashicorp/consul-k8s/cli/cmd/peering"
+	cmdplugin "github.com/hashicorp/consul-k8s/cli/cmd/plugin"
+	"github.com/hashicorp/consul-k8s/cli/cmd/proxy/accesslogs"
+	"github.com/hashicorp/consul-k8s/cli/cmd/proxy/analyze"
+	"github.com/hashicorp/consul-k8s/cli/cmd/proxy/certs"
+	"github.com/hashicorp/consul-k8s/cli/cmd/proxy/list"
+	"github.com/hashicorp/consul-k8s/cli/cmd/proxy/loglevel"
+	"github.com/hashicorp/consul-k8s/cli/cmd/proxy/stats"
+	"github.com/hashicorp/consul-k8s/cli/cmd/server"
+	"github.com/hashicorp/consul-k8s/cli/cmd/setloglevel"
+	"github.com/hashicorp/consul-k8s/cli/cmd/snapshot"
 	"github.com/hashicorp/consul-k8s/cli/cmd/status"
+	"github.com/hashicorp/consul-k8s/cli/cmd/ui"
 	"github.com/hashicorp/consul-k8s/cli/cmd/uninstall"
 	"github.com/hashicorp/consul-k8s/cli/cmd/upgrade"
+	"github.com/hashicorp/consul-k8s/cli/cmd/validate"
 	cmdversion "github.com/hashicorp/consul-k8s/cli/cmd/version"
 	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/plugin"
 	"github.com/hashicorp/consul-k8s/cli/version"
 	"github.com/hashicorp/go-hclog"
 	"github.com/mitchellh/cli"
@@ -21,17 +44,147 @@ func initializeCommands(ctx context.Context, log hclog.Logger) (*common.BaseComm
 		Log: log,
 	}
 
+	// The plugins are discovered once the built-in commands are known.
+	var plugins, shadowed []plugin.Plugin
+
 	commands := map[string]cli.CommandFactory{
+		"config effective": func() (cli.Command, error) {
+			return &cmdconfig.EffectiveCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"config read": func() (cli.Command, error) {
+			return &cmdconfig.ReadCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"config set": func() (cli.Command, error) {
+			return &cmdconfig.SetCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"config write": func() (cli.Command, error) {
+			return &cmdconfig.WriteCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"crd force-unlock": func() (cli.Command, error) {
+			return &crd.ForceUnlockCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"expose": func() (cli.Command, error) {
+			return &expose.Command{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"get services": func() (cli.Command, error) {
+			return &get.ServicesCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"gossip rotate": func() (cli.Command, error) {
+			return &gossip.RotateCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
 		"install": func() (cli.Command, error) {
 			return &install.Command{
 				BaseCommand: baseCommand,
 			}, nil
 		},
+		"intention check": func() (cli.Command, error) {
+			return &intention.CheckCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"intention graph": func() (cli.Command, error) {
+			return &intention.GraphCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"operator": func() (cli.Command, error) {
+			return &operator.Command{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"peering verify": func() (cli.Command, error) {
+			return &peering.VerifyCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"ui": func() (cli.Command, error) {
+			return &ui.Command{
+				BaseCommand: baseCommand,
+			}, nil
+		},
 		"uninstall": func() (cli.Command, error) {
 			return &uninstall.Command{
 				BaseCommand: baseCommand,
 			}, nil
 		},
+		"maintenance": func() (cli.Command, error) {
+			return &maintenance.Command{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"plugin list": func() (cli.Command, error) {
+			return &cmdplugin.ListCommand{
+				BaseCommand: baseCommand,
+				Plugins:     plugins,
+				Shadowed:    shadowed,
+			}, nil
+		},
+		"proxy accesslogs": func() (cli.Command, error) {
+			return &accesslogs.Command{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"proxy analyze": func() (cli.Command, error) {
+			return &analyze.Command{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"proxy certs": func() (cli.Command, error) {
+			return &certs.Command{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"proxy list": func() (cli.Command, error) {
+			return &list.Command{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"proxy log": func() (cli.Command, error) {
+			return &loglevel.Command{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"proxy stats": func() (cli.Command, error) {
+			return &stats.Command{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"server restart": func() (cli.Command, error) {
+			return &server.RestartCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"set-log-level": func() (cli.Command, error) {
+			return &setloglevel.Command{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"snapshot save": func() (cli.Command, error) {
+			return &snapshot.SaveCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"snapshot restore": func() (cli.Command, error) {
+			return &snapshot.RestoreCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
 		"status": func() (cli.Command, error) {
 			return &status.Command{
 				BaseCommand: baseCommand,
@@ -42,6 +195,11 @@ func initializeCommands(ctx context.Context, log hclog.Logger) (*common.BaseComm
 				BaseCommand: baseCommand,
 			}, nil
 		},
+		"validate crds": func() (cli.Command, error) {
+			return &validate.CRDsCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
 		"version": func() (cli.Command, error) {
 			return &cmdversion.Command{
 				BaseCommand: baseCommand,
@@ -50,5 +208,9 @@ func initializeCommands(ctx context.Context, log hclog.Logger) (*common.BaseComm
 		},
 	}
 
+	// Executables named consul-k8s-<name> on the PATH are run as the
+	// subcommand <name>, unless they would replace a built-in command.
+	plugins, shadowed = plugin.Register(commands, plugin.Discover(os.Getenv("PATH")))
+
 	return baseCommand, commands
 }
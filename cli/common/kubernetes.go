@@ -0,0 +1,56 @@
+package common
+
+import (
+	"fmt"
+
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// InitKubernetes sets restConfig and client to the REST config of the
+// Kubernetes cluster selected by the kubeconfig and context, which are the
+// defaults if empty, and a client for it. They are left as is if they are
+// already set, e.g. by tests. It returns the settings used to find the
+// cluster, which the Helm SDK uses as well.
+func InitKubernetes(kubeConfig, kubeContext string, restConfig **rest.Config, client *kubernetes.Interface) (*helmCLI.EnvSettings, error) {
+	// helmCLI.New() will create a settings object which is used to find the Kubernetes cluster.
+	settings := helmCLI.New()
+	if kubeConfig != "" {
+		settings.KubeConfig = kubeConfig
+	}
+	if kubeContext != "" {
+		settings.KubeContext = kubeContext
+	}
+
+	if *restConfig == nil {
+		var err error
+		*restConfig, err = settings.RESTClientGetter().ToRESTConfig()
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving Kubernetes authentication:\n%v", err)
+		}
+	}
+	if *client == nil {
+		var err error
+		*client, err = kubernetes.NewForConfig(*restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error initializing Kubernetes client:\n%v", err)
+		}
+	}
+	return settings, nil
+}
+
+// PodReady returns true if the pod is running, not terminating and its Ready
+// condition is true.
+func PodReady(pod corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+		return false
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
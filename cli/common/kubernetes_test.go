@@ -0,0 +1,53 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodReady(t *testing.T) {
+	now := metav1.Now()
+	cases := map[string]struct {
+		phase             corev1.PodPhase
+		ready             corev1.ConditionStatus
+		deletionTimestamp *metav1.Time
+		expected          bool
+	}{
+		"running and ready": {
+			phase:    corev1.PodRunning,
+			ready:    corev1.ConditionTrue,
+			expected: true,
+		},
+		"running and not ready": {
+			phase: corev1.PodRunning,
+			ready: corev1.ConditionFalse,
+		},
+		"running without a ready condition": {
+			phase: corev1.PodRunning,
+		},
+		"pending": {
+			phase: corev1.PodPending,
+			ready: corev1.ConditionTrue,
+		},
+		"terminating": {
+			phase:             corev1.PodRunning,
+			ready:             corev1.ConditionTrue,
+			deletionTimestamp: &now,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: c.deletionTimestamp},
+				Status:     corev1.PodStatus{Phase: c.phase},
+			}
+			if c.ready != "" {
+				pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: c.ready}}
+			}
+			require.Equal(t, c.expected, PodReady(pod))
+		})
+	}
+}
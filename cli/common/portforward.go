@@ -0,0 +1,112 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// PortOpener returns the local address that a port of the pod can be reached
+// on and a function that closes the connection.
+type PortOpener func(pod *corev1.Pod, port int) (string, func(), error)
+
+// PortForwarder describes how to port forward to pods.
+type PortForwarder struct {
+	KubeClient kubernetes.Interface
+	RestConfig *rest.Config
+}
+
+// Open port forwards to the port of the pod and returns the local address and
+// a function that stops forwarding.
+func (p PortForwarder) Open(pod *corev1.Pod, port int) (string, func(), error) {
+	pf := &PortForward{
+		Namespace:  pod.Namespace,
+		PodName:    pod.Name,
+		RemotePort: port,
+		KubeClient: p.KubeClient,
+		RestConfig: p.RestConfig,
+	}
+	addr, err := pf.Open()
+	if err != nil {
+		return "", nil, err
+	}
+	return addr, pf.Close, nil
+}
+
+// Opener returns open if it is set, e.g. by tests to fake the pods, and Open
+// otherwise.
+func (p PortForwarder) Opener(open PortOpener) PortOpener {
+	if open != nil {
+		return open
+	}
+	return p.Open
+}
+
+// PortForward forwards a random local port to a port of a pod.
+type PortForward struct {
+	Namespace  string
+	PodName    string
+	RemotePort int
+
+	KubeClient kubernetes.Interface
+	RestConfig *rest.Config
+
+	stopChan chan struct{}
+}
+
+// Open starts forwarding to the pod and returns the local address, i.e.
+// "localhost:<port>", that the pod's port can be reached on. Close must be
+// called to stop forwarding.
+func (pf *PortForward) Open() (string, error) {
+	transport, upgrader, err := spdy.RoundTripperFor(pf.RestConfig)
+	if err != nil {
+		return "", err
+	}
+	url := pf.KubeClient.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pf.Namespace).
+		Name(pf.PodName).
+		SubResource("portforward").
+		URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
+
+	pf.stopChan = make(chan struct{})
+	readyChan := make(chan struct{})
+	// Port 0 lets the OS pick a free local port.
+	forwarder, err := portforward.New(dialer, []string{fmt.Sprintf("0:%d", pf.RemotePort)}, pf.stopChan, readyChan, io.Discard, io.Discard)
+	if err != nil {
+		return "", err
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- forwarder.ForwardPorts()
+	}()
+
+	select {
+	case err := <-errChan:
+		return "", fmt.Errorf("error forwarding to port %d of pod %s/%s: %s", pf.RemotePort, pf.Namespace, pf.PodName, err)
+	case <-readyChan:
+	}
+
+	ports, err := forwarder.GetPorts()
+	if err != nil {
+		pf.Close()
+		return "", err
+	}
+	return fmt.Sprintf("localhost:%d", ports[0].Local), nil
+}
+
+// Close stops forwarding to the pod.
+func (pf *PortForward) Close() {
+	if pf.stopChan != nil {
+		close(pf.stopChan)
+		pf.stopChan = nil
+	}
+}
@@ -0,0 +1,130 @@
+package common
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/posener/complete"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// predictTimeout bounds the requests made to the cluster to predict
+// completions so that the shell never hangs on an unreachable cluster.
+const predictTimeout = 2 * time.Second
+
+// PredictKubeContexts predicts the names of the contexts in the kubeconfig
+// selected by the -kubeconfig flag on the command line.
+var PredictKubeContexts = complete.PredictFunc(func(args complete.Args) []string {
+	raw, err := kubeSettingsFromArgs(args.All).RESTClientGetter().ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(raw.Contexts))
+	for name := range raw.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+})
+
+// PredictKubeNamespaces predicts the names of the namespaces in the cluster
+// selected by the -kubeconfig and -context flags on the command line.
+var PredictKubeNamespaces = complete.PredictFunc(func(args complete.Args) []string {
+	client, err := kubeClientFromArgs(args.All)
+	if err != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), predictTimeout)
+	defer cancel()
+	namespaces, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(namespaces.Items))
+	for _, ns := range namespaces.Items {
+		names = append(names, ns.Name)
+	}
+	return names
+})
+
+// PredictKubePods predicts the names of the pods in the namespace set by the
+// -namespace flag on the command line, or in the default namespace. Only pods
+// matching the label selector are predicted if it is not empty.
+func PredictKubePods(labelSelector string) complete.Predictor {
+	return complete.PredictFunc(func(args complete.Args) []string {
+		client, err := kubeClientFromArgs(args.All)
+		if err != nil {
+			return nil
+		}
+		namespace := FlagValueFromArgs(args.All, "namespace", "n")
+		if namespace == "" {
+			namespace = "default"
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), predictTimeout)
+		defer cancel()
+		pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+		if err != nil {
+			return nil
+		}
+		names := make([]string, 0, len(pods.Items))
+		for _, pod := range pods.Items {
+			names = append(names, pod.Name)
+		}
+		return names
+	})
+}
+
+// FlagValueFromArgs returns the value of the flag with one of the given names
+// in the arguments, or an empty string if it is not set. The flag can be given
+// as "-name value" or "-name=value", with one or two leading dashes.
+func FlagValueFromArgs(args []string, names ...string) string {
+	value := ""
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		inline := ""
+		hasInline := false
+		if idx := strings.Index(name, "="); idx >= 0 {
+			name, inline, hasInline = name[:idx], name[idx+1:], true
+		}
+		for _, n := range names {
+			if name != n {
+				continue
+			}
+			if hasInline {
+				value = inline
+			} else if i+1 < len(args) {
+				value = args[i+1]
+			}
+		}
+	}
+	return value
+}
+
+// kubeSettingsFromArgs returns the settings to find the Kubernetes cluster
+// with, as selected by the -kubeconfig and -context flags in the arguments.
+func kubeSettingsFromArgs(args []string) *helmCLI.EnvSettings {
+	settings := helmCLI.New()
+	if kubeconfig := FlagValueFromArgs(args, "kubeconfig", "c"); kubeconfig != "" {
+		settings.KubeConfig = kubeconfig
+	}
+	if kubeContext := FlagValueFromArgs(args, "context"); kubeContext != "" {
+		settings.KubeContext = kubeContext
+	}
+	return settings
+}
+
+func kubeClientFromArgs(args []string) (kubernetes.Interface, error) {
+	restConfig, err := kubeSettingsFromArgs(args).RESTClientGetter().ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	restConfig.Timeout = predictTimeout
+	return kubernetes.NewForConfig(restConfig)
+}
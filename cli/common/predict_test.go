@@ -0,0 +1,45 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlagValueFromArgs(t *testing.T) {
+	cases := map[string]struct {
+		args []string
+		exp  string
+	}{
+		"not set": {
+			args: []string{"web", "-file", "dump.json"},
+			exp:  "",
+		},
+		"separate value": {
+			args: []string{"web", "-namespace", "consul"},
+			exp:  "consul",
+		},
+		"inline value": {
+			args: []string{"web", "-namespace=consul"},
+			exp:  "consul",
+		},
+		"alias with two dashes": {
+			args: []string{"--n", "consul", "web"},
+			exp:  "consul",
+		},
+		"last value wins": {
+			args: []string{"-n", "default", "-namespace", "consul"},
+			exp:  "consul",
+		},
+		"value still being typed": {
+			args: []string{"-namespace"},
+			exp:  "",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.exp, FlagValueFromArgs(c.args, "namespace", "n"))
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package common
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+)
+
+// TableFlags are the flags of commands that print long tables. They select
+// the rows and columns that are printed and whether the table is paged.
+type TableFlags struct {
+	filters []string
+	columns []string
+	limit   int
+	noPager bool
+
+	view terminal.TableView
+}
+
+// Flags adds the table flags to the flag set.
+func (t *TableFlags) Flags(f *flag.Set) {
+	f.VarFlag(&flag.VarFlag{
+		Name:  "filter",
+		Value: (*filterValue)(&t.filters),
+		Usage: "Only print the rows of the table that match the filter, of the form <column><operator><value> " +
+			"where the operator is one of =, !=, =~ or !~ (regular expression matches), e.g. -filter 'name=~^web-'. " +
+			"Column names are case-insensitive and spaces can be written as dashes. May be specified multiple times, " +
+			"in which case rows must match all filters. Only applies to table output.",
+	})
+	f.StringSliceVar(&flag.StringSliceVar{
+		Name:   "columns",
+		Target: &t.columns,
+		Usage:  "Comma-separated list of the columns of the table to print, in order, e.g. -columns name,envoy-version. Only applies to table output.",
+	})
+	f.IntVar(&flag.IntVar{
+		Name:   "limit",
+		Target: &t.limit,
+		Usage:  "The maximum number of rows of the table to print, after filtering. All rows are printed if it is 0. Only applies to table output.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:   "no-pager",
+		Target: &t.noPager,
+		Usage: "Print the table directly instead of through a pager. Tables printed to a terminal are otherwise piped through " +
+			"the pager set by the CONSUL_K8S_PAGER or PAGER environment variables, less by default.",
+	})
+}
+
+// Validate parses the table flags. It must be called before Print.
+func (t *TableFlags) Validate() error {
+	view := terminal.TableView{Limit: t.limit}
+	if t.limit < 0 {
+		return errors.New("-limit must be 0 or greater")
+	}
+	for _, raw := range t.filters {
+		filter, err := terminal.ParseTableFilter(raw)
+		if err != nil {
+			return err
+		}
+		view.Filters = append(view.Filters, filter)
+	}
+	for _, col := range t.columns {
+		if col = strings.TrimSpace(col); col != "" {
+			view.Columns = append(view.Columns, col)
+		}
+	}
+	t.view = view
+	return nil
+}
+
+// Print prints the rows and columns of the table the flags select. It returns
+// an error if the flags refer to a column the table doesn't have.
+func (t *TableFlags) Print(ui terminal.UI, tbl *terminal.Table) error {
+	tbl, err := t.view.Apply(tbl)
+	if err != nil {
+		return err
+	}
+	var opts []terminal.Option
+	if t.noPager {
+		opts = append(opts, terminal.WithoutPager())
+	}
+	ui.Table(tbl, opts...)
+	return nil
+}
+
+// filterValue is a flag value that is appended to each time the flag is set.
+// Unlike the StringSliceVar flag it doesn't split values on commas, which
+// are common in regular expressions.
+type filterValue []string
+
+func (f *filterValue) Set(val string) error {
+	*f = append(*f, val)
+	return nil
+}
+
+func (f *filterValue) String() string {
+	return strings.Join(*f, " ")
+}
@@ -0,0 +1,74 @@
+package terminal
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+const (
+	// pagerEnv is the environment variable that sets the pager command, in
+	// the same way as PAGER which it takes precedence over. Setting it to ""
+	// or "cat" disables the pager.
+	pagerEnv = "CONSUL_K8S_PAGER"
+	// defaultPager is the pager used if neither CONSUL_K8S_PAGER nor PAGER
+	// are set.
+	defaultPager = "less"
+	// defaultLess are the options less is run with if LESS isn't set: exit if
+	// the output fits on one screen, pass colors through and don't clear the
+	// screen on exit, like git does.
+	defaultLess = "FRX"
+)
+
+// pagerCommand returns the command of the pager that output to a terminal is
+// piped through, or nil if it isn't paged.
+func pagerCommand(lookupEnv func(string) (string, bool)) []string {
+	pager, ok := lookupEnv(pagerEnv)
+	if !ok {
+		pager, ok = lookupEnv("PAGER")
+	}
+	if !ok {
+		pager = defaultPager
+	}
+	args := strings.Fields(pager)
+	if len(args) == 0 || args[0] == "cat" {
+		return nil
+	}
+	return args
+}
+
+// page writes the output through the pager if stdout is a terminal and a
+// pager is configured, and directly to w otherwise. If the pager fails to
+// start, the output is written to w.
+func page(w io.Writer, output []byte) {
+	args := pagerCommand(os.LookupEnv)
+	if args == nil || !isatty.IsTerminal(os.Stdout.Fd()) {
+		_, _ = w.Write(output)
+		return
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(cmd.Env, "LESS="+defaultLess)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		_, _ = w.Write(output)
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		_, _ = w.Write(output)
+		return
+	}
+	// The user may quit the pager before reading all output, which makes the
+	// write fail.
+	_, _ = stdin.Write(output)
+	_ = stdin.Close()
+	_ = cmd.Wait()
+}
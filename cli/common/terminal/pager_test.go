@@ -0,0 +1,45 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPagerCommand(t *testing.T) {
+	cases := map[string]struct {
+		env map[string]string
+		exp []string
+	}{
+		"defaults to less": {
+			env: map[string]string{},
+			exp: []string{"less"},
+		},
+		"PAGER": {
+			env: map[string]string{"PAGER": "more -s"},
+			exp: []string{"more", "-s"},
+		},
+		"CONSUL_K8S_PAGER takes precedence": {
+			env: map[string]string{"PAGER": "more", pagerEnv: "less -S"},
+			exp: []string{"less", "-S"},
+		},
+		"empty disables the pager": {
+			env: map[string]string{"PAGER": "more", pagerEnv: ""},
+			exp: nil,
+		},
+		"cat disables the pager": {
+			env: map[string]string{"PAGER": "cat"},
+			exp: nil,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			lookupEnv := func(key string) (string, bool) {
+				v, ok := c.env[key]
+				return v, ok
+			}
+			require.Equal(t, c.exp, pagerCommand(lookupEnv))
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxPickerOptions is the number of options the picker lists at once. The
+// user narrows down longer lists by typing a filter.
+const maxPickerOptions = 20
+
+// Pick asks the user to pick one of the options. The user either enters the
+// number of a listed option or a filter which the options are fuzzy matched
+// against, in which case the matching options are listed until a single one
+// is left. noun describes the options in prompts, e.g. "pod".
+func Pick(ui UI, noun string, options []string) (string, error) {
+	if len(options) == 0 {
+		return "", fmt.Errorf("no %ss to pick from", noun)
+	}
+	if !ui.Interactive() {
+		return "", errors.New("the terminal is not interactive")
+	}
+
+	matches := options
+	for {
+		if len(matches) == 1 {
+			ui.Output("Picked %s %s", noun, matches[0], WithInfoStyle())
+			return matches[0], nil
+		}
+
+		ui.Output("Select a %s", noun, WithHeaderStyle())
+		for i, option := range matches {
+			if i == maxPickerOptions {
+				ui.Output("... %d more, type to filter", len(matches)-maxPickerOptions)
+				break
+			}
+			ui.Output("%3d) %s", i+1, option)
+		}
+
+		input, err := ui.Input(&Input{Prompt: fmt.Sprintf("Enter a number or filter the %ss:", noun)})
+		if err != nil {
+			return "", err
+		}
+		input = strings.TrimSpace(input)
+		if input == "" {
+			matches = options
+			continue
+		}
+		if n, err := strconv.Atoi(input); err == nil && n >= 1 && n <= len(matches) && n <= maxPickerOptions {
+			return matches[n-1], nil
+		}
+
+		filtered := FuzzyFilter(options, input)
+		if len(filtered) == 0 {
+			ui.Output("No %ss match %q", noun, input, WithWarningStyle())
+			matches = options
+			continue
+		}
+		matches = filtered
+	}
+}
+
+// FuzzyFilter returns the options that contain the characters of the pattern
+// in order, ignoring case. Options containing the pattern as a substring are
+// returned first.
+func FuzzyFilter(options []string, pattern string) []string {
+	pattern = strings.ToLower(pattern)
+	var exact, fuzzy []string
+	for _, option := range options {
+		lower := strings.ToLower(option)
+		if strings.Contains(lower, pattern) {
+			exact = append(exact, option)
+		} else if isSubsequence(pattern, lower) {
+			fuzzy = append(fuzzy, option)
+		}
+	}
+	return append(exact, fuzzy...)
+}
+
+// isSubsequence returns whether the characters of sub appear in s in order.
+func isSubsequence(sub, s string) bool {
+	remaining := []rune(sub)
+	for _, r := range s {
+		if len(remaining) == 0 {
+			break
+		}
+		if r == remaining[0] {
+			remaining = remaining[1:]
+		}
+	}
+	return len(remaining) == 0
+}
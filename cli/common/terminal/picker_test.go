@@ -0,0 +1,39 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuzzyFilter(t *testing.T) {
+	options := []string{"web-7d9f6-abcde", "api-5c8b4-fghij", "frontend-web-6f7d8-klmno", "backend-1a2b3-pqrst"}
+
+	cases := map[string]struct {
+		pattern string
+		exp     []string
+	}{
+		"substring matches come first": {
+			pattern: "web",
+			exp:     []string{"web-7d9f6-abcde", "frontend-web-6f7d8-klmno"},
+		},
+		"fuzzy match": {
+			pattern: "bknd",
+			exp:     []string{"backend-1a2b3-pqrst"},
+		},
+		"ignores case": {
+			pattern: "API",
+			exp:     []string{"api-5c8b4-fghij"},
+		},
+		"no match": {
+			pattern: "zz",
+			exp:     nil,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.exp, FuzzyFilter(options, c.pattern))
+		})
+	}
+}
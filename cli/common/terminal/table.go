@@ -1,6 +1,9 @@
 package terminal
 
 import (
+	"bytes"
+	"fmt"
+
 	"github.com/fatih/color"
 	"github.com/olekukonko/tablewriter"
 )
@@ -9,6 +12,8 @@ import (
 type Table struct {
 	Headers []string
 	Rows    [][]TableEntry
+	// Omitted is the number of rows left out by the limit of a TableView.
+	Omitted int
 }
 
 // Table creates a new Table structure that can be used with UI.Table.
@@ -47,7 +52,9 @@ func (u *basicUI) Table(tbl *Table, opts ...Option) {
 		opt(cfg)
 	}
 
-	table := tablewriter.NewWriter(cfg.Writer)
+	// Tables printed to the terminal are paged, so they are rendered first.
+	var buf bytes.Buffer
+	table := tablewriter.NewWriter(&buf)
 
 	table.SetHeader(tbl.Headers)
 	table.SetBorder(false)
@@ -84,6 +91,15 @@ func (u *basicUI) Table(tbl *Table, opts ...Option) {
 	}
 
 	table.Render()
+	if tbl.Omitted > 0 {
+		fmt.Fprintf(&buf, "... %d more rows not shown\n", tbl.Omitted)
+	}
+
+	if cfg.Writer == color.Output && !cfg.NoPager {
+		page(cfg.Writer, buf.Bytes())
+		return
+	}
+	_, _ = cfg.Writer.Write(buf.Bytes())
 }
 
 const (
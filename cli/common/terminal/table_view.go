@@ -0,0 +1,190 @@
+package terminal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Table filter operators.
+const (
+	FilterEqual      = "="
+	FilterNotEqual   = "!="
+	FilterMatches    = "=~"
+	FilterNotMatches = "!~"
+)
+
+// filterOperators are the operators of table filters, ordered so that the
+// two character operators are found before "=".
+var filterOperators = []string{FilterMatches, FilterNotMatches, FilterNotEqual, FilterEqual}
+
+// TableFilter selects the rows of a table by the value of a column.
+type TableFilter struct {
+	// Column is the header of the column, matched case-insensitively and
+	// ignoring spaces, dashes and underscores, e.g. "envoy-version" matches
+	// the "Envoy Version" column.
+	Column string
+	// Operator is one of FilterEqual, FilterNotEqual, FilterMatches and
+	// FilterNotMatches.
+	Operator string
+	// Value is compared to the values of the column, or is the regular
+	// expression they are matched against.
+	Value string
+
+	re *regexp.Regexp
+}
+
+// ParseTableFilter parses a filter of the form <column><operator><value>,
+// e.g. "name=~^web-" or "xds!=connected".
+func ParseTableFilter(s string) (TableFilter, error) {
+	idx, op := -1, ""
+	for _, candidate := range filterOperators {
+		i := strings.Index(s, candidate)
+		if i == -1 {
+			continue
+		}
+		// The operator that starts first wins, so that "a=b!=c" is a filter on
+		// column "a". Two character operators win ties since they are listed
+		// first.
+		if idx == -1 || i < idx {
+			idx, op = i, candidate
+		}
+	}
+	if idx <= 0 {
+		return TableFilter{}, fmt.Errorf("filter %q must be of the form <column><operator><value> where the operator is one of =, !=, =~ or !~", s)
+	}
+
+	f := TableFilter{
+		Column:   strings.TrimSpace(s[:idx]),
+		Operator: op,
+		Value:    s[idx+len(op):],
+	}
+	if op == FilterMatches || op == FilterNotMatches {
+		re, err := regexp.Compile(f.Value)
+		if err != nil {
+			return TableFilter{}, fmt.Errorf("filter %q has an invalid regular expression: %s", s, err)
+		}
+		f.re = re
+	}
+	return f, nil
+}
+
+// matches returns true if the value of the column satisfies the filter.
+func (f TableFilter) matches(value string) bool {
+	switch f.Operator {
+	case FilterEqual:
+		return value == f.Value
+	case FilterNotEqual:
+		return value != f.Value
+	case FilterMatches:
+		return f.re.MatchString(value)
+	case FilterNotMatches:
+		return !f.re.MatchString(value)
+	}
+	return false
+}
+
+// TableView selects the rows and columns of a Table that are printed. The
+// zero value prints the whole table.
+type TableView struct {
+	// Filters select the rows that match all of them.
+	Filters []TableFilter
+	// Columns are the headers of the columns to print, in the order they are
+	// printed. All columns are printed if it is empty.
+	Columns []string
+	// Limit is the maximum number of rows printed, after filtering. All rows
+	// are printed if it is zero.
+	Limit int
+}
+
+// Apply returns a table with the rows and columns of tbl the view selects.
+// The number of rows left out by the limit is recorded in the Omitted field
+// of the table. It returns an error if a filter or column doesn't match any
+// header of tbl.
+func (v TableView) Apply(tbl *Table) (*Table, error) {
+	filterColumns := make([]int, len(v.Filters))
+	for i, f := range v.Filters {
+		col, err := columnIndex(tbl.Headers, f.Column)
+		if err != nil {
+			return nil, err
+		}
+		filterColumns[i] = col
+	}
+
+	columns := make([]int, len(tbl.Headers))
+	for i := range tbl.Headers {
+		columns[i] = i
+	}
+	if len(v.Columns) > 0 {
+		columns = columns[:0]
+		for _, name := range v.Columns {
+			col, err := columnIndex(tbl.Headers, name)
+			if err != nil {
+				return nil, err
+			}
+			columns = append(columns, col)
+		}
+	}
+
+	out := &Table{}
+	for _, col := range columns {
+		out.Headers = append(out.Headers, tbl.Headers[col])
+	}
+
+rows:
+	for _, row := range tbl.Rows {
+		for i, f := range v.Filters {
+			if !f.matches(entryValue(row, filterColumns[i])) {
+				continue rows
+			}
+		}
+		if v.Limit > 0 && len(out.Rows) == v.Limit {
+			out.Omitted++
+			continue
+		}
+		selected := make([]TableEntry, len(columns))
+		for i, col := range columns {
+			if col < len(row) {
+				selected[i] = row[col]
+			}
+		}
+		out.Rows = append(out.Rows, selected)
+	}
+	return out, nil
+}
+
+// columnIndex returns the index of the header that name refers to.
+func columnIndex(headers []string, name string) (int, error) {
+	key := columnKey(name)
+	for i, h := range headers {
+		if columnKey(h) == key {
+			return i, nil
+		}
+	}
+	keys := make([]string, len(headers))
+	for i, h := range headers {
+		keys[i] = strings.ToLower(strings.ReplaceAll(h, " ", "-"))
+	}
+	return 0, fmt.Errorf("unknown column %q, must be one of: %s", name, strings.Join(keys, ", "))
+}
+
+// columnKey normalizes a column name so that "Envoy Version", "envoy-version"
+// and "envoy_version" refer to the same column.
+func columnKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '_' {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, name)
+}
+
+// entryValue returns the value of the column of the row, or "" if the row
+// is shorter.
+func entryValue(row []TableEntry, col int) string {
+	if col < len(row) {
+		return row[col].Value
+	}
+	return ""
+}
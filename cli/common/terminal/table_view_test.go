@@ -0,0 +1,181 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTableFilter(t *testing.T) {
+	cases := map[string]struct {
+		filter    string
+		expColumn string
+		expOp     string
+		expValue  string
+		expErr    string
+	}{
+		"equal": {
+			filter:    "name=web",
+			expColumn: "name",
+			expOp:     FilterEqual,
+			expValue:  "web",
+		},
+		"not equal": {
+			filter:    "xds!=connected",
+			expColumn: "xds",
+			expOp:     FilterNotEqual,
+			expValue:  "connected",
+		},
+		"matches": {
+			filter:    "name=~^web-[a-z]{1,3}",
+			expColumn: "name",
+			expOp:     FilterMatches,
+			expValue:  "^web-[a-z]{1,3}",
+		},
+		"not matches": {
+			filter:    "envoy-version!~^1\\.2",
+			expColumn: "envoy-version",
+			expOp:     FilterNotMatches,
+			expValue:  "^1\\.2",
+		},
+		"first operator wins": {
+			filter:    "name=a!=b",
+			expColumn: "name",
+			expOp:     FilterEqual,
+			expValue:  "a!=b",
+		},
+		"empty value": {
+			filter:    "protocol=",
+			expColumn: "protocol",
+			expOp:     FilterEqual,
+			expValue:  "",
+		},
+		"no operator": {
+			filter: "name",
+			expErr: `filter "name" must be of the form <column><operator><value>`,
+		},
+		"no column": {
+			filter: "=web",
+			expErr: `filter "=web" must be of the form <column><operator><value>`,
+		},
+		"invalid regular expression": {
+			filter: "name=~(",
+			expErr: `filter "name=~(" has an invalid regular expression`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			f, err := ParseTableFilter(c.filter)
+			if c.expErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expColumn, f.Column)
+			require.Equal(t, c.expOp, f.Operator)
+			require.Equal(t, c.expValue, f.Value)
+		})
+	}
+}
+
+func TestTableView_Apply(t *testing.T) {
+	tbl := NewTable("Name", "Envoy Version", "xDS")
+	tbl.Rich([]string{"web-1", "1.22.2", "connected"}, []string{"", "", Green})
+	tbl.Rich([]string{"web-2", "1.21.0", "disconnected"}, []string{"", "", Red})
+	tbl.Rich([]string{"api-1", "1.22.2", "connected"}, []string{"", "", Green})
+	tbl.Rich([]string{"api-2"}, nil)
+
+	filter := func(s string) TableFilter {
+		f, err := ParseTableFilter(s)
+		require.NoError(t, err)
+		return f
+	}
+
+	cases := map[string]struct {
+		view       TableView
+		expHeaders []string
+		expRows    [][]string
+		expOmitted int
+		expErr     string
+	}{
+		"zero value prints the whole table": {
+			view:       TableView{},
+			expHeaders: []string{"Name", "Envoy Version", "xDS"},
+			expRows: [][]string{
+				{"web-1", "1.22.2", "connected"},
+				{"web-2", "1.21.0", "disconnected"},
+				{"api-1", "1.22.2", "connected"},
+				{"api-2", "", ""},
+			},
+		},
+		"filters must all match": {
+			view:       TableView{Filters: []TableFilter{filter("name=~^web-"), filter("XDS=connected")}},
+			expHeaders: []string{"Name", "Envoy Version", "xDS"},
+			expRows: [][]string{
+				{"web-1", "1.22.2", "connected"},
+			},
+		},
+		"filters on missing entries compare the empty string": {
+			view:       TableView{Filters: []TableFilter{filter("envoy_version=")}},
+			expHeaders: []string{"Name", "Envoy Version", "xDS"},
+			expRows: [][]string{
+				{"api-2", "", ""},
+			},
+		},
+		"columns are selected in order": {
+			view:       TableView{Columns: []string{"xds", "name"}},
+			expHeaders: []string{"xDS", "Name"},
+			expRows: [][]string{
+				{"connected", "web-1"},
+				{"disconnected", "web-2"},
+				{"connected", "api-1"},
+				{"", "api-2"},
+			},
+		},
+		"limit applies after filtering": {
+			view:       TableView{Filters: []TableFilter{filter("envoy-version!=1.21.0")}, Columns: []string{"name"}, Limit: 1},
+			expHeaders: []string{"Name"},
+			expRows: [][]string{
+				{"web-1"},
+			},
+			expOmitted: 2,
+		},
+		"unknown filter column": {
+			view:   TableView{Filters: []TableFilter{filter("status=ok")}},
+			expErr: `unknown column "status", must be one of: name, envoy-version, xds`,
+		},
+		"unknown column": {
+			view:   TableView{Columns: []string{"name", "version"}},
+			expErr: `unknown column "version", must be one of: name, envoy-version, xds`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			out, err := c.view.Apply(tbl)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expHeaders, out.Headers)
+			var rows [][]string
+			for _, row := range out.Rows {
+				var values []string
+				for _, entry := range row {
+					values = append(values, entry.Value)
+				}
+				rows = append(rows, values)
+			}
+			require.Equal(t, c.expRows, rows)
+			require.Equal(t, c.expOmitted, out.Omitted)
+		})
+	}
+
+	// The colors of the selected entries are kept.
+	out, err := TableView{Columns: []string{"xds"}, Limit: 1}.Apply(tbl)
+	require.NoError(t, err)
+	require.Equal(t, Green, out.Rows[0][0].Color)
+}
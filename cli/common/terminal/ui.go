@@ -112,6 +112,9 @@ type config struct {
 
 	// The style the output should take on
 	Style string
+
+	// NoPager prints tables directly although they are printed to a terminal.
+	NoPager bool
 }
 
 // Option controls output styling.
@@ -194,6 +197,13 @@ func WithWriter(w io.Writer) Option {
 	return func(c *config) { c.Writer = w }
 }
 
+// WithoutPager prints a table directly instead of through the pager. Tables
+// printed to a terminal are otherwise piped through the pager set by the
+// CONSUL_K8S_PAGER or PAGER environment variables, less by default.
+func WithoutPager() Option {
+	return func(c *config) { c.NoPager = true }
+}
+
 var (
 	colorHeader        = color.New(color.Bold)
 	colorInfo          = color.New()
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"helm.sh/helm/v3/pkg/action"
 	helmCLI "helm.sh/helm/v3/pkg/cli"
@@ -19,8 +20,50 @@ const (
 	// which key to delete on an uninstall.
 	CLILabelKey   = "managed-by"
 	CLILabelValue = "consul-k8s"
+
+	// InjectStatusAnnotation is the annotation the connect injector sets to
+	// "injected" on the pods it injects.
+	InjectStatusAnnotation = "consul.hashicorp.com/connect-inject-status"
+	// InjectedPodSelector selects the pods the connect injector added a
+	// proxy to.
+	InjectedPodSelector = InjectStatusAnnotation + "=injected"
+	// InitContainerName is the name of the init container added to pods by
+	// the connect injector. It runs the consul-k8s-control-plane image that
+	// sets up the proxy of the pod.
+	InitContainerName = "consul-connect-inject-init"
+	// EnvoyContainerName is the name of the Envoy container added to pods by
+	// the connect injector.
+	EnvoyContainerName = "envoy-sidecar"
+
+	// TokenEnvVar is the environment variable the ACL token for the Consul
+	// API is read from if it isn't set with a flag.
+	TokenEnvVar = "CONSUL_HTTP_TOKEN"
+
+	// FlagNameAdminPort and DefaultAdminPort are the flag and default of the
+	// port of the Envoy admin API the proxy commands port-forward to.
+	FlagNameAdminPort = "admin-port"
+	DefaultAdminPort  = 19000
+
+	// ProbeConcurrency is the number of proxies that are probed at once.
+	ProbeConcurrency = 10
+	// ProbeTimeout bounds the time spent probing a single proxy.
+	ProbeTimeout = 10 * time.Second
+	// CertExpiryWarning is how long before expiry a certificate is
+	// highlighted.
+	CertExpiryWarning = 24 * time.Hour
 )
 
+// ImageTag returns the tag or digest of the image reference.
+func ImageTag(image string) string {
+	if idx := strings.LastIndex(image, "@"); idx >= 0 {
+		return image[idx+1:]
+	}
+	if idx := strings.LastIndex(image, ":"); idx > strings.LastIndex(image, "/") {
+		return image[idx+1:]
+	}
+	return "latest"
+}
+
 // Abort returns true if the raw input string is not equal to "y" or "yes".
 func Abort(raw string) bool {
 	confirmation := strings.TrimSuffix(raw, "\n")
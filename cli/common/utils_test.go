@@ -65,3 +65,22 @@ func TestIsValidLabel(t *testing.T) {
 		})
 	}
 }
+
+func TestImageTag(t *testing.T) {
+	cases := []struct {
+		name     string
+		image    string
+		expected string
+	}{
+		{"Tag", "hashicorp/consul-k8s-control-plane:0.42.0", "0.42.0"},
+		{"Digest", "hashicorp/consul@sha256:abc", "sha256:abc"},
+		{"Registry with port and no tag", "registry.internal:5000/hashicorp/consul", "latest"},
+		{"No tag", "envoyproxy/envoy", "latest"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, ImageTag(tc.image))
+		})
+	}
+}
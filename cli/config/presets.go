@@ -1,16 +1,133 @@
 package config
 
-import "sigs.k8s.io/yaml"
+import (
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
 
 const (
-	PresetDemo   = "demo"
-	PresetSecure = "secure"
+	PresetDemo                  = "demo"
+	PresetSecure                = "secure"
+	PresetProduction            = "production"
+	PresetMultiClusterPrimary   = "multi-cluster-primary"
+	PresetMultiClusterSecondary = "multi-cluster-secondary"
+	PresetExternalServers       = "external-servers"
+	PresetLambda                = "lambda"
 )
 
 // Presets is a map of pre-configured helm values.
 var Presets = map[string]interface{}{
-	PresetDemo:   Convert(demo),
-	PresetSecure: Convert(secure),
+	PresetDemo:                  Convert(demo),
+	PresetSecure:                Convert(secure),
+	PresetProduction:            Convert(production),
+	PresetMultiClusterPrimary:   Convert(multiClusterPrimary),
+	PresetMultiClusterSecondary: Convert(multiClusterSecondary),
+	PresetExternalServers:       Convert(externalServers),
+	PresetLambda:                Convert(lambda),
+}
+
+// PresetInput is a cluster-specific value that a preset can't provide and
+// that the user is prompted for when installing with the preset.
+type PresetInput struct {
+	// Paths are the dot-separated Helm value paths, e.g. "global.datacenter",
+	// set to the input.
+	Paths []string
+
+	// Prompt is the prompt given to the user.
+	Prompt string
+
+	// List is true if the value is a list, entered as comma-separated values.
+	List bool
+}
+
+// PresetInputs are the inputs required by each preset, in the order the user
+// is prompted for them.
+var PresetInputs = map[string][]PresetInput{
+	PresetProduction: {
+		{Paths: []string{"global.datacenter"}, Prompt: "Name of the Consul datacenter"},
+	},
+	PresetMultiClusterPrimary: {
+		{Paths: []string{"global.datacenter"}, Prompt: "Name of the Consul datacenter"},
+		{Paths: []string{"global.image"}, Prompt: "Consul Enterprise image, e.g. hashicorp/consul-enterprise:1.11.4-ent"},
+		{Paths: []string{"global.enterpriseLicense.secretName"}, Prompt: "Name of the Kubernetes secret with the Consul Enterprise license"},
+	},
+	PresetMultiClusterSecondary: {
+		{Paths: []string{"global.datacenter"}, Prompt: "Name of the Consul datacenter of the primary cluster"},
+		{Paths: []string{"global.adminPartitions.name"}, Prompt: "Name of the admin partition of this cluster"},
+		{Paths: []string{"global.image"}, Prompt: "Consul Enterprise image, e.g. hashicorp/consul-enterprise:1.11.4-ent"},
+		{Paths: []string{"global.enterpriseLicense.secretName"}, Prompt: "Name of the Kubernetes secret with the Consul Enterprise license"},
+		{Paths: []string{"externalServers.hosts", "client.join"}, Prompt: "Addresses of the partition service of the primary cluster (comma-separated)", List: true},
+		{Paths: []string{"externalServers.k8sAuthMethodHost"}, Prompt: "Address of the Kubernetes API server of this cluster, reachable from the Consul servers"},
+	},
+	PresetExternalServers: {
+		{Paths: []string{"global.datacenter"}, Prompt: "Name of the Consul datacenter of the external servers"},
+		{Paths: []string{"externalServers.hosts", "client.join"}, Prompt: "Addresses of the external Consul servers (comma-separated)", List: true},
+	},
+}
+
+// MissingPresetInputs returns the inputs required by the preset that aren't set
+// in vals.
+func MissingPresetInputs(preset string, vals map[string]interface{}) []PresetInput {
+	var missing []PresetInput
+	for _, input := range PresetInputs[preset] {
+		if _, ok := lookupValue(vals, input.Paths[0]); !ok {
+			missing = append(missing, input)
+		}
+	}
+	return missing
+}
+
+// Values returns the Helm values that set every path of the input to the raw
+// value entered by the user.
+func (i PresetInput) Values(raw string) map[string]interface{} {
+	var value interface{} = strings.TrimSpace(raw)
+	if i.List {
+		var list []interface{}
+		for _, v := range strings.Split(raw, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				list = append(list, v)
+			}
+		}
+		value = list
+	}
+
+	vals := make(map[string]interface{})
+	for _, path := range i.Paths {
+		keys := strings.Split(path, ".")
+		m := vals
+		for _, key := range keys[:len(keys)-1] {
+			next, ok := m[key].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				m[key] = next
+			}
+			m = next
+		}
+		m[keys[len(keys)-1]] = value
+	}
+	return vals
+}
+
+// lookupValue returns the non-empty value at the dot-separated path of vals.
+func lookupValue(vals map[string]interface{}, path string) (interface{}, bool) {
+	var cur interface{} = vals
+	for _, key := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[key]; !ok || cur == nil {
+			return nil, false
+		}
+	}
+	switch v := cur.(type) {
+	case string:
+		return v, v != ""
+	case []interface{}:
+		return v, len(v) > 0
+	}
+	return cur, true
 }
 
 // demo is a preset of common values for setting up Consul.
@@ -20,12 +137,12 @@ global:
   metrics:
     enabled: true
     enableAgentMetrics: true
+    enableGatewayMetrics: true
 connectInject:
   enabled: true
   metrics:
     defaultEnabled: true
     defaultEnableMerging: true
-    enableGatewayMetrics: true
 server:
   replicas: 1
 controller:
@@ -57,12 +174,146 @@ controller:
   enabled: true
 `
 
+// production is a preset for a single-datacenter production installation with
+// three servers, spread across nodes by the default affinity of the chart, and
+// every security feature enabled.
+const production = `
+global:
+  name: consul
+  gossipEncryption:
+    autoGenerate: true
+  tls:
+    enabled: true
+    enableAutoEncrypt: true
+  acls:
+    manageSystemACLs: true
+server:
+  replicas: 3
+  bootstrapExpect: 3
+connectInject:
+  enabled: true
+controller:
+  enabled: true
+ui:
+  enabled: true
+`
+
+// multiClusterPrimary is a preset for the cluster running the Consul Enterprise
+// servers that the admin partitions of other clusters join.
+const multiClusterPrimary = `
+global:
+  name: consul
+  enableConsulNamespaces: true
+  adminPartitions:
+    enabled: true
+    name: default
+  tls:
+    enabled: true
+  acls:
+    manageSystemACLs: true
+server:
+  replicas: 3
+  bootstrapExpect: 3
+  exposeGossipAndRPCPorts: true
+client:
+  exposeGossipPorts: true
+connectInject:
+  enabled: true
+controller:
+  enabled: true
+meshGateway:
+  enabled: true
+`
+
+// multiClusterSecondary is a preset for a cluster in its own admin partition
+// whose clients join the Consul Enterprise servers of the primary cluster. The
+// TLS CA and the partition ACL token must have been copied from the primary
+// cluster into the secrets referenced below.
+const multiClusterSecondary = `
+global:
+  name: consul
+  enableConsulNamespaces: true
+  adminPartitions:
+    enabled: true
+  tls:
+    enabled: true
+    caCert:
+      secretName: consul-ca-cert
+      secretKey: tls.crt
+    caKey:
+      secretName: consul-ca-key
+      secretKey: tls.key
+  acls:
+    manageSystemACLs: true
+    bootstrapToken:
+      secretName: consul-partitions-acl-token
+      secretKey: token
+server:
+  enabled: false
+externalServers:
+  enabled: true
+client:
+  enabled: true
+  exposeGossipPorts: true
+connectInject:
+  enabled: true
+controller:
+  enabled: true
+meshGateway:
+  enabled: true
+`
+
+// externalServers is a preset for a cluster that only runs the Consul clients
+// and the control plane, and uses Consul servers running outside of it.
+const externalServers = `
+global:
+  name: consul
+server:
+  enabled: false
+externalServers:
+  enabled: true
+client:
+  enabled: true
+connectInject:
+  enabled: true
+controller:
+  enabled: true
+`
+
+// lambda is a preset for a service mesh whose services call AWS Lambda
+// functions through a terminating gateway.
+const lambda = `
+global:
+  name: consul
+  tls:
+    enabled: true
+    enableAutoEncrypt: true
+  acls:
+    manageSystemACLs: true
+server:
+  replicas: 1
+connectInject:
+  enabled: true
+controller:
+  enabled: true
+terminatingGateways:
+  enabled: true
+`
+
 // GlobalNameConsul is used to set the global name of an install to consul.
 const GlobalNameConsul = `
 global:
   name: consul
 `
 
+// GlobalOpenShiftEnabled is used to enable the OpenShift support of an install
+// on an OpenShift cluster.
+const GlobalOpenShiftEnabled = `
+global:
+  openshift:
+    enabled: true
+`
+
 // convert is a helper function that converts a YAML string to a map.
 func Convert(s string) map[string]interface{} {
 	var m map[string]interface{}
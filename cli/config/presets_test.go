@@ -0,0 +1,85 @@
+package config
+
+import (
+	"testing"
+
+	consulChart "github.com/hashicorp/consul-k8s/charts"
+	"github.com/hashicorp/consul-k8s/cli/helm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresets(t *testing.T) {
+	for name := range Presets {
+		t.Run(name, func(t *testing.T) {
+			require.NotEmpty(t, Presets[name])
+		})
+	}
+	for name := range PresetInputs {
+		require.Contains(t, Presets, name)
+	}
+}
+
+// Test that the presets only set values that exist in the chart, with the
+// right types.
+func TestPresets_ValidValues(t *testing.T) {
+	chrt, err := helm.LoadChart(consulChart.ConsulHelmChart, "consul")
+	require.NoError(t, err)
+	for name, preset := range Presets {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, helm.ValidateValues(chrt, preset.(map[string]interface{})))
+		})
+	}
+}
+
+func TestMissingPresetInputs(t *testing.T) {
+	missing := MissingPresetInputs(PresetExternalServers, Convert(`
+global:
+  datacenter: dc2
+externalServers:
+  hosts: []
+`))
+	require.Len(t, missing, 1)
+	require.Equal(t, "externalServers.hosts", missing[0].Paths[0])
+
+	missing = MissingPresetInputs(PresetExternalServers, Convert(`
+global:
+  datacenter: dc2
+externalServers:
+  hosts: ["1.2.3.4"]
+`))
+	require.Empty(t, missing)
+
+	require.Empty(t, MissingPresetInputs(PresetDemo, nil))
+}
+
+func TestPresetInput_Values(t *testing.T) {
+	cases := map[string]struct {
+		input PresetInput
+		raw   string
+		exp   string
+	}{
+		"string": {
+			input: PresetInput{Paths: []string{"global.datacenter"}},
+			raw:   " dc2\n",
+			exp: `
+global:
+  datacenter: dc2
+`,
+		},
+		"list with multiple paths": {
+			input: PresetInput{Paths: []string{"externalServers.hosts", "client.join"}, List: true},
+			raw:   "1.2.3.4, 5.6.7.8,\n",
+			exp: `
+externalServers:
+  hosts: ["1.2.3.4", "5.6.7.8"]
+client:
+  join: ["1.2.3.4", "5.6.7.8"]
+`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, Convert(c.exp), c.input.Values(c.raw))
+		})
+	}
+}
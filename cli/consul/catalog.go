@@ -0,0 +1,100 @@
+package consul
+
+import (
+	"context"
+	"net/url"
+	"sort"
+)
+
+// Kinds of service instances in the catalog. Instances of typical services
+// have no kind.
+const (
+	ServiceKindTypical      = ""
+	ServiceKindConnectProxy = "connect-proxy"
+)
+
+// CatalogQuery scopes catalog requests to a Consul namespace, an admin
+// partition or a peer. Empty fields use the defaults of the server, and
+// Namespace can be "*" to query all namespaces.
+type CatalogQuery struct {
+	Namespace string
+	Partition string
+	Peer      string
+}
+
+// values returns the query parameters of the query.
+func (q CatalogQuery) values() url.Values {
+	query := url.Values{}
+	if q.Namespace != "" {
+		query.Set("ns", q.Namespace)
+	}
+	if q.Partition != "" {
+		query.Set("partition", q.Partition)
+	}
+	if q.Peer != "" {
+		query.Set("peer", q.Peer)
+	}
+	return query
+}
+
+// CatalogService is a service instance registered in the catalog.
+type CatalogService struct {
+	Datacenter  string
+	Node        string
+	ServiceID   string
+	ServiceName string
+	// ServiceKind is empty for typical services, or e.g. "connect-proxy" or
+	// "mesh-gateway".
+	ServiceKind    string
+	ServiceTags    []string
+	ServiceMeta    map[string]string
+	ServiceProxy   *CatalogServiceProxy
+	ServiceConnect *CatalogServiceConnect
+	Namespace      string
+	Partition      string
+	PeerName       string
+}
+
+// CatalogServiceProxy is the proxy config of a connect-proxy instance. It
+// names the instance the proxy is the sidecar of.
+type CatalogServiceProxy struct {
+	DestinationServiceName string
+	DestinationServiceID   string
+}
+
+// CatalogServiceConnect is the Connect config of a service instance. Native
+// instances are in the mesh without a sidecar proxy.
+type CatalogServiceConnect struct {
+	Native bool
+}
+
+// CatalogServices returns the sorted names of the services in the catalog.
+func (c *Client) CatalogServices(ctx context.Context, q CatalogQuery) ([]string, error) {
+	var services map[string][]string
+	if err := c.get(ctx, withQuery("/v1/catalog/services", q.values()), "catalog services", &services); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// CatalogService returns the instances of the service.
+func (c *Client) CatalogService(ctx context.Context, name string, q CatalogQuery) ([]CatalogService, error) {
+	var instances []CatalogService
+	if err := c.get(ctx, withQuery("/v1/catalog/service/"+url.PathEscape(name), q.values()), "catalog service", &instances); err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+// withQuery appends the query parameters to the path if there are any.
+func withQuery(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
@@ -0,0 +1,57 @@
+package consul
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalog(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/catalog/services":
+			require.Equal(t, "*", r.URL.Query().Get("ns"))
+			require.Equal(t, "team", r.URL.Query().Get("partition"))
+			w.Write([]byte(`{"web": [], "api": ["v2"], "api-sidecar-proxy": []}`))
+		case "/v1/catalog/service/api":
+			require.Equal(t, "dc2", r.URL.Query().Get("peer"))
+			w.Write([]byte(`[{"Datacenter": "dc1", "Node": "node-1", "ServiceID": "api-1", "ServiceName": "api", "ServiceMeta": {"k8s-namespace": "default"},
+  "ServiceConnect": {"Native": true}, "PeerName": "dc2"}]`))
+		case "/v1/catalog/service/api-sidecar-proxy":
+			require.Empty(t, r.URL.RawQuery)
+			w.Write([]byte(`[{"Node": "node-1", "ServiceID": "api-1-sidecar-proxy", "ServiceName": "api-sidecar-proxy", "ServiceKind": "connect-proxy",
+  "ServiceProxy": {"DestinationServiceName": "api", "DestinationServiceID": "api-1"}}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	client := &Client{Addr: strings.TrimPrefix(srv.URL, "http://")}
+	ctx := context.Background()
+
+	services, err := client.CatalogServices(ctx, CatalogQuery{Namespace: "*", Partition: "team"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"api", "api-sidecar-proxy", "web"}, services)
+
+	instances, err := client.CatalogService(ctx, "api", CatalogQuery{Peer: "dc2"})
+	require.NoError(t, err)
+	require.Equal(t, []CatalogService{{
+		Datacenter:     "dc1",
+		Node:           "node-1",
+		ServiceID:      "api-1",
+		ServiceName:    "api",
+		ServiceMeta:    map[string]string{"k8s-namespace": "default"},
+		ServiceConnect: &CatalogServiceConnect{Native: true},
+		PeerName:       "dc2",
+	}}, instances)
+
+	instances, err = client.CatalogService(ctx, "api-sidecar-proxy", CatalogQuery{})
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	require.Equal(t, ServiceKindConnectProxy, instances[0].ServiceKind)
+	require.Equal(t, &CatalogServiceProxy{DestinationServiceName: "api", DestinationServiceID: "api-1"}, instances[0].ServiceProxy)
+}
@@ -0,0 +1,84 @@
+// Package consul contains helpers that call the HTTP API of Consul servers,
+// usually through a port forward to a server pod.
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// tokenHeader is the header the ACL token is sent in.
+const tokenHeader = "X-Consul-Token"
+
+// Client calls the HTTP API of a single Consul server.
+type Client struct {
+	// Addr is the address of the server's HTTP API, e.g. "localhost:8500".
+	Addr string
+	// PathPrefix is prepended to the path of every request, e.g. to call the
+	// API through the Kubernetes API server. It is optional.
+	PathPrefix string
+	// Scheme is "http" or "https". It defaults to "http".
+	Scheme string
+	// Token is the ACL token sent with every request. It is optional.
+	Token string
+	// HTTPClient sends the requests. It defaults to http.DefaultClient and must
+	// be set to use a custom CA with https.
+	HTTPClient *http.Client
+}
+
+// Leader returns the address of the Raft leader, e.g. "10.0.0.5:8300".
+func (c *Client) Leader(ctx context.Context) (string, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/v1/status/leader", nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var leader string
+	if err := json.NewDecoder(resp.Body).Decode(&leader); err != nil {
+		return "", fmt.Errorf("invalid leader response: %s", err)
+	}
+	if leader == "" {
+		return "", fmt.Errorf("cluster has no leader")
+	}
+	return leader, nil
+}
+
+// do sends the request and returns the response if its status is 200 OK.
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// send sends the request and returns the response regardless of its status.
+func (c *Client) send(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	scheme := c.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s://%s%s%s", scheme, c.Addr, c.PathPrefix, path), body)
+	if err != nil {
+		return nil, err
+	}
+	if c.Token != "" {
+		req.Header.Set(tokenHeader, c.Token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return httpClient.Do(req)
+}
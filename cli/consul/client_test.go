@@ -0,0 +1,56 @@
+package consul
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeader(t *testing.T) {
+	cases := map[string]struct {
+		status int
+		body   string
+		exp    string
+		expErr string
+	}{
+		"leader": {
+			status: http.StatusOK,
+			body:   `"10.0.0.5:8300"`,
+			exp:    "10.0.0.5:8300",
+		},
+		"no leader": {
+			status: http.StatusOK,
+			body:   `""`,
+			expErr: "cluster has no leader",
+		},
+		"permission denied": {
+			status: http.StatusForbidden,
+			body:   "Permission denied\n",
+			expErr: "unexpected status 403 Forbidden: Permission denied",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "/v1/status/leader", r.URL.Path)
+				w.WriteHeader(c.status)
+				w.Write([]byte(c.body))
+			}))
+			defer srv.Close()
+
+			client := &Client{Addr: strings.TrimPrefix(srv.URL, "http://")}
+			leader, err := client.Leader(context.Background())
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, leader)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ConfigEntry is a config entry as returned by the HTTP API, e.g.
+// {"Kind": "service-defaults", "Name": "web", "Protocol": "http"}.
+type ConfigEntry map[string]interface{}
+
+// Name returns the name of the config entry.
+func (e ConfigEntry) Name() string {
+	name, _ := e["Name"].(string)
+	return name
+}
+
+// Meta returns the meta of the config entry.
+func (e ConfigEntry) Meta() map[string]string {
+	meta := make(map[string]string)
+	m, _ := e["Meta"].(map[string]interface{})
+	for k, v := range m {
+		if s, ok := v.(string); ok {
+			meta[k] = s
+		}
+	}
+	return meta
+}
+
+// ConfigEntries returns the config entries of the kind, e.g.
+// "service-defaults".
+func (c *Client) ConfigEntries(ctx context.Context, kind string) ([]ConfigEntry, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/v1/config/"+url.PathEscape(kind), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var entries []ConfigEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("invalid config entries response: %s", err)
+	}
+	return entries, nil
+}
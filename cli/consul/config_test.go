@@ -0,0 +1,27 @@
+package consul
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigEntries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/config/service-defaults", r.URL.Path)
+		w.Write([]byte(`[{"Kind": "service-defaults", "Name": "web", "Protocol": "http", "Meta": {"consul.hashicorp.com/source-datacenter": "dc1"}}]`))
+	}))
+	defer srv.Close()
+
+	client := &Client{Addr: strings.TrimPrefix(srv.URL, "http://")}
+	entries, err := client.ConfigEntries(context.Background(), "service-defaults")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "web", entries[0].Name())
+	require.Equal(t, "http", entries[0]["Protocol"])
+	require.Equal(t, map[string]string{"consul.hashicorp.com/source-datacenter": "dc1"}, entries[0].Meta())
+}
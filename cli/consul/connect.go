@@ -0,0 +1,48 @@
+package consul
+
+import (
+	"context"
+	"time"
+)
+
+// CARoots are the root certificates of the Connect CA. During a CA rotation
+// both the new, active root and the old roots are listed.
+type CARoots struct {
+	ActiveRootID string
+	TrustDomain  string
+	Roots        []CARoot
+}
+
+// CARoot is a root certificate of the Connect CA.
+type CARoot struct {
+	ID   string
+	Name string
+	// SigningKeyID is the ID of the key that signs the leaf certificates of
+	// the root, as colon separated hex bytes. Leaf certificates have it as
+	// their authority key ID.
+	SigningKeyID string
+	NotAfter     time.Time
+	// RootCert is the PEM encoded root certificate.
+	RootCert string
+	Active   bool
+}
+
+// ActiveRoot returns the root that signs new leaf certificates, or nil if
+// there is none.
+func (r *CARoots) ActiveRoot() *CARoot {
+	for i := range r.Roots {
+		if r.Roots[i].ID == r.ActiveRootID {
+			return &r.Roots[i]
+		}
+	}
+	return nil
+}
+
+// CARoots returns the root certificates of the Connect CA.
+func (c *Client) CARoots(ctx context.Context) (*CARoots, error) {
+	var roots CARoots
+	if err := c.get(ctx, "/v1/connect/ca/roots", "CA roots", &roots); err != nil {
+		return nil, err
+	}
+	return &roots, nil
+}
@@ -0,0 +1,31 @@
+package consul
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCARoots(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/connect/ca/roots", r.URL.Path)
+		w.Write([]byte(`{"ActiveRootID": "b", "TrustDomain": "11111111.consul", "Roots": [
+  {"ID": "a", "Name": "Consul CA Primary Cert", "SigningKeyID": "01:02", "Active": false},
+  {"ID": "b", "Name": "Consul CA Primary Cert", "SigningKeyID": "03:04", "Active": true}]}`))
+	}))
+	defer srv.Close()
+	client := &Client{Addr: strings.TrimPrefix(srv.URL, "http://")}
+
+	roots, err := client.CARoots(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "11111111.consul", roots.TrustDomain)
+	require.Len(t, roots.Roots, 2)
+	require.Equal(t, &roots.Roots[1], roots.ActiveRoot())
+	require.Equal(t, "03:04", roots.ActiveRoot().SigningKeyID)
+
+	require.Nil(t, (&CARoots{ActiveRootID: "c", Roots: roots.Roots}).ActiveRoot())
+}
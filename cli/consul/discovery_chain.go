@@ -0,0 +1,96 @@
+package consul
+
+import (
+	"context"
+	"net/url"
+)
+
+// Types of the nodes of a compiled discovery chain.
+const (
+	DiscoveryGraphNodeTypeRouter   = "router"
+	DiscoveryGraphNodeTypeSplitter = "splitter"
+	DiscoveryGraphNodeTypeResolver = "resolver"
+)
+
+// CompiledDiscoveryChain is the discovery chain of a service compiled from
+// its service router, splitter and resolver config entries. The traffic to
+// the service starts at the start node and passes through the routers and
+// splitters until it reaches a resolver, which picks the target it is sent
+// to.
+type CompiledDiscoveryChain struct {
+	ServiceName string
+	Namespace   string
+	Datacenter  string
+	Protocol    string
+	StartNode   string
+	Nodes       map[string]*DiscoveryGraphNode
+	Targets     map[string]*DiscoveryTarget
+}
+
+// DiscoveryGraphNode is a router, splitter or resolver of a discovery chain.
+type DiscoveryGraphNode struct {
+	Type     string
+	Name     string
+	Routes   []*DiscoveryRoute
+	Splits   []*DiscoverySplit
+	Resolver *DiscoveryResolver
+}
+
+// DiscoveryRoute is a route of a router node.
+type DiscoveryRoute struct {
+	NextNode string
+}
+
+// DiscoverySplit is a split of a splitter node. Weight is the percentage of
+// the traffic of the splitter that goes to the next node.
+type DiscoverySplit struct {
+	Weight   float64
+	NextNode string
+}
+
+// DiscoveryResolver is the resolver of a resolver node. Target is the ID of
+// the target the traffic is sent to, and the failover targets are used when
+// it has no healthy instances.
+type DiscoveryResolver struct {
+	Target   string
+	Failover *DiscoveryFailover
+}
+
+// DiscoveryFailover lists the IDs of the failover targets of a resolver.
+type DiscoveryFailover struct {
+	Targets []string
+}
+
+// DiscoveryTarget is a set of service instances that traffic is sent to.
+// Name is the name of the cluster that Envoy proxies have for the target,
+// which is also its SNI in most cases.
+type DiscoveryTarget struct {
+	ID            string
+	Service       string
+	ServiceSubset string
+	Namespace     string
+	Datacenter    string
+	Peer          string
+	SNI           string
+	Name          string
+}
+
+// ClusterName returns the name of the Envoy cluster of the target.
+func (t *DiscoveryTarget) ClusterName() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.SNI
+}
+
+// DiscoveryChain returns the compiled discovery chain of the service, as it
+// is used by the proxies with the service as an upstream.
+func (c *Client) DiscoveryChain(ctx context.Context, service string) (*CompiledDiscoveryChain, error) {
+	var resp struct {
+		Chain *CompiledDiscoveryChain
+	}
+	if err := c.get(ctx, "/v1/discovery-chain/"+url.PathEscape(service), "discovery chain", &resp); err != nil {
+		return nil, err
+	}
+	return resp.Chain, nil
+}
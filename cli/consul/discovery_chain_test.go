@@ -0,0 +1,39 @@
+package consul
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoveryChain(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/discovery-chain/api", r.URL.Path)
+		w.Write([]byte(`{"Chain": {"ServiceName": "api", "Protocol": "http", "StartNode": "splitter:api",
+  "Nodes": {
+    "splitter:api": {"Type": "splitter", "Name": "api", "Splits": [
+      {"Weight": 90, "NextNode": "resolver:v1.api.default.default.dc1"},
+      {"Weight": 10, "NextNode": "resolver:v2.api.default.default.dc1"}]},
+    "resolver:v1.api.default.default.dc1": {"Type": "resolver", "Resolver": {"Target": "v1.api.default.default.dc1"}}
+  },
+  "Targets": {
+    "v1.api.default.default.dc1": {"ID": "v1.api.default.default.dc1", "SNI": "v1.api.default.dc1.internal.1111.consul", "Name": "v1.api.default.dc1.internal.1111.consul"},
+    "v2.api.default.default.dc1": {"ID": "v2.api.default.default.dc1", "SNI": "v2.api.default.dc1.internal.1111.consul"}
+  }}}`))
+	}))
+	defer srv.Close()
+	client := &Client{Addr: strings.TrimPrefix(srv.URL, "http://")}
+
+	chain, err := client.DiscoveryChain(context.Background(), "api")
+	require.NoError(t, err)
+	require.Equal(t, "splitter:api", chain.StartNode)
+	require.Equal(t, DiscoveryGraphNodeTypeSplitter, chain.Nodes[chain.StartNode].Type)
+	require.Equal(t, 10.0, chain.Nodes[chain.StartNode].Splits[1].Weight)
+	require.Equal(t, "v1.api.default.dc1.internal.1111.consul", chain.Targets["v1.api.default.default.dc1"].ClusterName())
+	// The SNI is used if the target has no name.
+	require.Equal(t, "v2.api.default.dc1.internal.1111.consul", chain.Targets["v2.api.default.default.dc1"].ClusterName())
+}
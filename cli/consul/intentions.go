@@ -0,0 +1,72 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Intention is an intention as returned by the intention match endpoint.
+type Intention struct {
+	SourceName           string
+	SourceNS             string
+	SourcePartition      string
+	SourcePeer           string
+	DestinationName      string
+	DestinationNS        string
+	DestinationPartition string
+	// Action is "allow" or "deny". It is empty for intentions with
+	// permissions.
+	Action      string
+	Permissions []json.RawMessage
+	// Precedence is the order in which intentions are evaluated. Higher
+	// precedence intentions are evaluated first.
+	Precedence int
+}
+
+// IntentionMatch returns the intentions with the service as their
+// destination, ordered by precedence. The namespace and partition are
+// optional.
+func (c *Client) IntentionMatch(ctx context.Context, name, namespace, partition string) ([]Intention, error) {
+	query := url.Values{"by": {"destination"}, "name": {name}}
+	if namespace != "" {
+		query.Set("ns", namespace)
+	}
+	if partition != "" {
+		query.Set("partition", partition)
+	}
+	resp, err := c.do(ctx, http.MethodGet, "/v1/connect/intentions/match?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var matches map[string][]Intention
+	if err := json.NewDecoder(resp.Body).Decode(&matches); err != nil {
+		return nil, fmt.Errorf("invalid intention match response: %s", err)
+	}
+	return matches[name], nil
+}
+
+// IntentionCheck returns whether connections from the source to the
+// destination service are allowed, taking the default ACL policy into account
+// if no intention matches. Services in other namespaces are given as
+// "namespace/name".
+func (c *Client) IntentionCheck(ctx context.Context, source, destination string) (bool, error) {
+	query := url.Values{"source": {source}, "destination": {destination}}
+	resp, err := c.do(ctx, http.MethodGet, "/v1/connect/intentions/check?"+query.Encode(), nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	var check struct {
+		Allowed bool
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&check); err != nil {
+		return false, fmt.Errorf("invalid intention check response: %s", err)
+	}
+	return check.Allowed, nil
+}
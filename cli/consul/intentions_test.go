@@ -0,0 +1,50 @@
+package consul
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntentionMatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/connect/intentions/match", r.URL.Path)
+		require.Equal(t, "destination", r.URL.Query().Get("by"))
+		require.Equal(t, "api", r.URL.Query().Get("name"))
+		require.Equal(t, "team-a", r.URL.Query().Get("ns"))
+		require.False(t, r.URL.Query().Has("partition"))
+		w.Write([]byte(`{"api": [
+  {"SourceName": "web", "SourceNS": "team-a", "DestinationName": "api", "DestinationNS": "team-a", "Action": "allow", "Precedence": 9},
+  {"SourceName": "*", "SourceNS": "*", "DestinationName": "api", "DestinationNS": "team-a", "Permissions": [{"Action": "deny"}], "Precedence": 6}
+]}`))
+	}))
+	defer srv.Close()
+
+	client := &Client{Addr: strings.TrimPrefix(srv.URL, "http://")}
+	intentions, err := client.IntentionMatch(context.Background(), "api", "team-a", "")
+	require.NoError(t, err)
+	require.Len(t, intentions, 2)
+	require.Equal(t, Intention{SourceName: "web", SourceNS: "team-a", DestinationName: "api", DestinationNS: "team-a", Action: "allow", Precedence: 9}, intentions[0])
+	require.Equal(t, "*", intentions[1].SourceName)
+	require.Len(t, intentions[1].Permissions, 1)
+	require.Equal(t, 6, intentions[1].Precedence)
+}
+
+func TestIntentionCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/connect/intentions/check", r.URL.Path)
+		require.Equal(t, "team-a/web", r.URL.Query().Get("source"))
+		require.Equal(t, "api", r.URL.Query().Get("destination"))
+		w.Write([]byte(`{"Allowed": true}`))
+	}))
+	defer srv.Close()
+
+	client := &Client{Addr: strings.TrimPrefix(srv.URL, "http://")}
+	allowed, err := client.IntentionCheck(context.Background(), "team-a/web", "api")
+	require.NoError(t, err)
+	require.True(t, allowed)
+}
@@ -0,0 +1,114 @@
+package consul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// AutopilotHealth is the health of the Raft cluster as reported by autopilot.
+type AutopilotHealth struct {
+	// Healthy is whether all servers are healthy.
+	Healthy bool
+	// FailureTolerance is the number of servers that can fail without losing
+	// quorum.
+	FailureTolerance int
+	Servers          []ServerHealth
+}
+
+// ServerHealth is the health of a single server as reported by autopilot.
+type ServerHealth struct {
+	ID      string
+	Name    string
+	Address string
+	Leader  bool
+	Voter   bool
+	// Healthy is whether the server is alive, in contact with the leader and
+	// caught up with its Raft log.
+	Healthy bool
+	// LastIndex is the index of the last Raft log entry of the server.
+	LastIndex uint64
+}
+
+// AutopilotHealth returns the health of the Raft cluster.
+func (c *Client) AutopilotHealth(ctx context.Context) (*AutopilotHealth, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/v1/operator/autopilot/health", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Consul responds with 429 Too Many Requests if the cluster is unhealthy,
+	// which is still a valid health report.
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusTooManyRequests {
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var health AutopilotHealth
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return nil, fmt.Errorf("invalid autopilot health response: %s", err)
+	}
+	return &health, nil
+}
+
+// Keyring is the gossip encryption keyring of one gossip pool.
+type Keyring struct {
+	// WAN is whether this is the keyring of the WAN pool of the servers.
+	WAN        bool
+	Datacenter string
+	// Keys maps the installed keys to the number of agents that have them.
+	Keys map[string]int
+	// PrimaryKeys maps the primary keys to the number of agents that use them.
+	// It is empty for Consul versions that don't report the primary keys.
+	PrimaryKeys map[string]int
+	// NumNodes is the number of agents in the pool.
+	NumNodes int
+}
+
+// KeyringList returns the gossip keyrings of the LAN and WAN pools.
+func (c *Client) KeyringList(ctx context.Context) ([]Keyring, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/v1/operator/keyring", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var keyrings []Keyring
+	if err := json.NewDecoder(resp.Body).Decode(&keyrings); err != nil {
+		return nil, fmt.Errorf("invalid keyring response: %s", err)
+	}
+	return keyrings, nil
+}
+
+// KeyringInstall installs the gossip key on all agents.
+func (c *Client) KeyringInstall(ctx context.Context, key string) error {
+	return c.keyringOp(ctx, http.MethodPost, key)
+}
+
+// KeyringUse makes the installed gossip key the primary key of all agents.
+func (c *Client) KeyringUse(ctx context.Context, key string) error {
+	return c.keyringOp(ctx, http.MethodPut, key)
+}
+
+// KeyringRemove removes the gossip key from all agents. The primary key
+// cannot be removed.
+func (c *Client) KeyringRemove(ctx context.Context, key string) error {
+	return c.keyringOp(ctx, http.MethodDelete, key)
+}
+
+func (c *Client) keyringOp(ctx context.Context, method, key string) error {
+	body, err := json.Marshal(map[string]string{"Key": key})
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, method, "/v1/operator/keyring", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
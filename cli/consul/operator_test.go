@@ -0,0 +1,111 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutopilotHealth(t *testing.T) {
+	cases := map[string]struct {
+		status int
+		body   string
+		exp    *AutopilotHealth
+		expErr string
+	}{
+		"healthy": {
+			status: http.StatusOK,
+			body: `{"Healthy": true, "FailureTolerance": 1, "Servers": [
+  {"ID": "a", "Name": "consul-server-0", "Address": "10.0.0.1:8300", "Leader": true, "Voter": true, "Healthy": true, "LastIndex": 10},
+  {"ID": "b", "Name": "consul-server-1", "Address": "10.0.0.2:8300", "Voter": true, "Healthy": true, "LastIndex": 10}
+]}`,
+			exp: &AutopilotHealth{
+				Healthy:          true,
+				FailureTolerance: 1,
+				Servers: []ServerHealth{
+					{ID: "a", Name: "consul-server-0", Address: "10.0.0.1:8300", Leader: true, Voter: true, Healthy: true, LastIndex: 10},
+					{ID: "b", Name: "consul-server-1", Address: "10.0.0.2:8300", Voter: true, Healthy: true, LastIndex: 10},
+				},
+			},
+		},
+		"unhealthy": {
+			status: http.StatusTooManyRequests,
+			body: `{"Healthy": false, "FailureTolerance": 0, "Servers": [
+  {"ID": "a", "Name": "consul-server-0", "Address": "10.0.0.1:8300", "Leader": true, "Voter": true, "Healthy": false, "LastIndex": 3}
+]}`,
+			exp: &AutopilotHealth{
+				Servers: []ServerHealth{
+					{ID: "a", Name: "consul-server-0", Address: "10.0.0.1:8300", Leader: true, Voter: true, LastIndex: 3},
+				},
+			},
+		},
+		"permission denied": {
+			status: http.StatusForbidden,
+			body:   "Permission denied",
+			expErr: "unexpected status 403 Forbidden: Permission denied",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "/v1/operator/autopilot/health", r.URL.Path)
+				w.WriteHeader(c.status)
+				w.Write([]byte(c.body))
+			}))
+			defer srv.Close()
+
+			client := &Client{Addr: strings.TrimPrefix(srv.URL, "http://")}
+			health, err := client.AutopilotHealth(context.Background())
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, health)
+		})
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	keys := map[string]int{"old": 3}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/operator/keyring", r.URL.Path)
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`[{"WAN": false, "Datacenter": "dc1", "Keys": {"old": 3, "new": 3}, "PrimaryKeys": {"new": 3}, "NumNodes": 3}]`))
+			return
+		}
+		var body struct{ Key string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		switch r.Method {
+		case http.MethodPost:
+			keys[body.Key] = 3
+		case http.MethodDelete:
+			delete(keys, body.Key)
+		}
+	}))
+	defer srv.Close()
+
+	client := &Client{Addr: strings.TrimPrefix(srv.URL, "http://")}
+	require.NoError(t, client.KeyringInstall(context.Background(), "new"))
+	require.Equal(t, map[string]int{"old": 3, "new": 3}, keys)
+	require.NoError(t, client.KeyringUse(context.Background(), "new"))
+	require.NoError(t, client.KeyringRemove(context.Background(), "old"))
+	require.Equal(t, map[string]int{"new": 3}, keys)
+
+	keyrings, err := client.KeyringList(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []Keyring{
+		{
+			Datacenter:  "dc1",
+			Keys:        map[string]int{"old": 3, "new": 3},
+			PrimaryKeys: map[string]int{"new": 3},
+			NumNodes:    3,
+		},
+	}, keyrings)
+}
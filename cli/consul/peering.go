@@ -0,0 +1,75 @@
+package consul
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// Peering is a cluster peering. Peerings are supported by Consul 1.13+.
+type Peering struct {
+	ID   string
+	Name string
+	// State is e.g. "PENDING", "ESTABLISHING", "ACTIVE" or "FAILING".
+	State string
+	// PeerID is the ID of the peering in the peer cluster.
+	PeerID              string
+	PeerServerName      string
+	PeerServerAddresses []string
+	// StreamStatus is the status of the replication stream with the peer. It
+	// is only reported by Consul 1.14+.
+	StreamStatus *PeeringStreamStatus
+}
+
+// PeeringStreamStatus is the status of the replication stream of a peering.
+type PeeringStreamStatus struct {
+	ImportedServices []string
+	ExportedServices []string
+	LastHeartbeat    *time.Time
+	LastReceive      *time.Time
+	LastSend         *time.Time
+}
+
+// Peering returns the peering with the name.
+func (c *Client) Peering(ctx context.Context, name string) (*Peering, error) {
+	var peering Peering
+	if err := c.get(ctx, "/v1/peering/"+url.PathEscape(name), "peering", &peering); err != nil {
+		return nil, err
+	}
+	return &peering, nil
+}
+
+// Peerings returns all peerings.
+func (c *Client) Peerings(ctx context.Context) ([]Peering, error) {
+	var peerings []Peering
+	if err := c.get(ctx, "/v1/peerings", "peerings", &peerings); err != nil {
+		return nil, err
+	}
+	return peerings, nil
+}
+
+// PeerServices returns the sorted names of the services imported from the
+// peer.
+func (c *Client) PeerServices(ctx context.Context, peer string) ([]string, error) {
+	var services map[string][]string
+	if err := c.get(ctx, "/v1/catalog/services?peer="+url.QueryEscape(peer), "catalog services", &services); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// HealthyInstances returns the number of instances of the service whose
+// health checks are passing.
+func (c *Client) HealthyInstances(ctx context.Context, service string) (int, error) {
+	var instances []interface{}
+	if err := c.get(ctx, "/v1/health/service/"+url.PathEscape(service)+"?passing", "service health", &instances); err != nil {
+		return 0, err
+	}
+	return len(instances), nil
+}
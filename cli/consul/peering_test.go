@@ -0,0 +1,68 @@
+package consul
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeering(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/peering/dc2":
+			w.Write([]byte(`{"ID": "a", "Name": "dc2", "State": "ACTIVE", "PeerID": "b", "PeerServerAddresses": ["10.0.0.5:8443"],
+  "StreamStatus": {"ImportedServices": ["api"], "ExportedServices": ["web"], "LastHeartbeat": "2026-10-15T10:00:00Z"}}`))
+		case "/v1/peerings":
+			w.Write([]byte(`[{"ID": "b", "Name": "dc1", "State": "ACTIVE", "PeerID": "a"}]`))
+		case "/v1/catalog/services":
+			require.Equal(t, "dc2", r.URL.Query().Get("peer"))
+			w.Write([]byte(`{"web": [], "api": ["v2"]}`))
+		case "/v1/health/service/mesh-gateway":
+			_, passing := r.URL.Query()["passing"]
+			require.True(t, passing)
+			w.Write([]byte(`[{}, {}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("Peering not found"))
+		}
+	}))
+	defer srv.Close()
+	client := &Client{Addr: strings.TrimPrefix(srv.URL, "http://")}
+	ctx := context.Background()
+
+	peering, err := client.Peering(ctx, "dc2")
+	require.NoError(t, err)
+	heartbeat := time.Date(2026, 10, 15, 10, 0, 0, 0, time.UTC)
+	require.Equal(t, &Peering{
+		ID:                  "a",
+		Name:                "dc2",
+		State:               "ACTIVE",
+		PeerID:              "b",
+		PeerServerAddresses: []string{"10.0.0.5:8443"},
+		StreamStatus: &PeeringStreamStatus{
+			ImportedServices: []string{"api"},
+			ExportedServices: []string{"web"},
+			LastHeartbeat:    &heartbeat,
+		},
+	}, peering)
+
+	peerings, err := client.Peerings(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []Peering{{ID: "b", Name: "dc1", State: "ACTIVE", PeerID: "a"}}, peerings)
+
+	services, err := client.PeerServices(ctx, "dc2")
+	require.NoError(t, err)
+	require.Equal(t, []string{"api", "web"}, services)
+
+	healthy, err := client.HealthyInstances(ctx, "mesh-gateway")
+	require.NoError(t, err)
+	require.Equal(t, 2, healthy)
+
+	_, err = client.Peering(ctx, "missing")
+	require.EqualError(t, err, "unexpected status 404 Not Found: Peering not found")
+}
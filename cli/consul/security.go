@@ -0,0 +1,160 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const (
+	// GlobalManagementPolicyID is the ID of the builtin global-management policy.
+	GlobalManagementPolicyID = "00000000-0000-0000-0000-000000000001"
+	// AnonymousTokenID is the accessor ID of the builtin anonymous token, which
+	// is used for requests without a token.
+	AnonymousTokenID = "00000000-0000-0000-0000-000000000002"
+)
+
+// SecurityConfig is the security related configuration of a Consul agent.
+type SecurityConfig struct {
+	ACLsEnabled bool
+	// ACLDefaultPolicy is "allow" or "deny".
+	ACLDefaultPolicy string
+	// VerifyIncoming is whether the agent requires clients of its RPC
+	// interface to present a certificate signed by the CA.
+	VerifyIncoming bool
+	// VerifyOutgoing is whether the agent uses TLS for outgoing RPC
+	// connections and verifies the certificate of the server.
+	VerifyOutgoing bool
+}
+
+// SecurityConfig returns the security related configuration of the agent.
+func (c *Client) SecurityConfig(ctx context.Context) (*SecurityConfig, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/v1/agent/self", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var self struct {
+		DebugConfig struct {
+			ACLsEnabled bool
+			// ACLDefaultPolicy is reported by Consul versions before 1.11.
+			ACLDefaultPolicy    string
+			ACLResolverSettings struct {
+				ACLDefaultPolicy string
+			}
+			// The TLS settings are reported at the top level by Consul
+			// versions before 1.12.
+			VerifyIncoming    bool
+			VerifyIncomingRPC bool
+			VerifyOutgoing    bool
+			TLS               struct {
+				InternalRPC struct {
+					VerifyIncoming bool
+					VerifyOutgoing bool
+				}
+			}
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&self); err != nil {
+		return nil, fmt.Errorf("invalid agent self response: %s", err)
+	}
+	debug := self.DebugConfig
+	config := &SecurityConfig{
+		ACLsEnabled:      debug.ACLsEnabled,
+		ACLDefaultPolicy: debug.ACLResolverSettings.ACLDefaultPolicy,
+		VerifyIncoming:   debug.VerifyIncoming || debug.VerifyIncomingRPC || debug.TLS.InternalRPC.VerifyIncoming,
+		VerifyOutgoing:   debug.VerifyOutgoing || debug.TLS.InternalRPC.VerifyOutgoing,
+	}
+	if config.ACLDefaultPolicy == "" {
+		config.ACLDefaultPolicy = debug.ACLDefaultPolicy
+	}
+	if config.ACLDefaultPolicy == "" {
+		config.ACLDefaultPolicy = "allow"
+	}
+	return config, nil
+}
+
+// ACLLink is a reference from an ACL token or role to a policy or role.
+type ACLLink struct {
+	ID   string
+	Name string
+}
+
+// ACLToken is an ACL token without its secret.
+type ACLToken struct {
+	AccessorID  string
+	Description string
+	Policies    []ACLLink
+	Roles       []ACLLink
+	Local       bool
+}
+
+// ACLRole is a named set of ACL policies.
+type ACLRole struct {
+	ID       string
+	Name     string
+	Policies []ACLLink
+}
+
+// ACLPolicy is an ACL policy.
+type ACLPolicy struct {
+	ID          string
+	Name        string
+	Description string
+	// Rules are the HCL or JSON rules of the policy.
+	Rules string
+}
+
+// ACLToken returns the token with the accessor ID.
+func (c *Client) ACLToken(ctx context.Context, accessorID string) (*ACLToken, error) {
+	var token ACLToken
+	if err := c.get(ctx, "/v1/acl/token/"+url.PathEscape(accessorID), "ACL token", &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ACLTokens returns all ACL tokens.
+func (c *Client) ACLTokens(ctx context.Context) ([]ACLToken, error) {
+	var tokens []ACLToken
+	if err := c.get(ctx, "/v1/acl/tokens", "ACL tokens", &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// ACLRoles returns all ACL roles.
+func (c *Client) ACLRoles(ctx context.Context) ([]ACLRole, error) {
+	var roles []ACLRole
+	if err := c.get(ctx, "/v1/acl/roles", "ACL roles", &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// ACLPolicy returns the policy with the ID.
+func (c *Client) ACLPolicy(ctx context.Context, id string) (*ACLPolicy, error) {
+	var policy ACLPolicy
+	if err := c.get(ctx, "/v1/acl/policy/"+url.PathEscape(id), "ACL policy", &policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// get sends a GET request and decodes the JSON response into v. what
+// describes the response in errors.
+func (c *Client) get(ctx context.Context, path, what string, v interface{}) error {
+	resp, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid %s response: %s", what, err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package consul
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityConfig(t *testing.T) {
+	cases := map[string]struct {
+		body string
+		exp  *SecurityConfig
+	}{
+		"Consul 1.12+": {
+			body: `{"DebugConfig": {"ACLsEnabled": true, "ACLResolverSettings": {"ACLDefaultPolicy": "deny"},
+  "TLS": {"InternalRPC": {"VerifyIncoming": true, "VerifyOutgoing": true}}}}`,
+			exp: &SecurityConfig{ACLsEnabled: true, ACLDefaultPolicy: "deny", VerifyIncoming: true, VerifyOutgoing: true},
+		},
+		"Consul 1.11": {
+			body: `{"DebugConfig": {"ACLsEnabled": true, "ACLResolverSettings": {"ACLDefaultPolicy": "deny"},
+  "VerifyIncomingRPC": true, "VerifyOutgoing": true}}`,
+			exp: &SecurityConfig{ACLsEnabled: true, ACLDefaultPolicy: "deny", VerifyIncoming: true, VerifyOutgoing: true},
+		},
+		"Consul 1.10": {
+			body: `{"DebugConfig": {"ACLsEnabled": true, "ACLDefaultPolicy": "deny", "VerifyIncoming": true}}`,
+			exp:  &SecurityConfig{ACLsEnabled: true, ACLDefaultPolicy: "deny", VerifyIncoming: true},
+		},
+		"ACLs disabled": {
+			body: `{"DebugConfig": {"ACLsEnabled": false}}`,
+			exp:  &SecurityConfig{ACLDefaultPolicy: "allow"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "/v1/agent/self", r.URL.Path)
+				w.Write([]byte(c.body))
+			}))
+			defer srv.Close()
+
+			client := &Client{Addr: strings.TrimPrefix(srv.URL, "http://")}
+			config, err := client.SecurityConfig(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, c.exp, config)
+		})
+	}
+}
+
+func TestACL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "secret", r.Header.Get(tokenHeader))
+		switch r.URL.Path {
+		case "/v1/acl/token/" + AnonymousTokenID:
+			w.Write([]byte(`{"AccessorID": "00000000-0000-0000-0000-000000000002", "Description": "Anonymous Token",
+  "Policies": [{"ID": "a", "Name": "anonymous-token-policy"}]}`))
+		case "/v1/acl/tokens":
+			w.Write([]byte(`[{"AccessorID": "b", "Description": "Bootstrap Token",
+  "Policies": [{"ID": "00000000-0000-0000-0000-000000000001", "Name": "global-management"}]},
+  {"AccessorID": "c", "Roles": [{"ID": "r", "Name": "admin"}], "Local": true}]`))
+		case "/v1/acl/roles":
+			w.Write([]byte(`[{"ID": "r", "Name": "admin", "Policies": [{"ID": "00000000-0000-0000-0000-000000000001", "Name": "global-management"}]}]`))
+		case "/v1/acl/policy/a":
+			w.Write([]byte(`{"ID": "a", "Name": "anonymous-token-policy", "Rules": "node_prefix \"\" { policy = \"read\" }"}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("Permission denied"))
+		}
+	}))
+	defer srv.Close()
+	client := &Client{Addr: strings.TrimPrefix(srv.URL, "http://"), Token: "secret"}
+	ctx := context.Background()
+
+	token, err := client.ACLToken(ctx, AnonymousTokenID)
+	require.NoError(t, err)
+	require.Equal(t, &ACLToken{
+		AccessorID:  AnonymousTokenID,
+		Description: "Anonymous Token",
+		Policies:    []ACLLink{{ID: "a", Name: "anonymous-token-policy"}},
+	}, token)
+
+	tokens, err := client.ACLTokens(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []ACLToken{
+		{AccessorID: "b", Description: "Bootstrap Token", Policies: []ACLLink{{ID: GlobalManagementPolicyID, Name: "global-management"}}},
+		{AccessorID: "c", Roles: []ACLLink{{ID: "r", Name: "admin"}}, Local: true},
+	}, tokens)
+
+	roles, err := client.ACLRoles(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []ACLRole{{ID: "r", Name: "admin", Policies: []ACLLink{{ID: GlobalManagementPolicyID, Name: "global-management"}}}}, roles)
+
+	policy, err := client.ACLPolicy(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, &ACLPolicy{ID: "a", Name: "anonymous-token-policy", Rules: `node_prefix "" { policy = "read" }`}, policy)
+
+	_, err = client.ACLPolicy(ctx, "missing")
+	require.EqualError(t, err, "unexpected status 403 Forbidden: Permission denied")
+}
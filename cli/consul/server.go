@@ -0,0 +1,132 @@
+package consul
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// ServerLabelSelector selects the Consul server pods installed by the chart.
+	ServerLabelSelector = "app=consul,chart=consul-helm,component=server"
+	// HTTPPort and HTTPSPort are the ports of the servers' HTTP API.
+	HTTPPort  = 8500
+	HTTPSPort = 8501
+
+	// apiProxyPath is the path the API proxy of the chart passes the reads of
+	// the HTTP API through at, relative to the Kubernetes API server.
+	apiProxyPath = "/apis/api.consul.hashicorp.com/v1alpha1/consul"
+)
+
+// ServerOpener returns a client for the HTTP API of the server pod and a
+// function that closes the connection.
+type ServerOpener func(pod *corev1.Pod) (*Client, func(), error)
+
+// ServerConfig describes how to reach the HTTP API of Consul server pods.
+type ServerConfig struct {
+	KubeClient kubernetes.Interface
+	RestConfig *rest.Config
+
+	// Token is the ACL token sent with every request. It is optional.
+	Token string
+	// CAFile is the path to the CA certificate of the servers. If set, the
+	// servers are called over HTTPS instead of HTTP.
+	CAFile string
+}
+
+// Open port forwards to the HTTP API of the server pod and returns a client
+// for it and a function that stops forwarding.
+func (s ServerConfig) Open(pod *corev1.Pod) (*Client, func(), error) {
+	client := &Client{Scheme: "http", Token: s.Token}
+	port := HTTPPort
+	if s.CAFile != "" {
+		caPEM, err := os.ReadFile(s.CAFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading CA file: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, nil, fmt.Errorf("no certificates found in CA file %s", s.CAFile)
+		}
+		client.Scheme = "https"
+		client.HTTPClient = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		}
+		port = HTTPSPort
+	}
+
+	pf := common.PortForward{
+		Namespace:  pod.Namespace,
+		PodName:    pod.Name,
+		RemotePort: port,
+		KubeClient: s.KubeClient,
+		RestConfig: s.RestConfig,
+	}
+	addr, err := pf.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	client.Addr = addr
+	return client, pf.Close, nil
+}
+
+// Opener returns open if it is set, e.g. by tests to fake the servers, and
+// Open otherwise.
+func (s ServerConfig) Opener(open ServerOpener) ServerOpener {
+	if open != nil {
+		return open
+	}
+	return s.Open
+}
+
+// OpenRunningServer returns a client for the HTTP API of a running Consul
+// server in the namespace and a function that closes the connection.
+func OpenRunningServer(ctx context.Context, k8s kubernetes.Interface, namespace string, open ServerOpener) (*Client, func(), error) {
+	pods, err := k8s.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: ServerLabelSelector})
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase == corev1.PodRunning {
+			return open(pod)
+		}
+	}
+	return nil, nil, fmt.Errorf("no running Consul server pods found in namespace %q", namespace)
+}
+
+// OpenAPIProxy returns a client that calls the HTTP API of the servers through
+// the API proxy the chart deploys with apiProxy.enabled. Requests are sent to
+// the Kubernetes API server with the credentials of the config, so no port
+// forward or ACL token is needed, but only reads are served.
+func OpenAPIProxy(config *rest.Config) (*Client, error) {
+	transport, err := rest.TransportFor(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the Kubernetes transport: %s", err)
+	}
+	host := config.Host
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Kubernetes API server address %q: %s", config.Host, err)
+	}
+	return &Client{
+		Addr:       u.Host,
+		Scheme:     u.Scheme,
+		PathPrefix: strings.TrimSuffix(u.Path, "/") + apiProxyPath,
+		HTTPClient: &http.Client{Transport: transport},
+	}, nil
+}
@@ -0,0 +1,65 @@
+package consul
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func TestOpenAPIProxy(t *testing.T) {
+	// The fake Kubernetes API server is served under a path, like behind a
+	// load balancer, which the client must keep.
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/k8s/apis/api.consul.hashicorp.com/v1alpha1/consul/v1/catalog/services", r.URL.Path)
+		require.Equal(t, "peer=dc2", r.URL.RawQuery)
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"web":["v1"]}`))
+	}))
+	defer srv.Close()
+
+	client, err := OpenAPIProxy(&rest.Config{
+		Host:            srv.URL + "/k8s/",
+		BearerToken:     "secret",
+		TLSClientConfig: rest.TLSClientConfig{Insecure: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "https", client.Scheme)
+
+	services, err := client.CatalogServices(context.Background(), CatalogQuery{Peer: "dc2"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"web"}, services)
+}
+
+func TestOpenRunningServer(t *testing.T) {
+	server := func(name string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "consul",
+				Labels:    map[string]string{"app": "consul", "chart": "consul-helm", "component": "server"},
+			},
+			Status: corev1.PodStatus{Phase: phase},
+		}
+	}
+	var opened string
+	open := ServerConfig{}.Opener(func(pod *corev1.Pod) (*Client, func(), error) {
+		opened = pod.Name
+		return &Client{}, func() {}, nil
+	})
+
+	k8s := fake.NewSimpleClientset(server("consul-server-0", corev1.PodPending), server("consul-server-1", corev1.PodRunning))
+	_, _, err := OpenRunningServer(context.Background(), k8s, "consul", open)
+	require.NoError(t, err)
+	require.Equal(t, "consul-server-1", opened)
+
+	k8s = fake.NewSimpleClientset(server("consul-server-0", corev1.PodPending))
+	_, _, err = OpenRunningServer(context.Background(), k8s, "consul", open)
+	require.EqualError(t, err, `no running Consul server pods found in namespace "consul"`)
+}
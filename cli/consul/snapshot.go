@@ -0,0 +1,27 @@
+package consul
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// SaveSnapshot requests a snapshot of the cluster's state from the leader. The
+// snapshot is streamed from the returned reader, which must be closed.
+func (c *Client) SaveSnapshot(ctx context.Context) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/v1/snapshot", nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// RestoreSnapshot replaces the cluster's state with the snapshot read from r.
+func (c *Client) RestoreSnapshot(ctx context.Context, r io.Reader) error {
+	resp, err := c.do(ctx, http.MethodPut, "/v1/snapshot", r)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,43 @@
+package consul
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveAndRestoreSnapshot(t *testing.T) {
+	var restored string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/snapshot", r.URL.Path)
+		require.Equal(t, "secret", r.Header.Get(tokenHeader))
+		switch r.Method {
+		case http.MethodGet:
+			w.Write([]byte("snapshot-data"))
+		case http.MethodPut:
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			restored = string(body)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer srv.Close()
+
+	client := &Client{Addr: strings.TrimPrefix(srv.URL, "http://"), Token: "secret"}
+
+	snap, err := client.SaveSnapshot(context.Background())
+	require.NoError(t, err)
+	data, err := io.ReadAll(snap)
+	require.NoError(t, err)
+	require.NoError(t, snap.Close())
+	require.Equal(t, "snapshot-data", string(data))
+
+	require.NoError(t, client.RestoreSnapshot(context.Background(), strings.NewReader("snapshot-data")))
+	require.Equal(t, "snapshot-data", restored)
+}
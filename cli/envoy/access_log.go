@@ -0,0 +1,87 @@
+package envoy
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// AccessLogEntry is a request logged by an Envoy access log.
+type AccessLogEntry struct {
+	StartTime     string
+	Method        string
+	Path          string
+	Protocol      string
+	ResponseCode  string
+	ResponseFlags string
+	// Duration is the duration of the request in milliseconds.
+	Duration     string
+	Authority    string
+	UpstreamHost string
+}
+
+// defaultAccessLogFormat matches lines in Envoy's default access log format:
+//
+//	[%START_TIME%] "%REQ(:METHOD)% %REQ(X-ENVOY-ORIGINAL-PATH?:PATH)% %PROTOCOL%"
+//	%RESPONSE_CODE% %RESPONSE_FLAGS% %BYTES_RECEIVED% %BYTES_SENT% %DURATION%
+//	%RESP(X-ENVOY-UPSTREAM-SERVICE-TIME)% "%REQ(X-FORWARDED-FOR)%" "%REQ(USER-AGENT)%"
+//	"%REQ(X-REQUEST-ID)%" "%REQ(:AUTHORITY)%" "%UPSTREAM_HOST%"
+var defaultAccessLogFormat = regexp.MustCompile(
+	`^\[([^\]]+)\] "(\S+) (\S+) (\S+)" (\S+) (\S+) \S+ \S+ (\S+) \S+ "[^"]*" "[^"]*" "[^"]*" "([^"]*)" "([^"]*)"`)
+
+// ParseAccessLog parses a line of an access log in Envoy's default format or
+// in JSON with the command operators as keys in snake case, e.g.
+// "response_code", which is the format Consul uses for JSON access logs. It
+// returns false for lines that are not access log entries, such as the
+// application logs of Envoy that are written to the same stream.
+func ParseAccessLog(line string) (AccessLogEntry, bool) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "{") {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			return AccessLogEntry{}, false
+		}
+		// Envoy's application logs in JSON don't have a response code.
+		if _, ok := fields["response_code"]; !ok {
+			return AccessLogEntry{}, false
+		}
+		field := func(key string) string {
+			switch v := fields[key].(type) {
+			case nil:
+				return "-"
+			case string:
+				return v
+			default:
+				return fmt.Sprint(v)
+			}
+		}
+		return AccessLogEntry{
+			StartTime:     field("start_time"),
+			Method:        field("method"),
+			Path:          field("path"),
+			Protocol:      field("protocol"),
+			ResponseCode:  field("response_code"),
+			ResponseFlags: field("response_flags"),
+			Duration:      field("duration"),
+			Authority:     field("authority"),
+			UpstreamHost:  field("upstream_host"),
+		}, true
+	}
+
+	m := defaultAccessLogFormat.FindStringSubmatch(line)
+	if m == nil {
+		return AccessLogEntry{}, false
+	}
+	return AccessLogEntry{
+		StartTime:     m[1],
+		Method:        m[2],
+		Path:          m[3],
+		Protocol:      m[4],
+		ResponseCode:  m[5],
+		ResponseFlags: m[6],
+		Duration:      m[7],
+		Authority:     m[8],
+		UpstreamHost:  m[9],
+	}, true
+}
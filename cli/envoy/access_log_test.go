@@ -0,0 +1,75 @@
+package envoy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAccessLog(t *testing.T) {
+	cases := map[string]struct {
+		line     string
+		expEntry AccessLogEntry
+		expOK    bool
+	}{
+		"default format": {
+			line: `[2022-04-20T10:15:32.123Z] "GET /api/v1/orders HTTP/1.1" 200 - 0 512 13 12 "-" "curl/7.79.1" "6a9f3c2e-8d1b-4f6a-9c3e-2b7d5e1f0a4c" "api:8080" "10.0.0.7:20000"`,
+			expEntry: AccessLogEntry{
+				StartTime:     "2022-04-20T10:15:32.123Z",
+				Method:        "GET",
+				Path:          "/api/v1/orders",
+				Protocol:      "HTTP/1.1",
+				ResponseCode:  "200",
+				ResponseFlags: "-",
+				Duration:      "13",
+				Authority:     "api:8080",
+				UpstreamHost:  "10.0.0.7:20000",
+			},
+			expOK: true,
+		},
+		"TCP connection in the default format": {
+			line: `[2022-04-20T10:15:32.123Z] "- - -" 0 UF 0 0 1 - "-" "-" "-" "-" "10.0.0.7:20000"`,
+			expEntry: AccessLogEntry{
+				StartTime:     "2022-04-20T10:15:32.123Z",
+				Method:        "-",
+				Path:          "-",
+				Protocol:      "-",
+				ResponseCode:  "0",
+				ResponseFlags: "UF",
+				Duration:      "1",
+				Authority:     "-",
+				UpstreamHost:  "10.0.0.7:20000",
+			},
+			expOK: true,
+		},
+		"JSON": {
+			line: `{"start_time":"2022-04-20T10:15:32.123Z","method":"POST","path":"/orders","protocol":"HTTP/2","response_code":503,"response_flags":"UH","duration":2,"authority":"api","upstream_host":null}`,
+			expEntry: AccessLogEntry{
+				StartTime:     "2022-04-20T10:15:32.123Z",
+				Method:        "POST",
+				Path:          "/orders",
+				Protocol:      "HTTP/2",
+				ResponseCode:  "503",
+				ResponseFlags: "UH",
+				Duration:      "2",
+				Authority:     "api",
+				UpstreamHost:  "-",
+			},
+			expOK: true,
+		},
+		"Envoy application log": {
+			line: `[2022-04-20 10:15:32.123][1][info][upstream] [source/common/upstream/cds_api_helper.cc:30] cds: add 3 cluster(s), remove 0 cluster(s)`,
+		},
+		"Envoy application log in JSON": {
+			line: `{"level":"info","message":"cds: add 3 cluster(s)"}`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			entry, ok := ParseAccessLog(c.line)
+			require.Equal(t, c.expOK, ok)
+			require.Equal(t, c.expEntry, entry)
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package envoy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Options describe the environment of the proxy that is not part of its
+// configuration dump.
+type Options struct {
+	// TransparentProxy is whether traffic of the proxy's pod is redirected to
+	// the proxy. It is nil if unknown, e.g. for dumps read from a file, in
+	// which case the rules that depend on it are skipped.
+	TransparentProxy *bool
+}
+
+// Warning is a known problematic pattern found in the configuration.
+type Warning struct {
+	// Rule is the name of the rule that found the pattern.
+	Rule string
+	// Message describes where the pattern was found and its effect.
+	Message string
+	// Link points to documentation on how to fix the pattern.
+	Link string
+}
+
+// rule checks a configuration dump for a problematic pattern and returns a
+// message for every occurrence.
+type rule struct {
+	name  string
+	link  string
+	check func(dump *ConfigDump, opts Options) []string
+}
+
+// rules are run in order by Analyze.
+var rules = []rule{
+	{
+		name:  "deprecated filters",
+		link:  "https://www.envoyproxy.io/docs/envoy/latest/faq/api/envoy_v3",
+		check: checkDeprecatedFilters,
+	},
+	{
+		name:  "SAN matchers",
+		link:  "https://www.consul.io/docs/connect/proxies/envoy#escape-hatch-overrides",
+		check: checkSANMatchers,
+	},
+	{
+		name:  "original destination",
+		link:  "https://www.consul.io/docs/k8s/connect/transparent-proxy",
+		check: checkOriginalDst,
+	},
+	{
+		name:  "filter chains",
+		link:  "https://www.consul.io/docs/connect/proxies/envoy#escape-hatch-overrides",
+		check: checkFilterChains,
+	},
+}
+
+// Analyze runs every rule against the configuration dump and returns the
+// warnings they found.
+func Analyze(dump *ConfigDump, opts Options) []Warning {
+	var warnings []Warning
+	for _, r := range rules {
+		for _, m := range r.check(dump, opts) {
+			warnings = append(warnings, Warning{Rule: r.name, Message: m, Link: r.link})
+		}
+	}
+	return warnings
+}
+
+// v2Removal is the Envoy version that no longer supports the v2 API.
+var v2Removal = semver.MustParse("1.18.0")
+
+// v2TypeURL matches the type URLs of the v2 API, e.g.
+// "type.googleapis.com/envoy.config.filter.network.tcp_proxy.v2.TcpProxy".
+var v2TypeURL = regexp.MustCompile(`/envoy\.(api\.v2\.|config\.filter\.|.*\.v2(alpha\d*)?\.)`)
+
+// legacyFilterNames are the filter names that were deprecated in Envoy 1.14
+// in favor of the canonical names.
+var legacyFilterNames = map[string]string{
+	"envoy.http_connection_manager": "envoy.filters.network.http_connection_manager",
+	"envoy.tcp_proxy":               "envoy.filters.network.tcp_proxy",
+	"envoy.router":                  "envoy.filters.http.router",
+	"envoy.cors":                    "envoy.filters.http.cors",
+	"envoy.grpc_web":                "envoy.filters.http.grpc_web",
+	"envoy.lua":                     "envoy.filters.http.lua",
+	"envoy.listener.tls_inspector":  "envoy.filters.listener.tls_inspector",
+	"envoy.listener.http_inspector": "envoy.filters.listener.http_inspector",
+	"envoy.listener.original_dst":   "envoy.filters.listener.original_dst",
+	"envoy.listener.proxy_protocol": "envoy.filters.listener.proxy_protocol",
+}
+
+// filterLists are the keys of the lists of filters in listeners.
+var filterLists = map[string]bool{
+	"filters":          true,
+	"listener_filters": true,
+	"http_filters":     true,
+}
+
+// checkDeprecatedFilters reports types of the v2 API and filter names that
+// have been replaced by the canonical names.
+func checkDeprecatedFilters(dump *ConfigDump, _ Options) []string {
+	support := "which is not supported by Envoy 1.18 and later"
+	if dump.Version != nil && !dump.Version.LessThan(v2Removal) {
+		support = fmt.Sprintf("which is not supported by Envoy %s", dump.Version)
+	}
+
+	var messages []string
+	report := func(kind string, resource map[string]interface{}) {
+		walk(resource, "", func(key string, obj map[string]interface{}) {
+			if typeURL, ok := obj["@type"].(string); ok && v2TypeURL.MatchString(typeURL) {
+				messages = append(messages, fmt.Sprintf("%s %q uses the v2 API type %s, %s.", kind, name(resource), typeURL, support))
+			}
+			if !filterLists[key] {
+				return
+			}
+			if canonical, ok := legacyFilterNames[name(obj)]; ok {
+				messages = append(messages, fmt.Sprintf("%s %q uses the deprecated filter name %s. Use %s instead.", kind, name(resource), name(obj), canonical))
+			}
+		})
+	}
+	for _, l := range dump.Listeners {
+		report("Listener", l)
+	}
+	for _, c := range dump.Clusters {
+		report("Cluster", c)
+	}
+	return messages
+}
+
+// checkSANMatchers reports clusters that connect to upstreams over TLS
+// without matching the subject alternative names of the upstream's
+// certificate, so any certificate signed by the CA is accepted.
+func checkSANMatchers(dump *ConfigDump, _ Options) []string {
+	var messages []string
+	for _, c := range dump.Clusters {
+		sockets := []interface{}{c["transport_socket"]}
+		for _, match := range objects(c["transport_socket_matches"]) {
+			sockets = append(sockets, match["transport_socket"])
+		}
+		for _, socket := range sockets {
+			socketObj, _ := socket.(map[string]interface{})
+			tlsContext, _ := socketObj["typed_config"].(map[string]interface{})
+			typeURL, _ := tlsContext["@type"].(string)
+			if !strings.HasSuffix(typeURL, ".UpstreamTlsContext") {
+				continue
+			}
+			if !hasSANMatchers(tlsContext) {
+				messages = append(messages, fmt.Sprintf("Cluster %q uses TLS without SAN matchers, so the identity of the upstream is not verified.", name(c)))
+				break
+			}
+		}
+	}
+	return messages
+}
+
+func hasSANMatchers(tlsContext map[string]interface{}) bool {
+	common, _ := tlsContext["common_tls_context"].(map[string]interface{})
+	validation, _ := common["validation_context"].(map[string]interface{})
+	if combined, ok := common["combined_validation_context"].(map[string]interface{}); ok {
+		validation, _ = combined["default_validation_context"].(map[string]interface{})
+	}
+	for _, key := range []string{"match_subject_alt_names", "match_typed_subject_alt_names"} {
+		if matchers, ok := validation[key].([]interface{}); ok && len(matchers) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOriginalDst reports listeners and clusters that rely on the original
+// destination of connections when the pod's traffic is not redirected to the
+// proxy, in which case the original destination is the proxy itself.
+func checkOriginalDst(dump *ConfigDump, opts Options) []string {
+	if opts.TransparentProxy == nil || *opts.TransparentProxy {
+		return nil
+	}
+
+	var messages []string
+	for _, l := range dump.Listeners {
+		usesOriginalDst := l["use_original_dst"] == true
+		for _, filter := range objects(l["listener_filters"]) {
+			switch name(filter) {
+			case "envoy.filters.listener.original_dst", "envoy.listener.original_dst":
+				usesOriginalDst = true
+			}
+		}
+		if usesOriginalDst {
+			messages = append(messages, fmt.Sprintf("Listener %q uses the original destination of connections, but transparent proxy is not enabled for the pod.", name(l)))
+		}
+	}
+	for _, c := range dump.Clusters {
+		if c["type"] == "ORIGINAL_DST" {
+			messages = append(messages, fmt.Sprintf("Cluster %q is of type ORIGINAL_DST, but transparent proxy is not enabled for the pod.", name(c)))
+		}
+	}
+	return messages
+}
+
+// checkFilterChains reports listeners without filter chains, which close
+// every connection they accept.
+func checkFilterChains(dump *ConfigDump, _ Options) []string {
+	var messages []string
+	for _, l := range dump.Listeners {
+		if len(objects(l["filter_chains"])) == 0 && l["default_filter_chain"] == nil {
+			messages = append(messages, fmt.Sprintf("Listener %q has no filter chains, so it closes every connection it accepts.", name(l)))
+		}
+	}
+	return messages
+}
+
+// walk calls fn for obj and every object nested in it with the key of the
+// field the object is in. Objects in lists get the key of the list.
+func walk(v interface{}, key string, fn func(key string, obj map[string]interface{})) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		fn(key, val)
+		// Sort the keys so that occurrences are reported in a stable order.
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			walk(val[k], k, fn)
+		}
+	case []interface{}:
+		for _, elem := range val {
+			walk(elem, key, fn)
+		}
+	}
+}
+
+// name returns the name of a listener, cluster or filter.
+func name(obj map[string]interface{}) string {
+	n, _ := obj["name"].(string)
+	return n
+}
@@ -0,0 +1,165 @@
+package envoy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfigDump(t *testing.T) {
+	dump, err := ParseConfigDump([]byte(`{
+  "configs": [
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.BootstrapConfigDump",
+      "bootstrap": {"node": {"user_agent_build_version": {"version": {"major_number": 1, "minor_number": 20}}}}
+    },
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.ListenersConfigDump",
+      "static_listeners": [{"listener": {"name": "static"}}],
+      "dynamic_listeners": [{"name": "public_listener", "active_state": {"listener": {"name": "public_listener"}}}, {"name": "warming"}]
+    },
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.ClustersConfigDump",
+      "static_clusters": [{"cluster": {"name": "local_agent"}}],
+      "dynamic_active_clusters": [{"cluster": {"name": "local_app"}}]
+    }
+  ]
+}`))
+	require.NoError(t, err)
+	require.Equal(t, "1.20.0", dump.Version.String())
+	require.Equal(t, []map[string]interface{}{{"name": "static"}, {"name": "public_listener"}}, dump.Listeners)
+	require.Equal(t, []map[string]interface{}{{"name": "local_agent"}, {"name": "local_app"}}, dump.Clusters)
+
+	_, err = ParseConfigDump([]byte("not json"))
+	require.Error(t, err)
+}
+
+func TestAnalyze(t *testing.T) {
+	enabled := true
+	disabled := false
+
+	tcpProxyChain := []interface{}{map[string]interface{}{
+		"filters": []interface{}{map[string]interface{}{
+			"name":         "envoy.filters.network.tcp_proxy",
+			"typed_config": map[string]interface{}{"@type": "type.googleapis.com/envoy.extensions.filters.network.tcp_proxy.v3.TcpProxy"},
+		}},
+	}}
+	upstreamTLS := func(validation map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"name": "envoy.transport_sockets.tls",
+			"typed_config": map[string]interface{}{
+				"@type":              "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.UpstreamTlsContext",
+				"common_tls_context": map[string]interface{}{"validation_context": validation},
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		dump        ConfigDump
+		opts        Options
+		expWarnings []Warning
+	}{
+		"no issues": {
+			dump: ConfigDump{
+				Listeners: []map[string]interface{}{{"name": "public_listener", "filter_chains": tcpProxyChain}},
+				Clusters: []map[string]interface{}{{
+					"name": "db",
+					"transport_socket": upstreamTLS(map[string]interface{}{
+						"match_subject_alt_names": []interface{}{map[string]interface{}{"exact": "spiffe://dc1/svc/db"}},
+					}),
+				}},
+			},
+		},
+		"deprecated filters": {
+			dump: ConfigDump{
+				Listeners: []map[string]interface{}{{
+					"name": "public_listener",
+					"filter_chains": []interface{}{map[string]interface{}{
+						"filters": []interface{}{map[string]interface{}{
+							"name":         "envoy.tcp_proxy",
+							"typed_config": map[string]interface{}{"@type": "type.googleapis.com/envoy.config.filter.network.tcp_proxy.v2.TcpProxy"},
+						}},
+					}},
+				}},
+			},
+			expWarnings: []Warning{
+				{
+					Rule:    "deprecated filters",
+					Message: `Listener "public_listener" uses the deprecated filter name envoy.tcp_proxy. Use envoy.filters.network.tcp_proxy instead.`,
+					Link:    "https://www.envoyproxy.io/docs/envoy/latest/faq/api/envoy_v3",
+				},
+				{
+					Rule:    "deprecated filters",
+					Message: `Listener "public_listener" uses the v2 API type type.googleapis.com/envoy.config.filter.network.tcp_proxy.v2.TcpProxy, which is not supported by Envoy 1.18 and later.`,
+					Link:    "https://www.envoyproxy.io/docs/envoy/latest/faq/api/envoy_v3",
+				},
+			},
+		},
+		"missing SAN matchers": {
+			dump: ConfigDump{
+				Clusters: []map[string]interface{}{{
+					"name":             "db",
+					"transport_socket": upstreamTLS(map[string]interface{}{"trusted_ca": map[string]interface{}{"inline_string": "ca"}}),
+				}},
+			},
+			expWarnings: []Warning{{
+				Rule:    "SAN matchers",
+				Message: `Cluster "db" uses TLS without SAN matchers, so the identity of the upstream is not verified.`,
+				Link:    "https://www.consul.io/docs/connect/proxies/envoy#escape-hatch-overrides",
+			}},
+		},
+		"original destination without transparent proxy": {
+			dump: ConfigDump{
+				Listeners: []map[string]interface{}{{
+					"name":             "outbound_listener",
+					"filter_chains":    tcpProxyChain,
+					"listener_filters": []interface{}{map[string]interface{}{"name": "envoy.filters.listener.original_dst"}},
+				}},
+				Clusters: []map[string]interface{}{{"name": "passthrough", "type": "ORIGINAL_DST"}},
+			},
+			opts: Options{TransparentProxy: &disabled},
+			expWarnings: []Warning{
+				{
+					Rule:    "original destination",
+					Message: `Listener "outbound_listener" uses the original destination of connections, but transparent proxy is not enabled for the pod.`,
+					Link:    "https://www.consul.io/docs/k8s/connect/transparent-proxy",
+				},
+				{
+					Rule:    "original destination",
+					Message: `Cluster "passthrough" is of type ORIGINAL_DST, but transparent proxy is not enabled for the pod.`,
+					Link:    "https://www.consul.io/docs/k8s/connect/transparent-proxy",
+				},
+			},
+		},
+		"original destination with transparent proxy": {
+			dump: ConfigDump{
+				Clusters: []map[string]interface{}{{"name": "passthrough", "type": "ORIGINAL_DST"}},
+			},
+			opts: Options{TransparentProxy: &enabled},
+		},
+		"original destination with unknown transparent proxy": {
+			dump: ConfigDump{
+				Clusters: []map[string]interface{}{{"name": "passthrough", "type": "ORIGINAL_DST"}},
+			},
+		},
+		"listener without filter chains": {
+			dump: ConfigDump{
+				Listeners: []map[string]interface{}{
+					{"name": "empty"},
+					{"name": "default_only", "default_filter_chain": map[string]interface{}{}},
+				},
+			},
+			expWarnings: []Warning{{
+				Rule:    "filter chains",
+				Message: `Listener "empty" has no filter chains, so it closes every connection it accepts.`,
+				Link:    "https://www.consul.io/docs/connect/proxies/envoy#escape-hatch-overrides",
+			}},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.expWarnings, Analyze(&c.dump, c.opts))
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package envoy
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of certificates.
+const (
+	CertLeaf         = "leaf"
+	CertIntermediate = "intermediate"
+	CertRoot         = "root"
+)
+
+// Certificate is a certificate that Envoy presents or trusts.
+type Certificate struct {
+	// Kind is CertLeaf, CertIntermediate or CertRoot.
+	Kind    string `json:"kind"`
+	Subject string `json:"subject"`
+	Issuer  string `json:"issuer"`
+	// SPIFFEID is the SPIFFE ID in the URI SANs of the certificate, e.g.
+	// "spiffe://<trust domain>.consul/ns/default/dc/dc1/svc/web".
+	SPIFFEID     string    `json:"spiffeID,omitempty"`
+	DNSNames     []string  `json:"dnsNames,omitempty"`
+	SerialNumber string    `json:"serialNumber"`
+	NotBefore    time.Time `json:"notBefore"`
+	NotAfter     time.Time `json:"notAfter"`
+	// Fingerprint is the SHA-256 fingerprint of the certificate. It is empty
+	// for certificates read from the /certs endpoint, which doesn't return
+	// them.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// IssuedBy is the fingerprint of the certificate that signed this one if
+	// it is one of the certificates, which is how chains are told apart.
+	IssuedBy string `json:"issuedBy,omitempty"`
+	// AuthorityKeyID is the ID of the key that signed the certificate, as
+	// colon separated hex bytes like the SigningKeyID of Consul's CA roots.
+	// It is empty for certificates read from the /certs endpoint.
+	AuthorityKeyID string `json:"authorityKeyID,omitempty"`
+	// UsedBy are the listeners, clusters and secrets the certificate is
+	// presented or trusted in, e.g. "cluster api.default.dc1".
+	UsedBy []string `json:"usedBy,omitempty"`
+}
+
+// ExpiresWithin returns true if the certificate is expired or expires within
+// the duration from now.
+func (c Certificate) ExpiresWithin(d time.Duration, now time.Time) bool {
+	return c.NotAfter.Sub(now) < d
+}
+
+// Certificates returns the certificates that are inlined in the listeners,
+// clusters and secrets of the config dump, without duplicates. Leaf
+// certificates come first, then intermediates and roots, each sorted by
+// expiry.
+func Certificates(dump *ConfigDump) ([]Certificate, error) {
+	certs := make(map[string]*Certificate)
+	var parsed []*x509.Certificate
+	collect := func(kind string, resource map[string]interface{}) error {
+		var walkErr error
+		walk(resource, "", func(key string, obj map[string]interface{}) {
+			if walkErr != nil || (key != "certificate_chain" && key != "trusted_ca") {
+				return
+			}
+			data, err := inlineData(obj)
+			if err != nil {
+				walkErr = fmt.Errorf("%s %q: %s", kind, name(resource), err)
+				return
+			}
+			for _, x509Cert := range parsePEM(data) {
+				fingerprint := sha256Fingerprint(x509Cert)
+				cert, ok := certs[fingerprint]
+				if !ok {
+					c := fromX509(x509Cert)
+					c.Fingerprint = fingerprint
+					cert = &c
+					certs[fingerprint] = cert
+					parsed = append(parsed, x509Cert)
+				}
+				usedBy := fmt.Sprintf("%s %s", strings.ToLower(kind), name(resource))
+				if !contains(cert.UsedBy, usedBy) {
+					cert.UsedBy = append(cert.UsedBy, usedBy)
+				}
+			}
+		})
+		return walkErr
+	}
+	for _, l := range dump.Listeners {
+		if err := collect("Listener", l); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range dump.Clusters {
+		if err := collect("Cluster", c); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range dump.Secrets {
+		if err := collect("Secret", s); err != nil {
+			return nil, err
+		}
+	}
+
+	// Link the certificates to the certificates that signed them.
+	for _, child := range parsed {
+		for _, parent := range parsed {
+			if child == parent || !parent.IsCA {
+				continue
+			}
+			if child.CheckSignatureFrom(parent) == nil {
+				certs[sha256Fingerprint(child)].IssuedBy = sha256Fingerprint(parent)
+				break
+			}
+		}
+	}
+
+	result := make([]Certificate, 0, len(certs))
+	for _, cert := range certs {
+		result = append(result, *cert)
+	}
+	sortCertificates(result)
+	return result, nil
+}
+
+// FetchCertificates fetches the certificates from the /certs endpoint of the
+// admin API of Envoy listening on the given address, e.g. "localhost:19000".
+// Unlike the config dump, it includes certificates delivered through SDS.
+func FetchCertificates(ctx context.Context, adminAddr string) ([]Certificate, error) {
+	raw, err := adminGet(ctx, adminAddr, "/certs")
+	if err != nil {
+		return nil, err
+	}
+	return ParseCertificates(raw)
+}
+
+// ParseCertificates parses the response of the /certs endpoint, which has
+// the details of the certificates but not the certificates themselves.
+func ParseCertificates(raw []byte) ([]Certificate, error) {
+	type certDetails struct {
+		Path            string `json:"path"`
+		SerialNumber    string `json:"serial_number"`
+		SubjectAltNames []struct {
+			URI string `json:"uri"`
+			DNS string `json:"dns"`
+		} `json:"subject_alt_names"`
+		ValidFrom      string `json:"valid_from"`
+		ExpirationTime string `json:"expiration_time"`
+	}
+	var resp struct {
+		Certificates []struct {
+			CACert    []certDetails `json:"ca_cert"`
+			CertChain []certDetails `json:"cert_chain"`
+		} `json:"certificates"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("invalid certificates: %s", err)
+	}
+
+	var result []Certificate
+	add := func(kind string, details certDetails) error {
+		cert := Certificate{Kind: kind, SerialNumber: details.SerialNumber}
+		for _, san := range details.SubjectAltNames {
+			if strings.HasPrefix(san.URI, "spiffe://") && cert.SPIFFEID == "" {
+				cert.SPIFFEID = san.URI
+			}
+			if san.DNS != "" {
+				cert.DNSNames = append(cert.DNSNames, san.DNS)
+			}
+		}
+		var err error
+		if details.ValidFrom != "" {
+			if cert.NotBefore, err = time.Parse(time.RFC3339, details.ValidFrom); err != nil {
+				return fmt.Errorf("invalid certificate validity time %q: %s", details.ValidFrom, err)
+			}
+		}
+		if details.ExpirationTime != "" {
+			if cert.NotAfter, err = time.Parse(time.RFC3339, details.ExpirationTime); err != nil {
+				return fmt.Errorf("invalid certificate expiration time %q: %s", details.ExpirationTime, err)
+			}
+		}
+		if details.Path != "" && details.Path != "<inline>" {
+			cert.UsedBy = []string{details.Path}
+		}
+		result = append(result, cert)
+		return nil
+	}
+	for _, certs := range resp.Certificates {
+		for _, details := range certs.CertChain {
+			if err := add(CertLeaf, details); err != nil {
+				return nil, err
+			}
+		}
+		for _, details := range certs.CACert {
+			if err := add(CertRoot, details); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sortCertificates(result)
+	return result, nil
+}
+
+// inlineData returns the data of an Envoy data source, which is inlined as a
+// string or as base64 encoded bytes. Data sources that refer to files or
+// environment variables have no data.
+func inlineData(source map[string]interface{}) ([]byte, error) {
+	if s, ok := source["inline_string"].(string); ok {
+		return []byte(s), nil
+	}
+	if b, ok := source["inline_bytes"].(string); ok {
+		data, err := base64.StdEncoding.DecodeString(b)
+		if err != nil {
+			return nil, fmt.Errorf("invalid inline bytes: %s", err)
+		}
+		return data, nil
+	}
+	return nil, nil
+}
+
+// parsePEM returns the certificates in the PEM data. Blocks that are not
+// valid certificates are skipped since private keys are redacted in dumps.
+func parsePEM(data []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			certs = append(certs, cert)
+		}
+	}
+}
+
+func fromX509(cert *x509.Certificate) Certificate {
+	c := Certificate{
+		Kind:         CertLeaf,
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		DNSNames:     cert.DNSNames,
+		SerialNumber: serialNumber(cert),
+		NotBefore:    cert.NotBefore,
+		NotAfter:     cert.NotAfter,
+	}
+	if len(cert.AuthorityKeyId) > 0 {
+		c.AuthorityKeyID = colonHex(cert.AuthorityKeyId)
+	}
+	if cert.IsCA {
+		c.Kind = CertIntermediate
+		if cert.CheckSignatureFrom(cert) == nil {
+			c.Kind = CertRoot
+		}
+	}
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" {
+			c.SPIFFEID = uri.String()
+			break
+		}
+	}
+	return c
+}
+
+// serialNumber formats the serial number of the certificate as colon
+// separated hex bytes, like Consul does.
+func serialNumber(cert *x509.Certificate) string {
+	return colonHex(cert.SerialNumber.Bytes())
+}
+
+func colonHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i := range b {
+		parts[i] = hex.EncodeToString(b[i : i+1])
+	}
+	return strings.Join(parts, ":")
+}
+
+func sha256Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// sortCertificates sorts leaf certificates first, then intermediates and
+// roots, each by expiry.
+func sortCertificates(certs []Certificate) {
+	rank := map[string]int{CertLeaf: 0, CertIntermediate: 1, CertRoot: 2}
+	sort.SliceStable(certs, func(i, j int) bool {
+		if rank[certs[i].Kind] != rank[certs[j].Kind] {
+			return rank[certs[i].Kind] < rank[certs[j].Kind]
+		}
+		return certs[i].NotAfter.Before(certs[j].NotAfter)
+	})
+}
+
+func contains(list []string, s string) bool {
+	for _, elem := range list {
+		if elem == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package envoy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificates(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	root, rootKey := generateCert(t, certTemplate{serial: 1, cn: "Consul CA 1", ca: true, notAfter: now.Add(10 * 365 * 24 * time.Hour)}, nil, nil)
+	leaf, _ := generateCert(t, certTemplate{serial: 2, cn: "web", spiffeID: "spiffe://11111111.consul/ns/default/dc/dc1/svc/web", notAfter: now.Add(2 * time.Hour)}, root, rootKey)
+	rootPEM, leafPEM := encodePEM(root), encodePEM(leaf)
+
+	dump, err := ParseConfigDump([]byte(configDumpWithCerts(t, leafPEM, rootPEM)))
+	require.NoError(t, err)
+	certs, err := Certificates(dump)
+	require.NoError(t, err)
+	require.Len(t, certs, 2)
+
+	require.Equal(t, CertLeaf, certs[0].Kind)
+	require.Equal(t, "spiffe://11111111.consul/ns/default/dc/dc1/svc/web", certs[0].SPIFFEID)
+	require.Equal(t, "CN=web", certs[0].Subject)
+	require.Equal(t, "CN=Consul CA 1", certs[0].Issuer)
+	require.Equal(t, "02", certs[0].SerialNumber)
+	require.Equal(t, now.Add(2*time.Hour).UTC(), certs[0].NotAfter)
+	require.Equal(t, certs[1].Fingerprint, certs[0].IssuedBy)
+	require.Equal(t, colonHex(root.SubjectKeyId), certs[0].AuthorityKeyID)
+	require.Equal(t, []string{"listener public_listener"}, certs[0].UsedBy)
+	require.True(t, certs[0].ExpiresWithin(24*time.Hour, now))
+
+	require.Equal(t, CertRoot, certs[1].Kind)
+	require.Empty(t, certs[1].IssuedBy)
+	require.Equal(t, []string{"listener public_listener", "cluster api.default.dc1.internal.11111111.consul", "secret ROOTCA"}, certs[1].UsedBy)
+	require.False(t, certs[1].ExpiresWithin(24*time.Hour, now))
+}
+
+func TestParseCertificates(t *testing.T) {
+	certs, err := ParseCertificates([]byte(`{
+  "certificates": [{
+    "ca_cert": [{"path": "<inline>", "serial_number": "1", "subject_alt_names": [{"uri": "spiffe://11111111.consul"}],
+      "valid_from": "2022-01-01T00:00:00Z", "expiration_time": "2032-01-01T00:00:00Z"}],
+    "cert_chain": [{"path": "<inline>", "serial_number": "2", "subject_alt_names": [{"uri": "spiffe://11111111.consul/ns/default/dc/dc1/svc/web"}],
+      "valid_from": "2022-01-01T00:00:00Z", "expiration_time": "2022-01-04T00:00:00Z"}]
+  }]
+}`))
+	require.NoError(t, err)
+	require.Equal(t, []Certificate{
+		{
+			Kind:         CertLeaf,
+			SPIFFEID:     "spiffe://11111111.consul/ns/default/dc/dc1/svc/web",
+			SerialNumber: "2",
+			NotBefore:    time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			NotAfter:     time.Date(2022, 1, 4, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Kind:         CertRoot,
+			SPIFFEID:     "spiffe://11111111.consul",
+			SerialNumber: "1",
+			NotBefore:    time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			NotAfter:     time.Date(2032, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}, certs)
+
+	_, err = ParseCertificates([]byte(`{"certificates": [{"cert_chain": [{"expiration_time": "tomorrow"}]}]}`))
+	require.Error(t, err)
+}
+
+// configDumpWithCerts returns a config dump whose public listener presents
+// the leaf certificate and trusts the root, whose upstream cluster trusts the
+// root, and which has the root as an SDS secret.
+func configDumpWithCerts(t *testing.T, leafPEM, rootPEM string) string {
+	t.Helper()
+	tlsContext := map[string]interface{}{
+		"tls_certificates": []interface{}{map[string]interface{}{
+			"certificate_chain": map[string]interface{}{"inline_string": leafPEM},
+			"private_key":       map[string]interface{}{"inline_string": "[redacted]"},
+		}},
+		"validation_context": map[string]interface{}{
+			"trusted_ca": map[string]interface{}{"inline_string": rootPEM},
+		},
+	}
+	dump := map[string]interface{}{
+		"configs": []interface{}{
+			map[string]interface{}{
+				"@type": typeListenersConfigDump,
+				"dynamic_listeners": []interface{}{map[string]interface{}{"active_state": map[string]interface{}{"listener": map[string]interface{}{
+					"name": "public_listener",
+					"filter_chains": []interface{}{map[string]interface{}{"transport_socket": map[string]interface{}{
+						"typed_config": map[string]interface{}{"common_tls_context": tlsContext},
+					}}},
+				}}}},
+			},
+			map[string]interface{}{
+				"@type": typeClustersConfigDump,
+				"dynamic_active_clusters": []interface{}{map[string]interface{}{"cluster": map[string]interface{}{
+					"name": "api.default.dc1.internal.11111111.consul",
+					"transport_socket": map[string]interface{}{"typed_config": map[string]interface{}{"common_tls_context": map[string]interface{}{
+						"validation_context": map[string]interface{}{"trusted_ca": map[string]interface{}{"inline_string": rootPEM}},
+					}}},
+				}}},
+			},
+			map[string]interface{}{
+				"@type": typeSecretsConfigDump,
+				"dynamic_active_secrets": []interface{}{map[string]interface{}{"secret": map[string]interface{}{
+					"name":               "ROOTCA",
+					"validation_context": map[string]interface{}{"trusted_ca": map[string]interface{}{"inline_string": rootPEM}},
+				}}},
+			},
+		},
+	}
+	raw, err := json.Marshal(dump)
+	require.NoError(t, err)
+	return string(raw)
+}
+
+type certTemplate struct {
+	serial   int64
+	cn       string
+	spiffeID string
+	ca       bool
+	notAfter time.Time
+}
+
+// generateCert generates a certificate signed by the parent, or a self-signed
+// one if the parent is nil.
+func generateCert(t *testing.T, tmpl certTemplate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(tmpl.serial),
+		Subject:               pkix.Name{CommonName: tmpl.cn},
+		NotBefore:             tmpl.notAfter.Add(-72 * time.Hour),
+		NotAfter:              tmpl.notAfter,
+		IsCA:                  tmpl.ca,
+		BasicConstraintsValid: true,
+	}
+	if tmpl.ca {
+		template.KeyUsage = x509.KeyUsageCertSign
+	}
+	if tmpl.spiffeID != "" {
+		uri, err := url.Parse(tmpl.spiffeID)
+		require.NoError(t, err)
+		template.URIs = []*url.URL{uri}
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func encodePEM(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
@@ -0,0 +1,183 @@
+// Package envoy fetches the configuration and status of Envoy proxies,
+// summarizes the configuration and analyzes it for known problematic
+// patterns.
+package envoy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+const (
+	typeBootstrapConfigDump = "type.googleapis.com/envoy.admin.v3.BootstrapConfigDump"
+	typeListenersConfigDump = "type.googleapis.com/envoy.admin.v3.ListenersConfigDump"
+	typeClustersConfigDump  = "type.googleapis.com/envoy.admin.v3.ClustersConfigDump"
+	typeRoutesConfigDump    = "type.googleapis.com/envoy.admin.v3.RoutesConfigDump"
+	typeEndpointsConfigDump = "type.googleapis.com/envoy.admin.v3.EndpointsConfigDump"
+	typeSecretsConfigDump   = "type.googleapis.com/envoy.admin.v3.SecretsConfigDump"
+)
+
+// ConfigDump is the configuration of an Envoy proxy as returned by the
+// /config_dump endpoint of its admin API. Listeners, clusters, route
+// configurations and cluster load assignments are kept as decoded JSON since
+// only a few of their fields are inspected.
+type ConfigDump struct {
+	// Version is the version of Envoy. It is nil if the dump does not
+	// include the bootstrap configuration.
+	Version *semver.Version
+
+	Listeners []map[string]interface{}
+	Clusters  []map[string]interface{}
+	Routes    []map[string]interface{}
+	// Endpoints are the load assignments of the clusters. They are only
+	// included in dumps fetched with the include_eds parameter.
+	Endpoints []map[string]interface{}
+	// Secrets are the TLS certificates and validation contexts delivered
+	// through SDS. Their private keys are redacted.
+	Secrets []map[string]interface{}
+
+	// Versions are the xDS versions of the dynamic resources, i.e. the ones
+	// delivered by Consul rather than set in the bootstrap configuration.
+	Versions []ResourceVersion
+}
+
+// Types of the dynamic resources of a config dump.
+const (
+	ResourceListener = "Listener"
+	ResourceCluster  = "Cluster"
+	ResourceRoute    = "Route"
+	ResourceEndpoint = "Endpoint"
+	ResourceSecret   = "Secret"
+)
+
+// ResourceVersion is the version of a dynamic resource as last received by
+// Envoy over xDS.
+type ResourceVersion struct {
+	Type    string
+	Name    string
+	Version string
+}
+
+// FetchConfigDump fetches the configuration dump from the admin API of Envoy
+// listening on the given address, e.g. "localhost:19000". The dump includes
+// the endpoints of the clusters.
+func FetchConfigDump(ctx context.Context, adminAddr string) ([]byte, error) {
+	return adminGet(ctx, adminAddr, "/config_dump?include_eds")
+}
+
+// ParseConfigDump parses the JSON returned by the /config_dump endpoint.
+func ParseConfigDump(raw []byte) (*ConfigDump, error) {
+	var dump struct {
+		Configs []map[string]interface{} `json:"configs"`
+	}
+	if err := json.Unmarshal(raw, &dump); err != nil {
+		return nil, fmt.Errorf("invalid config dump: %s", err)
+	}
+
+	result := &ConfigDump{}
+	for _, config := range dump.Configs {
+		switch config["@type"] {
+		case typeBootstrapConfigDump:
+			version, err := envoyVersion(config)
+			if err != nil {
+				return nil, err
+			}
+			result.Version = version
+		case typeListenersConfigDump:
+			for _, l := range objects(config["static_listeners"]) {
+				result.Listeners = appendObject(result.Listeners, l["listener"])
+			}
+			for _, l := range objects(config["dynamic_listeners"]) {
+				state, _ := l["active_state"].(map[string]interface{})
+				result.Listeners = appendObject(result.Listeners, state["listener"])
+				result.addVersion(ResourceListener, l["name"], state["version_info"])
+			}
+		case typeClustersConfigDump:
+			for _, c := range objects(config["static_clusters"]) {
+				result.Clusters = appendObject(result.Clusters, c["cluster"])
+			}
+			for _, c := range objects(config["dynamic_active_clusters"]) {
+				result.Clusters = appendObject(result.Clusters, c["cluster"])
+				cluster, _ := c["cluster"].(map[string]interface{})
+				result.addVersion(ResourceCluster, cluster["name"], c["version_info"])
+			}
+		case typeRoutesConfigDump:
+			for _, r := range objects(config["static_route_configs"]) {
+				result.Routes = appendObject(result.Routes, r["route_config"])
+			}
+			for _, r := range objects(config["dynamic_route_configs"]) {
+				result.Routes = appendObject(result.Routes, r["route_config"])
+				route, _ := r["route_config"].(map[string]interface{})
+				result.addVersion(ResourceRoute, route["name"], r["version_info"])
+			}
+		case typeEndpointsConfigDump:
+			for _, e := range objects(config["static_endpoint_configs"]) {
+				result.Endpoints = appendObject(result.Endpoints, e["endpoint_config"])
+			}
+			for _, e := range objects(config["dynamic_endpoint_configs"]) {
+				result.Endpoints = appendObject(result.Endpoints, e["endpoint_config"])
+				endpoints, _ := e["endpoint_config"].(map[string]interface{})
+				result.addVersion(ResourceEndpoint, endpoints["cluster_name"], e["version_info"])
+			}
+		case typeSecretsConfigDump:
+			for _, s := range objects(config["static_secrets"]) {
+				result.Secrets = appendObject(result.Secrets, s["secret"])
+			}
+			for _, s := range objects(config["dynamic_active_secrets"]) {
+				result.Secrets = appendObject(result.Secrets, s["secret"])
+				result.addVersion(ResourceSecret, s["name"], s["version_info"])
+			}
+		}
+	}
+	return result, nil
+}
+
+// addVersion records the version of a dynamic resource. Resources without a
+// name are skipped since they can't be compared across proxies.
+func (d *ConfigDump) addVersion(resourceType string, name, version interface{}) {
+	n, _ := name.(string)
+	if n == "" {
+		return
+	}
+	v, _ := version.(string)
+	d.Versions = append(d.Versions, ResourceVersion{Type: resourceType, Name: n, Version: v})
+}
+
+// envoyVersion returns the version of Envoy from the node of the bootstrap
+// configuration.
+func envoyVersion(config map[string]interface{}) (*semver.Version, error) {
+	bootstrap, _ := config["bootstrap"].(map[string]interface{})
+	node, _ := bootstrap["node"].(map[string]interface{})
+	build, _ := node["user_agent_build_version"].(map[string]interface{})
+	version, ok := build["version"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	// Unset fields are omitted from the JSON and default to 0.
+	major, _ := version["major_number"].(float64)
+	minor, _ := version["minor_number"].(float64)
+	patch, _ := version["patch"].(float64)
+	return semver.NewVersion(fmt.Sprintf("%d.%d.%d", int(major), int(minor), int(patch)))
+}
+
+// objects returns the elements of a JSON array that are JSON objects.
+func objects(v interface{}) []map[string]interface{} {
+	list, _ := v.([]interface{})
+	var result []map[string]interface{}
+	for _, elem := range list {
+		if obj, ok := elem.(map[string]interface{}); ok {
+			result = append(result, obj)
+		}
+	}
+	return result
+}
+
+func appendObject(list []map[string]interface{}, v interface{}) []map[string]interface{} {
+	if obj, ok := v.(map[string]interface{}); ok {
+		return append(list, obj)
+	}
+	return list
+}
@@ -0,0 +1,64 @@
+package envoy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// LogLevels are the valid levels of Envoy loggers.
+var LogLevels = []string{"trace", "debug", "info", "warning", "error", "critical", "off"}
+
+// FetchLogLevels fetches the levels of the loggers of the Envoy proxy whose
+// admin API listens on the given address, keyed by logger name.
+func FetchLogLevels(ctx context.Context, adminAddr string) (map[string]string, error) {
+	raw, err := adminRequest(ctx, http.MethodPost, adminAddr, "/logging")
+	if err != nil {
+		return nil, err
+	}
+	return parseLogLevels(raw)
+}
+
+// SetLogLevels sets the levels of the loggers of the Envoy proxy whose admin
+// API listens on the given address and returns the levels of all loggers
+// afterwards. The levels are keyed by logger name, and the level keyed by ""
+// is set on all loggers before the others.
+func SetLogLevels(ctx context.Context, adminAddr string, levels map[string]string) (map[string]string, error) {
+	var queries []string
+	if level, ok := levels[""]; ok {
+		queries = append(queries, url.Values{"level": {level}}.Encode())
+	}
+	for logger, level := range levels {
+		if logger != "" {
+			queries = append(queries, url.Values{logger: {level}}.Encode())
+		}
+	}
+
+	// Older versions of Envoy only set a single logger per request.
+	for _, query := range queries {
+		if _, err := adminRequest(ctx, http.MethodPost, adminAddr, "/logging?"+query); err != nil {
+			return nil, err
+		}
+	}
+	return FetchLogLevels(ctx, adminAddr)
+}
+
+// parseLogLevels parses the response of the /logging endpoint, which lists
+// the loggers after a header line, e.g. "active loggers:\n  admin: info\n".
+func parseLogLevels(raw []byte) (map[string]string, error) {
+	levels := make(map[string]string)
+	for _, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == "active loggers:" {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid logger level %q", line)
+		}
+		levels[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return levels, nil
+}
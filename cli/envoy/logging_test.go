@@ -0,0 +1,73 @@
+package envoy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetLogLevels(t *testing.T) {
+	var mu sync.Mutex
+	levels := map[string]string{"admin": "info", "grpc": "info", "http": "info"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path != "/logging" || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for key, values := range r.URL.Query() {
+			if key == "level" {
+				for logger := range levels {
+					levels[logger] = values[0]
+				}
+				continue
+			}
+			if _, ok := levels[key]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, "invalid logger")
+				return
+			}
+			levels[key] = values[0]
+		}
+		var loggers []string
+		for logger := range levels {
+			loggers = append(loggers, logger)
+		}
+		sort.Strings(loggers)
+		fmt.Fprintln(w, "active loggers:")
+		for _, logger := range loggers {
+			fmt.Fprintf(w, "  %s: %s\n", logger, levels[logger])
+		}
+	}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+
+	got, err := FetchLogLevels(context.Background(), addr)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"admin": "info", "grpc": "info", "http": "info"}, got)
+
+	got, err = SetLogLevels(context.Background(), addr, map[string]string{"": "warning", "grpc": "debug"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"admin": "warning", "grpc": "debug", "http": "warning"}, got)
+
+	_, err = SetLogLevels(context.Background(), addr, map[string]string{"unknown": "debug"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid logger")
+}
+
+func TestParseLogLevels(t *testing.T) {
+	levels, err := parseLogLevels([]byte("active loggers:\n  admin: info\n  upstream: debug\n"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"admin": "info", "upstream": "debug"}, levels)
+
+	_, err = parseLogLevels([]byte("active loggers:\n  admin\n"))
+	require.Error(t, err)
+}
@@ -0,0 +1,144 @@
+package envoy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RouteTableEntry is one route of a virtual host of a route configuration.
+type RouteTableEntry struct {
+	RouteConfig string
+	VirtualHost string
+	Domains     []string
+	// Match is the path match of the route, e.g. "prefix /api", followed by
+	// its query parameter matches, e.g. "prefix /api?version=2".
+	Match string
+	// Headers are the header matches of the route, e.g. "x-debug=1".
+	Headers  []string
+	Clusters []WeightedCluster
+	// Action describes what the route does if it doesn't send requests to
+	// clusters, e.g. "redirect" or "direct response 404".
+	Action string
+}
+
+// WeightedCluster is a cluster that a route sends requests to.
+type WeightedCluster struct {
+	Name string
+	// Weight is the percentage of the requests of the route that are sent to
+	// the cluster.
+	Weight float64
+}
+
+// RouteTable returns the routes of the route configurations in the config
+// dump in the order in which Envoy matches them.
+func RouteTable(dump *ConfigDump) []RouteTableEntry {
+	var table []RouteTableEntry
+	for _, r := range dump.Routes {
+		for _, vhost := range objects(r["virtual_hosts"]) {
+			domains := stringList(vhost["domains"])
+			for _, route := range objects(vhost["routes"]) {
+				match, _ := route["match"].(map[string]interface{})
+				entry := RouteTableEntry{
+					RouteConfig: name(r),
+					VirtualHost: name(vhost),
+					Domains:     domains,
+					Match:       routeMatch(route),
+					Headers:     []string{},
+					Clusters:    weightedClusters(route),
+				}
+				var params []string
+				for _, p := range objects(match["query_parameters"]) {
+					params = append(params, matchRule(p))
+				}
+				if len(params) > 0 {
+					entry.Match += "?" + strings.Join(params, "&")
+				}
+				for _, h := range objects(match["headers"]) {
+					entry.Headers = append(entry.Headers, matchRule(h))
+				}
+				switch {
+				case route["redirect"] != nil:
+					entry.Action = "redirect"
+				case route["direct_response"] != nil:
+					response, _ := route["direct_response"].(map[string]interface{})
+					status, _ := response["status"].(float64)
+					entry.Action = fmt.Sprintf("direct response %d", int(status))
+				}
+				table = append(table, entry)
+			}
+		}
+	}
+	return table
+}
+
+// weightedClusters returns the clusters that the route sends requests to and
+// the percentage of the requests that each of them gets. The weights of
+// weighted clusters are relative to their total_weight, or to the sum of the
+// weights if it isn't set.
+func weightedClusters(route map[string]interface{}) []WeightedCluster {
+	action, _ := route["route"].(map[string]interface{})
+	if cluster, ok := action["cluster"].(string); ok {
+		return []WeightedCluster{{Name: cluster, Weight: 100}}
+	}
+	weighted, _ := action["weighted_clusters"].(map[string]interface{})
+	clusters := []WeightedCluster{}
+	sum := 0.0
+	for _, c := range objects(weighted["clusters"]) {
+		weight, _ := c["weight"].(float64)
+		sum += weight
+		clusters = append(clusters, WeightedCluster{Name: name(c), Weight: weight})
+	}
+	total, _ := weighted["total_weight"].(float64)
+	if total == 0 {
+		total = sum
+	}
+	for i := range clusters {
+		if total > 0 {
+			clusters[i].Weight = clusters[i].Weight * 100 / total
+		}
+	}
+	return clusters
+}
+
+// matchRule describes a header or query parameter matcher of a route, e.g.
+// "x-debug=1" or "version prefix v2".
+func matchRule(m map[string]interface{}) string {
+	rule := name(m)
+	invert, _ := m["invert_match"].(bool)
+	if stringMatch, ok := m["string_match"].(map[string]interface{}); ok {
+		m = stringMatch
+	}
+	switch {
+	case m["exact_match"] != nil:
+		rule += fmt.Sprintf("=%v", m["exact_match"])
+	case m["exact"] != nil:
+		rule += fmt.Sprintf("=%v", m["exact"])
+	case m["prefix_match"] != nil:
+		rule += fmt.Sprintf(" prefix %v", m["prefix_match"])
+	case m["prefix"] != nil:
+		rule += fmt.Sprintf(" prefix %v", m["prefix"])
+	case m["suffix_match"] != nil:
+		rule += fmt.Sprintf(" suffix %v", m["suffix_match"])
+	case m["suffix"] != nil:
+		rule += fmt.Sprintf(" suffix %v", m["suffix"])
+	case m["contains_match"] != nil:
+		rule += fmt.Sprintf(" contains %v", m["contains_match"])
+	case m["contains"] != nil:
+		rule += fmt.Sprintf(" contains %v", m["contains"])
+	case m["safe_regex_match"] != nil || m["safe_regex"] != nil:
+		regex, _ := m["safe_regex_match"].(map[string]interface{})
+		if regex == nil {
+			regex, _ = m["safe_regex"].(map[string]interface{})
+		}
+		rule += fmt.Sprintf(" regex %v", regex["regex"])
+	case m["range_match"] != nil:
+		r, _ := m["range_match"].(map[string]interface{})
+		rule += fmt.Sprintf(" in [%v, %v)", r["start"], r["end"])
+	case m["present_match"] != nil:
+		rule += " present"
+	}
+	if invert {
+		rule = "not " + rule
+	}
+	return rule
+}
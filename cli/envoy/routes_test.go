@@ -0,0 +1,60 @@
+package envoy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteTable(t *testing.T) {
+	dump, err := ParseConfigDump([]byte(`{
+  "configs": [{
+    "@type": "type.googleapis.com/envoy.admin.v3.RoutesConfigDump",
+    "dynamic_route_configs": [{"route_config": {
+      "name": "api",
+      "virtual_hosts": [{"name": "api", "domains": ["api.example.com", "api"], "routes": [
+        {
+          "match": {
+            "prefix": "/v2",
+            "headers": [
+              {"name": "x-debug", "exact_match": "1"},
+              {"name": "x-canary", "present_match": true, "invert_match": true},
+              {"name": "x-user", "string_match": {"prefix": "admin-"}}
+            ],
+            "query_parameters": [{"name": "version", "string_match": {"exact": "2"}}]
+          },
+          "route": {"weighted_clusters": {"total_weight": 100, "clusters": [{"name": "api-v2", "weight": 90}, {"name": "api-v1", "weight": 10}]}}
+        },
+        {"match": {"path": "/old"}, "redirect": {"path_redirect": "/new"}},
+        {"match": {"safe_regex": {"regex": "/admin/.*"}}, "direct_response": {"status": 403}},
+        {"match": {"prefix": "/"}, "route": {"weighted_clusters": {"clusters": [{"name": "a", "weight": 1}, {"name": "b", "weight": 3}]}}},
+        {"match": {"prefix": "/"}, "route": {"cluster": "api"}}
+      ]}]
+    }}]
+  }]
+}`))
+	require.NoError(t, err)
+
+	domains := []string{"api.example.com", "api"}
+	require.Equal(t, []RouteTableEntry{
+		{
+			RouteConfig: "api",
+			VirtualHost: "api",
+			Domains:     domains,
+			Match:       "prefix /v2?version=2",
+			Headers:     []string{"x-debug=1", "not x-canary present", "x-user prefix admin-"},
+			Clusters:    []WeightedCluster{{Name: "api-v2", Weight: 90}, {Name: "api-v1", Weight: 10}},
+		},
+		{RouteConfig: "api", VirtualHost: "api", Domains: domains, Match: "path /old", Headers: []string{}, Clusters: []WeightedCluster{}, Action: "redirect"},
+		{RouteConfig: "api", VirtualHost: "api", Domains: domains, Match: "regex /admin/.*", Headers: []string{}, Clusters: []WeightedCluster{}, Action: "direct response 403"},
+		{
+			RouteConfig: "api",
+			VirtualHost: "api",
+			Domains:     domains,
+			Match:       "prefix /",
+			Headers:     []string{},
+			Clusters:    []WeightedCluster{{Name: "a", Weight: 25}, {Name: "b", Weight: 75}},
+		},
+		{RouteConfig: "api", VirtualHost: "api", Domains: domains, Match: "prefix /", Headers: []string{}, Clusters: []WeightedCluster{{Name: "api", Weight: 100}}},
+	}, RouteTable(dump))
+}
@@ -0,0 +1,46 @@
+package envoy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// FetchStats fetches the values of the counters and gauges of the Envoy proxy
+// whose admin API listens on the given address, keyed by stat name. Only the
+// stats whose name matches the regular expression filter are fetched, or all
+// stats if it is empty. Histograms are skipped.
+func FetchStats(ctx context.Context, adminAddr, filter string) (map[string]int64, error) {
+	query := url.Values{"format": {"json"}}
+	if filter != "" {
+		query.Set("filter", filter)
+	}
+	raw, err := adminGet(ctx, adminAddr, "/stats?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	var stats struct {
+		Stats []struct {
+			Name  string      `json:"name"`
+			Value json.Number `json:"value"`
+		} `json:"stats"`
+	}
+	if err := json.Unmarshal(raw, &stats); err != nil {
+		return nil, fmt.Errorf("invalid stats: %s", err)
+	}
+	values := make(map[string]int64)
+	for _, stat := range stats.Stats {
+		// Histograms are listed without a name.
+		if stat.Name == "" {
+			continue
+		}
+		value, err := stat.Value.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of stat %q: %s", stat.Name, err)
+		}
+		values[stat.Name] = value
+	}
+	return values, nil
+}
@@ -0,0 +1,33 @@
+package envoy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/stats", r.URL.Path)
+		require.Equal(t, "json", r.URL.Query().Get("format"))
+		require.Equal(t, `upstream_rq_total$`, r.URL.Query().Get("filter"))
+		fmt.Fprint(w, `{"stats": [
+  {"name": "cluster.api.upstream_rq_total", "value": 42},
+  {"name": "cluster.web.upstream_rq_total", "value": 0},
+  {"histograms": {"supported_quantiles": [0, 50, 100], "computed_quantiles": []}}
+]}`)
+	}))
+	defer server.Close()
+
+	stats, err := FetchStats(context.Background(), strings.TrimPrefix(server.URL, "http://"), `upstream_rq_total$`)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{
+		"cluster.api.upstream_rq_total": 42,
+		"cluster.web.upstream_rq_total": 0,
+	}, stats)
+}
@@ -0,0 +1,148 @@
+package envoy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// controlPlaneConnectedStat is the gauge Envoy sets to 1 while it is
+// connected to its xDS server.
+const controlPlaneConnectedStat = "control_plane.connected_state"
+
+// Status is the health of an Envoy proxy as reported by its admin API.
+type Status struct {
+	// Version is the version of Envoy, e.g. "1.20.1".
+	Version string
+	// State is the state of the server, e.g. "LIVE" or "INITIALIZING".
+	State string
+	// CertExpiry is the time the first of the certificates Envoy serves or
+	// presents expires. It is zero if Envoy has no certificates.
+	CertExpiry time.Time
+	// XDSConnected is whether Envoy is connected to its xDS server.
+	XDSConnected bool
+}
+
+// FetchStatus fetches the status of the Envoy proxy whose admin API listens on
+// the given address, e.g. "localhost:19000".
+func FetchStatus(ctx context.Context, adminAddr string) (*Status, error) {
+	status := &Status{}
+
+	raw, err := adminGet(ctx, adminAddr, "/server_info")
+	if err != nil {
+		return nil, err
+	}
+	var info struct {
+		Version string `json:"version"`
+		State   string `json:"state"`
+	}
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return nil, fmt.Errorf("invalid server info: %s", err)
+	}
+	status.Version = parseBuildVersion(info.Version)
+	status.State = info.State
+
+	raw, err = adminGet(ctx, adminAddr, "/certs")
+	if err != nil {
+		return nil, err
+	}
+	status.CertExpiry, err = parseCertExpiry(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err = adminGet(ctx, adminAddr, "/stats?format=json&filter=^"+strings.ReplaceAll(controlPlaneConnectedStat, ".", `\.`)+"$")
+	if err != nil {
+		return nil, err
+	}
+	var stats struct {
+		Stats []struct {
+			Name  string      `json:"name"`
+			Value json.Number `json:"value"`
+		} `json:"stats"`
+	}
+	if err := json.Unmarshal(raw, &stats); err != nil {
+		return nil, fmt.Errorf("invalid stats: %s", err)
+	}
+	for _, stat := range stats.Stats {
+		if stat.Name == controlPlaneConnectedStat {
+			status.XDSConnected = stat.Value.String() == "1"
+		}
+	}
+	return status, nil
+}
+
+// parseBuildVersion returns the version from the build version Envoy reports,
+// which has the form "<commit>/<version>/<status>/<build type>/<ssl>".
+func parseBuildVersion(buildVersion string) string {
+	parts := strings.Split(buildVersion, "/")
+	if len(parts) < 2 {
+		return buildVersion
+	}
+	return parts[1]
+}
+
+// parseCertExpiry returns the earliest expiration time of the certificate
+// chains in the response of the /certs endpoint.
+func parseCertExpiry(raw []byte) (time.Time, error) {
+	var certs struct {
+		Certificates []struct {
+			CertChain []struct {
+				ExpirationTime string `json:"expiration_time"`
+			} `json:"cert_chain"`
+		} `json:"certificates"`
+	}
+	if err := json.Unmarshal(raw, &certs); err != nil {
+		return time.Time{}, fmt.Errorf("invalid certificates: %s", err)
+	}
+
+	var earliest time.Time
+	for _, cert := range certs.Certificates {
+		for _, c := range cert.CertChain {
+			if c.ExpirationTime == "" {
+				continue
+			}
+			expiry, err := time.Parse(time.RFC3339, c.ExpirationTime)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid certificate expiration time %q: %s", c.ExpirationTime, err)
+			}
+			if earliest.IsZero() || expiry.Before(earliest) {
+				earliest = expiry
+			}
+		}
+	}
+	return earliest, nil
+}
+
+// adminGet makes a GET request to the path of the admin API listening on the
+// given address and returns the response body.
+func adminGet(ctx context.Context, adminAddr, path string) ([]byte, error) {
+	return adminRequest(ctx, http.MethodGet, adminAddr, path)
+}
+
+// adminRequest makes a request to the path of the admin API listening on the
+// given address and returns the response body.
+func adminRequest(ctx context.Context, method, adminAddr, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("http://%s%s", adminAddr, path), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
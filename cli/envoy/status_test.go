@@ -0,0 +1,77 @@
+package envoy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchStatus(t *testing.T) {
+	cases := map[string]struct {
+		certs  string
+		stats  string
+		expErr string
+		exp    *Status
+	}{
+		"connected with certificates": {
+			certs: `{"certificates": [
+  {"ca_cert": [{"expiration_time": "2032-01-01T00:00:00Z"}], "cert_chain": [{"expiration_time": "2022-06-02T00:00:00Z"}]},
+  {"cert_chain": [{"expiration_time": "2022-06-01T00:00:00Z"}]}
+]}`,
+			stats: `{"stats": [{"name": "control_plane.connected_state", "value": 1}]}`,
+			exp: &Status{
+				Version:      "1.20.1",
+				State:        "LIVE",
+				CertExpiry:   time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC),
+				XDSConnected: true,
+			},
+		},
+		"disconnected without certificates": {
+			certs: `{"certificates": []}`,
+			stats: `{"stats": [{"name": "control_plane.connected_state", "value": 0}]}`,
+			exp: &Status{
+				Version: "1.20.1",
+				State:   "LIVE",
+			},
+		},
+		"invalid expiration time": {
+			certs:  `{"certificates": [{"cert_chain": [{"expiration_time": "tomorrow"}]}]}`,
+			stats:  `{"stats": []}`,
+			expErr: `invalid certificate expiration time "tomorrow"`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/server_info":
+					fmt.Fprint(w, `{"version": "ea23f47b27464794980c05ab290a3b73d801405e/1.20.1/Clean/RELEASE/BoringSSL", "state": "LIVE"}`)
+				case "/certs":
+					fmt.Fprint(w, c.certs)
+				case "/stats":
+					require.Equal(t, "json", r.URL.Query().Get("format"))
+					fmt.Fprint(w, c.stats)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			status, err := FetchStatus(context.Background(), strings.TrimPrefix(server.URL, "http://"))
+			if c.expErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, status)
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package envoy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Formats that a Summary can be rendered in.
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatYAML  = "yaml"
+)
+
+// redacted replaces the hosts of addresses in redacted summaries.
+const redacted = "<redacted>"
+
+// Summary is a typed overview of the configuration of an Envoy proxy: what it
+// listens on, which clusters it connects to, how requests are routed to them
+// and which endpoints they have.
+type Summary struct {
+	Listeners []Listener `json:"listeners"`
+	Clusters  []Cluster  `json:"clusters"`
+	Routes    []Route    `json:"routes"`
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+// Listener is an address Envoy accepts connections on.
+type Listener struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	// Filters are the names of the network filters of the filter chains,
+	// without duplicates.
+	Filters []string `json:"filters"`
+}
+
+// Cluster is a group of upstream endpoints Envoy connects to.
+type Cluster struct {
+	Name string `json:"name"`
+	// Type is the service discovery type, e.g. "EDS" or "STATIC".
+	Type string `json:"type"`
+	// TLS is whether Envoy connects to the endpoints over TLS.
+	TLS bool `json:"tls"`
+	// SNI is the server name sent in the TLS handshake, if any.
+	SNI string `json:"sni,omitempty"`
+}
+
+// Route matches requests of a virtual host of a route configuration and sends
+// them to clusters.
+type Route struct {
+	// Name is the name of the route configuration.
+	Name        string   `json:"name"`
+	VirtualHost string   `json:"virtualHost"`
+	Domains     []string `json:"domains"`
+	// Match is the path prefix, path or regular expression of the route.
+	Match    string   `json:"match"`
+	Clusters []string `json:"clusters"`
+}
+
+// Endpoint is an address of a cluster.
+type Endpoint struct {
+	Cluster string `json:"cluster"`
+	Address string `json:"address"`
+	// Health is the health status of the endpoint, e.g. "HEALTHY". It is
+	// "UNKNOWN" if not set by the control plane.
+	Health string `json:"health"`
+	Weight int    `json:"weight,omitempty"`
+}
+
+// SummaryOptions configure which parts of the configuration are summarized.
+type SummaryOptions struct {
+	// Name only keeps the listeners, clusters, route configurations and
+	// endpoints of clusters whose name contains it.
+	Name string
+	// Redact replaces the hosts of listener and endpoint addresses, e.g. so
+	// that the summary can be shared.
+	Redact bool
+}
+
+// Summarize returns the summary of the configuration dump.
+func Summarize(dump *ConfigDump, opts SummaryOptions) *Summary {
+	summary := &Summary{
+		Listeners: []Listener{},
+		Clusters:  []Cluster{},
+		Routes:    []Route{},
+		Endpoints: []Endpoint{},
+	}
+	keep := func(name string) bool {
+		return strings.Contains(name, opts.Name)
+	}
+	address := func(obj map[string]interface{}) string {
+		addr := socketAddress(obj)
+		if opts.Redact && addr != "" {
+			if _, port, err := net.SplitHostPort(addr); err == nil {
+				return net.JoinHostPort(redacted, port)
+			}
+			return redacted
+		}
+		return addr
+	}
+
+	for _, l := range dump.Listeners {
+		if !keep(name(l)) {
+			continue
+		}
+		listener := Listener{Name: name(l), Address: address(l), Filters: []string{}}
+		seen := make(map[string]bool)
+		for _, chain := range objects(l["filter_chains"]) {
+			for _, filter := range objects(chain["filters"]) {
+				if n := name(filter); !seen[n] {
+					seen[n] = true
+					listener.Filters = append(listener.Filters, n)
+				}
+			}
+		}
+		summary.Listeners = append(summary.Listeners, listener)
+	}
+
+	for _, c := range dump.Clusters {
+		if !keep(name(c)) {
+			continue
+		}
+		cluster := Cluster{Name: name(c)}
+		cluster.Type, _ = c["type"].(string)
+		if cluster.Type == "" {
+			// Clusters with a custom cluster type, e.g. aggregate clusters.
+			custom, _ := c["cluster_type"].(map[string]interface{})
+			cluster.Type = name(custom)
+		}
+		socket, _ := c["transport_socket"].(map[string]interface{})
+		tlsContext, _ := socket["typed_config"].(map[string]interface{})
+		if typeURL, _ := tlsContext["@type"].(string); strings.HasSuffix(typeURL, ".UpstreamTlsContext") {
+			cluster.TLS = true
+			cluster.SNI, _ = tlsContext["sni"].(string)
+		}
+		summary.Clusters = append(summary.Clusters, cluster)
+	}
+
+	for _, r := range dump.Routes {
+		if !keep(name(r)) {
+			continue
+		}
+		for _, vhost := range objects(r["virtual_hosts"]) {
+			domains := stringList(vhost["domains"])
+			for _, route := range objects(vhost["routes"]) {
+				summary.Routes = append(summary.Routes, Route{
+					Name:        name(r),
+					VirtualHost: name(vhost),
+					Domains:     domains,
+					Match:       routeMatch(route),
+					Clusters:    routeClusters(route),
+				})
+			}
+		}
+	}
+
+	for _, e := range dump.Endpoints {
+		clusterName, _ := e["cluster_name"].(string)
+		if !keep(clusterName) {
+			continue
+		}
+		for _, locality := range objects(e["endpoints"]) {
+			for _, lb := range objects(locality["lb_endpoints"]) {
+				endpoint, _ := lb["endpoint"].(map[string]interface{})
+				health, _ := lb["health_status"].(string)
+				if health == "" {
+					health = "UNKNOWN"
+				}
+				weight, _ := lb["load_balancing_weight"].(float64)
+				summary.Endpoints = append(summary.Endpoints, Endpoint{
+					Cluster: clusterName,
+					Address: address(endpoint),
+					Health:  health,
+					Weight:  int(weight),
+				})
+			}
+		}
+	}
+	return summary
+}
+
+// Render writes the summary to w in the format, which is one of FormatTable,
+// FormatJSON or FormatYAML.
+func (s *Summary) Render(w io.Writer, format string) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	case FormatYAML:
+		out, err := yaml.Marshal(s)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	case FormatTable:
+		return s.renderTables(w)
+	default:
+		return fmt.Errorf("unknown format %q, must be one of %q, %q or %q", format, FormatTable, FormatJSON, FormatYAML)
+	}
+}
+
+// renderTables writes a table for each part of the summary that is not empty.
+func (s *Summary) renderTables(w io.Writer) error {
+	var tables []func(tw *tabwriter.Writer)
+	if len(s.Listeners) > 0 {
+		tables = append(tables, func(tw *tabwriter.Writer) {
+			fmt.Fprintln(tw, "LISTENER\tADDRESS\tFILTERS")
+			for _, l := range s.Listeners {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", l.Name, l.Address, strings.Join(l.Filters, ", "))
+			}
+		})
+	}
+	if len(s.Clusters) > 0 {
+		tables = append(tables, func(tw *tabwriter.Writer) {
+			fmt.Fprintln(tw, "CLUSTER\tTYPE\tTLS\tSNI")
+			for _, c := range s.Clusters {
+				fmt.Fprintf(tw, "%s\t%s\t%t\t%s\n", c.Name, c.Type, c.TLS, c.SNI)
+			}
+		})
+	}
+	if len(s.Routes) > 0 {
+		tables = append(tables, func(tw *tabwriter.Writer) {
+			fmt.Fprintln(tw, "ROUTE\tVIRTUAL HOST\tDOMAINS\tMATCH\tCLUSTERS")
+			for _, r := range s.Routes {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Name, r.VirtualHost, strings.Join(r.Domains, ", "), r.Match, strings.Join(r.Clusters, ", "))
+			}
+		})
+	}
+	if len(s.Endpoints) > 0 {
+		tables = append(tables, func(tw *tabwriter.Writer) {
+			fmt.Fprintln(tw, "ENDPOINT\tCLUSTER\tHEALTH\tWEIGHT")
+			for _, e := range s.Endpoints {
+				weight := ""
+				if e.Weight > 0 {
+					weight = strconv.Itoa(e.Weight)
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.Address, e.Cluster, e.Health, weight)
+			}
+		})
+	}
+
+	for i, table := range tables {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		table(tw)
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// socketAddress returns the host and port of the socket address of a listener
+// or endpoint, or the path of its pipe.
+func socketAddress(obj map[string]interface{}) string {
+	addr, _ := obj["address"].(map[string]interface{})
+	if pipe, ok := addr["pipe"].(map[string]interface{}); ok {
+		path, _ := pipe["path"].(string)
+		return path
+	}
+	socket, _ := addr["socket_address"].(map[string]interface{})
+	host, _ := socket["address"].(string)
+	if host == "" {
+		return ""
+	}
+	port, _ := socket["port_value"].(float64)
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
+
+// routeMatch describes the requests matched by the route.
+func routeMatch(route map[string]interface{}) string {
+	match, _ := route["match"].(map[string]interface{})
+	if prefix, ok := match["prefix"].(string); ok {
+		return "prefix " + prefix
+	}
+	if path, ok := match["path"].(string); ok {
+		return "path " + path
+	}
+	if regex, ok := match["safe_regex"].(map[string]interface{}); ok {
+		r, _ := regex["regex"].(string)
+		return "regex " + r
+	}
+	return ""
+}
+
+// routeClusters returns the clusters that the route sends requests to.
+func routeClusters(route map[string]interface{}) []string {
+	action, _ := route["route"].(map[string]interface{})
+	if cluster, ok := action["cluster"].(string); ok {
+		return []string{cluster}
+	}
+	weighted, _ := action["weighted_clusters"].(map[string]interface{})
+	clusters := []string{}
+	for _, c := range objects(weighted["clusters"]) {
+		clusters = append(clusters, name(c))
+	}
+	return clusters
+}
+
+// stringList returns the elements of a JSON array that are strings.
+func stringList(v interface{}) []string {
+	list, _ := v.([]interface{})
+	result := []string{}
+	for _, elem := range list {
+		if s, ok := elem.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
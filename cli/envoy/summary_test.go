@@ -0,0 +1,115 @@
+package envoy
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const summaryConfigDump = `{
+  "configs": [
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.ListenersConfigDump",
+      "dynamic_listeners": [{"name": "public_listener", "active_state": {"listener": {
+        "name": "public_listener",
+        "address": {"socket_address": {"address": "10.0.0.5", "port_value": 20000}},
+        "filter_chains": [
+          {"filters": [{"name": "envoy.filters.network.rbac"}, {"name": "envoy.filters.network.http_connection_manager"}]},
+          {"filters": [{"name": "envoy.filters.network.http_connection_manager"}]}
+        ]
+      }}}]
+    },
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.ClustersConfigDump",
+      "static_clusters": [{"cluster": {"name": "local_app", "type": "STATIC"}}],
+      "dynamic_active_clusters": [{"cluster": {
+        "name": "api.default.dc1.internal.11111111-2222-3333-4444-555555555555.consul",
+        "type": "EDS",
+        "transport_socket": {"name": "tls", "typed_config": {
+          "@type": "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.UpstreamTlsContext",
+          "sni": "api.default.dc1.internal.11111111-2222-3333-4444-555555555555.consul"
+        }}
+      }}]
+    },
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.RoutesConfigDump",
+      "dynamic_route_configs": [{"route_config": {
+        "name": "api",
+        "virtual_hosts": [{"name": "api", "domains": ["*"], "routes": [
+          {"match": {"prefix": "/v2"}, "route": {"weighted_clusters": {"clusters": [{"name": "api-v2"}, {"name": "api-v1"}]}}},
+          {"match": {"prefix": "/"}, "route": {"cluster": "api"}}
+        ]}]
+      }}]
+    },
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.EndpointsConfigDump",
+      "dynamic_endpoint_configs": [{"endpoint_config": {
+        "cluster_name": "api.default.dc1.internal.11111111-2222-3333-4444-555555555555.consul",
+        "endpoints": [{"lb_endpoints": [
+          {"endpoint": {"address": {"socket_address": {"address": "10.0.0.6", "port_value": 20000}}}, "health_status": "HEALTHY", "load_balancing_weight": 1},
+          {"endpoint": {"address": {"socket_address": {"address": "10.0.0.7", "port_value": 20000}}}}
+        ]}]
+      }}]
+    }
+  ]
+}`
+
+func TestSummarize(t *testing.T) {
+	dump, err := ParseConfigDump([]byte(summaryConfigDump))
+	require.NoError(t, err)
+
+	apiCluster := "api.default.dc1.internal.11111111-2222-3333-4444-555555555555.consul"
+	require.Equal(t, &Summary{
+		Listeners: []Listener{{
+			Name:    "public_listener",
+			Address: "10.0.0.5:20000",
+			Filters: []string{"envoy.filters.network.rbac", "envoy.filters.network.http_connection_manager"},
+		}},
+		Clusters: []Cluster{
+			{Name: "local_app", Type: "STATIC"},
+			{Name: apiCluster, Type: "EDS", TLS: true, SNI: apiCluster},
+		},
+		Routes: []Route{
+			{Name: "api", VirtualHost: "api", Domains: []string{"*"}, Match: "prefix /v2", Clusters: []string{"api-v2", "api-v1"}},
+			{Name: "api", VirtualHost: "api", Domains: []string{"*"}, Match: "prefix /", Clusters: []string{"api"}},
+		},
+		Endpoints: []Endpoint{
+			{Cluster: apiCluster, Address: "10.0.0.6:20000", Health: "HEALTHY", Weight: 1},
+			{Cluster: apiCluster, Address: "10.0.0.7:20000", Health: "UNKNOWN"},
+		},
+	}, Summarize(dump, SummaryOptions{}))
+
+	// Filtering by name keeps the API cluster and its route and endpoints.
+	summary := Summarize(dump, SummaryOptions{Name: "api", Redact: true})
+	require.Empty(t, summary.Listeners)
+	require.Len(t, summary.Clusters, 1)
+	require.Len(t, summary.Routes, 2)
+	require.Equal(t, "<redacted>:20000", summary.Endpoints[0].Address)
+}
+
+func TestSummary_Render(t *testing.T) {
+	summary := &Summary{
+		Listeners: []Listener{{Name: "public_listener", Address: "10.0.0.5:20000", Filters: []string{"envoy.filters.network.tcp_proxy"}}},
+		Clusters:  []Cluster{{Name: "api", Type: "EDS", TLS: true, SNI: "api.default.dc1"}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, summary.Render(&buf, FormatTable))
+	require.Equal(t, `LISTENER         ADDRESS         FILTERS
+public_listener  10.0.0.5:20000  envoy.filters.network.tcp_proxy
+
+CLUSTER  TYPE  TLS   SNI
+api      EDS   true  api.default.dc1
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, summary.Render(&buf, FormatJSON))
+	require.Contains(t, buf.String(), `"address": "10.0.0.5:20000"`)
+
+	buf.Reset()
+	require.NoError(t, summary.Render(&buf, FormatYAML))
+	require.Contains(t, buf.String(), "- address: 10.0.0.5:20000\n")
+
+	require.EqualError(t, summary.Render(&buf, "xml"), `unknown format "xml", must be one of "table", "json" or "yaml"`)
+}
@@ -0,0 +1,120 @@
+package envoy
+
+import (
+	"sort"
+	"strconv"
+)
+
+// VersionMismatch is a dynamic resource whose xDS version is not the same in
+// all of the proxies compared.
+type VersionMismatch struct {
+	Type string
+	Name string
+	// Version is the version the proxies are expected to converge to.
+	Version string
+	// Lagging maps the proxies that don't have the expected version to the
+	// version they have. The version is empty if they don't have the resource.
+	Lagging map[string]string
+}
+
+// TypeConvergence counts the resources of a type that have the same version
+// in all of the proxies compared.
+type TypeConvergence struct {
+	Type       string
+	Resources  int
+	Mismatched int
+}
+
+// CompareVersions compares the xDS versions of the dynamic resources of the
+// config dumps, keyed by the name of their proxy. It returns the convergence
+// of each resource type that any proxy has, and the resources that are not at
+// the same version in all of the proxies, sorted by type and name.
+//
+// Consul versions resources with increasing numbers when proxies use the
+// state of the world xDS protocol, in which case the highest version is
+// expected. Otherwise the versions are hashes that can't be ordered and the
+// version most proxies have is expected.
+func CompareVersions(dumps map[string]*ConfigDump) ([]TypeConvergence, []VersionMismatch) {
+	type resourceKey struct{ typ, name string }
+	versions := make(map[resourceKey]map[string]string)
+	for proxy, dump := range dumps {
+		for _, v := range dump.Versions {
+			key := resourceKey{v.Type, v.Name}
+			if versions[key] == nil {
+				versions[key] = make(map[string]string)
+			}
+			versions[key][proxy] = v.Version
+		}
+	}
+
+	keys := make([]resourceKey, 0, len(versions))
+	for key := range versions {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].typ != keys[j].typ {
+			return keys[i].typ < keys[j].typ
+		}
+		return keys[i].name < keys[j].name
+	})
+
+	var convergence []TypeConvergence
+	var mismatches []VersionMismatch
+	for _, key := range keys {
+		if len(convergence) == 0 || convergence[len(convergence)-1].Type != key.typ {
+			convergence = append(convergence, TypeConvergence{Type: key.typ})
+		}
+		conv := &convergence[len(convergence)-1]
+		conv.Resources++
+
+		expected := expectedVersion(versions[key])
+		lagging := make(map[string]string)
+		for proxy := range dumps {
+			if version, ok := versions[key][proxy]; !ok || version != expected {
+				lagging[proxy] = version
+			}
+		}
+		if len(lagging) > 0 {
+			conv.Mismatched++
+			mismatches = append(mismatches, VersionMismatch{
+				Type:    key.typ,
+				Name:    key.name,
+				Version: expected,
+				Lagging: lagging,
+			})
+		}
+	}
+	return convergence, mismatches
+}
+
+// expectedVersion returns the highest of the versions if they are all
+// numbers, and otherwise the most common of them, preferring the lowest in
+// lexical order on a tie so that the result is stable.
+func expectedVersion(versions map[string]string) string {
+	counts := make(map[string]int)
+	numeric := true
+	var highest uint64
+	var highestVersion string
+	for _, version := range versions {
+		counts[version]++
+		n, err := strconv.ParseUint(version, 10, 64)
+		if err != nil {
+			numeric = false
+			continue
+		}
+		if highestVersion == "" || n > highest {
+			highest, highestVersion = n, version
+		}
+	}
+	if numeric {
+		return highestVersion
+	}
+
+	var expected string
+	for version, count := range counts {
+		if count > counts[expected] || (count == counts[expected] && version < expected) {
+			expected = version
+		}
+	}
+	return expected
+}
@@ -0,0 +1,88 @@
+package envoy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfigDump_Versions(t *testing.T) {
+	dump, err := ParseConfigDump([]byte(`{
+  "configs": [
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.ListenersConfigDump",
+      "static_listeners": [{"listener": {"name": "envoy_ready_listener"}}],
+      "dynamic_listeners": [{"name": "public_listener", "active_state": {"version_info": "3", "listener": {"name": "public_listener"}}}]
+    },
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.ClustersConfigDump",
+      "static_clusters": [{"cluster": {"name": "local_app"}}],
+      "dynamic_active_clusters": [{"version_info": "2", "cluster": {"name": "api"}}]
+    },
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.RoutesConfigDump",
+      "dynamic_route_configs": [{"version_info": "1", "route_config": {"name": "api"}}]
+    },
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.EndpointsConfigDump",
+      "dynamic_endpoint_configs": [{"version_info": "2", "endpoint_config": {"cluster_name": "api"}}]
+    },
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.SecretsConfigDump",
+      "dynamic_active_secrets": [{"name": "default", "version_info": "abc", "secret": {"name": "default"}}]
+    }
+  ]
+}`))
+	require.NoError(t, err)
+	require.Equal(t, []ResourceVersion{
+		{Type: ResourceListener, Name: "public_listener", Version: "3"},
+		{Type: ResourceCluster, Name: "api", Version: "2"},
+		{Type: ResourceRoute, Name: "api", Version: "1"},
+		{Type: ResourceEndpoint, Name: "api", Version: "2"},
+		{Type: ResourceSecret, Name: "default", Version: "abc"},
+	}, dump.Versions)
+}
+
+func TestCompareVersions(t *testing.T) {
+	dumps := map[string]*ConfigDump{
+		"web-1": {Versions: []ResourceVersion{
+			{Type: ResourceCluster, Name: "api", Version: "5"},
+			{Type: ResourceCluster, Name: "db", Version: "5"},
+			{Type: ResourceListener, Name: "public_listener", Version: "abc"},
+			{Type: ResourceSecret, Name: "default", Version: "1"},
+		}},
+		"web-2": {Versions: []ResourceVersion{
+			{Type: ResourceCluster, Name: "api", Version: "4"},
+			{Type: ResourceListener, Name: "public_listener", Version: "abc"},
+			{Type: ResourceSecret, Name: "default", Version: "1"},
+		}},
+		"web-3": {Versions: []ResourceVersion{
+			{Type: ResourceCluster, Name: "api", Version: "5"},
+			{Type: ResourceCluster, Name: "db", Version: "5"},
+			{Type: ResourceListener, Name: "public_listener", Version: "def"},
+			{Type: ResourceSecret, Name: "default", Version: "1"},
+		}},
+	}
+
+	convergence, mismatches := CompareVersions(dumps)
+	require.Equal(t, []TypeConvergence{
+		{Type: ResourceCluster, Resources: 2, Mismatched: 2},
+		{Type: ResourceListener, Resources: 1, Mismatched: 1},
+		{Type: ResourceSecret, Resources: 1, Mismatched: 0},
+	}, convergence)
+	require.Equal(t, []VersionMismatch{
+		// The highest numeric version is expected.
+		{Type: ResourceCluster, Name: "api", Version: "5", Lagging: map[string]string{"web-2": "4"}},
+		// Proxies without the resource are lagging.
+		{Type: ResourceCluster, Name: "db", Version: "5", Lagging: map[string]string{"web-2": ""}},
+		// The most common version is expected if versions are not numeric.
+		{Type: ResourceListener, Name: "public_listener", Version: "abc", Lagging: map[string]string{"web-3": "def"}},
+	}, mismatches)
+}
+
+func TestExpectedVersion(t *testing.T) {
+	require.Equal(t, "10", expectedVersion(map[string]string{"a": "9", "b": "10", "c": "9"}))
+	require.Equal(t, "x", expectedVersion(map[string]string{"a": "x", "b": "x", "c": "1"}))
+	// Ties are broken in lexical order.
+	require.Equal(t, "x", expectedVersion(map[string]string{"a": "y", "b": "x"}))
+}
@@ -2,6 +2,8 @@ package helm
 
 import (
 	"embed"
+	"fmt"
+	"os"
 	"path"
 	"strings"
 
@@ -9,6 +11,7 @@ import (
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
 	helmCLI "helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/release"
 )
 
 const (
@@ -27,22 +30,38 @@ func LoadChart(chart embed.FS, chartDirName string) (*chart.Chart, error) {
 	return loader.LoadFiles(chartFiles)
 }
 
+// LoadChartArchive will attempt to load a Helm chart from a local chart
+// archive (.tgz) or an unpacked chart directory. It does not make any network
+// calls so it can be used in air-gapped environments.
+func LoadChartArchive(path string) (*chart.Chart, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("unable to read chart archive %q: %s", path, err)
+	}
+	return loader.Load(path)
+}
+
 // FetchChartValues will attempt to fetch the values from the currently
 // installed Helm chart.
 func FetchChartValues(namespace, name string, settings *helmCLI.EnvSettings, uiLogger action.DebugLog) (map[string]interface{}, error) {
-	cfg := new(action.Configuration)
-	cfg, err := InitActionConfig(cfg, namespace, settings, uiLogger)
+	release, err := FetchRelease(namespace, name, settings, uiLogger)
 	if err != nil {
 		return nil, err
 	}
 
-	status := action.NewStatus(cfg)
-	release, err := status.Run(name)
+	return release.Config, nil
+}
+
+// FetchRelease will attempt to fetch the currently installed Helm release,
+// including the chart it was installed from.
+func FetchRelease(namespace, name string, settings *helmCLI.EnvSettings, uiLogger action.DebugLog) (*release.Release, error) {
+	cfg := new(action.Configuration)
+	cfg, err := InitActionConfig(cfg, namespace, settings, uiLogger)
 	if err != nil {
 		return nil, err
 	}
 
-	return release.Config, nil
+	status := action.NewStatus(cfg)
+	return status.Run(name)
 }
 
 // readChartFiles reads the chart files from the embedded file system, and loads
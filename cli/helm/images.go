@@ -0,0 +1,135 @@
+package helm
+
+import (
+	"strings"
+)
+
+// imageValuePaths are the paths into the Helm values of every image reference
+// used by the Consul chart: Consul, the control plane, Envoy and any
+// component-specific overrides of those images.
+var imageValuePaths = [][]string{
+	{"global", "image"},
+	{"global", "imageK8S"},
+	{"global", "imageEnvoy"},
+	{"server", "image"},
+	{"client", "image"},
+	{"syncCatalog", "image"},
+	{"connectInject", "image"},
+	{"connectInject", "imageConsul"},
+	{"apiGateway", "image"},
+	{"apiProxy", "image"},
+	{"telemetryCollector", "image"},
+}
+
+// RewriteImages returns a copy of vals where every image reference of the
+// chart has been rewritten to be pulled from the given registry mirror.
+// Images that are not overridden in vals are looked up in the chart's default
+// values so that the defaults are mirrored as well.
+func RewriteImages(vals, chartValues map[string]interface{}, mirror string) map[string]interface{} {
+	out := copyMap(vals)
+	for _, p := range imageValuePaths {
+		image, ok := lookupString(out, p)
+		if !ok {
+			image, ok = lookupString(chartValues, p)
+		}
+		if !ok || image == "" {
+			continue
+		}
+		setValue(out, p, MirrorImage(image, mirror))
+	}
+	return out
+}
+
+// MirrorImage rewrites an image reference so that it is pulled from the given
+// registry mirror. If the image already references a registry, that registry is
+// replaced by the mirror, e.g. with a mirror of "registry.internal",
+// "docker.io/envoyproxy/envoy:v1.20.2" becomes
+// "registry.internal/envoyproxy/envoy:v1.20.2". If the path of the mirror ends
+// with the path the repository starts with, it isn't repeated, e.g. with a
+// mirror of "registry.internal/hashicorp", "hashicorp/consul:1.11.4" becomes
+// "registry.internal/hashicorp/consul:1.11.4".
+func MirrorImage(image, mirror string) string {
+	mirror = strings.TrimSuffix(mirror, "/")
+	repository := image
+	if i := strings.Index(image, "/"); i != -1 {
+		// Follow the Docker reference rules: the first component is a
+		// registry host if it contains a '.' or ':' or is "localhost".
+		host := image[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			repository = image[i+1:]
+		}
+	}
+
+	// The first component of the mirror is its registry host, which is never
+	// part of the repository.
+	mirrorPath := strings.Split(mirror, "/")[1:]
+	repositoryPath := strings.Split(repository, "/")
+	for n := len(mirrorPath); n > 0; n-- {
+		if n < len(repositoryPath) && equalPaths(mirrorPath[len(mirrorPath)-n:], repositoryPath[:n]) {
+			repository = strings.Join(repositoryPath[n:], "/")
+			break
+		}
+	}
+	return mirror + "/" + repository
+}
+
+func equalPaths(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupString returns the string value at path p in the nested map m.
+func lookupString(m map[string]interface{}, p []string) (string, bool) {
+	current := m
+	for i, key := range p {
+		v, ok := current[key]
+		if !ok {
+			return "", false
+		}
+		if i == len(p)-1 {
+			s, ok := v.(string)
+			return s, ok
+		}
+		current, ok = v.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// setValue sets the value at path p in the nested map m, creating any
+// intermediate maps as needed.
+func setValue(m map[string]interface{}, p []string, value interface{}) {
+	current := m
+	for _, key := range p[:len(p)-1] {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			current[key] = next
+		}
+		current = next
+	}
+	current[p[len(p)-1]] = value
+}
+
+// copyMap returns a deep copy of the nested maps in m. Non-map values are
+// copied by reference.
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if vm, ok := v.(map[string]interface{}); ok {
+			out[k] = copyMap(vm)
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
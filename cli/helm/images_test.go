@@ -0,0 +1,119 @@
+package helm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirrorImage(t *testing.T) {
+	cases := map[string]struct {
+		image    string
+		mirror   string
+		expected string
+	}{
+		"image without registry": {
+			image:    "hashicorp/consul:1.11.4",
+			mirror:   "registry.internal",
+			expected: "registry.internal/hashicorp/consul:1.11.4",
+		},
+		"image with registry": {
+			image:    "docker.io/envoyproxy/envoy-alpine:v1.20.2",
+			mirror:   "registry.internal/mirror",
+			expected: "registry.internal/mirror/envoyproxy/envoy-alpine:v1.20.2",
+		},
+		"image with registry port": {
+			image:    "localhost:5000/consul:1.11.4",
+			mirror:   "registry.internal/",
+			expected: "registry.internal/consul:1.11.4",
+		},
+		"mirror path repeating the repository namespace": {
+			image:    "hashicorp/consul:1.11.4",
+			mirror:   "registry.internal/hashicorp",
+			expected: "registry.internal/hashicorp/consul:1.11.4",
+		},
+		"mirror path repeating the repository namespace of an image with registry": {
+			image:    "docker.io/hashicorp/consul-k8s-control-plane:0.42.0",
+			mirror:   "registry.internal/mirrors/hashicorp",
+			expected: "registry.internal/mirrors/hashicorp/consul-k8s-control-plane:0.42.0",
+		},
+		"mirror path not repeating the repository namespace": {
+			image:    "envoyproxy/envoy-alpine:v1.20.2",
+			mirror:   "registry.internal/hashicorp",
+			expected: "registry.internal/hashicorp/envoyproxy/envoy-alpine:v1.20.2",
+		},
+		"mirror path matching the whole repository": {
+			image:    "consul",
+			mirror:   "registry.internal/consul",
+			expected: "registry.internal/consul/consul",
+		},
+		"official image": {
+			image:    "consul",
+			mirror:   "registry.internal",
+			expected: "registry.internal/consul",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.expected, MirrorImage(c.image, c.mirror))
+		})
+	}
+}
+
+func TestRewriteImages(t *testing.T) {
+	chartValues := map[string]interface{}{
+		"global": map[string]interface{}{
+			"image":      "hashicorp/consul:1.11.4",
+			"imageK8S":   "hashicorp/consul-k8s-control-plane:0.42.0",
+			"imageEnvoy": "envoyproxy/envoy-alpine:v1.20.2",
+		},
+		"server": map[string]interface{}{
+			"image": nil,
+		},
+		"telemetryCollector": map[string]interface{}{
+			"image": "hashicorp/consul-telemetry-collector:0.0.1",
+		},
+	}
+	vals := map[string]interface{}{
+		"global": map[string]interface{}{
+			"image": "hashicorp/consul-enterprise:1.11.4-ent",
+		},
+		"client": map[string]interface{}{
+			"image": "quay.io/hashicorp/consul:1.11.3",
+		},
+		"apiProxy": map[string]interface{}{
+			"image": "hashicorp/consul-k8s-control-plane:0.42.0",
+		},
+	}
+
+	actual := RewriteImages(vals, chartValues, "registry.internal")
+	require.Equal(t, map[string]interface{}{
+		"global": map[string]interface{}{
+			"image":      "registry.internal/hashicorp/consul-enterprise:1.11.4-ent",
+			"imageK8S":   "registry.internal/hashicorp/consul-k8s-control-plane:0.42.0",
+			"imageEnvoy": "registry.internal/envoyproxy/envoy-alpine:v1.20.2",
+		},
+		"client": map[string]interface{}{
+			"image": "registry.internal/hashicorp/consul:1.11.3",
+		},
+		"apiProxy": map[string]interface{}{
+			"image": "registry.internal/hashicorp/consul-k8s-control-plane:0.42.0",
+		},
+		"telemetryCollector": map[string]interface{}{
+			"image": "registry.internal/hashicorp/consul-telemetry-collector:0.0.1",
+		},
+	}, actual)
+
+	// The original values must not be modified.
+	require.Equal(t, "hashicorp/consul-enterprise:1.11.4-ent", vals["global"].(map[string]interface{})["image"])
+}
+
+func TestLoadChartArchive(t *testing.T) {
+	actual, err := LoadChartArchive("test_fixtures/consul")
+	require.NoError(t, err)
+	require.Equal(t, "Foo", actual.Metadata.Name)
+
+	_, err = LoadChartArchive("test_fixtures/does-not-exist.tgz")
+	require.Error(t, err)
+}
@@ -0,0 +1,177 @@
+package helm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"helm.sh/helm/v3/pkg/kube"
+	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	kuberesource "k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// liveMetadataFields are the metadata fields the API server maintains on every object. They are
+// left out of the live diff since they change on every write and aren't set by the chart.
+var liveMetadataFields = []string{"managedFields", "resourceVersion", "generation", "uid", "creationTimestamp", "selfLink"}
+
+// DiffLiveManifests returns a diff, like DiffManifests, between the resources in the cluster and
+// the state they would have once Helm applies the target manifests of a release. The original
+// manifests are those of the release that is currently installed, or empty if there is none.
+//
+// Each resource of the target manifests is fetched from the cluster. If it exists, the patch
+// Helm would send is computed the same way Helm does, from the original manifest, the target
+// manifest and the live object, and sent to the API server as a dry run, so that the diff includes
+// the defaults and admission changes of the API server but no change is made. If it doesn't exist,
+// it is shown as added. Resources of the original manifests that are not in the target manifests
+// and still exist are shown as removed.
+func DiffLiveManifests(client kube.Interface, original, target string) (string, error) {
+	originalResources, err := client.Build(strings.NewReader(original), false)
+	if err != nil {
+		return "", fmt.Errorf("error building the current manifests: %s", err)
+	}
+	targetResources, err := client.Build(strings.NewReader(target), false)
+	if err != nil {
+		return "", fmt.Errorf("error building the rendered manifests: %s", err)
+	}
+	return diffLive(originalResources, targetResources)
+}
+
+// diffLive returns the diff of DiffLiveManifests for resources that have already been built.
+func diffLive(original, target kube.ResourceList) (string, error) {
+	var live, applied strings.Builder
+
+	for _, info := range target {
+		current, err := kuberesource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+		if apierrors.IsNotFound(err) {
+			if err := writeObject(&applied, info.Object, info.Namespace); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("error getting %s %s: %s", info.Mapping.GroupVersionKind.Kind, info.ObjectName(), err)
+		}
+
+		updated, err := dryRunPatch(originalObject(original, info), info, current)
+		if err != nil {
+			return "", fmt.Errorf("error computing the changes to %s %s: %s", info.Mapping.GroupVersionKind.Kind, info.ObjectName(), err)
+		}
+		if err := writeObject(&live, current, info.Namespace); err != nil {
+			return "", err
+		}
+		if err := writeObject(&applied, updated, info.Namespace); err != nil {
+			return "", err
+		}
+	}
+
+	for _, info := range original.Difference(target) {
+		current, err := kuberesource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("error getting %s %s: %s", info.Mapping.GroupVersionKind.Kind, info.ObjectName(), err)
+		}
+		if err := writeObject(&live, current, info.Namespace); err != nil {
+			return "", err
+		}
+	}
+
+	return DiffManifests(live.String(), applied.String())
+}
+
+// originalObject returns the object of the original manifests that matches target, or nil if
+// there is none, e.g. on install or when the resource is added by an upgrade.
+func originalObject(original kube.ResourceList, target *kuberesource.Info) runtime.Object {
+	for _, info := range original {
+		if isMatchingInfo(info, target) {
+			return info.Object
+		}
+	}
+	return nil
+}
+
+// isMatchingInfo is the comparison kube.ResourceList uses to match resources.
+func isMatchingInfo(a, b *kuberesource.Info) bool {
+	return a.Name == b.Name && a.Namespace == b.Namespace && a.Mapping.GroupVersionKind.Kind == b.Mapping.GroupVersionKind.Kind
+}
+
+// dryRunPatch sends the patch Helm sends when it updates the current object to the target as a
+// dry run and returns the resulting object. Like Helm, it uses a three-way strategic merge patch
+// for built-in kinds and a JSON merge patch for custom resources.
+func dryRunPatch(original runtime.Object, target *kuberesource.Info, current runtime.Object) (runtime.Object, error) {
+	originalData := []byte("{}")
+	if original != nil {
+		var err error
+		if originalData, err = json.Marshal(original); err != nil {
+			return nil, err
+		}
+	}
+	targetData, err := json.Marshal(target.Object)
+	if err != nil {
+		return nil, err
+	}
+	currentData, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+
+	var patch []byte
+	var patchType types.PatchType
+	versioned := kube.AsVersioned(target)
+	_, isUnstructured := versioned.(runtime.Unstructured)
+	_, isCRD := versioned.(*apiextv1beta1.CustomResourceDefinition)
+	if isUnstructured || isCRD {
+		patchType = types.MergePatchType
+		patch, err = jsonpatch.CreateMergePatch(originalData, targetData)
+	} else {
+		patchType = types.StrategicMergePatchType
+		var patchMeta strategicpatch.LookupPatchMeta
+		patchMeta, err = strategicpatch.NewPatchMetaFromStruct(versioned)
+		if err == nil {
+			patch, err = strategicpatch.CreateThreeWayMergePatch(originalData, targetData, currentData, patchMeta, true)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if string(patch) == "{}" {
+		return current, nil
+	}
+
+	return kuberesource.NewHelper(target.Client, target.Mapping).DryRun(true).Patch(target.Namespace, target.Name, patchType, patch, &metav1.PatchOptions{})
+}
+
+// writeObject appends obj to a YAML document stream, without its status and the metadata fields
+// maintained by the API server. The namespace is set for namespaced resources whose manifest
+// leaves it to the release namespace, so that they match their live object.
+func writeObject(b *strings.Builder, obj runtime.Object, namespace string) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj.DeepCopyObject())
+	if err != nil {
+		return err
+	}
+	delete(content, "status")
+	if metadata, ok := content["metadata"].(map[string]interface{}); ok {
+		for _, field := range liveMetadataFields {
+			delete(metadata, field)
+		}
+		if namespace != "" {
+			metadata["namespace"] = namespace
+		}
+	}
+
+	doc, err := yaml.Marshal(content)
+	if err != nil {
+		return err
+	}
+	b.WriteString("---\n")
+	b.Write(doc)
+	return nil
+}
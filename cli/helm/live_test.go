@@ -0,0 +1,132 @@
+package helm
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/kube"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	kuberesource "k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/rest/fake"
+)
+
+// TestDiffLive tests that the rendered manifests are diffed against the live
+// objects as updated by a dry run of the patch Helm sends.
+func TestDiffLive(t *testing.T) {
+	live := map[string]string{
+		// The API server allocated the cluster IP and set the status.
+		"consul-ui":     `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "consul-ui", "namespace": "consul", "resourceVersion": "12", "uid": "abc"}, "spec": {"type": "ClusterIP", "clusterIP": "10.0.0.1"}, "status": {"loadBalancer": {}}}`,
+		"consul-server": `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "consul-server", "namespace": "consul"}, "spec": {"clusterIP": "None"}}`,
+		"consul-old":    `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "consul-old", "namespace": "consul"}, "spec": {"clusterIP": "10.0.0.2"}}`,
+		// A user added a label to the live object that the chart doesn't set.
+		"consul-dns": `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "consul-dns", "namespace": "consul", "labels": {"team": "platform"}}, "spec": {"clusterIP": "10.0.0.3"}}`,
+	}
+	var patched []string
+	client := &fake.RESTClient{
+		NegotiatedSerializer: kuberesource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+		GroupVersion:         schema.GroupVersion{Version: "v1"},
+		VersionedAPIPath:     "/api/v1",
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			name := path.Base(req.URL.Path)
+			obj, ok := live[name]
+			if !ok {
+				return jsonResponse(http.StatusNotFound, `{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "NotFound", "code": 404}`), nil
+			}
+			switch req.Method {
+			case http.MethodGet:
+				return jsonResponse(http.StatusOK, obj), nil
+			case http.MethodPatch:
+				require.Equal(t, "All", req.URL.Query().Get("dryRun"), "patches must be dry runs")
+				patch, err := ioutil.ReadAll(req.Body)
+				require.NoError(t, err)
+				updated, err := strategicpatch.StrategicMergePatch([]byte(obj), patch, &corev1.Service{})
+				require.NoError(t, err)
+				patched = append(patched, name)
+				return jsonResponse(http.StatusOK, string(updated)), nil
+			}
+			t.Fatalf("unexpected request %s %s", req.Method, req.URL)
+			return nil, nil
+		}),
+	}
+	info := func(manifest string) *kuberesource.Info {
+		obj := &unstructured.Unstructured{}
+		require.NoError(t, json.Unmarshal([]byte(manifest), &obj.Object))
+		return &kuberesource.Info{
+			Client: client,
+			Mapping: &meta.RESTMapping{
+				Resource:         schema.GroupVersionResource{Version: "v1", Resource: "services"},
+				GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "Service"},
+				Scope:            meta.RESTScopeNamespace,
+			},
+			Namespace: "consul",
+			Name:      obj.GetName(),
+			Object:    obj,
+		}
+	}
+
+	original := kube.ResourceList{
+		info(`{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "consul-ui"}, "spec": {"type": "ClusterIP"}}`),
+		info(`{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "consul-server"}, "spec": {"clusterIP": "None"}}`),
+		info(`{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "consul-old"}}`),
+	}
+	target := kube.ResourceList{
+		info(`{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "consul-ui"}, "spec": {"type": "LoadBalancer"}}`),
+		info(`{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "consul-server"}, "spec": {"clusterIP": "None"}}`),
+		info(`{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "consul-dns"}, "spec": {"type": "ClusterIP"}}`),
+		info(`{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "consul-new"}}`),
+	}
+
+	diff, err := diffLive(original, target)
+	require.NoError(t, err)
+	require.Equal(t, `consul, consul-dns, Service (v1) has changed:
+@@ -7,3 +7,4 @@
+   namespace: consul
+ spec:
+   clusterIP: 10.0.0.3
++  type: ClusterIP
+
+consul, consul-new, Service (v1) has been added:
+@@ -0,0 +1,5 @@
++apiVersion: v1
++kind: Service
++metadata:
++  name: consul-new
++  namespace: consul
+
+consul, consul-old, Service (v1) has been removed:
+@@ -1,7 +0,0 @@
+-apiVersion: v1
+-kind: Service
+-metadata:
+-  name: consul-old
+-  namespace: consul
+-spec:
+-  clusterIP: 10.0.0.2
+
+consul, consul-ui, Service (v1) has changed:
+@@ -5,4 +5,4 @@
+   namespace: consul
+ spec:
+   clusterIP: 10.0.0.1
+-  type: ClusterIP
++  type: LoadBalancer
+
+`, diff)
+	// The unchanged service isn't patched at all.
+	require.ElementsMatch(t, []string{"consul-ui", "consul-dns"}, patched)
+}
+
+func jsonResponse(code int, body string) *http.Response {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	return &http.Response{StatusCode: code, Header: header, Body: ioutil.NopCloser(bytes.NewReader([]byte(body)))}
+}
@@ -0,0 +1,214 @@
+package helm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"helm.sh/helm/v3/pkg/release"
+	"sigs.k8s.io/yaml"
+)
+
+// manifestSeparator separates the Kubernetes resources in a rendered manifest.
+const manifestSeparator = "\n---"
+
+// resource is the subset of a Kubernetes resource used to identify it in a manifest.
+type resource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+}
+
+// ReleaseManifests returns all the manifests rendered for a Helm release,
+// including the manifests of its hooks, as a single YAML document stream.
+func ReleaseManifests(rel *release.Release) string {
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(rel.Manifest))
+	for _, hook := range rel.Hooks {
+		b.WriteString(manifestSeparator)
+		b.WriteString(fmt.Sprintf("\n# Source: %s\n", hook.Path))
+		b.WriteString(strings.TrimSpace(hook.Manifest))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// InstallManifests returns the manifests that Helm applies when installing
+// the release, in the order it applies them: the pre-install hooks, the
+// release and the post-install hooks. Hooks are ordered by their weight.
+// Other hooks, such as pre-delete and test hooks, are left out, as are the
+// manifests of empty templates.
+func InstallManifests(rel *release.Release) string {
+	var b strings.Builder
+	write := func(path, manifest string) {
+		if strings.TrimSpace(manifest) == "" {
+			return
+		}
+		b.WriteString("---")
+		if path != "" {
+			b.WriteString(fmt.Sprintf("\n# Source: %s", path))
+		}
+		b.WriteString("\n")
+		b.WriteString(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(manifest), "---")))
+		b.WriteString("\n")
+	}
+	writeHooks := func(event release.HookEvent) {
+		var hooks []*release.Hook
+		for _, hook := range rel.Hooks {
+			for _, e := range hook.Events {
+				if e == event {
+					hooks = append(hooks, hook)
+					break
+				}
+			}
+		}
+		sort.SliceStable(hooks, func(i, j int) bool {
+			if hooks[i].Weight != hooks[j].Weight {
+				return hooks[i].Weight < hooks[j].Weight
+			}
+			return hooks[i].Name < hooks[j].Name
+		})
+		for _, hook := range hooks {
+			write(hook.Path, hook.Manifest)
+		}
+	}
+
+	writeHooks(release.HookPreInstall)
+	write("", rel.Manifest)
+	writeHooks(release.HookPostInstall)
+	return b.String()
+}
+
+// DiffManifests returns a unified diff of each Kubernetes resource that differs
+// between the current and the upgraded manifests. Resources are matched by their
+// namespace, name, kind and API version. Added lines are prefixed with "+" and
+// removed lines with "-". If the manifests are identical, the returned string is
+// empty.
+func DiffManifests(current, upgraded string) (string, error) {
+	currentResources, err := parseManifests(current)
+	if err != nil {
+		return "", err
+	}
+	upgradedResources, err := parseManifests(upgraded)
+	if err != nil {
+		return "", err
+	}
+
+	keys := make([]string, 0, len(currentResources)+len(upgradedResources))
+	for key := range currentResources {
+		keys = append(keys, key)
+	}
+	for key := range upgradedResources {
+		if _, ok := currentResources[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	buf := new(strings.Builder)
+	for _, key := range keys {
+		a, inCurrent := currentResources[key]
+		b, inUpgraded := upgradedResources[key]
+
+		var header string
+		switch {
+		case inCurrent && inUpgraded:
+			if a == b {
+				continue
+			}
+			header = fmt.Sprintf("%s has changed:", key)
+		case inUpgraded:
+			header = fmt.Sprintf("%s has been added:", key)
+		default:
+			header = fmt.Sprintf("%s has been removed:", key)
+		}
+
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:       splitLines(a),
+			B:       splitLines(b),
+			Context: 3,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		buf.WriteString(header)
+		buf.WriteString("\n")
+		for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+			// Skip the file headers since there are no files to compare.
+			if strings.HasPrefix(line, "---") || strings.HasPrefix(line, "+++") {
+				continue
+			}
+			buf.WriteString(line)
+			buf.WriteString("\n")
+		}
+		buf.WriteString("\n")
+	}
+
+	return buf.String(), nil
+}
+
+// ManifestsOfKind returns the manifests of the Kubernetes resources of the
+// given kind in a YAML document stream, sorted by their identifier.
+func ManifestsOfKind(manifests, kind string) ([]string, error) {
+	resources, err := parseManifests(manifests)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(resources))
+	for key := range resources {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var docs []string
+	for _, key := range keys {
+		var r resource
+		if err := yaml.Unmarshal([]byte(resources[key]), &r); err != nil {
+			return nil, fmt.Errorf("error parsing manifest: %s", err)
+		}
+		if r.Kind == kind {
+			docs = append(docs, resources[key])
+		}
+	}
+	return docs, nil
+}
+
+// parseManifests splits a YAML document stream into its Kubernetes resources,
+// keyed by a human-readable identifier of the resource.
+func parseManifests(manifests string) (map[string]string, error) {
+	resources := make(map[string]string)
+	for _, doc := range strings.Split(manifests, manifestSeparator) {
+		doc = strings.TrimPrefix(strings.TrimSpace(doc), "---")
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+
+		var r resource
+		if err := yaml.Unmarshal([]byte(doc), &r); err != nil {
+			return nil, fmt.Errorf("error parsing manifest: %s", err)
+		}
+		// Documents that only contain comments, e.g. empty templates, are not resources.
+		if r.Kind == "" {
+			continue
+		}
+
+		key := fmt.Sprintf("%s, %s, %s (%s)", r.Metadata.Namespace, r.Metadata.Name, r.Kind, r.APIVersion)
+		resources[key] = strings.TrimSpace(doc) + "\n"
+	}
+	return resources, nil
+}
+
+// splitLines splits s into lines, keeping the trailing newline of each line as
+// expected by difflib.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
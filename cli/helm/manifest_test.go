@@ -0,0 +1,209 @@
+package helm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestDiffManifests(t *testing.T) {
+	current := `---
+# Source: consul/templates/server-service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: consul-server
+  namespace: consul
+spec:
+  clusterIP: None
+---
+# Source: consul/templates/ui-service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: consul-ui
+  namespace: consul
+spec:
+  type: ClusterIP
+`
+	upgraded := `---
+# Source: consul/templates/server-service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: consul-server
+  namespace: consul
+spec:
+  clusterIP: None
+---
+# Source: consul/templates/ui-service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: consul-ui
+  namespace: consul
+spec:
+  type: LoadBalancer
+---
+# Source: consul/templates/dns-service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: consul-dns
+  namespace: consul
+`
+
+	cases := map[string]struct {
+		current  string
+		upgraded string
+		expected string
+	}{
+		"identical manifests": {
+			current:  current,
+			upgraded: current,
+			expected: "",
+		},
+		"changed and added resources": {
+			current:  current,
+			upgraded: upgraded,
+			expected: `consul, consul-dns, Service (v1) has been added:
+@@ -0,0 +1,6 @@
++# Source: consul/templates/dns-service.yaml
++apiVersion: v1
++kind: Service
++metadata:
++  name: consul-dns
++  namespace: consul
+
+consul, consul-ui, Service (v1) has changed:
+@@ -5,4 +5,4 @@
+   name: consul-ui
+   namespace: consul
+ spec:
+-  type: ClusterIP
++  type: LoadBalancer
+
+`,
+		},
+		"removed resources": {
+			current:  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: consul-client-config\n",
+			upgraded: "",
+			expected: `, consul-client-config, ConfigMap (v1) has been removed:
+@@ -1,4 +0,0 @@
+-apiVersion: v1
+-kind: ConfigMap
+-metadata:
+-  name: consul-client-config
+
+`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual, err := DiffManifests(c.current, c.upgraded)
+			require.NoError(t, err)
+			require.Equal(t, c.expected, actual)
+		})
+	}
+}
+
+func TestReleaseManifests(t *testing.T) {
+	rel := &release.Release{
+		Manifest: "---\napiVersion: v1\nkind: Service\n",
+		Hooks: []*release.Hook{
+			{
+				Path:     "consul/templates/tests/test-runner.yaml",
+				Manifest: "apiVersion: v1\nkind: Pod\n",
+			},
+		},
+	}
+
+	require.Equal(t, "---\napiVersion: v1\nkind: Service\n---\n# Source: consul/templates/tests/test-runner.yaml\napiVersion: v1\nkind: Pod\n", ReleaseManifests(rel))
+}
+
+func TestInstallManifests(t *testing.T) {
+	rel := &release.Release{
+		Manifest: "---\n# Source: consul/templates/server-service.yaml\napiVersion: v1\nkind: Service\n",
+		Hooks: []*release.Hook{
+			{
+				Name:     "consul-server-acl-init-cleanup",
+				Path:     "consul/templates/server-acl-init-cleanup-job.yaml",
+				Manifest: "apiVersion: batch/v1\nkind: Job\n",
+				Events:   []release.HookEvent{release.HookPostInstall, release.HookPostUpgrade},
+			},
+			{
+				Name:     "consul-tls-init",
+				Path:     "consul/templates/tls-init-job.yaml",
+				Manifest: "apiVersion: batch/v1\nkind: Job\n",
+				Events:   []release.HookEvent{release.HookPreInstall, release.HookPreUpgrade},
+				Weight:   1,
+			},
+			{
+				Name:     "consul-tls-init",
+				Path:     "consul/templates/tls-init-serviceaccount.yaml",
+				Manifest: "apiVersion: v1\nkind: ServiceAccount\n",
+				Events:   []release.HookEvent{release.HookPreInstall, release.HookPreUpgrade},
+			},
+			{
+				Name:     "consul-test",
+				Path:     "consul/templates/tests/test-runner.yaml",
+				Manifest: "apiVersion: v1\nkind: Pod\n",
+				Events:   []release.HookEvent{release.HookTest},
+			},
+			{
+				Name:     "consul-tls-init-cleanup",
+				Path:     "consul/templates/tls-init-cleanup-job.yaml",
+				Manifest: "apiVersion: batch/v1\nkind: Job\n",
+				Events:   []release.HookEvent{release.HookPreDelete},
+			},
+		},
+	}
+
+	expected := `---
+# Source: consul/templates/tls-init-serviceaccount.yaml
+apiVersion: v1
+kind: ServiceAccount
+---
+# Source: consul/templates/tls-init-job.yaml
+apiVersion: batch/v1
+kind: Job
+---
+# Source: consul/templates/server-service.yaml
+apiVersion: v1
+kind: Service
+---
+# Source: consul/templates/server-acl-init-cleanup-job.yaml
+apiVersion: batch/v1
+kind: Job
+`
+	require.Equal(t, expected, InstallManifests(rel))
+}
+
+func TestManifestsOfKind(t *testing.T) {
+	manifests := `---
+# Source: consul/templates/server-service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: consul-server
+---
+# Source: consul/templates/crd-meshes.yaml
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: meshes.consul.hashicorp.com
+---
+# Source: consul/templates/crd-exportedservices.yaml
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: exportedservices.consul.hashicorp.com
+`
+	docs, err := ManifestsOfKind(manifests, "CustomResourceDefinition")
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	require.Contains(t, docs[0], "name: exportedservices.consul.hashicorp.com")
+	require.Contains(t, docs[1], "name: meshes.consul.hashicorp.com")
+}
@@ -0,0 +1,230 @@
+package helm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"sigs.k8s.io/yaml"
+)
+
+// ValuesError is returned by ValidateValues and lists all the problems of
+// the values with the paths of the keys they were found at.
+type ValuesError struct {
+	Problems []string
+}
+
+func (e *ValuesError) Error() string {
+	return fmt.Sprintf("Invalid Helm values:\n  - %s", strings.Join(e.Problems, "\n  - "))
+}
+
+// ValidateValues checks the values that the user set against the Values
+// struct of the chart: all keys must exist in the chart's values.yaml and
+// their values must have its types. If they do, the values are merged with
+// the chart's default values and checked for settings that are mutually
+// exclusive.
+//
+// The chart fails to render with most of these problems too, but only one at
+// a time and often with an error from deep inside a template.
+func ValidateValues(chrt *chart.Chart, values map[string]interface{}) error {
+	var problems []string
+	validateValue(reflect.TypeOf(Values{}), "", values, &problems)
+	if len(problems) > 0 {
+		return &ValuesError{Problems: problems}
+	}
+
+	merged, err := chartutil.CoalesceValues(chrt, values)
+	if err != nil {
+		return err
+	}
+	valuesYaml, err := yaml.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	var typed Values
+	if err := yaml.Unmarshal(valuesYaml, &typed); err != nil {
+		return err
+	}
+	if problems := exclusiveSettings(typed); len(problems) > 0 {
+		return &ValuesError{Problems: problems}
+	}
+	return nil
+}
+
+// validateValue appends the problems of the value at path to problems when
+// it doesn't have type t.
+func validateValue(t reflect.Type, path string, value interface{}, problems *[]string) {
+	// Null resets a key to the chart's default value.
+	if value == nil {
+		return
+	}
+
+	mismatch := func(expected string) {
+		*problems = append(*problems, fmt.Sprintf("%s: expected %s, got %s", path, expected, describe(value)))
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+	case reflect.Struct, reflect.Map:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			mismatch("a map")
+			return
+		}
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			if t.Kind() == reflect.Map {
+				validateValue(t.Elem(), keyPath, m[key], problems)
+				continue
+			}
+			field, ok := fieldForKey(t, key)
+			if !ok {
+				problem := fmt.Sprintf("%s: unknown key", keyPath)
+				if similar, ok := fieldForKeyFold(t, key); ok {
+					problem += fmt.Sprintf(", did you mean %q?", similar)
+				}
+				*problems = append(*problems, problem)
+				continue
+			}
+			validateValue(field.Type, keyPath, m[key], problems)
+		}
+	case reflect.Slice:
+		l, ok := value.([]interface{})
+		if !ok {
+			mismatch("a list")
+			return
+		}
+		for i, elem := range l {
+			validateValue(t.Elem(), fmt.Sprintf("%s[%d]", path, i), elem, problems)
+		}
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			mismatch("a boolean")
+		}
+	case reflect.Int:
+		if !isInteger(value) {
+			mismatch("an integer")
+		}
+	case reflect.Float64:
+		if !isInteger(value) {
+			if _, ok := value.(float64); !ok {
+				mismatch("a number")
+			}
+		}
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			mismatch("a string")
+		}
+	}
+}
+
+// fieldForKey returns the field of the struct type t with the YAML key.
+func fieldForKey(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("yaml") == key {
+			return t.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// fieldForKeyFold returns the YAML key of the field of the struct type t
+// whose key only differs in case from key, e.g. imageK8S for imageK8s.
+func fieldForKeyFold(t reflect.Type, key string) (string, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("yaml"); strings.EqualFold(tag, key) {
+			return tag, true
+		}
+	}
+	return "", false
+}
+
+// isInteger returns whether the value is an integer. Values files are
+// decoded from JSON, so their numbers are float64s, while --set values are
+// int64s.
+func isInteger(value interface{}) bool {
+	switch v := value.(type) {
+	case int, int32, int64:
+		return true
+	case float64:
+		return v == float64(int64(v))
+	}
+	return false
+}
+
+func describe(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case bool:
+		return fmt.Sprintf("boolean %t", v)
+	case map[string]interface{}:
+		return "a map"
+	case []interface{}:
+		return "a list"
+	case int, int32, int64, float64:
+		return fmt.Sprintf("number %v", v)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// exclusiveSettings returns the settings of the values, merged with the
+// chart's default values, that can't be used together.
+func exclusiveSettings(v Values) []string {
+	var problems []string
+	exclusive := func(a, b string) {
+		problems = append(problems, fmt.Sprintf("%s and %s are mutually exclusive", a, b))
+	}
+
+	if inherit(v.Server.Enabled, v.Global.Enabled) && v.ExternalServers.Enabled {
+		exclusive("server.enabled", "externalServers.enabled")
+	}
+	if v.Global.Federation.Enabled && v.Global.AdminPartitions.Enabled {
+		exclusive("global.federation.enabled", "global.adminPartitions.enabled")
+	}
+	if v.Global.GossipEncryption.AutoGenerate {
+		if v.Global.GossipEncryption.SecretName != "" {
+			exclusive("global.gossipEncryption.autoGenerate", "global.gossipEncryption.secretName")
+		}
+		if v.Global.GossipEncryption.SecretKey != "" {
+			exclusive("global.gossipEncryption.autoGenerate", "global.gossipEncryption.secretKey")
+		}
+	}
+	if v.Global.GossipEncryption.SyncKeyring && v.Global.SecretsBackend.Vault.Enabled {
+		exclusive("global.gossipEncryption.syncKeyring", "global.secretsBackend.vault.enabled")
+	}
+	if v.Global.TLS.Enabled && v.Global.TLS.ServerCertRenewal.Enabled {
+		if v.Server.ServerCert.SecretName != "" {
+			exclusive("global.tls.serverCertRenewal.enabled", "server.serverCert.secretName")
+		}
+		if v.Global.SecretsBackend.Vault.Enabled {
+			exclusive("global.tls.serverCertRenewal.enabled", "global.secretsBackend.vault.enabled")
+		}
+	}
+	if v.MeshGateway.Enabled && v.Global.Acls.ManageSystemACLs &&
+		v.MeshGateway.ConsulServiceName != "" && v.MeshGateway.ConsulServiceName != "mesh-gateway" {
+		exclusive("global.acls.manageSystemACLs", "meshGateway.consulServiceName")
+	}
+	return problems
+}
+
+// inherit returns the value of a boolean that defaults to "-", i.e. to the
+// value of another key.
+func inherit(value interface{}, inherited bool) bool {
+	if b, ok := value.(bool); ok {
+		return b
+	}
+	return inherited
+}
@@ -0,0 +1,194 @@
+package helm
+
+import (
+	"testing"
+
+	consulChart "github.com/hashicorp/consul-k8s/charts"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+	"sigs.k8s.io/yaml"
+)
+
+func TestValidateValues(t *testing.T) {
+	cases := map[string]struct {
+		values      string
+		expProblems []string
+	}{
+		"no values": {
+			values: ``,
+		},
+		"valid values": {
+			values: `
+global:
+  name: consul
+  datacenter: dc1
+  tls:
+    enabled: true
+    serverAdditionalDNSSANs: ["consul.example.com"]
+server:
+  enabled: true
+  replicas: 3
+  extraLabels:
+    team: mesh
+  resources:
+    requests:
+      ephemeral-storage: 1Gi
+client:
+  enabled: "-"
+  nodeMeta:
+    pod-name: ${HOSTNAME}
+  image: null
+ingressGateways:
+  gateways:
+    - name: ingress-gateway
+      replicas: 2
+`,
+		},
+		"unknown keys": {
+			values: `
+global:
+  foo: bar
+  imageK8s: hashicorp/consul-k8s-control-plane
+server:
+  tls:
+    enabled: true
+bar: {}
+`,
+			expProblems: []string{
+				`bar: unknown key`,
+				`global.foo: unknown key`,
+				`global.imageK8s: unknown key, did you mean "imageK8S"?`,
+				`server.tls: unknown key`,
+			},
+		},
+		"type mismatches": {
+			values: `
+global:
+  enabled: "yes"
+  tls: true
+  recursors: 1.1.1.1
+  federation:
+    primaryGateways: [1, "1.2.3.4:443"]
+server:
+  replicas: three
+  bootstrapExpect: 1.5
+  extraLabels: team
+connectInject:
+  k8sAllowNamespaces: "*"
+`,
+			expProblems: []string{
+				`connectInject.k8sAllowNamespaces: expected a list, got string "*"`,
+				`global.enabled: expected a boolean, got string "yes"`,
+				`global.federation.primaryGateways[0]: expected a string, got number 1`,
+				`global.recursors: expected a list, got string "1.1.1.1"`,
+				`global.tls: expected a map, got boolean true`,
+				`server.bootstrapExpect: expected an integer, got number 1.5`,
+				`server.extraLabels: expected a map, got string "team"`,
+				`server.replicas: expected an integer, got string "three"`,
+			},
+		},
+		"mutually exclusive settings": {
+			values: `
+global:
+  federation:
+    enabled: true
+  adminPartitions:
+    enabled: true
+  gossipEncryption:
+    autoGenerate: true
+    secretName: gossip
+    secretKey: key
+externalServers:
+  enabled: true
+`,
+			expProblems: []string{
+				`server.enabled and externalServers.enabled are mutually exclusive`,
+				`global.federation.enabled and global.adminPartitions.enabled are mutually exclusive`,
+				`global.gossipEncryption.autoGenerate and global.gossipEncryption.secretName are mutually exclusive`,
+				`global.gossipEncryption.autoGenerate and global.gossipEncryption.secretKey are mutually exclusive`,
+			},
+		},
+		"servers disabled with external servers": {
+			values: `
+global:
+  enabled: false
+externalServers:
+  enabled: true
+`,
+		},
+		"server cert renewal": {
+			values: `
+global:
+  tls:
+    enabled: true
+    serverCertRenewal:
+      enabled: true
+  secretsBackend:
+    vault:
+      enabled: true
+server:
+  serverCert:
+    secretName: server-cert
+`,
+			expProblems: []string{
+				`global.tls.serverCertRenewal.enabled and server.serverCert.secretName are mutually exclusive`,
+				`global.tls.serverCertRenewal.enabled and global.secretsBackend.vault.enabled are mutually exclusive`,
+			},
+		},
+		"mesh gateway service name with ACLs": {
+			values: `
+global:
+  acls:
+    manageSystemACLs: true
+meshGateway:
+  enabled: true
+  consulServiceName: gateway
+`,
+			expProblems: []string{
+				`global.acls.manageSystemACLs and meshGateway.consulServiceName are mutually exclusive`,
+			},
+		},
+	}
+
+	chrt := loadConsulChart(t)
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var values map[string]interface{}
+			require.NoError(t, yaml.Unmarshal([]byte(c.values), &values))
+
+			err := ValidateValues(chrt, values)
+			if c.expProblems == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.IsType(t, &ValuesError{}, err)
+			require.Equal(t, c.expProblems, err.(*ValuesError).Problems)
+		})
+	}
+}
+
+// Test that --set values, whose numbers are int64s, are validated.
+func TestValidateValues_SetValues(t *testing.T) {
+	chrt := loadConsulChart(t)
+	require.NoError(t, ValidateValues(chrt, map[string]interface{}{
+		"server": map[string]interface{}{"replicas": int64(3)},
+	}))
+
+	err := ValidateValues(chrt, map[string]interface{}{
+		"server": map[string]interface{}{"storage": int64(10)},
+	})
+	require.EqualError(t, err, "Invalid Helm values:\n  - server.storage: expected a string, got number 10")
+}
+
+// Test that the default values of the chart are valid, i.e. that the
+// Values struct is up to date with values.yaml.
+func TestValidateValues_DefaultValues(t *testing.T) {
+	chrt := loadConsulChart(t)
+	require.NoError(t, ValidateValues(chrt, chrt.Values))
+}
+
+func loadConsulChart(t *testing.T) *chart.Chart {
+	chrt, err := LoadChart(consulChart.ConsulHelmChart, "consul")
+	require.NoError(t, err)
+	return chrt
+}
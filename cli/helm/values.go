@@ -1,6 +1,6 @@
-package helm
+// Code generated by hack/helm-values-gen from charts/consul/values.yaml. DO NOT EDIT.
 
-// HACK this is a temporary hard-coded struct. We should actually generate this from our `values.yaml` file.
+package helm
 
 // Values is the Helm values that may be set for the Consul Helm Chart.
 type Values struct {
@@ -17,27 +17,35 @@ type Values struct {
 	IngressGateways     IngressGateways     `yaml:"ingressGateways"`
 	TerminatingGateways TerminatingGateways `yaml:"terminatingGateways"`
 	APIGateway          APIGateway          `yaml:"apiGateway"`
+	APIProxy            APIProxy            `yaml:"apiProxy"`
+	TelemetryCollector  TelemetryCollector  `yaml:"telemetryCollector"`
 	WebhookCertManager  WebhookCertManager  `yaml:"webhookCertManager"`
 	Prometheus          Prometheus          `yaml:"prometheus"`
 	Tests               Tests               `yaml:"tests"`
 }
 
 type NodePort struct {
-	RPC   interface{} `yaml:"rpc"`
-	Serf  interface{} `yaml:"serf"`
-	HTTPS interface{} `yaml:"https"`
+	RPC   int `yaml:"rpc"`
+	Serf  int `yaml:"serf"`
+	HTTPS int `yaml:"https"`
 }
 
-type AdminPartitionsService struct {
-	Type        string      `yaml:"type"`
-	NodePort    NodePort    `yaml:"nodePort"`
-	Annotations interface{} `yaml:"annotations"`
+type Service struct {
+	Type        string   `yaml:"type"`
+	NodePort    NodePort `yaml:"nodePort"`
+	Annotations string   `yaml:"annotations"`
 }
 
 type AdminPartitions struct {
-	Enabled bool                   `yaml:"enabled"`
-	Name    string                 `yaml:"name"`
-	Service AdminPartitionsService `yaml:"service"`
+	Enabled bool    `yaml:"enabled"`
+	Name    string  `yaml:"name"`
+	Service Service `yaml:"service"`
+}
+
+type Audit struct {
+	Enabled    bool   `yaml:"enabled"`
+	Events     bool   `yaml:"events"`
+	WebhookURL string `yaml:"webhookURL"`
 }
 
 type Ca struct {
@@ -54,14 +62,16 @@ type ConnectCA struct {
 }
 
 type Vault struct {
-	Enabled              bool        `yaml:"enabled"`
-	ConsulServerRole     string      `yaml:"consulServerRole"`
-	ConsulClientRole     string      `yaml:"consulClientRole"`
-	ManageSystemACLsRole string      `yaml:"manageSystemACLsRole"`
-	AgentAnnotations     interface{} `yaml:"agentAnnotations"`
-	ConsulCARole         string      `yaml:"consulCARole"`
-	Ca                   Ca          `yaml:"ca"`
-	ConnectCA            ConnectCA   `yaml:"connectCA"`
+	Enabled                 bool      `yaml:"enabled"`
+	ConsulServerRole        string    `yaml:"consulServerRole"`
+	ConsulClientRole        string    `yaml:"consulClientRole"`
+	ConsulSnapshotAgentRole string    `yaml:"consulSnapshotAgentRole"`
+	ManageSystemACLsRole    string    `yaml:"manageSystemACLsRole"`
+	AdminPartitionsRole     string    `yaml:"adminPartitionsRole"`
+	AgentAnnotations        string    `yaml:"agentAnnotations"`
+	ConsulCARole            string    `yaml:"consulCARole"`
+	Ca                      Ca        `yaml:"ca"`
+	ConnectCA               ConnectCA `yaml:"connectCA"`
 }
 
 type SecretsBackend struct {
@@ -72,6 +82,13 @@ type GossipEncryption struct {
 	AutoGenerate bool   `yaml:"autoGenerate"`
 	SecretName   string `yaml:"secretName"`
 	SecretKey    string `yaml:"secretKey"`
+	SyncKeyring  bool   `yaml:"syncKeyring"`
+}
+
+type ServerCertRenewal struct {
+	Enabled       bool   `yaml:"enabled"`
+	RenewWithin   string `yaml:"renewWithin"`
+	CheckInterval string `yaml:"checkInterval"`
 }
 
 type CaCert struct {
@@ -85,14 +102,15 @@ type CaKey struct {
 }
 
 type TLS struct {
-	Enabled                 bool          `yaml:"enabled"`
-	EnableAutoEncrypt       bool          `yaml:"enableAutoEncrypt"`
-	ServerAdditionalDNSSANs []interface{} `yaml:"serverAdditionalDNSSANs"`
-	ServerAdditionalIPSANs  []interface{} `yaml:"serverAdditionalIPSANs"`
-	Verify                  bool          `yaml:"verify"`
-	HTTPSOnly               bool          `yaml:"httpsOnly"`
-	CaCert                  CaCert        `yaml:"caCert"`
-	CaKey                   CaKey         `yaml:"caKey"`
+	Enabled                 bool              `yaml:"enabled"`
+	EnableAutoEncrypt       bool              `yaml:"enableAutoEncrypt"`
+	ServerAdditionalDNSSANs []string          `yaml:"serverAdditionalDNSSANs"`
+	ServerAdditionalIPSANs  []string          `yaml:"serverAdditionalIPSANs"`
+	Verify                  bool              `yaml:"verify"`
+	HTTPSOnly               bool              `yaml:"httpsOnly"`
+	ServerCertRenewal       ServerCertRenewal `yaml:"serverCertRenewal"`
+	CaCert                  CaCert            `yaml:"caCert"`
+	CaKey                   CaKey             `yaml:"caKey"`
 }
 
 type BootstrapToken struct {
@@ -105,11 +123,17 @@ type ReplicationToken struct {
 	SecretKey  string `yaml:"secretKey"`
 }
 
+type PartitionToken struct {
+	SecretName string `yaml:"secretName"`
+	SecretKey  string `yaml:"secretKey"`
+}
+
 type Acls struct {
 	ManageSystemACLs       bool             `yaml:"manageSystemACLs"`
 	BootstrapToken         BootstrapToken   `yaml:"bootstrapToken"`
 	CreateReplicationToken bool             `yaml:"createReplicationToken"`
 	ReplicationToken       ReplicationToken `yaml:"replicationToken"`
+	PartitionToken         PartitionToken   `yaml:"partitionToken"`
 }
 
 type EnterpriseLicense struct {
@@ -119,36 +143,22 @@ type EnterpriseLicense struct {
 }
 
 type Federation struct {
-	Enabled                bool          `yaml:"enabled"`
-	CreateFederationSecret bool          `yaml:"createFederationSecret"`
-	PrimaryDatacenter      string        `yaml:"primaryDatacenter"`
-	PrimaryGateways        []interface{} `yaml:"primaryGateways"`
+	Enabled                bool     `yaml:"enabled"`
+	CreateFederationSecret bool     `yaml:"createFederationSecret"`
+	PrimaryDatacenter      string   `yaml:"primaryDatacenter"`
+	PrimaryGateways        []string `yaml:"primaryGateways"`
+	K8SAuthMethodHost      string   `yaml:"k8sAuthMethodHost"`
 }
 
-type GlobalMetrics struct {
+type Metrics struct {
 	Enabled                   bool   `yaml:"enabled"`
 	EnableAgentMetrics        bool   `yaml:"enableAgentMetrics"`
 	AgentMetricsRetentionTime string `yaml:"agentMetricsRetentionTime"`
 	EnableGatewayMetrics      bool   `yaml:"enableGatewayMetrics"`
 }
 
-type Requests struct {
-	Memory string `yaml:"memory"`
-	CPU    string `yaml:"cpu"`
-}
-
-type Limits struct {
-	Memory string `yaml:"memory"`
-	CPU    string `yaml:"cpu"`
-}
-
-type Resources struct {
-	Requests Requests `yaml:"requests"`
-	Limits   Limits   `yaml:"limits"`
-}
-
 type ConsulSidecarContainer struct {
-	Resources Resources `yaml:"resources"`
+	Resources map[string]interface{} `yaml:"resources"`
 }
 
 type Openshift struct {
@@ -156,33 +166,35 @@ type Openshift struct {
 }
 
 type Global struct {
-	Enabled                   bool                   `yaml:"enabled"`
-	LogLevel                  string                 `yaml:"logLevel"`
-	LogJSON                   bool                   `yaml:"logJSON"`
-	Name                      interface{}            `yaml:"name"`
-	Domain                    string                 `yaml:"domain"`
-	AdminPartitions           AdminPartitions        `yaml:"adminPartitions"`
-	Image                     string                 `yaml:"image"`
-	ImagePullSecrets          []interface{}          `yaml:"imagePullSecrets"`
-	ImageK8S                  string                 `yaml:"imageK8S"`
-	Datacenter                string                 `yaml:"datacenter"`
-	EnablePodSecurityPolicies bool                   `yaml:"enablePodSecurityPolicies"`
-	SecretsBackend            SecretsBackend         `yaml:"secretsBackend"`
-	GossipEncryption          GossipEncryption       `yaml:"gossipEncryption"`
-	Recursors                 []interface{}          `yaml:"recursors"`
-	TLS                       TLS                    `yaml:"tls"`
-	EnableConsulNamespaces    bool                   `yaml:"enableConsulNamespaces"`
-	Acls                      Acls                   `yaml:"acls"`
-	EnterpriseLicense         EnterpriseLicense      `yaml:"enterpriseLicense"`
-	Federation                Federation             `yaml:"federation"`
-	Metrics                   GlobalMetrics          `yaml:"metrics"`
-	ConsulSidecarContainer    ConsulSidecarContainer `yaml:"consulSidecarContainer"`
-	ImageEnvoy                string                 `yaml:"imageEnvoy"`
-	Openshift                 Openshift              `yaml:"openshift"`
+	Enabled                   bool                     `yaml:"enabled"`
+	LogLevel                  string                   `yaml:"logLevel"`
+	LogJSON                   bool                     `yaml:"logJSON"`
+	Name                      string                   `yaml:"name"`
+	Domain                    string                   `yaml:"domain"`
+	AdminPartitions           AdminPartitions          `yaml:"adminPartitions"`
+	Image                     string                   `yaml:"image"`
+	ImagePullSecrets          []map[string]interface{} `yaml:"imagePullSecrets"`
+	ImageK8S                  string                   `yaml:"imageK8S"`
+	Datacenter                string                   `yaml:"datacenter"`
+	ClusterID                 string                   `yaml:"clusterID"`
+	Audit                     Audit                    `yaml:"audit"`
+	EnablePodSecurityPolicies bool                     `yaml:"enablePodSecurityPolicies"`
+	SecretsBackend            SecretsBackend           `yaml:"secretsBackend"`
+	GossipEncryption          GossipEncryption         `yaml:"gossipEncryption"`
+	Recursors                 []string                 `yaml:"recursors"`
+	TLS                       TLS                      `yaml:"tls"`
+	EnableConsulNamespaces    bool                     `yaml:"enableConsulNamespaces"`
+	Acls                      Acls                     `yaml:"acls"`
+	EnterpriseLicense         EnterpriseLicense        `yaml:"enterpriseLicense"`
+	Federation                Federation               `yaml:"federation"`
+	Metrics                   Metrics                  `yaml:"metrics"`
+	ConsulSidecarContainer    ConsulSidecarContainer   `yaml:"consulSidecarContainer"`
+	ImageEnvoy                string                   `yaml:"imageEnvoy"`
+	Openshift                 Openshift                `yaml:"openshift"`
 }
 
 type ServerCert struct {
-	SecretName interface{} `yaml:"secretName"`
+	SecretName string `yaml:"secretName"`
 }
 
 type Serflan struct {
@@ -194,132 +206,137 @@ type Ports struct {
 }
 
 type ServiceAccount struct {
-	Annotations interface{} `yaml:"annotations"`
-}
-
-type SecurityContext struct {
-	RunAsNonRoot bool `yaml:"runAsNonRoot"`
-	RunAsGroup   int  `yaml:"runAsGroup"`
-	RunAsUser    int  `yaml:"runAsUser"`
-	FsGroup      int  `yaml:"fsGroup"`
+	Annotations string `yaml:"annotations"`
 }
 
 type ContainerSecurityContext struct {
-	Server interface{} `yaml:"server"`
+	Server map[string]interface{} `yaml:"server"`
 }
 
 type DisruptionBudget struct {
-	Enabled        bool        `yaml:"enabled"`
-	MaxUnavailable interface{} `yaml:"maxUnavailable"`
+	Enabled        bool `yaml:"enabled"`
+	MaxUnavailable int  `yaml:"maxUnavailable"`
 }
 
 type ServerService struct {
-	Annotations interface{} `yaml:"annotations"`
-}
-
-type ExtraEnvironmentVars struct {
+	Annotations string `yaml:"annotations"`
 }
 
 type Server struct {
-	Enabled                   string                   `yaml:"enabled"`
-	Image                     interface{}              `yaml:"image"`
+	Enabled                   interface{}              `yaml:"enabled"`
+	Image                     string                   `yaml:"image"`
 	Replicas                  int                      `yaml:"replicas"`
-	BootstrapExpect           interface{}              `yaml:"bootstrapExpect"`
+	BootstrapExpect           int                      `yaml:"bootstrapExpect"`
 	ServerCert                ServerCert               `yaml:"serverCert"`
 	ExposeGossipAndRPCPorts   bool                     `yaml:"exposeGossipAndRPCPorts"`
 	Ports                     Ports                    `yaml:"ports"`
 	Storage                   string                   `yaml:"storage"`
-	StorageClass              interface{}              `yaml:"storageClass"`
+	StorageClass              string                   `yaml:"storageClass"`
 	Connect                   bool                     `yaml:"connect"`
 	ServiceAccount            ServiceAccount           `yaml:"serviceAccount"`
-	Resources                 Resources                `yaml:"resources"`
-	SecurityContext           SecurityContext          `yaml:"securityContext"`
+	Resources                 map[string]interface{}   `yaml:"resources"`
+	SecurityContext           map[string]interface{}   `yaml:"securityContext"`
 	ContainerSecurityContext  ContainerSecurityContext `yaml:"containerSecurityContext"`
 	UpdatePartition           int                      `yaml:"updatePartition"`
 	DisruptionBudget          DisruptionBudget         `yaml:"disruptionBudget"`
 	ExtraConfig               string                   `yaml:"extraConfig"`
-	ExtraVolumes              []interface{}            `yaml:"extraVolumes"`
-	ExtraContainers           []interface{}            `yaml:"extraContainers"`
+	ExtraVolumes              []map[string]interface{} `yaml:"extraVolumes"`
+	ExtraContainers           []map[string]interface{} `yaml:"extraContainers"`
 	Affinity                  string                   `yaml:"affinity"`
 	Tolerations               string                   `yaml:"tolerations"`
 	TopologySpreadConstraints string                   `yaml:"topologySpreadConstraints"`
-	NodeSelector              interface{}              `yaml:"nodeSelector"`
+	NodeSelector              string                   `yaml:"nodeSelector"`
 	PriorityClassName         string                   `yaml:"priorityClassName"`
-	ExtraLabels               interface{}              `yaml:"extraLabels"`
-	Annotations               interface{}              `yaml:"annotations"`
+	ExtraLabels               map[string]interface{}   `yaml:"extraLabels"`
+	Annotations               string                   `yaml:"annotations"`
 	Service                   ServerService            `yaml:"service"`
-	ExtraEnvironmentVars      ExtraEnvironmentVars     `yaml:"extraEnvironmentVars"`
+	ExtraEnvironmentVars      map[string]interface{}   `yaml:"extraEnvironmentVars"`
 }
 
 type ExternalServers struct {
-	Enabled           bool          `yaml:"enabled"`
-	Hosts             []interface{} `yaml:"hosts"`
-	HTTPSPort         int           `yaml:"httpsPort"`
-	TLSServerName     interface{}   `yaml:"tlsServerName"`
-	UseSystemRoots    bool          `yaml:"useSystemRoots"`
-	K8SAuthMethodHost interface{}   `yaml:"k8sAuthMethodHost"`
-}
-
-type NodeMeta struct {
-	PodName string `yaml:"pod-name"`
-	HostIP  string `yaml:"host-ip"`
+	Enabled           bool     `yaml:"enabled"`
+	Hosts             []string `yaml:"hosts"`
+	HTTPSPort         int      `yaml:"httpsPort"`
+	TLSServerName     string   `yaml:"tlsServerName"`
+	UseSystemRoots    bool     `yaml:"useSystemRoots"`
+	K8SAuthMethodHost string   `yaml:"k8sAuthMethodHost"`
 }
 
 type ClientContainerSecurityContext struct {
-	Client  interface{} `yaml:"client"`
-	ACLInit interface{} `yaml:"aclInit"`
-	TLSInit interface{} `yaml:"tlsInit"`
+	Client  map[string]interface{} `yaml:"client"`
+	ACLInit map[string]interface{} `yaml:"aclInit"`
+	TLSInit map[string]interface{} `yaml:"tlsInit"`
 }
 
 type ConfigSecret struct {
-	SecretName interface{} `yaml:"secretName"`
-	SecretKey  interface{} `yaml:"secretKey"`
+	SecretName string `yaml:"secretName"`
+	SecretKey  string `yaml:"secretKey"`
+}
+
+type Schedules struct {
+	Enabled bool `yaml:"enabled"`
 }
 
 type SnapshotAgent struct {
-	Enabled        bool           `yaml:"enabled"`
-	Replicas       int            `yaml:"replicas"`
-	ConfigSecret   ConfigSecret   `yaml:"configSecret"`
-	ServiceAccount ServiceAccount `yaml:"serviceAccount"`
-	Resources      Resources      `yaml:"resources"`
-	CaCert         interface{}    `yaml:"caCert"`
+	Enabled        bool                   `yaml:"enabled"`
+	Replicas       int                    `yaml:"replicas"`
+	ConfigSecret   ConfigSecret           `yaml:"configSecret"`
+	ServiceAccount ServiceAccount         `yaml:"serviceAccount"`
+	Resources      map[string]interface{} `yaml:"resources"`
+	CaCert         string                 `yaml:"caCert"`
+	Schedules      Schedules              `yaml:"schedules"`
 }
 
 type Client struct {
-	Enabled                  string                         `yaml:"enabled"`
-	Image                    interface{}                    `yaml:"image"`
-	Join                     interface{}                    `yaml:"join"`
-	DataDirectoryHostPath    interface{}                    `yaml:"dataDirectoryHostPath"`
+	Enabled                  interface{}                    `yaml:"enabled"`
+	Image                    string                         `yaml:"image"`
+	Join                     []string                       `yaml:"join"`
+	DataDirectoryHostPath    string                         `yaml:"dataDirectoryHostPath"`
 	Grpc                     bool                           `yaml:"grpc"`
-	NodeMeta                 NodeMeta                       `yaml:"nodeMeta"`
+	NodeMeta                 map[string]interface{}         `yaml:"nodeMeta"`
 	ExposeGossipPorts        bool                           `yaml:"exposeGossipPorts"`
 	ServiceAccount           ServiceAccount                 `yaml:"serviceAccount"`
-	Resources                Resources                      `yaml:"resources"`
-	SecurityContext          SecurityContext                `yaml:"securityContext"`
+	Resources                map[string]interface{}         `yaml:"resources"`
+	SecurityContext          map[string]interface{}         `yaml:"securityContext"`
 	ContainerSecurityContext ClientContainerSecurityContext `yaml:"containerSecurityContext"`
 	ExtraConfig              string                         `yaml:"extraConfig"`
-	ExtraVolumes             []interface{}                  `yaml:"extraVolumes"`
-	ExtraContainers          []interface{}                  `yaml:"extraContainers"`
+	ExtraVolumes             []map[string]interface{}       `yaml:"extraVolumes"`
+	ExtraContainers          []map[string]interface{}       `yaml:"extraContainers"`
 	Tolerations              string                         `yaml:"tolerations"`
-	NodeSelector             interface{}                    `yaml:"nodeSelector"`
-	Affinity                 interface{}                    `yaml:"affinity"`
+	NodeSelector             string                         `yaml:"nodeSelector"`
+	Affinity                 string                         `yaml:"affinity"`
 	PriorityClassName        string                         `yaml:"priorityClassName"`
-	Annotations              interface{}                    `yaml:"annotations"`
-	ExtraLabels              interface{}                    `yaml:"extraLabels"`
-	ExtraEnvironmentVars     ExtraEnvironmentVars           `yaml:"extraEnvironmentVars"`
-	DNSPolicy                interface{}                    `yaml:"dnsPolicy"`
+	Annotations              string                         `yaml:"annotations"`
+	ExtraLabels              map[string]interface{}         `yaml:"extraLabels"`
+	ExtraEnvironmentVars     map[string]interface{}         `yaml:"extraEnvironmentVars"`
+	DNSPolicy                string                         `yaml:"dnsPolicy"`
 	HostNetwork              bool                           `yaml:"hostNetwork"`
-	UpdateStrategy           interface{}                    `yaml:"updateStrategy"`
+	UpdateStrategy           string                         `yaml:"updateStrategy"`
 	SnapshotAgent            SnapshotAgent                  `yaml:"snapshotAgent"`
 }
 
+type CoreDNS struct {
+	Enabled            bool   `yaml:"enabled"`
+	ConfigMapName      string `yaml:"configMapName"`
+	ConfigMapNamespace string `yaml:"configMapNamespace"`
+}
+
+type NodeLocalDNS struct {
+	Enabled            bool   `yaml:"enabled"`
+	ConfigMapName      string `yaml:"configMapName"`
+	ConfigMapNamespace string `yaml:"configMapNamespace"`
+	BindAddress        string `yaml:"bindAddress"`
+}
+
 type DNS struct {
-	Enabled           string      `yaml:"enabled"`
-	EnableRedirection bool        `yaml:"enableRedirection"`
-	Type              string      `yaml:"type"`
-	ClusterIP         interface{} `yaml:"clusterIP"`
-	Annotations       interface{} `yaml:"annotations"`
-	AdditionalSpec    interface{} `yaml:"additionalSpec"`
+	Enabled           interface{}  `yaml:"enabled"`
+	EnableRedirection bool         `yaml:"enableRedirection"`
+	CoreDNS           CoreDNS      `yaml:"coreDNS"`
+	NodeLocalDNS      NodeLocalDNS `yaml:"nodeLocalDNS"`
+	Type              string       `yaml:"type"`
+	ClusterIP         string       `yaml:"clusterIP"`
+	Annotations       string       `yaml:"annotations"`
+	AdditionalSpec    string       `yaml:"additionalSpec"`
 }
 
 type Port struct {
@@ -328,31 +345,38 @@ type Port struct {
 }
 
 type ServiceNodePort struct {
-	HTTP  interface{} `yaml:"http"`
-	HTTPS interface{} `yaml:"https"`
+	HTTP  int `yaml:"http"`
+	HTTPS int `yaml:"https"`
 }
 
 type UIService struct {
 	Enabled        bool            `yaml:"enabled"`
-	Type           interface{}     `yaml:"type"`
+	Type           string          `yaml:"type"`
 	Port           Port            `yaml:"port"`
 	NodePort       ServiceNodePort `yaml:"nodePort"`
-	Annotations    interface{}     `yaml:"annotations"`
-	AdditionalSpec interface{}     `yaml:"additionalSpec"`
+	Annotations    string          `yaml:"annotations"`
+	AdditionalSpec string          `yaml:"additionalSpec"`
 }
+
 type Ingress struct {
-	Enabled          bool          `yaml:"enabled"`
-	IngressClassName string        `yaml:"ingressClassName"`
-	PathType         string        `yaml:"pathType"`
-	Hosts            []interface{} `yaml:"hosts"`
-	TLS              []interface{} `yaml:"tls"`
-	Annotations      interface{}   `yaml:"annotations"`
+	Enabled          bool                     `yaml:"enabled"`
+	IngressClassName string                   `yaml:"ingressClassName"`
+	PathType         string                   `yaml:"pathType"`
+	Hosts            []map[string]interface{} `yaml:"hosts"`
+	TLS              []map[string]interface{} `yaml:"tls"`
+	Annotations      string                   `yaml:"annotations"`
+}
+
+type Route struct {
+	Enabled     bool   `yaml:"enabled"`
+	Host        string `yaml:"host"`
+	Annotations string `yaml:"annotations"`
 }
 
 type UIMetrics struct {
-	Enabled  string `yaml:"enabled"`
-	Provider string `yaml:"provider"`
-	BaseURL  string `yaml:"baseURL"`
+	Enabled  interface{} `yaml:"enabled"`
+	Provider string      `yaml:"provider"`
+	BaseURL  string      `yaml:"baseURL"`
 }
 
 type DashboardURLTemplates struct {
@@ -360,253 +384,409 @@ type DashboardURLTemplates struct {
 }
 
 type UI struct {
-	Enabled               string                `yaml:"enabled"`
+	Enabled               interface{}           `yaml:"enabled"`
 	Service               UIService             `yaml:"service"`
 	Ingress               Ingress               `yaml:"ingress"`
+	Route                 Route                 `yaml:"route"`
 	Metrics               UIMetrics             `yaml:"metrics"`
 	DashboardURLTemplates DashboardURLTemplates `yaml:"dashboardURLTemplates"`
 }
 
 type ConsulNamespaces struct {
-	ConsulDestinationNamespace string `yaml:"consulDestinationNamespace"`
-	MirroringK8S               bool   `yaml:"mirroringK8S"`
-	MirroringK8SPrefix         string `yaml:"mirroringK8SPrefix"`
+	ConsulDestinationNamespace string                   `yaml:"consulDestinationNamespace"`
+	MirroringK8S               bool                     `yaml:"mirroringK8S"`
+	MirroringK8SPrefix         string                   `yaml:"mirroringK8SPrefix"`
+	MirroringK8SRules          []map[string]interface{} `yaml:"mirroringK8SRules"`
 }
 
 type ACLSyncToken struct {
-	SecretName interface{} `yaml:"secretName"`
-	SecretKey  interface{} `yaml:"secretKey"`
+	SecretName string `yaml:"secretName"`
+	SecretKey  string `yaml:"secretKey"`
 }
 
 type SyncCatalog struct {
-	Enabled               bool             `yaml:"enabled"`
-	Image                 interface{}      `yaml:"image"`
-	Default               bool             `yaml:"default"`
-	PriorityClassName     string           `yaml:"priorityClassName"`
-	ToConsul              bool             `yaml:"toConsul"`
-	ToK8S                 bool             `yaml:"toK8S"`
-	K8SPrefix             interface{}      `yaml:"k8sPrefix"`
-	K8SAllowNamespaces    []string         `yaml:"k8sAllowNamespaces"`
-	K8SDenyNamespaces     []string         `yaml:"k8sDenyNamespaces"`
-	K8SSourceNamespace    interface{}      `yaml:"k8sSourceNamespace"`
-	ConsulNamespaces      ConsulNamespaces `yaml:"consulNamespaces"`
-	AddK8SNamespaceSuffix bool             `yaml:"addK8SNamespaceSuffix"`
-	ConsulPrefix          interface{}      `yaml:"consulPrefix"`
-	K8STag                interface{}      `yaml:"k8sTag"`
-	ConsulNodeName        string           `yaml:"consulNodeName"`
-	SyncClusterIPServices bool             `yaml:"syncClusterIPServices"`
-	NodePortSyncType      string           `yaml:"nodePortSyncType"`
-	ACLSyncToken          ACLSyncToken     `yaml:"aclSyncToken"`
-	NodeSelector          interface{}      `yaml:"nodeSelector"`
-	Affinity              interface{}      `yaml:"affinity"`
-	Tolerations           interface{}      `yaml:"tolerations"`
-	ServiceAccount        ServiceAccount   `yaml:"serviceAccount"`
-	Resources             Resources        `yaml:"resources"`
-	LogLevel              string           `yaml:"logLevel"`
-	ConsulWriteInterval   interface{}      `yaml:"consulWriteInterval"`
-	ExtraLabels           interface{}      `yaml:"extraLabels"`
+	Enabled               bool                   `yaml:"enabled"`
+	Image                 string                 `yaml:"image"`
+	Default               bool                   `yaml:"default"`
+	PriorityClassName     string                 `yaml:"priorityClassName"`
+	ToConsul              bool                   `yaml:"toConsul"`
+	ToK8S                 bool                   `yaml:"toK8S"`
+	K8SPrefix             string                 `yaml:"k8sPrefix"`
+	K8SAllowNamespaces    []string               `yaml:"k8sAllowNamespaces"`
+	K8SDenyNamespaces     []string               `yaml:"k8sDenyNamespaces"`
+	K8SSourceNamespace    string                 `yaml:"k8sSourceNamespace"`
+	FilterConfigMap       string                 `yaml:"filterConfigMap"`
+	ConsulNamespaces      ConsulNamespaces       `yaml:"consulNamespaces"`
+	AddK8SNamespaceSuffix bool                   `yaml:"addK8SNamespaceSuffix"`
+	ConsulPrefix          string                 `yaml:"consulPrefix"`
+	K8STag                string                 `yaml:"k8sTag"`
+	ConsulNodeName        string                 `yaml:"consulNodeName"`
+	SyncClusterIPServices bool                   `yaml:"syncClusterIPServices"`
+	NodePortSyncType      string                 `yaml:"nodePortSyncType"`
+	PortTagTemplate       string                 `yaml:"portTagTemplate"`
+	TopologyLabels        []string               `yaml:"topologyLabels"`
+	ACLSyncToken          ACLSyncToken           `yaml:"aclSyncToken"`
+	NodeSelector          string                 `yaml:"nodeSelector"`
+	Affinity              string                 `yaml:"affinity"`
+	Tolerations           string                 `yaml:"tolerations"`
+	ServiceAccount        ServiceAccount         `yaml:"serviceAccount"`
+	Resources             map[string]interface{} `yaml:"resources"`
+	LogLevel              string                 `yaml:"logLevel"`
+	ConsulWriteInterval   string                 `yaml:"consulWriteInterval"`
+	ExtraLabels           map[string]interface{} `yaml:"extraLabels"`
+}
+
+type Sharding struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+type ServiceCache struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+type NodeHelper struct {
+	Enabled           bool                   `yaml:"enabled"`
+	Resources         map[string]interface{} `yaml:"resources"`
+	Tolerations       string                 `yaml:"tolerations"`
+	PriorityClassName string                 `yaml:"priorityClassName"`
 }
 
 type TransparentProxy struct {
-	DefaultEnabled         bool `yaml:"defaultEnabled"`
-	DefaultOverwriteProbes bool `yaml:"defaultOverwriteProbes"`
+	DefaultEnabled         bool       `yaml:"defaultEnabled"`
+	DefaultOverwriteProbes bool       `yaml:"defaultOverwriteProbes"`
+	InitMode               string     `yaml:"initMode"`
+	NodeHelper             NodeHelper `yaml:"nodeHelper"`
 }
 
-type Metrics struct {
-	DefaultEnabled              string `yaml:"defaultEnabled"`
-	DefaultEnableMerging        bool   `yaml:"defaultEnableMerging"`
-	DefaultMergedMetricsPort    int    `yaml:"defaultMergedMetricsPort"`
-	DefaultPrometheusScrapePort int    `yaml:"defaultPrometheusScrapePort"`
-	DefaultPrometheusScrapePath string `yaml:"defaultPrometheusScrapePath"`
+type NodeProxy struct {
+	Enabled      bool `yaml:"enabled"`
+	InboundPort  int  `yaml:"inboundPort"`
+	OutboundPort int  `yaml:"outboundPort"`
+}
+
+type NamespaceSidecarConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	ConfigMapName string `yaml:"configMapName"`
+}
+
+type EnvoyBootstrapConfigMaps struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+type Rollouts struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+type ServiceLocality struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+type PeerFailover struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+type EnvoyAccessLogs struct {
+	Enabled    bool   `yaml:"enabled"`
+	JSONFormat string `yaml:"jsonFormat"`
+	TextFormat string `yaml:"textFormat"`
+}
+
+type Deregistration struct {
+	NotReadyGracePeriod        string `yaml:"notReadyGracePeriod"`
+	DeregisterNotReadyAfter    string `yaml:"deregisterNotReadyAfter"`
+	DeregisterTerminatingAfter string `yaml:"deregisterTerminatingAfter"`
+}
+
+type XdsWatchdog struct {
+	Enabled   bool   `yaml:"enabled"`
+	Threshold string `yaml:"threshold"`
+	Policy    string `yaml:"policy"`
+}
+
+type NetworkPolicies struct {
+	Enabled      bool `yaml:"enabled"`
+	DefaultAllow bool `yaml:"defaultAllow"`
+}
+
+type ConnectInjectMetrics struct {
+	DefaultEnabled              interface{} `yaml:"defaultEnabled"`
+	DefaultEnableMerging        bool        `yaml:"defaultEnableMerging"`
+	DefaultMergedMetricsPort    int         `yaml:"defaultMergedMetricsPort"`
+	DefaultPrometheusScrapePort int         `yaml:"defaultPrometheusScrapePort"`
+	DefaultPrometheusScrapePath string      `yaml:"defaultPrometheusScrapePath"`
+}
+
+type ProjectedServiceAccountToken struct {
+	Enabled    bool   `yaml:"enabled"`
+	Audience   string `yaml:"audience"`
+	Expiration string `yaml:"expiration"`
 }
 
 type ACLInjectToken struct {
-	SecretName interface{} `yaml:"secretName"`
-	SecretKey  interface{} `yaml:"secretKey"`
+	SecretName string `yaml:"secretName"`
+	SecretKey  string `yaml:"secretKey"`
 }
 
-type SidecarProxy struct {
-	Resources Resources `yaml:"resources"`
+type Requests struct {
+	Memory string `yaml:"memory"`
+	CPU    string `yaml:"cpu"`
+}
+
+type Limits struct {
+	Memory string `yaml:"memory"`
+	CPU    string `yaml:"cpu"`
+}
+
+type Resources struct {
+	Requests Requests `yaml:"requests"`
+	Limits   Limits   `yaml:"limits"`
 }
 
-type InitContainer struct {
+type SidecarProxy struct {
 	Resources Resources `yaml:"resources"`
 }
 
 type ConnectInject struct {
-	Enabled                bool             `yaml:"enabled"`
-	Replicas               int              `yaml:"replicas"`
-	Image                  interface{}      `yaml:"image"`
-	Default                bool             `yaml:"default"`
-	TransparentProxy       TransparentProxy `yaml:"transparentProxy"`
-	Metrics                Metrics          `yaml:"metrics"`
-	EnvoyExtraArgs         interface{}      `yaml:"envoyExtraArgs"`
-	PriorityClassName      string           `yaml:"priorityClassName"`
-	ImageConsul            interface{}      `yaml:"imageConsul"`
-	LogLevel               string           `yaml:"logLevel"`
-	ServiceAccount         ServiceAccount   `yaml:"serviceAccount"`
-	Resources              Resources        `yaml:"resources"`
-	FailurePolicy          string           `yaml:"failurePolicy"`
-	NamespaceSelector      string           `yaml:"namespaceSelector"`
-	K8SAllowNamespaces     []string         `yaml:"k8sAllowNamespaces"`
-	K8SDenyNamespaces      []interface{}    `yaml:"k8sDenyNamespaces"`
-	ConsulNamespaces       ConsulNamespaces `yaml:"consulNamespaces"`
-	NodeSelector           interface{}      `yaml:"nodeSelector"`
-	Affinity               interface{}      `yaml:"affinity"`
-	Tolerations            interface{}      `yaml:"tolerations"`
-	ACLBindingRuleSelector string           `yaml:"aclBindingRuleSelector"`
-	OverrideAuthMethodName string           `yaml:"overrideAuthMethodName"`
-	ACLInjectToken         ACLInjectToken   `yaml:"aclInjectToken"`
-	SidecarProxy           SidecarProxy     `yaml:"sidecarProxy"`
-	InitContainer          InitContainer    `yaml:"initContainer"`
+	Enabled                         bool                         `yaml:"enabled"`
+	Replicas                        int                          `yaml:"replicas"`
+	Sharding                        Sharding                     `yaml:"sharding"`
+	ServiceCache                    ServiceCache                 `yaml:"serviceCache"`
+	Image                           string                       `yaml:"image"`
+	Default                         bool                         `yaml:"default"`
+	TransparentProxy                TransparentProxy             `yaml:"transparentProxy"`
+	NodeProxy                       NodeProxy                    `yaml:"nodeProxy"`
+	HoldApplicationUntilProxyStarts bool                         `yaml:"holdApplicationUntilProxyStarts"`
+	NamespaceSidecarConfig          NamespaceSidecarConfig       `yaml:"namespaceSidecarConfig"`
+	EnvoyBootstrapConfigMaps        EnvoyBootstrapConfigMaps     `yaml:"envoyBootstrapConfigMaps"`
+	ProbeHealthChecks               bool                         `yaml:"probeHealthChecks"`
+	Rollouts                        Rollouts                     `yaml:"rollouts"`
+	ServiceLocality                 ServiceLocality              `yaml:"serviceLocality"`
+	PeerFailover                    PeerFailover                 `yaml:"peerFailover"`
+	EnvoyAccessLogs                 EnvoyAccessLogs              `yaml:"envoyAccessLogs"`
+	Deregistration                  Deregistration               `yaml:"deregistration"`
+	XdsWatchdog                     XdsWatchdog                  `yaml:"xdsWatchdog"`
+	NetworkPolicies                 NetworkPolicies              `yaml:"networkPolicies"`
+	Metrics                         ConnectInjectMetrics         `yaml:"metrics"`
+	EnvoyExtraArgs                  string                       `yaml:"envoyExtraArgs"`
+	PriorityClassName               string                       `yaml:"priorityClassName"`
+	ImageConsul                     string                       `yaml:"imageConsul"`
+	LogLevel                        string                       `yaml:"logLevel"`
+	ServiceAccount                  ServiceAccount               `yaml:"serviceAccount"`
+	Resources                       map[string]interface{}       `yaml:"resources"`
+	FailurePolicy                   string                       `yaml:"failurePolicy"`
+	FailOpenDuringUpgrade           bool                         `yaml:"failOpenDuringUpgrade"`
+	WebhookTimeoutSeconds           int                          `yaml:"webhookTimeoutSeconds"`
+	NamespaceSelector               string                       `yaml:"namespaceSelector"`
+	ObjectSelector                  string                       `yaml:"objectSelector"`
+	K8SAllowNamespaces              []string                     `yaml:"k8sAllowNamespaces"`
+	K8SDenyNamespaces               []string                     `yaml:"k8sDenyNamespaces"`
+	ConsulNamespaces                ConsulNamespaces             `yaml:"consulNamespaces"`
+	NodeSelector                    string                       `yaml:"nodeSelector"`
+	Affinity                        string                       `yaml:"affinity"`
+	Tolerations                     string                       `yaml:"tolerations"`
+	ACLBindingRuleSelector          string                       `yaml:"aclBindingRuleSelector"`
+	OverrideAuthMethodName          string                       `yaml:"overrideAuthMethodName"`
+	ProjectedServiceAccountToken    ProjectedServiceAccountToken `yaml:"projectedServiceAccountToken"`
+	ACLInjectToken                  ACLInjectToken               `yaml:"aclInjectToken"`
+	SidecarProxy                    SidecarProxy                 `yaml:"sidecarProxy"`
+	InitContainer                   map[string]interface{}       `yaml:"initContainer"`
+}
+
+type ExternalServices struct {
+	ConfigMapName string `yaml:"configMapName"`
+	NodeName      string `yaml:"nodeName"`
 }
 
 type ACLToken struct {
-	SecretName interface{} `yaml:"secretName"`
-	SecretKey  interface{} `yaml:"secretKey"`
+	SecretName string `yaml:"secretName"`
+	SecretKey  string `yaml:"secretKey"`
 }
 
 type Controller struct {
-	Enabled           bool           `yaml:"enabled"`
-	Replicas          int            `yaml:"replicas"`
-	LogLevel          string         `yaml:"logLevel"`
-	ServiceAccount    ServiceAccount `yaml:"serviceAccount"`
-	Resources         Resources      `yaml:"resources"`
-	NodeSelector      interface{}    `yaml:"nodeSelector"`
-	Tolerations       interface{}    `yaml:"tolerations"`
-	Affinity          interface{}    `yaml:"affinity"`
-	PriorityClassName string         `yaml:"priorityClassName"`
-	ACLToken          ACLToken       `yaml:"aclToken"`
+	Enabled            bool                   `yaml:"enabled"`
+	Replicas           int                    `yaml:"replicas"`
+	LogLevel           string                 `yaml:"logLevel"`
+	ConfigEntryWorkers map[string]interface{} `yaml:"configEntryWorkers"`
+	ExternalServices   ExternalServices       `yaml:"externalServices"`
+	ServiceAccount     ServiceAccount         `yaml:"serviceAccount"`
+	Resources          map[string]interface{} `yaml:"resources"`
+	NodeSelector       string                 `yaml:"nodeSelector"`
+	Tolerations        string                 `yaml:"tolerations"`
+	Affinity           string                 `yaml:"affinity"`
+	PriorityClassName  string                 `yaml:"priorityClassName"`
+	ACLToken           ACLToken               `yaml:"aclToken"`
 }
 
 type WanAddress struct {
-	Source string `yaml:"source"`
-	Port   int    `yaml:"port"`
-	Static string `yaml:"static"`
+	Source            string `yaml:"source"`
+	Port              int    `yaml:"port"`
+	Static            string `yaml:"static"`
+	WatchLoadBalancer bool   `yaml:"watchLoadBalancer"`
+	ResolveHostnames  bool   `yaml:"resolveHostnames"`
 }
 
-type InitCopyConsulContainer struct {
-	Resources Resources `yaml:"resources"`
-}
-
-type InitServiceInitContainer struct {
-	Resources Resources `yaml:"resources"`
+type MeshGatewayService struct {
+	Enabled        bool   `yaml:"enabled"`
+	Type           string `yaml:"type"`
+	Port           int    `yaml:"port"`
+	NodePort       int    `yaml:"nodePort"`
+	Annotations    string `yaml:"annotations"`
+	AdditionalSpec string `yaml:"additionalSpec"`
 }
 
 type MeshGateway struct {
-	Enabled                  bool                     `yaml:"enabled"`
-	Replicas                 int                      `yaml:"replicas"`
-	WanAddress               WanAddress               `yaml:"wanAddress"`
-	Service                  Service                  `yaml:"service"`
-	HostNetwork              bool                     `yaml:"hostNetwork"`
-	DNSPolicy                interface{}              `yaml:"dnsPolicy"`
-	ConsulServiceName        string                   `yaml:"consulServiceName"`
-	ContainerPort            int                      `yaml:"containerPort"`
-	HostPort                 interface{}              `yaml:"hostPort"`
-	ServiceAccount           ServiceAccount           `yaml:"serviceAccount"`
-	Resources                Resources                `yaml:"resources"`
-	InitCopyConsulContainer  InitCopyConsulContainer  `yaml:"initCopyConsulContainer"`
-	InitServiceInitContainer InitServiceInitContainer `yaml:"initServiceInitContainer"`
-	Affinity                 string                   `yaml:"affinity"`
-	Tolerations              interface{}              `yaml:"tolerations"`
-	NodeSelector             interface{}              `yaml:"nodeSelector"`
-	PriorityClassName        string                   `yaml:"priorityClassName"`
-	Annotations              interface{}              `yaml:"annotations"`
-}
-
-type ServicePorts struct {
-	Port     int         `yaml:"port"`
-	NodePort interface{} `yaml:"nodePort"`
+	Enabled                  bool                   `yaml:"enabled"`
+	Replicas                 int                    `yaml:"replicas"`
+	WanAddress               WanAddress             `yaml:"wanAddress"`
+	Service                  MeshGatewayService     `yaml:"service"`
+	HostNetwork              bool                   `yaml:"hostNetwork"`
+	DNSPolicy                string                 `yaml:"dnsPolicy"`
+	ConsulServiceName        string                 `yaml:"consulServiceName"`
+	ContainerPort            int                    `yaml:"containerPort"`
+	HostPort                 int                    `yaml:"hostPort"`
+	ServiceAccount           ServiceAccount         `yaml:"serviceAccount"`
+	Resources                map[string]interface{} `yaml:"resources"`
+	InitCopyConsulContainer  map[string]interface{} `yaml:"initCopyConsulContainer"`
+	InitServiceInitContainer map[string]interface{} `yaml:"initServiceInitContainer"`
+	Affinity                 string                 `yaml:"affinity"`
+	Tolerations              string                 `yaml:"tolerations"`
+	NodeSelector             string                 `yaml:"nodeSelector"`
+	PriorityClassName        string                 `yaml:"priorityClassName"`
+	Annotations              string                 `yaml:"annotations"`
 }
 
 type DefaultsService struct {
-	Type           string         `yaml:"type"`
-	Ports          []ServicePorts `yaml:"ports"`
-	Annotations    interface{}    `yaml:"annotations"`
-	AdditionalSpec interface{}    `yaml:"additionalSpec"`
-}
-
-type IngressGatewayDefaults struct {
-	Replicas                      int                     `yaml:"replicas"`
-	Service                       DefaultsService         `yaml:"service"`
-	ServiceAccount                ServiceAccount          `yaml:"serviceAccount"`
-	Resources                     Resources               `yaml:"resources"`
-	InitCopyConsulContainer       InitCopyConsulContainer `yaml:"initCopyConsulContainer"`
-	Affinity                      string                  `yaml:"affinity"`
-	Tolerations                   interface{}             `yaml:"tolerations"`
-	NodeSelector                  interface{}             `yaml:"nodeSelector"`
-	PriorityClassName             string                  `yaml:"priorityClassName"`
-	TerminationGracePeriodSeconds int                     `yaml:"terminationGracePeriodSeconds"`
-	Annotations                   interface{}             `yaml:"annotations"`
-	ConsulNamespace               string                  `yaml:"consulNamespace"`
-}
-
-type Gateways struct {
-	Name string `yaml:"name"`
+	Type           string                   `yaml:"type"`
+	Ports          []map[string]interface{} `yaml:"ports"`
+	Annotations    string                   `yaml:"annotations"`
+	AdditionalSpec string                   `yaml:"additionalSpec"`
+}
+
+type Defaults struct {
+	Replicas                      int                    `yaml:"replicas"`
+	Service                       DefaultsService        `yaml:"service"`
+	ServiceAccount                ServiceAccount         `yaml:"serviceAccount"`
+	Resources                     map[string]interface{} `yaml:"resources"`
+	InitCopyConsulContainer       map[string]interface{} `yaml:"initCopyConsulContainer"`
+	Affinity                      string                 `yaml:"affinity"`
+	Tolerations                   string                 `yaml:"tolerations"`
+	NodeSelector                  string                 `yaml:"nodeSelector"`
+	PriorityClassName             string                 `yaml:"priorityClassName"`
+	TerminationGracePeriodSeconds int                    `yaml:"terminationGracePeriodSeconds"`
+	Annotations                   string                 `yaml:"annotations"`
+	ConsulNamespace               string                 `yaml:"consulNamespace"`
 }
 
 type IngressGateways struct {
-	Enabled  bool                   `yaml:"enabled"`
-	Defaults IngressGatewayDefaults `yaml:"defaults"`
-	Gateways []Gateways             `yaml:"gateways"`
+	Enabled  bool                     `yaml:"enabled"`
+	Defaults Defaults                 `yaml:"defaults"`
+	Gateways []map[string]interface{} `yaml:"gateways"`
 }
 
-type Defaults struct {
-	Replicas                int                     `yaml:"replicas"`
-	ExtraVolumes            []interface{}           `yaml:"extraVolumes"`
-	Resources               Resources               `yaml:"resources"`
-	InitCopyConsulContainer InitCopyConsulContainer `yaml:"initCopyConsulContainer"`
-	Affinity                string                  `yaml:"affinity"`
-	Tolerations             interface{}             `yaml:"tolerations"`
-	NodeSelector            interface{}             `yaml:"nodeSelector"`
-	PriorityClassName       string                  `yaml:"priorityClassName"`
-	Annotations             interface{}             `yaml:"annotations"`
-	ServiceAccount          ServiceAccount          `yaml:"serviceAccount"`
-	ConsulNamespace         string                  `yaml:"consulNamespace"`
+type TerminatingGatewaysDefaults struct {
+	Replicas                int                      `yaml:"replicas"`
+	ExtraVolumes            []map[string]interface{} `yaml:"extraVolumes"`
+	Resources               map[string]interface{}   `yaml:"resources"`
+	InitCopyConsulContainer map[string]interface{}   `yaml:"initCopyConsulContainer"`
+	Affinity                string                   `yaml:"affinity"`
+	Tolerations             string                   `yaml:"tolerations"`
+	NodeSelector            string                   `yaml:"nodeSelector"`
+	PriorityClassName       string                   `yaml:"priorityClassName"`
+	Annotations             string                   `yaml:"annotations"`
+	ServiceAccount          ServiceAccount           `yaml:"serviceAccount"`
+	ConsulNamespace         string                   `yaml:"consulNamespace"`
 }
 
 type TerminatingGateways struct {
-	Enabled  bool       `yaml:"enabled"`
-	Defaults Defaults   `yaml:"defaults"`
-	Gateways []Gateways `yaml:"gateways"`
+	Enabled  bool                        `yaml:"enabled"`
+	Defaults TerminatingGatewaysDefaults `yaml:"defaults"`
+	Gateways []map[string]interface{}    `yaml:"gateways"`
 }
 
 type CopyAnnotations struct {
-	Service interface{} `yaml:"service"`
+	Service string `yaml:"service"`
 }
 
 type ManagedGatewayClass struct {
 	Enabled         bool            `yaml:"enabled"`
-	NodeSelector    interface{}     `yaml:"nodeSelector"`
+	NodeSelector    string          `yaml:"nodeSelector"`
 	ServiceType     string          `yaml:"serviceType"`
 	UseHostPorts    bool            `yaml:"useHostPorts"`
 	CopyAnnotations CopyAnnotations `yaml:"copyAnnotations"`
 }
 
-type Service struct {
-	Annotations interface{} `yaml:"annotations"`
+type ControllerService struct {
+	Annotations string `yaml:"annotations"`
 }
 
 type APIGatewayController struct {
-	Replicas          int         `yaml:"replicas"`
-	Annotations       interface{} `yaml:"annotations"`
-	PriorityClassName string      `yaml:"priorityClassName"`
-	NodeSelector      interface{} `yaml:"nodeSelector"`
-	Service           Service     `yaml:"service"`
+	Replicas          int               `yaml:"replicas"`
+	Annotations       string            `yaml:"annotations"`
+	PriorityClassName string            `yaml:"priorityClassName"`
+	NodeSelector      string            `yaml:"nodeSelector"`
+	Service           ControllerService `yaml:"service"`
 }
 
 type APIGateway struct {
-	Enabled             bool                 `yaml:"enabled"`
-	Image               interface{}          `yaml:"image"`
-	LogLevel            string               `yaml:"logLevel"`
-	ManagedGatewayClass ManagedGatewayClass  `yaml:"managedGatewayClass"`
-	ConsulNamespaces    ConsulNamespaces     `yaml:"consulNamespaces"`
-	ServiceAccount      ServiceAccount       `yaml:"serviceAccount"`
-	Controller          APIGatewayController `yaml:"controller"`
+	Enabled                 bool                     `yaml:"enabled"`
+	Image                   string                   `yaml:"image"`
+	LogLevel                string                   `yaml:"logLevel"`
+	ManagedGatewayClass     ManagedGatewayClass      `yaml:"managedGatewayClass"`
+	Routes                  []map[string]interface{} `yaml:"routes"`
+	ServiceAccount          ServiceAccount           `yaml:"serviceAccount"`
+	Controller              APIGatewayController     `yaml:"controller"`
+	Resources               map[string]interface{}   `yaml:"resources"`
+	InitCopyConsulContainer map[string]interface{}   `yaml:"initCopyConsulContainer"`
+}
+
+type APIProxy struct {
+	Enabled   bool                   `yaml:"enabled"`
+	Image     string                 `yaml:"image"`
+	Replicas  int                    `yaml:"replicas"`
+	LogLevel  string                 `yaml:"logLevel"`
+	ACLToken  ACLToken               `yaml:"aclToken"`
+	Resources map[string]interface{} `yaml:"resources"`
+}
+
+type Namespaces struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+type TelemetryCollector struct {
+	Enabled              bool                   `yaml:"enabled"`
+	Image                string                 `yaml:"image"`
+	Replicas             int                    `yaml:"replicas"`
+	LogLevel             string                 `yaml:"logLevel"`
+	CustomExporterConfig string                 `yaml:"customExporterConfig"`
+	Namespaces           Namespaces             `yaml:"namespaces"`
+	Resources            map[string]interface{} `yaml:"resources"`
+	NodeSelector         string                 `yaml:"nodeSelector"`
+	PriorityClassName    string                 `yaml:"priorityClassName"`
+}
+
+type IssuerRef struct {
+	Name string `yaml:"name"`
+	Kind string `yaml:"kind"`
+}
+
+type CertManager struct {
+	IssuerRef   IssuerRef `yaml:"issuerRef"`
+	Duration    string    `yaml:"duration"`
+	RenewBefore string    `yaml:"renewBefore"`
+}
+
+type WebhookCertManagerVault struct {
+	Role    string `yaml:"role"`
+	PkiPath string `yaml:"pkiPath"`
+	CaPath  string `yaml:"caPath"`
 }
 
 type WebhookCertManager struct {
-	Tolerations interface{} `yaml:"tolerations"`
+	Source      string                  `yaml:"source"`
+	CertManager CertManager             `yaml:"certManager"`
+	Vault       WebhookCertManagerVault `yaml:"vault"`
+	Tolerations string                  `yaml:"tolerations"`
 }
 
 type Prometheus struct {
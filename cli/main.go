@@ -12,9 +12,6 @@ import (
 )
 
 func main() {
-	c := cli.NewCLI("consul-k8s", version.GetHumanVersion())
-	c.Args = os.Args[1:]
-
 	log := hclog.New(&hclog.LoggerOptions{
 		Name:   "cli",
 		Level:  hclog.Info,
@@ -25,7 +22,7 @@ func main() {
 	defer cancel()
 
 	basecmd, commands := initializeCommands(ctx, log)
-	c.Commands = commands
+	c := newCLI(os.Args[1:], commands)
 	defer func() {
 		_ = basecmd.Close()
 	}()
@@ -40,11 +37,24 @@ func main() {
 		os.Exit(1)
 	}()
 
-	c.HelpFunc = cli.BasicHelpFunc("consul-k8s")
-
 	exitStatus, err := c.Run()
 	if err != nil {
 		log.Info(err.Error())
 	}
 	os.Exit(exitStatus)
 }
+
+// newCLI returns the CLI that runs the commands with the arguments. Shell
+// completion is enabled: the shell calls the CLI with the COMP_LINE environment
+// variable set to get the completions, which are predicted by the flags of the
+// commands. The handler is added to the user's shell with -autocomplete-install.
+func newCLI(args []string, commands map[string]cli.CommandFactory) *cli.CLI {
+	c := cli.NewCLI("consul-k8s", version.GetHumanVersion())
+	c.Args = args
+	c.Commands = commands
+	c.HelpFunc = cli.BasicHelpFunc("consul-k8s")
+	c.Autocomplete = true
+	c.AutocompleteInstall = "autocomplete-install"
+	c.AutocompleteUninstall = "autocomplete-uninstall"
+	return c
+}
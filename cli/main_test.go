@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+const kubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: kind
+  cluster:
+    server: https://127.0.0.1:6443
+users:
+- name: admin
+contexts:
+- name: kind-dc1
+  context:
+    cluster: kind
+    user: admin
+- name: kind-dc2
+  context:
+    cluster: kind
+    user: admin
+- name: prod
+  context:
+    cluster: kind
+    user: admin
+`
+
+// TestCLI_Autocomplete tests that the CLI prints the completions predicted by
+// the flags of a command when the shell asks for them.
+func TestCLI_Autocomplete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	kubeConfigPath := filepath.Join(dir, "config")
+	require.NoError(t, ioutil.WriteFile(kubeConfigPath, []byte(kubeConfig), 0600))
+
+	cases := map[string]struct {
+		line     string
+		expected []string
+	}{
+		"subcommands": {
+			line:     "consul-k8s validate ",
+			expected: []string{"crds"},
+		},
+		"flags": {
+			line:     "consul-k8s validate crds -default-p",
+			expected: []string{"-default-protocol"},
+		},
+		"kube contexts": {
+			line:     "consul-k8s crd force-unlock -kubeconfig " + kubeConfigPath + " -context kind-",
+			expected: []string{"kind-dc1", "kind-dc2"},
+		},
+		"global install flag": {
+			line:     "consul-k8s -autocomplete-i",
+			expected: []string{"-autocomplete-install"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.expected, complete(t, c.line))
+		})
+	}
+}
+
+// complete runs the CLI as the shell does to complete the line and returns the
+// completions it prints.
+func complete(t *testing.T, line string) []string {
+	t.Helper()
+	os.Setenv("COMP_LINE", line)
+	defer os.Unsetenv("COMP_LINE")
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	basecmd, commands := initializeCommands(context.Background(), hclog.NewNullLogger())
+	defer basecmd.Close()
+	exitCode, err := newCLI(nil, commands).Run()
+	require.NoError(t, err)
+	require.Equal(t, 0, exitCode)
+
+	require.NoError(t, w.Close())
+	out, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	completions := strings.Fields(string(out))
+	sort.Strings(completions)
+	return completions
+}
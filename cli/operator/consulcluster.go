@@ -0,0 +1,106 @@
+// Package operator contains the controller of the consul-k8s operator, which
+// installs, upgrades and uninstalls Consul in the cluster it runs in according
+// to ConsulCluster custom resources.
+package operator
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	PhaseInstalling   = "Installing"
+	PhaseUpgrading    = "Upgrading"
+	PhaseInstalled    = "Installed"
+	PhaseUninstalling = "Uninstalling"
+	PhaseFailed       = "Failed"
+
+	// finalizerName is the finalizer the operator adds to ConsulClusters so
+	// that it can uninstall Consul before they are deleted.
+	finalizerName = "operator.consul.hashicorp.com"
+)
+
+var (
+	// ConsulClusterGVR identifies ConsulClusters for the dynamic client.
+	ConsulClusterGVR = schema.GroupVersionResource{Group: "consul.hashicorp.com", Version: "v1alpha1", Resource: "consulclusters"}
+
+	crdGVR = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+	//go:embed consulclusters.yaml
+	crdYAML []byte
+)
+
+// ConsulCluster is a Consul installation in the namespace of the resource.
+type ConsulCluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ConsulClusterSpec   `json:"spec,omitempty"`
+	Status ConsulClusterStatus `json:"status,omitempty"`
+}
+
+// ConsulClusterSpec is the configuration of the installation.
+type ConsulClusterSpec struct {
+	// Preset is the name of the preset the values are merged on top of, like
+	// the -preset flag of consul-k8s install.
+	Preset string `json:"preset,omitempty"`
+
+	// Values are the Helm values of the Consul chart.
+	Values map[string]interface{} `json:"values,omitempty"`
+
+	// Timeout is how long to wait for an install or upgrade to complete.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// ConsulClusterStatus is the observed state of the installation.
+type ConsulClusterStatus struct {
+	Phase              string      `json:"phase,omitempty"`
+	ObservedGeneration int64       `json:"observedGeneration,omitempty"`
+	ChartVersion       string      `json:"chartVersion,omitempty"`
+	Revision           int         `json:"revision,omitempty"`
+	Message            string      `json:"message,omitempty"`
+	LastUpdateTime     metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// fromUnstructured converts a ConsulCluster read with the dynamic client.
+func fromUnstructured(obj *unstructured.Unstructured) (*ConsulCluster, error) {
+	var cluster ConsulCluster
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &cluster); err != nil {
+		return nil, fmt.Errorf("error parsing ConsulCluster %s/%s: %s", obj.GetNamespace(), obj.GetName(), err)
+	}
+	return &cluster, nil
+}
+
+// ApplyCRD creates the ConsulCluster CRD or updates its spec if it exists.
+func ApplyCRD(ctx context.Context, client dynamic.Interface) error {
+	var target unstructured.Unstructured
+	if err := yaml.Unmarshal(crdYAML, &target.Object); err != nil {
+		return fmt.Errorf("error parsing the ConsulCluster CRD: %s", err)
+	}
+
+	crds := client.Resource(crdGVR)
+	current, err := crds.Get(ctx, target.GetName(), metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		if _, err := crds.Create(ctx, &target, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("error creating the ConsulCluster CRD: %s", err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error getting the ConsulCluster CRD: %s", err)
+	}
+
+	current.Object["spec"] = target.Object["spec"]
+	if _, err := crds.Update(ctx, current, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating the ConsulCluster CRD: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package operator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestApplyCRD(t *testing.T) {
+	ctx := context.Background()
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{crdGVR: "CustomResourceDefinitionList"})
+
+	// The CRD is created if it doesn't exist.
+	require.NoError(t, ApplyCRD(ctx, client))
+	crd, err := client.Resource(crdGVR).Get(ctx, "consulclusters.consul.hashicorp.com", metav1.GetOptions{})
+	require.NoError(t, err)
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	require.Equal(t, "ConsulCluster", kind)
+
+	// Only the spec is replaced when it exists.
+	crd.SetAnnotations(map[string]string{"foo": "bar"})
+	require.NoError(t, unstructured.SetNestedField(crd.Object, "Outdated", "spec", "names", "kind"))
+	_, err = client.Resource(crdGVR).Update(ctx, crd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, ApplyCRD(ctx, client))
+	crd, err = client.Resource(crdGVR).Get(ctx, "consulclusters.consul.hashicorp.com", metav1.GetOptions{})
+	require.NoError(t, err)
+	kind, _, _ = unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	require.Equal(t, "ConsulCluster", kind)
+	require.Equal(t, map[string]string{"foo": "bar"}, crd.GetAnnotations())
+}
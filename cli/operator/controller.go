@@ -0,0 +1,86 @@
+package operator
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Controller watches ConsulClusters and reconciles them one at a time, so that
+// at most one install, upgrade or uninstall runs at once. ConsulClusters are
+// also reconciled every resync period, which reverts changes made to the
+// release out-of-band, e.g. with helm upgrade.
+type Controller struct {
+	Reconciler *Reconciler
+
+	// Namespace is the namespace of the ConsulClusters to watch. All
+	// namespaces are watched if it is empty.
+	Namespace string
+
+	ResyncPeriod time.Duration
+
+	Log hclog.Logger
+}
+
+// Run reconciles ConsulClusters until the context is canceled.
+func (c *Controller) Run(ctx context.Context) error {
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(c.Reconciler.Dynamic, c.ResyncPeriod, c.Namespace, nil)
+	informer := factory.ForResource(ConsulClusterGVR).Informer()
+
+	queue := workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "consulclusters")
+	enqueue := func(obj interface{}) {
+		key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+		if err != nil {
+			c.Log.Error("error getting the key of a ConsulCluster", "err", err)
+			return
+		}
+		queue.Add(key)
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(_, obj interface{}) { enqueue(obj) },
+		DeleteFunc: enqueue,
+	})
+
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		queue.ShutDown()
+		return ctx.Err()
+	}
+
+	go func() {
+		<-ctx.Done()
+		queue.ShutDown()
+	}()
+	for c.processNextItem(ctx, queue) {
+	}
+	return nil
+}
+
+// processNextItem reconciles the next ConsulCluster of the queue. It returns
+// false once the queue is shut down.
+func (c *Controller) processNextItem(ctx context.Context, queue workqueue.RateLimitingInterface) bool {
+	item, shutdown := queue.Get()
+	if shutdown {
+		return false
+	}
+	defer queue.Done(item)
+
+	key := item.(string)
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		queue.Forget(item)
+		return true
+	}
+	if err := c.Reconciler.Reconcile(ctx, namespace, name); err != nil {
+		c.Log.Error("error reconciling ConsulCluster", "consulcluster", key, "err", err)
+		queue.AddRateLimited(item)
+		return true
+	}
+	queue.Forget(item)
+	return true
+}
@@ -0,0 +1,252 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/config"
+	"github.com/hashicorp/consul-k8s/cli/helm"
+	"github.com/hashicorp/go-hclog"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+const defaultTimeout = 10 * time.Minute
+
+// Reconciler installs, upgrades and uninstalls Consul according to
+// ConsulClusters. Consul is installed as the Helm release
+// common.DefaultReleaseName in the namespace of the ConsulCluster, and only
+// one Consul installation is allowed in the cluster, like with the install
+// command.
+type Reconciler struct {
+	Dynamic  dynamic.Interface
+	Releaser Releaser
+	Chart    *chart.Chart
+	Log      hclog.Logger
+}
+
+// Reconcile brings the Consul installation of the ConsulCluster in line with
+// its spec. An error is returned if reconciling should be retried; invalid
+// specs are only reported in the status since they have to be changed first.
+func (r *Reconciler) Reconcile(ctx context.Context, namespace, name string) error {
+	client := r.Dynamic.Resource(ConsulClusterGVR).Namespace(namespace)
+	obj, err := client.Get(ctx, name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	log := r.Log.With("consulcluster", namespace+"/"+name)
+
+	if obj.GetDeletionTimestamp() != nil {
+		if !hasFinalizer(obj) {
+			return nil
+		}
+		if obj, err = r.setStatus(ctx, obj, PhaseUninstalling, "Uninstalling Consul", nil); err != nil {
+			return err
+		}
+		log.Info("uninstalling Consul")
+		if err := r.Releaser.Uninstall(namespace); err != nil {
+			_, _ = r.setStatus(ctx, obj, PhaseFailed, fmt.Sprintf("Error uninstalling Consul: %s", err), nil)
+			return err
+		}
+		return r.patchFinalizers(ctx, obj, removeString(obj.GetFinalizers(), finalizerName))
+	}
+
+	if !hasFinalizer(obj) {
+		if err := r.patchFinalizers(ctx, obj, append(obj.GetFinalizers(), finalizerName)); err != nil {
+			return err
+		}
+		if obj, err = client.Get(ctx, name, metav1.GetOptions{}); err != nil {
+			return err
+		}
+	}
+
+	cluster, err := fromUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	vals, timeout, err := r.values(cluster.Spec)
+	if err != nil {
+		_, err = r.setStatus(ctx, obj, PhaseFailed, err.Error(), nil)
+		return err
+	}
+
+	releases, err := r.Releaser.List()
+	if err != nil {
+		return fmt.Errorf("couldn't check for installations: %s", err)
+	}
+	for _, rel := range releases {
+		if rel.Namespace != namespace {
+			msg := fmt.Sprintf("A Consul cluster is already installed in namespace %s with name %s.", rel.Namespace, rel.Name)
+			_, err = r.setStatus(ctx, obj, PhaseFailed, msg, nil)
+			return err
+		}
+	}
+
+	current, err := r.Releaser.Get(namespace)
+	if err != nil {
+		return err
+	}
+	if current != nil && r.upToDate(current, vals) {
+		_, err = r.setStatus(ctx, obj, PhaseInstalled, "Consul is installed", current)
+		return err
+	}
+
+	phase, message, verb, run := PhaseInstalling, "Installing Consul", "installing", r.Releaser.Install
+	if current != nil {
+		phase, message, verb, run = PhaseUpgrading, "Upgrading Consul", "upgrading", r.Releaser.Upgrade
+	}
+	if obj, err = r.setStatus(ctx, obj, phase, message, current); err != nil {
+		return err
+	}
+	log.Info(verb+" Consul", "chart", r.Chart.Metadata.Version)
+	rel, err := run(namespace, r.Chart, vals, timeout)
+	if err != nil {
+		_, _ = r.setStatus(ctx, obj, PhaseFailed, fmt.Sprintf("Error %s Consul: %s", verb, err), current)
+		return err
+	}
+	_, err = r.setStatus(ctx, obj, PhaseInstalled, "Consul is installed", rel)
+	return err
+}
+
+// values returns the Helm values and the timeout of the spec. Like the install
+// command, the values are merged on top of the preset and default global.name
+// to consul.
+func (r *Reconciler) values(spec ConsulClusterSpec) (map[string]interface{}, time.Duration, error) {
+	vals := spec.Values
+	if vals == nil {
+		vals = map[string]interface{}{}
+	}
+	if spec.Preset != "" {
+		preset, ok := config.Presets[spec.Preset]
+		if !ok {
+			return nil, 0, fmt.Errorf("'%s' is not a valid preset", spec.Preset)
+		}
+		vals = common.MergeMaps(preset.(map[string]interface{}), vals)
+		if missing := config.MissingPresetInputs(spec.Preset, vals); len(missing) > 0 {
+			var paths []string
+			for _, input := range missing {
+				paths = append(paths, input.Paths[0])
+			}
+			return nil, 0, fmt.Errorf("preset '%s' requires values for %s", spec.Preset, strings.Join(paths, ", "))
+		}
+	}
+	vals = common.MergeMaps(config.Convert(config.GlobalNameConsul), vals)
+	if err := helm.ValidateValues(r.Chart, vals); err != nil {
+		return nil, 0, err
+	}
+
+	timeout := defaultTimeout
+	if spec.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(spec.Timeout); err != nil || timeout <= 0 {
+			return nil, 0, fmt.Errorf("invalid timeout %q", spec.Timeout)
+		}
+	}
+	return vals, timeout, nil
+}
+
+// upToDate returns whether the release is deployed from the chart with the
+// values. The values are compared as JSON since the values stored in the
+// release and the values of the ConsulCluster decode numbers differently.
+func (r *Reconciler) upToDate(rel *release.Release, vals map[string]interface{}) bool {
+	if rel.Info == nil || rel.Info.Status != release.StatusDeployed {
+		return false
+	}
+	if rel.Chart == nil || rel.Chart.Metadata.Version != r.Chart.Metadata.Version {
+		return false
+	}
+	current, err := json.Marshal(rel.Config)
+	if err != nil {
+		return false
+	}
+	desired, err := json.Marshal(vals)
+	if err != nil {
+		return false
+	}
+	return string(current) == string(desired)
+}
+
+// setStatus updates the status of the ConsulCluster if it changed, and returns
+// the updated object.
+func (r *Reconciler) setStatus(ctx context.Context, obj *unstructured.Unstructured, phase, message string, rel *release.Release) (*unstructured.Unstructured, error) {
+	cluster, err := fromUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	status := cluster.Status
+	status.Phase = phase
+	status.Message = message
+	status.ObservedGeneration = obj.GetGeneration()
+	if rel != nil {
+		status.Revision = rel.Version
+		if rel.Chart != nil && rel.Chart.Metadata != nil {
+			status.ChartVersion = rel.Chart.Metadata.Version
+		}
+	}
+	if status == cluster.Status {
+		return obj, nil
+	}
+	status.LastUpdateTime = metav1.Now()
+
+	raw, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&status)
+	if err != nil {
+		return nil, err
+	}
+	updated := obj.DeepCopy()
+	updated.Object["status"] = raw
+	updated, err = r.Dynamic.Resource(ConsulClusterGVR).Namespace(obj.GetNamespace()).UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error updating the status of ConsulCluster %s/%s: %s", obj.GetNamespace(), obj.GetName(), err)
+	}
+	return updated, nil
+}
+
+// patchFinalizers replaces the finalizers of the ConsulCluster.
+func (r *Reconciler) patchFinalizers(ctx context.Context, obj *unstructured.Unstructured, finalizers []string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"finalizers":      finalizers,
+			"resourceVersion": obj.GetResourceVersion(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.Dynamic.Resource(ConsulClusterGVR).Namespace(obj.GetNamespace()).
+		Patch(ctx, obj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("error updating the finalizers of ConsulCluster %s/%s: %s", obj.GetNamespace(), obj.GetName(), err)
+	}
+	return nil
+}
+
+func hasFinalizer(obj *unstructured.Unstructured) bool {
+	for _, f := range obj.GetFinalizers() {
+		if f == finalizerName {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(list []string, s string) []string {
+	var out []string
+	for _, item := range list {
+		if item != s {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,242 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	consulChart "github.com/hashicorp/consul-k8s/charts"
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/config"
+	"github.com/hashicorp/consul-k8s/cli/helm"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestReconcile(t *testing.T) {
+	chrt, err := helm.LoadChart(consulChart.ConsulHelmChart, common.TopLevelChartDirName)
+	require.NoError(t, err)
+	oldChart := &chart.Chart{Metadata: &chart.Metadata{Name: "consul", Version: "0.0.1"}}
+
+	deployed := func(namespace string, chrt *chart.Chart, vals string) *release.Release {
+		return &release.Release{
+			Name:      common.DefaultReleaseName,
+			Namespace: namespace,
+			Version:   1,
+			Info:      &release.Info{Status: release.StatusDeployed},
+			Chart:     chrt,
+			Config:    config.Convert(vals),
+		}
+	}
+
+	cases := map[string]struct {
+		spec        string
+		finalizers  []string
+		deleting    bool
+		releases    []*release.Release
+		releaserErr error
+
+		expErr        string
+		expCalls      []string
+		expPhase      string
+		expMessage    string
+		expRevision   int64
+		expFinalizers []string
+	}{
+		"installs without a release": {
+			spec:          `values: {global: {datacenter: dc2}}`,
+			expCalls:      []string{"install consul"},
+			expPhase:      PhaseInstalled,
+			expMessage:    "Consul is installed",
+			expRevision:   1,
+			expFinalizers: []string{finalizerName},
+		},
+		"does nothing when the release is up to date": {
+			spec:          `values: {global: {datacenter: dc2, gossipEncryption: {autoGenerate: true}}}`,
+			finalizers:    []string{finalizerName},
+			releases:      []*release.Release{deployed("consul", chrt, `global: {name: consul, datacenter: dc2, gossipEncryption: {autoGenerate: true}}`)},
+			expPhase:      PhaseInstalled,
+			expMessage:    "Consul is installed",
+			expRevision:   1,
+			expFinalizers: []string{finalizerName},
+		},
+		"upgrades when the values change": {
+			spec:          `values: {global: {datacenter: dc3}}`,
+			finalizers:    []string{finalizerName},
+			releases:      []*release.Release{deployed("consul", chrt, `global: {name: consul, datacenter: dc2}`)},
+			expCalls:      []string{"upgrade consul"},
+			expPhase:      PhaseInstalled,
+			expMessage:    "Consul is installed",
+			expRevision:   2,
+			expFinalizers: []string{finalizerName},
+		},
+		"upgrades when the chart changes": {
+			finalizers:    []string{finalizerName},
+			releases:      []*release.Release{deployed("consul", oldChart, `global: {name: consul}`)},
+			expCalls:      []string{"upgrade consul"},
+			expPhase:      PhaseInstalled,
+			expMessage:    "Consul is installed",
+			expRevision:   2,
+			expFinalizers: []string{finalizerName},
+		},
+		"merges the values on top of the preset": {
+			spec:          `{preset: demo, values: {global: {datacenter: dc2}}}`,
+			expCalls:      []string{"install consul"},
+			expPhase:      PhaseInstalled,
+			expMessage:    "Consul is installed",
+			expRevision:   1,
+			expFinalizers: []string{finalizerName},
+		},
+		"fails with missing preset inputs": {
+			spec:          `preset: production`,
+			expPhase:      PhaseFailed,
+			expMessage:    "preset 'production' requires values for global.datacenter",
+			expFinalizers: []string{finalizerName},
+		},
+		"fails with invalid values": {
+			spec:          `values: {global: {notAValue: true}}`,
+			expPhase:      PhaseFailed,
+			expMessage:    "notAValue",
+			expFinalizers: []string{finalizerName},
+		},
+		"fails with an invalid timeout": {
+			spec:          `timeout: soon`,
+			expPhase:      PhaseFailed,
+			expMessage:    `invalid timeout "soon"`,
+			expFinalizers: []string{finalizerName},
+		},
+		"fails when Consul is installed in another namespace": {
+			releases:      []*release.Release{deployed("other", chrt, `global: {name: consul}`)},
+			expPhase:      PhaseFailed,
+			expMessage:    "A Consul cluster is already installed in namespace other with name consul.",
+			expFinalizers: []string{finalizerName},
+		},
+		"retries failed installs": {
+			releaserErr:   errors.New("timed out waiting for the condition"),
+			expErr:        "timed out waiting for the condition",
+			expCalls:      []string{"install consul"},
+			expPhase:      PhaseFailed,
+			expMessage:    "Error installing Consul: timed out waiting for the condition",
+			expFinalizers: []string{finalizerName},
+		},
+		"uninstalls when deleted": {
+			finalizers: []string{finalizerName, "other"},
+			deleting:   true,
+			releases:   []*release.Release{deployed("consul", chrt, `global: {name: consul}`)},
+			expCalls:   []string{"uninstall consul"},
+			expPhase:   PhaseUninstalling,
+			expMessage: "Uninstalling Consul",
+			// The finalizers of others are kept.
+			expFinalizers: []string{"other"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": config.Convert(c.spec)}}
+			obj.SetGroupVersionKind(schema.GroupVersionKind{Group: "consul.hashicorp.com", Version: "v1alpha1", Kind: "ConsulCluster"})
+			obj.SetName("consul")
+			obj.SetNamespace("consul")
+			obj.SetGeneration(3)
+			obj.SetFinalizers(c.finalizers)
+			if c.deleting {
+				now := metav1.Now()
+				obj.SetDeletionTimestamp(&now)
+			}
+			client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{ConsulClusterGVR: "ConsulClusterList"}, obj)
+			releaser := &fakeReleaser{releases: c.releases, err: c.releaserErr}
+
+			r := &Reconciler{
+				Dynamic:  client,
+				Releaser: releaser,
+				Chart:    chrt,
+				Log:      hclog.NewNullLogger(),
+			}
+			err := r.Reconcile(context.Background(), "consul", "consul")
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, c.expCalls, releaser.calls)
+
+			actual, err := client.Resource(ConsulClusterGVR).Namespace("consul").Get(context.Background(), "consul", metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, c.expFinalizers, actual.GetFinalizers())
+			phase, _, _ := unstructured.NestedString(actual.Object, "status", "phase")
+			require.Equal(t, c.expPhase, phase)
+			message, _, _ := unstructured.NestedString(actual.Object, "status", "message")
+			require.Contains(t, message, c.expMessage)
+			revision, _, _ := unstructured.NestedInt64(actual.Object, "status", "revision")
+			require.Equal(t, c.expRevision, revision)
+			generation, _, _ := unstructured.NestedInt64(actual.Object, "status", "observedGeneration")
+			require.Equal(t, int64(3), generation)
+		})
+	}
+}
+
+func TestReconcile_notFound(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{ConsulClusterGVR: "ConsulClusterList"})
+	releaser := &fakeReleaser{}
+	r := &Reconciler{Dynamic: client, Releaser: releaser, Log: hclog.NewNullLogger()}
+	require.NoError(t, r.Reconcile(context.Background(), "consul", "consul"))
+	require.Empty(t, releaser.calls)
+}
+
+// fakeReleaser records the calls made to it and keeps the releases in memory.
+type fakeReleaser struct {
+	releases []*release.Release
+	err      error
+	calls    []string
+}
+
+func (f *fakeReleaser) List() ([]*release.Release, error) {
+	return f.releases, nil
+}
+
+func (f *fakeReleaser) Get(namespace string) (*release.Release, error) {
+	for _, rel := range f.releases {
+		if rel.Namespace == namespace {
+			return rel, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeReleaser) Install(namespace string, chrt *chart.Chart, vals map[string]interface{}, _ time.Duration) (*release.Release, error) {
+	f.calls = append(f.calls, "install "+namespace)
+	if f.err != nil {
+		return nil, f.err
+	}
+	rel := &release.Release{Name: common.DefaultReleaseName, Namespace: namespace, Version: 1, Chart: chrt, Config: vals,
+		Info: &release.Info{Status: release.StatusDeployed}}
+	f.releases = append(f.releases, rel)
+	return rel, nil
+}
+
+func (f *fakeReleaser) Upgrade(namespace string, chrt *chart.Chart, vals map[string]interface{}, _ time.Duration) (*release.Release, error) {
+	f.calls = append(f.calls, "upgrade "+namespace)
+	if f.err != nil {
+		return nil, f.err
+	}
+	current, _ := f.Get(namespace)
+	rel := &release.Release{Name: common.DefaultReleaseName, Namespace: namespace, Version: current.Version + 1, Chart: chrt, Config: vals,
+		Info: &release.Info{Status: release.StatusDeployed}}
+	*current = *rel
+	return rel, nil
+}
+
+func (f *fakeReleaser) Uninstall(namespace string) error {
+	f.calls = append(f.calls, "uninstall "+namespace)
+	return f.err
+}
@@ -0,0 +1,117 @@
+package operator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/helm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// Releaser manages the Consul Helm release. The release is always named
+// common.DefaultReleaseName.
+type Releaser interface {
+	// List returns the Consul releases in all namespaces.
+	List() ([]*release.Release, error)
+
+	// Get returns the release in the namespace, or nil if there is none.
+	Get(namespace string) (*release.Release, error)
+
+	// Install installs the chart in the namespace.
+	Install(namespace string, chart *chart.Chart, vals map[string]interface{}, timeout time.Duration) (*release.Release, error)
+
+	// Upgrade upgrades the release in the namespace to the chart.
+	Upgrade(namespace string, chart *chart.Chart, vals map[string]interface{}, timeout time.Duration) (*release.Release, error)
+
+	// Uninstall uninstalls the release in the namespace. It succeeds if there
+	// is no release.
+	Uninstall(namespace string) error
+}
+
+// HelmReleaser is the Releaser that calls the Helm Go SDK like the install,
+// upgrade and uninstall commands do.
+type HelmReleaser struct {
+	Settings *helmCLI.EnvSettings
+	Log      action.DebugLog
+}
+
+func (h *HelmReleaser) List() ([]*release.Release, error) {
+	cfg := new(action.Configuration)
+	if err := cfg.Init(h.Settings.RESTClientGetter(), "", os.Getenv("HELM_DRIVER"), h.Log); err != nil {
+		return nil, fmt.Errorf("couldn't initialize helm config: %s", err)
+	}
+	lister := action.NewList(cfg)
+	lister.AllNamespaces = true
+	lister.StateMask = action.ListAll
+	releases, err := lister.Run()
+	if err != nil {
+		return nil, err
+	}
+	var consul []*release.Release
+	for _, rel := range releases {
+		if rel.Chart != nil && rel.Chart.Metadata.Name == common.TopLevelChartDirName {
+			consul = append(consul, rel)
+		}
+	}
+	return consul, nil
+}
+
+func (h *HelmReleaser) Get(namespace string) (*release.Release, error) {
+	cfg, err := h.actionConfig(namespace)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := action.NewGet(cfg).Run(common.DefaultReleaseName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, nil
+	}
+	return rel, err
+}
+
+func (h *HelmReleaser) Install(namespace string, chart *chart.Chart, vals map[string]interface{}, timeout time.Duration) (*release.Release, error) {
+	cfg, err := h.actionConfig(namespace)
+	if err != nil {
+		return nil, err
+	}
+	install := action.NewInstall(cfg)
+	install.ReleaseName = common.DefaultReleaseName
+	install.Namespace = namespace
+	install.Wait = true
+	install.Timeout = timeout
+	return install.Run(chart, vals)
+}
+
+func (h *HelmReleaser) Upgrade(namespace string, chart *chart.Chart, vals map[string]interface{}, timeout time.Duration) (*release.Release, error) {
+	cfg, err := h.actionConfig(namespace)
+	if err != nil {
+		return nil, err
+	}
+	upgrade := action.NewUpgrade(cfg)
+	upgrade.Namespace = namespace
+	upgrade.Wait = true
+	upgrade.Timeout = timeout
+	return upgrade.Run(common.DefaultReleaseName, chart, vals)
+}
+
+func (h *HelmReleaser) Uninstall(namespace string) error {
+	cfg, err := h.actionConfig(namespace)
+	if err != nil {
+		return err
+	}
+	_, err = action.NewUninstall(cfg).Run(common.DefaultReleaseName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (h *HelmReleaser) actionConfig(namespace string) (*action.Configuration, error) {
+	return helm.InitActionConfig(new(action.Configuration), namespace, h.Settings, h.Log)
+}
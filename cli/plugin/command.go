@@ -0,0 +1,92 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/version"
+)
+
+const (
+	// EnvCLIVersion is the environment variable plugins are passed the
+	// version of the CLI in.
+	EnvCLIVersion = "CONSUL_K8S_CLI_VERSION"
+
+	// EnvPluginName is the environment variable plugins are passed the
+	// subcommand they are run as in.
+	EnvPluginName = "CONSUL_K8S_PLUGIN_NAME"
+
+	// helpTimeout is how long a plugin has to print its help.
+	helpTimeout = 5 * time.Second
+)
+
+// Command runs a plugin with the arguments of the subcommand. The plugin is
+// connected to the standard input and output of the CLI and its exit code
+// is returned.
+type Command struct {
+	Plugin Plugin
+
+	// The streams of the plugin. The ones of the CLI are used if they are not
+	// set.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Run runs the plugin and returns its exit code.
+func (c *Command) Run(args []string) int {
+	cmd := c.command(context.Background(), args)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = c.Stdin, c.Stdout, c.Stderr
+	if cmd.Stdin == nil {
+		cmd.Stdin = os.Stdin
+	}
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		fmt.Fprintf(cmd.Stderr, "Error running plugin %s: %s\n", c.Plugin.Path, err)
+		return 1
+	}
+	return 0
+}
+
+// Help returns the output of the plugin run with -help. It is shown when the
+// subcommand is run with -help, which the CLI handles itself.
+func (c *Command) Help() string {
+	ctx, cancel := context.WithTimeout(context.Background(), helpTimeout)
+	defer cancel()
+	out, err := c.command(ctx, []string{"-help"}).CombinedOutput()
+	if help := strings.TrimSpace(string(out)); err == nil && help != "" {
+		return help
+	}
+	return fmt.Sprintf("Usage: consul-k8s %s [args]\n\n  %s.", c.Plugin.Name, c.Synopsis())
+}
+
+// Synopsis returns a one-line command summary.
+func (c *Command) Synopsis() string {
+	return fmt.Sprintf("Run the plugin %s", c.Plugin.Path)
+}
+
+func (c *Command) command(ctx context.Context, args []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, c.Plugin.Path, args...)
+	cmd.Env = append(os.Environ(),
+		EnvCLIVersion+"="+version.GetHumanVersion(),
+		EnvPluginName+"="+c.Plugin.Name,
+	)
+	return cmd
+}
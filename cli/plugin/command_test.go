@@ -0,0 +1,58 @@
+package plugin
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/version"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_Run(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "consul-k8s-foo")
+	require.NoError(t, os.WriteFile(file, []byte(`#!/bin/sh
+read input
+echo "$input $@ $CONSUL_K8S_PLUGIN_NAME $CONSUL_K8S_CLI_VERSION"
+echo failed >&2
+exit 3
+`), 0755))
+
+	var stdout, stderr bytes.Buffer
+	c := &Command{
+		Plugin: Plugin{Name: "foo", Path: file},
+		Stdin:  strings.NewReader("hello\n"),
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	require.Equal(t, 3, c.Run([]string{"-x", "y"}))
+	require.Equal(t, "hello -x y foo "+version.GetHumanVersion()+"\n", stdout.String())
+	require.Equal(t, "failed\n", stderr.String())
+}
+
+func TestCommand_RunMissing(t *testing.T) {
+	var stderr bytes.Buffer
+	c := &Command{
+		Plugin: Plugin{Name: "foo", Path: filepath.Join(t.TempDir(), "consul-k8s-foo")},
+		Stderr: &stderr,
+	}
+	require.Equal(t, 1, c.Run(nil))
+	require.Contains(t, stderr.String(), "Error running plugin")
+}
+
+func TestCommand_Help(t *testing.T) {
+	dir := t.TempDir()
+	withHelp := filepath.Join(dir, "consul-k8s-foo")
+	require.NoError(t, os.WriteFile(withHelp, []byte("#!/bin/sh\necho \"Usage: consul-k8s foo $1\"\n"), 0755))
+	failing := filepath.Join(dir, "consul-k8s-bar")
+	require.NoError(t, os.WriteFile(failing, []byte("#!/bin/sh\nexit 1\n"), 0755))
+
+	c := &Command{Plugin: Plugin{Name: "foo", Path: withHelp}}
+	require.Equal(t, "Usage: consul-k8s foo -help", c.Help())
+
+	c = &Command{Plugin: Plugin{Name: "bar", Path: failing}}
+	require.Equal(t, "Usage: consul-k8s bar [args]\n\n  Run the plugin "+failing+".", c.Help())
+}
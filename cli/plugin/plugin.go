@@ -0,0 +1,139 @@
+// Package plugin lets executables named consul-k8s-<name> on the PATH
+// extend the CLI with subcommands, like kubectl plugins.
+//
+// A plugin named consul-k8s-foo-bar is run as "consul-k8s foo bar". Dashes
+// in its name separate subcommands, and underscores are used for dashes in a
+// subcommand name, e.g. consul-k8s-foo-bar_baz is run as
+// "consul-k8s foo bar-baz". Plugins cannot replace built-in commands.
+//
+// Plugins written in Go can use the helpers in this package to set up the
+// Kubernetes client and terminal UI the same way the built-in commands do,
+// and the github.com/hashicorp/consul-k8s/cli/envoy package to parse Envoy
+// admin output.
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+// Prefix is the prefix of the names of plugin executables.
+const Prefix = "consul-k8s-"
+
+// Plugin is an executable on the PATH that is run as a subcommand.
+type Plugin struct {
+	// Name is the subcommand the plugin is run as, e.g. "foo bar".
+	Name string
+
+	// Path is the path of the executable.
+	Path string
+}
+
+// Discover returns the plugins in the directories of path, which is a list
+// like the PATH environment variable. If several directories have a plugin
+// with the same name, the first one is used, like the shell does. The
+// plugins are sorted by name.
+func Discover(path string) []Plugin {
+	seen := make(map[string]struct{})
+	var plugins []Plugin
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			// Directories on the PATH that don't exist are ignored.
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := commandName(entry.Name())
+			if !ok {
+				continue
+			}
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			file := filepath.Join(dir, entry.Name())
+			if !isExecutable(file) {
+				continue
+			}
+			seen[name] = struct{}{}
+			plugins = append(plugins, Plugin{Name: name, Path: file})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool {
+		return plugins[i].Name < plugins[j].Name
+	})
+	return plugins
+}
+
+// Register adds a command for each plugin to the commands, unless the plugin
+// would replace a built-in command. It returns the plugins that were
+// registered and the ones that were not.
+func Register(commands map[string]cli.CommandFactory, plugins []Plugin) (registered, shadowed []Plugin) {
+	builtin := make(map[string]cli.CommandFactory, len(commands))
+	for name, factory := range commands {
+		builtin[name] = factory
+	}
+	for _, p := range plugins {
+		if Shadowed(p.Name, builtin) {
+			shadowed = append(shadowed, p)
+			continue
+		}
+		p := p
+		commands[p.Name] = func() (cli.Command, error) {
+			return &Command{Plugin: p}, nil
+		}
+		registered = append(registered, p)
+	}
+	return registered, shadowed
+}
+
+// Shadowed returns true if the name is one of the commands or the parent of
+// one, e.g. "proxy" for "proxy list".
+func Shadowed(name string, commands map[string]cli.CommandFactory) bool {
+	for command := range commands {
+		if command == name || strings.HasPrefix(command, name+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// commandName returns the subcommand of the executable, or false if it is
+// not a plugin.
+func commandName(file string) (string, bool) {
+	if !strings.HasPrefix(file, Prefix) {
+		return "", false
+	}
+	// Allow plugins to be run on Windows.
+	name := strings.TrimSuffix(strings.TrimPrefix(file, Prefix), ".exe")
+	if name == "" {
+		return "", false
+	}
+	parts := strings.Split(name, "-")
+	for i, part := range parts {
+		if part == "" {
+			return "", false
+		}
+		parts[i] = strings.ReplaceAll(part, "_", "-")
+	}
+	return strings.Join(parts, " "), true
+}
+
+// isExecutable returns true if the file is a regular file that can be
+// executed.
+func isExecutable(file string) bool {
+	info, err := os.Stat(file)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if filepath.Ext(file) == ".exe" {
+		return true
+	}
+	return info.Mode().Perm()&0111 != 0
+}
@@ -0,0 +1,68 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscover(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	writePlugin(t, first, "consul-k8s-foo", 0755)
+	writePlugin(t, first, "consul-k8s-foo-bar_baz", 0755)
+	writePlugin(t, first, "consul-k8s-not-executable", 0644)
+	writePlugin(t, first, "consul-k8s-", 0755)
+	writePlugin(t, first, "consul-k8s-double--dash", 0755)
+	writePlugin(t, first, "kubectl-foo", 0755)
+	require.NoError(t, os.Mkdir(filepath.Join(first, "consul-k8s-dir"), 0755))
+	writePlugin(t, second, "consul-k8s-foo", 0755)
+	writePlugin(t, second, "consul-k8s-qux", 0755)
+
+	path := strings.Join([]string{first, "", filepath.Join(first, "missing"), second}, string(os.PathListSeparator))
+	require.Equal(t, []Plugin{
+		{Name: "foo", Path: filepath.Join(first, "consul-k8s-foo")},
+		{Name: "foo bar-baz", Path: filepath.Join(first, "consul-k8s-foo-bar_baz")},
+		{Name: "qux", Path: filepath.Join(second, "consul-k8s-qux")},
+	}, Discover(path))
+}
+
+func TestRegister(t *testing.T) {
+	builtin := func() (cli.Command, error) { return nil, nil }
+	commands := map[string]cli.CommandFactory{
+		"install":    builtin,
+		"proxy list": builtin,
+	}
+	registered, shadowed := Register(commands, []Plugin{
+		{Name: "install", Path: "/bin/consul-k8s-install"},
+		{Name: "proxy", Path: "/bin/consul-k8s-proxy"},
+		{Name: "proxy trace", Path: "/bin/consul-k8s-proxy-trace"},
+		{Name: "foo", Path: "/bin/consul-k8s-foo"},
+		{Name: "foo bar", Path: "/bin/consul-k8s-foo-bar"},
+	})
+
+	require.Equal(t, []Plugin{
+		{Name: "proxy trace", Path: "/bin/consul-k8s-proxy-trace"},
+		{Name: "foo", Path: "/bin/consul-k8s-foo"},
+		{Name: "foo bar", Path: "/bin/consul-k8s-foo-bar"},
+	}, registered)
+	require.Equal(t, []Plugin{
+		{Name: "install", Path: "/bin/consul-k8s-install"},
+		{Name: "proxy", Path: "/bin/consul-k8s-proxy"},
+	}, shadowed)
+
+	require.Len(t, commands, 5)
+	command, err := commands["foo bar"]()
+	require.NoError(t, err)
+	require.Equal(t, &Command{Plugin: Plugin{Name: "foo bar", Path: "/bin/consul-k8s-foo-bar"}}, command)
+}
+
+func writePlugin(t *testing.T, dir, name string, mode os.FileMode) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(file, []byte("#!/bin/sh\n"), mode))
+	return file
+}
@@ -0,0 +1,40 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// KubernetesClient returns the client of the Kubernetes cluster selected by
+// the kubeconfig file and context, like the -kubeconfig and -context flags
+// of the built-in commands do. The KUBECONFIG environment variable and the
+// current context are used if they are empty.
+func KubernetesClient(kubeconfig, kubeContext string) (kubernetes.Interface, *rest.Config, error) {
+	settings := helmCLI.New()
+	if kubeconfig != "" {
+		settings.KubeConfig = kubeconfig
+	}
+	if kubeContext != "" {
+		settings.KubeContext = kubeContext
+	}
+	restConfig, err := settings.RESTClientGetter().ToRESTConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error retrieving Kubernetes authentication:\n%v", err)
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error initializing Kubernetes client:\n%v", err)
+	}
+	return client, restConfig, nil
+}
+
+// NewUI returns the terminal UI the built-in commands write their output
+// with, so that plugins look the same.
+func NewUI(ctx context.Context) terminal.UI {
+	return terminal.NewBasicUI(ctx)
+}
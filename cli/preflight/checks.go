@@ -0,0 +1,177 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// removedValueHints are suggestions for replacing Helm values that have been
+// removed from the chart, keyed by the path of the removed value.
+var removedValueHints = map[string]string{
+	"apiGateway.consulNamespaces": "Set connectInject.consulNamespaces instead.",
+}
+
+// crdChange is a breaking change to a CRD that requires existing custom
+// resources to be updated.
+type crdChange struct {
+	// version is the chart version that introduced the change.
+	version string
+	// kind is the kind of the custom resource.
+	kind    string
+	message string
+}
+
+// crdChanges are the known breaking changes to the CRDs of the chart.
+var crdChanges = []crdChange{
+	{
+		version: "0.41.0",
+		kind:    "IngressGateway",
+		message: "The gatewayTLSConfig, gatewayServiceTLSConfig and gatewayTLSSDSConfig fields have been renamed to tls, tls and sds. " +
+			"IngressGateway resources that use the old field names must be updated.",
+	},
+}
+
+// deprecatedAnnotations are the pod annotations that are no longer supported,
+// with a suggestion of what to use instead. Annotations that are deprecated
+// but still supported, e.g. consul.hashicorp.com/connect-service-tags, don't
+// break upgrades and are not listed.
+var deprecatedAnnotations = map[string]string{
+	"consul.hashicorp.com/connect-service-protocol": "Set the protocol with a ServiceDefaults resource instead.",
+	"consul.hashicorp.com/connect-sync-period":      "The annotation can be removed.",
+}
+
+const (
+	// maxListedPods is the maximum number of pods listed in an issue.
+	maxListedPods = 5
+	// podPageSize is the number of pods listed per request so that the pods
+	// of large clusters are not all loaded at once.
+	podPageSize = 500
+)
+
+// checkRemovedValues reports values that are set for the upgrade but which are
+// defined by the current chart and no longer by the target chart. Values that
+// neither chart defines, e.g. the keys of maps such as annotations, are not
+// reported.
+func checkRemovedValues(_ context.Context, u *Upgrade) ([]string, error) {
+	var removed []string
+	findRemovedValues(u.Values, u.Current.Values, u.Target.Values, "", &removed)
+	sort.Strings(removed)
+
+	var messages []string
+	for _, path := range removed {
+		message := fmt.Sprintf("The value %s is set but has been removed from the chart.", path)
+		if hint, ok := removedValueHints[path]; ok {
+			message += " " + hint
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func findRemovedValues(vals, current, target map[string]interface{}, prefix string, removed *[]string) {
+	for key, val := range vals {
+		path := prefix + key
+		currentVal, inCurrent := current[key]
+		if !inCurrent {
+			continue
+		}
+		targetVal, inTarget := target[key]
+		if !inTarget {
+			*removed = append(*removed, path)
+			continue
+		}
+
+		valMap, ok := val.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		currentMap, ok := currentVal.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		targetMap, _ := targetVal.(map[string]interface{})
+		findRemovedValues(valMap, currentMap, targetMap, path+".", removed)
+	}
+}
+
+// checkCRDUpgrades reports the known breaking changes to CRDs introduced
+// between the current and target chart versions if the CRDs are installed.
+func checkCRDUpgrades(_ context.Context, u *Upgrade) ([]string, error) {
+	if !controllerEnabled(u) {
+		return nil, nil
+	}
+	current, target, err := chartVersions(u)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []string
+	for _, change := range crdChanges {
+		version := semver.MustParse(change.version)
+		if current.LessThan(version) && !target.LessThan(version) {
+			messages = append(messages, fmt.Sprintf("The %s CRD is upgraded by chart version %s. %s", change.kind, change.version, change.message))
+		}
+	}
+	return messages, nil
+}
+
+// controllerEnabled returns whether the controller and its CRDs are enabled
+// by the upgrade.
+func controllerEnabled(u *Upgrade) bool {
+	for _, vals := range []map[string]interface{}{u.Values, u.Target.Values} {
+		if controller, ok := vals["controller"].(map[string]interface{}); ok {
+			if enabled, ok := controller["enabled"].(bool); ok {
+				return enabled
+			}
+		}
+	}
+	return false
+}
+
+// checkDeprecatedAnnotations reports pods in any namespace that use
+// annotations which are no longer supported.
+func checkDeprecatedAnnotations(ctx context.Context, u *Upgrade) ([]string, error) {
+	podsByAnnotation := make(map[string][]string)
+	opts := metav1.ListOptions{Limit: podPageSize}
+	for {
+		pods, err := u.Kubernetes.CoreV1().Pods("").List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods.Items {
+			for annotation := range deprecatedAnnotations {
+				if _, ok := pod.Annotations[annotation]; ok {
+					podsByAnnotation[annotation] = append(podsByAnnotation[annotation], pod.Namespace+"/"+pod.Name)
+				}
+			}
+		}
+		if pods.Continue == "" {
+			break
+		}
+		opts.Continue = pods.Continue
+	}
+
+	var annotations []string
+	for annotation := range podsByAnnotation {
+		annotations = append(annotations, annotation)
+	}
+	sort.Strings(annotations)
+
+	var messages []string
+	for _, annotation := range annotations {
+		names := podsByAnnotation[annotation]
+		sort.Strings(names)
+		listed := names
+		if len(listed) > maxListedPods {
+			listed = append(listed[:maxListedPods:maxListedPods], fmt.Sprintf("and %d more", len(names)-maxListedPods))
+		}
+		messages = append(messages, fmt.Sprintf("%d pods use the deprecated annotation %q: %s. %s",
+			len(names), annotation, strings.Join(listed, ", "), deprecatedAnnotations[annotation]))
+	}
+	return messages, nil
+}
@@ -0,0 +1,105 @@
+// Package preflight checks an upgrade of an existing Consul installation for
+// known breaking changes between the installed chart and the chart being
+// upgraded to before any change is made to the cluster.
+package preflight
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+	"helm.sh/helm/v3/pkg/chart"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Upgrade describes the upgrade that is being checked.
+type Upgrade struct {
+	// Current is the chart the existing release was installed from. Its
+	// metadata and default values are used to find what changes in Target.
+	Current *chart.Chart
+	// Target is the chart being upgraded to.
+	Target *chart.Chart
+	// Values are the values the upgrade is performed with.
+	Values map[string]interface{}
+	// Kubernetes is used to inspect the resources in the cluster.
+	Kubernetes kubernetes.Interface
+}
+
+// Issue is a breaking change found by a check.
+type Issue struct {
+	// Check is the name of the check that found the issue.
+	Check string
+	// Message describes the breaking change and how to address it.
+	Message string
+}
+
+// check inspects an upgrade and returns the breaking changes it found.
+type check struct {
+	name string
+	run  func(ctx context.Context, u *Upgrade) ([]string, error)
+}
+
+// checks are run in order by Run.
+var checks = []check{
+	{name: "chart version", run: checkChartVersion},
+	{name: "removed values", run: checkRemovedValues},
+	{name: "CRD upgrades", run: checkCRDUpgrades},
+	{name: "deprecated annotations", run: checkDeprecatedAnnotations},
+}
+
+// Run runs every check against the upgrade and returns the issues they found.
+// An error is returned if a check could not be run.
+func Run(ctx context.Context, u *Upgrade) ([]Issue, error) {
+	var issues []Issue
+	for _, c := range checks {
+		messages, err := c.run(ctx, u)
+		if err != nil {
+			return nil, fmt.Errorf("error running %s check: %s", c.name, err)
+		}
+		for _, m := range messages {
+			issues = append(issues, Issue{Check: c.name, Message: m})
+		}
+	}
+	return issues, nil
+}
+
+// checkChartVersion reports a downgrade of the chart, since the chart does not
+// support rolling back changes made by newer versions, e.g. to CRDs.
+func checkChartVersion(_ context.Context, u *Upgrade) ([]string, error) {
+	current, target, err := chartVersions(u)
+	if err != nil {
+		return nil, err
+	}
+	if target.LessThan(current) {
+		return []string{fmt.Sprintf("The chart version %s is older than the installed chart version %s. Downgrades are not supported.",
+			target, current)}, nil
+	}
+	if u.Current.Metadata.AppVersion != "" && u.Target.Metadata.AppVersion != "" {
+		currentConsul, err := semver.NewVersion(u.Current.Metadata.AppVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Consul version %q: %s", u.Current.Metadata.AppVersion, err)
+		}
+		targetConsul, err := semver.NewVersion(u.Target.Metadata.AppVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Consul version %q: %s", u.Target.Metadata.AppVersion, err)
+		}
+		if targetConsul.LessThan(currentConsul) {
+			return []string{fmt.Sprintf("The Consul version %s is older than the installed Consul version %s. Downgrades are not supported.",
+				targetConsul, currentConsul)}, nil
+		}
+	}
+	return nil, nil
+}
+
+// chartVersions returns the versions of the current and target charts.
+func chartVersions(u *Upgrade) (*semver.Version, *semver.Version, error) {
+	current, err := semver.NewVersion(u.Current.Metadata.Version)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid chart version %q: %s", u.Current.Metadata.Version, err)
+	}
+	target, err := semver.NewVersion(u.Target.Metadata.Version)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid chart version %q: %s", u.Target.Metadata.Version, err)
+	}
+	return current, target, nil
+}
@@ -0,0 +1,195 @@
+package preflight
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestRun(t *testing.T) {
+	currentValues := map[string]interface{}{
+		"controller": map[string]interface{}{"enabled": false},
+		"apiGateway": map[string]interface{}{
+			"enabled":          false,
+			"consulNamespaces": map[string]interface{}{"consulDestinationNamespace": "default"},
+		},
+		"client": map[string]interface{}{
+			"nodeMeta": map[string]interface{}{"pod-name": "${HOSTNAME}"},
+		},
+	}
+	targetValues := map[string]interface{}{
+		"controller": map[string]interface{}{"enabled": false},
+		"apiGateway": map[string]interface{}{"enabled": false},
+		"client": map[string]interface{}{
+			"nodeMeta": map[string]interface{}{"pod-name": "${HOSTNAME}"},
+		},
+	}
+
+	cases := map[string]struct {
+		currentVersion string
+		targetVersion  string
+		values         map[string]interface{}
+		pods           []testPod
+		expIssues      []Issue
+	}{
+		"no breaking changes": {
+			currentVersion: "0.41.0",
+			targetVersion:  "0.42.0",
+			values: map[string]interface{}{
+				"client": map[string]interface{}{
+					"nodeMeta": map[string]interface{}{"custom": "value"},
+				},
+			},
+			pods: []testPod{{name: "web", annotations: map[string]string{"consul.hashicorp.com/service-tags": "v1"}}},
+		},
+		"downgrade": {
+			currentVersion: "0.42.0",
+			targetVersion:  "0.41.0",
+			expIssues: []Issue{{
+				Check:   "chart version",
+				Message: "The chart version 0.41.0 is older than the installed chart version 0.42.0. Downgrades are not supported.",
+			}},
+		},
+		"removed value": {
+			currentVersion: "0.42.0",
+			targetVersion:  "0.43.0",
+			values: map[string]interface{}{
+				"apiGateway": map[string]interface{}{
+					"enabled":          true,
+					"consulNamespaces": map[string]interface{}{"consulDestinationNamespace": "ns"},
+				},
+			},
+			expIssues: []Issue{{
+				Check:   "removed values",
+				Message: "The value apiGateway.consulNamespaces is set but has been removed from the chart. Set connectInject.consulNamespaces instead.",
+			}},
+		},
+		"CRD upgrade with controller enabled": {
+			currentVersion: "0.40.0",
+			targetVersion:  "0.42.0",
+			values: map[string]interface{}{
+				"controller": map[string]interface{}{"enabled": true},
+			},
+			expIssues: []Issue{{
+				Check: "CRD upgrades",
+				Message: "The IngressGateway CRD is upgraded by chart version 0.41.0. " +
+					"The gatewayTLSConfig, gatewayServiceTLSConfig and gatewayTLSSDSConfig fields have been renamed to tls, tls and sds. " +
+					"IngressGateway resources that use the old field names must be updated.",
+			}},
+		},
+		"CRD upgrade with controller disabled": {
+			currentVersion: "0.40.0",
+			targetVersion:  "0.42.0",
+		},
+		"CRD upgrade already applied": {
+			currentVersion: "0.41.0",
+			targetVersion:  "0.42.0",
+			values: map[string]interface{}{
+				"controller": map[string]interface{}{"enabled": true},
+			},
+		},
+		"deprecated annotations": {
+			currentVersion: "0.41.0",
+			targetVersion:  "0.42.0",
+			pods: []testPod{
+				{name: "web", annotations: map[string]string{"consul.hashicorp.com/connect-service-protocol": "http"}},
+				{name: "api", annotations: map[string]string{
+					"consul.hashicorp.com/connect-service-protocol": "http",
+					"consul.hashicorp.com/connect-sync-period":      "10s",
+				}},
+				// connect-service-tags is deprecated but still supported.
+				{name: "db", annotations: map[string]string{"consul.hashicorp.com/connect-service-tags": "v1"}},
+			},
+			expIssues: []Issue{
+				{
+					Check: "deprecated annotations",
+					Message: `2 pods use the deprecated annotation "consul.hashicorp.com/connect-service-protocol": default/api, default/web. ` +
+						"Set the protocol with a ServiceDefaults resource instead.",
+				},
+				{
+					Check:   "deprecated annotations",
+					Message: `1 pods use the deprecated annotation "consul.hashicorp.com/connect-sync-period": default/api. The annotation can be removed.`,
+				},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			k8s := fake.NewSimpleClientset()
+			for _, p := range c.pods {
+				_, err := k8s.CoreV1().Pods("default").Create(context.Background(), &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: p.name, Namespace: "default", Annotations: p.annotations},
+				}, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+
+			issues, err := Run(context.Background(), &Upgrade{
+				Current: &chart.Chart{
+					Metadata: &chart.Metadata{Version: c.currentVersion, AppVersion: "1.11.4"},
+					Values:   currentValues,
+				},
+				Target: &chart.Chart{
+					Metadata: &chart.Metadata{Version: c.targetVersion, AppVersion: "1.11.4"},
+					Values:   targetValues,
+				},
+				Values:     c.values,
+				Kubernetes: k8s,
+			})
+			require.NoError(t, err)
+			require.Equal(t, c.expIssues, issues)
+		})
+	}
+}
+
+func TestRun_InvalidVersion(t *testing.T) {
+	_, err := Run(context.Background(), &Upgrade{
+		Current:    &chart.Chart{Metadata: &chart.Metadata{Version: "invalid"}},
+		Target:     &chart.Chart{Metadata: &chart.Metadata{Version: "0.42.0"}},
+		Kubernetes: fake.NewSimpleClientset(),
+	})
+	require.EqualError(t, err, `error running chart version check: invalid chart version "invalid": Invalid Semantic Version`)
+}
+
+// Test that every page of pods is checked.
+func TestCheckDeprecatedAnnotations_Pages(t *testing.T) {
+	pages := []*corev1.PodList{
+		{
+			ListMeta: metav1.ListMeta{Continue: "page-2"},
+			Items: []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default",
+				Annotations: map[string]string{"consul.hashicorp.com/connect-sync-period": "10s"}}}},
+		},
+		{
+			Items: []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "other",
+				Annotations: map[string]string{"consul.hashicorp.com/connect-sync-period": "10s"}}}},
+		},
+	}
+	k8s := fake.NewSimpleClientset()
+	// The fake client doesn't pass on the continue token, so the pages are
+	// returned in the order they are listed in.
+	k8s.PrependReactor("list", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		page := pages[0]
+		pages = pages[1:]
+		return true, page, nil
+	})
+
+	messages, err := checkDeprecatedAnnotations(context.Background(), &Upgrade{Kubernetes: k8s})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		`2 pods use the deprecated annotation "consul.hashicorp.com/connect-sync-period": default/web, other/api. The annotation can be removed.`,
+	}, messages)
+	require.Empty(t, pages)
+}
+
+// testPod is a pod created in the cluster before the checks are run.
+type testPod struct {
+	name        string
+	annotations map[string]string
+}
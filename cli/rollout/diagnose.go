@@ -0,0 +1,312 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// maxDiagnosedPods is the maximum number of pods of a component that are
+	// diagnosed.
+	maxDiagnosedPods = 3
+	// maxLogLines is the number of the last log lines of a container that are
+	// collected.
+	maxLogLines = 20
+	// maxEvents is the number of the most recent events of a pod that are
+	// collected.
+	maxEvents = 10
+)
+
+// Diagnostic describes why a component hasn't rolled out.
+type Diagnostic struct {
+	Component Component
+	// Object is the pod that isn't ready, e.g. "Pod consul-server-0", or the
+	// workload itself if none of its pods are unready, e.g. because they
+	// couldn't be created.
+	Object string
+	// Problems are the reasons the containers of the pod aren't ready, e.g.
+	// "container consul is waiting: CrashLoopBackOff: back-off 5m0s
+	// restarting failed container".
+	Problems []string
+	// Logs are the last lines of the logs of the containers that aren't
+	// ready.
+	Logs []ContainerLogs
+	// Events are the most recent events of the object, oldest first.
+	Events []string
+}
+
+// ContainerLogs are the last lines of the logs of a container.
+type ContainerLogs struct {
+	Container string
+	// Previous is whether the logs are of the previous instance of the
+	// container, which is the one that failed if it's restarting.
+	Previous bool
+	Logs     string
+}
+
+// Diagnose collects the problems, logs and events of the unready pods of
+// each component that hasn't rolled out.
+func (w *Watcher) Diagnose(ctx context.Context, statuses []Status) ([]Diagnostic, error) {
+	var diagnostics []Diagnostic
+	for _, s := range statuses {
+		if s.Done {
+			continue
+		}
+		d, err := w.diagnose(ctx, s.Component)
+		if err != nil {
+			return nil, fmt.Errorf("error diagnosing %s: %s", s.Component.DisplayName(), err)
+		}
+		diagnostics = append(diagnostics, d...)
+	}
+	return diagnostics, nil
+}
+
+func (w *Watcher) diagnose(ctx context.Context, c Component) ([]Diagnostic, error) {
+	selector, err := w.podSelector(ctx, c)
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pods, err := w.Kubernetes.CoreV1().Pods(w.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
+
+	var diagnostics []Diagnostic
+	for _, pod := range pods.Items {
+		if podReady(pod) {
+			continue
+		}
+		d := Diagnostic{Component: c, Object: "Pod " + pod.Name, Problems: podProblems(pod)}
+		for _, container := range unreadyContainers(pod) {
+			previous := container.RestartCount > 0 && container.State.Running == nil
+			logs, err := w.Kubernetes.CoreV1().Pods(w.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+				Container: container.Name,
+				Previous:  previous,
+				TailLines: int64Ptr(maxLogLines),
+			}).DoRaw(ctx)
+			// Containers that never started, e.g. because their image can't
+			// be pulled, have no logs.
+			if err != nil || len(strings.TrimSpace(string(logs))) == 0 {
+				continue
+			}
+			d.Logs = append(d.Logs, ContainerLogs{Container: container.Name, Previous: previous, Logs: string(logs)})
+		}
+		if d.Events, err = w.events(ctx, "Pod", pod.Name); err != nil {
+			return nil, err
+		}
+		diagnostics = append(diagnostics, d)
+		if len(diagnostics) == maxDiagnosedPods {
+			break
+		}
+	}
+
+	// The events of the workload explain why its pods are missing, e.g.
+	// because they are rejected by a quota.
+	if len(diagnostics) == 0 {
+		d := Diagnostic{Component: c, Object: c.Kind + " " + c.Workload}
+		if d.Events, err = w.events(ctx, c.Kind, c.Workload); err != nil {
+			return nil, err
+		}
+		if len(d.Events) > 0 {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	return diagnostics, nil
+}
+
+// podSelector returns the selector of the pods of the component's workload.
+func (w *Watcher) podSelector(ctx context.Context, c Component) (string, error) {
+	var selector *metav1.LabelSelector
+	switch c.Kind {
+	case kindStatefulSet:
+		sts, err := w.Kubernetes.AppsV1().StatefulSets(w.Namespace).Get(ctx, c.Workload, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		selector = sts.Spec.Selector
+	case kindDaemonSet:
+		ds, err := w.Kubernetes.AppsV1().DaemonSets(w.Namespace).Get(ctx, c.Workload, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		selector = ds.Spec.Selector
+	case kindDeployment:
+		deployment, err := w.Kubernetes.AppsV1().Deployments(w.Namespace).Get(ctx, c.Workload, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		selector = deployment.Spec.Selector
+	case kindJob:
+		job, err := w.Kubernetes.BatchV1().Jobs(w.Namespace).Get(ctx, c.Workload, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		selector = job.Spec.Selector
+	default:
+		return "", fmt.Errorf("unsupported kind %s", c.Kind)
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return "", err
+	}
+	// A selector that selects everything would diagnose unrelated pods.
+	if s.Empty() {
+		return labels.Nothing().String(), nil
+	}
+	return s.String(), nil
+}
+
+// events returns the most recent events of the object, oldest first.
+func (w *Watcher) events(ctx context.Context, kind, name string) ([]string, error) {
+	list, err := w.Kubernetes.CoreV1().Events(w.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.kind=%s,involvedObject.name=%s", kind, name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var events []corev1.Event
+	for _, e := range list.Items {
+		if e.InvolvedObject.Kind == kind && e.InvolvedObject.Name == name {
+			events = append(events, e)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return eventTime(events[i]).Before(eventTime(events[j])) })
+	if len(events) > maxEvents {
+		events = events[len(events)-maxEvents:]
+	}
+
+	var messages []string
+	for _, e := range events {
+		msg := fmt.Sprintf("%s %s: %s", e.Type, e.Reason, strings.TrimSpace(e.Message))
+		if e.Count > 1 {
+			msg += fmt.Sprintf(" (x%d)", e.Count)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// PrintDiagnostics prints the diagnostics of the components that haven't
+// rolled out.
+func PrintDiagnostics(ui terminal.UI, diagnostics []Diagnostic) {
+	for _, d := range diagnostics {
+		ui.Output("%s: %s", d.Component.DisplayName(), d.Object, terminal.WithHeaderStyle())
+		for _, p := range d.Problems {
+			ui.Output("%s", p, terminal.WithErrorStyle())
+		}
+		for _, l := range d.Logs {
+			title := fmt.Sprintf("Logs of container %s", l.Container)
+			if l.Previous {
+				title += " (previous instance)"
+			}
+			ui.Output(title+":", terminal.WithInfoStyle())
+			ui.Output("%s", strings.TrimRight(l.Logs, "\n"), terminal.WithLibraryStyle())
+		}
+		if len(d.Events) > 0 {
+			ui.Output("Events:", terminal.WithInfoStyle())
+			for _, e := range d.Events {
+				ui.Output("%s", e, terminal.WithLibraryStyle())
+			}
+		}
+	}
+}
+
+// PrintProgress prints the status of a component. It is meant to be used as
+// the Progress of a Watcher.
+func PrintProgress(ui terminal.UI) func(Status) {
+	return func(s Status) {
+		style := terminal.WithInfoStyle()
+		switch {
+		case s.Done:
+			style = terminal.WithSuccessStyle()
+		case s.Failed:
+			style = terminal.WithErrorStyle()
+		}
+		ui.Output("%s: %s", s.Component.DisplayName(), s.Message, style)
+	}
+}
+
+// podProblems returns the reasons the containers of the pod aren't ready.
+func podProblems(pod corev1.Pod) []string {
+	var problems []string
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionFalse {
+			problems = append(problems, strings.TrimSuffix(fmt.Sprintf("pod is not scheduled: %s: %s", cond.Reason, cond.Message), ": "))
+		}
+	}
+	for _, container := range unreadyContainers(pod) {
+		switch {
+		case container.State.Waiting != nil:
+			problems = append(problems, strings.TrimSuffix(fmt.Sprintf("container %s is waiting: %s: %s",
+				container.Name, container.State.Waiting.Reason, container.State.Waiting.Message), ": "))
+		case container.State.Terminated != nil:
+			problems = append(problems, fmt.Sprintf("container %s exited with code %d: %s",
+				container.Name, container.State.Terminated.ExitCode, container.State.Terminated.Reason))
+		case container.State.Running != nil:
+			problems = append(problems, fmt.Sprintf("container %s is running but not ready", container.Name))
+		}
+		if container.RestartCount > 0 {
+			problems = append(problems, fmt.Sprintf("container %s restarted %d times", container.Name, container.RestartCount))
+		}
+	}
+	return problems
+}
+
+// unreadyContainers returns the init containers that haven't completed and
+// the containers that aren't ready. Containers that haven't started because
+// an init container hasn't completed are left out.
+func unreadyContainers(pod corev1.Pod) []corev1.ContainerStatus {
+	for _, init := range pod.Status.InitContainerStatuses {
+		if init.State.Terminated == nil || init.State.Terminated.ExitCode != 0 {
+			return []corev1.ContainerStatus{init}
+		}
+	}
+	var containers []corev1.ContainerStatus
+	for _, container := range pod.Status.ContainerStatuses {
+		// Containers of job pods that completed aren't ready either.
+		if container.Ready || (container.State.Terminated != nil && container.State.Terminated.ExitCode == 0) {
+			continue
+		}
+		containers = append(containers, container)
+	}
+	return containers
+}
+
+func podReady(pod corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded {
+		return true
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func eventTime(e corev1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
+}
+
+func int64Ptr(i int64) *int64 {
+	return &i
+}
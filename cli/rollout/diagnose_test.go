@@ -0,0 +1,175 @@
+package rollout
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/helper/test"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWatcher_Diagnose(t *testing.T) {
+	server := Component{Name: "server", Kind: kindStatefulSet, Workload: "consul-server"}
+	now := time.Now()
+
+	cases := map[string]struct {
+		objects        []runtime.Object
+		statuses       []Status
+		expDiagnostics []Diagnostic
+	}{
+		"done components are not diagnosed": {
+			objects:  []runtime.Object{statefulSet("consul-server", 1, 0, 1, 0), withContainers(test.ServerPod("consul-server-0", "consul", ""), crashingContainer())},
+			statuses: []Status{{Component: server, Done: true}},
+		},
+		"crashing container": {
+			objects: []runtime.Object{
+				statefulSet("consul-server", 2, 1, 2, 0),
+				withContainers(test.ServerPod("consul-server-0", "consul", ""), readyContainer()),
+				withContainers(test.ServerPod("consul-server-1", "consul", ""), crashingContainer()),
+				podEvent("consul-server-1", "Warning", "BackOff", "Back-off restarting failed container", 4, now),
+				podEvent("consul-server-1", "Normal", "Pulled", "Container image already present", 1, now.Add(-time.Minute)),
+				podEvent("consul-server-0", "Normal", "Started", "Started container consul", 1, now),
+			},
+			statuses: []Status{{Component: server}},
+			expDiagnostics: []Diagnostic{{
+				Component: server,
+				Object:    "Pod consul-server-1",
+				Problems: []string{
+					"container consul is waiting: CrashLoopBackOff: back-off 40s restarting failed container",
+					"container consul restarted 3 times",
+				},
+				// The fake clientset returns the same logs for every container.
+				Logs: []ContainerLogs{{Container: "consul", Previous: true, Logs: "fake logs"}},
+				Events: []string{
+					"Normal Pulled: Container image already present",
+					"Warning BackOff: Back-off restarting failed container (x4)",
+				},
+			}},
+		},
+		"pending init container": {
+			objects: []runtime.Object{
+				statefulSet("consul-server", 1, 0, 1, 0),
+				withContainers(test.ServerPod("consul-server-0", "consul", ""), corev1.ContainerStatus{Name: "consul"}, corev1.ContainerStatus{
+					Name:  "locality-init",
+					State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				}),
+			},
+			statuses: []Status{{Component: server}},
+			expDiagnostics: []Diagnostic{{
+				Component: server,
+				Object:    "Pod consul-server-0",
+				Problems:  []string{"container locality-init is running but not ready"},
+				Logs:      []ContainerLogs{{Container: "locality-init", Logs: "fake logs"}},
+			}},
+		},
+		"no pods": {
+			objects: []runtime.Object{
+				statefulSet("consul-server", 1, 0, 1, 0),
+				&corev1.Event{
+					ObjectMeta:     metav1.ObjectMeta{Name: "consul-server.1", Namespace: "consul"},
+					InvolvedObject: corev1.ObjectReference{Kind: kindStatefulSet, Name: "consul-server"},
+					Type:           "Warning",
+					Reason:         "FailedCreate",
+					Message:        "create Pod consul-server-0 failed: exceeded quota",
+				},
+			},
+			statuses: []Status{{Component: server}},
+			expDiagnostics: []Diagnostic{{
+				Component: server,
+				Object:    "StatefulSet consul-server",
+				Events:    []string{"Warning FailedCreate: create Pod consul-server-0 failed: exceeded quota"},
+			}},
+		},
+		"deleted workload": {
+			statuses: []Status{{Component: server}},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := &Watcher{Kubernetes: fake.NewSimpleClientset(c.objects...), Namespace: "consul"}
+			diagnostics, err := w.Diagnose(context.Background(), c.statuses)
+			require.NoError(t, err)
+			require.Equal(t, c.expDiagnostics, diagnostics)
+		})
+	}
+}
+
+func TestPrintDiagnostics(t *testing.T) {
+	buf := new(bytes.Buffer)
+	output := color.Output
+	color.Output = buf
+	defer func() { color.Output = output }()
+
+	PrintDiagnostics(terminal.NewBasicUI(context.Background()), []Diagnostic{{
+		Component: Component{Name: "server", Kind: kindStatefulSet, Workload: "consul-server"},
+		Object:    "Pod consul-server-1",
+		Problems:  []string{"container consul exited with code 1: Error"},
+		Logs:      []ContainerLogs{{Container: "consul", Previous: true, Logs: "==> 100% failed to parse config\n"}},
+		Events:    []string{"Warning BackOff: Back-off restarting failed container (x4)"},
+	}})
+
+	out := buf.String()
+	require.Contains(t, out, "Consul servers: Pod consul-server-1")
+	require.Contains(t, out, "container consul exited with code 1: Error")
+	require.Contains(t, out, "Logs of container consul (previous instance):")
+	require.Contains(t, out, "==> 100% failed to parse config")
+	require.Contains(t, out, "Warning BackOff: Back-off restarting failed container (x4)")
+}
+
+// withContainers sets the container statuses of the pod, which is ready if
+// all of them are.
+func withContainers(pod *corev1.Pod, containers ...corev1.ContainerStatus) *corev1.Pod {
+	ready := corev1.ConditionTrue
+	for _, container := range containers {
+		if container.Name == "locality-init" {
+			pod.Status.InitContainerStatuses = append(pod.Status.InitContainerStatuses, container)
+		} else {
+			pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, container)
+		}
+		if !container.Ready {
+			ready = corev1.ConditionFalse
+		}
+	}
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}}
+	return pod
+}
+
+func readyContainer() corev1.ContainerStatus {
+	return corev1.ContainerStatus{
+		Name:  "consul",
+		Ready: true,
+		State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+	}
+}
+
+func crashingContainer() corev1.ContainerStatus {
+	return corev1.ContainerStatus{
+		Name:         "consul",
+		RestartCount: 3,
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+			Reason:  "CrashLoopBackOff",
+			Message: "back-off 40s restarting failed container",
+		}},
+	}
+}
+
+func podEvent(pod, eventType, reason, message string, count int32, last time.Time) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: pod + "." + reason, Namespace: "consul"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: pod},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		Count:          count,
+		LastTimestamp:  metav1.NewTime(last),
+	}
+}
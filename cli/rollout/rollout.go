@@ -0,0 +1,412 @@
+// Package rollout waits for the components of a Consul installation to roll
+// out after an install or upgrade, reports their progress while they do, and
+// collects diagnostics of the components that failed to roll out.
+package rollout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/helm"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// Events of the Helm hooks that run as part of an install or upgrade.
+const (
+	Install = "install"
+	Upgrade = "upgrade"
+)
+
+const (
+	kindDeployment  = "Deployment"
+	kindStatefulSet = "StatefulSet"
+	kindDaemonSet   = "DaemonSet"
+	kindJob         = "Job"
+	kindWebhook     = "MutatingWebhookConfiguration"
+
+	// hookAnnotation is the annotation of the resources that are Helm hooks.
+	hookAnnotation = "helm.sh/hook"
+
+	defaultInterval = 2 * time.Second
+)
+
+// displayNames are the names the progress of the components with a single
+// workload is reported under, keyed by their component label.
+var displayNames = map[string]string{
+	"server":               "Consul servers",
+	"client":               "Consul clients",
+	"connect-injector":     "Connect injector",
+	"controller":           "Controller",
+	"sync-catalog":         "Catalog sync",
+	"server-acl-init":      "ACL bootstrap",
+	"tls-init":             "TLS bootstrap",
+	"webhook-cert-manager": "Webhook certificate manager",
+}
+
+// ErrTimeout is returned by Wait if the components did not roll out in time.
+var ErrTimeout = errors.New("timed out waiting for the components to be ready")
+
+// Component is a workload of the release that is waited for.
+type Component struct {
+	// Name is the component label of the workload, e.g. "server".
+	Name string
+	// Kind is Deployment, StatefulSet, DaemonSet or Job.
+	Kind string
+	// Workload is the name of the Kubernetes resource.
+	Workload string
+	// Hook is whether the workload is a Helm hook.
+	Hook bool
+	// Webhook is the name of the MutatingWebhookConfiguration of the
+	// component, if it has one. The component is only ready once the CA
+	// bundle of the webhooks is set.
+	Webhook string
+}
+
+// DisplayName returns the name the progress of the component is reported
+// under, e.g. "Consul servers" or the name of the workload.
+func (c Component) DisplayName() string {
+	if name, ok := displayNames[c.Name]; ok {
+		return name
+	}
+	return c.Workload
+}
+
+// Status is the rollout status of a component.
+type Status struct {
+	Component Component
+	// Message describes the progress of the component, e.g.
+	// "2/3 servers joined the Raft cluster".
+	Message string
+	// Done is whether the component rolled out.
+	Done bool
+	// Failed is whether the component failed and won't roll out without
+	// intervention, e.g. because its job exceeded its backoff limit.
+	Failed bool
+}
+
+// Watcher waits for components to roll out.
+type Watcher struct {
+	Kubernetes kubernetes.Interface
+	Namespace  string
+	// Interval is the interval the components are checked at. It defaults to
+	// two seconds.
+	Interval time.Duration
+	// AfterApply is set when the watch starts after Helm applied the release
+	// and ran its hooks, e.g. for upgrades. Jobs that don't exist are then
+	// done, since the chart only deletes jobs once they succeeded. Otherwise
+	// only the jobs that were seen before are.
+	AfterApply bool
+	// Progress is called with the status of a component whenever its message
+	// changes. It is optional.
+	Progress func(Status)
+
+	seen     map[string]bool
+	messages map[string]string
+}
+
+// Components returns the components of the release in the manifests that
+// are waited for: its deployments, stateful sets, daemon sets and jobs,
+// including the hooks that run on the event, which is Install or Upgrade.
+func Components(manifests, event string) ([]Component, error) {
+	webhooks := make(map[string]string)
+	docs, err := helm.ManifestsOfKind(manifests, kindWebhook)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		var obj metav1.PartialObjectMetadata
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			return nil, fmt.Errorf("error parsing manifest: %s", err)
+		}
+		if component := obj.Labels["component"]; component != "" {
+			webhooks[component] = obj.Name
+		}
+	}
+
+	var components []Component
+	for _, kind := range []string{kindStatefulSet, kindDaemonSet, kindDeployment, kindJob} {
+		docs, err := helm.ManifestsOfKind(manifests, kind)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			var obj metav1.PartialObjectMetadata
+			if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+				return nil, fmt.Errorf("error parsing manifest: %s", err)
+			}
+			hook, isHook := obj.Annotations[hookAnnotation]
+			if isHook && !strings.Contains(hook, "pre-"+event) && !strings.Contains(hook, "post-"+event) {
+				continue
+			}
+			c := Component{
+				Name:     obj.Labels["component"],
+				Kind:     kind,
+				Workload: obj.Name,
+				Hook:     isHook,
+			}
+			if kind == kindDeployment {
+				c.Webhook = webhooks[c.Name]
+			}
+			components = append(components, c)
+		}
+	}
+	return components, nil
+}
+
+// Wait checks the components until they have all rolled out and returns
+// their statuses. It returns ErrTimeout with the last statuses if the context
+// is done first, and an error naming the component if one failed.
+func (w *Watcher) Wait(ctx context.Context, components []Component) ([]Status, error) {
+	interval := w.Interval
+	if interval == 0 {
+		interval = defaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var statuses []Status
+	for {
+		current, err := w.Check(ctx, components)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			return statuses, err
+		case err == nil:
+			statuses = current
+			done := true
+			for _, s := range statuses {
+				if s.Failed {
+					return statuses, fmt.Errorf("%s failed: %s", s.Component.DisplayName(), s.Message)
+				}
+				done = done && s.Done
+			}
+			if done {
+				return statuses, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return statuses, ErrTimeout
+		case <-ticker.C:
+		}
+	}
+}
+
+// Watch is a Wait that runs in the background.
+type Watch struct {
+	cancel   context.CancelFunc
+	done     chan struct{}
+	statuses []Status
+	err      error
+}
+
+// Start runs Wait in the background, e.g. while Helm installs the release.
+func (w *Watcher) Start(ctx context.Context, components []Component) *Watch {
+	ctx, cancel := context.WithCancel(ctx)
+	watch := &Watch{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(watch.done)
+		watch.statuses, watch.err = w.Wait(ctx, components)
+	}()
+	return watch
+}
+
+// Wait returns the result of Wait once it returned.
+func (w *Watch) Wait() ([]Status, error) {
+	<-w.done
+	w.cancel()
+	return w.statuses, w.err
+}
+
+// Stop stops the Wait and returns the last statuses.
+func (w *Watch) Stop() []Status {
+	w.cancel()
+	<-w.done
+	return w.statuses
+}
+
+// Check returns the current status of each component and reports the
+// statuses whose message changed since the last check.
+func (w *Watcher) Check(ctx context.Context, components []Component) ([]Status, error) {
+	if w.seen == nil {
+		w.seen = make(map[string]bool)
+		w.messages = make(map[string]string)
+	}
+
+	statuses := make([]Status, 0, len(components))
+	for _, c := range components {
+		s, err := w.status(ctx, c)
+		if err != nil {
+			return nil, fmt.Errorf("error checking %s: %s", c.DisplayName(), err)
+		}
+		key := c.Kind + "/" + c.Workload
+		if w.messages[key] != s.Message {
+			w.messages[key] = s.Message
+			if w.Progress != nil {
+				w.Progress(s)
+			}
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// status returns the current status of the component.
+func (w *Watcher) status(ctx context.Context, c Component) (Status, error) {
+	s := Status{Component: c}
+	var err error
+	switch c.Kind {
+	case kindStatefulSet:
+		var sts *appsv1.StatefulSet
+		sts, err = w.Kubernetes.AppsV1().StatefulSets(w.Namespace).Get(ctx, c.Workload, metav1.GetOptions{})
+		if err == nil {
+			statefulSetStatus(sts, &s)
+		}
+	case kindDaemonSet:
+		var ds *appsv1.DaemonSet
+		ds, err = w.Kubernetes.AppsV1().DaemonSets(w.Namespace).Get(ctx, c.Workload, metav1.GetOptions{})
+		if err == nil {
+			daemonSetStatus(ds, &s)
+		}
+	case kindDeployment:
+		var deployment *appsv1.Deployment
+		deployment, err = w.Kubernetes.AppsV1().Deployments(w.Namespace).Get(ctx, c.Workload, metav1.GetOptions{})
+		if err == nil {
+			deploymentStatus(deployment, &s)
+			if s.Done && c.Webhook != "" {
+				err = w.webhookStatus(ctx, c.Webhook, &s)
+			}
+		}
+	case kindJob:
+		var job *batchv1.Job
+		job, err = w.Kubernetes.BatchV1().Jobs(w.Namespace).Get(ctx, c.Workload, metav1.GetOptions{})
+		if err == nil {
+			w.seen[c.Kind+"/"+c.Workload] = true
+			jobStatus(job, &s)
+		}
+	default:
+		return s, fmt.Errorf("unsupported kind %s", c.Kind)
+	}
+
+	if k8serrors.IsNotFound(err) {
+		// The chart deletes jobs once they succeeded, and Helm deletes hooks.
+		if c.Kind == kindJob && (c.Hook || w.AfterApply || w.seen[c.Kind+"/"+c.Workload]) {
+			s.Message = "done"
+			s.Done = true
+		} else {
+			s.Message = "waiting to be created"
+		}
+		return s, nil
+	}
+	return s, err
+}
+
+func statefulSetStatus(sts *appsv1.StatefulSet, s *Status) {
+	desired := replicas(sts.Spec.Replicas)
+	var partition int32
+	if sts.Spec.UpdateStrategy.RollingUpdate != nil && sts.Spec.UpdateStrategy.RollingUpdate.Partition != nil {
+		partition = *sts.Spec.UpdateStrategy.RollingUpdate.Partition
+	}
+	ready := sts.Status.ReadyReplicas
+	updated := sts.Status.UpdatedReplicas
+
+	// Servers are only ready once the cluster has a leader, so the ready
+	// servers are the ones that joined the Raft cluster.
+	if s.Component.Name == "server" {
+		s.Message = fmt.Sprintf("%d/%d servers joined the Raft cluster", ready, desired)
+	} else {
+		s.Message = fmt.Sprintf("%d/%d pods ready", ready, desired)
+	}
+	if updated < desired-partition {
+		s.Message += fmt.Sprintf(", %d/%d updated", updated, desired-partition)
+	}
+	s.Done = sts.Status.ObservedGeneration >= sts.Generation && ready == desired && updated >= desired-partition
+}
+
+func daemonSetStatus(ds *appsv1.DaemonSet, s *Status) {
+	desired := ds.Status.DesiredNumberScheduled
+	ready := ds.Status.NumberAvailable
+	updated := ds.Status.UpdatedNumberScheduled
+
+	if s.Component.Name == "client" {
+		s.Message = fmt.Sprintf("%d/%d clients ready", ready, desired)
+	} else {
+		s.Message = fmt.Sprintf("%d/%d pods ready", ready, desired)
+	}
+	if updated < desired {
+		s.Message += fmt.Sprintf(", %d/%d updated", updated, desired)
+	}
+	s.Done = ds.Status.ObservedGeneration >= ds.Generation && ready == desired && updated == desired
+}
+
+func deploymentStatus(deployment *appsv1.Deployment, s *Status) {
+	desired := replicas(deployment.Spec.Replicas)
+	ready := deployment.Status.AvailableReplicas
+	updated := deployment.Status.UpdatedReplicas
+
+	s.Message = fmt.Sprintf("%d/%d replicas ready", ready, desired)
+	if updated < desired {
+		s.Message += fmt.Sprintf(", %d/%d updated", updated, desired)
+	}
+	// The replicas of the previous version are only gone once the status
+	// counts no more replicas than desired.
+	s.Done = deployment.Status.ObservedGeneration >= deployment.Generation && ready == desired && updated == desired &&
+		deployment.Status.Replicas == desired
+}
+
+func jobStatus(job *batchv1.Job, s *Status) {
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			s.Message = "done"
+			s.Done = true
+			return
+		case batchv1.JobFailed:
+			s.Message = strings.TrimSuffix(fmt.Sprintf("%s: %s", cond.Reason, cond.Message), ": ")
+			s.Failed = true
+			return
+		}
+	}
+	s.Message = "running"
+	if job.Status.Failed > 0 {
+		s.Message += fmt.Sprintf(", %d failed attempts", job.Status.Failed)
+	}
+}
+
+// webhookStatus sets the component done only if the CA bundle of each of its
+// webhooks is set, since the API server can't call them until it is.
+func (w *Watcher) webhookStatus(ctx context.Context, name string, s *Status) error {
+	config, err := w.Kubernetes.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	for _, webhook := range config.Webhooks {
+		if len(webhook.ClientConfig.CABundle) == 0 {
+			s.Message += ", waiting for the webhook certificate"
+			s.Done = false
+			return nil
+		}
+	}
+	s.Message += ", webhook ready"
+	return nil
+}
+
+// replicas returns the number of replicas of a spec, which defaults to one.
+func replicas(r *int32) int32 {
+	if r == nil {
+		return 1
+	}
+	return *r
+}
@@ -0,0 +1,329 @@
+package rollout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testManifests = `---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: consul-server
+  labels:
+    component: server
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: consul-connect-injector
+  labels:
+    component: connect-injector
+---
+apiVersion: admissionregistration.k8s.io/v1
+kind: MutatingWebhookConfiguration
+metadata:
+  name: consul-connect-injector
+  labels:
+    component: connect-injector
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: consul-server-acl-init
+  labels:
+    component: server-acl-init
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: consul-tls-init
+  labels:
+    component: tls-init
+  annotations:
+    "helm.sh/hook": pre-install,pre-upgrade
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: consul-server-acl-init-cleanup
+  labels:
+    component: server-acl-init-cleanup
+  annotations:
+    "helm.sh/hook": post-install
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: consul-server
+`
+
+func TestComponents(t *testing.T) {
+	server := Component{Name: "server", Kind: kindStatefulSet, Workload: "consul-server"}
+	injector := Component{Name: "connect-injector", Kind: kindDeployment, Workload: "consul-connect-injector", Webhook: "consul-connect-injector"}
+	aclInit := Component{Name: "server-acl-init", Kind: kindJob, Workload: "consul-server-acl-init"}
+	tlsInit := Component{Name: "tls-init", Kind: kindJob, Workload: "consul-tls-init", Hook: true}
+	cleanup := Component{Name: "server-acl-init-cleanup", Kind: kindJob, Workload: "consul-server-acl-init-cleanup", Hook: true}
+
+	components, err := Components(testManifests, Install)
+	require.NoError(t, err)
+	require.Equal(t, []Component{server, injector, aclInit, cleanup, tlsInit}, components)
+
+	// Hooks that don't run on the event are left out.
+	components, err = Components(testManifests, Upgrade)
+	require.NoError(t, err)
+	require.Equal(t, []Component{server, injector, aclInit, tlsInit}, components)
+}
+
+func TestComponent_DisplayName(t *testing.T) {
+	require.Equal(t, "Consul servers", Component{Name: "server", Workload: "consul-server"}.DisplayName())
+	require.Equal(t, "consul-ingress-gateway", Component{Name: "ingress-gateway", Workload: "consul-ingress-gateway"}.DisplayName())
+}
+
+func TestWatcher_Check(t *testing.T) {
+	cases := map[string]struct {
+		component  Component
+		objects    []runtime.Object
+		afterApply bool
+		expMessage string
+		expDone    bool
+		expFailed  bool
+	}{
+		"servers joining": {
+			component:  Component{Name: "server", Kind: kindStatefulSet, Workload: "consul-server"},
+			objects:    []runtime.Object{statefulSet("consul-server", 3, 2, 3, 0)},
+			expMessage: "2/3 servers joined the Raft cluster",
+		},
+		"servers ready": {
+			component:  Component{Name: "server", Kind: kindStatefulSet, Workload: "consul-server"},
+			objects:    []runtime.Object{statefulSet("consul-server", 3, 3, 3, 0)},
+			expMessage: "3/3 servers joined the Raft cluster",
+			expDone:    true,
+		},
+		"servers updating": {
+			component:  Component{Name: "server", Kind: kindStatefulSet, Workload: "consul-server"},
+			objects:    []runtime.Object{statefulSet("consul-server", 3, 3, 1, 0)},
+			expMessage: "3/3 servers joined the Raft cluster, 1/3 updated",
+		},
+		"servers updated up to the partition": {
+			component:  Component{Name: "server", Kind: kindStatefulSet, Workload: "consul-server"},
+			objects:    []runtime.Object{statefulSet("consul-server", 3, 3, 1, 2)},
+			expMessage: "3/3 servers joined the Raft cluster",
+			expDone:    true,
+		},
+		"clients": {
+			component: Component{Name: "client", Kind: kindDaemonSet, Workload: "consul-client"},
+			objects: []runtime.Object{&appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "consul-client", Namespace: "consul"},
+				Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: 3, NumberAvailable: 1, UpdatedNumberScheduled: 3},
+			}},
+			expMessage: "1/3 clients ready",
+		},
+		"deployment with replicas of the previous version": {
+			component:  Component{Name: "controller", Kind: kindDeployment, Workload: "consul-controller"},
+			objects:    []runtime.Object{deployment("consul-controller", 1, 1, 2)},
+			expMessage: "1/1 replicas ready",
+		},
+		"webhook without a certificate": {
+			component: Component{Name: "connect-injector", Kind: kindDeployment, Workload: "consul-connect-injector", Webhook: "consul-connect-injector"},
+			objects: []runtime.Object{
+				deployment("consul-connect-injector", 2, 2, 2),
+				webhookConfig("consul-connect-injector", nil),
+			},
+			expMessage: "2/2 replicas ready, waiting for the webhook certificate",
+		},
+		"webhook ready": {
+			component: Component{Name: "connect-injector", Kind: kindDeployment, Workload: "consul-connect-injector", Webhook: "consul-connect-injector"},
+			objects: []runtime.Object{
+				deployment("consul-connect-injector", 2, 2, 2),
+				webhookConfig("consul-connect-injector", []byte("ca")),
+			},
+			expMessage: "2/2 replicas ready, webhook ready",
+			expDone:    true,
+		},
+		"job running": {
+			component:  Component{Name: "server-acl-init", Kind: kindJob, Workload: "consul-server-acl-init"},
+			objects:    []runtime.Object{job("consul-server-acl-init", 2, nil)},
+			expMessage: "running, 2 failed attempts",
+		},
+		"job complete": {
+			component: Component{Name: "server-acl-init", Kind: kindJob, Workload: "consul-server-acl-init"},
+			objects: []runtime.Object{job("consul-server-acl-init", 0, &batchv1.JobCondition{
+				Type: batchv1.JobComplete, Status: corev1.ConditionTrue,
+			})},
+			expMessage: "done",
+			expDone:    true,
+		},
+		"job failed": {
+			component: Component{Name: "server-acl-init", Kind: kindJob, Workload: "consul-server-acl-init"},
+			objects: []runtime.Object{job("consul-server-acl-init", 6, &batchv1.JobCondition{
+				Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded", Message: "Job has reached the specified backoff limit",
+			})},
+			expMessage: "BackoffLimitExceeded: Job has reached the specified backoff limit",
+			expFailed:  true,
+		},
+		"job not created yet": {
+			component:  Component{Name: "server-acl-init", Kind: kindJob, Workload: "consul-server-acl-init"},
+			expMessage: "waiting to be created",
+		},
+		"job deleted after apply": {
+			component:  Component{Name: "server-acl-init", Kind: kindJob, Workload: "consul-server-acl-init"},
+			afterApply: true,
+			expMessage: "done",
+			expDone:    true,
+		},
+		"hook deleted": {
+			component:  Component{Name: "tls-init", Kind: kindJob, Workload: "consul-tls-init", Hook: true},
+			expMessage: "done",
+			expDone:    true,
+		},
+		"deployment not created yet": {
+			component:  Component{Name: "controller", Kind: kindDeployment, Workload: "consul-controller"},
+			afterApply: true,
+			expMessage: "waiting to be created",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var reported []Status
+			w := &Watcher{
+				Kubernetes: fake.NewSimpleClientset(c.objects...),
+				Namespace:  "consul",
+				AfterApply: c.afterApply,
+				Progress:   func(s Status) { reported = append(reported, s) },
+			}
+			statuses, err := w.Check(context.Background(), []Component{c.component})
+			require.NoError(t, err)
+			require.Equal(t, []Status{{Component: c.component, Message: c.expMessage, Done: c.expDone, Failed: c.expFailed}}, statuses)
+			require.Equal(t, statuses, reported)
+
+			// Statuses are only reported when they change.
+			_, err = w.Check(context.Background(), []Component{c.component})
+			require.NoError(t, err)
+			require.Len(t, reported, 1)
+		})
+	}
+}
+
+func TestWatcher_CheckDeletedJob(t *testing.T) {
+	component := Component{Name: "server-acl-init", Kind: kindJob, Workload: "consul-server-acl-init"}
+	client := fake.NewSimpleClientset(job("consul-server-acl-init", 0, nil))
+	w := &Watcher{Kubernetes: client, Namespace: "consul"}
+
+	statuses, err := w.Check(context.Background(), []Component{component})
+	require.NoError(t, err)
+	require.False(t, statuses[0].Done)
+
+	// The chart deletes the job once it succeeded.
+	require.NoError(t, client.BatchV1().Jobs("consul").Delete(context.Background(), "consul-server-acl-init", metav1.DeleteOptions{}))
+	statuses, err = w.Check(context.Background(), []Component{component})
+	require.NoError(t, err)
+	require.True(t, statuses[0].Done)
+}
+
+func TestWatcher_Wait(t *testing.T) {
+	server := Component{Name: "server", Kind: kindStatefulSet, Workload: "consul-server"}
+	aclInit := Component{Name: "server-acl-init", Kind: kindJob, Workload: "consul-server-acl-init"}
+
+	t.Run("ready", func(t *testing.T) {
+		client := fake.NewSimpleClientset(statefulSet("consul-server", 3, 1, 3, 0))
+		w := &Watcher{Kubernetes: client, Namespace: "consul", Interval: 10 * time.Millisecond}
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			_, _ = client.AppsV1().StatefulSets("consul").UpdateStatus(context.Background(), statefulSet("consul-server", 3, 3, 3, 0), metav1.UpdateOptions{})
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		statuses, err := w.Wait(ctx, []Component{server})
+		require.NoError(t, err)
+		require.True(t, statuses[0].Done)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		client := fake.NewSimpleClientset(statefulSet("consul-server", 3, 1, 3, 0))
+		w := &Watcher{Kubernetes: client, Namespace: "consul", Interval: 10 * time.Millisecond}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		statuses, err := w.Wait(ctx, []Component{server})
+		require.Equal(t, ErrTimeout, err)
+		require.Equal(t, "1/3 servers joined the Raft cluster", statuses[0].Message)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		client := fake.NewSimpleClientset(
+			statefulSet("consul-server", 3, 1, 3, 0),
+			job("consul-server-acl-init", 1, &batchv1.JobCondition{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "DeadlineExceeded"}),
+		)
+		w := &Watcher{Kubernetes: client, Namespace: "consul", Interval: 10 * time.Millisecond}
+		_, err := w.Wait(context.Background(), []Component{server, aclInit})
+		require.EqualError(t, err, "ACL bootstrap failed: DeadlineExceeded")
+	})
+
+	t.Run("in the background", func(t *testing.T) {
+		client := fake.NewSimpleClientset(statefulSet("consul-server", 3, 1, 3, 0))
+		w := &Watcher{Kubernetes: client, Namespace: "consul", Interval: 10 * time.Millisecond}
+		watch := w.Start(context.Background(), []Component{server})
+		time.Sleep(50 * time.Millisecond)
+		statuses := watch.Stop()
+		require.Len(t, statuses, 1)
+		require.False(t, statuses[0].Done)
+	})
+}
+
+func statefulSet(name string, replicas, ready, updated, partition int32) *appsv1.StatefulSet {
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "consul"},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"component": "server"}},
+		},
+		Status: appsv1.StatefulSetStatus{ReadyReplicas: ready, UpdatedReplicas: updated},
+	}
+	if partition > 0 {
+		sts.Spec.UpdateStrategy.RollingUpdate = &appsv1.RollingUpdateStatefulSetStrategy{Partition: &partition}
+	}
+	return sts
+}
+
+func deployment(name string, replicas, available, total int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "consul"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{Replicas: total, AvailableReplicas: available, UpdatedReplicas: replicas},
+	}
+}
+
+func webhookConfig(name string, caBundle []byte) *admissionv1.MutatingWebhookConfiguration {
+	return &admissionv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Webhooks: []admissionv1.MutatingWebhook{{
+			Name:         name + ".consul.hashicorp.com",
+			ClientConfig: admissionv1.WebhookClientConfig{CABundle: caBundle},
+		}},
+	}
+}
+
+func job(name string, failed int32, cond *batchv1.JobCondition) *batchv1.Job {
+	j := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "consul"},
+		Spec: batchv1.JobSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"job-name": name}},
+		},
+		Status: batchv1.JobStatus{Failed: failed},
+	}
+	if cond != nil {
+		j.Status.Conditions = []batchv1.JobCondition{*cond}
+	}
+	return j
+}
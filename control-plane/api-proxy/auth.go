@@ -0,0 +1,113 @@
+package apiproxy
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// authenticationConfigMapNamespace and authenticationConfigMapName identify
+	// the config map in which the Kubernetes API server publishes how it
+	// authenticates requests to aggregated API servers.
+	authenticationConfigMapNamespace = "kube-system"
+	authenticationConfigMapName      = "extension-apiserver-authentication"
+
+	// defaultUsernameHeader is used if the API server does not configure
+	// the headers it sets the username in.
+	defaultUsernameHeader = "X-Remote-User"
+)
+
+// RequestHeaderAuthenticator verifies that requests are proxied by the
+// Kubernetes API server, which authenticates and authorizes the user before
+// proxying a request to an aggregated API server. Requests must present a
+// client certificate signed by the API server's request header CA.
+type RequestHeaderAuthenticator struct {
+	// ClientCAs are the CAs that sign the client certificates of the API server.
+	ClientCAs *x509.CertPool
+	// AllowedNames are the common names the client certificate can have. Any
+	// name is allowed if it is empty.
+	AllowedNames []string
+	// UsernameHeaders are the headers the API server sets the name of the
+	// authenticated user in.
+	UsernameHeaders []string
+}
+
+// LoadRequestHeaderAuthenticator creates a RequestHeaderAuthenticator from the
+// configuration published by the Kubernetes API server.
+func LoadRequestHeaderAuthenticator(ctx context.Context, client kubernetes.Interface) (*RequestHeaderAuthenticator, error) {
+	cm, err := client.CoreV1().ConfigMaps(authenticationConfigMapNamespace).Get(ctx, authenticationConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error reading config map %s/%s: %s", authenticationConfigMapNamespace, authenticationConfigMapName, err)
+	}
+
+	caPEM, ok := cm.Data["requestheader-client-ca-file"]
+	if !ok {
+		return nil, errors.New("the API server does not configure a request header client CA: the aggregation layer is not enabled")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(caPEM)) {
+		return nil, errors.New("request header client CA contains no valid certificates")
+	}
+
+	auth := &RequestHeaderAuthenticator{ClientCAs: pool}
+	if err := unmarshalList(cm.Data, "requestheader-allowed-names", &auth.AllowedNames); err != nil {
+		return nil, err
+	}
+	if err := unmarshalList(cm.Data, "requestheader-username-headers", &auth.UsernameHeaders); err != nil {
+		return nil, err
+	}
+	if len(auth.UsernameHeaders) == 0 {
+		auth.UsernameHeaders = []string{defaultUsernameHeader}
+	}
+	return auth, nil
+}
+
+// Authenticate returns the name of the user a request is proxied for, or an
+// error if the request is not proxied by the API server. The server must
+// verify client certificates against ClientCAs so that the verified chains of
+// the request are set.
+func (a *RequestHeaderAuthenticator) Authenticate(r *http.Request) (string, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", errors.New("no verified client certificate")
+	}
+	if len(a.AllowedNames) > 0 {
+		name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		allowed := false
+		for _, n := range a.AllowedNames {
+			if n == name {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return "", fmt.Errorf("client certificate name %q is not allowed", name)
+		}
+	}
+
+	for _, header := range a.UsernameHeaders {
+		if user := r.Header.Get(header); user != "" {
+			return user, nil
+		}
+	}
+	return "", errors.New("no user set in request headers")
+}
+
+// unmarshalList decodes the JSON list stored in the key of the config map data
+// if it is set.
+func unmarshalList(data map[string]string, key string, list *[]string) error {
+	raw, ok := data[key]
+	if !ok || raw == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(raw), list); err != nil {
+		return fmt.Errorf("invalid %s: %s", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package apiproxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/control-plane/helper/cert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLoadRequestHeaderAuthenticator(t *testing.T) {
+	_, _, caPEM, _, err := cert.GenerateCA("front-proxy-ca")
+	require.NoError(t, err)
+
+	cases := map[string]struct {
+		data            map[string]string
+		expAllowedNames []string
+		expHeaders      []string
+		expErr          string
+	}{
+		"all keys set": {
+			data: map[string]string{
+				"requestheader-client-ca-file":   caPEM,
+				"requestheader-allowed-names":    `["front-proxy-client"]`,
+				"requestheader-username-headers": `["X-Remote-User"]`,
+			},
+			expAllowedNames: []string{"front-proxy-client"},
+			expHeaders:      []string{"X-Remote-User"},
+		},
+		"defaults": {
+			data: map[string]string{
+				"requestheader-client-ca-file": caPEM,
+			},
+			expHeaders: []string{"X-Remote-User"},
+		},
+		"no client CA": {
+			data:   map[string]string{},
+			expErr: "the API server does not configure a request header client CA: the aggregation layer is not enabled",
+		},
+		"invalid client CA": {
+			data:   map[string]string{"requestheader-client-ca-file": "invalid"},
+			expErr: "request header client CA contains no valid certificates",
+		},
+		"invalid allowed names": {
+			data: map[string]string{
+				"requestheader-client-ca-file": caPEM,
+				"requestheader-allowed-names":  "front-proxy-client",
+			},
+			expErr: "invalid requestheader-allowed-names: invalid character 'r' in literal false (expecting 'a')",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: authenticationConfigMapName, Namespace: authenticationConfigMapNamespace},
+				Data:       c.data,
+			})
+			auth, err := LoadRequestHeaderAuthenticator(context.Background(), client)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expAllowedNames, auth.AllowedNames)
+			require.Equal(t, c.expHeaders, auth.UsernameHeaders)
+		})
+	}
+}
+
+func TestRequestHeaderAuthenticator_Authenticate(t *testing.T) {
+	auth := &RequestHeaderAuthenticator{
+		AllowedNames:    []string{"front-proxy-client"},
+		UsernameHeaders: []string{"X-Remote-User"},
+	}
+
+	cases := map[string]struct {
+		commonName string
+		noTLS      bool
+		user       string
+		expUser    string
+		expErr     string
+	}{
+		"proxied request": {
+			commonName: "front-proxy-client",
+			user:       "alice",
+			expUser:    "alice",
+		},
+		"no client certificate": {
+			noTLS:  true,
+			user:   "alice",
+			expErr: "no verified client certificate",
+		},
+		"name not allowed": {
+			commonName: "other",
+			user:       "alice",
+			expErr:     `client certificate name "other" is not allowed`,
+		},
+		"no user": {
+			commonName: "front-proxy-client",
+			expErr:     "no user set in request headers",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", versionPath, nil)
+			r.TLS = nil
+			if !c.noTLS {
+				r.TLS = &tls.ConnectionState{
+					VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: c.commonName}}}},
+				}
+			}
+			if c.user != "" {
+				r.Header.Set("X-Remote-User", c.user)
+			}
+
+			user, err := auth.Authenticate(r)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expUser, user)
+		})
+	}
+}
@@ -0,0 +1,300 @@
+// Package apiproxy exposes a read-only subset of the Consul API as an
+// aggregated API of the Kubernetes API server. The API server authenticates,
+// authorizes with RBAC and audits each request before proxying it, so clients
+// can read from Consul without network access to the Consul servers or a
+// Consul ACL token. Besides the resources, reads of some areas of the Consul
+// HTTP API are passed through as is, for clients such as the consul-k8s CLI.
+package apiproxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	capi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// Group and Version are the API group and version the Consul API is
+	// served as.
+	Group   = "api.consul.hashicorp.com"
+	Version = "v1alpha1"
+
+	groupPath   = "/apis/" + Group
+	versionPath = groupPath + "/" + Version
+)
+
+// resource is a Consul API endpoint exposed as a Kubernetes resource. Access
+// to it is governed by RBAC rules for the resource in the API group.
+type resource struct {
+	name string
+	kind string
+	// list is called for the collection of the resource. It is required.
+	list func(client *capi.Client, q *capi.QueryOptions, r *http.Request) (interface{}, error)
+	// get is called for a single resource. The resource can't be read by
+	// name if it is nil.
+	get func(client *capi.Client, name string, q *capi.QueryOptions, r *http.Request) (interface{}, error)
+}
+
+// resources are the Consul API endpoints that are exposed.
+var resources = []resource{
+	{
+		name: "services",
+		kind: "Service",
+		list: func(client *capi.Client, q *capi.QueryOptions, _ *http.Request) (interface{}, error) {
+			services, _, err := client.Catalog().Services(q)
+			return services, err
+		},
+		get: func(client *capi.Client, name string, q *capi.QueryOptions, r *http.Request) (interface{}, error) {
+			passing, _ := strconv.ParseBool(r.URL.Query().Get("passing"))
+			entries, _, err := client.Health().Service(name, "", passing, q)
+			return entries, err
+		},
+	},
+	{
+		name: "nodes",
+		kind: "Node",
+		list: func(client *capi.Client, q *capi.QueryOptions, _ *http.Request) (interface{}, error) {
+			nodes, _, err := client.Catalog().Nodes(q)
+			return nodes, err
+		},
+		get: func(client *capi.Client, name string, q *capi.QueryOptions, _ *http.Request) (interface{}, error) {
+			node, _, err := client.Catalog().Node(name, q)
+			if err == nil && node == nil {
+				return nil, nil
+			}
+			return node, err
+		},
+	},
+	{
+		name: "intentions",
+		kind: "Intention",
+		list: func(client *capi.Client, q *capi.QueryOptions, _ *http.Request) (interface{}, error) {
+			intentions, _, err := client.Connect().Intentions(q)
+			return intentions, err
+		},
+	},
+}
+
+// consulPath is the resource the Consul HTTP API is passed through under, as
+// <consulPath>/v1/<area>/..., for clients written against the Consul HTTP API.
+// The API server authorizes these requests as the get verb on the subresource
+// consul/<area>, so access can be granted to each area separately.
+const consulPath = versionPath + "/consul/v1/"
+
+// consulAreas are the areas of the Consul HTTP API that are passed through.
+// Only their reads are, so that nothing can be changed through the proxy.
+var consulAreas = map[string]bool{
+	"catalog":         true,
+	"health":          true,
+	"config":          true,
+	"connect":         true,
+	"discovery-chain": true,
+	"peering":         true,
+	"peerings":        true,
+}
+
+// Handler serves the Consul API resources and their discovery documents, and
+// passes reads of the Consul HTTP API through.
+type Handler struct {
+	ConsulClient *capi.Client
+	// ConsulConfig is the configuration ConsulClient was created with, which
+	// the requests passed through to Consul are sent with.
+	ConsulConfig *capi.Config
+	// Authenticator verifies that requests come from the Kubernetes API
+	// server. Requests are not authenticated if it is nil.
+	Authenticator *RequestHeaderAuthenticator
+	Log           hclog.Logger
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	if path == "/healthz" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user := ""
+	if h.Authenticator != nil {
+		var err error
+		user, err = h.Authenticator.Authenticate(r)
+		if err != nil {
+			h.Log.Warn("rejecting unauthenticated request", "path", r.URL.Path, "remote-addr", r.RemoteAddr, "err", err)
+			writeStatus(w, http.StatusUnauthorized, metav1.StatusReasonUnauthorized, "request must be proxied by the Kubernetes API server")
+			return
+		}
+	}
+	h.Log.Debug("request", "user", user, "method", r.Method, "path", r.URL.Path)
+
+	if r.Method != http.MethodGet {
+		writeStatus(w, http.StatusMethodNotAllowed, metav1.StatusReasonMethodNotAllowed, "only get and list are supported")
+		return
+	}
+
+	if strings.HasPrefix(path, consulPath) {
+		h.passThrough(w, r, strings.TrimPrefix(path, consulPath))
+		return
+	}
+
+	switch path {
+	case groupPath:
+		writeJSON(w, http.StatusOK, apiGroup())
+		return
+	case versionPath:
+		writeJSON(w, http.StatusOK, apiResourceList())
+		return
+	}
+
+	if !strings.HasPrefix(path, versionPath+"/") {
+		writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, "not found")
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(path, versionPath+"/"), "/")
+	if len(parts) > 2 {
+		writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, "not found")
+		return
+	}
+
+	for _, res := range resources {
+		if res.name != parts[0] {
+			continue
+		}
+		q := queryOptions(r)
+		var result interface{}
+		var err error
+		if len(parts) == 1 {
+			result, err = res.list(h.ConsulClient, q, r)
+		} else if res.get != nil {
+			result, err = res.get(h.ConsulClient, parts[1], q, r)
+			if err == nil && result == nil {
+				writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, res.name+" \""+parts[1]+"\" not found")
+				return
+			}
+		} else {
+			writeStatus(w, http.StatusMethodNotAllowed, metav1.StatusReasonMethodNotAllowed, res.name+" can only be listed")
+			return
+		}
+		if err != nil {
+			h.Log.Error("error querying Consul", "resource", res.name, "err", err)
+			writeStatus(w, http.StatusBadGateway, metav1.StatusReasonServiceUnavailable, "error querying Consul: "+err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, "resource "+parts[0]+" not found")
+}
+
+// passThrough sends the request to the endpoint of the Consul HTTP API at
+// /v1/<path>, with the query of the request, and copies the response.
+func (h *Handler) passThrough(w http.ResponseWriter, r *http.Request, path string) {
+	segments := strings.Split(path, "/")
+	for _, segment := range segments {
+		// The path isn't cleaned, so it must not escape the area.
+		if segment == ".." || segment == "." {
+			writeStatus(w, http.StatusBadRequest, metav1.StatusReasonBadRequest, "invalid path")
+			return
+		}
+	}
+	area := segments[0]
+	if !consulAreas[area] {
+		writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, "the Consul API "+area+" is not served")
+		return
+	}
+
+	consulURL := url.URL{
+		Scheme:   h.ConsulConfig.Scheme,
+		Host:     h.ConsulConfig.Address,
+		Path:     h.ConsulConfig.PathPrefix + "/v1/" + path,
+		RawQuery: r.URL.RawQuery,
+	}
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, consulURL.String(), nil)
+	if err != nil {
+		writeStatus(w, http.StatusBadRequest, metav1.StatusReasonBadRequest, err.Error())
+		return
+	}
+	if h.ConsulConfig.Token != "" {
+		req.Header.Set("X-Consul-Token", h.ConsulConfig.Token)
+	}
+	resp, err := h.ConsulConfig.HttpClient.Do(req)
+	if err != nil {
+		h.Log.Error("error querying Consul", "path", consulURL.Path, "err", err)
+		writeStatus(w, http.StatusBadGateway, metav1.StatusReasonServiceUnavailable, "error querying Consul: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, header := range []string{"Content-Type", "X-Consul-Index", "X-Consul-Knownleader", "X-Consul-Lastcontact"} {
+		if v := resp.Header.Get(header); v != "" {
+			w.Header().Set(header, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+// queryOptions returns the Consul query options set by the query parameters
+// of the request.
+func queryOptions(r *http.Request) *capi.QueryOptions {
+	params := r.URL.Query()
+	return &capi.QueryOptions{
+		Datacenter: params.Get("dc"),
+		Namespace:  params.Get("ns"),
+		Partition:  params.Get("partition"),
+		Filter:     params.Get("filter"),
+	}
+}
+
+// apiGroup is the discovery document of the API group.
+func apiGroup() *metav1.APIGroup {
+	version := metav1.GroupVersionForDiscovery{GroupVersion: Group + "/" + Version, Version: Version}
+	return &metav1.APIGroup{
+		TypeMeta:         metav1.TypeMeta{Kind: "APIGroup", APIVersion: "v1"},
+		Name:             Group,
+		Versions:         []metav1.GroupVersionForDiscovery{version},
+		PreferredVersion: version,
+	}
+}
+
+// apiResourceList is the discovery document of the API version.
+func apiResourceList() *metav1.APIResourceList {
+	list := &metav1.APIResourceList{
+		TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+		GroupVersion: Group + "/" + Version,
+	}
+	for _, res := range resources {
+		verbs := metav1.Verbs{"list"}
+		if res.get != nil {
+			verbs = metav1.Verbs{"get", "list"}
+		}
+		list.APIResources = append(list.APIResources, metav1.APIResource{
+			Name:       res.name,
+			Namespaced: false,
+			Kind:       res.kind,
+			Verbs:      verbs,
+		})
+	}
+	return list
+}
+
+func writeStatus(w http.ResponseWriter, code int, reason metav1.StatusReason, message string) {
+	writeJSON(w, code, &metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   metav1.StatusFailure,
+		Message:  message,
+		Reason:   reason,
+		Code:     int32(code),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,180 @@
+package apiproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	capi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	// consul is a fake Consul server that records the requests it receives.
+	var consulRequests []string
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		consulRequests = append(consulRequests, r.URL.String())
+		switch r.URL.Path {
+		case "/v1/catalog/services":
+			fmt.Fprint(w, `{"web":["v1"]}`)
+		case "/v1/health/service/web":
+			fmt.Fprint(w, `[{"Service":{"ID":"web-1","Service":"web"}}]`)
+		case "/v1/catalog/node/missing":
+			fmt.Fprint(w, `null`)
+		case "/v1/connect/intentions/check":
+			fmt.Fprint(w, `{"Allowed":true}`)
+		case "/v1/config/service-defaults/missing":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `"Config entry not found"`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, "unexpected request")
+		}
+	}))
+	defer consul.Close()
+
+	config := &capi.Config{Address: consul.URL}
+	client, err := capi.NewClient(config)
+	require.NoError(t, err)
+	handler := &Handler{ConsulClient: client, ConsulConfig: config, Log: hclog.NewNullLogger()}
+
+	cases := map[string]struct {
+		method           string
+		path             string
+		expCode          int
+		expBody          string
+		expConsulRequest string
+	}{
+		"API group": {
+			path:    groupPath,
+			expCode: http.StatusOK,
+			expBody: `{"kind":"APIGroup","apiVersion":"v1","name":"api.consul.hashicorp.com",` +
+				`"versions":[{"groupVersion":"api.consul.hashicorp.com/v1alpha1","version":"v1alpha1"}],` +
+				`"preferredVersion":{"groupVersion":"api.consul.hashicorp.com/v1alpha1","version":"v1alpha1"}}`,
+		},
+		"API resources": {
+			path:    versionPath,
+			expCode: http.StatusOK,
+			expBody: `{"kind":"APIResourceList","apiVersion":"v1","groupVersion":"api.consul.hashicorp.com/v1alpha1","resources":[` +
+				`{"name":"services","singularName":"","namespaced":false,"kind":"Service","verbs":["get","list"]},` +
+				`{"name":"nodes","singularName":"","namespaced":false,"kind":"Node","verbs":["get","list"]},` +
+				`{"name":"intentions","singularName":"","namespaced":false,"kind":"Intention","verbs":["list"]}]}`,
+		},
+		"list services": {
+			path:             versionPath + "/services?dc=dc2&ns=team",
+			expCode:          http.StatusOK,
+			expBody:          `{"web":["v1"]}`,
+			expConsulRequest: "/v1/catalog/services?dc=dc2&ns=team",
+		},
+		"get service": {
+			path:             versionPath + "/services/web?passing=true",
+			expCode:          http.StatusOK,
+			expBody:          `[{"Node":null,"Service":{"ID":"web-1","Service":"web","Tags":null,"Meta":null,"Port":0,"Address":"","Weights":{"Passing":0,"Warning":0},"EnableTagOverride":false},"Checks":null}]`,
+			expConsulRequest: "/v1/health/service/web?passing=1",
+		},
+		"get missing node": {
+			path:             versionPath + "/nodes/missing",
+			expCode:          http.StatusNotFound,
+			expBody:          `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure","message":"nodes \"missing\" not found","reason":"NotFound","code":404}`,
+			expConsulRequest: "/v1/catalog/node/missing",
+		},
+		"get intention": {
+			path:    versionPath + "/intentions/web",
+			expCode: http.StatusMethodNotAllowed,
+			expBody: `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure","message":"intentions can only be listed","reason":"MethodNotAllowed","code":405}`,
+		},
+		"Consul error": {
+			path:             versionPath + "/nodes",
+			expCode:          http.StatusBadGateway,
+			expBody:          `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure","message":"error querying Consul: Unexpected response code: 500 (unexpected request)","reason":"ServiceUnavailable","code":502}`,
+			expConsulRequest: "/v1/catalog/nodes",
+		},
+		"unknown resource": {
+			path:    versionPath + "/kv",
+			expCode: http.StatusNotFound,
+			expBody: `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure","message":"resource kv not found","reason":"NotFound","code":404}`,
+		},
+		"pass through": {
+			path:             consulPath + "catalog/services?peer=dc2",
+			expCode:          http.StatusOK,
+			expBody:          `{"web":["v1"]}`,
+			expConsulRequest: "/v1/catalog/services?peer=dc2",
+		},
+		"pass through with query": {
+			path:             consulPath + "connect/intentions/check?source=web&destination=db",
+			expCode:          http.StatusOK,
+			expBody:          `{"Allowed":true}`,
+			expConsulRequest: "/v1/connect/intentions/check?source=web&destination=db",
+		},
+		"pass through Consul error": {
+			path:             consulPath + "config/service-defaults/missing",
+			expCode:          http.StatusNotFound,
+			expBody:          `"Config entry not found"`,
+			expConsulRequest: "/v1/config/service-defaults/missing",
+		},
+		"pass through area not served": {
+			path:    consulPath + "acl/tokens",
+			expCode: http.StatusNotFound,
+			expBody: `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure","message":"the Consul API acl is not served","reason":"NotFound","code":404}`,
+		},
+		"pass through escaping the area": {
+			path:    consulPath + "catalog/../acl/tokens",
+			expCode: http.StatusBadRequest,
+			expBody: `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure","message":"invalid path","reason":"BadRequest","code":400}`,
+		},
+		"pass through write": {
+			method:  http.MethodPut,
+			path:    consulPath + "config",
+			expCode: http.StatusMethodNotAllowed,
+			expBody: `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure","message":"only get and list are supported","reason":"MethodNotAllowed","code":405}`,
+		},
+		"write": {
+			method:  http.MethodDelete,
+			path:    versionPath + "/services/web",
+			expCode: http.StatusMethodNotAllowed,
+			expBody: `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure","message":"only get and list are supported","reason":"MethodNotAllowed","code":405}`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			consulRequests = nil
+			method := c.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(method, c.path, nil))
+
+			require.Equal(t, c.expCode, rec.Code)
+			require.JSONEq(t, c.expBody, rec.Body.String())
+			if c.expConsulRequest == "" {
+				require.Empty(t, consulRequests)
+			} else {
+				require.Equal(t, []string{c.expConsulRequest}, consulRequests)
+			}
+		})
+	}
+}
+
+func TestHandler_RejectsUnauthenticatedRequests(t *testing.T) {
+	handler := &Handler{
+		Authenticator: &RequestHeaderAuthenticator{UsernameHeaders: []string{"X-Remote-User"}},
+		Log:           hclog.NewNullLogger(),
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, versionPath, nil))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	var status map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	require.Equal(t, "Unauthorized", status["reason"])
+
+	// Health checks are not authenticated.
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+}
@@ -11,6 +11,7 @@ const (
 	ExportedServices   string = "exportedservices"
 	IngressGateway     string = "ingressgateway"
 	TerminatingGateway string = "terminatinggateway"
+	JWTProvider        string = "jwtprovider"
 
 	Global                 string = "global"
 	Mesh                   string = "mesh"
@@ -20,6 +21,7 @@ const (
 
 	SourceKey        string = "external-source"
 	DatacenterKey    string = "consul.hashicorp.com/source-datacenter"
+	ClusterIDKey     string = "consul.hashicorp.com/source-cluster-id"
 	MigrateEntryKey  string = "consul.hashicorp.com/migrate-entry"
 	MigrateEntryTrue string = "true"
 	SourceValue      string = "kubernetes"
@@ -1,7 +1,9 @@
 package common
 
 import (
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-version"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -96,4 +98,21 @@ type ConsulMeta struct {
 	// service in the k8s `staging` namespace will be registered into the
 	// `k8s-staging` Consul namespace.
 	Prefix string
+	// MirroringRules work in conjunction with Mirroring. They rewrite the
+	// k8s namespace name before Prefix is added, e.g. to strip a "team-" prefix.
+	MirroringRules namespaces.MirroringRules
+
+	// ConsulVersion is the version of the Consul agent the config entries are written
+	// through. Fields that need a newer version of Consul are rejected. It is nil if the
+	// version isn't known, in which case they aren't.
+	ConsulVersion *version.Version
+}
+
+// SupportsConsulVersion returns whether the Consul version is at least minVersion. It
+// returns true if the Consul version isn't known.
+func (m ConsulMeta) SupportsConsulVersion(minVersion string) bool {
+	if m.ConsulVersion == nil {
+		return true
+	}
+	return !m.ConsulVersion.LessThan(version.Must(version.NewVersion(minVersion)))
 }
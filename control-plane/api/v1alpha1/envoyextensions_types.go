@@ -0,0 +1,423 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-version"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// EnvoyExtension has configuration for an extension that patches Envoy resources.
+type EnvoyExtension struct {
+	// Name is the name of the extension. It must be one of the builtin extensions:
+	// "builtin/aws/lambda", "builtin/ext-authz", "builtin/lua", "builtin/otel-access-logging",
+	// "builtin/property-override" or "builtin/wasm".
+	Name string `json:"name,omitempty"`
+	// Required makes the configuration of the proxy fail if the extension can't be applied.
+	// Otherwise the extension is skipped.
+	Required bool `json:"required,omitempty"`
+	// Arguments are the arguments of the extension, whose keys are the fields documented
+	// for it, e.g. "ProxyType". They are checked against the schema of the extension.
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	// ConsulVersion is a version constraint, e.g. ">= 1.16.0", which the version of the
+	// Consul servers must satisfy for the extension to be applied.
+	ConsulVersion string `json:"consulVersion,omitempty"`
+	// EnvoyVersion is a version constraint which the version of Envoy must satisfy for
+	// the extension to be applied.
+	EnvoyVersion string `json:"envoyVersion,omitempty"`
+}
+
+// EnvoyExtensions is a list of extensions which patch Envoy resources.
+type EnvoyExtensions []EnvoyExtension
+
+// builtinEnvoyExtension describes an extension that Consul provides.
+type builtinEnvoyExtension struct {
+	// minConsulVersion is the first Consul version with the extension.
+	minConsulVersion string
+	// arguments returns the arguments that the extension is configured with.
+	arguments func() envoyExtensionArguments
+}
+
+// envoyExtensionArguments are the arguments of a builtin extension.
+type envoyExtensionArguments interface {
+	validate(path *field.Path) field.ErrorList
+}
+
+var builtinEnvoyExtensions = map[string]builtinEnvoyExtension{
+	"builtin/aws/lambda": {"1.15.0", func() envoyExtensionArguments { return &awsLambdaArguments{} }},
+	"builtin/lua":        {"1.15.0", func() envoyExtensionArguments { return &luaArguments{} }},
+	"builtin/ext-authz":  {"1.16.0", func() envoyExtensionArguments { return &extAuthzArguments{} }},
+	"builtin/otel-access-logging": {"1.16.0", func() envoyExtensionArguments {
+		return &otelAccessLoggingArguments{}
+	}},
+	"builtin/property-override": {"1.16.0", func() envoyExtensionArguments { return &propertyOverrideArguments{} }},
+	"builtin/wasm":              {"1.16.0", func() envoyExtensionArguments { return &wasmArguments{} }},
+}
+
+func (in EnvoyExtensions) toConsul() []capi.EnvoyExtension {
+	if in == nil {
+		return nil
+	}
+	outConfig := make([]capi.EnvoyExtension, 0, len(in))
+	for _, e := range in {
+		outConfig = append(outConfig, e.toConsul())
+	}
+	return outConfig
+}
+
+func (in EnvoyExtensions) validate(consulMeta common.ConsulMeta, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i, e := range in {
+		errs = append(errs, e.validate(consulMeta, path.Index(i))...)
+	}
+	return errs
+}
+
+func (in EnvoyExtension) toConsul() capi.EnvoyExtension {
+	// We explicitly ignore the error returned by Unmarshal
+	// because validate() ensures that if we get to here that it
+	// won't return an error.
+	var args map[string]interface{}
+	_ = json.Unmarshal(in.Arguments, &args)
+	return capi.EnvoyExtension{
+		Name:          in.Name,
+		Required:      in.Required,
+		Arguments:     args,
+		ConsulVersion: in.ConsulVersion,
+		EnvoyVersion:  in.EnvoyVersion,
+	}
+}
+
+func (in EnvoyExtension) validate(consulMeta common.ConsulMeta, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for _, v := range []struct {
+		name       string
+		constraint string
+	}{{"consulVersion", in.ConsulVersion}, {"envoyVersion", in.EnvoyVersion}} {
+		if v.constraint == "" {
+			continue
+		}
+		if _, err := version.NewConstraint(v.constraint); err != nil {
+			errs = append(errs, field.Invalid(path.Child(v.name), v.constraint, fmt.Sprintf("must be a version constraint: %s", err)))
+		}
+	}
+
+	builtin, ok := builtinEnvoyExtensions[in.Name]
+	if !ok {
+		var names []string
+		for name := range builtinEnvoyExtensions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return append(errs, field.Invalid(path.Child("name"), in.Name, notInSliceMessage(names)))
+	}
+	if err := requireConsulVersion(consulMeta, path.Child("name"), true, builtin.minConsulVersion); err != nil {
+		errs = append(errs, err)
+	}
+
+	argsPath := path.Child("arguments")
+	args := builtin.arguments()
+	if in.Arguments != nil {
+		var object map[string]interface{}
+		if err := json.Unmarshal(in.Arguments, &object); err != nil {
+			return append(errs, field.Invalid(argsPath, string(in.Arguments), fmt.Sprintf("must be valid map value: %s", err)))
+		}
+		if err := json.Unmarshal(in.Arguments, args); err != nil {
+			return append(errs, field.Invalid(argsPath, string(in.Arguments), fmt.Sprintf("invalid arguments for %s: %s", in.Name, err)))
+		}
+	}
+	return append(errs, args.validate(argsPath)...)
+}
+
+// The arguments of the builtin extensions below are decoded from JSON, which matches
+// keys to fields case-insensitively like Consul does.
+
+// +kubebuilder:object:generate=false
+type awsLambdaArguments struct {
+	ARN                string
+	PayloadPassthrough bool
+	InvocationMode     string
+}
+
+func (in *awsLambdaArguments) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if !strings.HasPrefix(in.ARN, "arn:") {
+		errs = append(errs, field.Invalid(path.Child("ARN"), in.ARN, "must be the ARN of a Lambda function"))
+	}
+	modes := []string{"", "synchronous", "asynchronous"}
+	if !sliceContains(modes, in.InvocationMode) {
+		errs = append(errs, field.Invalid(path.Child("InvocationMode"), in.InvocationMode, notInSliceMessage(modes)))
+	}
+	return errs
+}
+
+// +kubebuilder:object:generate=false
+type luaArguments struct {
+	ProxyType string
+	Listener  string
+	Script    string
+}
+
+func (in *luaArguments) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if err := validateExtensionProxyType(path.Child("ProxyType"), in.ProxyType); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateExtensionListenerType(path.Child("Listener"), in.Listener, false); err != nil {
+		errs = append(errs, err)
+	}
+	if in.Script == "" {
+		errs = append(errs, field.Required(path.Child("Script"), "a Lua script must be set"))
+	}
+	return errs
+}
+
+// +kubebuilder:object:generate=false
+type extAuthzArguments struct {
+	ProxyType    string
+	ListenerType string
+	Config       struct {
+		GrpcService *extensionService
+		HttpService *extensionService
+	}
+}
+
+func (in *extAuthzArguments) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if err := validateExtensionProxyType(path.Child("ProxyType"), in.ProxyType); err != nil {
+		errs = append(errs, err)
+	}
+	// The authorization service can only be called for inbound traffic.
+	if in.ListenerType != "" && in.ListenerType != "inbound" {
+		errs = append(errs, field.Invalid(path.Child("ListenerType"), in.ListenerType, notInSliceMessage([]string{"inbound"})))
+	}
+	configPath := path.Child("Config")
+	if (in.Config.GrpcService == nil) == (in.Config.HttpService == nil) {
+		errs = append(errs, field.Invalid(configPath, "", "exactly one of GrpcService or HttpService must be set"))
+	}
+	errs = append(errs, in.Config.GrpcService.validate(configPath.Child("GrpcService"))...)
+	errs = append(errs, in.Config.HttpService.validate(configPath.Child("HttpService"))...)
+	return errs
+}
+
+// +kubebuilder:object:generate=false
+type otelAccessLoggingArguments struct {
+	ProxyType    string
+	ListenerType string
+	Config       struct {
+		LogName             string
+		GrpcService         *extensionService
+		BufferFlushInterval *extensionDuration
+		BufferSizeBytes     uint32
+	}
+}
+
+func (in *otelAccessLoggingArguments) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if err := validateExtensionProxyType(path.Child("ProxyType"), in.ProxyType); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateExtensionListenerType(path.Child("ListenerType"), in.ListenerType, false); err != nil {
+		errs = append(errs, err)
+	}
+	configPath := path.Child("Config")
+	if in.Config.GrpcService == nil {
+		errs = append(errs, field.Required(configPath.Child("GrpcService"), "the OpenTelemetry collector must be set"))
+	}
+	errs = append(errs, in.Config.GrpcService.validate(configPath.Child("GrpcService"))...)
+	return errs
+}
+
+// +kubebuilder:object:generate=false
+type propertyOverrideArguments struct {
+	ProxyType string
+	Debug     bool
+	Patches   []struct {
+		ResourceFilter struct {
+			ResourceType     string
+			TrafficDirection string
+		}
+		Op    string
+		Path  string
+		Value interface{}
+	}
+}
+
+func (in *propertyOverrideArguments) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if err := validateExtensionProxyType(path.Child("ProxyType"), in.ProxyType); err != nil {
+		errs = append(errs, err)
+	}
+	if len(in.Patches) == 0 {
+		errs = append(errs, field.Required(path.Child("Patches"), "at least one patch must be set"))
+	}
+	resourceTypes := []string{"cluster", "cluster-load-assignment", "listener", "route"}
+	ops := []string{"add", "remove"}
+	for i, patch := range in.Patches {
+		patchPath := path.Child("Patches").Index(i)
+		filterPath := patchPath.Child("ResourceFilter")
+		if !sliceContains(resourceTypes, patch.ResourceFilter.ResourceType) {
+			errs = append(errs, field.Invalid(filterPath.Child("ResourceType"), patch.ResourceFilter.ResourceType, notInSliceMessage(resourceTypes)))
+		}
+		if err := validateExtensionListenerType(filterPath.Child("TrafficDirection"), patch.ResourceFilter.TrafficDirection, true); err != nil {
+			errs = append(errs, err)
+		}
+		if !sliceContains(ops, patch.Op) {
+			errs = append(errs, field.Invalid(patchPath.Child("Op"), patch.Op, notInSliceMessage(ops)))
+		}
+		if !strings.HasPrefix(patch.Path, "/") {
+			errs = append(errs, field.Invalid(patchPath.Child("Path"), patch.Path, `must begin with a '/'`))
+		}
+		if patch.Op == "add" && patch.Value == nil {
+			errs = append(errs, field.Required(patchPath.Child("Value"), "a value must be set for an add patch"))
+		}
+	}
+	return errs
+}
+
+// +kubebuilder:object:generate=false
+type wasmArguments struct {
+	Protocol     string
+	ListenerType string
+	ProxyType    string
+	PluginConfig struct {
+		Name     string
+		RootID   string
+		VmConfig struct {
+			VmID    string
+			Runtime string
+			Code    struct {
+				Local *struct {
+					Filename string
+				}
+				Remote *struct {
+					HttpURI *extensionService
+					SHA256  string
+				}
+			}
+		}
+	}
+}
+
+func (in *wasmArguments) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	protocols := []string{"", "tcp", "http"}
+	if !sliceContains(protocols, in.Protocol) {
+		errs = append(errs, field.Invalid(path.Child("Protocol"), in.Protocol, notInSliceMessage(protocols)))
+	}
+	if err := validateExtensionListenerType(path.Child("ListenerType"), in.ListenerType, false); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateExtensionProxyType(path.Child("ProxyType"), in.ProxyType); err != nil {
+		errs = append(errs, err)
+	}
+
+	vmPath := path.Child("PluginConfig").Child("VmConfig")
+	vm := in.PluginConfig.VmConfig
+	runtimes := []string{"", "v8", "wamr", "wavm", "wasmtime"}
+	if !sliceContains(runtimes, vm.Runtime) {
+		errs = append(errs, field.Invalid(vmPath.Child("Runtime"), vm.Runtime, notInSliceMessage(runtimes)))
+	}
+	codePath := vmPath.Child("Code")
+	switch code := vm.Code; {
+	case (code.Local == nil) == (code.Remote == nil):
+		errs = append(errs, field.Invalid(codePath, "", "exactly one of Local or Remote must be set"))
+	case code.Local != nil:
+		if code.Local.Filename == "" {
+			errs = append(errs, field.Required(codePath.Child("Local").Child("Filename"), "the file of the plugin must be set"))
+		}
+	default:
+		remotePath := codePath.Child("Remote")
+		if code.Remote.HttpURI == nil {
+			errs = append(errs, field.Required(remotePath.Child("HttpURI"), "the server of the plugin must be set"))
+		}
+		errs = append(errs, code.Remote.HttpURI.validate(remotePath.Child("HttpURI"))...)
+		if code.Remote.SHA256 == "" {
+			errs = append(errs, field.Required(remotePath.Child("SHA256"), "the checksum of the plugin must be set"))
+		}
+	}
+	return errs
+}
+
+// extensionService is a service called by Envoy for an extension, which is either a
+// service in the mesh or a URI.
+// +kubebuilder:object:generate=false
+type extensionService struct {
+	Target *struct {
+		Service *struct {
+			Name      string
+			Namespace string
+			Partition string
+		}
+		URI     string
+		Timeout *extensionDuration
+	}
+}
+
+func (in *extensionService) validate(path *field.Path) field.ErrorList {
+	if in == nil {
+		return nil
+	}
+	targetPath := path.Child("Target")
+	if in.Target == nil {
+		return field.ErrorList{field.Required(targetPath, "the target of the service must be set")}
+	}
+	if (in.Target.Service == nil) == (in.Target.URI == "") {
+		return field.ErrorList{field.Invalid(targetPath, "", "exactly one of Service or URI must be set")}
+	}
+	if in.Target.Service != nil && in.Target.Service.Name == "" {
+		return field.ErrorList{field.Required(targetPath.Child("Service").Child("Name"), "the name of the service must be set")}
+	}
+	return nil
+}
+
+// extensionDuration is a duration given as a string like "5s", or as a number
+// of nanoseconds.
+// +kubebuilder:object:generate=false
+type extensionDuration time.Duration
+
+func (d *extensionDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n int64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("%s is not a duration", data)
+		}
+		*d = extensionDuration(n)
+		return nil
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = extensionDuration(parsed)
+	return nil
+}
+
+func validateExtensionProxyType(path *field.Path, proxyType string) *field.Error {
+	proxyTypes := []string{"", "connect-proxy"}
+	if !sliceContains(proxyTypes, proxyType) {
+		return field.Invalid(path, proxyType, notInSliceMessage(proxyTypes))
+	}
+	return nil
+}
+
+func validateExtensionListenerType(path *field.Path, listenerType string, optional bool) *field.Error {
+	listenerTypes := []string{"inbound", "outbound"}
+	if optional && listenerType == "" {
+		return nil
+	}
+	if !sliceContains(listenerTypes, listenerType) {
+		return field.Invalid(path, listenerType, notInSliceMessage(listenerTypes))
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package v1alpha1
+
+import (
+	"net"
+	"net/url"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	ExternalWorkloadKubeKind = "externalworkload"
+
+	// DefaultExternalWorkloadCheckInterval is how often the health endpoint
+	// of an external workload is probed unless set.
+	DefaultExternalWorkloadCheckInterval = 10 * time.Second
+	// DefaultExternalWorkloadCheckTimeout is how long a probe of the health
+	// endpoint can take unless set.
+	DefaultExternalWorkloadCheckTimeout = 5 * time.Second
+)
+
+func init() {
+	SchemeBuilder.Register(&ExternalWorkload{}, &ExternalWorkloadList{})
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// ExternalWorkload is the Schema for the externalworkloads API. It registers
+// a workload that runs outside of Kubernetes, such as a VM, as an instance of
+// a service in the Consul catalog.
+// +kubebuilder:printcolumn:name="Service",type="string",JSONPath=".spec.service",description="The Consul service the workload is an instance of"
+// +kubebuilder:printcolumn:name="Address",type="string",JSONPath=".spec.address",description="The address of the workload"
+// +kubebuilder:printcolumn:name="Synced",type="string",JSONPath=".status.conditions[?(@.type==\"Synced\")].status",description="The sync status of the resource with Consul"
+// +kubebuilder:printcolumn:name="Last Synced",type="date",JSONPath=".status.lastSyncedTime",description="The last successful synced time of the resource with Consul"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="The age of the resource"
+type ExternalWorkload struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ExternalWorkloadSpec `json:"spec,omitempty"`
+	Status `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ExternalWorkloadList contains a list of ExternalWorkload.
+type ExternalWorkloadList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ExternalWorkload `json:"items"`
+}
+
+// ExternalWorkloadSpec defines the desired state of ExternalWorkload.
+type ExternalWorkloadSpec struct {
+	// Service is the name of the Consul service the workload is an instance
+	// of. It defaults to the name of the resource.
+	Service string `json:"service,omitempty"`
+	// Address is the IP address the workload is reachable at.
+	Address string `json:"address"`
+	// Port is the port the workload is reachable at.
+	Port int `json:"port"`
+	// Tags are the tags of the service instance.
+	Tags []string `json:"tags,omitempty"`
+	// Meta is the metadata of the service instance.
+	Meta map[string]string `json:"meta,omitempty"`
+	// HealthCheck is the health endpoint of the workload. If it is not set,
+	// the workload is always considered healthy.
+	HealthCheck *ExternalWorkloadHealthCheck `json:"healthCheck,omitempty"`
+}
+
+// ExternalWorkloadHealthCheck is a health endpoint that is probed by the
+// controller, since there is no Consul agent on the workload to run checks.
+// Exactly one of HTTP and TCP must be set.
+type ExternalWorkloadHealthCheck struct {
+	// HTTP is the URL that is requested. The workload is healthy if it
+	// responds with a 2xx status code.
+	HTTP string `json:"http,omitempty"`
+	// TCP is the host:port that is dialed. The workload is healthy if the
+	// connection is accepted.
+	TCP string `json:"tcp,omitempty"`
+	// Interval is how often the endpoint is probed. Defaults to 10s.
+	Interval metav1.Duration `json:"interval,omitempty"`
+	// Timeout is how long a probe can take. Defaults to 5s.
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+}
+
+// ServiceName returns the name of the Consul service the workload is an
+// instance of.
+func (in *ExternalWorkload) ServiceName() string {
+	if in.Spec.Service != "" {
+		return in.Spec.Service
+	}
+	return in.Name
+}
+
+func (in *ExternalWorkload) KubeKind() string {
+	return ExternalWorkloadKubeKind
+}
+
+func (in *ExternalWorkload) KubernetesName() string {
+	return in.ObjectMeta.Name
+}
+
+func (in *ExternalWorkload) SyncedConditionStatus() corev1.ConditionStatus {
+	cond := in.Status.GetCondition(ConditionSynced)
+	if cond == nil {
+		return corev1.ConditionUnknown
+	}
+	return cond.Status
+}
+
+func (in *ExternalWorkload) SetSyncedCondition(status corev1.ConditionStatus, reason string, message string) {
+	in.Status.Conditions = Conditions{
+		{
+			Type:               ConditionSynced,
+			Status:             status,
+			LastTransitionTime: metav1.Now(),
+			Reason:             reason,
+			Message:            message,
+		},
+	}
+}
+
+func (in *ExternalWorkload) SetLastSyncedTime(time *metav1.Time) {
+	in.Status.LastSyncedTime = time
+}
+
+func (in *ExternalWorkload) Validate() error {
+	var errs field.ErrorList
+	path := field.NewPath("spec")
+
+	if ip := net.ParseIP(in.Spec.Address); ip == nil {
+		errs = append(errs, field.Invalid(path.Child("address"), in.Spec.Address, "must be an IP address"))
+	}
+	if in.Spec.Port < 1 || in.Spec.Port > 65535 {
+		errs = append(errs, field.Invalid(path.Child("port"), in.Spec.Port, "must be between 1 and 65535"))
+	}
+	errs = append(errs, in.Spec.HealthCheck.validate(path.Child("healthCheck"))...)
+
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(
+			schema.GroupKind{Group: ConsulHashicorpGroup, Kind: ExternalWorkloadKubeKind},
+			in.KubernetesName(), errs)
+	}
+	return nil
+}
+
+// IntervalOrDefault returns how often the endpoint is probed.
+func (in *ExternalWorkloadHealthCheck) IntervalOrDefault() time.Duration {
+	if in.Interval.Duration > 0 {
+		return in.Interval.Duration
+	}
+	return DefaultExternalWorkloadCheckInterval
+}
+
+// TimeoutOrDefault returns how long a probe can take.
+func (in *ExternalWorkloadHealthCheck) TimeoutOrDefault() time.Duration {
+	if in.Timeout.Duration > 0 {
+		return in.Timeout.Duration
+	}
+	return DefaultExternalWorkloadCheckTimeout
+}
+
+func (in *ExternalWorkloadHealthCheck) validate(path *field.Path) field.ErrorList {
+	if in == nil {
+		return nil
+	}
+
+	var errs field.ErrorList
+	switch {
+	case in.HTTP == "" && in.TCP == "":
+		errs = append(errs, field.Required(path, "one of http or tcp must be set"))
+	case in.HTTP != "" && in.TCP != "":
+		errs = append(errs, field.Invalid(path, in, "only one of http or tcp can be set"))
+	case in.HTTP != "":
+		if u, err := url.Parse(in.HTTP); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, field.Invalid(path.Child("http"), in.HTTP, "must be an http or https URL"))
+		}
+	case in.TCP != "":
+		if _, _, err := net.SplitHostPort(in.TCP); err != nil {
+			errs = append(errs, field.Invalid(path.Child("tcp"), in.TCP, "must be host:port"))
+		}
+	}
+	if in.Interval.Duration < 0 {
+		errs = append(errs, field.Invalid(path.Child("interval"), in.Interval.Duration.String(), "must not be negative"))
+	}
+	if in.Timeout.Duration < 0 {
+		errs = append(errs, field.Invalid(path.Child("timeout"), in.Timeout.Duration.String(), "must not be negative"))
+	}
+	return errs
+}
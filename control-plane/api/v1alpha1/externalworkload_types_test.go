@@ -0,0 +1,95 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestExternalWorkload_ServiceName(t *testing.T) {
+	workload := &ExternalWorkload{ObjectMeta: metav1.ObjectMeta{Name: "billing-vm-1"}}
+	require.Equal(t, "billing-vm-1", workload.ServiceName())
+
+	workload.Spec.Service = "billing"
+	require.Equal(t, "billing", workload.ServiceName())
+}
+
+func TestExternalWorkload_Validate(t *testing.T) {
+	cases := map[string]struct {
+		spec            ExternalWorkloadSpec
+		expectedErrMsgs []string
+	}{
+		"valid": {
+			spec: ExternalWorkloadSpec{Address: "10.0.0.5", Port: 8080},
+		},
+		"valid http check": {
+			spec: ExternalWorkloadSpec{
+				Address:     "10.0.0.5",
+				Port:        8080,
+				HealthCheck: &ExternalWorkloadHealthCheck{HTTP: "http://10.0.0.5:8080/health"},
+			},
+		},
+		"valid tcp check": {
+			spec: ExternalWorkloadSpec{
+				Address:     "10.0.0.5",
+				Port:        8080,
+				HealthCheck: &ExternalWorkloadHealthCheck{TCP: "10.0.0.5:8080", Interval: metav1.Duration{Duration: time.Minute}},
+			},
+		},
+		"invalid address and port": {
+			spec: ExternalWorkloadSpec{Address: "billing.internal", Port: 0},
+			expectedErrMsgs: []string{
+				`spec.address: Invalid value: "billing.internal": must be an IP address`,
+				`spec.port: Invalid value: 0: must be between 1 and 65535`,
+			},
+		},
+		"check without endpoint": {
+			spec: ExternalWorkloadSpec{
+				Address:     "10.0.0.5",
+				Port:        8080,
+				HealthCheck: &ExternalWorkloadHealthCheck{},
+			},
+			expectedErrMsgs: []string{
+				`spec.healthCheck: Required value: one of http or tcp must be set`,
+			},
+		},
+		"check with both endpoints": {
+			spec: ExternalWorkloadSpec{
+				Address:     "10.0.0.5",
+				Port:        8080,
+				HealthCheck: &ExternalWorkloadHealthCheck{HTTP: "http://10.0.0.5:8080/health", TCP: "10.0.0.5:8080"},
+			},
+			expectedErrMsgs: []string{
+				`only one of http or tcp can be set`,
+			},
+		},
+		"invalid endpoints": {
+			spec: ExternalWorkloadSpec{
+				Address:     "10.0.0.5",
+				Port:        8080,
+				HealthCheck: &ExternalWorkloadHealthCheck{HTTP: "10.0.0.5:8080/health", Timeout: metav1.Duration{Duration: -time.Second}},
+			},
+			expectedErrMsgs: []string{
+				`spec.healthCheck.http: Invalid value: "10.0.0.5:8080/health": must be an http or https URL`,
+				`spec.healthCheck.timeout: Invalid value: "-1s": must not be negative`,
+			},
+		},
+	}
+
+	for name, testCase := range cases {
+		t.Run(name, func(t *testing.T) {
+			workload := &ExternalWorkload{ObjectMeta: metav1.ObjectMeta{Name: "billing"}, Spec: testCase.spec}
+			err := workload.Validate()
+			if len(testCase.expectedErrMsgs) != 0 {
+				require.Error(t, err)
+				for _, s := range testCase.expectedErrMsgs {
+					require.Contains(t, err.Error(), s)
+				}
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
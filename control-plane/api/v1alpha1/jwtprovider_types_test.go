@@ -0,0 +1,485 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestJWTProvider_MatchesConsul(t *testing.T) {
+	cases := map[string]struct {
+		Ours    JWTProvider
+		Theirs  capi.ConfigEntry
+		Matches bool
+	}{
+		"empty fields matches": {
+			Ours: JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "okta",
+				},
+				Spec: JWTProviderSpec{},
+			},
+			Theirs: &capi.JWTProviderConfigEntry{
+				Kind:        capi.JWTProvider,
+				Name:        "okta",
+				CreateIndex: 1,
+				ModifyIndex: 2,
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Matches: true,
+		},
+		"all fields set matches": {
+			Ours: JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "okta",
+				},
+				Spec: jwtProviderSpecAllFields(),
+			},
+			Theirs:  jwtProviderEntryAllFields(),
+			Matches: true,
+		},
+		"different issuer does not match": {
+			Ours: JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "okta",
+				},
+				Spec: JWTProviderSpec{
+					Issuer: "https://other.okta.com",
+				},
+			},
+			Theirs: &capi.JWTProviderConfigEntry{
+				Kind:   capi.JWTProvider,
+				Name:   "okta",
+				Issuer: "https://okta.com",
+			},
+			Matches: false,
+		},
+		"mismatched types does not match": {
+			Ours: JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "okta",
+				},
+				Spec: JWTProviderSpec{},
+			},
+			Theirs: &capi.ServiceConfigEntry{
+				Name: "okta",
+				Kind: capi.JWTProvider,
+			},
+			Matches: false,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.Matches, c.Ours.MatchesConsul(c.Theirs))
+		})
+	}
+}
+
+func TestJWTProvider_ToConsul(t *testing.T) {
+	cases := map[string]struct {
+		Ours JWTProvider
+		Exp  *capi.JWTProviderConfigEntry
+	}{
+		"empty fields": {
+			Ours: JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "okta",
+				},
+				Spec: JWTProviderSpec{},
+			},
+			Exp: &capi.JWTProviderConfigEntry{
+				Kind: capi.JWTProvider,
+				Name: "okta",
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+		},
+		"every field set": {
+			Ours: JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "okta",
+				},
+				Spec: jwtProviderSpecAllFields(),
+			},
+			Exp: jwtProviderEntryAllFields(),
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			act := c.Ours.ToConsul("datacenter")
+			provider, ok := act.(*capi.JWTProviderConfigEntry)
+			require.True(t, ok, "could not cast")
+			require.Equal(t, c.Exp, provider)
+		})
+	}
+}
+
+func TestJWTProvider_Validate(t *testing.T) {
+	remote := func(modify func(*RemoteJWKS)) JWTProviderSpec {
+		r := &RemoteJWKS{URI: "https://okta.com/.well-known/jwks.json"}
+		modify(r)
+		return JWTProviderSpec{JSONWebKeySet: &JSONWebKeySet{Remote: r}}
+	}
+
+	cases := map[string]struct {
+		spec           JWTProviderSpec
+		expectedErrMsg string
+	}{
+		"valid": {
+			spec: jwtProviderSpecAllFields(),
+		},
+		"valid local": {
+			spec: JWTProviderSpec{
+				JSONWebKeySet: &JSONWebKeySet{Local: &LocalJWKS{JWKS: "eyJrZXlzIjpbXX0="}},
+			},
+		},
+		"no key set": {
+			spec:           JWTProviderSpec{},
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "okta" is invalid: spec.jsonWebKeySet: Required value: a JSON Web Key Set must be specified`,
+		},
+		"local and remote key set": {
+			spec: JWTProviderSpec{
+				JSONWebKeySet: &JSONWebKeySet{
+					Local:  &LocalJWKS{Filename: "/etc/jwks.json"},
+					Remote: &RemoteJWKS{URI: "https://okta.com/.well-known/jwks.json"},
+				},
+			},
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "okta" is invalid: spec.jsonWebKeySet: Invalid value: "{\"local\":{\"filename\":\"/etc/jwks.json\"},\"remote\":{\"uri\":\"https://okta.com/.well-known/jwks.json\",\"cacheDuration\":\"0s\"}}": exactly one of local or remote must be specified`,
+		},
+		"local jwks and filename": {
+			spec: JWTProviderSpec{
+				JSONWebKeySet: &JSONWebKeySet{Local: &LocalJWKS{JWKS: "eyJrZXlzIjpbXX0=", Filename: "/etc/jwks.json"}},
+			},
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "okta" is invalid: spec.jsonWebKeySet.local: Invalid value: "{\"jwks\":\"eyJrZXlzIjpbXX0=\",\"filename\":\"/etc/jwks.json\"}": exactly one of jwks or filename must be specified`,
+		},
+		"local jwks not base64": {
+			spec: JWTProviderSpec{
+				JSONWebKeySet: &JSONWebKeySet{Local: &LocalJWKS{JWKS: "{\"keys\":[]}"}},
+			},
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "okta" is invalid: spec.jsonWebKeySet.local.jwks: Invalid value: "{\"keys\":[]}": must be base64 encoded`,
+		},
+		"remote without uri": {
+			spec:           remote(func(r *RemoteJWKS) { r.URI = "" }),
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "okta" is invalid: spec.jsonWebKeySet.remote.uri: Required value: the URI of the JWKS must be specified`,
+		},
+		"remote uri not http": {
+			spec:           remote(func(r *RemoteJWKS) { r.URI = "file:///etc/jwks.json" }),
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "okta" is invalid: spec.jsonWebKeySet.remote.uri: Invalid value: "file:///etc/jwks.json": must be an http or https URL`,
+		},
+		"negative remote fields": {
+			spec: remote(func(r *RemoteJWKS) {
+				r.RequestTimeoutMs = -1
+				r.CacheDuration = metav1.Duration{Duration: -time.Second}
+				r.RetryPolicy = &JWKSRetryPolicy{NumRetries: -1}
+			}),
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "okta" is invalid: [` +
+				`spec.jsonWebKeySet.remote.requestTimeoutMs: Invalid value: -1: must be 0 or greater, ` +
+				`spec.jsonWebKeySet.remote.cacheDuration: Invalid value: "-1s": must be 0 or greater, ` +
+				`spec.jsonWebKeySet.remote.retryPolicy.numRetries: Invalid value: -1: must be 0 or greater]`,
+		},
+		"max interval less than base interval": {
+			spec: remote(func(r *RemoteJWKS) {
+				r.RetryPolicy = &JWKSRetryPolicy{RetryPolicyBackOff: &RetryPolicyBackOff{
+					BaseInterval: metav1.Duration{Duration: 2 * time.Second},
+					MaxInterval:  metav1.Duration{Duration: time.Second},
+				}}
+			}),
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "okta" is invalid: spec.jsonWebKeySet.remote.retryPolicy.retryPolicyBackOff.maxInterval: Invalid value: "1s": must be greater than or equal to baseInterval`,
+		},
+		"invalid discovery type": {
+			spec: remote(func(r *RemoteJWKS) {
+				r.JWKSCluster = &JWKSCluster{DiscoveryType: "DNS"}
+			}),
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "okta" is invalid: spec.jsonWebKeySet.remote.jwksCluster.discoveryType: Invalid value: "DNS": must be one of "", "STRICT_DNS", "STATIC", "LOGICAL_DNS", "EDS", "ORIGINAL_DST"`,
+		},
+		"tls certificates without a source": {
+			spec: remote(func(r *RemoteJWKS) {
+				r.JWKSCluster = &JWKSCluster{TLSCertificates: &JWKSTLSCertificate{}}
+			}),
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "okta" is invalid: spec.jsonWebKeySet.remote.jwksCluster.tlsCertificates: Invalid value: "{}": exactly one of caCertificateProviderInstance or trustedCA must be specified`,
+		},
+		"trusted CA with two sources": {
+			spec: remote(func(r *RemoteJWKS) {
+				r.JWKSCluster = &JWKSCluster{TLSCertificates: &JWKSTLSCertificate{
+					TrustedCA: &JWKSTLSCertTrustedCA{Filename: "/etc/ca.pem", EnvironmentVariable: "CA"},
+				}}
+			}),
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "okta" is invalid: spec.jsonWebKeySet.remote.jwksCluster.tlsCertificates.trustedCA: Invalid value: "{\"filename\":\"/etc/ca.pem\",\"environmentVariable\":\"CA\"}": exactly one of filename, environmentVariable, inlineString or inlineBytes must be specified`,
+		},
+		"invalid locations": {
+			spec: JWTProviderSpec{
+				JSONWebKeySet: &JSONWebKeySet{Local: &LocalJWKS{Filename: "/etc/jwks.json"}},
+				Locations: []*JWTLocation{
+					{Header: &JWTLocationHeader{Name: "Authorization"}, Cookie: &JWTLocationCookie{Name: "jwt"}},
+					{QueryParam: &JWTLocationQueryParam{}},
+					nil,
+				},
+			},
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "okta" is invalid: [` +
+				`spec.locations[0]: Invalid value: "{\"header\":{\"name\":\"Authorization\"},\"cookie\":{\"name\":\"jwt\"}}": exactly one of header, queryParam or cookie must be specified, ` +
+				`spec.locations[1].queryParam.name: Required value: the name must be specified, ` +
+				`spec.locations[2]: Required value: a location must be specified]`,
+		},
+		"forwarding without header name": {
+			spec: JWTProviderSpec{
+				JSONWebKeySet: &JSONWebKeySet{Local: &LocalJWKS{Filename: "/etc/jwks.json"}},
+				Forwarding:    &JWTForwardingConfig{PadForwardPayloadHeader: true},
+			},
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "okta" is invalid: spec.forwarding.headerName: Required value: the header to forward verified JWTs in must be specified`,
+		},
+		"negative clock skew and cache size": {
+			spec: JWTProviderSpec{
+				JSONWebKeySet:    &JSONWebKeySet{Local: &LocalJWKS{Filename: "/etc/jwks.json"}},
+				ClockSkewSeconds: -1,
+				CacheConfig:      &JWTCacheConfig{Size: -1},
+			},
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "okta" is invalid: [` +
+				`spec.clockSkewSeconds: Invalid value: -1: must be 0 or greater, ` +
+				`spec.cacheConfig.size: Invalid value: -1: must be 0 or greater]`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			provider := &JWTProvider{ObjectMeta: metav1.ObjectMeta{Name: "okta"}, Spec: c.spec}
+			err := provider.Validate(common.ConsulMeta{})
+			if c.expectedErrMsg != "" {
+				require.EqualError(t, err, c.expectedErrMsg)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestJWTProvider_ValidateConsulVersion(t *testing.T) {
+	cases := map[string]struct {
+		consulVersion  string
+		expectedErrMsg string
+	}{
+		"unknown version": {},
+		"new enough version": {
+			consulVersion: "1.17.0",
+		},
+		"version without jwks cluster": {
+			consulVersion: "1.16.2",
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "okta" is invalid: ` +
+				`spec.jsonWebKeySet.remote.jwksCluster: Forbidden: requires Consul 1.17.0 or newer, but Consul is 1.16.2`,
+		},
+		"version without jwt providers": {
+			consulVersion: "1.15.4",
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "okta" is invalid: [` +
+				`spec: Forbidden: requires Consul 1.16.0 or newer, but Consul is 1.15.4, ` +
+				`spec.jsonWebKeySet.remote.jwksCluster: Forbidden: requires Consul 1.17.0 or newer, but Consul is 1.15.4]`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var consulMeta common.ConsulMeta
+			if c.consulVersion != "" {
+				consulMeta.ConsulVersion = version.Must(version.NewVersion(c.consulVersion))
+			}
+			provider := &JWTProvider{ObjectMeta: metav1.ObjectMeta{Name: "okta"}, Spec: jwtProviderSpecAllFields()}
+			err := provider.Validate(consulMeta)
+			if c.expectedErrMsg != "" {
+				require.EqualError(t, err, c.expectedErrMsg)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestJWTProvider_AddFinalizer(t *testing.T) {
+	provider := &JWTProvider{}
+	provider.AddFinalizer("finalizer")
+	require.Equal(t, []string{"finalizer"}, provider.ObjectMeta.Finalizers)
+}
+
+func TestJWTProvider_RemoveFinalizer(t *testing.T) {
+	provider := &JWTProvider{
+		ObjectMeta: metav1.ObjectMeta{
+			Finalizers: []string{"f1", "f2"},
+		},
+	}
+	provider.RemoveFinalizer("f1")
+	require.Equal(t, []string{"f2"}, provider.ObjectMeta.Finalizers)
+}
+
+func TestJWTProvider_SetSyncedCondition(t *testing.T) {
+	provider := &JWTProvider{}
+	provider.SetSyncedCondition(corev1.ConditionTrue, "reason", "message")
+
+	require.Equal(t, corev1.ConditionTrue, provider.Status.Conditions[0].Status)
+	require.Equal(t, "reason", provider.Status.Conditions[0].Reason)
+	require.Equal(t, "message", provider.Status.Conditions[0].Message)
+	now := metav1.Now()
+	require.True(t, provider.Status.Conditions[0].LastTransitionTime.Before(&now))
+}
+
+// The Synced and JWKSReachable conditions are set independently, so setting one
+// keeps the other.
+func TestJWTProvider_SetJWKSReachableCondition(t *testing.T) {
+	provider := &JWTProvider{}
+	provider.SetSyncedCondition(corev1.ConditionTrue, "", "")
+	provider.SetJWKSReachableCondition(corev1.ConditionFalse, "JWKSUnreachable", "connection refused")
+	provider.SetSyncedCondition(corev1.ConditionFalse, "ConsulAgentError", "error")
+
+	require.Len(t, provider.Status.Conditions, 2)
+	reachable := provider.GetCondition(ConditionJWKSReachable)
+	require.NotNil(t, reachable)
+	require.Equal(t, corev1.ConditionFalse, reachable.Status)
+	require.Equal(t, "JWKSUnreachable", reachable.Reason)
+	require.Equal(t, "connection refused", reachable.Message)
+	require.Equal(t, corev1.ConditionFalse, provider.SyncedConditionStatus())
+
+	provider.SetJWKSReachableCondition("", "", "")
+	require.Nil(t, provider.GetCondition(ConditionJWKSReachable))
+	require.Equal(t, corev1.ConditionFalse, provider.SyncedConditionStatus())
+}
+
+func TestJWTProvider_SetLastSyncedTime(t *testing.T) {
+	provider := &JWTProvider{}
+	syncedTime := metav1.NewTime(time.Now())
+	provider.SetLastSyncedTime(&syncedTime)
+
+	require.Equal(t, &syncedTime, provider.Status.LastSyncedTime)
+}
+
+func TestJWTProvider_SyncedConditionWhenStatusNil(t *testing.T) {
+	status, reason, message := (&JWTProvider{}).SyncedCondition()
+	require.Equal(t, corev1.ConditionUnknown, status)
+	require.Equal(t, "", reason)
+	require.Equal(t, "", message)
+}
+
+func TestJWTProvider_ConsulKind(t *testing.T) {
+	require.Equal(t, capi.JWTProvider, (&JWTProvider{}).ConsulKind())
+}
+
+func TestJWTProvider_KubeKind(t *testing.T) {
+	require.Equal(t, "jwtprovider", (&JWTProvider{}).KubeKind())
+}
+
+func TestJWTProvider_ConsulName(t *testing.T) {
+	require.Equal(t, "foo", (&JWTProvider{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}).ConsulName())
+}
+
+func TestJWTProvider_KubernetesName(t *testing.T) {
+	require.Equal(t, "foo", (&JWTProvider{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}).KubernetesName())
+}
+
+func TestJWTProvider_ConsulNamespace(t *testing.T) {
+	require.Equal(t, common.DefaultConsulNamespace, (&JWTProvider{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"}}).ConsulMirroringNS())
+}
+
+func TestJWTProvider_ConsulGlobalResource(t *testing.T) {
+	require.True(t, (&JWTProvider{}).ConsulGlobalResource())
+}
+
+func TestJWTProvider_ObjectMeta(t *testing.T) {
+	meta := metav1.ObjectMeta{
+		Name:      "name",
+		Namespace: "namespace",
+	}
+	provider := &JWTProvider{
+		ObjectMeta: meta,
+	}
+	require.Equal(t, meta, provider.GetObjectMeta())
+}
+
+func jwtProviderSpecAllFields() JWTProviderSpec {
+	return JWTProviderSpec{
+		JSONWebKeySet: &JSONWebKeySet{
+			Remote: &RemoteJWKS{
+				URI:                 "https://okta.com/.well-known/jwks.json",
+				RequestTimeoutMs:    500,
+				CacheDuration:       metav1.Duration{Duration: 10 * time.Minute},
+				FetchAsynchronously: true,
+				RetryPolicy: &JWKSRetryPolicy{
+					NumRetries: 3,
+					RetryPolicyBackOff: &RetryPolicyBackOff{
+						BaseInterval: metav1.Duration{Duration: time.Second},
+						MaxInterval:  metav1.Duration{Duration: 10 * time.Second},
+					},
+				},
+				JWKSCluster: &JWKSCluster{
+					DiscoveryType: "STRICT_DNS",
+					TLSCertificates: &JWKSTLSCertificate{
+						TrustedCA: &JWKSTLSCertTrustedCA{Filename: "/etc/ca.pem"},
+					},
+					ConnectTimeout: metav1.Duration{Duration: 5 * time.Second},
+				},
+			},
+		},
+		Issuer:    "https://okta.com",
+		Audiences: []string{"api"},
+		Locations: []*JWTLocation{
+			{Header: &JWTLocationHeader{Name: "Authorization", ValuePrefix: "Bearer ", Forward: true}},
+			{QueryParam: &JWTLocationQueryParam{Name: "access_token"}},
+			{Cookie: &JWTLocationCookie{Name: "jwt"}},
+		},
+		Forwarding: &JWTForwardingConfig{
+			HeaderName:              "x-jwt",
+			PadForwardPayloadHeader: true,
+		},
+		ClockSkewSeconds: 20,
+		CacheConfig:      &JWTCacheConfig{Size: 100},
+	}
+}
+
+func jwtProviderEntryAllFields() *capi.JWTProviderConfigEntry {
+	return &capi.JWTProviderConfigEntry{
+		Kind: capi.JWTProvider,
+		Name: "okta",
+		JSONWebKeySet: &capi.JSONWebKeySet{
+			Remote: &capi.RemoteJWKS{
+				URI:                 "https://okta.com/.well-known/jwks.json",
+				RequestTimeoutMs:    500,
+				CacheDuration:       10 * time.Minute,
+				FetchAsynchronously: true,
+				RetryPolicy: &capi.JWKSRetryPolicy{
+					NumRetries: 3,
+					RetryPolicyBackOff: &capi.RetryPolicyBackOff{
+						BaseInterval: time.Second,
+						MaxInterval:  10 * time.Second,
+					},
+				},
+				JWKSCluster: &capi.JWKSCluster{
+					DiscoveryType: capi.DiscoveryTypeStrictDNS,
+					TLSCertificates: &capi.JWKSTLSCertificate{
+						TrustedCA: &capi.JWKSTLSCertTrustedCA{Filename: "/etc/ca.pem"},
+					},
+					ConnectTimeout: 5 * time.Second,
+				},
+			},
+		},
+		Issuer:    "https://okta.com",
+		Audiences: []string{"api"},
+		Locations: []*capi.JWTLocation{
+			{Header: &capi.JWTLocationHeader{Name: "Authorization", ValuePrefix: "Bearer ", Forward: true}},
+			{QueryParam: &capi.JWTLocationQueryParam{Name: "access_token"}},
+			{Cookie: &capi.JWTLocationCookie{Name: "jwt"}},
+		},
+		Forwarding: &capi.JWTForwardingConfig{
+			HeaderName:              "x-jwt",
+			PadForwardPayloadHeader: true,
+		},
+		ClockSkewSeconds: 20,
+		CacheConfig:      &capi.JWTCacheConfig{Size: 100},
+		Meta: map[string]string{
+			common.SourceKey:     common.SourceValue,
+			common.DatacenterKey: "datacenter",
+		},
+	}
+}
@@ -0,0 +1,71 @@
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:object:generate=false
+
+type JWTProviderWebhook struct {
+	client.Client
+	ConsulClient *capi.Client
+	Logger       logr.Logger
+	decoder      *admission.Decoder
+	ConsulMeta   common.ConsulMeta
+}
+
+// NOTE: The path value in the below line is the path to the webhook.
+// If it is updated, run code-gen, update subcommand/controller/command.go
+// and the consul-helm value for the path to the webhook.
+//
+// NOTE: The below line cannot be combined with any other comment. If it is
+// it will break the code generation.
+//
+// +kubebuilder:webhook:verbs=create;update,path=/mutate-v1alpha1-jwtprovider,mutating=true,failurePolicy=fail,groups=consul.hashicorp.com,resources=jwtproviders,versions=v1alpha1,name=mutate-jwtprovider.consul.hashicorp.com,sideEffects=None,admissionReviewVersions=v1beta1;v1
+
+func (v *JWTProviderWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var provider JWTProvider
+	var providerList JWTProviderList
+	err := v.decoder.Decode(req, &provider)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// jwt-provider config entries aren't namespaced in Consul, so the names of the
+	// resources must be unique across Kubernetes namespaces.
+	if req.Operation == admissionv1.Create {
+		v.Logger.Info("validate create", "name", provider.KubernetesName())
+
+		if err := v.Client.List(ctx, &providerList); err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+
+		for _, item := range providerList.Items {
+			if item.KubernetesName() == provider.KubernetesName() {
+				return admission.Errored(http.StatusBadRequest,
+					fmt.Errorf("%s resource with name %q is already defined – all %s resources must have unique names across namespaces",
+						provider.KubeKind(), provider.KubernetesName(), provider.KubeKind()))
+			}
+		}
+	}
+
+	if err := provider.Validate(v.ConsulMeta); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	return admission.Allowed(fmt.Sprintf("valid %s request", provider.KubeKind()))
+}
+
+func (v *JWTProviderWebhook) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
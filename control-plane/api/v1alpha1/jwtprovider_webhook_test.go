@@ -0,0 +1,102 @@
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestValidateJWTProvider(t *testing.T) {
+	spec := JWTProviderSpec{
+		JSONWebKeySet: &JSONWebKeySet{Remote: &RemoteJWKS{URI: "https://okta.com/.well-known/jwks.json"}},
+	}
+
+	cases := map[string]struct {
+		existingResources []runtime.Object
+		newResource       *JWTProvider
+		consulMeta        common.ConsulMeta
+		expAllow          bool
+		expErrMessage     string
+	}{
+		"no duplicates, valid": {
+			newResource: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "okta", Namespace: "default"},
+				Spec:       spec,
+			},
+			expAllow: true,
+		},
+		"jwtprovider exists in another namespace": {
+			existingResources: []runtime.Object{&JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "okta", Namespace: "other"},
+				Spec:       spec,
+			}},
+			newResource: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "okta", Namespace: "default"},
+				Spec:       spec,
+			},
+			expAllow:      false,
+			expErrMessage: "jwtprovider resource with name \"okta\" is already defined – all jwtprovider resources must have unique names across namespaces",
+		},
+		"invalid": {
+			newResource: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "okta", Namespace: "default"},
+			},
+			expAllow:      false,
+			expErrMessage: "jwtprovider.consul.hashicorp.com \"okta\" is invalid: spec.jsonWebKeySet: Required value: a JSON Web Key Set must be specified",
+		},
+		"Consul too old": {
+			newResource: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "okta", Namespace: "default"},
+				Spec:       spec,
+			},
+			consulMeta:    common.ConsulMeta{ConsulVersion: version.Must(version.NewVersion("1.15.0"))},
+			expAllow:      false,
+			expErrMessage: "jwtprovider.consul.hashicorp.com \"okta\" is invalid: spec: Forbidden: requires Consul 1.16.0 or newer, but Consul is 1.15.0",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			marshalledRequestObject, err := json.Marshal(c.newResource)
+			require.NoError(t, err)
+			s := runtime.NewScheme()
+			s.AddKnownTypes(GroupVersion, &JWTProvider{}, &JWTProviderList{})
+			client := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(c.existingResources...).Build()
+			decoder, err := admission.NewDecoder(s)
+			require.NoError(t, err)
+
+			validator := &JWTProviderWebhook{
+				Client:       client,
+				ConsulClient: nil,
+				Logger:       logrtest.TestLogger{T: t},
+				decoder:      decoder,
+				ConsulMeta:   c.consulMeta,
+			}
+			response := validator.Handle(ctx, admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Name:      c.newResource.KubernetesName(),
+					Namespace: c.newResource.Namespace,
+					Operation: admissionv1.Create,
+					Object: runtime.RawExtension{
+						Raw: marshalledRequestObject,
+					},
+				},
+			})
+
+			require.Equal(t, c.expAllow, response.Allowed)
+			if c.expErrMessage != "" {
+				require.Equal(t, c.expErrMessage, response.AdmissionResponse.Result.Message)
+			}
+		})
+	}
+}
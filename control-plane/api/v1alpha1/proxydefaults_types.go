@@ -75,8 +75,64 @@ type ProxyDefaultsSpec struct {
 	MeshGateway MeshGateway `json:"meshGateway,omitempty"`
 	// Expose controls the default expose path configuration for Envoy.
 	Expose Expose `json:"expose,omitempty"`
+	// AccessLogs controls all envoy instances' access logging configuration. Requires Consul 1.15+.
+	AccessLogs *AccessLogs `json:"accessLogs,omitempty"`
+	// MutualTLSMode can be one of "strict" or "permissive". "strict" requires mTLS for incoming
+	// traffic; "permissive" also allows non-mTLS traffic to the services of proxies in
+	// transparent mode. Requires Consul 1.16+.
+	MutualTLSMode MutualTLSMode `json:"mutualTLSMode,omitempty"`
+	// FailoverPolicy specifies the exact mechanism used for failover of the upstreams of
+	// all proxies. Requires Consul 1.17+.
+	FailoverPolicy *FailoverPolicy `json:"failoverPolicy,omitempty"`
+	// PrioritizeByLocality controls whether the locality of services within the local
+	// partition is used to prioritize connectivity to their instances. Requires Consul 1.17+.
+	PrioritizeByLocality *PrioritizeByLocality `json:"prioritizeByLocality,omitempty"`
 }
 
+// AccessLogs describes the access logging configuration of the proxies.
+type AccessLogs struct {
+	// Enabled turns on all access logging.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// DisableListenerLogs turns off just listener logs for connections rejected by Envoy because they don't
+	// have a matching listener filter.
+	DisableListenerLogs bool `json:"disableListenerLogs,omitempty"`
+
+	// Type selects the output for logs
+	// one of "file", "stderr", "stdout".
+	Type LogSinkType `json:"type,omitempty"`
+
+	// Path is the output file to write logs for file-type logging
+	Path string `json:"path,omitempty"`
+
+	// JSONFormat is a JSON-formatted string of an Envoy access log format dictionary.
+	// See for more info on formatting: https://www.envoyproxy.io/docs/envoy/latest/configuration/observability/access_log/usage#format-dictionaries
+	// Defining JSONFormat and TextFormat is invalid.
+	JSONFormat string `json:"jsonFormat,omitempty"`
+
+	// TextFormat is a representation of Envoy access logs format.
+	// See for more info on formatting: https://www.envoyproxy.io/docs/envoy/latest/configuration/observability/access_log/usage#format-strings
+	// Defining JSONFormat and TextFormat is invalid.
+	TextFormat string `json:"textFormat,omitempty"`
+}
+
+type LogSinkType string
+
+const (
+	DefaultLogSinkType LogSinkType = ""
+	FileLogSinkType    LogSinkType = "file"
+	StdErrLogSinkType  LogSinkType = "stderr"
+	StdOutLogSinkType  LogSinkType = "stdout"
+)
+
+type MutualTLSMode string
+
+const (
+	MutualTLSModeDefault    MutualTLSMode = ""
+	MutualTLSModeStrict     MutualTLSMode = "strict"
+	MutualTLSModePermissive MutualTLSMode = "permissive"
+)
+
 func (in *ProxyDefaults) GetObjectMeta() metav1.ObjectMeta {
 	return in.ObjectMeta
 }
@@ -159,13 +215,17 @@ func (in *ProxyDefaults) SetLastSyncedTime(time *metav1.Time) {
 func (in *ProxyDefaults) ToConsul(datacenter string) capi.ConfigEntry {
 	consulConfig := in.convertConfig()
 	return &capi.ProxyConfigEntry{
-		Kind:             in.ConsulKind(),
-		Name:             in.ConsulName(),
-		MeshGateway:      in.Spec.MeshGateway.toConsul(),
-		Expose:           in.Spec.Expose.toConsul(),
-		Config:           consulConfig,
-		TransparentProxy: in.Spec.TransparentProxy.toConsul(),
-		Meta:             meta(datacenter),
+		Kind:                 in.ConsulKind(),
+		Name:                 in.ConsulName(),
+		MeshGateway:          in.Spec.MeshGateway.toConsul(),
+		Expose:               in.Spec.Expose.toConsul(),
+		Config:               consulConfig,
+		TransparentProxy:     in.Spec.TransparentProxy.toConsul(),
+		AccessLogs:           in.Spec.AccessLogs.toConsul(),
+		MutualTLSMode:        capi.MutualTLSMode(in.Spec.MutualTLSMode),
+		FailoverPolicy:       in.Spec.FailoverPolicy.toConsul(),
+		PrioritizeByLocality: in.Spec.PrioritizeByLocality.toConsul(),
+		Meta:                 meta(datacenter),
 	}
 }
 
@@ -179,7 +239,7 @@ func (in *ProxyDefaults) MatchesConsul(candidate api.ConfigEntry) bool {
 		cmp.Comparer(transparentProxyConfigComparer))
 }
 
-func (in *ProxyDefaults) Validate(_ common.ConsulMeta) error {
+func (in *ProxyDefaults) Validate(consulMeta common.ConsulMeta) error {
 	var allErrs field.ErrorList
 	path := field.NewPath("spec")
 
@@ -196,6 +256,26 @@ func (in *ProxyDefaults) Validate(_ common.ConsulMeta) error {
 		allErrs = append(allErrs, err)
 	}
 	allErrs = append(allErrs, in.Spec.Expose.validate(path.Child("expose"))...)
+	allErrs = append(allErrs, in.Spec.AccessLogs.validate(path.Child("accessLogs"))...)
+	if err := in.Spec.MutualTLSMode.validate(path.Child("mutualTLSMode")); err != nil {
+		allErrs = append(allErrs, err)
+	}
+	if err := in.Spec.FailoverPolicy.validate(path.Child("failoverPolicy")); err != nil {
+		allErrs = append(allErrs, err)
+	}
+	if err := in.Spec.PrioritizeByLocality.validate(path.Child("prioritizeByLocality")); err != nil {
+		allErrs = append(allErrs, err)
+	}
+	for _, err := range []*field.Error{
+		requireConsulVersion(consulMeta, path.Child("accessLogs"), in.Spec.AccessLogs != nil, "1.15.0"),
+		requireConsulVersion(consulMeta, path.Child("mutualTLSMode"), in.Spec.MutualTLSMode != MutualTLSModeDefault, "1.16.0"),
+		requireConsulVersion(consulMeta, path.Child("failoverPolicy"), in.Spec.FailoverPolicy != nil, "1.17.0"),
+		requireConsulVersion(consulMeta, path.Child("prioritizeByLocality"), in.Spec.PrioritizeByLocality != nil, "1.17.0"),
+	} {
+		if err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
 	if len(allErrs) > 0 {
 		return apierrors.NewInvalid(
 			schema.GroupKind{Group: ConsulHashicorpGroup, Kind: ProxyDefaultsKubeKind},
@@ -237,3 +317,60 @@ func (in *ProxyDefaults) validateConfig(path *field.Path) *field.Error {
 	}
 	return nil
 }
+
+func (in *AccessLogs) toConsul() *capi.AccessLogsConfig {
+	if in == nil {
+		return nil
+	}
+	return &capi.AccessLogsConfig{
+		Enabled:             in.Enabled,
+		DisableListenerLogs: in.DisableListenerLogs,
+		JSONFormat:          in.JSONFormat,
+		Path:                in.Path,
+		TextFormat:          in.TextFormat,
+		Type:                capi.LogSinkType(in.Type),
+	}
+}
+
+func (in *AccessLogs) validate(path *field.Path) field.ErrorList {
+	if in == nil {
+		return nil
+	}
+
+	var errs field.ErrorList
+	switch in.Type {
+	case DefaultLogSinkType, StdErrLogSinkType, StdOutLogSinkType:
+		// OK
+	case FileLogSinkType:
+		if in.Path == "" {
+			errs = append(errs, field.Invalid(path.Child("path"), in.Path, "path must be specified when using file type access logs"))
+		}
+	default:
+		errs = append(errs, field.Invalid(path.Child("type"), in.Type, notInSliceMessage([]string{"file", "stderr", "stdout"})))
+	}
+
+	if in.JSONFormat != "" && in.TextFormat != "" {
+		errs = append(errs, field.Invalid(path.Child("textFormat"), in.TextFormat, "cannot specify both access log jsonFormat and textFormat"))
+	}
+
+	if in.Type != FileLogSinkType && in.Path != "" {
+		errs = append(errs, field.Invalid(path.Child("path"), in.Path, "path is only valid for file type access logs"))
+	}
+
+	if in.JSONFormat != "" {
+		var jsonFormat map[string]interface{}
+		if err := json.Unmarshal([]byte(in.JSONFormat), &jsonFormat); err != nil {
+			errs = append(errs, field.Invalid(path.Child("jsonFormat"), in.JSONFormat, fmt.Sprintf("invalid access log json: %s", err)))
+		}
+	}
+
+	return errs
+}
+
+func (in MutualTLSMode) validate(path *field.Path) *field.Error {
+	switch in {
+	case MutualTLSModeDefault, MutualTLSModeStrict, MutualTLSModePermissive:
+		return nil
+	}
+	return field.Invalid(path, in, notInSliceMessage([]string{"strict", "permissive"}))
+}
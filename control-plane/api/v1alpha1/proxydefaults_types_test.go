@@ -7,6 +7,7 @@ import (
 
 	"github.com/hashicorp/consul-k8s/control-plane/api/common"
 	capi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-version"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -71,6 +72,21 @@ func TestProxyDefaults_MatchesConsul(t *testing.T) {
 						OutboundListenerPort: 1000,
 						DialedDirectly:       true,
 					},
+					AccessLogs: &AccessLogs{
+						Enabled:             true,
+						DisableListenerLogs: true,
+						Type:                FileLogSinkType,
+						Path:                "/var/log/envoy.logs",
+						TextFormat:          "ITS WORKING %START_TIME%",
+					},
+					MutualTLSMode: MutualTLSModePermissive,
+					FailoverPolicy: &FailoverPolicy{
+						Mode:    "order-by-locality",
+						Regions: []string{"us-west-1", "us-west-2"},
+					},
+					PrioritizeByLocality: &PrioritizeByLocality{
+						Mode: "failover",
+					},
 				},
 			},
 			Theirs: &capi.ProxyConfigEntry{
@@ -103,6 +119,21 @@ func TestProxyDefaults_MatchesConsul(t *testing.T) {
 					OutboundListenerPort: 1000,
 					DialedDirectly:       true,
 				},
+				AccessLogs: &capi.AccessLogsConfig{
+					Enabled:             true,
+					DisableListenerLogs: true,
+					Type:                capi.FileLogSinkType,
+					Path:                "/var/log/envoy.logs",
+					TextFormat:          "ITS WORKING %START_TIME%",
+				},
+				MutualTLSMode: capi.MutualTLSModePermissive,
+				FailoverPolicy: &capi.ServiceResolverFailoverPolicy{
+					Mode:    "order-by-locality",
+					Regions: []string{"us-west-1", "us-west-2"},
+				},
+				PrioritizeByLocality: &capi.ServiceResolverPrioritizeByLocality{
+					Mode: "failover",
+				},
 			},
 			Matches: true,
 		},
@@ -236,6 +267,21 @@ func TestProxyDefaults_ToConsul(t *testing.T) {
 						OutboundListenerPort: 1000,
 						DialedDirectly:       true,
 					},
+					AccessLogs: &AccessLogs{
+						Enabled:             true,
+						DisableListenerLogs: true,
+						Type:                FileLogSinkType,
+						Path:                "/var/log/envoy.logs",
+						TextFormat:          "ITS WORKING %START_TIME%",
+					},
+					MutualTLSMode: MutualTLSModePermissive,
+					FailoverPolicy: &FailoverPolicy{
+						Mode:    "order-by-locality",
+						Regions: []string{"us-west-1", "us-west-2"},
+					},
+					PrioritizeByLocality: &PrioritizeByLocality{
+						Mode: "failover",
+					},
 				},
 			},
 			Exp: &capi.ProxyConfigEntry{
@@ -269,6 +315,21 @@ func TestProxyDefaults_ToConsul(t *testing.T) {
 					OutboundListenerPort: 1000,
 					DialedDirectly:       true,
 				},
+				AccessLogs: &capi.AccessLogsConfig{
+					Enabled:             true,
+					DisableListenerLogs: true,
+					Type:                capi.FileLogSinkType,
+					Path:                "/var/log/envoy.logs",
+					TextFormat:          "ITS WORKING %START_TIME%",
+				},
+				MutualTLSMode: capi.MutualTLSModePermissive,
+				FailoverPolicy: &capi.ServiceResolverFailoverPolicy{
+					Mode:    "order-by-locality",
+					Regions: []string{"us-west-1", "us-west-2"},
+				},
+				PrioritizeByLocality: &capi.ServiceResolverPrioritizeByLocality{
+					Mode: "failover",
+				},
 				Meta: map[string]string{
 					common.SourceKey:     common.SourceValue,
 					common.DatacenterKey: "datacenter",
@@ -366,6 +427,110 @@ func TestProxyDefaults_Validate(t *testing.T) {
 			},
 			"proxydefaults.consul.hashicorp.com \"global\" is invalid: spec.mode: Invalid value: \"transparent\": use the annotation `consul.hashicorp.com/transparent-proxy` to configure the Transparent Proxy Mode",
 		},
+		"accessLogs.type": {
+			&ProxyDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "global",
+				},
+				Spec: ProxyDefaultsSpec{
+					AccessLogs: &AccessLogs{
+						Type: "foo",
+					},
+				},
+			},
+			`proxydefaults.consul.hashicorp.com "global" is invalid: spec.accessLogs.type: Invalid value: "foo": must be one of "file", "stderr", "stdout"`,
+		},
+		"accessLogs.path missing": {
+			&ProxyDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "global",
+				},
+				Spec: ProxyDefaultsSpec{
+					AccessLogs: &AccessLogs{
+						Type: FileLogSinkType,
+					},
+				},
+			},
+			`proxydefaults.consul.hashicorp.com "global" is invalid: spec.accessLogs.path: Invalid value: "": path must be specified when using file type access logs`,
+		},
+		"accessLogs.path for wrong type": {
+			&ProxyDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "global",
+				},
+				Spec: ProxyDefaultsSpec{
+					AccessLogs: &AccessLogs{
+						Type: StdOutLogSinkType,
+						Path: "/var/log/envoy.logs",
+					},
+				},
+			},
+			`proxydefaults.consul.hashicorp.com "global" is invalid: spec.accessLogs.path: Invalid value: "/var/log/envoy.logs": path is only valid for file type access logs`,
+		},
+		"accessLogs.jsonFormat": {
+			&ProxyDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "global",
+				},
+				Spec: ProxyDefaultsSpec{
+					AccessLogs: &AccessLogs{
+						JSONFormat: "{ \"start_time\": \"%START_TIME%\"",
+					},
+				},
+			},
+			`proxydefaults.consul.hashicorp.com "global" is invalid: spec.accessLogs.jsonFormat: Invalid value: "{ \"start_time\": \"%START_TIME%\"": invalid access log json: unexpected end of JSON input`,
+		},
+		"accessLogs.textFormat": {
+			&ProxyDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "global",
+				},
+				Spec: ProxyDefaultsSpec{
+					AccessLogs: &AccessLogs{
+						JSONFormat: "{ \"start_time\": \"%START_TIME%\" }",
+						TextFormat: "START_TIME=%START_TIME%",
+					},
+				},
+			},
+			`proxydefaults.consul.hashicorp.com "global" is invalid: spec.accessLogs.textFormat: Invalid value: "START_TIME=%START_TIME%": cannot specify both access log jsonFormat and textFormat`,
+		},
+		"mutualTLSMode": {
+			&ProxyDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "global",
+				},
+				Spec: ProxyDefaultsSpec{
+					MutualTLSMode: "foo",
+				},
+			},
+			`proxydefaults.consul.hashicorp.com "global" is invalid: spec.mutualTLSMode: Invalid value: "foo": must be one of "strict", "permissive"`,
+		},
+		"failoverPolicy.mode": {
+			&ProxyDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "global",
+				},
+				Spec: ProxyDefaultsSpec{
+					FailoverPolicy: &FailoverPolicy{
+						Mode: "wrong-mode",
+					},
+				},
+			},
+			`proxydefaults.consul.hashicorp.com "global" is invalid: spec.failoverPolicy.mode: Invalid value: "wrong-mode": must be one of "", "sequential", "order-by-locality"`,
+		},
+		"prioritizeByLocality.mode": {
+			&ProxyDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "global",
+				},
+				Spec: ProxyDefaultsSpec{
+					PrioritizeByLocality: &PrioritizeByLocality{
+						Mode: "wrong-mode",
+					},
+				},
+			},
+			`proxydefaults.consul.hashicorp.com "global" is invalid: spec.prioritizeByLocality.mode: Invalid value: "wrong-mode": must be one of "", "none", "failover"`,
+		},
 		"multi-error": {
 			&ProxyDefaults{
 				ObjectMeta: metav1.ObjectMeta{
@@ -404,6 +569,55 @@ func TestProxyDefaults_Validate(t *testing.T) {
 	}
 }
 
+func TestProxyDefaults_ValidateConsulVersion(t *testing.T) {
+	spec := ProxyDefaultsSpec{
+		AccessLogs:           &AccessLogs{Enabled: true},
+		MutualTLSMode:        MutualTLSModeStrict,
+		FailoverPolicy:       &FailoverPolicy{Mode: "sequential"},
+		PrioritizeByLocality: &PrioritizeByLocality{Mode: "failover"},
+	}
+	cases := map[string]struct {
+		consulVersion  string
+		spec           ProxyDefaultsSpec
+		expectedErrMsg string
+	}{
+		"unknown version": {
+			spec: spec,
+		},
+		"new enough version": {
+			consulVersion: "1.17.0",
+			spec:          spec,
+		},
+		"old version without the fields": {
+			consulVersion: "1.11.4",
+			spec:          ProxyDefaultsSpec{MeshGateway: MeshGateway{Mode: "local"}},
+		},
+		"old version": {
+			consulVersion: "1.15.2",
+			spec:          spec,
+			expectedErrMsg: `proxydefaults.consul.hashicorp.com "global" is invalid: [` +
+				`spec.mutualTLSMode: Forbidden: requires Consul 1.16.0 or newer, but Consul is 1.15.2, ` +
+				`spec.failoverPolicy: Forbidden: requires Consul 1.17.0 or newer, but Consul is 1.15.2, ` +
+				`spec.prioritizeByLocality: Forbidden: requires Consul 1.17.0 or newer, but Consul is 1.15.2]`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var consulMeta common.ConsulMeta
+			if c.consulVersion != "" {
+				consulMeta.ConsulVersion = version.Must(version.NewVersion(c.consulVersion))
+			}
+			proxyDefaults := &ProxyDefaults{ObjectMeta: metav1.ObjectMeta{Name: common.Global}, Spec: c.spec}
+			err := proxyDefaults.Validate(consulMeta)
+			if c.expectedErrMsg != "" {
+				require.EqualError(t, err, c.expectedErrMsg)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestProxyDefaults_ValidateConfigValid(t *testing.T) {
 	cases := map[string]json.RawMessage{
 		"envoy_tracing_json":                     json.RawMessage(`{"envoy_tracing_json": "{\"http\":{\"name\":\"envoy.zipkin\",\"config\":{\"collector_cluster\":\"zipkin\",\"collector_endpoint\":\"/api/v1/spans\",\"shared_span_context\":false}}}"}`),
@@ -75,6 +75,9 @@ type ServiceDefaultsSpec struct {
 	// and per-upstream configuration overrides. Note that per-upstream configuration applies
 	// across all federated datacenters to the pairing of source and upstream destination services.
 	UpstreamConfig *Upstreams `json:"upstreamConfig,omitempty"`
+	// EnvoyExtensions are a list of extensions to modify Envoy proxy configuration.
+	// Their arguments are checked against the schema of each builtin extension. Requires Consul 1.15+.
+	EnvoyExtensions EnvoyExtensions `json:"envoyExtensions,omitempty"`
 }
 
 type Upstreams struct {
@@ -235,6 +238,7 @@ func (in *ServiceDefaults) ToConsul(datacenter string) capi.ConfigEntry {
 		ExternalSNI:      in.Spec.ExternalSNI,
 		TransparentProxy: in.Spec.TransparentProxy.toConsul(),
 		UpstreamConfig:   in.Spec.UpstreamConfig.toConsul(),
+		EnvoyExtensions:  in.Spec.EnvoyExtensions.toConsul(),
 		Meta:             meta(datacenter),
 	}
 }
@@ -260,6 +264,10 @@ func (in *ServiceDefaults) Validate(consulMeta common.ConsulMeta) error {
 	}
 	allErrs = append(allErrs, in.Spec.UpstreamConfig.validate(path.Child("upstreamConfig"), consulMeta.PartitionsEnabled)...)
 	allErrs = append(allErrs, in.Spec.Expose.validate(path.Child("expose"))...)
+	allErrs = append(allErrs, in.Spec.EnvoyExtensions.validate(consulMeta, path.Child("envoyExtensions"))...)
+	if err := requireConsulVersion(consulMeta, path.Child("envoyExtensions"), len(in.Spec.EnvoyExtensions) > 0, "1.15.0"); err != nil {
+		allErrs = append(allErrs, err)
+	}
 
 	if len(allErrs) > 0 {
 		return apierrors.NewInvalid(
@@ -1,11 +1,13 @@
 package v1alpha1
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
 	"github.com/hashicorp/consul-k8s/control-plane/api/common"
 	capi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-version"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -241,6 +243,51 @@ func TestServiceDefaults_ToConsul(t *testing.T) {
 				},
 			},
 		},
+		"envoy extensions": {
+			&ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: ServiceDefaultsSpec{
+					EnvoyExtensions: EnvoyExtensions{
+						{
+							Name:          "builtin/lua",
+							Required:      true,
+							Arguments:     json.RawMessage(`{"ProxyType": "connect-proxy", "Listener": "inbound", "Script": "function envoy_on_request(h) end"}`),
+							ConsulVersion: ">= 1.15.0",
+							EnvoyVersion:  ">= 1.25.0",
+						},
+						{
+							Name: "builtin/aws/lambda",
+						},
+					},
+				},
+			},
+			&capi.ServiceConfigEntry{
+				Name: "foo",
+				Kind: capi.ServiceDefaults,
+				EnvoyExtensions: []capi.EnvoyExtension{
+					{
+						Name:     "builtin/lua",
+						Required: true,
+						Arguments: map[string]interface{}{
+							"ProxyType": "connect-proxy",
+							"Listener":  "inbound",
+							"Script":    "function envoy_on_request(h) end",
+						},
+						ConsulVersion: ">= 1.15.0",
+						EnvoyVersion:  ">= 1.25.0",
+					},
+					{
+						Name: "builtin/aws/lambda",
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+		},
 	}
 
 	for name, testCase := range cases {
@@ -550,6 +597,63 @@ func TestServiceDefaults_MatchesConsul(t *testing.T) {
 			},
 			matches: true,
 		},
+		"envoy extensions with the same arguments match": {
+			internal: &ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-test-service",
+				},
+				Spec: ServiceDefaultsSpec{
+					EnvoyExtensions: EnvoyExtensions{
+						{
+							Name:      "builtin/aws/lambda",
+							Arguments: json.RawMessage(`{"ARN": "arn:aws:lambda:us-east-1:111111111111:function:lambda", "PayloadPassthrough": true}`),
+						},
+					},
+				},
+			},
+			consul: &capi.ServiceConfigEntry{
+				Kind: capi.ServiceDefaults,
+				Name: "my-test-service",
+				EnvoyExtensions: []capi.EnvoyExtension{
+					{
+						Name: "builtin/aws/lambda",
+						Arguments: map[string]interface{}{
+							"ARN":                "arn:aws:lambda:us-east-1:111111111111:function:lambda",
+							"PayloadPassthrough": true,
+						},
+					},
+				},
+			},
+			matches: true,
+		},
+		"envoy extensions with different arguments do not match": {
+			internal: &ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-test-service",
+				},
+				Spec: ServiceDefaultsSpec{
+					EnvoyExtensions: EnvoyExtensions{
+						{
+							Name:      "builtin/aws/lambda",
+							Arguments: json.RawMessage(`{"ARN": "arn:aws:lambda:us-east-1:111111111111:function:lambda"}`),
+						},
+					},
+				},
+			},
+			consul: &capi.ServiceConfigEntry{
+				Kind: capi.ServiceDefaults,
+				Name: "my-test-service",
+				EnvoyExtensions: []capi.EnvoyExtension{
+					{
+						Name: "builtin/aws/lambda",
+						Arguments: map[string]interface{}{
+							"ARN": "arn:aws:lambda:us-east-1:111111111111:function:other",
+						},
+					},
+				},
+			},
+			matches: false,
+		},
 	}
 
 	for name, testCase := range cases {
@@ -816,6 +920,227 @@ func TestServiceDefaults_Validate(t *testing.T) {
 	}
 }
 
+func TestServiceDefaults_ValidateEnvoyExtensions(t *testing.T) {
+	cases := map[string]struct {
+		extension      EnvoyExtension
+		expectedErrMsg string
+	}{
+		"lua": {
+			extension: EnvoyExtension{
+				Name:      "builtin/lua",
+				Arguments: json.RawMessage(`{"ProxyType": "connect-proxy", "Listener": "outbound", "Script": "function envoy_on_request(h) end"}`),
+			},
+		},
+		"lua without arguments": {
+			extension: EnvoyExtension{
+				Name: "builtin/lua",
+			},
+			expectedErrMsg: `[spec.envoyExtensions[0].arguments.Listener: Invalid value: "": must be one of "inbound", "outbound", ` +
+				`spec.envoyExtensions[0].arguments.Script: Required value: a Lua script must be set]`,
+		},
+		"aws lambda": {
+			extension: EnvoyExtension{
+				Name:      "builtin/aws/lambda",
+				Arguments: json.RawMessage(`{"arn": "arn:aws:lambda:us-east-1:111111111111:function:lambda", "invocationMode": "asynchronous"}`),
+			},
+		},
+		"aws lambda with wrong types": {
+			extension: EnvoyExtension{
+				Name:      "builtin/aws/lambda",
+				Arguments: json.RawMessage(`{"ARN": "arn:aws:lambda:us-east-1:111111111111:function:lambda", "PayloadPassthrough": "yes"}`),
+			},
+			expectedErrMsg: `spec.envoyExtensions[0].arguments: Invalid value: "{\"ARN\": \"arn:aws:lambda:us-east-1:111111111111:function:lambda\", \"PayloadPassthrough\": \"yes\"}": ` +
+				`invalid arguments for builtin/aws/lambda: json: cannot unmarshal string into Go struct field awsLambdaArguments.PayloadPassthrough of type bool`,
+		},
+		"wasm with remote code": {
+			extension: EnvoyExtension{
+				Name: "builtin/wasm",
+				Arguments: json.RawMessage(`{
+  "Protocol": "http",
+  "ListenerType": "inbound",
+  "PluginConfig": {
+    "VmConfig": {
+      "Code": {
+        "Remote": {
+          "HttpURI": {"Target": {"URI": "https://wasm.example.com/plugin.wasm", "Timeout": "5s"}},
+          "SHA256": "d05d88b0ce8a8f1d5176481e0af3ae5c65ed82cbfb8c61506c5354b076078545"
+        }
+      }
+    },
+    "Configuration": "{\"header\": \"x-plugin\"}"
+  }
+}`),
+			},
+		},
+		"wasm with local and remote code": {
+			extension: EnvoyExtension{
+				Name: "builtin/wasm",
+				Arguments: json.RawMessage(`{
+  "Protocol": "udp",
+  "ListenerType": "inbound",
+  "PluginConfig": {"VmConfig": {"Runtime": "js", "Code": {"Local": {"Filename": "plugin.wasm"}, "Remote": {"SHA256": "abc"}}}}
+}`),
+			},
+			expectedErrMsg: `[spec.envoyExtensions[0].arguments.Protocol: Invalid value: "udp": must be one of "", "tcp", "http", ` +
+				`spec.envoyExtensions[0].arguments.PluginConfig.VmConfig.Runtime: Invalid value: "js": must be one of "", "v8", "wamr", "wavm", "wasmtime", ` +
+				`spec.envoyExtensions[0].arguments.PluginConfig.VmConfig.Code: Invalid value: "": exactly one of Local or Remote must be set]`,
+		},
+		"wasm with a remote plugin without checksum": {
+			extension: EnvoyExtension{
+				Name:      "builtin/wasm",
+				Arguments: json.RawMessage(`{"ListenerType": "outbound", "PluginConfig": {"VmConfig": {"Code": {"Remote": {"HttpURI": {"Target": {"Service": {}}}}}}}}`),
+			},
+			expectedErrMsg: `[spec.envoyExtensions[0].arguments.PluginConfig.VmConfig.Code.Remote.HttpURI.Target.Service.Name: Required value: the name of the service must be set, ` +
+				`spec.envoyExtensions[0].arguments.PluginConfig.VmConfig.Code.Remote.SHA256: Required value: the checksum of the plugin must be set]`,
+		},
+		"ext-authz": {
+			extension: EnvoyExtension{
+				Name:      "builtin/ext-authz",
+				Required:  true,
+				Arguments: json.RawMessage(`{"ProxyType": "connect-proxy", "Config": {"GrpcService": {"Target": {"Service": {"Name": "authz"}}}}}`),
+			},
+		},
+		"ext-authz with an invalid timeout": {
+			extension: EnvoyExtension{
+				Name:      "builtin/ext-authz",
+				Arguments: json.RawMessage(`{"Config": {"HttpService": {"Target": {"URI": "127.0.0.1:9191", "Timeout": "soon"}}}}`),
+			},
+			expectedErrMsg: `spec.envoyExtensions[0].arguments: Invalid value: "{\"Config\": {\"HttpService\": {\"Target\": {\"URI\": \"127.0.0.1:9191\", \"Timeout\": \"soon\"}}}}": ` +
+				`invalid arguments for builtin/ext-authz: time: invalid duration "soon"`,
+		},
+		"ext-authz with both services": {
+			extension: EnvoyExtension{
+				Name:      "builtin/ext-authz",
+				Arguments: json.RawMessage(`{"Config": {"GrpcService": {"Target": {"URI": "127.0.0.1:9191"}}, "HttpService": {"Target": {"URI": "127.0.0.1:9192"}}}}`),
+			},
+			expectedErrMsg: `spec.envoyExtensions[0].arguments.Config: Invalid value: "": exactly one of GrpcService or HttpService must be set`,
+		},
+		"ext-authz without a service": {
+			extension: EnvoyExtension{
+				Name:      "builtin/ext-authz",
+				Arguments: json.RawMessage(`{"ListenerType": "outbound", "Config": {}}`),
+			},
+			expectedErrMsg: `[spec.envoyExtensions[0].arguments.ListenerType: Invalid value: "outbound": must be one of "inbound", ` +
+				`spec.envoyExtensions[0].arguments.Config: Invalid value: "": exactly one of GrpcService or HttpService must be set]`,
+		},
+		"otel access logging": {
+			extension: EnvoyExtension{
+				Name: "builtin/otel-access-logging",
+				Arguments: json.RawMessage(`{
+  "ListenerType": "inbound",
+  "Config": {"LogName": "web", "GrpcService": {"Target": {"Service": {"Name": "otel-collector"}}}, "BufferFlushInterval": "1s", "BufferSizeBytes": 16384}
+}`),
+			},
+		},
+		"otel access logging without a collector": {
+			extension: EnvoyExtension{
+				Name:      "builtin/otel-access-logging",
+				Arguments: json.RawMessage(`{"ListenerType": "inbound", "Config": {"GrpcService": {"Target": {}}}}`),
+			},
+			expectedErrMsg: `spec.envoyExtensions[0].arguments.Config.GrpcService.Target: Invalid value: "": exactly one of Service or URI must be set`,
+		},
+		"property override": {
+			extension: EnvoyExtension{
+				Name: "builtin/property-override",
+				Arguments: json.RawMessage(`{
+  "Patches": [{"ResourceFilter": {"ResourceType": "cluster", "TrafficDirection": "outbound"}, "Op": "add", "Path": "/upstream_connection_options/tcp_keepalive/keepalive_probes", "Value": 5}]
+}`),
+			},
+		},
+		"property override with invalid patches": {
+			extension: EnvoyExtension{
+				Name:      "builtin/property-override",
+				Arguments: json.RawMessage(`{"Patches": [{"ResourceFilter": {"ResourceType": "secret"}, "Op": "add", "Path": "connect_timeout"}]}`),
+			},
+			expectedErrMsg: `[spec.envoyExtensions[0].arguments.Patches[0].ResourceFilter.ResourceType: Invalid value: "secret": must be one of "cluster", "cluster-load-assignment", "listener", "route", ` +
+				`spec.envoyExtensions[0].arguments.Patches[0].Path: Invalid value: "connect_timeout": must begin with a '/', ` +
+				`spec.envoyExtensions[0].arguments.Patches[0].Value: Required value: a value must be set for an add patch]`,
+		},
+		"arguments that are not an object": {
+			extension: EnvoyExtension{
+				Name:      "builtin/lua",
+				Arguments: json.RawMessage(`["inbound"]`),
+			},
+			expectedErrMsg: `spec.envoyExtensions[0].arguments: Invalid value: "[\"inbound\"]": must be valid map value: json: cannot unmarshal array into Go value of type map[string]interface {}`,
+		},
+		"unknown extension and invalid version constraints": {
+			extension: EnvoyExtension{
+				Name:          "custom/extension",
+				ConsulVersion: "1.16",
+				EnvoyVersion:  "newest",
+			},
+			expectedErrMsg: `[spec.envoyExtensions[0].envoyVersion: Invalid value: "newest": must be a version constraint: Malformed constraint: newest, ` +
+				`spec.envoyExtensions[0].name: Invalid value: "custom/extension": must be one of "builtin/aws/lambda", "builtin/ext-authz", "builtin/lua", "builtin/otel-access-logging", "builtin/property-override", "builtin/wasm"]`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			serviceDefaults := &ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-service"},
+				Spec:       ServiceDefaultsSpec{EnvoyExtensions: EnvoyExtensions{c.extension}},
+			}
+			err := serviceDefaults.Validate(common.ConsulMeta{})
+			if c.expectedErrMsg != "" {
+				require.EqualError(t, err, `servicedefaults.consul.hashicorp.com "my-service" is invalid: `+c.expectedErrMsg)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestServiceDefaults_ValidateConsulVersion(t *testing.T) {
+	spec := ServiceDefaultsSpec{
+		EnvoyExtensions: EnvoyExtensions{
+			{
+				Name:      "builtin/aws/lambda",
+				Arguments: json.RawMessage(`{"ARN": "arn:aws:lambda:us-east-1:111111111111:function:lambda"}`),
+			},
+			{
+				Name:      "builtin/ext-authz",
+				Arguments: json.RawMessage(`{"Config": {"HttpService": {"Target": {"URI": "127.0.0.1:9191"}}}}`),
+			},
+		},
+	}
+	cases := map[string]struct {
+		consulVersion  string
+		expectedErrMsg string
+	}{
+		"unknown version": {},
+		"new enough version": {
+			consulVersion: "1.16.0",
+		},
+		"version without some extensions": {
+			consulVersion: "1.15.2",
+			expectedErrMsg: `servicedefaults.consul.hashicorp.com "my-service" is invalid: ` +
+				`spec.envoyExtensions[1].name: Forbidden: requires Consul 1.16.0 or newer, but Consul is 1.15.2`,
+		},
+		"version without extensions": {
+			consulVersion: "1.14.4",
+			expectedErrMsg: `servicedefaults.consul.hashicorp.com "my-service" is invalid: [` +
+				`spec.envoyExtensions[0].name: Forbidden: requires Consul 1.15.0 or newer, but Consul is 1.14.4, ` +
+				`spec.envoyExtensions[1].name: Forbidden: requires Consul 1.16.0 or newer, but Consul is 1.14.4, ` +
+				`spec.envoyExtensions: Forbidden: requires Consul 1.15.0 or newer, but Consul is 1.14.4]`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var consulMeta common.ConsulMeta
+			if c.consulVersion != "" {
+				consulMeta.ConsulVersion = version.Must(version.NewVersion(c.consulVersion))
+			}
+			serviceDefaults := &ServiceDefaults{ObjectMeta: metav1.ObjectMeta{Name: "my-service"}, Spec: spec}
+			err := serviceDefaults.Validate(consulMeta)
+			if c.expectedErrMsg != "" {
+				require.EqualError(t, err, c.expectedErrMsg)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
 func proxyModeRef(mode string) *ProxyMode {
 	proxyMode := ProxyMode(mode)
 	return &proxyMode
@@ -55,6 +55,9 @@ type ServiceIntentionsSpec struct {
 	// The order of this list does not matter, but out of convenience Consul will always store this
 	// reverse sorted by intention precedence, as that is the order that they will be evaluated at enforcement time.
 	Sources SourceIntentions `json:"sources,omitempty"`
+	// JWT specifies the JSON Web Token requirements that requests to the destination
+	// must satisfy. Requires Consul 1.16+.
+	JWT *IntentionJWTRequirement `json:"jwt,omitempty"`
 }
 
 type Destination struct {
@@ -100,6 +103,30 @@ type IntentionPermission struct {
 	Action IntentionAction `json:"action,omitempty"`
 	// HTTP is a set of HTTP-specific authorization criteria.
 	HTTP *IntentionHTTPPermission `json:"http,omitempty"`
+	// JWT specifies the JSON Web Token requirements that requests must satisfy for the
+	// permission to match. Requires Consul 1.16+.
+	JWT *IntentionJWTRequirement `json:"jwt,omitempty"`
+}
+
+type IntentionJWTRequirement struct {
+	// Providers is a list of providers to consider when verifying a JWT.
+	Providers []*IntentionJWTProvider `json:"providers,omitempty"`
+}
+
+type IntentionJWTProvider struct {
+	// Name is the name of the JWT provider. There MUST be a corresponding
+	// JWTProvider resource.
+	Name string `json:"name,omitempty"`
+	// VerifyClaims is a list of additional claims to verify in a JWT's payload.
+	VerifyClaims []*IntentionJWTClaimVerification `json:"verifyClaims,omitempty"`
+}
+
+type IntentionJWTClaimVerification struct {
+	// Path is the path to the claim in the token JSON.
+	Path []string `json:"path,omitempty"`
+	// Value is the expected value at the given path. If the claim at the path
+	// is a list, the value must be contained in it, otherwise it must match it.
+	Value string `json:"value,omitempty"`
 }
 
 type IntentionHTTPPermission struct {
@@ -218,6 +245,7 @@ func (in *ServiceIntentions) ToConsul(datacenter string) api.ConfigEntry {
 		Name:      in.Spec.Destination.Name,
 		Namespace: in.Spec.Destination.Namespace,
 		Sources:   in.Spec.Sources.toConsul(),
+		JWT:       in.Spec.JWT.toConsul(),
 		Meta:      meta(datacenter),
 	}
 }
@@ -267,6 +295,15 @@ func (in *ServiceIntentions) Validate(consulMeta common.ConsulMeta) error {
 		} else {
 			errs = append(errs, source.Permissions.validate(path.Child("sources").Index(i))...)
 		}
+		for j, permission := range source.Permissions {
+			if err := requireConsulVersion(consulMeta, path.Child("sources").Index(i).Child("permissions").Index(j).Child("jwt"), permission.JWT != nil, "1.16.0"); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	errs = append(errs, in.Spec.JWT.validate(path.Child("jwt"))...)
+	if err := requireConsulVersion(consulMeta, path.Child("jwt"), in.Spec.JWT != nil, "1.16.0"); err != nil {
+		errs = append(errs, err)
 	}
 
 	errs = append(errs, in.validateNamespaces(consulMeta.NamespacesEnabled)...)
@@ -288,7 +325,7 @@ func (in *ServiceIntentions) DefaultNamespaceFields(consulMeta common.ConsulMeta
 	// making API calls (because namespace fields can't be set in OSS).
 	if consulMeta.NamespacesEnabled {
 		// Default to the current namespace (i.e. the namespace of the config entry).
-		namespace := namespaces.ConsulNamespace(in.Namespace, consulMeta.NamespacesEnabled, consulMeta.DestinationNamespace, consulMeta.Mirroring, consulMeta.Prefix)
+		namespace := namespaces.ConsulNamespace(in.Namespace, consulMeta.NamespacesEnabled, consulMeta.DestinationNamespace, consulMeta.Mirroring, consulMeta.Prefix, consulMeta.MirroringRules)
 		if in.Spec.Destination.Namespace == "" {
 			in.Spec.Destination.Namespace = namespace
 		}
@@ -327,6 +364,7 @@ func (in IntentionPermissions) toConsul() []*capi.IntentionPermission {
 		consulIntentionPermissions = append(consulIntentionPermissions, &capi.IntentionPermission{
 			Action: permission.Action.toConsul(),
 			HTTP:   permission.HTTP.toConsul(),
+			JWT:    permission.JWT.toConsul(),
 		})
 	}
 	return consulIntentionPermissions
@@ -371,6 +409,53 @@ func (in IntentionPermissions) validate(path *field.Path) field.ErrorList {
 		if permission.HTTP != nil {
 			errs = append(errs, permission.HTTP.validate(path.Child("permissions").Index(i))...)
 		}
+		errs = append(errs, permission.JWT.validate(path.Child("permissions").Index(i).Child("jwt"))...)
+	}
+	return errs
+}
+
+func (in *IntentionJWTRequirement) toConsul() *capi.IntentionJWTRequirement {
+	if in == nil {
+		return nil
+	}
+	var providers []*capi.IntentionJWTProvider
+	for _, provider := range in.Providers {
+		var claims []*capi.IntentionJWTClaimVerification
+		for _, claim := range provider.VerifyClaims {
+			claims = append(claims, &capi.IntentionJWTClaimVerification{
+				Path:  claim.Path,
+				Value: claim.Value,
+			})
+		}
+		providers = append(providers, &capi.IntentionJWTProvider{
+			Name:         provider.Name,
+			VerifyClaims: claims,
+		})
+	}
+	return &capi.IntentionJWTRequirement{
+		Providers: providers,
+	}
+}
+
+func (in *IntentionJWTRequirement) validate(path *field.Path) field.ErrorList {
+	if in == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	if len(in.Providers) == 0 {
+		errs = append(errs, field.Required(path.Child("providers"), "at least one provider must be specified"))
+	}
+	for i, provider := range in.Providers {
+		providerPath := path.Child("providers").Index(i)
+		if provider == nil || provider.Name == "" {
+			errs = append(errs, field.Required(providerPath.Child("name"), "the name of a JWTProvider must be specified"))
+			continue
+		}
+		for j, claim := range provider.VerifyClaims {
+			if claim == nil || len(claim.Path) == 0 {
+				errs = append(errs, field.Required(providerPath.Child("verifyClaims").Index(j).Child("path"), "the path of the claim must be specified"))
+			}
+		}
 	}
 	return errs
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/hashicorp/consul-k8s/control-plane/api/common"
 	capi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-version"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -293,11 +294,26 @@ func TestServiceIntentions_ToConsul(t *testing.T) {
 											"PUT",
 										},
 									},
+									JWT: &IntentionJWTRequirement{
+										Providers: []*IntentionJWTProvider{
+											{Name: "auth0"},
+										},
+									},
 								},
 							},
 							Description: "an L7 config",
 						},
 					},
+					JWT: &IntentionJWTRequirement{
+						Providers: []*IntentionJWTProvider{
+							{
+								Name: "okta",
+								VerifyClaims: []*IntentionJWTClaimVerification{
+									{Path: []string{"perms", "role"}, Value: "admin"},
+								},
+							},
+						},
+					},
 				},
 			},
 			Exp: &capi.ServiceIntentionsConfigEntry{
@@ -346,11 +362,26 @@ func TestServiceIntentions_ToConsul(t *testing.T) {
 										"PUT",
 									},
 								},
+								JWT: &capi.IntentionJWTRequirement{
+									Providers: []*capi.IntentionJWTProvider{
+										{Name: "auth0"},
+									},
+								},
 							},
 						},
 						Description: "an L7 config",
 					},
 				},
+				JWT: &capi.IntentionJWTRequirement{
+					Providers: []*capi.IntentionJWTProvider{
+						{
+							Name: "okta",
+							VerifyClaims: []*capi.IntentionJWTClaimVerification{
+								{Path: []string{"perms", "role"}, Value: "admin"},
+							},
+						},
+					},
+				},
 				Meta: map[string]string{
 					common.SourceKey:     common.SourceValue,
 					common.DatacenterKey: "datacenter",
@@ -1237,6 +1268,45 @@ func TestServiceIntentions_Validate(t *testing.T) {
 				`spec.sources[2].namespace: Invalid value: "namespace-d": Consul Enterprise namespaces must be enabled to set source.namespace`,
 			},
 		},
+		"invalid jwt": {
+			input: &ServiceIntentions{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "does-not-matter",
+				},
+				Spec: ServiceIntentionsSpec{
+					Destination: Destination{
+						Name: "dest-service",
+					},
+					Sources: SourceIntentions{
+						{
+							Name: "web",
+							Permissions: IntentionPermissions{
+								{
+									Action: "allow",
+									JWT:    &IntentionJWTRequirement{},
+								},
+							},
+						},
+					},
+					JWT: &IntentionJWTRequirement{
+						Providers: []*IntentionJWTProvider{
+							{},
+							{
+								Name: "okta",
+								VerifyClaims: []*IntentionJWTClaimVerification{
+									{Value: "admin"},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.sources[0].permissions[0].jwt.providers: Required value: at least one provider must be specified`,
+				`spec.jwt.providers[0].name: Required value: the name of a JWTProvider must be specified`,
+				`spec.jwt.providers[1].verifyClaims[0].path: Required value: the path of the claim must be specified`,
+			},
+		},
 		"partitions disabled: single source partition specified": {
 			input: &ServiceIntentions{
 				ObjectMeta: metav1.ObjectMeta{
@@ -1326,3 +1396,49 @@ func TestServiceIntentions_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestServiceIntentions_ValidateConsulVersion(t *testing.T) {
+	jwt := &IntentionJWTRequirement{Providers: []*IntentionJWTProvider{{Name: "okta"}}}
+	cases := map[string]struct {
+		consulVersion  string
+		expectedErrMsg string
+	}{
+		"unknown version": {},
+		"new enough version": {
+			consulVersion: "1.16.0",
+		},
+		"version without jwt": {
+			consulVersion: "1.15.4",
+			expectedErrMsg: `serviceintentions.consul.hashicorp.com "does-not-matter" is invalid: [` +
+				`spec.sources[0].permissions[0].jwt: Forbidden: requires Consul 1.16.0 or newer, but Consul is 1.15.4, ` +
+				`spec.jwt: Forbidden: requires Consul 1.16.0 or newer, but Consul is 1.15.4]`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var consulMeta common.ConsulMeta
+			if c.consulVersion != "" {
+				consulMeta.ConsulVersion = version.Must(version.NewVersion(c.consulVersion))
+			}
+			intentions := &ServiceIntentions{
+				ObjectMeta: metav1.ObjectMeta{Name: "does-not-matter"},
+				Spec: ServiceIntentionsSpec{
+					Destination: Destination{Name: "dest-service"},
+					Sources: SourceIntentions{
+						{
+							Name:        "web",
+							Permissions: IntentionPermissions{{Action: "allow", JWT: jwt}},
+						},
+					},
+					JWT: jwt,
+				},
+			}
+			err := intentions.Validate(consulMeta)
+			if c.expectedErrMsg != "" {
+				require.EqualError(t, err, c.expectedErrMsg)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -61,6 +61,25 @@ type MeshGateway struct {
 
 type ProxyMode string
 
+// FailoverPolicy specifies the exact mechanism used for failover.
+type FailoverPolicy struct {
+	// Mode specifies the type of failover that will be performed. Valid values are
+	// "sequential", "" (equivalent to "sequential") and "order-by-locality".
+	Mode string `json:"mode,omitempty"`
+	// Regions is the ordered list of the regions of the failover targets.
+	// Valid values can be "us-west-1", "us-west-2", and so on.
+	Regions []string `json:"regions,omitempty"`
+}
+
+// PrioritizeByLocality controls whether the locality of services within the
+// local partition will be used to prioritize connectivity.
+type PrioritizeByLocality struct {
+	// Mode specifies the type of prioritization that will be performed
+	// when selecting nodes in the local partition.
+	// Valid values are: "" (default "none"), "none", and "failover".
+	Mode string `json:"mode,omitempty"`
+}
+
 // HTTPHeaderModifiers is a set of rules for HTTP header modification that
 // should be performed by proxies as the request passes through them. It can
 // operate on either request or response headers depending on the context in
@@ -165,6 +184,47 @@ func (in *ProxyMode) validate(path *field.Path) *field.Error {
 	return nil
 }
 
+func (in *FailoverPolicy) toConsul() *capi.ServiceResolverFailoverPolicy {
+	if in == nil {
+		return nil
+	}
+	return &capi.ServiceResolverFailoverPolicy{
+		Mode:    in.Mode,
+		Regions: in.Regions,
+	}
+}
+
+func (in *FailoverPolicy) validate(path *field.Path) *field.Error {
+	if in == nil {
+		return nil
+	}
+	modes := []string{"", "sequential", "order-by-locality"}
+	if !sliceContains(modes, in.Mode) {
+		return field.Invalid(path.Child("mode"), in.Mode, notInSliceMessage(modes))
+	}
+	return nil
+}
+
+func (in *PrioritizeByLocality) toConsul() *capi.ServiceResolverPrioritizeByLocality {
+	if in == nil {
+		return nil
+	}
+	return &capi.ServiceResolverPrioritizeByLocality{
+		Mode: in.Mode,
+	}
+}
+
+func (in *PrioritizeByLocality) validate(path *field.Path) *field.Error {
+	if in == nil {
+		return nil
+	}
+	modes := []string{"", "none", "failover"}
+	if !sliceContains(modes, in.Mode) {
+		return field.Invalid(path.Child("mode"), in.Mode, notInSliceMessage(modes))
+	}
+	return nil
+}
+
 func (in *HTTPHeaderModifiers) toConsul() *capi.HTTPHeaderModifiers {
 	if in == nil {
 		return nil
@@ -176,6 +236,15 @@ func (in *HTTPHeaderModifiers) toConsul() *capi.HTTPHeaderModifiers {
 	}
 }
 
+// requireConsulVersion returns an error for the field if it is set and the Consul version
+// is known to be older than minVersion, which the field needs.
+func requireConsulVersion(consulMeta common.ConsulMeta, path *field.Path, set bool, minVersion string) *field.Error {
+	if !set || consulMeta.SupportsConsulVersion(minVersion) {
+		return nil
+	}
+	return field.Forbidden(path, fmt.Sprintf("requires Consul %s or newer, but Consul is %s", minVersion, consulMeta.ConsulVersion))
+}
+
 func notInSliceMessage(slice []string) string {
 	return fmt.Sprintf(`must be one of "%s"`, strings.Join(slice, `", "`))
 }
@@ -0,0 +1,209 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	SnapshotScheduleKubeKind = "snapshotschedule"
+
+	// DefaultSnapshotRetain is the number of snapshots kept unless set.
+	DefaultSnapshotRetain = 30
+	// DefaultSnapshotFailureThreshold is the number of consecutive failed
+	// snapshots after which a warning event is recorded unless set.
+	DefaultSnapshotFailureThreshold = 3
+)
+
+func init() {
+	SchemeBuilder.Register(&SnapshotSchedule{}, &SnapshotScheduleList{})
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// SnapshotSchedule is the Schema for the snapshotschedules API. It takes
+// snapshots of the Consul servers with the snapshot agent at a regular
+// interval and saves them to a destination.
+// +kubebuilder:printcolumn:name="Interval",type="string",JSONPath=".spec.interval",description="How often a snapshot is taken"
+// +kubebuilder:printcolumn:name="Last Success",type="date",JSONPath=".status.lastSuccessTime",description="The last time a snapshot was saved"
+// +kubebuilder:printcolumn:name="Last Failure",type="date",JSONPath=".status.lastFailureTime",description="The last time a snapshot failed"
+// +kubebuilder:printcolumn:name="Failures",type="integer",JSONPath=".status.consecutiveFailures",description="The number of snapshots that failed since the last success"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="The age of the resource"
+type SnapshotSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SnapshotScheduleSpec   `json:"spec,omitempty"`
+	Status SnapshotScheduleStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SnapshotScheduleList contains a list of SnapshotSchedule.
+type SnapshotScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SnapshotSchedule `json:"items"`
+}
+
+// SnapshotScheduleSpec defines the desired state of SnapshotSchedule.
+type SnapshotScheduleSpec struct {
+	// Interval is how often a snapshot is taken, e.g. "1h".
+	Interval metav1.Duration `json:"interval"`
+	// Retain is the number of snapshots kept at the destination. Older
+	// snapshots are deleted. Defaults to 30.
+	Retain int `json:"retain,omitempty"`
+	// Destination is where the snapshots are saved.
+	Destination SnapshotDestination `json:"destination"`
+	// FailureThreshold is the number of consecutive failed snapshots after
+	// which a warning event is recorded for each further failure.
+	// Defaults to 3.
+	FailureThreshold int `json:"failureThreshold,omitempty"`
+}
+
+// SnapshotDestination is where snapshots are saved. Exactly one of Local and
+// S3 must be set.
+type SnapshotDestination struct {
+	// Local saves the snapshots to a persistent volume.
+	Local *LocalSnapshotDestination `json:"local,omitempty"`
+	// S3 saves the snapshots to an AWS S3 bucket.
+	S3 *S3SnapshotDestination `json:"s3,omitempty"`
+}
+
+// LocalSnapshotDestination saves snapshots to a persistent volume.
+type LocalSnapshotDestination struct {
+	// ClaimName is the name of the PersistentVolumeClaim of the volume.
+	ClaimName string `json:"claimName"`
+}
+
+// S3SnapshotDestination saves snapshots to an AWS S3 bucket.
+type S3SnapshotDestination struct {
+	// Bucket is the name of the bucket.
+	Bucket string `json:"bucket"`
+	// Region is the region of the bucket.
+	Region string `json:"region"`
+	// KeyPrefix is the prefix of the keys of the snapshots in the bucket.
+	// Defaults to "consul-snapshot".
+	KeyPrefix string `json:"keyPrefix,omitempty"`
+	// Endpoint is the endpoint of an S3 compatible storage, if the bucket
+	// isn't in AWS S3.
+	Endpoint string `json:"endpoint,omitempty"`
+	// CredentialsSecret is the name of a Secret whose keys are set as
+	// environment variables of the snapshot agent, e.g. AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY. If it is not set, the agent uses the credentials
+	// of its environment, such as the IAM role of its service account.
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+}
+
+// SnapshotScheduleStatus defines the observed state of SnapshotSchedule.
+type SnapshotScheduleStatus struct {
+	// Conditions indicate the latest available observations of the schedule.
+	// The Synced condition is true once the snapshot jobs are scheduled.
+	// +optional
+	Conditions Conditions `json:"conditions,omitempty"`
+	// LastSuccessTime is the last time a snapshot was saved.
+	// +optional
+	LastSuccessTime *metav1.Time `json:"lastSuccessTime,omitempty"`
+	// LastFailureTime is the last time a snapshot failed.
+	// +optional
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+	// LastFailureMessage describes the last failed snapshot.
+	// +optional
+	LastFailureMessage string `json:"lastFailureMessage,omitempty"`
+	// ConsecutiveFailures is the number of snapshots that failed since the
+	// last one that was saved.
+	// +optional
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+}
+
+func (in *SnapshotSchedule) KubeKind() string {
+	return SnapshotScheduleKubeKind
+}
+
+func (in *SnapshotSchedule) KubernetesName() string {
+	return in.ObjectMeta.Name
+}
+
+func (in *SnapshotSchedule) SyncedConditionStatus() corev1.ConditionStatus {
+	for _, cond := range in.Status.Conditions {
+		if cond.Type == ConditionSynced {
+			return cond.Status
+		}
+	}
+	return corev1.ConditionUnknown
+}
+
+func (in *SnapshotSchedule) SetSyncedCondition(status corev1.ConditionStatus, reason string, message string) {
+	in.Status.Conditions = Conditions{
+		{
+			Type:               ConditionSynced,
+			Status:             status,
+			LastTransitionTime: metav1.Now(),
+			Reason:             reason,
+			Message:            message,
+		},
+	}
+}
+
+// RetainOrDefault returns the number of snapshots kept at the destination.
+func (in *SnapshotSchedule) RetainOrDefault() int {
+	if in.Spec.Retain > 0 {
+		return in.Spec.Retain
+	}
+	return DefaultSnapshotRetain
+}
+
+// FailureThresholdOrDefault returns the number of consecutive failed
+// snapshots after which a warning event is recorded.
+func (in *SnapshotSchedule) FailureThresholdOrDefault() int {
+	if in.Spec.FailureThreshold > 0 {
+		return in.Spec.FailureThreshold
+	}
+	return DefaultSnapshotFailureThreshold
+}
+
+func (in *SnapshotSchedule) Validate() error {
+	var errs field.ErrorList
+	path := field.NewPath("spec")
+
+	if in.Spec.Interval.Duration <= 0 {
+		errs = append(errs, field.Invalid(path.Child("interval"), in.Spec.Interval.Duration.String(), "must be positive"))
+	}
+	if in.Spec.Retain < 0 {
+		errs = append(errs, field.Invalid(path.Child("retain"), in.Spec.Retain, "must not be negative"))
+	}
+	if in.Spec.FailureThreshold < 0 {
+		errs = append(errs, field.Invalid(path.Child("failureThreshold"), in.Spec.FailureThreshold, "must not be negative"))
+	}
+
+	destPath := path.Child("destination")
+	dest := in.Spec.Destination
+	switch {
+	case dest.Local == nil && dest.S3 == nil:
+		errs = append(errs, field.Required(destPath, "one of local or s3 must be set"))
+	case dest.Local != nil && dest.S3 != nil:
+		errs = append(errs, field.Invalid(destPath, dest, "only one of local or s3 can be set"))
+	case dest.Local != nil:
+		if dest.Local.ClaimName == "" {
+			errs = append(errs, field.Required(destPath.Child("local", "claimName"), "must be set"))
+		}
+	case dest.S3 != nil:
+		if dest.S3.Bucket == "" {
+			errs = append(errs, field.Required(destPath.Child("s3", "bucket"), "must be set"))
+		}
+		if dest.S3.Region == "" {
+			errs = append(errs, field.Required(destPath.Child("s3", "region"), "must be set"))
+		}
+	}
+
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(
+			schema.GroupKind{Group: ConsulHashicorpGroup, Kind: SnapshotScheduleKubeKind},
+			in.KubernetesName(), errs)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSnapshotSchedule_Defaults(t *testing.T) {
+	schedule := &SnapshotSchedule{}
+	require.Equal(t, 30, schedule.RetainOrDefault())
+	require.Equal(t, 3, schedule.FailureThresholdOrDefault())
+
+	schedule.Spec.Retain = 5
+	schedule.Spec.FailureThreshold = 1
+	require.Equal(t, 5, schedule.RetainOrDefault())
+	require.Equal(t, 1, schedule.FailureThresholdOrDefault())
+}
+
+func TestSnapshotSchedule_Validate(t *testing.T) {
+	hourly := metav1.Duration{Duration: time.Hour}
+	cases := map[string]struct {
+		spec            SnapshotScheduleSpec
+		expectedErrMsgs []string
+	}{
+		"valid local": {
+			spec: SnapshotScheduleSpec{
+				Interval:    hourly,
+				Destination: SnapshotDestination{Local: &LocalSnapshotDestination{ClaimName: "snapshots"}},
+			},
+		},
+		"valid s3": {
+			spec: SnapshotScheduleSpec{
+				Interval:    hourly,
+				Retain:      10,
+				Destination: SnapshotDestination{S3: &S3SnapshotDestination{Bucket: "snapshots", Region: "us-east-1"}},
+			},
+		},
+		"invalid interval, retain and threshold": {
+			spec: SnapshotScheduleSpec{
+				Retain:           -1,
+				FailureThreshold: -1,
+				Destination:      SnapshotDestination{Local: &LocalSnapshotDestination{ClaimName: "snapshots"}},
+			},
+			expectedErrMsgs: []string{
+				`spec.interval: Invalid value: "0s": must be positive`,
+				`spec.retain: Invalid value: -1: must not be negative`,
+				`spec.failureThreshold: Invalid value: -1: must not be negative`,
+			},
+		},
+		"no destination": {
+			spec: SnapshotScheduleSpec{Interval: hourly},
+			expectedErrMsgs: []string{
+				`spec.destination: Required value: one of local or s3 must be set`,
+			},
+		},
+		"both destinations": {
+			spec: SnapshotScheduleSpec{
+				Interval: hourly,
+				Destination: SnapshotDestination{
+					Local: &LocalSnapshotDestination{ClaimName: "snapshots"},
+					S3:    &S3SnapshotDestination{Bucket: "snapshots", Region: "us-east-1"},
+				},
+			},
+			expectedErrMsgs: []string{
+				`only one of local or s3 can be set`,
+			},
+		},
+		"incomplete destinations": {
+			spec: SnapshotScheduleSpec{
+				Interval:    hourly,
+				Destination: SnapshotDestination{S3: &S3SnapshotDestination{}},
+			},
+			expectedErrMsgs: []string{
+				`spec.destination.s3.bucket: Required value: must be set`,
+				`spec.destination.s3.region: Required value: must be set`,
+			},
+		},
+	}
+
+	for name, testCase := range cases {
+		t.Run(name, func(t *testing.T) {
+			schedule := &SnapshotSchedule{ObjectMeta: metav1.ObjectMeta{Name: "hourly"}, Spec: testCase.spec}
+			err := schedule.Validate()
+			if len(testCase.expectedErrMsgs) != 0 {
+				require.Error(t, err)
+				for _, s := range testCase.expectedErrMsgs {
+					require.Contains(t, err.Error(), s)
+				}
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
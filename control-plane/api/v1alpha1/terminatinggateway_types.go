@@ -2,6 +2,8 @@ package v1alpha1
 
 import (
 	"encoding/json"
+	"fmt"
+	"path"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -17,6 +19,13 @@ import (
 
 const (
 	terminatingGatewayKubeKind = "terminatinggateway"
+
+	// TerminatingGatewayTLSMountPath is the directory of the terminating gateway
+	// container that the Secrets referenced by the tls field of linked services
+	// are mounted in, each in a subdirectory named after the Secret.
+	TerminatingGatewayTLSMountPath = "/consul/terminating-gateway-tls"
+
+	defaultCASecretKey = "ca.crt"
 )
 
 func init() {
@@ -76,6 +85,37 @@ type LinkedService struct {
 
 	// SNI is the optional name to specify during the TLS handshake with a linked service.
 	SNI string `json:"sni,omitempty"`
+
+	// TLS configures TLS origination to the linked service with certificates
+	// from Kubernetes Secrets. The controller mounts the Secrets into the
+	// terminating gateway deployment and sets caFile, certFile and keyFile to
+	// their paths, so those fields cannot be set together with tls. It can
+	// only be set on TerminatingGateway resources in the namespace of the
+	// terminating gateway deployment.
+	TLS *LinkedServiceTLS `json:"tls,omitempty"`
+}
+
+// LinkedServiceTLS references the Kubernetes Secrets with the certificates that
+// the terminating gateway uses for TLS connections to a linked service. The
+// Secrets must be in the namespace of the terminating gateway deployment.
+type LinkedServiceTLS struct {
+	// CASecret is the Secret with the CA certificate used to verify the
+	// certificate of the linked service.
+	CASecret *SecretKeyReference `json:"caSecret,omitempty"`
+
+	// ClientCertSecret is the name of a kubernetes.io/tls Secret whose tls.crt
+	// and tls.key are the client certificate and private key the gateway
+	// presents to the linked service.
+	ClientCertSecret string `json:"clientCertSecret,omitempty"`
+}
+
+// SecretKeyReference is a key of a Kubernetes Secret.
+type SecretKeyReference struct {
+	// Name is the name of the Secret.
+	Name string `json:"name"`
+
+	// Key is the key within the Secret. Defaults to "ca.crt".
+	Key string `json:"key,omitempty"`
 }
 
 func (in *TerminatingGateway) GetObjectMeta() metav1.ObjectMeta {
@@ -204,7 +244,7 @@ func (in *TerminatingGateway) DefaultNamespaceFields(consulMeta common.ConsulMet
 	// making API calls (because namespace fields can't be set in OSS).
 	if consulMeta.NamespacesEnabled {
 		// Default to the current namespace (i.e. the namespace of the config entry).
-		namespace := namespaces.ConsulNamespace(in.Namespace, consulMeta.NamespacesEnabled, consulMeta.DestinationNamespace, consulMeta.Mirroring, consulMeta.Prefix)
+		namespace := namespaces.ConsulNamespace(in.Namespace, consulMeta.NamespacesEnabled, consulMeta.DestinationNamespace, consulMeta.Mirroring, consulMeta.Prefix, consulMeta.MirroringRules)
 		for i, service := range in.Spec.Services {
 			if service.Namespace == "" {
 				in.Spec.Services[i].Namespace = namespace
@@ -213,8 +253,51 @@ func (in *TerminatingGateway) DefaultNamespaceFields(consulMeta common.ConsulMet
 	}
 }
 
+// ValidateTLSNamespace returns an error if the linked services reference
+// Secrets while the gateway isn't in the namespace of the terminating gateway
+// deployments. The Secrets are mounted from that namespace, so allowing it
+// would let any namespace mount them into the gateway.
+func (in *TerminatingGateway) ValidateTLSNamespace(gatewayNamespace string) error {
+	if gatewayNamespace == "" || in.Namespace == gatewayNamespace {
+		return nil
+	}
+	var errs field.ErrorList
+	path := field.NewPath("spec").Child("services")
+	for i, s := range in.Spec.Services {
+		if s.TLS != nil {
+			errs = append(errs, field.Forbidden(path.Index(i).Child("tls"),
+				fmt.Sprintf("may only be set on TerminatingGateway resources in namespace %q, the namespace of the terminating gateway deployment", gatewayNamespace)))
+		}
+	}
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(
+			schema.GroupKind{Group: ConsulHashicorpGroup, Kind: terminatingGatewayKubeKind},
+			in.KubernetesName(), errs)
+	}
+	return nil
+}
+
+// TLSSecrets returns the names of the Secrets referenced by the tls field of
+// the linked services.
+func (in *TerminatingGateway) TLSSecrets() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, s := range in.Spec.Services {
+		if s.TLS == nil {
+			continue
+		}
+		for _, name := range s.TLS.secrets() {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
 func (in LinkedService) toConsul() capi.LinkedService {
-	return capi.LinkedService{
+	svc := capi.LinkedService{
 		Namespace: in.Namespace,
 		Name:      in.Name,
 		CAFile:    in.CAFile,
@@ -222,6 +305,37 @@ func (in LinkedService) toConsul() capi.LinkedService {
 		KeyFile:   in.KeyFile,
 		SNI:       in.SNI,
 	}
+	if in.TLS != nil {
+		if in.TLS.CASecret != nil {
+			key := in.TLS.CASecret.Key
+			if key == "" {
+				key = defaultCASecretKey
+			}
+			svc.CAFile = TerminatingGatewayTLSPath(in.TLS.CASecret.Name, key)
+		}
+		if in.TLS.ClientCertSecret != "" {
+			svc.CertFile = TerminatingGatewayTLSPath(in.TLS.ClientCertSecret, corev1.TLSCertKey)
+			svc.KeyFile = TerminatingGatewayTLSPath(in.TLS.ClientCertSecret, corev1.TLSPrivateKeyKey)
+		}
+	}
+	return svc
+}
+
+// TerminatingGatewayTLSPath returns the path that the key of the Secret is
+// mounted at in the terminating gateway container.
+func TerminatingGatewayTLSPath(secret, key string) string {
+	return path.Join(TerminatingGatewayTLSMountPath, secret, key)
+}
+
+func (in *LinkedServiceTLS) secrets() []string {
+	var names []string
+	if in.CASecret != nil && in.CASecret.Name != "" {
+		names = append(names, in.CASecret.Name)
+	}
+	if in.ClientCertSecret != "" {
+		names = append(names, in.ClientCertSecret)
+	}
+	return names
 }
 
 func (in LinkedService) validate(path *field.Path) field.ErrorList {
@@ -232,6 +346,20 @@ func (in LinkedService) validate(path *field.Path) field.ErrorList {
 			string(asJSON),
 			"if certFile or keyFile is set, the other must also be set"))
 	}
+	if in.TLS != nil {
+		if in.CAFile != "" || in.CertFile != "" || in.KeyFile != "" {
+			asJSON, _ := json.Marshal(in)
+			errs = append(errs, field.Invalid(path,
+				string(asJSON),
+				"tls cannot be set together with caFile, certFile or keyFile"))
+		}
+		if in.TLS.CASecret == nil && in.TLS.ClientCertSecret == "" {
+			errs = append(errs, field.Required(path.Child("tls"), "at least one of caSecret or clientCertSecret must be set"))
+		}
+		if in.TLS.CASecret != nil && in.TLS.CASecret.Name == "" {
+			errs = append(errs, field.Required(path.Child("tls", "caSecret", "name"), "the name of the CA Secret must be set"))
+		}
+	}
 	return errs
 }
 
@@ -170,6 +170,52 @@ func TestTerminatingGateway_ToConsul(t *testing.T) {
 				},
 			},
 		},
+		"tls secrets": {
+			Ours: TerminatingGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: TerminatingGatewaySpec{
+					Services: []LinkedService{
+						{
+							Name: "db",
+							SNI:  "db.example.com",
+							TLS: &LinkedServiceTLS{
+								CASecret:         &SecretKeyReference{Name: "db-ca"},
+								ClientCertSecret: "db-client",
+							},
+						},
+						{
+							Name: "api",
+							TLS: &LinkedServiceTLS{
+								CASecret: &SecretKeyReference{Name: "bundle", Key: "root.pem"},
+							},
+						},
+					},
+				},
+			},
+			Exp: &capi.TerminatingGatewayConfigEntry{
+				Kind: capi.TerminatingGateway,
+				Name: "name",
+				Services: []capi.LinkedService{
+					{
+						Name:     "db",
+						CAFile:   "/consul/terminating-gateway-tls/db-ca/ca.crt",
+						CertFile: "/consul/terminating-gateway-tls/db-client/tls.crt",
+						KeyFile:  "/consul/terminating-gateway-tls/db-client/tls.key",
+						SNI:      "db.example.com",
+					},
+					{
+						Name:   "api",
+						CAFile: "/consul/terminating-gateway-tls/bundle/root.pem",
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+		},
 	}
 
 	for name, c := range cases {
@@ -228,6 +274,50 @@ func TestTerminatingGateway_Validate(t *testing.T) {
 				`spec.services[0]: Invalid value: "{\"name\":\"foo\",\"keyFile\":\"keyFile\"}": if certFile or keyFile is set, the other must also be set`,
 			},
 		},
+		"tls set with caFile": {
+			input: &TerminatingGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: TerminatingGatewaySpec{
+					Services: []LinkedService{
+						{
+							Name:   "foo",
+							CAFile: "caFile",
+							TLS:    &LinkedServiceTLS{ClientCertSecret: "foo-client"},
+						},
+					},
+				},
+			},
+			namespacesEnabled: false,
+			expectedErrMsgs: []string{
+				`spec.services[0]: Invalid value: "{\"name\":\"foo\",\"caFile\":\"caFile\",\"tls\":{\"clientCertSecret\":\"foo-client\"}}": tls cannot be set together with caFile, certFile or keyFile`,
+			},
+		},
+		"tls without secrets": {
+			input: &TerminatingGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: TerminatingGatewaySpec{
+					Services: []LinkedService{
+						{
+							Name: "foo",
+							TLS:  &LinkedServiceTLS{CASecret: &SecretKeyReference{Key: "ca.pem"}},
+						},
+						{
+							Name: "bar",
+							TLS:  &LinkedServiceTLS{},
+						},
+					},
+				},
+			},
+			namespacesEnabled: false,
+			expectedErrMsgs: []string{
+				`spec.services[0].tls.caSecret.name: Required value: the name of the CA Secret must be set`,
+				`spec.services[1].tls: Required value: at least one of caSecret or clientCertSecret must be set`,
+			},
+		},
 		"service.namespace set when namespaces disabled": {
 			input: &TerminatingGateway{
 				ObjectMeta: metav1.ObjectMeta{
@@ -281,6 +371,39 @@ func TestTerminatingGateway_Validate(t *testing.T) {
 	}
 }
 
+func TestTerminatingGateway_TLSSecrets(t *testing.T) {
+	gateway := TerminatingGateway{
+		Spec: TerminatingGatewaySpec{
+			Services: []LinkedService{
+				{Name: "db", TLS: &LinkedServiceTLS{CASecret: &SecretKeyReference{Name: "ca"}, ClientCertSecret: "db-client"}},
+				{Name: "api", TLS: &LinkedServiceTLS{CASecret: &SecretKeyReference{Name: "ca"}}},
+				{Name: "web", CAFile: "/etc/ssl/ca.crt"},
+			},
+		},
+	}
+	require.Equal(t, []string{"ca", "db-client"}, gateway.TLSSecrets())
+}
+
+func TestTerminatingGateway_ValidateTLSNamespace(t *testing.T) {
+	gateway := TerminatingGateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "terminating-gateway", Namespace: "default"},
+		Spec: TerminatingGatewaySpec{
+			Services: []LinkedService{
+				{Name: "web", CAFile: "/etc/ssl/ca.crt"},
+				{Name: "db", TLS: &LinkedServiceTLS{ClientCertSecret: "db-client"}},
+			},
+		},
+	}
+	require.NoError(t, gateway.ValidateTLSNamespace(""))
+	require.NoError(t, gateway.ValidateTLSNamespace("default"))
+	require.EqualError(t, gateway.ValidateTLSNamespace("consul"),
+		`terminatinggateway.consul.hashicorp.com "terminating-gateway" is invalid: spec.services[1].tls: Forbidden: may only be set on TerminatingGateway resources in namespace "consul", the namespace of the terminating gateway deployment`)
+
+	// Gateways without tls may be in any namespace.
+	gateway.Spec.Services = gateway.Spec.Services[:1]
+	require.NoError(t, gateway.ValidateTLSNamespace("consul"))
+}
+
 // Test defaulting behavior when namespaces are enabled as well as disabled.
 func TestTerminatingGateway_DefaultNamespaceFields(t *testing.T) {
 	namespaceConfig := map[string]struct {
@@ -20,6 +20,11 @@ type TerminatingGatewayWebhook struct {
 	// ConsulMeta contains metadata specific to the Consul installation.
 	ConsulMeta common.ConsulMeta
 
+	// GatewayNamespace is the namespace of the terminating gateway deployments
+	// that the Secrets referenced by the tls field of linked services are
+	// mounted from. Only resources in it may reference Secrets.
+	GatewayNamespace string
+
 	decoder *admission.Decoder
 	client.Client
 }
@@ -39,6 +44,10 @@ func (v *TerminatingGatewayWebhook) Handle(ctx context.Context, req admission.Re
 		return admission.Errored(http.StatusBadRequest, err)
 	}
 
+	if err := resource.ValidateTLSNamespace(v.GatewayNamespace); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
 	return common.ValidateConfigEntry(ctx, req, v.Logger, v, &resource, v.ConsulMeta)
 }
 
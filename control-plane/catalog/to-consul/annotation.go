@@ -23,4 +23,20 @@ const (
 	// annotationServiceMetaPrefix is the prefix for setting meta key/value
 	// for a service. The remainder of the key is the meta key.
 	annotationServiceMetaPrefix = "consul.hashicorp.com/service-meta-"
+
+	// annotationServicePorts specifies the names of the ports of the Service
+	// that are synced, comma separated. Only these ports are added to the
+	// meta and tags of the service instances, and the first of them is the
+	// instance port unless annotationServicePort is set.
+	annotationServicePorts = "consul.hashicorp.com/service-ports"
+
+	// annotationServiceWeight specifies the weight of the service instances
+	// in DNS SRV responses while they are passing.
+	annotationServiceWeight = "consul.hashicorp.com/service-weight"
+
+	// annotationServiceHealthCheckPath specifies the path of an HTTP health
+	// check that is registered for each service instance. The syncer requests
+	// the path on the instance address and port, and sets the status of the
+	// check from the response.
+	annotationServiceHealthCheckPath = "consul.hashicorp.com/service-health-check-path"
 )
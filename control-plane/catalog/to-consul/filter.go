@@ -0,0 +1,168 @@
+package catalog
+
+import (
+	"fmt"
+	"regexp"
+
+	mapset "github.com/deckarep/golang-set"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// ServiceFilter decides which Kubernetes services are eligible for syncing to
+// Consul. Deny rules take precedence over allow rules: a service is eligible
+// only if no deny rule and every allow rule matches it. The service-sync
+// annotation is only consulted for eligible services.
+type ServiceFilter struct {
+	// AllowNamespaces is the set of k8s namespaces whose services are allowed
+	// to be synced. It supports the special value `*` which allows all k8s
+	// namespaces unless explicitly denied.
+	AllowNamespaces mapset.Set
+
+	// DenyNamespaces is the set of k8s namespaces whose services are never
+	// synced.
+	DenyNamespaces mapset.Set
+
+	// NamespaceSelector must match the labels of the k8s namespace of a
+	// service for it to be synced. Nil matches every namespace.
+	NamespaceSelector labels.Selector
+
+	// ServiceSelector must match the labels of a service for it to be synced.
+	// Nil matches every service.
+	ServiceSelector labels.Selector
+
+	// AllowServices are regular expressions matched against the whole
+	// "<namespace>/<name>" of a service. If any are set, one of them must match
+	// for the service to be synced.
+	AllowServices []*regexp.Regexp
+
+	// DenyServices are regular expressions matched against the whole
+	// "<namespace>/<name>" of a service. Services matching any of them are
+	// never synced.
+	DenyServices []*regexp.Regexp
+}
+
+// Allows returns whether the service in the namespace with the given labels is
+// eligible for syncing and, if it isn't, the reason why.
+func (f *ServiceFilter) Allows(svc *apiv1.Service, nsLabels labels.Set) (bool, string) {
+	name := svc.Namespace + "/" + svc.Name
+
+	// Deny rules first so that they always take precedence.
+	if f.DenyNamespaces != nil && f.DenyNamespaces.Contains(svc.Namespace) {
+		return false, "namespace is in the deny list"
+	}
+	for _, re := range f.DenyServices {
+		if re.MatchString(name) {
+			return false, fmt.Sprintf("service matches deny rule %q", re)
+		}
+	}
+
+	if f.AllowNamespaces == nil || (!f.AllowNamespaces.Contains("*") && !f.AllowNamespaces.Contains(svc.Namespace)) {
+		return false, "namespace is not in the allow list"
+	}
+	if f.NamespaceSelector != nil && !f.NamespaceSelector.Matches(nsLabels) {
+		return false, "namespace labels don't match the namespace selector"
+	}
+	if f.ServiceSelector != nil && !f.ServiceSelector.Matches(labels.Set(svc.Labels)) {
+		return false, "service labels don't match the service selector"
+	}
+	if len(f.AllowServices) > 0 {
+		allowed := false
+		for _, re := range f.AllowServices {
+			if re.MatchString(name) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false, "service matches no allow rule"
+		}
+	}
+	return true, ""
+}
+
+// FilterConfig is the serialized form of a ServiceFilter used by the flags and
+// the filter ConfigMap of the sync process.
+type FilterConfig struct {
+	// AllowNamespaces are the k8s namespaces to allow. All namespaces are
+	// allowed if it is omitted, but none if it is an empty list.
+	AllowNamespaces []string `json:"allowNamespaces,omitempty"`
+	// DenyNamespaces are the k8s namespaces to deny.
+	DenyNamespaces []string `json:"denyNamespaces,omitempty"`
+	// NamespaceSelector is a label selector, e.g. "env in (prod,staging)", that
+	// the labels of the namespace of a service must match.
+	NamespaceSelector string `json:"namespaceSelector,omitempty"`
+	// ServiceSelector is a label selector that the labels of a service must match.
+	ServiceSelector string `json:"serviceSelector,omitempty"`
+	// AllowServices are regular expressions, e.g. "payments/.*", of which one must
+	// match the whole "<namespace>/<name>" of a service if any are set.
+	AllowServices []string `json:"allowServices,omitempty"`
+	// DenyServices are regular expressions, e.g. ".*/internal-.*", that deny
+	// the services whose whole "<namespace>/<name>" they match.
+	DenyServices []string `json:"denyServices,omitempty"`
+}
+
+// ParseFilterConfig parses a FilterConfig from YAML or JSON.
+func ParseFilterConfig(raw string) (FilterConfig, error) {
+	var cfg FilterConfig
+	if err := yaml.UnmarshalStrict([]byte(raw), &cfg); err != nil {
+		return FilterConfig{}, fmt.Errorf("unable to parse service filter: %w", err)
+	}
+	return cfg, nil
+}
+
+// Filter compiles the config into a ServiceFilter.
+func (c FilterConfig) Filter() (*ServiceFilter, error) {
+	f := &ServiceFilter{
+		AllowNamespaces: mapset.NewSet("*"),
+		DenyNamespaces:  mapset.NewSet(),
+	}
+	if c.AllowNamespaces != nil {
+		f.AllowNamespaces = toSet(c.AllowNamespaces)
+	}
+	if c.DenyNamespaces != nil {
+		f.DenyNamespaces = toSet(c.DenyNamespaces)
+	}
+
+	var err error
+	if c.NamespaceSelector != "" {
+		if f.NamespaceSelector, err = labels.Parse(c.NamespaceSelector); err != nil {
+			return nil, fmt.Errorf("namespace selector %q is invalid: %w", c.NamespaceSelector, err)
+		}
+	}
+	if c.ServiceSelector != "" {
+		if f.ServiceSelector, err = labels.Parse(c.ServiceSelector); err != nil {
+			return nil, fmt.Errorf("service selector %q is invalid: %w", c.ServiceSelector, err)
+		}
+	}
+	if f.AllowServices, err = compileServiceRules(c.AllowServices); err != nil {
+		return nil, err
+	}
+	if f.DenyServices, err = compileServiceRules(c.DenyServices); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// compileServiceRules compiles the regular expressions, anchored so that they
+// always match the whole "<namespace>/<name>" of a service.
+func compileServiceRules(rules []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, rule := range rules {
+		re, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", rule))
+		if err != nil {
+			return nil, fmt.Errorf("service rule %q is invalid: %w", rule, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+func toSet(values []string) mapset.Set {
+	set := mapset.NewSet()
+	for _, v := range values {
+		set.Add(v)
+	}
+	return set
+}
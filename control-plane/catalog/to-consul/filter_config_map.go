@@ -0,0 +1,97 @@
+package catalog
+
+import (
+	"context"
+
+	"github.com/hashicorp/go-hclog"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// FilterConfigMapKey is the key of the FilterConfig in the data of the filter
+// ConfigMap.
+const FilterConfigMapKey = "filter.yaml"
+
+// FilterConfigMapResource implements controller.Resource to reload the filter
+// of a ServiceResource whenever its ConfigMap changes. The filter of the
+// ConfigMap replaces the default filter, which is restored if the ConfigMap is
+// deleted. An invalid filter is logged and the previous one is kept.
+type FilterConfigMapResource struct {
+	Log    hclog.Logger
+	Client kubernetes.Interface
+	Ctx    context.Context
+
+	// Namespace and Name are the namespace and name of the ConfigMap.
+	Namespace string
+	Name      string
+
+	// Default is the filter used while the ConfigMap doesn't exist.
+	Default *ServiceFilter
+
+	// Service is the resource whose filter is updated.
+	Service *ServiceResource
+}
+
+// Informer implements the controller.Resource interface.
+func (t *FilterConfigMapResource) Informer() cache.SharedIndexInformer {
+	selector := fields.OneTermEqualSelector("metadata.name", t.Name).String()
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.FieldSelector = selector
+				return t.Client.CoreV1().ConfigMaps(t.Namespace).List(t.Ctx, options)
+			},
+
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.FieldSelector = selector
+				return t.Client.CoreV1().ConfigMaps(t.Namespace).Watch(t.Ctx, options)
+			},
+		},
+		&apiv1.ConfigMap{},
+		0,
+		cache.Indexers{},
+	)
+}
+
+// Upsert implements the controller.Resource interface.
+func (t *FilterConfigMapResource) Upsert(key string, raw interface{}) error {
+	cm, ok := raw.(*apiv1.ConfigMap)
+	if !ok {
+		t.Log.Warn("upsert got invalid type", "raw", raw)
+		return nil
+	}
+
+	// Errors are not returned since retrying won't fix the ConfigMap. It is
+	// upserted again once it has been edited.
+	data, ok := cm.Data[FilterConfigMapKey]
+	if !ok {
+		t.Log.Error("filter ConfigMap has no filter, keeping the current filter", "key", key, "data-key", FilterConfigMapKey)
+		return nil
+	}
+	cfg, err := ParseFilterConfig(data)
+	if err != nil {
+		t.Log.Error("invalid filter ConfigMap, keeping the current filter", "key", key, "err", err)
+		return nil
+	}
+	filter, err := cfg.Filter()
+	if err != nil {
+		t.Log.Error("invalid filter ConfigMap, keeping the current filter", "key", key, "err", err)
+		return nil
+	}
+
+	t.Log.Info("loaded filter from ConfigMap", "key", key)
+	t.Service.SetFilter(filter)
+	return nil
+}
+
+// Delete implements the controller.Resource interface.
+func (t *FilterConfigMapResource) Delete(key string, _ interface{}) error {
+	t.Log.Info("filter ConfigMap deleted, restoring the default filter", "key", key)
+	t.Service.SetFilter(t.Default)
+	return nil
+}
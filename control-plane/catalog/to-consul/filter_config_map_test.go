@@ -0,0 +1,87 @@
+package catalog
+
+import (
+	"context"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/controller"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// Test that the filter is reloaded when the ConfigMap changes and restored
+// when it is deleted.
+func TestFilterConfigMapResource(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	defaultFilter := serviceResource.Filter
+
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+	filterCloser := controller.TestControllerRun(&FilterConfigMapResource{
+		Log:       hclog.Default(),
+		Client:    client,
+		Ctx:       context.Background(),
+		Namespace: "consul",
+		Name:      "sync-filter",
+		Default:   defaultFilter,
+		Service:   &serviceResource,
+	})
+	defer filterCloser()
+
+	for _, ns := range []string{"foo", "bar"} {
+		_, err := client.CoreV1().Services(ns).Create(context.Background(), lbService(ns, ns, "1.2.3.4"), metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+	requireServices := func(exp ...string) {
+		retry.Run(t, func(r *retry.R) {
+			syncer.Lock()
+			defer syncer.Unlock()
+			var actual []string
+			for _, reg := range syncer.Registrations {
+				actual = append(actual, reg.Service.Service)
+			}
+			require.ElementsMatch(r, exp, actual)
+		})
+	}
+	requireServices("foo", "bar")
+
+	// A ConfigMap with another name is ignored.
+	other := &apiv1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "consul"},
+		Data:       map[string]string{FilterConfigMapKey: "denyNamespaces: [foo, bar]"},
+	}
+	_, err := client.CoreV1().ConfigMaps("consul").Create(context.Background(), other, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	cm := &apiv1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "sync-filter", Namespace: "consul"},
+		Data:       map[string]string{FilterConfigMapKey: "denyNamespaces: [foo]"},
+	}
+	_, err = client.CoreV1().ConfigMaps("consul").Create(context.Background(), cm, metav1.CreateOptions{})
+	require.NoError(t, err)
+	requireServices("bar")
+
+	// An invalid filter keeps the current one.
+	cm.Data[FilterConfigMapKey] = "namespaceSelector: '=='"
+	_, err = client.CoreV1().ConfigMaps("consul").Update(context.Background(), cm, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	requireServices("bar")
+
+	cm.Data[FilterConfigMapKey] = "allowNamespaces: [foo]"
+	_, err = client.CoreV1().ConfigMaps("consul").Update(context.Background(), cm, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	requireServices("foo")
+
+	err = client.CoreV1().ConfigMaps("consul").Delete(context.Background(), "sync-filter", metav1.DeleteOptions{})
+	require.NoError(t, err)
+	requireServices("foo", "bar")
+	require.True(t, serviceResource.Filter.AllowNamespaces.Equal(mapset.NewSet("*")))
+}
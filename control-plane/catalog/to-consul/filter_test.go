@@ -0,0 +1,129 @@
+package catalog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestServiceFilter_Allows(t *testing.T) {
+	t.Parallel()
+	svc := &apiv1.Service{ObjectMeta: metav1.ObjectMeta{
+		Name:      "web",
+		Namespace: "payments",
+		Labels:    map[string]string{"tier": "frontend"},
+	}}
+	nsLabels := labels.Set{"env": "prod"}
+
+	cases := map[string]struct {
+		Config FilterConfig
+		Exp    bool
+	}{
+		"empty config allows all": {
+			Config: FilterConfig{},
+			Exp:    true,
+		},
+		"empty allow list denies all": {
+			Config: FilterConfig{AllowNamespaces: []string{}},
+			Exp:    false,
+		},
+		"namespace allowed": {
+			Config: FilterConfig{AllowNamespaces: []string{"payments"}},
+			Exp:    true,
+		},
+		"namespace not allowed": {
+			Config: FilterConfig{AllowNamespaces: []string{"other"}},
+			Exp:    false,
+		},
+		"namespace denied and allowed": {
+			Config: FilterConfig{AllowNamespaces: []string{"payments"}, DenyNamespaces: []string{"payments"}},
+			Exp:    false,
+		},
+		"namespace selector matches": {
+			Config: FilterConfig{NamespaceSelector: "env in (prod,staging)"},
+			Exp:    true,
+		},
+		"namespace selector doesn't match": {
+			Config: FilterConfig{NamespaceSelector: "env=dev"},
+			Exp:    false,
+		},
+		"service selector matches": {
+			Config: FilterConfig{ServiceSelector: "tier"},
+			Exp:    true,
+		},
+		"service selector doesn't match": {
+			Config: FilterConfig{ServiceSelector: "tier!=frontend"},
+			Exp:    false,
+		},
+		"allow rule matches": {
+			Config: FilterConfig{AllowServices: []string{"other/.*", "payments/.*"}},
+			Exp:    true,
+		},
+		"allow rule only matches part of the name": {
+			Config: FilterConfig{AllowServices: []string{"web"}},
+			Exp:    false,
+		},
+		"deny rule takes precedence over allow rule": {
+			Config: FilterConfig{AllowServices: []string{"payments/.*"}, DenyServices: []string{".*/web"}},
+			Exp:    false,
+		},
+		"deny rule takes precedence over selectors": {
+			Config: FilterConfig{NamespaceSelector: "env=prod", ServiceSelector: "tier=frontend", DenyServices: []string{"payments/web"}},
+			Exp:    false,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			f, err := c.Config.Filter()
+			require.NoError(t, err)
+			ok, reason := f.Allows(svc, nsLabels)
+			require.Equal(t, c.Exp, ok)
+			if ok {
+				require.Empty(t, reason)
+			} else {
+				require.NotEmpty(t, reason)
+			}
+		})
+	}
+}
+
+func TestParseFilterConfig(t *testing.T) {
+	t.Parallel()
+	cfg, err := ParseFilterConfig(`
+allowNamespaces: ["*"]
+denyNamespaces: [kube-system]
+namespaceSelector: env=prod
+allowServices:
+- payments/.*
+`)
+	require.NoError(t, err)
+	require.Equal(t, FilterConfig{
+		AllowNamespaces:   []string{"*"},
+		DenyNamespaces:    []string{"kube-system"},
+		NamespaceSelector: "env=prod",
+		AllowServices:     []string{"payments/.*"},
+	}, cfg)
+
+	_, err = ParseFilterConfig(`allowNamespace: ["*"]`)
+	require.Error(t, err)
+}
+
+func TestFilterConfig_FilterErrors(t *testing.T) {
+	t.Parallel()
+	cases := map[string]FilterConfig{
+		"namespace selector": {NamespaceSelector: "env in (prod"},
+		"service selector":   {ServiceSelector: "in (a"},
+		"allow rule":         {AllowServices: []string{"("}},
+		"deny rule":          {DenyServices: []string{"["}},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := c.Filter()
+			require.Error(t, err)
+		})
+	}
+}
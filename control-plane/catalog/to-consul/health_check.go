@@ -0,0 +1,74 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	// healthCheckTimeout bounds the time spent probing a single HTTP health check.
+	healthCheckTimeout = 5 * time.Second
+
+	// healthCheckConcurrency is the number of HTTP health checks probed at once.
+	healthCheckConcurrency = 10
+
+	// healthCheckMaxOutput is the number of bytes of the response body kept as
+	// the output of a check.
+	healthCheckMaxOutput = 4 * 1024
+)
+
+// probeHealthChecks sets the status and output of the HTTP health checks of the
+// registrations by requesting their URLs, since the checks of catalog
+// registrations are not run by any Consul agent. Like Consul agents, a 2xx
+// response is passing, a 429 response is warning and anything else is critical.
+func probeHealthChecks(ctx context.Context, client *http.Client, rs []*api.CatalogRegistration) {
+	sem := make(chan struct{}, healthCheckConcurrency)
+	var wg sync.WaitGroup
+	for _, r := range rs {
+		for _, check := range r.Checks {
+			if check.Definition.HTTP == "" {
+				continue
+			}
+			wg.Add(1)
+			go func(check *api.HealthCheck) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				check.Status, check.Output = probeHTTP(ctx, client, check.Definition.HTTP)
+			}(check)
+		}
+	}
+	wg.Wait()
+}
+
+// probeHTTP requests the URL and returns the check status and output.
+func probeHTTP(ctx context.Context, client *http.Client, url string) (string, string) {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return api.HealthCritical, err.Error()
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return api.HealthCritical, err.Error()
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, healthCheckMaxOutput))
+	output := fmt.Sprintf("HTTP GET %s: %s Output: %s", url, resp.Status, body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return api.HealthPassing, output
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return api.HealthWarning, output
+	default:
+		return api.HealthCritical, output
+	}
+}
@@ -0,0 +1,51 @@
+package catalog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeHealthChecks(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthy":
+			w.Write([]byte("ok"))
+		case "/throttled":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	check := func(path string) *api.HealthCheck {
+		return &api.HealthCheck{
+			Status:     api.HealthCritical,
+			Definition: api.HealthCheckDefinition{HTTP: server.URL + path},
+		}
+	}
+	rs := []*api.CatalogRegistration{
+		{Checks: api.HealthChecks{check("/healthy")}},
+		{Checks: api.HealthChecks{check("/throttled")}},
+		{Checks: api.HealthChecks{check("/unhealthy")}},
+		{Checks: api.HealthChecks{{Status: api.HealthCritical, Definition: api.HealthCheckDefinition{HTTP: "http://127.0.0.1:0/"}}}},
+		{Checks: api.HealthChecks{{Status: api.HealthPassing}}},
+	}
+	probeHealthChecks(context.Background(), http.DefaultClient, rs)
+
+	require.Equal(t, api.HealthPassing, rs[0].Checks[0].Status)
+	require.Contains(t, rs[0].Checks[0].Output, "200 OK Output: ok")
+	require.Equal(t, api.HealthWarning, rs[1].Checks[0].Status)
+	require.Equal(t, api.HealthCritical, rs[2].Checks[0].Status)
+	require.Contains(t, rs[2].Checks[0].Output, "503 Service Unavailable")
+	require.Equal(t, api.HealthCritical, rs[3].Checks[0].Status)
+	require.NotEmpty(t, rs[3].Checks[0].Output)
+	// Checks without an HTTP definition are left as they are.
+	require.Equal(t, api.HealthPassing, rs[4].Checks[0].Status)
+}
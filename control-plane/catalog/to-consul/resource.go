@@ -1,13 +1,16 @@
 package catalog
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"net"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 
-	mapset "github.com/deckarep/golang-set"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/controller"
 	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	consulapi "github.com/hashicorp/consul/api"
@@ -29,6 +32,19 @@ const (
 	// ConsulK8SNS is the key used in the meta to record the namespace
 	// of the service/node registration.
 	ConsulK8SNS = "external-k8s-ns"
+
+	// ConsulK8SClusterID is the key used in the meta to record the ID of the
+	// Kubernetes cluster that registered the service, when several clusters
+	// sync services into the same Consul datacenter.
+	ConsulK8SClusterID = "external-k8s-cluster-id"
+
+	// portMetaPrefix is the prefix of the meta keys that record the ports of
+	// the service. The remainder of the key is the port name.
+	portMetaPrefix = "port-"
+
+	// appProtocolMetaSuffix is appended to the port meta key to record the
+	// appProtocol of the port.
+	appProtocolMetaSuffix = "-app-protocol"
 )
 
 type NodePortSyncType string
@@ -56,17 +72,10 @@ type ServiceResource struct {
 	// Ctx is used to cancel processes kicked off by ServiceResource.
 	Ctx context.Context
 
-	// AllowK8sNamespacesSet is a set of k8s namespaces to explicitly allow for
-	// syncing. It supports the special character `*` which indicates that
-	// all k8s namespaces are eligible unless explicitly denied. This filter
-	// is applied before checking pod annotations.
-	AllowK8sNamespacesSet mapset.Set
-
-	// DenyK8sNamespacesSet is a set of k8s namespaces to explicitly deny
-	// syncing and thus service registration with Consul. An empty set
-	// means that no namespaces are removed from consideration. This filter
-	// takes precedence over AllowK8sNamespacesSet.
-	DenyK8sNamespacesSet mapset.Set
+	// Filter decides which services are eligible for syncing. It is replaced
+	// with SetFilter once the controller is running. A nil filter allows all
+	// services.
+	Filter *ServiceFilter
 
 	// ConsulK8STag is the tag value for services registered.
 	ConsulK8STag string
@@ -119,12 +128,41 @@ type ServiceResource struct {
 	// `k8s-default` namespace.
 	K8SNSMirroringPrefix string
 
+	// K8SNSMirroringRules rewrite the names of the k8s namespaces that are mirrored,
+	// e.g. to strip a "team-" prefix, before K8SNSMirroringPrefix is added.
+	K8SNSMirroringRules namespaces.MirroringRules
+
 	// The Consul node name to register service with.
 	ConsulNodeName string
 
-	// serviceLock must be held for any read/write to these maps.
+	// ClusterID is the ID of the Kubernetes cluster that is added to the meta
+	// of the services. It is not added if it is empty.
+	ClusterID string
+
+	// PortTagTemplate is executed for each port of a service with a
+	// PortTemplateData to generate additional tags for the service, e.g.
+	// "{{ .Name }}-{{ .AppProtocol }}". Empty results are ignored. No tags are
+	// generated if it is nil.
+	PortTagTemplate *template.Template
+
+	// TopologyLabels are the keys of node labels, e.g.
+	// "topology.kubernetes.io/zone", whose values are added to the meta of the
+	// service instances registered for the endpoints on the node. The meta
+	// key is the label key with every character that is not allowed in a
+	// meta key replaced by a dash.
+	TopologyLabels []string
+
+	// informer is the informer of the services, used to re-evaluate the
+	// services that aren't synced when the filter or namespaces change.
+	informer cache.SharedIndexInformer
+
+	// serviceLock must be held for any read/write to these maps and to Filter.
 	serviceLock sync.RWMutex
 
+	// namespaceMap holds the k8s namespaces, keyed by name, for matching the
+	// namespace selector and service-sync annotation of namespaces.
+	namespaceMap map[string]*apiv1.Namespace
+
 	// serviceMap holds services we should sync to Consul. Keys are the
 	// in the form <kube namespace>/<kube svc name>.
 	serviceMap map[string]*apiv1.Service
@@ -142,8 +180,8 @@ type ServiceResource struct {
 // Informer implements the controller.Resource interface.
 func (t *ServiceResource) Informer() cache.SharedIndexInformer {
 	// Watch all k8s namespaces. Events will be filtered out as appropriate
-	// based on the allow and deny lists in the `shouldSync` function.
-	return cache.NewSharedIndexInformer(
+	// based on the filter in the `shouldSync` function.
+	t.informer = cache.NewSharedIndexInformer(
 		&cache.ListWatch{
 			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
 				return t.Client.CoreV1().Services(metav1.NamespaceAll).List(t.Ctx, options)
@@ -157,6 +195,7 @@ func (t *ServiceResource) Informer() cache.SharedIndexInformer {
 		0,
 		cache.Indexers{},
 	)
+	return t.informer
 }
 
 // Upsert implements the controller.Resource interface.
@@ -242,26 +281,87 @@ func (t *ServiceResource) doDelete(key string) {
 
 // Run implements the controller.Backgrounder interface.
 func (t *ServiceResource) Run(ch <-chan struct{}) {
+	t.Log.Info("starting runner for namespaces")
+	namespacesDone := make(chan struct{})
+	go func() {
+		defer close(namespacesDone)
+		(&controller.Controller{
+			Log:      t.Log.Named("controller/namespaces"),
+			Resource: &serviceNamespaceResource{Service: t, Ctx: t.Ctx},
+		}).Run(ch)
+	}()
+
 	t.Log.Info("starting runner for endpoints")
 	(&controller.Controller{
 		Log:      t.Log.Named("controller/endpoints"),
 		Resource: &serviceEndpointsResource{Service: t, Ctx: t.Ctx},
 	}).Run(ch)
+	<-namespacesDone
+}
+
+// SetFilter replaces the filter and syncs or stops syncing the services
+// whose eligibility changed.
+func (t *ServiceResource) SetFilter(f *ServiceFilter) {
+	t.serviceLock.Lock()
+	t.Filter = f
+	t.serviceLock.Unlock()
+	t.Log.Info("service filter updated")
+	t.reevaluate("")
+}
+
+// reevaluate upserts the services in the k8s namespace, or in all namespaces
+// if it is empty, whose eligibility for syncing no longer matches whether
+// they are synced.
+func (t *ServiceResource) reevaluate(namespace string) {
+	if t.informer == nil {
+		return
+	}
+
+	var changed []*apiv1.Service
+	t.serviceLock.RLock()
+	for _, raw := range t.informer.GetStore().List() {
+		svc, ok := raw.(*apiv1.Service)
+		if !ok || (namespace != "" && svc.Namespace != namespace) {
+			continue
+		}
+		_, synced := t.serviceMap[t.serviceKey(svc)]
+		if t.shouldSync(svc) != synced {
+			changed = append(changed, svc)
+		}
+	}
+	t.serviceLock.RUnlock()
+
+	for _, svc := range changed {
+		if err := t.Upsert(t.serviceKey(svc), svc); err != nil {
+			t.Log.Warn("error re-evaluating service", "key", t.serviceKey(svc), "err", err)
+		}
+	}
+}
+
+// serviceKey returns the key of the service in serviceMap, which is the same
+// as the key of the informer store.
+func (t *ServiceResource) serviceKey(svc *apiv1.Service) string {
+	if svc.Namespace == "" {
+		return svc.Name
+	}
+	return svc.Namespace + "/" + svc.Name
 }
 
 // shouldSync returns true if resyncing should be enabled for the given service.
+//
+// Precondition: assumes t.serviceLock is held.
 func (t *ServiceResource) shouldSync(svc *apiv1.Service) bool {
 	// Namespace logic
-	// If in deny list, don't sync
-	if t.DenyK8sNamespacesSet.Contains(svc.Namespace) {
-		t.Log.Debug("[shouldSync] service is in the deny list", "svc.Namespace", svc.Namespace, "service", svc)
-		return false
+	var nsLabels map[string]string
+	ns, nsKnown := t.namespaceMap[svc.Namespace]
+	if nsKnown {
+		nsLabels = ns.Labels
 	}
-
-	// If not in allow list or allow list is not *, don't sync
-	if !t.AllowK8sNamespacesSet.Contains("*") && !t.AllowK8sNamespacesSet.Contains(svc.Namespace) {
-		t.Log.Debug("[shouldSync] service not in allow list", "svc.Namespace", svc.Namespace, "service", svc)
-		return false
+	if t.Filter != nil {
+		if ok, reason := t.Filter.Allows(svc, nsLabels); !ok {
+			t.Log.Debug("[shouldSync] service is filtered out", "reason", reason, "svc.Namespace", svc.Namespace, "service", svc)
+			return false
+		}
 	}
 
 	// Ignore ClusterIP services if ClusterIP sync is disabled
@@ -270,10 +370,25 @@ func (t *ServiceResource) shouldSync(svc *apiv1.Service) bool {
 		return false
 	}
 
+	// The annotation of the namespace overrides the default for its services.
+	def := !t.ExplicitEnable
+	if nsKnown {
+		if raw, ok := ns.Annotations[annotationServiceSync]; ok {
+			v, err := strconv.ParseBool(raw)
+			if err != nil {
+				t.Log.Warn("error parsing service-sync annotation of namespace",
+					"namespace", svc.Namespace,
+					"err", err)
+			} else {
+				def = v
+			}
+		}
+	}
+
 	raw, ok := svc.Annotations[annotationServiceSync]
 	if !ok {
 		// If there is no explicit value, then set it to our current default.
-		return !t.ExplicitEnable
+		return def
 	}
 
 	v, err := strconv.ParseBool(raw)
@@ -283,7 +398,7 @@ func (t *ServiceResource) shouldSync(svc *apiv1.Service) bool {
 			"err", err)
 
 		// Fallback to default
-		return !t.ExplicitEnable
+		return def
 	}
 
 	return v
@@ -365,7 +480,8 @@ func (t *ServiceResource) generateRegistrations(key string) {
 		t.EnableNamespaces,
 		t.ConsulDestinationNamespace,
 		t.EnableK8SNSMirroring,
-		t.K8SNSMirroringPrefix)
+		t.K8SNSMirroringPrefix,
+		t.K8SNSMirroringRules)
 	if consulNS != "" {
 		t.Log.Debug("[generateRegistrations] namespace being used", "key", key, "namespace", consulNS)
 		baseService.Namespace = consulNS
@@ -374,12 +490,17 @@ func (t *ServiceResource) generateRegistrations(key string) {
 	// Determine the default port and set port annotations
 	var overridePortName string
 	var overridePortNumber int
-	if len(svc.Spec.Ports) > 0 {
+	ports := t.syncedPorts(key, svc)
+	if len(ports) > 0 {
 		var port int
 		isNodePort := svc.Spec.Type == apiv1.ServiceTypeNodePort
 
-		// If a specific port is specified, then use that port value
+		// If a specific port is specified, then use that port value.
+		// Otherwise, if only some ports are synced, use the first of them.
 		portAnnotation, ok := svc.Annotations[annotationServicePort]
+		if !ok && len(ports) < len(svc.Spec.Ports) && ports[0].Name != "" {
+			portAnnotation, ok = ports[0].Name, true
+		}
 		if ok {
 			if v, err := strconv.ParseInt(portAnnotation, 0, 0); err == nil {
 				port = int(v)
@@ -392,7 +513,7 @@ func (t *ServiceResource) generateRegistrations(key string) {
 		// For when the port was a name instead of an int
 		if overridePortName != "" {
 			// Find the named port
-			for _, p := range svc.Spec.Ports {
+			for _, p := range ports {
 				if p.Name == overridePortName {
 					if isNodePort && p.NodePort > 0 {
 						port = int(p.NodePort)
@@ -411,14 +532,14 @@ func (t *ServiceResource) generateRegistrations(key string) {
 		if port == 0 {
 			if isNodePort {
 				// Find first defined NodePort
-				for _, p := range svc.Spec.Ports {
+				for _, p := range ports {
 					if p.NodePort > 0 {
 						port = int(p.NodePort)
 						break
 					}
 				}
 			} else {
-				port = int(svc.Spec.Ports[0].Port)
+				port = int(ports[0].Port)
 				// NOTE: for cluster IP services we always use the endpoint
 				// ports so this will be overridden.
 			}
@@ -427,10 +548,16 @@ func (t *ServiceResource) generateRegistrations(key string) {
 		baseService.Port = port
 
 		// Add all the ports as annotations
-		for _, p := range svc.Spec.Ports {
+		for _, p := range ports {
 			// Set the tag
-			baseService.Meta["port-"+p.Name] = strconv.FormatInt(int64(p.Port), 10)
+			baseService.Meta[portMetaPrefix+p.Name] = strconv.FormatInt(int64(p.Port), 10)
+			if p.AppProtocol != nil && *p.AppProtocol != "" {
+				baseService.Meta[portMetaPrefix+p.Name+appProtocolMetaSuffix] = *p.AppProtocol
+			}
 		}
+
+		// Add the tags generated from the ports
+		baseService.Tags = append(baseService.Tags, t.portTags(key, ports)...)
 	}
 
 	// Parse any additional tags
@@ -446,6 +573,23 @@ func (t *ServiceResource) generateRegistrations(key string) {
 		}
 	}
 
+	// The cluster ID is set after the meta from annotations so that services
+	// can't claim the ownership of another cluster.
+	if t.ClusterID != "" {
+		baseService.Meta[ConsulK8SClusterID] = t.ClusterID
+	}
+
+	// Parse the weight
+	if raw, ok := svc.Annotations[annotationServiceWeight]; ok {
+		weight, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || weight < 1 {
+			t.Log.Warn("service-weight annotation must be a positive integer, ignoring it",
+				"key", key, "value", raw)
+		} else {
+			baseService.Weights = consulapi.AgentWeights{Passing: weight, Warning: 1}
+		}
+	}
+
 	// Always log what we generated
 	defer func() {
 		t.Log.Debug("generated registration",
@@ -455,6 +599,12 @@ func (t *ServiceResource) generateRegistrations(key string) {
 			"instances", len(t.consulMap[key]))
 	}()
 
+	// Add the health checks once the instances have been generated. This runs
+	// before the registration is logged above.
+	if path, ok := svc.Annotations[annotationServiceHealthCheckPath]; ok {
+		defer t.addHealthChecks(key, path)
+	}
+
 	// If there are external IPs then those become the instance registrations
 	// for any type of service.
 	if ips := svc.Spec.ExternalIPs; len(ips) > 0 {
@@ -552,6 +702,7 @@ func (t *ServiceResource) generateRegistrations(key string) {
 						r.Service = &rs
 						r.Service.ID = serviceID(r.Service.Service, subsetAddr.IP)
 						r.Service.Address = address.Address
+						r.Service.Meta = t.topologyMeta(rs.Meta, node)
 
 						t.consulMap[key] = append(t.consulMap[key], &r)
 						// Only consider the first address that matches. In some cases
@@ -573,6 +724,7 @@ func (t *ServiceResource) generateRegistrations(key string) {
 							r.Service = &rs
 							r.Service.ID = serviceID(r.Service.Service, subsetAddr.IP)
 							r.Service.Address = address.Address
+							r.Service.Meta = t.topologyMeta(rs.Meta, node)
 
 							t.consulMap[key] = append(t.consulMap[key], &r)
 							// Only consider the first address that matches. In some cases
@@ -594,6 +746,69 @@ func (t *ServiceResource) generateRegistrations(key string) {
 	}
 }
 
+// syncedPorts returns the ports of the service that are synced, which are the
+// ports named by the service-ports annotation or all ports if it isn't set.
+func (t *ServiceResource) syncedPorts(key string, svc *apiv1.Service) []apiv1.ServicePort {
+	raw, ok := svc.Annotations[annotationServicePorts]
+	if !ok {
+		return svc.Spec.Ports
+	}
+
+	var ports []apiv1.ServicePort
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, p := range svc.Spec.Ports {
+			if p.Name == name {
+				ports = append(ports, p)
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Log.Warn("port in service-ports annotation not found in service", "key", key, "port", name)
+		}
+	}
+	if len(ports) == 0 {
+		t.Log.Warn("no port in service-ports annotation found in service, syncing all ports", "key", key)
+		return svc.Spec.Ports
+	}
+	return ports
+}
+
+// addHealthChecks adds an HTTP health check for the path to each service
+// instance registration of the key. The syncer sets the status of the checks
+// by probing them before registering.
+func (t *ServiceResource) addHealthChecks(key, path string) {
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	for _, r := range t.consulMap[key] {
+		if r.Service.Address == "" || r.Service.Port == 0 {
+			continue
+		}
+		r.Checks = consulapi.HealthChecks{
+			{
+				Node:        r.Node,
+				CheckID:     fmt.Sprintf("%s/http", r.Service.ID),
+				Name:        "HTTP Health Check",
+				Status:      consulapi.HealthCritical,
+				ServiceID:   r.Service.ID,
+				ServiceName: r.Service.Service,
+				Namespace:   r.Service.Namespace,
+				Definition: consulapi.HealthCheckDefinition{
+					HTTP:    fmt.Sprintf("http://%s%s", net.JoinHostPort(r.Service.Address, strconv.Itoa(r.Service.Port)), path),
+					Timeout: consulapi.ReadableDuration(healthCheckTimeout),
+				},
+			},
+		}
+	}
+}
+
 func (t *ServiceResource) registerServiceInstance(
 	baseNode consulapi.CatalogRegistration,
 	baseService consulapi.AgentService,
@@ -612,6 +827,9 @@ func (t *ServiceResource) registerServiceInstance(
 	}
 
 	seen := map[string]struct{}{}
+	// nodes caches the nodes of the endpoints that are looked up for their
+	// topology labels.
+	nodes := map[string]*apiv1.Node{}
 	for _, subset := range endpoints.Subsets {
 		// For ClusterIP services and if LoadBalancerEndpointsSync is true, we use the endpoint port instead
 		// of the service port because we're registering each endpoint
@@ -656,6 +874,18 @@ func (t *ServiceResource) registerServiceInstance(
 			r.Service.ID = serviceID(r.Service.Service, addr)
 			r.Service.Address = addr
 			r.Service.Port = epPort
+			if len(t.TopologyLabels) > 0 && subsetAddr.NodeName != nil {
+				node, ok := nodes[*subsetAddr.NodeName]
+				if !ok {
+					var err error
+					node, err = t.Client.CoreV1().Nodes().Get(t.Ctx, *subsetAddr.NodeName, metav1.GetOptions{})
+					if err != nil {
+						t.Log.Warn("error getting node info", "error", err)
+					}
+					nodes[*subsetAddr.NodeName] = node
+				}
+				r.Service.Meta = t.topologyMeta(rs.Meta, node)
+			}
 
 			t.consulMap[key] = append(t.consulMap[key], &r)
 		}
@@ -690,8 +920,7 @@ type serviceEndpointsResource struct {
 func (t *serviceEndpointsResource) Informer() cache.SharedIndexInformer {
 	// Watch all k8s namespaces. Events will be filtered out as appropriate in the
 	// `shouldTrackEndpoints` function which checks whether the service is marked
-	// to be tracked by the `shouldSync` function which uses the allow and deny
-	// namespace lists.
+	// to be tracked by the `shouldSync` function which uses the filter.
 	return cache.NewSharedIndexInformer(
 		&cache.ListWatch{
 			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
@@ -760,6 +989,63 @@ func (t *serviceEndpointsResource) Delete(key string, _ interface{}) error {
 	return nil
 }
 
+// serviceNamespaceResource implements controller.Resource and starts a
+// background watcher on namespaces that is used by the ServiceResource to
+// match the namespace selector of its filter and the service-sync annotation
+// of namespaces.
+type serviceNamespaceResource struct {
+	Service *ServiceResource
+	Ctx     context.Context
+}
+
+func (t *serviceNamespaceResource) Informer() cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return t.Service.Client.CoreV1().Namespaces().List(t.Ctx, options)
+			},
+
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return t.Service.Client.CoreV1().Namespaces().Watch(t.Ctx, options)
+			},
+		},
+		&apiv1.Namespace{},
+		0,
+		cache.Indexers{},
+	)
+}
+
+func (t *serviceNamespaceResource) Upsert(key string, raw interface{}) error {
+	ns, ok := raw.(*apiv1.Namespace)
+	if !ok {
+		t.Service.Log.Warn("upsert got invalid type", "raw", raw)
+		return nil
+	}
+
+	t.Service.serviceLock.Lock()
+	if t.Service.namespaceMap == nil {
+		t.Service.namespaceMap = make(map[string]*apiv1.Namespace)
+	}
+	t.Service.namespaceMap[ns.Name] = ns
+	t.Service.serviceLock.Unlock()
+
+	// The labels or annotations of the namespace may have changed which
+	// services in it are synced.
+	t.Service.reevaluate(ns.Name)
+	t.Service.Log.Debug("upsert namespace", "key", key)
+	return nil
+}
+
+func (t *serviceNamespaceResource) Delete(key string, _ interface{}) error {
+	t.Service.serviceLock.Lock()
+	delete(t.Service.namespaceMap, key)
+	t.Service.serviceLock.Unlock()
+
+	t.Service.reevaluate(key)
+	t.Service.Log.Debug("delete namespace", "key", key)
+	return nil
+}
+
 func (t *ServiceResource) addPrefixAndK8SNamespace(name, namespace string) string {
 	if t.ConsulServicePrefix != "" {
 		name = fmt.Sprintf("%s%s", t.ConsulServicePrefix, name)
@@ -772,6 +1058,88 @@ func (t *ServiceResource) addPrefixAndK8SNamespace(name, namespace string) strin
 	return name
 }
 
+// PortTemplateData is the data the PortTagTemplate is executed with.
+type PortTemplateData struct {
+	// Name is the name of the port.
+	Name string
+	// Port is the port of the service.
+	Port int32
+	// NodePort is the node port of the port, or 0 if it has none.
+	NodePort int32
+	// Protocol is the IP protocol of the port, e.g. "TCP".
+	Protocol string
+	// AppProtocol is the application protocol of the port, or empty if it
+	// is not set.
+	AppProtocol string
+}
+
+// portTags returns the tags generated by the PortTagTemplate for the ports.
+func (t *ServiceResource) portTags(key string, ports []apiv1.ServicePort) []string {
+	if t.PortTagTemplate == nil {
+		return nil
+	}
+
+	var tags []string
+	seen := map[string]struct{}{}
+	for _, p := range ports {
+		data := PortTemplateData{
+			Name:     p.Name,
+			Port:     p.Port,
+			NodePort: p.NodePort,
+			Protocol: string(p.Protocol),
+		}
+		if p.AppProtocol != nil {
+			data.AppProtocol = *p.AppProtocol
+		}
+
+		var buf bytes.Buffer
+		if err := t.PortTagTemplate.Execute(&buf, data); err != nil {
+			t.Log.Warn("error executing port tag template", "key", key, "port", p.Name, "error", err)
+			continue
+		}
+		tag := strings.TrimSpace(buf.String())
+		if _, ok := seen[tag]; tag == "" || ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// invalidMetaKeyChars matches the characters that are not allowed in the
+// keys of service meta.
+var invalidMetaKeyChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// topologyMeta returns a copy of meta with the values of the node's labels
+// in TopologyLabels added. meta is returned unchanged if node is nil or has
+// none of the labels.
+func (t *ServiceResource) topologyMeta(meta map[string]string, node *apiv1.Node) map[string]string {
+	if node == nil {
+		return meta
+	}
+
+	var result map[string]string
+	for _, label := range t.TopologyLabels {
+		value, ok := node.Labels[label]
+		if !ok {
+			continue
+		}
+		// Copy the meta since it is shared by all instances of the service.
+		if result == nil {
+			result = make(map[string]string, len(meta)+len(t.TopologyLabels))
+			for k, v := range meta {
+				result[k] = v
+			}
+		}
+		result[invalidMetaKeyChars.ReplaceAllString(label, "-")] = value
+	}
+	if result == nil {
+		return meta
+	}
+	return result
+}
+
 // parseTags parses the tags annotation into a slice of tags.
 // Tags are split on commas (except for escaped commas "\,").
 func parseTags(tagsAnno string) []string {
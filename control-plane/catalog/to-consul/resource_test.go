@@ -2,15 +2,19 @@ package catalog
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"text/template"
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/controller"
+	consulapi "github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/require"
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
@@ -280,6 +284,33 @@ func TestServiceResource_ConsulNodeName(t *testing.T) {
 	})
 }
 
+// Test that the cluster ID is added to the meta of the services, and that
+// services can't override it with annotations.
+func TestServiceResource_clusterID(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterID = "cluster-a"
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	svc := lbService("foo", "namespace", "1.2.3.4")
+	svc.Annotations[annotationServiceMetaPrefix+ConsulK8SClusterID] = "cluster-b"
+	_, err := client.CoreV1().Services("namespace").Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 1)
+		require.Equal(r, "cluster-a", actual[0].Service.Meta[ConsulK8SClusterID])
+	})
+}
+
 // Test k8s namespace suffix is not appended
 // when the service name annotation is provided.
 func TestServiceResource_addK8SNamespaceWithNameAnnotation(t *testing.T) {
@@ -1266,6 +1297,228 @@ func TestServiceResource_clusterIPTargetPortNamed(t *testing.T) {
 	})
 }
 
+// Test that the appProtocol of the ports is added to the meta and that tags
+// are generated from the ports with the port tag template.
+func TestServiceResource_portAppProtocolAndTags(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+	serviceResource.ConsulK8STag = TestConsulK8STag
+	serviceResource.PortTagTemplate = template.Must(template.New("").Parse(
+		"{{ if .AppProtocol }}{{ .Name }}-{{ .AppProtocol }}{{ end }}"))
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the service
+	http := "http"
+	grpc := "grpc"
+	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	svc.Spec.Ports = []apiv1.ServicePort{
+		{Name: "http", Port: 80, TargetPort: intstr.FromInt(8080), AppProtocol: &http},
+		{Name: "rpc", Port: 8500, TargetPort: intstr.FromInt(2000), AppProtocol: &grpc},
+		{Name: "metrics", Port: 9102, TargetPort: intstr.FromInt(9102)},
+	}
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert the endpoints
+	createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 2)
+		for _, reg := range actual {
+			require.Equal(r, []string{TestConsulK8STag, "http-http", "rpc-grpc"}, reg.Service.Tags)
+			require.Equal(r, "80", reg.Service.Meta["port-http"])
+			require.Equal(r, "http", reg.Service.Meta["port-http-app-protocol"])
+			require.Equal(r, "grpc", reg.Service.Meta["port-rpc-app-protocol"])
+			require.Equal(r, "9102", reg.Service.Meta["port-metrics"])
+			require.NotContains(r, reg.Service.Meta, "port-metrics-app-protocol")
+		}
+	})
+}
+
+// Test that only the ports in the service-ports annotation are synced, and that
+// the first of them is the instance port.
+func TestServiceResource_clusterIPAnnotatedPorts(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the service
+	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	svc.Annotations[annotationServicePorts] = "rpc, missing"
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert the endpoints
+	createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 2)
+		for _, reg := range actual {
+			require.Equal(r, 2000, reg.Service.Port)
+			require.Equal(r, "8500", reg.Service.Meta["port-rpc"])
+			require.NotContains(r, reg.Service.Meta, "port-http")
+		}
+	})
+}
+
+// Test that the weight annotation sets the weights of the service instances.
+func TestServiceResource_clusterIPAnnotatedWeight(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		weight     string
+		expWeights consulapi.AgentWeights
+	}{
+		"valid weight": {
+			weight:     "10",
+			expWeights: consulapi.AgentWeights{Passing: 10, Warning: 1},
+		},
+		"invalid weight is ignored": {
+			weight:     "0",
+			expWeights: consulapi.AgentWeights{},
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			client := fake.NewSimpleClientset()
+			syncer := newTestSyncer()
+			serviceResource := defaultServiceResource(client, syncer)
+			serviceResource.ClusterIPSync = true
+
+			// Start the controller
+			closer := controller.TestControllerRun(&serviceResource)
+			defer closer()
+
+			// Insert the service
+			svc := clusterIPService("foo", metav1.NamespaceDefault)
+			svc.Annotations[annotationServiceWeight] = c.weight
+			_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+			require.NoError(t, err)
+
+			// Insert the endpoints
+			createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+
+			// Verify what we got
+			retry.Run(t, func(r *retry.R) {
+				syncer.Lock()
+				defer syncer.Unlock()
+				actual := syncer.Registrations
+				require.Len(r, actual, 2)
+				for _, reg := range actual {
+					require.Equal(r, c.expWeights, reg.Service.Weights)
+				}
+			})
+		})
+	}
+}
+
+// Test that the health check path annotation adds an HTTP health check to each
+// service instance.
+func TestServiceResource_clusterIPAnnotatedHealthCheckPath(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the service
+	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	svc.Annotations[annotationServiceHealthCheckPath] = "healthz"
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert the endpoints
+	createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 2)
+		for _, reg := range actual {
+			require.Len(r, reg.Checks, 1)
+			check := reg.Checks[0]
+			require.Equal(r, reg.Service.ID+"/http", check.CheckID)
+			require.Equal(r, reg.Service.ID, check.ServiceID)
+			require.Equal(r, "foo", check.ServiceName)
+			require.Equal(r, consulapi.HealthCritical, check.Status)
+			require.Equal(r, fmt.Sprintf("http://%s:8080/healthz", reg.Service.Address), check.Definition.HTTP)
+		}
+	})
+}
+
+// Test that the topology labels of the nodes are added to the meta of the
+// service instances on the nodes.
+func TestServiceResource_topologyLabels(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+	serviceResource.TopologyLabels = []string{"topology.kubernetes.io/zone", "topology.kubernetes.io/region"}
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the nodes. Only the first node has a region label.
+	node1, node2 := createNodes(t, client)
+	node1.Labels = map[string]string{"topology.kubernetes.io/zone": "us-east-1a", "topology.kubernetes.io/region": "us-east-1"}
+	_, err := client.CoreV1().Nodes().Update(context.Background(), node1, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	node2.Labels = map[string]string{"topology.kubernetes.io/zone": "us-east-1b"}
+	_, err = client.CoreV1().Nodes().Update(context.Background(), node2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// Insert the service
+	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	_, err = client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert the endpoints
+	createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 2)
+		require.Equal(r, "1.1.1.1", actual[0].Service.Address)
+		require.Equal(r, "us-east-1a", actual[0].Service.Meta["topology-kubernetes-io-zone"])
+		require.Equal(r, "us-east-1", actual[0].Service.Meta["topology-kubernetes-io-region"])
+		require.Equal(r, "2.2.2.2", actual[1].Service.Address)
+		require.Equal(r, "us-east-1b", actual[1].Service.Meta["topology-kubernetes-io-zone"])
+		require.NotContains(r, actual[1].Service.Meta, "topology-kubernetes-io-region")
+	})
+}
+
 // Test allow/deny namespace lists.
 func TestServiceResource_AllowDenyNamespaces(t *testing.T) {
 	t.Parallel()
@@ -1312,8 +1565,7 @@ func TestServiceResource_AllowDenyNamespaces(t *testing.T) {
 			client := fake.NewSimpleClientset()
 			syncer := newTestSyncer()
 			serviceResource := defaultServiceResource(client, syncer)
-			serviceResource.AllowK8sNamespacesSet = c.AllowList
-			serviceResource.DenyK8sNamespacesSet = c.DenyList
+			serviceResource.Filter = &ServiceFilter{AllowNamespaces: c.AllowList, DenyNamespaces: c.DenyList}
 
 			// Start the controller
 			closer := controller.TestControllerRun(&serviceResource)
@@ -1353,6 +1605,129 @@ func TestServiceResource_AllowDenyNamespaces(t *testing.T) {
 	}
 }
 
+// Test that services are synced or deregistered when the labels of their
+// namespace start or stop matching the namespace selector.
+func TestServiceResource_namespaceSelector(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.Filter.NamespaceSelector = labels.SelectorFromSet(labels.Set{"sync": "true"})
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// The service is created before its namespace is known.
+	_, err := client.CoreV1().Services("foo").Create(context.Background(), lbService("foo", "foo", "1.2.3.4"), metav1.CreateOptions{})
+	require.NoError(t, err)
+	ns := &apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "foo", Labels: map[string]string{"sync": "true"}}}
+	_, err = client.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		require.Len(r, syncer.Registrations, 1)
+	})
+
+	// Removing the label stops syncing the service.
+	ns.Labels = nil
+	_, err = client.CoreV1().Namespaces().Update(context.Background(), ns, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		require.Len(r, syncer.Registrations, 0)
+	})
+}
+
+// Test that the service-sync annotation of a namespace overrides the default
+// and is overridden by the annotation of a service.
+func TestServiceResource_namespaceAnnotation(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ExplicitEnable = true
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	ns := &apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "foo",
+		Annotations: map[string]string{annotationServiceSync: "true"},
+	}}
+	_, err := client.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+	require.NoError(t, err)
+	_, err = client.CoreV1().Services("foo").Create(context.Background(), lbService("enabled", "foo", "1.2.3.4"), metav1.CreateOptions{})
+	require.NoError(t, err)
+	disabled := lbService("disabled", "foo", "1.2.3.5")
+	disabled.Annotations[annotationServiceSync] = "false"
+	_, err = client.CoreV1().Services("foo").Create(context.Background(), disabled, metav1.CreateOptions{})
+	require.NoError(t, err)
+	_, err = client.CoreV1().Services("bar").Create(context.Background(), lbService("other", "bar", "1.2.3.6"), metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		require.Len(r, syncer.Registrations, 1)
+		require.Equal(r, "enabled", syncer.Registrations[0].Service.Service)
+	})
+}
+
+// Test that replacing the filter syncs the services it allows and
+// deregisters the ones it denies.
+func TestServiceResource_SetFilter(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	for _, name := range []string{"web", "internal-api"} {
+		svc := lbService(name, metav1.NamespaceDefault, "1.2.3.4")
+		svc.Labels = map[string]string{"app": name}
+		_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		require.Len(r, syncer.Registrations, 2)
+	})
+
+	// The deny rule takes precedence over the service selector.
+	filter, err := FilterConfig{
+		ServiceSelector: "app in (web,internal-api)",
+		DenyServices:    []string{".*/internal-.*"},
+	}.Filter()
+	require.NoError(t, err)
+	serviceResource.SetFilter(filter)
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		require.Len(r, syncer.Registrations, 1)
+		require.Equal(r, "web", syncer.Registrations[0].Service.Service)
+	})
+
+	// Services that are allowed again are synced again.
+	filter, err = FilterConfig{}.Filter()
+	require.NoError(t, err)
+	serviceResource.SetFilter(filter)
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		require.Len(r, syncer.Registrations, 2)
+	})
+}
+
 // Test that services are synced to the correct destination ns
 // when a single destination namespace is set.
 func TestServiceResource_singleDestNamespace(t *testing.T) {
@@ -1638,12 +2013,11 @@ func createEndpoints(t *testing.T, client *fake.Clientset, serviceName string, n
 
 func defaultServiceResource(client kubernetes.Interface, syncer Syncer) ServiceResource {
 	return ServiceResource{
-		Log:                   hclog.Default(),
-		Client:                client,
-		Syncer:                syncer,
-		Ctx:                   context.Background(),
-		AllowK8sNamespacesSet: mapset.NewSet("*"),
-		DenyK8sNamespacesSet:  mapset.NewSet(),
-		ConsulNodeName:        ConsulSyncNodeName,
+		Log:            hclog.Default(),
+		Client:         client,
+		Syncer:         syncer,
+		Ctx:            context.Background(),
+		Filter:         &ServiceFilter{AllowNamespaces: mapset.NewSet("*"), DenyNamespaces: mapset.NewSet()},
+		ConsulNodeName: ConsulSyncNodeName,
 	}
 }
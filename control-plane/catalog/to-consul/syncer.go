@@ -2,11 +2,13 @@ package catalog
 
 import (
 	"context"
+	"net/http"
 	"sync"
 	"time"
 
 	"github.com/cenkalti/backoff"
 	mapset "github.com/deckarep/golang-set"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
 	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/go-hclog"
@@ -69,6 +71,15 @@ type ConsulSyncer struct {
 	// The Consul node name to register services with.
 	ConsulNodeName string
 
+	// ClusterID is the ID of the Kubernetes cluster, when several clusters
+	// sync services into the same Consul datacenter. Services registered by
+	// other clusters are never overwritten or deregistered.
+	ClusterID string
+
+	// Audit records the service instances the syncer registers and
+	// deregisters. It records nothing if nil.
+	Audit *consul.AuditTrail
+
 	// ConsulNodeServicesClient is used to list services for a node. We use a
 	// separate client for this API call that handles older version of Consul.
 	ConsulNodeServicesClient ConsulNodeServicesClient
@@ -288,6 +299,10 @@ func (s *ConsulSyncer) watchService(ctx context.Context, name, namespace string)
 		s.lock.Lock()
 
 		for _, svc := range services {
+			if !s.owns(svc) {
+				continue
+			}
+
 			// Make sure the namespace exists before we run checks against it
 			if _, ok := s.serviceNames[namespace]; ok {
 				// If the service is valid and its info isn't nil, we don't deregister it
@@ -333,6 +348,12 @@ func (s *ConsulSyncer) scheduleReapServiceLocked(name, namespace string) error {
 
 	// Create deregistrations for all of these
 	for _, svc := range services {
+		if !s.owns(svc) {
+			s.Log.Debug("[scheduleReapServiceLocked] skipping service registered by another cluster",
+				"service id", svc.ServiceID,
+				"cluster id", svc.ServiceMeta[ConsulK8SClusterID])
+			continue
+		}
 		s.deregs[svc.ServiceID] = &api.CatalogDeregistration{
 			Node:      svc.Node,
 			ServiceID: svc.ServiceID,
@@ -404,16 +425,43 @@ func (s *ConsulSyncer) syncFull(ctx context.Context) {
 				"service-id", r.ServiceID,
 				"service-consul-namespace", r.Namespace,
 				"err", err)
+			continue
 		}
+		s.Audit.Record(nil, consul.AuditWrite{
+			Operation: consul.AuditOpDeregister,
+			Kind:      consul.AuditKindCatalogService,
+			Name:      r.ServiceID,
+			Namespace: r.Namespace,
+		})
 	}
 
 	// Always clear deregistrations, they'll repopulate if we had errors
 	s.deregs = make(map[string]*api.CatalogDeregistration)
 
+	// Set the status of the HTTP health checks of the service instances.
+	var rs []*api.CatalogRegistration
+	for _, services := range s.namespaces {
+		for _, r := range services {
+			rs = append(rs, r)
+		}
+	}
+	probeHealthChecks(ctx, http.DefaultClient, rs)
+
 	// Register all the services. This will overwrite any changes that
-	// may have been made to the registered services.
+	// may have been made to the registered services, but not the services
+	// registered by other clusters on the same node.
+	foreign := s.foreignServiceIDs()
 	for _, services := range s.namespaces {
 		for _, r := range services {
+			if owner, ok := foreign[r.Service.Namespace][r.Service.ID]; ok {
+				s.Log.Warn("not registering service, it is registered by another cluster",
+					"node-name", r.Node,
+					"service-id", r.Service.ID,
+					"consul-namespace-name", r.Service.Namespace,
+					"cluster-id", owner)
+				continue
+			}
+
 			if s.EnableNamespaces {
 				_, err := namespaces.EnsureExists(s.Client, r.Service.Namespace, s.CrossNamespaceACLPolicy)
 				if err != nil {
@@ -436,6 +484,13 @@ func (s *ConsulSyncer) syncFull(ctx context.Context) {
 					"err", err)
 				continue
 			}
+			s.Audit.Record(nil, consul.AuditWrite{
+				Operation: consul.AuditOpRegister,
+				Kind:      consul.AuditKindCatalogService,
+				Name:      r.Service.ID,
+				Namespace: r.Service.Namespace,
+				Payload:   r,
+			})
 
 			s.Log.Debug("registered service instance",
 				"node-name", r.Node,
@@ -446,6 +501,53 @@ func (s *ConsulSyncer) syncFull(ctx context.Context) {
 	}
 }
 
+// owns returns true if the service instance was registered by this cluster.
+// Instances registered before cluster IDs were set are owned by the cluster
+// that registers services on the same node, or by every cluster if this
+// cluster has no ID.
+func (s *ConsulSyncer) owns(svc *api.CatalogService) bool {
+	if owner, ok := svc.ServiceMeta[ConsulK8SClusterID]; ok {
+		return owner == s.ClusterID
+	}
+	return s.ClusterID == "" || svc.Node == s.ConsulNodeName
+}
+
+// foreignServiceIDs returns the IDs of the service instances on the node
+// that were registered by other clusters, keyed by Consul namespace and
+// mapped to the ID of the cluster. They are only registered by another
+// cluster if both clusters use the same node name. If the instances can't be
+// listed, it returns nil so that the sync isn't blocked.
+func (s *ConsulSyncer) foreignServiceIDs() map[string]map[string]string {
+	opts := &api.QueryOptions{AllowStale: true}
+	if s.EnableNamespaces {
+		opts.Namespace = "*"
+	}
+	nodeServices, _, err := s.Client.Catalog().NodeServiceList(s.ConsulNodeName, opts)
+	if err != nil {
+		s.Log.Warn("error listing services of node to check their owner",
+			"node-name", s.ConsulNodeName,
+			"err", err)
+		return nil
+	}
+	// The node does not exist yet if nothing has been registered.
+	if nodeServices == nil {
+		return nil
+	}
+
+	foreign := make(map[string]map[string]string)
+	for _, svc := range nodeServices.Services {
+		owner, ok := svc.Meta[ConsulK8SClusterID]
+		if !ok || owner == s.ClusterID {
+			continue
+		}
+		if foreign[svc.Namespace] == nil {
+			foreign[svc.Namespace] = make(map[string]string)
+		}
+		foreign[svc.Namespace][svc.ID] = owner
+	}
+	return foreign
+}
+
 func (s *ConsulSyncer) init() {
 	s.lock.Lock()
 	defer s.lock.Unlock()
@@ -180,6 +180,66 @@ func TestConsulSyncer_reapService(t *testing.T) {
 	}
 }
 
+// Test that the syncer neither reaps nor overwrites the services registered
+// by other clusters in the same datacenter.
+func TestConsulSyncer_clusterID(t *testing.T) {
+	t.Parallel()
+
+	a, err := testutil.NewTestServerConfigT(t, nil)
+	require.NoError(t, err)
+	defer a.Stop()
+	client, err := api.NewClient(&api.Config{
+		Address: a.HTTPAddr,
+	})
+	require.NoError(t, err)
+
+	// Create services directly in Consul as other clusters would have.
+	register := func(node, service, clusterID string) {
+		svc := testRegistration(node, service, "default")
+		if clusterID != "" {
+			svc.Service.Meta[ConsulK8SClusterID] = clusterID
+		}
+		_, err := client.Catalog().Register(svc, nil)
+		require.NoError(t, err)
+	}
+	// The same instance as the one synced below, registered by a cluster with the same node name.
+	register(ConsulSyncNodeName, "bar", "cluster-b")
+	// An instance of the synced service on the node of another cluster.
+	register("other-sync", "bar", "cluster-b")
+	// A service that isn't synced, registered by a cluster with the same node name.
+	register(ConsulSyncNodeName, "baz", "cluster-b")
+	// A service that isn't synced, registered on our node before the cluster ID was set.
+	register(ConsulSyncNodeName, "qux", "")
+
+	s, closer := testConsulSyncerWithConfig(client, func(s *ConsulSyncer) {
+		s.ClusterID = "cluster-a"
+	})
+	defer closer()
+
+	bar := testRegistration(ConsulSyncNodeName, "bar", "default")
+	bar.Service.Meta[ConsulK8SClusterID] = "cluster-a"
+	s.Sync([]*api.CatalogRegistration{bar})
+
+	retry.Run(t, func(r *retry.R) {
+		quxInstances, _, err := client.Catalog().Service("qux", "", nil)
+		require.NoError(r, err)
+		require.Len(r, quxInstances, 0)
+	})
+
+	// Wait for another full sync and check that the services of the other
+	// cluster are unchanged.
+	time.Sleep(2 * s.SyncPeriod)
+	barInstances, _, err := client.Catalog().Service("bar", "", nil)
+	require.NoError(t, err)
+	require.Len(t, barInstances, 2)
+	for _, instance := range barInstances {
+		require.Equal(t, "cluster-b", instance.ServiceMeta[ConsulK8SClusterID])
+	}
+	bazInstances, _, err := client.Catalog().Service("baz", "", nil)
+	require.NoError(t, err)
+	require.Len(t, bazInstances, 1)
+}
+
 // Test that the syncer doesn't reap any services until the initial sync has
 // been performed.
 func TestConsulSyncer_noReapingUntilInitialSync(t *testing.T) {
@@ -4,7 +4,9 @@ import (
 	"os"
 
 	cmdACLInit "github.com/hashicorp/consul-k8s/control-plane/subcommand/acl-init"
+	cmdBenchmarkInject "github.com/hashicorp/consul-k8s/control-plane/subcommand/benchmark-inject"
 	cmdConnectInit "github.com/hashicorp/consul-k8s/control-plane/subcommand/connect-init"
+	cmdConsulAPIProxy "github.com/hashicorp/consul-k8s/control-plane/subcommand/consul-api-proxy"
 	cmdConsulLogout "github.com/hashicorp/consul-k8s/control-plane/subcommand/consul-logout"
 	cmdConsulSidecar "github.com/hashicorp/consul-k8s/control-plane/subcommand/consul-sidecar"
 	cmdController "github.com/hashicorp/consul-k8s/control-plane/subcommand/controller"
@@ -13,12 +15,16 @@ import (
 	cmdGetConsulClientCA "github.com/hashicorp/consul-k8s/control-plane/subcommand/get-consul-client-ca"
 	cmdGossipEncryptionAutogenerate "github.com/hashicorp/consul-k8s/control-plane/subcommand/gossip-encryption-autogenerate"
 	cmdInjectConnect "github.com/hashicorp/consul-k8s/control-plane/subcommand/inject-connect"
+	cmdMigrationCutover "github.com/hashicorp/consul-k8s/control-plane/subcommand/migration-cutover"
 	cmdPartitionInit "github.com/hashicorp/consul-k8s/control-plane/subcommand/partition-init"
+	cmdRedirectTraffic "github.com/hashicorp/consul-k8s/control-plane/subcommand/redirect-traffic"
 	cmdServerACLInit "github.com/hashicorp/consul-k8s/control-plane/subcommand/server-acl-init"
 	cmdServiceAddress "github.com/hashicorp/consul-k8s/control-plane/subcommand/service-address"
 	cmdSyncCatalog "github.com/hashicorp/consul-k8s/control-plane/subcommand/sync-catalog"
 	cmdTLSInit "github.com/hashicorp/consul-k8s/control-plane/subcommand/tls-init"
+	cmdTProxyNodeHelper "github.com/hashicorp/consul-k8s/control-plane/subcommand/tproxy-node-helper"
 	cmdVersion "github.com/hashicorp/consul-k8s/control-plane/subcommand/version"
+	cmdWaitForProxy "github.com/hashicorp/consul-k8s/control-plane/subcommand/wait-for-proxy"
 	webhookCertManager "github.com/hashicorp/consul-k8s/control-plane/subcommand/webhook-cert-manager"
 	"github.com/hashicorp/consul-k8s/control-plane/version"
 	"github.com/mitchellh/cli"
@@ -98,6 +104,30 @@ func init() {
 		"gossip-encryption-autogenerate": func() (cli.Command, error) {
 			return &cmdGossipEncryptionAutogenerate.Command{UI: ui}, nil
 		},
+
+		"migration-cutover": func() (cli.Command, error) {
+			return &cmdMigrationCutover.Command{UI: ui}, nil
+		},
+
+		"consul-api-proxy": func() (cli.Command, error) {
+			return &cmdConsulAPIProxy.Command{UI: ui}, nil
+		},
+
+		"wait-for-proxy": func() (cli.Command, error) {
+			return &cmdWaitForProxy.Command{UI: ui}, nil
+		},
+
+		"redirect-traffic": func() (cli.Command, error) {
+			return &cmdRedirectTraffic.Command{UI: ui}, nil
+		},
+
+		"tproxy-node-helper": func() (cli.Command, error) {
+			return &cmdTProxyNodeHelper.Command{UI: ui}, nil
+		},
+
+		"benchmark inject": func() (cli.Command, error) {
+			return &cmdBenchmarkInject.Command{UI: ui}, nil
+		},
 	}
 }
 
@@ -107,7 +137,8 @@ func helpFunc() cli.HelpFunc {
 	// aren't shown in any help output. We use this for prerelease functionality
 	// or advanced features.
 	hidden := map[string]struct{}{
-		"inject-connect": {},
+		"inject-connect":   {},
+		"benchmark inject": {},
 	}
 
 	var include []string
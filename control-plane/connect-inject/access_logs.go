@@ -0,0 +1,69 @@
+package connectinject
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// EnvoyAccessLogs are the default access log settings of the Envoy proxies of injected pods.
+type EnvoyAccessLogs struct {
+	// Enabled makes the proxies write access logs to stdout.
+	Enabled bool
+	// JSONFormat and TextFormat are the format of the entries. At most one of them is set,
+	// and Envoy's default format is used if neither is.
+	JSONFormat string
+	TextFormat string
+}
+
+// Validate returns an error if both formats are set or the JSON format isn't a JSON object.
+func (l EnvoyAccessLogs) Validate() error {
+	if l.JSONFormat != "" && l.TextFormat != "" {
+		return fmt.Errorf("only one of the JSON and text access log formats may be set")
+	}
+	if l.JSONFormat != "" {
+		var format map[string]interface{}
+		if err := json.Unmarshal([]byte(l.JSONFormat), &format); err != nil {
+			return fmt.Errorf("the JSON access log format must be a JSON object: %s", err)
+		}
+	}
+	return nil
+}
+
+// envoyAccessLogs returns the access log configuration of the proxy of the pod, or nil if it
+// doesn't write access logs, in which case the access log settings of the proxy-defaults apply.
+// The annotations of the pod take precedence over the defaults, and a format annotation
+// replaces both default formats.
+func envoyAccessLogs(pod corev1.Pod, defaults EnvoyAccessLogs) (*api.AccessLogsConfig, error) {
+	logs := defaults
+	if raw, ok := pod.Annotations[annotationEnvoyAccessLogs]; ok {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s annotation value of %s was invalid: %s", annotationEnvoyAccessLogs, raw, err)
+		}
+		logs.Enabled = enabled
+	}
+	jsonFormat, hasJSONFormat := pod.Annotations[annotationEnvoyAccessLogJSONFormat]
+	textFormat, hasTextFormat := pod.Annotations[annotationEnvoyAccessLogTextFormat]
+	if hasJSONFormat || hasTextFormat {
+		logs.JSONFormat = jsonFormat
+		logs.TextFormat = textFormat
+	}
+	if !logs.Enabled {
+		return nil, nil
+	}
+	if err := logs.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid access log format annotations %s and %s: %s",
+			annotationEnvoyAccessLogJSONFormat, annotationEnvoyAccessLogTextFormat, err)
+	}
+
+	return &api.AccessLogsConfig{
+		Enabled:    true,
+		Type:       api.StdOutLogSinkType,
+		JSONFormat: logs.JSONFormat,
+		TextFormat: logs.TextFormat,
+	}, nil
+}
@@ -0,0 +1,84 @@
+package connectinject
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEnvoyAccessLogs(t *testing.T) {
+	t.Parallel()
+
+	jsonFormat := `{"method": "%REQ(:METHOD)%", "code": "%RESPONSE_CODE%"}`
+	textFormat := "%REQ(:METHOD)% %RESPONSE_CODE%\n"
+	cases := map[string]struct {
+		annotations map[string]string
+		defaults    EnvoyAccessLogs
+		exp         *api.AccessLogsConfig
+		expErr      string
+	}{
+		"disabled by default": {},
+		"enabled by default": {
+			defaults: EnvoyAccessLogs{Enabled: true},
+			exp:      &api.AccessLogsConfig{Enabled: true, Type: api.StdOutLogSinkType},
+		},
+		"default format": {
+			defaults: EnvoyAccessLogs{Enabled: true, JSONFormat: jsonFormat},
+			exp:      &api.AccessLogsConfig{Enabled: true, Type: api.StdOutLogSinkType, JSONFormat: jsonFormat},
+		},
+		"enabled via annotation": {
+			annotations: map[string]string{annotationEnvoyAccessLogs: "true"},
+			exp:         &api.AccessLogsConfig{Enabled: true, Type: api.StdOutLogSinkType},
+		},
+		"disabled via annotation": {
+			annotations: map[string]string{annotationEnvoyAccessLogs: "false"},
+			defaults:    EnvoyAccessLogs{Enabled: true},
+		},
+		"format annotation replaces the default format": {
+			annotations: map[string]string{annotationEnvoyAccessLogTextFormat: textFormat},
+			defaults:    EnvoyAccessLogs{Enabled: true, JSONFormat: jsonFormat},
+			exp:         &api.AccessLogsConfig{Enabled: true, Type: api.StdOutLogSinkType, TextFormat: textFormat},
+		},
+		"format annotation doesn't enable access logs": {
+			annotations: map[string]string{annotationEnvoyAccessLogJSONFormat: jsonFormat},
+		},
+		"invalid annotation": {
+			annotations: map[string]string{annotationEnvoyAccessLogs: "yes please"},
+			expErr:      "consul.hashicorp.com/envoy-access-logs annotation value of yes please was invalid",
+		},
+		"both format annotations": {
+			annotations: map[string]string{
+				annotationEnvoyAccessLogs:          "true",
+				annotationEnvoyAccessLogJSONFormat: jsonFormat,
+				annotationEnvoyAccessLogTextFormat: textFormat,
+			},
+			expErr: "only one of the JSON and text access log formats may be set",
+		},
+		"invalid JSON format annotation": {
+			annotations: map[string]string{
+				annotationEnvoyAccessLogs:          "true",
+				annotationEnvoyAccessLogJSONFormat: "%REQ(:METHOD)%",
+			},
+			expErr: "the JSON access log format must be a JSON object",
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: c.annotations}}
+			logs, err := envoyAccessLogs(pod, c.defaults)
+			if c.expErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, logs)
+		})
+	}
+}
@@ -46,11 +46,15 @@ const (
 	// proxy in the format of `<service-name>:<local-port>,...`. The
 	// service name should map to a Consul service namd and the local port
 	// is the local port in the pod that the listener will bind to. It can
-	// be a named port.
+	// be a named port. Upstreams can also be qualified with their namespace,
+	// partition, peer and datacenter; see parseUpstreams for the formats.
 	annotationUpstreams = "consul.hashicorp.com/connect-service-upstreams"
 
 	// annotationTags is a list of tags to register with the service
 	// this is specified as a comma separated list e.g. abc,123.
+	// The value can be a template of the pod's name, namespace, node name,
+	// labels and annotations, e.g. version={{ .Labels.version }}; see
+	// podTemplateData.
 	annotationTags = "consul.hashicorp.com/service-tags"
 
 	// annotationConnectTags is a list of tags to register with the service
@@ -64,7 +68,8 @@ const (
 
 	// annotationMeta is a list of metadata key/value pairs to add to the service
 	// registration. This is specified in the format `<key>:<value>`
-	// e.g. consul.hashicorp.com/service-meta-foo:bar.
+	// e.g. consul.hashicorp.com/service-meta-foo:bar. Values can be templates
+	// of the pod's fields like the tags, e.g. {{ .NodeName }}.
 	annotationMeta = "consul.hashicorp.com/service-meta-"
 
 	// annotationSyncPeriod controls the -sync-period flag passed to the
@@ -104,6 +109,31 @@ const (
 	// passed via the -envoy-extra-args flag.
 	annotationEnvoyExtraArgs = "consul.hashicorp.com/envoy-extra-args"
 
+	// annotationEnvoyConcurrency is the number of worker threads Envoy runs, passed as --concurrency.
+	annotationEnvoyConcurrency = "consul.hashicorp.com/envoy-concurrency"
+
+	// annotationEnvoyOverloadMaxHeapSize enables the Envoy overload manager with a fixed heap monitor
+	// of this size, e.g. "512Mi". Envoy shrinks its heap and then stops accepting requests as the heap
+	// grows towards it.
+	annotationEnvoyOverloadMaxHeapSize = "consul.hashicorp.com/envoy-overload-max-heap-size"
+
+	// annotationEnvoyOverloadShrinkHeapThreshold and annotationEnvoyOverloadStopAcceptingRequestsThreshold
+	// are the fractions of the max heap size at which the overload manager shrinks the heap and stops
+	// accepting requests. They default to 0.95 and 0.98.
+	annotationEnvoyOverloadShrinkHeapThreshold            = "consul.hashicorp.com/envoy-overload-shrink-heap-threshold"
+	annotationEnvoyOverloadStopAcceptingRequestsThreshold = "consul.hashicorp.com/envoy-overload-stop-accepting-requests-threshold"
+
+	// annotationEnvoyBootstrapExtraConfig is YAML or JSON merged into the Envoy bootstrap config with
+	// --config-yaml, e.g. to add static clusters or stats sinks. Lists are appended to, so it can't
+	// remove what the generated bootstrap config contains.
+	annotationEnvoyBootstrapExtraConfig = "consul.hashicorp.com/envoy-bootstrap-extra-config"
+
+	// annotationEnvoyBootstrapConfigMap is set on a namespace to the name of a ConfigMap in the
+	// namespace whose keys are Envoy bootstrap config fragments, e.g. stats sinks, tracing or the
+	// overload manager. They are merged with the envoy-bootstrap-extra-config annotation of the
+	// pods of the namespace.
+	annotationEnvoyBootstrapConfigMap = "consul.hashicorp.com/envoy-bootstrap-config-map"
+
 	// annotationConsulNamespace is the Consul namespace the service is registered into.
 	annotationConsulNamespace = "consul.hashicorp.com/consul-namespace"
 
@@ -135,6 +165,95 @@ const (
 	// to point to the Envoy proxy when running in Transparent Proxy mode.
 	annotationTransparentProxyOverwriteProbes = "consul.hashicorp.com/transparent-proxy-overwrite-probes"
 
+	// AnnotationTransparentProxyInitMode selects how the traffic redirection rules of the pod are applied
+	// when running in Transparent Proxy mode. The handler sets it to the mode it selected so that the
+	// transparent proxy node helper can find the pods it applies the rules for.
+	AnnotationTransparentProxyInitMode = "consul.hashicorp.com/transparent-proxy-init-mode"
+
+	// AnnotationNodeProxy opts the pod into the experimental node proxy mode. Pods in node proxy mode
+	// get no sidecars: the node proxy on their node handles their mesh traffic. It takes a boolean
+	// value (true/false) and requires node proxy mode to be enabled.
+	AnnotationNodeProxy = "consul.hashicorp.com/node-proxy"
+
+	// annotationHoldApplicationUntilProxyStarts controls whether the application containers of the pod are
+	// only started once the Envoy proxy is ready and has received its certificates.
+	annotationHoldApplicationUntilProxyStarts = "consul.hashicorp.com/hold-application-until-proxy-starts"
+
+	// annotationProbeHealthChecks controls whether every Kubernetes probe and readiness gate of the pod
+	// is registered as a separate Consul health check in addition to the check mirroring pod readiness.
+	annotationProbeHealthChecks = "consul.hashicorp.com/probe-health-checks"
+
+	// annotationProbeHealthChecksExclude is a comma-separated list of probe health checks that should not
+	// be registered. Probes are referenced as "<container>.<probe>", e.g. "app.liveness", and readiness
+	// gates by their condition type.
+	annotationProbeHealthChecksExclude = "consul.hashicorp.com/probe-health-checks-exclude"
+
+	// annotationProbeHealthCheckNames is a comma-separated list of "<check>=<name>" pairs that override
+	// the names of probe health checks in Consul, e.g. "app.liveness=App Liveness".
+	annotationProbeHealthCheckNames = "consul.hashicorp.com/probe-health-check-names"
+
+	// annotationServiceLocality controls whether the service instances of the pod are registered with
+	// the region and zone of its node, so that Consul prefers instances in the same zone when routing
+	// and failing over. It takes a boolean value (true/false).
+	annotationServiceLocality = "consul.hashicorp.com/service-locality"
+
+	// annotationPeerFailover controls whether the service resolver of the service of the pod fails
+	// over to the peers that import the service. It takes a boolean value (true/false).
+	annotationPeerFailover = "consul.hashicorp.com/peer-failover"
+
+	// annotationEnvoyAccessLogs controls whether the Envoy proxy of the pod writes access logs to
+	// stdout, where they are read with kubectl logs or consul-k8s proxy accesslogs. It takes a boolean
+	// value (true/false). Requires Consul 1.15+.
+	annotationEnvoyAccessLogs = "consul.hashicorp.com/envoy-access-logs"
+
+	// annotationEnvoyAccessLogJSONFormat is the JSON object the access log entries of the Envoy proxy
+	// of the pod are formatted as, with Envoy command operators as values, e.g.
+	// '{"method": "%REQ(:METHOD)%", "code": "%RESPONSE_CODE%"}'.
+	annotationEnvoyAccessLogJSONFormat = "consul.hashicorp.com/envoy-access-log-json-format"
+
+	// annotationEnvoyAccessLogTextFormat is the template the access log entries of the Envoy proxy of
+	// the pod are formatted with, using Envoy command operators. Only one of it and
+	// annotationEnvoyAccessLogJSONFormat may be set.
+	annotationEnvoyAccessLogTextFormat = "consul.hashicorp.com/envoy-access-log-text-format"
+
+	// annotationNotReadyGracePeriod is how long the service instance of the pod stays passing after
+	// the pod becomes not ready, e.g. "10s". Readiness flaps shorter than it don't reach Consul.
+	annotationNotReadyGracePeriod = "consul.hashicorp.com/not-ready-grace-period"
+
+	// annotationDeregisterNotReadyAfter is how long the pod may be not ready before its service
+	// instance is deregistered instead of being registered as critical, e.g. "5m".
+	annotationDeregisterNotReadyAfter = "consul.hashicorp.com/deregister-not-ready-after"
+
+	// annotationDeregisterTerminatingAfter is how long after the pod starts terminating its service
+	// instance is deregistered, e.g. "30s". By default it stays registered as critical until the
+	// pod stops.
+	annotationDeregisterTerminatingAfter = "consul.hashicorp.com/deregister-terminating-after"
+
+	// annotationXDSWatchdog enables or disables the xDS watchdog of the consul sidecar, which acts on
+	// Envoy proxies that are disconnected from xDS or not ready for longer than a threshold.
+	annotationXDSWatchdog = "consul.hashicorp.com/xds-watchdog"
+
+	// annotationXDSWatchdogThreshold is how long an Envoy proxy may be disconnected from xDS before the
+	// xDS watchdog acts on it, e.g. "2m".
+	annotationXDSWatchdogThreshold = "consul.hashicorp.com/xds-watchdog-threshold"
+
+	// annotationXDSWatchdogPolicy is what the xDS watchdog does with disconnected Envoy proxies. It is
+	// "log" to only log and report them in the merged metrics, or "restart" to also restart them.
+	annotationXDSWatchdogPolicy = "consul.hashicorp.com/xds-watchdog-policy"
+
+	// annotationJobCompletion controls whether the consul sidecar stops the Envoy proxies of the pod once
+	// its application containers have exited, so that the pod completes. For pods owned by a Job, which
+	// includes the Jobs of CronJobs, with the Never restart policy it defaults to the
+	// -default-enable-job-completion flag of the injector, and otherwise to false. It can't be enabled for
+	// pods with another restart policy. Enabling it shares the process namespace of the pod, see
+	// Handler.EnableJobCompletion. It takes a boolean value (true/false).
+	annotationJobCompletion = "consul.hashicorp.com/job-completion"
+
+	// annotationJobServeTraffic controls whether the service instance of a pod owned by a Job is registered
+	// as passing while the pod is ready. By default it is registered as critical so that no traffic is
+	// routed to the short-lived pod. It takes a boolean value (true/false).
+	annotationJobServeTraffic = "consul.hashicorp.com/job-serve-traffic"
+
 	// annotationOriginalPod is the value of the pod before being overwritten by the consul
 	// webhook/handler.
 	annotationOriginalPod = "consul.hashicorp.com/original-pod"
@@ -143,6 +262,24 @@ const (
 	// registered with Consul.
 	labelServiceIgnore = "consul.hashicorp.com/service-ignore"
 
+	// labelMaintenanceMode is a label that can be added to a namespace to pause the
+	// deregistration of service instances in that namespace during planned maintenance,
+	// e.g. of Kubernetes nodes. Service instances are still registered.
+	labelMaintenanceMode = "consul.hashicorp.com/maintenance-mode"
+
+	// labelNetworkPolicy is a label that can be added to a namespace to generate NetworkPolicies
+	// for the services in that namespace that mirror the reachability of the mesh. It takes a
+	// boolean value (true/false) and requires the network policy controller to be enabled.
+	labelNetworkPolicy = "consul.hashicorp.com/network-policy"
+
+	// LabelNodeProxy is the label the handler adds to pods in node proxy mode so that the node helper
+	// can find the pods whose traffic it redirects to the node proxy.
+	LabelNodeProxy = "consul.hashicorp.com/node-proxy"
+
+	// labelPodSecurityEnforce is the namespace label of the Pod Security admission controller that
+	// sets the Pod Security Standard pods in the namespace must meet.
+	labelPodSecurityEnforce = "pod-security.kubernetes.io/enforce"
+
 	// injected is used as the annotation value for annotationInjected.
 	injected = "injected"
 
@@ -0,0 +1,187 @@
+package connectinject
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// BenchmarkResult is the result of replaying admission requests against the
+// handler with RunBenchmark.
+type BenchmarkResult struct {
+	Requests int
+	// Errors is the number of requests that were not allowed.
+	Errors int
+	// Duration is the wall clock time of all requests.
+	Duration time.Duration
+	P50      time.Duration
+	P99      time.Duration
+	Max      time.Duration
+	// AllocsPerRequest and BytesPerRequest are the heap allocations of the
+	// process during the benchmark divided by the number of requests.
+	AllocsPerRequest uint64
+	BytesPerRequest  uint64
+}
+
+// SyntheticRequests returns n admission requests for pods in the namespace.
+// The pods cycle through the shapes of pods that are commonly injected:
+// a plain pod, a pod with upstreams, a transparent proxy pod with HTTP
+// probes, a pod with metrics merging, a multi port pod, and a pod that has
+// opted out of injection.
+func SyntheticRequests(n int, namespace string) ([]admission.Request, error) {
+	requests := make([]admission.Request, 0, n)
+	for i := 0; i < n; i++ {
+		pod := syntheticPod(i)
+		raw, err := json.Marshal(pod)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				UID:       types.UID(fmt.Sprintf("benchmark-%d", i)),
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+				Resource:  metav1.GroupVersionResource{Version: "v1", Resource: "pods"},
+				Name:      pod.Name,
+				Namespace: namespace,
+				Operation: admissionv1.Create,
+				Object:    k8sruntime.RawExtension{Raw: raw},
+			},
+		})
+	}
+	return requests, nil
+}
+
+func syntheticPod(i int) *corev1.Pod {
+	pod := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("benchmark-%d", i),
+			Labels:      map[string]string{"app": "benchmark"},
+			Annotations: map[string]string{annotationInject: "true"},
+		},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: "benchmark",
+			Containers: []corev1.Container{
+				{
+					Name:  "app",
+					Image: "hashicorp/http-echo:latest",
+					Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      "benchmark-token",
+							MountPath: "/var/run/secrets/kubernetes.io/serviceaccount",
+							ReadOnly:  true,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	switch i % 6 {
+	case 1:
+		pod.Annotations[annotationUpstreams] = "db:1234,cache:1235,api.svc.dc2.peer:1236"
+	case 2:
+		pod.Annotations[keyTransparentProxy] = "true"
+		pod.Annotations[annotationTransparentProxyOverwriteProbes] = "true"
+		probe := &corev1.Probe{
+			Handler: corev1.Handler{
+				HTTPGet: &corev1.HTTPGetAction{Path: "/health", Port: intstr.FromInt(8080)},
+			},
+		}
+		pod.Spec.Containers[0].ReadinessProbe = probe
+		pod.Spec.Containers[0].LivenessProbe = probe
+	case 3:
+		pod.Annotations[annotationEnableMetrics] = "true"
+		pod.Annotations[annotationEnableMetricsMerging] = "true"
+		pod.Annotations[annotationServiceMetricsPort] = "8080"
+	case 4:
+		// Multi port pods don't support transparent proxy, so they opt out
+		// in case it is enabled for all pods.
+		pod.Annotations[annotationService] = "benchmark,benchmark-admin"
+		pod.Annotations[keyTransparentProxy] = "false"
+		admin := pod.Spec.Containers[0]
+		admin.Name = "admin"
+		admin.Ports = []corev1.ContainerPort{{Name: "admin", ContainerPort: 9090}}
+		pod.Spec.Containers = append(pod.Spec.Containers, admin)
+	case 5:
+		pod.Annotations[annotationInject] = "false"
+	}
+	return pod
+}
+
+// RunBenchmark sends the requests to the handler from concurrency goroutines
+// and measures the latency and the allocations of the requests.
+func RunBenchmark(ctx context.Context, h *Handler, requests []admission.Request, concurrency int) BenchmarkResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	latencies := make([]time.Duration, len(requests))
+	allowed := make([]bool, len(requests))
+	next := make(chan int)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				requestStart := time.Now()
+				resp := h.Handle(ctx, requests[i])
+				latencies[i] = time.Since(requestStart)
+				allowed[i] = resp.Allowed
+			}
+		}()
+	}
+	for i := range requests {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	result := BenchmarkResult{Requests: len(requests), Duration: time.Since(start)}
+	runtime.ReadMemStats(&after)
+	if len(requests) == 0 {
+		return result
+	}
+
+	for _, ok := range allowed {
+		if !ok {
+			result.Errors++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	result.P50 = percentile(latencies, 0.50)
+	result.P99 = percentile(latencies, 0.99)
+	result.Max = latencies[len(latencies)-1]
+	result.AllocsPerRequest = (after.Mallocs - before.Mallocs) / uint64(len(requests))
+	result.BytesPerRequest = (after.TotalAlloc - before.TotalAlloc) / uint64(len(requests))
+	return result
+}
+
+// percentile returns the nearest-rank percentile p of the sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
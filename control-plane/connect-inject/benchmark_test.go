@@ -0,0 +1,81 @@
+package connectinject
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mapset "github.com/deckarep/golang-set"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Every synthetic pod must be handled without errors, otherwise the
+// benchmark would measure the error paths.
+func TestRunBenchmark(t *testing.T) {
+	requests, err := SyntheticRequests(60, "default")
+	require.NoError(t, err)
+
+	result := RunBenchmark(context.Background(), benchmarkHandler(t), requests, 4)
+	require.Equal(t, 60, result.Requests)
+	require.Equal(t, 0, result.Errors)
+	require.NotZero(t, result.P50)
+	require.LessOrEqual(t, result.P50, result.P99)
+	require.LessOrEqual(t, result.P99, result.Max)
+	require.NotZero(t, result.AllocsPerRequest)
+}
+
+func TestPercentile(t *testing.T) {
+	latencies := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	require.Equal(t, time.Duration(5), percentile(latencies, 0.50))
+	require.Equal(t, time.Duration(10), percentile(latencies, 0.99))
+	require.Equal(t, time.Duration(1), percentile(latencies[:1], 0.99))
+}
+
+// BenchmarkHandlerHandle measures the injection path for each shape of
+// synthetic pod. Run it with:
+//
+//	go test ./connect-inject -run '^$' -bench HandlerHandle
+func BenchmarkHandlerHandle(b *testing.B) {
+	shapes := []string{"basic", "upstreams", "transparent-proxy", "metrics-merging", "multi-port", "not-injected"}
+	requests, err := SyntheticRequests(len(shapes), "default")
+	require.NoError(b, err)
+	h := benchmarkHandler(b)
+	ctx := context.Background()
+
+	for i, shape := range shapes {
+		req := requests[i]
+		b.Run(shape, func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				if resp := h.Handle(ctx, req); !resp.Allowed {
+					b.Fatalf("request was not allowed: %s", resp.Result.Message)
+				}
+			}
+		})
+	}
+}
+
+func benchmarkHandler(t testing.TB) *Handler {
+	s := runtime.NewScheme()
+	s.AddKnownTypes(schema.GroupVersion{Group: "", Version: "v1"}, &corev1.Pod{})
+	decoder, err := admission.NewDecoder(s)
+	require.NoError(t, err)
+
+	h := &Handler{
+		Clientset:             defaultTestClientWithNamespace(),
+		ImageConsul:           "hashicorp/consul:latest",
+		ImageEnvoy:            "envoyproxy/envoy:latest",
+		ImageConsulK8S:        "hashicorp/consul-k8s:latest",
+		AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:  mapset.NewSet(),
+		TProxyOverwriteProbes: true,
+		Log:                   logr.Discard(),
+	}
+	require.NoError(t, h.InjectDecoder(decoder))
+	return h
+}
@@ -0,0 +1,193 @@
+package connectinject
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// envoyBootstrapFragmentFields are the Envoy bootstrap config fields that the fragments of an
+// envoy-bootstrap-config-map ConfigMap can set, and the kind of their values. Other fields, e.g.
+// admin or node, are generated by Consul and can't be changed by a namespace.
+var envoyBootstrapFragmentFields = map[string]string{
+	"stats_sinks":          "list",
+	"stats_config":         "object",
+	"stats_flush_interval": "string",
+	"tracing":              "object",
+	"overload_manager":     "object",
+	"static_resources":     "object",
+	"layered_runtime":      "object",
+}
+
+// applyEnvoyBootstrapConfigMap merges the Envoy bootstrap config fragments of the ConfigMap named
+// by the annotationEnvoyBootstrapConfigMap annotation of the namespace into the
+// annotationEnvoyBootstrapExtraConfig annotation of the pod. It does nothing unless
+// EnableEnvoyBootstrapConfigMaps is set.
+func (h *Handler) applyEnvoyBootstrapConfigMap(ctx context.Context, ns corev1.Namespace, pod *corev1.Pod) error {
+	name, ok := ns.Annotations[annotationEnvoyBootstrapConfigMap]
+	if !h.EnableEnvoyBootstrapConfigMaps || !ok {
+		return nil
+	}
+	configMap, err := h.Clientset.CoreV1().ConfigMaps(ns.Name).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("getting Envoy bootstrap ConfigMap %s/%s named by annotation %s: %s", ns.Name, name, annotationEnvoyBootstrapConfigMap, err)
+	}
+	return mergeEnvoyBootstrapConfigMap(pod, configMap)
+}
+
+// mergeEnvoyBootstrapConfigMap merges the fragments of the ConfigMap, in the order of their keys,
+// and then the annotationEnvoyBootstrapExtraConfig annotation of the pod, and sets the annotation
+// to the result. Objects are merged and lists are appended to, like Envoy does with --config-yaml.
+// A field set to different values by two of them is a conflict, since it's not obvious which one
+// should win.
+func mergeEnvoyBootstrapConfigMap(pod *corev1.Pod, configMap *corev1.ConfigMap) error {
+	keys := make([]string, 0, len(configMap.Data))
+	for key := range configMap.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	merged := make(map[string]interface{})
+	for _, key := range keys {
+		source := fmt.Sprintf("key %s of ConfigMap %s/%s", key, configMap.Namespace, configMap.Name)
+		fragment, err := parseEnvoyBootstrapConfig(configMap.Data[key])
+		if err != nil {
+			return fmt.Errorf("parsing %s: %s", source, err)
+		}
+		if err := validateEnvoyBootstrapFragment(fragment); err != nil {
+			return fmt.Errorf("%s is invalid: %s", source, err)
+		}
+		if _, ok := fragment["overload_manager"]; ok {
+			if _, ok := pod.Annotations[annotationEnvoyOverloadMaxHeapSize]; ok {
+				return fmt.Errorf("%s cannot set overload_manager together with annotation %s", source, annotationEnvoyOverloadMaxHeapSize)
+			}
+		}
+		if err := mergeEnvoyBootstrapConfig(merged, fragment, ""); err != nil {
+			return fmt.Errorf("merging %s: %s", source, err)
+		}
+	}
+
+	if raw, ok := pod.Annotations[annotationEnvoyBootstrapExtraConfig]; ok {
+		config, err := parseEnvoyBootstrapConfig(raw)
+		if err != nil {
+			return fmt.Errorf("parsing annotation %s: %s", annotationEnvoyBootstrapExtraConfig, err)
+		}
+		if err := mergeEnvoyBootstrapConfig(merged, config, ""); err != nil {
+			return fmt.Errorf("merging annotation %s with ConfigMap %s/%s: %s",
+				annotationEnvoyBootstrapExtraConfig, configMap.Namespace, configMap.Name, err)
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+
+	mergedJSON, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	pod.Annotations[annotationEnvoyBootstrapExtraConfig] = string(mergedJSON)
+	return nil
+}
+
+// parseEnvoyBootstrapConfig parses YAML or JSON Envoy bootstrap config. Numbers are decoded as
+// json.Number so that large integers keep their precision.
+func parseEnvoyBootstrapConfig(raw string) (map[string]interface{}, error) {
+	config := make(map[string]interface{})
+	configJSON, err := yaml.YAMLToJSON([]byte(raw))
+	if err == nil {
+		decoder := json.NewDecoder(bytes.NewReader(configJSON))
+		decoder.UseNumber()
+		err = decoder.Decode(&config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("must be a YAML or JSON object: %s", err)
+	}
+	return config, nil
+}
+
+// validateEnvoyBootstrapFragment checks that the fragment only sets the fields of
+// envoyBootstrapFragmentFields and that their values are of the right kind.
+func validateEnvoyBootstrapFragment(fragment map[string]interface{}) error {
+	var unknown []string
+	for field, value := range fragment {
+		kind, ok := envoyBootstrapFragmentFields[field]
+		if !ok {
+			unknown = append(unknown, field)
+			continue
+		}
+		if valueKind(value) != kind {
+			return fmt.Errorf("%s must be a YAML or JSON %s", field, kind)
+		}
+		if list, ok := value.([]interface{}); ok {
+			for i, entry := range list {
+				if valueKind(entry) != "object" {
+					return fmt.Errorf("%s[%d] must be an object", field, i)
+				}
+			}
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		allowed := make([]string, 0, len(envoyBootstrapFragmentFields))
+		for field := range envoyBootstrapFragmentFields {
+			allowed = append(allowed, field)
+		}
+		sort.Strings(allowed)
+		return fmt.Errorf("unsupported fields %s, only %s can be set",
+			strings.Join(unknown, ", "), strings.Join(allowed, ", "))
+	}
+	return nil
+}
+
+// mergeEnvoyBootstrapConfig merges src into dst. Objects are merged recursively and lists are
+// appended to. It returns an error if a field of src is already set to a different value in dst.
+func mergeEnvoyBootstrapConfig(dst, src map[string]interface{}, path string) error {
+	for key, srcValue := range src {
+		fieldPath := key
+		if path != "" {
+			fieldPath = path + "." + key
+		}
+		dstValue, ok := dst[key]
+		if !ok {
+			dst[key] = srcValue
+			continue
+		}
+		switch {
+		case valueKind(dstValue) == "object" && valueKind(srcValue) == "object":
+			if err := mergeEnvoyBootstrapConfig(dstValue.(map[string]interface{}), srcValue.(map[string]interface{}), fieldPath); err != nil {
+				return err
+			}
+		case valueKind(dstValue) == "list" && valueKind(srcValue) == "list":
+			dst[key] = append(dstValue.([]interface{}), srcValue.([]interface{})...)
+		case !reflect.DeepEqual(dstValue, srcValue):
+			return fmt.Errorf("conflicting values for %s", fieldPath)
+		}
+	}
+	return nil
+}
+
+// valueKind returns the kind of a value decoded from JSON, as named by
+// envoyBootstrapFragmentFields.
+func valueKind(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "list"
+	case string:
+		return "string"
+	default:
+		return "scalar"
+	}
+}
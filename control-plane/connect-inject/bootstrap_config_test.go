@@ -0,0 +1,161 @@
+package connectinject
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMergeEnvoyBootstrapConfigMap(t *testing.T) {
+	cases := map[string]struct {
+		data        map[string]string
+		annotations map[string]string
+		expConfig   string
+		expErr      string
+	}{
+		"empty": {
+			expConfig: "",
+		},
+		"fragments are merged in key order": {
+			data: map[string]string{
+				"tracing.yaml": `
+tracing:
+  http:
+    name: envoy.tracers.zipkin
+`,
+				"stats.yaml": `
+stats_flush_interval: 10s
+stats_sinks:
+- name: envoy.stat_sinks.statsd
+`,
+			},
+			expConfig: `{"stats_flush_interval":"10s","stats_sinks":[{"name":"envoy.stat_sinks.statsd"}],"tracing":{"http":{"name":"envoy.tracers.zipkin"}}}`,
+		},
+		"pod annotation is merged after the fragments": {
+			data: map[string]string{
+				"stats.yaml": `{"stats_sinks": [{"name": "envoy.stat_sinks.statsd"}], "stats_config": {"use_all_default_tags": true}}`,
+			},
+			annotations: map[string]string{
+				annotationEnvoyBootstrapExtraConfig: `{"stats_sinks": [{"name": "envoy.stat_sinks.dog_statsd"}], "stats_config": {"use_all_default_tags": true}, "admin": {"access_log_path": "/dev/stdout"}}`,
+			},
+			expConfig: `{"admin":{"access_log_path":"/dev/stdout"},"stats_config":{"use_all_default_tags":true},"stats_sinks":[{"name":"envoy.stat_sinks.statsd"},{"name":"envoy.stat_sinks.dog_statsd"}]}`,
+		},
+		"large integers keep their precision": {
+			data: map[string]string{
+				"overload.yaml": `{"overload_manager": {"resource_monitors": [{"typed_config": {"max_heap_size_bytes": 9007199254740993}}]}}`,
+			},
+			expConfig: `{"overload_manager":{"resource_monitors":[{"typed_config":{"max_heap_size_bytes":9007199254740993}}]}}`,
+		},
+		"invalid fragment": {
+			data:   map[string]string{"stats.yaml": "- not an object"},
+			expErr: "parsing key stats.yaml of ConfigMap default/envoy-bootstrap: must be a YAML or JSON object: json: cannot unmarshal array into Go value of type map[string]interface {}",
+		},
+		"unsupported fields": {
+			data:   map[string]string{"stats.yaml": `{"admin": {}, "node": {}, "tracing": {}}`},
+			expErr: "key stats.yaml of ConfigMap default/envoy-bootstrap is invalid: unsupported fields admin, node, only layered_runtime, overload_manager, static_resources, stats_config, stats_flush_interval, stats_sinks, tracing can be set",
+		},
+		"wrong kind": {
+			data:   map[string]string{"stats.yaml": `{"stats_sinks": {"name": "envoy.stat_sinks.statsd"}}`},
+			expErr: "key stats.yaml of ConfigMap default/envoy-bootstrap is invalid: stats_sinks must be a YAML or JSON list",
+		},
+		"wrong kind of list entry": {
+			data:   map[string]string{"stats.yaml": `{"stats_sinks": ["envoy.stat_sinks.statsd"]}`},
+			expErr: "key stats.yaml of ConfigMap default/envoy-bootstrap is invalid: stats_sinks[0] must be an object",
+		},
+		"conflicting fragments": {
+			data: map[string]string{
+				"a.yaml": `{"tracing": {"http": {"name": "envoy.tracers.zipkin"}}}`,
+				"b.yaml": `{"tracing": {"http": {"name": "envoy.tracers.datadog"}}}`,
+			},
+			expErr: "merging key b.yaml of ConfigMap default/envoy-bootstrap: conflicting values for tracing.http.name",
+		},
+		"fragment conflicting with pod annotation": {
+			data: map[string]string{"stats.yaml": `{"stats_flush_interval": "10s"}`},
+			annotations: map[string]string{
+				annotationEnvoyBootstrapExtraConfig: `{"stats_flush_interval": "5s"}`,
+			},
+			expErr: "merging annotation consul.hashicorp.com/envoy-bootstrap-extra-config with ConfigMap default/envoy-bootstrap: conflicting values for stats_flush_interval",
+		},
+		"fragment conflicting with overload annotations": {
+			data: map[string]string{"overload.yaml": `{"overload_manager": {"refresh_interval": "1s"}}`},
+			annotations: map[string]string{
+				annotationEnvoyOverloadMaxHeapSize: "512Mi",
+			},
+			expErr: "key overload.yaml of ConfigMap default/envoy-bootstrap cannot set overload_manager together with annotation consul.hashicorp.com/envoy-overload-max-heap-size",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: c.annotations}}
+			err := mergeEnvoyBootstrapConfigMap(pod, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "envoy-bootstrap", Namespace: "default"},
+				Data:       c.data,
+			})
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expConfig, pod.Annotations[annotationEnvoyBootstrapExtraConfig])
+		})
+	}
+}
+
+func TestHandlerApplyEnvoyBootstrapConfigMap(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "envoy-bootstrap", Namespace: "default"},
+		Data:       map[string]string{"stats.yaml": `{"stats_flush_interval": "10s"}`},
+	}
+	annotated := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "default",
+		Annotations: map[string]string{annotationEnvoyBootstrapConfigMap: "envoy-bootstrap"},
+	}}
+
+	cases := map[string]struct {
+		enabled   bool
+		namespace corev1.Namespace
+		expConfig string
+		expErr    string
+	}{
+		"disabled": {
+			namespace: annotated,
+		},
+		"namespace without annotation": {
+			enabled:   true,
+			namespace: corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		},
+		"annotated namespace": {
+			enabled:   true,
+			namespace: annotated,
+			expConfig: `{"stats_flush_interval":"10s"}`,
+		},
+		"missing ConfigMap": {
+			enabled: true,
+			namespace: corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "default",
+				Annotations: map[string]string{annotationEnvoyBootstrapConfigMap: "missing"},
+			}},
+			expErr: `getting Envoy bootstrap ConfigMap default/missing named by annotation consul.hashicorp.com/envoy-bootstrap-config-map: configmaps "missing" not found`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := Handler{
+				Clientset:                      fake.NewSimpleClientset(configMap),
+				EnableEnvoyBootstrapConfigMaps: c.enabled,
+			}
+			pod := &corev1.Pod{}
+			err := h.applyEnvoyBootstrapConfigMap(context.Background(), c.namespace, pod)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expConfig, pod.Annotations[annotationEnvoyBootstrapExtraConfig])
+		})
+	}
+}
@@ -2,20 +2,47 @@ package connectinject
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
 
-// consulSidecar starts the consul-sidecar command to only run
-// the metrics merging server when metrics merging feature is enabled.
+const consulSidecarContainer = "consul-sidecar"
+
+// Policies of the xDS watchdog of the consul sidecar.
+const (
+	xdsWatchdogPolicyLog     = "log"
+	xdsWatchdogPolicyRestart = "restart"
+)
+
+// xdsWatchdogConfig is the configuration of the xDS watchdog of a pod.
+type xdsWatchdogConfig struct {
+	enabled   bool
+	threshold time.Duration
+	policy    string
+}
+
+// consulSidecar starts the consul-sidecar command to run the metrics merging
+// server when the metrics merging feature is enabled, the xDS watchdog when
+// it is enabled, and job completion for pods of Jobs.
 // It always disables service registration because for connect we no longer
 // need to keep services registered as this is handled in the endpoints-controller.
 func (h *Handler) consulSidecar(pod corev1.Pod) (corev1.Container, error) {
-	metricsPorts, err := h.MetricsConfig.mergedMetricsServerConfiguration(pod)
+	runMetricsMerging, err := h.MetricsConfig.shouldRunMergedMetricsServer(pod)
 	if err != nil {
 		return corev1.Container{}, err
 	}
+	watchdog, err := h.xdsWatchdogConfig(pod)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+	jobCompletion, err := jobCompletionEnabled(pod, h.EnableJobCompletion)
+	if err != nil {
+		return corev1.Container{}, fmt.Errorf("%s annotation value of %s was invalid: %s", annotationJobCompletion, pod.Annotations[annotationJobCompletion], err)
+	}
 
 	resources, err := h.consulSidecarResources(pod)
 	if err != nil {
@@ -26,16 +53,40 @@ func (h *Handler) consulSidecar(pod corev1.Pod) (corev1.Container, error) {
 		"consul-k8s-control-plane",
 		"consul-sidecar",
 		"-enable-service-registration=false",
-		"-enable-metrics-merging=true",
-		fmt.Sprintf("-merged-metrics-port=%s", metricsPorts.mergedPort),
-		fmt.Sprintf("-service-metrics-port=%s", metricsPorts.servicePort),
-		fmt.Sprintf("-service-metrics-path=%s", metricsPorts.servicePath),
+		fmt.Sprintf("-enable-metrics-merging=%t", runMetricsMerging),
+	}
+	if runMetricsMerging {
+		metricsPorts, err := h.MetricsConfig.mergedMetricsServerConfiguration(pod)
+		if err != nil {
+			return corev1.Container{}, err
+		}
+		command = append(command,
+			fmt.Sprintf("-merged-metrics-port=%s", metricsPorts.mergedPort),
+			fmt.Sprintf("-service-metrics-port=%s", metricsPorts.servicePort),
+			fmt.Sprintf("-service-metrics-path=%s", metricsPorts.servicePath),
+		)
+	}
+	if watchdog.enabled {
+		command = append(command,
+			"-enable-xds-watchdog=true",
+			fmt.Sprintf("-xds-watchdog-threshold=%s", watchdog.threshold),
+			fmt.Sprintf("-xds-watchdog-policy=%s", watchdog.policy),
+			fmt.Sprintf("-xds-watchdog-envoy-admin-ports=%s", h.envoyAdminPorts(pod)),
+		)
+	}
+	if jobCompletion {
+		command = append(command,
+			"-enable-job-completion=true",
+			fmt.Sprintf("-job-completion-envoy-admin-ports=%s", h.envoyAdminPorts(pod)),
+		)
+	}
+	command = append(command,
 		fmt.Sprintf("-log-level=%s", h.LogLevel),
 		fmt.Sprintf("-log-json=%t", h.LogJSON),
-	}
+	)
 
 	return corev1.Container{
-		Name:  "consul-sidecar",
+		Name:  consulSidecarContainer,
 		Image: h.ImageConsulK8S,
 		VolumeMounts: []corev1.VolumeMount{
 			{
@@ -48,6 +99,59 @@ func (h *Handler) consulSidecar(pod corev1.Pod) (corev1.Container, error) {
 	}, nil
 }
 
+// envoyAdminPorts returns the comma separated admin API ports of the Envoy
+// sidecars of the pod. Each Envoy sidecar of a multi port pod has its own
+// admin port.
+func (h *Handler) envoyAdminPorts(pod corev1.Pod) string {
+	var adminPorts []string
+	services := len(h.annotatedServiceNames(pod))
+	if services == 0 {
+		services = 1
+	}
+	for i := 0; i < services; i++ {
+		adminPorts = append(adminPorts, strconv.Itoa(19000+i))
+	}
+	return strings.Join(adminPorts, ",")
+}
+
+// xdsWatchdogConfig returns the configuration of the xDS watchdog of the pod,
+// which is the configuration of the handler overridden by the annotations of
+// the pod.
+func (h *Handler) xdsWatchdogConfig(pod corev1.Pod) (xdsWatchdogConfig, error) {
+	config := xdsWatchdogConfig{
+		enabled:   h.EnableXDSWatchdog,
+		threshold: h.XDSWatchdogThreshold,
+		policy:    h.XDSWatchdogPolicy,
+	}
+	if raw, ok := pod.Annotations[annotationXDSWatchdog]; ok && raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return xdsWatchdogConfig{}, fmt.Errorf("%s annotation value of %s was invalid: %s", annotationXDSWatchdog, raw, err)
+		}
+		config.enabled = enabled
+	}
+	if raw, ok := pod.Annotations[annotationXDSWatchdogThreshold]; ok && raw != "" {
+		threshold, err := time.ParseDuration(raw)
+		if err != nil || threshold <= 0 {
+			return xdsWatchdogConfig{}, fmt.Errorf("%s annotation value of %s must be a positive duration, e.g. \"2m\"", annotationXDSWatchdogThreshold, raw)
+		}
+		config.threshold = threshold
+	}
+	if raw, ok := pod.Annotations[annotationXDSWatchdogPolicy]; ok && raw != "" {
+		if raw != xdsWatchdogPolicyLog && raw != xdsWatchdogPolicyRestart {
+			return xdsWatchdogConfig{}, fmt.Errorf("%s annotation value of %s must be %q or %q", annotationXDSWatchdogPolicy, raw, xdsWatchdogPolicyLog, xdsWatchdogPolicyRestart)
+		}
+		config.policy = raw
+	}
+	if config.threshold == 0 {
+		config.threshold = 2 * time.Minute
+	}
+	if config.policy == "" {
+		config.policy = xdsWatchdogPolicyLog
+	}
+	return config, nil
+}
+
 func (h *Handler) consulSidecarResources(pod corev1.Pod) (corev1.ResourceRequirements, error) {
 	resources := corev1.ResourceRequirements{
 		Limits:   corev1.ResourceList{},
@@ -2,6 +2,7 @@ package connectinject
 
 import (
 	"testing"
+	"time"
 
 	logrtest "github.com/go-logr/logr/testing"
 	"github.com/stretchr/testify/require"
@@ -45,6 +46,189 @@ func TestConsulSidecar_MetricsFlags(t *testing.T) {
 	require.Contains(t, container.Command, "-service-metrics-path=/metrics")
 }
 
+// Test that the xDS watchdog flags are passed to consul sidecar when the
+// watchdog is enabled by the handler or the annotations of the pod.
+func TestConsulSidecar_XDSWatchdogFlags(t *testing.T) {
+	cases := map[string]struct {
+		handler     Handler
+		annotations map[string]string
+		expFlags    []string
+		expErr      string
+	}{
+		"disabled": {
+			handler:  Handler{},
+			expFlags: []string{"-enable-metrics-merging=false"},
+		},
+		"enabled by the handler": {
+			handler: Handler{EnableXDSWatchdog: true, XDSWatchdogThreshold: time.Minute, XDSWatchdogPolicy: "restart"},
+			expFlags: []string{
+				"-enable-metrics-merging=false",
+				"-enable-xds-watchdog=true",
+				"-xds-watchdog-threshold=1m0s",
+				"-xds-watchdog-policy=restart",
+				"-xds-watchdog-envoy-admin-ports=19000",
+			},
+		},
+		"enabled by annotations": {
+			handler: Handler{},
+			annotations: map[string]string{
+				annotationXDSWatchdog:          "true",
+				annotationXDSWatchdogThreshold: "30s",
+			},
+			expFlags: []string{
+				"-enable-xds-watchdog=true",
+				"-xds-watchdog-threshold=30s",
+				"-xds-watchdog-policy=log",
+			},
+		},
+		"multi port pod": {
+			handler: Handler{EnableXDSWatchdog: true},
+			annotations: map[string]string{
+				annotationService: "web,web-admin",
+			},
+			expFlags: []string{
+				"-xds-watchdog-threshold=2m0s",
+				"-xds-watchdog-envoy-admin-ports=19000,19001",
+			},
+		},
+		"invalid policy annotation": {
+			handler: Handler{EnableXDSWatchdog: true},
+			annotations: map[string]string{
+				annotationXDSWatchdogPolicy: "evict",
+			},
+			expErr: `consul.hashicorp.com/xds-watchdog-policy annotation value of evict must be "log" or "restart"`,
+		},
+		"invalid threshold annotation": {
+			handler: Handler{EnableXDSWatchdog: true},
+			annotations: map[string]string{
+				annotationXDSWatchdogThreshold: "-1m",
+			},
+			expErr: `consul.hashicorp.com/xds-watchdog-threshold annotation value of -1m must be a positive duration, e.g. "2m"`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			c.handler.Log = logrtest.TestLogger{T: t}
+			container, err := c.handler.consulSidecar(corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: c.annotations,
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "web",
+						},
+					},
+				},
+			})
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			for _, flag := range c.expFlags {
+				require.Contains(t, container.Command, flag)
+			}
+			if !c.handler.EnableXDSWatchdog && c.annotations[annotationXDSWatchdog] != "true" {
+				require.NotContains(t, container.Command, "-enable-xds-watchdog=true")
+			}
+		})
+	}
+}
+
+// Test that the job completion flags are passed to consul sidecar for pods
+// owned by a Job.
+func TestConsulSidecar_JobCompletionFlags(t *testing.T) {
+	jobOwner := []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "migrate"}}
+	cases := map[string]struct {
+		owners        []metav1.OwnerReference
+		annotations   map[string]string
+		restartPolicy corev1.RestartPolicy
+		// enabled is the default of the injector.
+		enabled    bool
+		expEnabled bool
+		expErr     string
+	}{
+		"not a job": {
+			enabled:    true,
+			expEnabled: false,
+		},
+		"job": {
+			owners:        jobOwner,
+			restartPolicy: corev1.RestartPolicyNever,
+			expEnabled:    false,
+		},
+		"job, enabled by default": {
+			owners:        jobOwner,
+			restartPolicy: corev1.RestartPolicyNever,
+			enabled:       true,
+			expEnabled:    true,
+		},
+		"job with job completion enabled": {
+			owners:        jobOwner,
+			annotations:   map[string]string{annotationJobCompletion: "true"},
+			restartPolicy: corev1.RestartPolicyNever,
+			expEnabled:    true,
+		},
+		"job that restarts on failure": {
+			owners:        jobOwner,
+			restartPolicy: corev1.RestartPolicyOnFailure,
+			enabled:       true,
+			expEnabled:    false,
+		},
+		"job with job completion disabled": {
+			owners:        jobOwner,
+			annotations:   map[string]string{annotationJobCompletion: "false"},
+			restartPolicy: corev1.RestartPolicyNever,
+			enabled:       true,
+			expEnabled:    false,
+		},
+		"job that restarts on failure with job completion enabled": {
+			owners:        jobOwner,
+			annotations:   map[string]string{annotationJobCompletion: "true"},
+			restartPolicy: corev1.RestartPolicyOnFailure,
+			expErr:        `consul.hashicorp.com/job-completion annotation value of true was invalid: pods with the "OnFailure" restart policy are not supported, only "Never"`,
+		},
+		"invalid annotation": {
+			owners:      jobOwner,
+			annotations: map[string]string{annotationJobCompletion: "yes please"},
+			expErr:      `consul.hashicorp.com/job-completion annotation value of yes please was invalid: strconv.ParseBool: parsing "yes please": invalid syntax`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			handler := Handler{Log: logrtest.TestLogger{T: t}, EnableJobCompletion: c.enabled}
+			container, err := handler.consulSidecar(corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations:     c.annotations,
+					OwnerReferences: c.owners,
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "web",
+						},
+					},
+					RestartPolicy: c.restartPolicy,
+				},
+			})
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			if c.expEnabled {
+				require.Contains(t, container.Command, "-enable-job-completion=true")
+				require.Contains(t, container.Command, "-job-completion-envoy-admin-ports=19000")
+			} else {
+				require.NotContains(t, container.Command, "-enable-job-completion=true")
+			}
+		})
+	}
+}
+
 func TestHandlerConsulSidecar_Resources(t *testing.T) {
 	mem1 := resource.MustParse("100Mi")
 	mem2 := resource.MustParse("200Mi")
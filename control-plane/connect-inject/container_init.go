@@ -54,6 +54,10 @@ type initContainerCommandData struct {
 	// container to do that.
 	EnableTransparentProxy bool
 
+	// TProxyNodeHelper configures the init container to leave applying the traffic redirection rules to the
+	// transparent proxy node helper, i.e. run consul-k8s-control-plane redirect-traffic instead.
+	TProxyNodeHelper bool
+
 	// TProxyExcludeInboundPorts is a list of inbound ports to exclude from traffic redirection via
 	// the consul connect redirect-traffic command.
 	TProxyExcludeInboundPorts []string
@@ -84,6 +88,10 @@ type initContainerCommandData struct {
 	// BearerTokenFile configures where the service account token can be found. This will be unique per service in a
 	// multi port Pod.
 	BearerTokenFile string
+
+	// CopyControlPlaneBinary configures whether the consul-k8s-control-plane binary is copied to the shared
+	// volume so that the Envoy sidecar can run it to wait until Envoy is ready.
+	CopyControlPlaneBinary bool
 }
 
 // initCopyContainer returns the init container spec for the copy container which places
@@ -103,8 +111,11 @@ func (h *Handler) initCopyContainer() corev1.Container {
 		},
 		Command: []string{"/bin/sh", "-ec", cmd},
 	}
-	// If running on OpenShift, don't set the security context and instead let OpenShift set a random user/group for us.
-	if !h.EnableOpenShift {
+	// If running on OpenShift, don't set the user and instead let OpenShift set a random user/group for us.
+	if h.EnableOpenShift {
+		container.SecurityContext = restrictedSecurityContext()
+		container.SecurityContext.ReadOnlyRootFilesystem = pointerToBool(true)
+	} else {
 		container.SecurityContext = &corev1.SecurityContext{
 			// Set RunAsUser because the default user for the consul container is root and we want to run non-root.
 			RunAsUser:              pointerToInt64(copyContainerUserAndGroupID),
@@ -155,11 +166,32 @@ func (h *Handler) containerInit(namespace corev1.Namespace, pod corev1.Pod, mpi
 		TProxyExcludeOutboundCIDRs: splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeOutboundCIDRs, pod),
 		TProxyExcludeUIDs:          splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeUIDs, pod),
 		ConsulDNSClusterIP:         consulDNSClusterIP,
-		EnvoyUID:                   envoyUserAndGroupID,
 		MultiPort:                  multiPort,
 		EnvoyAdminPort:             19000 + mpi.serviceIndex,
 	}
 
+	// The binary only needs to be copied once for multi port pods.
+	holdApplication, err := holdApplicationUntilProxyStarts(pod, h.HoldApplicationUntilProxyStarts)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+	data.CopyControlPlaneBinary = holdApplication && mpi.serviceIndex == 0
+
+	var tproxyInitMode string
+	if tproxyEnabled {
+		tproxyInitMode, err = h.tproxyInitMode(namespace, pod)
+		if err != nil {
+			return corev1.Container{}, err
+		}
+		data.TProxyNodeHelper = tproxyInitMode == TProxyInitModeNodeHelper
+
+		envoyUID, _, err := h.envoyUserAndGroup(namespace, pod)
+		if err != nil {
+			return corev1.Container{}, err
+		}
+		data.EnvoyUID = int(envoyUID)
+	}
+
 	// Create expected volume mounts
 	volMounts := []corev1.VolumeMount{
 		{
@@ -181,15 +213,25 @@ func (h *Handler) containerInit(namespace corev1.Namespace, pod corev1.Pod, mpi
 		} else {
 			data.ServiceAccountName = pod.Spec.ServiceAccountName
 		}
-		// Extract the service account token's volume mount
-		saTokenVolumeMount, bearerTokenFile, err := findServiceAccountVolumeMount(pod, multiPort, mpi.serviceName)
-		if err != nil {
-			return corev1.Container{}, err
+		if h.EnableProjectedServiceAccountToken && !multiPort {
+			// Log in with the projected service account token added to the pod.
+			data.BearerTokenFile = projectedTokenMountPath + "/token"
+			volMounts = append(volMounts, corev1.VolumeMount{
+				Name:      projectedTokenVolumeName,
+				ReadOnly:  true,
+				MountPath: projectedTokenMountPath,
+			})
+		} else {
+			// Extract the service account token's volume mount
+			saTokenVolumeMount, bearerTokenFile, err := findServiceAccountVolumeMount(pod, multiPort, mpi.serviceName)
+			if err != nil {
+				return corev1.Container{}, err
+			}
+			data.BearerTokenFile = bearerTokenFile
+
+			// Append to volume mounts
+			volMounts = append(volMounts, saTokenVolumeMount)
 		}
-		data.BearerTokenFile = bearerTokenFile
-
-		// Append to volume mounts
-		volMounts = append(volMounts, saTokenVolumeMount)
 	}
 
 	// This determines how to configure the consul connect envoy command: what
@@ -257,18 +299,12 @@ func (h *Handler) containerInit(namespace corev1.Namespace, pod corev1.Pod, mpi
 	}
 
 	if tproxyEnabled {
-		// Running consul connect redirect-traffic with iptables
-		// requires both being a root user and having NET_ADMIN capability.
-		container.SecurityContext = &corev1.SecurityContext{
-			RunAsUser:  pointerToInt64(rootUserAndGroupID),
-			RunAsGroup: pointerToInt64(rootUserAndGroupID),
-			// RunAsNonRoot overrides any setting in the Pod so that we can still run as root here as required.
-			RunAsNonRoot: pointerToBool(false),
-			Privileged:   pointerToBool(true),
-			Capabilities: &corev1.Capabilities{
-				Add: []corev1.Capability{netAdminCapability},
-			},
-		}
+		container.SecurityContext = tproxyInitSecurityContext(tproxyInitMode)
+	}
+	// On OpenShift, the init container runs under the restricted-v2 SecurityContextConstraints as the
+	// user OpenShift assigns unless it applies the traffic redirection rules itself.
+	if h.EnableOpenShift && (!tproxyEnabled || tproxyInitMode == TProxyInitModeNodeHelper) {
+		container.SecurityContext = restrictedSecurityContext()
 	}
 
 	return container, nil
@@ -350,6 +386,9 @@ EOF
 export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
 export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
 {{- end}}
+{{- if .CopyControlPlaneBinary }}
+cp "$(command -v consul-k8s-control-plane)" /consul/connect-inject/consul-k8s-control-plane
+{{- end }}
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   {{- if .AuthMethod }}
   -acl-auth-method="{{ .AuthMethod }}" \
@@ -418,6 +457,36 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
 {{- if .EnableTransparentProxy }}
 {{- /* The newline below is intentional to allow extra space
        in the rendered template between this and the previous commands. */}}
+{{- if .TProxyNodeHelper }}
+
+# Request traffic redirection rules from the node helper.
+consul-k8s-control-plane redirect-traffic \
+  {{- if .AuthMethod }}
+  -token-file="/consul/connect-inject/acl-token" \
+  {{- end }}
+  {{- if .ConsulPartition }}
+  -partition="{{ .ConsulPartition }}" \
+  {{- end }}
+  {{- if .ConsulNamespace }}
+  -consul-service-namespace="{{ .ConsulNamespace }}" \
+  {{- end }}
+  {{- if .ConsulDNSClusterIP }}
+  -consul-dns-ip="{{ .ConsulDNSClusterIP }}" \
+  {{- end }}
+  {{- range .TProxyExcludeInboundPorts }}
+  -exclude-inbound-port="{{ . }}" \
+  {{- end }}
+  {{- range .TProxyExcludeOutboundPorts }}
+  -exclude-outbound-port="{{ . }}" \
+  {{- end }}
+  {{- range .TProxyExcludeOutboundCIDRs }}
+  -exclude-outbound-cidr="{{ . }}" \
+  {{- end }}
+  {{- range .TProxyExcludeUIDs }}
+  -exclude-uid="{{ . }}" \
+  {{- end }}
+  -proxy-uid={{ .EnvoyUID }}
+{{- else }}
 
 # Apply traffic redirection rules.
 /consul/connect-inject/consul connect redirect-traffic \
@@ -448,4 +517,5 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
   -proxy-id="$(cat /consul/connect-inject/proxyid)" \
   -proxy-uid={{ .EnvoyUID }}
 {{- end }}
+{{- end }}
 `
@@ -123,6 +123,30 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
   -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`,
 			"",
 		},
+		{
+			"When holding the application until the proxy starts, copies the control plane binary",
+			func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[annotationService] = "web"
+				pod.Annotations[annotationHoldApplicationUntilProxyStarts] = "true"
+				return pod
+			},
+			Handler{},
+			`export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
+cp "$(command -v consul-k8s-control-plane)" /consul/connect-inject/consul-k8s-control-plane
+consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \`,
+			"",
+		},
+		{
+			"When the annotation disables holding the application, does not copy the control plane binary",
+			func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[annotationService] = "web"
+				pod.Annotations[annotationHoldApplicationUntilProxyStarts] = "false"
+				return pod
+			},
+			Handler{HoldApplicationUntilProxyStarts: true},
+			"",
+			"consul-k8s-control-plane /consul/connect-inject/consul-k8s-control-plane",
+		},
 	}
 
 	for _, tt := range cases {
@@ -311,6 +335,125 @@ func TestHandlerContainerInit_transparentProxy(t *testing.T) {
 	}
 }
 
+func TestHandlerContainerInit_transparentProxyInitMode(t *testing.T) {
+	privileged := `/consul/connect-inject/consul connect redirect-traffic \
+  -exclude-inbound-port="9090" \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -proxy-uid=5995`
+	nodeHelper := `# Request traffic redirection rules from the node helper.
+consul-k8s-control-plane redirect-traffic \
+  -exclude-inbound-port="9090" \
+  -proxy-uid=5995`
+
+	cases := map[string]struct {
+		handler        Handler
+		annotations    map[string]string
+		namespaceLabel map[string]string
+		expCmd         string
+		expSC          *corev1.SecurityContext
+		expErr         string
+	}{
+		"auto without pod security level": {
+			handler: Handler{EnableTransparentProxy: true, EnableTProxyNodeHelper: true},
+			expCmd:  privileged,
+			expSC:   tproxyInitSecurityContext(TProxyInitModePrivileged),
+		},
+		"auto with privileged pod security level": {
+			handler:        Handler{EnableTransparentProxy: true, EnableTProxyNodeHelper: true},
+			namespaceLabel: map[string]string{labelPodSecurityEnforce: "privileged"},
+			expCmd:         privileged,
+			expSC:          tproxyInitSecurityContext(TProxyInitModePrivileged),
+		},
+		"auto with restricted pod security level": {
+			handler:        Handler{EnableTransparentProxy: true, EnableTProxyNodeHelper: true},
+			namespaceLabel: map[string]string{labelPodSecurityEnforce: "restricted"},
+			expCmd:         nodeHelper,
+			expSC: &corev1.SecurityContext{
+				RunAsUser:                pointerToInt64(copyContainerUserAndGroupID),
+				RunAsGroup:               pointerToInt64(copyContainerUserAndGroupID),
+				RunAsNonRoot:             pointerToBool(true),
+				AllowPrivilegeEscalation: pointerToBool(false),
+				Capabilities: &corev1.Capabilities{
+					Drop: []corev1.Capability{"ALL"},
+				},
+				SeccompProfile: &corev1.SeccompProfile{
+					Type: corev1.SeccompProfileTypeRuntimeDefault,
+				},
+			},
+		},
+		"auto with baseline pod security level without node helper": {
+			handler:        Handler{EnableTransparentProxy: true},
+			namespaceLabel: map[string]string{labelPodSecurityEnforce: "baseline"},
+			expErr:         "namespace k8snamespace enforces the baseline Pod Security Standard",
+		},
+		"net-admin by default": {
+			handler: Handler{EnableTransparentProxy: true, TProxyInitMode: TProxyInitModeNetAdmin},
+			expCmd:  privileged,
+			expSC: &corev1.SecurityContext{
+				RunAsUser:                pointerToInt64(0),
+				RunAsGroup:               pointerToInt64(0),
+				RunAsNonRoot:             pointerToBool(false),
+				Privileged:               pointerToBool(false),
+				AllowPrivilegeEscalation: pointerToBool(false),
+				Capabilities: &corev1.Capabilities{
+					Add:  []corev1.Capability{netAdminCapability, netRawCapability},
+					Drop: []corev1.Capability{"ALL"},
+				},
+			},
+		},
+		"node-helper by annotation": {
+			handler:     Handler{EnableTransparentProxy: true, EnableTProxyNodeHelper: true},
+			annotations: map[string]string{AnnotationTransparentProxyInitMode: TProxyInitModeNodeHelper},
+			expCmd:      nodeHelper,
+			expSC:       tproxyInitSecurityContext(TProxyInitModeNodeHelper),
+		},
+		"node-helper by annotation without node helper": {
+			handler:     Handler{EnableTransparentProxy: true},
+			annotations: map[string]string{AnnotationTransparentProxyInitMode: TProxyInitModeNodeHelper},
+			expErr:      `transparent proxy init mode "node-helper" requires the transparent proxy node helper to be enabled`,
+		},
+		"invalid annotation": {
+			handler:     Handler{EnableTransparentProxy: true},
+			annotations: map[string]string{AnnotationTransparentProxyInitMode: "foo"},
+			expErr:      `invalid transparent proxy init mode "foo"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotationService:                   "foo",
+						annotationTProxyExcludeInboundPorts: "9090",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "web",
+						},
+					},
+				},
+			}
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+			ns := testNS
+			ns.Labels = c.namespaceLabel
+
+			container, err := c.handler.containerInit(ns, pod, multiPortInfo{})
+			if c.expErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Contains(t, strings.Join(container.Command, " "), c.expCmd)
+			require.Equal(t, c.expSC, container.SecurityContext)
+		})
+	}
+}
+
 func TestHandlerContainerInit_consulDNS(t *testing.T) {
 	cases := map[string]struct {
 		globalEnabled       bool
@@ -954,6 +1097,65 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
   -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`)
 }
 
+// If projected service account tokens are enabled, the init container logs in with the
+// projected token even if the service account token isn't mounted in the pod.
+func TestHandlerContainerInit_projectedServiceAccountToken(t *testing.T) {
+	require := require.New(t)
+	h := Handler{
+		AuthMethod:                         "release-name-consul-k8s-auth-method",
+		EnableProjectedServiceAccountToken: true,
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				annotationService: "foo",
+			},
+		},
+
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "web",
+				},
+			},
+			ServiceAccountName:           "foo",
+			AutomountServiceAccountToken: pointerToBool(false),
+		},
+	}
+	container, err := h.containerInit(testNS, *pod, multiPortInfo{})
+	require.NoError(err)
+	require.Contains(strings.Join(container.Command, " "), `-bearer-token-file=/consul/connect-inject-token/token \`)
+	require.Contains(container.VolumeMounts, corev1.VolumeMount{
+		Name:      projectedTokenVolumeName,
+		ReadOnly:  true,
+		MountPath: "/consul/connect-inject-token",
+	})
+}
+
+func TestHandlerProjectedTokenVolume(t *testing.T) {
+	h := Handler{
+		ProjectedTokenAudience:          "consul",
+		ProjectedTokenExpirationSeconds: 600,
+	}
+	expiration := int64(600)
+	require.Equal(t, corev1.Volume{
+		Name: projectedTokenVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{{ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+					Audience:          "consul",
+					ExpirationSeconds: &expiration,
+					Path:              "token",
+				}}},
+			},
+		},
+	}, h.projectedTokenVolume())
+
+	// Kubernetes defaults the expiration if it isn't set.
+	h = Handler{}
+	require.Nil(t, h.projectedTokenVolume().Projected.Sources[0].ServiceAccountToken.ExpirationSeconds)
+}
+
 // If Consul CA cert is set,
 // Consul addresses should use HTTPS
 // and CA cert should be set as env variable.
@@ -1046,7 +1248,9 @@ func TestHandlerInitCopyContainer(t *testing.T) {
 			container := h.initCopyContainer()
 
 			if openShiftEnabled {
-				require.Nil(t, container.SecurityContext)
+				expectedSecurityContext := restrictedSecurityContext()
+				expectedSecurityContext.ReadOnlyRootFilesystem = pointerToBool(true)
+				require.Equal(t, expectedSecurityContext, container.SecurityContext)
 			} else {
 				expectedSecurityContext := &corev1.SecurityContext{
 					RunAsUser:              pointerToInt64(copyContainerUserAndGroupID),
@@ -4,9 +4,18 @@ import (
 	corev1 "k8s.io/api/core/v1"
 )
 
-// volumeName is the name of the volume that is created to store the
-// Consul Connect injection data.
-const volumeName = "consul-connect-inject-data"
+const (
+	// volumeName is the name of the volume that is created to store the
+	// Consul Connect injection data.
+	volumeName = "consul-connect-inject-data"
+
+	// projectedTokenVolumeName is the name of the volume that holds the projected
+	// service account token that the init container logs in to Consul with.
+	projectedTokenVolumeName = "consul-connect-inject-token"
+
+	// projectedTokenMountPath is where the projected service account token volume is mounted.
+	projectedTokenMountPath = "/consul/connect-inject-token"
+)
 
 // containerVolume returns the volume data to add to the pod. This volume
 // is used for shared data between containers.
@@ -18,3 +27,24 @@ func (h *Handler) containerVolume() corev1.Volume {
 		},
 	}
 }
+
+// projectedTokenVolume returns the volume with a service account token of the pod that is
+// bound to the pod, has the configured audience and expires after the configured duration.
+// The kubelet rotates the token before it expires.
+func (h *Handler) projectedTokenVolume() corev1.Volume {
+	token := &corev1.ServiceAccountTokenProjection{
+		Audience: h.ProjectedTokenAudience,
+		Path:     "token",
+	}
+	if h.ProjectedTokenExpirationSeconds != 0 {
+		token.ExpirationSeconds = &h.ProjectedTokenExpirationSeconds
+	}
+	return corev1.Volume{
+		Name: projectedTokenVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{{ServiceAccountToken: token}},
+			},
+		},
+	}
+}
@@ -0,0 +1,149 @@
+package connectinject
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// corefileKey is the key of the Corefile in the CoreDNS and node-local-dns
+	// ConfigMaps.
+	corefileKey = "Corefile"
+
+	corefileStanzaBegin = "# BEGIN consul-k8s: managed by Consul, do not edit"
+	corefileStanzaEnd   = "# END consul-k8s"
+
+	defaultCoreDNSSyncInterval = 1 * time.Minute
+)
+
+// CoreDNSController keeps a server block for the Consul DNS domain in a
+// Corefile, so that the cluster's CoreDNS, or the node-local-dns cache in front
+// of it, forwards lookups in that domain to the ClusterIP of the Consul DNS
+// service. Pods then resolve .consul names without any change to their DNS
+// configuration.
+//
+// The server block is wrapped in marker comments and replaced whenever the
+// ClusterIP of the service changes. The rest of the Corefile is left as is.
+// CoreDNS picks up the change if its Corefile uses the reload plugin.
+//
+// The ConfigMap and the service are polled rather than watched so that the
+// controller only needs permission to get and update this one ConfigMap.
+type CoreDNSController struct {
+	Clientset kubernetes.Interface
+	Log       logr.Logger
+
+	// ConfigMap is the name and namespace of the ConfigMap with the Corefile,
+	// e.g. kube-system/coredns.
+	ConfigMap types.NamespacedName
+	// DNSService is the name and namespace of the Consul DNS service.
+	DNSService types.NamespacedName
+	// Domain is the Consul DNS domain, e.g. "consul".
+	Domain string
+	// BindAddress is the address the server block listens on. It must be set
+	// for node-local-dns, which only listens on its link-local address, and
+	// left empty for CoreDNS.
+	BindAddress string
+	// SyncInterval is how often the Corefile is synced. It defaults to 1m.
+	SyncInterval time.Duration
+}
+
+// Start syncs the Corefile every SyncInterval until the context is cancelled.
+// It implements manager.Runnable so that it runs only on the leader.
+func (r *CoreDNSController) Start(ctx context.Context) error {
+	interval := r.SyncInterval
+	if interval <= 0 {
+		interval = defaultCoreDNSSyncInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Sync(ctx); err != nil {
+			r.Log.Error(err, "syncing Corefile", "configmap", r.ConfigMap)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync updates the Consul server block in the Corefile if it doesn't forward
+// to the current ClusterIP of the Consul DNS service.
+func (r *CoreDNSController) Sync(ctx context.Context) error {
+	svc, err := r.Clientset.CoreV1().Services(r.DNSService.Namespace).Get(ctx, r.DNSService.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("getting service %s: %w", r.DNSService, err)
+	}
+	clusterIP := svc.Spec.ClusterIP
+	if clusterIP == "" || clusterIP == "None" {
+		return fmt.Errorf("service %s has no ClusterIP", r.DNSService)
+	}
+
+	configMaps := r.Clientset.CoreV1().ConfigMaps(r.ConfigMap.Namespace)
+	cm, err := configMaps.Get(ctx, r.ConfigMap.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("getting configmap %s: %w", r.ConfigMap, err)
+	}
+	corefile, ok := cm.Data[corefileKey]
+	if !ok {
+		return fmt.Errorf("configmap %s has no %s", r.ConfigMap, corefileKey)
+	}
+
+	updated := upsertCorefileStanza(corefile, r.stanza(clusterIP))
+	if updated == corefile {
+		return nil
+	}
+	cm.Data[corefileKey] = updated
+	if _, err := configMaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("updating configmap %s: %w", r.ConfigMap, err)
+	}
+	r.Log.Info("updated Consul DNS server block in Corefile", "configmap", r.ConfigMap, "upstream", clusterIP)
+	return nil
+}
+
+// stanza returns the server block that forwards the Consul domain to the
+// Consul DNS service, wrapped in the marker comments.
+func (r *CoreDNSController) stanza(clusterIP string) string {
+	lines := []string{
+		corefileStanzaBegin,
+		fmt.Sprintf("%s:53 {", strings.TrimSuffix(r.Domain, ".")),
+		"    errors",
+		"    cache 30",
+	}
+	if r.BindAddress != "" {
+		lines = append(lines, "    bind "+r.BindAddress)
+	}
+	lines = append(lines,
+		"    forward . "+clusterIP,
+		"}",
+		corefileStanzaEnd,
+	)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// upsertCorefileStanza replaces the stanza between the marker comments in the
+// Corefile, or appends it if the Corefile has no stanza yet.
+func upsertCorefileStanza(corefile, stanza string) string {
+	begin := strings.Index(corefile, corefileStanzaBegin)
+	if begin >= 0 {
+		if end := strings.Index(corefile[begin:], corefileStanzaEnd); end >= 0 {
+			end += begin + len(corefileStanzaEnd)
+			if end < len(corefile) && corefile[end] == '\n' {
+				end++
+			}
+			return corefile[:begin] + stanza + corefile[end:]
+		}
+	}
+	if corefile != "" && !strings.HasSuffix(corefile, "\n") {
+		corefile += "\n"
+	}
+	return corefile + stanza
+}
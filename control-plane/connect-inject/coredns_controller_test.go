@@ -0,0 +1,156 @@
+package connectinject
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testCorefile = `.:53 {
+    errors
+    health
+    kubernetes cluster.local in-addr.arpa ip6.arpa
+    forward . /etc/resolv.conf
+    cache 30
+    reload
+}
+`
+
+func TestCoreDNSController_Sync(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		bindAddress string
+		corefile    string
+		clusterIP   string
+		expected    string
+	}{
+		"appends the server block": {
+			corefile:  testCorefile,
+			clusterIP: "10.0.0.53",
+			expected: testCorefile + `# BEGIN consul-k8s: managed by Consul, do not edit
+consul:53 {
+    errors
+    cache 30
+    forward . 10.0.0.53
+}
+# END consul-k8s
+`,
+		},
+		"replaces the server block when the ClusterIP changes": {
+			corefile: testCorefile + `# BEGIN consul-k8s: managed by Consul, do not edit
+consul:53 {
+    errors
+    cache 30
+    forward . 10.0.0.53
+}
+# END consul-k8s
+`,
+			clusterIP: "10.0.0.54",
+			expected: testCorefile + `# BEGIN consul-k8s: managed by Consul, do not edit
+consul:53 {
+    errors
+    cache 30
+    forward . 10.0.0.54
+}
+# END consul-k8s
+`,
+		},
+		"keeps the blocks after the server block": {
+			corefile: `# BEGIN consul-k8s: managed by Consul, do not edit
+consul:53 {
+    forward . 10.0.0.53
+}
+# END consul-k8s
+` + testCorefile,
+			clusterIP: "10.0.0.53",
+			expected: `# BEGIN consul-k8s: managed by Consul, do not edit
+consul:53 {
+    errors
+    cache 30
+    forward . 10.0.0.53
+}
+# END consul-k8s
+` + testCorefile,
+		},
+		"binds to the node-local-dns address": {
+			bindAddress: "169.254.20.10",
+			corefile:    "cluster.local:53 {\n    bind 169.254.20.10\n}",
+			clusterIP:   "10.0.0.53",
+			expected: `cluster.local:53 {
+    bind 169.254.20.10
+}
+# BEGIN consul-k8s: managed by Consul, do not edit
+consul:53 {
+    errors
+    cache 30
+    bind 169.254.20.10
+    forward . 10.0.0.53
+}
+# END consul-k8s
+`,
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			client := fake.NewSimpleClientset(
+				&corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{Name: "consul-dns", Namespace: "consul"},
+					Spec:       corev1.ServiceSpec{ClusterIP: c.clusterIP},
+				},
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+					Data:       map[string]string{"Corefile": c.corefile},
+				},
+			)
+			ctrl := &CoreDNSController{
+				Clientset:   client,
+				Log:         logrtest.TestLogger{T: t},
+				ConfigMap:   types.NamespacedName{Name: "coredns", Namespace: "kube-system"},
+				DNSService:  types.NamespacedName{Name: "consul-dns", Namespace: "consul"},
+				Domain:      "consul",
+				BindAddress: c.bindAddress,
+			}
+
+			// Syncing again doesn't change the Corefile.
+			for i := 0; i < 2; i++ {
+				require.NoError(t, ctrl.Sync(ctx))
+				cm, err := client.CoreV1().ConfigMaps("kube-system").Get(ctx, "coredns", metav1.GetOptions{})
+				require.NoError(t, err)
+				require.Equal(t, c.expected, cm.Data["Corefile"])
+			}
+		})
+	}
+}
+
+func TestCoreDNSController_SyncErrors(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctrl := &CoreDNSController{
+		Log:        logrtest.TestLogger{T: t},
+		ConfigMap:  types.NamespacedName{Name: "coredns", Namespace: "kube-system"},
+		DNSService: types.NamespacedName{Name: "consul-dns", Namespace: "consul"},
+		Domain:     "consul",
+	}
+
+	ctrl.Clientset = fake.NewSimpleClientset()
+	require.EqualError(t, ctrl.Sync(ctx), `getting service consul/consul-dns: services "consul-dns" not found`)
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-dns", Namespace: "consul"},
+		Spec:       corev1.ServiceSpec{ClusterIP: "10.0.0.53"},
+	}
+	ctrl.Clientset = fake.NewSimpleClientset(svc, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Data:       map[string]string{"other": ""},
+	})
+	require.EqualError(t, ctrl.Sync(ctx), "configmap kube-system/coredns has no Corefile")
+}
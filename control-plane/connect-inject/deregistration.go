@@ -0,0 +1,173 @@
+package connectinject
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// deregistrationPolicy controls how the service instance of a pod is registered while the pod
+// is not ready or terminating. Zero durations disable the respective behavior.
+type deregistrationPolicy struct {
+	// notReadyGracePeriod is how long the service instance stays passing after the pod becomes
+	// not ready, so that brief readiness flaps, e.g. during rolling deploys, don't cause every
+	// upstream proxy to be updated twice.
+	notReadyGracePeriod time.Duration
+	// deregisterNotReadyAfter is how long the pod may be not ready before its service instance is
+	// deregistered instead of being registered as critical.
+	deregisterNotReadyAfter time.Duration
+	// deregisterTerminatingAfter is how long after the pod starts terminating its service instance
+	// is deregistered instead of being registered as critical until the pod stops.
+	deregisterTerminatingAfter time.Duration
+}
+
+// registrationDecision is what the endpoints controller does with the service instance of a pod
+// according to its deregistration policy.
+type registrationDecision struct {
+	// deregister is true if the service instance should be deregistered although the pod is still
+	// running.
+	deregister bool
+	// holdPassing is true if a passing service instance should stay passing although the pod is
+	// not ready.
+	holdPassing bool
+	// requeueAfter is how long until the decision changes unless the pod changes first. It is
+	// zero if it doesn't change.
+	requeueAfter time.Duration
+}
+
+// deregistrationPolicy returns the deregistration policy of the pod. The defaults of the controller
+// can be overridden per pod via annotations.
+func (r *EndpointsController) deregistrationPolicy(pod corev1.Pod) (deregistrationPolicy, error) {
+	policy := deregistrationPolicy{
+		notReadyGracePeriod:        r.NotReadyGracePeriod,
+		deregisterNotReadyAfter:    r.DeregisterNotReadyAfter,
+		deregisterTerminatingAfter: r.DeregisterTerminatingAfter,
+	}
+	for _, override := range []struct {
+		annotation string
+		target     *time.Duration
+	}{
+		{annotationNotReadyGracePeriod, &policy.notReadyGracePeriod},
+		{annotationDeregisterNotReadyAfter, &policy.deregisterNotReadyAfter},
+		{annotationDeregisterTerminatingAfter, &policy.deregisterTerminatingAfter},
+	} {
+		raw, ok := pod.Annotations[override.annotation]
+		if !ok {
+			continue
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return deregistrationPolicy{}, fmt.Errorf("annotation %s of pod %s/%s must be a non-negative duration, e.g. \"30s\": %q",
+				override.annotation, pod.Namespace, pod.Name, raw)
+		}
+		*override.target = d
+	}
+	return policy, nil
+}
+
+// decide returns what to do with the service instance of the pod at the time now, given the
+// health status of its address in the endpoints.
+func (p deregistrationPolicy) decide(pod corev1.Pod, healthStatus string, now time.Time) registrationDecision {
+	var decision registrationDecision
+	if podTerminating(pod) {
+		since, ok := terminatingSince(pod)
+		if !ok || p.deregisterTerminatingAfter == 0 {
+			return decision
+		}
+		if remaining := since.Add(p.deregisterTerminatingAfter).Sub(now); remaining > 0 {
+			decision.requeueAfter = remaining
+		} else {
+			decision.deregister = true
+		}
+		return decision
+	}
+
+	if healthStatus != api.HealthCritical {
+		return decision
+	}
+	since, ok := notReadySince(pod)
+	if !ok {
+		return decision
+	}
+	if p.deregisterNotReadyAfter > 0 {
+		if remaining := since.Add(p.deregisterNotReadyAfter).Sub(now); remaining > 0 {
+			decision.requeueAfter = remaining
+		} else {
+			decision.deregister = true
+			return decision
+		}
+	}
+	if p.notReadyGracePeriod > 0 {
+		if remaining := since.Add(p.notReadyGracePeriod).Sub(now); remaining > 0 {
+			decision.holdPassing = true
+			decision.requeueAfter = minRequeueAfter(decision.requeueAfter, remaining)
+		}
+	}
+	return decision
+}
+
+// notReadySince returns when the pod became not ready. It returns false if the pod is ready or
+// the time is unknown.
+func notReadySince(pod corev1.Pod) (time.Time, bool) {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady && cond.Status != corev1.ConditionTrue && !cond.LastTransitionTime.IsZero() {
+			return cond.LastTransitionTime.Time, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// terminatingSince returns when the terminating pod started terminating. The deletion timestamp of
+// a pod is when its grace period ends, so the grace period is subtracted from it.
+func terminatingSince(pod corev1.Pod) (time.Time, bool) {
+	if pod.DeletionTimestamp != nil {
+		since := pod.DeletionTimestamp.Time
+		if pod.DeletionGracePeriodSeconds != nil {
+			since = since.Add(-time.Duration(*pod.DeletionGracePeriodSeconds) * time.Second)
+		}
+		return since, true
+	}
+	if cond, ok := disruptionTarget(pod); ok && !cond.LastTransitionTime.IsZero() {
+		return cond.LastTransitionTime.Time, true
+	}
+	return time.Time{}, false
+}
+
+// minRequeueAfter returns the shorter of the two requeue durations, ignoring zero durations.
+func minRequeueAfter(a, b time.Duration) time.Duration {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// deregisterNotReadyPod deregisters the service and proxy instances of a pod that has been not
+// ready for longer than its deregistration policy allows. Unlike the instances of pods that are
+// gone, the ACL token of the pod is kept since the instances are registered again once the pod
+// is ready.
+func (r *EndpointsController) deregisterNotReadyPod(pod corev1.Pod, serviceEndpoints corev1.Endpoints) error {
+	client, err := r.remoteConsulClient(pod.Status.HostIP, r.consulNamespace(pod.Namespace))
+	if err != nil {
+		return err
+	}
+	serviceID := getServiceID(pod, serviceEndpoints)
+	proxyServiceID := getProxyServiceID(pod, serviceEndpoints)
+	svcs, err := client.Agent().ServicesWithFilter(fmt.Sprintf("ID == %q or ID == %q", serviceID, proxyServiceID))
+	if err != nil {
+		return fmt.Errorf("unable to get agent services: serviceID=%s, %w", serviceID, err)
+	}
+	for _, id := range []string{proxyServiceID, serviceID} {
+		if _, ok := svcs[id]; !ok {
+			continue
+		}
+		r.Log.Info("deregistering service of not ready pod from consul", "svc", id, "name", pod.Name, "ns", pod.Namespace)
+		if err := client.Agent().ServiceDeregister(id); err != nil {
+			return fmt.Errorf("failed to deregister service instance %q: %w", id, err)
+		}
+		r.auditServiceInstance(&pod, consul.AuditOpDeregister, id, svcs[id].Namespace, nil)
+	}
+	return nil
+}
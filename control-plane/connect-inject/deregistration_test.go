@@ -0,0 +1,248 @@
+package connectinject
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDeregistrationPolicy(t *testing.T) {
+	ep := &EndpointsController{
+		NotReadyGracePeriod:        10 * time.Second,
+		DeregisterNotReadyAfter:    5 * time.Minute,
+		DeregisterTerminatingAfter: 0,
+	}
+	cases := map[string]struct {
+		annotations map[string]string
+		expected    deregistrationPolicy
+		expErr      string
+	}{
+		"defaults": {
+			expected: deregistrationPolicy{
+				notReadyGracePeriod:     10 * time.Second,
+				deregisterNotReadyAfter: 5 * time.Minute,
+			},
+		},
+		"annotations override the defaults": {
+			annotations: map[string]string{
+				annotationNotReadyGracePeriod:        "0s",
+				annotationDeregisterTerminatingAfter: "30s",
+			},
+			expected: deregistrationPolicy{
+				deregisterNotReadyAfter:    5 * time.Minute,
+				deregisterTerminatingAfter: 30 * time.Second,
+			},
+		},
+		"invalid duration": {
+			annotations: map[string]string{annotationDeregisterNotReadyAfter: "5"},
+			expErr:      `annotation consul.hashicorp.com/deregister-not-ready-after of pod default/pod1 must be a non-negative duration, e.g. "30s": "5"`,
+		},
+		"negative duration": {
+			annotations: map[string]string{annotationNotReadyGracePeriod: "-1s"},
+			expErr:      `annotation consul.hashicorp.com/not-ready-grace-period of pod default/pod1 must be a non-negative duration, e.g. "30s": "-1s"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+			policy, err := ep.deregistrationPolicy(*pod)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expected, policy)
+		})
+	}
+}
+
+func TestDeregistrationPolicyDecide(t *testing.T) {
+	now := time.Now()
+	notReadyFor := func(d time.Duration) *corev1.Pod {
+		pod := createPod("pod1", "1.2.3.4", true, true)
+		pod.Status.Conditions = []corev1.PodCondition{{
+			Type:               corev1.PodReady,
+			Status:             corev1.ConditionFalse,
+			LastTransitionTime: metav1.NewTime(now.Add(-d)),
+		}}
+		return pod
+	}
+	terminatingFor := func(d time.Duration) *corev1.Pod {
+		pod := createPod("pod1", "1.2.3.4", true, true)
+		gracePeriod := int64(30)
+		deletionTimestamp := metav1.NewTime(now.Add(-d).Add(30 * time.Second))
+		pod.DeletionTimestamp = &deletionTimestamp
+		pod.DeletionGracePeriodSeconds = &gracePeriod
+		return pod
+	}
+	policy := deregistrationPolicy{
+		notReadyGracePeriod:        10 * time.Second,
+		deregisterNotReadyAfter:    time.Minute,
+		deregisterTerminatingAfter: 20 * time.Second,
+	}
+
+	cases := map[string]struct {
+		policy       deregistrationPolicy
+		pod          *corev1.Pod
+		healthStatus string
+		expected     registrationDecision
+	}{
+		"ready": {
+			policy:       policy,
+			pod:          createPod("pod1", "1.2.3.4", true, true),
+			healthStatus: api.HealthPassing,
+		},
+		"not ready within the grace period": {
+			policy:       policy,
+			pod:          notReadyFor(4 * time.Second),
+			healthStatus: api.HealthCritical,
+			expected:     registrationDecision{holdPassing: true, requeueAfter: 6 * time.Second},
+		},
+		"not ready after the grace period": {
+			policy:       policy,
+			pod:          notReadyFor(15 * time.Second),
+			healthStatus: api.HealthCritical,
+			expected:     registrationDecision{requeueAfter: 45 * time.Second},
+		},
+		"not ready for longer than deregister not ready after": {
+			policy:       policy,
+			pod:          notReadyFor(2 * time.Minute),
+			healthStatus: api.HealthCritical,
+			expected:     registrationDecision{deregister: true},
+		},
+		"not ready without policy": {
+			pod:          notReadyFor(2 * time.Minute),
+			healthStatus: api.HealthCritical,
+		},
+		"not ready since unknown": {
+			policy:       policy,
+			pod:          createPod("pod1", "1.2.3.4", true, true),
+			healthStatus: api.HealthCritical,
+		},
+		"terminating": {
+			policy:       policy,
+			pod:          terminatingFor(5 * time.Second),
+			healthStatus: api.HealthCritical,
+			expected:     registrationDecision{requeueAfter: 15 * time.Second},
+		},
+		"terminating for longer than deregister terminating after": {
+			policy:       policy,
+			pod:          terminatingFor(25 * time.Second),
+			healthStatus: api.HealthCritical,
+			expected:     registrationDecision{deregister: true},
+		},
+		"terminating without policy": {
+			policy:       deregistrationPolicy{notReadyGracePeriod: 10 * time.Second},
+			pod:          terminatingFor(time.Second),
+			healthStatus: api.HealthCritical,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.expected, c.policy.decide(*c.pod, c.healthStatus, now))
+		})
+	}
+}
+
+func TestUpsertHealthCheck_HoldPassing(t *testing.T) {
+	agent := &fakeAgentChecks{
+		checks: map[string]*api.AgentCheck{
+			"default/pod1-service-created/kubernetes-health-check": {Status: api.HealthPassing},
+			"default/pod2-service-created/kubernetes-health-check": {Status: api.HealthCritical},
+		},
+	}
+	srv := httptest.NewServer(agent)
+	defer srv.Close()
+	client, err := api.NewClient(&api.Config{Address: srv.URL})
+	require.NoError(t, err)
+	ep := &EndpointsController{Log: logrtest.TestLogger{T: t}}
+
+	// A passing check stays passing.
+	pod1 := createPod("pod1", "1.2.3.4", true, true)
+	status, err := ep.upsertHealthCheck(*pod1, client, "pod1-service-created", "default/pod1-service-created/kubernetes-health-check", api.HealthCritical, true)
+	require.NoError(t, err)
+	require.Equal(t, api.HealthPassing, status)
+	require.Equal(t, api.HealthPassing, agent.checks["default/pod1-service-created/kubernetes-health-check"].Status)
+
+	// A critical check isn't made passing.
+	pod2 := createPod("pod2", "2.2.3.4", true, true)
+	status, err = ep.upsertHealthCheck(*pod2, client, "pod2-service-created", "default/pod2-service-created/kubernetes-health-check", api.HealthCritical, true)
+	require.NoError(t, err)
+	require.Equal(t, api.HealthCritical, status)
+
+	// Without the grace period, the passing check becomes critical.
+	status, err = ep.upsertHealthCheck(*pod1, client, "pod1-service-created", "default/pod1-service-created/kubernetes-health-check", api.HealthCritical, false)
+	require.NoError(t, err)
+	require.Equal(t, api.HealthCritical, status)
+	require.Equal(t, api.HealthCritical, agent.checks["default/pod1-service-created/kubernetes-health-check"].Status)
+}
+
+// Test that terminating pods are no longer drained once their deregistration policy deregisters
+// their service instances.
+func TestDrainTerminatingPods_DeregisterTerminatingAfter(t *testing.T) {
+	// Timestamps of objects in the fake client are truncated to seconds.
+	now := time.Now().Truncate(time.Second)
+	// pod1 started terminating a minute ago.
+	pod1 := createPod("pod1", "1.2.3.4", true, true)
+	deletionTimestamp1 := metav1.NewTime(now.Add(-time.Minute))
+	pod1.DeletionTimestamp = &deletionTimestamp1
+	// pod2 started terminating 10 seconds ago.
+	pod2 := createPod("pod2", "2.2.3.4", true, true)
+	deletionTimestamp2 := metav1.NewTime(now.Add(-10 * time.Second))
+	pod2.DeletionTimestamp = &deletionTimestamp2
+	for _, pod := range []*corev1.Pod{pod1, pod2} {
+		pod.Labels["app"] = "service-created"
+	}
+	endpoints := corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "service-created", Namespace: "default"}}
+
+	agent := &fakeAgentChecks{
+		checks: map[string]*api.AgentCheck{
+			"default/pod1-service-created/kubernetes-health-check": {Status: api.HealthPassing},
+			"default/pod2-service-created/kubernetes-health-check": {Status: api.HealthPassing},
+		},
+	}
+	srv := httptest.NewServer(agent)
+	defer srv.Close()
+	serverURL, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(
+		createService("service-created", "default", map[string]string{"app": "service-created"}),
+		pod1, pod2,
+	).Build()
+	ep := &EndpointsController{
+		Client:                     fakeClient,
+		Log:                        logrtest.TestLogger{T: t},
+		ConsulClientCfg:            &api.Config{},
+		ConsulScheme:               "http",
+		ConsulPort:                 serverURL.Port(),
+		DeregisterTerminatingAfter: 30 * time.Second,
+	}
+
+	endpointAddressMap := map[string]bool{}
+	requeueAfter, err := ep.drainTerminatingPods(context.Background(), endpoints, endpointAddressMap, now, false)
+	require.NoError(t, err)
+	require.Equal(t, 20*time.Second, requeueAfter)
+	require.Equal(t, map[string]bool{"2.2.3.4": true}, endpointAddressMap)
+	require.Equal(t, api.HealthPassing, agent.checks["default/pod1-service-created/kubernetes-health-check"].Status)
+	require.Equal(t, api.HealthCritical, agent.checks["default/pod2-service-created/kubernetes-health-check"].Status)
+
+	// While the instances are kept, e.g. in maintenance mode, pod1 is drained too.
+	endpointAddressMap = map[string]bool{}
+	_, err = ep.drainTerminatingPods(context.Background(), endpoints, endpointAddressMap, now, true)
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{"1.2.3.4": true, "2.2.3.4": true}, endpointAddressMap)
+	require.Equal(t, api.HealthCritical, agent.checks["default/pod1-service-created/kubernetes-health-check"].Status)
+}
@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/go-logr/logr"
@@ -22,9 +23,11 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -36,10 +39,16 @@ const (
 	MetaKeyKubeServiceName     = "k8s-service-name"
 	MetaKeyKubeNS              = "k8s-namespace"
 	MetaKeyManagedBy           = "managed-by"
+	MetaKeyClusterID           = "k8s-cluster-id"
+	MetaKeyKubeServicePortName = "k8s-service-port-name"
+	MetaKeyHostPort            = "host-port"
 	TokenMetaPodNameKey        = "pod"
 	kubernetesSuccessReasonMsg = "Kubernetes health checks passing"
 	envoyPrometheusBindAddr    = "envoy_prometheus_bind_addr"
-	envoySidecarContainer      = "envoy-sidecar"
+	// envoyTelemetryCollectorBindSocketDir is the proxy config that makes Envoy send its metrics to the
+	// consul-telemetry-collector service through a Unix socket in the directory.
+	envoyTelemetryCollectorBindSocketDir = "envoy_telemetry_collector_bind_socket_dir"
+	envoySidecarContainer                = "envoy-sidecar"
 
 	// clusterIPTaggedAddressName is the key for the tagged address to store the service's cluster IP and service port
 	// in Consul. Note: This value should not be changed without a corresponding change in Consul.
@@ -91,6 +100,9 @@ type EndpointsController struct {
 	// then the k8s `default` namespace will be mirrored in Consul's
 	// `k8s-default` namespace.
 	NSMirroringPrefix string
+	// NSMirroringRules rewrite the names of the k8s namespaces that are mirrored,
+	// e.g. to strip a "team-" prefix, before NSMirroringPrefix is added.
+	NSMirroringRules namespaces.MirroringRules
 	// CrossNSACLPolicy is the name of the ACL policy to attach to
 	// any created Consul namespaces to allow cross namespace service discovery.
 	// Only necessary if ACLs are enabled.
@@ -99,17 +111,83 @@ type EndpointsController struct {
 	ReleaseName string
 	// ReleaseNamespace is the namespace where Consul is installed.
 	ReleaseNamespace string
+	// ClusterID identifies the Kubernetes cluster when several clusters register service
+	// instances in the same Consul datacenter. It is added to the meta of the service instances,
+	// and instances registered by other clusters are never deregistered.
+	ClusterID string
 	// EnableTransparentProxy controls whether transparent proxy should be enabled
 	// for all proxy service registrations.
 	EnableTransparentProxy bool
 	// TProxyOverwriteProbes controls whether the endpoints controller should expose pod's HTTP probes
 	// via Envoy proxy.
 	TProxyOverwriteProbes bool
+	// EnableProbeHealthChecks controls whether every Kubernetes probe and readiness gate of a pod is
+	// registered as a separate Consul health check of the service instance.
+	EnableProbeHealthChecks bool
+	// NotReadyGracePeriod is how long the service instance of a pod stays passing after the pod
+	// becomes not ready. It can be overridden per pod via annotation.
+	NotReadyGracePeriod time.Duration
+	// DeregisterNotReadyAfter is how long a pod may be not ready before its service instance is
+	// deregistered instead of being registered as critical. If zero, it is never deregistered.
+	// It can be overridden per pod via annotation.
+	DeregisterNotReadyAfter time.Duration
+	// DeregisterTerminatingAfter is how long after a pod starts terminating its service instance is
+	// deregistered. If zero, it stays registered as critical until the pod stops. It can be
+	// overridden per pod via annotation.
+	DeregisterTerminatingAfter time.Duration
+	// EnableTelemetryCollector makes the proxies of pods forward their metrics to the
+	// consul-telemetry-collector service. Only the proxies of pods in the namespaces that
+	// TelemetryCollectorAllowK8sNamespacesSet allows and TelemetryCollectorDenyK8sNamespacesSet
+	// doesn't deny do.
+	EnableTelemetryCollector                bool
+	TelemetryCollectorAllowK8sNamespacesSet mapset.Set
+	TelemetryCollectorDenyK8sNamespacesSet  mapset.Set
+	// EnableRolloutSubsets adds the role of pods in Argo Rollouts and Flagger canary deployments to
+	// the meta of their service instances and writes a service resolver with a stable and a canary
+	// subset for their services.
+	EnableRolloutSubsets bool
+	// EnableServiceLocality registers the service instances of pods with the region and zone of
+	// their node, taken from its topology labels, so that Consul prefers instances in the same
+	// zone. It can be overridden per pod via annotation.
+	EnableServiceLocality bool
+	// EnablePeerFailover writes a service resolver for the services of pods that fails over to the
+	// peers with an active peering that import them. It can be overridden per pod via annotation.
+	EnablePeerFailover bool
+	// EnvoyAccessLogs are the default access log settings of the proxies of pods, which are
+	// registered with them. They can be overridden per pod via annotations.
+	EnvoyAccessLogs EnvoyAccessLogs
+	// NodeProxyInboundPort is the port the node proxy accepts mesh traffic for pods in node proxy
+	// mode on. It defaults to DefaultNodeProxyInboundPort.
+	NodeProxyInboundPort int
 	// AuthMethod is the name of the Kubernetes Auth Method that
 	// was used to login with Consul. The Endpoints controller
 	// will delete any tokens associated with this auth method
 	// whenever service instances are deregistered.
 	AuthMethod string
+	// MigrationConsulClient points at the servers of a second Consul datacenter that
+	// this cluster is being migrated to. When set, service instances are registered
+	// into both datacenters so that there is no registration gap at cutover.
+	MigrationConsulClient *api.Client
+	// MigrationNodeName is the name of the synthetic node that service instances are
+	// registered on in the datacenter being migrated to.
+	MigrationNodeName string
+	// ServiceCache caches the service instances on the nodes of the client agents. If set,
+	// agents that have no instances to deregister according to the cache aren't queried,
+	// and the Endpoints are reconciled again when their instances in the cache change.
+	ServiceCache *ServiceCache
+	// MigrationServiceCache caches the service instances on the node in the datacenter
+	// being migrated to. If set, they are read from the cache instead of the catalog.
+	MigrationServiceCache *ServiceCache
+	// Shards shards the reconcile work across the connect-inject replicas by
+	// namespace. If set, only endpoints in the namespaces this replica owns are
+	// reconciled; otherwise all are, and only the leader should run the controller.
+	Shards *ShardMembership
+	// ConsulHealth tracks whether the Consul servers are reachable. If set, reconciles
+	// that fail while they aren't are requeued with backoff instead of being logged.
+	ConsulHealth *consul.HealthTracker
+	// Audit records the service instances, ACL tokens and config entries the controller
+	// writes to Consul. It records nothing if nil.
+	Audit *consul.AuditTrail
 
 	MetricsConfig MetricsConfig
 	Log           logr.Logger
@@ -121,6 +199,10 @@ type EndpointsController struct {
 // Reconcile reads the state of an Endpoints object for a Kubernetes Service and reconciles Consul services which
 // correspond to the Kubernetes Service. These events are driven by changes to the Pods backing the Kube service.
 func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return r.ConsulHealth.Backoff(r.reconcile(ctx, req))
+}
+
+func (r *EndpointsController) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	var errs error
 	var serviceEndpoints corev1.Endpoints
 
@@ -129,6 +211,11 @@ func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, nil
 	}
 
+	// Ignore the request if another replica owns the namespace.
+	if r.Shards != nil && !r.Shards.Owns(req.Namespace) {
+		return ctrl.Result{}, nil
+	}
+
 	err := r.Client.Get(ctx, req.NamespacedName, &serviceEndpoints)
 
 	// endpointPods holds a set of all pods this endpoints object is currently pointing to.
@@ -163,6 +250,25 @@ func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (
 	// against service instances in Consul to deregister them if they are not in the map.
 	endpointAddressMap := map[string]bool{}
 
+	// requeueAfter is when the deregistration policy of a pod in the Endpoints next changes its
+	// service instance, if the pod doesn't change before.
+	now := time.Now()
+	var requeueAfter time.Duration
+
+	// peerFailoverServices are the services whose service resolver fails over to peers.
+	peerFailoverServices := map[types.NamespacedName]bool{}
+
+	// If the namespace is in maintenance mode, no service instances are deregistered so that pods
+	// being evicted or not ready during the maintenance don't churn the Consul catalog. Those
+	// instances are deregistered once the namespace is taken out of maintenance mode. If it isn't
+	// known whether the namespace is in maintenance mode, it is treated as if it were.
+	inMaintenance, maintenanceErr := r.isNamespaceInMaintenance(ctx, serviceEndpoints.Namespace)
+	if maintenanceErr != nil {
+		r.Log.Error(maintenanceErr, "failed to get namespace", "name", serviceEndpoints.Namespace)
+		errs = multierror.Append(errs, maintenanceErr)
+	}
+	keepInstances := inMaintenance || maintenanceErr != nil
+
 	// Register all addresses of this Endpoints object as service instances in Consul.
 	for _, subset := range serviceEndpoints.Subsets {
 		for address, healthStatus := range mapAddresses(subset) {
@@ -184,15 +290,88 @@ func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (
 
 				if hasBeenInjected(pod) {
 					endpointPods.Add(address.TargetRef.Name)
-					if err := r.registerServicesAndHealthCheck(pod, serviceEndpoints, healthStatus, endpointAddressMap); err != nil {
+					// Kubernetes may not have moved a pod that started terminating to the not ready
+					// addresses yet, but it is going away so it shouldn't receive new traffic.
+					if podTerminating(pod) {
+						healthStatus = api.HealthCritical
+					}
+					// Job pods are registered so that their proxies can be configured, but don't
+					// receive traffic unless annotated.
+					jobStatus, err := jobHealthStatus(pod, healthStatus)
+					if err != nil {
+						r.Log.Error(err, "failed to get health status of job pod", "name", pod.Name, "ns", pod.Namespace)
+						errs = multierror.Append(errs, err)
+						continue
+					}
+					healthStatus = jobStatus
+					policy, err := r.deregistrationPolicy(pod)
+					if err != nil {
+						r.Log.Error(err, "failed to get deregistration policy", "name", pod.Name, "ns", pod.Namespace)
+						errs = multierror.Append(errs, err)
+						continue
+					}
+					decision := policy.decide(pod, healthStatus, now)
+					requeueAfter = minRequeueAfter(requeueAfter, decision.requeueAfter)
+					// In maintenance mode the instance stays registered as critical instead.
+					if decision.deregister && !keepInstances {
+						// Instances of terminating pods are deregistered below because their
+						// addresses aren't added to the endpointAddressMap.
+						if podTerminating(pod) || !managedByEndpointsController(pod) {
+							continue
+						}
+						endpointAddressMap[pod.Status.PodIP] = true
+						if err := r.deregisterNotReadyPod(pod, serviceEndpoints); err != nil {
+							r.Log.Error(err, "failed to deregister service of not ready pod", "name", pod.Name, "ns", pod.Namespace)
+							errs = multierror.Append(errs, err)
+						}
+						continue
+					}
+					if err := r.registerServicesAndHealthCheck(pod, serviceEndpoints, healthStatus, decision.holdPassing, endpointAddressMap); err != nil {
 						r.Log.Error(err, "failed to register services or health check", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
 						errs = multierror.Append(errs, err)
+						continue
+					}
+					if managedByEndpointsController(pod) {
+						peerFailover, err := peerFailoverEnabled(pod, r.EnablePeerFailover)
+						if err != nil {
+							r.Log.Error(err, "failed to parse peer failover annotation", "name", pod.Name, "ns", pod.Namespace)
+							errs = multierror.Append(errs, err)
+						} else if peerFailover {
+							peerFailoverServices[types.NamespacedName{Name: getServiceName(pod, serviceEndpoints), Namespace: r.consulNamespace(pod.Namespace)}] = true
+						}
 					}
 				}
 			}
 		}
 	}
 
+	for service := range peerFailoverServices {
+		if err := r.upsertPeerFailover(ctx, service.Name, service.Namespace); err != nil {
+			r.Log.Error(err, "failed to write peer failover", "name", service.Name, "ns", service.Namespace)
+			errs = multierror.Append(errs, err)
+		}
+	}
+	if len(peerFailoverServices) > 0 {
+		requeueAfter = minRequeueAfter(requeueAfter, wait.Jitter(peerFailoverResyncPeriod, 0.1))
+	}
+
+	// Keep the service instances of terminating pods that have been removed from the Endpoints
+	// registered as critical until they stop.
+	drainRequeueAfter, err := r.drainTerminatingPods(ctx, serviceEndpoints, endpointAddressMap, now, keepInstances)
+	if err != nil {
+		r.Log.Error(err, "failed to drain terminating pods", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+		errs = multierror.Append(errs, err)
+	}
+	requeueAfter = minRequeueAfter(requeueAfter, drainRequeueAfter)
+
+	if maintenanceErr != nil {
+		return ctrl.Result{RequeueAfter: requeueAfter}, errs
+	}
+	if inMaintenance {
+		r.Log.Info("skipping deregistration because namespace is in maintenance mode", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+		return ctrl.Result{RequeueAfter: requeueAfter}, errs
+	}
+
 	// Compare service instances in Consul with addresses in Endpoints. If an address is not in Endpoints, deregister
 	// from Consul. This uses endpointAddressMap which is populated with the addresses in the Endpoints object during
 	// the registration codepath.
@@ -201,26 +380,81 @@ func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (
 		errs = multierror.Append(errs, err)
 	}
 
-	return ctrl.Result{}, errs
+	return ctrl.Result{RequeueAfter: requeueAfter}, errs
 }
 
 func (r *EndpointsController) Logger(name types.NamespacedName) logr.Logger {
 	return r.Log.WithValues("request", name)
 }
 
+// SetupWithManager adds the controller to the manager. Unless the reconcile work is sharded, the
+// controller only runs on the leader. If it is, it runs on every replica, each reconciling the
+// namespaces it owns, while the other controllers of the manager still only run on the leader.
 func (r *EndpointsController) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.Endpoints{}).
-		Watches(
-			&source.Kind{Type: &corev1.Pod{}},
-			handler.EnqueueRequestsFromMapFunc(r.requestsForRunningAgentPods),
-			builder.WithPredicates(predicate.NewPredicateFuncs(r.filterAgentPods)),
-		).Complete(r)
+	c, err := controller.NewUnmanaged("endpoints", mgr, controller.Options{
+		Reconciler: r,
+		Log:        mgr.GetLogger().WithValues("reconciler group", "", "reconciler kind", "Endpoints"),
+	})
+	if err != nil {
+		return err
+	}
+	if err := c.Watch(&source.Kind{Type: &corev1.Endpoints{}}, &handler.EnqueueRequestForObject{}); err != nil {
+		return err
+	}
+	if err := c.Watch(
+		&source.Kind{Type: &corev1.Pod{}},
+		handler.EnqueueRequestsFromMapFunc(r.requestsForRunningAgentPods),
+		predicate.NewPredicateFuncs(r.filterAgentPods),
+	); err != nil {
+		return err
+	}
+	if err := c.Watch(
+		&source.Kind{Type: &corev1.Pod{}},
+		handler.EnqueueRequestsFromMapFunc(r.requestsForTerminatingPod),
+		podTerminationChanged(),
+	); err != nil {
+		return err
+	}
+	if err := c.Watch(
+		&source.Kind{Type: &corev1.Namespace{}},
+		handler.EnqueueRequestsFromMapFunc(r.requestsForNamespace),
+		maintenanceModeEnded(),
+	); err != nil {
+		return err
+	}
+	if r.ServiceCache != nil && r.ServiceCache.Events != nil {
+		if err := c.Watch(&source.Channel{Source: r.ServiceCache.Events}, &handler.EnqueueRequestForObject{}); err != nil {
+			return err
+		}
+	}
+	if r.Shards == nil {
+		return mgr.Add(c)
+	}
+
+	if err := c.Watch(
+		&source.Channel{Source: r.Shards.Events},
+		handler.EnqueueRequestsFromMapFunc(r.requestsForShardChange),
+	); err != nil {
+		return err
+	}
+	return mgr.Add(shardedController{Controller: c})
+}
+
+// shardedController runs a controller on every replica instead of only on the leader.
+type shardedController struct {
+	controller.Controller
+}
+
+// NeedLeaderElection returns false so that every replica runs the controller.
+// It implements manager.LeaderElectionRunnable.
+func (shardedController) NeedLeaderElection() bool {
+	return false
 }
 
 // registerServicesAndHealthCheck creates Consul registrations for the service and proxy and registers them with Consul.
 // It also upserts a Kubernetes health check for the service based on whether the endpoint address is ready.
-func (r *EndpointsController) registerServicesAndHealthCheck(pod corev1.Pod, serviceEndpoints corev1.Endpoints, healthStatus string, endpointAddressMap map[string]bool) error {
+// If holdPassing is true, a passing health check stays passing although the endpoint address isn't ready.
+func (r *EndpointsController) registerServicesAndHealthCheck(pod corev1.Pod, serviceEndpoints corev1.Endpoints, healthStatus string, holdPassing bool, endpointAddressMap map[string]bool) error {
 	podHostIP := pod.Status.HostIP
 
 	if hasBeenInjected(pod) {
@@ -233,14 +467,12 @@ func (r *EndpointsController) registerServicesAndHealthCheck(pod corev1.Pod, ser
 			return err
 		}
 
-		var managedByEndpointsController bool
-		if raw, ok := pod.Labels[keyManagedBy]; ok && raw == managedByValue {
-			managedByEndpointsController = true
-		}
+		managedByEndpointsController := managedByEndpointsController(pod)
+		var serviceRegistration, proxyServiceRegistration *api.AgentServiceRegistration
 		// For pods managed by this controller, create and register the service instance.
 		if managedByEndpointsController {
 			// Get information from the pod to create service instance registrations.
-			serviceRegistration, proxyServiceRegistration, err := r.createServiceRegistrations(pod, serviceEndpoints)
+			serviceRegistration, proxyServiceRegistration, err = r.createServiceRegistrations(pod, serviceEndpoints)
 			if err != nil {
 				r.Log.Error(err, "failed to create service registrations for endpoints", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
 				return err
@@ -257,6 +489,7 @@ func (r *EndpointsController) registerServicesAndHealthCheck(pod corev1.Pod, ser
 				r.Log.Error(err, "failed to register service", "name", serviceRegistration.Name)
 				return err
 			}
+			r.auditServiceInstance(&pod, consul.AuditOpRegister, serviceRegistration.ID, serviceRegistration.Namespace, serviceRegistration)
 
 			// Register the proxy service instance with the local agent.
 			r.Log.Info("registering proxy service with Consul", "name", proxyServiceRegistration.Name)
@@ -265,6 +498,14 @@ func (r *EndpointsController) registerServicesAndHealthCheck(pod corev1.Pod, ser
 				r.Log.Error(err, "failed to register proxy service", "name", proxyServiceRegistration.Name)
 				return err
 			}
+			r.auditServiceInstance(&pod, consul.AuditOpRegister, proxyServiceRegistration.ID, proxyServiceRegistration.Namespace, proxyServiceRegistration)
+
+			if serviceRegistration.Meta[MetaKeyRolloutRole] != "" {
+				if err := r.upsertRolloutSubsets(serviceRegistration); err != nil {
+					r.Log.Error(err, "failed to write rollout subsets", "name", serviceRegistration.Name)
+					return err
+				}
+			}
 		}
 
 		// Update the service TTL health check for both legacy services and services managed by endpoints
@@ -272,21 +513,62 @@ func (r *EndpointsController) registerServicesAndHealthCheck(pod corev1.Pod, ser
 		// lifecycle sidecar for legacy services. Here, we always update the health check for legacy and
 		// newer services idempotently since the service health check is not added as part of the service
 		// registration.
-		reason := getHealthCheckStatusReason(healthStatus, pod.Name, pod.Namespace)
+		reason := getHealthCheckStatusReason(healthStatus, pod)
 		serviceName := getServiceName(pod, serviceEndpoints)
 		r.Log.Info("updating health check status for service", "name", serviceName, "reason", reason, "status", healthStatus)
 		serviceID := getServiceID(pod, serviceEndpoints)
 		healthCheckID := getConsulHealthCheckID(pod, serviceID)
-		err = r.upsertHealthCheck(pod, client, serviceID, healthCheckID, healthStatus)
+		healthStatus, err = r.upsertHealthCheck(pod, client, serviceID, healthCheckID, healthStatus, holdPassing)
 		if err != nil {
 			r.Log.Error(err, "failed to update health check status for service", "name", serviceName)
 			return err
 		}
+		reason = getHealthCheckStatusReason(healthStatus, pod)
+
+		if managedByEndpointsController {
+			probeChecksEnabled, err := probeHealthChecksEnabled(pod, r.EnableProbeHealthChecks)
+			if err != nil {
+				return err
+			}
+			if probeChecksEnabled {
+				r.Log.Info("updating probe health checks for service", "name", serviceName)
+				err = r.upsertProbeHealthChecks(pod, client, serviceID)
+				if err != nil {
+					r.Log.Error(err, "failed to update probe health checks for service", "name", serviceName)
+					return err
+				}
+			}
+		}
+
+		// While migrating to another datacenter, also register the service instances there.
+		if r.MigrationConsulClient != nil && managedByEndpointsController {
+			err = r.registerMigrationServices(serviceRegistration, proxyServiceRegistration, healthStatus, reason)
+			if err != nil {
+				r.Log.Error(err, "failed to register service with migration datacenter", "name", serviceName)
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
+// auditServiceInstance records a registration or deregistration of a service instance made for
+// the Kubernetes object. The registration is nil for deregistrations.
+func (r *EndpointsController) auditServiceInstance(obj client.Object, op, id, namespace string, registration *api.AgentServiceRegistration) {
+	w := consul.AuditWrite{
+		Operation: op,
+		Kind:      consul.AuditKindServiceInstance,
+		Name:      id,
+		Namespace: namespace,
+	}
+	// A nil registration must not be stored as a non-nil payload.
+	if registration != nil {
+		w.Payload = registration
+	}
+	r.Audit.Record(obj, w)
+}
+
 // getServiceCheck will return the health check for this pod and service if it exists.
 func getServiceCheck(client *api.Client, healthCheckID string) (*api.AgentCheck, error) {
 	filter := fmt.Sprintf("CheckID == `%s`", healthCheckID)
@@ -339,34 +621,38 @@ func (r *EndpointsController) updateConsulHealthCheckStatus(client *api.Client,
 }
 
 // upsertHealthCheck checks if the healthcheck exists for the service, and creates it if it doesn't exist, or updates it
-// if it does.
-func (r *EndpointsController) upsertHealthCheck(pod corev1.Pod, client *api.Client, serviceID, healthCheckID, status string) error {
-	reason := getHealthCheckStatusReason(status, pod.Name, pod.Namespace)
+// if it does. If holdPassing is true, an existing passing health check is left passing. It returns the status of the
+// health check.
+func (r *EndpointsController) upsertHealthCheck(pod corev1.Pod, client *api.Client, serviceID, healthCheckID, status string, holdPassing bool) (string, error) {
+	reason := getHealthCheckStatusReason(status, pod)
 	// Retrieve the health check that would exist if the service had one registered for this pod.
 	serviceCheck, err := getServiceCheck(client, healthCheckID)
 	if err != nil {
-		return fmt.Errorf("unable to get agent health checks: serviceID=%s, checkID=%s, %s", serviceID, healthCheckID, err)
+		return "", fmt.Errorf("unable to get agent health checks: serviceID=%s, checkID=%s, %w", serviceID, healthCheckID, err)
 	}
 	if serviceCheck == nil {
 		// Create a new health check.
 		err = registerConsulHealthCheck(client, healthCheckID, serviceID, status)
 		if err != nil {
-			return err
+			return "", err
 		}
 
 		// Also update it, the reason this is separate is there is no way to set the Output field of the health check
 		// at creation time, and this is what is displayed on the UI as opposed to the Notes field.
 		err = r.updateConsulHealthCheckStatus(client, healthCheckID, status, reason)
 		if err != nil {
-			return err
+			return "", err
 		}
+	} else if holdPassing && serviceCheck.Status == api.HealthPassing {
+		r.Log.Info("keeping health check passing during not ready grace period", "id", healthCheckID)
+		return api.HealthPassing, nil
 	} else if serviceCheck.Status != status {
 		err = r.updateConsulHealthCheckStatus(client, healthCheckID, status, reason)
 		if err != nil {
-			return err
+			return "", err
 		}
 	}
-	return nil
+	return status, nil
 }
 
 // getServiceName computes the service name to register with Consul from the pod and endpoints object. In a single port
@@ -403,8 +689,10 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 	// and register that port for the host service.
 	// The handler will always set the port annotation if one is not provided on the pod.
 	var consulServicePort int
+	multiPortPod := false
 	if raw, ok := pod.Annotations[annotationPort]; ok && raw != "" {
 		if multiPort := strings.Split(raw, ","); len(multiPort) > 1 {
+			multiPortPod = true
 			// Figure out which index of the ports annotation to use by
 			// finding the index of the service names annotation.
 			raw = multiPort[getMultiPortIdx(pod, serviceEndpoints)]
@@ -417,6 +705,18 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 		}
 	}
 
+	// When the port annotation was defaulted by the handler it is simply the first port of the first
+	// container, which isn't necessarily the port the Kubernetes Service sends traffic to. The Endpoints
+	// object has already resolved the Service's (possibly named) targetPorts for this pod, so prefer
+	// those ports, keeping the defaulted port if the Service targets it.
+	endpointPorts := endpointPortsForPod(pod, serviceEndpoints)
+	if !multiPortPod && len(endpointPorts) > 0 && portAnnotationDefaulted(pod) {
+		if findEndpointPort(endpointPorts, consulServicePort) == nil {
+			consulServicePort = int(endpointPorts[0].Port)
+		}
+	}
+	endpointPort := findEndpointPort(endpointPorts, consulServicePort)
+
 	// We only want that annotation to be present when explicitly overriding the consul svc name
 	// Otherwise, the Consul service name should equal the Kubernetes Service name.
 	// The service name in Consul defaults to the Endpoints object name, and is overridden by the pod
@@ -431,16 +731,48 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 		MetaKeyKubeNS:          serviceEndpoints.Namespace,
 		MetaKeyManagedBy:       managedByValue,
 	}
-	for k, v := range pod.Annotations {
-		if strings.HasPrefix(k, annotationMeta) && strings.TrimPrefix(k, annotationMeta) != "" {
-			if v == "$POD_NAME" {
-				meta[strings.TrimPrefix(k, annotationMeta)] = pod.Name
-			} else {
-				meta[strings.TrimPrefix(k, annotationMeta)] = v
-			}
+	podMeta, err := consulMeta(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range podMeta {
+		meta[k] = v
+	}
+	// The cluster ID is set after the meta from annotations so that pods can't claim the
+	// ownership of another cluster.
+	if r.ClusterID != "" {
+		meta[MetaKeyClusterID] = r.ClusterID
+	}
+	if endpointPort != nil && endpointPort.Name != "" {
+		meta[MetaKeyKubeServicePortName] = endpointPort.Name
+	}
+	if hostPort := containerHostPort(pod, consulServicePort); hostPort > 0 {
+		meta[MetaKeyHostPort] = strconv.Itoa(int(hostPort))
+	}
+	if r.EnableRolloutSubsets {
+		role, err := r.rolloutRole(r.Context, pod)
+		if err != nil {
+			return nil, nil, err
+		}
+		if role != "" {
+			meta[MetaKeyRolloutRole] = role
+		}
+	}
+	tags, err := consulTags(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var locality *api.Locality
+	localityEnabled, err := serviceLocalityEnabled(pod, r.EnableServiceLocality)
+	if err != nil {
+		return nil, nil, err
+	}
+	if localityEnabled {
+		if locality, err = r.serviceLocality(r.Context, pod); err != nil {
+			return nil, nil, err
 		}
 	}
-	tags := consulTags(pod)
 
 	service := &api.AgentServiceRegistration{
 		ID:        serviceID,
@@ -450,6 +782,7 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 		Meta:      meta,
 		Namespace: r.consulNamespace(pod.Namespace),
 		Tags:      tags,
+		Locality:  locality,
 	}
 
 	proxyServiceName := getProxyServiceName(pod, serviceEndpoints)
@@ -478,6 +811,12 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 		proxyConfig.Config[envoyPrometheusBindAddr] = prometheusScrapeListener
 	}
 
+	// If the telemetry collector is enabled, Envoy sends its metrics through a socket in the
+	// directory shared with the init container, which Consul routes to the collector.
+	if r.EnableTelemetryCollector && !shouldIgnore(pod.Namespace, r.TelemetryCollectorDenyK8sNamespacesSet, r.TelemetryCollectorAllowK8sNamespacesSet) {
+		proxyConfig.Config[envoyTelemetryCollectorBindSocketDir] = "/consul/connect-inject"
+	}
+
 	if consulServicePort > 0 {
 		proxyConfig.LocalServiceAddress = "127.0.0.1"
 		proxyConfig.LocalServicePort = consulServicePort
@@ -489,6 +828,12 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 	}
 	proxyConfig.Upstreams = upstreams
 
+	accessLogs, err := envoyAccessLogs(pod, r.EnvoyAccessLogs)
+	if err != nil {
+		return nil, nil, err
+	}
+	proxyConfig.AccessLogs = accessLogs
+
 	proxyPort := 20000
 	if idx := getMultiPortIdx(pod, serviceEndpoints); idx >= 0 {
 		proxyPort += idx
@@ -502,6 +847,7 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 		Meta:      meta,
 		Namespace: r.consulNamespace(pod.Namespace),
 		Proxy:     proxyConfig,
+		Locality:  locality,
 		Checks: api.AgentServiceChecks{
 			{
 				Name:                           "Proxy Public Listener",
@@ -517,6 +863,11 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 		Tags: tags,
 	}
 
+	if usesNodeProxy(pod) {
+		r.useNodeProxy(pod, proxyService)
+		return service, proxyService, nil
+	}
+
 	// A user can enable/disable tproxy for an entire namespace.
 	var ns corev1.Namespace
 	err = r.Client.Get(r.Context, types.NamespacedName{Name: pod.Namespace, Namespace: ""}, &ns)
@@ -547,6 +898,16 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 			// on a single service.
 			var k8sServicePort int32
 			for _, sp := range k8sService.Spec.Ports {
+				// Endpoints ports carry the name of the Service port they were resolved from,
+				// which is unambiguous even when several Service ports target the same pod port.
+				if endpointPort != nil && endpointPort.Name != "" {
+					if sp.Name == endpointPort.Name {
+						k8sServicePort = sp.Port
+						break
+					}
+					continue
+				}
+
 				targetPortValue, err := portValueFromIntOrString(pod, sp.TargetPort)
 				if err != nil {
 					return nil, nil, err
@@ -652,6 +1013,59 @@ func portValueFromIntOrString(pod corev1.Pod, port intstr.IntOrString) (int, err
 	return int(portVal), nil
 }
 
+// endpointPortsForPod returns the ports of the Endpoints subset that contains the pod.
+// Kubernetes resolves the Service's targetPorts, including named ports, separately for
+// every pod, so these are the container ports that the Service routes to on this pod.
+func endpointPortsForPod(pod corev1.Pod, serviceEndpoints corev1.Endpoints) []corev1.EndpointPort {
+	for _, subset := range serviceEndpoints.Subsets {
+		for address := range mapAddresses(subset) {
+			if address.TargetRef != nil && address.TargetRef.Kind == "Pod" && address.TargetRef.Name == pod.Name {
+				return subset.Ports
+			}
+		}
+	}
+	return nil
+}
+
+// findEndpointPort returns the endpoint port with the given port number or nil if there isn't one.
+func findEndpointPort(ports []corev1.EndpointPort, port int) *corev1.EndpointPort {
+	for i := range ports {
+		if int(ports[i].Port) == port {
+			return &ports[i]
+		}
+	}
+	return nil
+}
+
+// portAnnotationDefaulted returns true if the port annotation was not set on the pod
+// by the user but defaulted by the handler. Pods without the original pod annotation
+// are treated as having set the port explicitly.
+func portAnnotationDefaulted(pod corev1.Pod) bool {
+	raw, ok := pod.Annotations[annotationOriginalPod]
+	if !ok {
+		return false
+	}
+	var originalPod corev1.Pod
+	if err := json.Unmarshal([]byte(raw), &originalPod); err != nil {
+		return false
+	}
+	_, ok = originalPod.Annotations[annotationPort]
+	return !ok
+}
+
+// containerHostPort returns the host port that is mapped to the given container port
+// or 0 if the port isn't exposed on the host.
+func containerHostPort(pod corev1.Pod, port int) int32 {
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if int(p.ContainerPort) == port && p.HostPort > 0 {
+				return p.HostPort
+			}
+		}
+	}
+	return 0
+}
+
 // getConsulHealthCheckID deterministically generates a health check ID that will be unique to the Agent
 // where the health check is registered and deregistered.
 func getConsulHealthCheckID(pod corev1.Pod, serviceID string) string {
@@ -659,13 +1073,19 @@ func getConsulHealthCheckID(pod corev1.Pod, serviceID string) string {
 }
 
 // getHealthCheckStatusReason takes an Consul's health check status (either passing or critical)
-// as well as pod name and namespace and returns the reason message.
-func getHealthCheckStatusReason(healthCheckStatus, podName, podNamespace string) string {
+// as well as the pod and returns the reason message.
+func getHealthCheckStatusReason(healthCheckStatus string, pod corev1.Pod) string {
 	if healthCheckStatus == api.HealthPassing {
 		return kubernetesSuccessReasonMsg
 	}
+	if podTerminating(pod) {
+		return podTerminatingReason(pod)
+	}
+	if serves, err := jobServesTraffic(pod); err == nil && !serves {
+		return jobPodReason(pod)
+	}
 
-	return fmt.Sprintf("Pod \"%s/%s\" is not ready", podNamespace, podName)
+	return fmt.Sprintf("Pod \"%s/%s\" is not ready", pod.Namespace, pod.Name)
 }
 
 // deregisterServiceOnAllAgents queries all agents for service instances that have the metadata
@@ -679,6 +1099,9 @@ func getHealthCheckStatusReason(healthCheckStatus, podName, podNamespace string)
 // them only if they are not in endpointsAddressesMap. If the map is nil, it will deregister all instances. If the map
 // has addresses, it will only deregister instances not in the map.
 func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context, k8sSvcName, k8sSvcNamespace string, endpointsAddressesMap map[string]bool) error {
+	// The Endpoints object may already be deleted, so only its name is known.
+	auditEndpoints := &corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: k8sSvcName, Namespace: k8sSvcNamespace}}
+
 	// Get all agents by getting pods with label component=client, app=consul and release=<ReleaseName>
 	agents := corev1.PodList{}
 	listOptions := client.ListOptions{
@@ -694,6 +1117,15 @@ func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context,
 		return err
 	}
 
+	// Stop watching the nodes whose agent is gone.
+	if r.ServiceCache != nil {
+		nodes := make(map[string]bool)
+		for _, agent := range agents.Items {
+			nodes[agent.Spec.NodeName] = true
+		}
+		r.ServiceCache.Retain(nodes)
+	}
+
 	// On each agent, we need to get services matching "k8s-service-name" and "k8s-namespace" metadata.
 	for _, agent := range agents.Items {
 		ready := false
@@ -708,6 +1140,13 @@ func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context,
 			r.Log.Info("Consul client agent is not ready, skipping deregistration", "consul-agent", agent.Name, "svc", k8sSvcName)
 			continue
 		}
+		// Skip the agent if it has nothing to deregister according to the cache.
+		if r.ServiceCache != nil {
+			cached, ok := r.ServiceCache.ServiceInstances(agent.Spec.NodeName, k8sSvcName, k8sSvcNamespace)
+			if ok && !hasInstancesToDeregister(cached, endpointsAddressesMap) {
+				continue
+			}
+		}
 		client, err := r.remoteConsulClient(agent.Status.PodIP, r.consulNamespace(k8sSvcNamespace))
 		if err != nil {
 			r.Log.Error(err, "failed to create a new Consul client", "address", agent.Status.PodIP)
@@ -723,6 +1162,9 @@ func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context,
 
 		// Deregister each service instance that matches the metadata.
 		for svcID, serviceRegistration := range svcs {
+			if !r.ownsServiceInstance(serviceRegistration) {
+				continue
+			}
 			// If we selectively deregister, only deregister if the address is not in the map. Otherwise, deregister
 			// every service instance.
 			var serviceDeregistered bool
@@ -734,6 +1176,7 @@ func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context,
 						r.Log.Error(err, "failed to deregister service instance", "id", svcID)
 						return err
 					}
+					r.auditServiceInstance(auditEndpoints, consul.AuditOpDeregister, svcID, serviceRegistration.Namespace, nil)
 					serviceDeregistered = true
 				}
 			} else {
@@ -742,6 +1185,7 @@ func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context,
 					r.Log.Error(err, "failed to deregister service instance", "id", svcID)
 					return err
 				}
+				r.auditServiceInstance(auditEndpoints, consul.AuditOpDeregister, svcID, serviceRegistration.Namespace, nil)
 				serviceDeregistered = true
 			}
 
@@ -756,9 +1200,28 @@ func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context,
 		}
 	}
 
+	// While migrating to another datacenter, deregister the same service instances there.
+	if r.MigrationConsulClient != nil {
+		if err := r.deregisterMigrationServices(k8sSvcName, k8sSvcNamespace, endpointsAddressesMap); err != nil {
+			r.Log.Error(err, "failed to deregister service instances from migration datacenter", "name", k8sSvcName)
+			return err
+		}
+	}
+
 	return nil
 }
 
+// hasInstancesToDeregister returns true if any of the service instances would be deregistered
+// by deregisterServiceOnAllAgents given the endpointsAddressesMap.
+func hasInstancesToDeregister(svcs map[string]*api.AgentService, endpointsAddressesMap map[string]bool) bool {
+	for _, svc := range svcs {
+		if endpointsAddressesMap == nil || !endpointsAddressesMap[svc.Address] {
+			return true
+		}
+	}
+	return false
+}
+
 // deleteACLTokensForServiceInstance finds the ACL tokens that belongs to the service instance and deletes it from Consul.
 // It will only check for ACL tokens that have been created with the auth method this controller
 // has been configured with and will only delete tokens for the provided podName.
@@ -770,7 +1233,7 @@ func (r *EndpointsController) deleteACLTokensForServiceInstance(client *api.Clie
 
 	tokens, _, err := client.ACL().TokenList(nil)
 	if err != nil {
-		return fmt.Errorf("failed to get a list of tokens from Consul: %s", err)
+		return fmt.Errorf("failed to get a list of tokens from Consul: %w", err)
 	}
 
 	for _, token := range tokens {
@@ -792,8 +1255,14 @@ func (r *EndpointsController) deleteACLTokensForServiceInstance(client *api.Clie
 				r.Log.Info("deleting ACL token for pod", "name", podName)
 				_, err = client.ACL().TokenDelete(token.AccessorID, nil)
 				if err != nil {
-					return fmt.Errorf("failed to delete token from Consul: %s", err)
+					return fmt.Errorf("failed to delete token from Consul: %w", err)
 				}
+				r.Audit.Record(nil, consul.AuditWrite{
+					Operation: consul.AuditOpDelete,
+					Kind:      consul.AuditKindACLToken,
+					Name:      token.AccessorID,
+					Namespace: token.Namespace,
+				})
 			} else if err != nil {
 				return err
 			}
@@ -830,6 +1299,20 @@ func serviceInstancesForK8SServiceNameAndNamespace(k8sServiceName, k8sServiceNam
 			MetaKeyKubeServiceName, k8sServiceName, MetaKeyKubeNS, k8sServiceNamespace, MetaKeyManagedBy, managedByValue))
 }
 
+// ownsServiceInstance returns true if the service instance was registered by this cluster. Instances
+// registered before the cluster ID was set are owned by every cluster. The instances of other clusters
+// are logged and counted since the same Kubernetes service name and namespace in several clusters
+// would otherwise deregister each other's instances.
+func (r *EndpointsController) ownsServiceInstance(svc *api.AgentService) bool {
+	owner, ok := svc.Meta[MetaKeyClusterID]
+	if !ok || owner == r.ClusterID {
+		return true
+	}
+	r.Log.Info("skipping deregistration of service instance registered by another cluster", "svc", svc.ID, "cluster-id", owner)
+	consul.RecordOwnershipConflict("connect-injector", "service-instance")
+	return false
+}
+
 // processUpstreams reads the list of upstreams from the Pod annotation and converts them into a list of api.Upstream
 // objects.
 func (r *EndpointsController) processUpstreams(pod corev1.Pod, endpoints corev1.Endpoints) ([]api.Upstream, error) {
@@ -840,79 +1323,36 @@ func (r *EndpointsController) processUpstreams(pod corev1.Pod, endpoints corev1.
 		return []api.Upstream{}, nil
 	}
 
-	var upstreams []api.Upstream
-	if raw, ok := pod.Annotations[annotationUpstreams]; ok && raw != "" {
-		for _, raw := range strings.Split(raw, ",") {
-			parts := strings.SplitN(raw, ":", 3)
-
-			var datacenter, serviceName, preparedQuery, namespace, partition string
-			var port int32
-			if strings.TrimSpace(parts[0]) == "prepared_query" {
-				port, _ = portValue(pod, strings.TrimSpace(parts[2]))
-				preparedQuery = strings.TrimSpace(parts[1])
-			} else {
-				port, _ = portValue(pod, strings.TrimSpace(parts[1]))
-
-				// If Consul Namespaces or Admin Partitions are enabled, attempt to parse the
-				// upstream for a namespace.
-				if r.EnableConsulNamespaces || r.EnableConsulPartitions {
-					pieces := strings.SplitN(parts[0], ".", 3)
-					switch len(pieces) {
-					case 3:
-						partition = strings.TrimSpace(pieces[2])
-						fallthrough
-					case 2:
-						namespace = strings.TrimSpace(pieces[1])
-						fallthrough
-					default:
-						serviceName = strings.TrimSpace(pieces[0])
-					}
-				} else {
-					serviceName = strings.TrimSpace(parts[0])
-				}
-
-				// parse the optional datacenter
-				if len(parts) > 2 {
-					datacenter = strings.TrimSpace(parts[2])
-
-					// Check if there's a proxy defaults config with mesh gateway
-					// mode set to local or remote. This helps users from
-					// accidentally forgetting to set a mesh gateway mode
-					// and then being confused as to why their traffic isn't
-					// routing.
-					entry, _, err := r.ConsulClient.ConfigEntries().Get(api.ProxyDefaults, api.ProxyConfigGlobal, nil)
-					if err != nil && strings.Contains(err.Error(), "Unexpected response code: 404") {
-						return []api.Upstream{}, fmt.Errorf("upstream %q is invalid: there is no ProxyDefaults config to set mesh gateway mode", raw)
-					} else if err == nil {
-						mode := entry.(*api.ProxyConfigEntry).MeshGateway.Mode
-						if mode != api.MeshGatewayModeLocal && mode != api.MeshGatewayModeRemote {
-							return []api.Upstream{}, fmt.Errorf("upstream %q is invalid: ProxyDefaults mesh gateway mode is neither %q nor %q", raw, api.MeshGatewayModeLocal, api.MeshGatewayModeRemote)
-						}
-					}
-					// NOTE: If we can't reach Consul we don't error out because
-					// that would fail the pod scheduling and this is a nice-to-have
-					// check, not something that should block during a Consul hiccup.
-				}
-			}
-
-			if port > 0 {
-				upstream := api.Upstream{
-					DestinationType:      api.UpstreamDestTypeService,
-					DestinationPartition: partition,
-					DestinationNamespace: namespace,
-					DestinationName:      serviceName,
-					Datacenter:           datacenter,
-					LocalBindPort:        int(port),
-				}
+	// Upstreams are validated when the pod is admitted, so invalid ones can
+	// only be found on pods created before the validation was added. They are
+	// skipped rather than failing the registration of the whole pod.
+	parsed, err := parseUpstreams(pod, r.EnableConsulNamespaces, r.EnableConsulPartitions)
+	if err != nil {
+		r.Log.Error(err, "skipping invalid upstreams", "name", pod.Name, "ns", pod.Namespace)
+	}
 
-				if preparedQuery != "" {
-					upstream.DestinationType = api.UpstreamDestTypePreparedQuery
-					upstream.DestinationName = preparedQuery
+	var upstreams []api.Upstream
+	for _, upstream := range parsed {
+		if upstream.Datacenter != "" {
+			// Check if there's a proxy defaults config with mesh gateway
+			// mode set to local or remote. This helps users from
+			// accidentally forgetting to set a mesh gateway mode
+			// and then being confused as to why their traffic isn't
+			// routing.
+			entry, _, err := r.ConsulClient.ConfigEntries().Get(api.ProxyDefaults, api.ProxyConfigGlobal, nil)
+			if err != nil && strings.Contains(err.Error(), "Unexpected response code: 404") {
+				return []api.Upstream{}, fmt.Errorf("upstream %q is invalid: there is no ProxyDefaults config to set mesh gateway mode", upstream.raw)
+			} else if err == nil {
+				mode := entry.(*api.ProxyConfigEntry).MeshGateway.Mode
+				if mode != api.MeshGatewayModeLocal && mode != api.MeshGatewayModeRemote {
+					return []api.Upstream{}, fmt.Errorf("upstream %q is invalid: ProxyDefaults mesh gateway mode is neither %q nor %q", upstream.raw, api.MeshGatewayModeLocal, api.MeshGatewayModeRemote)
 				}
-
-				upstreams = append(upstreams, upstream)
 			}
+			// NOTE: If we can't reach Consul we don't error out because
+			// that would fail the pod scheduling and this is a nice-to-have
+			// check, not something that should block during a Consul hiccup.
 		}
+		upstreams = append(upstreams, upstream.Upstream)
 	}
 
 	return upstreams, nil
@@ -1033,10 +1473,79 @@ func (r *EndpointsController) requestsForRunningAgentPods(object client.Object)
 	return requests
 }
 
+// isNamespaceInMaintenance returns true if the Kubernetes namespace has the label
+// `consul.hashicorp.com/maintenance-mode` set to a "truthy" value.
+func (r *EndpointsController) isNamespaceInMaintenance(ctx context.Context, name string) (bool, error) {
+	var namespace corev1.Namespace
+	err := r.Client.Get(ctx, types.NamespacedName{Name: name}, &namespace)
+	if k8serrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return isLabeledMaintenanceMode(namespace.Labels), nil
+}
+
+// maintenanceModeEnded filters namespace events to those where the namespace is
+// taken out of maintenance mode.
+func maintenanceModeEnded() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return isLabeledMaintenanceMode(e.ObjectOld.GetLabels()) && !isLabeledMaintenanceMode(e.ObjectNew.GetLabels())
+		},
+	}
+}
+
+// requestsForNamespace enqueues a request for each endpoints object in a namespace that has been
+// taken out of maintenance mode so that the deregistrations paused during the maintenance happen.
+func (r *EndpointsController) requestsForNamespace(object client.Object) []ctrl.Request {
+	if shouldIgnore(object.GetName(), r.DenyK8sNamespacesSet, r.AllowK8sNamespacesSet) {
+		return []ctrl.Request{}
+	}
+	r.Log.Info("namespace taken out of maintenance mode", "name", object.GetName())
+
+	var endpointsList corev1.EndpointsList
+	if err := r.Client.List(r.Context, &endpointsList, client.InNamespace(object.GetName())); err != nil {
+		r.Log.Error(err, "failed to list endpoints", "ns", object.GetName())
+		return []ctrl.Request{}
+	}
+
+	var requests []reconcile.Request
+	for _, ep := range endpointsList.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: ep.Name, Namespace: ep.Namespace}})
+	}
+	return requests
+}
+
+// requestsForShardChange enqueues a request for each endpoints object in the namespaces this
+// replica owns after the shard members have changed, so that namespaces that moved to this
+// replica are reconciled. Events for them may have been handled by a replica that left, or
+// ignored by this replica before it owned them.
+func (r *EndpointsController) requestsForShardChange(_ client.Object) []ctrl.Request {
+	var endpointsList corev1.EndpointsList
+	if err := r.Client.List(r.Context, &endpointsList); err != nil {
+		r.Log.Error(err, "failed to list endpoints")
+		return []ctrl.Request{}
+	}
+
+	var requests []reconcile.Request
+	for _, ep := range endpointsList.Items {
+		if shouldIgnore(ep.Namespace, r.DenyK8sNamespacesSet, r.AllowK8sNamespacesSet) || !r.Shards.Owns(ep.Namespace) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: ep.Name, Namespace: ep.Namespace}})
+	}
+	return requests
+}
+
 // consulNamespace returns the Consul destination namespace for a provided Kubernetes namespace
 // depending on Consul Namespaces being enabled and the value of namespace mirroring.
 func (r *EndpointsController) consulNamespace(namespace string) string {
-	return namespaces.ConsulNamespace(namespace, r.EnableConsulNamespaces, r.ConsulDestinationNamespace, r.EnableNSMirroring, r.NSMirroringPrefix)
+	return namespaces.ConsulNamespace(namespace, r.EnableConsulNamespaces, r.ConsulDestinationNamespace, r.EnableNSMirroring, r.NSMirroringPrefix, r.NSMirroringRules)
 }
 
 // hasBeenInjected checks the value of the status annotation and returns true if the Pod has been injected.
@@ -1061,6 +1570,13 @@ func mapAddresses(addresses corev1.EndpointSubset) map[corev1.EndpointAddress]st
 	return m
 }
 
+// managedByEndpointsController returns true if the service instances of the pod are registered
+// by the endpoints controller rather than by the lifecycle sidecar of legacy pods.
+func managedByEndpointsController(pod corev1.Pod) bool {
+	raw, ok := pod.Labels[keyManagedBy]
+	return ok && raw == managedByValue
+}
+
 // isLabeledIgnore checks the value of the label `consul.hashicorp.com/service-ignore` and returns true if the
 // label exists and is "truthy". Otherwise, it returns false.
 func isLabeledIgnore(labels map[string]string) bool {
@@ -1070,15 +1586,30 @@ func isLabeledIgnore(labels map[string]string) bool {
 	return shouldIgnore && labelExists && err == nil
 }
 
+// isLabeledMaintenanceMode checks the value of the label `consul.hashicorp.com/maintenance-mode` and returns true
+// if the label exists and is "truthy". Otherwise, it returns false.
+func isLabeledMaintenanceMode(labels map[string]string) bool {
+	value, labelExists := labels[labelMaintenanceMode]
+	inMaintenance, err := strconv.ParseBool(value)
+
+	return inMaintenance && labelExists && err == nil
+}
+
 // consulTags returns tags that should be added to the Consul service and proxy registrations.
-func consulTags(pod corev1.Pod) []string {
+// The annotations are rendered as templates of the pod's fields before they are split into tags.
+func consulTags(pod corev1.Pod) ([]string, error) {
 	var tags []string
-	if raw, ok := pod.Annotations[annotationTags]; ok && raw != "" {
-		tags = strings.Split(raw, ",")
-	}
-	// Get the tags from the deprecated tags annotation and combine.
-	if raw, ok := pod.Annotations[annotationConnectTags]; ok && raw != "" {
-		tags = append(tags, strings.Split(raw, ",")...)
+	for _, annotation := range []string{annotationTags, annotationConnectTags} {
+		// The tags of the deprecated tags annotation are combined with the others.
+		raw, ok := pod.Annotations[annotation]
+		if !ok || raw == "" {
+			continue
+		}
+		rendered, err := renderPodTemplate(pod, annotation, raw)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, strings.Split(rendered, ",")...)
 	}
 
 	var interpolatedTags []string
@@ -1093,7 +1624,30 @@ func consulTags(pod corev1.Pod) []string {
 		interpolatedTags = append(interpolatedTags, t)
 	}
 
-	return interpolatedTags
+	return interpolatedTags, nil
+}
+
+// consulMeta returns the meta from the service-meta annotations of the pod that should be added
+// to the Consul service and proxy registrations. The values are rendered as templates of the
+// pod's fields.
+func consulMeta(pod corev1.Pod) (map[string]string, error) {
+	meta := make(map[string]string)
+	for k, v := range pod.Annotations {
+		key := strings.TrimPrefix(k, annotationMeta)
+		if !strings.HasPrefix(k, annotationMeta) || key == "" {
+			continue
+		}
+		if v == "$POD_NAME" {
+			meta[key] = pod.Name
+			continue
+		}
+		rendered, err := renderPodTemplate(pod, k, v)
+		if err != nil {
+			return nil, err
+		}
+		meta[key] = rendered
+	}
+	return meta, nil
 }
 
 func getMultiPortIdx(pod corev1.Pod, serviceEndpoints corev1.Endpoints) int {
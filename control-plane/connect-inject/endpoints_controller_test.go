@@ -2,9 +2,11 @@ package connectinject
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	mapset "github.com/deckarep/golang-set"
 	logrtest "github.com/go-logr/logr/testing"
@@ -22,6 +24,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 )
 
 const (
@@ -3442,6 +3445,205 @@ func TestReconcileIgnoresServiceIgnoreLabel(t *testing.T) {
 	}
 }
 
+// Test that service instances are not deregistered while their namespace is in maintenance mode
+// but that new service instances are still registered.
+func TestReconcile_MaintenanceMode(t *testing.T) {
+	t.Parallel()
+	nodeName := "test-node"
+	serviceName := "service-maintenance"
+	namespace := "default"
+
+	cases := map[string]struct {
+		namespaceLabels map[string]string
+		// pod1NotReady makes pod1 not ready for longer than its deregistration policy allows.
+		pod1NotReady            bool
+		expectedNumSvcInstances int
+	}{
+		"Namespace in maintenance mode keeps stale instances": {
+			namespaceLabels:         map[string]string{labelMaintenanceMode: "true"},
+			expectedNumSvcInstances: 2,
+		},
+		"Namespace with maintenance mode disabled deregisters stale instances": {
+			namespaceLabels:         map[string]string{labelMaintenanceMode: "false"},
+			expectedNumSvcInstances: 1,
+		},
+		"Namespace without label deregisters stale instances": {
+			namespaceLabels:         map[string]string{},
+			expectedNumSvcInstances: 1,
+		},
+		"Namespace in maintenance mode keeps instances of pods not ready past the deregistration timeout": {
+			namespaceLabels:         map[string]string{labelMaintenanceMode: "true"},
+			pod1NotReady:            true,
+			expectedNumSvcInstances: 2,
+		},
+		"Namespace without label deregisters instances of pods not ready past the deregistration timeout": {
+			namespaceLabels:         map[string]string{},
+			pod1NotReady:            true,
+			expectedNumSvcInstances: 0,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Set up the fake Kubernetes client with an endpoint for pod1 only, since pod2 has been evicted.
+			endpoint := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      serviceName,
+					Namespace: namespace,
+				},
+				Subsets: []corev1.EndpointSubset{
+					{
+						Addresses: []corev1.EndpointAddress{
+							{
+								IP:       "1.2.3.4",
+								NodeName: &nodeName,
+								TargetRef: &corev1.ObjectReference{
+									Kind:      "Pod",
+									Name:      "pod1",
+									Namespace: namespace,
+								},
+							},
+						},
+					},
+				},
+			}
+			pod1 := createPod("pod1", "1.2.3.4", true, true)
+			if tt.pod1NotReady {
+				pod1.Annotations[annotationDeregisterNotReadyAfter] = "10s"
+				pod1.Status.Conditions = []corev1.PodCondition{{
+					Type:               corev1.PodReady,
+					Status:             corev1.ConditionFalse,
+					LastTransitionTime: metav1.NewTime(time.Now().Add(-time.Minute)),
+				}}
+				endpoint.Subsets[0].NotReadyAddresses = endpoint.Subsets[0].Addresses
+				endpoint.Subsets[0].Addresses = nil
+			}
+			fakeClientPod := createPod("fake-consul-client", "127.0.0.1", false, true)
+			fakeClientPod.Labels = map[string]string{"component": "client", "app": "consul", "release": "consul"}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace, Labels: tt.namespaceLabels}}
+			k8sObjects := []runtime.Object{endpoint, pod1, fakeClientPod, &ns}
+			fakeClient := fake.NewClientBuilder().WithRuntimeObjects(k8sObjects...).Build()
+
+			// Create test Consul server.
+			consul, err := testutil.NewTestServerConfigT(t, func(c *testutil.TestServerConfig) { c.NodeName = nodeName })
+			require.NoError(t, err)
+			defer consul.Stop()
+			consul.WaitForServiceIntentions(t)
+			cfg := &api.Config{Address: consul.HTTPAddr}
+			consulClient, err := api.NewClient(cfg)
+			require.NoError(t, err)
+			addr := strings.Split(consul.HTTPAddr, ":")
+			consulPort := addr[1]
+
+			// Register the service instance of the evicted pod.
+			err = consulClient.Agent().ServiceRegister(&api.AgentServiceRegistration{
+				ID:      "pod2-" + serviceName,
+				Name:    serviceName,
+				Port:    0,
+				Address: "2.2.2.2",
+				Meta: map[string]string{
+					"k8s-namespace":    namespace,
+					"k8s-service-name": serviceName,
+					"managed-by":       "consul-k8s-endpoints-controller",
+					"pod-name":         "pod2",
+				},
+			})
+			require.NoError(t, err)
+
+			// Create the endpoints controller.
+			ep := &EndpointsController{
+				Client:                fakeClient,
+				Log:                   logrtest.TestLogger{T: t},
+				ConsulClient:          consulClient,
+				ConsulPort:            consulPort,
+				ConsulScheme:          "http",
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSetWith(),
+				ReleaseName:           "consul",
+				ReleaseNamespace:      namespace,
+				ConsulClientCfg:       cfg,
+			}
+
+			namespacedName := types.NamespacedName{Namespace: namespace, Name: serviceName}
+			resp, err := ep.Reconcile(context.Background(), ctrl.Request{NamespacedName: namespacedName})
+			require.NoError(t, err)
+			require.False(t, resp.Requeue)
+
+			// Check that pod1 has been registered and pod2 is only deregistered outside of maintenance mode.
+			serviceInstances, _, err := consulClient.Catalog().Service(serviceName, "", nil)
+			require.NoError(t, err)
+			require.Len(t, serviceInstances, tt.expectedNumSvcInstances)
+
+			// A not ready pod whose instance is kept is registered as critical.
+			if tt.pod1NotReady && tt.expectedNumSvcInstances > 0 {
+				checks, err := consulClient.Agent().ChecksWithFilter(fmt.Sprintf("ServiceID == %q", "pod1-"+serviceName))
+				require.NoError(t, err)
+				require.Len(t, checks, 1)
+				for _, check := range checks {
+					require.Equal(t, api.HealthCritical, check.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestMaintenanceModeEnded(t *testing.T) {
+	t.Parallel()
+	inMaintenance := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: map[string]string{labelMaintenanceMode: "true"}}}
+	notInMaintenance := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+
+	p := maintenanceModeEnded()
+	require.True(t, p.Update(event.UpdateEvent{ObjectOld: inMaintenance, ObjectNew: notInMaintenance}))
+	require.False(t, p.Update(event.UpdateEvent{ObjectOld: notInMaintenance, ObjectNew: inMaintenance}))
+	require.False(t, p.Update(event.UpdateEvent{ObjectOld: inMaintenance, ObjectNew: inMaintenance}))
+	require.False(t, p.Update(event.UpdateEvent{ObjectOld: notInMaintenance, ObjectNew: notInMaintenance}))
+	require.False(t, p.Create(event.CreateEvent{Object: inMaintenance}))
+	require.False(t, p.Delete(event.DeleteEvent{Object: inMaintenance}))
+}
+
+func TestRequestsForNamespace(t *testing.T) {
+	t.Parallel()
+	endpoints := []runtime.Object{
+		&corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}},
+		&corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}},
+		&corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "other"}},
+	}
+
+	cases := map[string]struct {
+		namespace        string
+		denyNamespaces   mapset.Set
+		expectedRequests []ctrl.Request
+	}{
+		"Enqueues endpoints in the namespace": {
+			namespace:      "default",
+			denyNamespaces: mapset.NewSetWith(),
+			expectedRequests: []ctrl.Request{
+				{NamespacedName: types.NamespacedName{Name: "api", Namespace: "default"}},
+				{NamespacedName: types.NamespacedName{Name: "web", Namespace: "default"}},
+			},
+		},
+		"Ignores denied namespaces": {
+			namespace:        "default",
+			denyNamespaces:   mapset.NewSetWith("default"),
+			expectedRequests: []ctrl.Request{},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			ep := &EndpointsController{
+				Client:                fake.NewClientBuilder().WithRuntimeObjects(endpoints...).Build(),
+				Log:                   logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  tt.denyNamespaces,
+				Context:               context.Background(),
+			}
+			requests := ep.requestsForNamespace(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: tt.namespace}})
+			require.ElementsMatch(t, tt.expectedRequests, requests)
+		})
+	}
+}
+
 // Test that when an endpoints pod specifies the name for the Kubernetes service it wants to use
 // for registration, all other endpoints for that pod are skipped.
 func TestReconcile_podSpecifiesExplicitService(t *testing.T) {
@@ -5593,6 +5795,348 @@ func TestCreateServiceRegistrations_withTransparentProxy(t *testing.T) {
 	}
 }
 
+func TestCreateServiceRegistrations_servicePorts(t *testing.T) {
+	t.Parallel()
+
+	const serviceName = "test-service"
+
+	containers := []corev1.Container{
+		{
+			Name: "test",
+			Ports: []corev1.ContainerPort{
+				{
+					Name:          "admin",
+					ContainerPort: 9090,
+				},
+				{
+					Name:          "http",
+					ContainerPort: 8080,
+					HostPort:      30080,
+				},
+			},
+		},
+	}
+
+	cases := map[string]struct {
+		// explicitPort is the port annotation set by the user. If empty, the annotation
+		// is defaulted the same way the handler does it.
+		explicitPort       string
+		endpointPorts      []corev1.EndpointPort
+		servicePorts       []corev1.ServicePort
+		expPort            int
+		expMeta            map[string]string
+		expTaggedAddresses map[string]api.ServiceAddress
+	}{
+		"defaulted port annotation without endpoint ports": {
+			expPort: 9090,
+			expMeta: map[string]string{},
+			servicePorts: []corev1.ServicePort{
+				{Port: 9090},
+			},
+			expTaggedAddresses: map[string]api.ServiceAddress{
+				"virtual": {Address: "10.0.0.1", Port: 9090},
+			},
+		},
+		"defaulted port annotation resolves the service's named target port": {
+			endpointPorts: []corev1.EndpointPort{
+				{Name: "web", Port: 8080},
+			},
+			servicePorts: []corev1.ServicePort{
+				{Name: "web", Port: 80, TargetPort: intstr.FromString("http")},
+			},
+			expPort: 8080,
+			expMeta: map[string]string{
+				MetaKeyKubeServicePortName: "web",
+				MetaKeyHostPort:            "30080",
+			},
+			expTaggedAddresses: map[string]api.ServiceAddress{
+				"virtual": {Address: "10.0.0.1", Port: 80},
+			},
+		},
+		"defaulted port annotation is kept when the service targets it": {
+			endpointPorts: []corev1.EndpointPort{
+				{Name: "web", Port: 8080},
+				{Name: "metrics", Port: 9090},
+			},
+			servicePorts: []corev1.ServicePort{
+				{Name: "web", Port: 80, TargetPort: intstr.FromString("http")},
+				{Name: "metrics", Port: 90, TargetPort: intstr.FromInt(9090)},
+			},
+			expPort: 9090,
+			expMeta: map[string]string{
+				MetaKeyKubeServicePortName: "metrics",
+			},
+			expTaggedAddresses: map[string]api.ServiceAddress{
+				"virtual": {Address: "10.0.0.1", Port: 90},
+			},
+		},
+		"service ports targeting the same pod port are matched by name": {
+			explicitPort: "http",
+			endpointPorts: []corev1.EndpointPort{
+				{Name: "https", Port: 8080},
+				{Name: "web", Port: 8080},
+			},
+			servicePorts: []corev1.ServicePort{
+				{Name: "https", Port: 443, TargetPort: intstr.FromInt(8080)},
+				{Name: "web", Port: 80, TargetPort: intstr.FromInt(8080)},
+			},
+			expPort: 8080,
+			expMeta: map[string]string{
+				MetaKeyKubeServicePortName: "https",
+				MetaKeyHostPort:            "30080",
+			},
+			expTaggedAddresses: map[string]api.ServiceAddress{
+				"virtual": {Address: "10.0.0.1", Port: 443},
+			},
+		},
+		"explicit port annotation is not overridden": {
+			explicitPort: "admin",
+			endpointPorts: []corev1.EndpointPort{
+				{Name: "web", Port: 8080},
+			},
+			servicePorts: []corev1.ServicePort{
+				{Name: "web", Port: 80, TargetPort: intstr.FromString("http")},
+			},
+			expPort: 9090,
+			expMeta: map[string]string{},
+			expTaggedAddresses: map[string]api.ServiceAddress{
+				"virtual": {Address: "10.0.0.1", Port: 0},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("test-pod-1", "1.2.3.4", true, true)
+			pod.Spec.Containers = containers
+
+			if c.explicitPort != "" {
+				pod.Annotations[annotationPort] = c.explicitPort
+			}
+			podJson, err := json.Marshal(pod)
+			require.NoError(t, err)
+			h := Handler{}
+			require.NoError(t, h.defaultAnnotations(pod, string(podJson)))
+
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      serviceName,
+					Namespace: "default",
+				},
+				Subsets: []corev1.EndpointSubset{
+					{
+						Addresses: []corev1.EndpointAddress{
+							{
+								IP: "1.2.3.4",
+								TargetRef: &corev1.ObjectReference{
+									Kind:      "Pod",
+									Name:      pod.Name,
+									Namespace: pod.Namespace,
+								},
+							},
+						},
+						Ports: c.endpointPorts,
+					},
+				},
+			}
+			service := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      serviceName,
+					Namespace: "default",
+				},
+				Spec: corev1.ServiceSpec{
+					ClusterIP: "10.0.0.1",
+					Ports:     c.servicePorts,
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: pod.Namespace}}
+			fakeClient := fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, service, &ns).Build()
+
+			epCtrl := EndpointsController{
+				Client:                 fakeClient,
+				EnableTransparentProxy: true,
+				Log:                    logrtest.TestLogger{T: t},
+			}
+
+			serviceRegistration, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(*pod, *endpoints)
+			require.NoError(t, err)
+
+			require.Equal(t, c.expPort, serviceRegistration.Port)
+			require.Equal(t, c.expPort, proxyServiceRegistration.Proxy.LocalServicePort)
+			for _, k := range []string{MetaKeyKubeServicePortName, MetaKeyHostPort} {
+				require.Equal(t, c.expMeta[k], serviceRegistration.Meta[k], k)
+			}
+			require.Equal(t, c.expTaggedAddresses, serviceRegistration.TaggedAddresses)
+		})
+	}
+}
+
+// Test that the proxies of pods forward their metrics to the telemetry collector
+// only if it is enabled and their namespace is allowed.
+func TestCreateServiceRegistrations_telemetryCollector(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		enabled   bool
+		namespace string
+		allow     mapset.Set
+		deny      mapset.Set
+		expConfig bool
+	}{
+		"disabled": {
+			namespace: "default",
+			allow:     mapset.NewSetWith("*"),
+			deny:      mapset.NewSet(),
+		},
+		"enabled in all namespaces": {
+			enabled:   true,
+			namespace: "default",
+			allow:     mapset.NewSetWith("*"),
+			deny:      mapset.NewSet(),
+			expConfig: true,
+		},
+		"enabled in an allowed namespace": {
+			enabled:   true,
+			namespace: "web",
+			allow:     mapset.NewSetWith("web"),
+			deny:      mapset.NewSet(),
+			expConfig: true,
+		},
+		"enabled but the namespace is not allowed": {
+			enabled:   true,
+			namespace: "default",
+			allow:     mapset.NewSetWith("web"),
+			deny:      mapset.NewSet(),
+		},
+		"enabled but the namespace is denied": {
+			enabled:   true,
+			namespace: "default",
+			allow:     mapset.NewSetWith("*"),
+			deny:      mapset.NewSetWith("default"),
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("test-pod-1", "1.2.3.4", true, true)
+			pod.Namespace = c.namespace
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-service",
+					Namespace: c.namespace,
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: c.namespace}}
+			epCtrl := EndpointsController{
+				Client:                                  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				EnableTelemetryCollector:                c.enabled,
+				TelemetryCollectorAllowK8sNamespacesSet: c.allow,
+				TelemetryCollectorDenyK8sNamespacesSet:  c.deny,
+				Log:                                     logrtest.TestLogger{T: t},
+			}
+
+			_, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(*pod, *endpoints)
+			require.NoError(t, err)
+			if c.expConfig {
+				require.Equal(t, "/consul/connect-inject", proxyServiceRegistration.Proxy.Config[envoyTelemetryCollectorBindSocketDir])
+			} else {
+				require.NotContains(t, proxyServiceRegistration.Proxy.Config, envoyTelemetryCollectorBindSocketDir)
+			}
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_clusterID(t *testing.T) {
+	t.Parallel()
+
+	pod := createPod("pod1", "1.2.3.4", true, true)
+	// Pods can't claim to be registered by another cluster.
+	pod.Annotations[annotationMeta+MetaKeyClusterID] = "cluster-b"
+	endpoints := corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "service-created", Namespace: "default"}}
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	epCtrl := EndpointsController{
+		Client:    fake.NewClientBuilder().WithRuntimeObjects(pod, &ns).Build(),
+		Log:       logrtest.TestLogger{T: t},
+		ClusterID: "cluster-a",
+	}
+
+	service, proxyService, err := epCtrl.createServiceRegistrations(*pod, endpoints)
+	require.NoError(t, err)
+	require.Equal(t, "cluster-a", service.Meta[MetaKeyClusterID])
+	require.Equal(t, "cluster-a", proxyService.Meta[MetaKeyClusterID])
+}
+
+func TestCreateServiceRegistrations_templates(t *testing.T) {
+	t.Parallel()
+
+	pod := createPod("pod1", "1.2.3.4", true, true)
+	pod.Labels["version"] = "v2"
+	pod.Spec.NodeName = "node-1"
+	pod.Annotations[annotationTags] = "version={{ .Labels.version }},$POD_NAME"
+	pod.Annotations[annotationConnectTags] = "node={{ .NodeName }}"
+	pod.Annotations[annotationMeta+"version"] = "{{ .Labels.version }}"
+	pod.Annotations[annotationMeta+"pod"] = "$POD_NAME"
+	endpoints := corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "service-created", Namespace: "default"}}
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	epCtrl := EndpointsController{
+		Client: fake.NewClientBuilder().WithRuntimeObjects(pod, &ns).Build(),
+		Log:    logrtest.TestLogger{T: t},
+	}
+
+	service, proxyService, err := epCtrl.createServiceRegistrations(*pod, endpoints)
+	require.NoError(t, err)
+	require.Equal(t, []string{"version=v2", "pod1", "node=node-1"}, service.Tags)
+	require.Equal(t, service.Tags, proxyService.Tags)
+	require.Equal(t, "v2", service.Meta["version"])
+	require.Equal(t, "pod1", service.Meta["pod"])
+	require.Equal(t, "v2", proxyService.Meta["version"])
+
+	pod.Annotations[annotationMeta+"version"] = "{{ .Labels.version }"
+	_, _, err = epCtrl.createServiceRegistrations(*pod, endpoints)
+	require.Error(t, err)
+}
+
+func TestOwnsServiceInstance(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		clusterID string
+		meta      map[string]string
+		expOwns   bool
+	}{
+		"no cluster IDs": {
+			meta:    map[string]string{MetaKeyManagedBy: managedByValue},
+			expOwns: true,
+		},
+		"registered before the cluster ID was set": {
+			clusterID: "cluster-a",
+			meta:      map[string]string{MetaKeyManagedBy: managedByValue},
+			expOwns:   true,
+		},
+		"registered by this cluster": {
+			clusterID: "cluster-a",
+			meta:      map[string]string{MetaKeyManagedBy: managedByValue, MetaKeyClusterID: "cluster-a"},
+			expOwns:   true,
+		},
+		"registered by another cluster": {
+			clusterID: "cluster-a",
+			meta:      map[string]string{MetaKeyManagedBy: managedByValue, MetaKeyClusterID: "cluster-b"},
+			expOwns:   false,
+		},
+		"registered by a cluster with an ID": {
+			meta:    map[string]string{MetaKeyManagedBy: managedByValue, MetaKeyClusterID: "cluster-b"},
+			expOwns: false,
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			epCtrl := EndpointsController{Log: logrtest.TestLogger{T: t}, ClusterID: c.clusterID}
+			require.Equal(t, c.expOwns, epCtrl.ownsServiceInstance(&api.AgentService{ID: "web-1", Meta: c.meta}))
+		})
+	}
+}
+
 func TestGetTokenMetaFromDescription(t *testing.T) {
 	t.Parallel()
 	cases := map[string]struct {
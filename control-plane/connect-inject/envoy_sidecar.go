@@ -1,6 +1,7 @@
 package connectinject
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
@@ -48,37 +49,69 @@ func (h *Handler) envoySidecar(namespace corev1.Namespace, pod corev1.Pod, mpi m
 		Command: cmd,
 	}
 
+	holdApplication, err := holdApplicationUntilProxyStarts(pod, h.HoldApplicationUntilProxyStarts)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+	if holdApplication {
+		// The kubelet only starts the next container once the post-start hook returns,
+		// i.e. once Envoy is ready. The init container copies the binary to the shared volume.
+		container.Lifecycle = &corev1.Lifecycle{
+			PostStart: &corev1.Handler{
+				Exec: &corev1.ExecAction{
+					Command: []string{
+						"/consul/connect-inject/consul-k8s-control-plane",
+						"wait-for-proxy",
+						fmt.Sprintf("-admin-addr=127.0.0.1:%d", 19000+mpi.serviceIndex),
+					},
+				},
+			},
+		}
+	}
+
 	tproxyEnabled, err := transparentProxyEnabled(namespace, pod, h.EnableTransparentProxy)
 	if err != nil {
 		return corev1.Container{}, err
 	}
 
-	// If not running in transparent proxy mode and in an OpenShift environment,
-	// skip setting the security context and let OpenShift set it for us.
-	// When transparent proxy is enabled, then Envoy needs to run as our specific user
-	// so that traffic redirection will work.
-	if tproxyEnabled || !h.EnableOpenShift {
-		if pod.Spec.SecurityContext != nil {
-			// User container and Envoy container cannot have the same UID.
-			if pod.Spec.SecurityContext.RunAsUser != nil && *pod.Spec.SecurityContext.RunAsUser == envoyUserAndGroupID {
-				return corev1.Container{}, fmt.Errorf("pod security context cannot have the same uid as envoy: %v", envoyUserAndGroupID)
+	// On OpenShift, Envoy runs under the restricted-v2 SecurityContextConstraints. If not running in
+	// transparent proxy mode, the user is left to OpenShift. When transparent proxy is enabled, Envoy
+	// needs to run as a known user so that the traffic redirection rules can exclude its traffic, so
+	// it runs as a user from the range of the namespace.
+	if h.EnableOpenShift {
+		container.SecurityContext = restrictedSecurityContext()
+		container.SecurityContext.ReadOnlyRootFilesystem = pointerToBool(true)
+		if tproxyEnabled {
+			uid, gid, err := h.envoyUserAndGroup(namespace, pod)
+			if err != nil {
+				return corev1.Container{}, err
 			}
+			container.SecurityContext.RunAsUser = pointerToInt64(uid)
+			container.SecurityContext.RunAsGroup = pointerToInt64(gid)
 		}
-		// Ensure that none of the user's containers have the same UID as Envoy. At this point in injection the handler
-		// has only injected init containers so all containers defined in pod.Spec.Containers are from the user.
-		for _, c := range pod.Spec.Containers {
-			// User container and Envoy container cannot have the same UID.
-			if c.SecurityContext != nil && c.SecurityContext.RunAsUser != nil && *c.SecurityContext.RunAsUser == envoyUserAndGroupID && c.Image != h.ImageEnvoy {
-				return corev1.Container{}, fmt.Errorf("container %q has runAsUser set to the same uid %q as envoy which is not allowed", c.Name, envoyUserAndGroupID)
-			}
+		return container, nil
+	}
+
+	if pod.Spec.SecurityContext != nil {
+		// User container and Envoy container cannot have the same UID.
+		if pod.Spec.SecurityContext.RunAsUser != nil && *pod.Spec.SecurityContext.RunAsUser == envoyUserAndGroupID {
+			return corev1.Container{}, fmt.Errorf("pod security context cannot have the same uid as envoy: %v", envoyUserAndGroupID)
 		}
-		container.SecurityContext = &corev1.SecurityContext{
-			RunAsUser:              pointerToInt64(envoyUserAndGroupID),
-			RunAsGroup:             pointerToInt64(envoyUserAndGroupID),
-			RunAsNonRoot:           pointerToBool(true),
-			ReadOnlyRootFilesystem: pointerToBool(true),
+	}
+	// Ensure that none of the user's containers have the same UID as Envoy. At this point in injection the handler
+	// has only injected init containers so all containers defined in pod.Spec.Containers are from the user.
+	for _, c := range pod.Spec.Containers {
+		// User container and Envoy container cannot have the same UID.
+		if c.SecurityContext != nil && c.SecurityContext.RunAsUser != nil && *c.SecurityContext.RunAsUser == envoyUserAndGroupID && c.Image != h.ImageEnvoy {
+			return corev1.Container{}, fmt.Errorf("container %q has runAsUser set to the same uid %q as envoy which is not allowed", c.Name, envoyUserAndGroupID)
 		}
 	}
+	container.SecurityContext = &corev1.SecurityContext{
+		RunAsUser:              pointerToInt64(envoyUserAndGroupID),
+		RunAsGroup:             pointerToInt64(envoyUserAndGroupID),
+		RunAsNonRoot:           pointerToBool(true),
+		ReadOnlyRootFilesystem: pointerToBool(true),
+	}
 
 	return container, nil
 }
@@ -96,6 +129,12 @@ func (h *Handler) getContainerSidecarCommand(pod corev1.Pod, multiPortSvcName st
 		cmd = append(cmd, "--base-id", fmt.Sprintf("%d", multiPortSvcIdx))
 	}
 
+	tuningArgs, err := envoyTuningArgs(pod)
+	if err != nil {
+		return []string{}, err
+	}
+	cmd = append(cmd, tuningArgs...)
+
 	extraArgs, annotationSet := pod.Annotations[annotationEnvoyExtraArgs]
 
 	if annotationSet || h.EnvoyExtraArgs != "" {
@@ -123,6 +162,118 @@ func (h *Handler) getContainerSidecarCommand(pod corev1.Pod, multiPortSvcName st
 	return cmd, nil
 }
 
+// envoyTuningArgs returns the Envoy arguments for the concurrency, overload manager and
+// bootstrap config annotations of the pod. The overload manager and the extra bootstrap
+// config are merged into a single --config-yaml argument.
+func envoyTuningArgs(pod corev1.Pod) ([]string, error) {
+	var args []string
+	if raw, ok := pod.Annotations[annotationEnvoyConcurrency]; ok {
+		concurrency, err := strconv.Atoi(raw)
+		if err != nil || concurrency < 0 {
+			return nil, fmt.Errorf("annotation %s:%q must be a non-negative integer", annotationEnvoyConcurrency, raw)
+		}
+		args = append(args, "--concurrency", strconv.Itoa(concurrency))
+	}
+
+	config := make(map[string]interface{})
+	if raw, ok := pod.Annotations[annotationEnvoyBootstrapExtraConfig]; ok {
+		var err error
+		if config, err = parseEnvoyBootstrapConfig(raw); err != nil {
+			return nil, fmt.Errorf("parsing annotation %s: %s", annotationEnvoyBootstrapExtraConfig, err)
+		}
+	}
+
+	overloadManager, err := envoyOverloadManager(pod)
+	if err != nil {
+		return nil, err
+	}
+	if overloadManager != nil {
+		if _, ok := config["overload_manager"]; ok {
+			return nil, fmt.Errorf("annotation %s cannot set overload_manager together with annotation %s",
+				annotationEnvoyBootstrapExtraConfig, annotationEnvoyOverloadMaxHeapSize)
+		}
+		config["overload_manager"] = overloadManager
+	}
+
+	if len(config) > 0 {
+		configJSON, err := json.Marshal(config)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--config-yaml", string(configJSON))
+	}
+	return args, nil
+}
+
+// envoyOverloadManager returns the overload manager config for the overload annotations
+// of the pod, or nil if the max heap size isn't set.
+func envoyOverloadManager(pod corev1.Pod) (map[string]interface{}, error) {
+	raw, ok := pod.Annotations[annotationEnvoyOverloadMaxHeapSize]
+	if !ok {
+		for _, anno := range []string{annotationEnvoyOverloadShrinkHeapThreshold, annotationEnvoyOverloadStopAcceptingRequestsThreshold} {
+			if _, ok := pod.Annotations[anno]; ok {
+				return nil, fmt.Errorf("annotation %s requires annotation %s", anno, annotationEnvoyOverloadMaxHeapSize)
+			}
+		}
+		return nil, nil
+	}
+	maxHeapSize, err := resource.ParseQuantity(raw)
+	if err != nil || maxHeapSize.Value() <= 0 {
+		return nil, fmt.Errorf("annotation %s:%q must be a positive quantity", annotationEnvoyOverloadMaxHeapSize, raw)
+	}
+
+	shrinkHeap, err := overloadThreshold(pod, annotationEnvoyOverloadShrinkHeapThreshold, 0.95)
+	if err != nil {
+		return nil, err
+	}
+	stopAcceptingRequests, err := overloadThreshold(pod, annotationEnvoyOverloadStopAcceptingRequestsThreshold, 0.98)
+	if err != nil {
+		return nil, err
+	}
+
+	action := func(name string, threshold float64) map[string]interface{} {
+		return map[string]interface{}{
+			"name": name,
+			"triggers": []interface{}{
+				map[string]interface{}{
+					"name":      "envoy.resource_monitors.fixed_heap",
+					"threshold": map[string]interface{}{"value": threshold},
+				},
+			},
+		}
+	}
+	return map[string]interface{}{
+		"refresh_interval": "0.25s",
+		"resource_monitors": []interface{}{
+			map[string]interface{}{
+				"name": "envoy.resource_monitors.fixed_heap",
+				"typed_config": map[string]interface{}{
+					"@type":               "type.googleapis.com/envoy.extensions.resource_monitors.fixed_heap.v3.FixedHeapConfig",
+					"max_heap_size_bytes": maxHeapSize.Value(),
+				},
+			},
+		},
+		"actions": []interface{}{
+			action("envoy.overload_actions.shrink_heap", shrinkHeap),
+			action("envoy.overload_actions.stop_accepting_requests", stopAcceptingRequests),
+		},
+	}, nil
+}
+
+// overloadThreshold returns the overload manager threshold set by the annotation, or the
+// default if it isn't set. It must be greater than 0 and at most 1.
+func overloadThreshold(pod corev1.Pod, annotation string, defaultThreshold float64) (float64, error) {
+	raw, ok := pod.Annotations[annotation]
+	if !ok {
+		return defaultThreshold, nil
+	}
+	threshold, err := strconv.ParseFloat(raw, 64)
+	if err != nil || threshold <= 0 || threshold > 1 {
+		return 0, fmt.Errorf("annotation %s:%q must be a number greater than 0 and at most 1", annotation, raw)
+	}
+	return threshold, nil
+}
+
 func (h *Handler) envoySidecarResources(pod corev1.Pod) (corev1.ResourceRequirements, error) {
 	resources := corev1.ResourceRequirements{
 		Limits:   corev1.ResourceList{},
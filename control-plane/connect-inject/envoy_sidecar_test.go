@@ -92,6 +92,76 @@ func TestHandlerEnvoySidecar_Multiport(t *testing.T) {
 	}
 }
 
+func TestHandlerEnvoySidecar_HoldApplicationUntilProxyStarts(t *testing.T) {
+	cases := map[string]struct {
+		globalHold   bool
+		annotation   string
+		mpi          multiPortInfo
+		expLifecycle *corev1.Lifecycle
+		expErr       string
+	}{
+		"disabled by default": {},
+		"enabled globally": {
+			globalHold: true,
+			expLifecycle: &corev1.Lifecycle{
+				PostStart: &corev1.Handler{
+					Exec: &corev1.ExecAction{
+						Command: []string{"/consul/connect-inject/consul-k8s-control-plane", "wait-for-proxy", "-admin-addr=127.0.0.1:19000"},
+					},
+				},
+			},
+		},
+		"enabled by annotation for second service of multi port pod": {
+			annotation: "true",
+			mpi:        multiPortInfo{serviceIndex: 1, serviceName: "web-admin"},
+			expLifecycle: &corev1.Lifecycle{
+				PostStart: &corev1.Handler{
+					Exec: &corev1.ExecAction{
+						Command: []string{"/consul/connect-inject/consul-k8s-control-plane", "wait-for-proxy", "-admin-addr=127.0.0.1:19001"},
+					},
+				},
+			},
+		},
+		"disabled by annotation": {
+			globalHold: true,
+			annotation: "false",
+		},
+		"invalid annotation": {
+			annotation: "not-a-bool",
+			expErr:     `strconv.ParseBool: parsing "not-a-bool": invalid syntax`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := Handler{HoldApplicationUntilProxyStarts: c.globalHold}
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotationService: "foo",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "web",
+						},
+					},
+				},
+			}
+			if c.annotation != "" {
+				pod.Annotations[annotationHoldApplicationUntilProxyStarts] = c.annotation
+			}
+			container, err := h.envoySidecar(testNS, pod, c.mpi)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expLifecycle, container.Lifecycle)
+		})
+	}
+}
+
 func TestHandlerEnvoySidecar_withSecurityContext(t *testing.T) {
 	cases := map[string]struct {
 		tproxyEnabled      bool
@@ -119,18 +189,27 @@ func TestHandlerEnvoySidecar_withSecurityContext(t *testing.T) {
 			},
 		},
 		"tproxy disabled; openshift enabled": {
-			tproxyEnabled:      false,
-			openShiftEnabled:   true,
-			expSecurityContext: nil,
+			tproxyEnabled:    false,
+			openShiftEnabled: true,
+			expSecurityContext: &corev1.SecurityContext{
+				RunAsNonRoot:             pointerToBool(true),
+				ReadOnlyRootFilesystem:   pointerToBool(true),
+				AllowPrivilegeEscalation: pointerToBool(false),
+				Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+				SeccompProfile:           &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			},
 		},
 		"tproxy enabled; openshift enabled": {
 			tproxyEnabled:    true,
 			openShiftEnabled: true,
 			expSecurityContext: &corev1.SecurityContext{
-				RunAsUser:              pointerToInt64(envoyUserAndGroupID),
-				RunAsGroup:             pointerToInt64(envoyUserAndGroupID),
-				RunAsNonRoot:           pointerToBool(true),
-				ReadOnlyRootFilesystem: pointerToBool(true),
+				RunAsUser:                pointerToInt64(1000629999),
+				RunAsGroup:               pointerToInt64(1000620000),
+				RunAsNonRoot:             pointerToBool(true),
+				ReadOnlyRootFilesystem:   pointerToBool(true),
+				AllowPrivilegeEscalation: pointerToBool(false),
+				Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+				SeccompProfile:           &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
 			},
 		},
 	}
@@ -155,7 +234,7 @@ func TestHandlerEnvoySidecar_withSecurityContext(t *testing.T) {
 					},
 				},
 			}
-			ec, err := h.envoySidecar(testNS, pod, multiPortInfo{})
+			ec, err := h.envoySidecar(openShiftTestNS, pod, multiPortInfo{})
 			require.NoError(t, err)
 			require.Equal(t, c.expSecurityContext, ec.SecurityContext)
 		})
@@ -354,6 +433,112 @@ func TestHandlerEnvoySidecar_EnvoyExtraArgs(t *testing.T) {
 	}
 }
 
+func TestHandlerEnvoySidecar_TuningAnnotations(t *testing.T) {
+	overloadManager := func(maxHeapSize string, shrinkHeap, stopAcceptingRequests string) string {
+		return `"overload_manager":{"actions":[` +
+			`{"name":"envoy.overload_actions.shrink_heap","triggers":[{"name":"envoy.resource_monitors.fixed_heap","threshold":{"value":` + shrinkHeap + `}}]},` +
+			`{"name":"envoy.overload_actions.stop_accepting_requests","triggers":[{"name":"envoy.resource_monitors.fixed_heap","threshold":{"value":` + stopAcceptingRequests + `}}]}],` +
+			`"refresh_interval":"0.25s","resource_monitors":[{"name":"envoy.resource_monitors.fixed_heap","typed_config":{` +
+			`"@type":"type.googleapis.com/envoy.extensions.resource_monitors.fixed_heap.v3.FixedHeapConfig","max_heap_size_bytes":` + maxHeapSize + `}}]}`
+	}
+
+	cases := map[string]struct {
+		annotations map[string]string
+		expArgs     []string
+		expErr      string
+	}{
+		"concurrency": {
+			annotations: map[string]string{annotationEnvoyConcurrency: "4"},
+			expArgs:     []string{"--concurrency", "4"},
+		},
+		"invalid concurrency": {
+			annotations: map[string]string{annotationEnvoyConcurrency: "-1"},
+			expErr:      `annotation consul.hashicorp.com/envoy-concurrency:"-1" must be a non-negative integer`,
+		},
+		"overload manager with default thresholds": {
+			annotations: map[string]string{annotationEnvoyOverloadMaxHeapSize: "512Mi"},
+			expArgs:     []string{"--config-yaml", "{" + overloadManager("536870912", "0.95", "0.98") + "}"},
+		},
+		"overload manager with thresholds": {
+			annotations: map[string]string{
+				annotationEnvoyOverloadMaxHeapSize:                    "1Gi",
+				annotationEnvoyOverloadShrinkHeapThreshold:            "0.8",
+				annotationEnvoyOverloadStopAcceptingRequestsThreshold: "0.9",
+			},
+			expArgs: []string{"--config-yaml", "{" + overloadManager("1073741824", "0.8", "0.9") + "}"},
+		},
+		"invalid max heap size": {
+			annotations: map[string]string{annotationEnvoyOverloadMaxHeapSize: "lots"},
+			expErr:      `annotation consul.hashicorp.com/envoy-overload-max-heap-size:"lots" must be a positive quantity`,
+		},
+		"invalid threshold": {
+			annotations: map[string]string{
+				annotationEnvoyOverloadMaxHeapSize:         "1Gi",
+				annotationEnvoyOverloadShrinkHeapThreshold: "1.5",
+			},
+			expErr: `annotation consul.hashicorp.com/envoy-overload-shrink-heap-threshold:"1.5" must be a number greater than 0 and at most 1`,
+		},
+		"threshold without max heap size": {
+			annotations: map[string]string{annotationEnvoyOverloadStopAcceptingRequestsThreshold: "0.9"},
+			expErr:      "annotation consul.hashicorp.com/envoy-overload-stop-accepting-requests-threshold requires annotation consul.hashicorp.com/envoy-overload-max-heap-size",
+		},
+		"bootstrap extra config": {
+			annotations: map[string]string{
+				annotationEnvoyBootstrapExtraConfig: `
+stats_sinks:
+- name: envoy.stat_sinks.statsd
+  typed_config:
+    "@type": type.googleapis.com/envoy.config.metrics.v3.StatsdSink
+    address:
+      socket_address: {address: 127.0.0.1, port_value: 8125}
+`,
+			},
+			expArgs: []string{"--config-yaml", `{"stats_sinks":[{"name":"envoy.stat_sinks.statsd","typed_config":{"@type":"type.googleapis.com/envoy.config.metrics.v3.StatsdSink","address":{"socket_address":{"address":"127.0.0.1","port_value":8125}}}}]}`},
+		},
+		"bootstrap extra config merged with overload manager": {
+			annotations: map[string]string{
+				annotationEnvoyConcurrency:          "2",
+				annotationEnvoyOverloadMaxHeapSize:  "512Mi",
+				annotationEnvoyBootstrapExtraConfig: `{"stats_flush_interval": "10s"}`,
+			},
+			expArgs: []string{"--concurrency", "2", "--config-yaml", "{" + overloadManager("536870912", "0.95", "0.98") + `,"stats_flush_interval":"10s"}`},
+		},
+		"invalid bootstrap extra config": {
+			annotations: map[string]string{annotationEnvoyBootstrapExtraConfig: "- not an object"},
+			expErr:      "parsing annotation consul.hashicorp.com/envoy-bootstrap-extra-config: must be a YAML or JSON object",
+		},
+		"bootstrap extra config with overload manager conflicts with max heap size": {
+			annotations: map[string]string{
+				annotationEnvoyOverloadMaxHeapSize:  "512Mi",
+				annotationEnvoyBootstrapExtraConfig: `{"overload_manager": {}}`,
+			},
+			expErr: "annotation consul.hashicorp.com/envoy-bootstrap-extra-config cannot set overload_manager together with annotation consul.hashicorp.com/envoy-overload-max-heap-size",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := Handler{
+				ImageConsul:    "hashicorp/consul:latest",
+				ImageEnvoy:     "hashicorp/consul-k8s:latest",
+				EnvoyExtraArgs: "--log-level debug",
+			}
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations}}
+
+			c, err := h.envoySidecar(testNS, pod, multiPortInfo{})
+			if tc.expErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expErr)
+				return
+			}
+			require.NoError(t, err)
+			expCommand := append([]string{"envoy", "--config-path", "/consul/connect-inject/envoy-bootstrap.yaml"}, tc.expArgs...)
+			expCommand = append(expCommand, "--log-level", "debug")
+			require.Equal(t, expCommand, c.Command)
+		})
+	}
+}
+
 func TestHandlerEnvoySidecar_Resources(t *testing.T) {
 	mem1 := resource.MustParse("100Mi")
 	mem2 := resource.MustParse("200Mi")
@@ -9,9 +9,11 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
 	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	"github.com/hashicorp/consul/api"
 	"gomodules.xyz/jsonpatch/v2"
@@ -58,6 +60,21 @@ type Handler struct {
 	// use for identity with connectInjection if ACLs are enabled.
 	AuthMethod string
 
+	// EnableProjectedServiceAccountToken makes the init container of single port pods log in
+	// with a projected service account token that is bound to the pod and expires, instead of
+	// the token of the service account mounted in the pod. Multi port pods log in with the
+	// tokens of the service accounts of their services, which can't be projected.
+	EnableProjectedServiceAccountToken bool
+
+	// ProjectedTokenAudience is the audience of the projected service account token. The
+	// Kubernetes auth method reviews tokens with the audiences of the API server, so it must
+	// be one of them. If empty, the token has the audience of the API server.
+	ProjectedTokenAudience string
+
+	// ProjectedTokenExpirationSeconds is the lifetime of the projected service account token.
+	// If zero, it defaults to one hour.
+	ProjectedTokenExpirationSeconds int64
+
 	// The PEM-encoded CA certificate string
 	// to use when communicating with Consul clients over HTTPS.
 	// If not set, will use HTTP.
@@ -102,6 +119,10 @@ type Handler struct {
 	// `k8s-default` namespace.
 	K8SNSMirroringPrefix string
 
+	// K8SNSMirroringRules rewrite the names of the k8s namespaces that are mirrored,
+	// e.g. to strip a "team-" prefix, before K8SNSMirroringPrefix is added.
+	K8SNSMirroringRules namespaces.MirroringRules
+
 	// CrossNamespaceACLPolicy is the name of the ACL policy to attach to
 	// any created Consul namespaces to allow cross namespace service discovery.
 	// Only necessary if ACLs are enabled.
@@ -114,6 +135,16 @@ type Handler struct {
 	DefaultProxyMemoryRequest resource.Quantity
 	DefaultProxyMemoryLimit   resource.Quantity
 
+	// NamespaceSidecarConfigMapName is the name of the ConfigMap that overrides the sidecar
+	// defaults for the pods in its namespace, with the settings of the sidecar annotations.
+	// Pod annotations take precedence over it. If empty, namespaces can't override the defaults.
+	NamespaceSidecarConfigMapName string
+
+	// EnableEnvoyBootstrapConfigMaps merges the Envoy bootstrap config fragments of the ConfigMap
+	// named by the annotationEnvoyBootstrapConfigMap annotation of a namespace into the bootstrap
+	// config of the sidecars of its pods.
+	EnableEnvoyBootstrapConfigMaps bool
+
 	// MetricsConfig contains metrics configuration from the inject-connect command and has methods to determine whether
 	// configuration should come from the default flags or annotations. The handler uses this to configure prometheus
 	// annotations and the merged metrics server.
@@ -127,6 +158,20 @@ type Handler struct {
 	// will be populated by the defaults provided in the initial flags.
 	DefaultConsulSidecarResources corev1.ResourceRequirements
 
+	// EnableXDSWatchdog runs the xDS watchdog in the consul sidecar, which acts on Envoy proxies
+	// that have been disconnected from xDS or not ready for longer than XDSWatchdogThreshold
+	// according to XDSWatchdogPolicy. All three can be overridden per pod with annotations.
+	EnableXDSWatchdog    bool
+	XDSWatchdogThreshold time.Duration
+	XDSWatchdogPolicy    string
+
+	// EnableJobCompletion stops the Envoy proxies of Job pods with the Never restart policy once
+	// their application containers have exited, so that the pods complete. It can be overridden
+	// per pod with an annotation. It shares the process namespace of the pod, so the processes of
+	// the application can see and signal those of the sidecars and read their files, including
+	// the ACL tokens, through /proc/<pid>/root.
+	EnableJobCompletion bool
+
 	// EnableTransparentProxy enables transparent proxy mode.
 	// This means that the injected init container will apply traffic redirection rules
 	// so that all traffic will go through the Envoy proxy.
@@ -136,6 +181,24 @@ type Handler struct {
 	// to point them to the Envoy proxy.
 	TProxyOverwriteProbes bool
 
+	// HoldApplicationUntilProxyStarts controls whether the application containers are
+	// started only once the Envoy proxy is ready. It can be overridden per pod with the
+	// hold-application-until-proxy-starts annotation.
+	HoldApplicationUntilProxyStarts bool
+
+	// TProxyInitMode selects how the traffic redirection rules are applied for pods in Transparent
+	// Proxy mode. It is one of the TProxyInitMode constants and can be overridden per pod with the
+	// transparent-proxy-init-mode annotation.
+	TProxyInitMode string
+
+	// EnableTProxyNodeHelper indicates that the transparent proxy node helper DaemonSet is deployed,
+	// which is required for the node-helper init mode.
+	EnableTProxyNodeHelper bool
+
+	// EnableNodeProxy enables the experimental node proxy mode, which pods opt into with the
+	// node-proxy annotation.
+	EnableNodeProxy bool
+
 	// EnableConsulDNS enables traffic redirection so that DNS requests are directed to Consul
 	// from mesh services.
 	EnableConsulDNS bool
@@ -144,11 +207,18 @@ type Handler struct {
 	// name of the Consul DNS service.
 	ResourcePrefix string
 
-	// EnableOpenShift indicates that when tproxy is enabled, the security context for the Envoy and init
-	// containers should not be added because OpenShift sets a random user for those and will not allow
-	// those containers to be created otherwise.
+	// EnableOpenShift indicates that the injected containers run under the restricted-v2
+	// SecurityContextConstraints of OpenShift. Their users are left to OpenShift, which assigns one
+	// from the range of the namespace, except for Envoy in transparent proxy mode, which runs as a
+	// user from that range since the traffic redirection rules need to know it.
 	EnableOpenShift bool
 
+	// ConsulHealth tracks whether the Consul servers are reachable. While they
+	// aren't, namespaces in NamespaceCache are assumed to exist so that pods
+	// are still injected. Both are optional.
+	ConsulHealth   *consul.HealthTracker
+	NamespaceCache *namespaces.Cache
+
 	// Log
 	Log logr.Logger
 	// Log settings for consul-sidecar
@@ -203,8 +273,30 @@ func (h *Handler) Handle(ctx context.Context, req admission.Request) admission.R
 		return admission.Allowed(fmt.Sprintf("%s %s does not require injection", pod.Kind, pod.Name))
 	}
 
+	// Reject malformed upstreams now since the endpoints controller can only
+	// skip them once the pod is running.
+	if _, err := parseUpstreams(pod, h.EnableNamespaces, h.ConsulPartition != ""); err != nil {
+		h.Log.Error(err, "error validating upstreams", "request name", req.Name)
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	// Likewise reject malformed tag and meta templates, which are only rendered once the pod
+	// is scheduled.
+	if err := validateServiceTemplates(pod); err != nil {
+		h.Log.Error(err, "error validating service tags and meta", "request name", req.Name)
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
 	h.Log.Info("received pod", "name", req.Name, "ns", req.Namespace)
 
+	nodeProxy, err := nodeProxyEnabled(pod, h.EnableNodeProxy)
+	if err != nil {
+		h.Log.Error(err, "error determining if node proxy mode is enabled", "request name", req.Name)
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if nodeProxy {
+		return h.handleNodeProxy(pod, origPodJson, req)
+	}
+
 	// Add our volume that will be shared by the init container and
 	// the sidecar for passing data in the pod.
 	pod.Spec.Volumes = append(pod.Spec.Volumes, h.containerVolume())
@@ -234,13 +326,41 @@ func (h *Handler) Handle(ctx context.Context, req admission.Request) admission.R
 		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error getting namespace metadata for container: %s", err))
 	}
 
+	// Apply the sidecar settings of the namespace that the pod doesn't override. This MUST be done
+	// before the containers are configured since they read the settings from the pod annotations.
+	namespaceSidecarConfig, err := h.namespaceSidecarConfig(ctx, req.Namespace)
+	if err != nil {
+		h.Log.Error(err, "error getting namespace sidecar config", "request name", req.Name)
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error getting namespace sidecar config: %s", err))
+	}
+	applyNamespaceSidecarConfig(&pod, namespaceSidecarConfig)
+
+	// Merge the Envoy bootstrap config fragments of the namespace into the extra bootstrap config
+	// of the pod, which may have been set by the namespace sidecar config above.
+	if err := h.applyEnvoyBootstrapConfigMap(ctx, *ns, &pod); err != nil {
+		h.Log.Error(err, "error applying namespace Envoy bootstrap config", "request name", req.Name)
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("error applying namespace Envoy bootstrap config: %s", err))
+	}
+
 	// Get service names from the annotation. If theres 0-1 service names, it's a single port pod, otherwise it's multi
 	// port.
 	annotatedSvcNames := h.annotatedServiceNames(pod)
 	multiPort := len(annotatedSvcNames) > 1
 
+	// Determine whether the application containers are held until the proxy starts, in which case
+	// the Envoy sidecars are placed before them.
+	holdApplication, err := holdApplicationUntilProxyStarts(pod, h.HoldApplicationUntilProxyStarts)
+	if err != nil {
+		h.Log.Error(err, "error determining if the application should be held until the proxy starts", "request name", req.Name)
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error determining if the application should be held until the proxy starts: %s", err))
+	}
+
 	// For single port pods, add the single init container and envoy sidecar.
 	if !multiPort {
+		if h.AuthMethod != "" && h.EnableProjectedServiceAccountToken {
+			pod.Spec.Volumes = append(pod.Spec.Volumes, h.projectedTokenVolume())
+		}
+
 		// Add the init container that registers the service and sets up the Envoy configuration.
 		initContainer, err := h.containerInit(*ns, pod, multiPortInfo{})
 		if err != nil {
@@ -255,7 +375,7 @@ func (h *Handler) Handle(ctx context.Context, req admission.Request) admission.R
 			h.Log.Error(err, "error configuring injection sidecar container", "request name", req.Name)
 			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error configuring injection sidecar container: %s", err))
 		}
-		pod.Spec.Containers = append(pod.Spec.Containers, envoySidecar)
+		pod.Spec.Containers = insertEnvoySidecar(pod.Spec.Containers, envoySidecar, 0, holdApplication)
 	} else {
 		// For multi port pods, check for unsupported cases, mount all relevant service account tokens, and mount an init
 		// container and envoy sidecar per port. Tproxy, metrics, and metrics merging are not supported for multi port pods.
@@ -316,22 +436,41 @@ func (h *Handler) Handle(ctx context.Context, req admission.Request) admission.R
 				h.Log.Error(err, "error configuring injection sidecar container", "request name", req.Name)
 				return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error configuring injection sidecar container: %s", err))
 			}
-			pod.Spec.Containers = append(pod.Spec.Containers, envoySidecar)
+			pod.Spec.Containers = insertEnvoySidecar(pod.Spec.Containers, envoySidecar, i, holdApplication)
 		}
 	}
 
 	// Now that the consul-sidecar no longer needs to re-register services periodically
 	// (that functionality lives in the endpoints-controller),
-	// we only need the consul sidecar to run the metrics merging server.
+	// we only need the consul sidecar to run the metrics merging server, the xDS watchdog
+	// and job completion.
 	// First, determine if we need to run the metrics merging server.
 	shouldRunMetricsMerging, err := h.MetricsConfig.shouldRunMergedMetricsServer(pod)
 	if err != nil {
 		h.Log.Error(err, "error determining if metrics merging server should be run", "request name", req.Name)
 		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error determining if metrics merging server should be run: %s", err))
 	}
+	watchdog, err := h.xdsWatchdogConfig(pod)
+	if err != nil {
+		h.Log.Error(err, "error determining xDS watchdog configuration", "request name", req.Name)
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error determining xDS watchdog configuration: %s", err))
+	}
+
+	// Job pods only complete once all of their containers have exited, so the consul sidecar stops the
+	// Envoy sidecars once the application containers have exited. It finds their processes in the
+	// process namespace shared by the containers of the pod, which is why job completion is opt-in:
+	// sharing it also lets the application see and signal the processes of the sidecars.
+	jobCompletion, err := jobCompletionEnabled(pod, h.EnableJobCompletion)
+	if err != nil {
+		h.Log.Error(err, "error determining if job completion is enabled", "request name", req.Name)
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("%s annotation value of %s was invalid: %s", annotationJobCompletion, pod.Annotations[annotationJobCompletion], err))
+	}
+	if jobCompletion {
+		pod.Spec.ShareProcessNamespace = pointerToBool(true)
+	}
 
-	// Add the consul-sidecar only if we need to run the metrics merging server.
-	if shouldRunMetricsMerging {
+	// Add the consul-sidecar only if we need to run the metrics merging server, the xDS watchdog or job completion.
+	if shouldRunMetricsMerging || watchdog.enabled || jobCompletion {
 		consulSidecar, err := h.consulSidecar(pod)
 		if err != nil {
 			h.Log.Error(err, "error configuring consul sidecar container", "request name", req.Name)
@@ -340,6 +479,36 @@ func (h *Handler) Handle(ctx context.Context, req admission.Request) admission.R
 		pod.Spec.Containers = append(pod.Spec.Containers, consulSidecar)
 	}
 
+	// Record the init mode of transparent proxy pods so that the transparent proxy node helper can find the pods
+	// it applies the traffic redirection rules for. Transparent proxy is not supported for multi port pods.
+	tproxyEnabled, err := transparentProxyEnabled(*ns, pod, h.EnableTransparentProxy)
+	if err != nil {
+		h.Log.Error(err, "error determining if transparent proxy is enabled", "request name", req.Name)
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error determining if transparent proxy is enabled: %s", err))
+	}
+	// On OpenShift, the injected containers run under the restricted-v2 SecurityContextConstraints.
+	restrict := h.EnableOpenShift
+	if tproxyEnabled && !multiPort {
+		tproxyInitMode, err := h.tproxyInitMode(*ns, pod)
+		if err != nil {
+			h.Log.Error(err, "error determining transparent proxy init mode", "request name", req.Name)
+			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error determining transparent proxy init mode: %s", err))
+		}
+		pod.Annotations[AnnotationTransparentProxyInitMode] = tproxyInitMode
+		if tproxyInitMode == TProxyInitModeNodeHelper {
+			restrict = true
+		}
+		if h.EnableOpenShift {
+			if err := applyOpenShiftFSGroup(*ns, &pod); err != nil {
+				h.Log.Error(err, "error setting the fsGroup of the pod", "request name", req.Name)
+				return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error setting the fsGroup of the pod: %s", err))
+			}
+		}
+	}
+	if restrict {
+		restrictInjectedContainers(&pod)
+	}
+
 	// pod.Annotations has already been initialized by h.defaultAnnotations()
 	// and does not need to be checked for being a nil value.
 	pod.Annotations[keyInjectStatus] = injected
@@ -389,7 +558,7 @@ func (h *Handler) Handle(ctx context.Context, req admission.Request) admission.R
 	// all patches are created to guarantee no errors were encountered in
 	// that process before modifying the Consul cluster.
 	if h.EnableNamespaces {
-		if _, err := namespaces.EnsureExists(h.ConsulClient, h.consulNamespace(req.Namespace), h.CrossNamespaceACLPolicy); err != nil {
+		if err := h.ensureNamespace(h.consulNamespace(req.Namespace)); err != nil {
 			h.Log.Error(err, "error checking or creating namespace",
 				"ns", h.consulNamespace(req.Namespace), "request name", req.Name)
 			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error checking or creating namespace: %s", err))
@@ -411,6 +580,28 @@ func shouldOverwriteProbes(pod corev1.Pod, globalOverwrite bool) (bool, error) {
 	return globalOverwrite, nil
 }
 
+// holdApplicationUntilProxyStarts returns true if the application containers of this pod should only
+// be started once the Envoy proxy is ready. It returns an error when the annotation value cannot be
+// parsed by strconv.ParseBool.
+func holdApplicationUntilProxyStarts(pod corev1.Pod, globalHold bool) (bool, error) {
+	if raw, ok := pod.Annotations[annotationHoldApplicationUntilProxyStarts]; ok {
+		return strconv.ParseBool(raw)
+	}
+
+	return globalHold, nil
+}
+
+// insertEnvoySidecar adds the Envoy sidecar to the containers. If the application is held until the
+// proxy starts, the sidecar is inserted at the index so that the sidecars precede the application
+// containers: the kubelet starts containers in order and waits for the post-start hook of the
+// sidecar, which returns once Envoy is ready, before starting the next container.
+func insertEnvoySidecar(containers []corev1.Container, sidecar corev1.Container, index int, holdApplication bool) []corev1.Container {
+	if !holdApplication {
+		return append(containers, sidecar)
+	}
+	return append(containers[:index], append([]corev1.Container{sidecar}, containers[index:]...)...)
+}
+
 // overwriteProbes overwrites readiness/liveness probes of this pod when
 // both transparent proxy is enabled and overwrite probes is true for the pod.
 func (h *Handler) overwriteProbes(ns corev1.Namespace, pod *corev1.Pod) error {
@@ -536,7 +727,25 @@ func (h *Handler) prometheusAnnotations(pod *corev1.Pod) error {
 // registered in based on the namespace options. It returns an
 // empty string if namespaces aren't enabled.
 func (h *Handler) consulNamespace(ns string) string {
-	return namespaces.ConsulNamespace(ns, h.EnableNamespaces, h.ConsulDestinationNamespace, h.EnableK8SNSMirroring, h.K8SNSMirroringPrefix)
+	return namespaces.ConsulNamespace(ns, h.EnableNamespaces, h.ConsulDestinationNamespace, h.EnableK8SNSMirroring, h.K8SNSMirroringPrefix, h.K8SNSMirroringRules)
+}
+
+// ensureNamespace ensures the Consul namespace exists. Namespaces that are
+// known to exist are not checked while the Consul servers are unreachable,
+// and are assumed to exist if checking them fails.
+func (h *Handler) ensureNamespace(ns string) error {
+	if h.NamespaceCache.Has(ns) && h.ConsulHealth.Degraded() {
+		return nil
+	}
+	if _, err := namespaces.EnsureExists(h.ConsulClient, ns, h.CrossNamespaceACLPolicy); err != nil {
+		if h.NamespaceCache.Has(ns) {
+			h.Log.Info("unable to check namespace, injecting with cached namespace", "ns", ns, "err", err.Error())
+			return nil
+		}
+		return err
+	}
+	h.NamespaceCache.Add(ns)
+	return nil
 }
 
 func (h *Handler) validatePod(pod corev1.Pod) error {
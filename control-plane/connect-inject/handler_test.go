@@ -3,12 +3,18 @@ package connectinject
 import (
 	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	mapset "github.com/deckarep/golang-set"
 	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
 	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
 	"github.com/stretchr/testify/require"
 	"gomodules.xyz/jsonpatch/v2"
 	admissionv1 "k8s.io/api/admission/v1"
@@ -31,6 +37,8 @@ func TestHandlerHandle(t *testing.T) {
 			},
 		},
 	}
+	jobSpec := *basicSpec.DeepCopy()
+	jobSpec.RestartPolicy = corev1.RestartPolicyNever
 	s := runtime.NewScheme()
 	s.AddKnownTypes(schema.GroupVersion{
 		Group:   "",
@@ -132,6 +140,58 @@ func TestHandlerHandle(t *testing.T) {
 			},
 		},
 
+		{
+			"pod with an invalid upstream",
+			Handler{
+				Log:                   logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+				Clientset:             defaultTestClientWithNamespace(),
+			},
+			admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: namespaces.DefaultNamespace,
+					Object: encodeRaw(t, &corev1.Pod{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								annotationUpstreams: "echo:1234,db.svc.dc2.datacenter:1234",
+							},
+						},
+						Spec: basicSpec,
+					}),
+				},
+			},
+			`upstream "db.svc.dc2.datacenter:1234" is invalid: unknown label "datacenter"`,
+			nil,
+		},
+
+		{
+			"pod with an invalid service meta template",
+			Handler{
+				Log:                   logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+				Clientset:             defaultTestClientWithNamespace(),
+			},
+			admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: namespaces.DefaultNamespace,
+					Object: encodeRaw(t, &corev1.Pod{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								annotationMeta + "version": "{{ .Labels.version ",
+							},
+						},
+						Spec: basicSpec,
+					}),
+				},
+			},
+			`consul.hashicorp.com/service-meta-version annotation value of {{ .Labels.version  was invalid`,
+			nil,
+		},
+
 		{
 			"pod with upstreams specified",
 			Handler{
@@ -636,6 +696,10 @@ func TestHandlerHandle(t *testing.T) {
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(annotationOriginalPod),
 				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(AnnotationTransparentProxyInitMode),
+				},
 				{
 					Operation: "replace",
 					Path:      "/spec/containers/0/livenessProbe/httpGet/port",
@@ -700,6 +764,115 @@ func TestHandlerHandle(t *testing.T) {
 				},
 			},
 		},
+		{
+			"job pod",
+			Handler{
+				Log:                   logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+				Clientset:             defaultTestClientWithNamespace(),
+			},
+			admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: namespaces.DefaultNamespace,
+					Object: encodeRaw(t, &corev1.Pod{
+						Spec: jobSpec,
+						ObjectMeta: metav1.ObjectMeta{
+							OwnerReferences: []metav1.OwnerReference{
+								{
+									APIVersion: "batch/v1",
+									Kind:       "Job",
+									Name:       "migrate",
+								},
+							},
+						},
+					}),
+				},
+			},
+			"",
+			[]jsonpatch.Operation{
+				{
+					Operation: "add",
+					Path:      "/metadata/labels",
+				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations",
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/volumes",
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/initContainers",
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/containers/1",
+				},
+			},
+		},
+		{
+			"job pod with job completion enabled",
+			Handler{
+				Log:                   logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+				Clientset:             defaultTestClientWithNamespace(),
+				EnableJobCompletion:   true,
+			},
+			admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: namespaces.DefaultNamespace,
+					Object: encodeRaw(t, &corev1.Pod{
+						Spec: jobSpec,
+						ObjectMeta: metav1.ObjectMeta{
+							OwnerReferences: []metav1.OwnerReference{
+								{
+									APIVersion: "batch/v1",
+									Kind:       "Job",
+									Name:       "migrate",
+								},
+							},
+						},
+					}),
+				},
+			},
+			"",
+			[]jsonpatch.Operation{
+				{
+					Operation: "add",
+					Path:      "/metadata/labels",
+				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations",
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/volumes",
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/initContainers",
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/containers/1",
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/containers/2",
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/shareProcessNamespace",
+				},
+			},
+		},
 	}
 
 	for _, tt := range cases {
@@ -1171,6 +1344,45 @@ func TestConsulNamespace(t *testing.T) {
 	}
 }
 
+// Test that namespaces known to exist are relied on while Consul is unreachable.
+func TestHandler_ensureNamespace(t *testing.T) {
+	var requests int32
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer consulServer.Close()
+	client, err := api.NewClient(&api.Config{Address: consulServer.URL})
+	require.NoError(t, err)
+
+	h := Handler{
+		ConsulClient:   client,
+		NamespaceCache: &namespaces.Cache{},
+		Log:            logrtest.TestLogger{T: t},
+	}
+
+	// Unknown namespaces fail the request.
+	require.Error(t, h.ensureNamespace("web"))
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// Known namespaces are assumed to exist if checking them fails.
+	h.NamespaceCache.Add("web")
+	require.NoError(t, h.ensureNamespace("web"))
+	require.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	// They aren't checked at all while Consul is known to be unreachable.
+	h.ConsulHealth = &consul.HealthTracker{Client: client, Log: logrtest.TestLogger{T: t}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.ConsulHealth.Start(ctx)
+	retry.Run(t, func(r *retry.R) {
+		require.True(r, h.ConsulHealth.Degraded())
+	})
+	checks := atomic.LoadInt32(&requests)
+	require.NoError(t, h.ensureNamespace("web"))
+	require.Equal(t, checks, atomic.LoadInt32(&requests))
+}
+
 // Test shouldInject function.
 func TestShouldInject(t *testing.T) {
 	cases := []struct {
@@ -1849,3 +2061,40 @@ func clientWithNamespace(name string) kubernetes.Interface {
 	}
 	return fake.NewSimpleClientset(&ns)
 }
+
+func TestInsertEnvoySidecar(t *testing.T) {
+	app := func() []corev1.Container {
+		return []corev1.Container{{Name: "web"}, {Name: "web-admin"}}
+	}
+	cases := map[string]struct {
+		holdApplication bool
+		index           int
+		exp             []string
+	}{
+		"appends without hold": {
+			holdApplication: false,
+			index:           0,
+			exp:             []string{"web", "web-admin", "envoy-sidecar"},
+		},
+		"inserts first with hold": {
+			holdApplication: true,
+			index:           0,
+			exp:             []string{"envoy-sidecar", "web", "web-admin"},
+		},
+		"inserts after previous sidecars with hold": {
+			holdApplication: true,
+			index:           1,
+			exp:             []string{"web", "envoy-sidecar", "web-admin"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			containers := insertEnvoySidecar(app(), corev1.Container{Name: "envoy-sidecar"}, c.index, c.holdApplication)
+			var names []string
+			for _, container := range containers {
+				names = append(names, container.Name)
+			}
+			require.Equal(t, c.exp, names)
+		})
+	}
+}
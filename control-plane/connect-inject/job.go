@@ -0,0 +1,81 @@
+package connectinject
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// jobName returns the name of the Job that owns the pod, if any. Pods of CronJobs are owned by
+// the Jobs the CronJob creates.
+func jobName(pod corev1.Pod) (string, bool) {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Kind == "Job" && strings.HasPrefix(ref.APIVersion, "batch/") {
+			return ref.Name, true
+		}
+	}
+	return "", false
+}
+
+// jobCompletionEnabled returns true if the Envoy proxies of the pod should be stopped once its
+// application containers have exited. It defaults to enableJobCompletion for pods owned by a Job.
+// It returns an error when the annotation value cannot be parsed by strconv.ParseBool.
+//
+// Only pods with the Never restart policy are supported. The kubelet restarts the failed
+// application containers of other pods, and until it does none of their processes are running,
+// so the proxies would be stopped for good while the application is still being retried.
+func jobCompletionEnabled(pod corev1.Pod, enableJobCompletion bool) (bool, error) {
+	enabled := false
+	if raw, ok := pod.Annotations[annotationJobCompletion]; ok {
+		var err error
+		if enabled, err = strconv.ParseBool(raw); err != nil {
+			return false, err
+		}
+		if enabled && pod.Spec.RestartPolicy != corev1.RestartPolicyNever {
+			return false, fmt.Errorf("pods with the %q restart policy are not supported, only %q", pod.Spec.RestartPolicy, corev1.RestartPolicyNever)
+		}
+		return enabled, nil
+	}
+
+	_, ok := jobName(pod)
+	return enableJobCompletion && ok && pod.Spec.RestartPolicy == corev1.RestartPolicyNever, nil
+}
+
+// jobServesTraffic returns false if the pod is owned by a Job and its service instance should
+// not receive traffic. Job pods only run until their work is done, so they are not registered
+// as long-lived service instances unless the job-serve-traffic annotation is set. It returns
+// an error when the annotation value cannot be parsed by strconv.ParseBool.
+func jobServesTraffic(pod corev1.Pod) (bool, error) {
+	if _, ok := jobName(pod); !ok {
+		return true, nil
+	}
+	if raw, ok := pod.Annotations[annotationJobServeTraffic]; ok {
+		return strconv.ParseBool(raw)
+	}
+
+	return false, nil
+}
+
+// jobHealthStatus returns the health status that the service instance of the pod is registered
+// with. The instances of Job pods that don't serve traffic are always critical; they are still
+// registered since the proxies of the pods are configured from their registration.
+func jobHealthStatus(pod corev1.Pod, healthStatus string) (string, error) {
+	serves, err := jobServesTraffic(pod)
+	if err != nil {
+		return "", fmt.Errorf("%s annotation value of %s was invalid: %s", annotationJobServeTraffic, pod.Annotations[annotationJobServeTraffic], err)
+	}
+	if !serves {
+		return api.HealthCritical, nil
+	}
+	return healthStatus, nil
+}
+
+// jobPodReason returns the output of the Consul health check of a Job pod that doesn't serve
+// traffic.
+func jobPodReason(pod corev1.Pod) string {
+	job, _ := jobName(pod)
+	return fmt.Sprintf("Pod \"%s/%s\" belongs to Job %q and does not serve traffic", pod.Namespace, pod.Name, job)
+}
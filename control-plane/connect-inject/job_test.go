@@ -0,0 +1,169 @@
+package connectinject
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestJobCompletionEnabled(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		owners        []metav1.OwnerReference
+		annotations   map[string]string
+		restartPolicy corev1.RestartPolicy
+		// enabled is the default of the injector.
+		enabled    bool
+		expEnabled bool
+		expErr     bool
+	}{
+		"not owned": {
+			restartPolicy: corev1.RestartPolicyNever,
+			expEnabled:    false,
+		},
+		"owned by a replica set": {
+			owners:        []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-5d8f"}},
+			restartPolicy: corev1.RestartPolicyAlways,
+			expEnabled:    false,
+		},
+		"owned by a job": {
+			owners:        []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "migrate"}},
+			restartPolicy: corev1.RestartPolicyNever,
+			expEnabled:    false,
+		},
+		"owned by a job, enabled by default": {
+			owners:        []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "migrate"}},
+			restartPolicy: corev1.RestartPolicyNever,
+			enabled:       true,
+			expEnabled:    true,
+		},
+		"not owned, enabled by default": {
+			restartPolicy: corev1.RestartPolicyNever,
+			enabled:       true,
+			expEnabled:    false,
+		},
+		"owned by a job that restarts on failure": {
+			owners:        []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "migrate"}},
+			restartPolicy: corev1.RestartPolicyOnFailure,
+			enabled:       true,
+			expEnabled:    false,
+		},
+		"owned by a job of another API group": {
+			owners:        []metav1.OwnerReference{{APIVersion: "example.com/v1", Kind: "Job", Name: "migrate"}},
+			restartPolicy: corev1.RestartPolicyNever,
+			expEnabled:    false,
+		},
+		"disabled via annotation": {
+			owners:        []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "migrate"}},
+			annotations:   map[string]string{annotationJobCompletion: "false"},
+			restartPolicy: corev1.RestartPolicyNever,
+			enabled:       true,
+			expEnabled:    false,
+		},
+		"enabled via annotation": {
+			annotations:   map[string]string{annotationJobCompletion: "true"},
+			restartPolicy: corev1.RestartPolicyNever,
+			expEnabled:    true,
+		},
+		"enabled via annotation for a pod that restarts on failure": {
+			owners:        []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "migrate"}},
+			annotations:   map[string]string{annotationJobCompletion: "true"},
+			restartPolicy: corev1.RestartPolicyOnFailure,
+			expErr:        true,
+		},
+		"invalid annotation": {
+			annotations:   map[string]string{annotationJobCompletion: "maybe"},
+			restartPolicy: corev1.RestartPolicyNever,
+			expErr:        true,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: c.annotations, OwnerReferences: c.owners},
+				Spec:       corev1.PodSpec{RestartPolicy: c.restartPolicy},
+			}
+			enabled, err := jobCompletionEnabled(pod, c.enabled)
+			if c.expErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expEnabled, enabled)
+		})
+	}
+}
+
+func TestJobHealthStatus(t *testing.T) {
+	t.Parallel()
+
+	jobOwner := []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "migrate-27713920"}}
+	cases := map[string]struct {
+		owners      []metav1.OwnerReference
+		annotations map[string]string
+		status      string
+		expStatus   string
+		expErr      string
+	}{
+		"not a job": {
+			status:    api.HealthPassing,
+			expStatus: api.HealthPassing,
+		},
+		"job": {
+			owners:    jobOwner,
+			status:    api.HealthPassing,
+			expStatus: api.HealthCritical,
+		},
+		"job serving traffic": {
+			owners:      jobOwner,
+			annotations: map[string]string{annotationJobServeTraffic: "true"},
+			status:      api.HealthPassing,
+			expStatus:   api.HealthPassing,
+		},
+		"job serving traffic that isn't ready": {
+			owners:      jobOwner,
+			annotations: map[string]string{annotationJobServeTraffic: "true"},
+			status:      api.HealthCritical,
+			expStatus:   api.HealthCritical,
+		},
+		"invalid annotation": {
+			owners:      jobOwner,
+			annotations: map[string]string{annotationJobServeTraffic: "maybe"},
+			status:      api.HealthPassing,
+			expErr:      `consul.hashicorp.com/job-serve-traffic annotation value of maybe was invalid: strconv.ParseBool: parsing "maybe": invalid syntax`,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: c.annotations, OwnerReferences: c.owners}}
+			status, err := jobHealthStatus(pod, c.status)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expStatus, status)
+		})
+	}
+}
+
+func TestJobPodReason(t *testing.T) {
+	t.Parallel()
+
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:            "migrate-27713920-xk2p9",
+		Namespace:       "default",
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "migrate-27713920"}},
+	}}
+	require.Equal(t, `Pod "default/migrate-27713920-xk2p9" belongs to Job "migrate-27713920" and does not serve traffic`, jobPodReason(pod))
+}
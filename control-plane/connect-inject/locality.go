@@ -0,0 +1,48 @@
+package connectinject
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// serviceLocalityEnabled returns true if the service instances of the pod should be registered
+// with the locality of its node. It returns an error when the annotation value cannot be parsed
+// by strconv.ParseBool.
+func serviceLocalityEnabled(pod corev1.Pod, globalEnabled bool) (bool, error) {
+	if raw, ok := pod.Annotations[annotationServiceLocality]; ok {
+		return strconv.ParseBool(raw)
+	}
+
+	return globalEnabled, nil
+}
+
+// serviceLocality returns the locality of the node the pod runs on, taken from the well-known
+// topology.kubernetes.io/region and topology.kubernetes.io/zone labels of the node. It returns
+// nil if the pod isn't scheduled yet, its node is gone or the node has neither label, in which
+// case the service instances are registered without locality.
+func (r *EndpointsController) serviceLocality(ctx context.Context, pod corev1.Pod) (*api.Locality, error) {
+	if pod.Spec.NodeName == "" {
+		return nil, nil
+	}
+
+	var node corev1.Node
+	err := r.Client.Get(ctx, types.NamespacedName{Name: pod.Spec.NodeName}, &node)
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	region := node.Labels[corev1.LabelTopologyRegion]
+	zone := node.Labels[corev1.LabelTopologyZone]
+	if region == "" && zone == "" {
+		return nil, nil
+	}
+	return &api.Locality{Region: region, Zone: zone}, nil
+}
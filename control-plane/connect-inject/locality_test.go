@@ -0,0 +1,151 @@
+package connectinject
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestServiceLocalityEnabled(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		annotations   map[string]string
+		globalEnabled bool
+		expEnabled    bool
+		expErr        bool
+	}{
+		"disabled by default": {
+			expEnabled: false,
+		},
+		"enabled globally": {
+			globalEnabled: true,
+			expEnabled:    true,
+		},
+		"enabled via annotation": {
+			annotations: map[string]string{annotationServiceLocality: "true"},
+			expEnabled:  true,
+		},
+		"annotation takes precedence": {
+			annotations:   map[string]string{annotationServiceLocality: "false"},
+			globalEnabled: true,
+			expEnabled:    false,
+		},
+		"invalid annotation": {
+			annotations: map[string]string{annotationServiceLocality: "yes please"},
+			expErr:      true,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: c.annotations}}
+			enabled, err := serviceLocalityEnabled(pod, c.globalEnabled)
+			if c.expErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expEnabled, enabled)
+		})
+	}
+}
+
+func TestServiceLocality(t *testing.T) {
+	t.Parallel()
+
+	node := func(labels map[string]string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: labels}}
+	}
+
+	cases := map[string]struct {
+		nodeName    string
+		objects     []runtime.Object
+		expLocality *api.Locality
+	}{
+		"pod not scheduled": {
+			objects: []runtime.Object{node(map[string]string{corev1.LabelTopologyZone: "us-east-1a"})},
+		},
+		"node not found": {
+			nodeName: "node-1",
+		},
+		"node without topology labels": {
+			nodeName: "node-1",
+			objects:  []runtime.Object{node(nil)},
+		},
+		"node with region and zone": {
+			nodeName: "node-1",
+			objects: []runtime.Object{node(map[string]string{
+				corev1.LabelTopologyRegion: "us-east-1",
+				corev1.LabelTopologyZone:   "us-east-1a",
+			})},
+			expLocality: &api.Locality{Region: "us-east-1", Zone: "us-east-1a"},
+		},
+		"node with zone only": {
+			nodeName:    "node-1",
+			objects:     []runtime.Object{node(map[string]string{corev1.LabelTopologyZone: "zone-a"})},
+			expLocality: &api.Locality{Zone: "zone-a"},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			r := &EndpointsController{
+				Client: fake.NewClientBuilder().WithRuntimeObjects(c.objects...).Build(),
+				Log:    logrtest.TestLogger{T: t},
+			}
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec:       corev1.PodSpec{NodeName: c.nodeName},
+			}
+			locality, err := r.serviceLocality(context.Background(), pod)
+			require.NoError(t, err)
+			require.Equal(t, c.expLocality, locality)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_locality(t *testing.T) {
+	t.Parallel()
+
+	pod := createPod("pod1", "1.2.3.4", true, true)
+	pod.Spec.NodeName = "node-1"
+	endpoints := corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "service-created", Namespace: "default"}}
+	objects := []runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{
+			corev1.LabelTopologyRegion: "us-east-1",
+			corev1.LabelTopologyZone:   "us-east-1a",
+		}}},
+	}
+
+	for _, enabled := range []bool{false, true} {
+		r := &EndpointsController{
+			Client:                fake.NewClientBuilder().WithRuntimeObjects(objects...).Build(),
+			Log:                   logrtest.TestLogger{T: t},
+			Context:               context.Background(),
+			EnableServiceLocality: enabled,
+		}
+		service, proxyService, err := r.createServiceRegistrations(*pod, endpoints)
+		require.NoError(t, err)
+		if !enabled {
+			require.Nil(t, service.Locality)
+			require.Nil(t, proxyService.Locality)
+			continue
+		}
+		expLocality := &api.Locality{Region: "us-east-1", Zone: "us-east-1a"}
+		require.Equal(t, expLocality, service.Locality)
+		require.Equal(t, expLocality, proxyService.Locality)
+	}
+}
@@ -0,0 +1,153 @@
+package connectinject
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	// MigrationNodeMetaKey is the node meta key set on the synthetic node that service
+	// instances are registered on in the datacenter being migrated to.
+	MigrationNodeMetaKey = "consul-k8s-migration"
+
+	// DefaultMigrationNodeName is the default name of the synthetic node that service
+	// instances are registered on in the datacenter being migrated to.
+	DefaultMigrationNodeName = "k8s-migration"
+)
+
+// registerMigrationServices registers the service and proxy service instances into the catalog of the
+// datacenter being migrated to. The instances are registered on a synthetic node since the Consul
+// client agents are still joined to the old datacenter. Health is mirrored from the Kubernetes
+// readiness of the pod because the agent health checks of the registrations can't be run by the catalog.
+func (r *EndpointsController) registerMigrationServices(service, proxyService *api.AgentServiceRegistration, healthStatus, reason string) error {
+	for _, reg := range []*api.AgentServiceRegistration{service, proxyService} {
+		checkID := fmt.Sprintf("%s/migration", reg.ID)
+		r.Log.Info("registering service with migration datacenter", "name", reg.Name, "id", reg.ID, "node", r.migrationNodeName())
+		_, err := r.MigrationConsulClient.Catalog().Register(&api.CatalogRegistration{
+			Node:    r.migrationNodeName(),
+			Address: "127.0.0.1",
+			NodeMeta: map[string]string{
+				MigrationNodeMetaKey: "true",
+			},
+			SkipNodeUpdate: true,
+			Service:        agentServiceFromRegistration(reg),
+			Checks: api.HealthChecks{
+				{
+					Node:        r.migrationNodeName(),
+					CheckID:     checkID,
+					Name:        "Kubernetes Health Check",
+					Status:      healthStatus,
+					Output:      reason,
+					ServiceID:   reg.ID,
+					ServiceName: reg.Name,
+					Namespace:   reg.Namespace,
+				},
+			},
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("registering service %q with migration datacenter: %w", reg.ID, err)
+		}
+		r.Audit.Record(nil, consul.AuditWrite{
+			Operation: consul.AuditOpRegister,
+			Kind:      consul.AuditKindCatalogService,
+			Name:      reg.ID,
+			Namespace: reg.Namespace,
+			Payload:   reg,
+		})
+	}
+	return nil
+}
+
+// deregisterMigrationServices deregisters service instances for the Kubernetes service from the catalog
+// of the datacenter being migrated to. If endpointsAddressesMap is nil, every instance is deregistered,
+// otherwise only instances with addresses that are no longer part of the Endpoints are deregistered.
+func (r *EndpointsController) deregisterMigrationServices(k8sSvcName, k8sSvcNamespace string, endpointsAddressesMap map[string]bool) error {
+	services, err := r.migrationServiceInstances(k8sSvcName, k8sSvcNamespace)
+	if err != nil {
+		return err
+	}
+
+	for _, svc := range services {
+		if !r.ownsServiceInstance(svc) {
+			continue
+		}
+		if endpointsAddressesMap != nil {
+			if _, ok := endpointsAddressesMap[svc.Address]; ok {
+				continue
+			}
+		}
+		r.Log.Info("deregistering service from migration datacenter", "svc", svc.ID)
+		_, err := r.MigrationConsulClient.Catalog().Deregister(&api.CatalogDeregistration{
+			Node:      r.migrationNodeName(),
+			ServiceID: svc.ID,
+			Namespace: svc.Namespace,
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("deregistering service %q from migration datacenter: %w", svc.ID, err)
+		}
+		r.Audit.Record(nil, consul.AuditWrite{
+			Operation: consul.AuditOpDeregister,
+			Kind:      consul.AuditKindCatalogService,
+			Name:      svc.ID,
+			Namespace: svc.Namespace,
+		})
+	}
+	return nil
+}
+
+// migrationServiceInstances returns the service instances for the Kubernetes service on the node
+// in the datacenter being migrated to, from the cache if it has synced and the catalog otherwise.
+func (r *EndpointsController) migrationServiceInstances(k8sSvcName, k8sSvcNamespace string) ([]*api.AgentService, error) {
+	if r.MigrationServiceCache != nil {
+		if cached, ok := r.MigrationServiceCache.ServiceInstances(r.migrationNodeName(), k8sSvcName, k8sSvcNamespace); ok {
+			var services []*api.AgentService
+			for _, svc := range cached {
+				services = append(services, svc)
+			}
+			return services, nil
+		}
+	}
+
+	filter := fmt.Sprintf(`Meta[%q] == %q and Meta[%q] == %q and Meta[%q] == %q`,
+		MetaKeyKubeServiceName, k8sSvcName, MetaKeyKubeNS, k8sSvcNamespace, MetaKeyManagedBy, managedByValue)
+	nodeServices, _, err := r.MigrationConsulClient.Catalog().NodeServiceList(r.migrationNodeName(), &api.QueryOptions{
+		Filter:    filter,
+		Namespace: r.consulNamespace(k8sSvcNamespace),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing services in migration datacenter: %w", err)
+	}
+	// The node does not exist yet if nothing has been registered.
+	if nodeServices == nil {
+		return nil, nil
+	}
+	return nodeServices.Services, nil
+}
+
+func (r *EndpointsController) migrationNodeName() string {
+	if r.MigrationNodeName == "" {
+		return DefaultMigrationNodeName
+	}
+	return r.MigrationNodeName
+}
+
+// agentServiceFromRegistration converts an agent service registration into the service
+// definition used by catalog registrations.
+func agentServiceFromRegistration(reg *api.AgentServiceRegistration) *api.AgentService {
+	return &api.AgentService{
+		Kind:            reg.Kind,
+		ID:              reg.ID,
+		Service:         reg.Name,
+		Tags:            reg.Tags,
+		Meta:            reg.Meta,
+		Port:            reg.Port,
+		Address:         reg.Address,
+		TaggedAddresses: reg.TaggedAddresses,
+		Proxy:           reg.Proxy,
+		Connect:         reg.Connect,
+		Namespace:       reg.Namespace,
+		Partition:       reg.Partition,
+	}
+}
@@ -0,0 +1,118 @@
+package connectinject
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set"
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// Test that while migrating, service instances are registered into and
+// deregistered from the datacenter being migrated to.
+func TestReconcile_MigrationDatacenter(t *testing.T) {
+	t.Parallel()
+	nodeName := "test-node"
+
+	pod1 := createPod("pod1", "1.2.3.4", true, true)
+	endpoint := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "service-created",
+			Namespace: "default",
+		},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{
+					{
+						IP:       "1.2.3.4",
+						NodeName: &nodeName,
+						TargetRef: &corev1.ObjectReference{
+							Kind:      "Pod",
+							Name:      "pod1",
+							Namespace: "default",
+						},
+					},
+				},
+			},
+		},
+	}
+	fakeClientPod := createPod("fake-consul-client", "127.0.0.1", false, true)
+	fakeClientPod.Labels = map[string]string{"component": "client", "app": "consul", "release": "consul"}
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(pod1, endpoint, fakeClientPod, &ns).Build()
+
+	// Create the test consul server for the current datacenter.
+	consul, err := testutil.NewTestServerConfigT(t, func(c *testutil.TestServerConfig) {
+		c.NodeName = nodeName
+	})
+	require.NoError(t, err)
+	defer consul.Stop()
+	consul.WaitForServiceIntentions(t)
+	cfg := &api.Config{
+		Address: consul.HTTPAddr,
+	}
+	consulClient, err := api.NewClient(cfg)
+	require.NoError(t, err)
+	consulPort := strings.Split(consul.HTTPAddr, ":")[1]
+
+	// Create the test consul server for the datacenter being migrated to.
+	migrationConsul, err := testutil.NewTestServerConfigT(t, func(c *testutil.TestServerConfig) {
+		c.Datacenter = "dc2"
+	})
+	require.NoError(t, err)
+	defer migrationConsul.Stop()
+	migrationConsul.WaitForLeader(t)
+	migrationConsulClient, err := api.NewClient(&api.Config{Address: migrationConsul.HTTPAddr})
+	require.NoError(t, err)
+
+	ep := &EndpointsController{
+		Client:                fakeClient,
+		Log:                   logrtest.TestLogger{T: t},
+		ConsulClient:          consulClient,
+		ConsulPort:            consulPort,
+		ConsulScheme:          "http",
+		AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:  mapset.NewSetWith(),
+		ReleaseName:           "consul",
+		ReleaseNamespace:      "default",
+		ConsulClientCfg:       cfg,
+		MigrationConsulClient: migrationConsulClient,
+	}
+	namespacedName := types.NamespacedName{
+		Namespace: "default",
+		Name:      "service-created",
+	}
+
+	resp, err := ep.Reconcile(context.Background(), ctrl.Request{NamespacedName: namespacedName})
+	require.NoError(t, err)
+	require.False(t, resp.Requeue)
+
+	// The service and proxy should be registered on the migration node with the pod's health.
+	for _, name := range []string{"service-created", "service-created-sidecar-proxy"} {
+		instances, _, err := migrationConsulClient.Health().Service(name, "", false, nil)
+		require.NoError(t, err)
+		require.Len(t, instances, 1)
+		require.Equal(t, DefaultMigrationNodeName, instances[0].Node.Node)
+		require.Equal(t, "1.2.3.4", instances[0].Service.Address)
+		require.Equal(t, api.HealthPassing, instances[0].Checks.AggregatedStatus())
+	}
+
+	// Deleting the endpoints should deregister the instances from the migration datacenter.
+	require.NoError(t, fakeClient.Delete(context.Background(), endpoint))
+	_, err = ep.Reconcile(context.Background(), ctrl.Request{NamespacedName: namespacedName})
+	require.NoError(t, err)
+	for _, name := range []string{"service-created", "service-created-sidecar-proxy"} {
+		instances, _, err := migrationConsulClient.Catalog().Service(name, "", nil)
+		require.NoError(t, err)
+		require.Empty(t, instances)
+	}
+}
@@ -0,0 +1,604 @@
+package connectinject
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mapset "github.com/deckarep/golang-set"
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// networkPolicyManagedByValue is the value of the keyManagedBy label of the
+	// NetworkPolicies generated by the NetworkPolicyController.
+	networkPolicyManagedByValue = "consul-k8s-network-policy-controller"
+
+	// networkPolicyNamePrefix is the prefix of the names of the generated NetworkPolicies.
+	// The rest of the name is the name of the Kubernetes service.
+	networkPolicyNamePrefix = "consul-mesh-"
+
+	// wildcard is the name of the intention sources and destinations that match every service.
+	wildcard = "*"
+
+	// labelNamespaceName is the label that Kubernetes 1.21+ sets on every namespace to its name.
+	labelNamespaceName = "kubernetes.io/metadata.name"
+
+	defaultNetworkPolicyIntentionsWaitTime      = 5 * time.Minute
+	defaultNetworkPolicyIntentionsRetryInterval = 5 * time.Second
+)
+
+// NetworkPolicyController generates a NetworkPolicy for every Kubernetes service in the
+// namespaces that opted in with the consul.hashicorp.com/network-policy label, so that
+// the network mirrors the reachability of the mesh. The policy of a service applies to
+// its injected pods and:
+//
+//   - allows inbound traffic only on the public listener port of their proxies, from the
+//     injected pods of the services that intentions allow to connect to the service, and
+//     from gateways.
+//   - allows outbound traffic only to Consul servers and clients, to DNS, to other
+//     injected pods and to gateways.
+//
+// Intentions are watched with a blocking query, so the policies are updated as
+// intentions change. Consul service names are assumed to be the names of the Kubernetes
+// services, and their Consul namespaces are derived from their Kubernetes namespaces like
+// the endpoints controller registers them. A NetworkPolicy can't express exceptions, so if intentions allow any source
+// to connect to a service but deny some, the policy allows every injected pod and the
+// proxy enforces the denials. Other traffic, e.g. to the Kubernetes API or from
+// Prometheus, can be allowed by additional NetworkPolicies since policies are additive.
+type NetworkPolicyController struct {
+	client.Client
+	ConsulClient *api.Client
+	// EnableConsulNamespaces, ConsulDestinationNamespace, EnableNSMirroring,
+	// NSMirroringPrefix and NSMirroringRules map Kubernetes namespaces to Consul
+	// namespaces. They must match the configuration of the endpoints controller.
+	EnableConsulNamespaces     bool
+	ConsulDestinationNamespace string
+	EnableNSMirroring          bool
+	NSMirroringPrefix          string
+	NSMirroringRules           namespaces.MirroringRules
+	// DefaultAllow is whether Consul allows connections that no intention matches,
+	// i.e. whether the ACL default policy is "allow".
+	DefaultAllow bool
+	// Only namespaces in the allow set and not in the deny set are opted in, in
+	// addition to the namespace label.
+	AllowK8sNamespacesSet mapset.Set
+	DenyK8sNamespacesSet  mapset.Set
+	// ReleaseName and ReleaseNamespace select the Consul servers, clients and gateways
+	// of this installation by their labels.
+	ReleaseName      string
+	ReleaseNamespace string
+	// WaitTime is the maximum duration of the blocking query on intentions. It defaults to 5m.
+	WaitTime time.Duration
+	// RetryInterval is how long to wait after a query failed. It defaults to 5s.
+	RetryInterval time.Duration
+	Log           logr.Logger
+	Context       context.Context
+
+	mu               sync.RWMutex
+	intentionsSynced bool
+	intentions       map[consulService]*api.ServiceIntentionsConfigEntry
+	events           chan event.GenericEvent
+}
+
+// Reconcile creates, updates and deletes the NetworkPolicies of the services in the namespace.
+func (r *NetworkPolicyController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// Until intentions are known every policy would deny everything, so wait for the
+	// first query, which enqueues every namespace.
+	if !r.synced() {
+		return ctrl.Result{}, nil
+	}
+
+	var ns corev1.Namespace
+	err := r.Client.Get(ctx, types.NamespacedName{Name: req.Name}, &ns)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	desired := make(map[string]*networkingv1.NetworkPolicy)
+	if err == nil && r.optedIn(ns) {
+		desired, err = r.desiredPolicies(ctx, ns.Name)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	var existing networkingv1.NetworkPolicyList
+	if err := r.Client.List(ctx, &existing, client.InNamespace(req.Name), client.MatchingLabels{keyManagedBy: networkPolicyManagedByValue}); err != nil {
+		return ctrl.Result{}, err
+	}
+	for i := range existing.Items {
+		policy := &existing.Items[i]
+		want, ok := desired[policy.Name]
+		if !ok {
+			r.Log.Info("deleting network policy", "name", policy.Name, "ns", policy.Namespace)
+			if err := r.Client.Delete(ctx, policy); err != nil && !k8serrors.IsNotFound(err) {
+				return ctrl.Result{}, err
+			}
+			continue
+		}
+		delete(desired, policy.Name)
+		if equality.Semantic.DeepEqual(policy.Spec, want.Spec) {
+			continue
+		}
+		policy.Spec = want.Spec
+		r.Log.Info("updating network policy", "name", policy.Name, "ns", policy.Namespace)
+		if err := r.Client.Update(ctx, policy); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	for _, name := range sortedPolicyNames(desired) {
+		policy := desired[name]
+		r.Log.Info("creating network policy", "name", policy.Name, "ns", policy.Namespace)
+		if err := r.Client.Create(ctx, policy); err != nil {
+			if k8serrors.IsAlreadyExists(err) {
+				// A NetworkPolicy with this name that isn't managed by the controller is left alone.
+				r.Log.Info("skipping network policy because a policy with the same name exists", "name", policy.Name, "ns", policy.Namespace)
+				continue
+			}
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// desiredPolicies returns the NetworkPolicies of the services in the namespace, by name.
+func (r *NetworkPolicyController) desiredPolicies(ctx context.Context, namespace string) (map[string]*networkingv1.NetworkPolicy, error) {
+	var services corev1.ServiceList
+	if err := r.Client.List(ctx, &services, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	var allServices corev1.ServiceList
+	if err := r.Client.List(ctx, &allServices); err != nil {
+		return nil, err
+	}
+
+	policies := make(map[string]*networkingv1.NetworkPolicy)
+	for _, svc := range services.Items {
+		if len(svc.Spec.Selector) == 0 || isLabeledIgnore(svc.Labels) {
+			continue
+		}
+		ports, err := r.publicListenerPorts(ctx, svc)
+		if err != nil {
+			return nil, err
+		}
+		allowAll, sources := r.intentionSources(consulService{Namespace: r.consulNamespace(svc.Namespace), Name: svc.Name})
+		policy := r.networkPolicy(svc, ports, r.sourcePeers(allowAll, sources, allServices.Items))
+		policies[policy.Name] = policy
+	}
+	return policies, nil
+}
+
+// publicListenerPorts returns the public listener ports of the proxies of the injected pods
+// of the service. Each service of a multi-port pod has its own proxy, so the port depends on
+// the position of the service in the connect-service annotation.
+func (r *NetworkPolicyController) publicListenerPorts(ctx context.Context, svc corev1.Service) ([]int, error) {
+	var pods corev1.PodList
+	selector := client.MatchingLabels{keyInjectStatus: injected}
+	for k, v := range svc.Spec.Selector {
+		selector[k] = v
+	}
+	if err := r.Client.List(ctx, &pods, client.InNamespace(svc.Namespace), selector); err != nil {
+		return nil, err
+	}
+	ports := make(map[int]bool)
+	for _, pod := range pods.Items {
+		port := 20000
+		if names := strings.Split(pod.Annotations[annotationService], ","); len(names) > 1 {
+			for i, name := range names {
+				if strings.TrimSpace(name) == svc.Name {
+					port += i
+				}
+			}
+		}
+		ports[port] = true
+	}
+	if len(ports) == 0 {
+		ports[20000] = true
+	}
+	var sorted []int
+	for port := range ports {
+		sorted = append(sorted, port)
+	}
+	sort.Ints(sorted)
+	return sorted, nil
+}
+
+// consulService is the Consul namespace and name of a service. The namespace is empty if
+// Consul namespaces are disabled.
+type consulService struct {
+	Namespace string
+	Name      string
+}
+
+// intentionSources returns whether intentions allow any service to connect to the
+// destination and, if not, the services they allow. The name of an allowed service is
+// the wildcard if intentions allow every service of its namespace. Intentions are
+// evaluated in Consul's order of precedence: exact destination before the wildcard
+// destination of its namespace and, for each, exact source before the wildcard source
+// of its namespace before the wildcard source of every namespace. Sources in other
+// partitions or peers are ignored since their traffic comes through gateways.
+func (r *NetworkPolicyController) intentionSources(destination consulService) (bool, []consulService) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := []*api.ServiceIntentionsConfigEntry{
+		r.intentions[destination],
+		r.intentions[consulService{Namespace: destination.Namespace, Name: wildcard}],
+	}
+	var sources []consulService
+	seen := make(map[consulService]bool)
+	for _, entry := range entries {
+		if entry == nil {
+			continue
+		}
+		for _, src := range entry.Sources {
+			source := r.intentionSource(entry, src)
+			if source == r.anySource() || src.Peer != "" || src.Partition != "" || seen[source] {
+				continue
+			}
+			seen[source] = true
+			if r.allowsSource(entries, source) {
+				sources = append(sources, source)
+			}
+		}
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].Namespace != sources[j].Namespace {
+			return sources[i].Namespace < sources[j].Namespace
+		}
+		return sources[i].Name < sources[j].Name
+	})
+	// A source that no intention names is only allowed by the wildcard source of every
+	// namespace or the default.
+	return r.allowsSource(entries, r.anySource()), sources
+}
+
+// allowsSource returns whether the first intention of the entries that matches the source
+// allows it to connect. L7 intentions are allowed since their permissions are enforced by
+// the proxy.
+func (r *NetworkPolicyController) allowsSource(entries []*api.ServiceIntentionsConfigEntry, source consulService) bool {
+	for _, entry := range entries {
+		if entry == nil {
+			continue
+		}
+		for _, match := range []consulService{source, {Namespace: source.Namespace, Name: wildcard}, r.anySource()} {
+			for _, src := range entry.Sources {
+				if r.intentionSource(entry, src) == match && src.Peer == "" && src.Partition == "" {
+					return src.Action != api.IntentionActionDeny
+				}
+			}
+		}
+	}
+	return r.DefaultAllow
+}
+
+// intentionSource returns the service of the source of the intentions. A source without
+// a namespace is in the namespace of the destination.
+func (r *NetworkPolicyController) intentionSource(entry *api.ServiceIntentionsConfigEntry, src *api.SourceIntention) consulService {
+	namespace := src.Namespace
+	if namespace == "" {
+		namespace = entry.Namespace
+	}
+	return consulService{Namespace: r.normalizeNamespace(namespace), Name: src.Name}
+}
+
+// anySource matches every source service.
+func (r *NetworkPolicyController) anySource() consulService {
+	if !r.EnableConsulNamespaces {
+		return consulService{Name: wildcard}
+	}
+	return consulService{Namespace: wildcard, Name: wildcard}
+}
+
+// consulNamespace returns the Consul namespace of the services in the Kubernetes namespace.
+func (r *NetworkPolicyController) consulNamespace(namespace string) string {
+	return r.normalizeNamespace(namespaces.ConsulNamespace(namespace, r.EnableConsulNamespaces, r.ConsulDestinationNamespace, r.EnableNSMirroring, r.NSMirroringPrefix, r.NSMirroringRules))
+}
+
+// normalizeNamespace returns the Consul namespace, or the default namespace if it is empty,
+// if Consul namespaces are enabled and an empty namespace otherwise.
+func (r *NetworkPolicyController) normalizeNamespace(namespace string) string {
+	if !r.EnableConsulNamespaces {
+		return ""
+	}
+	if namespace == "" {
+		return "default"
+	}
+	return namespace
+}
+
+// sourcePeers returns the peers that may connect to the public listener of a service:
+// every injected pod if intentions allow any source, otherwise the injected pods of the
+// allowed services, and the gateways in both cases.
+func (r *NetworkPolicyController) sourcePeers(allowAll bool, sources []consulService, services []corev1.Service) []networkingv1.NetworkPolicyPeer {
+	var peers []networkingv1.NetworkPolicyPeer
+	if allowAll {
+		peers = append(peers, r.meshPeer())
+	} else {
+		allowed := make(map[consulService]bool)
+		for _, source := range sources {
+			allowed[source] = true
+		}
+		sort.Slice(services, func(i, j int) bool {
+			if services[i].Namespace != services[j].Namespace {
+				return services[i].Namespace < services[j].Namespace
+			}
+			return services[i].Name < services[j].Name
+		})
+		for _, svc := range services {
+			namespace := r.consulNamespace(svc.Namespace)
+			if !allowed[consulService{Namespace: namespace, Name: svc.Name}] && !allowed[consulService{Namespace: namespace, Name: wildcard}] {
+				continue
+			}
+			if len(svc.Spec.Selector) == 0 {
+				continue
+			}
+			podLabels := map[string]string{keyInjectStatus: injected}
+			for k, v := range svc.Spec.Selector {
+				podLabels[k] = v
+			}
+			peers = append(peers, networkingv1.NetworkPolicyPeer{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{labelNamespaceName: svc.Namespace}},
+				PodSelector:       &metav1.LabelSelector{MatchLabels: podLabels},
+			})
+		}
+	}
+	return append(peers, r.gatewayPeer())
+}
+
+// networkPolicy returns the NetworkPolicy of the service.
+func (r *NetworkPolicyController) networkPolicy(svc corev1.Service, ports []int, peers []networkingv1.NetworkPolicyPeer) *networkingv1.NetworkPolicy {
+	podLabels := map[string]string{keyInjectStatus: injected}
+	for k, v := range svc.Spec.Selector {
+		podLabels[k] = v
+	}
+	var ingressPorts []networkingv1.NetworkPolicyPort
+	for _, port := range ports {
+		ingressPorts = append(ingressPorts, policyPort(corev1.ProtocolTCP, port))
+	}
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      networkPolicyNamePrefix + svc.Name,
+			Namespace: svc.Namespace,
+			Labels:    map[string]string{keyManagedBy: networkPolicyManagedByValue},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: podLabels},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{Ports: ingressPorts, From: peers},
+			},
+			Egress: []networkingv1.NetworkPolicyEgressRule{
+				// Consul servers and clients.
+				{To: []networkingv1.NetworkPolicyPeer{{
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{labelNamespaceName: r.ReleaseNamespace}},
+					PodSelector:       r.releaseSelector("server", "client"),
+				}}},
+				// DNS.
+				{Ports: []networkingv1.NetworkPolicyPort{policyPort(corev1.ProtocolUDP, 53), policyPort(corev1.ProtocolTCP, 53)}},
+				// Upstreams.
+				{To: []networkingv1.NetworkPolicyPeer{r.meshPeer(), r.gatewayPeer()}},
+			},
+		},
+	}
+}
+
+// meshPeer selects every injected pod.
+func (r *NetworkPolicyController) meshPeer() networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: &metav1.LabelSelector{},
+		PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{keyInjectStatus: injected}},
+	}
+}
+
+// gatewayPeer selects the gateways of the installation.
+func (r *NetworkPolicyController) gatewayPeer() networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: &metav1.LabelSelector{},
+		PodSelector:       r.releaseSelector("ingress-gateway", "mesh-gateway", "terminating-gateway"),
+	}
+}
+
+// releaseSelector selects the pods of the installation with one of the components.
+func (r *NetworkPolicyController) releaseSelector(components ...string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{"release": r.ReleaseName},
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "component", Operator: metav1.LabelSelectorOpIn, Values: components},
+		},
+	}
+}
+
+// injectedPodCreatedOrDeleted filters pod events to the creation and deletion of injected
+// pods, which can change the public listener ports of a service.
+func injectedPodCreatedOrDeleted() predicate.Predicate {
+	injectedPod := func(object client.Object) bool {
+		return object.GetLabels()[keyInjectStatus] == injected
+	}
+	return predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return injectedPod(e.Object) },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return injectedPod(e.Object) },
+		UpdateFunc:  func(event.UpdateEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+	}
+}
+
+func policyPort(protocol corev1.Protocol, port int) networkingv1.NetworkPolicyPort {
+	p := intstr.FromInt(port)
+	return networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &p}
+}
+
+func sortedPolicyNames(policies map[string]*networkingv1.NetworkPolicy) []string {
+	var names []string
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// optedIn returns whether NetworkPolicies are generated for the namespace.
+func (r *NetworkPolicyController) optedIn(ns corev1.Namespace) bool {
+	if shouldIgnore(ns.Name, r.DenyK8sNamespacesSet, r.AllowK8sNamespacesSet) {
+		return false
+	}
+	enabled, err := strconv.ParseBool(ns.Labels[labelNetworkPolicy])
+	return err == nil && enabled
+}
+
+func (r *NetworkPolicyController) synced() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.intentionsSynced
+}
+
+// setIntentions replaces the known intentions.
+func (r *NetworkPolicyController) setIntentions(entries []api.ConfigEntry) {
+	intentions := make(map[consulService]*api.ServiceIntentionsConfigEntry)
+	for _, entry := range entries {
+		if ixn, ok := entry.(*api.ServiceIntentionsConfigEntry); ok {
+			intentions[consulService{Namespace: r.normalizeNamespace(ixn.Namespace), Name: ixn.Name}] = ixn
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.intentions = intentions
+	r.intentionsSynced = true
+}
+
+// watchIntentions lists intentions with a blocking query until the context is cancelled
+// and enqueues every namespace that opted in whenever they change.
+func (r *NetworkPolicyController) watchIntentions(ctx context.Context) error {
+	waitTime := r.WaitTime
+	if waitTime <= 0 {
+		waitTime = defaultNetworkPolicyIntentionsWaitTime
+	}
+	retryInterval := r.RetryInterval
+	if retryInterval <= 0 {
+		retryInterval = defaultNetworkPolicyIntentionsRetryInterval
+	}
+	// Intentions of every Consul namespace are listed with the wildcard namespace.
+	consulNamespace := ""
+	if r.EnableConsulNamespaces {
+		consulNamespace = wildcard
+	}
+	var index uint64
+	for {
+		opts := (&api.QueryOptions{Namespace: consulNamespace, WaitIndex: index, WaitTime: waitTime}).WithContext(ctx)
+		entries, meta, err := r.ConsulClient.ConfigEntries().List(api.ServiceIntentions, opts)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			r.Log.Error(err, "failed to list intentions")
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(retryInterval):
+			}
+			continue
+		}
+		if meta.LastIndex == index {
+			continue
+		}
+		// Blocking queries require an index greater than 0.
+		index = meta.LastIndex
+		if index < 1 {
+			index = 1
+		}
+		r.setIntentions(entries)
+		r.enqueueOptedInNamespaces(ctx)
+	}
+}
+
+// enqueueOptedInNamespaces reconciles every namespace that opted in.
+func (r *NetworkPolicyController) enqueueOptedInNamespaces(ctx context.Context) {
+	var namespaces corev1.NamespaceList
+	if err := r.Client.List(ctx, &namespaces); err != nil {
+		r.Log.Error(err, "failed to list namespaces")
+		return
+	}
+	for i := range namespaces.Items {
+		if !r.optedIn(namespaces.Items[i]) {
+			continue
+		}
+		select {
+		case r.events <- event.GenericEvent{Object: &namespaces.Items[i]}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// requestsForOptedInNamespaces enqueues every namespace that opted in. Services in any
+// namespace can be sources of the policies in every namespace.
+func (r *NetworkPolicyController) requestsForOptedInNamespaces(client.Object) []ctrl.Request {
+	var namespaces corev1.NamespaceList
+	if err := r.Client.List(r.Context, &namespaces); err != nil {
+		r.Log.Error(err, "failed to list namespaces")
+		return []ctrl.Request{}
+	}
+	var requests []ctrl.Request
+	for _, ns := range namespaces.Items {
+		if r.optedIn(ns) {
+			requests = append(requests, ctrl.Request{NamespacedName: types.NamespacedName{Name: ns.Name}})
+		}
+	}
+	return requests
+}
+
+// requestForNamespaceOf enqueues the namespace of the object.
+func (r *NetworkPolicyController) requestForNamespaceOf(object client.Object) []ctrl.Request {
+	return []ctrl.Request{{NamespacedName: types.NamespacedName{Name: object.GetNamespace()}}}
+}
+
+func (r *NetworkPolicyController) SetupWithManager(mgr ctrl.Manager) error {
+	r.events = make(chan event.GenericEvent)
+	if err := mgr.Add(manager.RunnableFunc(r.watchIntentions)); err != nil {
+		return err
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("networkpolicy").
+		For(&corev1.Namespace{}).
+		Watches(
+			&source.Kind{Type: &corev1.Service{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForOptedInNamespaces),
+		).
+		Watches(
+			&source.Kind{Type: &corev1.Pod{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestForNamespaceOf),
+			builder.WithPredicates(injectedPodCreatedOrDeleted()),
+		).
+		Watches(
+			&source.Kind{Type: &networkingv1.NetworkPolicy{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestForNamespaceOf),
+			builder.WithPredicates(predicate.NewPredicateFuncs(func(object client.Object) bool {
+				return object.GetLabels()[keyManagedBy] == networkPolicyManagedByValue
+			})),
+		).
+		Watches(
+			&source.Channel{Source: r.events},
+			&handler.EnqueueRequestForObject{},
+		).
+		Complete(r)
+}
@@ -0,0 +1,346 @@
+package connectinject
+
+import (
+	"context"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set"
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestNetworkPolicyController_intentionSources(t *testing.T) {
+	cases := map[string]struct {
+		defaultAllow bool
+		intentions   []api.ConfigEntry
+		expAllowAll  bool
+		expSources   []consulService
+	}{
+		"no intentions, default deny": {
+			defaultAllow: false,
+			expAllowAll:  false,
+		},
+		"no intentions, default allow": {
+			defaultAllow: true,
+			expAllowAll:  true,
+		},
+		"allowed and denied sources": {
+			intentions: []api.ConfigEntry{
+				serviceIntentions("web",
+					sourceIntention("api", api.IntentionActionAllow),
+					sourceIntention("db", api.IntentionActionDeny),
+					&api.SourceIntention{Name: "l7", Permissions: []*api.IntentionPermission{{Action: api.IntentionActionAllow}}},
+				),
+			},
+			expSources: []consulService{{Name: "api"}, {Name: "l7"}},
+		},
+		"wildcard source allows all": {
+			intentions: []api.ConfigEntry{
+				serviceIntentions("web", sourceIntention("db", api.IntentionActionDeny), sourceIntention("*", api.IntentionActionAllow)),
+			},
+			expAllowAll: true,
+		},
+		"wildcard source deny overrides default allow": {
+			defaultAllow: true,
+			intentions: []api.ConfigEntry{
+				serviceIntentions("web", sourceIntention("api", api.IntentionActionAllow), sourceIntention("*", api.IntentionActionDeny)),
+			},
+			expSources: []consulService{{Name: "api"}},
+		},
+		"exact destination takes precedence over wildcard destination": {
+			intentions: []api.ConfigEntry{
+				serviceIntentions("web", sourceIntention("*", api.IntentionActionDeny)),
+				serviceIntentions("*", sourceIntention("api", api.IntentionActionAllow), sourceIntention("*", api.IntentionActionAllow)),
+			},
+			expSources: nil,
+		},
+		"wildcard destination": {
+			intentions: []api.ConfigEntry{
+				serviceIntentions("*", sourceIntention("api", api.IntentionActionAllow)),
+				serviceIntentions("other", sourceIntention("db", api.IntentionActionAllow)),
+			},
+			expSources: []consulService{{Name: "api"}},
+		},
+		"peer and partition sources are ignored": {
+			intentions: []api.ConfigEntry{
+				serviceIntentions("web",
+					&api.SourceIntention{Name: "api", Peer: "cluster-2", Action: api.IntentionActionAllow},
+					&api.SourceIntention{Name: "db", Partition: "other", Action: api.IntentionActionAllow},
+				),
+			},
+			expSources: nil,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &NetworkPolicyController{DefaultAllow: c.defaultAllow}
+			r.setIntentions(c.intentions)
+			allowAll, sources := r.intentionSources(consulService{Name: "web"})
+			require.Equal(t, c.expAllowAll, allowAll)
+			require.Equal(t, c.expSources, sources)
+		})
+	}
+}
+
+func TestNetworkPolicyController_Reconcile(t *testing.T) {
+	optedIn := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: map[string]string{labelNetworkPolicy: "true"}}}
+	other := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}}
+
+	web := createPod("web", "1.2.3.4", true, true)
+	web.Labels["app"] = "web"
+	multiport := createPod("multiport", "1.2.3.5", true, true)
+	multiport.Labels["app"] = "multiport"
+	multiport.Annotations[annotationService] = "multiport-admin,multiport"
+	api1 := createPod("api", "1.2.3.6", true, true)
+	api1.Namespace = "other"
+	api1.Labels["app"] = "api"
+
+	ignored := createService("ignored", "default", map[string]string{"app": "ignored"})
+	ignored.Labels = map[string]string{labelServiceIgnore: "true"}
+	stale := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      networkPolicyNamePrefix + "deleted",
+			Namespace: "default",
+			Labels:    map[string]string{keyManagedBy: networkPolicyManagedByValue},
+		},
+	}
+	unmanaged := &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: networkPolicyNamePrefix + "unmanaged", Namespace: "default"}}
+
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(
+		optedIn, other, web, multiport, api1, ignored, stale, unmanaged,
+		createService("web", "default", map[string]string{"app": "web"}),
+		createService("multiport", "default", map[string]string{"app": "multiport"}),
+		createService("unmanaged", "default", map[string]string{"app": "unmanaged"}),
+		createService("external", "default", nil),
+		createService("api", "other", map[string]string{"app": "api"}),
+	).Build()
+	r := &NetworkPolicyController{
+		Client:                fakeClient,
+		AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:  mapset.NewSetWith(),
+		ReleaseName:           "consul",
+		ReleaseNamespace:      "consul",
+		Log:                   logrtest.TestLogger{T: t},
+		Context:               context.Background(),
+	}
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "default"}}
+
+	// Nothing is done until intentions are known.
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, []string{networkPolicyNamePrefix + "deleted", networkPolicyNamePrefix + "unmanaged"}, policyNames(t, fakeClient, "default"))
+
+	r.setIntentions([]api.ConfigEntry{serviceIntentions("web", sourceIntention("api", api.IntentionActionAllow))})
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, []string{networkPolicyNamePrefix + "multiport", networkPolicyNamePrefix + "unmanaged", networkPolicyNamePrefix + "web"}, policyNames(t, fakeClient, "default"))
+
+	var policy networkingv1.NetworkPolicy
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: networkPolicyNamePrefix + "web", Namespace: "default"}, &policy))
+	require.Equal(t, map[string]string{keyInjectStatus: injected, "app": "web"}, policy.Spec.PodSelector.MatchLabels)
+	require.Len(t, policy.Spec.Ingress, 1)
+	require.Len(t, policy.Spec.Ingress[0].Ports, 1)
+	require.Equal(t, 20000, policy.Spec.Ingress[0].Ports[0].Port.IntValue())
+	require.Equal(t, []networkingv1.NetworkPolicyPeer{
+		{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{labelNamespaceName: "other"}},
+			PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{keyInjectStatus: injected, "app": "api"}},
+		},
+		r.gatewayPeer(),
+	}, policy.Spec.Ingress[0].From)
+	require.Len(t, policy.Spec.Egress, 3)
+	require.Equal(t, []networkingv1.NetworkPolicyPeer{r.meshPeer(), r.gatewayPeer()}, policy.Spec.Egress[2].To)
+
+	// Each service of a multi-port pod has its own proxy.
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: networkPolicyNamePrefix + "multiport", Namespace: "default"}, &policy))
+	require.Equal(t, 20001, policy.Spec.Ingress[0].Ports[0].Port.IntValue())
+	require.Equal(t, []networkingv1.NetworkPolicyPeer{r.gatewayPeer()}, policy.Spec.Ingress[0].From)
+
+	// The policies are updated as intentions change.
+	r.setIntentions([]api.ConfigEntry{serviceIntentions("*", sourceIntention("*", api.IntentionActionAllow))})
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: networkPolicyNamePrefix + "web", Namespace: "default"}, &policy))
+	require.Equal(t, []networkingv1.NetworkPolicyPeer{r.meshPeer(), r.gatewayPeer()}, policy.Spec.Ingress[0].From)
+
+	// The policies are deleted when the namespace opts out.
+	optedIn.Labels[labelNetworkPolicy] = "false"
+	require.NoError(t, fakeClient.Update(ctx, optedIn))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, []string{networkPolicyNamePrefix + "unmanaged"}, policyNames(t, fakeClient, "default"))
+}
+
+func TestNetworkPolicyController_intentionSourcesNamespaces(t *testing.T) {
+	cases := map[string]struct {
+		intentions  []api.ConfigEntry
+		expAllowAll bool
+		expSources  []consulService
+	}{
+		"sources without a namespace are in the namespace of the destination": {
+			intentions: []api.ConfigEntry{
+				namespacedIntentions("team-a", "web", sourceIntention("api", api.IntentionActionAllow)),
+			},
+			expSources: []consulService{{Namespace: "team-a", Name: "api"}},
+		},
+		"sources in other namespaces": {
+			intentions: []api.ConfigEntry{
+				namespacedIntentions("team-a", "web",
+					&api.SourceIntention{Name: "api", Namespace: "team-b", Action: api.IntentionActionAllow},
+					&api.SourceIntention{Name: "db", Namespace: "team-b", Action: api.IntentionActionDeny},
+				),
+			},
+			expSources: []consulService{{Namespace: "team-b", Name: "api"}},
+		},
+		"intentions of the destination in other namespaces are ignored": {
+			intentions: []api.ConfigEntry{
+				namespacedIntentions("team-b", "web", sourceIntention("api", api.IntentionActionAllow)),
+				namespacedIntentions("team-b", "*", sourceIntention("*", api.IntentionActionAllow)),
+			},
+			expSources: nil,
+		},
+		"wildcard source of a namespace": {
+			intentions: []api.ConfigEntry{
+				namespacedIntentions("team-a", "web",
+					&api.SourceIntention{Name: "db", Namespace: "team-b", Action: api.IntentionActionDeny},
+					&api.SourceIntention{Name: "*", Namespace: "team-b", Action: api.IntentionActionAllow},
+				),
+			},
+			expSources: []consulService{{Namespace: "team-b", Name: "*"}},
+		},
+		"wildcard source of every namespace allows all": {
+			intentions: []api.ConfigEntry{
+				namespacedIntentions("team-a", "*", &api.SourceIntention{Name: "*", Namespace: "*", Action: api.IntentionActionAllow}),
+			},
+			expAllowAll: true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &NetworkPolicyController{EnableConsulNamespaces: true}
+			r.setIntentions(c.intentions)
+			allowAll, sources := r.intentionSources(consulService{Namespace: "team-a", Name: "web"})
+			require.Equal(t, c.expAllowAll, allowAll)
+			require.Equal(t, c.expSources, sources)
+		})
+	}
+}
+
+// Services with the same name in different namespaces are different sources and
+// destinations when Consul namespaces mirror Kubernetes namespaces.
+func TestNetworkPolicyController_ReconcileNamespaces(t *testing.T) {
+	var objects []runtime.Object
+	for _, ns := range []string{"team-a", "team-b"} {
+		objects = append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns, Labels: map[string]string{labelNetworkPolicy: "true"}}})
+		for _, name := range []string{"web", "api"} {
+			pod := createPod(name, "1.2.3.4", true, true)
+			pod.Namespace = ns
+			pod.Labels["app"] = name
+			objects = append(objects, pod, createService(name, ns, map[string]string{"app": name}))
+		}
+	}
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(objects...).Build()
+	r := &NetworkPolicyController{
+		Client:                 fakeClient,
+		EnableConsulNamespaces: true,
+		EnableNSMirroring:      true,
+		NSMirroringPrefix:      "k8s-",
+		AllowK8sNamespacesSet:  mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:   mapset.NewSetWith(),
+		ReleaseName:            "consul",
+		ReleaseNamespace:       "consul",
+		Log:                    logrtest.TestLogger{T: t},
+		Context:                context.Background(),
+	}
+	// web in team-a allows api in team-b, and web in team-b allows api in its own namespace.
+	r.setIntentions([]api.ConfigEntry{
+		namespacedIntentions("k8s-team-a", "web", &api.SourceIntention{Name: "api", Namespace: "k8s-team-b", Action: api.IntentionActionAllow}),
+		namespacedIntentions("k8s-team-b", "web", sourceIntention("api", api.IntentionActionAllow)),
+	})
+	ctx := context.Background()
+	for _, ns := range []string{"team-a", "team-b"} {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: ns}})
+		require.NoError(t, err)
+	}
+
+	apiPeer := func(ns string) networkingv1.NetworkPolicyPeer {
+		return networkingv1.NetworkPolicyPeer{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{labelNamespaceName: ns}},
+			PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{keyInjectStatus: injected, "app": "api"}},
+		}
+	}
+	for ns, expFrom := range map[string][]networkingv1.NetworkPolicyPeer{
+		"team-a": {apiPeer("team-b"), r.gatewayPeer()},
+		"team-b": {apiPeer("team-b"), r.gatewayPeer()},
+	} {
+		var policy networkingv1.NetworkPolicy
+		require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: networkPolicyNamePrefix + "web", Namespace: ns}, &policy))
+		require.Equal(t, expFrom, policy.Spec.Ingress[0].From, ns)
+		require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: networkPolicyNamePrefix + "api", Namespace: ns}, &policy))
+		require.Equal(t, []networkingv1.NetworkPolicyPeer{r.gatewayPeer()}, policy.Spec.Ingress[0].From, ns)
+	}
+}
+
+func TestNetworkPolicyController_requestsForOptedInNamespaces(t *testing.T) {
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: map[string]string{labelNetworkPolicy: "true"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "denied", Labels: map[string]string{labelNetworkPolicy: "true"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+	).Build()
+	r := &NetworkPolicyController{
+		Client:                fakeClient,
+		AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:  mapset.NewSetWith("denied"),
+		Log:                   logrtest.TestLogger{T: t},
+		Context:               context.Background(),
+	}
+	require.Equal(t, []ctrl.Request{{NamespacedName: types.NamespacedName{Name: "default"}}},
+		r.requestsForOptedInNamespaces(createService("web", "other", nil)))
+}
+
+func TestInjectedPodCreatedOrDeleted(t *testing.T) {
+	pod := createPod("pod1", "1.2.3.4", true, true)
+	notInjected := createPod("pod1", "1.2.3.4", false, false)
+
+	p := injectedPodCreatedOrDeleted()
+	require.True(t, p.Create(event.CreateEvent{Object: pod}))
+	require.True(t, p.Delete(event.DeleteEvent{Object: pod}))
+	require.False(t, p.Update(event.UpdateEvent{ObjectOld: pod, ObjectNew: pod}))
+	require.False(t, p.Create(event.CreateEvent{Object: notInjected}))
+}
+
+func serviceIntentions(destination string, sources ...*api.SourceIntention) *api.ServiceIntentionsConfigEntry {
+	return &api.ServiceIntentionsConfigEntry{Kind: api.ServiceIntentions, Name: destination, Sources: sources}
+}
+
+func namespacedIntentions(namespace, destination string, sources ...*api.SourceIntention) *api.ServiceIntentionsConfigEntry {
+	entry := serviceIntentions(destination, sources...)
+	entry.Namespace = namespace
+	return entry
+}
+
+func sourceIntention(name string, action api.IntentionAction) *api.SourceIntention {
+	return &api.SourceIntention{Name: name, Action: action}
+}
+
+func policyNames(t *testing.T, c client.Client, namespace string) []string {
+	t.Helper()
+	var policies networkingv1.NetworkPolicyList
+	require.NoError(t, c.List(context.Background(), &policies, client.InNamespace(namespace)))
+	var names []string
+	for _, policy := range policies.Items {
+		names = append(names, policy.Name)
+	}
+	return names
+}
@@ -0,0 +1,120 @@
+package connectinject
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/hashicorp/consul/api"
+	"gomodules.xyz/jsonpatch/v2"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// DefaultNodeProxyInboundPort is the port the node proxy accepts mesh traffic for the pods on its
+	// node on.
+	DefaultNodeProxyInboundPort = 20100
+
+	// DefaultNodeProxyOutboundPort is the port the node proxy accepts the redirected outbound traffic
+	// of the pods on its node on.
+	DefaultNodeProxyOutboundPort = 15101
+
+	// MetaKeyNodeProxy is the meta key of proxy service instances that are served by a node proxy. Its
+	// value is the name of the node.
+	MetaKeyNodeProxy = "node-proxy"
+)
+
+// nodeProxyEnabled returns true if the pod opted into node proxy mode with the node-proxy annotation.
+// It returns an error if the annotation cannot be parsed by strconv.ParseBool, or if the pod opted in
+// but node proxy mode is not enabled.
+func nodeProxyEnabled(pod corev1.Pod, enabled bool) (bool, error) {
+	raw, ok := pod.Annotations[AnnotationNodeProxy]
+	if !ok {
+		return false, nil
+	}
+	nodeProxy, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("%s annotation value of %q is invalid: %s", AnnotationNodeProxy, raw, err)
+	}
+	if nodeProxy && !enabled {
+		return false, fmt.Errorf("%s annotation is set but node proxy mode is not enabled", AnnotationNodeProxy)
+	}
+	return nodeProxy, nil
+}
+
+// usesNodeProxy returns true if the pod was injected in node proxy mode.
+func usesNodeProxy(pod corev1.Pod) bool {
+	return pod.Labels[LabelNodeProxy] == "true"
+}
+
+// handleNodeProxy handles pods in node proxy mode. They don't get any containers injected. Instead,
+// they are labeled so that the node helper redirects their outbound traffic to the node proxy on
+// their node, and the endpoints controller registers the node proxy as their proxy.
+func (h *Handler) handleNodeProxy(pod corev1.Pod, origPodJson []byte, req admission.Request) admission.Response {
+	if len(h.annotatedServiceNames(pod)) > 1 {
+		err := fmt.Errorf("node proxy mode is not supported for multi port pods")
+		h.Log.Error(err, "error handling node proxy pod", "request name", req.Name)
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// pod.Annotations has already been initialized by h.defaultAnnotations()
+	// and does not need to be checked for being a nil value.
+	pod.Annotations[keyInjectStatus] = injected
+	if pod.Labels == nil {
+		pod.Labels = make(map[string]string)
+	}
+	pod.Labels[keyInjectStatus] = injected
+	pod.Labels[keyManagedBy] = managedByValue
+	pod.Labels[LabelNodeProxy] = "true"
+
+	// Consul-ENT only: Add the Consul destination namespace as an annotation to the pod.
+	if h.EnableNamespaces {
+		pod.Annotations[annotationConsulNamespace] = h.consulNamespace(req.Namespace)
+	}
+
+	updatedPodJson, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	patches, err := jsonpatch.CreatePatch(origPodJson, updatedPodJson)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if h.EnableNamespaces {
+		if err := h.ensureNamespace(h.consulNamespace(req.Namespace)); err != nil {
+			h.Log.Error(err, "error checking or creating namespace",
+				"ns", h.consulNamespace(req.Namespace), "request name", req.Name)
+			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error checking or creating namespace: %s", err))
+		}
+	}
+	return admission.Patched(fmt.Sprintf("valid %s request", pod.Kind), patches...)
+}
+
+// useNodeProxy points the proxy service instance of a pod in node proxy mode at the node proxy on
+// the node of the pod, which forwards the traffic to the pod IP.
+func (r *EndpointsController) useNodeProxy(pod corev1.Pod, proxyService *api.AgentServiceRegistration) {
+	port := r.NodeProxyInboundPort
+	if port == 0 {
+		port = DefaultNodeProxyInboundPort
+	}
+
+	// The meta is shared with the service instance.
+	meta := make(map[string]string, len(proxyService.Meta)+1)
+	for k, v := range proxyService.Meta {
+		meta[k] = v
+	}
+	meta[MetaKeyNodeProxy] = pod.Spec.NodeName
+
+	proxyService.Address = pod.Status.HostIP
+	proxyService.Port = port
+	proxyService.Meta = meta
+	proxyService.Proxy.LocalServiceAddress = pod.Status.PodIP
+	for _, check := range proxyService.Checks {
+		if check.TCP != "" {
+			check.TCP = fmt.Sprintf("%s:%d", pod.Status.HostIP, port)
+		}
+	}
+}
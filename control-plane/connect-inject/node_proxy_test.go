@@ -0,0 +1,193 @@
+package connectinject
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set"
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestNodeProxyEnabled(t *testing.T) {
+	cases := map[string]struct {
+		annotations map[string]string
+		enabled     bool
+		exp         bool
+		expErr      string
+	}{
+		"no annotation": {
+			enabled: true,
+		},
+		"opted in": {
+			annotations: map[string]string{AnnotationNodeProxy: "true"},
+			enabled:     true,
+			exp:         true,
+		},
+		"opted out": {
+			annotations: map[string]string{AnnotationNodeProxy: "false"},
+		},
+		"opted in but not enabled": {
+			annotations: map[string]string{AnnotationNodeProxy: "true"},
+			expErr:      "consul.hashicorp.com/node-proxy annotation is set but node proxy mode is not enabled",
+		},
+		"invalid": {
+			annotations: map[string]string{AnnotationNodeProxy: "yes"},
+			enabled:     true,
+			expErr:      `consul.hashicorp.com/node-proxy annotation value of "yes" is invalid: strconv.ParseBool: parsing "yes": invalid syntax`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: c.annotations}}
+			actual, err := nodeProxyEnabled(pod, c.enabled)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, actual)
+		})
+	}
+}
+
+func TestHandlerHandle_NodeProxy(t *testing.T) {
+	s := runtime.NewScheme()
+	s.AddKnownTypes(schema.GroupVersion{
+		Group:   "",
+		Version: "v1",
+	}, &corev1.Pod{})
+	decoder, err := admission.NewDecoder(s)
+	require.NoError(t, err)
+
+	cases := map[string]struct {
+		enabled  bool
+		services string
+		expErr   string
+	}{
+		"enabled": {
+			enabled: true,
+		},
+		"not enabled": {
+			expErr: "node proxy mode is not enabled",
+		},
+		"multi port pod": {
+			enabled:  true,
+			services: "web,web-admin",
+			expErr:   "node proxy mode is not supported for multi port pods",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := Handler{
+				Log:                   logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				EnableNodeProxy:       c.enabled,
+				decoder:               decoder,
+				Clientset:             defaultTestClientWithNamespace(),
+			}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:   "default",
+					Annotations: map[string]string{AnnotationNodeProxy: "true"},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "web"}},
+				},
+			}
+			if c.services != "" {
+				pod.Annotations[annotationService] = c.services
+			}
+			resp := h.Handle(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: "default",
+					Object:    encodeRaw(t, pod),
+				},
+			})
+			if c.expErr != "" {
+				require.False(t, resp.Allowed)
+				require.Contains(t, resp.Result.Message, c.expErr)
+				return
+			}
+			require.True(t, resp.Allowed)
+
+			// No containers are injected, the pod is only labeled.
+			var labels map[string]string
+			for _, patch := range resp.Patches {
+				require.NotContains(t, patch.Path, "/spec/")
+				if patch.Path == "/metadata/labels" {
+					raw, err := json.Marshal(patch.Value)
+					require.NoError(t, err)
+					require.NoError(t, json.Unmarshal(raw, &labels))
+				}
+			}
+			require.Equal(t, map[string]string{
+				keyInjectStatus: injected,
+				keyManagedBy:    managedByValue,
+				LabelNodeProxy:  "true",
+			}, labels)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_nodeProxy(t *testing.T) {
+	pod := createPod("test-pod-1", "1.2.3.4", true, true)
+	pod.Labels[LabelNodeProxy] = "true"
+	pod.Spec.NodeName = "node-1"
+	pod.Status.HostIP = "10.0.0.5"
+	pod.Annotations[annotationPort] = "8080"
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-service",
+			Namespace: "default",
+		},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{
+					{
+						IP: "1.2.3.4",
+						TargetRef: &corev1.ObjectReference{
+							Kind:      "Pod",
+							Name:      pod.Name,
+							Namespace: pod.Namespace,
+						},
+					},
+				},
+			},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints).Build()
+
+	epCtrl := EndpointsController{
+		Client:                 fakeClient,
+		EnableTransparentProxy: true,
+		Log:                    logrtest.TestLogger{T: t},
+	}
+
+	service, proxyService, err := epCtrl.createServiceRegistrations(*pod, *endpoints)
+	require.NoError(t, err)
+
+	require.Equal(t, "1.2.3.4", service.Address)
+	require.Equal(t, "10.0.0.5", proxyService.Address)
+	require.Equal(t, DefaultNodeProxyInboundPort, proxyService.Port)
+	require.Equal(t, "1.2.3.4", proxyService.Proxy.LocalServiceAddress)
+	require.Equal(t, 8080, proxyService.Proxy.LocalServicePort)
+	require.Equal(t, "node-1", proxyService.Meta[MetaKeyNodeProxy])
+	require.NotContains(t, service.Meta, MetaKeyNodeProxy)
+	// Pods in node proxy mode don't have their own proxy to redirect traffic to.
+	require.Empty(t, proxyService.Proxy.Mode)
+	for _, check := range proxyService.Checks {
+		if check.TCP != "" {
+			require.Equal(t, "10.0.0.5:20100", check.TCP)
+		}
+	}
+}
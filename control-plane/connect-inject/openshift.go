@@ -0,0 +1,121 @@
+package connectinject
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// annotationOpenShiftUIDRange is set by OpenShift on every namespace to the range of UIDs that the
+	// restricted-v2 SecurityContextConstraints allow the pods in the namespace to run as, e.g.
+	// "1000620000/10000" for the 10000 UIDs starting at 1000620000.
+	annotationOpenShiftUIDRange = "openshift.io/sa.scc.uid-range"
+
+	// annotationOpenShiftSupplementalGroups is set by OpenShift on every namespace to the ranges of the
+	// group IDs that the pods in the namespace may use, in the same format. The first group is the
+	// fsGroup that the restricted-v2 SecurityContextConstraints default pods to.
+	annotationOpenShiftSupplementalGroups = "openshift.io/sa.scc.supplemental-groups"
+)
+
+// openShiftIDRange returns the first and last ID of the first range of the OpenShift namespace
+// annotation. The annotation is a comma separated list of ranges formatted as "<first>/<size>".
+func openShiftIDRange(namespace corev1.Namespace, annotation string) (int64, int64, error) {
+	raw, ok := namespace.Annotations[annotation]
+	if !ok {
+		return 0, 0, fmt.Errorf("namespace %s does not have the %s annotation", namespace.Name, annotation)
+	}
+	block := strings.Split(raw, ",")[0]
+	parts := strings.Split(block, "/")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid %s annotation %q of namespace %s", annotation, raw, namespace.Name)
+	}
+	first, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid %s annotation %q of namespace %s: %s", annotation, raw, namespace.Name, err)
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
+	if err != nil || size < 1 {
+		return 0, 0, fmt.Errorf("invalid %s annotation %q of namespace %s: invalid size", annotation, raw, namespace.Name)
+	}
+	return first, first + size - 1, nil
+}
+
+// envoyUserAndGroup returns the UID and GID that Envoy runs as, which the traffic redirection rules
+// exclude from redirection. Outside of OpenShift, Envoy runs as envoyUserAndGroupID. On OpenShift,
+// the restricted-v2 SecurityContextConstraints only allow the UIDs of the namespace's range, so
+// Envoy runs as the highest UID of the range that the pod's containers don't run as, and in the
+// first supplemental group of the namespace.
+func (h *Handler) envoyUserAndGroup(namespace corev1.Namespace, pod corev1.Pod) (int64, int64, error) {
+	if !h.EnableOpenShift {
+		return envoyUserAndGroupID, envoyUserAndGroupID, nil
+	}
+
+	first, last, err := openShiftIDRange(namespace, annotationOpenShiftUIDRange)
+	if err != nil {
+		return 0, 0, err
+	}
+	group, _, err := openShiftIDRange(namespace, annotationOpenShiftSupplementalGroups)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	used := make(map[int64]bool)
+	if pod.Spec.SecurityContext != nil && pod.Spec.SecurityContext.RunAsUser != nil {
+		used[*pod.Spec.SecurityContext.RunAsUser] = true
+	}
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, c := range containers {
+			// Skip the Envoy sidecars of multi port pods that are already injected.
+			if c.Image == h.ImageEnvoy {
+				continue
+			}
+			if c.SecurityContext != nil && c.SecurityContext.RunAsUser != nil {
+				used[*c.SecurityContext.RunAsUser] = true
+			}
+		}
+	}
+	for uid := last; uid >= first; uid-- {
+		if !used[uid] {
+			return uid, group, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("all UIDs of the %s annotation of namespace %s are used by the containers of the pod",
+		annotationOpenShiftUIDRange, namespace.Name)
+}
+
+// restrictedSecurityContext returns the security context that the restricted-v2
+// SecurityContextConstraints of OpenShift require. The user isn't set so that OpenShift assigns
+// one from the range of the namespace.
+func restrictedSecurityContext() *corev1.SecurityContext {
+	return &corev1.SecurityContext{
+		RunAsNonRoot:             pointerToBool(true),
+		AllowPrivilegeEscalation: pointerToBool(false),
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
+}
+
+// applyOpenShiftFSGroup sets the fsGroup of the pod to the first supplemental group of the
+// namespace, which Envoy runs in, unless the pod sets one. The files the init containers write to
+// the shared volume are then owned by Envoy's group.
+func applyOpenShiftFSGroup(namespace corev1.Namespace, pod *corev1.Pod) error {
+	if pod.Spec.SecurityContext != nil && pod.Spec.SecurityContext.FSGroup != nil {
+		return nil
+	}
+	group, _, err := openShiftIDRange(namespace, annotationOpenShiftSupplementalGroups)
+	if err != nil {
+		return err
+	}
+	if pod.Spec.SecurityContext == nil {
+		pod.Spec.SecurityContext = &corev1.PodSecurityContext{}
+	}
+	pod.Spec.SecurityContext.FSGroup = pointerToInt64(group)
+	return nil
+}
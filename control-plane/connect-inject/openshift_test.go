@@ -0,0 +1,228 @@
+package connectinject
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// openShiftTestNS is a namespace with the annotations that OpenShift sets on every namespace.
+var openShiftTestNS = corev1.Namespace{
+	ObjectMeta: metav1.ObjectMeta{
+		Name: k8sNamespace,
+		Annotations: map[string]string{
+			annotationOpenShiftUIDRange:           "1000620000/10000",
+			annotationOpenShiftSupplementalGroups: "1000620000/10000",
+		},
+	},
+}
+
+func TestOpenShiftIDRange(t *testing.T) {
+	cases := map[string]struct {
+		annotations map[string]string
+		expFirst    int64
+		expLast     int64
+		expErr      string
+	}{
+		"single range": {
+			annotations: map[string]string{annotationOpenShiftUIDRange: "1000620000/10000"},
+			expFirst:    1000620000,
+			expLast:     1000629999,
+		},
+		"multiple ranges": {
+			annotations: map[string]string{annotationOpenShiftUIDRange: "1000620000/10000,1000700000/10000"},
+			expFirst:    1000620000,
+			expLast:     1000629999,
+		},
+		"missing annotation": {
+			expErr: "namespace k8snamespace does not have the openshift.io/sa.scc.uid-range annotation",
+		},
+		"missing size": {
+			annotations: map[string]string{annotationOpenShiftUIDRange: "1000620000"},
+			expErr:      `invalid openshift.io/sa.scc.uid-range annotation "1000620000" of namespace k8snamespace`,
+		},
+		"invalid first": {
+			annotations: map[string]string{annotationOpenShiftUIDRange: "foo/10000"},
+			expErr:      `invalid openshift.io/sa.scc.uid-range annotation "foo/10000" of namespace k8snamespace`,
+		},
+		"zero size": {
+			annotations: map[string]string{annotationOpenShiftUIDRange: "1000620000/0"},
+			expErr:      "invalid size",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: k8sNamespace, Annotations: c.annotations}}
+			first, last, err := openShiftIDRange(ns, annotationOpenShiftUIDRange)
+			if c.expErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expFirst, first)
+			require.Equal(t, c.expLast, last)
+		})
+	}
+}
+
+func TestHandlerEnvoyUserAndGroup(t *testing.T) {
+	container := func(image string, uid int64) corev1.Container {
+		return corev1.Container{
+			Image:           image,
+			SecurityContext: &corev1.SecurityContext{RunAsUser: pointerToInt64(uid)},
+		}
+	}
+
+	cases := map[string]struct {
+		openShift bool
+		namespace corev1.Namespace
+		pod       corev1.PodSpec
+		expUID    int64
+		expGID    int64
+		expErr    string
+	}{
+		"not on OpenShift": {
+			namespace: testNS,
+			expUID:    envoyUserAndGroupID,
+			expGID:    envoyUserAndGroupID,
+		},
+		"OpenShift": {
+			openShift: true,
+			namespace: openShiftTestNS,
+			expUID:    1000629999,
+			expGID:    1000620000,
+		},
+		"OpenShift with containers that use the highest UIDs": {
+			openShift: true,
+			namespace: openShiftTestNS,
+			pod: corev1.PodSpec{
+				SecurityContext: &corev1.PodSecurityContext{RunAsUser: pointerToInt64(1000629999)},
+				InitContainers:  []corev1.Container{container("init", 1000629998)},
+				Containers:      []corev1.Container{container("app", 1000629997)},
+			},
+			expUID: 1000629996,
+			expGID: 1000620000,
+		},
+		"OpenShift with the Envoy sidecar of another service": {
+			openShift: true,
+			namespace: openShiftTestNS,
+			pod: corev1.PodSpec{
+				Containers: []corev1.Container{container("envoy", 1000629999)},
+			},
+			expUID: 1000629999,
+			expGID: 1000620000,
+		},
+		"OpenShift without the annotations": {
+			openShift: true,
+			namespace: testNS,
+			expErr:    "namespace k8snamespace does not have the openshift.io/sa.scc.uid-range annotation",
+		},
+		"OpenShift with all UIDs used": {
+			openShift: true,
+			namespace: corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: k8sNamespace,
+					Annotations: map[string]string{
+						annotationOpenShiftUIDRange:           "1000620000/1",
+						annotationOpenShiftSupplementalGroups: "1000620000/1",
+					},
+				},
+			},
+			pod: corev1.PodSpec{
+				Containers: []corev1.Container{container("app", 1000620000)},
+			},
+			expErr: "all UIDs of the openshift.io/sa.scc.uid-range annotation of namespace k8snamespace are used",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := Handler{EnableOpenShift: c.openShift, ImageEnvoy: "envoy"}
+			uid, gid, err := h.envoyUserAndGroup(c.namespace, corev1.Pod{Spec: c.pod})
+			if c.expErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expUID, uid)
+			require.Equal(t, c.expGID, gid)
+		})
+	}
+}
+
+func TestApplyOpenShiftFSGroup(t *testing.T) {
+	t.Run("sets the first supplemental group", func(t *testing.T) {
+		pod := corev1.Pod{}
+		require.NoError(t, applyOpenShiftFSGroup(openShiftTestNS, &pod))
+		require.Equal(t, pointerToInt64(1000620000), pod.Spec.SecurityContext.FSGroup)
+	})
+
+	t.Run("keeps the fsGroup of the pod", func(t *testing.T) {
+		pod := corev1.Pod{
+			Spec: corev1.PodSpec{
+				SecurityContext: &corev1.PodSecurityContext{FSGroup: pointerToInt64(1000620005)},
+			},
+		}
+		require.NoError(t, applyOpenShiftFSGroup(testNS, &pod))
+		require.Equal(t, pointerToInt64(1000620005), pod.Spec.SecurityContext.FSGroup)
+	})
+
+	t.Run("errors without the annotation", func(t *testing.T) {
+		pod := corev1.Pod{}
+		err := applyOpenShiftFSGroup(testNS, &pod)
+		require.EqualError(t, err, "namespace k8snamespace does not have the openshift.io/sa.scc.supplemental-groups annotation")
+	})
+}
+
+func TestHandlerContainerInit_openShift(t *testing.T) {
+	cases := map[string]struct {
+		handler Handler
+		expCmd  string
+		expSC   *corev1.SecurityContext
+	}{
+		"without transparent proxy": {
+			handler: Handler{EnableOpenShift: true},
+			expSC:   restrictedSecurityContext(),
+		},
+		"transparent proxy with the node helper": {
+			handler: Handler{EnableOpenShift: true, EnableTransparentProxy: true, EnableTProxyNodeHelper: true},
+			expCmd: `consul-k8s-control-plane redirect-traffic \
+  -proxy-uid=1000629999`,
+			expSC: restrictedSecurityContext(),
+		},
+		"transparent proxy without the node helper": {
+			handler: Handler{EnableOpenShift: true, EnableTransparentProxy: true},
+			expCmd: `/consul/connect-inject/consul connect redirect-traffic \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -proxy-uid=1000629999`,
+			expSC: tproxyInitSecurityContext(TProxyInitModePrivileged),
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotationService: "foo",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "web",
+						},
+					},
+				},
+			}
+
+			container, err := c.handler.containerInit(openShiftTestNS, pod, multiPortInfo{})
+			require.NoError(t, err)
+			require.Contains(t, strings.Join(container.Command, " "), c.expCmd)
+			require.Equal(t, c.expSC, container.SecurityContext)
+		})
+	}
+}
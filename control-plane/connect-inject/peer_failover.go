@@ -0,0 +1,123 @@
+package connectinject
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// peerFailoverResyncPeriod is how often the Endpoints of services with peer failover are
+	// reconciled again, since changes to the peerings don't trigger a reconcile.
+	peerFailoverResyncPeriod = 1 * time.Minute
+
+	// peerFailoverKey is the key of the failover stanza for all subsets of a service resolver.
+	peerFailoverKey = "*"
+)
+
+// peerFailoverEnabled returns true if a service resolver with failover targets for the peers that
+// import the service of the pod should be written. It returns an error when the annotation value
+// cannot be parsed by strconv.ParseBool.
+func peerFailoverEnabled(pod corev1.Pod, globalEnabled bool) (bool, error) {
+	if raw, ok := pod.Annotations[annotationPeerFailover]; ok {
+		return strconv.ParseBool(raw)
+	}
+
+	return globalEnabled, nil
+}
+
+// peerFailoverTargets returns a failover target for each active peering that imports the
+// service, sorted by peer name. Imported services are listed either by name or as
+// "<namespace>/<name>", so only the last path component is compared.
+func peerFailoverTargets(peerings []*api.Peering, service string) []api.ServiceResolverFailoverTarget {
+	var targets []api.ServiceResolverFailoverTarget
+	for _, p := range peerings {
+		if p == nil || p.State != api.PeeringStateActive {
+			continue
+		}
+		for _, imported := range p.StreamStatus.ImportedServices {
+			if path.Base(imported) == service {
+				targets = append(targets, api.ServiceResolverFailoverTarget{Peer: p.Name})
+				break
+			}
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Peer < targets[j].Peer })
+	return targets
+}
+
+// upsertPeerFailover sets the failover of the service resolver of a service to the peers that
+// import it, so that traffic fails over to their instances when no local instance is healthy.
+// The failover is removed again once no active peering imports the service.
+//
+// Service resolvers that weren't written by the endpoints controller, e.g. by a ServiceResolver
+// resource, aren't changed.
+func (r *EndpointsController) upsertPeerFailover(ctx context.Context, name, namespace string) error {
+	peerings, _, err := r.ConsulClient.Peerings().List(ctx, &api.QueryOptions{Namespace: namespace})
+	if err != nil {
+		return fmt.Errorf("listing peerings: %w", err)
+	}
+	targets := peerFailoverTargets(peerings, name)
+
+	opts := &api.QueryOptions{Namespace: namespace}
+	entry, _, err := r.ConsulClient.ConfigEntries().Get(api.ServiceResolver, name, opts)
+	if err != nil && !strings.Contains(err.Error(), "Unexpected response code: 404") {
+		return fmt.Errorf("getting service resolver %q: %w", name, err)
+	}
+
+	desired := &api.ServiceResolverConfigEntry{
+		Kind:      api.ServiceResolver,
+		Name:      name,
+		Namespace: namespace,
+		Meta:      map[string]string{MetaKeyManagedBy: managedByValue},
+	}
+	if entry != nil {
+		existing, ok := entry.(*api.ServiceResolverConfigEntry)
+		if !ok || existing.Meta[MetaKeyManagedBy] != managedByValue {
+			r.Log.Info("not adding peer failover to service resolver that isn't managed by consul-k8s", "name", name)
+			return nil
+		}
+		if reflect.DeepEqual(existing.Failover[peerFailoverKey].Targets, targets) {
+			return nil
+		}
+		desired.DefaultSubset = existing.DefaultSubset
+		desired.Subsets = existing.Subsets
+		desired.Failover = make(map[string]api.ServiceResolverFailover, len(existing.Failover))
+		for subset, failover := range existing.Failover {
+			desired.Failover[subset] = failover
+		}
+	} else if len(targets) == 0 {
+		return nil
+	}
+
+	if len(targets) > 0 {
+		if desired.Failover == nil {
+			desired.Failover = make(map[string]api.ServiceResolverFailover, 1)
+		}
+		desired.Failover[peerFailoverKey] = api.ServiceResolverFailover{Targets: targets}
+	} else {
+		delete(desired.Failover, peerFailoverKey)
+	}
+
+	r.Log.Info("writing service resolver with peer failover", "name", name, "targets", len(targets))
+	if _, _, err := r.ConsulClient.ConfigEntries().Set(desired, &api.WriteOptions{Namespace: namespace}); err != nil {
+		return fmt.Errorf("writing service resolver %q: %w", name, err)
+	}
+	r.Audit.Record(nil, consul.AuditWrite{
+		Operation: consul.AuditOpWrite,
+		Kind:      api.ServiceResolver,
+		Name:      name,
+		Namespace: namespace,
+		Payload:   desired,
+	})
+	return nil
+}
@@ -0,0 +1,182 @@
+package connectinject
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPeerFailoverEnabled(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		annotations   map[string]string
+		globalEnabled bool
+		expEnabled    bool
+		expErr        bool
+	}{
+		"disabled by default": {
+			expEnabled: false,
+		},
+		"enabled globally": {
+			globalEnabled: true,
+			expEnabled:    true,
+		},
+		"enabled via annotation": {
+			annotations: map[string]string{annotationPeerFailover: "true"},
+			expEnabled:  true,
+		},
+		"annotation takes precedence": {
+			annotations:   map[string]string{annotationPeerFailover: "false"},
+			globalEnabled: true,
+			expEnabled:    false,
+		},
+		"invalid annotation": {
+			annotations: map[string]string{annotationPeerFailover: "maybe"},
+			expErr:      true,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: c.annotations}}
+			enabled, err := peerFailoverEnabled(pod, c.globalEnabled)
+			if c.expErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expEnabled, enabled)
+		})
+	}
+}
+
+func TestPeerFailoverTargets(t *testing.T) {
+	t.Parallel()
+
+	peering := func(name string, state api.PeeringState, imported ...string) *api.Peering {
+		return &api.Peering{
+			Name:         name,
+			State:        state,
+			StreamStatus: api.PeeringStreamStatus{ImportedServices: imported},
+		}
+	}
+
+	cases := map[string]struct {
+		peerings   []*api.Peering
+		expTargets []api.ServiceResolverFailoverTarget
+	}{
+		"no peerings": {},
+		"peering that doesn't import the service": {
+			peerings: []*api.Peering{peering("east", api.PeeringStateActive, "api")},
+		},
+		"peering that isn't active": {
+			peerings: []*api.Peering{
+				peering("east", api.PeeringStateFailing, "web"),
+				peering("north", api.PeeringStatePending, "web"),
+			},
+		},
+		"active peerings that import the service, sorted by peer": {
+			peerings: []*api.Peering{
+				peering("west", api.PeeringStateActive, "web"),
+				peering("east", api.PeeringStateActive, "api", "web"),
+			},
+			expTargets: []api.ServiceResolverFailoverTarget{{Peer: "east"}, {Peer: "west"}},
+		},
+		"service imported into a namespace": {
+			peerings:   []*api.Peering{peering("east", api.PeeringStateActive, "frontend/web")},
+			expTargets: []api.ServiceResolverFailoverTarget{{Peer: "east"}},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, c.expTargets, peerFailoverTargets(c.peerings, "web"))
+		})
+	}
+}
+
+func TestUpsertPeerFailover(t *testing.T) {
+	t.Parallel()
+
+	peerFailover := map[string]api.ServiceResolverFailover{
+		peerFailoverKey: {Targets: []api.ServiceResolverFailoverTarget{{Peer: "east"}}},
+	}
+
+	cases := map[string]struct {
+		existing    *api.ServiceResolverConfigEntry
+		expResolver bool
+		expFailover map[string]api.ServiceResolverFailover
+	}{
+		"no service resolver and no peers": {
+			expResolver: false,
+		},
+		"peer failover removed once no peer imports the service": {
+			existing: &api.ServiceResolverConfigEntry{
+				Kind:          api.ServiceResolver,
+				Name:          "web",
+				DefaultSubset: RolloutRoleStable,
+				Subsets: map[string]api.ServiceResolverSubset{
+					RolloutRoleStable: {Filter: `Service.Meta["rollout-role"] == "stable"`},
+				},
+				Failover: peerFailover,
+				Meta:     map[string]string{MetaKeyManagedBy: managedByValue},
+			},
+			expResolver: true,
+		},
+		"service resolver not managed by the endpoints controller": {
+			existing: &api.ServiceResolverConfigEntry{
+				Kind:     api.ServiceResolver,
+				Name:     "web",
+				Failover: peerFailover,
+			},
+			expResolver: true,
+			expFailover: peerFailover,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			consul, err := testutil.NewTestServerConfigT(t, nil)
+			require.NoError(t, err)
+			defer consul.Stop()
+			consul.WaitForServiceIntentions(t)
+
+			consulClient, err := api.NewClient(&api.Config{Address: consul.HTTPAddr})
+			require.NoError(t, err)
+			if c.existing != nil {
+				_, _, err = consulClient.ConfigEntries().Set(c.existing, nil)
+				require.NoError(t, err)
+			}
+
+			r := &EndpointsController{
+				ConsulClient: consulClient,
+				Log:          logrtest.TestLogger{T: t},
+			}
+			require.NoError(t, r.upsertPeerFailover(context.Background(), "web", ""))
+
+			entry, _, err := consulClient.ConfigEntries().Get(api.ServiceResolver, "web", nil)
+			if !c.expResolver {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			resolver := entry.(*api.ServiceResolverConfigEntry)
+			require.Equal(t, c.expFailover, resolver.Failover)
+			// The subsets of a managed service resolver are kept.
+			require.Equal(t, c.existing.Subsets, resolver.Subsets)
+		})
+	}
+}
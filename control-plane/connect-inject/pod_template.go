@@ -0,0 +1,59 @@
+package connectinject
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// podTemplateData is the data the templates in the service-tags and
+// service-meta annotations are rendered with, e.g.
+// `version={{ .Labels.version }}` or `{{ index .Labels "app.kubernetes.io/version" }}`
+// for label keys that aren't identifiers.
+type podTemplateData struct {
+	Name        string
+	Namespace   string
+	NodeName    string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// renderPodTemplate renders the value of the annotation as a Go template of
+// the fields of the pod. Missing labels and annotations render as "". The
+// node name is only set once the pod is scheduled, so the templates are
+// rendered by the endpoints controller at registration and only validated by
+// the webhook.
+func renderPodTemplate(pod corev1.Pod, annotation, value string) (string, error) {
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+	tmpl, err := template.New(annotation).Option("missingkey=zero").Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("%s annotation value of %s was invalid: %s", annotation, value, err)
+	}
+	var out strings.Builder
+	err = tmpl.Execute(&out, podTemplateData{
+		Name:        pod.Name,
+		Namespace:   pod.Namespace,
+		NodeName:    pod.Spec.NodeName,
+		Labels:      pod.Labels,
+		Annotations: pod.Annotations,
+	})
+	if err != nil {
+		return "", fmt.Errorf("%s annotation value of %s could not be rendered: %s", annotation, value, err)
+	}
+	return out.String(), nil
+}
+
+// validateServiceTemplates returns an error if a template in the tags or meta
+// annotations of the pod is malformed, so that the pod is rejected at
+// admission rather than failing to register once it is running.
+func validateServiceTemplates(pod corev1.Pod) error {
+	if _, err := consulTags(pod); err != nil {
+		return err
+	}
+	_, err := consulMeta(pod)
+	return err
+}
@@ -0,0 +1,72 @@
+package connectinject
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRenderPodTemplate(t *testing.T) {
+	t.Parallel()
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "web-7d9f6-abcde",
+			Namespace:   "apps",
+			Labels:      map[string]string{"version": "v1.2.3", "app.kubernetes.io/version": "1.2"},
+			Annotations: map[string]string{"build": "4567"},
+		},
+		Spec: corev1.PodSpec{NodeName: "node-1"},
+	}
+
+	cases := map[string]struct {
+		value  string
+		exp    string
+		expErr string
+	}{
+		"no template": {
+			value: "version=v1",
+			exp:   "version=v1",
+		},
+		"label": {
+			value: "version={{ .Labels.version }}",
+			exp:   "version=v1.2.3",
+		},
+		"label with a key that isn't an identifier": {
+			value: `{{ index .Labels "app.kubernetes.io/version" }}`,
+			exp:   "1.2",
+		},
+		"pod fields": {
+			value: "{{ .Namespace }}/{{ .Name }} on {{ .NodeName }} build {{ .Annotations.build }}",
+			exp:   "apps/web-7d9f6-abcde on node-1 build 4567",
+		},
+		"missing label": {
+			value: "track={{ .Labels.track }}",
+			exp:   "track=",
+		},
+		"malformed template": {
+			value:  "{{ .Labels.version ",
+			expErr: "consul.hashicorp.com/service-tags annotation value of {{ .Labels.version  was invalid",
+		},
+		"unknown field": {
+			value:  "{{ .Image }}",
+			expErr: "consul.hashicorp.com/service-tags annotation value of {{ .Image }} could not be rendered",
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			out, err := renderPodTemplate(pod, annotationTags, c.value)
+			if c.expErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, out)
+		})
+	}
+}
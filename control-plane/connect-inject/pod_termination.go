@@ -0,0 +1,194 @@
+package connectinject
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-multierror"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// podConditionDisruptionTarget is the condition that Kubernetes 1.25+ adds to pods that are
+// about to be deleted because they are evicted, preempted or garbage collected. It is set
+// before the deletion timestamp so it is the earliest sign that a pod is going away.
+const podConditionDisruptionTarget corev1.PodConditionType = "DisruptionTarget"
+
+// podTerminating returns true if the pod is being deleted, evicted or preempted but its
+// containers may still be running. Pods that have already stopped aren't terminating since
+// they can't serve traffic anymore and are deregistered right away.
+func podTerminating(pod corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	if pod.DeletionTimestamp != nil {
+		return true
+	}
+	_, ok := disruptionTarget(pod)
+	return ok
+}
+
+// disruptionTarget returns the DisruptionTarget condition of the pod if it is true.
+func disruptionTarget(pod corev1.Pod) (corev1.PodCondition, bool) {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == podConditionDisruptionTarget && cond.Status == corev1.ConditionTrue {
+			return cond, true
+		}
+	}
+	return corev1.PodCondition{}, false
+}
+
+// podTerminatingReason returns the output of the Consul health check of a terminating pod.
+func podTerminatingReason(pod corev1.Pod) string {
+	if cond, ok := disruptionTarget(pod); ok && cond.Reason != "" {
+		return fmt.Sprintf("Pod \"%s/%s\" is terminating (%s)", pod.Namespace, pod.Name, cond.Reason)
+	}
+	return fmt.Sprintf("Pod \"%s/%s\" is terminating", pod.Namespace, pod.Name)
+}
+
+// drainTerminatingPods marks the service instances of injected pods that are terminating but
+// are no longer part of the Endpoints object as critical, and adds their addresses to the
+// endpointAddressMap so that they aren't deregistered yet. Kubernetes removes terminating pods
+// from Endpoints as soon as they start terminating, but their containers keep serving traffic
+// until they stop. Keeping the critical instances in Consul until then drains the traffic of
+// upstream proxies instead of dropping it. The instances are deregistered once the pods are
+// deleted or stopped, or once their deregistration policy allows at the time now unless
+// keepInstances is set. It returns how long until the deregistration policy of a pod next
+// deregisters its instance.
+func (r *EndpointsController) drainTerminatingPods(ctx context.Context, serviceEndpoints corev1.Endpoints, endpointAddressMap map[string]bool, now time.Time, keepInstances bool) (time.Duration, error) {
+	// The pods of a service can only be found through its selector.
+	var service corev1.Service
+	err := r.Client.Get(ctx, types.NamespacedName{Name: serviceEndpoints.Name, Namespace: serviceEndpoints.Namespace}, &service)
+	if k8serrors.IsNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(service.Spec.Selector) == 0 {
+		return 0, nil
+	}
+
+	var pods corev1.PodList
+	if err := r.Client.List(ctx, &pods, client.InNamespace(service.Namespace), client.MatchingLabels(service.Spec.Selector)); err != nil {
+		return 0, err
+	}
+
+	var errs error
+	var requeueAfter time.Duration
+	for _, pod := range pods.Items {
+		if !hasBeenInjected(pod) || !podTerminating(pod) || pod.Status.PodIP == "" || endpointAddressMap[pod.Status.PodIP] {
+			continue
+		}
+		if serviceName, ok := pod.Annotations[annotationKubernetesService]; ok && serviceName != serviceEndpoints.Name {
+			continue
+		}
+		policy, err := r.deregistrationPolicy(pod)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		decision := policy.decide(pod, api.HealthCritical, now)
+		if decision.deregister && !keepInstances {
+			continue
+		}
+		requeueAfter = minRequeueAfter(requeueAfter, decision.requeueAfter)
+		registered, err := r.markPodTerminating(pod, serviceEndpoints)
+		if err != nil {
+			r.Log.Error(err, "failed to update health check status for terminating pod", "name", pod.Name, "ns", pod.Namespace)
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		if registered {
+			endpointAddressMap[pod.Status.PodIP] = true
+		}
+	}
+	return requeueAfter, errs
+}
+
+// markPodTerminating updates the Kubernetes health check of the service instance of the pod to
+// critical. It returns false if the service instance isn't registered.
+func (r *EndpointsController) markPodTerminating(pod corev1.Pod, serviceEndpoints corev1.Endpoints) (bool, error) {
+	client, err := r.remoteConsulClient(pod.Status.HostIP, r.consulNamespace(pod.Namespace))
+	if err != nil {
+		return false, err
+	}
+	serviceID := getServiceID(pod, serviceEndpoints)
+	healthCheckID := getConsulHealthCheckID(pod, serviceID)
+	serviceCheck, err := getServiceCheck(client, healthCheckID)
+	if err != nil {
+		return false, fmt.Errorf("unable to get agent health checks: serviceID=%s, checkID=%s, %w", serviceID, healthCheckID, err)
+	}
+	if serviceCheck == nil {
+		return false, nil
+	}
+	if serviceCheck.Status != api.HealthCritical {
+		r.Log.Info("draining service instance of terminating pod", "name", pod.Name, "ns", pod.Namespace, "id", serviceID)
+		if err := r.updateConsulHealthCheckStatus(client, healthCheckID, api.HealthCritical, podTerminatingReason(pod)); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// podTerminationChanged filters pod events to those where an injected pod starts or stops
+// terminating, or is deleted.
+func podTerminationChanged() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			pod, ok := e.Object.(*corev1.Pod)
+			return ok && hasBeenInjected(*pod)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldPod, ok := e.ObjectOld.(*corev1.Pod)
+			if !ok {
+				return false
+			}
+			newPod, ok := e.ObjectNew.(*corev1.Pod)
+			if !ok {
+				return false
+			}
+			return hasBeenInjected(*newPod) && podTerminating(*oldPod) != podTerminating(*newPod)
+		},
+	}
+}
+
+// requestsForTerminatingPod enqueues a request for the endpoints object of each service that
+// selects a pod that started or stopped terminating, or was deleted. Kubernetes may already
+// have removed the pod from the endpoints objects, so they are found through the selectors
+// of the services instead.
+func (r *EndpointsController) requestsForTerminatingPod(object client.Object) []ctrl.Request {
+	if shouldIgnore(object.GetNamespace(), r.DenyK8sNamespacesSet, r.AllowK8sNamespacesSet) {
+		return []ctrl.Request{}
+	}
+	if r.Shards != nil && !r.Shards.Owns(object.GetNamespace()) {
+		return []ctrl.Request{}
+	}
+
+	var services corev1.ServiceList
+	if err := r.Client.List(r.Context, &services, client.InNamespace(object.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list services", "ns", object.GetNamespace())
+		return []ctrl.Request{}
+	}
+
+	var requests []ctrl.Request
+	for _, svc := range services.Items {
+		if len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		if labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(object.GetLabels())) {
+			requests = append(requests, ctrl.Request{NamespacedName: types.NamespacedName{Name: svc.Name, Namespace: svc.Namespace}})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,211 @@
+package connectinject
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mapset "github.com/deckarep/golang-set"
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestPodTerminating(t *testing.T) {
+	now := metav1.Now()
+	cases := map[string]struct {
+		pod       func(*corev1.Pod)
+		expected  bool
+		expReason string
+	}{
+		"running": {
+			pod:       func(*corev1.Pod) {},
+			expected:  false,
+			expReason: `Pod "default/pod1" is not ready`,
+		},
+		"deleted": {
+			pod: func(pod *corev1.Pod) {
+				pod.DeletionTimestamp = &now
+			},
+			expected:  true,
+			expReason: `Pod "default/pod1" is terminating`,
+		},
+		"preempted": {
+			pod: func(pod *corev1.Pod) {
+				pod.Status.Conditions = append(pod.Status.Conditions, corev1.PodCondition{
+					Type:   podConditionDisruptionTarget,
+					Status: corev1.ConditionTrue,
+					Reason: "PreemptionByKubeScheduler",
+				})
+			},
+			expected:  true,
+			expReason: `Pod "default/pod1" is terminating (PreemptionByKubeScheduler)`,
+		},
+		"stopped": {
+			pod: func(pod *corev1.Pod) {
+				pod.DeletionTimestamp = &now
+				pod.Status.Phase = corev1.PodFailed
+			},
+			expected:  false,
+			expReason: `Pod "default/pod1" is not ready`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			c.pod(pod)
+			require.Equal(t, c.expected, podTerminating(*pod))
+			require.Equal(t, c.expReason, getHealthCheckStatusReason(api.HealthCritical, *pod))
+			require.Equal(t, kubernetesSuccessReasonMsg, getHealthCheckStatusReason(api.HealthPassing, *pod))
+		})
+	}
+}
+
+func TestPodTerminationChanged(t *testing.T) {
+	now := metav1.Now()
+	running := createPod("pod1", "1.2.3.4", true, true)
+	terminating := running.DeepCopy()
+	terminating.DeletionTimestamp = &now
+	notInjected := createPod("pod1", "1.2.3.4", false, false)
+	notInjectedTerminating := notInjected.DeepCopy()
+	notInjectedTerminating.DeletionTimestamp = &now
+
+	p := podTerminationChanged()
+	require.False(t, p.Create(event.CreateEvent{Object: running}))
+	require.True(t, p.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: terminating}))
+	require.False(t, p.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: running}))
+	require.False(t, p.Update(event.UpdateEvent{ObjectOld: notInjected, ObjectNew: notInjectedTerminating}))
+	require.True(t, p.Delete(event.DeleteEvent{Object: terminating}))
+	require.False(t, p.Delete(event.DeleteEvent{Object: notInjected}))
+}
+
+func TestRequestsForTerminatingPod(t *testing.T) {
+	pod := createPod("pod1", "1.2.3.4", true, true)
+	pod.Labels["app"] = "web"
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(
+		createService("web", "default", map[string]string{"app": "web"}),
+		createService("api", "default", map[string]string{"app": "api"}),
+		createService("external", "default", nil),
+		createService("web", "other", map[string]string{"app": "web"}),
+	).Build()
+
+	ep := &EndpointsController{
+		Client:                fakeClient,
+		Log:                   logrtest.TestLogger{T: t},
+		Context:               context.Background(),
+		AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:  mapset.NewSetWith(),
+	}
+	require.Equal(t, []ctrl.Request{{NamespacedName: types.NamespacedName{Name: "web", Namespace: "default"}}}, ep.requestsForTerminatingPod(pod))
+
+	ep.DenyK8sNamespacesSet = mapset.NewSetWith("default")
+	require.Empty(t, ep.requestsForTerminatingPod(pod))
+}
+
+func TestDrainTerminatingPods(t *testing.T) {
+	now := metav1.Now()
+	// pod1 is terminating and registered.
+	pod1 := createPod("pod1", "1.2.3.4", true, true)
+	pod1.DeletionTimestamp = &now
+	// pod2 is terminating but not registered.
+	pod2 := createPod("pod2", "2.2.3.4", true, true)
+	pod2.DeletionTimestamp = &now
+	// pod3 is running.
+	pod3 := createPod("pod3", "3.2.3.4", true, true)
+	// pod4 is terminating but still part of the endpoints.
+	pod4 := createPod("pod4", "4.2.3.4", true, true)
+	pod4.DeletionTimestamp = &now
+	for _, pod := range []*corev1.Pod{pod1, pod2, pod3, pod4} {
+		pod.Labels["app"] = "service-created"
+	}
+	endpoints := corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "service-created", Namespace: "default"}}
+
+	agent := &fakeAgentChecks{
+		checks: map[string]*api.AgentCheck{
+			"default/pod1-service-created/kubernetes-health-check": {Status: api.HealthPassing},
+			"default/pod4-service-created/kubernetes-health-check": {Status: api.HealthPassing},
+		},
+	}
+	srv := httptest.NewServer(agent)
+	defer srv.Close()
+	serverURL, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(
+		createService("service-created", "default", map[string]string{"app": "service-created"}),
+		pod1, pod2, pod3, pod4,
+	).Build()
+	ep := &EndpointsController{
+		Client:          fakeClient,
+		Log:             logrtest.TestLogger{T: t},
+		ConsulClientCfg: &api.Config{},
+		ConsulScheme:    "http",
+		ConsulPort:      serverURL.Port(),
+	}
+
+	endpointAddressMap := map[string]bool{"4.2.3.4": true}
+	requeueAfter, err := ep.drainTerminatingPods(context.Background(), endpoints, endpointAddressMap, time.Now(), false)
+	require.NoError(t, err)
+	require.Zero(t, requeueAfter)
+	require.Equal(t, map[string]bool{"1.2.3.4": true, "4.2.3.4": true}, endpointAddressMap)
+	require.Equal(t, api.HealthCritical, agent.checks["default/pod1-service-created/kubernetes-health-check"].Status)
+	require.Equal(t, `Pod "default/pod1" is terminating`, agent.checks["default/pod1-service-created/kubernetes-health-check"].Output)
+	require.Equal(t, api.HealthPassing, agent.checks["default/pod4-service-created/kubernetes-health-check"].Status)
+}
+
+// fakeAgentChecks serves the health check endpoints of the Consul agent API.
+type fakeAgentChecks struct {
+	mu     sync.Mutex
+	checks map[string]*api.AgentCheck
+}
+
+func (a *fakeAgentChecks) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch {
+	case r.URL.Path == "/v1/agent/checks":
+		checks := make(map[string]*api.AgentCheck)
+		for id, check := range a.checks {
+			if strings.Contains(r.URL.Query().Get("filter"), id) {
+				checks[id] = check
+			}
+		}
+		json.NewEncoder(w).Encode(checks)
+	case strings.HasPrefix(r.URL.Path, "/v1/agent/check/update/"):
+		var update struct {
+			Status string
+			Output string
+		}
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		check, ok := a.checks[strings.TrimPrefix(r.URL.Path, "/v1/agent/check/update/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		check.Status, check.Output = update.Status, update.Output
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func createService(name, namespace string, selector map[string]string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       corev1.ServiceSpec{Selector: selector},
+	}
+}
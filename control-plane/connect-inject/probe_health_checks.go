@@ -0,0 +1,211 @@
+package connectinject
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	probeReadiness = "readiness"
+	probeLiveness  = "liveness"
+	probeStartup   = "startup"
+)
+
+// probeHealthCheck is a Consul TTL health check that mirrors the result of a single
+// Kubernetes probe or readiness gate of a pod.
+type probeHealthCheck struct {
+	// Key references the check in the exclude and names annotations. It is
+	// "<container>.<probe>" for probes and the condition type for readiness gates.
+	Key    string
+	ID     string
+	Name   string
+	Status string
+	Output string
+}
+
+// probeHealthChecksEnabled returns true if the probes of the pod should be registered as
+// separate Consul health checks. It returns an error when the annotation value cannot be
+// parsed by strconv.ParseBool.
+func probeHealthChecksEnabled(pod corev1.Pod, globalEnabled bool) (bool, error) {
+	if raw, ok := pod.Annotations[annotationProbeHealthChecks]; ok {
+		return strconv.ParseBool(raw)
+	}
+
+	return globalEnabled, nil
+}
+
+// probeHealthCheckIDPrefix is the prefix of the IDs of all probe health checks of the service
+// instance. It lets us find checks of probes that were removed or excluded since.
+func probeHealthCheckIDPrefix(pod corev1.Pod, serviceID string) string {
+	return fmt.Sprintf("%s/%s/kubernetes-probe/", pod.Namespace, serviceID)
+}
+
+// probeHealthChecks returns a health check for every probe of the application containers and
+// every readiness gate of the pod, except for the ones excluded via annotation.
+func probeHealthChecks(pod corev1.Pod, serviceID string) ([]probeHealthCheck, error) {
+	excluded := make(map[string]bool)
+	if raw, ok := pod.Annotations[annotationProbeHealthChecksExclude]; ok {
+		for _, key := range strings.Split(raw, ",") {
+			excluded[strings.TrimSpace(key)] = true
+		}
+	}
+	names := make(map[string]string)
+	if raw, ok := pod.Annotations[annotationProbeHealthCheckNames]; ok && raw != "" {
+		for _, pair := range strings.Split(raw, ",") {
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+				return nil, fmt.Errorf("%s annotation value of %q is invalid: expected <check>=<name>", annotationProbeHealthCheckNames, pair)
+			}
+			names[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	containerStatuses := make(map[string]corev1.ContainerStatus)
+	for _, cs := range pod.Status.ContainerStatuses {
+		containerStatuses[cs.Name] = cs
+	}
+
+	var checks []probeHealthCheck
+	add := func(check probeHealthCheck) {
+		if excluded[check.Key] {
+			return
+		}
+		check.ID = probeHealthCheckIDPrefix(pod, serviceID) + check.Key
+		if name, ok := names[check.Key]; ok {
+			check.Name = name
+		}
+		checks = append(checks, check)
+	}
+
+	for _, c := range pod.Spec.Containers {
+		// The probes of the injected sidecars are not part of the application's health.
+		if c.Name == envoySidecarContainer || c.Name == consulSidecarContainer {
+			continue
+		}
+		cs, hasStatus := containerStatuses[c.Name]
+		for _, probe := range []struct {
+			kind  string
+			title string
+			probe *corev1.Probe
+		}{
+			{probeReadiness, "Readiness", c.ReadinessProbe},
+			{probeLiveness, "Liveness", c.LivenessProbe},
+			{probeStartup, "Startup", c.StartupProbe},
+		} {
+			if probe.probe == nil {
+				continue
+			}
+			check := probeHealthCheck{
+				Key:  fmt.Sprintf("%s.%s", c.Name, probe.kind),
+				Name: fmt.Sprintf("Kubernetes %s Probe (%s)", probe.title, c.Name),
+			}
+			check.Status, check.Output = probeStatus(probe.kind, c.Name, cs, hasStatus)
+			add(check)
+		}
+	}
+
+	for _, gate := range pod.Spec.ReadinessGates {
+		check := probeHealthCheck{
+			Key:    string(gate.ConditionType),
+			Name:   fmt.Sprintf("Kubernetes Readiness Gate (%s)", gate.ConditionType),
+			Status: api.HealthCritical,
+			Output: fmt.Sprintf("Readiness gate %q has not been set on the pod", gate.ConditionType),
+		}
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type != gate.ConditionType {
+				continue
+			}
+			if cond.Status == corev1.ConditionTrue {
+				check.Status = api.HealthPassing
+				check.Output = fmt.Sprintf("Readiness gate %q is passing", gate.ConditionType)
+			} else {
+				check.Output = fmt.Sprintf("Readiness gate %q is failing: %s %s", gate.ConditionType, cond.Reason, cond.Message)
+			}
+		}
+		add(check)
+	}
+
+	return checks, nil
+}
+
+// probeStatus returns the Consul health status and output of the given kind of probe from the status
+// of the container. Kubernetes only reports the readiness and startup probe results, so the liveness
+// probe is considered passing for as long as the container is running.
+func probeStatus(kind, container string, cs corev1.ContainerStatus, hasStatus bool) (string, string) {
+	if !hasStatus {
+		return api.HealthCritical, fmt.Sprintf("Container %q has not been created yet", container)
+	}
+
+	var passing bool
+	switch kind {
+	case probeReadiness:
+		passing = cs.Ready
+	case probeStartup:
+		passing = cs.Started != nil && *cs.Started
+	case probeLiveness:
+		passing = cs.State.Running != nil
+	}
+	if passing {
+		return api.HealthPassing, fmt.Sprintf("Kubernetes %s probe of container %q is passing", kind, container)
+	}
+	return api.HealthCritical, fmt.Sprintf("Kubernetes %s probe of container %q is failing (restarts: %d)", kind, container, cs.RestartCount)
+}
+
+// upsertProbeHealthChecks registers the probe health checks of the service instance, updates the
+// status of existing ones and deregisters the checks of probes that no longer exist or are excluded.
+func (r *EndpointsController) upsertProbeHealthChecks(pod corev1.Pod, client *api.Client, serviceID string) error {
+	checks, err := probeHealthChecks(pod, serviceID)
+	if err != nil {
+		return err
+	}
+
+	existing, err := client.Agent().ChecksWithFilter(fmt.Sprintf("ServiceID == `%s`", serviceID))
+	if err != nil {
+		return fmt.Errorf("unable to get agent health checks: serviceID=%s, %w", serviceID, err)
+	}
+
+	desired := make(map[string]bool)
+	for _, check := range checks {
+		desired[check.ID] = true
+		current, ok := existing[check.ID]
+		if !ok || current.Name != check.Name {
+			r.Log.Info("registering probe health check", "id", check.ID)
+			err = client.Agent().CheckRegister(&api.AgentCheckRegistration{
+				ID:        check.ID,
+				Name:      check.Name,
+				ServiceID: serviceID,
+				AgentServiceCheck: api.AgentServiceCheck{
+					TTL:                    "100000h",
+					Status:                 check.Status,
+					SuccessBeforePassing:   1,
+					FailuresBeforeCritical: 1,
+				},
+			})
+			if err != nil {
+				return fmt.Errorf("registering probe health check %q for service %q: %w", check.ID, serviceID, err)
+			}
+		} else if current.Status == check.Status && current.Output == check.Output {
+			continue
+		}
+
+		err = r.updateConsulHealthCheckStatus(client, check.ID, check.Status, check.Output)
+		if err != nil {
+			return err
+		}
+	}
+
+	prefix := probeHealthCheckIDPrefix(pod, serviceID)
+	for id := range existing {
+		if strings.HasPrefix(id, prefix) && !desired[id] {
+			r.Log.Info("deregistering probe health check", "id", id)
+			if err := client.Agent().CheckDeregister(id); err != nil {
+				return fmt.Errorf("deregistering probe health check %q: %w", id, err)
+			}
+		}
+	}
+	return nil
+}
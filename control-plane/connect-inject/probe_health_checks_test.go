@@ -0,0 +1,302 @@
+package connectinject
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set"
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestProbeHealthChecks(t *testing.T) {
+	t.Parallel()
+
+	started := true
+	probe := &corev1.Probe{}
+	containers := []corev1.Container{
+		{
+			Name:           "app",
+			ReadinessProbe: probe,
+			LivenessProbe:  probe,
+			StartupProbe:   probe,
+		},
+		{
+			Name:           envoySidecarContainer,
+			ReadinessProbe: probe,
+		},
+	}
+
+	cases := map[string]struct {
+		annotations       map[string]string
+		readinessGates    []corev1.PodReadinessGate
+		conditions        []corev1.PodCondition
+		containerStatuses []corev1.ContainerStatus
+		expChecks         []probeHealthCheck
+		expErr            string
+	}{
+		"container without status": {
+			expChecks: []probeHealthCheck{
+				{
+					Key:    "app.readiness",
+					ID:     "default/svc-id/kubernetes-probe/app.readiness",
+					Name:   "Kubernetes Readiness Probe (app)",
+					Status: api.HealthCritical,
+					Output: `Container "app" has not been created yet`,
+				},
+				{
+					Key:    "app.liveness",
+					ID:     "default/svc-id/kubernetes-probe/app.liveness",
+					Name:   "Kubernetes Liveness Probe (app)",
+					Status: api.HealthCritical,
+					Output: `Container "app" has not been created yet`,
+				},
+				{
+					Key:    "app.startup",
+					ID:     "default/svc-id/kubernetes-probe/app.startup",
+					Name:   "Kubernetes Startup Probe (app)",
+					Status: api.HealthCritical,
+					Output: `Container "app" has not been created yet`,
+				},
+			},
+		},
+		"running container that is not ready": {
+			containerStatuses: []corev1.ContainerStatus{
+				{
+					Name:         "app",
+					Started:      &started,
+					RestartCount: 2,
+					State:        corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				},
+			},
+			expChecks: []probeHealthCheck{
+				{
+					Key:    "app.readiness",
+					ID:     "default/svc-id/kubernetes-probe/app.readiness",
+					Name:   "Kubernetes Readiness Probe (app)",
+					Status: api.HealthCritical,
+					Output: `Kubernetes readiness probe of container "app" is failing (restarts: 2)`,
+				},
+				{
+					Key:    "app.liveness",
+					ID:     "default/svc-id/kubernetes-probe/app.liveness",
+					Name:   "Kubernetes Liveness Probe (app)",
+					Status: api.HealthPassing,
+					Output: `Kubernetes liveness probe of container "app" is passing`,
+				},
+				{
+					Key:    "app.startup",
+					ID:     "default/svc-id/kubernetes-probe/app.startup",
+					Name:   "Kubernetes Startup Probe (app)",
+					Status: api.HealthPassing,
+					Output: `Kubernetes startup probe of container "app" is passing`,
+				},
+			},
+		},
+		"readiness gates": {
+			annotations: map[string]string{
+				annotationProbeHealthChecksExclude: "app.readiness, app.liveness,app.startup",
+			},
+			readinessGates: []corev1.PodReadinessGate{
+				{ConditionType: "example.com/ready"},
+				{ConditionType: "example.com/failing"},
+				{ConditionType: "example.com/unset"},
+			},
+			conditions: []corev1.PodCondition{
+				{Type: "example.com/ready", Status: corev1.ConditionTrue},
+				{Type: "example.com/failing", Status: corev1.ConditionFalse, Reason: "Unhealthy", Message: "target is draining"},
+			},
+			expChecks: []probeHealthCheck{
+				{
+					Key:    "example.com/ready",
+					ID:     "default/svc-id/kubernetes-probe/example.com/ready",
+					Name:   "Kubernetes Readiness Gate (example.com/ready)",
+					Status: api.HealthPassing,
+					Output: `Readiness gate "example.com/ready" is passing`,
+				},
+				{
+					Key:    "example.com/failing",
+					ID:     "default/svc-id/kubernetes-probe/example.com/failing",
+					Name:   "Kubernetes Readiness Gate (example.com/failing)",
+					Status: api.HealthCritical,
+					Output: `Readiness gate "example.com/failing" is failing: Unhealthy target is draining`,
+				},
+				{
+					Key:    "example.com/unset",
+					ID:     "default/svc-id/kubernetes-probe/example.com/unset",
+					Name:   "Kubernetes Readiness Gate (example.com/unset)",
+					Status: api.HealthCritical,
+					Output: `Readiness gate "example.com/unset" has not been set on the pod`,
+				},
+			},
+		},
+		"renamed checks": {
+			annotations: map[string]string{
+				annotationProbeHealthChecksExclude: "app.liveness,app.startup",
+				annotationProbeHealthCheckNames:    "app.readiness=App Ready, example.com/ready=Load Balancer",
+			},
+			readinessGates: []corev1.PodReadinessGate{
+				{ConditionType: "example.com/ready"},
+			},
+			conditions: []corev1.PodCondition{
+				{Type: "example.com/ready", Status: corev1.ConditionTrue},
+			},
+			containerStatuses: []corev1.ContainerStatus{
+				{Name: "app", Ready: true},
+			},
+			expChecks: []probeHealthCheck{
+				{
+					Key:    "app.readiness",
+					ID:     "default/svc-id/kubernetes-probe/app.readiness",
+					Name:   "App Ready",
+					Status: api.HealthPassing,
+					Output: `Kubernetes readiness probe of container "app" is passing`,
+				},
+				{
+					Key:    "example.com/ready",
+					ID:     "default/svc-id/kubernetes-probe/example.com/ready",
+					Name:   "Load Balancer",
+					Status: api.HealthPassing,
+					Output: `Readiness gate "example.com/ready" is passing`,
+				},
+			},
+		},
+		"invalid names annotation": {
+			annotations: map[string]string{
+				annotationProbeHealthCheckNames: "app.readiness",
+			},
+			expErr: `consul.hashicorp.com/probe-health-check-names annotation value of "app.readiness" is invalid: expected <check>=<name>`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+			pod.Spec.Containers = containers
+			pod.Spec.ReadinessGates = c.readinessGates
+			pod.Status.Conditions = c.conditions
+			pod.Status.ContainerStatuses = c.containerStatuses
+
+			checks, err := probeHealthChecks(*pod, "svc-id")
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expChecks, checks)
+		})
+	}
+}
+
+// Test that the probes of a pod are registered as separate health checks of the service
+// instance and that checks of excluded probes are deregistered.
+func TestReconcile_ProbeHealthChecks(t *testing.T) {
+	t.Parallel()
+	nodeName := "test-node"
+
+	pod1 := createPod("pod1", "1.2.3.4", true, true)
+	pod1.Spec.Containers = []corev1.Container{
+		{
+			Name:           "app",
+			ReadinessProbe: &corev1.Probe{},
+			LivenessProbe:  &corev1.Probe{},
+		},
+	}
+	pod1.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{
+			Name:  "app",
+			Ready: true,
+			State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+		},
+	}
+	endpoint := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "service-created",
+			Namespace: "default",
+		},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{
+					{
+						IP:       "1.2.3.4",
+						NodeName: &nodeName,
+						TargetRef: &corev1.ObjectReference{
+							Kind:      "Pod",
+							Name:      "pod1",
+							Namespace: "default",
+						},
+					},
+				},
+			},
+		},
+	}
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(pod1, endpoint, &ns).Build()
+
+	consul, err := testutil.NewTestServerConfigT(t, func(c *testutil.TestServerConfig) {
+		c.NodeName = nodeName
+	})
+	require.NoError(t, err)
+	defer consul.Stop()
+	consul.WaitForServiceIntentions(t)
+	cfg := &api.Config{
+		Address: consul.HTTPAddr,
+	}
+	consulClient, err := api.NewClient(cfg)
+	require.NoError(t, err)
+	consulPort := strings.Split(consul.HTTPAddr, ":")[1]
+
+	ep := &EndpointsController{
+		Client:                  fakeClient,
+		Log:                     logrtest.TestLogger{T: t},
+		ConsulClient:            consulClient,
+		ConsulPort:              consulPort,
+		ConsulScheme:            "http",
+		AllowK8sNamespacesSet:   mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:    mapset.NewSetWith(),
+		ReleaseName:             "consul",
+		ReleaseNamespace:        "default",
+		ConsulClientCfg:         cfg,
+		EnableProbeHealthChecks: true,
+	}
+	namespacedName := types.NamespacedName{
+		Namespace: "default",
+		Name:      "service-created",
+	}
+
+	resp, err := ep.Reconcile(context.Background(), ctrl.Request{NamespacedName: namespacedName})
+	require.NoError(t, err)
+	require.False(t, resp.Requeue)
+
+	checks, err := consulClient.Agent().ChecksWithFilter("ServiceID == `pod1-service-created`")
+	require.NoError(t, err)
+	require.Contains(t, checks, "default/pod1-service-created/kubernetes-health-check")
+	require.Contains(t, checks, "default/pod1-service-created/kubernetes-probe/app.readiness")
+	require.Contains(t, checks, "default/pod1-service-created/kubernetes-probe/app.liveness")
+	require.Equal(t, "Kubernetes Liveness Probe (app)", checks["default/pod1-service-created/kubernetes-probe/app.liveness"].Name)
+	require.Equal(t, api.HealthPassing, checks["default/pod1-service-created/kubernetes-probe/app.liveness"].Status)
+
+	// Excluding the liveness probe should deregister its check.
+	var pod corev1.Pod
+	require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "pod1", Namespace: "default"}, &pod))
+	pod.Annotations[annotationProbeHealthChecksExclude] = "app.liveness"
+	require.NoError(t, fakeClient.Update(context.Background(), &pod))
+	_, err = ep.Reconcile(context.Background(), ctrl.Request{NamespacedName: namespacedName})
+	require.NoError(t, err)
+
+	checks, err = consulClient.Agent().ChecksWithFilter("ServiceID == `pod1-service-created`")
+	require.NoError(t, err)
+	require.Contains(t, checks, "default/pod1-service-created/kubernetes-probe/app.readiness")
+	require.NotContains(t, checks, "default/pod1-service-created/kubernetes-probe/app.liveness")
+}
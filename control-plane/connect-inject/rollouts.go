@@ -0,0 +1,162 @@
+package connectinject
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// MetaKeyRolloutRole is the meta key of the service instances of pods that are part of an Argo
+	// Rollouts or Flagger canary deployment. Its value is RolloutRoleStable or RolloutRoleCanary.
+	MetaKeyRolloutRole = "rollout-role"
+
+	// RolloutRoleStable and RolloutRoleCanary are the values of MetaKeyRolloutRole, and the names of
+	// the service resolver subsets that select the instances with them.
+	RolloutRoleStable = "stable"
+	RolloutRoleCanary = "canary"
+
+	// labelRolloutsPodTemplateHash is set by Argo Rollouts on the pods of a Rollout. Their ReplicaSet
+	// is named after the Rollout and the hash.
+	labelRolloutsPodTemplateHash = "rollouts-pod-template-hash"
+
+	// labelPodTemplateHash is set by Kubernetes on the pods of a Deployment. Their ReplicaSet is
+	// named after the Deployment and the hash.
+	labelPodTemplateHash = "pod-template-hash"
+
+	// flaggerPrimarySuffix is the suffix of the Deployment that Flagger creates for the stable pods of
+	// the Deployment that a Canary targets. The targeted Deployment runs the canary pods.
+	flaggerPrimarySuffix = "-primary"
+)
+
+var (
+	rolloutGVK       = schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}
+	flaggerCanaryGVK = schema.GroupVersionKind{Group: "flagger.app", Version: "v1beta1", Kind: "Canary"}
+)
+
+// rolloutRole returns whether the pod is a stable or a canary pod of an Argo Rollout or a Flagger
+// Canary. It returns "" if the pod isn't part of either, including when their CRDs aren't installed.
+func (r *EndpointsController) rolloutRole(ctx context.Context, pod corev1.Pod) (string, error) {
+	owner := metav1.GetControllerOf(&pod)
+	if owner == nil || owner.Kind != "ReplicaSet" {
+		return "", nil
+	}
+
+	// The pods of the ReplicaSet that Argo Rollouts marked stable are stable. Before the first
+	// rollout has completed there is no stable ReplicaSet yet, so all pods are stable.
+	if hash, ok := pod.Labels[labelRolloutsPodTemplateHash]; ok {
+		rollout, err := r.getOptionalObject(ctx, rolloutGVK, pod.Namespace, strings.TrimSuffix(owner.Name, "-"+hash))
+		if rollout == nil || err != nil {
+			return "", err
+		}
+		stableRS, _, _ := unstructured.NestedString(rollout.Object, "status", "stableRS")
+		if stableRS == "" || stableRS == hash {
+			return RolloutRoleStable, nil
+		}
+		return RolloutRoleCanary, nil
+	}
+
+	// Flagger runs the stable pods in a copy of the target Deployment of a Canary, with the primary
+	// suffix, and the canary pods in the target Deployment. Canaries are looked up by the name of
+	// their target, which they are usually named after.
+	if hash, ok := pod.Labels[labelPodTemplateHash]; ok {
+		deployment := strings.TrimSuffix(owner.Name, "-"+hash)
+		target := strings.TrimSuffix(deployment, flaggerPrimarySuffix)
+		canary, err := r.getOptionalObject(ctx, flaggerCanaryGVK, pod.Namespace, target)
+		if canary == nil || err != nil {
+			return "", err
+		}
+		if targetName, _, _ := unstructured.NestedString(canary.Object, "spec", "targetRef", "name"); targetName != target {
+			return "", nil
+		}
+		if deployment != target {
+			return RolloutRoleStable, nil
+		}
+		return RolloutRoleCanary, nil
+	}
+	return "", nil
+}
+
+// getOptionalObject gets the object of a kind that is defined by a CRD. It returns nil if the
+// object or the CRD doesn't exist.
+func (r *EndpointsController) getOptionalObject(ctx context.Context, gvk schema.GroupVersionKind, namespace, name string) (*unstructured.Unstructured, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, obj)
+	if k8serrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// upsertRolloutSubsets writes a service resolver for the service of a pod that is part of a
+// rollout, with a subset for each rollout role, so that service splitters can send a share of the
+// traffic to the canary instances. The stable subset is the default, so the canary instances
+// only get traffic that is explicitly split to them.
+//
+// Service resolvers that weren't written by the endpoints controller, e.g. by a ServiceResolver
+// resource, aren't changed.
+func (r *EndpointsController) upsertRolloutSubsets(service *api.AgentServiceRegistration) error {
+	opts := &api.QueryOptions{Namespace: service.Namespace}
+	entry, _, err := r.ConsulClient.ConfigEntries().Get(api.ServiceResolver, service.Name, opts)
+	if err != nil && !strings.Contains(err.Error(), "Unexpected response code: 404") {
+		return fmt.Errorf("getting service resolver %q: %w", service.Name, err)
+	}
+
+	desired := rolloutServiceResolver(service)
+	if entry != nil {
+		existing, ok := entry.(*api.ServiceResolverConfigEntry)
+		if !ok || existing.Meta[MetaKeyManagedBy] != managedByValue {
+			r.Log.Info("not adding rollout subsets to service resolver that isn't managed by consul-k8s", "name", service.Name)
+			return nil
+		}
+		if existing.DefaultSubset == desired.DefaultSubset && len(existing.Subsets) == len(desired.Subsets) {
+			return nil
+		}
+		// Keep the failover, e.g. to peers, that was written along with the subsets.
+		desired.Failover = existing.Failover
+	}
+
+	r.Log.Info("writing service resolver with rollout subsets", "name", service.Name)
+	if _, _, err := r.ConsulClient.ConfigEntries().Set(desired, &api.WriteOptions{Namespace: service.Namespace}); err != nil {
+		return fmt.Errorf("writing service resolver %q: %w", service.Name, err)
+	}
+	r.Audit.Record(nil, consul.AuditWrite{
+		Operation: consul.AuditOpWrite,
+		Kind:      api.ServiceResolver,
+		Name:      service.Name,
+		Namespace: service.Namespace,
+		Payload:   desired,
+	})
+	return nil
+}
+
+func rolloutServiceResolver(service *api.AgentServiceRegistration) *api.ServiceResolverConfigEntry {
+	subset := func(role string) api.ServiceResolverSubset {
+		return api.ServiceResolverSubset{Filter: fmt.Sprintf(`Service.Meta[%q] == %q`, MetaKeyRolloutRole, role)}
+	}
+	return &api.ServiceResolverConfigEntry{
+		Kind:          api.ServiceResolver,
+		Name:          service.Name,
+		Namespace:     service.Namespace,
+		DefaultSubset: RolloutRoleStable,
+		Subsets: map[string]api.ServiceResolverSubset{
+			RolloutRoleStable: subset(RolloutRoleStable),
+			RolloutRoleCanary: subset(RolloutRoleCanary),
+		},
+		Meta: map[string]string{MetaKeyManagedBy: managedByValue},
+	}
+}
@@ -0,0 +1,183 @@
+package connectinject
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRolloutRole(t *testing.T) {
+	t.Parallel()
+
+	pod := func(replicaSet string, labels map[string]string) corev1.Pod {
+		pod := corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-abc", Namespace: "default", Labels: labels},
+		}
+		if replicaSet != "" {
+			pod.OwnerReferences = []metav1.OwnerReference{{
+				APIVersion: "apps/v1",
+				Kind:       "ReplicaSet",
+				Name:       replicaSet,
+				Controller: pointerToBool(true),
+			}}
+		}
+		return pod
+	}
+	rollout := func(stableRS string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(rolloutGVK)
+		obj.SetName("web")
+		obj.SetNamespace("default")
+		if stableRS != "" {
+			require.NoError(t, unstructured.SetNestedField(obj.Object, stableRS, "status", "stableRS"))
+		}
+		return obj
+	}
+	canary := func(name, target string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(flaggerCanaryGVK)
+		obj.SetName(name)
+		obj.SetNamespace("default")
+		require.NoError(t, unstructured.SetNestedField(obj.Object, target, "spec", "targetRef", "name"))
+		return obj
+	}
+
+	cases := map[string]struct {
+		pod     corev1.Pod
+		objects []runtime.Object
+		expRole string
+	}{
+		"pod without owner": {
+			pod:     pod("", nil),
+			expRole: "",
+		},
+		"deployment pod": {
+			pod:     pod("web-5d8f7b", map[string]string{labelPodTemplateHash: "5d8f7b"}),
+			expRole: "",
+		},
+		"rollout pod without rollout": {
+			pod:     pod("web-5d8f7b", map[string]string{labelRolloutsPodTemplateHash: "5d8f7b"}),
+			expRole: "",
+		},
+		"rollout pod before the first rollout completed": {
+			pod:     pod("web-5d8f7b", map[string]string{labelRolloutsPodTemplateHash: "5d8f7b"}),
+			objects: []runtime.Object{rollout("")},
+			expRole: RolloutRoleStable,
+		},
+		"stable rollout pod": {
+			pod:     pod("web-5d8f7b", map[string]string{labelRolloutsPodTemplateHash: "5d8f7b"}),
+			objects: []runtime.Object{rollout("5d8f7b")},
+			expRole: RolloutRoleStable,
+		},
+		"canary rollout pod": {
+			pod:     pod("web-9c4e21", map[string]string{labelRolloutsPodTemplateHash: "9c4e21"}),
+			objects: []runtime.Object{rollout("5d8f7b")},
+			expRole: RolloutRoleCanary,
+		},
+		"flagger primary pod": {
+			pod:     pod("web-primary-5d8f7b", map[string]string{labelPodTemplateHash: "5d8f7b"}),
+			objects: []runtime.Object{canary("web", "web")},
+			expRole: RolloutRoleStable,
+		},
+		"flagger canary pod": {
+			pod:     pod("web-9c4e21", map[string]string{labelPodTemplateHash: "9c4e21"}),
+			objects: []runtime.Object{canary("web", "web")},
+			expRole: RolloutRoleCanary,
+		},
+		"flagger canary targeting another deployment": {
+			pod:     pod("web-9c4e21", map[string]string{labelPodTemplateHash: "9c4e21"}),
+			objects: []runtime.Object{canary("web", "api")},
+			expRole: "",
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			r := &EndpointsController{
+				Client: fake.NewClientBuilder().WithRuntimeObjects(c.objects...).Build(),
+				Log:    logrtest.TestLogger{T: t},
+			}
+			role, err := r.rolloutRole(context.Background(), c.pod)
+			require.NoError(t, err)
+			require.Equal(t, c.expRole, role)
+		})
+	}
+}
+
+func TestUpsertRolloutSubsets(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		existing   *api.ServiceResolverConfigEntry
+		expSubsets bool
+	}{
+		"no service resolver": {
+			expSubsets: true,
+		},
+		"service resolver managed by the endpoints controller": {
+			existing: &api.ServiceResolverConfigEntry{
+				Kind: api.ServiceResolver,
+				Name: "web",
+				Meta: map[string]string{MetaKeyManagedBy: managedByValue},
+			},
+			expSubsets: true,
+		},
+		"service resolver not managed by the endpoints controller": {
+			existing: &api.ServiceResolverConfigEntry{
+				Kind:           api.ServiceResolver,
+				Name:           "web",
+				ConnectTimeout: 15e9,
+			},
+			expSubsets: false,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			consul, err := testutil.NewTestServerConfigT(t, nil)
+			require.NoError(t, err)
+			defer consul.Stop()
+			consul.WaitForServiceIntentions(t)
+
+			consulClient, err := api.NewClient(&api.Config{Address: consul.HTTPAddr})
+			require.NoError(t, err)
+			if c.existing != nil {
+				_, _, err = consulClient.ConfigEntries().Set(c.existing, nil)
+				require.NoError(t, err)
+			}
+
+			r := &EndpointsController{
+				ConsulClient: consulClient,
+				Log:          logrtest.TestLogger{T: t},
+			}
+			err = r.upsertRolloutSubsets(&api.AgentServiceRegistration{Name: "web"})
+			require.NoError(t, err)
+
+			entry, _, err := consulClient.ConfigEntries().Get(api.ServiceResolver, "web", nil)
+			require.NoError(t, err)
+			resolver := entry.(*api.ServiceResolverConfigEntry)
+			if !c.expSubsets {
+				require.Empty(t, resolver.Subsets)
+				return
+			}
+			require.Equal(t, RolloutRoleStable, resolver.DefaultSubset)
+			require.Equal(t, map[string]api.ServiceResolverSubset{
+				RolloutRoleStable: {Filter: `Service.Meta["rollout-role"] == "stable"`},
+				RolloutRoleCanary: {Filter: `Service.Meta["rollout-role"] == "canary"`},
+			}, resolver.Subsets)
+		})
+	}
+}
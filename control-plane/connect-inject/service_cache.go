@@ -0,0 +1,240 @@
+package connectinject
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const (
+	defaultServiceCacheWaitTime      = 5 * time.Minute
+	defaultServiceCacheRetryInterval = 5 * time.Second
+)
+
+// ServiceCache caches the service instances registered by the endpoints controller on
+// Consul nodes. Each node is watched with a blocking query on the catalog once it has
+// been looked up, so that deciding which instances to deregister doesn't require
+// querying every client agent, or the catalog, on every reconcile.
+//
+// The catalog is updated by the client agents asynchronously, so the cache is only
+// used to decide which instances to deregister, never to skip registrations. An
+// instance an agent has registered may not be in the catalog yet when the cache is
+// consulted, so the Endpoints of the Kubernetes services whose instances change on a
+// node are sent to Events, for the controller to reconcile them again once the
+// catalog has caught up.
+type ServiceCache struct {
+	Client *api.Client
+	// Namespace is the Consul namespace of the queries. It must be the wildcard
+	// namespace "*" if Consul namespaces are enabled and empty otherwise.
+	Namespace string
+	// WaitTime is the maximum duration of a blocking query. It defaults to 5m.
+	WaitTime time.Duration
+	// RetryInterval is how long to wait after a query failed. It defaults to 5s.
+	RetryInterval time.Duration
+	Log           logr.Logger
+	// Events receives an Endpoints object, with only its name and namespace set, for
+	// each Kubernetes service whose instances on a watched node change. It may be nil.
+	Events chan event.GenericEvent
+
+	mu    sync.RWMutex
+	ctx   context.Context
+	nodes map[string]*cachedNode
+}
+
+// cachedNode holds the services of a node from the latest blocking query.
+type cachedNode struct {
+	cancel context.CancelFunc
+	synced bool
+	// loaded is true once the services have been synced at least once. Lookups
+	// before that fall back to the agents, so the first sync isn't a change.
+	loaded   bool
+	services []*api.AgentService
+}
+
+// Start keeps the watches of the nodes running until the context is cancelled.
+// Nodes aren't watched before it has been called. It implements manager.Runnable.
+func (c *ServiceCache) Start(ctx context.Context) error {
+	c.mu.Lock()
+	c.ctx = ctx
+	c.mu.Unlock()
+	<-ctx.Done()
+	return nil
+}
+
+// NeedLeaderElection returns false so that the cache is available on every
+// replica. It implements manager.LeaderElectionRunnable.
+func (c *ServiceCache) NeedLeaderElection() bool {
+	return false
+}
+
+// ServiceInstances returns the service instances on the node that have been registered
+// for the Kubernetes service. It returns false if the services of the node aren't known
+// yet, in which case the caller must query them itself. The first lookup of a node
+// starts watching it.
+func (c *ServiceCache) ServiceInstances(node, k8sSvcName, k8sSvcNamespace string) (map[string]*api.AgentService, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ctx == nil {
+		return nil, false
+	}
+	if c.nodes == nil {
+		c.nodes = make(map[string]*cachedNode)
+	}
+	cached, ok := c.nodes[node]
+	if !ok {
+		ctx, cancel := context.WithCancel(c.ctx)
+		cached = &cachedNode{cancel: cancel}
+		c.nodes[node] = cached
+		go c.watch(ctx, node, cached)
+	}
+	if !cached.synced {
+		return nil, false
+	}
+
+	instances := make(map[string]*api.AgentService)
+	for _, svc := range cached.services {
+		if svc.Meta[MetaKeyKubeServiceName] == k8sSvcName && svc.Meta[MetaKeyKubeNS] == k8sSvcNamespace {
+			instances[svc.ID] = svc
+		}
+	}
+	return instances, true
+}
+
+// Retain stops watching the nodes that aren't in nodes, e.g. because their client
+// agent is gone, and forgets their services. They are watched again if they are
+// looked up again.
+func (c *ServiceCache) Retain(nodes map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for node, cached := range c.nodes {
+		if !nodes[node] {
+			cached.cancel()
+			delete(c.nodes, node)
+		}
+	}
+}
+
+// watch updates the services of the node with blocking queries until the context
+// is cancelled.
+func (c *ServiceCache) watch(ctx context.Context, node string, cached *cachedNode) {
+	var index uint64
+	for {
+		opts := &api.QueryOptions{
+			Filter:    fmt.Sprintf(`Meta[%q] == %q`, MetaKeyManagedBy, managedByValue),
+			Namespace: c.Namespace,
+			WaitIndex: index,
+			WaitTime:  c.waitTime(),
+		}
+		nodeServices, meta, err := c.Client.Catalog().NodeServiceList(node, opts.WithContext(ctx))
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			c.Log.Error(err, "failed to watch services of node", "node", node)
+			c.update(ctx, cached, false, nil)
+			index = 0
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(c.retryInterval()):
+			}
+			continue
+		}
+
+		// The node doesn't exist if it has no services.
+		var services []*api.AgentService
+		if nodeServices != nil {
+			services = nodeServices.Services
+		}
+		c.update(ctx, cached, true, services)
+
+		// Start over if the index went backwards, e.g. after a snapshot restore.
+		if meta.LastIndex < index {
+			index = 0
+		} else {
+			index = meta.LastIndex
+		}
+	}
+}
+
+// update sets the services of the node and sends an event for each Kubernetes service
+// whose instances have changed since the node was last synced.
+func (c *ServiceCache) update(ctx context.Context, cached *cachedNode, synced bool, services []*api.AgentService) {
+	c.mu.Lock()
+	var changed []types.NamespacedName
+	if synced && cached.loaded {
+		changed = changedKubeServices(cached.services, services)
+	}
+	cached.synced = synced
+	if synced {
+		cached.loaded = true
+		cached.services = services
+	}
+	c.mu.Unlock()
+
+	if c.Events == nil {
+		return
+	}
+	for _, svc := range changed {
+		e := event.GenericEvent{Object: &corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: svc.Name, Namespace: svc.Namespace}}}
+		select {
+		case c.Events <- e:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// changedKubeServices returns the Kubernetes services that have an instance that was
+// added, removed or moved to another address between the old and new services.
+func changedKubeServices(old, new []*api.AgentService) []types.NamespacedName {
+	oldByID := make(map[string]*api.AgentService, len(old))
+	for _, svc := range old {
+		oldByID[svc.ID] = svc
+	}
+	seen := make(map[types.NamespacedName]bool)
+	var changed []types.NamespacedName
+	add := func(svc *api.AgentService) {
+		name := types.NamespacedName{Name: svc.Meta[MetaKeyKubeServiceName], Namespace: svc.Meta[MetaKeyKubeNS]}
+		if name.Name == "" || seen[name] {
+			return
+		}
+		seen[name] = true
+		changed = append(changed, name)
+	}
+	for _, svc := range new {
+		prev, ok := oldByID[svc.ID]
+		if !ok || prev.Address != svc.Address {
+			add(svc)
+		}
+		delete(oldByID, svc.ID)
+	}
+	for _, svc := range old {
+		if _, ok := oldByID[svc.ID]; ok {
+			add(svc)
+		}
+	}
+	return changed
+}
+
+func (c *ServiceCache) waitTime() time.Duration {
+	if c.WaitTime > 0 {
+		return c.WaitTime
+	}
+	return defaultServiceCacheWaitTime
+}
+
+func (c *ServiceCache) retryInterval() time.Duration {
+	if c.RetryInterval > 0 {
+		return c.RetryInterval
+	}
+	return defaultServiceCacheRetryInterval
+}
@@ -0,0 +1,281 @@
+package connectinject
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestServiceCache_ServiceInstances(t *testing.T) {
+	t.Parallel()
+	catalog := &fakeNodeCatalog{services: map[string][]*api.AgentService{
+		"node-1": {
+			managedService("svc-1", "web", "default", "1.2.3.4"),
+			managedService("svc-2", "web", "other", "1.2.3.5"),
+			managedService("svc-3", "api", "default", "1.2.3.6"),
+		},
+	}}
+	srv := httptest.NewServer(catalog)
+	defer srv.Close()
+	client, err := api.NewClient(&api.Config{Address: srv.URL})
+	require.NoError(t, err)
+
+	cache := &ServiceCache{
+		Client:   client,
+		WaitTime: 100 * time.Millisecond,
+		Log:      logrtest.TestLogger{T: t},
+	}
+
+	// Nothing is known before the cache has started.
+	_, ok := cache.ServiceInstances("node-1", "web", "default")
+	require.False(t, ok)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cache.Start(ctx)
+
+	retry.Run(t, func(r *retry.R) {
+		instances, ok := cache.ServiceInstances("node-1", "web", "default")
+		require.True(r, ok)
+		require.Len(r, instances, 1)
+		require.Equal(r, "1.2.3.4", instances["svc-1"].Address)
+	})
+
+	// A node without services has no instances.
+	retry.Run(t, func(r *retry.R) {
+		instances, ok := cache.ServiceInstances("node-2", "web", "default")
+		require.True(r, ok)
+		require.Empty(r, instances)
+	})
+
+	// Changes to the catalog are picked up.
+	catalog.set("node-1", nil)
+	retry.Run(t, func(r *retry.R) {
+		instances, ok := cache.ServiceInstances("node-1", "web", "default")
+		require.True(r, ok)
+		require.Empty(r, instances)
+	})
+}
+
+func TestServiceCache_UnsyncedAfterError(t *testing.T) {
+	t.Parallel()
+	catalog := &fakeNodeCatalog{fail: true}
+	srv := httptest.NewServer(catalog)
+	defer srv.Close()
+	client, err := api.NewClient(&api.Config{Address: srv.URL})
+	require.NoError(t, err)
+
+	cache := &ServiceCache{
+		Client:        client,
+		RetryInterval: 10 * time.Millisecond,
+		Log:           logrtest.TestLogger{T: t},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cache.Start(ctx)
+
+	retry.Run(t, func(r *retry.R) {
+		_, ok := cache.ServiceInstances("node-1", "web", "default")
+		require.False(r, ok)
+		require.NotZero(r, catalog.requests())
+	})
+}
+
+func TestServiceCache_EventsOnChange(t *testing.T) {
+	t.Parallel()
+	catalog := &fakeNodeCatalog{services: map[string][]*api.AgentService{
+		"node-1": {
+			managedService("svc-1", "web", "default", "1.2.3.4"),
+			managedService("svc-2", "api", "default", "1.2.3.5"),
+		},
+	}}
+	srv := httptest.NewServer(catalog)
+	defer srv.Close()
+	client, err := api.NewClient(&api.Config{Address: srv.URL})
+	require.NoError(t, err)
+
+	cache := &ServiceCache{
+		Client:   client,
+		WaitTime: 100 * time.Millisecond,
+		Log:      logrtest.TestLogger{T: t},
+		Events:   make(chan event.GenericEvent, 10),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cache.Start(ctx)
+
+	retry.Run(t, func(r *retry.R) {
+		_, ok := cache.ServiceInstances("node-1", "web", "default")
+		require.True(r, ok)
+	})
+	// The first sync isn't a change.
+	require.Empty(t, cache.Events)
+
+	// An instance that reaches the catalog after it was registered with the agent
+	// requeues the Endpoints of its service, and only those.
+	catalog.set("node-1", []*api.AgentService{
+		managedService("svc-1", "web", "default", "1.2.3.4"),
+		managedService("svc-2", "api", "default", "1.2.3.5"),
+		managedService("svc-3", "web", "other", "1.2.3.6"),
+	})
+	select {
+	case e := <-cache.Events:
+		require.Equal(t, "web", e.Object.GetName())
+		require.Equal(t, "other", e.Object.GetNamespace())
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event for the added instance")
+	}
+
+	// Removed and moved instances requeue their services.
+	catalog.set("node-1", []*api.AgentService{
+		managedService("svc-1", "web", "default", "1.2.3.7"),
+		managedService("svc-3", "web", "other", "1.2.3.6"),
+	})
+	var requeued []string
+	for len(requeued) < 2 {
+		select {
+		case e := <-cache.Events:
+			requeued = append(requeued, e.Object.GetNamespace()+"/"+e.Object.GetName())
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only got events for %v", requeued)
+		}
+	}
+	require.ElementsMatch(t, []string{"default/web", "default/api"}, requeued)
+}
+
+func TestServiceCache_Retain(t *testing.T) {
+	t.Parallel()
+	catalog := &fakeNodeCatalog{services: map[string][]*api.AgentService{
+		"node-1": {managedService("svc-1", "web", "default", "1.2.3.4")},
+	}}
+	srv := httptest.NewServer(catalog)
+	defer srv.Close()
+	client, err := api.NewClient(&api.Config{Address: srv.URL})
+	require.NoError(t, err)
+
+	cache := &ServiceCache{
+		Client:   client,
+		WaitTime: 10 * time.Millisecond,
+		Log:      logrtest.TestLogger{T: t},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cache.Start(ctx)
+
+	retry.Run(t, func(r *retry.R) {
+		_, ok := cache.ServiceInstances("node-1", "web", "default")
+		require.True(r, ok)
+	})
+
+	// Retaining the node keeps its services.
+	cache.Retain(map[string]bool{"node-1": true})
+	_, ok := cache.ServiceInstances("node-1", "web", "default")
+	require.True(t, ok)
+
+	// Once its agent is gone, the node is forgotten and no longer queried.
+	cache.Retain(map[string]bool{"node-2": true})
+	time.Sleep(50 * time.Millisecond)
+	requests := catalog.requests()
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, requests, catalog.requests())
+
+	// It is watched again if it is looked up again.
+	_, ok = cache.ServiceInstances("node-1", "web", "default")
+	require.False(t, ok)
+	retry.Run(t, func(r *retry.R) {
+		_, ok := cache.ServiceInstances("node-1", "web", "default")
+		require.True(r, ok)
+	})
+}
+
+func TestHasInstancesToDeregister(t *testing.T) {
+	t.Parallel()
+	svcs := map[string]*api.AgentService{"svc-1": {ID: "svc-1", Address: "1.2.3.4"}}
+	require.True(t, hasInstancesToDeregister(svcs, nil))
+	require.True(t, hasInstancesToDeregister(svcs, map[string]bool{"1.2.3.5": true}))
+	require.False(t, hasInstancesToDeregister(svcs, map[string]bool{"1.2.3.4": true}))
+	require.False(t, hasInstancesToDeregister(nil, nil))
+}
+
+func managedService(id, k8sName, k8sNS, address string) *api.AgentService {
+	return &api.AgentService{
+		ID:      id,
+		Service: k8sName,
+		Address: address,
+		Meta: map[string]string{
+			MetaKeyKubeServiceName: k8sName,
+			MetaKeyKubeNS:          k8sNS,
+			MetaKeyManagedBy:       managedByValue,
+		},
+	}
+}
+
+// fakeNodeCatalog serves the services of nodes from the catalog. Queries with the
+// current index block until the services change or the wait time has passed.
+type fakeNodeCatalog struct {
+	mu       sync.Mutex
+	index    uint64
+	services map[string][]*api.AgentService
+	fail     bool
+	count    int
+}
+
+func (f *fakeNodeCatalog) set(node string, services []*api.AgentService) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.services[node] = services
+	f.index++
+}
+
+func (f *fakeNodeCatalog) requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.count
+}
+
+func (f *fakeNodeCatalog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.count++
+	fail := f.fail
+	f.mu.Unlock()
+	if fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+	wait, _ := time.ParseDuration(r.URL.Query().Get("wait"))
+	deadline := time.Now().Add(wait)
+	for {
+		f.mu.Lock()
+		if f.index+1 != index || time.Now().After(deadline) {
+			break
+		}
+		f.mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}
+	defer f.mu.Unlock()
+
+	node := r.URL.Path[len("/v1/catalog/node-services/"):]
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index+1, 10))
+	services, ok := f.services[node]
+	if !ok {
+		w.Write([]byte("null"))
+		return
+	}
+	json.NewEncoder(w).Encode(api.CatalogNodeServiceList{
+		Node:     &api.Node{Node: node},
+		Services: services,
+	})
+}
@@ -0,0 +1,229 @@
+package connectinject
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const (
+	// shardGroupLabel labels the leases of the replicas that share the
+	// reconcile work with the name of their group.
+	shardGroupLabel = "consul.hashicorp.com/connect-inject-shard-group"
+
+	defaultShardLeaseDuration = 15 * time.Second
+	defaultShardRenewInterval = 5 * time.Second
+)
+
+// ShardMembership shards the reconcile work of the endpoints controller across
+// the connect-inject replicas by namespace, so that every replica reconciles
+// instead of only the leader.
+//
+// Every replica holds a lease that it renews periodically. The replicas with a
+// lease that hasn't expired are the members of the group. A replica owns the
+// namespaces whose hash, modulo the number of members, is its index in the
+// members sorted by identity. When a replica joins or leaves, ownership of
+// namespaces moves to other replicas, which then reconcile all endpoints in
+// the namespaces they own.
+type ShardMembership struct {
+	Clientset kubernetes.Interface
+	// Namespace is the namespace of the leases.
+	Namespace string
+	// Group is the name of the group of replicas that share the work. It
+	// separates multiple installations in the same namespace.
+	Group string
+	// Identity uniquely identifies this replica, e.g. its pod name.
+	Identity string
+	// LeaseDuration is how long a replica is a member after it last renewed
+	// its lease. It defaults to 15s.
+	LeaseDuration time.Duration
+	// RenewInterval is how often the lease is renewed and the members are
+	// updated. It must be less than LeaseDuration. It defaults to 5s.
+	RenewInterval time.Duration
+	Log           logr.Logger
+
+	// Events receives an event whenever the members change, and so the
+	// namespaces this replica owns. It must be read from.
+	Events chan event.GenericEvent
+
+	mu      sync.RWMutex
+	members []string
+	index   int
+}
+
+// Owns returns true if this replica reconciles the namespace. It owns no
+// namespaces until it has joined the group.
+func (s *ShardMembership) Owns(namespace string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.members) == 0 {
+		return false
+	}
+	return shardOf(namespace, len(s.members)) == s.index
+}
+
+// Start renews the lease and updates the members until the context is
+// cancelled. It implements manager.Runnable.
+func (s *ShardMembership) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.renewInterval())
+	defer ticker.Stop()
+	for {
+		if err := s.sync(ctx); err != nil {
+			s.Log.Error(err, "failed to update shard membership")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection returns false so that every replica runs it.
+// It implements manager.LeaderElectionRunnable.
+func (s *ShardMembership) NeedLeaderElection() bool {
+	return false
+}
+
+// sync renews the lease of this replica and updates the members from the
+// leases that haven't expired.
+func (s *ShardMembership) sync(ctx context.Context) error {
+	now := metav1.NewMicroTime(time.Now())
+	leases := s.Clientset.CoordinationV1().Leases(s.Namespace)
+
+	name := fmt.Sprintf("%s-shard-%s", s.Group, s.Identity)
+	lease, err := leases.Get(ctx, name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		seconds := int32(s.leaseDuration().Seconds())
+		lease, err = leases.Create(ctx, &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: s.Namespace,
+				Labels:    map[string]string{shardGroupLabel: s.Group},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &s.Identity,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("creating lease %s: %w", name, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("getting lease %s: %w", name, err)
+	} else {
+		lease.Spec.RenewTime = &now
+		if _, err := leases.Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("renewing lease %s: %w", name, err)
+		}
+	}
+
+	list, err := leases.List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", shardGroupLabel, s.Group)})
+	if err != nil {
+		return fmt.Errorf("listing leases: %w", err)
+	}
+	members := activeMembers(list.Items, now.Time)
+	s.deleteExpiredLeases(ctx, list.Items, now.Time)
+
+	s.mu.Lock()
+	changed := !reflect.DeepEqual(members, s.members)
+	if changed {
+		s.members = members
+		// The lease of this replica may be missing from the members, e.g. if it
+		// couldn't be renewed in time. It then owns no namespaces until it is
+		// a member again, since its shard is another replica's.
+		s.index = sort.SearchStrings(members, s.Identity)
+		if s.index == len(members) || members[s.index] != s.Identity {
+			s.index = -1
+		}
+	}
+	s.mu.Unlock()
+
+	if changed {
+		s.Log.Info("shard members changed", "members", members, "identity", s.Identity)
+		select {
+		case s.Events <- event.GenericEvent{Object: lease}:
+		case <-ctx.Done():
+		}
+	}
+	return nil
+}
+
+func (s *ShardMembership) leaseDuration() time.Duration {
+	if s.LeaseDuration > 0 {
+		return s.LeaseDuration
+	}
+	return defaultShardLeaseDuration
+}
+
+func (s *ShardMembership) renewInterval() time.Duration {
+	if s.RenewInterval > 0 {
+		return s.RenewInterval
+	}
+	return defaultShardRenewInterval
+}
+
+// deleteExpiredLeases deletes the leases of the group that expired more than a lease duration ago.
+// Every replica has its own lease, named after its pod, so without this a lease would be left
+// behind by every pod that is replaced. A lease is only deleted if it hasn't been renewed since it
+// was listed, and a replica that was only slow creates its lease again on its next sync.
+func (s *ShardMembership) deleteExpiredLeases(ctx context.Context, leases []coordinationv1.Lease, now time.Time) {
+	for _, lease := range leases {
+		expiry, ok := leaseExpiry(lease)
+		if !ok || expiry.Add(time.Duration(*lease.Spec.LeaseDurationSeconds)*time.Second).After(now) {
+			continue
+		}
+		resourceVersion := lease.ResourceVersion
+		err := s.Clientset.CoordinationV1().Leases(s.Namespace).Delete(ctx, lease.Name, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &lease.UID, ResourceVersion: &resourceVersion},
+		})
+		if err != nil && !k8serrors.IsNotFound(err) && !k8serrors.IsConflict(err) {
+			s.Log.Error(err, "failed to delete expired lease", "name", lease.Name)
+			continue
+		}
+		if err == nil {
+			s.Log.Info("deleted expired lease", "name", lease.Name)
+		}
+	}
+}
+
+// activeMembers returns the sorted holders of the leases that haven't expired.
+func activeMembers(leases []coordinationv1.Lease, now time.Time) []string {
+	var members []string
+	for _, lease := range leases {
+		if expiry, ok := leaseExpiry(lease); ok && expiry.After(now) {
+			members = append(members, *lease.Spec.HolderIdentity)
+		}
+	}
+	sort.Strings(members)
+	return members
+}
+
+// leaseExpiry returns when the lease expires. It returns false if the lease isn't held.
+func leaseExpiry(lease coordinationv1.Lease) (time.Time, bool) {
+	spec := lease.Spec
+	if spec.HolderIdentity == nil || spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+		return time.Time{}, false
+	}
+	return spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second), true
+}
+
+// shardOf returns the shard of the namespace out of the given number of shards.
+func shardOf(namespace string, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(namespace))
+	return int(h.Sum32() % uint32(shards))
+}
@@ -0,0 +1,220 @@
+package connectinject
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/stretchr/testify/require"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestShardMembership_OwnsEachNamespaceOnce(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+
+	var replicas []*ShardMembership
+	for _, identity := range []string{"injector-a", "injector-b", "injector-c"} {
+		replicas = append(replicas, &ShardMembership{
+			Clientset: client,
+			Namespace: "consul",
+			Group:     "consul-connect-injector",
+			Identity:  identity,
+			Log:       logrtest.TestLogger{T: t},
+			Events:    make(chan event.GenericEvent, 10),
+		})
+	}
+
+	// A replica owns no namespaces until it has joined.
+	require.False(t, replicas[0].Owns("default"))
+
+	// Sync twice so that the replicas that joined first see the later ones.
+	for i := 0; i < 2; i++ {
+		for _, r := range replicas {
+			require.NoError(t, r.sync(ctx))
+		}
+	}
+
+	owned := make(map[string]int)
+	for i := 0; i < 50; i++ {
+		ns := fmt.Sprintf("ns-%d", i)
+		for _, r := range replicas {
+			if r.Owns(ns) {
+				owned[ns]++
+			}
+		}
+		require.Equal(t, 1, owned[ns], "namespace %s", ns)
+	}
+
+	// Every replica owns some of the namespaces.
+	for _, r := range replicas {
+		var count int
+		for ns := range owned {
+			if r.Owns(ns) {
+				count++
+			}
+		}
+		require.NotZero(t, count, "replica %s", r.Identity)
+	}
+
+	// The last replica to join was notified once, the others twice.
+	require.Len(t, replicas[0].Events, 2)
+	require.Len(t, replicas[2].Events, 1)
+}
+
+func TestShardMembership_IgnoresExpiredAndOtherGroups(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	expired := metav1.NewMicroTime(time.Now().Add(-time.Minute))
+	seconds := int32(15)
+	lease := func(name, group, holder string) *coordinationv1.Lease {
+		return &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "consul",
+				Labels:    map[string]string{shardGroupLabel: group},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &seconds,
+				RenewTime:            &expired,
+			},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		lease("consul-connect-injector-shard-injector-a", "consul-connect-injector", "injector-a"),
+		lease("other-connect-injector-shard-injector-c", "other-connect-injector", "injector-c"),
+	)
+
+	s := &ShardMembership{
+		Clientset: client,
+		Namespace: "consul",
+		Group:     "consul-connect-injector",
+		Identity:  "injector-b",
+		Log:       logrtest.TestLogger{T: t},
+		Events:    make(chan event.GenericEvent, 1),
+	}
+	require.NoError(t, s.sync(ctx))
+	require.Equal(t, []string{"injector-b"}, s.members)
+	require.Len(t, s.Events, 1)
+
+	// The only member owns every namespace.
+	for _, ns := range []string{"default", "kube-system", "foo"} {
+		require.True(t, s.Owns(ns))
+	}
+
+	// Its lease has been created and is renewed on the next sync.
+	created, err := client.CoordinationV1().Leases("consul").Get(ctx, "consul-connect-injector-shard-injector-b", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NoError(t, s.sync(ctx))
+	renewed, err := client.CoordinationV1().Leases("consul").Get(ctx, "consul-connect-injector-shard-injector-b", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.False(t, renewed.Spec.RenewTime.Before(created.Spec.RenewTime))
+
+	// The members haven't changed, so there is no new event.
+	require.Len(t, s.Events, 1)
+}
+
+func TestShardMembership_DeletesExpiredLeases(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	seconds := int32(15)
+	lease := func(name, group string, renewed time.Duration) *coordinationv1.Lease {
+		holder := name
+		renewTime := metav1.NewMicroTime(time.Now().Add(-renewed))
+		return &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "consul",
+				Labels:    map[string]string{shardGroupLabel: group},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &seconds,
+				RenewTime:            &renewTime,
+			},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		// Expired more than a lease duration ago.
+		lease("consul-connect-injector-shard-injector-a", "consul-connect-injector", time.Minute),
+		// Expired less than a lease duration ago.
+		lease("consul-connect-injector-shard-injector-c", "consul-connect-injector", 20*time.Second),
+		// Expired long ago but in another group.
+		lease("other-connect-injector-shard-injector-d", "other-connect-injector", time.Hour),
+	)
+
+	s := &ShardMembership{
+		Clientset: client,
+		Namespace: "consul",
+		Group:     "consul-connect-injector",
+		Identity:  "injector-b",
+		Log:       logrtest.TestLogger{T: t},
+		Events:    make(chan event.GenericEvent, 1),
+	}
+	require.NoError(t, s.sync(ctx))
+	require.Equal(t, []string{"injector-b"}, s.members)
+
+	leases, err := client.CoordinationV1().Leases("consul").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	var names []string
+	for _, l := range leases.Items {
+		names = append(names, l.Name)
+	}
+	require.ElementsMatch(t, []string{
+		"consul-connect-injector-shard-injector-b",
+		"consul-connect-injector-shard-injector-c",
+		"other-connect-injector-shard-injector-d",
+	}, names)
+}
+
+func TestShardMembership_OwnsNothingWithoutOwnLease(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	now := metav1.NewMicroTime(time.Now())
+	lease := func(holder string, seconds int32) *coordinationv1.Lease {
+		return &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "consul-connect-injector-shard-" + holder,
+				Namespace: "consul",
+				Labels:    map[string]string{shardGroupLabel: "consul-connect-injector"},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &seconds,
+				RenewTime:            &now,
+			},
+		}
+	}
+	// The lease of injector-a expires as soon as it is renewed, so it isn't
+	// one of the members although it sorts before injector-b.
+	client := fake.NewSimpleClientset(
+		lease("injector-a", 0),
+		lease("injector-b", 60),
+	)
+
+	s := &ShardMembership{
+		Clientset: client,
+		Namespace: "consul",
+		Group:     "consul-connect-injector",
+		Identity:  "injector-a",
+		Log:       logrtest.TestLogger{T: t},
+		Events:    make(chan event.GenericEvent, 1),
+	}
+	require.NoError(t, s.sync(ctx))
+	require.Equal(t, []string{"injector-b"}, s.members)
+
+	// The namespaces are injector-b's.
+	for _, ns := range []string{"default", "kube-system", "foo"} {
+		require.False(t, s.Owns(ns))
+	}
+}
@@ -0,0 +1,93 @@
+package connectinject
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// annotationPrefix is the prefix of the annotations of the sidecar settings. The keys of the
+// namespace sidecar config ConfigMap are the annotations without it, since ConfigMap keys
+// can't contain a slash.
+const annotationPrefix = "consul.hashicorp.com/"
+
+// namespaceSidecarConfigAnnotations are the pod annotations that the namespace sidecar config
+// ConfigMap can set for the pods in its namespace.
+var namespaceSidecarConfigAnnotations = []string{
+	annotationSidecarProxyCPULimit,
+	annotationSidecarProxyCPURequest,
+	annotationSidecarProxyMemoryLimit,
+	annotationSidecarProxyMemoryRequest,
+	annotationConsulSidecarCPULimit,
+	annotationConsulSidecarCPURequest,
+	annotationConsulSidecarMemoryLimit,
+	annotationConsulSidecarMemoryRequest,
+	annotationEnvoyExtraArgs,
+	annotationEnvoyConcurrency,
+	annotationEnvoyOverloadMaxHeapSize,
+	annotationEnvoyOverloadShrinkHeapThreshold,
+	annotationEnvoyOverloadStopAcceptingRequestsThreshold,
+	annotationEnvoyBootstrapExtraConfig,
+	annotationHoldApplicationUntilProxyStarts,
+}
+
+// namespaceSidecarConfig returns the sidecar settings of the namespace, keyed by their pod
+// annotation. They are read from the ConfigMap named NamespaceSidecarConfigMapName in the
+// namespace, if it is set and the ConfigMap exists.
+func (h *Handler) namespaceSidecarConfig(ctx context.Context, namespace string) (map[string]string, error) {
+	if h.NamespaceSidecarConfigMapName == "" {
+		return nil, nil
+	}
+	configMap, err := h.Clientset.CoreV1().ConfigMaps(namespace).Get(ctx, h.NamespaceSidecarConfigMapName, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting sidecar config ConfigMap %s/%s: %s", namespace, h.NamespaceSidecarConfigMapName, err)
+	}
+	return parseNamespaceSidecarConfig(configMap)
+}
+
+// parseNamespaceSidecarConfig returns the sidecar settings of the ConfigMap keyed by their pod
+// annotation. Unknown keys are rejected rather than ignored so that a misspelt setting doesn't
+// silently fall back to the default.
+func parseNamespaceSidecarConfig(configMap *corev1.ConfigMap) (map[string]string, error) {
+	config := make(map[string]string, len(configMap.Data))
+	var unknown []string
+	for key, value := range configMap.Data {
+		annotation := annotationPrefix + key
+		if !sliceContains(namespaceSidecarConfigAnnotations, annotation) {
+			unknown = append(unknown, key)
+			continue
+		}
+		config[annotation] = value
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("sidecar config ConfigMap %s/%s has unknown keys: %s",
+			configMap.Namespace, configMap.Name, strings.Join(unknown, ", "))
+	}
+	return config, nil
+}
+
+// applyNamespaceSidecarConfig sets the annotations of the namespace sidecar settings that the
+// pod doesn't set itself. Settings are resolved from the pod annotations first, then from the
+// namespace, and then from the defaults of the handler, which come from the Helm values.
+func applyNamespaceSidecarConfig(pod *corev1.Pod, config map[string]string) {
+	if len(config) == 0 {
+		return
+	}
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	for annotation, value := range config {
+		if _, ok := pod.Annotations[annotation]; !ok {
+			pod.Annotations[annotation] = value
+		}
+	}
+}
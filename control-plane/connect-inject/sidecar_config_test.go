@@ -0,0 +1,166 @@
+package connectinject
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set"
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestParseNamespaceSidecarConfig(t *testing.T) {
+	cases := map[string]struct {
+		data     map[string]string
+		expected map[string]string
+		expErr   string
+	}{
+		"empty": {
+			expected: map[string]string{},
+		},
+		"known keys": {
+			data: map[string]string{
+				"sidecar-proxy-cpu-limit":             "200m",
+				"envoy-concurrency":                   "2",
+				"hold-application-until-proxy-starts": "true",
+			},
+			expected: map[string]string{
+				annotationSidecarProxyCPULimit:            "200m",
+				annotationEnvoyConcurrency:                "2",
+				annotationHoldApplicationUntilProxyStarts: "true",
+			},
+		},
+		"unknown keys": {
+			data: map[string]string{
+				"sidecar-proxy-cpu-limit": "200m",
+				"sidecar-proxy-cpu-limt":  "200m",
+				"connect-service":         "web",
+			},
+			expErr: "sidecar config ConfigMap default/consul-sidecar-config has unknown keys: connect-service, sidecar-proxy-cpu-limt",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			config, err := parseNamespaceSidecarConfig(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "consul-sidecar-config", Namespace: "default"},
+				Data:       c.data,
+			})
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expected, config)
+		})
+	}
+}
+
+func TestApplyNamespaceSidecarConfig(t *testing.T) {
+	pod := &corev1.Pod{}
+	applyNamespaceSidecarConfig(pod, nil)
+	require.Nil(t, pod.Annotations)
+
+	pod.Annotations = map[string]string{annotationSidecarProxyCPULimit: "100m"}
+	applyNamespaceSidecarConfig(pod, map[string]string{
+		annotationSidecarProxyCPULimit: "200m",
+		annotationEnvoyConcurrency:     "2",
+	})
+	require.Equal(t, map[string]string{
+		annotationSidecarProxyCPULimit: "100m",
+		annotationEnvoyConcurrency:     "2",
+	}, pod.Annotations)
+}
+
+// Test that the settings of the pod annotations take precedence over the settings of the
+// namespace, which take precedence over the defaults of the handler.
+func TestHandlerHandle_NamespaceSidecarConfig(t *testing.T) {
+	decoder, err := admission.NewDecoder(runtime.NewScheme())
+	require.NoError(t, err)
+
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-sidecar-config", Namespace: "default"},
+		Data: map[string]string{
+			"sidecar-proxy-cpu-limit":    "200m",
+			"sidecar-proxy-memory-limit": "256Mi",
+			"envoy-concurrency":          "2",
+		},
+	}
+
+	cases := map[string]struct {
+		configMapName       string
+		annotations         map[string]string
+		expectedCPULimit    string
+		expectedMemoryLimit string
+		expectedConcurrency bool
+	}{
+		"defaults": {
+			configMapName:       "",
+			expectedCPULimit:    "100m",
+			expectedMemoryLimit: "128Mi",
+		},
+		"namespace overrides the defaults": {
+			configMapName:       "consul-sidecar-config",
+			expectedCPULimit:    "200m",
+			expectedMemoryLimit: "256Mi",
+			expectedConcurrency: true,
+		},
+		"pod overrides the namespace": {
+			configMapName:       "consul-sidecar-config",
+			annotations:         map[string]string{annotationSidecarProxyCPULimit: "300m"},
+			expectedCPULimit:    "300m",
+			expectedMemoryLimit: "256Mi",
+			expectedConcurrency: true,
+		},
+		"missing ConfigMap": {
+			configMapName:       "missing",
+			expectedCPULimit:    "100m",
+			expectedMemoryLimit: "128Mi",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := Handler{
+				Log:                           logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet:         mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:          mapset.NewSet(),
+				DefaultProxyCPULimit:          resource.MustParse("100m"),
+				DefaultProxyMemoryLimit:       resource.MustParse("128Mi"),
+				NamespaceSidecarConfigMapName: c.configMapName,
+				decoder:                       decoder,
+				Clientset:                     fake.NewSimpleClientset(namespace, configMap),
+			}
+			resp := h.Handle(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: "default",
+					Object: encodeRaw(t, &corev1.Pod{
+						ObjectMeta: metav1.ObjectMeta{Annotations: c.annotations},
+						Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+					}),
+				},
+			})
+			require.True(t, resp.Allowed, resp.Result)
+
+			var sidecar corev1.Container
+			for _, patch := range resp.Patches {
+				if patch.Path == "/spec/containers/1" {
+					raw, err := json.Marshal(patch.Value)
+					require.NoError(t, err)
+					require.NoError(t, json.Unmarshal(raw, &sidecar))
+				}
+			}
+			require.Equal(t, envoySidecarContainer, sidecar.Name)
+			require.Equal(t, resource.MustParse(c.expectedCPULimit), sidecar.Resources.Limits[corev1.ResourceCPU])
+			require.Equal(t, resource.MustParse(c.expectedMemoryLimit), sidecar.Resources.Limits[corev1.ResourceMemory])
+			require.Equal(t, c.expectedConcurrency, sliceContains(sidecar.Command, "--concurrency"))
+		})
+	}
+}
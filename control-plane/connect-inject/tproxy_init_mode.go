@@ -0,0 +1,153 @@
+package connectinject
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// TProxyInitModeAuto selects the init mode based on the Pod Security Standard the namespace of
+	// the pod enforces.
+	TProxyInitModeAuto = "auto"
+
+	// TProxyInitModePrivileged runs the init container as a privileged root container that applies
+	// the traffic redirection rules itself.
+	TProxyInitModePrivileged = "privileged"
+
+	// TProxyInitModeNetAdmin runs the init container as root with only the capabilities iptables
+	// needs. This mode is for clusters whose policies allow the NET_ADMIN capability but not
+	// privileged containers.
+	TProxyInitModeNetAdmin = "net-admin"
+
+	// TProxyInitModeNodeHelper runs the init container as non-root without any capabilities. The
+	// init container writes the traffic redirection rules to the shared volume and the transparent
+	// proxy node helper on the node of the pod applies them in the network namespace of the pod.
+	TProxyInitModeNodeHelper = "node-helper"
+
+	netRawCapability = "NET_RAW"
+)
+
+// ValidTProxyInitMode returns whether mode is one of the supported init modes.
+func ValidTProxyInitMode(mode string) bool {
+	switch mode {
+	case TProxyInitModeAuto, TProxyInitModePrivileged, TProxyInitModeNetAdmin, TProxyInitModeNodeHelper:
+		return true
+	}
+	return false
+}
+
+// tproxyInitMode returns the init mode of the pod in transparent proxy mode. The mode is taken from
+// the pod annotation if it is set, and from the handler default otherwise. The auto mode keeps
+// the privileged init container unless the namespace enforces the baseline or restricted Pod
+// Security Standard, neither of which allow it, or the node helper is enabled on OpenShift, in
+// which case the node helper is used.
+func (h *Handler) tproxyInitMode(namespace corev1.Namespace, pod corev1.Pod) (string, error) {
+	mode := h.TProxyInitMode
+	if raw, ok := pod.Annotations[AnnotationTransparentProxyInitMode]; ok {
+		mode = raw
+	}
+	if mode == "" {
+		mode = TProxyInitModeAuto
+	}
+	if !ValidTProxyInitMode(mode) {
+		return "", fmt.Errorf("invalid transparent proxy init mode %q", mode)
+	}
+
+	if mode == TProxyInitModeAuto {
+		switch level := namespace.Labels[labelPodSecurityEnforce]; level {
+		case "baseline", "restricted":
+			if !h.EnableTProxyNodeHelper {
+				return "", fmt.Errorf("namespace %s enforces the %s Pod Security Standard which does not allow the "+
+					"capabilities needed to apply the traffic redirection rules: enable the transparent proxy node helper "+
+					"or disable transparent proxy for this pod", namespace.Name, level)
+			}
+			mode = TProxyInitModeNodeHelper
+		default:
+			mode = TProxyInitModePrivileged
+			// The restricted-v2 SecurityContextConstraints of OpenShift don't allow the privileged
+			// init container either, but pods whose service account was granted the privileged
+			// SecurityContextConstraints keep using it if the node helper isn't enabled.
+			if h.EnableOpenShift && h.EnableTProxyNodeHelper {
+				mode = TProxyInitModeNodeHelper
+			}
+		}
+	}
+
+	if mode == TProxyInitModeNodeHelper && !h.EnableTProxyNodeHelper {
+		return "", fmt.Errorf("transparent proxy init mode %q requires the transparent proxy node helper to be enabled", mode)
+	}
+	return mode, nil
+}
+
+// tproxyInitSecurityContext returns the security context of the init container in the init mode.
+func tproxyInitSecurityContext(mode string) *corev1.SecurityContext {
+	switch mode {
+	case TProxyInitModeNetAdmin:
+		// iptables needs to run as root, but only needs the NET_ADMIN and NET_RAW capabilities.
+		return &corev1.SecurityContext{
+			RunAsUser:                pointerToInt64(rootUserAndGroupID),
+			RunAsGroup:               pointerToInt64(rootUserAndGroupID),
+			RunAsNonRoot:             pointerToBool(false),
+			Privileged:               pointerToBool(false),
+			AllowPrivilegeEscalation: pointerToBool(false),
+			Capabilities: &corev1.Capabilities{
+				Add:  []corev1.Capability{netAdminCapability, netRawCapability},
+				Drop: []corev1.Capability{"ALL"},
+			},
+		}
+	case TProxyInitModeNodeHelper:
+		// The node helper applies the rules so the init container meets the restricted Pod Security Standard.
+		return &corev1.SecurityContext{
+			RunAsUser:                pointerToInt64(copyContainerUserAndGroupID),
+			RunAsGroup:               pointerToInt64(copyContainerUserAndGroupID),
+			RunAsNonRoot:             pointerToBool(true),
+			AllowPrivilegeEscalation: pointerToBool(false),
+			Capabilities: &corev1.Capabilities{
+				Drop: []corev1.Capability{"ALL"},
+			},
+			SeccompProfile: &corev1.SeccompProfile{
+				Type: corev1.SeccompProfileTypeRuntimeDefault,
+			},
+		}
+	default:
+		// Running consul connect redirect-traffic with iptables
+		// requires both being a root user and having NET_ADMIN capability.
+		return &corev1.SecurityContext{
+			RunAsUser:  pointerToInt64(rootUserAndGroupID),
+			RunAsGroup: pointerToInt64(rootUserAndGroupID),
+			// RunAsNonRoot overrides any setting in the Pod so that we can still run as root here as required.
+			RunAsNonRoot: pointerToBool(false),
+			Privileged:   pointerToBool(true),
+			Capabilities: &corev1.Capabilities{
+				Add: []corev1.Capability{netAdminCapability},
+			},
+		}
+	}
+}
+
+// restrictInjectedContainers tightens the security contexts of the containers the handler injected
+// so that the pod can meet the restricted Pod Security Standard in node helper mode.
+func restrictInjectedContainers(pod *corev1.Pod) {
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for i := range containers {
+			switch containers[i].Name {
+			case InjectInitCopyContainerName, envoySidecarContainer, consulSidecarContainer:
+			default:
+				continue
+			}
+			if containers[i].SecurityContext == nil {
+				containers[i].SecurityContext = &corev1.SecurityContext{}
+			}
+			sc := containers[i].SecurityContext
+			sc.RunAsNonRoot = pointerToBool(true)
+			sc.AllowPrivilegeEscalation = pointerToBool(false)
+			sc.Capabilities = &corev1.Capabilities{
+				Drop: []corev1.Capability{"ALL"},
+			}
+			sc.SeccompProfile = &corev1.SeccompProfile{
+				Type: corev1.SeccompProfileTypeRuntimeDefault,
+			}
+		}
+	}
+}
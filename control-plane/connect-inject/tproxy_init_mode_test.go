@@ -0,0 +1,45 @@
+package connectinject
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRestrictInjectedContainers(t *testing.T) {
+	h := Handler{}
+	pod := corev1.Pod{
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{h.initCopyContainer()},
+			Containers: []corev1.Container{
+				{
+					Name: "web",
+				},
+				{
+					Name: envoySidecarContainer,
+					SecurityContext: &corev1.SecurityContext{
+						RunAsUser: pointerToInt64(envoyUserAndGroupID),
+					},
+				},
+				{
+					Name: consulSidecarContainer,
+				},
+			},
+		},
+	}
+
+	restrictInjectedContainers(&pod)
+
+	// The application container is left alone.
+	require.Nil(t, pod.Spec.Containers[0].SecurityContext)
+	for _, c := range []corev1.Container{pod.Spec.InitContainers[0], pod.Spec.Containers[1], pod.Spec.Containers[2]} {
+		require.Equal(t, pointerToBool(true), c.SecurityContext.RunAsNonRoot, c.Name)
+		require.Equal(t, pointerToBool(false), c.SecurityContext.AllowPrivilegeEscalation, c.Name)
+		require.Equal(t, []corev1.Capability{"ALL"}, c.SecurityContext.Capabilities.Drop, c.Name)
+		require.Equal(t, corev1.SeccompProfileTypeRuntimeDefault, c.SecurityContext.SeccompProfile.Type, c.Name)
+	}
+	// Existing settings are kept.
+	require.Equal(t, pointerToInt64(envoyUserAndGroupID), pod.Spec.Containers[1].SecurityContext.RunAsUser)
+	require.Equal(t, pointerToInt64(copyContainerUserAndGroupID), pod.Spec.InitContainers[0].SecurityContext.RunAsUser)
+}
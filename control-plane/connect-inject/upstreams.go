@@ -0,0 +1,231 @@
+package connectinject
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Labels of the qualifiers of an upstream in the labeled format of the
+// upstreams annotation, e.g. `api.svc.ns1.ns.ap1.ap:1234`.
+const (
+	upstreamLabelService    = "svc"
+	upstreamLabelNamespace  = "ns"
+	upstreamLabelPartition  = "ap"
+	upstreamLabelPeer       = "peer"
+	upstreamLabelDatacenter = "dc"
+)
+
+// parsedUpstream is an upstream of the upstreams annotation along with the
+// string it was parsed from.
+type parsedUpstream struct {
+	raw string
+	api.Upstream
+}
+
+// parseUpstreams parses the upstreams annotation of the pod. Each upstream is
+// in one of the formats:
+//
+//	<service>[.<namespace>[.<partition>]]:<port>[:<datacenter>]
+//	<service>.svc[.<namespace>.ns][.<partition>.ap][.<peer>.peer][.<datacenter>.dc]:<port>
+//	prepared_query:<query>:<port>
+//
+// where the port is a number or the name of a port of the pod. The namespace
+// and partition of the first format are only parsed if Consul namespaces or
+// partitions are enabled, otherwise they are part of the service name.
+//
+// The upstreams that are valid are returned even if others are not, along with
+// an error that describes each of the invalid upstreams.
+func parseUpstreams(pod corev1.Pod, enableNamespaces, enablePartitions bool) ([]parsedUpstream, error) {
+	raw, ok := pod.Annotations[annotationUpstreams]
+	if !ok || raw == "" {
+		return nil, nil
+	}
+
+	var upstreams []parsedUpstream
+	var errs []string
+	for _, raw := range strings.Split(raw, ",") {
+		upstream, err := parseUpstream(pod, strings.TrimSpace(raw), enableNamespaces, enablePartitions)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		upstreams = append(upstreams, parsedUpstream{raw: strings.TrimSpace(raw), Upstream: upstream})
+	}
+	if len(errs) > 0 {
+		return upstreams, fmt.Errorf("invalid %q annotation: %s", annotationUpstreams, strings.Join(errs, "; "))
+	}
+	return upstreams, nil
+}
+
+// parseUpstream parses a single upstream of the upstreams annotation. Errors
+// include the interpretation of the part of the upstream parsed before the
+// error so that it is clear which field is at fault.
+func parseUpstream(pod corev1.Pod, raw string, enableNamespaces, enablePartitions bool) (api.Upstream, error) {
+	upstream := api.Upstream{DestinationType: api.UpstreamDestTypeService}
+	invalid := func(format string, args ...interface{}) (api.Upstream, error) {
+		msg := fmt.Sprintf("upstream %q is invalid: %s", raw, fmt.Sprintf(format, args...))
+		if upstream.DestinationName != "" {
+			msg += fmt.Sprintf(" (parsed as %s)", describeUpstream(upstream))
+		}
+		return api.Upstream{}, errors.New(msg)
+	}
+
+	if raw == "" {
+		return invalid("it is empty")
+	}
+	parts := strings.Split(raw, ":")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	var port string
+	switch {
+	case parts[0] == "prepared_query":
+		if len(parts) != 3 {
+			return invalid("prepared query upstreams must be in the format prepared_query:<query>:<port>")
+		}
+		upstream.DestinationType = api.UpstreamDestTypePreparedQuery
+		upstream.DestinationName = parts[1]
+		if upstream.DestinationName == "" {
+			return invalid("the prepared query name is empty")
+		}
+		port = parts[2]
+	case isLabeledUpstream(parts[0]):
+		if len(parts) != 2 {
+			return invalid("upstreams with labels must be in the format <service>.svc[.<namespace>.ns][.<partition>.ap][.<peer>.peer][.<datacenter>.dc]:<port>")
+		}
+		if err := parseUpstreamLabels(&upstream, parts[0]); err != nil {
+			return invalid("%s", err)
+		}
+		if upstream.DestinationNamespace != "" && !enableNamespaces {
+			return invalid("a namespace is set but Consul namespaces are not enabled")
+		}
+		if upstream.DestinationPartition != "" && !enablePartitions {
+			return invalid("a partition is set but Consul admin partitions are not enabled")
+		}
+		if upstream.DestinationPeer != "" && upstream.Datacenter != "" {
+			return invalid("a peer and a datacenter can't both be set")
+		}
+		if upstream.DestinationPeer != "" && upstream.DestinationPartition != "" {
+			return invalid("a peer and a partition can't both be set")
+		}
+		port = parts[1]
+	default:
+		if len(parts) < 2 || len(parts) > 3 {
+			return invalid("upstreams must be in the format <service>[.<namespace>[.<partition>]]:<port>[:<datacenter>]")
+		}
+		upstream.DestinationName = parts[0]
+		if enableNamespaces || enablePartitions {
+			pieces := strings.Split(parts[0], ".")
+			if len(pieces) > 3 {
+				return invalid("the service %q has more than 3 dot-separated parts: <service>.<namespace>.<partition>", parts[0])
+			}
+			upstream.DestinationName = pieces[0]
+			if len(pieces) > 1 {
+				upstream.DestinationNamespace = pieces[1]
+			}
+			if len(pieces) > 2 {
+				upstream.DestinationPartition = pieces[2]
+			}
+		}
+		if upstream.DestinationName == "" {
+			return invalid("the service name is empty")
+		}
+		port = parts[1]
+		if len(parts) == 3 {
+			if parts[2] == "" {
+				return invalid("the datacenter is empty")
+			}
+			upstream.Datacenter = parts[2]
+		}
+	}
+
+	if port == "" {
+		return invalid("the local port is empty")
+	}
+	localPort, err := portValue(pod, port)
+	if err != nil || localPort <= 0 || localPort > 65535 {
+		return invalid("the local port %q is neither a port number nor the name of a port of the pod", port)
+	}
+	upstream.LocalBindPort = int(localPort)
+	return upstream, nil
+}
+
+// isLabeledUpstream returns true if the service part of an upstream is in the
+// labeled format, i.e. its name is followed by the "svc" label.
+func isLabeledUpstream(service string) bool {
+	pieces := strings.Split(service, ".")
+	return len(pieces) > 1 && strings.TrimSpace(pieces[1]) == upstreamLabelService
+}
+
+// parseUpstreamLabels sets the destination of the upstream from the service
+// part of an upstream in the labeled format, where each value is followed by
+// its label.
+func parseUpstreamLabels(upstream *api.Upstream, service string) error {
+	pieces := strings.Split(service, ".")
+	if len(pieces)%2 != 0 {
+		return fmt.Errorf("%q is not a list of <value>.<label> pairs", service)
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < len(pieces); i += 2 {
+		value, label := strings.TrimSpace(pieces[i]), strings.TrimSpace(pieces[i+1])
+		if value == "" {
+			return fmt.Errorf("the value of the %q label is empty", label)
+		}
+		if seen[label] {
+			return fmt.Errorf("the %q label is set more than once", label)
+		}
+		seen[label] = true
+
+		switch label {
+		case upstreamLabelService:
+			if i != 0 {
+				return fmt.Errorf("the %q label must be first", upstreamLabelService)
+			}
+			upstream.DestinationName = value
+		case upstreamLabelNamespace:
+			upstream.DestinationNamespace = value
+		case upstreamLabelPartition:
+			upstream.DestinationPartition = value
+		case upstreamLabelPeer:
+			upstream.DestinationPeer = value
+		case upstreamLabelDatacenter:
+			upstream.Datacenter = value
+		default:
+			return fmt.Errorf("unknown label %q, must be one of %q, %q, %q, %q or %q", label,
+				upstreamLabelService, upstreamLabelNamespace, upstreamLabelPartition, upstreamLabelPeer, upstreamLabelDatacenter)
+		}
+	}
+	return nil
+}
+
+// describeUpstream describes the upstream in words, e.g. `service "api" in
+// namespace "ns1" on local port 1234`.
+func describeUpstream(upstream api.Upstream) string {
+	var desc string
+	if upstream.DestinationType == api.UpstreamDestTypePreparedQuery {
+		desc = fmt.Sprintf("prepared query %q", upstream.DestinationName)
+	} else {
+		desc = fmt.Sprintf("service %q", upstream.DestinationName)
+	}
+	if upstream.DestinationNamespace != "" {
+		desc += fmt.Sprintf(" in namespace %q", upstream.DestinationNamespace)
+	}
+	if upstream.DestinationPartition != "" {
+		desc += fmt.Sprintf(" in partition %q", upstream.DestinationPartition)
+	}
+	if upstream.DestinationPeer != "" {
+		desc += fmt.Sprintf(" of peer %q", upstream.DestinationPeer)
+	}
+	if upstream.Datacenter != "" {
+		desc += fmt.Sprintf(" in datacenter %q", upstream.Datacenter)
+	}
+	if upstream.LocalBindPort > 0 {
+		desc += fmt.Sprintf(" on local port %d", upstream.LocalBindPort)
+	}
+	return desc
+}
@@ -0,0 +1,185 @@
+package connectinject
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseUpstreams(t *testing.T) {
+	cases := []struct {
+		name             string
+		annotation       string
+		enableNamespaces bool
+		enablePartitions bool
+		expected         []api.Upstream
+		expErr           string
+	}{
+		{
+			name:       "service",
+			annotation: "api:1234",
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "api", LocalBindPort: 1234},
+			},
+		},
+		{
+			name:       "service with datacenter and named port",
+			annotation: "api:http:dc2",
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "api", Datacenter: "dc2", LocalBindPort: 8080},
+			},
+		},
+		{
+			name:       "dots are part of the service name without namespaces",
+			annotation: "api.foo:1234",
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "api.foo", LocalBindPort: 1234},
+			},
+		},
+		{
+			name:             "service with namespace and partition",
+			annotation:       "api.foo.bar:1234",
+			enableNamespaces: true,
+			enablePartitions: true,
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "api", DestinationNamespace: "foo", DestinationPartition: "bar", LocalBindPort: 1234},
+			},
+		},
+		{
+			name:       "prepared query",
+			annotation: "prepared_query:query:1234",
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypePreparedQuery, DestinationName: "query", LocalBindPort: 1234},
+			},
+		},
+		{
+			name:             "labeled service with namespace and partition",
+			annotation:       "api.svc.foo.ns.bar.ap:1234",
+			enableNamespaces: true,
+			enablePartitions: true,
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "api", DestinationNamespace: "foo", DestinationPartition: "bar", LocalBindPort: 1234},
+			},
+		},
+		{
+			name:             "labeled service with peer",
+			annotation:       "api.svc.foo.ns.cluster-2.peer:1234",
+			enableNamespaces: true,
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "api", DestinationNamespace: "foo", DestinationPeer: "cluster-2", LocalBindPort: 1234},
+			},
+		},
+		{
+			name:       "labeled service with datacenter",
+			annotation: "api.svc.dc2.dc:1234",
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "api", Datacenter: "dc2", LocalBindPort: 1234},
+			},
+		},
+		{
+			name:       "multiple upstreams",
+			annotation: "api:1234, db.svc.cluster-2.peer:2234",
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "api", LocalBindPort: 1234},
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "db", DestinationPeer: "cluster-2", LocalBindPort: 2234},
+			},
+		},
+		{
+			name:       "missing port",
+			annotation: "api",
+			expErr:     `invalid "consul.hashicorp.com/connect-service-upstreams" annotation: upstream "api" is invalid: upstreams must be in the format <service>[.<namespace>[.<partition>]]:<port>[:<datacenter>]`,
+		},
+		{
+			name:       "empty upstream",
+			annotation: "api:1234,",
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "api", LocalBindPort: 1234},
+			},
+			expErr: `invalid "consul.hashicorp.com/connect-service-upstreams" annotation: upstream "" is invalid: it is empty`,
+		},
+		{
+			name:       "unknown named port",
+			annotation: "api:grpc:dc2",
+			expErr:     `invalid "consul.hashicorp.com/connect-service-upstreams" annotation: upstream "api:grpc:dc2" is invalid: the local port "grpc" is neither a port number nor the name of a port of the pod (parsed as service "api" in datacenter "dc2")`,
+		},
+		{
+			name:       "prepared query without port",
+			annotation: "prepared_query:query",
+			expErr:     `invalid "consul.hashicorp.com/connect-service-upstreams" annotation: upstream "prepared_query:query" is invalid: prepared query upstreams must be in the format prepared_query:<query>:<port>`,
+		},
+		{
+			name:       "labeled service with datacenter field",
+			annotation: "api.svc:1234:dc2",
+			expErr:     `invalid "consul.hashicorp.com/connect-service-upstreams" annotation: upstream "api.svc:1234:dc2" is invalid: upstreams with labels must be in the format <service>.svc[.<namespace>.ns][.<partition>.ap][.<peer>.peer][.<datacenter>.dc]:<port>`,
+		},
+		{
+			name:       "labeled service with unknown label",
+			annotation: "api.svc.foo.namespace:1234",
+			expErr:     `invalid "consul.hashicorp.com/connect-service-upstreams" annotation: upstream "api.svc.foo.namespace:1234" is invalid: unknown label "namespace", must be one of "svc", "ns", "ap", "peer" or "dc" (parsed as service "api")`,
+		},
+		{
+			name:       "labeled service without label value",
+			annotation: "api.svc.foo:1234",
+			expErr:     `invalid "consul.hashicorp.com/connect-service-upstreams" annotation: upstream "api.svc.foo:1234" is invalid: "api.svc.foo" is not a list of <value>.<label> pairs`,
+		},
+		{
+			name:       "labeled service with namespace without namespaces",
+			annotation: "api.svc.foo.ns:1234",
+			expErr:     `invalid "consul.hashicorp.com/connect-service-upstreams" annotation: upstream "api.svc.foo.ns:1234" is invalid: a namespace is set but Consul namespaces are not enabled (parsed as service "api" in namespace "foo")`,
+		},
+		{
+			name:       "labeled service with peer and datacenter",
+			annotation: "api.svc.cluster-2.peer.dc2.dc:1234",
+			expErr:     `invalid "consul.hashicorp.com/connect-service-upstreams" annotation: upstream "api.svc.cluster-2.peer.dc2.dc:1234" is invalid: a peer and a datacenter can't both be set (parsed as service "api" of peer "cluster-2" in datacenter "dc2")`,
+		},
+		{
+			name:             "labeled service with peer and partition",
+			annotation:       "api.svc.bar.ap.cluster-2.peer:1234",
+			enablePartitions: true,
+			expErr:           `invalid "consul.hashicorp.com/connect-service-upstreams" annotation: upstream "api.svc.bar.ap.cluster-2.peer:1234" is invalid: a peer and a partition can't both be set (parsed as service "api" in partition "bar" of peer "cluster-2")`,
+		},
+		{
+			name:       "labeled service with duplicate label",
+			annotation: "api.svc.dc1.dc.dc2.dc:1234",
+			expErr:     `invalid "consul.hashicorp.com/connect-service-upstreams" annotation: upstream "api.svc.dc1.dc.dc2.dc:1234" is invalid: the "dc" label is set more than once (parsed as service "api" in datacenter "dc1")`,
+		},
+		{
+			name:       "every invalid upstream is reported",
+			annotation: "api:abc, db:",
+			expErr:     `invalid "consul.hashicorp.com/connect-service-upstreams" annotation: upstream "api:abc" is invalid: the local port "abc" is neither a port number nor the name of a port of the pod (parsed as service "api"); upstream "db:" is invalid: the local port is empty (parsed as service "db")`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{annotationUpstreams: c.annotation},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "web",
+							Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+						},
+					},
+				},
+			}
+
+			parsed, err := parseUpstreams(pod, c.enableNamespaces, c.enablePartitions)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+			} else {
+				require.NoError(t, err)
+			}
+			var upstreams []api.Upstream
+			for _, upstream := range parsed {
+				upstreams = append(upstreams, upstream.Upstream)
+			}
+			require.Equal(t, c.expected, upstreams)
+		})
+	}
+}
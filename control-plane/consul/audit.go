@@ -0,0 +1,316 @@
+package consul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Operations of the writes recorded by an AuditTrail.
+const (
+	AuditOpRegister   = "register"
+	AuditOpDeregister = "deregister"
+	AuditOpWrite      = "write"
+	AuditOpDelete     = "delete"
+)
+
+// Kinds of the objects written to Consul besides config entries, which are
+// recorded with their own kind, e.g. "service-intentions".
+const (
+	AuditKindServiceInstance = "service-instance"
+	AuditKindCatalogService  = "catalog-service"
+	AuditKindACLToken        = "acl-token"
+)
+
+const (
+	// auditWebhookQueueSize is the number of entries that are buffered while
+	// they are sent to the webhook. Entries are dropped once it is full so
+	// that a slow webhook doesn't hold up the controllers.
+	auditWebhookQueueSize = 1024
+	auditWebhookTimeout   = 10 * time.Second
+)
+
+var auditWebhookFailuresCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "consul_k8s_audit_webhook_failures_total",
+	Help: "Number of audit entries that were not delivered to the audit webhook, because the queue was full or the request failed.",
+}, []string{"component"})
+
+func init() {
+	metrics.Registry.MustRegister(auditWebhookFailuresCounter)
+}
+
+// AuditEntry is a write to Consul made by a component.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// Component is the component that made the write, e.g. "connect-injector".
+	Component string `json:"component"`
+	// Operation is one of AuditOpRegister, AuditOpDeregister, AuditOpWrite
+	// and AuditOpDelete.
+	Operation string `json:"operation"`
+	// Kind is the kind of the object written, e.g. AuditKindServiceInstance
+	// or the kind of a config entry.
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Partition string `json:"partition,omitempty"`
+	// Source is the Kubernetes object the write was made for, e.g.
+	// "Pod default/web-7d9f6-abcde".
+	Source string `json:"source,omitempty"`
+	// Diff is the difference between the object before and after the write.
+	// Unless the component read the object before writing it, it is the
+	// difference to what the component last wrote for it, so writes of
+	// objects the component hasn't written since it started show the whole
+	// object.
+	Diff string `json:"diff,omitempty"`
+}
+
+// AuditWrite describes a write to Consul that is recorded by an AuditTrail.
+type AuditWrite struct {
+	Operation string
+	Kind      string
+	Name      string
+	Namespace string
+	Partition string
+	// Payload is what was written, e.g. the service registration. It is nil
+	// for deregistrations and deletes.
+	Payload interface{}
+	// Previous is the object in Consul before the write, if the component
+	// read it. Otherwise the diff is against the payload the component last
+	// wrote for the object.
+	Previous interface{}
+}
+
+// AuditTrail records the writes a component makes to Consul in its logs, and
+// optionally as Kubernetes events and by posting them to a webhook. A nil
+// AuditTrail records nothing.
+//
+// Controllers rewrite what they manage on every reconcile, so writes that
+// don't change what the component last wrote for the object are not
+// recorded.
+type AuditTrail struct {
+	// Component is the name of the component in the entries, e.g.
+	// "connect-injector".
+	Component string
+	Log       logr.Logger
+	// Recorder records an event on the Kubernetes object each write is made
+	// for, if set.
+	Recorder record.EventRecorder
+	// WebhookURL is the URL the entries are posted to as JSON, if set. The
+	// entries are posted by Start.
+	WebhookURL string
+
+	mu sync.Mutex
+	// written is the JSON of the payload last written for each object.
+	written map[string][]byte
+
+	queueOnce sync.Once
+	queue     chan AuditEntry
+	// client and now are only set in tests.
+	client *http.Client
+	now    func() time.Time
+}
+
+// Record records a write to Consul that succeeded. Obj is the Kubernetes
+// object the write was made for and may be nil.
+func (a *AuditTrail) Record(obj runtime.Object, w AuditWrite) {
+	if a == nil {
+		return
+	}
+	diff, changed := a.diff(w)
+	if !changed {
+		return
+	}
+
+	entry := AuditEntry{
+		Time:      a.clock(),
+		Component: a.Component,
+		Operation: w.Operation,
+		Kind:      w.Kind,
+		Name:      w.Name,
+		Namespace: w.Namespace,
+		Partition: w.Partition,
+		Source:    auditSource(obj),
+		Diff:      diff,
+	}
+	a.Log.Info("Consul write", "operation", entry.Operation, "kind", entry.Kind, "name", entry.Name,
+		"namespace", entry.Namespace, "partition", entry.Partition, "source", entry.Source, "diff", entry.Diff)
+	if a.Recorder != nil && obj != nil {
+		a.Recorder.Eventf(obj, corev1.EventTypeNormal, "ConsulWrite", "Consul %s of %s %q", entry.Operation, entry.Kind, entry.Name)
+	}
+	if a.WebhookURL != "" {
+		select {
+		case a.webhookQueue() <- entry:
+		default:
+			a.Log.Info("audit webhook queue is full, dropping entry", "kind", entry.Kind, "name", entry.Name)
+			auditWebhookFailuresCounter.WithLabelValues(a.Component).Inc()
+		}
+	}
+}
+
+// Start posts the entries to the webhook until the context is cancelled. It
+// returns immediately if WebhookURL isn't set. It implements
+// manager.Runnable.
+func (a *AuditTrail) Start(ctx context.Context) error {
+	if a.WebhookURL == "" {
+		return nil
+	}
+	queue := a.webhookQueue()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry := <-queue:
+			if err := a.post(ctx, entry); err != nil {
+				a.Log.Error(err, "failed to send audit entry to webhook", "kind", entry.Kind, "name", entry.Name)
+				auditWebhookFailuresCounter.WithLabelValues(a.Component).Inc()
+			}
+		}
+	}
+}
+
+// NeedLeaderElection returns false so that the entries of every replica are
+// sent. It implements manager.LeaderElectionRunnable.
+func (a *AuditTrail) NeedLeaderElection() bool {
+	return false
+}
+
+// diff returns the difference between the payload of the write and the
+// previous object or the payload last written for the object, and false if
+// the write doesn't change the payload last written.
+func (a *AuditTrail) diff(w AuditWrite) (string, bool) {
+	key := fmt.Sprintf("%s/%s/%s/%s", w.Kind, w.Partition, w.Namespace, w.Name)
+	payload := a.encode(w, w.Payload)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.written == nil {
+		a.written = make(map[string][]byte)
+	}
+	last, ok := a.written[key]
+	if w.Payload == nil {
+		delete(a.written, key)
+	} else {
+		if ok && bytes.Equal(last, payload) {
+			return "", false
+		}
+		a.written[key] = payload
+	}
+	if w.Previous != nil {
+		last = a.encode(w, w.Previous)
+	}
+	return cmp.Diff(decodeJSON(last), decodeJSON(payload)), true
+}
+
+// encode returns the JSON of the object of the write, or nil if there is
+// none. The write is still recorded if it can't be encoded, only without its
+// diff.
+func (a *AuditTrail) encode(w AuditWrite, obj interface{}) []byte {
+	if obj == nil {
+		return nil
+	}
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		a.Log.Error(err, "failed to encode audited object", "kind", w.Kind, "name", w.Name)
+		return nil
+	}
+	return raw
+}
+
+func (a *AuditTrail) post(ctx context.Context, entry AuditEntry) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, auditWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := a.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (a *AuditTrail) webhookQueue() chan AuditEntry {
+	a.queueOnce.Do(func() {
+		a.queue = make(chan AuditEntry, auditWebhookQueueSize)
+	})
+	return a.queue
+}
+
+func (a *AuditTrail) clock() time.Time {
+	if a.now != nil {
+		return a.now()
+	}
+	return time.Now()
+}
+
+// auditSource returns the kind, namespace and name of the Kubernetes object,
+// e.g. "Pod default/web-7d9f6-abcde".
+func auditSource(obj runtime.Object) string {
+	if obj == nil {
+		return ""
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if kind == "" {
+		// Typed objects usually don't have their type meta set.
+		t := reflect.TypeOf(obj)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		kind = t.Name()
+	}
+	name := accessor.GetName()
+	if ns := accessor.GetNamespace(); ns != "" {
+		name = ns + "/" + name
+	}
+	return kind + " " + name
+}
+
+// decodeJSON decodes the JSON so that payloads are compared field by field,
+// or returns nil if there is none. The Raft indexes of objects read from
+// Consul are left out since they change on every write.
+func decodeJSON(raw []byte) interface{} {
+	if raw == nil {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil
+	}
+	if obj, ok := v.(map[string]interface{}); ok {
+		delete(obj, "CreateIndex")
+		delete(obj, "ModifyIndex")
+	}
+	return v
+}
@@ -0,0 +1,158 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testing"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestAuditTrail_Record(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-7d9f6-abcde", Namespace: "default"}}
+	recorder := record.NewFakeRecorder(10)
+	audit := &AuditTrail{
+		Component:  "connect-injector",
+		Log:        logrtest.TestLogger{T: t},
+		Recorder:   recorder,
+		WebhookURL: "http://audit.example.com",
+	}
+	write := func(port int) AuditWrite {
+		return AuditWrite{
+			Operation: AuditOpRegister,
+			Kind:      AuditKindServiceInstance,
+			Name:      "web-7d9f6-abcde-web",
+			Namespace: "default",
+			Payload:   &capi.AgentServiceRegistration{ID: "web-7d9f6-abcde-web", Name: "web", Port: port},
+		}
+	}
+
+	// The first write shows the whole registration.
+	audit.Record(pod, write(8080))
+	entry := <-audit.webhookQueue()
+	require.Equal(t, "connect-injector", entry.Component)
+	require.Equal(t, AuditOpRegister, entry.Operation)
+	require.Equal(t, "Pod default/web-7d9f6-abcde", entry.Source)
+	require.Contains(t, changedLines(entry.Diff), `"Name"`)
+	require.Equal(t, `Normal ConsulWrite Consul register of service-instance "web-7d9f6-abcde-web"`, <-recorder.Events)
+
+	// Writes that don't change the registration aren't recorded.
+	audit.Record(pod, write(8080))
+	require.Len(t, audit.webhookQueue(), 0)
+	require.Len(t, recorder.Events, 0)
+
+	// Changes show only the fields that changed.
+	audit.Record(pod, write(9090))
+	entry = <-audit.webhookQueue()
+	require.Contains(t, changedLines(entry.Diff), "8080")
+	require.Contains(t, changedLines(entry.Diff), "9090")
+	require.NotContains(t, changedLines(entry.Diff), `"Name"`)
+	<-recorder.Events
+
+	// Deregistrations are always recorded and the next registration shows the
+	// whole registration again.
+	deregister := AuditWrite{Operation: AuditOpDeregister, Kind: AuditKindServiceInstance, Name: "web-7d9f6-abcde-web", Namespace: "default"}
+	audit.Record(pod, deregister)
+	entry = <-audit.webhookQueue()
+	require.Equal(t, AuditOpDeregister, entry.Operation)
+	require.Contains(t, entry.Diff, "9090")
+	<-recorder.Events
+	audit.Record(pod, deregister)
+	entry = <-audit.webhookQueue()
+	require.Empty(t, entry.Diff)
+	<-recorder.Events
+	audit.Record(pod, write(9090))
+	entry = <-audit.webhookQueue()
+	require.Contains(t, changedLines(entry.Diff), `"Name"`)
+}
+
+func TestAuditTrail_RecordPrevious(t *testing.T) {
+	audit := &AuditTrail{Log: logrtest.TestLogger{T: t}, WebhookURL: "http://audit.example.com"}
+	previous := &capi.ServiceConfigEntry{Kind: capi.ServiceDefaults, Name: "web", Protocol: "tcp", ModifyIndex: 10}
+	entry := &capi.ServiceConfigEntry{Kind: capi.ServiceDefaults, Name: "web", Protocol: "http"}
+
+	audit.Record(nil, AuditWrite{Operation: AuditOpWrite, Kind: capi.ServiceDefaults, Name: "web", Payload: entry, Previous: previous})
+	recorded := <-audit.webhookQueue()
+	require.Empty(t, recorded.Source)
+	require.Contains(t, changedLines(recorded.Diff), `"tcp"`)
+	require.Contains(t, changedLines(recorded.Diff), `"http"`)
+	require.NotContains(t, recorded.Diff, "ModifyIndex")
+	require.NotContains(t, changedLines(recorded.Diff), `"Name"`)
+}
+
+func TestAuditTrail_Nil(t *testing.T) {
+	var audit *AuditTrail
+	require.NotPanics(t, func() {
+		audit.Record(&corev1.Pod{}, AuditWrite{Operation: AuditOpDelete, Kind: AuditKindACLToken, Name: "accessor"})
+	})
+}
+
+func TestAuditTrail_Start(t *testing.T) {
+	received := make(chan AuditEntry, 2)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var entry AuditEntry
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&entry))
+		received <- entry
+	}))
+	defer webhook.Close()
+
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	audit := &AuditTrail{
+		Component:  "controller",
+		Log:        logrtest.TestLogger{T: t},
+		WebhookURL: webhook.URL,
+		client:     webhook.Client(),
+		now:        func() time.Time { return now },
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go audit.Start(ctx)
+
+	audit.Record(nil, AuditWrite{Operation: AuditOpDelete, Kind: capi.ServiceIntentions, Name: "web", Namespace: "apps", Partition: "ap1"})
+	select {
+	case entry := <-received:
+		require.Equal(t, AuditEntry{
+			Time:      now,
+			Component: "controller",
+			Operation: AuditOpDelete,
+			Kind:      capi.ServiceIntentions,
+			Name:      "web",
+			Namespace: "apps",
+			Partition: "ap1",
+		}, entry)
+	case <-time.After(5 * time.Second):
+		t.Fatal("audit entry was not posted to the webhook")
+	}
+}
+
+func TestAuditSource(t *testing.T) {
+	require.Equal(t, "", auditSource(nil))
+	require.Equal(t, "Pod default/web", auditSource(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}))
+	require.Equal(t, "Node node-1", auditSource(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}))
+	require.Equal(t, "Endpoints default/web", auditSource(&corev1.Endpoints{
+		TypeMeta:   metav1.TypeMeta{Kind: "Endpoints"},
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+	}))
+}
+
+// changedLines returns the lines of the diff that were added or removed.
+func changedLines(diff string) string {
+	var changed []string
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "-") || strings.HasPrefix(line, "+") {
+			changed = append(changed, line)
+		}
+	}
+	return strings.Join(changed, "\n")
+}
@@ -2,18 +2,74 @@ package consul
 
 import (
 	"fmt"
+	"net/http"
 
 	"github.com/hashicorp/consul-k8s/control-plane/version"
 	capi "github.com/hashicorp/consul/api"
+	goversion "github.com/hashicorp/go-version"
 )
 
 // NewClient returns a Consul API client. It adds a required User-Agent
-// header that describes the version of consul-k8s making the call.
+// header that describes the version of consul-k8s making the call. Requests
+// that fail to reach the Consul agent return an error wrapping an
+// *UnreachableError.
 func NewClient(config *capi.Config) (*capi.Client, error) {
 	client, err := capi.NewClient(config)
 	if err != nil {
 		return nil, err
 	}
 	client.AddHeader("User-Agent", fmt.Sprintf("consul-k8s/%s", version.GetHumanVersion()))
+	// capi.NewClient sets the HTTP client of the config if it isn't set, and
+	// the client shares it.
+	if _, ok := config.HttpClient.Transport.(*unreachableTransport); !ok {
+		config.HttpClient.Transport = &unreachableTransport{next: config.HttpClient.Transport}
+	}
 	return client, nil
 }
+
+// UnreachableError is the error of a request to Consul that didn't get a
+// response, e.g. because the agent isn't running, as opposed to a request
+// that Consul answered with an error.
+type UnreachableError struct {
+	Err error
+}
+
+func (e *UnreachableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UnreachableError) Unwrap() error {
+	return e.Err
+}
+
+// unreachableTransport wraps the errors of requests that didn't get a
+// response in an *UnreachableError, so that they can be told apart from
+// errors of the Kubernetes API, which look the same.
+type unreachableTransport struct {
+	next http.RoundTripper
+}
+
+func (t *unreachableTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, &UnreachableError{Err: err}
+	}
+	return resp, nil
+}
+
+// AgentVersion returns the version of the Consul agent the client talks to.
+func AgentVersion(client *capi.Client) (*goversion.Version, error) {
+	self, err := client.Agent().Self()
+	if err != nil {
+		return nil, err
+	}
+	raw, ok := self["Config"]["Version"].(string)
+	if !ok {
+		return nil, fmt.Errorf("agent self endpoint has no Config.Version")
+	}
+	return goversion.NewVersion(raw)
+}
@@ -1,6 +1,7 @@
 package consul
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -43,3 +44,26 @@ func TestNewClient(t *testing.T) {
 		UserAgentHeader: fmt.Sprintf("consul-k8s/%s", version.GetHumanVersion()),
 	}, consulAPICalls[0])
 }
+
+func TestNewClient_Errors(t *testing.T) {
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintln(w, "No cluster leader")
+	}))
+	client, err := NewClient(&capi.Config{Address: consulServer.URL})
+	require.NoError(t, err)
+
+	// The agent answers with an error.
+	_, err = client.Status().Leader()
+	require.Error(t, err)
+	require.True(t, IsConsulError(err))
+	var unreachable *UnreachableError
+	require.False(t, errors.As(err, &unreachable))
+
+	// The agent can't be reached.
+	consulServer.Close()
+	_, err = client.Status().Leader()
+	require.Error(t, err)
+	require.True(t, IsConsulError(fmt.Errorf("getting leader: %w", err)))
+	require.True(t, errors.As(err, &unreachable))
+}
@@ -0,0 +1,277 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-multierror"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// HealthState is the state of a component with regard to the reachability of
+// the Consul servers.
+type HealthState string
+
+const (
+	// HealthOK means the Consul servers are reachable.
+	HealthOK HealthState = "ok"
+	// HealthDegraded means the Consul servers are unreachable but the
+	// component keeps serving, e.g. the webhook injects with cached
+	// configuration and the controllers retry with backoff.
+	HealthDegraded HealthState = "degraded"
+	// HealthFailed means the Consul servers have been unreachable for longer
+	// than the component tolerates.
+	HealthFailed HealthState = "failed"
+)
+
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+
+	// minRetryInterval and maxRetryInterval bound how long reconciles are
+	// requeued for during an outage. The interval grows with the length of
+	// the outage.
+	minRetryInterval = 5 * time.Second
+	maxRetryInterval = 2 * time.Minute
+)
+
+var (
+	reachableGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "consul_k8s_consul_reachable",
+		Help: "Whether the Consul servers are reachable (1) or not (0).",
+	}, []string{"component"})
+	outageStartGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "consul_k8s_consul_outage_start_time_seconds",
+		Help: "Unix time the current outage of the Consul servers started at, or 0 if they are reachable.",
+	}, []string{"component"})
+	outagesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "consul_k8s_consul_outages_total",
+		Help: "Number of times the Consul servers became unreachable.",
+	}, []string{"component"})
+	outageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "consul_k8s_consul_outage_duration_seconds",
+		Help:    "Duration of the outages of the Consul servers that have ended.",
+		Buckets: []float64{10, 30, 60, 300, 900, 1800, 3600, 4 * 3600},
+	}, []string{"component"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(reachableGauge, outageStartGauge, outagesCounter, outageDuration)
+}
+
+// HealthTracker periodically checks that the Consul servers are reachable
+// so that components degrade gracefully during an outage instead of failing
+// every request. A nil HealthTracker always reports the servers as
+// reachable.
+type HealthTracker struct {
+	Client *capi.Client
+	// Component is the name of the component in the metrics, e.g.
+	// "connect-injector".
+	Component string
+	// CheckInterval is how often the servers are checked. It defaults to 10s.
+	CheckInterval time.Duration
+	// FailAfter is how long the servers may be unreachable before the
+	// component is failed rather than degraded. It is never failed if zero.
+	FailAfter time.Duration
+	Log       logr.Logger
+
+	mu          sync.RWMutex
+	outageStart time.Time
+	lastErr     error
+	// now is only set in tests.
+	now func() time.Time
+}
+
+// Start checks the servers until the context is cancelled. It implements
+// manager.Runnable.
+func (h *HealthTracker) Start(ctx context.Context) error {
+	h.setReachable()
+	interval := h.CheckInterval
+	if interval == 0 {
+		interval = defaultHealthCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		h.check(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection returns false so that every replica tracks the servers.
+// It implements manager.LeaderElectionRunnable.
+func (h *HealthTracker) NeedLeaderElection() bool {
+	return false
+}
+
+// check asks the agent for the leader of the servers, which fails if the
+// agent can't reach them.
+func (h *HealthTracker) check(ctx context.Context) {
+	leader, err := h.Client.Status().Leader()
+	if ctx.Err() != nil {
+		return
+	}
+	if err == nil && leader == "" {
+		err = errors.New("no cluster leader")
+	}
+	if err != nil {
+		h.setUnreachable(err)
+		return
+	}
+	h.setReachable()
+}
+
+func (h *HealthTracker) setUnreachable(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastErr = err
+	if !h.outageStart.IsZero() {
+		// Only the start of the outage is logged so that the logs aren't
+		// flooded while the servers are down.
+		return
+	}
+	h.outageStart = h.clock()
+	h.Log.Error(err, "Consul servers are unreachable, degrading until they are back")
+	reachableGauge.WithLabelValues(h.Component).Set(0)
+	outageStartGauge.WithLabelValues(h.Component).Set(float64(h.outageStart.Unix()))
+	outagesCounter.WithLabelValues(h.Component).Inc()
+}
+
+func (h *HealthTracker) setReachable() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastErr = nil
+	reachableGauge.WithLabelValues(h.Component).Set(1)
+	if h.outageStart.IsZero() {
+		return
+	}
+	outage := h.clock().Sub(h.outageStart)
+	h.outageStart = time.Time{}
+	h.Log.Info("Consul servers are reachable again", "outage", outage.Round(time.Second).String())
+	outageStartGauge.WithLabelValues(h.Component).Set(0)
+	outageDuration.WithLabelValues(h.Component).Observe(outage.Seconds())
+}
+
+// State returns the state of the component and when the current outage
+// started, which is zero if the servers are reachable.
+func (h *HealthTracker) State() (HealthState, time.Time) {
+	if h == nil {
+		return HealthOK, time.Time{}
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	switch {
+	case h.outageStart.IsZero():
+		return HealthOK, time.Time{}
+	case h.FailAfter > 0 && h.clock().Sub(h.outageStart) > h.FailAfter:
+		return HealthFailed, h.outageStart
+	default:
+		return HealthDegraded, h.outageStart
+	}
+}
+
+// Degraded returns true if the servers are unreachable.
+func (h *HealthTracker) Degraded() bool {
+	state, _ := h.State()
+	return state != HealthOK
+}
+
+// Ready returns an error if the component is failed. A degraded component is
+// still ready since it keeps serving. It is a healthz.Checker.
+func (h *HealthTracker) Ready(_ *http.Request) error {
+	state, since := h.State()
+	if state == HealthFailed {
+		return fmt.Errorf("Consul servers have been unreachable since %s", since.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// ServeHTTP serves the state as JSON so that a degraded component can be told
+// apart from a healthy one, since both are ready.
+func (h *HealthTracker) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	status := struct {
+		State       HealthState `json:"state"`
+		OutageStart *time.Time  `json:"outageStart,omitempty"`
+		Error       string      `json:"error,omitempty"`
+	}{}
+	var since time.Time
+	status.State, since = h.State()
+	if status.State != HealthOK {
+		status.OutageStart = &since
+		h.mu.RLock()
+		if h.lastErr != nil {
+			status.Error = h.lastErr.Error()
+		}
+		h.mu.RUnlock()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if status.State == HealthFailed {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+// Backoff returns the result and error of a reconcile unchanged unless it
+// failed because of Consul while the servers are unreachable. Then the error
+// is dropped so that it isn't logged on every retry, and the request is
+// requeued after an interval that grows with the length of the outage, with
+// jitter so that the retries of all requests don't hit the servers at once
+// when they are back. Other errors, e.g. of the Kubernetes API, are returned
+// even during an outage.
+func (h *HealthTracker) Backoff(result ctrl.Result, err error) (ctrl.Result, error) {
+	if err == nil {
+		return result, nil
+	}
+	state, since := h.State()
+	if state == HealthOK || !IsConsulError(err) {
+		return result, err
+	}
+	h.Log.V(1).Info("requeuing reconcile while Consul servers are unreachable", "error", err.Error())
+	interval := h.clock().Sub(since)
+	if interval < minRetryInterval {
+		interval = minRetryInterval
+	}
+	if interval > maxRetryInterval {
+		interval = maxRetryInterval
+	}
+	return ctrl.Result{RequeueAfter: wait.Jitter(interval, 0.5)}, nil
+}
+
+// IsConsulError returns true if err is an error of a request to Consul: the
+// request didn't reach the agent, or the agent answered with an error, e.g.
+// because it can't reach the servers. If err combines several errors, they
+// all have to be.
+func IsConsulError(err error) bool {
+	var merr *multierror.Error
+	if errors.As(err, &merr) {
+		for _, e := range merr.Errors {
+			if !IsConsulError(e) {
+				return false
+			}
+		}
+		return len(merr.Errors) > 0
+	}
+	var unreachable *UnreachableError
+	var status capi.StatusError
+	return errors.As(err, &unreachable) || errors.As(err, &status)
+}
+
+func (h *HealthTracker) clock() time.Time {
+	if h.now != nil {
+		return h.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,175 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testing"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/hashicorp/go-multierror"
+	"github.com/stretchr/testify/require"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestHealthTracker_Start(t *testing.T) {
+	var down int32
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&down) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`"10.0.0.1:8300"`))
+	}))
+	defer consulServer.Close()
+	client, err := capi.NewClient(&capi.Config{Address: consulServer.URL})
+	require.NoError(t, err)
+
+	tracker := &HealthTracker{
+		Client:        client,
+		Component:     "test",
+		CheckInterval: 10 * time.Millisecond,
+		Log:           logrtest.TestLogger{T: t},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go tracker.Start(ctx)
+
+	require.False(t, tracker.Degraded())
+	atomic.StoreInt32(&down, 1)
+	retry.Run(t, func(r *retry.R) {
+		require.True(r, tracker.Degraded())
+	})
+	// A degraded component is still ready.
+	require.NoError(t, tracker.Ready(nil))
+
+	atomic.StoreInt32(&down, 0)
+	retry.Run(t, func(r *retry.R) {
+		require.False(r, tracker.Degraded())
+	})
+}
+
+func TestHealthTracker_State(t *testing.T) {
+	now := time.Now()
+	tracker := &HealthTracker{
+		FailAfter: time.Minute,
+		Log:       logrtest.TestLogger{T: t},
+		now:       func() time.Time { return now },
+	}
+
+	state, _ := tracker.State()
+	require.Equal(t, HealthOK, state)
+
+	start := now
+	tracker.setUnreachable(errors.New("connection refused"))
+	state, since := tracker.State()
+	require.Equal(t, HealthDegraded, state)
+	require.Equal(t, start, since)
+	require.NoError(t, tracker.Ready(nil))
+
+	// Further failures don't move the start of the outage.
+	now = now.Add(2 * time.Minute)
+	tracker.setUnreachable(errors.New("connection refused"))
+	state, since = tracker.State()
+	require.Equal(t, HealthFailed, state)
+	require.Equal(t, start, since)
+	require.Error(t, tracker.Ready(nil))
+
+	tracker.setReachable()
+	state, _ = tracker.State()
+	require.Equal(t, HealthOK, state)
+	require.NoError(t, tracker.Ready(nil))
+}
+
+func TestHealthTracker_ServeHTTP(t *testing.T) {
+	now := time.Now()
+	tracker := &HealthTracker{
+		FailAfter: time.Minute,
+		Log:       logrtest.TestLogger{T: t},
+		now:       func() time.Time { return now },
+	}
+
+	cases := []struct {
+		name      string
+		update    func()
+		expState  HealthState
+		expStatus int
+	}{
+		{"ok", func() {}, HealthOK, http.StatusOK},
+		{"degraded", func() { tracker.setUnreachable(errors.New("connection refused")) }, HealthDegraded, http.StatusOK},
+		{"failed", func() { now = now.Add(2 * time.Minute) }, HealthFailed, http.StatusServiceUnavailable},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.update()
+			rec := httptest.NewRecorder()
+			tracker.ServeHTTP(rec, httptest.NewRequest("GET", "/consul-status", nil))
+			require.Equal(t, c.expStatus, rec.Code)
+
+			var status struct {
+				State HealthState `json:"state"`
+				Error string      `json:"error"`
+			}
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&status))
+			require.Equal(t, c.expState, status.State)
+			if c.expState != HealthOK {
+				require.Equal(t, "connection refused", status.Error)
+			}
+		})
+	}
+}
+
+func TestHealthTracker_Backoff(t *testing.T) {
+	now := time.Now()
+	tracker := &HealthTracker{
+		Log: logrtest.TestLogger{T: t},
+		now: func() time.Time { return now },
+	}
+	reconcileErr := fmt.Errorf("getting leader: %w", &UnreachableError{Err: errors.New("connection refused")})
+
+	// Errors are returned while the servers are reachable.
+	_, err := tracker.Backoff(ctrl.Result{}, reconcileErr)
+	require.Equal(t, reconcileErr, err)
+
+	// A nil tracker leaves the result unchanged.
+	var nilTracker *HealthTracker
+	_, err = nilTracker.Backoff(ctrl.Result{}, reconcileErr)
+	require.Equal(t, reconcileErr, err)
+
+	// During an outage the error is dropped and the request requeued after
+	// an interval that grows with the outage, with jitter.
+	tracker.setUnreachable(reconcileErr)
+	result, err := tracker.Backoff(ctrl.Result{}, reconcileErr)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, result.RequeueAfter, minRetryInterval)
+	require.LessOrEqual(t, result.RequeueAfter, minRetryInterval*3/2)
+
+	now = now.Add(time.Hour)
+	result, err = tracker.Backoff(ctrl.Result{}, reconcileErr)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, result.RequeueAfter, maxRetryInterval)
+	require.LessOrEqual(t, result.RequeueAfter, maxRetryInterval*3/2)
+
+	// Errors that aren't of Consul are still returned during an outage, also
+	// if they are combined with errors of Consul.
+	kubeErr := errors.New(`pods "foo" not found`)
+	_, err = tracker.Backoff(ctrl.Result{}, kubeErr)
+	require.Equal(t, kubeErr, err)
+	combinedErr := multierror.Append(reconcileErr, kubeErr)
+	_, err = tracker.Backoff(ctrl.Result{}, combinedErr)
+	require.Equal(t, combinedErr, err)
+	_, err = tracker.Backoff(ctrl.Result{}, multierror.Append(reconcileErr, capi.StatusError{Code: 500, Body: "No cluster leader"}))
+	require.NoError(t, err)
+
+	// Successful reconciles are unchanged.
+	result, err = tracker.Backoff(ctrl.Result{Requeue: true}, nil)
+	require.NoError(t, err)
+	require.Equal(t, ctrl.Result{Requeue: true}, result)
+}
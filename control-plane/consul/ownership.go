@@ -0,0 +1,23 @@
+package consul
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var ownershipConflictsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "consul_k8s_ownership_conflicts_total",
+	Help: "Number of writes to Consul that were refused because the service instance or config entry is owned by another Kubernetes cluster or was created outside of Kubernetes.",
+}, []string{"component", "kind"})
+
+func init() {
+	metrics.Registry.MustRegister(ownershipConflictsCounter)
+}
+
+// RecordOwnershipConflict counts a write to Consul that the component refused
+// because the object it would have overwritten or deleted is not owned by this
+// cluster. Kind is the kind of the object, e.g. "service-instance" or the
+// kind of a config entry.
+func RecordOwnershipConflict(component, kind string) {
+	ownershipConflictsCounter.WithLabelValues(component, kind).Inc()
+}
@@ -9,18 +9,17 @@ import (
 
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
 	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	capi "github.com/hashicorp/consul/api"
-	"golang.org/x/time/rate"
 	corev1 "k8s.io/api/core/v1"
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/util/workqueue"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller"
-	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 const (
@@ -56,6 +55,21 @@ type ConfigEntryController struct {
 	// operating in. Adds this value as metadata on managed resources.
 	DatacenterName string
 
+	// ClusterID identifies the Kubernetes cluster the controller is running in
+	// when several clusters manage config entries in the same Consul
+	// datacenter. Adds this value as metadata on managed resources, and config
+	// entries with a different cluster ID are not overwritten or deleted.
+	ClusterID string
+
+	// Recorder records an event on the resource when its config entry is owned
+	// by another cluster or was created outside of Kubernetes. If it is nil, no
+	// events are recorded.
+	Recorder record.EventRecorder
+
+	// Audit records the config entries the controller writes to and deletes
+	// from Consul. It records nothing if nil.
+	Audit *consul.AuditTrail
+
 	// EnableConsulNamespaces indicates that a user is running Consul Enterprise
 	// with version 1.7+ which supports namespaces.
 	EnableConsulNamespaces bool
@@ -74,11 +88,29 @@ type ConfigEntryController struct {
 	// then the k8s `default` namespace will be mirrored in Consul's
 	// `k8s-default` namespace.
 	NSMirroringPrefix string
+	// NSMirroringRules rewrite the names of the k8s namespaces that are mirrored,
+	// e.g. to strip a "team-" prefix, before NSMirroringPrefix is added.
+	NSMirroringRules namespaces.MirroringRules
 
 	// CrossNSACLPolicy is the name of the ACL policy to attach to
 	// any created Consul namespaces to allow cross namespace service discovery.
 	// Only necessary if ACLs are enabled.
 	CrossNSACLPolicy string
+
+	// ConsulHealth tracks whether the Consul servers are reachable. If set,
+	// reconciles that fail while they aren't are requeued with backoff
+	// instead of being logged.
+	ConsulHealth *consul.HealthTracker
+
+	// MaxConcurrentReconciles is the number of workers of each config entry
+	// kind, keyed by its Kubernetes kind, e.g. "servicedefaults". Kinds that
+	// aren't in the map have one worker.
+	MaxConcurrentReconciles map[string]int
+
+	// Reader reads the ProxyDefaults, Mesh and JWTProvider resources so that the other
+	// kinds are only reconciled once they have been synced. If it is nil, all
+	// kinds are reconciled in the order they are queued.
+	Reader client.Reader
 }
 
 // ReconcileEntry reconciles an update to a resource. CRD-specific controller's
@@ -88,6 +120,19 @@ type ConfigEntryController struct {
 // need to call back into their own update methods to ensure they update their
 // internal state.
 func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Controller, req ctrl.Request, configEntry common.ConfigEntryResource) (ctrl.Result, error) {
+	deferred, err := r.deferToPriorityKinds(ctx, configEntry.KubeKind())
+	if err != nil {
+		crdCtrl.Logger(req.NamespacedName).Error(err, "listing priority resources")
+		return ctrl.Result{}, err
+	}
+	if deferred {
+		crdCtrl.Logger(req.NamespacedName).V(1).Info("deferring reconcile until ProxyDefaults, Mesh and JWTProvider resources are synced")
+		return ctrl.Result{RequeueAfter: wait.Jitter(priorityRequeueInterval, retryJitter)}, nil
+	}
+	return r.ConsulHealth.Backoff(r.reconcileEntry(ctx, crdCtrl, req, configEntry))
+}
+
+func (r *ConfigEntryController) reconcileEntry(ctx context.Context, crdCtrl Controller, req ctrl.Request, configEntry common.ConfigEntryResource) (ctrl.Result, error) {
 	logger := crdCtrl.Logger(req.NamespacedName)
 	err := crdCtrl.Get(ctx, req.NamespacedName, configEntry)
 	if k8serr.IsNotFound(err) {
@@ -97,7 +142,7 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 		return ctrl.Result{}, err
 	}
 
-	consulEntry := configEntry.ToConsul(r.DatacenterName)
+	consulEntry := r.toConsul(configEntry)
 
 	if configEntry.GetDeletionTimestamp().IsZero() {
 		// The object is not being deleted, so if it does not have our finalizer,
@@ -120,11 +165,18 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 
 			// Ignore the error where the config entry isn't found in Consul.
 			// It is indicative of desired state.
+			// Any other error keeps the finalizer so that the config entry isn't
+			// left behind in Consul, which means the resource can't be deleted
+			// while Consul is unreachable. The failure is recorded in the Synced
+			// condition; if the Consul servers are gone for good, the finalizer
+			// can be removed with `consul-k8s crd force-unlock`.
 			if err != nil && !isNotFoundErr(err) {
-				return ctrl.Result{}, fmt.Errorf("getting config entry from consul: %w", err)
+				return r.syncFailed(ctx, logger, crdCtrl, configEntry, ConsulAgentError,
+					fmt.Errorf("getting config entry from consul: %w", err))
 			} else if err == nil {
-				// Only delete the resource from Consul if it is owned by our datacenter.
-				if entry.GetMeta()[common.DatacenterKey] == r.DatacenterName {
+				// Only delete the resource from Consul if it is owned by our datacenter
+				// and cluster.
+				if entry.GetMeta()[common.DatacenterKey] == r.DatacenterName && r.ownsClusterID(entry.GetMeta()[common.ClusterIDKey]) {
 					_, err := r.ConsulClient.ConfigEntries().Delete(configEntry.ConsulKind(), configEntry.ConsulName(), &capi.WriteOptions{
 						Namespace: r.consulNamespace(consulEntry, configEntry.ConsulMirroringNS(), configEntry.ConsulGlobalResource()),
 					})
@@ -132,9 +184,12 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 						return r.syncFailed(ctx, logger, crdCtrl, configEntry, ConsulAgentError,
 							fmt.Errorf("deleting config entry from consul: %w", err))
 					}
+					r.audit(configEntry, consul.AuditOpDelete, consulEntry, entry)
 					logger.Info("deletion from Consul successful")
-				} else {
+				} else if entry.GetMeta()[common.DatacenterKey] != r.DatacenterName {
 					logger.Info("config entry in Consul was created in another datacenter - skipping delete from Consul", "external-datacenter", entry.GetMeta()[common.DatacenterKey])
+				} else {
+					logger.Info("config entry in Consul was created by another cluster - skipping delete from Consul", "external-cluster-id", entry.GetMeta()[common.ClusterIDKey])
 				}
 			}
 			// remove our finalizer from the list and update it.
@@ -179,6 +234,7 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 			return r.syncFailed(ctx, logger, crdCtrl, configEntry, ConsulAgentError,
 				fmt.Errorf("writing config entry to consul: %w", err))
 		}
+		r.audit(configEntry, consul.AuditOpWrite, consulEntry, nil)
 		logger.Info("config entry created", "request-time", writeMeta.RequestTime)
 		return r.syncSuccessful(ctx, crdCtrl, configEntry)
 	}
@@ -191,11 +247,12 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 
 	requiresMigration := false
 	sourceDatacenter := entry.GetMeta()[common.DatacenterKey]
+	sourceClusterID := entry.GetMeta()[common.ClusterIDKey]
 
-	// Check if the config entry is managed by our datacenter.
+	// Check if the config entry is managed by our datacenter and cluster.
 	// Do not process resource if the entry was not created within our datacenter
-	// as it was created in a different cluster which will be managing that config entry.
-	if sourceDatacenter != r.DatacenterName {
+	// or by another cluster in our datacenter as that cluster will be managing that config entry.
+	if sourceDatacenter != r.DatacenterName || !r.ownsClusterID(sourceClusterID) {
 
 		// Note that there is a special case where we will migrate a config entry
 		// that wasn't created by the controller if it has the migrate-entry annotation set to true.
@@ -203,13 +260,21 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 		// chart versions where they had previously created config entries themselves but
 		// now want to manage them through custom resources.
 		if configEntry.GetObjectMeta().Annotations[common.MigrateEntryKey] != common.MigrateEntryTrue {
-			return r.syncFailed(ctx, logger, crdCtrl, configEntry, ExternallyManagedConfigError,
-				sourceDatacenterMismatchErr(sourceDatacenter))
+			mismatchErr := sourceDatacenterMismatchErr(sourceDatacenter)
+			if sourceDatacenter == r.DatacenterName {
+				mismatchErr = sourceClusterIDMismatchErr(sourceClusterID)
+			}
+			r.recordOwnershipConflict(configEntry, mismatchErr)
+			return r.syncFailed(ctx, logger, crdCtrl, configEntry, ExternallyManagedConfigError, mismatchErr)
 		}
 
 		requiresMigration = true
 	}
 
+	// Config entries written before the cluster ID was set are adopted by
+	// writing the cluster ID to them.
+	requiresClusterID := r.ClusterID != "" && sourceClusterID == ""
+
 	if !configEntry.MatchesConsul(entry) {
 		if requiresMigration {
 			// If we're migrating this config entry but the custom resource
@@ -227,13 +292,15 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 			return r.syncUnknownWithError(ctx, logger, crdCtrl, configEntry, ConsulAgentError,
 				fmt.Errorf("updating config entry in consul: %w", err))
 		}
+		r.audit(configEntry, consul.AuditOpWrite, consulEntry, entry)
 		logger.Info("config entry updated", "request-time", writeMeta.RequestTime)
 		return r.syncSuccessful(ctx, crdCtrl, configEntry)
-	} else if requiresMigration && entry.GetMeta()[common.DatacenterKey] != r.DatacenterName {
+	} else if requiresMigration || requiresClusterID {
 		// If we get here then we're doing a migration and the entry in Consul
 		// matches the entry in Kubernetes. We just need to update the metadata
-		// of the entry in Consul to say that it's now managed by Kubernetes.
-		logger.Info("migrating config entry to be managed by Kubernetes")
+		// of the entry in Consul to say that it's now managed by Kubernetes
+		// in our cluster.
+		logger.Info("migrating config entry to be managed by Kubernetes", "cluster-id", r.ClusterID)
 		_, writeMeta, err := r.ConsulClient.ConfigEntries().Set(consulEntry, &capi.WriteOptions{
 			Namespace: r.consulNamespace(consulEntry, configEntry.ConsulMirroringNS(), configEntry.ConsulGlobalResource()),
 		})
@@ -241,6 +308,7 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 			return r.syncUnknownWithError(ctx, logger, crdCtrl, configEntry, ConsulAgentError,
 				fmt.Errorf("updating config entry in consul: %w", err))
 		}
+		r.audit(configEntry, consul.AuditOpWrite, consulEntry, entry)
 		logger.Info("config entry migrated", "request-time", writeMeta.RequestTime)
 		return r.syncSuccessful(ctx, crdCtrl, configEntry)
 	} else if configEntry.SyncedConditionStatus() != corev1.ConditionTrue {
@@ -250,37 +318,6 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 	return ctrl.Result{}, nil
 }
 
-// setupWithManager sets up the controller manager for the given resource
-// with our default options.
-func setupWithManager(mgr ctrl.Manager, resource client.Object, reconciler reconcile.Reconciler) error {
-	options := controller.Options{
-		// Taken from https://github.com/kubernetes/client-go/blob/master/util/workqueue/default_rate_limiters.go#L39
-		// and modified from a starting backoff of 5ms and max of 1000s to a
-		// starting backoff of 200ms and a max of 5s to better fit our most
-		// common error cases and performance characteristics.
-		//
-		// One common error case is that a config entry is applied that requires
-		// a protocol like http or grpc. Often the user will apply a new config
-		// entry to set the protocol in a minute or two. During this time, the
-		// default backoff could then be set up to 5m or more which means the
-		// original config entry takes a long time to re-sync.
-		//
-		// In terms of performance, Consul servers can handle tens of thousands
-		// of writes per second, so retrying at max every 5s isn't an issue and
-		// provides a better UX.
-		RateLimiter: workqueue.NewMaxOfRateLimiter(
-			workqueue.NewItemExponentialFailureRateLimiter(200*time.Millisecond, 5*time.Second),
-			// 10 qps, 100 bucket size.  This is only for retry speed and its only the overall factor (not per item)
-			&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
-		),
-	}
-
-	return ctrl.NewControllerManagedBy(mgr).
-		For(resource).
-		WithOptions(options).
-		Complete(reconciler)
-}
-
 func (r *ConfigEntryController) consulNamespace(configEntry capi.ConfigEntry, namespace string, globalResource bool) string {
 	// ServiceIntentions have the appropriate Consul Namespace set on them as the value
 	// is defaulted by the webhook. These are then set on the ServiceIntentions config entry
@@ -293,7 +330,7 @@ func (r *ConfigEntryController) consulNamespace(configEntry capi.ConfigEntry, na
 	// Does not attempt to parse the namespace for global resources like ProxyDefaults or
 	// wildcard namespace destinations are they will not be prefixed and will remain "default"/"*".
 	if !globalResource && namespace != common.WildcardNamespace {
-		return namespaces.ConsulNamespace(namespace, r.EnableConsulNamespaces, r.ConsulDestinationNamespace, r.EnableNSMirroring, r.NSMirroringPrefix)
+		return namespaces.ConsulNamespace(namespace, r.EnableConsulNamespaces, r.ConsulDestinationNamespace, r.EnableNSMirroring, r.NSMirroringPrefix, r.NSMirroringRules)
 	}
 	if r.EnableConsulNamespaces {
 		return namespace
@@ -301,6 +338,54 @@ func (r *ConfigEntryController) consulNamespace(configEntry capi.ConfigEntry, na
 	return ""
 }
 
+// toConsul returns the config entry to write to Consul for the resource, with
+// the cluster ID added to its metadata.
+func (r *ConfigEntryController) toConsul(configEntry common.ConfigEntryResource) capi.ConfigEntry {
+	consulEntry := configEntry.ToConsul(r.DatacenterName)
+	if r.ClusterID != "" {
+		consulEntry.GetMeta()[common.ClusterIDKey] = r.ClusterID
+	}
+	return consulEntry
+}
+
+// ownsClusterID returns true if a config entry with the source cluster ID
+// meta value is managed by this cluster. Config entries without a cluster ID
+// were written before clusters were told apart and are managed by every
+// cluster in the datacenter.
+func (r *ConfigEntryController) ownsClusterID(sourceClusterID string) bool {
+	return sourceClusterID == "" || sourceClusterID == r.ClusterID
+}
+
+// audit records a write of the config entry of the resource to Consul.
+// Previous is the config entry in Consul before the write, or nil if there was
+// none.
+func (r *ConfigEntryController) audit(configEntry common.ConfigEntryResource, op string, consulEntry, previous capi.ConfigEntry) {
+	w := consul.AuditWrite{
+		Operation: op,
+		Kind:      configEntry.ConsulKind(),
+		Name:      configEntry.ConsulName(),
+		Namespace: r.consulNamespace(consulEntry, configEntry.ConsulMirroringNS(), configEntry.ConsulGlobalResource()),
+		Partition: consulEntry.GetPartition(),
+	}
+	if op != consul.AuditOpDelete {
+		w.Payload = consulEntry
+	}
+	if previous != nil {
+		w.Previous = previous
+	}
+	r.Audit.Record(configEntry, w)
+}
+
+// recordOwnershipConflict records that the config entry of the resource
+// wasn't written to Consul because it isn't owned by this cluster.
+func (r *ConfigEntryController) recordOwnershipConflict(configEntry common.ConfigEntryResource, err error) {
+	consul.RecordOwnershipConflict("controller", configEntry.ConsulKind())
+	if r.Recorder != nil {
+		r.Recorder.Eventf(configEntry, corev1.EventTypeWarning, ExternallyManagedConfigError,
+			"Not writing config entry to Consul: %s", err)
+	}
+}
+
 func (r *ConfigEntryController) syncFailed(ctx context.Context, logger logr.Logger, updater Controller, configEntry common.ConfigEntryResource, errType string, err error) (ctrl.Result, error) {
 	configEntry.SetSyncedCondition(corev1.ConditionFalse, errType, err.Error())
 	if updateErr := updater.UpdateStatus(ctx, configEntry); updateErr != nil {
@@ -384,3 +469,10 @@ func sourceDatacenterMismatchErr(sourceDatacenter string) error {
 	}
 	return fmt.Errorf("config entry managed in different datacenter: %q", sourceDatacenter)
 }
+
+// sourceClusterIDMismatchErr returns an error for when the source cluster ID
+// meta key does not match our cluster ID because the config entry was created
+// by the controller of another Kubernetes cluster in our datacenter.
+func sourceClusterIDMismatchErr(sourceClusterID string) error {
+	return fmt.Errorf("config entry managed by different cluster: %q", sourceClusterID)
+}
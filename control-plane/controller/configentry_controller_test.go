@@ -19,6 +19,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -1647,6 +1648,114 @@ func TestConfigEntryControllers_doesNotDeleteUnownedConfig(t *testing.T) {
 	}
 }
 
+// Test that config entries written by the controller of another cluster in
+// the same datacenter are not overwritten, and that config entries written
+// before the cluster ID was set are adopted.
+func TestConfigEntryControllers_clusterID(t *testing.T) {
+	t.Parallel()
+	kubeNS := "default"
+
+	cases := map[string]struct {
+		sourceClusterID string
+		expErr          string
+		expClusterID    string
+		expEvent        bool
+	}{
+		"owned by this cluster": {
+			sourceClusterID: "cluster-a",
+			expClusterID:    "cluster-a",
+		},
+		"written before the cluster ID was set": {
+			sourceClusterID: "",
+			expClusterID:    "cluster-a",
+		},
+		"owned by another cluster": {
+			sourceClusterID: "cluster-b",
+			expErr:          "config entry managed by different cluster: \"cluster-b\"",
+			expClusterID:    "cluster-b",
+			expEvent:        true,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			req := require.New(t)
+			ctx := context.Background()
+
+			s := runtime.NewScheme()
+			svcDefaults := &v1alpha1.ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: kubeNS,
+				},
+				Spec: v1alpha1.ServiceDefaultsSpec{
+					Protocol: "http",
+				},
+			}
+			s.AddKnownTypes(v1alpha1.GroupVersion, svcDefaults)
+			fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(svcDefaults).Build()
+
+			consul, err := testutil.NewTestServerConfigT(t, nil)
+			req.NoError(err)
+			defer consul.Stop()
+
+			consul.WaitForServiceIntentions(t)
+			consulClient, err := capi.NewClient(&capi.Config{
+				Address: consul.HTTPAddr,
+			})
+			req.NoError(err)
+
+			// Create the config entry in Consul as the cluster with the source
+			// cluster ID would have.
+			{
+				entry := svcDefaults.ToConsul(datacenterName)
+				if c.sourceClusterID != "" {
+					entry.GetMeta()[common.ClusterIDKey] = c.sourceClusterID
+				}
+				written, _, err := consulClient.ConfigEntries().Set(entry, nil)
+				req.NoError(err)
+				req.True(written)
+			}
+
+			namespacedName := types.NamespacedName{
+				Namespace: kubeNS,
+				Name:      svcDefaults.KubernetesName(),
+			}
+			recorder := record.NewFakeRecorder(1)
+			reconciler := ServiceDefaultsController{
+				Client: fakeClient,
+				Log:    logrtest.TestLogger{T: t},
+				ConfigEntryController: &ConfigEntryController{
+					ConsulClient:   consulClient,
+					DatacenterName: datacenterName,
+					ClusterID:      "cluster-a",
+					Recorder:       recorder,
+				},
+			}
+			_, err = reconciler.Reconcile(ctx, ctrl.Request{
+				NamespacedName: namespacedName,
+			})
+			if c.expErr != "" {
+				req.EqualError(err, c.expErr)
+			} else {
+				req.NoError(err)
+			}
+
+			cfg, _, err := consulClient.ConfigEntries().Get(capi.ServiceDefaults, svcDefaults.ConsulName(), nil)
+			req.NoError(err)
+			req.Equal(c.expClusterID, cfg.GetMeta()[common.ClusterIDKey])
+
+			if c.expEvent {
+				req.Len(recorder.Events, 1)
+				req.Equal("Warning ExternallyManagedConfigError Not writing config entry to Consul: "+c.expErr, <-recorder.Events)
+			} else {
+				req.Empty(recorder.Events)
+			}
+		})
+	}
+}
+
 func TestConfigEntryControllers_updatesStatusWhenDeleteFails(t *testing.T) {
 	ctx := context.Background()
 	kubeNS := "default"
@@ -1756,6 +1865,52 @@ func TestConfigEntryControllers_updatesStatusWhenDeleteFails(t *testing.T) {
 	require.True(t, cmp.Equal(syncCondition, expectedCondition, cmpopts.IgnoreFields(v1alpha1.Condition{}, "LastTransitionTime")))
 }
 
+// Test that the finalizer is kept and the failure is recorded in the status
+// when Consul can't be reached while the resource is being deleted.
+func TestConfigEntryControllers_keepsFinalizerWhenConsulUnreachable(t *testing.T) {
+	ctx := context.Background()
+
+	s := runtime.NewScheme()
+	s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.ServiceDefaults{})
+
+	defaults := &v1alpha1.ServiceDefaults{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "service",
+			Namespace:         "default",
+			DeletionTimestamp: &metav1.Time{Time: time.Now()},
+			Finalizers:        []string{FinalizerName},
+		},
+		Spec: v1alpha1.ServiceDefaultsSpec{
+			Protocol: "http",
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(defaults).Build()
+
+	// Nothing listens on this address.
+	consulClient, err := capi.NewClient(&capi.Config{Address: "127.0.0.1:1"})
+	require.NoError(t, err)
+
+	reconciler := ServiceDefaultsController{
+		Client: fakeClient,
+		Log:    logrtest.TestLogger{T: t},
+		ConfigEntryController: &ConfigEntryController{
+			ConsulClient:   consulClient,
+			DatacenterName: datacenterName,
+		},
+	}
+	namespacedName := types.NamespacedName{Namespace: defaults.Namespace, Name: defaults.Name}
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: namespacedName})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "getting config entry from consul")
+
+	require.NoError(t, fakeClient.Get(ctx, namespacedName, defaults))
+	require.Equal(t, []string{FinalizerName}, defaults.GetFinalizers())
+	syncCondition := defaults.GetCondition(v1alpha1.ConditionSynced)
+	require.Equal(t, corev1.ConditionFalse, syncCondition.Status)
+	require.Equal(t, ConsulAgentError, syncCondition.Reason)
+	require.Contains(t, syncCondition.Message, "getting config entry from consul")
+}
+
 // Test that if the resource already exists in Consul but the Kube resource
 // has the "migrate-entry" annotation then we let the Kube resource sync to Consul.
 func TestConfigEntryController_Migration(t *testing.T) {
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	consulv1alpha1 "github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+)
+
+const (
+	// retryBaseDelay and retryMaxDelay bound the exponential backoff of
+	// reconciles that failed, e.g. because of a Consul API error.
+	retryBaseDelay = 200 * time.Millisecond
+	retryMaxDelay  = 5 * time.Second
+	// retryJitter is the maximum fraction of the backoff that is added to it.
+	retryJitter = 0.2
+
+	// priorityRequeueInterval is how long the reconcile of a per-service kind
+	// is deferred while ProxyDefaults, Mesh or JWTProvider resources haven't
+	// been synced.
+	priorityRequeueInterval = time.Second
+)
+
+// priorityKinds are the config entry kinds that are reconciled before the
+// other kinds. They hold the defaults, e.g. the protocol of services, and the
+// JWT providers that Consul validates the per-service kinds against, so
+// writing a per-service kind before them fails and is retried with backoff.
+var priorityKinds = map[string]bool{
+	common.ProxyDefaults: true,
+	common.Mesh:          true,
+	common.JWTProvider:   true,
+}
+
+// setupWithManager sets up the controller manager for the given resource
+// with our default options. Each kind has its own controller with its own
+// queue, rate limiter and workers, so a kind whose reconciles keep failing
+// doesn't delay the reconciles of the other kinds.
+func (r *ConfigEntryController) setupWithManager(mgr ctrl.Manager, resource common.ConfigEntryResource, reconciler reconcile.Reconciler) error {
+	options := controller.Options{
+		MaxConcurrentReconciles: r.maxConcurrentReconciles(resource.KubeKind()),
+		RateLimiter:             configEntryRateLimiter(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(resource).
+		WithOptions(options).
+		Complete(reconciler)
+}
+
+// maxConcurrentReconciles returns the number of workers of the kind.
+func (r *ConfigEntryController) maxConcurrentReconciles(kind string) int {
+	if workers := r.MaxConcurrentReconciles[kind]; workers > 0 {
+		return workers
+	}
+	return 1
+}
+
+// configEntryRateLimiter returns the rate limiter of the queue of a kind.
+//
+// The backoff is taken from https://github.com/kubernetes/client-go/blob/master/util/workqueue/default_rate_limiters.go#L39
+// and modified from a starting backoff of 5ms and max of 1000s to a
+// starting backoff of 200ms and a max of 5s to better fit our most
+// common error cases and performance characteristics.
+//
+// One common error case is that a config entry is applied that requires
+// a protocol like http or grpc. Often the user will apply a new config
+// entry to set the protocol in a minute or two. During this time, the
+// default backoff could then be set up to 5m or more which means the
+// original config entry takes a long time to re-sync.
+//
+// In terms of performance, Consul servers can handle tens of thousands
+// of writes per second, so retrying at max every 5s isn't an issue and
+// provides a better UX. The backoff is jittered so that the retries of
+// requests that failed at the same time, e.g. while a Consul server was
+// restarting, are spread out.
+func configEntryRateLimiter() workqueue.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		&jitterRateLimiter{
+			RateLimiter: workqueue.NewItemExponentialFailureRateLimiter(retryBaseDelay, retryMaxDelay),
+			maxFactor:   retryJitter,
+		},
+		// 10 qps, 100 bucket size.  This is only for retry speed and its only the overall factor (not per item)
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+	)
+}
+
+// jitterRateLimiter adds up to maxFactor of the delay of the wrapped rate
+// limiter to it.
+type jitterRateLimiter struct {
+	workqueue.RateLimiter
+	maxFactor float64
+}
+
+func (l *jitterRateLimiter) When(item interface{}) time.Duration {
+	return wait.Jitter(l.RateLimiter.When(item), l.maxFactor)
+}
+
+// deferToPriorityKinds returns whether the reconcile of a resource of the
+// kind should be deferred because a ProxyDefaults, Mesh or JWTProvider resource
+// hasn't been synced yet. Resources whose sync failed don't defer the other kinds, so a
+// failing ProxyDefaults doesn't starve them.
+func (r *ConfigEntryController) deferToPriorityKinds(ctx context.Context, kind string) (bool, error) {
+	if r.Reader == nil || priorityKinds[kind] {
+		return false, nil
+	}
+
+	var proxyDefaults consulv1alpha1.ProxyDefaultsList
+	if err := r.Reader.List(ctx, &proxyDefaults); err != nil {
+		return false, err
+	}
+	for i := range proxyDefaults.Items {
+		if syncPending(&proxyDefaults.Items[i]) {
+			return true, nil
+		}
+	}
+	var meshes consulv1alpha1.MeshList
+	if err := r.Reader.List(ctx, &meshes); err != nil {
+		return false, err
+	}
+	for i := range meshes.Items {
+		if syncPending(&meshes.Items[i]) {
+			return true, nil
+		}
+	}
+	var providers consulv1alpha1.JWTProviderList
+	if err := r.Reader.List(ctx, &providers); err != nil {
+		return false, err
+	}
+	for i := range providers.Items {
+		if syncPending(&providers.Items[i]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// syncPending returns whether the resource hasn't been synced to Consul yet.
+// Failed syncs set a reason on the synced condition.
+func syncPending(configEntry common.ConfigEntryResource) bool {
+	if !configEntry.GetDeletionTimestamp().IsZero() {
+		return false
+	}
+	status, reason, _ := configEntry.SyncedCondition()
+	return status == corev1.ConditionUnknown && reason == ""
+}
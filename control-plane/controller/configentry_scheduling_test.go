@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestConfigEntryController_deferToPriorityKinds(t *testing.T) {
+	t.Parallel()
+
+	proxyDefaults := func(status corev1.ConditionStatus, reason string) *v1alpha1.ProxyDefaults {
+		resource := &v1alpha1.ProxyDefaults{
+			ObjectMeta: metav1.ObjectMeta{Name: common.Global, Namespace: "default"},
+		}
+		if status != "" {
+			resource.SetSyncedCondition(status, reason, "")
+		}
+		return resource
+	}
+
+	cases := map[string]struct {
+		kind      string
+		resources []runtime.Object
+		expDefer  bool
+	}{
+		"no priority resources": {
+			kind:     common.ServiceDefaults,
+			expDefer: false,
+		},
+		"ProxyDefaults never reconciled": {
+			kind:      common.ServiceDefaults,
+			resources: []runtime.Object{proxyDefaults("", "")},
+			expDefer:  true,
+		},
+		"ProxyDefaults being synced": {
+			kind:      common.ServiceRouter,
+			resources: []runtime.Object{proxyDefaults(corev1.ConditionUnknown, "")},
+			expDefer:  true,
+		},
+		"ProxyDefaults synced": {
+			kind:      common.ServiceDefaults,
+			resources: []runtime.Object{proxyDefaults(corev1.ConditionTrue, "")},
+			expDefer:  false,
+		},
+		"ProxyDefaults failed to sync": {
+			kind:      common.ServiceDefaults,
+			resources: []runtime.Object{proxyDefaults(corev1.ConditionFalse, ConsulAgentError)},
+			expDefer:  false,
+		},
+		"ProxyDefaults failed to update": {
+			kind:      common.ServiceDefaults,
+			resources: []runtime.Object{proxyDefaults(corev1.ConditionUnknown, ConsulAgentError)},
+			expDefer:  false,
+		},
+		"ProxyDefaults being deleted": {
+			kind: common.ServiceDefaults,
+			resources: []runtime.Object{func() runtime.Object {
+				resource := proxyDefaults("", "")
+				resource.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+				resource.AddFinalizer(FinalizerName)
+				return resource
+			}()},
+			expDefer: false,
+		},
+		"Mesh never reconciled": {
+			kind: common.ServiceIntentions,
+			resources: []runtime.Object{
+				proxyDefaults(corev1.ConditionTrue, ""),
+				&v1alpha1.Mesh{ObjectMeta: metav1.ObjectMeta{Name: common.Mesh, Namespace: "default"}},
+			},
+			expDefer: true,
+		},
+		"JWTProvider never reconciled": {
+			kind: common.ServiceIntentions,
+			resources: []runtime.Object{
+				proxyDefaults(corev1.ConditionTrue, ""),
+				&v1alpha1.JWTProvider{ObjectMeta: metav1.ObjectMeta{Name: "okta", Namespace: "default"}},
+			},
+			expDefer: true,
+		},
+		"priority kinds aren't deferred": {
+			kind: common.Mesh,
+			resources: []runtime.Object{
+				proxyDefaults("", ""),
+				&v1alpha1.Mesh{ObjectMeta: metav1.ObjectMeta{Name: common.Mesh, Namespace: "default"}},
+			},
+			expDefer: false,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			r := &ConfigEntryController{Reader: priorityTestClient(c.resources...)}
+			deferred, err := r.deferToPriorityKinds(context.Background(), c.kind)
+			require.NoError(t, err)
+			require.Equal(t, c.expDefer, deferred)
+		})
+	}
+}
+
+func TestConfigEntryController_deferToPriorityKinds_NoReader(t *testing.T) {
+	t.Parallel()
+	deferred, err := (&ConfigEntryController{}).deferToPriorityKinds(context.Background(), common.ServiceDefaults)
+	require.NoError(t, err)
+	require.False(t, deferred)
+}
+
+// The reconcile of a per-service kind is requeued without talking to Consul
+// while a ProxyDefaults resource hasn't been synced.
+func TestServiceDefaultsController_deferredToProxyDefaults(t *testing.T) {
+	t.Parallel()
+	serviceDefaults := &v1alpha1.ServiceDefaults{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+	}
+	fakeClient := priorityTestClient(serviceDefaults, &v1alpha1.ProxyDefaults{
+		ObjectMeta: metav1.ObjectMeta{Name: common.Global, Namespace: "default"},
+	})
+
+	r := &ServiceDefaultsController{
+		Client: fakeClient,
+		Log:    logrtest.TestLogger{T: t},
+		ConfigEntryController: &ConfigEntryController{
+			// A nil Consul client panics if the reconcile isn't deferred.
+			Reader: fakeClient,
+		},
+	}
+	resp, err := r.Reconcile(context.Background(), ctrl.Request{
+		NamespacedName: types.NamespacedName{Namespace: "default", Name: "foo"},
+	})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, resp.RequeueAfter, priorityRequeueInterval)
+	require.LessOrEqual(t, resp.RequeueAfter, time.Duration(float64(priorityRequeueInterval)*(1+retryJitter)))
+}
+
+func TestConfigEntryController_maxConcurrentReconciles(t *testing.T) {
+	t.Parallel()
+	r := &ConfigEntryController{
+		MaxConcurrentReconciles: map[string]int{common.ServiceIntentions: 4},
+	}
+	require.Equal(t, 4, r.maxConcurrentReconciles(common.ServiceIntentions))
+	require.Equal(t, 1, r.maxConcurrentReconciles(common.ServiceDefaults))
+	require.Equal(t, 1, (&ConfigEntryController{}).maxConcurrentReconciles(common.ServiceDefaults))
+}
+
+func TestConfigEntryRateLimiter(t *testing.T) {
+	t.Parallel()
+	limiter := configEntryRateLimiter()
+
+	// The backoff doubles with each failure, plus jitter, up to the max.
+	expected := retryBaseDelay
+	for i := 0; i < 10; i++ {
+		delay := limiter.When("item")
+		require.GreaterOrEqual(t, delay, expected)
+		require.LessOrEqual(t, delay, time.Duration(float64(expected)*(1+retryJitter)))
+		expected *= 2
+		if expected > retryMaxDelay {
+			expected = retryMaxDelay
+		}
+	}
+	require.Equal(t, 10, limiter.NumRequeues("item"))
+
+	// Other items and forgotten items start over.
+	require.Less(t, limiter.When("other"), 2*retryBaseDelay)
+	limiter.Forget("item")
+	require.Less(t, limiter.When("item"), 2*retryBaseDelay)
+}
+
+func priorityTestClient(objs ...runtime.Object) client.Client {
+	s := runtime.NewScheme()
+	s.AddKnownTypes(v1alpha1.GroupVersion,
+		&v1alpha1.ProxyDefaults{}, &v1alpha1.ProxyDefaultsList{},
+		&v1alpha1.Mesh{}, &v1alpha1.MeshList{},
+		&v1alpha1.JWTProvider{}, &v1alpha1.JWTProviderList{},
+		&v1alpha1.ServiceDefaults{}, &v1alpha1.ServiceDefaultsList{})
+	return fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build()
+}
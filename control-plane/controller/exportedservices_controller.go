@@ -36,5 +36,5 @@ func (r *ExportedServicesController) UpdateStatus(ctx context.Context, obj clien
 }
 
 func (r *ExportedServicesController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.ExportedServices{}, r)
+	return r.ConfigEntryController.setupWithManager(mgr, &consulv1alpha1.ExportedServices{}, r)
 }
@@ -0,0 +1,466 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	capi "github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ExternalServicesConfigMapKey is the key of the ExternalServicesConfig in
+	// the data of the external services ConfigMap.
+	ExternalServicesConfigMapKey = "services.yaml"
+
+	// externalServicesLabel labels the ServiceDefaults and TerminatingGateway
+	// resources created for the external services with the name of the
+	// ConfigMap they were created for.
+	externalServicesLabel = "consul.hashicorp.com/external-services-config-map"
+	// externalServicesAnnotation lists the linked services of a
+	// TerminatingGateway that were added for the external services, so that
+	// they can be removed again without touching the other linked services.
+	externalServicesAnnotation = "consul.hashicorp.com/external-services"
+
+	// Meta keys of the Consul Lambda integration.
+	lambdaEnabledMetaKey            = "serverless.consul.hashicorp.com/v1alpha1/lambda/enabled"
+	lambdaARNMetaKey                = "serverless.consul.hashicorp.com/v1alpha1/lambda/arn"
+	lambdaRegionMetaKey             = "serverless.consul.hashicorp.com/v1alpha1/lambda/region"
+	lambdaPayloadPassthroughMetaKey = "serverless.consul.hashicorp.com/v1alpha1/lambda/payload-passthrough"
+)
+
+// ExternalServicesConfig describes services that run outside the cluster, such
+// as Lambda functions, ECS tasks or VMs, so that Kubernetes services can call
+// them through the mesh.
+type ExternalServicesConfig struct {
+	// TerminatingGateway is the name of the TerminatingGateway resource that
+	// services are linked to unless they set their own.
+	TerminatingGateway string            `json:"terminatingGateway,omitempty"`
+	Services           []ExternalService `json:"services,omitempty"`
+}
+
+// ExternalService is a service outside the cluster. It is either a Lambda
+// function or reachable at an address and port.
+type ExternalService struct {
+	Name     string            `json:"name"`
+	Address  string            `json:"address,omitempty"`
+	Port     int               `json:"port,omitempty"`
+	Meta     map[string]string `json:"meta,omitempty"`
+	Lambda   *ExternalLambda   `json:"lambda,omitempty"`
+	Protocol string            `json:"protocol,omitempty"`
+
+	// TerminatingGateway is the name of the TerminatingGateway resource the
+	// service is linked to. It defaults to the one of the config.
+	TerminatingGateway string `json:"terminatingGateway,omitempty"`
+	// CAFile, CertFile, KeyFile and SNI configure TLS from the terminating
+	// gateway to the service.
+	CAFile   string `json:"caFile,omitempty"`
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	SNI      string `json:"sni,omitempty"`
+}
+
+// ExternalLambda is a Lambda function that is invoked by the terminating
+// gateway.
+type ExternalLambda struct {
+	ARN string `json:"arn"`
+	// Region defaults to the region of the ARN.
+	Region             string `json:"region,omitempty"`
+	PayloadPassthrough bool   `json:"payloadPassthrough,omitempty"`
+}
+
+// ParseExternalServicesConfig parses and validates the config.
+func ParseExternalServicesConfig(raw string) (ExternalServicesConfig, error) {
+	var cfg ExternalServicesConfig
+	if err := yaml.UnmarshalStrict([]byte(raw), &cfg); err != nil {
+		return ExternalServicesConfig{}, fmt.Errorf("unable to parse external services: %w", err)
+	}
+	seen := make(map[string]bool)
+	for i, svc := range cfg.Services {
+		if svc.Name == "" {
+			return ExternalServicesConfig{}, fmt.Errorf("service %d: name must be set", i)
+		}
+		if seen[svc.Name] {
+			return ExternalServicesConfig{}, fmt.Errorf("service %q is defined more than once", svc.Name)
+		}
+		seen[svc.Name] = true
+		if svc.Lambda != nil {
+			if svc.Address != "" || svc.Port != 0 {
+				return ExternalServicesConfig{}, fmt.Errorf("service %q: address and port can't be set for a Lambda function", svc.Name)
+			}
+			if _, err := svc.Lambda.region(); err != nil {
+				return ExternalServicesConfig{}, fmt.Errorf("service %q: %w", svc.Name, err)
+			}
+		} else if svc.Address == "" || svc.Port <= 0 {
+			return ExternalServicesConfig{}, fmt.Errorf("service %q: address and port must be set", svc.Name)
+		}
+	}
+	return cfg, nil
+}
+
+// region returns the region of the Lambda function, which is the region of
+// its ARN, arn:aws:lambda:<region>:<account>:function:<name>, unless set.
+func (l *ExternalLambda) region() (string, error) {
+	parts := strings.Split(l.ARN, ":")
+	if len(parts) < 7 || parts[0] != "arn" || parts[2] != "lambda" || parts[5] != "function" {
+		return "", fmt.Errorf("lambda ARN %q is invalid", l.ARN)
+	}
+	if l.Region != "" {
+		return l.Region, nil
+	}
+	return parts[3], nil
+}
+
+// protocol is the protocol of the service. Lambda functions can only be
+// invoked over HTTP.
+func (s ExternalService) protocol() string {
+	if s.Protocol == "" && s.Lambda != nil {
+		return "http"
+	}
+	return s.Protocol
+}
+
+// ExternalServicesController registers the services described by a ConfigMap
+// in the Consul catalog and links them to a terminating gateway, so that
+// services in the mesh can call services that run outside of it.
+//
+// The services are registered on a synthetic node. Their protocol is set by a
+// ServiceDefaults resource and they are linked by adding them to a
+// TerminatingGateway resource, both in the namespace of the ConfigMap, so that
+// the config entries are written by the config entry controllers. Services
+// removed from the ConfigMap are deregistered and unlinked again.
+type ExternalServicesController struct {
+	// Client manages the ServiceDefaults and TerminatingGateway resources.
+	Client client.Client
+	// ConfigMapReader reads the ConfigMap. It should not be backed by the
+	// manager's cache so that ConfigMaps across the cluster aren't watched.
+	ConfigMapReader client.Reader
+	ConsulClient    *capi.Client
+	Log             logr.Logger
+
+	// ConfigMap is the name and namespace of the ConfigMap with the services.
+	ConfigMap types.NamespacedName
+	// NodeName is the name of the Consul node the services are registered on.
+	NodeName string
+	// SyncInterval is how often the services are synced with the ConfigMap.
+	SyncInterval time.Duration
+	// Audit records the services the controller registers and deregisters.
+	// It records nothing if nil.
+	Audit *consul.AuditTrail
+}
+
+// Start syncs the services every SyncInterval until the context is
+// cancelled. It implements manager.Runnable so that it runs only on the
+// leader.
+func (r *ExternalServicesController) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.SyncInterval)
+	defer ticker.Stop()
+	for {
+		if err := r.Sync(ctx); err != nil {
+			r.Log.Error(err, "syncing external services")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync registers, configures and links the services of the ConfigMap, and
+// removes the ones that are no longer in it. A missing ConfigMap removes all
+// services, while an invalid one leaves them as they are.
+func (r *ExternalServicesController) Sync(ctx context.Context) error {
+	var cfg ExternalServicesConfig
+	var cm corev1.ConfigMap
+	err := r.ConfigMapReader.Get(ctx, r.ConfigMap, &cm)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("getting configmap %s: %w", r.ConfigMap, err)
+	}
+	if err == nil {
+		if cfg, err = ParseExternalServicesConfig(cm.Data[ExternalServicesConfigMapKey]); err != nil {
+			return fmt.Errorf("configmap %s: %w", r.ConfigMap, err)
+		}
+	}
+
+	if err := r.syncCatalog(cfg); err != nil {
+		return err
+	}
+	if err := r.syncServiceDefaults(ctx, cfg); err != nil {
+		return err
+	}
+	return r.syncTerminatingGateways(ctx, cfg)
+}
+
+// syncCatalog registers the services on the node and deregisters the other
+// services on it.
+func (r *ExternalServicesController) syncCatalog(cfg ExternalServicesConfig) error {
+	desired := make(map[string]bool)
+	for _, svc := range cfg.Services {
+		desired[svc.Name] = true
+		meta := map[string]string{
+			common.SourceKey: common.SourceValue,
+		}
+		for k, v := range svc.Meta {
+			meta[k] = v
+		}
+		if svc.Lambda != nil {
+			region, _ := svc.Lambda.region()
+			meta[lambdaEnabledMetaKey] = "true"
+			meta[lambdaARNMetaKey] = svc.Lambda.ARN
+			meta[lambdaRegionMetaKey] = region
+			meta[lambdaPayloadPassthroughMetaKey] = fmt.Sprintf("%t", svc.Lambda.PayloadPassthrough)
+		}
+		registration := &capi.CatalogRegistration{
+			Node:    r.NodeName,
+			Address: "127.0.0.1",
+			NodeMeta: map[string]string{
+				"external-node":  "true",
+				"external-probe": "false",
+			},
+			Service: &capi.AgentService{
+				ID:      svc.Name,
+				Service: svc.Name,
+				Address: svc.Address,
+				Port:    svc.Port,
+				Meta:    meta,
+			},
+		}
+		if _, err := r.ConsulClient.Catalog().Register(registration, nil); err != nil {
+			return fmt.Errorf("registering service %q: %w", svc.Name, err)
+		}
+		r.Audit.Record(nil, consul.AuditWrite{
+			Operation: consul.AuditOpRegister,
+			Kind:      consul.AuditKindCatalogService,
+			Name:      svc.Name,
+			Payload:   registration,
+		})
+	}
+
+	node, _, err := r.ConsulClient.Catalog().NodeServiceList(r.NodeName, nil)
+	if err != nil {
+		return fmt.Errorf("listing services of node %q: %w", r.NodeName, err)
+	}
+	if node == nil {
+		return nil
+	}
+	for _, svc := range node.Services {
+		if desired[svc.ID] || svc.Meta[common.SourceKey] != common.SourceValue {
+			continue
+		}
+		r.Log.Info("deregistering external service", "name", svc.Service)
+		_, err := r.ConsulClient.Catalog().Deregister(&capi.CatalogDeregistration{
+			Node:      r.NodeName,
+			ServiceID: svc.ID,
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("deregistering service %q: %w", svc.Service, err)
+		}
+		r.Audit.Record(nil, consul.AuditWrite{
+			Operation: consul.AuditOpDeregister,
+			Kind:      consul.AuditKindCatalogService,
+			Name:      svc.ID,
+		})
+	}
+	return nil
+}
+
+// syncServiceDefaults creates or updates a ServiceDefaults resource with the
+// protocol of each service that has one, and deletes the ones of services that
+// were removed. ServiceDefaults that weren't created for the external services
+// are left alone.
+func (r *ExternalServicesController) syncServiceDefaults(ctx context.Context, cfg ExternalServicesConfig) error {
+	desired := make(map[string]bool)
+	for _, svc := range cfg.Services {
+		protocol := svc.protocol()
+		if protocol == "" {
+			continue
+		}
+		desired[svc.Name] = true
+
+		var existing v1alpha1.ServiceDefaults
+		err := r.Client.Get(ctx, types.NamespacedName{Name: svc.Name, Namespace: r.ConfigMap.Namespace}, &existing)
+		if k8serrors.IsNotFound(err) {
+			sd := &v1alpha1.ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      svc.Name,
+					Namespace: r.ConfigMap.Namespace,
+					Labels:    map[string]string{externalServicesLabel: r.ConfigMap.Name},
+				},
+				Spec: v1alpha1.ServiceDefaultsSpec{Protocol: protocol},
+			}
+			if err := r.Client.Create(ctx, sd); err != nil {
+				return fmt.Errorf("creating service defaults %q: %w", svc.Name, err)
+			}
+			continue
+		} else if err != nil {
+			return fmt.Errorf("getting service defaults %q: %w", svc.Name, err)
+		}
+		if existing.Labels[externalServicesLabel] != r.ConfigMap.Name {
+			r.Log.Info("service defaults already exist, not setting the protocol of external service", "name", svc.Name)
+			continue
+		}
+		if existing.Spec.Protocol != protocol {
+			existing.Spec.Protocol = protocol
+			if err := r.Client.Update(ctx, &existing); err != nil {
+				return fmt.Errorf("updating service defaults %q: %w", svc.Name, err)
+			}
+		}
+	}
+
+	var list v1alpha1.ServiceDefaultsList
+	if err := r.Client.List(ctx, &list, client.InNamespace(r.ConfigMap.Namespace),
+		client.MatchingLabels{externalServicesLabel: r.ConfigMap.Name}); err != nil {
+		return fmt.Errorf("listing service defaults: %w", err)
+	}
+	for i := range list.Items {
+		sd := &list.Items[i]
+		if desired[sd.Name] {
+			continue
+		}
+		if err := r.Client.Delete(ctx, sd); err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("deleting service defaults %q: %w", sd.Name, err)
+		}
+	}
+	return nil
+}
+
+// syncTerminatingGateways links the services to their TerminatingGateway
+// resources, creating them if needed, and unlinks the services that were
+// removed. Linked services that weren't added for the external services are
+// kept.
+func (r *ExternalServicesController) syncTerminatingGateways(ctx context.Context, cfg ExternalServicesConfig) error {
+	desired := make(map[string][]v1alpha1.LinkedService)
+	for _, svc := range cfg.Services {
+		gateway := svc.TerminatingGateway
+		if gateway == "" {
+			gateway = cfg.TerminatingGateway
+		}
+		if gateway == "" {
+			continue
+		}
+		desired[gateway] = append(desired[gateway], v1alpha1.LinkedService{
+			Name:     svc.Name,
+			CAFile:   svc.CAFile,
+			CertFile: svc.CertFile,
+			KeyFile:  svc.KeyFile,
+			SNI:      svc.SNI,
+		})
+	}
+
+	// Gateways that services were linked to before have to be synced too, so
+	// that the services are unlinked.
+	var list v1alpha1.TerminatingGatewayList
+	if err := r.Client.List(ctx, &list, client.InNamespace(r.ConfigMap.Namespace)); err != nil {
+		return fmt.Errorf("listing terminating gateways: %w", err)
+	}
+	gateways := make(map[string]bool)
+	for gateway := range desired {
+		gateways[gateway] = true
+	}
+	for _, tg := range list.Items {
+		if _, ok := tg.Annotations[externalServicesAnnotation]; ok {
+			gateways[tg.Name] = true
+		}
+	}
+
+	names := make([]string, 0, len(gateways))
+	for gateway := range gateways {
+		names = append(names, gateway)
+	}
+	sort.Strings(names)
+	for _, gateway := range names {
+		if err := r.syncTerminatingGateway(ctx, gateway, desired[gateway]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ExternalServicesController) syncTerminatingGateway(ctx context.Context, name string, linked []v1alpha1.LinkedService) error {
+	managed := make([]string, 0, len(linked))
+	for _, svc := range linked {
+		managed = append(managed, svc.Name)
+	}
+	sort.Strings(managed)
+
+	var tg v1alpha1.TerminatingGateway
+	err := r.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: r.ConfigMap.Namespace}, &tg)
+	if k8serrors.IsNotFound(err) {
+		if len(linked) == 0 {
+			return nil
+		}
+		tg = v1alpha1.TerminatingGateway{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   r.ConfigMap.Namespace,
+				Labels:      map[string]string{externalServicesLabel: r.ConfigMap.Name},
+				Annotations: map[string]string{externalServicesAnnotation: strings.Join(managed, ",")},
+			},
+			Spec: v1alpha1.TerminatingGatewaySpec{Services: linked},
+		}
+		if err := r.Client.Create(ctx, &tg); err != nil {
+			return fmt.Errorf("creating terminating gateway %q: %w", name, err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("getting terminating gateway %q: %w", name, err)
+	}
+
+	// Keep the services linked by others and replace the ones linked before.
+	previous := make(map[string]bool)
+	if raw := tg.Annotations[externalServicesAnnotation]; raw != "" {
+		for _, svc := range strings.Split(raw, ",") {
+			previous[svc] = true
+		}
+	}
+	current := make(map[string]bool)
+	for _, svc := range managed {
+		current[svc] = true
+	}
+	var services []v1alpha1.LinkedService
+	for _, svc := range tg.Spec.Services {
+		if !previous[svc.Name] && !current[svc.Name] {
+			services = append(services, svc)
+		}
+	}
+	services = append(services, linked...)
+
+	if len(services) == 0 && tg.Labels[externalServicesLabel] == r.ConfigMap.Name {
+		if err := r.Client.Delete(ctx, &tg); err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("deleting terminating gateway %q: %w", name, err)
+		}
+		return nil
+	}
+
+	annotation := strings.Join(managed, ",")
+	if reflect.DeepEqual(services, tg.Spec.Services) && tg.Annotations[externalServicesAnnotation] == annotation {
+		return nil
+	}
+	if tg.Annotations == nil {
+		tg.Annotations = make(map[string]string)
+	}
+	if annotation != "" {
+		tg.Annotations[externalServicesAnnotation] = annotation
+	} else {
+		delete(tg.Annotations, externalServicesAnnotation)
+	}
+	tg.Spec.Services = services
+	if err := r.Client.Update(ctx, &tg); err != nil {
+		return fmt.Errorf("updating terminating gateway %q: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParseExternalServicesConfig(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		raw    string
+		expErr string
+	}{
+		"address and port": {
+			raw: `
+services:
+- name: billing
+  address: 10.0.0.5
+  port: 8080`,
+		},
+		"lambda": {
+			raw: `
+services:
+- name: thumbnails
+  lambda:
+    arn: arn:aws:lambda:us-east-1:123456789012:function:thumbnails`,
+		},
+		"unknown field": {
+			raw:    "services:\n- name: billing\n  hostname: billing.internal",
+			expErr: `unable to parse external services: error unmarshaling JSON: while decoding JSON: json: unknown field "hostname"`,
+		},
+		"missing name": {
+			raw:    "services:\n- address: 10.0.0.5\n  port: 8080",
+			expErr: "service 0: name must be set",
+		},
+		"duplicate name": {
+			raw:    "services:\n- name: billing\n  address: 10.0.0.5\n  port: 8080\n- name: billing\n  address: 10.0.0.6\n  port: 8080",
+			expErr: `service "billing" is defined more than once`,
+		},
+		"missing port": {
+			raw:    "services:\n- name: billing\n  address: 10.0.0.5",
+			expErr: `service "billing": address and port must be set`,
+		},
+		"lambda with address": {
+			raw:    "services:\n- name: thumbnails\n  address: 10.0.0.5\n  lambda:\n    arn: arn:aws:lambda:us-east-1:123456789012:function:thumbnails",
+			expErr: `service "thumbnails": address and port can't be set for a Lambda function`,
+		},
+		"invalid lambda ARN": {
+			raw:    "services:\n- name: thumbnails\n  lambda:\n    arn: thumbnails",
+			expErr: `service "thumbnails": lambda ARN "thumbnails" is invalid`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseExternalServicesConfig(c.raw)
+			if c.expErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, c.expErr)
+			}
+		})
+	}
+}
+
+// Test that the terminating gateway keeps the services linked by others when
+// external services are linked to and unlinked from it.
+func TestExternalServicesController_SyncTerminatingGateways(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tg := &v1alpha1.TerminatingGateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "terminating-gateway", Namespace: "default"},
+		Spec: v1alpha1.TerminatingGatewaySpec{
+			Services: []v1alpha1.LinkedService{{Name: "database"}},
+		},
+	}
+	r := &ExternalServicesController{
+		Client:    fake.NewClientBuilder().WithScheme(externalServicesScheme()).WithRuntimeObjects(tg).Build(),
+		Log:       logrtest.TestLogger{T: t},
+		ConfigMap: types.NamespacedName{Name: "external-services", Namespace: "default"},
+	}
+	cfg, err := ParseExternalServicesConfig(`
+terminatingGateway: terminating-gateway
+services:
+- name: billing
+  address: 10.0.0.5
+  port: 8080
+  sni: billing.internal
+- name: thumbnails
+  terminatingGateway: lambda-gateway
+  lambda:
+    arn: arn:aws:lambda:us-east-1:123456789012:function:thumbnails`)
+	require.NoError(t, err)
+	require.NoError(t, r.syncTerminatingGateways(ctx, cfg))
+
+	require.NoError(t, r.Client.Get(ctx, types.NamespacedName{Name: "terminating-gateway", Namespace: "default"}, tg))
+	require.Equal(t, []v1alpha1.LinkedService{{Name: "database"}, {Name: "billing", SNI: "billing.internal"}}, tg.Spec.Services)
+	require.Equal(t, "billing", tg.Annotations[externalServicesAnnotation])
+
+	var lambdaGateway v1alpha1.TerminatingGateway
+	require.NoError(t, r.Client.Get(ctx, types.NamespacedName{Name: "lambda-gateway", Namespace: "default"}, &lambdaGateway))
+	require.Equal(t, []v1alpha1.LinkedService{{Name: "thumbnails"}}, lambdaGateway.Spec.Services)
+	require.Equal(t, "external-services", lambdaGateway.Labels[externalServicesLabel])
+
+	// Removing the services unlinks them and deletes the gateway that was
+	// created for them.
+	require.NoError(t, r.syncTerminatingGateways(ctx, ExternalServicesConfig{}))
+
+	require.NoError(t, r.Client.Get(ctx, types.NamespacedName{Name: "terminating-gateway", Namespace: "default"}, tg))
+	require.Equal(t, []v1alpha1.LinkedService{{Name: "database"}}, tg.Spec.Services)
+	require.NotContains(t, tg.Annotations, externalServicesAnnotation)
+
+	err = r.Client.Get(ctx, types.NamespacedName{Name: "lambda-gateway", Namespace: "default"}, &lambdaGateway)
+	require.True(t, k8serrors.IsNotFound(err))
+}
+
+// Test that service defaults are created for the protocols of the services and
+// that service defaults not created for them are left alone.
+func TestExternalServicesController_SyncServiceDefaults(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	existing := &v1alpha1.ServiceDefaults{
+		ObjectMeta: metav1.ObjectMeta{Name: "billing", Namespace: "default"},
+		Spec:       v1alpha1.ServiceDefaultsSpec{Protocol: "grpc"},
+	}
+	r := &ExternalServicesController{
+		Client:    fake.NewClientBuilder().WithScheme(externalServicesScheme()).WithRuntimeObjects(existing).Build(),
+		Log:       logrtest.TestLogger{T: t},
+		ConfigMap: types.NamespacedName{Name: "external-services", Namespace: "default"},
+	}
+	cfg, err := ParseExternalServicesConfig(`
+services:
+- name: billing
+  address: 10.0.0.5
+  port: 8080
+  protocol: http
+- name: inventory
+  address: 10.0.0.6
+  port: 8080
+- name: thumbnails
+  lambda:
+    arn: arn:aws:lambda:us-east-1:123456789012:function:thumbnails`)
+	require.NoError(t, err)
+	require.NoError(t, r.syncServiceDefaults(ctx, cfg))
+
+	var sd v1alpha1.ServiceDefaults
+	require.NoError(t, r.Client.Get(ctx, types.NamespacedName{Name: "billing", Namespace: "default"}, &sd))
+	require.Equal(t, "grpc", sd.Spec.Protocol)
+	require.NoError(t, r.Client.Get(ctx, types.NamespacedName{Name: "thumbnails", Namespace: "default"}, &sd))
+	require.Equal(t, "http", sd.Spec.Protocol)
+	err = r.Client.Get(ctx, types.NamespacedName{Name: "inventory", Namespace: "default"}, &sd)
+	require.True(t, k8serrors.IsNotFound(err))
+
+	require.NoError(t, r.syncServiceDefaults(ctx, ExternalServicesConfig{}))
+	var list v1alpha1.ServiceDefaultsList
+	require.NoError(t, r.Client.List(ctx, &list))
+	require.Len(t, list.Items, 1)
+	require.Equal(t, "billing", list.Items[0].Name)
+}
+
+// Test that services are registered with the Lambda meta and deregistered
+// once they are removed from the ConfigMap.
+func TestExternalServicesController_Sync(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	consul, err := testutil.NewTestServerConfigT(t, nil)
+	require.NoError(t, err)
+	defer consul.Stop()
+	consul.WaitForLeader(t)
+	consulClient, err := capi.NewClient(&capi.Config{Address: consul.HTTPAddr})
+	require.NoError(t, err)
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "external-services", Namespace: "default"},
+		Data: map[string]string{ExternalServicesConfigMapKey: `
+services:
+- name: billing
+  address: 10.0.0.5
+  port: 8080
+- name: thumbnails
+  lambda:
+    arn: arn:aws:lambda:us-east-1:123456789012:function:thumbnails
+    payloadPassthrough: true`},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(externalServicesScheme()).WithRuntimeObjects(cm).Build()
+	r := &ExternalServicesController{
+		Client:          k8sClient,
+		ConfigMapReader: k8sClient,
+		ConsulClient:    consulClient,
+		Log:             logrtest.TestLogger{T: t},
+		ConfigMap:       types.NamespacedName{Name: cm.Name, Namespace: cm.Namespace},
+		NodeName:        "k8s-external-services",
+	}
+	require.NoError(t, r.Sync(ctx))
+
+	billing, _, err := consulClient.Catalog().Service("billing", "", nil)
+	require.NoError(t, err)
+	require.Len(t, billing, 1)
+	require.Equal(t, "10.0.0.5", billing[0].ServiceAddress)
+	require.Equal(t, 8080, billing[0].ServicePort)
+
+	thumbnails, _, err := consulClient.Catalog().Service("thumbnails", "", nil)
+	require.NoError(t, err)
+	require.Len(t, thumbnails, 1)
+	require.Equal(t, "true", thumbnails[0].ServiceMeta[lambdaEnabledMetaKey])
+	require.Equal(t, "us-east-1", thumbnails[0].ServiceMeta[lambdaRegionMetaKey])
+	require.Equal(t, "true", thumbnails[0].ServiceMeta[lambdaPayloadPassthroughMetaKey])
+
+	require.NoError(t, k8sClient.Delete(ctx, cm))
+	require.NoError(t, r.Sync(ctx))
+
+	node, _, err := consulClient.Catalog().NodeServiceList("k8s-external-services", nil)
+	require.NoError(t, err)
+	require.Empty(t, node.Services)
+}
+
+func externalServicesScheme() *runtime.Scheme {
+	s := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(s)
+	_ = v1alpha1.AddToScheme(s)
+	return s
+}
@@ -0,0 +1,289 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	capi "github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// InvalidExternalWorkloadError is the reason of the Synced condition of
+	// an ExternalWorkload with an invalid spec.
+	InvalidExternalWorkloadError = "InvalidExternalWorkloadError"
+
+	// Meta keys of the service instances of external workloads.
+	externalWorkloadNameMetaKey      = "external-workload-name"
+	externalWorkloadNamespaceMetaKey = "external-workload-namespace"
+)
+
+// ExternalWorkloadController registers the workloads described by
+// ExternalWorkload resources, such as VMs, as service instances in the Consul
+// catalog, so that they can be part of the mesh without running a Consul
+// agent.
+//
+// The instances are registered on a synthetic node in the partition of the
+// controller's Consul client. Since no agent runs their checks, the
+// controller probes their health endpoints itself and registers the result
+// as the status of a check of the instance. Instances are deregistered when
+// their resource is deleted.
+type ExternalWorkloadController struct {
+	client.Client
+	ConsulClient *capi.Client
+	Log          logr.Logger
+
+	// NodeName is the name of the Consul node the workloads are registered on.
+	NodeName string
+
+	// EnableConsulNamespaces, ConsulDestinationNamespace, EnableNSMirroring,
+	// NSMirroringPrefix and NSMirroringRules select the Consul namespace of a
+	// workload from its Kubernetes namespace, like for config entries.
+	EnableConsulNamespaces     bool
+	ConsulDestinationNamespace string
+	EnableNSMirroring          bool
+	NSMirroringPrefix          string
+	NSMirroringRules           namespaces.MirroringRules
+	// CrossNSACLPolicy is the name of the ACL policy to attach to any created
+	// Consul namespaces. Only necessary if ACLs are enabled.
+	CrossNSACLPolicy string
+
+	// Audit records the service instances the controller registers and
+	// deregisters. It records nothing if nil.
+	Audit *consul.AuditTrail
+
+	// Probe checks the health endpoint of a workload and returns an error if
+	// it is unhealthy. It defaults to requesting or dialing the endpoint.
+	Probe func(ctx context.Context, check *v1alpha1.ExternalWorkloadHealthCheck) error
+}
+
+// +kubebuilder:rbac:groups=consul.hashicorp.com,resources=externalworkloads,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=consul.hashicorp.com,resources=externalworkloads/status,verbs=get;update;patch
+
+func (r *ExternalWorkloadController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := r.Log.WithValues("request", req.NamespacedName)
+
+	var workload v1alpha1.ExternalWorkload
+	if err := r.Get(ctx, req.NamespacedName, &workload); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	consulNS := namespaces.ConsulNamespace(workload.Namespace, r.EnableConsulNamespaces, r.ConsulDestinationNamespace,
+		r.EnableNSMirroring, r.NSMirroringPrefix, r.NSMirroringRules)
+
+	if !workload.GetDeletionTimestamp().IsZero() {
+		if !containsString(workload.Finalizers, FinalizerName) {
+			return ctrl.Result{}, nil
+		}
+		logger.Info("deletion event")
+		_, err := r.ConsulClient.Catalog().Deregister(&capi.CatalogDeregistration{
+			Node:      r.NodeName,
+			ServiceID: externalWorkloadServiceID(&workload),
+			Namespace: consulNS,
+		}, nil)
+		if err != nil {
+			return r.syncFailed(ctx, logger, &workload, ConsulAgentError,
+				fmt.Errorf("deregistering service instance from consul: %w", err))
+		}
+		r.Audit.Record(&workload, consul.AuditWrite{
+			Operation: consul.AuditOpDeregister,
+			Kind:      consul.AuditKindCatalogService,
+			Name:      externalWorkloadServiceID(&workload),
+			Namespace: consulNS,
+		})
+		workload.Finalizers = removeString(workload.Finalizers, FinalizerName)
+		if err := r.Update(ctx, &workload); err != nil {
+			return ctrl.Result{}, err
+		}
+		logger.Info("finalizer removed")
+		return ctrl.Result{}, nil
+	}
+
+	if !containsString(workload.Finalizers, FinalizerName) {
+		workload.Finalizers = append(workload.Finalizers, FinalizerName)
+		if err := r.Update(ctx, &workload); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// An invalid workload isn't requeued since it can only be fixed by
+	// updating it.
+	if err := workload.Validate(); err != nil {
+		logger.Error(err, "invalid external workload")
+		workload.SetSyncedCondition(corev1.ConditionFalse, InvalidExternalWorkloadError, err.Error())
+		return ctrl.Result{}, r.Status().Update(ctx, &workload)
+	}
+
+	if r.EnableConsulNamespaces {
+		created, err := namespaces.EnsureExists(r.ConsulClient, consulNS, r.CrossNSACLPolicy)
+		if err != nil {
+			return r.syncFailed(ctx, logger, &workload, ConsulAgentError,
+				fmt.Errorf("creating consul namespace %q: %w", consulNS, err))
+		}
+		if created {
+			logger.Info("consul namespace created", "ns", consulNS)
+		}
+	}
+
+	registration := r.registration(ctx, &workload, consulNS)
+	_, err := r.ConsulClient.Catalog().Register(registration, nil)
+	if err != nil {
+		return r.syncFailed(ctx, logger, &workload, ConsulAgentError,
+			fmt.Errorf("registering service instance in consul: %w", err))
+	}
+	r.Audit.Record(&workload, consul.AuditWrite{
+		Operation: consul.AuditOpRegister,
+		Kind:      consul.AuditKindCatalogService,
+		Name:      externalWorkloadServiceID(&workload),
+		Namespace: consulNS,
+		Payload:   registration,
+	})
+
+	var result ctrl.Result
+	if workload.Spec.HealthCheck != nil {
+		result.RequeueAfter = workload.Spec.HealthCheck.IntervalOrDefault()
+	}
+	if workload.SyncedConditionStatus() != corev1.ConditionTrue {
+		logger.Info("service instance registered", "service", workload.ServiceName())
+		workload.SetSyncedCondition(corev1.ConditionTrue, "", "")
+		now := metav1.Now()
+		workload.SetLastSyncedTime(&now)
+		if err := r.Status().Update(ctx, &workload); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return result, nil
+}
+
+// registration returns the catalog registration of the workload's service
+// instance, with a check whose status is the result of probing the health
+// endpoint.
+func (r *ExternalWorkloadController) registration(ctx context.Context, workload *v1alpha1.ExternalWorkload, consulNS string) *capi.CatalogRegistration {
+	serviceID := externalWorkloadServiceID(workload)
+	meta := map[string]string{
+		common.SourceKey:                 common.SourceValue,
+		externalWorkloadNameMetaKey:      workload.Name,
+		externalWorkloadNamespaceMetaKey: workload.Namespace,
+	}
+	for k, v := range workload.Spec.Meta {
+		meta[k] = v
+	}
+
+	status, output := capi.HealthPassing, "No health check is configured."
+	if check := workload.Spec.HealthCheck; check != nil {
+		probe := r.Probe
+		if probe == nil {
+			probe = probeExternalWorkload
+		}
+		output = "Health check passed."
+		if err := probe(ctx, check); err != nil {
+			status, output = capi.HealthCritical, err.Error()
+		}
+	}
+
+	return &capi.CatalogRegistration{
+		Node:    r.NodeName,
+		Address: "127.0.0.1",
+		NodeMeta: map[string]string{
+			"external-node":  "true",
+			"external-probe": "false",
+		},
+		SkipNodeUpdate: true,
+		Service: &capi.AgentService{
+			ID:        serviceID,
+			Service:   workload.ServiceName(),
+			Address:   workload.Spec.Address,
+			Port:      workload.Spec.Port,
+			Tags:      workload.Spec.Tags,
+			Meta:      meta,
+			Namespace: consulNS,
+		},
+		Check: &capi.AgentCheck{
+			Node:        r.NodeName,
+			CheckID:     serviceID + "-health",
+			Name:        "External workload health check",
+			Status:      status,
+			Output:      output,
+			ServiceID:   serviceID,
+			ServiceName: workload.ServiceName(),
+			Namespace:   consulNS,
+		},
+	}
+}
+
+func (r *ExternalWorkloadController) syncFailed(ctx context.Context, logger logr.Logger, workload *v1alpha1.ExternalWorkload, errType string, err error) (ctrl.Result, error) {
+	workload.SetSyncedCondition(corev1.ConditionFalse, errType, err.Error())
+	if updateErr := r.Status().Update(ctx, workload); updateErr != nil {
+		// Log the original error here because we are returning the updateErr.
+		// Otherwise the original error would be lost.
+		logger.Error(err, "sync failed")
+		return ctrl.Result{}, updateErr
+	}
+	return ctrl.Result{}, err
+}
+
+func (r *ExternalWorkloadController) SetupWithManager(mgr ctrl.Manager) error {
+	// Status updates don't change the generation, so they don't trigger
+	// reconciles. The health endpoints are probed by requeueing instead.
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.ExternalWorkload{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
+
+// externalWorkloadServiceID returns the ID of the workload's service instance,
+// which is unique on the node across Kubernetes namespaces.
+func externalWorkloadServiceID(workload *v1alpha1.ExternalWorkload) string {
+	return fmt.Sprintf("%s-%s", workload.Namespace, workload.Name)
+}
+
+// probeExternalWorkload requests the HTTP endpoint or dials the TCP endpoint
+// of the check.
+func probeExternalWorkload(ctx context.Context, check *v1alpha1.ExternalWorkloadHealthCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, check.TimeoutOrDefault())
+	defer cancel()
+
+	if check.TCP != "" {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", check.TCP)
+		if err != nil {
+			return fmt.Errorf("TCP connect %s: %w", check.TCP, err)
+		}
+		return conn.Close()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.HTTP, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP GET %s: %w", check.HTTP, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP GET %s: %s", check.HTTP, resp.Status)
+	}
+	return nil
+}
+
+// removeString returns the slice without the string s.
+func removeString(slice []string, s string) []string {
+	var result []string
+	for _, item := range slice {
+		if item != s {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeCatalog records the catalog registrations and deregistrations of a fake
+// Consul server.
+type fakeCatalog struct {
+	mu              sync.Mutex
+	registrations   []capi.CatalogRegistration
+	deregistrations []capi.CatalogDeregistration
+}
+
+func (f *fakeCatalog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var err error
+	switch r.URL.Path {
+	case "/v1/catalog/register":
+		var reg capi.CatalogRegistration
+		err = json.NewDecoder(r.Body).Decode(&reg)
+		f.registrations = append(f.registrations, reg)
+	case "/v1/catalog/deregister":
+		var dereg capi.CatalogDeregistration
+		err = json.NewDecoder(r.Body).Decode(&dereg)
+		f.deregistrations = append(f.deregistrations, dereg)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	_, _ = w.Write([]byte("true"))
+}
+
+func newExternalWorkloadController(t *testing.T, objs ...*v1alpha1.ExternalWorkload) (*ExternalWorkloadController, *fakeCatalog) {
+	catalog := &fakeCatalog{}
+	srv := httptest.NewServer(catalog)
+	t.Cleanup(srv.Close)
+	consulClient, err := capi.NewClient(&capi.Config{Address: srv.URL})
+	require.NoError(t, err)
+
+	builder := fake.NewClientBuilder().WithScheme(externalServicesScheme())
+	for _, obj := range objs {
+		builder = builder.WithRuntimeObjects(obj)
+	}
+	return &ExternalWorkloadController{
+		Client:       builder.Build(),
+		ConsulClient: consulClient,
+		Log:          logrtest.TestLogger{T: t},
+		NodeName:     "k8s-external-workloads",
+	}, catalog
+}
+
+func TestExternalWorkloadController_Register(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	workload := &v1alpha1.ExternalWorkload{
+		ObjectMeta: metav1.ObjectMeta{Name: "billing-vm-1", Namespace: "default"},
+		Spec: v1alpha1.ExternalWorkloadSpec{
+			Service: "billing",
+			Address: "10.0.0.5",
+			Port:    8080,
+			Tags:    []string{"vm"},
+			Meta:    map[string]string{"version": "2"},
+			HealthCheck: &v1alpha1.ExternalWorkloadHealthCheck{
+				HTTP:     "http://10.0.0.5:8080/health",
+				Interval: metav1.Duration{Duration: 30 * time.Second},
+			},
+		},
+	}
+	r, catalog := newExternalWorkloadController(t, workload)
+	healthy := true
+	r.Probe = func(_ context.Context, check *v1alpha1.ExternalWorkloadHealthCheck) error {
+		require.Equal(t, "http://10.0.0.5:8080/health", check.HTTP)
+		if !healthy {
+			return errors.New("HTTP GET http://10.0.0.5:8080/health: 503 Service Unavailable")
+		}
+		return nil
+	}
+
+	name := types.NamespacedName{Name: "billing-vm-1", Namespace: "default"}
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: name})
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Second, result.RequeueAfter)
+
+	require.Len(t, catalog.registrations, 1)
+	reg := catalog.registrations[0]
+	require.Equal(t, "k8s-external-workloads", reg.Node)
+	require.Equal(t, "default-billing-vm-1", reg.Service.ID)
+	require.Equal(t, "billing", reg.Service.Service)
+	require.Equal(t, "10.0.0.5", reg.Service.Address)
+	require.Equal(t, 8080, reg.Service.Port)
+	require.Equal(t, []string{"vm"}, reg.Service.Tags)
+	require.Equal(t, map[string]string{
+		"external-source":             "kubernetes",
+		"external-workload-name":      "billing-vm-1",
+		"external-workload-namespace": "default",
+		"version":                     "2",
+	}, reg.Service.Meta)
+	require.Equal(t, capi.HealthPassing, reg.Check.Status)
+	require.Equal(t, "default-billing-vm-1", reg.Check.ServiceID)
+
+	require.NoError(t, r.Get(ctx, name, workload))
+	require.Contains(t, workload.Finalizers, FinalizerName)
+	require.Equal(t, corev1.ConditionTrue, workload.SyncedConditionStatus())
+	require.NotNil(t, workload.Status.LastSyncedTime)
+
+	// A failing probe marks the instance critical.
+	healthy = false
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: name})
+	require.NoError(t, err)
+	require.Len(t, catalog.registrations, 2)
+	require.Equal(t, capi.HealthCritical, catalog.registrations[1].Check.Status)
+	require.Equal(t, "HTTP GET http://10.0.0.5:8080/health: 503 Service Unavailable", catalog.registrations[1].Check.Output)
+}
+
+func TestExternalWorkloadController_Invalid(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	workload := &v1alpha1.ExternalWorkload{
+		ObjectMeta: metav1.ObjectMeta{Name: "billing", Namespace: "default"},
+		Spec:       v1alpha1.ExternalWorkloadSpec{Address: "billing.internal", Port: 8080},
+	}
+	r, catalog := newExternalWorkloadController(t, workload)
+
+	name := types.NamespacedName{Name: "billing", Namespace: "default"}
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: name})
+	require.NoError(t, err)
+	require.Equal(t, ctrl.Result{}, result)
+	require.Empty(t, catalog.registrations)
+
+	require.NoError(t, r.Get(ctx, name, workload))
+	cond := workload.Status.GetCondition(v1alpha1.ConditionSynced)
+	require.True(t, cond.IsFalse())
+	require.Equal(t, InvalidExternalWorkloadError, cond.Reason)
+	require.Contains(t, cond.Message, "must be an IP address")
+}
+
+func TestExternalWorkloadController_Delete(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	now := metav1.Now()
+	workload := &v1alpha1.ExternalWorkload{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "billing",
+			Namespace:         "default",
+			Finalizers:        []string{FinalizerName},
+			DeletionTimestamp: &now,
+		},
+		Spec: v1alpha1.ExternalWorkloadSpec{Address: "10.0.0.5", Port: 8080},
+	}
+	r, catalog := newExternalWorkloadController(t, workload)
+
+	name := types.NamespacedName{Name: "billing", Namespace: "default"}
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: name})
+	require.NoError(t, err)
+	require.Empty(t, catalog.registrations)
+	require.Equal(t, []capi.CatalogDeregistration{{Node: "k8s-external-workloads", ServiceID: "default-billing"}}, catalog.deregistrations)
+
+	// Removing the finalizer lets the resource be deleted.
+	err = r.Get(ctx, name, workload)
+	require.True(t, k8serrors.IsNotFound(err))
+}
+
+func TestProbeExternalWorkload(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	require.NoError(t, probeExternalWorkload(ctx, &v1alpha1.ExternalWorkloadHealthCheck{HTTP: srv.URL}))
+	status = http.StatusServiceUnavailable
+	require.EqualError(t, probeExternalWorkload(ctx, &v1alpha1.ExternalWorkloadHealthCheck{HTTP: srv.URL}),
+		"HTTP GET "+srv.URL+": 503 Service Unavailable")
+
+	require.NoError(t, probeExternalWorkload(ctx, &v1alpha1.ExternalWorkloadHealthCheck{TCP: srv.Listener.Addr().String()}))
+
+	// Nothing listens on a port that was just closed.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	require.Error(t, probeExternalWorkload(ctx, &v1alpha1.ExternalWorkloadHealthCheck{TCP: addr}))
+}
@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	capi "github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GossipKeyringController keeps the gossip encryption keyring of the Consul
+// cluster in sync with the gossip encryption key stored in a Kubernetes secret.
+// When the key in the secret changes, it installs the new key on all agents,
+// makes it the primary key once every agent has it and then removes the old
+// keys. Rotating the gossip key therefore only requires updating the secret,
+// which is also what new server and client pods read their key from.
+//
+// The secret is polled rather than watched so that the controller only needs
+// permission to get this one secret.
+type GossipKeyringController struct {
+	// Client reads the secret. It should not be backed by the manager's cache
+	// so that secrets across the cluster aren't watched.
+	Client       client.Reader
+	ConsulClient *capi.Client
+	Log          logr.Logger
+
+	// Secret is the name and namespace of the secret with the gossip key.
+	Secret types.NamespacedName
+	// SecretKey is the key within the secret that holds the gossip key.
+	SecretKey string
+	// SyncInterval is how often the keyring is synced with the secret.
+	SyncInterval time.Duration
+}
+
+// Start syncs the keyring every SyncInterval until the context is cancelled.
+// It implements manager.Runnable so that it runs only on the leader.
+func (r *GossipKeyringController) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.SyncInterval)
+	defer ticker.Stop()
+	for {
+		if err := r.Sync(ctx); err != nil {
+			r.Log.Error(err, "syncing gossip keyring")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync takes the next step of bringing the keyring in line with the key in
+// the secret: installing the key, making it the primary key and removing the
+// old keys. Each step only happens once the previous one has converged on all
+// agents, so a rotation can take several syncs.
+func (r *GossipKeyringController) Sync(ctx context.Context) error {
+	var secret corev1.Secret
+	if err := r.Client.Get(ctx, r.Secret, &secret); err != nil {
+		return fmt.Errorf("getting secret %s: %w", r.Secret, err)
+	}
+	key := strings.TrimSpace(string(secret.Data[r.SecretKey]))
+	if key == "" {
+		return fmt.Errorf("secret %s has no gossip key at %q", r.Secret, r.SecretKey)
+	}
+
+	rings, err := r.ConsulClient.Operator().KeyringList(nil)
+	if err != nil {
+		return fmt.Errorf("listing gossip keyring: %w", err)
+	}
+	state := keyringStateOf(rings, key)
+
+	switch {
+	case !state.installed:
+		r.Log.Info("installing new gossip key")
+		if err := r.ConsulClient.Operator().KeyringInstall(key, nil); err != nil {
+			return fmt.Errorf("installing gossip key: %w", err)
+		}
+	case !state.primary:
+		r.Log.Info("making new gossip key the primary key")
+		if err := r.ConsulClient.Operator().KeyringUse(key, nil); err != nil {
+			return fmt.Errorf("making gossip key the primary key: %w", err)
+		}
+	default:
+		// Older Consul versions don't report the primary keys, so make sure the
+		// key is the primary key before the old ones are removed.
+		if state.primaryUnknown && len(state.oldKeys) > 0 {
+			if err := r.ConsulClient.Operator().KeyringUse(key, nil); err != nil {
+				return fmt.Errorf("making gossip key the primary key: %w", err)
+			}
+		}
+		for _, old := range state.oldKeys {
+			r.Log.Info("removing old gossip key")
+			if err := r.ConsulClient.Operator().KeyringRemove(old, nil); err != nil {
+				return fmt.Errorf("removing old gossip key: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// keyringState is how far the key has been rolled out across the keyrings of
+// the LAN and WAN gossip pools.
+type keyringState struct {
+	// installed is whether every agent has the key.
+	installed bool
+	// primary is whether the key is the primary key of every agent. It is
+	// true if Consul doesn't report the primary keys, in which case
+	// primaryUnknown is set.
+	primary        bool
+	primaryUnknown bool
+	// oldKeys are the other keys that agents still have.
+	oldKeys []string
+}
+
+func keyringStateOf(rings []*capi.KeyringResponse, key string) keyringState {
+	state := keyringState{installed: true, primary: true}
+	seen := make(map[string]bool)
+	for _, ring := range rings {
+		if ring.Keys[key] < ring.NumNodes {
+			state.installed = false
+		}
+		if ring.PrimaryKeys == nil {
+			state.primaryUnknown = true
+		} else if ring.PrimaryKeys[key] < ring.NumNodes {
+			state.primary = false
+		}
+		for k := range ring.Keys {
+			if k != key && !seen[k] {
+				seen[k] = true
+				state.oldKeys = append(state.oldKeys, k)
+			}
+		}
+	}
+	sort.Strings(state.oldKeys)
+	return state
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	oldGossipKey = "6Ypk7MBp4c+6HR6/r9Czs1Cr0OfANRUzGA2E6UXbZs4="
+	newGossipKey = "bUeVY1mVdHzvHq3e6G3dJ4bKcnS2ndD3EptSN4UX0Bk="
+)
+
+func TestKeyringStateOf(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		rings    []*capi.KeyringResponse
+		expState keyringState
+	}{
+		"not installed on all agents": {
+			rings: []*capi.KeyringResponse{
+				{Keys: map[string]int{oldGossipKey: 3, newGossipKey: 3}, PrimaryKeys: map[string]int{oldGossipKey: 3}, NumNodes: 3},
+				{WAN: true, Keys: map[string]int{oldGossipKey: 2, newGossipKey: 1}, PrimaryKeys: map[string]int{oldGossipKey: 2}, NumNodes: 2},
+			},
+			expState: keyringState{primary: false, oldKeys: []string{oldGossipKey}},
+		},
+		"installed but not primary": {
+			rings: []*capi.KeyringResponse{
+				{Keys: map[string]int{oldGossipKey: 3, newGossipKey: 3}, PrimaryKeys: map[string]int{oldGossipKey: 1, newGossipKey: 2}, NumNodes: 3},
+			},
+			expState: keyringState{installed: true, oldKeys: []string{oldGossipKey}},
+		},
+		"primary with old key": {
+			rings: []*capi.KeyringResponse{
+				{Keys: map[string]int{oldGossipKey: 3, newGossipKey: 3}, PrimaryKeys: map[string]int{newGossipKey: 3}, NumNodes: 3},
+			},
+			expState: keyringState{installed: true, primary: true, oldKeys: []string{oldGossipKey}},
+		},
+		"primary keys not reported": {
+			rings: []*capi.KeyringResponse{
+				{Keys: map[string]int{oldGossipKey: 3, newGossipKey: 3}, NumNodes: 3},
+			},
+			expState: keyringState{installed: true, primary: true, primaryUnknown: true, oldKeys: []string{oldGossipKey}},
+		},
+		"converged": {
+			rings: []*capi.KeyringResponse{
+				{Keys: map[string]int{newGossipKey: 3}, PrimaryKeys: map[string]int{newGossipKey: 3}, NumNodes: 3},
+			},
+			expState: keyringState{installed: true, primary: true},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.expState, keyringStateOf(c.rings, newGossipKey))
+		})
+	}
+}
+
+// Test that syncing repeatedly rotates the keyring to the key in the secret.
+func TestGossipKeyringController_Sync(t *testing.T) {
+	t.Parallel()
+
+	consul, err := testutil.NewTestServerConfigT(t, func(c *testutil.TestServerConfig) {
+		c.Encrypt = oldGossipKey
+	})
+	require.NoError(t, err)
+	defer consul.Stop()
+	consul.WaitForLeader(t)
+	consulClient, err := capi.NewClient(&capi.Config{Address: consul.HTTPAddr})
+	require.NoError(t, err)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-gossip-encryption-key", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte(newGossipKey + "\n")},
+	}
+	r := &GossipKeyringController{
+		Client:       fake.NewClientBuilder().WithRuntimeObjects(secret).Build(),
+		ConsulClient: consulClient,
+		Log:          logrtest.TestLogger{T: t},
+		Secret:       types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace},
+		SecretKey:    "key",
+	}
+
+	// Install, use and remove each take a sync.
+	for i := 0; i < 3; i++ {
+		require.NoError(t, r.Sync(context.Background()))
+	}
+
+	rings, err := consulClient.Operator().KeyringList(nil)
+	require.NoError(t, err)
+	for _, ring := range rings {
+		require.Equal(t, map[string]int{newGossipKey: ring.NumNodes}, ring.Keys)
+	}
+}
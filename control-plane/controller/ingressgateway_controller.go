@@ -36,5 +36,5 @@ func (r *IngressGatewayController) UpdateStatus(ctx context.Context, obj client.
 }
 
 func (r *IngressGatewayController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.IngressGateway{}, r)
+	return r.ConfigEntryController.setupWithManager(mgr, &consulv1alpha1.IngressGateway{}, r)
 }
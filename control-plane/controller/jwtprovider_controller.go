@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	consulv1alpha1 "github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+)
+
+const (
+	// JWKSUnreachable is the reason of the JWKSReachable condition of providers
+	// whose remote JSON Web Key Set can't be fetched.
+	JWKSUnreachable = "JWKSUnreachable"
+
+	// jwksCheckInterval is how often the remote JSON Web Key Sets are fetched again.
+	jwksCheckInterval = 5 * time.Minute
+	// defaultJWKSRequestTimeout is the timeout of fetching a JSON Web Key Set if the
+	// provider doesn't set one.
+	defaultJWKSRequestTimeout = 5 * time.Second
+	// maxJWKSSize is the size of the largest JSON Web Key Set that is read.
+	maxJWKSSize = 1 << 20
+)
+
+// JWTProviderController reconciles a JWTProvider object.
+type JWTProviderController struct {
+	client.Client
+	Log                   logr.Logger
+	Scheme                *runtime.Scheme
+	ConfigEntryController *ConfigEntryController
+
+	// HTTPClient fetches the remote JSON Web Key Sets of the providers to report
+	// whether they are reachable. If it is nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// +kubebuilder:rbac:groups=consul.hashicorp.com,resources=jwtproviders,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=consul.hashicorp.com,resources=jwtproviders/status,verbs=get;update;patch
+
+func (r *JWTProviderController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	result, err := r.ConfigEntryController.ReconcileEntry(ctx, r, req, &consulv1alpha1.JWTProvider{})
+	if err != nil || !result.IsZero() {
+		return result, err
+	}
+	return r.checkJWKS(ctx, req.NamespacedName)
+}
+
+func (r *JWTProviderController) Logger(name types.NamespacedName) logr.Logger {
+	return r.Log.WithValues("request", name)
+}
+
+func (r *JWTProviderController) UpdateStatus(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return r.Status().Update(ctx, obj, opts...)
+}
+
+func (r *JWTProviderController) SetupWithManager(mgr ctrl.Manager) error {
+	return r.ConfigEntryController.setupWithManager(mgr, &consulv1alpha1.JWTProvider{}, r)
+}
+
+// checkJWKS fetches the remote JSON Web Key Set of the provider and records whether it
+// could be fetched in the JWKSReachable condition, which is removed if the key set is
+// local. The key set is fetched from the controller, so the result doesn't account for
+// the network of the proxies or a JWKSCluster. It is fetched again periodically.
+func (r *JWTProviderController) checkJWKS(ctx context.Context, name types.NamespacedName) (ctrl.Result, error) {
+	var provider consulv1alpha1.JWTProvider
+	if err := r.Get(ctx, name, &provider); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !provider.GetDeletionTimestamp().IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	var result ctrl.Result
+	var status corev1.ConditionStatus
+	var reason, message string
+	if jwks := provider.Spec.JSONWebKeySet; jwks != nil && jwks.Remote != nil {
+		status = corev1.ConditionTrue
+		if err := r.fetchJWKS(ctx, jwks.Remote); err != nil {
+			status, reason, message = corev1.ConditionFalse, JWKSUnreachable, err.Error()
+		}
+		result.RequeueAfter = wait.Jitter(jwksCheckInterval, retryJitter)
+	}
+
+	cond := provider.Status.GetCondition(consulv1alpha1.ConditionJWKSReachable)
+	if cond == nil && status == "" || cond != nil && cond.Status == status && cond.Reason == reason && cond.Message == message {
+		return result, nil
+	}
+	if status == corev1.ConditionFalse {
+		r.Logger(name).Info("JSON Web Key Set is unreachable", "uri", provider.Spec.JSONWebKeySet.Remote.URI, "err", message)
+	}
+	provider.SetJWKSReachableCondition(status, reason, message)
+	return result, r.UpdateStatus(ctx, &provider)
+}
+
+// fetchJWKS returns an error if the remote JSON Web Key Set can't be fetched or
+// has no keys.
+func (r *JWTProviderController) fetchJWKS(ctx context.Context, remote *consulv1alpha1.RemoteJWKS) error {
+	timeout := defaultJWKSRequestTimeout
+	if remote.RequestTimeoutMs > 0 {
+		timeout = time.Duration(remote.RequestTimeoutMs) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, remote.URI, nil)
+	if err != nil {
+		return err
+	}
+	httpClient := r.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %q: unexpected status %s", remote.URI, resp.Status)
+	}
+
+	var keySet struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSSize)).Decode(&keySet); err != nil {
+		return fmt.Errorf("GET %q: invalid JSON Web Key Set: %s", remote.URI, err)
+	}
+	if len(keySet.Keys) == 0 {
+		return fmt.Errorf("GET %q: the JSON Web Key Set has no keys", remote.URI)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestJWTProviderController_CheckJWKS(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/jwks":
+			w.Write([]byte(`{"keys":[{"kty":"oct","k":"c2VjcmV0"}]}`))
+		case "/empty":
+			w.Write([]byte(`{"keys":[]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	cases := map[string]struct {
+		jwks            *v1alpha1.JSONWebKeySet
+		conditions      v1alpha1.Conditions
+		expectedStatus  corev1.ConditionStatus
+		expectedReason  string
+		expectedRequeue bool
+	}{
+		"reachable": {
+			jwks:            &v1alpha1.JSONWebKeySet{Remote: &v1alpha1.RemoteJWKS{URI: srv.URL + "/jwks"}},
+			expectedStatus:  corev1.ConditionTrue,
+			expectedRequeue: true,
+		},
+		"not found": {
+			jwks:            &v1alpha1.JSONWebKeySet{Remote: &v1alpha1.RemoteJWKS{URI: srv.URL + "/missing"}},
+			expectedStatus:  corev1.ConditionFalse,
+			expectedReason:  JWKSUnreachable,
+			expectedRequeue: true,
+		},
+		"no keys": {
+			jwks:            &v1alpha1.JSONWebKeySet{Remote: &v1alpha1.RemoteJWKS{URI: srv.URL + "/empty"}},
+			expectedStatus:  corev1.ConditionFalse,
+			expectedReason:  JWKSUnreachable,
+			expectedRequeue: true,
+		},
+		"local key set removes the condition": {
+			jwks: &v1alpha1.JSONWebKeySet{Local: &v1alpha1.LocalJWKS{Filename: "/etc/jwks.json"}},
+			conditions: v1alpha1.Conditions{
+				{Type: v1alpha1.ConditionSynced, Status: corev1.ConditionTrue},
+				{Type: v1alpha1.ConditionJWKSReachable, Status: corev1.ConditionFalse, Reason: JWKSUnreachable},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			provider := &v1alpha1.JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "okta", Namespace: "default"},
+				Spec:       v1alpha1.JWTProviderSpec{JSONWebKeySet: c.jwks},
+				Status:     v1alpha1.Status{Conditions: c.conditions},
+			}
+			r := &JWTProviderController{
+				Client:     fake.NewClientBuilder().WithScheme(externalServicesScheme()).WithRuntimeObjects(provider).Build(),
+				Log:        logrtest.TestLogger{T: t},
+				HTTPClient: srv.Client(),
+			}
+			name := types.NamespacedName{Name: "okta", Namespace: "default"}
+
+			result, err := r.checkJWKS(ctx, name)
+			require.NoError(t, err)
+			require.Equal(t, c.expectedRequeue, result.RequeueAfter > 0)
+
+			var updated v1alpha1.JWTProvider
+			require.NoError(t, r.Get(ctx, name, &updated))
+			cond := updated.GetCondition(v1alpha1.ConditionJWKSReachable)
+			if c.expectedStatus == "" {
+				require.Nil(t, cond)
+			} else {
+				require.NotNil(t, cond)
+				require.Equal(t, c.expectedStatus, cond.Status)
+				require.Equal(t, c.expectedReason, cond.Reason)
+			}
+			// The Synced condition is left alone.
+			require.Equal(t, provider.GetCondition(v1alpha1.ConditionSynced), updated.GetCondition(v1alpha1.ConditionSynced))
+		})
+	}
+}
+
+func TestJWTProviderController_CheckJWKSNotFound(t *testing.T) {
+	t.Parallel()
+
+	r := &JWTProviderController{
+		Client: fake.NewClientBuilder().WithScheme(externalServicesScheme()).Build(),
+		Log:    logrtest.TestLogger{T: t},
+	}
+	result, err := r.checkJWKS(context.Background(), types.NamespacedName{Name: "okta", Namespace: "default"})
+	require.NoError(t, err)
+	require.True(t, result.IsZero())
+}
@@ -36,5 +36,5 @@ func (r *ProxyDefaultsController) UpdateStatus(ctx context.Context, obj client.O
 }
 
 func (r *ProxyDefaultsController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.ProxyDefaults{}, r)
+	return r.ConfigEntryController.setupWithManager(mgr, &consulv1alpha1.ProxyDefaults{}, r)
 }
@@ -36,5 +36,5 @@ func (r *ServiceDefaultsController) UpdateStatus(ctx context.Context, obj client
 }
 
 func (r *ServiceDefaultsController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.ServiceDefaults{}, r)
+	return r.ConfigEntryController.setupWithManager(mgr, &consulv1alpha1.ServiceDefaults{}, r)
 }
@@ -0,0 +1,341 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// InvalidSnapshotScheduleError is the reason of the Synced condition of
+	// a SnapshotSchedule with an invalid spec.
+	InvalidSnapshotScheduleError = "InvalidSnapshotScheduleError"
+	// SnapshotPodTemplateError is the reason of the Synced condition of a
+	// SnapshotSchedule whose snapshot agent pod template can't be used.
+	SnapshotPodTemplateError = "SnapshotPodTemplateError"
+	// SnapshotCronJobError is the reason of the Synced condition of a
+	// SnapshotSchedule whose CronJob can't be created or updated.
+	SnapshotCronJobError = "SnapshotCronJobError"
+
+	// SnapshotsFailingReason is the reason of the warning events recorded
+	// for a SnapshotSchedule once its snapshots fail repeatedly.
+	SnapshotsFailingReason = "SnapshotsFailing"
+
+	// snapshotScheduleLabel is the label of the jobs of a SnapshotSchedule.
+	// Its value is the name of the schedule.
+	snapshotScheduleLabel = "consul.hashicorp.com/snapshot-schedule"
+	// snapshotAgentContainerName is the name of the container of the pod
+	// template that runs the snapshot agent.
+	snapshotAgentContainerName = "consul-snapshot-agent"
+	// snapshotLocalPath is where the volume of a local destination is mounted.
+	snapshotLocalPath = "/consul/snapshots"
+)
+
+// SnapshotScheduleController takes the snapshots described by
+// SnapshotSchedule resources with a CronJob per schedule.
+//
+// Each job runs the snapshot agent with an interval of 0, which takes a single
+// snapshot, deletes the snapshots beyond the ones to retain and exits, so
+// that the result of a job is the result of a snapshot. The controller
+// records the results in the status of the schedule, and records a warning
+// event for each failure once too many snapshots failed in a row.
+//
+// The pods of the jobs are created from a PodTemplate, rendered by the Helm
+// chart with the configuration the snapshot agent needs to reach the Consul
+// servers, such as the TLS CA and the ACL login.
+type SnapshotScheduleController struct {
+	client.Client
+	Log      logr.Logger
+	Recorder record.EventRecorder
+
+	// PodTemplateName is the name of the PodTemplate of the snapshot agent
+	// pods. It is looked up in the namespace of each schedule.
+	PodTemplateName string
+}
+
+// +kubebuilder:rbac:groups=consul.hashicorp.com,resources=snapshotschedules,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=consul.hashicorp.com,resources=snapshotschedules/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=podtemplates,verbs=get
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+func (r *SnapshotScheduleController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := r.Log.WithValues("request", req.NamespacedName)
+
+	// The CronJob is owned by the schedule, so it is deleted along with its
+	// jobs by the garbage collector when the schedule is deleted.
+	var schedule v1alpha1.SnapshotSchedule
+	if err := r.Get(ctx, req.NamespacedName, &schedule); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	status := schedule.Status.DeepCopy()
+
+	// An invalid schedule isn't requeued since it can only be fixed by
+	// updating it.
+	if err := schedule.Validate(); err != nil {
+		logger.Error(err, "invalid snapshot schedule")
+		schedule.SetSyncedCondition(corev1.ConditionFalse, InvalidSnapshotScheduleError, err.Error())
+		return ctrl.Result{}, r.Status().Update(ctx, &schedule)
+	}
+
+	var podTemplate corev1.PodTemplate
+	err := r.Get(ctx, types.NamespacedName{Namespace: schedule.Namespace, Name: r.PodTemplateName}, &podTemplate)
+	if err != nil {
+		return r.syncFailed(ctx, logger, &schedule, SnapshotPodTemplateError,
+			fmt.Errorf("getting snapshot agent pod template %q: %w", r.PodTemplateName, err))
+	}
+
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      snapshotCronJobName(&schedule),
+			Namespace: schedule.Namespace,
+		},
+	}
+	_, err = controllerutil.CreateOrUpdate(ctx, r.Client, cronJob, func() error {
+		if err := setSnapshotCronJobSpec(cronJob, &schedule, &podTemplate); err != nil {
+			return err
+		}
+		return controllerutil.SetControllerReference(&schedule, cronJob, r.Scheme())
+	})
+	if err != nil {
+		return r.syncFailed(ctx, logger, &schedule, SnapshotCronJobError,
+			fmt.Errorf("creating or updating cron job %q: %w", cronJob.Name, err))
+	}
+
+	var jobs batchv1.JobList
+	err = r.List(ctx, &jobs, client.InNamespace(schedule.Namespace), client.MatchingLabels{snapshotScheduleLabel: schedule.Name})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	r.recordResults(&schedule, jobs.Items)
+
+	if schedule.SyncedConditionStatus() != corev1.ConditionTrue {
+		logger.Info("snapshots scheduled", "cronjob", cronJob.Name)
+		schedule.SetSyncedCondition(corev1.ConditionTrue, "", "")
+	}
+	if equality.Semantic.DeepEqual(status, &schedule.Status) {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{}, r.Status().Update(ctx, &schedule)
+}
+
+// recordResults records the results of the jobs that finished since the
+// last recorded result in the status of the schedule.
+func (r *SnapshotScheduleController) recordResults(schedule *v1alpha1.SnapshotSchedule, jobs []batchv1.Job) {
+	var results []snapshotResult
+	for _, job := range jobs {
+		if result, ok := snapshotJobResult(&job); ok {
+			results = append(results, result)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].finished.Before(&results[j].finished)
+	})
+
+	for _, result := range results {
+		if last := lastSnapshotTime(schedule); last != nil && !result.finished.After(last.Time) {
+			continue
+		}
+		finished := result.finished
+		if !result.failed {
+			schedule.Status.LastSuccessTime = &finished
+			schedule.Status.ConsecutiveFailures = 0
+			continue
+		}
+
+		schedule.Status.LastFailureTime = &finished
+		schedule.Status.LastFailureMessage = result.message
+		schedule.Status.ConsecutiveFailures++
+		if schedule.Status.ConsecutiveFailures >= schedule.FailureThresholdOrDefault() {
+			r.Recorder.Eventf(schedule, corev1.EventTypeWarning, SnapshotsFailingReason,
+				"%d snapshots failed in a row, the last one with: %s", schedule.Status.ConsecutiveFailures, result.message)
+		}
+	}
+}
+
+func (r *SnapshotScheduleController) syncFailed(ctx context.Context, logger logr.Logger, schedule *v1alpha1.SnapshotSchedule, errType string, err error) (ctrl.Result, error) {
+	schedule.SetSyncedCondition(corev1.ConditionFalse, errType, err.Error())
+	if updateErr := r.Status().Update(ctx, schedule); updateErr != nil {
+		// Log the original error here because we are returning the updateErr.
+		// Otherwise the original error would be lost.
+		logger.Error(err, "sync failed")
+		return ctrl.Result{}, updateErr
+	}
+	return ctrl.Result{}, err
+}
+
+func (r *SnapshotScheduleController) SetupWithManager(mgr ctrl.Manager) error {
+	// Status updates don't change the generation, so they don't trigger
+	// reconciles. The schedules are reconciled when their jobs change
+	// instead.
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.SnapshotSchedule{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&batchv1.CronJob{}).
+		Watches(
+			&source.Kind{Type: &batchv1.Job{}},
+			handler.EnqueueRequestsFromMapFunc(requestForSnapshotJob),
+		).
+		Complete(r)
+}
+
+// requestForSnapshotJob returns a request for the schedule of a job, if the
+// job was created for one.
+func requestForSnapshotJob(object client.Object) []reconcile.Request {
+	name, ok := object.GetLabels()[snapshotScheduleLabel]
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: object.GetNamespace(), Name: name}}}
+}
+
+// setSnapshotCronJobSpec sets the spec of the CronJob of the schedule. The
+// pods of its jobs run the snapshot agent of the pod template with the
+// interval, retention and destination of the schedule.
+func setSnapshotCronJobSpec(cronJob *batchv1.CronJob, schedule *v1alpha1.SnapshotSchedule, podTemplate *corev1.PodTemplate) error {
+	pod := podTemplate.Template.DeepCopy()
+	var agent *corev1.Container
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == snapshotAgentContainerName {
+			agent = &pod.Spec.Containers[i]
+		}
+	}
+	if agent == nil {
+		return fmt.Errorf("pod template %q has no %q container", podTemplate.Name, snapshotAgentContainerName)
+	}
+
+	// The container runs the snapshot agent in a shell script that passes it
+	// its arguments, the first of which is the name of the script.
+	agent.Args = append([]string{snapshotAgentContainerName}, snapshotAgentFlags(schedule)...)
+	dest := schedule.Spec.Destination
+	if dest.S3 != nil && dest.S3.CredentialsSecret != "" {
+		agent.EnvFrom = append(agent.EnvFrom, corev1.EnvFromSource{
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: dest.S3.CredentialsSecret},
+			},
+		})
+	}
+	if dest.Local != nil {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name: "snapshots",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: dest.Local.ClaimName},
+			},
+		})
+		agent.VolumeMounts = append(agent.VolumeMounts, corev1.VolumeMount{
+			Name:      "snapshots",
+			MountPath: snapshotLocalPath,
+		})
+	}
+	pod.Spec.RestartPolicy = corev1.RestartPolicyNever
+	if pod.Labels == nil {
+		pod.Labels = make(map[string]string)
+	}
+	pod.Labels[snapshotScheduleLabel] = schedule.Name
+
+	// A failed snapshot isn't retried since the next one is only an interval
+	// away, and a retry would hide the failure.
+	backoffLimit := int32(0)
+	historyLimit := int32(3)
+	cronJob.Spec = batchv1.CronJobSpec{
+		Schedule:                   fmt.Sprintf("@every %s", schedule.Spec.Interval.Duration),
+		ConcurrencyPolicy:          batchv1.ForbidConcurrent,
+		SuccessfulJobsHistoryLimit: &historyLimit,
+		FailedJobsHistoryLimit:     &historyLimit,
+		JobTemplate: batchv1.JobTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{snapshotScheduleLabel: schedule.Name},
+			},
+			Spec: batchv1.JobSpec{
+				BackoffLimit: &backoffLimit,
+				Template:     *pod,
+			},
+		},
+	}
+	return nil
+}
+
+// snapshotAgentFlags returns the flags of the snapshot agent for the
+// schedule.
+func snapshotAgentFlags(schedule *v1alpha1.SnapshotSchedule) []string {
+	flags := []string{
+		"-interval=0",
+		fmt.Sprintf("-retain=%d", schedule.RetainOrDefault()),
+	}
+	dest := schedule.Spec.Destination
+	switch {
+	case dest.Local != nil:
+		flags = append(flags, "-local-path="+snapshotLocalPath)
+	case dest.S3 != nil:
+		flags = append(flags,
+			"-aws-s3-bucket="+dest.S3.Bucket,
+			"-aws-s3-region="+dest.S3.Region)
+		if dest.S3.KeyPrefix != "" {
+			flags = append(flags, "-aws-s3-key-prefix="+dest.S3.KeyPrefix)
+		}
+		if dest.S3.Endpoint != "" {
+			flags = append(flags, "-aws-s3-endpoint="+dest.S3.Endpoint)
+		}
+	}
+	return flags
+}
+
+// snapshotResult is the result of a finished snapshot job.
+type snapshotResult struct {
+	finished metav1.Time
+	failed   bool
+	message  string
+}
+
+// snapshotJobResult returns the result of the job, and false if it hasn't
+// finished.
+func snapshotJobResult(job *batchv1.Job) (snapshotResult, bool) {
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			return snapshotResult{finished: cond.LastTransitionTime}, true
+		case batchv1.JobFailed:
+			return snapshotResult{
+				finished: cond.LastTransitionTime,
+				failed:   true,
+				message:  fmt.Sprintf("job %s failed: %s", job.Name, cond.Message),
+			}, true
+		}
+	}
+	return snapshotResult{}, false
+}
+
+// lastSnapshotTime returns when the last recorded snapshot finished, or nil
+// if none is recorded.
+func lastSnapshotTime(schedule *v1alpha1.SnapshotSchedule) *metav1.Time {
+	success, failure := schedule.Status.LastSuccessTime, schedule.Status.LastFailureTime
+	if success == nil || (failure != nil && failure.After(success.Time)) {
+		return failure
+	}
+	return success
+}
+
+// snapshotCronJobName returns the name of the CronJob of the schedule.
+func snapshotCronJobName(schedule *v1alpha1.SnapshotSchedule) string {
+	return fmt.Sprintf("%s-snapshot", schedule.Name)
+}
@@ -0,0 +1,285 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newSnapshotScheduleController(t *testing.T, objs ...runtime.Object) (*SnapshotScheduleController, *record.FakeRecorder) {
+	recorder := record.NewFakeRecorder(10)
+	return &SnapshotScheduleController{
+		Client:          fake.NewClientBuilder().WithScheme(externalServicesScheme()).WithRuntimeObjects(objs...).Build(),
+		Log:             logrtest.TestLogger{T: t},
+		Recorder:        recorder,
+		PodTemplateName: "consul-snapshot-agent",
+	}, recorder
+}
+
+func snapshotPodTemplate() *corev1.PodTemplate {
+	return &corev1.PodTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-snapshot-agent", Namespace: "consul"},
+		Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"component": "client-snapshot-agent"}},
+			Spec: corev1.PodSpec{
+				ServiceAccountName: "consul-snapshot-agent",
+				Containers: []corev1.Container{
+					{
+						Name:    "consul-snapshot-agent",
+						Image:   "hashicorp/consul-enterprise:1.11.4-ent",
+						Command: []string{"/bin/sh", "-ec", `exec /bin/consul snapshot agent "$@"`},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestSnapshotScheduleController_CronJob(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	cases := map[string]struct {
+		destination     v1alpha1.SnapshotDestination
+		expectedArgs    []string
+		expectedEnvFrom []corev1.EnvFromSource
+		expectedVolume  *corev1.Volume
+	}{
+		"s3": {
+			destination: v1alpha1.SnapshotDestination{
+				S3: &v1alpha1.S3SnapshotDestination{
+					Bucket:            "consul-snapshots",
+					Region:            "us-east-1",
+					KeyPrefix:         "dc1",
+					CredentialsSecret: "aws-credentials",
+				},
+			},
+			expectedArgs: []string{
+				"consul-snapshot-agent",
+				"-interval=0",
+				"-retain=10",
+				"-aws-s3-bucket=consul-snapshots",
+				"-aws-s3-region=us-east-1",
+				"-aws-s3-key-prefix=dc1",
+			},
+			expectedEnvFrom: []corev1.EnvFromSource{
+				{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "aws-credentials"}}},
+			},
+		},
+		"local": {
+			destination: v1alpha1.SnapshotDestination{
+				Local: &v1alpha1.LocalSnapshotDestination{ClaimName: "snapshots"},
+			},
+			expectedArgs: []string{
+				"consul-snapshot-agent",
+				"-interval=0",
+				"-retain=10",
+				"-local-path=/consul/snapshots",
+			},
+			expectedVolume: &corev1.Volume{
+				Name: "snapshots",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "snapshots"},
+				},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			schedule := &v1alpha1.SnapshotSchedule{
+				ObjectMeta: metav1.ObjectMeta{Name: "hourly", Namespace: "consul"},
+				Spec: v1alpha1.SnapshotScheduleSpec{
+					Interval:    metav1.Duration{Duration: time.Hour},
+					Retain:      10,
+					Destination: c.destination,
+				},
+			}
+			r, _ := newSnapshotScheduleController(t, schedule, snapshotPodTemplate())
+
+			name := types.NamespacedName{Name: "hourly", Namespace: "consul"}
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: name})
+			require.NoError(t, err)
+
+			var cronJob batchv1.CronJob
+			require.NoError(t, r.Get(ctx, types.NamespacedName{Name: "hourly-snapshot", Namespace: "consul"}, &cronJob))
+			require.Equal(t, "@every 1h0m0s", cronJob.Spec.Schedule)
+			require.Equal(t, batchv1.ForbidConcurrent, cronJob.Spec.ConcurrencyPolicy)
+			require.Equal(t, "hourly", cronJob.OwnerReferences[0].Name)
+			require.Equal(t, "hourly", cronJob.Spec.JobTemplate.Labels[snapshotScheduleLabel])
+			require.Equal(t, int32(0), *cronJob.Spec.JobTemplate.Spec.BackoffLimit)
+
+			pod := cronJob.Spec.JobTemplate.Spec.Template
+			require.Equal(t, corev1.RestartPolicyNever, pod.Spec.RestartPolicy)
+			require.Equal(t, "consul-snapshot-agent", pod.Spec.ServiceAccountName)
+			require.Equal(t, map[string]string{"component": "client-snapshot-agent", snapshotScheduleLabel: "hourly"}, pod.Labels)
+			agent := pod.Spec.Containers[0]
+			require.Equal(t, c.expectedArgs, agent.Args)
+			require.Equal(t, c.expectedEnvFrom, agent.EnvFrom)
+			if c.expectedVolume != nil {
+				require.Equal(t, []corev1.Volume{*c.expectedVolume}, pod.Spec.Volumes)
+				require.Equal(t, []corev1.VolumeMount{{Name: "snapshots", MountPath: "/consul/snapshots"}}, agent.VolumeMounts)
+			} else {
+				require.Empty(t, pod.Spec.Volumes)
+			}
+
+			require.NoError(t, r.Get(ctx, name, schedule))
+			require.Equal(t, corev1.ConditionTrue, schedule.SyncedConditionStatus())
+		})
+	}
+}
+
+func TestSnapshotScheduleController_RecordsResults(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	schedule := &v1alpha1.SnapshotSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "hourly", Namespace: "consul"},
+		Spec: v1alpha1.SnapshotScheduleSpec{
+			Interval:         metav1.Duration{Duration: time.Hour},
+			Destination:      v1alpha1.SnapshotDestination{S3: &v1alpha1.S3SnapshotDestination{Bucket: "b", Region: "r"}},
+			FailureThreshold: 2,
+		},
+	}
+	start := time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
+	r, recorder := newSnapshotScheduleController(t, schedule, snapshotPodTemplate(),
+		snapshotJob("hourly-1", "hourly", batchv1.JobComplete, start),
+		snapshotJob("hourly-2", "hourly", batchv1.JobFailed, start.Add(time.Hour)),
+		// Jobs of other schedules and jobs that are running are ignored.
+		snapshotJob("daily-1", "daily", batchv1.JobComplete, start.Add(2*time.Hour)),
+		snapshotJob("hourly-3", "hourly", "", time.Time{}),
+	)
+
+	name := types.NamespacedName{Name: "hourly", Namespace: "consul"}
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: name})
+	require.NoError(t, err)
+	require.NoError(t, r.Get(ctx, name, schedule))
+	require.Equal(t, start, schedule.Status.LastSuccessTime.UTC())
+	require.Equal(t, start.Add(time.Hour), schedule.Status.LastFailureTime.UTC())
+	require.Equal(t, "job hourly-2 failed: Job has reached the specified backoff limit", schedule.Status.LastFailureMessage)
+	require.Equal(t, 1, schedule.Status.ConsecutiveFailures)
+	require.Empty(t, recorder.Events)
+
+	// Results are only recorded once, and a warning event is recorded for
+	// each failure once the threshold is reached.
+	require.NoError(t, r.Create(ctx, snapshotJob("hourly-4", "hourly", batchv1.JobFailed, start.Add(3*time.Hour))))
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: name})
+	require.NoError(t, err)
+	require.NoError(t, r.Get(ctx, name, schedule))
+	require.Equal(t, 2, schedule.Status.ConsecutiveFailures)
+	require.Equal(t, start.Add(3*time.Hour), schedule.Status.LastFailureTime.UTC())
+	require.Len(t, recorder.Events, 1)
+	require.Equal(t, "Warning SnapshotsFailing 2 snapshots failed in a row, the last one with: job hourly-4 failed: Job has reached the specified backoff limit", <-recorder.Events)
+
+	// A success resets the failure count.
+	require.NoError(t, r.Create(ctx, snapshotJob("hourly-5", "hourly", batchv1.JobComplete, start.Add(4*time.Hour))))
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: name})
+	require.NoError(t, err)
+	require.NoError(t, r.Get(ctx, name, schedule))
+	require.Equal(t, 0, schedule.Status.ConsecutiveFailures)
+	require.Equal(t, start.Add(4*time.Hour), schedule.Status.LastSuccessTime.UTC())
+	require.Empty(t, recorder.Events)
+}
+
+func TestSnapshotScheduleController_Errors(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	cases := map[string]struct {
+		spec           v1alpha1.SnapshotScheduleSpec
+		podTemplate    *corev1.PodTemplate
+		expectedReason string
+		expectedErr    string
+	}{
+		"invalid schedule": {
+			spec:           v1alpha1.SnapshotScheduleSpec{Interval: metav1.Duration{Duration: time.Hour}},
+			podTemplate:    snapshotPodTemplate(),
+			expectedReason: InvalidSnapshotScheduleError,
+		},
+		"missing pod template": {
+			spec: v1alpha1.SnapshotScheduleSpec{
+				Interval:    metav1.Duration{Duration: time.Hour},
+				Destination: v1alpha1.SnapshotDestination{Local: &v1alpha1.LocalSnapshotDestination{ClaimName: "snapshots"}},
+			},
+			expectedReason: SnapshotPodTemplateError,
+			expectedErr:    `getting snapshot agent pod template "consul-snapshot-agent": podtemplates "consul-snapshot-agent" not found`,
+		},
+		"pod template without snapshot agent": {
+			spec: v1alpha1.SnapshotScheduleSpec{
+				Interval:    metav1.Duration{Duration: time.Hour},
+				Destination: v1alpha1.SnapshotDestination{Local: &v1alpha1.LocalSnapshotDestination{ClaimName: "snapshots"}},
+			},
+			podTemplate: func() *corev1.PodTemplate {
+				podTemplate := snapshotPodTemplate()
+				podTemplate.Template.Spec.Containers[0].Name = "consul"
+				return podTemplate
+			}(),
+			expectedReason: SnapshotCronJobError,
+			expectedErr:    `creating or updating cron job "hourly-snapshot": pod template "consul-snapshot-agent" has no "consul-snapshot-agent" container`,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			schedule := &v1alpha1.SnapshotSchedule{
+				ObjectMeta: metav1.ObjectMeta{Name: "hourly", Namespace: "consul"},
+				Spec:       c.spec,
+			}
+			objs := []runtime.Object{schedule}
+			if c.podTemplate != nil {
+				objs = append(objs, c.podTemplate)
+			}
+			r, _ := newSnapshotScheduleController(t, objs...)
+
+			name := types.NamespacedName{Name: "hourly", Namespace: "consul"}
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: name})
+			if c.expectedErr != "" {
+				require.EqualError(t, err, c.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.NoError(t, r.Get(ctx, name, schedule))
+			require.Equal(t, corev1.ConditionFalse, schedule.SyncedConditionStatus())
+			require.Equal(t, c.expectedReason, schedule.Status.Conditions[0].Reason)
+		})
+	}
+}
+
+// snapshotJob returns a job of the schedule that finished with the given
+// condition, or that is running if the condition is empty.
+func snapshotJob(name, schedule string, condition batchv1.JobConditionType, finished time.Time) *batchv1.Job {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "consul",
+			Labels:    map[string]string{snapshotScheduleLabel: schedule},
+		},
+	}
+	if condition != "" {
+		cond := batchv1.JobCondition{
+			Type:               condition,
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(finished),
+		}
+		if condition == batchv1.JobFailed {
+			cond.Reason = "BackoffLimitExceeded"
+			cond.Message = "Job has reached the specified backoff limit"
+		}
+		job.Status.Conditions = []batchv1.JobCondition{cond}
+	}
+	return job
+}
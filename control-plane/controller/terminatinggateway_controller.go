@@ -2,8 +2,13 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
 
 	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -12,18 +17,46 @@ import (
 	consulv1alpha1 "github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
 )
 
+const (
+	// terminatingGatewayServiceLabel is the label of terminating gateway
+	// deployments with the name of the gateway service in Consul, which is
+	// also the name of its TerminatingGateway resource.
+	terminatingGatewayServiceLabel = "terminating-gateway-service-name"
+	// terminatingGatewayContainer is the name of the Envoy container of
+	// terminating gateway deployments.
+	terminatingGatewayContainer = "terminating-gateway"
+	// tlsVolumePrefix prefixes the names of the volumes of the Secrets
+	// referenced by the tls field of linked services.
+	tlsVolumePrefix = "tls-origination-"
+)
+
 // TerminatingGatewayController is the controller for TerminatingGateway resources.
 type TerminatingGatewayController struct {
 	client.Client
 	Log                   logr.Logger
 	Scheme                *runtime.Scheme
 	ConfigEntryController *ConfigEntryController
+
+	// GatewayNamespace is the namespace of the terminating gateway deployments
+	// that the Secrets referenced by the tls field of linked services are
+	// mounted into. If it is empty, the Secrets are not mounted. Only the
+	// TerminatingGateway resources in it have their Secrets mounted.
+	GatewayNamespace string
 }
 
 // +kubebuilder:rbac:groups=consul.hashicorp.com,resources=terminatinggateways,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=consul.hashicorp.com,resources=terminatinggateways/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;update
 
 func (r *TerminatingGatewayController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// The Secrets are mounted before the config entry is written so that the
+	// gateway starts rolling out before it is told to use the new files.
+	if r.GatewayNamespace != "" {
+		if err := r.mountTLSSecrets(ctx, req.NamespacedName); err != nil {
+			r.Logger(req.NamespacedName).Error(err, "mounting TLS secrets into the terminating gateway deployment")
+			return ctrl.Result{}, err
+		}
+	}
 	return r.ConfigEntryController.ReconcileEntry(ctx, r, req, &consulv1alpha1.TerminatingGateway{})
 }
 
@@ -36,5 +69,122 @@ func (r *TerminatingGatewayController) UpdateStatus(ctx context.Context, obj cli
 }
 
 func (r *TerminatingGatewayController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.TerminatingGateway{}, r)
+	return r.ConfigEntryController.setupWithManager(mgr, &consulv1alpha1.TerminatingGateway{}, r)
+}
+
+// mountTLSSecrets mounts the Secrets referenced by the tls field of the linked
+// services of the gateway into its deployments, and unmounts the Secrets that
+// are no longer referenced. Updating the pod template rolls out the gateway
+// pods so that Envoy reads the new files. Gateways in other namespaces than
+// GatewayNamespace are ignored.
+func (r *TerminatingGatewayController) mountTLSSecrets(ctx context.Context, name types.NamespacedName) error {
+	// Only resources in the gateway namespace may reference its Secrets, or any
+	// namespace could mount them into the gateway. The webhook rejects the
+	// others, but they may predate it.
+	if name.Namespace != r.GatewayNamespace {
+		return nil
+	}
+
+	var secrets []string
+	var gateway consulv1alpha1.TerminatingGateway
+	err := r.Client.Get(ctx, name, &gateway)
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if err == nil && gateway.DeletionTimestamp.IsZero() {
+		secrets = gateway.TLSSecrets()
+	}
+
+	var deployments appsv1.DeploymentList
+	err = r.Client.List(ctx, &deployments, client.InNamespace(r.GatewayNamespace),
+		client.MatchingLabels{"component": "terminating-gateway", terminatingGatewayServiceLabel: name.Name})
+	if err != nil {
+		return fmt.Errorf("listing terminating gateway deployments: %w", err)
+	}
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		if !setTLSSecretVolumes(&deployment.Spec.Template.Spec, secrets) {
+			continue
+		}
+		r.Logger(name).Info("updating TLS secrets of terminating gateway deployment", "deployment", deployment.Name, "secrets", secrets)
+		if err := r.Client.Update(ctx, deployment); err != nil {
+			return fmt.Errorf("updating deployment %s: %w", deployment.Name, err)
+		}
+	}
+	return nil
+}
+
+// setTLSSecretVolumes makes the volumes of the pod and the volume mounts of
+// its gateway container match the Secrets. It returns whether it changed the
+// pod.
+func setTLSSecretVolumes(pod *corev1.PodSpec, secrets []string) bool {
+	var container *corev1.Container
+	for i := range pod.Containers {
+		if pod.Containers[i].Name == terminatingGatewayContainer {
+			container = &pod.Containers[i]
+		}
+	}
+	if container == nil {
+		return false
+	}
+
+	wanted := make(map[string]bool, len(secrets))
+	for _, secret := range secrets {
+		wanted[tlsVolumeName(secret)] = true
+	}
+
+	changed := false
+	var volumes []corev1.Volume
+	hasVolume := make(map[string]bool)
+	for _, volume := range pod.Volumes {
+		if !wanted[volume.Name] && strings.HasPrefix(volume.Name, tlsVolumePrefix) {
+			changed = true
+			continue
+		}
+		hasVolume[volume.Name] = true
+		volumes = append(volumes, volume)
+	}
+	var mounts []corev1.VolumeMount
+	hasMount := make(map[string]bool)
+	for _, mount := range container.VolumeMounts {
+		if !wanted[mount.Name] && strings.HasPrefix(mount.Name, tlsVolumePrefix) {
+			changed = true
+			continue
+		}
+		hasMount[mount.Name] = true
+		mounts = append(mounts, mount)
+	}
+
+	for _, secret := range secrets {
+		volumeName := tlsVolumeName(secret)
+		if !hasVolume[volumeName] {
+			changed = true
+			volumes = append(volumes, corev1.Volume{
+				Name: volumeName,
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{SecretName: secret},
+				},
+			})
+		}
+		if !hasMount[volumeName] {
+			changed = true
+			mounts = append(mounts, corev1.VolumeMount{
+				Name:      volumeName,
+				MountPath: consulv1alpha1.TerminatingGatewayTLSPath(secret, ""),
+				ReadOnly:  true,
+			})
+		}
+	}
+	pod.Volumes = volumes
+	container.VolumeMounts = mounts
+	return changed
+}
+
+// tlsVolumeName returns the name of the volume of the Secret. Secret names
+// may be longer than volume names and contain dots, so the volume is named
+// after a hash of the Secret name.
+func tlsVolumeName(secret string) string {
+	h := fnv.New32a()
+	h.Write([]byte(secret))
+	return fmt.Sprintf("%s%08x", tlsVolumePrefix, h.Sum32())
 }
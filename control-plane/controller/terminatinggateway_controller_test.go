@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func terminatingGatewayDeployment() *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "consul-terminating-gateway",
+			Namespace: "consul",
+			Labels:    map[string]string{"component": "terminating-gateway", terminatingGatewayServiceLabel: "terminating-gateway"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Volumes: []corev1.Volume{{Name: "consul-service"}},
+					Containers: []corev1.Container{{
+						Name:         "terminating-gateway",
+						VolumeMounts: []corev1.VolumeMount{{Name: "consul-service", MountPath: "/consul/service"}},
+					}},
+				},
+			},
+		},
+	}
+}
+
+func TestTerminatingGatewayController_MountTLSSecrets(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	gatewayName := types.NamespacedName{Name: "terminating-gateway", Namespace: "consul"}
+	gateway := &v1alpha1.TerminatingGateway{
+		ObjectMeta: metav1.ObjectMeta{Name: gatewayName.Name, Namespace: gatewayName.Namespace},
+		Spec: v1alpha1.TerminatingGatewaySpec{
+			Services: []v1alpha1.LinkedService{
+				{Name: "db", TLS: &v1alpha1.LinkedServiceTLS{CASecret: &v1alpha1.SecretKeyReference{Name: "db-ca"}, ClientCertSecret: "db-client"}},
+				{Name: "web"},
+			},
+		},
+	}
+
+	otherNamespaceGateway := gateway.DeepCopy()
+	otherNamespaceGateway.Namespace = "default"
+
+	cases := map[string]struct {
+		objects         []runtime.Object
+		name            types.NamespacedName
+		expectedSecrets []string
+	}{
+		"mounts the secrets": {
+			objects:         []runtime.Object{gateway, terminatingGatewayDeployment()},
+			expectedSecrets: []string{"db-ca", "db-client"},
+		},
+		"ignores gateways in other namespaces": {
+			objects: []runtime.Object{otherNamespaceGateway, terminatingGatewayDeployment()},
+			name:    types.NamespacedName{Name: gatewayName.Name, Namespace: "default"},
+		},
+		"unmounts the secrets when the gateway is deleted": {
+			objects: []runtime.Object{func() runtime.Object {
+				deployment := terminatingGatewayDeployment()
+				setTLSSecretVolumes(&deployment.Spec.Template.Spec, []string{"db-ca", "old"})
+				return deployment
+			}()},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &TerminatingGatewayController{
+				Client:           fake.NewClientBuilder().WithScheme(externalServicesScheme()).WithRuntimeObjects(c.objects...).Build(),
+				Log:              logrtest.TestLogger{T: t},
+				GatewayNamespace: "consul",
+			}
+			name := gatewayName
+			if c.name.Name != "" {
+				name = c.name
+			}
+			require.NoError(t, r.mountTLSSecrets(ctx, name))
+
+			var deployment appsv1.Deployment
+			require.NoError(t, r.Client.Get(ctx, types.NamespacedName{Name: "consul-terminating-gateway", Namespace: "consul"}, &deployment))
+			pod := deployment.Spec.Template.Spec
+			var secrets, mountPaths []string
+			for _, volume := range pod.Volumes {
+				if volume.Secret != nil {
+					secrets = append(secrets, volume.Secret.SecretName)
+				}
+			}
+			for _, mount := range pod.Containers[0].VolumeMounts {
+				if mount.Name != "consul-service" {
+					mountPaths = append(mountPaths, mount.MountPath)
+				}
+			}
+			require.Equal(t, c.expectedSecrets, secrets)
+			var expectedPaths []string
+			for _, secret := range c.expectedSecrets {
+				expectedPaths = append(expectedPaths, "/consul/terminating-gateway-tls/"+secret)
+			}
+			require.Equal(t, expectedPaths, mountPaths)
+			require.Equal(t, "consul-service", pod.Volumes[0].Name)
+		})
+	}
+}
+
+func TestSetTLSSecretVolumes(t *testing.T) {
+	pod := terminatingGatewayDeployment().Spec.Template.Spec
+	require.True(t, setTLSSecretVolumes(&pod, []string{"ca"}))
+	require.False(t, setTLSSecretVolumes(&pod, []string{"ca"}))
+	require.Len(t, pod.Volumes, 2)
+	require.Equal(t, corev1.VolumeMount{Name: tlsVolumeName("ca"), MountPath: "/consul/terminating-gateway-tls/ca", ReadOnly: true}, pod.Containers[0].VolumeMounts[1])
+
+	// Pods without a terminating gateway container are left alone.
+	pod = corev1.PodSpec{Containers: []corev1.Container{{Name: "other"}}}
+	require.False(t, setTLSSecretVolumes(&pod, []string{"ca"}))
+	require.Empty(t, pod.Volumes)
+}
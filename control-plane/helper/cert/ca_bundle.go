@@ -0,0 +1,110 @@
+package cert
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const defaultCABundleSyncInterval = 10 * time.Second
+
+// CABundleUpdater keeps the caBundle of every webhook on a
+// MutatingWebhookConfiguration in sync with a PEM-encoded CA certificate file.
+// It is used when the webhook certificates are issued by an external source,
+// such as Vault, instead of the webhook-cert-manager, which would otherwise
+// update the caBundle itself.
+type CABundleUpdater struct {
+	Clientset kubernetes.Interface
+	Log       logr.Logger
+
+	// WebhookConfigName is the name of the MutatingWebhookConfiguration to update.
+	WebhookConfigName string
+	// CAFile is the path to the CA certificate. It is re-read on every sync so
+	// that a rotated CA is picked up without a restart.
+	CAFile string
+	// SyncInterval is how often the caBundle is synced. Defaults to 10s.
+	SyncInterval time.Duration
+}
+
+// Start syncs the caBundle until the context is cancelled. It implements
+// manager.Runnable.
+func (u *CABundleUpdater) Start(ctx context.Context) error {
+	interval := u.SyncInterval
+	if interval == 0 {
+		interval = defaultCABundleSyncInterval
+	}
+	for {
+		if err := u.Sync(ctx); err != nil {
+			u.Log.Error(err, "failed to sync webhook caBundle", "mutatingwebhookconfig", u.WebhookConfigName)
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// NeedLeaderElection returns false so that every replica keeps the caBundle
+// in sync, since they all serve certificates issued by the same CA.
+func (u *CABundleUpdater) NeedLeaderElection() bool {
+	return false
+}
+
+// Sync patches the caBundle of the webhooks that don't match the CA file.
+func (u *CABundleUpdater) Sync(ctx context.Context) error {
+	caCert, err := os.ReadFile(u.CAFile)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(caCert)) == 0 {
+		return fmt.Errorf("CA certificate file %q is empty", u.CAFile)
+	}
+
+	webhookCfg, err := u.Clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, u.WebhookConfigName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if len(webhookCfg.Webhooks) == 0 {
+		return errors.New("no webhooks on the webhook configuration")
+	}
+
+	type patch struct {
+		Op    string `json:"op,omitempty"`
+		Path  string `json:"path,omitempty"`
+		Value string `json:"value,omitempty"`
+	}
+	var patches []patch
+	for i, webhook := range webhookCfg.Webhooks {
+		if bytes.Equal(webhook.ClientConfig.CABundle, caCert) {
+			continue
+		}
+		patches = append(patches, patch{
+			Op:    "add",
+			Path:  fmt.Sprintf("/webhooks/%d/clientConfig/caBundle", i),
+			Value: base64.StdEncoding.EncodeToString(caCert),
+		})
+	}
+	if len(patches) == 0 {
+		return nil
+	}
+	patchesJson, err := json.Marshal(patches)
+	if err != nil {
+		return err
+	}
+	_, err = u.Clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().Patch(ctx, u.WebhookConfigName, types.JSONPatchType, patchesJson, metav1.PatchOptions{})
+	if err == nil {
+		u.Log.Info("updated webhook caBundle", "mutatingwebhookconfig", u.WebhookConfigName)
+	}
+	return err
+}
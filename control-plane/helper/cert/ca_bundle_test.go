@@ -0,0 +1,59 @@
+package cert
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCABundleUpdater_Sync(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset(&admissionv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook-config"},
+		Webhooks: []admissionv1.MutatingWebhook{
+			{Name: "webhook-one"},
+			{Name: "webhook-two", ClientConfig: admissionv1.WebhookClientConfig{CABundle: []byte("old-ca")}},
+		},
+	})
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	updater := &CABundleUpdater{
+		Clientset:         clientset,
+		Log:               logr.Discard(),
+		WebhookConfigName: "webhook-config",
+		CAFile:            caFile,
+	}
+
+	// The CA file doesn't exist yet.
+	require.Error(t, updater.Sync(ctx))
+
+	require.NoError(t, os.WriteFile(caFile, []byte(""), 0600))
+	require.EqualError(t, updater.Sync(ctx), `CA certificate file "`+caFile+`" is empty`)
+
+	_, _, caCert, _, err := GenerateCA("Test CA")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(caFile, []byte(caCert), 0600))
+	require.NoError(t, updater.Sync(ctx))
+
+	webhookCfg, err := clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, "webhook-config", metav1.GetOptions{})
+	require.NoError(t, err)
+	for _, webhook := range webhookCfg.Webhooks {
+		require.Equal(t, caCert, string(webhook.ClientConfig.CABundle))
+	}
+
+	// Nothing is patched if the caBundle is up to date.
+	clientset.ClearActions()
+	require.NoError(t, updater.Sync(ctx))
+	for _, action := range clientset.Actions() {
+		require.Equal(t, "get", action.GetVerb())
+	}
+
+	updater.WebhookConfigName = "missing"
+	require.Error(t, updater.Sync(ctx))
+}
@@ -0,0 +1,40 @@
+package cert
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// Registry is the registry of the certificate metrics. It is separate
+	// from the default registry so that only these metrics are served by
+	// MetricsHandler.
+	Registry = prometheus.NewRegistry()
+
+	expiryTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "consul_k8s_certificate_expiry_timestamp_seconds",
+		Help: "The time the certificate stored in the secret expires, in seconds since the Unix epoch.",
+	}, []string{"secret_namespace", "secret_name"})
+)
+
+func init() {
+	Registry.MustRegister(expiryTimestamp)
+}
+
+// RecordExpiry records the expiry of the PEM-encoded certificate stored in
+// the secret.
+func RecordExpiry(secretNamespace, secretName string, certPEM []byte) error {
+	cert, err := ParseCert(certPEM)
+	if err != nil {
+		return err
+	}
+	expiryTimestamp.WithLabelValues(secretNamespace, secretName).Set(float64(cert.NotAfter.Unix()))
+	return nil
+}
+
+// MetricsHandler serves the certificate metrics in the Prometheus format.
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
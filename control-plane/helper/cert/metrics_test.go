@@ -0,0 +1,30 @@
+package cert
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordExpiry(t *testing.T) {
+	signer, _, _, caCert, err := GenerateCA("Test CA")
+	require.NoError(t, err)
+	certPEM, _, err := GenerateCert("test", time.Hour, caCert, signer, []string{"test"})
+	require.NoError(t, err)
+	cert, err := ParseCert([]byte(certPEM))
+	require.NoError(t, err)
+
+	require.NoError(t, RecordExpiry("default", "test-cert", []byte(certPEM)))
+	require.Error(t, RecordExpiry("default", "invalid-cert", []byte("invalid")))
+
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := ioutil.ReadAll(rec.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), fmt.Sprintf(`consul_k8s_certificate_expiry_timestamp_seconds{secret_name="test-cert",secret_namespace="default"} %g`, float64(cert.NotAfter.Unix())))
+	require.NotContains(t, string(body), "invalid-cert")
+}
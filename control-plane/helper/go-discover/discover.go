@@ -40,7 +40,7 @@ func ConsulServerAddresses(discoverString string, providers map[string]discover.
 
 // newDiscover initializes the new Discover object
 // set up with all predefined providers, as well as
-// the k8s provider.
+// the k8s and dns-srv providers.
 // This code was adapted from
 // https://github.com/hashicorp/consul/blob/c5fe112e59f6e8b03159ec8f2dbe7f4a026ce823/agent/retry_join.go#L42-L53
 func newDiscover(providers map[string]discover.Provider) (*discover.Discover, error) {
@@ -52,6 +52,9 @@ func newDiscover(providers map[string]discover.Provider) (*discover.Discover, er
 		providers[k] = v
 	}
 	providers["k8s"] = &discoverk8s.Provider{}
+	if _, ok := providers["dns-srv"]; !ok {
+		providers["dns-srv"] = &DNSSRVProvider{}
+	}
 
 	userAgent := fmt.Sprintf("consul-k8s/%s (https://www.consul.io/)", version.GetHumanVersion())
 	return discover.New(
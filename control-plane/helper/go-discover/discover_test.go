@@ -2,6 +2,7 @@ package godiscover
 
 import (
 	"errors"
+	"net"
 	"testing"
 
 	"github.com/hashicorp/consul-k8s/control-plane/helper/go-discover/mocks"
@@ -68,3 +69,29 @@ func TestConsulServerAddresses(t *testing.T) {
 		})
 	}
 }
+
+func TestDNSSRVProvider(t *testing.T) {
+	logger := hclog.New(nil)
+	provider := &DNSSRVProvider{
+		LookupSRV: func(service, proto, name string) (string, []*net.SRV, error) {
+			if name != "_consul-server._tcp.example.com" {
+				return "", nil, errors.New("no such host")
+			}
+			return "", []*net.SRV{
+				{Target: "server-1.example.com.", Port: 8300},
+				{Target: "server-2.example.com.", Port: 8300},
+			}, nil
+		},
+	}
+	providers := map[string]discover.Provider{"dns-srv": provider}
+
+	got, err := ConsulServerAddresses("provider=dns-srv name=_consul-server._tcp.example.com", providers, logger)
+	require.NoError(t, err)
+	require.Equal(t, []string{"server-1.example.com", "server-2.example.com"}, got)
+
+	_, err = ConsulServerAddresses("provider=dns-srv name=_consul-server._tcp.other.com", providers, logger)
+	require.EqualError(t, err, "discover-dns-srv: no such host")
+
+	_, err = ConsulServerAddresses("provider=dns-srv", providers, logger)
+	require.EqualError(t, err, "discover-dns-srv: name must be set")
+}
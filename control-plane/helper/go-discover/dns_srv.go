@@ -0,0 +1,57 @@
+package godiscover
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+)
+
+// DNSSRVProvider implements the go-discover Provider interface to discover
+// Consul servers from the targets of DNS SRV records, so that servers running
+// outside of Kubernetes can be found without a static list of hosts.
+type DNSSRVProvider struct {
+	// LookupSRV looks up the SRV records of a name. It defaults to
+	// net.LookupSRV and is only set in tests.
+	LookupSRV func(service, proto, name string) (string, []*net.SRV, error)
+}
+
+// Help implements the go-discover Provider interface.
+func (p *DNSSRVProvider) Help() string {
+	return `DNS SRV:
+
+    provider: "dns-srv"
+    name:     The full name of the SRV records, e.g. _consul-server._tcp.example.com
+
+    The targets of the SRV records are returned in the order of their priority
+    and weight. Their ports are ignored.
+`
+}
+
+// Addrs implements the go-discover Provider interface.
+func (p *DNSSRVProvider) Addrs(args map[string]string, l *log.Logger) ([]string, error) {
+	if args["provider"] != "dns-srv" {
+		return nil, fmt.Errorf("discover-dns-srv: invalid provider %s", args["provider"])
+	}
+	name := args["name"]
+	if name == "" {
+		return nil, fmt.Errorf("discover-dns-srv: name must be set")
+	}
+
+	lookup := p.LookupSRV
+	if lookup == nil {
+		lookup = net.LookupSRV
+	}
+	// With an empty service and proto, the name is looked up directly.
+	_, records, err := lookup("", "", name)
+	if err != nil {
+		return nil, fmt.Errorf("discover-dns-srv: %s", err)
+	}
+
+	var addrs []string
+	for _, r := range records {
+		addrs = append(addrs, strings.TrimSuffix(r.Target, "."))
+	}
+	l.Printf("[DEBUG] discover-dns-srv: Found %d servers for %s", len(addrs), name)
+	return addrs, nil
+}
@@ -0,0 +1,37 @@
+package namespaces
+
+import (
+	"sync"
+)
+
+// Cache remembers the Consul namespaces that are known to exist so that they
+// can be relied on while the Consul servers are unreachable. A nil Cache
+// remembers nothing.
+type Cache struct {
+	mu    sync.RWMutex
+	known map[string]struct{}
+}
+
+// Add records that the namespace exists.
+func (c *Cache) Add(ns string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.known == nil {
+		c.known = make(map[string]struct{})
+	}
+	c.known[ns] = struct{}{}
+}
+
+// Has returns true if the namespace is known to exist.
+func (c *Cache) Has(ns string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.known[ns]
+	return ok
+}
@@ -0,0 +1,63 @@
+package namespaces
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// MirroringRule rewrites the name of a Kubernetes namespace when it is mirrored
+// into a Consul namespace.
+type MirroringRule struct {
+	// Match is the regular expression that the whole Kubernetes namespace name
+	// must match for the rule to apply, e.g. "team-(.*)".
+	Match string `json:"match"`
+	// Replace is the name of the Consul namespace that the Kubernetes namespace is
+	// mirrored into. It can reference the capture groups of Match, e.g. "$1".
+	Replace string `json:"replace"`
+
+	re *regexp.Regexp
+}
+
+// MirroringRules is an ordered list of rules. The first rule that matches a
+// Kubernetes namespace is applied.
+type MirroringRules []MirroringRule
+
+// ParseMirroringRules parses the JSON list of mirroring rules, e.g.
+// [{"match": "team-(.*)", "replace": "$1"}]. An empty string results in no rules.
+func ParseMirroringRules(raw string) (MirroringRules, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var rules MirroringRules
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return nil, fmt.Errorf("unable to parse namespace mirroring rules: %w", err)
+	}
+	for i := range rules {
+		if rules[i].Match == "" {
+			return nil, fmt.Errorf("namespace mirroring rule %d: match must be set", i)
+		}
+		if rules[i].Replace == "" {
+			return nil, fmt.Errorf("namespace mirroring rule %d: replace must be set", i)
+		}
+		// Anchor the expression so that rules always match the whole namespace name.
+		re, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", rules[i].Match))
+		if err != nil {
+			return nil, fmt.Errorf("namespace mirroring rule %d: %w", i, err)
+		}
+		rules[i].re = re
+	}
+	return rules, nil
+}
+
+// Apply returns the name of the Consul namespace for the Kubernetes namespace
+// kubeNS. If no rule matches, kubeNS is returned unchanged.
+func (r MirroringRules) Apply(kubeNS string) string {
+	for _, rule := range r {
+		if rule.re != nil && rule.re.MatchString(kubeNS) {
+			return rule.re.ReplaceAllString(kubeNS, rule.Replace)
+		}
+	}
+	return kubeNS
+}
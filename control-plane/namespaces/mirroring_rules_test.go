@@ -0,0 +1,121 @@
+package namespaces
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMirroringRules(t *testing.T) {
+	cases := map[string]struct {
+		raw    string
+		expLen int
+		expErr string
+	}{
+		"empty": {
+			raw:    "",
+			expLen: 0,
+		},
+		"valid rules": {
+			raw:    `[{"match": "team-(.*)", "replace": "$1"}, {"match": "prod-.*", "replace": "prod"}]`,
+			expLen: 2,
+		},
+		"invalid json": {
+			raw:    `{"match": "team-(.*)"}`,
+			expErr: "unable to parse namespace mirroring rules: json: cannot unmarshal object into Go value of type namespaces.MirroringRules",
+		},
+		"missing match": {
+			raw:    `[{"replace": "foo"}]`,
+			expErr: "namespace mirroring rule 0: match must be set",
+		},
+		"missing replace": {
+			raw:    `[{"match": "foo"}, {"match": "bar"}]`,
+			expErr: "namespace mirroring rule 0: replace must be set",
+		},
+		"invalid regular expression": {
+			raw:    `[{"match": "foo", "replace": "bar"}, {"match": "team-(", "replace": "$1"}]`,
+			expErr: "namespace mirroring rule 1: error parsing regexp: missing closing ): `^(?:team-()$`",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			rules, err := ParseMirroringRules(c.raw)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, rules, c.expLen)
+		})
+	}
+}
+
+func TestMirroringRules_Apply(t *testing.T) {
+	rules, err := ParseMirroringRules(`[
+		{"match": "team-(.*)", "replace": "$1"},
+		{"match": "prod-(.*)", "replace": "production-${1}"},
+		{"match": "payments", "replace": "billing"},
+		{"match": "(.*)-dev", "replace": "dev"}
+	]`)
+	require.NoError(t, err)
+
+	cases := map[string]string{
+		"team-frontend":  "frontend",
+		"prod-us-east":   "production-us-east",
+		"payments":       "billing",
+		"payments-api":   "payments-api",
+		"old-payments":   "old-payments",
+		"team-api-dev":   "api-dev",
+		"checkout-dev":   "dev",
+		"default":        "default",
+		"kube-system":    "kube-system",
+		"my-team-shared": "my-team-shared",
+	}
+	for kubeNS, expNS := range cases {
+		t.Run(kubeNS, func(t *testing.T) {
+			require.Equal(t, expNS, rules.Apply(kubeNS))
+		})
+	}
+}
+
+func TestConsulNamespace_MirroringRules(t *testing.T) {
+	rules, err := ParseMirroringRules(`[{"match": "team-(.*)", "replace": "$1"}]`)
+	require.NoError(t, err)
+
+	cases := map[string]struct {
+		enableMirroring bool
+		mirroringPrefix string
+		kubeNS          string
+		expNS           string
+	}{
+		"rules are applied when mirroring": {
+			enableMirroring: true,
+			kubeNS:          "team-frontend",
+			expNS:           "frontend",
+		},
+		"prefix is added after the rules are applied": {
+			enableMirroring: true,
+			mirroringPrefix: "k8s-",
+			kubeNS:          "team-frontend",
+			expNS:           "k8s-frontend",
+		},
+		"namespaces that match no rule are mirrored as is": {
+			enableMirroring: true,
+			mirroringPrefix: "k8s-",
+			kubeNS:          "default",
+			expNS:           "k8s-default",
+		},
+		"rules are not applied without mirroring": {
+			kubeNS: "team-frontend",
+			expNS:  "dest",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			act := ConsulNamespace(c.kubeNS, true, "dest", c.enableMirroring, c.mirroringPrefix, rules)
+			require.Equal(t, c.expNS, act)
+		})
+	}
+}
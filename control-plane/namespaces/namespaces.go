@@ -54,15 +54,17 @@ func EnsureExists(client *capi.Client, ns string, crossNSAClPolicy string) (bool
 
 // ConsulNamespace returns the consul namespace that a service should be
 // registered in based on the namespace options. It returns an
-// empty string if namespaces aren't enabled.
-func ConsulNamespace(kubeNS string, enableConsulNamespaces bool, consulDestNS string, enableMirroring bool, mirroringPrefix string) string {
+// empty string if namespaces aren't enabled. When mirroring, the
+// mirroring rules rewrite the Kubernetes namespace name before the
+// mirroring prefix is added.
+func ConsulNamespace(kubeNS string, enableConsulNamespaces bool, consulDestNS string, enableMirroring bool, mirroringPrefix string, mirroringRules MirroringRules) string {
 	if !enableConsulNamespaces {
 		return ""
 	}
 
 	// Mirroring takes precedence.
 	if enableMirroring {
-		return fmt.Sprintf("%s%s", mirroringPrefix, kubeNS)
+		return fmt.Sprintf("%s%s", mirroringPrefix, mirroringRules.Apply(kubeNS))
 	}
 
 	return consulDestNS
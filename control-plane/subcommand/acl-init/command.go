@@ -88,6 +88,7 @@ func (c *Command) init() {
 		"Name of the component to pass to ACL Login as metadata.")
 	c.flags.Var((*flags.AppendSliceValue)(&c.flagServerAddresses), "server-address",
 		"The IP, DNS name or the cloud auto-join string of the Consul server(s). If providing IPs or DNS names, may be specified multiple times. "+
+			"The cloud auto-join string may also discover the servers from DNS SRV records, e.g. \"provider=dns-srv name=_consul-server._tcp.example.com\". "+
 			"At least one value is required.")
 	c.flags.UintVar(&c.flagServerPort, "server-port", 8500, "The HTTP or HTTPS port of the Consul server. Defaults to 8500.")
 	c.flags.StringVar(&c.flagConsulCACert, "consul-ca-cert", "",
@@ -0,0 +1,173 @@
+package benchmarkinject
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	mapset "github.com/deckarep/golang-set"
+	"github.com/go-logr/logr"
+	connectinject "github.com/hashicorp/consul-k8s/control-plane/connect-inject"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	"github.com/mitchellh/cli"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Command replays synthetic admission requests against the connect inject
+// webhook handler and reports their latency and allocations. It doesn't need
+// a Kubernetes cluster or Consul servers, so it can be run in CI to catch
+// regressions of the injection path before a release.
+type Command struct {
+	UI cli.Ui
+
+	flags *flag.FlagSet
+
+	flagRequests               int
+	flagConcurrency            int
+	flagWarmup                 int
+	flagEnableTransparentProxy bool
+	flagMaxP99                 time.Duration
+	flagMaxAllocs              uint64
+
+	once sync.Once
+	help string
+}
+
+func (c *Command) init() {
+	c.flags = flag.NewFlagSet("", flag.ContinueOnError)
+	c.flags.IntVar(&c.flagRequests, "requests", 5000,
+		"Number of admission requests to replay.")
+	c.flags.IntVar(&c.flagConcurrency, "concurrency", runtime.NumCPU(),
+		"Number of requests that are handled concurrently. Defaults to the number of CPUs.")
+	c.flags.IntVar(&c.flagWarmup, "warmup", 100,
+		"Number of admission requests that are handled before the measured requests.")
+	c.flags.BoolVar(&c.flagEnableTransparentProxy, "enable-transparent-proxy", false,
+		"Enable transparent proxy mode for all pods, as with the flag of inject-connect.")
+	c.flags.DurationVar(&c.flagMaxP99, "max-p99", 0,
+		"Exit with a non-zero status if the 99th percentile latency is higher. Zero disables the check.")
+	c.flags.Uint64Var(&c.flagMaxAllocs, "max-allocs-per-request", 0,
+		"Exit with a non-zero status if requests allocate more on average. Zero disables the check.")
+
+	c.help = flags.Usage(help, c.flags)
+}
+
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	if err := c.flags.Parse(args); err != nil {
+		return 1
+	}
+	if len(c.flags.Args()) > 0 {
+		c.UI.Error("Should have no non-flag arguments.")
+		return 1
+	}
+	if c.flagRequests < 1 {
+		c.UI.Error("-requests must be greater than 0")
+		return 1
+	}
+	if c.flagConcurrency < 1 {
+		c.UI.Error("-concurrency must be greater than 0")
+		return 1
+	}
+	if c.flagWarmup < 0 {
+		c.UI.Error("-warmup must not be negative")
+		return 1
+	}
+
+	handler, err := c.handler()
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error creating handler: %s", err))
+		return 1
+	}
+	requests, err := connectinject.SyntheticRequests(c.flagWarmup+c.flagRequests, metav1.NamespaceDefault)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error creating requests: %s", err))
+		return 1
+	}
+
+	ctx := context.Background()
+	connectinject.RunBenchmark(ctx, handler, requests[:c.flagWarmup], c.flagConcurrency)
+	result := connectinject.RunBenchmark(ctx, handler, requests[c.flagWarmup:], c.flagConcurrency)
+
+	c.UI.Info(fmt.Sprintf("Requests:      %d (%d concurrent)", result.Requests, c.flagConcurrency))
+	c.UI.Info(fmt.Sprintf("Duration:      %s (%.0f requests/s)", result.Duration, float64(result.Requests)/result.Duration.Seconds()))
+	c.UI.Info(fmt.Sprintf("Latency p50:   %s", result.P50))
+	c.UI.Info(fmt.Sprintf("Latency p99:   %s", result.P99))
+	c.UI.Info(fmt.Sprintf("Latency max:   %s", result.Max))
+	c.UI.Info(fmt.Sprintf("Allocs/req:    %d (%d B)", result.AllocsPerRequest, result.BytesPerRequest))
+
+	exitCode := 0
+	if result.Errors > 0 {
+		c.UI.Error(fmt.Sprintf("%d requests were not allowed", result.Errors))
+		exitCode = 1
+	}
+	if c.flagMaxP99 > 0 && result.P99 > c.flagMaxP99 {
+		c.UI.Error(fmt.Sprintf("p99 latency %s is higher than -max-p99 %s", result.P99, c.flagMaxP99))
+		exitCode = 1
+	}
+	if c.flagMaxAllocs > 0 && result.AllocsPerRequest > c.flagMaxAllocs {
+		c.UI.Error(fmt.Sprintf("%d allocations per request are more than -max-allocs-per-request %d", result.AllocsPerRequest, c.flagMaxAllocs))
+		exitCode = 1
+	}
+	return exitCode
+}
+
+// handler returns a webhook handler with a fake Kubernetes client that only
+// knows the namespace of the synthetic pods. Log output is discarded so that
+// it doesn't dominate the measurements.
+func (c *Command) handler() (*connectinject.Handler, error) {
+	s := k8sruntime.NewScheme()
+	s.AddKnownTypes(schema.GroupVersion{Group: "", Version: "v1"}, &corev1.Pod{})
+	decoder, err := admission.NewDecoder(s)
+	if err != nil {
+		return nil, err
+	}
+
+	handler := &connectinject.Handler{
+		Clientset: fake.NewSimpleClientset(&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: metav1.NamespaceDefault},
+		}),
+		ImageConsul:            "hashicorp/consul:latest",
+		ImageEnvoy:             "envoyproxy/envoy:latest",
+		ImageConsulK8S:         "hashicorp/consul-k8s-control-plane:latest",
+		AllowK8sNamespacesSet:  mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:   mapset.NewSet(),
+		EnableTransparentProxy: c.flagEnableTransparentProxy,
+		TProxyOverwriteProbes:  true,
+		Log:                    logr.Discard(),
+	}
+	if err := handler.InjectDecoder(decoder); err != nil {
+		return nil, err
+	}
+	return handler, nil
+}
+
+func (c *Command) Synopsis() string { return synopsis }
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.help
+}
+
+const synopsis = "Benchmark the connect inject webhook handler."
+const help = `
+Usage: consul-k8s-control-plane benchmark inject [options]
+
+  Replays synthetic admission requests against the connect inject webhook
+  handler and reports the p50 and p99 latency and the allocations per
+  request. The synthetic pods cycle through the common shapes of injected
+  pods: plain pods, pods with upstreams, transparent proxy pods with HTTP
+  probes, pods with metrics merging, multi port pods, and pods that opted
+  out of injection.
+
+  The handler runs in process with a fake Kubernetes client, so no cluster
+  or Consul servers are needed. Use -max-p99 and -max-allocs-per-request to
+  fail on regressions.
+
+`
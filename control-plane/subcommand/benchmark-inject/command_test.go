@@ -0,0 +1,83 @@
+package benchmarkinject
+
+import (
+	"testing"
+
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_FlagValidation(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		flags  []string
+		expErr string
+	}{
+		{
+			flags:  []string{"-requests", "0"},
+			expErr: "-requests must be greater than 0",
+		},
+		{
+			flags:  []string{"-concurrency", "0"},
+			expErr: "-concurrency must be greater than 0",
+		},
+		{
+			flags:  []string{"-warmup", "-1"},
+			expErr: "-warmup must not be negative",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{UI: ui}
+			exitCode := cmd.Run(c.flags)
+			require.Equal(t, 1, exitCode, ui.ErrorWriter.String())
+			require.Contains(t, ui.ErrorWriter.String(), c.expErr)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		flags   []string
+		expCode int
+		expErr  string
+	}{
+		"no thresholds": {
+			flags:   []string{"-requests", "60", "-warmup", "6"},
+			expCode: 0,
+		},
+		"transparent proxy": {
+			flags:   []string{"-requests", "60", "-warmup", "6", "-enable-transparent-proxy"},
+			expCode: 0,
+		},
+		"p99 threshold exceeded": {
+			flags:   []string{"-requests", "60", "-max-p99", "1ns"},
+			expCode: 1,
+			expErr:  "is higher than -max-p99 1ns",
+		},
+		"allocs threshold exceeded": {
+			flags:   []string{"-requests", "60", "-max-allocs-per-request", "1"},
+			expCode: 1,
+			expErr:  "are more than -max-allocs-per-request 1",
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ui := cli.NewMockUi()
+			cmd := Command{UI: ui}
+			exitCode := cmd.Run(c.flags)
+			require.Equal(t, c.expCode, exitCode, ui.ErrorWriter.String())
+			require.Contains(t, ui.OutputWriter.String(), "Requests:      60")
+			require.Contains(t, ui.OutputWriter.String(), "Latency p99:")
+			if c.expErr != "" {
+				require.Contains(t, ui.ErrorWriter.String(), c.expErr)
+			}
+		})
+	}
+}
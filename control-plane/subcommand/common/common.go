@@ -15,8 +15,6 @@ import (
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/go-discover"
 	"github.com/hashicorp/go-hclog"
-	"go.uber.org/zap/zapcore"
-	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
 
 const (
@@ -55,20 +53,13 @@ func Logger(level string, jsonLogging bool) (hclog.Logger, error) {
 }
 
 // ZapLogger returns a logr.Logger instance with log level set and JSON logging enabled/disabled, or an error if the level is invalid.
+// Use NewLogLevels instead to change the log level at runtime.
 func ZapLogger(level string, jsonLogging bool) (logr.Logger, error) {
-	var zapLevel zapcore.Level
-	// It is possible that a user passes in "trace" from global.logLevel, until we standardize on one logging framework
-	// we will assume they meant debug here and not fail.
-	if level == "trace" || level == "TRACE" {
-		level = "debug"
-	}
-	if err := zapLevel.UnmarshalText([]byte(level)); err != nil {
-		return nil, fmt.Errorf("unknown log level %q: %s", level, err.Error())
-	}
-	if jsonLogging {
-		return zap.New(zap.UseDevMode(false), zap.Level(zapLevel), zap.JSONEncoder()), nil
+	levels, err := NewLogLevels(level)
+	if err != nil {
+		return nil, err
 	}
-	return zap.New(zap.UseDevMode(false), zap.Level(zapLevel), zap.ConsoleEncoder()), nil
+	return levels.Logger(jsonLogging), nil
 }
 
 // ValidateUnprivilegedPort converts flags representing ports into integer and validates
@@ -221,3 +212,50 @@ func GetResolvedServerAddresses(serverAddresses []string, providers map[string]d
 	}
 	return godiscover.ConsulServerAddresses(serverAddresses[0], providers, logger)
 }
+
+// DefaultServerAddressRefreshInterval is how often ServerAddresses resolves
+// the Consul server addresses again by default.
+const DefaultServerAddressRefreshInterval = 30 * time.Second
+
+// ServerAddresses hands out the Consul server addresses in turn so that a
+// command retrying requests fails over to the next server. Addresses that
+// are discovered with go-discover are resolved again once they are older
+// than the refresh interval, so that servers that were replaced since they
+// were last resolved are picked up.
+type ServerAddresses struct {
+	// Addresses are the server addresses or a single go-discover string.
+	Addresses []string
+	Providers map[string]discover.Provider
+	Logger    hclog.Logger
+
+	// RefreshInterval defaults to DefaultServerAddressRefreshInterval.
+	RefreshInterval time.Duration
+
+	resolved   []string
+	resolvedAt time.Time
+	next       int
+}
+
+// Next returns the next server address. If the addresses can't be resolved
+// again, the previously resolved addresses are used until they can be.
+func (s *ServerAddresses) Next() (string, error) {
+	interval := s.RefreshInterval
+	if interval == 0 {
+		interval = DefaultServerAddressRefreshInterval
+	}
+	if s.resolved == nil || time.Since(s.resolvedAt) >= interval {
+		resolved, err := GetResolvedServerAddresses(s.Addresses, s.Providers, s.Logger)
+		switch {
+		case err == nil:
+			s.resolved = resolved
+			s.resolvedAt = time.Now()
+		case s.resolved == nil:
+			return "", err
+		default:
+			s.Logger.Error("Unable to resolve Consul server addresses, using the previous addresses", "err", err)
+		}
+	}
+	addr := s.resolved[s.next%len(s.resolved)]
+	s.next++
+	return addr, nil
+}
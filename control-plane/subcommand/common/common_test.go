@@ -1,6 +1,7 @@
 package common
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
@@ -296,6 +297,50 @@ func TestGetResolvedServerAddresses(t *testing.T) {
 	}
 }
 
+func TestServerAddresses(t *testing.T) {
+	provider := new(mocks.MockProvider)
+	provider.On("Addrs", mock.Anything, mock.Anything).Return([]string{"1.1.1.1", "2.2.2.2"}, nil).Once()
+	provider.On("Addrs", mock.Anything, mock.Anything).Return(nil, errors.New("provider error")).Once()
+	provider.On("Addrs", mock.Anything, mock.Anything).Return([]string{"3.3.3.3"}, nil).Once()
+	addresses := &ServerAddresses{
+		Addresses:       []string{"provider=mock"},
+		Providers:       map[string]discover.Provider{"mock": provider},
+		Logger:          hclog.NewNullLogger(),
+		RefreshInterval: time.Hour,
+	}
+
+	// The addresses are handed out in turn.
+	for _, expected := range []string{"1.1.1.1", "2.2.2.2", "1.1.1.1"} {
+		addr, err := addresses.Next()
+		require.NoError(t, err)
+		require.Equal(t, expected, addr)
+	}
+
+	// The previous addresses are used if they can't be resolved again.
+	addresses.RefreshInterval = time.Nanosecond
+	addr, err := addresses.Next()
+	require.NoError(t, err)
+	require.Equal(t, "2.2.2.2", addr)
+
+	// Servers that were replaced are picked up.
+	addr, err = addresses.Next()
+	require.NoError(t, err)
+	require.Equal(t, "3.3.3.3", addr)
+	provider.AssertExpectations(t)
+}
+
+func TestServerAddresses_Error(t *testing.T) {
+	provider := new(mocks.MockProvider)
+	provider.On("Addrs", mock.Anything, mock.Anything).Return(nil, errors.New("provider error"))
+	addresses := &ServerAddresses{
+		Addresses: []string{"provider=mock"},
+		Providers: map[string]discover.Provider{"mock": provider},
+		Logger:    hclog.NewNullLogger(),
+	}
+	_, err := addresses.Next()
+	require.EqualError(t, err, "provider error")
+}
+
 // startMockServer starts an httptest server used to mock a Consul server's
 // /v1/acl/login endpoint. apiCallCounter will be incremented on each call to /v1/acl/login.
 // It returns a consul client pointing at the server.
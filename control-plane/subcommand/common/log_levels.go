@@ -0,0 +1,186 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	uberzap "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// LogLevels holds the log level of a command and of its components, which can
+// be changed at runtime through its HTTP API. Components are named loggers,
+// e.g. "controller.endpoints" for ctrl.Log.WithName("controller").WithName("endpoints").
+// A component's level applies to the loggers named after it too, e.g. the
+// level of "controller" applies to "controller.endpoints" unless it has its
+// own level.
+type LogLevels struct {
+	mu         sync.RWMutex
+	level      zapcore.Level
+	components map[string]zapcore.Level
+}
+
+// LogLevelsStatus is the body of the responses of the HTTP API, and of the
+// requests that update the levels. A request updates the level of the
+// component if it is set and of the command otherwise. An empty level removes
+// the level of the component so that it inherits it again.
+type LogLevelsStatus struct {
+	Component  string            `json:"component,omitempty"`
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components,omitempty"`
+}
+
+// NewLogLevels returns log levels with the level of the command set.
+func NewLogLevels(level string) (*LogLevels, error) {
+	zapLevel, err := parseZapLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	return &LogLevels{level: zapLevel}, nil
+}
+
+// parseZapLevel parses a level, accepting "trace" as debug.
+func parseZapLevel(level string) (zapcore.Level, error) {
+	var zapLevel zapcore.Level
+	// It is possible that a user passes in "trace" from global.logLevel, until we standardize on one logging framework
+	// we will assume they meant debug here and not fail.
+	if level == "trace" || level == "TRACE" {
+		level = "debug"
+	}
+	if err := zapLevel.UnmarshalText([]byte(level)); err != nil {
+		return zapLevel, fmt.Errorf("unknown log level %q: %s", level, err.Error())
+	}
+	return zapLevel, nil
+}
+
+// Logger returns a logr.Logger whose levels are controlled by l.
+func (l *LogLevels) Logger(jsonLogging bool) logr.Logger {
+	encoder := zap.ConsoleEncoder()
+	if jsonLogging {
+		encoder = zap.JSONEncoder()
+	}
+	return zap.New(zap.UseDevMode(false), encoder, zap.Level(l),
+		zap.RawZapOpts(uberzap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return &levelsCore{Core: core, levels: l}
+		})))
+}
+
+// Enabled returns true if the level is enabled for any component. It
+// implements zapcore.LevelEnabler so that entries below all levels are
+// dropped early.
+func (l *LogLevels) Enabled(level zapcore.Level) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if level >= l.level {
+		return true
+	}
+	for _, componentLevel := range l.components {
+		if level >= componentLevel {
+			return true
+		}
+	}
+	return false
+}
+
+// levelFor returns the level of the logger, which is the level of the
+// longest component its name starts with.
+func (l *LogLevels) levelFor(name string) zapcore.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for {
+		if level, ok := l.components[name]; ok {
+			return level
+		}
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			return l.level
+		}
+		name = name[:i]
+	}
+}
+
+// Set sets the level of the component, or of the command if component is
+// empty. An empty level removes the level of the component.
+func (l *LogLevels) Set(component, level string) error {
+	if component != "" && level == "" {
+		l.mu.Lock()
+		delete(l.components, component)
+		l.mu.Unlock()
+		return nil
+	}
+	zapLevel, err := parseZapLevel(level)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if component == "" {
+		l.level = zapLevel
+		return nil
+	}
+	if l.components == nil {
+		l.components = make(map[string]zapcore.Level)
+	}
+	l.components[component] = zapLevel
+	return nil
+}
+
+// Status returns the levels of the command and its components.
+func (l *LogLevels) Status() LogLevelsStatus {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	status := LogLevelsStatus{Level: l.level.String()}
+	if len(l.components) > 0 {
+		status.Components = make(map[string]string, len(l.components))
+		for component, level := range l.components {
+			status.Components[component] = level.String()
+		}
+	}
+	return status
+}
+
+// ServeHTTP returns the levels on GET and updates them on PUT, e.g.
+// {"component": "controller.endpoints", "level": "debug"}.
+func (l *LogLevels) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req LogLevelsStatus
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request: %s", err), http.StatusBadRequest)
+			return
+		}
+		if err := l.Set(req.Component, req.Level); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "only GET and PUT are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(l.Status())
+}
+
+// levelsCore drops the entries below the level of the logger that wrote them.
+type levelsCore struct {
+	zapcore.Core
+	levels *LogLevels
+}
+
+func (c *levelsCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelsCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *levelsCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if entry.Level < c.levels.levelFor(entry.LoggerName) {
+		return checked
+	}
+	return c.Core.Check(entry, checked)
+}
@@ -0,0 +1,115 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogLevels_levelFor(t *testing.T) {
+	levels, err := NewLogLevels("info")
+	require.NoError(t, err)
+	require.NoError(t, levels.Set("controller", "error"))
+	require.NoError(t, levels.Set("controller.endpoints", "debug"))
+
+	require.Equal(t, zapcore.InfoLevel, levels.levelFor("handler.connect"))
+	require.Equal(t, zapcore.InfoLevel, levels.levelFor(""))
+	require.Equal(t, zapcore.ErrorLevel, levels.levelFor("controller"))
+	require.Equal(t, zapcore.ErrorLevel, levels.levelFor("controller.coredns"))
+	require.Equal(t, zapcore.DebugLevel, levels.levelFor("controller.endpoints"))
+	require.Equal(t, zapcore.DebugLevel, levels.levelFor("controller.endpoints.request"))
+	// Only whole names match.
+	require.Equal(t, zapcore.InfoLevel, levels.levelFor("controllers"))
+
+	// Debug logs are enabled since a component logs at debug.
+	require.True(t, levels.Enabled(zapcore.DebugLevel))
+
+	// Removing the level of a component makes it inherit it again.
+	require.NoError(t, levels.Set("controller.endpoints", ""))
+	require.Equal(t, zapcore.ErrorLevel, levels.levelFor("controller.endpoints"))
+	require.False(t, levels.Enabled(zapcore.DebugLevel))
+
+	require.Error(t, levels.Set("controller", "verbose"))
+}
+
+func TestLogLevels_Logger(t *testing.T) {
+	levels, err := NewLogLevels("info")
+	require.NoError(t, err)
+	log := levels.Logger(false)
+
+	require.False(t, log.WithName("controller").V(1).Enabled())
+	require.NoError(t, levels.Set("controller", "debug"))
+	require.True(t, log.WithName("controller").V(1).Enabled())
+	require.NoError(t, levels.Set("", "error"))
+	require.True(t, log.WithName("controller").V(1).Enabled())
+}
+
+func TestLogLevels_ServeHTTP(t *testing.T) {
+	levels, err := NewLogLevels("trace")
+	require.NoError(t, err)
+
+	cases := []struct {
+		name      string
+		method    string
+		body      string
+		expStatus int
+		expLevels LogLevelsStatus
+	}{
+		{
+			name:      "get",
+			method:    http.MethodGet,
+			expStatus: http.StatusOK,
+			expLevels: LogLevelsStatus{Level: "debug"},
+		},
+		{
+			name:      "set level",
+			method:    http.MethodPut,
+			body:      `{"level": "warn"}`,
+			expStatus: http.StatusOK,
+			expLevels: LogLevelsStatus{Level: "warn"},
+		},
+		{
+			name:      "set component level",
+			method:    http.MethodPut,
+			body:      `{"component": "controller.endpoints", "level": "debug"}`,
+			expStatus: http.StatusOK,
+			expLevels: LogLevelsStatus{Level: "warn", Components: map[string]string{"controller.endpoints": "debug"}},
+		},
+		{
+			name:      "invalid level",
+			method:    http.MethodPut,
+			body:      `{"level": "verbose"}`,
+			expStatus: http.StatusBadRequest,
+		},
+		{
+			name:      "remove component level",
+			method:    http.MethodPut,
+			body:      `{"component": "controller.endpoints", "level": ""}`,
+			expStatus: http.StatusOK,
+			expLevels: LogLevelsStatus{Level: "warn"},
+		},
+		{
+			name:      "unsupported method",
+			method:    http.MethodPost,
+			expStatus: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			levels.ServeHTTP(rec, httptest.NewRequest(c.method, "/log-level", strings.NewReader(c.body)))
+			require.Equal(t, c.expStatus, rec.Code, rec.Body.String())
+			if c.expStatus != http.StatusOK {
+				return
+			}
+			var status LogLevelsStatus
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&status))
+			require.Equal(t, c.expLevels, status)
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package consulapiproxy
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	apiproxy "github.com/hashicorp/consul-k8s/control-plane/api-proxy"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+	"k8s.io/client-go/kubernetes"
+)
+
+const shutdownTimeout = 10 * time.Second
+
+type Command struct {
+	UI cli.Ui
+
+	flagSet *flag.FlagSet
+	k8s     *flags.K8SFlags
+	http    *flags.HTTPFlags
+
+	flagListen      string
+	flagTLSCertFile string
+	flagTLSKeyFile  string
+	flagLogLevel    string
+	flagLogJSON     bool
+
+	clientset    kubernetes.Interface
+	consulClient *capi.Client
+	consulConfig *capi.Config
+
+	once  sync.Once
+	help  string
+	sigCh chan os.Signal
+}
+
+func (c *Command) init() {
+	c.flagSet = flag.NewFlagSet("", flag.ContinueOnError)
+	c.flagSet.StringVar(&c.flagListen, "listen", ":8443",
+		"Address to serve the aggregated Consul API on.")
+	c.flagSet.StringVar(&c.flagTLSCertFile, "tls-cert-file", "",
+		"Path to the TLS certificate to serve the aggregated Consul API with.")
+	c.flagSet.StringVar(&c.flagTLSKeyFile, "tls-key-file", "",
+		"Path to the key of the TLS certificate to serve the aggregated Consul API with.")
+	c.flagSet.StringVar(&c.flagLogLevel, "log-level", "info",
+		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
+			"\"debug\", \"info\", \"warn\", and \"error\".")
+	c.flagSet.BoolVar(&c.flagLogJSON, "log-json", false,
+		"Enable or disable JSON output format for logging.")
+
+	c.http = &flags.HTTPFlags{}
+	c.k8s = &flags.K8SFlags{}
+	flags.Merge(c.flagSet, c.http.Flags())
+	flags.Merge(c.flagSet, c.k8s.Flags())
+	c.help = flags.Usage(help, c.flagSet)
+
+	// Wait on an interrupt or terminate to exit. This channel must be initialized before
+	// Run() is called so that there are no race conditions where the channel
+	// is not defined.
+	if c.sigCh == nil {
+		c.sigCh = make(chan os.Signal, 1)
+		signal.Notify(c.sigCh, syscall.SIGINT, syscall.SIGTERM)
+	}
+}
+
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	if err := c.flagSet.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("Error parsing flagSet: %s", err))
+		return 1
+	}
+	if err := c.validateFlags(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	logger, err := common.Logger(c.flagLogLevel, c.flagLogJSON)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if c.clientset == nil {
+		config, err := subcommand.K8SConfig(c.k8s.KubeConfig())
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error retrieving Kubernetes auth: %s", err))
+			return 1
+		}
+		c.clientset, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error initializing Kubernetes client: %s", err))
+			return 1
+		}
+	}
+	if c.consulClient == nil {
+		// The client fills in the config, which the Consul HTTP API is passed
+		// through with.
+		c.consulConfig = capi.DefaultConfig()
+		c.http.MergeOntoConfig(c.consulConfig)
+		c.consulClient, err = capi.NewClient(c.consulConfig)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error connecting to Consul agent: %s", err))
+			return 1
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	auth, err := apiproxy.LoadRequestHeaderAuthenticator(ctx, c.clientset)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error loading the API server authentication configuration: %s", err))
+		return 1
+	}
+
+	server := &http.Server{
+		Addr: c.flagListen,
+		Handler: &apiproxy.Handler{
+			ConsulClient:  c.consulClient,
+			ConsulConfig:  c.consulConfig,
+			Authenticator: auth,
+			Log:           logger.Named("api-proxy"),
+		},
+		// Client certificates are verified if given so that the health check
+		// can be served without one. Handler rejects other requests without
+		// a verified certificate.
+		TLSConfig: &tls.Config{
+			ClientCAs:  auth.ClientCAs,
+			ClientAuth: tls.VerifyClientCertIfGiven,
+			MinVersion: tls.VersionTLS12,
+		},
+	}
+
+	exitCh := make(chan error, 1)
+	go func() {
+		logger.Info("serving the Consul API", "listen", c.flagListen, "group", apiproxy.Group, "version", apiproxy.Version)
+		exitCh <- server.ListenAndServeTLS(c.flagTLSCertFile, c.flagTLSKeyFile)
+	}()
+
+	select {
+	case err := <-exitCh:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			c.UI.Error(fmt.Sprintf("Error serving the Consul API: %s", err))
+			return 1
+		}
+		return 0
+	case sig := <-c.sigCh:
+		logger.Info(fmt.Sprintf("%s received, shutting down", sig))
+		shutdownCtx, shutdownCancel := context.WithTimeout(ctx, shutdownTimeout)
+		defer shutdownCancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			c.UI.Error(fmt.Sprintf("Error shutting down: %s", err))
+			return 1
+		}
+		return 0
+	}
+}
+
+func (c *Command) validateFlags() error {
+	if len(c.flagSet.Args()) > 0 {
+		return errors.New("Invalid arguments: should have no non-flag arguments")
+	}
+	if c.flagTLSCertFile == "" {
+		return errors.New("-tls-cert-file must be set")
+	}
+	if c.flagTLSKeyFile == "" {
+		return errors.New("-tls-key-file must be set")
+	}
+	return nil
+}
+
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.help
+}
+
+func (c *Command) Synopsis() string {
+	return synopsis
+}
+
+const synopsis = "Serve the Consul API through the Kubernetes API aggregation layer"
+const help = `
+Usage: consul-k8s-control-plane consul-api-proxy [options]
+
+  Serves a read-only subset of the Consul API as the api.consul.hashicorp.com
+  API group of the Kubernetes API server. Requests are only accepted when
+  they are proxied by the API server, which authenticates, authorizes and
+  audits them. Reads of the catalog, health, config, connect, discovery-chain
+  and peering areas of the Consul HTTP API are passed through under
+  /apis/api.consul.hashicorp.com/v1alpha1/consul/v1/.
+
+`
@@ -0,0 +1,59 @@
+package consulapiproxy
+
+import (
+	"testing"
+
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRun_FlagValidation(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		Flags  []string
+		ExpErr string
+	}{
+		{
+			Flags:  []string{},
+			ExpErr: "-tls-cert-file must be set",
+		},
+		{
+			Flags:  []string{"-tls-cert-file", "tls.crt"},
+			ExpErr: "-tls-key-file must be set",
+		},
+		{
+			Flags:  []string{"-tls-cert-file", "tls.crt", "-tls-key-file", "tls.key", "foo"},
+			ExpErr: "Invalid arguments: should have no non-flag arguments",
+		},
+		{
+			Flags:  []string{"-tls-cert-file", "tls.crt", "-tls-key-file", "tls.key", "-log-level", "invalid"},
+			ExpErr: "unknown log level: invalid",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.ExpErr, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{
+				UI:        ui,
+				clientset: fake.NewSimpleClientset(),
+			}
+			code := cmd.Run(c.Flags)
+			require.Equal(t, 1, code)
+			require.Contains(t, ui.ErrorWriter.String(), c.ExpErr)
+		})
+	}
+}
+
+func TestRun_RequiresAggregationLayer(t *testing.T) {
+	t.Parallel()
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI:        ui,
+		clientset: fake.NewSimpleClientset(),
+	}
+	code := cmd.Run([]string{"-tls-cert-file", "tls.crt", "-tls-key-file", "tls.key"})
+	require.Equal(t, 1, code)
+	require.Contains(t, ui.ErrorWriter.String(), "Error loading the API server authentication configuration")
+}
@@ -6,19 +6,23 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
 	"github.com/hashicorp/go-hclog"
 	"github.com/mitchellh/cli"
+	"k8s.io/client-go/kubernetes"
 )
 
 const (
@@ -47,11 +51,38 @@ type Command struct {
 	flagServiceMetricsPort   string
 	flagServiceMetricsPath   string
 
+	// Flags to configure the xDS watchdog
+	flagEnableXDSWatchdog          bool
+	flagXDSWatchdogThreshold       time.Duration
+	flagXDSWatchdogPolicy          string
+	flagXDSWatchdogInterval        time.Duration
+	flagXDSWatchdogMaxRestarts     int
+	flagXDSWatchdogEnvoyAdminPorts string
+
+	// Flags to configure stopping the proxies of Job pods
+	flagEnableJobCompletion          bool
+	flagJobCompletionInterval        time.Duration
+	flagJobCompletionEnvoyAdminPorts string
+
+	// Flags to configure the WAN address watcher
+	flagWANAddressService          string
+	flagWANAddressNamespace        string
+	flagWANAddressResolveHostnames bool
+	flagWANAddressInterval         time.Duration
+
 	envoyMetricsGetter   metricsGetter
 	serviceMetricsGetter metricsGetter
 
 	consulCommand []string
 
+	// xdsWatchdog is set when the xDS watchdog is enabled. Its metrics are
+	// added to the merged metrics.
+	xdsWatchdog *xdsWatchdog
+
+	// k8sClient is used by the WAN address watcher. It is created from the
+	// in-cluster config if not set, which lets tests replace it.
+	k8sClient kubernetes.Interface
+
 	logger hclog.Logger
 	once   sync.Once
 	help   string
@@ -86,6 +117,37 @@ func (c *Command) init() {
 	c.flagSet.StringVar(&c.flagMergedMetricsPort, "merged-metrics-port", "20100", "Port to serve merged Envoy and application metrics. Defaults to 20100.")
 	c.flagSet.StringVar(&c.flagServiceMetricsPort, "service-metrics-port", "0", "Port where application metrics are being served. Defaults to 0.")
 	c.flagSet.StringVar(&c.flagServiceMetricsPath, "service-metrics-path", "/metrics", "Path where application metrics are being served. Defaults to /metrics.")
+	c.flagSet.BoolVar(&c.flagEnableXDSWatchdog, "enable-xds-watchdog", false,
+		"Enables consul sidecar to watch whether the Envoy proxies are connected to xDS. Defaults to false.")
+	c.flagSet.DurationVar(&c.flagXDSWatchdogThreshold, "xds-watchdog-threshold", 2*time.Minute,
+		"How long an Envoy proxy may be disconnected from xDS or not ready before the xDS watchdog acts on it. Defaults to 2m.")
+	c.flagSet.StringVar(&c.flagXDSWatchdogPolicy, "xds-watchdog-policy", xdsWatchdogPolicyLog,
+		fmt.Sprintf("What the xDS watchdog does with Envoy proxies that are disconnected for longer than the threshold. "+
+			"%q logs and reports them in the merged metrics, %q also restarts them. Defaults to %q.",
+			xdsWatchdogPolicyLog, xdsWatchdogPolicyRestart, xdsWatchdogPolicyLog))
+	c.flagSet.DurationVar(&c.flagXDSWatchdogInterval, "xds-watchdog-interval", 10*time.Second,
+		"Time between checks of the xDS watchdog. Defaults to 10s.")
+	c.flagSet.IntVar(&c.flagXDSWatchdogMaxRestarts, "xds-watchdog-max-restarts", 3,
+		"How many times the xDS watchdog restarts an Envoy proxy that does not reconnect to xDS in between. "+
+			"The time the proxy must be disconnected doubles with each of these restarts. Defaults to 3.")
+	c.flagSet.StringVar(&c.flagXDSWatchdogEnvoyAdminPorts, "xds-watchdog-envoy-admin-ports", "19000",
+		"Comma separated admin API ports of the Envoy proxies the xDS watchdog watches. Defaults to 19000.")
+	c.flagSet.BoolVar(&c.flagEnableJobCompletion, "enable-job-completion", false,
+		"Stop the Envoy proxies once the application containers of the pod have exited, so that Job pods complete. "+
+			"Requires the pod to share its process namespace and to have the Never restart policy.")
+	c.flagSet.DurationVar(&c.flagJobCompletionInterval, "job-completion-interval", 1*time.Second,
+		"Time between checks whether the application containers have exited. Defaults to 1s.")
+	c.flagSet.StringVar(&c.flagJobCompletionEnvoyAdminPorts, "job-completion-envoy-admin-ports", "19000",
+		"Comma separated admin API ports of the Envoy proxies that are stopped once the application containers have exited. Defaults to 19000.")
+	c.flagSet.StringVar(&c.flagWANAddressService, "wan-address-service", "",
+		"Name of the LoadBalancer service of a mesh gateway. If set, the WAN address in the service config is kept in sync "+
+			"with the ingress IP or hostname of the service, and the service is registered again when it changes.")
+	c.flagSet.StringVar(&c.flagWANAddressNamespace, "wan-address-namespace", "",
+		"Kubernetes namespace of the -wan-address-service service.")
+	c.flagSet.BoolVar(&c.flagWANAddressResolveHostnames, "wan-address-resolve-hostnames", false,
+		"If true, the ingress hostname of the -wan-address-service service is resolved and its first IP address is used.")
+	c.flagSet.DurationVar(&c.flagWANAddressInterval, "wan-address-interval", 30*time.Second,
+		"Time between checks of the ingress of the -wan-address-service service. Defaults to 30s.")
 	c.help = flags.Usage(help, c.flagSet)
 	c.http = &flags.HTTPFlags{}
 	flags.Merge(c.flagSet, c.http.Flags())
@@ -134,6 +196,12 @@ func (c *Command) Run(args []string) int {
 		"merged-metrics-port", c.flagMergedMetricsPort,
 		"service-metrics-port", c.flagServiceMetricsPort,
 		"service-metrics-path", c.flagServiceMetricsPath,
+		"enable-xds-watchdog", c.flagEnableXDSWatchdog,
+		"xds-watchdog-threshold", c.flagXDSWatchdogThreshold,
+		"xds-watchdog-policy", c.flagXDSWatchdogPolicy,
+		"xds-watchdog-max-restarts", c.flagXDSWatchdogMaxRestarts,
+		"enable-job-completion", c.flagEnableJobCompletion,
+		"wan-address-service", c.flagWANAddressService,
 	)
 
 	// signalCtx that we pass in to the main work loop, signal handling is handled in another thread
@@ -146,6 +214,63 @@ func (c *Command) Run(args []string) int {
 		cancelFunc()
 	}()
 
+	// If the xDS watchdog is enabled, check the Envoy proxies until a signal is
+	// received. It is created before the merged metrics server so that its
+	// metrics are always available to it.
+	if c.flagEnableXDSWatchdog {
+		adminAddrs := envoyAdminAddrs(c.flagXDSWatchdogEnvoyAdminPorts)
+		c.xdsWatchdog = newXDSWatchdog(adminAddrs, c.flagXDSWatchdogThreshold, c.flagXDSWatchdogPolicy, c.flagXDSWatchdogMaxRestarts, c.logger.Named("xds-watchdog"))
+		c.logger.Info("Running xDS watchdog.", "envoy-admin", adminAddrs)
+		go c.xdsWatchdog.run(signalCtx, c.flagXDSWatchdogInterval)
+	}
+
+	// If job completion is enabled, stop the Envoy proxies once the application
+	// containers have exited, and then exit as well so that the pod completes.
+	if c.flagEnableJobCompletion {
+		completion := &jobCompletion{
+			procDir:    "/proc",
+			selfPID:    os.Getpid(),
+			adminAddrs: envoyAdminAddrs(c.flagJobCompletionEnvoyAdminPorts),
+			client:     &http.Client{Timeout: 5 * time.Second},
+			logger:     c.logger.Named("job-completion"),
+		}
+		c.logger.Info("Waiting for the application containers to exit.", "envoy-admin", completion.adminAddrs)
+		go func() {
+			completion.run(signalCtx, c.flagJobCompletionInterval)
+			cancelFunc()
+		}()
+	}
+
+	// If the WAN address watcher is enabled, keep the WAN address in the
+	// service config up to date, and register the service again right away
+	// when it changes.
+	wanAddressChanged := make(chan struct{}, 1)
+	if c.flagWANAddressService != "" {
+		if c.k8sClient == nil {
+			config, err := subcommand.K8SConfig("")
+			if err != nil {
+				c.UI.Error(fmt.Sprintf("Error retrieving Kubernetes auth: %s", err))
+				return 1
+			}
+			c.k8sClient, err = kubernetes.NewForConfig(config)
+			if err != nil {
+				c.UI.Error(fmt.Sprintf("Error initializing Kubernetes client: %s", err))
+				return 1
+			}
+		}
+		watcher := &wanAddressWatcher{
+			k8sClient:        c.k8sClient,
+			namespace:        c.flagWANAddressNamespace,
+			serviceName:      c.flagWANAddressService,
+			resolveHostnames: c.flagWANAddressResolveHostnames,
+			serviceConfig:    c.flagServiceConfig,
+			logger:           c.logger.Named("wan-address"),
+			lookupIP:         net.LookupIP,
+		}
+		c.logger.Info("Watching the WAN address.", "service", c.flagWANAddressService, "namespace", c.flagWANAddressNamespace)
+		go watcher.run(signalCtx, c.flagWANAddressInterval, wanAddressChanged)
+	}
+
 	// If metrics merging is enabled, run a merged metrics server in a goroutine
 	// that serves Envoy sidecar metrics and Connect service metrics. The merged
 	// metrics server will be shut down when a signal is received by the main
@@ -188,9 +313,12 @@ func (c *Command) Run(args []string) int {
 					c.logger.Info("successfully synced service", "output", strings.TrimSpace(string(output)), "duration", time.Since(start))
 				}
 				select {
-				// Re-loop after syncPeriod or exit if we receive interrupt or terminate signals.
+				// Re-loop after syncPeriod or when the WAN address changed, or exit if we
+				// receive interrupt or terminate signals.
 				case <-time.After(c.flagSyncPeriod):
 					continue
+				case <-wanAddressChanged:
+					continue
 				case <-signalCtx.Done():
 					return
 				}
@@ -297,6 +425,9 @@ func (c *Command) mergedMetricsHandler(rw http.ResponseWriter, _ *http.Request)
 		return
 	}
 	writeResponse(rw, envoyMetricsBody, "envoy metrics", c.logger)
+	if c.xdsWatchdog != nil {
+		writeResponse(rw, c.xdsWatchdog.metrics(), "xds watchdog metrics", c.logger)
+	}
 
 	serviceMetricsAddr := fmt.Sprintf("http://127.0.0.1:%s%s", c.flagServiceMetricsPort, c.flagServiceMetricsPath)
 	serviceMetrics, err := c.serviceMetricsGetter.Get(serviceMetricsAddr)
@@ -342,8 +473,19 @@ func writeResponse(rw http.ResponseWriter, resp []byte, respName string, logger
 
 // validateFlags validates the flags.
 func (c *Command) validateFlags() error {
-	if !c.flagEnableServiceRegistration && !c.flagEnableMetricsMerging {
-		return errors.New("at least one of -enable-service-registration or -enable-metrics-merging must be true")
+	if !c.flagEnableServiceRegistration && !c.flagEnableMetricsMerging && !c.flagEnableXDSWatchdog && !c.flagEnableJobCompletion {
+		return errors.New("at least one of -enable-service-registration, -enable-metrics-merging, -enable-xds-watchdog or -enable-job-completion must be true")
+	}
+	if c.flagWANAddressService != "" {
+		if !c.flagEnableServiceRegistration {
+			return errors.New("-wan-address-service requires -enable-service-registration")
+		}
+		if c.flagWANAddressNamespace == "" {
+			return errors.New("-wan-address-namespace must be set with -wan-address-service")
+		}
+		if c.flagWANAddressInterval <= 0 {
+			return errors.New("-wan-address-interval must be greater than 0")
+		}
 	}
 	if c.flagEnableServiceRegistration {
 		if c.flagSyncPeriod == 0 {
@@ -367,9 +509,48 @@ func (c *Command) validateFlags() error {
 			return fmt.Errorf("-consul-binary %q not found: %s", c.flagConsulBinary, err)
 		}
 	}
+	if c.flagEnableXDSWatchdog {
+		if c.flagXDSWatchdogThreshold <= 0 {
+			return errors.New("-xds-watchdog-threshold must be greater than 0")
+		}
+		if c.flagXDSWatchdogInterval <= 0 {
+			return errors.New("-xds-watchdog-interval must be greater than 0")
+		}
+		if c.flagXDSWatchdogMaxRestarts <= 0 {
+			return errors.New("-xds-watchdog-max-restarts must be greater than 0")
+		}
+		if c.flagXDSWatchdogPolicy != xdsWatchdogPolicyLog && c.flagXDSWatchdogPolicy != xdsWatchdogPolicyRestart {
+			return fmt.Errorf("-xds-watchdog-policy must be %q or %q", xdsWatchdogPolicyLog, xdsWatchdogPolicyRestart)
+		}
+		for _, port := range strings.Split(c.flagXDSWatchdogEnvoyAdminPorts, ",") {
+			if _, err := strconv.Atoi(strings.TrimSpace(port)); err != nil {
+				return fmt.Errorf("-xds-watchdog-envoy-admin-ports has invalid port %q", port)
+			}
+		}
+	}
+	if c.flagEnableJobCompletion {
+		if c.flagJobCompletionInterval <= 0 {
+			return errors.New("-job-completion-interval must be greater than 0")
+		}
+		for _, port := range strings.Split(c.flagJobCompletionEnvoyAdminPorts, ",") {
+			if _, err := strconv.Atoi(strings.TrimSpace(port)); err != nil {
+				return fmt.Errorf("-job-completion-envoy-admin-ports has invalid port %q", port)
+			}
+		}
+	}
 	return nil
 }
 
+// envoyAdminAddrs returns the local addresses of the comma separated Envoy
+// admin API ports.
+func envoyAdminAddrs(ports string) []string {
+	var addrs []string
+	for _, port := range strings.Split(ports, ",") {
+		addrs = append(addrs, fmt.Sprintf("127.0.0.1:%s", strings.TrimSpace(port)))
+	}
+	return addrs
+}
+
 // non2xxCode returns true if code is not in the range of 200-299 inclusive.
 func non2xxCode(code int) bool {
 	return code < 200 || code >= 300
@@ -418,6 +599,10 @@ const help = `
 Usage: consul-k8s-control-plane consul-sidecar [options]
 
   Run as a sidecar to your Connect service. Ensures that your service
-  is registered with the local Consul client.
+  is registered with the local Consul client, serves the merged
+  metrics of Envoy and your service, and watches whether Envoy is
+  connected to xDS. In Job pods, it stops Envoy once the application
+  containers have exited. For mesh gateways, it can keep the registered
+  WAN address in sync with the ingress of their LoadBalancer service.
 
 `
@@ -370,7 +370,79 @@ func TestRun_FlagValidation(t *testing.T) {
 				"-enable-service-registration=false",
 				"-enable-metrics-merging=false",
 			},
-			ExpErr: " at least one of -enable-service-registration or -enable-metrics-merging must be true",
+			ExpErr: " at least one of -enable-service-registration, -enable-metrics-merging, -enable-xds-watchdog or -enable-job-completion must be true",
+		},
+		{
+			Flags: []string{
+				"-enable-service-registration=false",
+				"-enable-xds-watchdog=true",
+				"-xds-watchdog-threshold=0s",
+			},
+			ExpErr: "-xds-watchdog-threshold must be greater than 0",
+		},
+		{
+			Flags: []string{
+				"-enable-service-registration=false",
+				"-enable-xds-watchdog=true",
+				"-xds-watchdog-policy=evict",
+			},
+			ExpErr: `-xds-watchdog-policy must be "log" or "restart"`,
+		},
+		{
+			Flags: []string{
+				"-enable-service-registration=false",
+				"-enable-xds-watchdog=true",
+				"-xds-watchdog-max-restarts=0",
+			},
+			ExpErr: "-xds-watchdog-max-restarts must be greater than 0",
+		},
+		{
+			Flags: []string{
+				"-enable-service-registration=false",
+				"-enable-xds-watchdog=true",
+				"-xds-watchdog-envoy-admin-ports=19000,admin",
+			},
+			ExpErr: `-xds-watchdog-envoy-admin-ports has invalid port "admin"`,
+		},
+		{
+			Flags: []string{
+				"-enable-service-registration=false",
+				"-enable-job-completion=true",
+				"-job-completion-interval=0s",
+			},
+			ExpErr: "-job-completion-interval must be greater than 0",
+		},
+		{
+			Flags: []string{
+				"-enable-service-registration=false",
+				"-enable-job-completion=true",
+				"-job-completion-envoy-admin-ports=19000,admin",
+			},
+			ExpErr: `-job-completion-envoy-admin-ports has invalid port "admin"`,
+		},
+		{
+			Flags: []string{
+				"-enable-service-registration=false",
+				"-enable-metrics-merging=true",
+				"-wan-address-service=consul-mesh-gateway",
+			},
+			ExpErr: "-wan-address-service requires -enable-service-registration",
+		},
+		{
+			Flags: []string{
+				"-service-config=/config.hcl",
+				"-wan-address-service=consul-mesh-gateway",
+			},
+			ExpErr: "-wan-address-namespace must be set with -wan-address-service",
+		},
+		{
+			Flags: []string{
+				"-service-config=/config.hcl",
+				"-wan-address-service=consul-mesh-gateway",
+				"-wan-address-namespace=default",
+				"-wan-address-interval=0s",
+			},
+			ExpErr: "-wan-address-interval must be greater than 0",
 		},
 	}
 
@@ -0,0 +1,117 @@
+package consulsidecar
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// sidecarProcessNames are the names of the processes of the pod that are not
+// application processes: the pause process of the pod sandbox and the
+// injected proxies. The consul sidecar itself is recognized by its PID.
+var sidecarProcessNames = map[string]bool{
+	"pause":            true,
+	"envoy":            true,
+	"consul-dataplane": true,
+}
+
+// jobCompletion stops the Envoy proxies of a Job pod once its application
+// containers have exited, so that the pod completes instead of running
+// forever. It requires the pod to share its process namespace, so that the
+// processes of all containers are visible in procDir.
+//
+// The kubelet starts the containers of a pod in order and the consul sidecar
+// is the last one, so the application containers have been started by the
+// time it runs and it doesn't need to wait for their processes to appear.
+//
+// It is only enabled for pods with the Never restart policy. Otherwise the
+// kubelet restarts failed application containers, and no application process
+// runs until it does, which this cannot tell apart from completion.
+type jobCompletion struct {
+	// procDir is the proc filesystem, e.g. "/proc". It is overridden in tests.
+	procDir string
+	// selfPID is the PID of the consul sidecar.
+	selfPID int
+	// adminAddrs are the addresses of the admin APIs of the Envoy proxies,
+	// e.g. "127.0.0.1:19000".
+	adminAddrs []string
+	client     *http.Client
+	logger     hclog.Logger
+}
+
+// run checks for application processes every interval. Once there are none
+// left it stops the proxies and returns. It also returns when the context is
+// cancelled.
+func (j *jobCompletion) run(ctx context.Context, interval time.Duration) {
+	for {
+		running, err := j.applicationRunning()
+		if err != nil {
+			j.logger.Warn("unable to list the processes of the pod", "err", err)
+		} else if !running {
+			j.logger.Info("application containers have exited, stopping Envoy")
+			j.stopProxies(ctx)
+			return
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// applicationRunning returns true if any process of the pod other than the
+// pause process, the proxies and the consul sidecar is running.
+func (j *jobCompletion) applicationRunning() (bool, error) {
+	entries, err := ioutil.ReadDir(j.procDir)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() || pid == j.selfPID {
+			continue
+		}
+		comm, err := ioutil.ReadFile(filepath.Join(j.procDir, entry.Name(), "comm"))
+		if err != nil {
+			// The process exited since the directory was read.
+			continue
+		}
+		if !sidecarProcessNames[strings.TrimSpace(string(comm))] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// stopProxies makes each Envoy proxy exit with status 0.
+func (j *jobCompletion) stopProxies(ctx context.Context) {
+	for _, addr := range j.adminAddrs {
+		if err := j.quit(ctx, addr); err != nil {
+			j.logger.Error("unable to stop Envoy", "envoy-admin", addr, "err", err)
+		}
+	}
+}
+
+func (j *jobCompletion) quit(ctx context.Context, addr string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://%s/quitquitquit", addr), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if non2xxCode(resp.StatusCode) {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package consulsidecar
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobCompletion_applicationRunning(t *testing.T) {
+	cases := map[string]struct {
+		processes map[string]string
+		exp       bool
+	}{
+		"application running": {
+			processes: map[string]string{"1": "pause", "7": "python3", "12": "envoy", "20": "consul-k8s-cont"},
+			exp:       true,
+		},
+		"application exited": {
+			processes: map[string]string{"1": "pause", "12": "envoy", "20": "consul-k8s-cont"},
+			exp:       false,
+		},
+		"consul-dataplane": {
+			processes: map[string]string{"1": "pause", "12": "consul-dataplane", "13": "envoy", "20": "consul-k8s-cont"},
+			exp:       false,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			j := &jobCompletion{procDir: fakeProcDir(t, c.processes), selfPID: 20}
+			running, err := j.applicationRunning()
+			require.NoError(t, err)
+			require.Equal(t, c.exp, running)
+		})
+	}
+}
+
+func TestJobCompletion_run(t *testing.T) {
+	envoy := &fakeEnvoyAdmin{connected: true, ready: true}
+	srv := httptest.NewServer(envoy)
+	defer srv.Close()
+
+	procDir := fakeProcDir(t, map[string]string{"1": "pause", "7": "python3", "12": "envoy", "20": "consul-k8s-cont"})
+	j := &jobCompletion{
+		procDir:    procDir,
+		selfPID:    20,
+		adminAddrs: []string{strings.TrimPrefix(srv.URL, "http://")},
+		client:     &http.Client{Timeout: 5 * time.Second},
+		logger:     hclog.NewNullLogger(),
+	}
+	done := make(chan struct{})
+	go func() {
+		j.run(context.Background(), 10*time.Millisecond)
+		close(done)
+	}()
+
+	// Envoy keeps running while the application does.
+	time.Sleep(50 * time.Millisecond)
+	envoy.mu.Lock()
+	require.Equal(t, 0, envoy.quits)
+	envoy.mu.Unlock()
+
+	require.NoError(t, os.RemoveAll(filepath.Join(procDir, "7")))
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the proxies to be stopped")
+	}
+	envoy.mu.Lock()
+	defer envoy.mu.Unlock()
+	require.Equal(t, 1, envoy.quits)
+}
+
+// fakeProcDir returns a directory laid out like the proc filesystem with the
+// processes, which map PIDs to their names.
+func fakeProcDir(t *testing.T, processes map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for pid, name := range processes {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, pid), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, pid, "comm"), []byte(name+"\n"), 0644))
+	}
+	// Files in the proc filesystem that aren't processes are skipped.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "uptime"), []byte("1.00 1.00\n"), 0644))
+	return dir
+}
@@ -0,0 +1,151 @@
+package consulsidecar
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// wanAddressRegexp matches the address of the wan tagged address in a service
+// config file, e.g. `wan { address = "1.2.3.4"`. The address is the second
+// submatch.
+var wanAddressRegexp = regexp.MustCompile(`(wan\s*\{\s*address\s*=\s*)"([^"]*)"`)
+
+// wanAddressWatcher keeps the WAN address in the service config of a mesh
+// gateway in sync with the ingress of its LoadBalancer service. Load
+// balancers may get their address only after the gateway started, and the
+// IPs behind their hostnames may change over time, so the address is checked
+// periodically rather than once at startup.
+type wanAddressWatcher struct {
+	k8sClient        kubernetes.Interface
+	namespace        string
+	serviceName      string
+	resolveHostnames bool
+	// serviceConfig is the path to the service config file whose WAN address
+	// is updated.
+	serviceConfig string
+	logger        hclog.Logger
+	// lookupIP resolves hostnames. It is overridden in tests.
+	lookupIP func(host string) ([]net.IP, error)
+}
+
+// run checks the WAN address every interval until the context is cancelled.
+// After the service config is updated, a value is sent on changed so that
+// the service is registered again right away.
+func (w *wanAddressWatcher) run(ctx context.Context, interval time.Duration, changed chan<- struct{}) {
+	for {
+		updated, err := w.check(ctx)
+		if err != nil {
+			w.logger.Warn("unable to update the WAN address", "err", err)
+		}
+		if updated {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check reads the ingress of the service and writes its address to the
+// service config if it is different from the current WAN address. It
+// returns whether the service config was updated.
+func (w *wanAddressWatcher) check(ctx context.Context) (bool, error) {
+	svc, err := w.k8sClient.CoreV1().Services(w.namespace).Get(ctx, w.serviceName, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("getting service %s: %s", w.serviceName, err)
+	}
+	address, err := w.ingressAddress(svc)
+	if err != nil {
+		return false, err
+	}
+
+	config, err := ioutil.ReadFile(w.serviceConfig)
+	if err != nil {
+		return false, err
+	}
+	match := wanAddressRegexp.FindSubmatch(config)
+	if match == nil {
+		return false, fmt.Errorf("service config %s has no wan tagged address", w.serviceConfig)
+	}
+	if string(match[2]) == address {
+		return false, nil
+	}
+
+	updated := wanAddressRegexp.ReplaceAll(config, []byte(fmt.Sprintf(`${1}"%s"`, address)))
+	if err := writeFileAtomic(w.serviceConfig, updated); err != nil {
+		return false, err
+	}
+	w.logger.Info("updated the WAN address", "previous", string(match[2]), "address", address)
+	return true, nil
+}
+
+// ingressAddress returns the IP or hostname of the first ingress of the load
+// balancer. Hostnames are resolved to their first IPv4 address if
+// resolveHostnames is set.
+func (w *wanAddressWatcher) ingressAddress(svc *corev1.Service) (string, error) {
+	if svc.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		return "", fmt.Errorf("service %s is of type %s, not %s", svc.Name, svc.Spec.Type, corev1.ServiceTypeLoadBalancer)
+	}
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			return ingress.IP, nil
+		}
+		if ingress.Hostname == "" {
+			continue
+		}
+		if !w.resolveHostnames {
+			return ingress.Hostname, nil
+		}
+		ips, err := w.lookupIP(ingress.Hostname)
+		if err != nil {
+			return "", fmt.Errorf("unable to resolve hostname %q: %s", ingress.Hostname, err)
+		}
+		for _, ip := range ips {
+			if ip.To4() != nil {
+				return ip.String(), nil
+			}
+		}
+		return "", fmt.Errorf("hostname %q had no ipv4 IPs", ingress.Hostname)
+	}
+	return "", fmt.Errorf("service %s has no ingress IP or hostname", svc.Name)
+}
+
+// writeFileAtomic replaces the file so that the consul binary never reads a
+// partially written service config.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if info, err := os.Stat(path); err == nil {
+		if err := tmp.Chmod(info.Mode()); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
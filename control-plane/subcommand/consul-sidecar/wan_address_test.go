@@ -0,0 +1,186 @@
+package consulsidecar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const meshGatewayServiceConfig = `service {
+  kind = "mesh-gateway"
+  name = "mesh-gateway"
+  port = 8443
+  address = "10.0.0.5"
+  tagged_addresses {
+    lan {
+      address = "10.0.0.5"
+      port = 8443
+    }
+    wan {
+      address = "%s"
+      port = 443
+    }
+  }
+}
+`
+
+func TestWANAddressWatcher_check(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		serviceType      corev1.ServiceType
+		ingress          []corev1.LoadBalancerIngress
+		resolveHostnames bool
+		current          string
+		expAddress       string
+		expUpdated       bool
+		expErr           string
+	}{
+		"ingress IP": {
+			ingress:    []corev1.LoadBalancerIngress{{IP: "1.2.3.4"}},
+			current:    "10.0.0.5",
+			expAddress: "1.2.3.4",
+			expUpdated: true,
+		},
+		"unchanged": {
+			ingress:    []corev1.LoadBalancerIngress{{IP: "1.2.3.4"}},
+			current:    "1.2.3.4",
+			expAddress: "1.2.3.4",
+		},
+		"ingress hostname": {
+			ingress:    []corev1.LoadBalancerIngress{{Hostname: "gateway.elb.example.com"}},
+			current:    "1.2.3.4",
+			expAddress: "gateway.elb.example.com",
+			expUpdated: true,
+		},
+		"resolved ingress hostname": {
+			ingress:          []corev1.LoadBalancerIngress{{Hostname: "gateway.elb.example.com"}},
+			resolveHostnames: true,
+			current:          "1.2.3.4",
+			expAddress:       "5.6.7.8",
+			expUpdated:       true,
+		},
+		"unresolvable ingress hostname": {
+			ingress:          []corev1.LoadBalancerIngress{{Hostname: "unknown.example.com"}},
+			resolveHostnames: true,
+			current:          "1.2.3.4",
+			expAddress:       "1.2.3.4",
+			expErr:           `unable to resolve hostname "unknown.example.com": no such host`,
+		},
+		"no ingress yet": {
+			current:    "10.0.0.5",
+			expAddress: "10.0.0.5",
+			expErr:     "service consul-mesh-gateway has no ingress IP or hostname",
+		},
+		"not a load balancer": {
+			serviceType: corev1.ServiceTypeClusterIP,
+			current:     "10.0.0.5",
+			expAddress:  "10.0.0.5",
+			expErr:      "service consul-mesh-gateway is of type ClusterIP, not LoadBalancer",
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			serviceType := c.serviceType
+			if serviceType == "" {
+				serviceType = corev1.ServiceTypeLoadBalancer
+			}
+			svc := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "consul-mesh-gateway", Namespace: "default"},
+				Spec:       corev1.ServiceSpec{Type: serviceType},
+				Status:     corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{Ingress: c.ingress}},
+			}
+			configFile := filepath.Join(t.TempDir(), "service.hcl")
+			require.NoError(t, ioutil.WriteFile(configFile, []byte(serviceConfigWithWANAddress(c.current)), 0644))
+
+			w := &wanAddressWatcher{
+				k8sClient:        fake.NewSimpleClientset(svc),
+				namespace:        "default",
+				serviceName:      "consul-mesh-gateway",
+				resolveHostnames: c.resolveHostnames,
+				serviceConfig:    configFile,
+				logger:           hclog.NewNullLogger(),
+				lookupIP:         fakeLookupIP,
+			}
+			updated, err := w.check(context.Background())
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, c.expUpdated, updated)
+
+			config, err := ioutil.ReadFile(configFile)
+			require.NoError(t, err)
+			require.Equal(t, serviceConfigWithWANAddress(c.expAddress), string(config))
+			info, err := os.Stat(configFile)
+			require.NoError(t, err)
+			require.Equal(t, os.FileMode(0644), info.Mode().Perm())
+		})
+	}
+}
+
+func TestWANAddressWatcher_run(t *testing.T) {
+	t.Parallel()
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-mesh-gateway", Namespace: "default"},
+		Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+	}
+	client := fake.NewSimpleClientset(svc)
+	configFile := filepath.Join(t.TempDir(), "service.hcl")
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(serviceConfigWithWANAddress("10.0.0.5")), 0644))
+
+	w := &wanAddressWatcher{
+		k8sClient:     client,
+		namespace:     "default",
+		serviceName:   "consul-mesh-gateway",
+		serviceConfig: configFile,
+		logger:        hclog.NewNullLogger(),
+		lookupIP:      fakeLookupIP,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan struct{}, 1)
+	go w.run(ctx, 10*time.Millisecond, changed)
+
+	// The load balancer gets its address after the gateway started.
+	svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "1.2.3.4"}}
+	_, err := client.CoreV1().Services("default").UpdateStatus(ctx, svc, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the WAN address to change")
+	}
+	config, err := ioutil.ReadFile(configFile)
+	require.NoError(t, err)
+	require.Equal(t, serviceConfigWithWANAddress("1.2.3.4"), string(config))
+}
+
+func serviceConfigWithWANAddress(address string) string {
+	return fmt.Sprintf(meshGatewayServiceConfig, address)
+}
+
+func fakeLookupIP(host string) ([]net.IP, error) {
+	if host == "gateway.elb.example.com" {
+		return []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("5.6.7.8")}, nil
+	}
+	return nil, errors.New("no such host")
+}
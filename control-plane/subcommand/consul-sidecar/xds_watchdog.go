@@ -0,0 +1,330 @@
+package consulsidecar
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+const (
+	// xdsWatchdogPolicyLog only logs and reports proxies that are disconnected
+	// from xDS for longer than the threshold.
+	xdsWatchdogPolicyLog = "log"
+	// xdsWatchdogPolicyRestart also makes those proxies exit so that the kubelet
+	// restarts them and they connect to xDS again from scratch, as long as their
+	// xDS server is reachable.
+	xdsWatchdogPolicyRestart = "restart"
+
+	// xdsWatchdogMaxBackoff caps how long a proxy that was restarted must be
+	// disconnected again before it is restarted again.
+	xdsWatchdogMaxBackoff = 30 * time.Minute
+
+	// xdsClusterName is the name of the Envoy cluster of the xDS server in the
+	// bootstrap configuration that Consul generates.
+	xdsClusterName = "local_agent"
+
+	// envoyConnectedStateStat is the Envoy stat that is 1 while Envoy is
+	// connected to its xDS server.
+	envoyConnectedStateStat = "control_plane.connected_state"
+)
+
+// xdsWatchdog watches whether the Envoy proxies of the pod are connected to
+// xDS and ready, and logs or restarts the proxies that have been disconnected
+// for longer than the threshold.
+//
+// A restart only helps a proxy that is stale while its xDS server is up, so
+// proxies are only restarted if their xDS server is reachable. Each restart
+// without a reconnect in between doubles how long the proxy must be
+// disconnected before the next one, and after maxRestarts of them the proxy
+// is only logged until it reconnects.
+type xdsWatchdog struct {
+	// adminAddrs are the addresses of the admin APIs of the Envoy proxies,
+	// e.g. "127.0.0.1:19000".
+	adminAddrs  []string
+	threshold   time.Duration
+	policy      string
+	maxRestarts int
+	client      *http.Client
+	logger      hclog.Logger
+	// now returns the current time. It is overridden in tests.
+	now func() time.Time
+
+	mu      sync.Mutex
+	proxies map[string]*proxyState
+}
+
+// proxyState is what the watchdog knows about one Envoy proxy.
+type proxyState struct {
+	// disconnectedSince is when the proxy was first seen disconnected. It is
+	// zero while the proxy is connected.
+	disconnectedSince time.Time
+	// restarts is the number of times the watchdog restarted the proxy.
+	restarts int
+	// consecutiveRestarts is the number of times the watchdog restarted the
+	// proxy since it was last connected.
+	consecutiveRestarts int
+}
+
+func newXDSWatchdog(adminAddrs []string, threshold time.Duration, policy string, maxRestarts int, logger hclog.Logger) *xdsWatchdog {
+	proxies := make(map[string]*proxyState, len(adminAddrs))
+	for _, addr := range adminAddrs {
+		proxies[addr] = &proxyState{}
+	}
+	return &xdsWatchdog{
+		adminAddrs:  adminAddrs,
+		threshold:   threshold,
+		policy:      policy,
+		maxRestarts: maxRestarts,
+		client:      &http.Client{Timeout: 5 * time.Second},
+		logger:      logger,
+		now:         time.Now,
+		proxies:     proxies,
+	}
+}
+
+// run checks the proxies every interval until the context is cancelled.
+func (w *xdsWatchdog) run(ctx context.Context, interval time.Duration) {
+	for {
+		w.check(ctx)
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check checks whether each proxy is connected and acts on the proxies that
+// have been disconnected for longer than the threshold. Proxies whose admin
+// API can't be reached are skipped since they are not running, which the
+// kubelet takes care of.
+func (w *xdsWatchdog) check(ctx context.Context) {
+	for _, addr := range w.adminAddrs {
+		connected, err := w.connected(ctx, addr)
+		if err != nil {
+			w.logger.Debug("unable to check whether Envoy is connected to xDS", "envoy-admin", addr, "err", err)
+			continue
+		}
+
+		w.mu.Lock()
+		state := w.proxies[addr]
+		now := w.now()
+		if connected {
+			if !state.disconnectedSince.IsZero() {
+				w.logger.Info("Envoy reconnected to xDS", "envoy-admin", addr, "disconnected-for", now.Sub(state.disconnectedSince).String())
+			}
+			state.disconnectedSince = time.Time{}
+			state.consecutiveRestarts = 0
+			w.mu.Unlock()
+			continue
+		}
+		if state.disconnectedSince.IsZero() {
+			state.disconnectedSince = now
+		}
+		disconnectedFor := now.Sub(state.disconnectedSince)
+		consecutiveRestarts := state.consecutiveRestarts
+		w.mu.Unlock()
+
+		if disconnectedFor < w.threshold {
+			continue
+		}
+		w.logger.Warn("Envoy has been disconnected from xDS for longer than the threshold", "envoy-admin", addr,
+			"disconnected-for", disconnectedFor.String(), "threshold", w.threshold.String(), "policy", w.policy)
+		if w.policy != xdsWatchdogPolicyRestart || disconnectedFor < w.backoff(consecutiveRestarts) {
+			continue
+		}
+		if consecutiveRestarts >= w.maxRestarts {
+			w.logger.Warn("not restarting Envoy because it was restarted the maximum number of times without reconnecting",
+				"envoy-admin", addr, "max-restarts", w.maxRestarts)
+			continue
+		}
+		// If the xDS server is down, restarting the proxy only drops the
+		// configuration it still serves traffic with.
+		if err := w.xdsServerReachable(ctx, addr); err != nil {
+			w.logger.Warn("not restarting Envoy because its xDS server is unreachable", "envoy-admin", addr, "err", err)
+			continue
+		}
+		if err := w.restart(ctx, addr); err != nil {
+			w.logger.Error("unable to restart Envoy", "envoy-admin", addr, "err", err)
+			continue
+		}
+		w.mu.Lock()
+		// The restarted proxy gets the full threshold to connect again.
+		state.disconnectedSince = time.Time{}
+		state.restarts++
+		state.consecutiveRestarts++
+		w.mu.Unlock()
+	}
+}
+
+// backoff returns how long a proxy that was restarted the given number of
+// times without reconnecting must be disconnected before it is restarted.
+func (w *xdsWatchdog) backoff(consecutiveRestarts int) time.Duration {
+	backoff := w.threshold
+	for i := 0; i < consecutiveRestarts && backoff < xdsWatchdogMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > xdsWatchdogMaxBackoff {
+		return xdsWatchdogMaxBackoff
+	}
+	return backoff
+}
+
+// xdsServerReachable returns an error unless a TCP connection can be opened
+// to one of the addresses of the xDS server of the proxy.
+func (w *xdsWatchdog) xdsServerReachable(ctx context.Context, addr string) error {
+	clusters, code, err := w.get(ctx, fmt.Sprintf("http://%s/clusters", addr))
+	if err != nil {
+		return err
+	}
+	if non2xxCode(code) {
+		return fmt.Errorf("unexpected status %d getting clusters: %s", code, clusters)
+	}
+	hosts := parseClusterHosts(clusters, xdsClusterName)
+	if len(hosts) == 0 {
+		return fmt.Errorf("no hosts found for cluster %q", xdsClusterName)
+	}
+	dialer := net.Dialer{Timeout: w.client.Timeout}
+	for _, host := range hosts {
+		var conn net.Conn
+		conn, err = dialer.DialContext(ctx, "tcp", host)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+	}
+	return err
+}
+
+// parseClusterHosts returns the addresses of the hosts of the cluster in the
+// output of the /clusters endpoint of the Envoy admin API, e.g.
+// "local_agent::10.0.0.1:8502::cx_active::1".
+func parseClusterHosts(clusters []byte, cluster string) []string {
+	var hosts []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(clusters), "\n") {
+		if !strings.HasPrefix(line, cluster+"::") {
+			continue
+		}
+		// IPv6 hosts contain "::" too, so the stat and its value are split
+		// off the end.
+		host := strings.TrimPrefix(line, cluster+"::")
+		for i := 0; i < 2; i++ {
+			end := strings.LastIndex(host, "::")
+			if end < 0 {
+				host = ""
+				break
+			}
+			host = host[:end]
+		}
+		if _, _, err := net.SplitHostPort(host); err != nil || seen[host] {
+			continue
+		}
+		seen[host] = true
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// connected returns whether the proxy is connected to its xDS server and
+// ready.
+func (w *xdsWatchdog) connected(ctx context.Context, addr string) (bool, error) {
+	stats, code, err := w.get(ctx, fmt.Sprintf("http://%s/stats?filter=^%s$", addr, strings.ReplaceAll(envoyConnectedStateStat, ".", `\.`)))
+	if err != nil {
+		return false, err
+	}
+	if non2xxCode(code) {
+		return false, fmt.Errorf("unexpected status %d getting stats: %s", code, stats)
+	}
+	_, code, err = w.get(ctx, fmt.Sprintf("http://%s/ready", addr))
+	if err != nil {
+		return false, err
+	}
+	return parseConnectedState(stats) && !non2xxCode(code), nil
+}
+
+// parseConnectedState returns whether the stats of the Envoy admin API, e.g.
+// "control_plane.connected_state: 1", report that Envoy is connected.
+func parseConnectedState(stats []byte) bool {
+	for _, line := range strings.Split(string(stats), "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == envoyConnectedStateStat {
+			return strings.TrimSpace(parts[1]) == "1"
+		}
+	}
+	return false
+}
+
+// restart makes the proxy exit so that the kubelet restarts it.
+func (w *xdsWatchdog) restart(ctx context.Context, addr string) error {
+	w.logger.Info("restarting Envoy", "envoy-admin", addr)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://%s/quitquitquit", addr), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if non2xxCode(resp.StatusCode) {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (w *xdsWatchdog) get(ctx context.Context, url string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return body, resp.StatusCode, nil
+}
+
+// metrics returns the Prometheus metrics of the watchdog: whether each proxy
+// is disconnected, for how long, and how often it was restarted.
+func (w *xdsWatchdog) metrics() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	var buf bytes.Buffer
+	buf.WriteString("# TYPE consul_xds_watchdog_disconnected gauge\n")
+	for _, addr := range w.adminAddrs {
+		disconnected := 0
+		if !w.proxies[addr].disconnectedSince.IsZero() {
+			disconnected = 1
+		}
+		fmt.Fprintf(&buf, "consul_xds_watchdog_disconnected{envoy_admin=%q} %d\n", addr, disconnected)
+	}
+	buf.WriteString("# TYPE consul_xds_watchdog_disconnected_seconds gauge\n")
+	for _, addr := range w.adminAddrs {
+		seconds := 0.0
+		if since := w.proxies[addr].disconnectedSince; !since.IsZero() {
+			seconds = now.Sub(since).Seconds()
+		}
+		fmt.Fprintf(&buf, "consul_xds_watchdog_disconnected_seconds{envoy_admin=%q} %g\n", addr, seconds)
+	}
+	buf.WriteString("# TYPE consul_xds_watchdog_restarts_total counter\n")
+	for _, addr := range w.adminAddrs {
+		fmt.Fprintf(&buf, "consul_xds_watchdog_restarts_total{envoy_admin=%q} %d\n", addr, w.proxies[addr].restarts)
+	}
+	return buf.Bytes()
+}
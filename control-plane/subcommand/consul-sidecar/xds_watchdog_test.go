@@ -0,0 +1,254 @@
+package consulsidecar
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEnvoyAdmin serves the parts of the Envoy admin API that the xDS watchdog
+// uses.
+type fakeEnvoyAdmin struct {
+	mu        sync.Mutex
+	connected bool
+	ready     bool
+	quits     int
+	// xdsAddr is the address of the host of the xDS cluster.
+	xdsAddr string
+}
+
+func (f *fakeEnvoyAdmin) set(connected, ready bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.connected = connected
+	f.ready = ready
+}
+
+func (f *fakeEnvoyAdmin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.URL.Path == "/stats" && r.URL.Query().Get("filter") == `^control_plane\.connected_state$`:
+		state := 0
+		if f.connected {
+			state = 1
+		}
+		fmt.Fprintf(w, "control_plane.connected_state: %d\n", state)
+	case r.URL.Path == "/ready":
+		if f.ready {
+			w.Write([]byte("LIVE\n"))
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("PRE_INITIALIZING\n"))
+		}
+	case r.URL.Path == "/clusters":
+		fmt.Fprintf(w, "local_agent::default_priority::max_connections::1024\n")
+		fmt.Fprintf(w, "local_agent::%s::cx_active::0\n", f.xdsAddr)
+		fmt.Fprintf(w, "local_agent::%s::health_flags::healthy\n", f.xdsAddr)
+	case r.URL.Path == "/quitquitquit" && r.Method == http.MethodPost:
+		f.quits++
+		w.Write([]byte("OK\n"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestXDSWatchdog(t *testing.T) {
+	cases := map[string]struct {
+		policy      string
+		expQuits    int
+		expRestarts int
+	}{
+		"log": {
+			policy: xdsWatchdogPolicyLog,
+		},
+		"restart": {
+			policy:      xdsWatchdogPolicyRestart,
+			expQuits:    1,
+			expRestarts: 1,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			xds := httptest.NewServer(http.NotFoundHandler())
+			defer xds.Close()
+			envoy := &fakeEnvoyAdmin{connected: true, ready: true, xdsAddr: strings.TrimPrefix(xds.URL, "http://")}
+			srv := httptest.NewServer(envoy)
+			defer srv.Close()
+			addr := strings.TrimPrefix(srv.URL, "http://")
+
+			now := time.Now()
+			w := newXDSWatchdog([]string{addr}, time.Minute, c.policy, 3, hclog.NewNullLogger())
+			w.now = func() time.Time { return now }
+			ctx := context.Background()
+
+			w.check(ctx)
+			require.True(t, w.proxies[addr].disconnectedSince.IsZero())
+
+			// The proxy is disconnected but not for longer than the threshold.
+			envoy.set(false, true)
+			w.check(ctx)
+			require.Equal(t, now, w.proxies[addr].disconnectedSince)
+			now = now.Add(30 * time.Second)
+			w.check(ctx)
+			require.Equal(t, 0, envoy.quits)
+			require.Contains(t, string(w.metrics()), fmt.Sprintf("consul_xds_watchdog_disconnected{envoy_admin=%q} 1\n", addr))
+			require.Contains(t, string(w.metrics()), fmt.Sprintf("consul_xds_watchdog_disconnected_seconds{envoy_admin=%q} 30\n", addr))
+
+			// The proxy has been disconnected for longer than the threshold.
+			now = now.Add(30 * time.Second)
+			w.check(ctx)
+			require.Equal(t, c.expQuits, envoy.quits)
+			require.Equal(t, c.expRestarts, w.proxies[addr].restarts)
+			require.Contains(t, string(w.metrics()), fmt.Sprintf("consul_xds_watchdog_restarts_total{envoy_admin=%q} %d\n", addr, c.expRestarts))
+
+			// A proxy that is connected but not ready is disconnected too.
+			envoy.set(true, false)
+			now = now.Add(time.Second)
+			w.check(ctx)
+			require.False(t, w.proxies[addr].disconnectedSince.IsZero())
+
+			// The proxy reconnects.
+			envoy.set(true, true)
+			w.check(ctx)
+			require.True(t, w.proxies[addr].disconnectedSince.IsZero())
+			require.Contains(t, string(w.metrics()), fmt.Sprintf("consul_xds_watchdog_disconnected{envoy_admin=%q} 0\n", addr))
+		})
+	}
+}
+
+// Test that proxies whose admin API can't be reached are not considered
+// disconnected since they are not running.
+func TestXDSWatchdog_AdminUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	addr := strings.TrimPrefix(srv.URL, "http://")
+	srv.Close()
+
+	w := newXDSWatchdog([]string{addr}, time.Nanosecond, xdsWatchdogPolicyRestart, 3, hclog.NewNullLogger())
+	w.check(context.Background())
+	require.True(t, w.proxies[addr].disconnectedSince.IsZero())
+	require.Equal(t, 0, w.proxies[addr].restarts)
+}
+
+// Test that proxies are not restarted while their xDS server is down, since
+// they would lose the configuration they still serve traffic with.
+func TestXDSWatchdog_XDSServerUnreachable(t *testing.T) {
+	xds := httptest.NewServer(http.NotFoundHandler())
+	xdsAddr := strings.TrimPrefix(xds.URL, "http://")
+	xds.Close()
+	envoy := &fakeEnvoyAdmin{xdsAddr: xdsAddr}
+	srv := httptest.NewServer(envoy)
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	now := time.Now()
+	w := newXDSWatchdog([]string{addr}, time.Minute, xdsWatchdogPolicyRestart, 3, hclog.NewNullLogger())
+	w.now = func() time.Time { return now }
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		w.check(ctx)
+		now = now.Add(time.Minute)
+	}
+	require.Equal(t, 0, envoy.quits)
+	require.Equal(t, 0, w.proxies[addr].restarts)
+
+	// The proxy is restarted once the xDS server is back.
+	xds = httptest.NewUnstartedServer(http.NotFoundHandler())
+	listener, err := net.Listen("tcp", xdsAddr)
+	require.NoError(t, err)
+	xds.Listener = listener
+	xds.Start()
+	defer xds.Close()
+	w.check(ctx)
+	require.Equal(t, 1, envoy.quits)
+}
+
+// Test that a proxy that doesn't reconnect is restarted with exponential
+// backoff up to the maximum number of restarts, and that reconnecting resets
+// both.
+func TestXDSWatchdog_BackoffAndMaxRestarts(t *testing.T) {
+	xds := httptest.NewServer(http.NotFoundHandler())
+	defer xds.Close()
+	envoy := &fakeEnvoyAdmin{xdsAddr: strings.TrimPrefix(xds.URL, "http://")}
+	srv := httptest.NewServer(envoy)
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	now := time.Now()
+	w := newXDSWatchdog([]string{addr}, time.Minute, xdsWatchdogPolicyRestart, 3, hclog.NewNullLogger())
+	w.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// checkAfter starts the disconnection of the proxy, which starts again
+	// after each restart, and checks it again after d.
+	checkAfter := func(d time.Duration) {
+		w.check(ctx)
+		now = now.Add(d)
+		w.check(ctx)
+	}
+	checkAfter(time.Minute)
+	require.Equal(t, 1, envoy.quits)
+
+	// The second restart waits for twice the threshold.
+	checkAfter(time.Minute)
+	require.Equal(t, 1, envoy.quits)
+	now = now.Add(time.Minute)
+	w.check(ctx)
+	require.Equal(t, 2, envoy.quits)
+
+	// The third restart waits for four times the threshold.
+	checkAfter(4*time.Minute - time.Second)
+	require.Equal(t, 2, envoy.quits)
+	now = now.Add(time.Second)
+	w.check(ctx)
+	require.Equal(t, 3, envoy.quits)
+
+	// The proxy is not restarted again until it reconnects.
+	checkAfter(time.Hour)
+	require.Equal(t, 3, envoy.quits)
+	require.Equal(t, 3, w.proxies[addr].restarts)
+
+	envoy.set(true, true)
+	w.check(ctx)
+	require.Equal(t, 0, w.proxies[addr].consecutiveRestarts)
+	envoy.set(false, false)
+	checkAfter(time.Minute)
+	require.Equal(t, 4, envoy.quits)
+	require.Equal(t, 4, w.proxies[addr].restarts)
+}
+
+func TestXDSWatchdog_backoff(t *testing.T) {
+	w := newXDSWatchdog(nil, time.Minute, xdsWatchdogPolicyRestart, 3, hclog.NewNullLogger())
+	require.Equal(t, time.Minute, w.backoff(0))
+	require.Equal(t, 2*time.Minute, w.backoff(1))
+	require.Equal(t, 8*time.Minute, w.backoff(3))
+	require.Equal(t, xdsWatchdogMaxBackoff, w.backoff(10))
+	require.Equal(t, xdsWatchdogMaxBackoff, w.backoff(100))
+}
+
+func TestParseClusterHosts(t *testing.T) {
+	clusters := []byte(`local_agent::observability_name::local_agent
+local_agent::default_priority::max_connections::1024
+local_agent::10.0.0.1:8502::cx_active::1
+local_agent::10.0.0.1:8502::health_flags::healthy
+local_agent::[::1]:8502::cx_active::0
+web::10.0.0.2:20000::cx_active::1
+`)
+	require.Equal(t, []string{"10.0.0.1:8502", "[::1]:8502"}, parseClusterHosts(clusters, "local_agent"))
+	require.Empty(t, parseClusterHosts([]byte(""), "local_agent"))
+}
+
+func TestParseConnectedState(t *testing.T) {
+	require.True(t, parseConnectedState([]byte("control_plane.connected_state: 1\n")))
+	require.False(t, parseConnectedState([]byte("control_plane.connected_state: 0\n")))
+	require.False(t, parseConnectedState([]byte("")))
+}
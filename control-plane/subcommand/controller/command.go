@@ -3,19 +3,25 @@ package controller
 import (
 	"flag"
 	"fmt"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/consul-k8s/control-plane/api/common"
 	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
 	"github.com/hashicorp/consul-k8s/control-plane/controller"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/cert"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	cmdCommon "github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
 	"github.com/hashicorp/consul/api"
 	"github.com/mitchellh/cli"
 	"go.uber.org/zap/zapcore"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -29,9 +35,12 @@ type Command struct {
 	httpFlags *flags.HTTPFlags
 
 	flagWebhookTLSCertDir    string
+	flagWebhookCACertFile    string
+	flagWebhookConfigName    string
 	flagEnableLeaderElection bool
 	flagEnableWebhooks       bool
 	flagDatacenter           string
+	flagClusterID            string
 	flagLogLevel             string
 	flagLogJSON              bool
 
@@ -40,8 +49,36 @@ type Command struct {
 	flagConsulDestinationNamespace string
 	flagEnableNSMirroring          bool
 	flagNSMirroringPrefix          string
+	flagNSMirroringRules           string
 	flagCrossNSACLPolicy           string
 
+	// Flags to sync the gossip encryption keyring with a Kubernetes secret.
+	flagGossipKeySecretName      string
+	flagGossipKeySecretNamespace string
+	flagGossipKeySecretKey       string
+	flagGossipKeyringSyncPeriod  time.Duration
+
+	// Flags to register the external services described by a ConfigMap.
+	flagExternalServicesConfigMapName      string
+	flagExternalServicesConfigMapNamespace string
+	flagExternalServicesNodeName           string
+	flagExternalServicesSyncPeriod         time.Duration
+	flagExternalWorkloadsNodeName          string
+
+	// Flag to take the snapshots of SnapshotSchedule resources.
+	flagSnapshotPodTemplateName string
+
+	// Flag to mount the TLS Secrets of TerminatingGateway resources.
+	flagTerminatingGatewayNamespace string
+
+	// Flag to set the number of workers of config entry kinds.
+	flagConfigEntryWorkers flags.FlagMapValue
+
+	// Flags for the audit trail of the writes to Consul.
+	flagEnableAudit     bool
+	flagAuditEvents     bool
+	flagAuditWebhookURL string
+
 	once sync.Once
 	help string
 }
@@ -64,6 +101,9 @@ func (c *Command) init() {
 			"Enabling this will ensure there is only one active controller manager.")
 	c.flagSet.StringVar(&c.flagDatacenter, "datacenter", "",
 		"Name of the Consul datacenter the controller is operating in. This is added as metadata on managed custom resources.")
+	c.flagSet.StringVar(&c.flagClusterID, "cluster-id", "",
+		"ID of the Kubernetes cluster the controller is operating in, when several clusters manage config entries in the same Consul datacenter. "+
+			"This is added as metadata on managed config entries, and config entries of other clusters are not overwritten or deleted.")
 	c.flagSet.BoolVar(&c.flagEnableNamespaces, "enable-namespaces", false,
 		"[Enterprise Only] Enables Consul Enterprise namespaces, in either a single Consul namespace or mirrored.")
 	c.flagSet.StringVar(&c.flagConsulDestinationNamespace, "consul-destination-namespace", "default",
@@ -73,11 +113,58 @@ func (c *Command) init() {
 		"k8s namespace mirroring.")
 	c.flagSet.StringVar(&c.flagNSMirroringPrefix, "k8s-namespace-mirroring-prefix", "",
 		"[Enterprise Only] Prefix that will be added to all k8s namespaces mirrored into Consul if mirroring is enabled.")
+	c.flagSet.StringVar(&c.flagNSMirroringRules, "k8s-namespace-mirroring-rules", "",
+		"[Enterprise Only] JSON list of rules that rewrite the names of k8s namespaces mirrored into Consul, e.g. "+
+			"'[{\"match\": \"team-(.*)\", \"replace\": \"$1\"}]'. The first rule whose regular expression matches the whole "+
+			"namespace name is applied before the mirroring prefix is added.")
 	c.flagSet.StringVar(&c.flagCrossNSACLPolicy, "consul-cross-namespace-acl-policy", "",
 		"[Enterprise Only] Name of the ACL policy to attach to all created Consul namespaces to allow service "+
 			"discovery across Consul namespaces. Only necessary if ACLs are enabled.")
+	c.flagSet.StringVar(&c.flagGossipKeySecretName, "gossip-key-secret-name", "",
+		"Name of the Kubernetes secret with the gossip encryption key. If set, the gossip keyring of the Consul "+
+			"cluster is kept in sync with the key in the secret, so that updating the secret rotates the key.")
+	c.flagSet.StringVar(&c.flagGossipKeySecretNamespace, "gossip-key-secret-namespace", "",
+		"Namespace of the Kubernetes secret with the gossip encryption key.")
+	c.flagSet.StringVar(&c.flagGossipKeySecretKey, "gossip-key-secret-key", "",
+		"Key within the Kubernetes secret that holds the gossip encryption key.")
+	c.flagSet.DurationVar(&c.flagGossipKeyringSyncPeriod, "gossip-keyring-sync-period", 1*time.Minute,
+		"How often the gossip keyring is synced with the Kubernetes secret.")
+	c.flagSet.StringVar(&c.flagExternalServicesConfigMapName, "external-services-config-map-name", "",
+		"Name of a ConfigMap describing services outside the cluster, such as Lambda functions, ECS tasks or VMs. "+
+			"If set, the services are registered in Consul and linked to terminating gateways.")
+	c.flagSet.StringVar(&c.flagExternalServicesConfigMapNamespace, "external-services-config-map-namespace", "",
+		"Namespace of the ConfigMap describing the external services. Their ServiceDefaults and TerminatingGateway "+
+			"resources are created in this namespace.")
+	c.flagSet.StringVar(&c.flagExternalServicesNodeName, "external-services-node-name", "k8s-external-services",
+		"Name of the Consul node that the external services are registered on.")
+	c.flagSet.DurationVar(&c.flagExternalServicesSyncPeriod, "external-services-sync-period", 30*time.Second,
+		"How often the external services are synced with the ConfigMap.")
+	c.flagSet.StringVar(&c.flagExternalWorkloadsNodeName, "external-workloads-node-name", "k8s-external-workloads",
+		"Name of the Consul node that the workloads of ExternalWorkload resources are registered on.")
+	c.flagSet.StringVar(&c.flagSnapshotPodTemplateName, "snapshot-pod-template", "",
+		"Name of the PodTemplate of the snapshot agent pods, in the namespace of each SnapshotSchedule. "+
+			"If not set, SnapshotSchedule resources are ignored.")
+	c.flagSet.StringVar(&c.flagTerminatingGatewayNamespace, "terminating-gateway-namespace", "",
+		"Namespace of the terminating gateway deployments that the Secrets referenced by the tls field of "+
+			"TerminatingGateway linked services are mounted into. If not set, the Secrets are not mounted.")
+	c.flagSet.Var(&c.flagConfigEntryWorkers, "config-entry-workers",
+		"Number of config entry resources of a kind that are reconciled concurrently, as <kind>=<workers>, "+
+			"e.g. serviceintentions=4. Kinds that are not set have one worker. Can be specified multiple times.")
+	c.flagSet.BoolVar(&c.flagEnableAudit, "enable-audit", false,
+		"Log every config entry, including intentions, and every external service the controllers write to or delete from Consul, "+
+			"with the difference to what was in Consul before, as an audit trail of their changes.")
+	c.flagSet.BoolVar(&c.flagAuditEvents, "audit-events", false,
+		"Also record the writes to Consul as Kubernetes events on the resources they are made for. Requires -enable-audit.")
+	c.flagSet.StringVar(&c.flagAuditWebhookURL, "audit-webhook-url", "",
+		"URL the writes to Consul are posted to as JSON. Requires -enable-audit.")
 	c.flagSet.StringVar(&c.flagWebhookTLSCertDir, "webhook-tls-cert-dir", "",
 		"Directory that contains the TLS cert and key required for the webhook. The cert and key files must be named 'tls.crt' and 'tls.key' respectively.")
+	c.flagSet.StringVar(&c.flagWebhookCACertFile, "webhook-ca-cert-file", "",
+		"Path to the PEM-encoded CA certificate that signed the certificate in -webhook-tls-cert-dir. If set, the caBundle of "+
+			"the webhooks on -webhook-config-name is kept in sync with it. Only needed if the certificates are not "+
+			"issued by the webhook-cert-manager.")
+	c.flagSet.StringVar(&c.flagWebhookConfigName, "webhook-config-name", "",
+		"Name of the MutatingWebhookConfiguration of the controller. Required if -webhook-ca-cert-file is set.")
 	c.flagSet.BoolVar(&c.flagEnableWebhooks, "enable-webhooks", true,
 		"Enable webhooks. Disable when running locally since Kube API server won't be able to route to local server.")
 	c.flagSet.StringVar(&c.flagLogLevel, "log-level", zapcore.InfoLevel.String(),
@@ -105,16 +192,53 @@ func (c *Command) Run(args []string) int {
 		c.UI.Error("Invalid arguments: -webhook-tls-cert-dir must be set")
 		return 1
 	}
+	if c.flagWebhookCACertFile != "" && c.flagWebhookConfigName == "" {
+		c.UI.Error("Invalid arguments: -webhook-config-name must be set if -webhook-ca-cert-file is set")
+		return 1
+	}
 	if c.flagDatacenter == "" {
 		c.UI.Error("Invalid arguments: -datacenter must be set")
 		return 1
 	}
+	if c.flagGossipKeySecretName != "" && (c.flagGossipKeySecretNamespace == "" || c.flagGossipKeySecretKey == "") {
+		c.UI.Error("Invalid arguments: -gossip-key-secret-namespace and -gossip-key-secret-key must be set with -gossip-key-secret-name")
+		return 1
+	}
+	if c.flagGossipKeySecretName != "" && c.flagGossipKeyringSyncPeriod <= 0 {
+		c.UI.Error("Invalid arguments: -gossip-keyring-sync-period must be greater than zero")
+		return 1
+	}
+	if c.flagExternalServicesConfigMapName != "" && c.flagExternalServicesConfigMapNamespace == "" {
+		c.UI.Error("Invalid arguments: -external-services-config-map-namespace must be set with -external-services-config-map-name")
+		return 1
+	}
+	if c.flagExternalServicesConfigMapName != "" && c.flagExternalServicesSyncPeriod <= 0 {
+		c.UI.Error("Invalid arguments: -external-services-sync-period must be greater than zero")
+		return 1
+	}
+	if (c.flagAuditEvents || c.flagAuditWebhookURL != "") && !c.flagEnableAudit {
+		c.UI.Error("Invalid arguments: -enable-audit must be set with -audit-events or -audit-webhook-url")
+		return 1
+	}
+	nsMirroringRules, err := namespaces.ParseMirroringRules(c.flagNSMirroringRules)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Invalid arguments: -k8s-namespace-mirroring-rules is invalid: %s", err))
+		return 1
+	}
+	configEntryWorkers, err := parseConfigEntryWorkers(c.flagConfigEntryWorkers)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Invalid arguments: -config-entry-workers is invalid: %s", err))
+		return 1
+	}
 
-	zapLogger, err := cmdCommon.ZapLogger(c.flagLogLevel, c.flagLogJSON)
+	// The log levels can be changed at runtime through /log-level on the
+	// metrics server.
+	logLevels, err := cmdCommon.NewLogLevels(c.flagLogLevel)
 	if err != nil {
 		c.UI.Error(fmt.Sprintf("Error setting up logging: %s", err.Error()))
 		return 1
 	}
+	zapLogger := logLevels.Logger(c.flagLogJSON)
 	ctrl.SetLogger(zapLogger)
 	klog.SetLogger(zapLogger)
 
@@ -146,16 +270,62 @@ func (c *Command) Run(args []string) int {
 		DestinationNamespace: c.flagConsulDestinationNamespace,
 		Mirroring:            c.flagEnableNSMirroring,
 		Prefix:               c.flagNSMirroringPrefix,
+		MirroringRules:       nsMirroringRules,
+	}
+	// Fields of config entries that need a newer version of Consul are rejected at admission
+	// rather than failing to sync. Consul may not be reachable yet, in which case they aren't.
+	if consulMeta.ConsulVersion, err = consul.AgentVersion(consulClient); err != nil {
+		setupLog.Info("unable to get the Consul version, config entry fields won't be checked against it", "err", err.Error())
+	}
+
+	consulHealth := &consul.HealthTracker{
+		Client:    consulClient,
+		Component: "controller",
+		Log:       ctrl.Log.WithName("consul-health"),
+	}
+	if err = mgr.Add(consulHealth); err != nil {
+		setupLog.Error(err, "unable to add Consul health tracker")
+		return 1
+	}
+	var audit *consul.AuditTrail
+	if c.flagEnableAudit {
+		audit = &consul.AuditTrail{
+			Component:  "controller",
+			Log:        ctrl.Log.WithName("audit"),
+			WebhookURL: c.flagAuditWebhookURL,
+		}
+		if c.flagAuditEvents {
+			audit.Recorder = mgr.GetEventRecorderFor("controller")
+		}
+		if err = mgr.Add(audit); err != nil {
+			setupLog.Error(err, "unable to add audit trail")
+			return 1
+		}
+	}
+	if err = mgr.AddMetricsExtraHandler("/consul-status", consulHealth); err != nil {
+		setupLog.Error(err, "unable to add Consul status handler")
+		return 1
+	}
+	if err = mgr.AddMetricsExtraHandler("/log-level", logLevels); err != nil {
+		setupLog.Error(err, "unable to add log level handler")
+		return 1
 	}
 
 	configEntryReconciler := &controller.ConfigEntryController{
 		ConsulClient:               consulClient,
 		DatacenterName:             c.flagDatacenter,
+		ClusterID:                  c.flagClusterID,
+		Recorder:                   mgr.GetEventRecorderFor("config-entry-controller"),
 		EnableConsulNamespaces:     c.flagEnableNamespaces,
 		ConsulDestinationNamespace: c.flagConsulDestinationNamespace,
 		EnableNSMirroring:          c.flagEnableNSMirroring,
 		NSMirroringPrefix:          c.flagNSMirroringPrefix,
+		NSMirroringRules:           nsMirroringRules,
 		CrossNSACLPolicy:           c.flagCrossNSACLPolicy,
+		ConsulHealth:               consulHealth,
+		Audit:                      audit,
+		MaxConcurrentReconciles:    configEntryWorkers,
+		Reader:                     mgr.GetClient(),
 	}
 	if err = (&controller.ServiceDefaultsController{
 		ConfigEntryController: configEntryReconciler,
@@ -202,6 +372,15 @@ func (c *Command) Run(args []string) int {
 		setupLog.Error(err, "unable to create controller", "controller", common.ExportedServices)
 		return 1
 	}
+	if err = (&controller.JWTProviderController{
+		ConfigEntryController: configEntryReconciler,
+		Client:                mgr.GetClient(),
+		Log:                   ctrl.Log.WithName("controller").WithName(common.JWTProvider),
+		Scheme:                mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", common.JWTProvider)
+		return 1
+	}
 	if err = (&controller.ServiceRouterController{
 		ConfigEntryController: configEntryReconciler,
 		Client:                mgr.GetClient(),
@@ -243,16 +422,100 @@ func (c *Command) Run(args []string) int {
 		Client:                mgr.GetClient(),
 		Log:                   ctrl.Log.WithName("controller").WithName(common.TerminatingGateway),
 		Scheme:                mgr.GetScheme(),
+		GatewayNamespace:      c.flagTerminatingGatewayNamespace,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", common.TerminatingGateway)
 		return 1
 	}
+	if err = (&controller.ExternalWorkloadController{
+		Client:                     mgr.GetClient(),
+		ConsulClient:               consulClient,
+		Log:                        ctrl.Log.WithName("controller").WithName("external-workload"),
+		NodeName:                   c.flagExternalWorkloadsNodeName,
+		EnableConsulNamespaces:     c.flagEnableNamespaces,
+		ConsulDestinationNamespace: c.flagConsulDestinationNamespace,
+		EnableNSMirroring:          c.flagEnableNSMirroring,
+		NSMirroringPrefix:          c.flagNSMirroringPrefix,
+		NSMirroringRules:           nsMirroringRules,
+		CrossNSACLPolicy:           c.flagCrossNSACLPolicy,
+		Audit:                      audit,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "external-workload")
+		return 1
+	}
+
+	if c.flagSnapshotPodTemplateName != "" {
+		if err = (&controller.SnapshotScheduleController{
+			Client:          mgr.GetClient(),
+			Log:             ctrl.Log.WithName("controller").WithName("snapshot-schedule"),
+			Recorder:        mgr.GetEventRecorderFor("snapshot-schedule-controller"),
+			PodTemplateName: c.flagSnapshotPodTemplateName,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "snapshot-schedule")
+			return 1
+		}
+	}
+
+	if c.flagGossipKeySecretName != "" {
+		err = mgr.Add(&controller.GossipKeyringController{
+			Client:       mgr.GetAPIReader(),
+			ConsulClient: consulClient,
+			Log:          ctrl.Log.WithName("controller").WithName("gossip-keyring"),
+			Secret: types.NamespacedName{
+				Name:      c.flagGossipKeySecretName,
+				Namespace: c.flagGossipKeySecretNamespace,
+			},
+			SecretKey:    c.flagGossipKeySecretKey,
+			SyncInterval: c.flagGossipKeyringSyncPeriod,
+		})
+		if err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "gossip-keyring")
+			return 1
+		}
+	}
+
+	if c.flagExternalServicesConfigMapName != "" {
+		err = mgr.Add(&controller.ExternalServicesController{
+			Client:          mgr.GetClient(),
+			ConfigMapReader: mgr.GetAPIReader(),
+			ConsulClient:    consulClient,
+			Log:             ctrl.Log.WithName("controller").WithName("external-services"),
+			ConfigMap: types.NamespacedName{
+				Name:      c.flagExternalServicesConfigMapName,
+				Namespace: c.flagExternalServicesConfigMapNamespace,
+			},
+			NodeName:     c.flagExternalServicesNodeName,
+			SyncInterval: c.flagExternalServicesSyncPeriod,
+			Audit:        audit,
+		})
+		if err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "external-services")
+			return 1
+		}
+	}
 
 	if c.flagEnableWebhooks {
 		// This webhook server sets up a Cert Watcher on the CertDir. This watches for file changes and updates the webhook certificates
 		// automatically when new certificates are available.
 		mgr.GetWebhookServer().CertDir = c.flagWebhookTLSCertDir
 
+		if c.flagWebhookCACertFile != "" {
+			clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+			if err != nil {
+				setupLog.Error(err, "unable to create Kubernetes client")
+				return 1
+			}
+			if err = mgr.Add(&cert.CABundleUpdater{
+				Clientset:         clientset,
+				Log:               ctrl.Log.WithName("webhook-ca-bundle"),
+				WebhookConfigName: c.flagWebhookConfigName,
+				CAFile:            c.flagWebhookCACertFile,
+			}); err != nil {
+				setupLog.Error(err, "unable to add webhook caBundle updater")
+				return 1
+			}
+		}
+
 		// Note: The path here should be identical to the one on the kubebuilder
 		// annotation in each webhook file.
 		mgr.GetWebhookServer().Register("/mutate-v1alpha1-servicedefaults",
@@ -289,6 +552,13 @@ func (c *Command) Run(args []string) int {
 				Logger:       ctrl.Log.WithName("webhooks").WithName(common.ExportedServices),
 				ConsulMeta:   consulMeta,
 			}})
+		mgr.GetWebhookServer().Register("/mutate-v1alpha1-jwtprovider",
+			&webhook.Admission{Handler: &v1alpha1.JWTProviderWebhook{
+				Client:       mgr.GetClient(),
+				ConsulClient: consulClient,
+				Logger:       ctrl.Log.WithName("webhooks").WithName(common.JWTProvider),
+				ConsulMeta:   consulMeta,
+			}})
 		mgr.GetWebhookServer().Register("/mutate-v1alpha1-servicerouter",
 			&webhook.Admission{Handler: &v1alpha1.ServiceRouterWebhook{
 				Client:       mgr.GetClient(),
@@ -319,10 +589,11 @@ func (c *Command) Run(args []string) int {
 			}})
 		mgr.GetWebhookServer().Register("/mutate-v1alpha1-terminatinggateway",
 			&webhook.Admission{Handler: &v1alpha1.TerminatingGatewayWebhook{
-				Client:       mgr.GetClient(),
-				ConsulClient: consulClient,
-				Logger:       ctrl.Log.WithName("webhooks").WithName(common.TerminatingGateway),
-				ConsulMeta:   consulMeta,
+				Client:           mgr.GetClient(),
+				ConsulClient:     consulClient,
+				Logger:           ctrl.Log.WithName("webhooks").WithName(common.TerminatingGateway),
+				ConsulMeta:       consulMeta,
+				GatewayNamespace: c.flagTerminatingGatewayNamespace,
 			}})
 	}
 	// +kubebuilder:scaffold:builder
@@ -335,6 +606,36 @@ func (c *Command) Run(args []string) int {
 	return 0
 }
 
+// parseConfigEntryWorkers parses the number of workers of each config entry
+// kind from the -config-entry-workers flag.
+func parseConfigEntryWorkers(values flags.FlagMapValue) (map[string]int, error) {
+	kinds := map[string]bool{
+		common.ServiceDefaults:    true,
+		common.ProxyDefaults:      true,
+		common.ServiceResolver:    true,
+		common.ServiceRouter:      true,
+		common.ServiceSplitter:    true,
+		common.ServiceIntentions:  true,
+		common.ExportedServices:   true,
+		common.IngressGateway:     true,
+		common.TerminatingGateway: true,
+		common.JWTProvider:        true,
+		common.Mesh:               true,
+	}
+	workers := make(map[string]int, len(values))
+	for kind, value := range values {
+		if !kinds[kind] {
+			return nil, fmt.Errorf("unknown config entry kind %q", kind)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("workers of %s must be a positive number, got %q", kind, value)
+		}
+		workers[kind] = n
+	}
+	return workers, nil
+}
+
 func (c *Command) Help() string {
 	c.once.Do(c.init)
 	return c.help
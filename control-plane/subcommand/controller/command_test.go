@@ -26,10 +26,48 @@ func TestRun_FlagValidation(t *testing.T) {
 			flags:  []string{"-webhook-tls-cert-dir", "/foo"},
 			expErr: "-datacenter must be set",
 		},
+		{
+			flags:  []string{"-webhook-tls-cert-dir", "/foo", "-webhook-ca-cert-file", "/foo/ca.crt"},
+			expErr: "-webhook-config-name must be set if -webhook-ca-cert-file is set",
+		},
 		{
 			flags:  []string{"-webhook-tls-cert-dir", "/foo", "-datacenter", "foo", "-log-level", "invalid"},
 			expErr: `unknown log level "invalid": unrecognized level: "invalid"`,
 		},
+		{
+			flags:  []string{"-webhook-tls-cert-dir", "/foo", "-datacenter", "foo", "-k8s-namespace-mirroring-rules", "not-json"},
+			expErr: "-k8s-namespace-mirroring-rules is invalid: unable to parse namespace mirroring rules",
+		},
+		{
+			flags:  []string{"-webhook-tls-cert-dir", "/foo", "-datacenter", "foo", "-gossip-key-secret-name", "consul-gossip-encryption-key"},
+			expErr: "-gossip-key-secret-namespace and -gossip-key-secret-key must be set with -gossip-key-secret-name",
+		},
+		{
+			flags: []string{"-webhook-tls-cert-dir", "/foo", "-datacenter", "foo", "-gossip-key-secret-name", "consul-gossip-encryption-key",
+				"-gossip-key-secret-namespace", "default", "-gossip-key-secret-key", "key", "-gossip-keyring-sync-period", "0s"},
+			expErr: "-gossip-keyring-sync-period must be greater than zero",
+		},
+		{
+			flags:  []string{"-webhook-tls-cert-dir", "/foo", "-datacenter", "foo", "-external-services-config-map-name", "external-services"},
+			expErr: "-external-services-config-map-namespace must be set with -external-services-config-map-name",
+		},
+		{
+			flags: []string{"-webhook-tls-cert-dir", "/foo", "-datacenter", "foo", "-external-services-config-map-name", "external-services",
+				"-external-services-config-map-namespace", "default", "-external-services-sync-period", "0s"},
+			expErr: "-external-services-sync-period must be greater than zero",
+		},
+		{
+			flags:  []string{"-webhook-tls-cert-dir", "/foo", "-datacenter", "foo", "-config-entry-workers", "servicedefault=2"},
+			expErr: `-config-entry-workers is invalid: unknown config entry kind "servicedefault"`,
+		},
+		{
+			flags:  []string{"-webhook-tls-cert-dir", "/foo", "-datacenter", "foo", "-config-entry-workers", "servicedefaults=0"},
+			expErr: `-config-entry-workers is invalid: workers of servicedefaults must be a positive number, got "0"`,
+		},
+		{
+			flags:  []string{"-webhook-tls-cert-dir", "/foo", "-datacenter", "foo", "-audit-webhook-url", "https://audit.example.com"},
+			expErr: "-enable-audit must be set with -audit-events or -audit-webhook-url",
+		},
 	}
 
 	for _, c := range cases {
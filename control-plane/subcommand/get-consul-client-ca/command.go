@@ -10,7 +10,6 @@ import (
 
 	"github.com/cenkalti/backoff"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
-	godiscover "github.com/hashicorp/consul-k8s/control-plane/helper/go-discover"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
 	"github.com/hashicorp/consul/api"
@@ -39,6 +38,10 @@ type Command struct {
 	help string
 
 	providers map[string]discover.Provider
+
+	// serverAddresses resolves the -server-addr and fails over between the
+	// discovered servers.
+	serverAddresses *common.ServerAddresses
 }
 
 func (c *Command) init() {
@@ -46,7 +49,8 @@ func (c *Command) init() {
 	c.flags.StringVar(&c.flagOutputFile, "output-file", "",
 		"The file path for writing the Consul client's CA certificate.")
 	c.flags.StringVar(&c.flagServerAddr, "server-addr", "",
-		"The address of the Consul server or the cloud auto-join string. The server must be running with TLS enabled. "+
+		"The address of the Consul server or the cloud auto-join string. The cloud auto-join string may also discover the "+
+			"servers from DNS SRV records, e.g. \"provider=dns-srv name=_consul-server._tcp.example.com\". The server must be running with TLS enabled. "+
 			"This value is required.")
 	c.flags.StringVar(&c.flagServerPort, "server-port", "443", "The HTTPS port of the Consul server.")
 	c.flags.StringVar(&c.flagCAFile, "ca-file", "",
@@ -90,6 +94,12 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
+	c.serverAddresses = &common.ServerAddresses{
+		Addresses: []string{c.flagServerAddr},
+		Providers: c.providers,
+		Logger:    logger,
+	}
+
 	// create Consul client
 	consulClient, err := c.consulClient(logger)
 	if err != nil {
@@ -104,6 +114,12 @@ func (c *Command) Run(args []string) int {
 		caRoots, _, err := consulClient.Agent().ConnectCARoots(nil)
 		if err != nil {
 			logger.Error("Error retrieving CA roots from Consul", "err", err)
+			// Fail over to the next server for the next attempt.
+			if next, clientErr := c.consulClient(logger); clientErr != nil {
+				logger.Error("Error initializing Consul client", "err", clientErr)
+			} else {
+				consulClient = next
+			}
 			return err
 		}
 
@@ -161,7 +177,9 @@ func (c *Command) consulClient(logger hclog.Logger) (*api.Client, error) {
 //
 // 1. If the server address is a cloud auto-join URL,
 //    it calls go-discover library to discover server addresses,
-//    picks the first address from the list and uses the provided port.
+//    picks the next address from the list and uses the provided port.
+//    The addresses are discovered again once they are older than
+//    the refresh interval of c.serverAddresses.
 // 2. Otherwise, it uses the address provided by the -server-addr
 //    and the -server-port flags.
 func (c *Command) consulServerAddr(logger hclog.Logger) (string, error) {
@@ -172,16 +190,15 @@ func (c *Command) consulServerAddr(logger hclog.Logger) (string, error) {
 		return fmt.Sprintf("%s:%s", c.flagServerAddr, c.flagServerPort), nil
 	}
 
-	servers, err := godiscover.ConsulServerAddresses(c.flagServerAddr, c.providers, logger)
+	server, err := c.serverAddresses.Next()
 	if err != nil {
 		return "", err
 	}
 
-	// Pick the first server from the list,
-	// ignoring the port since we need to use HTTP API
+	// Ignore the port since we need to use HTTP API
 	// and don't care about the RPC port.
-	firstServer := strings.SplitN(servers[0], ":", 2)[0]
-	return fmt.Sprintf("%s:%s", firstServer, c.flagServerPort), nil
+	server = strings.SplitN(server, ":", 2)[0]
+	return fmt.Sprintf("%s:%s", server, c.flagServerPort), nil
 }
 
 // getActiveRoot returns the currently active root
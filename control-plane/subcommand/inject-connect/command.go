@@ -6,12 +6,16 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	connectinject "github.com/hashicorp/consul-k8s/control-plane/connect-inject"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/cert"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
 	"github.com/hashicorp/consul/api"
@@ -21,12 +25,14 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
@@ -47,6 +53,16 @@ type Command struct {
 	flagLogLevel             string
 	flagLogJSON              bool
 
+	// Flags for keeping the caBundle of the webhook in sync with a CA file when
+	// the certificates in -tls-cert-dir are not issued by the webhook-cert-manager.
+	flagWebhookCACertFile string
+	flagWebhookConfigName string
+
+	// Flags for logging in to the auth method with projected service account tokens.
+	flagEnableProjectedServiceAccountToken     bool
+	flagProjectedServiceAccountTokenAudience   string
+	flagProjectedServiceAccountTokenExpiration time.Duration
+
 	flagAllowK8sNamespacesList []string // K8s namespaces to explicitly inject
 	flagDenyK8sNamespacesList  []string // K8s namespaces to deny injection (has precedence)
 
@@ -57,11 +73,13 @@ type Command struct {
 	flagConsulDestinationNamespace string // Consul namespace to register everything if not mirroring
 	flagEnableK8SNSMirroring       bool   // Enables mirroring of k8s namespaces into Consul
 	flagK8SNSMirroringPrefix       string // Prefix added to Consul namespaces created when mirroring
+	flagK8SNSMirroringRules        string // JSON list of rules rewriting k8s namespace names when mirroring
 	flagCrossNamespaceACLPolicy    string // The name of the ACL policy to add to every created namespace if ACLs are enabled
 
 	// Flags for endpoints controller.
 	flagReleaseName      string
 	flagReleaseNamespace string
+	flagClusterID        string
 
 	// Proxy resource settings.
 	flagDefaultSidecarProxyCPULimit      string
@@ -90,14 +108,80 @@ type Command struct {
 
 	// Transparent proxy flags.
 	flagDefaultEnableTransparentProxy          bool
+	flagEnableProbeHealthChecks                bool
+	flagEnableRolloutSubsets                   bool
+	flagEnableServiceLocality                  bool
+	flagEnablePeerFailover                     bool
+	flagDefaultEnableEnvoyAccessLogs           bool
+	flagDefaultEnvoyAccessLogJSONFormat        string
+	flagDefaultEnvoyAccessLogTextFormat        string
 	flagTransparentProxyDefaultOverwriteProbes bool
+	flagTransparentProxyInitMode               string
+	flagEnableTProxyNodeHelper                 bool
+
+	// Node proxy flags.
+	flagEnableNodeProxy      bool
+	flagNodeProxyInboundPort int
+
+	flagDefaultHoldApplicationUntilProxyStarts bool
+
+	// Flags for how quickly service instances of not ready and terminating pods are deregistered.
+	flagNotReadyGracePeriod        time.Duration
+	flagDeregisterNotReadyAfter    time.Duration
+	flagDeregisterTerminatingAfter time.Duration
+
+	// Flags for forwarding the metrics of proxies to the telemetry collector.
+	flagEnableTelemetryCollector                 bool
+	flagTelemetryCollectorAllowK8sNamespacesList []string
+	flagTelemetryCollectorDenyK8sNamespacesList  []string
+
+	// Flags for the xDS watchdog of the consul sidecar.
+	flagEnableXDSWatchdog    bool
+	flagEnableJobCompletion  bool
+	flagXDSWatchdogThreshold time.Duration
+	flagXDSWatchdogPolicy    string
+
+	// Name of the ConfigMap that overrides the sidecar defaults for its namespace.
+	flagNamespaceSidecarConfigMap string
+
+	// Allow namespaces to merge a ConfigMap of Envoy bootstrap config fragments into the
+	// bootstrap config of their sidecars.
+	flagEnableEnvoyBootstrapConfigMaps bool
 
 	// Consul DNS flags.
-	flagEnableConsulDNS bool
-	flagResourcePrefix  string
+	flagEnableConsulDNS         bool
+	flagResourcePrefix          string
+	flagConsulDNSDomain         string
+	flagCoreDNSConfigMap        string
+	flagNodeLocalDNSConfigMap   string
+	flagNodeLocalDNSBindAddress string
 
 	flagEnableOpenShift bool
 
+	// Flags for generating NetworkPolicies that mirror the reachability of the mesh.
+	flagEnableNetworkPolicies     bool
+	flagNetworkPolicyDefaultAllow bool
+
+	// Flags for registering services into a second datacenter during a migration.
+	flagMigrationConsulHTTPAddr   string
+	flagMigrationConsulDatacenter string
+	flagMigrationConsulTokenFile  string
+	flagMigrationNodeName         string
+
+	// Flag for sharding the endpoints reconciliation across the replicas.
+	flagEnableSharding bool
+
+	// Flag for caching the service instances on the Consul nodes with blocking queries.
+	flagEnableServiceCache bool
+
+	// Flag for how long the Consul servers may be unreachable before the injector is no longer ready.
+	flagConsulUnreachableFailAfter time.Duration
+
+	// Flags for the audit trail of the writes to Consul.
+	flagEnableAudit     bool
+	flagAuditEvents     bool
+	flagAuditWebhookURL string
+
 	flagSet *flag.FlagSet
 	http    *flags.HTTPFlags
 
@@ -124,7 +208,13 @@ func (c *Command) init() {
 	c.flagSet.StringVar(&c.flagListen, "listen", ":8080", "Address to bind listener to.")
 	c.flagSet.BoolVar(&c.flagDefaultInject, "default-inject", true, "Inject by default.")
 	c.flagSet.StringVar(&c.flagCertDir, "tls-cert-dir", "",
-		"Directory with PEM-encoded TLS certificate and key to serve.")
+		"Directory with PEM-encoded TLS certificate and key to serve. Changes to the files are picked up without a restart.")
+	c.flagSet.StringVar(&c.flagWebhookCACertFile, "webhook-ca-cert-file", "",
+		"Path to the PEM-encoded CA certificate that signed the certificate in -tls-cert-dir. If set, the caBundle of "+
+			"the webhooks on -webhook-config-name is kept in sync with it. Only needed if the certificates are not "+
+			"issued by the webhook-cert-manager.")
+	c.flagSet.StringVar(&c.flagWebhookConfigName, "webhook-config-name", "",
+		"Name of the MutatingWebhookConfiguration of the injector. Required if -webhook-ca-cert-file is set.")
 	c.flagSet.StringVar(&c.flagConsulImage, "consul-image", "",
 		"Docker image for Consul.")
 	c.flagSet.StringVar(&c.flagEnvoyImage, "envoy-image", "",
@@ -135,6 +225,14 @@ func (c *Command) init() {
 		"Extra envoy command line args to be set when starting envoy (e.g \"--log-level debug --disable-hot-restart\").")
 	c.flagSet.StringVar(&c.flagACLAuthMethod, "acl-auth-method", "",
 		"The name of the Kubernetes Auth Method to use for connectInjection if ACLs are enabled.")
+	c.flagSet.BoolVar(&c.flagEnableProjectedServiceAccountToken, "enable-projected-service-account-token", false,
+		"Log in to the auth method with a projected service account token that is bound to the pod and expires, "+
+			"instead of the service account token mounted in the pod. Multi port pods always use the latter.")
+	c.flagSet.StringVar(&c.flagProjectedServiceAccountTokenAudience, "projected-service-account-token-audience", "",
+		"Audience of the projected service account token. It must be one of the audiences of the Kubernetes API server "+
+			"since the auth method reviews tokens with them. Defaults to the audience of the API server.")
+	c.flagSet.DurationVar(&c.flagProjectedServiceAccountTokenExpiration, "projected-service-account-token-expiration", time.Hour,
+		"How long the projected service account token is valid for. Must be at least 10m.")
 	c.flagSet.BoolVar(&c.flagWriteServiceDefaults, "enable-central-config", false,
 		"Write a service-defaults config for every Connect service using protocol from -default-protocol or Pod annotation.")
 	c.flagSet.StringVar(&c.flagDefaultProtocol, "default-protocol", "",
@@ -147,6 +245,9 @@ func (c *Command) init() {
 		"K8s namespaces to explicitly deny. Takes precedence over allow. May be specified multiple times.")
 	c.flagSet.StringVar(&c.flagReleaseName, "release-name", "consul", "The Consul Helm installation release name, e.g 'helm install <RELEASE-NAME>'")
 	c.flagSet.StringVar(&c.flagReleaseNamespace, "release-namespace", "default", "The Consul Helm installation namespace, e.g 'helm install <RELEASE-NAME> --namespace <RELEASE-NAMESPACE>'")
+	c.flagSet.StringVar(&c.flagClusterID, "cluster-id", "",
+		"ID of the Kubernetes cluster, when several clusters register service instances in the same Consul datacenter. "+
+			"It is added to the meta of the service instances, and the instances of other clusters are never deregistered.")
 	c.flagSet.BoolVar(&c.flagEnablePartitions, "enable-partitions", false,
 		"[Enterprise Only] Enables Admin Partitions.")
 	c.flagSet.BoolVar(&c.flagEnableNamespaces, "enable-namespaces", false,
@@ -158,6 +259,10 @@ func (c *Command) init() {
 		"k8s namespace mirroring.")
 	c.flagSet.StringVar(&c.flagK8SNSMirroringPrefix, "k8s-namespace-mirroring-prefix", "",
 		"[Enterprise Only] Prefix that will be added to all k8s namespaces mirrored into Consul if mirroring is enabled.")
+	c.flagSet.StringVar(&c.flagK8SNSMirroringRules, "k8s-namespace-mirroring-rules", "",
+		"[Enterprise Only] JSON list of rules that rewrite the names of k8s namespaces mirrored into Consul, e.g. "+
+			"'[{\"match\": \"team-(.*)\", \"replace\": \"$1\"}]'. The first rule whose regular expression matches the whole "+
+			"namespace name is applied before the mirroring prefix is added.")
 	c.flagSet.StringVar(&c.flagCrossNamespaceACLPolicy, "consul-cross-namespace-acl-policy", "",
 		"[Enterprise Only] Name of the ACL policy to attach to all created Consul namespaces to allow service "+
 			"discovery across Consul namespaces. Only necessary if ACLs are enabled.")
@@ -165,12 +270,119 @@ func (c *Command) init() {
 		"Enable transparent proxy mode for all Consul service mesh applications by default.")
 	c.flagSet.BoolVar(&c.flagTransparentProxyDefaultOverwriteProbes, "transparent-proxy-default-overwrite-probes", true,
 		"Overwrite Kubernetes probes to point to Envoy by default when in Transparent Proxy mode.")
+	c.flagSet.StringVar(&c.flagTransparentProxyInitMode, "transparent-proxy-init-mode", connectinject.TProxyInitModeAuto,
+		"How the traffic redirection rules are applied when in Transparent Proxy mode. One of \"auto\", \"privileged\", "+
+			"\"net-admin\" or \"node-helper\". The auto mode uses the node helper for namespaces that enforce the baseline "+
+			"or restricted Pod Security Standard and a privileged init container otherwise.")
+	c.flagSet.BoolVar(&c.flagEnableTProxyNodeHelper, "enable-transparent-proxy-node-helper", false,
+		"Indicates that the transparent proxy node helper is deployed to apply traffic redirection rules on behalf of pods.")
+	c.flagSet.BoolVar(&c.flagEnableNodeProxy, "enable-node-proxy", false,
+		"Allow pods to opt into node proxy mode, where a proxy shared by all pods on the node replaces the sidecar. Experimental.")
+	c.flagSet.IntVar(&c.flagNodeProxyInboundPort, "node-proxy-inbound-port", connectinject.DefaultNodeProxyInboundPort,
+		"Port the node proxy accepts mesh traffic for the pods on its node on.")
+	c.flagSet.BoolVar(&c.flagDefaultHoldApplicationUntilProxyStarts, "default-hold-application-until-proxy-starts", false,
+		"Start application containers only once the Envoy sidecar is ready by default.")
+	c.flagSet.StringVar(&c.flagNamespaceSidecarConfigMap, "namespace-sidecar-config-map", "",
+		"Name of the ConfigMap that overrides the sidecar defaults for the pods in its namespace. Its keys are the "+
+			"sidecar annotations without the consul.hashicorp.com/ prefix, e.g. sidecar-proxy-cpu-limit. "+
+			"Pod annotations take precedence over it.")
+	c.flagSet.BoolVar(&c.flagEnableEnvoyBootstrapConfigMaps, "enable-envoy-bootstrap-config-maps", false,
+		"Merge the Envoy bootstrap config fragments of the ConfigMap named by the consul.hashicorp.com/envoy-bootstrap-config-map "+
+			"annotation of a namespace into the bootstrap config of the sidecars of its pods.")
+	c.flagSet.BoolVar(&c.flagEnableProbeHealthChecks, "enable-probe-health-checks", false,
+		"Register every Kubernetes probe and readiness gate of a pod as a separate Consul health check by default.")
+	c.flagSet.BoolVar(&c.flagEnableRolloutSubsets, "enable-rollout-subsets", false,
+		"Add the stable or canary role of pods in Argo Rollouts and Flagger canary deployments to the meta of "+
+			"their service instances and write service resolvers with a stable and a canary subset for their services.")
+	c.flagSet.BoolVar(&c.flagEnableServiceLocality, "enable-service-locality", false,
+		"Register service instances with the region and zone of their node by default, taken from the "+
+			"topology.kubernetes.io/region and topology.kubernetes.io/zone node labels. Pod annotations take precedence over it.")
+	c.flagSet.BoolVar(&c.flagEnablePeerFailover, "enable-peer-failover", false,
+		"Write service resolvers that fail over to the peers with an active peering that import the services of pods "+
+			"by default. Service resolvers that are managed otherwise aren't changed. Pod annotations take precedence over it.")
+	c.flagSet.BoolVar(&c.flagDefaultEnableEnvoyAccessLogs, "default-enable-envoy-access-logs", false,
+		"Make the Envoy proxies of pods write access logs to stdout by default. Requires Consul 1.15+. "+
+			"Pod annotations take precedence over it.")
+	c.flagSet.StringVar(&c.flagDefaultEnvoyAccessLogJSONFormat, "default-envoy-access-log-json-format", "",
+		"The JSON object the access log entries of the Envoy proxies are formatted as by default, "+
+			"with Envoy command operators as values.")
+	c.flagSet.StringVar(&c.flagDefaultEnvoyAccessLogTextFormat, "default-envoy-access-log-text-format", "",
+		"The template the access log entries of the Envoy proxies are formatted with by default, "+
+			"using Envoy command operators. Only one of it and -default-envoy-access-log-json-format may be set.")
+	c.flagSet.DurationVar(&c.flagNotReadyGracePeriod, "not-ready-grace-period", 0,
+		"How long the service instance of a pod stays passing after the pod becomes not ready by default, so that "+
+			"brief readiness flaps don't reach the upstream proxies.")
+	c.flagSet.DurationVar(&c.flagDeregisterNotReadyAfter, "deregister-not-ready-after", 0,
+		"How long a pod may be not ready before its service instance is deregistered instead of being registered "+
+			"as critical by default. If zero, it is never deregistered while the pod exists.")
+	c.flagSet.DurationVar(&c.flagDeregisterTerminatingAfter, "deregister-terminating-after", 0,
+		"How long after a pod starts terminating its service instance is deregistered by default. If zero, it stays "+
+			"registered as critical until the pod stops.")
+	c.flagSet.BoolVar(&c.flagEnableTelemetryCollector, "enable-telemetry-collector", false,
+		"Make the proxies of pods forward their metrics to the consul-telemetry-collector service.")
+	c.flagSet.Var((*flags.AppendSliceValue)(&c.flagTelemetryCollectorAllowK8sNamespacesList), "telemetry-collector-allow-k8s-namespace",
+		"K8s namespaces whose pods forward their metrics to the telemetry collector. May be specified multiple times. "+
+			"If not set, all namespaces are allowed.")
+	c.flagSet.Var((*flags.AppendSliceValue)(&c.flagTelemetryCollectorDenyK8sNamespacesList), "telemetry-collector-deny-k8s-namespace",
+		"K8s namespaces whose pods don't forward their metrics to the telemetry collector. Takes precedence over allow. "+
+			"May be specified multiple times.")
+	c.flagSet.BoolVar(&c.flagEnableXDSWatchdog, "default-enable-xds-watchdog", false,
+		"Run the xDS watchdog in the consul sidecar by default, which acts on Envoy proxies that are disconnected "+
+			"from xDS or not ready for longer than -xds-watchdog-threshold.")
+	c.flagSet.BoolVar(&c.flagEnableJobCompletion, "default-enable-job-completion", false,
+		"Stop the Envoy proxies of Job pods with the Never restart policy by default once their application containers "+
+			"have exited, so that the pods complete. This shares the process namespace of the pods.")
+	c.flagSet.DurationVar(&c.flagXDSWatchdogThreshold, "xds-watchdog-threshold", 2*time.Minute,
+		"How long an Envoy proxy may be disconnected from xDS before the xDS watchdog acts on it by default.")
+	c.flagSet.StringVar(&c.flagXDSWatchdogPolicy, "xds-watchdog-policy", "log",
+		"What the xDS watchdog does with disconnected Envoy proxies by default. \"log\" logs and reports them in "+
+			"the merged metrics, \"restart\" also restarts them.")
 	c.flagSet.BoolVar(&c.flagEnableConsulDNS, "enable-consul-dns", false,
 		"Enables Consul DNS lookup for services in the mesh.")
 	c.flagSet.StringVar(&c.flagResourcePrefix, "resource-prefix", "",
 		"Release prefix of the Consul installation used to determine Consul DNS Service name.")
+	c.flagSet.StringVar(&c.flagConsulDNSDomain, "consul-dns-domain", "consul",
+		"Domain of Consul DNS that CoreDNS and node-local-dns forward to the Consul DNS Service.")
+	c.flagSet.StringVar(&c.flagCoreDNSConfigMap, "coredns-config-map", "",
+		"<namespace>/<name> of the CoreDNS ConfigMap, e.g. kube-system/coredns. If set, a server block that forwards "+
+			"the Consul DNS domain to the Consul DNS Service is kept up to date in its Corefile.")
+	c.flagSet.StringVar(&c.flagNodeLocalDNSConfigMap, "node-local-dns-config-map", "",
+		"<namespace>/<name> of the node-local-dns ConfigMap, e.g. kube-system/node-local-dns. If set, a server block "+
+			"that forwards the Consul DNS domain to the Consul DNS Service is kept up to date in its Corefile.")
+	c.flagSet.StringVar(&c.flagNodeLocalDNSBindAddress, "node-local-dns-bind-address", "169.254.20.10",
+		"Address node-local-dns listens on.")
 	c.flagSet.BoolVar(&c.flagEnableOpenShift, "enable-openshift", false,
 		"Indicates that the command runs in an OpenShift cluster.")
+	c.flagSet.BoolVar(&c.flagEnableNetworkPolicies, "enable-network-policies", false,
+		"Generate NetworkPolicies for the services in namespaces labeled with consul.hashicorp.com/network-policy=true "+
+			"that only allow the traffic intentions allow.")
+	c.flagSet.BoolVar(&c.flagNetworkPolicyDefaultAllow, "network-policy-default-allow", true,
+		"Whether connections that no intention matches are allowed, i.e. whether the ACL default policy is \"allow\".")
+	c.flagSet.StringVar(&c.flagMigrationConsulHTTPAddr, "migration-consul-http-addr", "",
+		"Address of the Consul servers of a datacenter this cluster is being migrated to. When set, service "+
+			"instances are registered into this datacenter in addition to the datacenter of the local Consul clients.")
+	c.flagSet.StringVar(&c.flagMigrationConsulDatacenter, "migration-consul-datacenter", "",
+		"Name of the Consul datacenter this cluster is being migrated to.")
+	c.flagSet.StringVar(&c.flagMigrationConsulTokenFile, "migration-consul-token-file", "",
+		"File containing the ACL token to use when registering services into the datacenter this cluster is being migrated to.")
+	c.flagSet.StringVar(&c.flagMigrationNodeName, "migration-node-name", connectinject.DefaultMigrationNodeName,
+		"Name of the node service instances are registered on in the datacenter this cluster is being migrated to.")
+	c.flagSet.DurationVar(&c.flagConsulUnreachableFailAfter, "consul-unreachable-fail-after", 0,
+		"How long the Consul servers may be unreachable before the injector reports that it is not ready. Until then "+
+			"it keeps injecting with cached configuration and reports that it is degraded. It is always ready if zero.")
+	c.flagSet.BoolVar(&c.flagEnableAudit, "enable-audit", false,
+		"Log every service instance, ACL token and config entry the endpoints controller writes to or deletes from Consul, "+
+			"with the difference to what it last wrote, as an audit trail of its changes.")
+	c.flagSet.BoolVar(&c.flagAuditEvents, "audit-events", false,
+		"Also record the writes to Consul as Kubernetes events on the pods and endpoints they are made for. Requires -enable-audit.")
+	c.flagSet.StringVar(&c.flagAuditWebhookURL, "audit-webhook-url", "",
+		"URL the writes to Consul are posted to as JSON. Requires -enable-audit.")
+	c.flagSet.BoolVar(&c.flagEnableServiceCache, "enable-service-cache", false,
+		"Watch the service instances registered on the Consul nodes with blocking queries and use them to decide "+
+			"which instances to deregister instead of querying every client agent on each reconcile.")
+	c.flagSet.BoolVar(&c.flagEnableSharding, "enable-sharding", false,
+		"Shard the reconciliation of endpoints across all replicas by namespace instead of reconciling "+
+			"them on the leader only.")
 	c.flagSet.StringVar(&c.flagLogLevel, "log-level", zapcore.InfoLevel.String(),
 		fmt.Sprintf("Log verbosity level. Supported values (in order of detail) are "+
 			"%q, %q, %q, and %q.", zapcore.DebugLevel.String(), zapcore.InfoLevel.String(), zapcore.WarnLevel.String(), zapcore.ErrorLevel.String()))
@@ -250,9 +462,76 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
+	if !connectinject.ValidTProxyInitMode(c.flagTransparentProxyInitMode) {
+		c.UI.Error(fmt.Sprintf("-transparent-proxy-init-mode %q is invalid, must be one of \"auto\", \"privileged\", \"net-admin\" or \"node-helper\"", c.flagTransparentProxyInitMode))
+		return 1
+	}
+	if c.flagTransparentProxyInitMode == connectinject.TProxyInitModeNodeHelper && !c.flagEnableTProxyNodeHelper {
+		c.UI.Error("-enable-transparent-proxy-node-helper must be set if -transparent-proxy-init-mode is \"node-helper\"")
+		return 1
+	}
+	if c.flagEnableNodeProxy && !c.flagEnableTProxyNodeHelper {
+		c.UI.Error("-enable-transparent-proxy-node-helper must be set if -enable-node-proxy is set")
+		return 1
+	}
+	if (c.flagAuditEvents || c.flagAuditWebhookURL != "") && !c.flagEnableAudit {
+		c.UI.Error("-enable-audit must be set if -audit-events or -audit-webhook-url is set")
+		return 1
+	}
+
+	if c.flagEnableProjectedServiceAccountToken && c.flagProjectedServiceAccountTokenExpiration < 10*time.Minute {
+		c.UI.Error("-projected-service-account-token-expiration must be at least 10m")
+		return 1
+	}
+	if c.flagNotReadyGracePeriod < 0 || c.flagDeregisterNotReadyAfter < 0 || c.flagDeregisterTerminatingAfter < 0 {
+		c.UI.Error("-not-ready-grace-period, -deregister-not-ready-after and -deregister-terminating-after must not be negative")
+		return 1
+	}
+	if c.flagXDSWatchdogThreshold <= 0 {
+		c.UI.Error("-xds-watchdog-threshold must be greater than 0")
+		return 1
+	}
+	if c.flagXDSWatchdogPolicy != "log" && c.flagXDSWatchdogPolicy != "restart" {
+		c.UI.Error("-xds-watchdog-policy must be \"log\" or \"restart\"")
+		return 1
+	}
+	envoyAccessLogs := connectinject.EnvoyAccessLogs{
+		Enabled:    c.flagDefaultEnableEnvoyAccessLogs,
+		JSONFormat: c.flagDefaultEnvoyAccessLogJSONFormat,
+		TextFormat: c.flagDefaultEnvoyAccessLogTextFormat,
+	}
+	if err := envoyAccessLogs.Validate(); err != nil {
+		c.UI.Error(fmt.Sprintf("Invalid -default-envoy-access-log-json-format or -default-envoy-access-log-text-format: %s", err))
+		return 1
+	}
+
+	coreDNSConfigMap, err := parseConfigMapFlag("coredns-config-map", c.flagCoreDNSConfigMap)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	nodeLocalDNSConfigMap, err := parseConfigMapFlag("node-local-dns-config-map", c.flagNodeLocalDNSConfigMap)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	if (c.flagCoreDNSConfigMap != "" || c.flagNodeLocalDNSConfigMap != "") && c.flagResourcePrefix == "" {
+		c.UI.Error("-resource-prefix must be set if -coredns-config-map or -node-local-dns-config-map is set")
+		return 1
+	}
+	if c.flagWebhookCACertFile != "" && c.flagWebhookConfigName == "" {
+		c.UI.Error("-webhook-config-name must be set if -webhook-ca-cert-file is set")
+		return 1
+	}
+
+	k8sNSMirroringRules, err := namespaces.ParseMirroringRules(c.flagK8SNSMirroringRules)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("-k8s-namespace-mirroring-rules is invalid: %s", err))
+		return 1
+	}
+
 	// Proxy resources.
 	var sidecarProxyCPULimit, sidecarProxyCPURequest, sidecarProxyMemoryLimit, sidecarProxyMemoryRequest resource.Quantity
-	var err error
 	if c.flagDefaultSidecarProxyCPURequest != "" {
 		sidecarProxyCPURequest, err = resource.ParseQuantity(c.flagDefaultSidecarProxyCPURequest)
 		if err != nil {
@@ -366,6 +645,22 @@ func (c *Command) Run(args []string) int {
 		}
 	}
 
+	// Set up the Consul client for the datacenter being migrated to.
+	var migrationConsulClient *api.Client
+	if c.flagMigrationConsulHTTPAddr != "" {
+		migrationCfg := api.DefaultConfig()
+		c.http.MergeOntoConfig(migrationCfg)
+		migrationCfg.Address = c.flagMigrationConsulHTTPAddr
+		migrationCfg.Datacenter = c.flagMigrationConsulDatacenter
+		migrationCfg.Token = ""
+		migrationCfg.TokenFile = c.flagMigrationConsulTokenFile
+		migrationConsulClient, err = consul.NewClient(migrationCfg)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("error creating Consul client for migration datacenter: %s", err))
+			return 1
+		}
+	}
+
 	// Create a context to be used by the processes started in this command.
 	ctx, cancelFunc := context.WithCancel(context.Background())
 	defer cancelFunc()
@@ -373,12 +668,20 @@ func (c *Command) Run(args []string) int {
 	// Convert allow/deny lists to sets.
 	allowK8sNamespaces := flags.ToSet(c.flagAllowK8sNamespacesList)
 	denyK8sNamespaces := flags.ToSet(c.flagDenyK8sNamespacesList)
+	if len(c.flagTelemetryCollectorAllowK8sNamespacesList) == 0 {
+		c.flagTelemetryCollectorAllowK8sNamespacesList = []string{"*"}
+	}
+	telemetryCollectorAllowK8sNamespaces := flags.ToSet(c.flagTelemetryCollectorAllowK8sNamespacesList)
+	telemetryCollectorDenyK8sNamespaces := flags.ToSet(c.flagTelemetryCollectorDenyK8sNamespacesList)
 
-	zapLogger, err := common.ZapLogger(c.flagLogLevel, c.flagLogJSON)
+	// The log levels can be changed at runtime through /log-level on the
+	// metrics server.
+	logLevels, err := common.NewLogLevels(c.flagLogLevel)
 	if err != nil {
 		c.UI.Error(fmt.Sprintf("Error setting up logging: %s", err.Error()))
 		return 1
 	}
+	zapLogger := logLevels.Logger(c.flagLogJSON)
 	ctrl.SetLogger(zapLogger)
 	klog.SetLogger(zapLogger)
 
@@ -416,75 +719,263 @@ func (c *Command) Run(args []string) int {
 		DefaultPrometheusScrapePath: c.flagDefaultPrometheusScrapePath,
 	}
 
+	var shards *connectinject.ShardMembership
+	if c.flagEnableSharding {
+		identity, err := os.Hostname()
+		if err != nil {
+			setupLog.Error(err, "unable to get hostname")
+			return 1
+		}
+		shards = &connectinject.ShardMembership{
+			Clientset: c.clientset,
+			Namespace: c.flagReleaseNamespace,
+			Group:     fmt.Sprintf("%s-connect-injector", c.flagReleaseName),
+			Identity:  identity,
+			Log:       ctrl.Log.WithName("shards"),
+			Events:    make(chan event.GenericEvent),
+		}
+		if err = mgr.Add(shards); err != nil {
+			setupLog.Error(err, "unable to add shard membership")
+			return 1
+		}
+	}
+
+	consulHealth := &consul.HealthTracker{
+		Client:    c.consulClient,
+		Component: "connect-injector",
+		FailAfter: c.flagConsulUnreachableFailAfter,
+		Log:       ctrl.Log.WithName("consul-health"),
+	}
+	if err = mgr.Add(consulHealth); err != nil {
+		setupLog.Error(err, "unable to add Consul health tracker")
+		return 1
+	}
+	var audit *consul.AuditTrail
+	if c.flagEnableAudit {
+		audit = &consul.AuditTrail{
+			Component:  "connect-injector",
+			Log:        ctrl.Log.WithName("audit"),
+			WebhookURL: c.flagAuditWebhookURL,
+		}
+		if c.flagAuditEvents {
+			audit.Recorder = mgr.GetEventRecorderFor("connect-injector")
+		}
+		if err = mgr.Add(audit); err != nil {
+			setupLog.Error(err, "unable to add audit trail")
+			return 1
+		}
+	}
+	if err = mgr.AddMetricsExtraHandler("/consul-status", consulHealth); err != nil {
+		setupLog.Error(err, "unable to add Consul status handler")
+		return 1
+	}
+	if err = mgr.AddMetricsExtraHandler("/log-level", logLevels); err != nil {
+		setupLog.Error(err, "unable to add log level handler")
+		return 1
+	}
+
+	var serviceCache, migrationServiceCache *connectinject.ServiceCache
+	if c.flagEnableServiceCache {
+		var consulNamespace string
+		if c.flagEnableNamespaces {
+			consulNamespace = "*"
+		}
+		serviceCache = &connectinject.ServiceCache{
+			Client:    c.consulClient,
+			Namespace: consulNamespace,
+			Log:       ctrl.Log.WithName("service-cache"),
+			Events:    make(chan event.GenericEvent),
+		}
+		if err = mgr.Add(serviceCache); err != nil {
+			setupLog.Error(err, "unable to add service cache")
+			return 1
+		}
+		if migrationConsulClient != nil {
+			migrationServiceCache = &connectinject.ServiceCache{
+				Client:    migrationConsulClient,
+				Namespace: consulNamespace,
+				Log:       ctrl.Log.WithName("migration-service-cache"),
+			}
+			if err = mgr.Add(migrationServiceCache); err != nil {
+				setupLog.Error(err, "unable to add migration service cache")
+				return 1
+			}
+		}
+	}
+
+	dnsService := types.NamespacedName{Name: c.flagResourcePrefix + "-dns", Namespace: c.flagReleaseNamespace}
+	if c.flagCoreDNSConfigMap != "" {
+		if err = mgr.Add(&connectinject.CoreDNSController{
+			Clientset:  c.clientset,
+			Log:        ctrl.Log.WithName("controller").WithName("coredns"),
+			ConfigMap:  coreDNSConfigMap,
+			DNSService: dnsService,
+			Domain:     c.flagConsulDNSDomain,
+		}); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "coredns")
+			return 1
+		}
+	}
+	if c.flagNodeLocalDNSConfigMap != "" {
+		if err = mgr.Add(&connectinject.CoreDNSController{
+			Clientset:   c.clientset,
+			Log:         ctrl.Log.WithName("controller").WithName("node-local-dns"),
+			ConfigMap:   nodeLocalDNSConfigMap,
+			DNSService:  dnsService,
+			Domain:      c.flagConsulDNSDomain,
+			BindAddress: c.flagNodeLocalDNSBindAddress,
+		}); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "node-local-dns")
+			return 1
+		}
+	}
+
 	if err = (&connectinject.EndpointsController{
-		Client:                     mgr.GetClient(),
-		ConsulClient:               c.consulClient,
-		ConsulScheme:               consulURL.Scheme,
-		ConsulPort:                 consulURL.Port(),
-		AllowK8sNamespacesSet:      allowK8sNamespaces,
-		DenyK8sNamespacesSet:       denyK8sNamespaces,
-		MetricsConfig:              metricsConfig,
-		ConsulClientCfg:            cfg,
-		EnableConsulPartitions:     c.flagEnablePartitions,
-		EnableConsulNamespaces:     c.flagEnableNamespaces,
-		ConsulDestinationNamespace: c.flagConsulDestinationNamespace,
-		EnableNSMirroring:          c.flagEnableK8SNSMirroring,
-		NSMirroringPrefix:          c.flagK8SNSMirroringPrefix,
-		CrossNSACLPolicy:           c.flagCrossNamespaceACLPolicy,
-		EnableTransparentProxy:     c.flagDefaultEnableTransparentProxy,
-		TProxyOverwriteProbes:      c.flagTransparentProxyDefaultOverwriteProbes,
-		AuthMethod:                 c.flagACLAuthMethod,
-		Log:                        ctrl.Log.WithName("controller").WithName("endpoints"),
-		Scheme:                     mgr.GetScheme(),
-		ReleaseName:                c.flagReleaseName,
-		ReleaseNamespace:           c.flagReleaseNamespace,
-		Context:                    ctx,
+		Client:                                  mgr.GetClient(),
+		ConsulClient:                            c.consulClient,
+		ConsulScheme:                            consulURL.Scheme,
+		ConsulPort:                              consulURL.Port(),
+		AllowK8sNamespacesSet:                   allowK8sNamespaces,
+		DenyK8sNamespacesSet:                    denyK8sNamespaces,
+		MetricsConfig:                           metricsConfig,
+		ConsulClientCfg:                         cfg,
+		EnableConsulPartitions:                  c.flagEnablePartitions,
+		EnableConsulNamespaces:                  c.flagEnableNamespaces,
+		ConsulDestinationNamespace:              c.flagConsulDestinationNamespace,
+		EnableNSMirroring:                       c.flagEnableK8SNSMirroring,
+		NSMirroringPrefix:                       c.flagK8SNSMirroringPrefix,
+		NSMirroringRules:                        k8sNSMirroringRules,
+		CrossNSACLPolicy:                        c.flagCrossNamespaceACLPolicy,
+		EnableTransparentProxy:                  c.flagDefaultEnableTransparentProxy,
+		TProxyOverwriteProbes:                   c.flagTransparentProxyDefaultOverwriteProbes,
+		EnableProbeHealthChecks:                 c.flagEnableProbeHealthChecks,
+		EnableRolloutSubsets:                    c.flagEnableRolloutSubsets,
+		EnableServiceLocality:                   c.flagEnableServiceLocality,
+		EnablePeerFailover:                      c.flagEnablePeerFailover,
+		EnvoyAccessLogs:                         envoyAccessLogs,
+		NotReadyGracePeriod:                     c.flagNotReadyGracePeriod,
+		DeregisterNotReadyAfter:                 c.flagDeregisterNotReadyAfter,
+		DeregisterTerminatingAfter:              c.flagDeregisterTerminatingAfter,
+		EnableTelemetryCollector:                c.flagEnableTelemetryCollector,
+		TelemetryCollectorAllowK8sNamespacesSet: telemetryCollectorAllowK8sNamespaces,
+		TelemetryCollectorDenyK8sNamespacesSet:  telemetryCollectorDenyK8sNamespaces,
+		NodeProxyInboundPort:                    c.flagNodeProxyInboundPort,
+		AuthMethod:                              c.flagACLAuthMethod,
+		MigrationConsulClient:                   migrationConsulClient,
+		MigrationNodeName:                       c.flagMigrationNodeName,
+		ServiceCache:                            serviceCache,
+		MigrationServiceCache:                   migrationServiceCache,
+		Shards:                                  shards,
+		ConsulHealth:                            consulHealth,
+		Audit:                                   audit,
+		Log:                                     ctrl.Log.WithName("controller").WithName("endpoints"),
+		Scheme:                                  mgr.GetScheme(),
+		ReleaseName:                             c.flagReleaseName,
+		ReleaseNamespace:                        c.flagReleaseNamespace,
+		ClusterID:                               c.flagClusterID,
+		Context:                                 ctx,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", connectinject.EndpointsController{})
 		return 1
 	}
 
+	if c.flagEnableNetworkPolicies {
+		if err = (&connectinject.NetworkPolicyController{
+			Client:                     mgr.GetClient(),
+			ConsulClient:               c.consulClient,
+			EnableConsulNamespaces:     c.flagEnableNamespaces,
+			ConsulDestinationNamespace: c.flagConsulDestinationNamespace,
+			EnableNSMirroring:          c.flagEnableK8SNSMirroring,
+			NSMirroringPrefix:          c.flagK8SNSMirroringPrefix,
+			NSMirroringRules:           k8sNSMirroringRules,
+			DefaultAllow:               c.flagNetworkPolicyDefaultAllow,
+			AllowK8sNamespacesSet:      allowK8sNamespaces,
+			DenyK8sNamespacesSet:       denyK8sNamespaces,
+			ReleaseName:                c.flagReleaseName,
+			ReleaseNamespace:           c.flagReleaseNamespace,
+			Log:                        ctrl.Log.WithName("controller").WithName("networkpolicy"),
+			Context:                    ctx,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "networkpolicy")
+			return 1
+		}
+	}
+
+	if c.flagWebhookCACertFile != "" {
+		if err = mgr.Add(&cert.CABundleUpdater{
+			Clientset:         c.clientset,
+			Log:               ctrl.Log.WithName("webhook-ca-bundle"),
+			WebhookConfigName: c.flagWebhookConfigName,
+			CAFile:            c.flagWebhookCACertFile,
+		}); err != nil {
+			setupLog.Error(err, "unable to add webhook caBundle updater")
+			return 1
+		}
+	}
+
 	if err = mgr.AddReadyzCheck("ready", connectinject.ReadinessCheck{CertDir: c.flagCertDir}.Ready); err != nil {
 		setupLog.Error(err, "unable to create readiness check", "controller", connectinject.EndpointsController{})
 		return 1
 	}
+	if err = mgr.AddReadyzCheck("consul", consulHealth.Ready); err != nil {
+		setupLog.Error(err, "unable to create Consul readiness check")
+		return 1
+	}
 
+	// The webhook server watches the files in the CertDir and reloads the
+	// certificate when it is rotated.
 	mgr.GetWebhookServer().CertDir = c.flagCertDir
 
 	mgr.GetWebhookServer().Register("/mutate",
 		&webhook.Admission{Handler: &connectinject.Handler{
-			Clientset:                     c.clientset,
-			ConsulClient:                  c.consulClient,
-			ImageConsul:                   c.flagConsulImage,
-			ImageEnvoy:                    c.flagEnvoyImage,
-			EnvoyExtraArgs:                c.flagEnvoyExtraArgs,
-			ImageConsulK8S:                c.flagConsulK8sImage,
-			RequireAnnotation:             !c.flagDefaultInject,
-			AuthMethod:                    c.flagACLAuthMethod,
-			ConsulCACert:                  string(consulCACert),
-			DefaultProxyCPURequest:        sidecarProxyCPURequest,
-			DefaultProxyCPULimit:          sidecarProxyCPULimit,
-			DefaultProxyMemoryRequest:     sidecarProxyMemoryRequest,
-			DefaultProxyMemoryLimit:       sidecarProxyMemoryLimit,
-			MetricsConfig:                 metricsConfig,
-			InitContainerResources:        initResources,
-			DefaultConsulSidecarResources: consulSidecarResources,
-			ConsulPartition:               c.http.Partition(),
-			AllowK8sNamespacesSet:         allowK8sNamespaces,
-			DenyK8sNamespacesSet:          denyK8sNamespaces,
-			EnableNamespaces:              c.flagEnableNamespaces,
-			ConsulDestinationNamespace:    c.flagConsulDestinationNamespace,
-			EnableK8SNSMirroring:          c.flagEnableK8SNSMirroring,
-			K8SNSMirroringPrefix:          c.flagK8SNSMirroringPrefix,
-			CrossNamespaceACLPolicy:       c.flagCrossNamespaceACLPolicy,
-			EnableTransparentProxy:        c.flagDefaultEnableTransparentProxy,
-			TProxyOverwriteProbes:         c.flagTransparentProxyDefaultOverwriteProbes,
-			EnableConsulDNS:               c.flagEnableConsulDNS,
-			ResourcePrefix:                c.flagResourcePrefix,
-			EnableOpenShift:               c.flagEnableOpenShift,
-			Log:                           ctrl.Log.WithName("handler").WithName("connect"),
-			LogLevel:                      c.flagLogLevel,
-			LogJSON:                       c.flagLogJSON,
+			Clientset:                          c.clientset,
+			ConsulClient:                       c.consulClient,
+			ImageConsul:                        c.flagConsulImage,
+			ImageEnvoy:                         c.flagEnvoyImage,
+			EnvoyExtraArgs:                     c.flagEnvoyExtraArgs,
+			ImageConsulK8S:                     c.flagConsulK8sImage,
+			RequireAnnotation:                  !c.flagDefaultInject,
+			AuthMethod:                         c.flagACLAuthMethod,
+			EnableProjectedServiceAccountToken: c.flagEnableProjectedServiceAccountToken,
+			ProjectedTokenAudience:             c.flagProjectedServiceAccountTokenAudience,
+			ProjectedTokenExpirationSeconds:    int64(c.flagProjectedServiceAccountTokenExpiration / time.Second),
+			ConsulCACert:                       string(consulCACert),
+			DefaultProxyCPURequest:             sidecarProxyCPURequest,
+			DefaultProxyCPULimit:               sidecarProxyCPULimit,
+			DefaultProxyMemoryRequest:          sidecarProxyMemoryRequest,
+			DefaultProxyMemoryLimit:            sidecarProxyMemoryLimit,
+			NamespaceSidecarConfigMapName:      c.flagNamespaceSidecarConfigMap,
+			EnableEnvoyBootstrapConfigMaps:     c.flagEnableEnvoyBootstrapConfigMaps,
+			MetricsConfig:                      metricsConfig,
+			InitContainerResources:             initResources,
+			DefaultConsulSidecarResources:      consulSidecarResources,
+			EnableXDSWatchdog:                  c.flagEnableXDSWatchdog,
+			XDSWatchdogThreshold:               c.flagXDSWatchdogThreshold,
+			XDSWatchdogPolicy:                  c.flagXDSWatchdogPolicy,
+			EnableJobCompletion:                c.flagEnableJobCompletion,
+			ConsulPartition:                    c.http.Partition(),
+			AllowK8sNamespacesSet:              allowK8sNamespaces,
+			DenyK8sNamespacesSet:               denyK8sNamespaces,
+			EnableNamespaces:                   c.flagEnableNamespaces,
+			ConsulDestinationNamespace:         c.flagConsulDestinationNamespace,
+			EnableK8SNSMirroring:               c.flagEnableK8SNSMirroring,
+			K8SNSMirroringPrefix:               c.flagK8SNSMirroringPrefix,
+			K8SNSMirroringRules:                k8sNSMirroringRules,
+			CrossNamespaceACLPolicy:            c.flagCrossNamespaceACLPolicy,
+			EnableTransparentProxy:             c.flagDefaultEnableTransparentProxy,
+			TProxyOverwriteProbes:              c.flagTransparentProxyDefaultOverwriteProbes,
+			HoldApplicationUntilProxyStarts:    c.flagDefaultHoldApplicationUntilProxyStarts,
+			TProxyInitMode:                     c.flagTransparentProxyInitMode,
+			EnableTProxyNodeHelper:             c.flagEnableTProxyNodeHelper,
+			EnableNodeProxy:                    c.flagEnableNodeProxy,
+			EnableConsulDNS:                    c.flagEnableConsulDNS,
+			ResourcePrefix:                     c.flagResourcePrefix,
+			EnableOpenShift:                    c.flagEnableOpenShift,
+			ConsulHealth:                       consulHealth,
+			NamespaceCache:                     &namespaces.Cache{},
+			Log:                                ctrl.Log.WithName("handler").WithName("connect"),
+			LogLevel:                           c.flagLogLevel,
+			LogJSON:                            c.flagLogJSON,
 		}})
 
 	if err := mgr.Start(ctx); err != nil {
@@ -595,6 +1086,19 @@ func (c *Command) parseAndValidateResourceFlags() (corev1.ResourceRequirements,
 	return initResources, consulSidecarResources, nil
 }
 
+// parseConfigMapFlag parses the <namespace>/<name> of a ConfigMap from the
+// value of the flag. An empty value is valid.
+func parseConfigMapFlag(flag, value string) (types.NamespacedName, error) {
+	if value == "" {
+		return types.NamespacedName{}, nil
+	}
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return types.NamespacedName{}, fmt.Errorf("-%s %q is invalid, must be of the form <namespace>/<name>", flag, value)
+	}
+	return types.NamespacedName{Namespace: parts[0], Name: parts[1]}, nil
+}
+
 func (c *Command) Synopsis() string { return synopsis }
 func (c *Command) Help() string {
 	c.once.Do(c.init)
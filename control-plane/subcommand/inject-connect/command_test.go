@@ -57,6 +57,67 @@ func TestRun_FlagValidation(t *testing.T) {
 				"-partition", "default"},
 			expErr: "-enable-partitions must be set to 'true' if -partition-name is set",
 		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-transparent-proxy-init-mode", "foo"},
+			expErr: "-transparent-proxy-init-mode \"foo\" is invalid",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-transparent-proxy-init-mode", "node-helper"},
+			expErr: "-enable-transparent-proxy-node-helper must be set if -transparent-proxy-init-mode is \"node-helper\"",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-enable-node-proxy"},
+			expErr: "-enable-transparent-proxy-node-helper must be set if -enable-node-proxy is set",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-enable-projected-service-account-token", "-projected-service-account-token-expiration", "5m"},
+			expErr: "-projected-service-account-token-expiration must be at least 10m",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-deregister-not-ready-after", "-1s"},
+			expErr: "-not-ready-grace-period, -deregister-not-ready-after and -deregister-terminating-after must not be negative",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-xds-watchdog-policy", "evict"},
+			expErr: "-xds-watchdog-policy must be \"log\" or \"restart\"",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-default-envoy-access-log-json-format", "{}", "-default-envoy-access-log-text-format", "%START_TIME%"},
+			expErr: "Invalid -default-envoy-access-log-json-format or -default-envoy-access-log-text-format: " +
+				"only one of the JSON and text access log formats may be set",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-coredns-config-map", "coredns"},
+			expErr: "-coredns-config-map \"coredns\" is invalid, must be of the form <namespace>/<name>",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-node-local-dns-config-map", "kube-system/node-local-dns"},
+			expErr: "-resource-prefix must be set if -coredns-config-map or -node-local-dns-config-map is set",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-webhook-ca-cert-file", "/vault/secrets/ca.crt"},
+			expErr: "-webhook-config-name must be set if -webhook-ca-cert-file is set",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-audit-events"},
+			expErr: "-enable-audit must be set if -audit-events or -audit-webhook-url is set",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-k8s-namespace-mirroring-rules", `[{"replace": "foo"}]`},
+			expErr: "-k8s-namespace-mirroring-rules is invalid: namespace mirroring rule 0: match must be set",
+		},
 		{
 			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
 				"-default-sidecar-proxy-cpu-limit=unparseable"},
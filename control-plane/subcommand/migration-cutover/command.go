@@ -0,0 +1,168 @@
+package migrationcutover
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	connectinject "github.com/hashicorp/consul-k8s/control-plane/connect-inject"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+)
+
+// Command is the command for completing a migration of service registrations
+// from one Consul datacenter to another.
+type Command struct {
+	UI cli.Ui
+
+	flagNodeName string
+	flagForce    bool
+	flagLogLevel string
+	flagLogJSON  bool
+
+	flagSet *flag.FlagSet
+	http    *flags.HTTPFlags
+
+	consulClient *api.Client
+
+	once   sync.Once
+	help   string
+	logger hclog.Logger
+}
+
+func (c *Command) init() {
+	c.flagSet = flag.NewFlagSet("", flag.ContinueOnError)
+	c.flagSet.StringVar(&c.flagNodeName, "migration-node-name", connectinject.DefaultMigrationNodeName,
+		"Name of the node service instances were registered on in the datacenter being migrated to.")
+	c.flagSet.BoolVar(&c.flagForce, "force", false,
+		"Remove the migration registrations even if some services have not been registered by Consul clients yet.")
+	c.flagSet.StringVar(&c.flagLogLevel, "log-level", "info",
+		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
+			"\"debug\", \"info\", \"warn\", and \"error\".")
+	c.flagSet.BoolVar(&c.flagLogJSON, "log-json", false,
+		"Enable or disable JSON output format for logging.")
+
+	c.http = &flags.HTTPFlags{}
+	flags.Merge(c.flagSet, c.http.Flags())
+	c.help = flags.Usage(help, c.flagSet)
+}
+
+func (c *Command) Run(args []string) int {
+	var err error
+	c.once.Do(c.init)
+
+	if err := c.flagSet.Parse(args); err != nil {
+		return 1
+	}
+	if len(c.flagSet.Args()) > 0 {
+		c.UI.Error("Should have no non-flag arguments.")
+		return 1
+	}
+	if c.logger == nil {
+		c.logger, err = common.Logger(c.flagLogLevel, c.flagLogJSON)
+		if err != nil {
+			c.UI.Error(err.Error())
+			return 1
+		}
+	}
+
+	if c.consulClient == nil {
+		cfg := api.DefaultConfig()
+		c.http.MergeOntoConfig(cfg)
+		c.consulClient, err = consul.NewClient(cfg)
+		if err != nil {
+			c.logger.Error("Unable to create Consul client", "error", err)
+			return 1
+		}
+	}
+
+	nodeServices, _, err := c.consulClient.Catalog().NodeServiceList(c.flagNodeName, nil)
+	if err != nil {
+		c.logger.Error("Unable to list services on migration node", "node", c.flagNodeName, "error", err)
+		return 1
+	}
+	if nodeServices == nil {
+		c.logger.Info("Migration node does not exist, nothing to cut over", "node", c.flagNodeName)
+		return 0
+	}
+
+	// Only remove the migration registrations once every service also has an instance
+	// registered by a Consul client, otherwise there would be a gap where the service
+	// has no instances in this datacenter.
+	if !c.flagForce {
+		missing, err := c.servicesWithoutClientInstances(nodeServices.Services)
+		if err != nil {
+			c.logger.Error("Unable to check services for instances registered by Consul clients", "error", err)
+			return 1
+		}
+		if len(missing) > 0 {
+			c.logger.Error("Services do not have any instances registered by Consul clients yet; point the Consul clients "+
+				"at this datacenter before cutting over, or use -force", "services", strings.Join(missing, ","))
+			return 1
+		}
+	}
+
+	_, err = c.consulClient.Catalog().Deregister(&api.CatalogDeregistration{Node: c.flagNodeName}, nil)
+	if err != nil {
+		c.logger.Error("Unable to deregister migration node", "node", c.flagNodeName, "error", err)
+		return 1
+	}
+	c.logger.Info("Cutover complete, deregistered migration node", "node", c.flagNodeName, "services", len(nodeServices.Services))
+	return 0
+}
+
+// servicesWithoutClientInstances returns the sorted names of the services that are only
+// registered on the migration node.
+func (c *Command) servicesWithoutClientInstances(services []*api.AgentService) ([]string, error) {
+	seen := make(map[string]bool)
+	var missing []string
+	for _, svc := range services {
+		key := svc.Namespace + "/" + svc.Service
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		instances, _, err := c.consulClient.Catalog().Service(svc.Service, "", &api.QueryOptions{Namespace: svc.Namespace})
+		if err != nil {
+			return nil, fmt.Errorf("listing instances of service %q: %w", svc.Service, err)
+		}
+		registered := false
+		for _, instance := range instances {
+			if instance.Node != c.flagNodeName {
+				registered = true
+				break
+			}
+		}
+		if !registered {
+			missing = append(missing, svc.Service)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+func (c *Command) Synopsis() string { return synopsis }
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.help
+}
+
+const synopsis = "Complete a migration of service registrations to another Consul datacenter."
+const help = `
+Usage: consul-k8s-control-plane migration-cutover [options]
+
+  Removes the service instances that the endpoints controller registered
+  into the datacenter being migrated to when it was run with
+  -migration-consul-http-addr. Run this against the new datacenter once
+  the Consul clients have been pointed at it and the migration flags have
+  been removed from the connect injector, so that service instances are
+  registered by the Consul clients instead.
+
+`
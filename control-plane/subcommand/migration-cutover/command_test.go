@@ -0,0 +1,92 @@
+package migrationcutover
+
+import (
+	"testing"
+
+	connectinject "github.com/hashicorp/consul-k8s/control-plane/connect-inject"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil"
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_FlagValidation(t *testing.T) {
+	t.Parallel()
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI: ui,
+	}
+	code := cmd.Run([]string{"extra-arg"})
+	require.Equal(t, 1, code)
+	require.Contains(t, ui.ErrorWriter.String(), "Should have no non-flag arguments.")
+}
+
+func TestRun_Cutover(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		clientRegistered bool
+		force            bool
+		expCode          int
+	}{
+		"services registered by clients": {
+			clientRegistered: true,
+			expCode:          0,
+		},
+		"services not registered by clients": {
+			clientRegistered: false,
+			expCode:          1,
+		},
+		"services not registered by clients with force": {
+			clientRegistered: false,
+			force:            true,
+			expCode:          0,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			server, err := testutil.NewTestServerConfigT(t, nil)
+			require.NoError(t, err)
+			defer server.Stop()
+			server.WaitForLeader(t)
+			consulClient, err := api.NewClient(&api.Config{Address: server.HTTPAddr})
+			require.NoError(t, err)
+
+			_, err = consulClient.Catalog().Register(&api.CatalogRegistration{
+				Node:    connectinject.DefaultMigrationNodeName,
+				Address: "127.0.0.1",
+				Service: &api.AgentService{ID: "pod1-web", Service: "web", Address: "1.2.3.4"},
+			}, nil)
+			require.NoError(t, err)
+			if c.clientRegistered {
+				_, err = consulClient.Catalog().Register(&api.CatalogRegistration{
+					Node:    "k8s-node",
+					Address: "127.0.0.1",
+					Service: &api.AgentService{ID: "pod1-web", Service: "web", Address: "1.2.3.4"},
+				}, nil)
+				require.NoError(t, err)
+			}
+
+			ui := cli.NewMockUi()
+			cmd := Command{
+				UI:           ui,
+				consulClient: consulClient,
+			}
+			args := []string{}
+			if c.force {
+				args = append(args, "-force")
+			}
+			code := cmd.Run(args)
+			require.Equal(t, c.expCode, code)
+
+			node, _, err := consulClient.Catalog().Node(connectinject.DefaultMigrationNodeName, nil)
+			require.NoError(t, err)
+			if c.expCode == 0 {
+				require.Nil(t, node)
+			} else {
+				require.NotNil(t, node)
+			}
+		})
+	}
+}
@@ -56,6 +56,7 @@ func (c *Command) init() {
 
 	c.flags.Var((*flags.AppendSliceValue)(&c.flagServerAddresses), "server-address",
 		"The IP, DNS name or the cloud auto-join string of the Consul server(s). If providing IPs or DNS names, may be specified multiple times. "+
+			"The cloud auto-join string may also discover the servers from DNS SRV records, e.g. \"provider=dns-srv name=_consul-server._tcp.example.com\". "+
 			"At least one value is required.")
 	c.flags.UintVar(&c.flagServerPort, "server-port", 8500, "The HTTP or HTTPS port of the Consul server. Defaults to 8500.")
 	c.flags.BoolVar(&c.flagUseHTTPS, "use-https", false,
@@ -116,28 +117,24 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
-	serverAddresses, err := common.GetResolvedServerAddresses(c.flagServerAddresses, c.providers, c.log)
-	if err != nil {
-		c.UI.Error(fmt.Sprintf("Unable to discover any Consul addresses from %q: %s", c.flagServerAddresses[0], err))
-		return 1
+	serverAddresses := &common.ServerAddresses{
+		Addresses: c.flagServerAddresses,
+		Providers: c.providers,
+		Logger:    c.log,
 	}
 
 	scheme := "http"
 	if c.flagUseHTTPS {
 		scheme = "https"
 	}
-	// For all of the next operations we'll need a Consul client.
-	serverAddr := fmt.Sprintf("%s:%d", serverAddresses[0], c.flagServerPort)
-	cfg := api.DefaultConfig()
-	cfg.Address = serverAddr
-	cfg.Scheme = scheme
-	c.http.MergeOntoConfig(cfg)
-	consulClient, err := consul.NewClient(cfg)
-	if err != nil {
-		c.UI.Error(fmt.Sprintf("Error creating Consul client for addr %q: %s", serverAddr, err))
-		return 1
-	}
 	for {
+		// Each attempt uses the next server so that a server that is down
+		// doesn't block the partition from being created.
+		consulClient, err := c.consulClient(serverAddresses, scheme)
+		if err != nil {
+			c.UI.Error(err.Error())
+			return 1
+		}
 		partition, _, err := consulClient.Partitions().Read(c.ctx, c.flagPartitionName, nil)
 		// The API does not return an error if the Partition does not exist. It returns a nil Partition.
 		if err != nil {
@@ -170,6 +167,24 @@ func (c *Command) Run(args []string) int {
 	}
 }
 
+// consulClient returns a Consul client for the next server address.
+func (c *Command) consulClient(serverAddresses *common.ServerAddresses, scheme string) (*api.Client, error) {
+	host, err := serverAddresses.Next()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to discover any Consul addresses from %q: %s", c.flagServerAddresses[0], err)
+	}
+	serverAddr := fmt.Sprintf("%s:%d", host, c.flagServerPort)
+	cfg := api.DefaultConfig()
+	cfg.Address = serverAddr
+	cfg.Scheme = scheme
+	c.http.MergeOntoConfig(cfg)
+	consulClient, err := consul.NewClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating Consul client for addr %q: %s", serverAddr, err)
+	}
+	return consulClient, nil
+}
+
 func (c *Command) validateFlags() error {
 	if len(c.flagServerAddresses) == 0 {
 		return errors.New("-server-address must be set at least once")
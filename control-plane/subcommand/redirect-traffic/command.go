@@ -0,0 +1,190 @@
+package redirecttraffic
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	"github.com/hashicorp/consul-k8s/control-plane/tproxy"
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+const (
+	defaultConfigDir   = "/consul/connect-inject"
+	defaultProxyIDFile = "/consul/connect-inject/proxyid"
+)
+
+type Command struct {
+	UI cli.Ui
+
+	flagProxyIDFile            string
+	flagConsulServiceNamespace string
+	flagProxyUID               string
+	flagConsulDNSIP            string
+	flagExcludeInboundPorts    []string
+	flagExcludeOutboundPorts   []string
+	flagExcludeOutboundCIDRs   []string
+	flagExcludeUIDs            []string
+	flagConfigDir              string
+	flagTimeout                time.Duration
+	flagLogLevel               string
+	flagLogJSON                bool
+
+	flagSet *flag.FlagSet
+	http    *flags.HTTPFlags
+
+	// retryDuration is how often to check whether the node helper has applied
+	// the config. Only set in tests.
+	retryDuration time.Duration
+
+	once sync.Once
+	help string
+}
+
+func (c *Command) init() {
+	c.flagSet = flag.NewFlagSet("", flag.ContinueOnError)
+	c.flagSet.StringVar(&c.flagProxyIDFile, "proxy-id-file", defaultProxyIDFile,
+		"File containing the Consul service ID of the proxy to redirect traffic to.")
+	c.flagSet.StringVar(&c.flagConsulServiceNamespace, "consul-service-namespace", "",
+		"Consul destination namespace of the service.")
+	c.flagSet.StringVar(&c.flagProxyUID, "proxy-uid", "",
+		"The user ID of the proxy to exclude from traffic redirection.")
+	c.flagSet.StringVar(&c.flagConsulDNSIP, "consul-dns-ip", "",
+		"IP of the Consul DNS server to redirect DNS queries to.")
+	c.flagSet.Var((*flags.AppendSliceValue)(&c.flagExcludeInboundPorts), "exclude-inbound-port",
+		"Inbound port to exclude from traffic redirection. May be specified multiple times.")
+	c.flagSet.Var((*flags.AppendSliceValue)(&c.flagExcludeOutboundPorts), "exclude-outbound-port",
+		"Outbound port to exclude from traffic redirection. May be specified multiple times.")
+	c.flagSet.Var((*flags.AppendSliceValue)(&c.flagExcludeOutboundCIDRs), "exclude-outbound-cidr",
+		"Outbound CIDR to exclude from traffic redirection. May be specified multiple times.")
+	c.flagSet.Var((*flags.AppendSliceValue)(&c.flagExcludeUIDs), "exclude-uid",
+		"Additional user ID to exclude from traffic redirection. May be specified multiple times.")
+	c.flagSet.StringVar(&c.flagConfigDir, "config-dir", defaultConfigDir,
+		"Directory shared with the node helper to write the traffic redirection config to.")
+	c.flagSet.DurationVar(&c.flagTimeout, "timeout", 2*time.Minute,
+		"How long to wait for the node helper to apply the traffic redirection config.")
+	c.flagSet.StringVar(&c.flagLogLevel, "log-level", "info",
+		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
+			"\"debug\", \"info\", \"warn\", and \"error\".")
+	c.flagSet.BoolVar(&c.flagLogJSON, "log-json", false,
+		"Enable or disable JSON output format for logging.")
+
+	if c.retryDuration == 0 {
+		c.retryDuration = 1 * time.Second
+	}
+
+	c.http = &flags.HTTPFlags{}
+	flags.Merge(c.flagSet, c.http.Flags())
+	c.help = flags.Usage(help, c.flagSet)
+}
+
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+
+	if err := c.flagSet.Parse(args); err != nil {
+		return 1
+	}
+	if len(c.flagSet.Args()) > 0 {
+		c.UI.Error("Invalid arguments: should have no non-flag arguments")
+		return 1
+	}
+	if c.flagProxyUID == "" {
+		c.UI.Error("-proxy-uid must be set")
+		return 1
+	}
+	if c.flagTimeout <= 0 {
+		c.UI.Error("-timeout must be positive")
+		return 1
+	}
+
+	logger, err := common.Logger(c.flagLogLevel, c.flagLogJSON)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	proxyID, err := os.ReadFile(c.flagProxyIDFile)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Unable to read proxy ID: %s", err))
+		return 1
+	}
+
+	cfg := api.DefaultConfig()
+	cfg.Namespace = c.flagConsulServiceNamespace
+	c.http.MergeOntoConfig(cfg)
+	consulClient, err := consul.NewClient(cfg)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Unable to get client connection: %s", err))
+		return 1
+	}
+
+	redirectCfg, err := tproxy.ConfigFromProxy(consulClient, strings.TrimSpace(string(proxyID)))
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	redirectCfg.ProxyUserID = c.flagProxyUID
+	redirectCfg.ConsulDNSIP = c.flagConsulDNSIP
+	redirectCfg.ExcludeInboundPorts = append(redirectCfg.ExcludeInboundPorts, c.flagExcludeInboundPorts...)
+	redirectCfg.ExcludeOutboundPorts = append(redirectCfg.ExcludeOutboundPorts, c.flagExcludeOutboundPorts...)
+	redirectCfg.ExcludeOutboundCIDRs = append(redirectCfg.ExcludeOutboundCIDRs, c.flagExcludeOutboundCIDRs...)
+	redirectCfg.ExcludeUIDs = append(redirectCfg.ExcludeUIDs, c.flagExcludeUIDs...)
+
+	if err := tproxy.WriteConfig(c.flagConfigDir, redirectCfg); err != nil {
+		c.UI.Error(fmt.Sprintf("Unable to write traffic redirection config: %s", err))
+		return 1
+	}
+	logger.Info("Waiting for the node helper to apply the traffic redirection rules")
+
+	if err := c.waitForNodeHelper(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	logger.Info("Traffic redirection rules have been applied")
+	return 0
+}
+
+// waitForNodeHelper waits until the node helper has created the applied file
+// or written the failed file.
+func (c *Command) waitForNodeHelper() error {
+	deadline := time.Now().Add(c.flagTimeout)
+	for {
+		if _, err := os.Stat(filepath.Join(c.flagConfigDir, tproxy.AppliedFile)); err == nil {
+			return nil
+		}
+		if msg, err := os.ReadFile(filepath.Join(c.flagConfigDir, tproxy.FailedFile)); err == nil {
+			return fmt.Errorf("The node helper failed to apply the traffic redirection rules: %s", strings.TrimSpace(string(msg)))
+		}
+		if time.Now().After(deadline) {
+			return errors.New("Timed out waiting for the node helper to apply the traffic redirection rules. " +
+				"Check that the transparent proxy node helper is running on this node.")
+		}
+		time.Sleep(c.retryDuration)
+	}
+}
+
+func (c *Command) Synopsis() string { return synopsis }
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.help
+}
+
+const synopsis = "Request traffic redirection rules from the transparent proxy node helper."
+const help = `
+Usage: consul-k8s-control-plane redirect-traffic [options]
+
+  Generates the traffic redirection rules for the proxy of this pod and
+  waits until the transparent proxy node helper has applied them in the
+  network namespace of the pod. Used by the connect-inject init container
+  when it is not permitted to apply the rules itself.
+  Not intended for stand-alone use.
+`
@@ -0,0 +1,125 @@
+package redirecttraffic
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/tproxy"
+	"github.com/hashicorp/consul/sdk/iptables"
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_FlagValidation(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		flags  []string
+		expErr string
+	}{
+		{
+			flags:  []string{"foo"},
+			expErr: "Invalid arguments: should have no non-flag arguments",
+		},
+		{
+			flags:  []string{},
+			expErr: "-proxy-uid must be set",
+		},
+		{
+			flags:  []string{"-proxy-uid=5995", "-timeout=0s"},
+			expErr: "-timeout must be positive",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{UI: ui}
+			require.Equal(t, 1, cmd.Run(c.flags))
+			require.Contains(t, ui.ErrorWriter.String(), c.expErr)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		// nodeHelper simulates the node helper by writing to the config dir.
+		nodeHelper func(dir string) error
+		expCode    int
+		expErr     string
+	}{
+		"applied": {
+			nodeHelper: func(dir string) error {
+				return os.WriteFile(filepath.Join(dir, tproxy.AppliedFile), nil, 0444)
+			},
+			expCode: 0,
+		},
+		"failed": {
+			nodeHelper: func(dir string) error {
+				return os.WriteFile(filepath.Join(dir, tproxy.FailedFile), []byte("iptables: not found\n"), 0444)
+			},
+			expCode: 1,
+			expErr:  "The node helper failed to apply the traffic redirection rules: iptables: not found",
+		},
+		"timeout": {
+			nodeHelper: func(string) error { return nil },
+			expCode:    1,
+			expErr:     "Timed out waiting for the node helper to apply the traffic redirection rules.",
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/v1/agent/service/web-sidecar-proxy" {
+					fmt.Fprint(w, `{"ID": "web-sidecar-proxy", "Port": 20000, "Proxy": {"DestinationServiceName": "web"}}`)
+					return
+				}
+				w.WriteHeader(http.StatusNotFound)
+			}))
+			defer server.Close()
+
+			dir := t.TempDir()
+			proxyIDFile := filepath.Join(dir, "proxyid")
+			require.NoError(t, os.WriteFile(proxyIDFile, []byte("web-sidecar-proxy"), 0444))
+
+			// Act as the node helper once the config has been written.
+			go func() {
+				for {
+					if _, err := tproxy.ReadConfig(dir); err == nil {
+						require.NoError(t, c.nodeHelper(dir))
+						return
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+			}()
+
+			ui := cli.NewMockUi()
+			cmd := Command{UI: ui, retryDuration: 10 * time.Millisecond}
+			code := cmd.Run([]string{
+				"-http-addr", server.URL,
+				"-proxy-id-file", proxyIDFile,
+				"-config-dir", dir,
+				"-proxy-uid=5995",
+				"-exclude-outbound-cidr=10.0.0.0/8",
+				"-timeout=500ms",
+			})
+			require.Equal(t, c.expCode, code, ui.ErrorWriter.String())
+			require.Contains(t, ui.ErrorWriter.String(), c.expErr)
+
+			cfg, err := tproxy.ReadConfig(dir)
+			require.NoError(t, err)
+			require.Equal(t, iptables.Config{
+				ProxyUserID:          "5995",
+				ProxyInboundPort:     20000,
+				ProxyOutboundPort:    iptables.DefaultTProxyOutboundPort,
+				ExcludeOutboundCIDRs: []string{"10.0.0.0/8"},
+			}, cfg)
+		})
+	}
+}
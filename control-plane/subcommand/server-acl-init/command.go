@@ -44,11 +44,14 @@ type Command struct {
 	flagSyncCatalog        bool
 	flagSyncConsulNodeName string
 
-	flagConnectInject       bool
-	flagAuthMethodHost      string
-	flagBindingRuleSelector string
+	flagConnectInject             bool
+	flagConnectInjectPeerFailover bool
+	flagAuthMethodHost            string
+	flagBindingRuleSelector       string
 
-	flagController bool
+	flagController                 bool
+	flagControllerGossipKeyring    bool
+	flagControllerExternalServices bool
 
 	flagCreateEntLicenseToken bool
 
@@ -134,6 +137,8 @@ func (c *Command) init() {
 
 	c.flags.BoolVar(&c.flagConnectInject, "connect-inject", false,
 		"Toggle for configuring ACL login for Connect inject.")
+	c.flags.BoolVar(&c.flagConnectInjectPeerFailover, "connect-inject-peer-failover", false,
+		"Toggle for allowing Connect inject to read peerings to write service resolvers that fail over to peers.")
 	c.flags.StringVar(&c.flagAuthMethodHost, "auth-method-host", "",
 		"Kubernetes Host config parameter for the auth method."+
 			"If not provided, the default cluster Kubernetes service will be used.")
@@ -142,6 +147,10 @@ func (c *Command) init() {
 
 	c.flags.BoolVar(&c.flagController, "controller", false,
 		"Toggle for configuring ACL login for the controller.")
+	c.flags.BoolVar(&c.flagControllerGossipKeyring, "controller-gossip-keyring", false,
+		"Toggle for allowing the controller to manage the gossip encryption keyring.")
+	c.flags.BoolVar(&c.flagControllerExternalServices, "controller-external-services", false,
+		"Toggle for allowing the controller to register external services on their own Consul node.")
 
 	c.flags.BoolVar(&c.flagCreateEntLicenseToken, "create-enterprise-license-token", false,
 		"Toggle for creating a token for the enterprise license job.")
@@ -162,6 +171,7 @@ func (c *Command) init() {
 
 	c.flags.Var((*flags.AppendSliceValue)(&c.flagServerAddresses), "server-address",
 		"The IP, DNS name or the cloud auto-join string of the Consul server(s). If providing IPs or DNS names, may be specified multiple times. "+
+			"The cloud auto-join string may also discover the servers from DNS SRV records, e.g. \"provider=dns-srv name=_consul-server._tcp.example.com\". "+
 			"At least one value is required.")
 	c.flags.UintVar(&c.flagServerPort, "server-port", 8500, "The HTTP or HTTPS port of the Consul server. Defaults to 8500.")
 	c.flags.StringVar(&c.flagConsulCACert, "consul-ca-cert", "",
@@ -17,6 +17,9 @@ type rulesData struct {
 	InjectEnableNSMirroring bool
 	InjectNSMirroringPrefix string
 	SyncConsulNodeName      string
+	ManageGossipKeyring     bool
+	ManageExternalServices  bool
+	InjectPeerFailover      bool
 }
 
 type gatewayRulesData struct {
@@ -295,7 +298,8 @@ func (c *Command) injectRules() (string, error) {
 	// It must also create/update service health checks via the endpoints controller.
 	// When ACLs are enabled, the endpoints controller needs "acl:write" permissions
 	// to delete ACL tokens created via "consul login". policy = "write" is required when
-	// creating namespaces within a partition.
+	// creating namespaces within a partition. Writing service resolvers that fail over to peers
+	// requires "peering:read" to find the peers that import a service.
 	injectRulesTpl := `
 {{- if .EnablePartitions }}
 partition "{{ .PartitionName }}" {
@@ -307,6 +311,9 @@ partition "{{ .PartitionName }}" {
   node_prefix "" {
     policy = "write"
   }
+{{- if .InjectPeerFailover }}
+  peering = "read"
+{{- end }}
 {{- if .EnableNamespaces }}
   namespace_prefix "" {
 {{- end }}
@@ -394,9 +401,17 @@ partition "{{ .PartitionName }}" {
 {{- if .EnableNamespaces }}
   }
 {{- end }}
+{{- if .ManageExternalServices }}
+  node_prefix "" {
+    policy = "write"
+  }
+{{- end }}
 {{- if .EnablePartitions }}
 }
 {{- end }}
+{{- if .ManageGossipKeyring }}
+keyring = "write"
+{{- end }}
 `
 	return c.renderRules(controllerRules)
 }
@@ -413,6 +428,9 @@ func (c *Command) rulesData() rulesData {
 		InjectEnableNSMirroring: c.flagEnableInjectK8SNSMirroring,
 		InjectNSMirroringPrefix: c.flagInjectK8SNSMirroringPrefix,
 		SyncConsulNodeName:      c.flagSyncConsulNodeName,
+		ManageGossipKeyring:     c.flagControllerGossipKeyring,
+		ManageExternalServices:  c.flagControllerExternalServices,
+		InjectPeerFailover:      c.flagConnectInjectPeerFailover,
 	}
 }
 
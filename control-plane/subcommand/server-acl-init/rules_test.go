@@ -667,6 +667,7 @@ func TestInjectRules(t *testing.T) {
 		EnableNamespaces bool
 		EnablePartitions bool
 		PartitionName    string
+		PeerFailover     bool
 		Expected         string
 	}{
 		{
@@ -712,18 +713,53 @@ partition "part-1" {
       policy = "write"
     }
   }
+}`,
+		},
+		{
+			EnableNamespaces: false,
+			EnablePartitions: false,
+			PeerFailover:     true,
+			Expected: `
+  node_prefix "" {
+    policy = "write"
+  }
+  peering = "read"
+    acl = "write"
+    service_prefix "" {
+      policy = "write"
+    }`,
+		},
+		{
+			EnableNamespaces: true,
+			EnablePartitions: true,
+			PartitionName:    "part-1",
+			PeerFailover:     true,
+			Expected: `
+partition "part-1" {
+  node_prefix "" {
+    policy = "write"
+  }
+  peering = "read"
+  namespace_prefix "" {
+    policy = "write"
+    acl = "write"
+    service_prefix "" {
+      policy = "write"
+    }
+  }
 }`,
 		},
 	}
 
 	for _, tt := range cases {
-		caseName := fmt.Sprintf("ns=%t, partition=%t", tt.EnableNamespaces, tt.EnablePartitions)
+		caseName := fmt.Sprintf("ns=%t, partition=%t, peerFailover=%t", tt.EnableNamespaces, tt.EnablePartitions, tt.PeerFailover)
 		t.Run(caseName, func(t *testing.T) {
 
 			cmd := Command{
-				flagEnablePartitions: tt.EnablePartitions,
-				flagPartitionName:    tt.PartitionName,
-				flagEnableNamespaces: tt.EnableNamespaces,
+				flagEnablePartitions:          tt.EnablePartitions,
+				flagPartitionName:             tt.PartitionName,
+				flagEnableNamespaces:          tt.EnableNamespaces,
+				flagConnectInjectPeerFailover: tt.PeerFailover,
 			}
 
 			injectorRules, err := cmd.injectRules()
@@ -825,6 +861,8 @@ func TestControllerRules(t *testing.T) {
 		DestConsulNS     string
 		Mirroring        bool
 		MirroringPrefix  string
+		GossipKeyring    bool
+		ExternalServices bool
 		Expected         string
 	}{
 		{
@@ -936,6 +974,68 @@ partition "part-1" {
       intentions = "write"
     }
   }
+}`,
+		},
+		{
+			Name:          "gossipKeyring=true, partitions=disabled",
+			GossipKeyring: true,
+			Expected: `
+  operator = "write"
+  acl = "write"
+    service_prefix "" {
+      policy = "write"
+      intentions = "write"
+    }
+keyring = "write"`,
+		},
+		{
+			Name:             "gossipKeyring=true, partitions=enabled",
+			EnablePartitions: true,
+			PartitionName:    "part-1",
+			GossipKeyring:    true,
+			Expected: `
+partition "part-1" {
+  mesh = "write"
+  acl = "write"
+    policy = "write"
+    service_prefix "" {
+      policy = "write"
+      intentions = "write"
+    }
+}
+keyring = "write"`,
+		},
+		{
+			Name:             "externalServices=true, partitions=disabled",
+			ExternalServices: true,
+			Expected: `
+  operator = "write"
+  acl = "write"
+    service_prefix "" {
+      policy = "write"
+      intentions = "write"
+    }
+  node_prefix "" {
+    policy = "write"
+  }`,
+		},
+		{
+			Name:             "externalServices=true, partitions=enabled",
+			EnablePartitions: true,
+			PartitionName:    "part-1",
+			ExternalServices: true,
+			Expected: `
+partition "part-1" {
+  mesh = "write"
+  acl = "write"
+    policy = "write"
+    service_prefix "" {
+      policy = "write"
+      intentions = "write"
+    }
+  node_prefix "" {
+    policy = "write"
+  }
 }`,
 		},
 	}
@@ -949,6 +1049,8 @@ partition "part-1" {
 				flagInjectK8SNSMirroringPrefix:       tt.MirroringPrefix,
 				flagEnablePartitions:                 tt.EnablePartitions,
 				flagPartitionName:                    tt.PartitionName,
+				flagControllerGossipKeyring:          tt.GossipKeyring,
+				flagControllerExternalServices:       tt.ExternalServices,
 			}
 
 			rules, err := cmd.controllerRules()
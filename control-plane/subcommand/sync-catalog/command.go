@@ -2,6 +2,7 @@ package synccatalog
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
@@ -10,18 +11,21 @@ import (
 	"regexp"
 	"sync"
 	"syscall"
+	"text/template"
 	"time"
 
-	mapset "github.com/deckarep/golang-set"
 	catalogtoconsul "github.com/hashicorp/consul-k8s/control-plane/catalog/to-consul"
 	catalogtok8s "github.com/hashicorp/consul-k8s/control-plane/catalog/to-k8s"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/controller"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/go-hclog"
 	"github.com/mitchellh/cli"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
@@ -41,6 +45,7 @@ type Command struct {
 	flagConsulDomain          string
 	flagConsulK8STag          string
 	flagConsulNodeName        string
+	flagClusterID             string
 	flagK8SDefault            bool
 	flagK8SServicePrefix      string
 	flagConsulServicePrefix   string
@@ -51,20 +56,34 @@ type Command struct {
 	flagSyncLBEndpoints       bool
 	flagNodePortSyncType      string
 	flagAddK8SNamespaceSuffix bool
+	flagPortTagTemplate       string
+	flagTopologyLabels        []string
 	flagLogLevel              string
 	flagLogJSON               bool
+	flagEnableAudit           bool
+	flagAuditWebhookURL       string
 
 	// Flags to support namespaces
 	flagEnableNamespaces           bool     // Use namespacing on all components
 	flagConsulDestinationNamespace string   // Consul namespace to register everything if not mirroring
 	flagAllowK8sNamespacesList     []string // K8s namespaces to explicitly inject
 	flagDenyK8sNamespacesList      []string // K8s namespaces to deny injection (has precedence)
+	flagK8SNamespaceSelector       string   // Label selector that the namespaces of synced services must match
+	flagK8SServiceSelector         string   // Label selector that synced services must match
+	flagAllowK8sServicesList       []string // Regular expressions of which one must match "<namespace>/<name>" of synced services
+	flagDenyK8sServicesList        []string // Regular expressions of "<namespace>/<name>" of services to deny (has precedence)
+	flagFilterConfigMap            string   // Name of the ConfigMap with the filter that replaces the filter flags
+	flagFilterConfigMapNamespace   string   // Namespace of the filter ConfigMap
 	flagEnableK8SNSMirroring       bool     // Enables mirroring of k8s namespaces into Consul
 	flagK8SNSMirroringPrefix       string   // Prefix added to Consul namespaces created when mirroring
+	flagK8SNSMirroringRules        string   // JSON list of rules rewriting k8s namespace names when mirroring
 	flagCrossNamespaceACLPolicy    string   // The name of the ACL policy to add to every created namespace if ACLs are enabled
 
-	consulClient *api.Client
-	clientset    kubernetes.Interface
+	consulClient        *api.Client
+	clientset           kubernetes.Interface
+	portTagTemplate     *template.Template
+	k8sNSMirroringRules namespaces.MirroringRules
+	filter              *catalogtoconsul.ServiceFilter
 
 	once   sync.Once
 	sigCh  chan os.Signal
@@ -103,6 +122,9 @@ func (c *Command) init() {
 	c.flags.StringVar(&c.flagConsulNodeName, "consul-node-name", "k8s-sync",
 		"The Consul node name to register for catalog sync. Defaults to k8s-sync. To be discoverable "+
 			"via DNS, the name should only contain alpha-numerics and dashes.")
+	c.flags.StringVar(&c.flagClusterID, "cluster-id", "",
+		"ID of the Kubernetes cluster, when several clusters sync services into the same Consul datacenter. "+
+			"It is added to the meta of the synced services, and the services of other clusters are never overwritten or deregistered.")
 	c.flags.DurationVar(&c.flagConsulWritePeriod, "consul-write-interval", 30*time.Second,
 		"The interval to perform syncing operations creating Consul services, formatted "+
 			"as a time.Duration. All changes are merged and write calls are only made "+
@@ -120,16 +142,46 @@ func (c *Command) init() {
 		"If true, Kubernetes namespace will be appended to service names synced to Consul separated by a dash. "+
 			"If false, no suffix will be appended to the service names in Consul. "+
 			"If the service name annotation is provided, the suffix is not appended.")
+	c.flags.StringVar(&c.flagPortTagTemplate, "port-tag-template", "",
+		"Go template executed for each port of a Kubernetes service to generate additional tags for the service "+
+			"synced to Consul, e.g. \"{{ .Name }}-{{ .AppProtocol }}\". The template can use the fields "+
+			"Name, Port, NodePort, Protocol and AppProtocol of the port. Empty results are ignored.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagTopologyLabels), "topology-label",
+		"Key of a node label, e.g. topology.kubernetes.io/zone, whose value is added to the meta of the service "+
+			"instances of endpoints on the node. May be specified multiple times.")
 	c.flags.StringVar(&c.flagLogLevel, "log-level", "info",
 		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
 			"\"debug\", \"info\", \"warn\", and \"error\".")
 	c.flags.BoolVar(&c.flagLogJSON, "log-json", false,
 		"Enable or disable JSON output format for logging.")
+	c.flags.BoolVar(&c.flagEnableAudit, "enable-audit", false,
+		"Log every service instance synced to or deregistered from Consul, with the difference to what was last synced, "+
+			"as an audit trail of the changes to the catalog.")
+	c.flags.StringVar(&c.flagAuditWebhookURL, "audit-webhook-url", "",
+		"URL the writes to Consul are posted to as JSON. Requires -enable-audit.")
 
 	c.flags.Var((*flags.AppendSliceValue)(&c.flagAllowK8sNamespacesList), "allow-k8s-namespace",
 		"K8s namespaces to explicitly allow. May be specified multiple times.")
 	c.flags.Var((*flags.AppendSliceValue)(&c.flagDenyK8sNamespacesList), "deny-k8s-namespace",
 		"K8s namespaces to explicitly deny. Takes precedence over allow. May be specified multiple times.")
+	c.flags.StringVar(&c.flagK8SNamespaceSelector, "k8s-namespace-selector", "",
+		"Label selector, e.g. \"env in (prod,staging)\", that the labels of the k8s namespace of a service "+
+			"must match for the service to be synced.")
+	c.flags.StringVar(&c.flagK8SServiceSelector, "k8s-service-selector", "",
+		"Label selector that the labels of a k8s service must match for it to be synced.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagAllowK8sServicesList), "allow-k8s-service",
+		"Regular expression matched against the whole \"<namespace>/<name>\" of k8s services, e.g. \"payments/.*\". "+
+			"If set, only matching services are synced. May be specified multiple times.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagDenyK8sServicesList), "deny-k8s-service",
+		"Regular expression matched against the whole \"<namespace>/<name>\" of k8s services that are never synced. "+
+			"Takes precedence over allow. May be specified multiple times.")
+	c.flags.StringVar(&c.flagFilterConfigMap, "filter-config-map", "",
+		fmt.Sprintf("Name of a ConfigMap whose %q key holds the service filter as YAML, with the keys allowNamespaces, "+
+			"denyNamespaces, namespaceSelector, serviceSelector, allowServices and denyServices. While the ConfigMap "+
+			"exists, its filter replaces the filter of the namespace and service flags, and it is reloaded "+
+			"whenever the ConfigMap changes.", catalogtoconsul.FilterConfigMapKey))
+	c.flags.StringVar(&c.flagFilterConfigMapNamespace, "filter-config-map-namespace", metav1.NamespaceDefault,
+		"The Kubernetes namespace of the filter ConfigMap.")
 	c.flags.BoolVar(&c.flagEnableNamespaces, "enable-namespaces", false,
 		"[Enterprise Only] Enables namespaces, in either a single Consul namespace or mirrored.")
 	c.flags.StringVar(&c.flagConsulDestinationNamespace, "consul-destination-namespace", "default",
@@ -139,6 +191,10 @@ func (c *Command) init() {
 		"namespace mirroring.")
 	c.flags.StringVar(&c.flagK8SNSMirroringPrefix, "k8s-namespace-mirroring-prefix", "",
 		"[Enterprise Only] Prefix that will be added to all k8s namespaces mirrored into Consul if mirroring is enabled.")
+	c.flags.StringVar(&c.flagK8SNSMirroringRules, "k8s-namespace-mirroring-rules", "",
+		"[Enterprise Only] JSON list of rules that rewrite the names of k8s namespaces mirrored into Consul, e.g. "+
+			"'[{\"match\": \"team-(.*)\", \"replace\": \"$1\"}]'. The first rule whose regular expression matches the whole "+
+			"namespace name is applied before the mirroring prefix is added.")
 	c.flags.StringVar(&c.flagCrossNamespaceACLPolicy, "consul-cross-namespace-acl-policy", "",
 		"[Enterprise Only] Name of the ACL policy to attach to all created Consul namespaces to allow service "+
 			"discovery across Consul namespaces. Only necessary if ACLs are enabled.")
@@ -210,16 +266,35 @@ func (c *Command) Run(args []string) int {
 		}
 	}
 
-	// Convert allow/deny lists to sets
-	allowSet := flags.ToSet(c.flagAllowK8sNamespacesList)
-	denySet := flags.ToSet(c.flagDenyK8sNamespacesList)
-	if c.flagK8SSourceNamespace != "" {
-		// For backwards compatibility, if `flagK8SSourceNamespace` is set,
-		// it will be the only allowed namespace
-		allowSet = mapset.NewSet(c.flagK8SSourceNamespace)
+	// The filter of the ConfigMap replaces the filter of the flags if it
+	// exists at startup, so that services aren't synced before it is loaded.
+	filter := c.filter
+	if c.flagFilterConfigMap != "" {
+		cm, err := c.clientset.CoreV1().ConfigMaps(c.flagFilterConfigMapNamespace).Get(context.Background(), c.flagFilterConfigMap, metav1.GetOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			c.UI.Error(fmt.Sprintf("Error getting filter ConfigMap: %s", err))
+			return 1
+		}
+		if err == nil {
+			data, ok := cm.Data[catalogtoconsul.FilterConfigMapKey]
+			if !ok {
+				err = fmt.Errorf("missing key %q", catalogtoconsul.FilterConfigMapKey)
+			}
+			var cfg catalogtoconsul.FilterConfig
+			if err == nil {
+				cfg, err = catalogtoconsul.ParseFilterConfig(data)
+			}
+			if err == nil {
+				filter, err = cfg.Filter()
+			}
+			if err != nil {
+				c.UI.Error(fmt.Sprintf("Filter ConfigMap %s/%s is invalid: %s", c.flagFilterConfigMapNamespace, c.flagFilterConfigMap, err))
+				return 1
+			}
+		}
 	}
-	c.logger.Info("K8s namespace syncing configuration", "k8s namespaces allowed to be synced", allowSet,
-		"k8s namespaces denied from syncing", denySet)
+	c.logger.Info("K8s namespace syncing configuration", "k8s namespaces allowed to be synced", filter.AllowNamespaces,
+		"k8s namespaces denied from syncing", filter.DenyNamespaces)
 
 	// Create the context we'll use to cancel everything
 	ctx, cancelF := context.WithCancel(context.Background())
@@ -251,33 +326,69 @@ func (c *Command) Run(args []string) int {
 			ServicePollPeriod:        c.flagConsulWritePeriod * 2,
 			ConsulK8STag:             c.flagConsulK8STag,
 			ConsulNodeName:           c.flagConsulNodeName,
+			ClusterID:                c.flagClusterID,
 			ConsulNodeServicesClient: svcsClient,
 		}
+		if c.flagEnableAudit {
+			auditLog, err := common.ZapLogger(c.flagLogLevel, c.flagLogJSON)
+			if err != nil {
+				c.UI.Error(fmt.Sprintf("Error setting up audit logging: %s", err))
+				cancelF()
+				return 1
+			}
+			syncer.Audit = &consul.AuditTrail{
+				Component:  "sync-catalog",
+				Log:        auditLog.WithName("audit"),
+				WebhookURL: c.flagAuditWebhookURL,
+			}
+			go syncer.Audit.Start(ctx)
+		}
 		go syncer.Run(ctx)
 
 		// Build the controller and start it
+		serviceResource := &catalogtoconsul.ServiceResource{
+			Log:                        c.logger.Named("to-consul/source"),
+			Client:                     c.clientset,
+			Syncer:                     syncer,
+			Ctx:                        ctx,
+			Filter:                     filter,
+			ExplicitEnable:             !c.flagK8SDefault,
+			ClusterIPSync:              c.flagSyncClusterIPServices,
+			LoadBalancerEndpointsSync:  c.flagSyncLBEndpoints,
+			NodePortSync:               catalogtoconsul.NodePortSyncType(c.flagNodePortSyncType),
+			ConsulK8STag:               c.flagConsulK8STag,
+			ConsulServicePrefix:        c.flagConsulServicePrefix,
+			AddK8SNamespaceSuffix:      c.flagAddK8SNamespaceSuffix,
+			EnableNamespaces:           c.flagEnableNamespaces,
+			ConsulDestinationNamespace: c.flagConsulDestinationNamespace,
+			EnableK8SNSMirroring:       c.flagEnableK8SNSMirroring,
+			K8SNSMirroringPrefix:       c.flagK8SNSMirroringPrefix,
+			K8SNSMirroringRules:        c.k8sNSMirroringRules,
+			ConsulNodeName:             c.flagConsulNodeName,
+			ClusterID:                  c.flagClusterID,
+			PortTagTemplate:            c.portTagTemplate,
+			TopologyLabels:             c.flagTopologyLabels,
+		}
 		ctl := &controller.Controller{
-			Log: c.logger.Named("to-consul/controller"),
-			Resource: &catalogtoconsul.ServiceResource{
-				Log:                        c.logger.Named("to-consul/source"),
-				Client:                     c.clientset,
-				Syncer:                     syncer,
-				Ctx:                        ctx,
-				AllowK8sNamespacesSet:      allowSet,
-				DenyK8sNamespacesSet:       denySet,
-				ExplicitEnable:             !c.flagK8SDefault,
-				ClusterIPSync:              c.flagSyncClusterIPServices,
-				LoadBalancerEndpointsSync:  c.flagSyncLBEndpoints,
-				NodePortSync:               catalogtoconsul.NodePortSyncType(c.flagNodePortSyncType),
-				ConsulK8STag:               c.flagConsulK8STag,
-				ConsulServicePrefix:        c.flagConsulServicePrefix,
-				AddK8SNamespaceSuffix:      c.flagAddK8SNamespaceSuffix,
-				EnableNamespaces:           c.flagEnableNamespaces,
-				ConsulDestinationNamespace: c.flagConsulDestinationNamespace,
-				EnableK8SNSMirroring:       c.flagEnableK8SNSMirroring,
-				K8SNSMirroringPrefix:       c.flagK8SNSMirroringPrefix,
-				ConsulNodeName:             c.flagConsulNodeName,
-			},
+			Log:      c.logger.Named("to-consul/controller"),
+			Resource: serviceResource,
+		}
+
+		// Reload the filter whenever its ConfigMap changes.
+		if c.flagFilterConfigMap != "" {
+			filterCtl := &controller.Controller{
+				Log: c.logger.Named("to-consul/filter-controller"),
+				Resource: &catalogtoconsul.FilterConfigMapResource{
+					Log:       c.logger.Named("to-consul/filter"),
+					Client:    c.clientset,
+					Ctx:       ctx,
+					Namespace: c.flagFilterConfigMapNamespace,
+					Name:      c.flagFilterConfigMap,
+					Default:   c.filter,
+					Service:   serviceResource,
+				},
+			}
+			go filterCtl.Run(ctx.Done())
 		}
 
 		toConsulCh = make(chan struct{})
@@ -411,6 +522,43 @@ func (c *Command) validateFlags() error {
 		)
 	}
 
+	if c.flagAuditWebhookURL != "" && !c.flagEnableAudit {
+		return errors.New("-enable-audit must be set if -audit-webhook-url is set")
+	}
+
+	if c.flagPortTagTemplate != "" {
+		tmpl, err := template.New("port-tag").Option("missingkey=error").Parse(c.flagPortTagTemplate)
+		if err != nil {
+			return fmt.Errorf("-port-tag-template is invalid: %s", err)
+		}
+		c.portTagTemplate = tmpl
+	}
+
+	// For backwards compatibility, if `-k8s-source-namespace` is set, it will
+	// be the only allowed namespace.
+	filterCfg := catalogtoconsul.FilterConfig{
+		AllowNamespaces:   append([]string{}, c.flagAllowK8sNamespacesList...),
+		DenyNamespaces:    c.flagDenyK8sNamespacesList,
+		NamespaceSelector: c.flagK8SNamespaceSelector,
+		ServiceSelector:   c.flagK8SServiceSelector,
+		AllowServices:     c.flagAllowK8sServicesList,
+		DenyServices:      c.flagDenyK8sServicesList,
+	}
+	if c.flagK8SSourceNamespace != "" {
+		filterCfg.AllowNamespaces = []string{c.flagK8SSourceNamespace}
+	}
+	filter, err := filterCfg.Filter()
+	if err != nil {
+		return fmt.Errorf("service filter flags are invalid: %s", err)
+	}
+	c.filter = filter
+
+	rules, err := namespaces.ParseMirroringRules(c.flagK8SNSMirroringRules)
+	if err != nil {
+		return fmt.Errorf("-k8s-namespace-mirroring-rules is invalid: %s", err)
+	}
+	c.k8sNSMirroringRules = rules
+
 	return nil
 }
 
@@ -36,6 +36,14 @@ func TestRun_FlagValidation(t *testing.T) {
 			ExpErr: "-consul-node-name=5r9OPGfSRXUdGzNjBdAwmhCBrzHDNYs4XjZVR4wp7lSLIzqwS0ta51nBLIN0TMPV-too-long is invalid: node name will not be discoverable " +
 				"via DNS due to it being too long. Valid lengths are between 1 and 63 bytes",
 		},
+		{
+			Flags:  []string{"-port-tag-template={{ .Name"},
+			ExpErr: "-port-tag-template is invalid: template: port-tag:1: unclosed action",
+		},
+		{
+			Flags:  []string{`-k8s-namespace-mirroring-rules=[{"match": "team-(.*"}]`},
+			ExpErr: "-k8s-namespace-mirroring-rules is invalid: namespace mirroring rule 0: replace must be set",
+		},
 	}
 
 	for _, c := range cases {
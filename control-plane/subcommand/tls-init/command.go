@@ -2,12 +2,20 @@ package tls_init
 
 import (
 	"context"
+	"crypto"
+	"crypto/x509"
 	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/hashicorp/consul-k8s/control-plane/helper/cert"
@@ -49,12 +57,19 @@ type Command struct {
 	flagK8sNamespace string
 	flagNamePrefix   string
 
+	// flags that keep the command running to renew the server certificate
+	// before it expires.
+	flagRenewWithin   time.Duration
+	flagCheckInterval time.Duration
+	flagMetricsAddr   string
+
 	// log
 	log          hclog.Logger
 	flagLogLevel string
 	flagLogJSON  bool
 
-	ctx context.Context
+	ctx   context.Context
+	sigCh chan os.Signal
 
 	once sync.Once
 	help string
@@ -225,20 +240,77 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
+	if err := c.syncServerCert(name, hosts, caCert, signer); err != nil {
+		c.log.Error(err.Error())
+		return 1
+	}
+	if c.flagCheckInterval == 0 {
+		return 0
+	}
+
+	// Keep running and renew the server certificate before it expires.
+	if c.sigCh == nil {
+		c.sigCh = make(chan os.Signal, 1)
+		signal.Notify(c.sigCh, syscall.SIGINT, syscall.SIGTERM)
+	}
+	if c.flagMetricsAddr != "" {
+		go func() {
+			if err := http.ListenAndServe(c.flagMetricsAddr, cert.MetricsHandler()); err != nil {
+				c.log.Error("error serving metrics", "err", err)
+			}
+		}()
+	}
+	ticker := time.NewTicker(c.flagCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// Errors are retried on the next check, which is well before the
+			// certificate expires.
+			if err := c.syncServerCert(name, hosts, caCert, signer); err != nil {
+				c.log.Error(err.Error())
+			}
+		case sig := <-c.sigCh:
+			c.log.Info(fmt.Sprintf("%s received, shutting down", sig))
+			return 0
+		}
+	}
+}
+
+// syncServerCert creates or updates the secret with the server certificate and
+// private key. If -renew-within is set, an existing certificate is only
+// replaced if it expires within that duration, was not signed by the CA or
+// doesn't have the given hosts as SANs.
+func (c *Command) syncServerCert(name string, hosts []string, caCert *x509.Certificate, signer crypto.Signer) error {
+	secretName := fmt.Sprintf("%s-server-cert", c.flagNamePrefix)
+	serverCertSecret, err := c.clientset.CoreV1().Secrets(c.flagK8sNamespace).Get(c.ctx, secretName, metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("error reading server certificate secret from kubernetes: %s", err)
+	}
+	exists := err == nil
+	if exists && c.flagRenewWithin > 0 {
+		existing := serverCertSecret.Data[corev1.TLSCertKey]
+		reason := renewalReason(existing, caCert, hosts, c.flagRenewWithin)
+		if reason == "" {
+			c.log.Debug("server certificate is up to date")
+			c.recordExpiry(secretName, existing)
+			return nil
+		}
+		c.log.Info("renewing server certificate", "reason", reason)
+	}
+
 	c.log.Info("generating server certificate and private key")
 	serverCert, serverKey, err := cert.GenerateCert(name, c.getDaysAsDuration(), caCert, signer, hosts)
 	if err != nil {
-		c.log.Error("error generating server certificate and private key", "err", err)
-		return 1
+		return fmt.Errorf("error generating server certificate and private key: %s", err)
 	}
 
-	serverCertSecret, err := c.clientset.CoreV1().Secrets(c.flagK8sNamespace).Get(c.ctx, fmt.Sprintf("%s-server-cert", c.flagNamePrefix), metav1.GetOptions{})
-	if err != nil && k8serrors.IsNotFound(err) {
+	if !exists {
 		c.log.Info("creating server certificate and private key secret")
 		_, err := c.clientset.CoreV1().Secrets(c.flagK8sNamespace).Create(c.ctx, &corev1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
 				Namespace: c.flagK8sNamespace,
-				Name:      fmt.Sprintf("%s-server-cert", c.flagNamePrefix),
+				Name:      secretName,
 				Labels:    map[string]string{common.CLILabelKey: common.CLILabelValue},
 			},
 			Data: map[string][]byte{
@@ -248,10 +320,9 @@ func (c *Command) Run(args []string) int {
 			Type: corev1.SecretTypeTLS,
 		}, metav1.CreateOptions{})
 		if err != nil {
-			c.log.Error("error creating server certificate secret in kubernetes", "err", err)
-			return 1
+			return fmt.Errorf("error creating server certificate secret in kubernetes: %s", err)
 		}
-	} else if err == nil {
+	} else {
 		serverCertSecret.Data = map[string][]byte{
 			corev1.TLSCertKey:       []byte(serverCert),
 			corev1.TLSPrivateKeyKey: []byte(serverKey),
@@ -266,15 +337,52 @@ func (c *Command) Run(args []string) int {
 		c.log.Info("updating server certificate and private key secret")
 		_, err := c.clientset.CoreV1().Secrets(c.flagK8sNamespace).Update(c.ctx, serverCertSecret, metav1.UpdateOptions{})
 		if err != nil {
-			c.log.Error("error updating server certificate secret in kubernetes", "err", err)
-			return 1
+			return fmt.Errorf("error updating server certificate secret in kubernetes: %s", err)
 		}
-	} else {
-		c.log.Error("error reading server certificate secret from kubernetes", "err", err)
-		return 1
+	}
+	c.recordExpiry(secretName, []byte(serverCert))
+	return nil
+}
+
+// recordExpiry records the expiry of the server certificate for the metrics.
+func (c *Command) recordExpiry(secretName string, certPEM []byte) {
+	if err := cert.RecordExpiry(c.flagK8sNamespace, secretName, certPEM); err != nil {
+		c.log.Warn("unable to record the expiry of the server certificate", "err", err)
+	}
+}
+
+// renewalReason returns why the PEM-encoded certificate needs to be renewed,
+// or an empty string if it doesn't.
+func renewalReason(certPEM []byte, caCert *x509.Certificate, hosts []string, renewWithin time.Duration) string {
+	existing, err := cert.ParseCert(certPEM)
+	if err != nil {
+		return fmt.Sprintf("invalid certificate: %s", err)
+	}
+	if err := existing.CheckSignatureFrom(caCert); err != nil {
+		return "certificate is not signed by the CA"
+	}
+	if time.Until(existing.NotAfter) < renewWithin {
+		return fmt.Sprintf("certificate expires at %s", existing.NotAfter.Format(time.RFC3339))
 	}
 
-	return 0
+	sans := make(map[string]bool)
+	for _, d := range existing.DNSNames {
+		sans[d] = true
+	}
+	for _, ip := range existing.IPAddresses {
+		sans[ip.String()] = true
+	}
+	want := make(map[string]bool)
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			h = ip.String()
+		}
+		want[h] = true
+	}
+	if !reflect.DeepEqual(sans, want) {
+		return "subject alternative names have changed"
+	}
+	return ""
 }
 
 // getDaysAsDuration returns number of days the certificate
@@ -301,6 +409,12 @@ func (c *Command) init() {
 		"localhost is always included. This flag may be provided multiple times.")
 	c.flags.Var(&c.flagIPAddresses, "additional-ipaddress", "Additional IP address to add to the Consul server certificate as the Subject Alternative Name. "+
 		"127.0.0.1 is always included. This flag may be provided multiple times.")
+	c.flags.DurationVar(&c.flagRenewWithin, "renew-within", 0, "If set, an existing server certificate is only renewed if it expires within this duration, "+
+		"is not signed by the CA or its Subject Alternative Names have changed. By default, the server certificate is always regenerated.")
+	c.flags.DurationVar(&c.flagCheckInterval, "check-interval", 0, "If set, the command keeps running and checks whether the server certificate "+
+		"needs to be renewed at this interval. Requires -renew-within.")
+	c.flags.StringVar(&c.flagMetricsAddr, "metrics-addr", "", "Address to serve the expiry of the server certificate as Prometheus metrics on "+
+		"at /metrics while the command keeps running, e.g. \":9102\". Metrics are not served if not set.")
 	c.flags.StringVar(&c.flagLogLevel, "log-level", "info",
 		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
 			"\"debug\", \"info\", \"warn\", and \"error\".")
@@ -357,6 +471,18 @@ func (c *Command) validateFlags() error {
 	if c.flagDays <= 0 {
 		return errors.New("-days must be a positive integer")
 	}
+	if c.flagRenewWithin < 0 {
+		return errors.New("-renew-within must not be negative")
+	}
+	if c.flagCheckInterval < 0 {
+		return errors.New("-check-interval must not be negative")
+	}
+	if c.flagCheckInterval > 0 && c.flagRenewWithin == 0 {
+		return errors.New("-renew-within must be set with -check-interval")
+	}
+	if c.flagRenewWithin >= time.Duration(c.flagDays)*24*time.Hour {
+		return errors.New("-renew-within must be less than -days")
+	}
 
 	return nil
 }
@@ -369,4 +495,8 @@ Usage: consul-k8s-control-plane tls-init [options]
   for the Consul server. It manages the rotation of the Server certificates on subsequent
   runs. It can be provided with the CA certificate and key files on disk or can manage it's own CA.
 
+  With -check-interval and -renew-within, it keeps running and renews the server certificate
+  before it expires. Consul servers that mount the secret reload the renewed certificate when
+  they are sent SIGHUP or "consul reload" is run.
+
 `
@@ -7,9 +7,12 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/hashicorp/consul-k8s/control-plane/helper/cert"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
 	"github.com/mitchellh/cli"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
@@ -44,6 +47,22 @@ func TestRun_FlagValidation(t *testing.T) {
 			flags:  []string{"-name-prefix", "consul", "-days", "-3"},
 			expErr: "-days must be a positive integer",
 		},
+		{
+			flags:  []string{"-name-prefix", "consul", "-renew-within", "-1h"},
+			expErr: "-renew-within must not be negative",
+		},
+		{
+			flags:  []string{"-name-prefix", "consul", "-renew-within", "1h", "-check-interval", "-1h"},
+			expErr: "-check-interval must not be negative",
+		},
+		{
+			flags:  []string{"-name-prefix", "consul", "-check-interval", "1h"},
+			expErr: "-renew-within must be set with -check-interval",
+		},
+		{
+			flags:  []string{"-name-prefix", "consul", "-days", "30", "-renew-within", "720h"},
+			expErr: "-renew-within must be less than -days",
+		},
 	}
 
 	for _, c := range cases {
@@ -354,6 +373,77 @@ func TestRun_UpdatesServerCertificatesWithExistingCertsAsSecrets(t *testing.T) {
 	require.NoError(t, certificate.CheckSignatureFrom(caCertificate))
 }
 
+// Test that with -renew-within the server certificate is only renewed if it is
+// about to expire or its SANs have changed.
+func TestRun_RenewsServerCertificatesWhenNeeded(t *testing.T) {
+	k8s := fake.NewSimpleClientset()
+	serverCert := func() []byte {
+		secret, err := k8s.CoreV1().Secrets("default").Get(context.Background(), "consul-server-cert", metav1.GetOptions{})
+		require.NoError(t, err)
+		return secret.Data[corev1.TLSCertKey]
+	}
+	run := func(flags ...string) {
+		ui := cli.NewMockUi()
+		cmd := Command{UI: ui, clientset: k8s}
+		exitCode := cmd.Run(append([]string{"-name-prefix", "consul"}, flags...))
+		require.Equal(t, 0, exitCode, ui.ErrorWriter.String())
+	}
+
+	run("-days", "10", "-renew-within", "24h")
+	created := serverCert()
+
+	// The certificate is still valid for longer than 24h.
+	run("-days", "10", "-renew-within", "24h")
+	require.Equal(t, created, serverCert())
+
+	// The certificate expires within 11 days.
+	run("-days", "20", "-renew-within", "264h")
+	renewed := serverCert()
+	require.NotEqual(t, created, renewed)
+
+	// The SANs have changed.
+	run("-renew-within", "24h", "-additional-dnsname", "test.dns.name")
+	require.NotEqual(t, renewed, serverCert())
+	certificate, err := cert.ParseCert(serverCert())
+	require.NoError(t, err)
+	require.Equal(t, []string{"test.dns.name", "server.dc1.consul", "localhost"}, certificate.DNSNames)
+}
+
+// Test that with -check-interval the command keeps the server certificate
+// secret up to date until it receives a signal.
+func TestRun_KeepsRenewingServerCertificatesUntilSignal(t *testing.T) {
+	ui := cli.NewMockUi()
+	k8s := fake.NewSimpleClientset()
+	cmd := Command{UI: ui, clientset: k8s, sigCh: make(chan os.Signal, 1)}
+
+	exitCh := make(chan int, 1)
+	go func() {
+		exitCh <- cmd.Run([]string{"-name-prefix", "consul", "-renew-within", "24h", "-check-interval", "10ms"})
+	}()
+
+	getSecret := func() error {
+		_, err := k8s.CoreV1().Secrets("default").Get(context.Background(), "consul-server-cert", metav1.GetOptions{})
+		return err
+	}
+	retry.Run(t, func(r *retry.R) {
+		require.NoError(r, getSecret())
+	})
+
+	// The secret is recreated if it is deleted.
+	require.NoError(t, k8s.CoreV1().Secrets("default").Delete(context.Background(), "consul-server-cert", metav1.DeleteOptions{}))
+	retry.Run(t, func(r *retry.R) {
+		require.NoError(r, getSecret())
+	})
+
+	cmd.sigCh <- syscall.SIGINT
+	select {
+	case exitCode := <-exitCh:
+		require.Equal(t, 0, exitCode, ui.ErrorWriter.String())
+	case <-time.After(5 * time.Second):
+		t.Fatal("command did not exit after receiving a signal")
+	}
+}
+
 func TestRun_CreatesServerCertificatesWithExpiryWithinSpecifiedDays(t *testing.T) {
 	ui := cli.NewMockUi()
 	cmd := Command{UI: ui}
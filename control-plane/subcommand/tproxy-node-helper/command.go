@@ -0,0 +1,436 @@
+package tproxynodehelper
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	connectinject "github.com/hashicorp/consul-k8s/control-plane/connect-inject"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	"github.com/hashicorp/consul-k8s/control-plane/tproxy"
+	"github.com/hashicorp/consul/sdk/iptables"
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// sharedVolumePath is where the init container mounts the volume it shares
+// with the Envoy sidecar.
+const sharedVolumePath = "consul/connect-inject"
+
+type Command struct {
+	UI cli.Ui
+
+	flagSet *flag.FlagSet
+	k8s     *flags.K8SFlags
+
+	flagNodeName string
+	flagProcDir  string
+	flagTimeout  time.Duration
+	flagLogLevel string
+	flagLogJSON  bool
+
+	flagEnableNodeProxy       bool
+	flagNodeProxyOutboundPort int
+
+	clientset kubernetes.Interface
+	logger    hclog.Logger
+
+	// setupIptables applies the traffic redirection rules. Only set in tests.
+	setupIptables func(iptables.Config) error
+	// runIptables runs iptables with the arguments in the network namespace.
+	// Only set in tests.
+	runIptables func(netNS string, args ...string) error
+	// retryDuration is how often to check for the config of an init container.
+	// Only set in tests.
+	retryDuration time.Duration
+
+	// handled are the IDs of the init containers the rules are being or have
+	// been applied for.
+	handled   map[string]struct{}
+	handledMu sync.Mutex
+
+	once  sync.Once
+	help  string
+	sigCh chan os.Signal
+}
+
+func (c *Command) init() {
+	c.flagSet = flag.NewFlagSet("", flag.ContinueOnError)
+	c.flagSet.StringVar(&c.flagNodeName, "node-name", "",
+		"Name of the Kubernetes node this helper runs on.")
+	c.flagSet.StringVar(&c.flagProcDir, "proc-dir", "/proc",
+		"Path to the proc filesystem of the host.")
+	c.flagSet.DurationVar(&c.flagTimeout, "timeout", 5*time.Minute,
+		"How long to wait for an init container to write its traffic redirection config.")
+	c.flagSet.StringVar(&c.flagLogLevel, "log-level", "info",
+		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
+			"\"debug\", \"info\", \"warn\", and \"error\".")
+	c.flagSet.BoolVar(&c.flagLogJSON, "log-json", false,
+		"Enable or disable JSON output format for logging.")
+	c.flagSet.BoolVar(&c.flagEnableNodeProxy, "enable-node-proxy", false,
+		"Redirect the outbound traffic of pods in node proxy mode to the node proxy. Experimental.")
+	c.flagSet.IntVar(&c.flagNodeProxyOutboundPort, "node-proxy-outbound-port", connectinject.DefaultNodeProxyOutboundPort,
+		"Port the node proxy accepts the redirected outbound traffic of pods on.")
+
+	c.k8s = &flags.K8SFlags{}
+	flags.Merge(c.flagSet, c.k8s.Flags())
+	c.help = flags.Usage(help, c.flagSet)
+
+	if c.setupIptables == nil {
+		c.setupIptables = iptables.Setup
+	}
+	if c.runIptables == nil {
+		c.runIptables = runIptables
+	}
+	if c.retryDuration == 0 {
+		c.retryDuration = 1 * time.Second
+	}
+	c.handled = make(map[string]struct{})
+
+	// Wait on an interrupt or terminate to exit. This channel must be initialized before
+	// Run() is called so that there are no race conditions where the channel
+	// is not defined.
+	if c.sigCh == nil {
+		c.sigCh = make(chan os.Signal, 1)
+		signal.Notify(c.sigCh, syscall.SIGINT, syscall.SIGTERM)
+	}
+}
+
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	if err := c.flagSet.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("Error parsing flagSet: %s", err))
+		return 1
+	}
+	if len(c.flagSet.Args()) > 0 {
+		c.UI.Error("Invalid arguments: should have no non-flag arguments")
+		return 1
+	}
+	if c.flagNodeName == "" {
+		c.UI.Error("-node-name must be set")
+		return 1
+	}
+
+	var err error
+	c.logger, err = common.Logger(c.flagLogLevel, c.flagLogJSON)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if c.clientset == nil {
+		config, err := subcommand.K8SConfig(c.k8s.KubeConfig())
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error retrieving Kubernetes auth: %s", err))
+			return 1
+		}
+		c.clientset, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error initializing Kubernetes client: %s", err))
+			return 1
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Only watch the pods on this node.
+	factory := informers.NewSharedInformerFactoryWithOptions(c.clientset, 0,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", c.flagNodeName).String()
+		}))
+	podInformer := factory.Core().V1().Pods().Informer()
+	podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.handlePod(ctx, obj) },
+		UpdateFunc: func(_, obj interface{}) { c.handlePod(ctx, obj) },
+		DeleteFunc: c.forgetPod,
+	})
+	factory.Start(ctx.Done())
+
+	c.logger.Info("Applying traffic redirection rules for pods on node", "node", c.flagNodeName)
+	sig := <-c.sigCh
+	c.logger.Info(fmt.Sprintf("%s received, shutting down", sig))
+	return 0
+}
+
+// handlePod starts applying the traffic redirection rules for the pod if it
+// is in node helper mode and its init container is running, or if it is in
+// node proxy mode and one of its containers is running.
+func (c *Command) handlePod(ctx context.Context, obj interface{}) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+	nodeProxy := c.flagEnableNodeProxy && pod.Labels[connectinject.LabelNodeProxy] == "true"
+	if !nodeProxy && pod.Annotations[connectinject.AnnotationTransparentProxyInitMode] != connectinject.TProxyInitModeNodeHelper {
+		return
+	}
+	var containerID string
+	if nodeProxy {
+		containerID = runningContainerID(pod)
+	} else {
+		containerID = initContainerID(pod)
+	}
+	if containerID == "" {
+		return
+	}
+
+	c.handledMu.Lock()
+	defer c.handledMu.Unlock()
+	if _, ok := c.handled[containerID]; ok {
+		return
+	}
+	c.handled[containerID] = struct{}{}
+
+	logger := c.logger.With("pod", fmt.Sprintf("%s/%s", pod.Namespace, pod.Name), "container-id", containerID)
+	go func() {
+		var err error
+		if nodeProxy {
+			err = c.redirectToNodeProxy(logger, containerID, pod.Status.HostIP)
+		} else {
+			err = c.redirectTraffic(ctx, logger, containerID)
+		}
+		if err != nil {
+			logger.Error("Unable to apply traffic redirection rules", "error", err)
+		}
+	}()
+}
+
+// forgetPod stops tracking the init containers of the deleted pod.
+func (c *Command) forgetPod(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+	c.handledMu.Lock()
+	defer c.handledMu.Unlock()
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			delete(c.handled, trimContainerID(status.ContainerID))
+		}
+	}
+}
+
+// redirectTraffic waits for the init container to write its traffic
+// redirection config, applies it in the network namespace of the init
+// container and reports the result back to it.
+func (c *Command) redirectTraffic(ctx context.Context, logger hclog.Logger, containerID string) error {
+	deadline := time.Now().Add(c.flagTimeout)
+	for {
+		pid, err := c.findPID(containerID)
+		if err != nil {
+			return err
+		}
+		// The process is gone once the init container has exited.
+		if pid == 0 {
+			return errors.New("init container is not running")
+		}
+		done, err := c.applyConfig(logger, pid)
+		if done || err != nil {
+			return err
+		}
+
+		if time.Now().After(deadline) {
+			return errors.New("timed out waiting for the traffic redirection config")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.retryDuration):
+		}
+	}
+}
+
+// applyConfig applies the traffic redirection config the init container with
+// the process ID wrote to the shared volume, and reports the result back to
+// it. It returns false if the config hasn't been written yet.
+func (c *Command) applyConfig(logger hclog.Logger, pid int) (bool, error) {
+	volume, err := openSharedVolume(c.flagProcDir, pid)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer volume.close()
+
+	// The rules cannot be applied twice, e.g. after this helper restarted.
+	applied, err := volume.exists(tproxy.AppliedFile)
+	if err != nil {
+		return false, err
+	}
+	if applied {
+		logger.Debug("Traffic redirection rules have already been applied")
+		return true, nil
+	}
+
+	raw, err := volume.readFile(tproxy.ConfigFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	cfg, err := tproxy.ParseConfig(raw)
+	if err != nil {
+		return false, err
+	}
+	cfg.NetNS = filepath.Join(c.flagProcDir, strconv.Itoa(pid), "ns", "net")
+	if err := c.setupIptables(cfg); err != nil {
+		if writeErr := volume.writeFile(tproxy.FailedFile, []byte(err.Error()), 0444); writeErr != nil {
+			logger.Error("Unable to report failure to the init container", "error", writeErr)
+		}
+		return false, err
+	}
+	logger.Info("Applied traffic redirection rules")
+	return true, volume.writeFile(tproxy.AppliedFile, nil, 0444)
+}
+
+// redirectToNodeProxy redirects the outbound traffic of a pod in node proxy
+// mode to the node proxy on this node. The rules are applied in the network
+// namespace of the container, which all containers of the pod share.
+func (c *Command) redirectToNodeProxy(logger hclog.Logger, containerID, hostIP string) error {
+	if ip := net.ParseIP(hostIP); ip == nil || ip.To4() == nil {
+		return fmt.Errorf("node proxy mode requires an IPv4 host IP, got %q", hostIP)
+	}
+	pid, err := c.findPID(containerID)
+	if err != nil {
+		return err
+	}
+	if pid == 0 {
+		return errors.New("container is not running")
+	}
+	netNS := filepath.Join(c.flagProcDir, strconv.Itoa(pid), "ns", "net")
+
+	// The rules cannot be applied twice, e.g. after this helper restarted.
+	if err := c.runIptables(netNS, "-t", "nat", "-S", tproxy.NodeProxyChain); err == nil {
+		logger.Debug("Node proxy redirection rules have already been applied")
+		return nil
+	}
+	for _, rule := range tproxy.NodeProxyRules(hostIP, c.flagNodeProxyOutboundPort) {
+		if err := c.runIptables(netNS, rule...); err != nil {
+			return err
+		}
+	}
+	logger.Info("Applied node proxy redirection rules")
+	return nil
+}
+
+// runIptables runs iptables with the arguments in the network namespace.
+func runIptables(netNS string, args ...string) error {
+	cmd := exec.Command("nsenter", append([]string{"--net=" + netNS, "--", "iptables"}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("iptables %s: %s: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// findPID returns the ID of a process of the container, or 0 if the container
+// has no processes. Processes are matched on the cgroups they belong to, the
+// paths of which contain the container ID for all common container runtimes.
+func (c *Command) findPID(containerID string) (int, error) {
+	entries, err := os.ReadDir(c.flagProcDir)
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		f, err := os.Open(filepath.Join(c.flagProcDir, entry.Name(), "cgroup"))
+		if err != nil {
+			// The process may have exited in the meantime.
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		found := false
+		for scanner.Scan() {
+			if strings.Contains(scanner.Text(), containerID) {
+				found = true
+				break
+			}
+		}
+		f.Close()
+		if found {
+			return pid, nil
+		}
+	}
+	return 0, nil
+}
+
+// initContainerID returns the ID of the connect-inject init container of the
+// pod if it is running.
+func initContainerID(pod *corev1.Pod) string {
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name == connectinject.InjectInitContainerName && status.State.Running != nil {
+			return trimContainerID(status.ContainerID)
+		}
+	}
+	return ""
+}
+
+// runningContainerID returns the ID of a running container of the pod.
+func runningContainerID(pod *corev1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Running != nil && status.ContainerID != "" {
+			return trimContainerID(status.ContainerID)
+		}
+	}
+	return ""
+}
+
+// trimContainerID removes the container runtime prefix from the container ID,
+// e.g. containerd://<id>.
+func trimContainerID(id string) string {
+	if i := strings.Index(id, "://"); i >= 0 {
+		return id[i+len("://"):]
+	}
+	return id
+}
+
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.help
+}
+
+func (c *Command) Synopsis() string {
+	return synopsis
+}
+
+const synopsis = "Apply traffic redirection rules on behalf of transparent proxy pods"
+const help = `
+Usage: consul-k8s-control-plane tproxy-node-helper [options]
+
+  Applies the traffic redirection rules of transparent proxy pods on this
+  node whose init containers are not permitted to apply them themselves,
+  e.g. in namespaces that enforce the restricted Pod Security Standard.
+  Must run privileged in the host PID namespace.
+
+  With -enable-node-proxy, it also redirects the outbound traffic of pods in
+  the experimental node proxy mode to the node proxy on this node.
+
+`
@@ -0,0 +1,316 @@
+package tproxynodehelper
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	connectinject "github.com/hashicorp/consul-k8s/control-plane/connect-inject"
+	"github.com/hashicorp/consul-k8s/control-plane/tproxy"
+	"github.com/hashicorp/consul/sdk/iptables"
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testContainerID = "3b5f1f2c9d7e"
+
+func TestRun_FlagValidation(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		flags  []string
+		expErr string
+	}{
+		{
+			flags:  []string{"foo"},
+			expErr: "Invalid arguments: should have no non-flag arguments",
+		},
+		{
+			flags:  []string{},
+			expErr: "-node-name must be set",
+		},
+		{
+			flags:  []string{"-node-name=node", "-log-level=invalid"},
+			expErr: "unknown log level: invalid",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{UI: ui, sigCh: make(chan os.Signal, 1)}
+			require.Equal(t, 1, cmd.Run(c.flags))
+			require.Contains(t, ui.ErrorWriter.String(), c.expErr)
+		})
+	}
+}
+
+// TestRun tests that the rules are applied in the network namespace of the
+// init container of a pod in node helper mode.
+func TestRun(t *testing.T) {
+	t.Parallel()
+	procDir := t.TempDir()
+	sharedDir := fakeProcess(t, procDir, 42, "0::/kubepods/pod1234/cri-containerd-"+testContainerID+".scope")
+	fakeProcess(t, procDir, 7, "0::/kubepods/pod5678/cri-containerd-other.scope")
+	require.NoError(t, tproxy.WriteConfig(sharedDir, iptables.Config{ProxyUserID: "5995", ProxyInboundPort: 20000}))
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "web",
+			Namespace:   "default",
+			Annotations: map[string]string{connectinject.AnnotationTransparentProxyInitMode: connectinject.TProxyInitModeNodeHelper},
+		},
+		Spec: corev1.PodSpec{NodeName: "node"},
+		Status: corev1.PodStatus{
+			InitContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name:        connectinject.InjectInitContainerName,
+					ContainerID: "containerd://" + testContainerID,
+					State:       corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				},
+			},
+		},
+	}
+	applied := make(chan iptables.Config, 1)
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI:        ui,
+		clientset: fake.NewSimpleClientset(pod),
+		setupIptables: func(cfg iptables.Config) error {
+			applied <- cfg
+			return nil
+		},
+		retryDuration: 10 * time.Millisecond,
+		sigCh:         make(chan os.Signal, 1),
+	}
+	exitCh := make(chan int, 1)
+	go func() {
+		exitCh <- cmd.Run([]string{"-node-name=node", "-proc-dir", procDir})
+	}()
+
+	select {
+	case cfg := <-applied:
+		require.Equal(t, filepath.Join(procDir, "42", "ns", "net"), cfg.NetNS)
+		require.Equal(t, "5995", cfg.ProxyUserID)
+		require.Equal(t, 20000, cfg.ProxyInboundPort)
+	case <-time.After(5 * time.Second):
+		t.Fatal("traffic redirection rules were not applied")
+	}
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(sharedDir, tproxy.AppliedFile))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cmd.sigCh <- os.Interrupt
+	require.Equal(t, 0, <-exitCh, ui.ErrorWriter.String())
+}
+
+func TestRedirectTraffic(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		setupIptables func(iptables.Config) error
+		// alreadyApplied simulates a restart of the helper after applying the rules.
+		alreadyApplied bool
+		noConfig       bool
+		expErr         string
+		expFailedFile  string
+		expApplied     bool
+	}{
+		"applied": {
+			expApplied: true,
+		},
+		"already applied": {
+			setupIptables: func(iptables.Config) error {
+				return errors.New("chain already exists")
+			},
+			alreadyApplied: true,
+			expApplied:     true,
+		},
+		"failure is reported to the init container": {
+			setupIptables: func(iptables.Config) error {
+				return errors.New("iptables: not found")
+			},
+			expErr:        "iptables: not found",
+			expFailedFile: "iptables: not found",
+		},
+		"timeout": {
+			noConfig: true,
+			expErr:   "timed out waiting for the traffic redirection config",
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			procDir := t.TempDir()
+			sharedDir := fakeProcess(t, procDir, 42, "12:pids:/docker/"+testContainerID)
+			if !c.noConfig {
+				require.NoError(t, tproxy.WriteConfig(sharedDir, iptables.Config{ProxyUserID: "5995"}))
+			}
+			if c.alreadyApplied {
+				require.NoError(t, os.WriteFile(filepath.Join(sharedDir, tproxy.AppliedFile), nil, 0444))
+			}
+			setupIptables := c.setupIptables
+			if setupIptables == nil {
+				setupIptables = func(iptables.Config) error { return nil }
+			}
+
+			cmd := Command{
+				flagProcDir:   procDir,
+				flagTimeout:   50 * time.Millisecond,
+				setupIptables: setupIptables,
+				retryDuration: 10 * time.Millisecond,
+			}
+			err := cmd.redirectTraffic(context.Background(), hclog.NewNullLogger(), testContainerID)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			_, err = os.Stat(filepath.Join(sharedDir, tproxy.AppliedFile))
+			require.Equal(t, c.expApplied, err == nil)
+			failed, err := os.ReadFile(filepath.Join(sharedDir, tproxy.FailedFile))
+			if c.expFailedFile != "" {
+				require.NoError(t, err)
+				require.Equal(t, c.expFailedFile, string(failed))
+			} else {
+				require.True(t, os.IsNotExist(err))
+			}
+		})
+	}
+}
+
+// TestRedirectTraffic_Symlinks tests that files in the shared volume aren't
+// opened through symlinks, which containers of the pod that run before the
+// init container could plant to make the helper write files of the node.
+func TestRedirectTraffic_Symlinks(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		symlink string
+		expErr  string
+	}{
+		"failed file": {
+			symlink: tproxy.FailedFile,
+			expErr:  "iptables: not found",
+		},
+		"config file": {
+			symlink: tproxy.ConfigFile,
+			expErr:  "openat2 " + tproxy.ConfigFile + ": too many levels of symbolic links",
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			procDir := t.TempDir()
+			sharedDir := fakeProcess(t, procDir, 42, "12:pids:/docker/"+testContainerID)
+			target := filepath.Join(t.TempDir(), "node-file")
+			require.NoError(t, os.WriteFile(target, []byte("{}"), 0644))
+			if c.symlink != tproxy.ConfigFile {
+				require.NoError(t, tproxy.WriteConfig(sharedDir, iptables.Config{ProxyUserID: "5995"}))
+			}
+			require.NoError(t, os.Symlink(target, filepath.Join(sharedDir, c.symlink)))
+
+			cmd := Command{
+				flagProcDir: procDir,
+				flagTimeout: 50 * time.Millisecond,
+				setupIptables: func(iptables.Config) error {
+					return errors.New("iptables: not found")
+				},
+				retryDuration: 10 * time.Millisecond,
+			}
+			err := cmd.redirectTraffic(context.Background(), hclog.NewNullLogger(), testContainerID)
+			require.EqualError(t, err, c.expErr)
+
+			content, err := os.ReadFile(target)
+			require.NoError(t, err)
+			require.Equal(t, "{}", string(content))
+		})
+	}
+}
+
+func TestRedirectTraffic_ContainerExited(t *testing.T) {
+	t.Parallel()
+	cmd := Command{flagProcDir: t.TempDir(), flagTimeout: time.Second}
+	err := cmd.redirectTraffic(context.Background(), hclog.NewNullLogger(), testContainerID)
+	require.EqualError(t, err, "init container is not running")
+}
+
+func TestRedirectToNodeProxy(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		hostIP         string
+		alreadyApplied bool
+		expErr         string
+		expRules       [][]string
+	}{
+		"applied": {
+			hostIP:   "10.0.0.5",
+			expRules: tproxy.NodeProxyRules("10.0.0.5", 15101),
+		},
+		"already applied": {
+			hostIP:         "10.0.0.5",
+			alreadyApplied: true,
+		},
+		"IPv6 host IP": {
+			hostIP: "fd00::5",
+			expErr: `node proxy mode requires an IPv4 host IP, got "fd00::5"`,
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			procDir := t.TempDir()
+			fakeProcess(t, procDir, 42, "12:pids:/docker/"+testContainerID)
+
+			var rules [][]string
+			cmd := Command{
+				flagProcDir:               procDir,
+				flagNodeProxyOutboundPort: 15101,
+				runIptables: func(netNS string, args ...string) error {
+					require.Equal(t, filepath.Join(procDir, "42", "ns", "net"), netNS)
+					if args[len(args)-2] == "-S" {
+						if c.alreadyApplied {
+							return nil
+						}
+						return errors.New("chain does not exist")
+					}
+					rules = append(rules, args)
+					return nil
+				},
+			}
+			err := cmd.redirectToNodeProxy(hclog.NewNullLogger(), testContainerID, c.hostIP)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expRules, rules)
+		})
+	}
+}
+
+func TestRedirectToNodeProxy_ContainerExited(t *testing.T) {
+	t.Parallel()
+	cmd := Command{flagProcDir: t.TempDir()}
+	err := cmd.redirectToNodeProxy(hclog.NewNullLogger(), testContainerID, "10.0.0.5")
+	require.EqualError(t, err, "container is not running")
+}
+
+// fakeProcess creates the proc entry of a process in the cgroup and returns
+// the path of the shared volume in its root filesystem.
+func fakeProcess(t *testing.T, procDir string, pid int, cgroup string) string {
+	dir := filepath.Join(procDir, strconv.Itoa(pid))
+	sharedDir := filepath.Join(dir, "root", sharedVolumePath)
+	require.NoError(t, os.MkdirAll(sharedDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cgroup"), []byte(cgroup+"\n"), 0444))
+	return sharedDir
+}
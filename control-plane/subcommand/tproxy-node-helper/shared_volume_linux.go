@@ -0,0 +1,112 @@
+//go:build linux
+// +build linux
+
+package tproxynodehelper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxSharedFileSize limits how much of a file in the shared volume is read.
+const maxSharedFileSize = 1 << 20
+
+// sharedVolume is the volume the init container shares with the Envoy
+// sidecar, opened through the root of the init container. Containers of the
+// pod that run before it can write to the volume, so its files are never
+// opened through symlinks, which would otherwise resolve against the root of
+// the node.
+type sharedVolume struct {
+	fd int
+}
+
+// openSharedVolume opens the shared volume of the init container with the
+// process ID. Symlinks on the way to the volume are resolved within the root
+// of the container.
+func openSharedVolume(procDir string, pid int) (*sharedVolume, error) {
+	root, err := unix.Open(filepath.Join(procDir, strconv.Itoa(pid), "root"), unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: filepath.Join(procDir, strconv.Itoa(pid), "root"), Err: err}
+	}
+	defer unix.Close(root)
+
+	fd, err := unix.Openat2(root, sharedVolumePath, &unix.OpenHow{
+		Flags:   unix.O_PATH | unix.O_DIRECTORY | unix.O_CLOEXEC,
+		Resolve: unix.RESOLVE_IN_ROOT | unix.RESOLVE_NO_MAGICLINKS,
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: "openat2", Path: sharedVolumePath, Err: err}
+	}
+	return &sharedVolume{fd: fd}, nil
+}
+
+// exists returns true if the volume has an entry with the name.
+func (v *sharedVolume) exists(name string) (bool, error) {
+	var st unix.Stat_t
+	err := unix.Fstatat(v.fd, name, &st, unix.AT_SYMLINK_NOFOLLOW)
+	if errors.Is(err, unix.ENOENT) {
+		return false, nil
+	}
+	if err != nil {
+		return false, &os.PathError{Op: "fstatat", Path: name, Err: err}
+	}
+	return true, nil
+}
+
+// readFile reads the regular file with the name.
+func (v *sharedVolume) readFile(name string) ([]byte, error) {
+	f, err := v.open(name, unix.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, maxSharedFileSize))
+}
+
+// writeFile creates or truncates the regular file with the name and writes the
+// data to it.
+func (v *sharedVolume) writeFile(name string, data []byte, perm uint32) error {
+	f, err := v.open(name, unix.O_WRONLY|unix.O_CREAT|unix.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// open opens the file with the name in the volume. It fails if the name is a
+// symlink or isn't a regular file. Opening is non-blocking so that a FIFO
+// cannot block the helper.
+func (v *sharedVolume) open(name string, flags int, perm uint32) (*os.File, error) {
+	fd, err := unix.Openat2(v.fd, name, &unix.OpenHow{
+		Flags:   uint64(flags | unix.O_NOFOLLOW | unix.O_NONBLOCK | unix.O_CLOEXEC),
+		Mode:    uint64(perm),
+		Resolve: unix.RESOLVE_BENEATH | unix.RESOLVE_NO_SYMLINKS,
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: "openat2", Path: name, Err: err}
+	}
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		unix.Close(fd)
+		return nil, &os.PathError{Op: "fstat", Path: name, Err: err}
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFREG {
+		unix.Close(fd)
+		return nil, fmt.Errorf("%s is not a regular file", name)
+	}
+	return os.NewFile(uintptr(fd), name), nil
+}
+
+func (v *sharedVolume) close() error {
+	return unix.Close(v.fd)
+}
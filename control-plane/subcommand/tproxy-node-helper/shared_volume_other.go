@@ -0,0 +1,22 @@
+//go:build !linux
+// +build !linux
+
+package tproxynodehelper
+
+import "errors"
+
+// sharedVolume is the volume the init container shares with the Envoy
+// sidecar. The node helper only runs on Linux nodes.
+type sharedVolume struct{}
+
+func openSharedVolume(string, int) (*sharedVolume, error) {
+	return nil, errors.New("the node helper is only supported on Linux")
+}
+
+func (v *sharedVolume) exists(string) (bool, error) { return false, nil }
+
+func (v *sharedVolume) readFile(string) ([]byte, error) { return nil, nil }
+
+func (v *sharedVolume) writeFile(string, []byte, uint32) error { return nil }
+
+func (v *sharedVolume) close() error { return nil }
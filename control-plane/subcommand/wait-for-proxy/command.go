@@ -0,0 +1,160 @@
+package waitforproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	"github.com/mitchellh/cli"
+)
+
+const (
+	defaultAdminAddr = "127.0.0.1:19000"
+	defaultTimeout   = 2 * time.Minute
+	requestTimeout   = 1 * time.Second
+)
+
+type Command struct {
+	UI cli.Ui
+
+	flags *flag.FlagSet
+
+	flagAdminAddr string
+	flagTimeout   time.Duration
+	flagLogLevel  string
+	flagLogJSON   bool
+
+	retryDuration time.Duration
+	once          sync.Once
+	help          string
+}
+
+func (c *Command) init() {
+	c.flags = flag.NewFlagSet("", flag.ContinueOnError)
+	c.flags.StringVar(&c.flagAdminAddr, "admin-addr", defaultAdminAddr,
+		"Address of the Envoy admin API.")
+	c.flags.DurationVar(&c.flagTimeout, "timeout", defaultTimeout,
+		"How long to wait for Envoy to be ready before failing.")
+	c.flags.StringVar(&c.flagLogLevel, "log-level", "info",
+		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
+			"\"debug\", \"info\", \"warn\", and \"error\".")
+	c.flags.BoolVar(&c.flagLogJSON, "log-json", false,
+		"Enable or disable JSON output format for logging.")
+	c.help = flags.Usage(help, c.flags)
+}
+
+// Run waits until Envoy is ready and has received its certificates.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	if err := c.flags.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("Error parsing flags: %s", err))
+		return 1
+	}
+	if len(c.flags.Args()) > 0 {
+		c.UI.Error("Invalid arguments: should have no non-flag arguments")
+		return 1
+	}
+	if c.flagTimeout <= 0 {
+		c.UI.Error("-timeout must be positive")
+		return 1
+	}
+	if c.retryDuration == 0 {
+		c.retryDuration = 1 * time.Second
+	}
+	logger, err := common.Logger(c.flagLogLevel, c.flagLogJSON)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.flagTimeout)
+	defer cancel()
+	client := &http.Client{Timeout: requestTimeout}
+	for {
+		err := c.checkReady(ctx, client)
+		if err == nil {
+			logger.Info("Envoy is ready", "admin-addr", c.flagAdminAddr)
+			return 0
+		}
+		logger.Info("Envoy is not ready yet", "admin-addr", c.flagAdminAddr, "reason", err)
+
+		select {
+		case <-ctx.Done():
+			c.UI.Error(fmt.Sprintf("Timed out waiting for Envoy to be ready: %s", err))
+			return 1
+		case <-time.After(c.retryDuration):
+		}
+	}
+}
+
+// checkReady returns an error if Envoy is not ready or has not received its
+// certificates yet. Envoy only reports ready once it has received its
+// initial listeners and clusters.
+func (c *Command) checkReady(ctx context.Context, client *http.Client) error {
+	if _, err := c.adminGet(ctx, client, "/ready"); err != nil {
+		return err
+	}
+
+	body, err := c.adminGet(ctx, client, "/certs")
+	if err != nil {
+		return err
+	}
+	var certs struct {
+		Certificates []struct {
+			CertChain []json.RawMessage `json:"cert_chain"`
+		} `json:"certificates"`
+	}
+	if err := json.Unmarshal(body, &certs); err != nil {
+		return fmt.Errorf("invalid certificates: %s", err)
+	}
+	for _, cert := range certs.Certificates {
+		if len(cert.CertChain) > 0 {
+			return nil
+		}
+	}
+	return errors.New("no certificates received")
+}
+
+func (c *Command) adminGet(ctx context.Context, client *http.Client, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s%s", c.flagAdminAddr, path), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", path, resp.Status)
+	}
+	return body, nil
+}
+
+func (c *Command) Synopsis() string { return synopsis }
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.help
+}
+
+const synopsis = "Wait until the Envoy proxy is ready."
+const help = `
+Usage: consul-k8s-control-plane wait-for-proxy [options]
+
+  Waits until the Envoy proxy is ready and has received its certificates.
+  It is run as the post-start hook of the Envoy sidecar so that the
+  application containers are only started once the proxy is ready.
+
+`
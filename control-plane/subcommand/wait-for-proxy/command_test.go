@@ -0,0 +1,94 @@
+package waitforproxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_FlagValidation(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		flags  []string
+		expErr string
+	}{
+		{
+			flags:  []string{"foo"},
+			expErr: "Invalid arguments: should have no non-flag arguments",
+		},
+		{
+			flags:  []string{"-timeout=0s"},
+			expErr: "-timeout must be positive",
+		},
+		{
+			flags:  []string{"-log-level=invalid"},
+			expErr: "unknown log level: invalid",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{UI: ui}
+			require.Equal(t, 1, cmd.Run(c.flags))
+			require.Contains(t, ui.ErrorWriter.String(), c.expErr)
+		})
+	}
+}
+
+// TestRun_WaitsUntilReady tests that the command only returns once Envoy
+// reports ready and has received its certificates.
+func TestRun_WaitsUntilReady(t *testing.T) {
+	t.Parallel()
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/ready":
+			// Envoy is initializing for the first check.
+			if n == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprint(w, "PRE_INITIALIZING")
+				return
+			}
+			fmt.Fprint(w, "LIVE")
+		case "/certs":
+			// Envoy has not received its certificates for the second check.
+			if n <= 3 {
+				fmt.Fprint(w, `{"certificates": []}`)
+				return
+			}
+			fmt.Fprint(w, `{"certificates": [{"ca_cert": [{}], "cert_chain": [{"path": "<inline>"}]}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ui := cli.NewMockUi()
+	cmd := Command{UI: ui, retryDuration: 10 * time.Millisecond}
+	code := cmd.Run([]string{"-admin-addr", strings.TrimPrefix(server.URL, "http://")})
+	require.Equal(t, 0, code, ui.ErrorWriter.String())
+	// Three checks were made: not ready, no certificates, ready.
+	require.Equal(t, int32(5), atomic.LoadInt32(&requests))
+}
+
+func TestRun_TimesOut(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ui := cli.NewMockUi()
+	cmd := Command{UI: ui, retryDuration: 10 * time.Millisecond}
+	code := cmd.Run([]string{"-admin-addr", strings.TrimPrefix(server.URL, "http://"), "-timeout", "100ms"})
+	require.Equal(t, 1, code)
+	require.Contains(t, ui.ErrorWriter.String(), "Timed out waiting for Envoy to be ready: /ready returned 503 Service Unavailable")
+}
@@ -9,8 +9,10 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
 	"strings"
 	"sync"
 	"syscall"
@@ -23,16 +25,26 @@ import (
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-multierror"
 	"github.com/mitchellh/cli"
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 )
 
 const (
 	defaultCertExpiry    = 24 * time.Hour
 	defaultRetryDuration = 1 * time.Second
+
+	// settingsResyncPeriod is how often the webhook settings are reconciled
+	// even though neither the MutatingWebhookConfiguration nor the deployment
+	// serving it changed.
+	settingsResyncPeriod = 5 * time.Minute
 )
 
 type Command struct {
@@ -47,6 +59,7 @@ type Command struct {
 
 	flagDeploymentName      string
 	flagDeploymentNamespace string
+	flagMetricsAddr         string
 
 	clientset kubernetes.Interface
 
@@ -67,6 +80,9 @@ func (c *Command) init() {
 		"Name of deployment that the cert-manager pod is managed by.")
 	c.flagSet.StringVar(&c.flagDeploymentNamespace, "deployment-namespace", "",
 		"Namespace of deployment that the cert-manager pod is managed by.")
+	c.flagSet.StringVar(&c.flagMetricsAddr, "metrics-addr", "",
+		"Address to serve the expiry of the webhook certificates as Prometheus metrics on at /metrics, e.g. \":9101\". "+
+			"Metrics are not served if not set.")
 	c.flagSet.StringVar(&c.flagLogLevel, "log-level", "info",
 		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
 			"\"debug\", \"info\", \"warn\", and \"error\".")
@@ -157,6 +173,14 @@ func (c *Command) Run(args []string) int {
 		}
 	}
 
+	if c.flagMetricsAddr != "" {
+		go func() {
+			if err := http.ListenAndServe(c.flagMetricsAddr, cert.MetricsHandler()); err != nil {
+				c.logger.Error("error serving metrics", "err", err)
+			}
+		}()
+	}
+
 	// Create the certificate notifier so we can update certificates,
 	// then start all the background routines for updating certificates.
 	var notifiers []*cert.Notify
@@ -183,6 +207,9 @@ func (c *Command) Run(args []string) int {
 		notifiers = append(notifiers, certNotify)
 		go certNotify.Start(ctx)
 		go c.certWatcher(ctx, certCh, c.clientset, c.logger)
+		if config.hasWebhookSettings() {
+			go c.settingsWatcher(ctx, config, c.clientset, c.logger)
+		}
 	}
 
 	// We define a signal handler for OS interrupts, and when an SIGINT or SIGTERM is received,
@@ -221,6 +248,10 @@ func (c *Command) certWatcher(ctx context.Context, ch <-chan cert.MetaBundle, cl
 
 		if err := c.reconcileCertificates(ctx, clientset, bundle, log); err != nil {
 			log.Error("failed to reconcile certificates", "err", err)
+			continue
+		}
+		if err := cert.RecordExpiry(bundle.SecretNamespace, bundle.SecretName, bundle.Cert); err != nil {
+			log.Warn("unable to record the expiry of the webhook certificate", "err", err)
 		}
 	}
 }
@@ -360,11 +391,143 @@ func (c *Command) webhookUpdated(ctx context.Context, bundle cert.MetaBundle, cl
 	return true
 }
 
+// settingsWatcher keeps the failure policy, timeout and selectors of the
+// webhooks on the MutatingWebhookConfiguration in sync with the config, so
+// that they are reset if they are changed, e.g. by a Helm upgrade that
+// re-applies the MutatingWebhookConfiguration while the webhook is rolled out.
+// It watches the MutatingWebhookConfiguration and, with FailOpenDuringRollout,
+// the deployment serving the webhook, and reconciles whenever either changes.
+func (c *Command) settingsWatcher(ctx context.Context, config webhookConfig, clientset kubernetes.Interface, log hclog.Logger) {
+	trigger := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case trigger <- struct{}{}:
+		default:
+		}
+	}
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { notify() },
+		UpdateFunc: func(interface{}, interface{}) { notify() },
+		DeleteFunc: func(interface{}) { notify() },
+	}
+
+	webhookFactory := informers.NewSharedInformerFactoryWithOptions(clientset, settingsResyncPeriod,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", config.Name).String()
+		}))
+	webhookFactory.Admissionregistration().V1().MutatingWebhookConfigurations().Informer().AddEventHandler(handler)
+	webhookFactory.Start(ctx.Done())
+	if config.FailOpenDuringRollout {
+		deploymentFactory := informers.NewSharedInformerFactoryWithOptions(clientset, settingsResyncPeriod,
+			informers.WithNamespace(config.DeploymentNamespace),
+			informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+				opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", config.DeploymentName).String()
+			}))
+		deploymentFactory.Apps().V1().Deployments().Informer().AddEventHandler(handler)
+		deploymentFactory.Start(ctx.Done())
+	}
+
+	for {
+		select {
+		case <-trigger:
+		case <-ctx.Done():
+			return
+		}
+		if err := c.reconcileWebhookSettings(ctx, clientset, config, log); err != nil {
+			log.Error("failed to reconcile webhook settings", "mutatingwebhookconfig", config.Name, "err", err)
+			time.AfterFunc(defaultRetryDuration, notify)
+		}
+	}
+}
+
+// reconcileWebhookSettings patches the webhooks on the MutatingWebhookConfiguration
+// whose settings differ from the config. The failure policy is set to Ignore
+// while the deployment serving the webhook is rolled out if FailOpenDuringRollout
+// is set, so that pods can still be created while no replica of the webhook
+// can answer.
+func (c *Command) reconcileWebhookSettings(ctx context.Context, clientset kubernetes.Interface, config webhookConfig, log hclog.Logger) error {
+	failurePolicy := config.FailurePolicy
+	if config.FailOpenDuringRollout && failurePolicy != nil && *failurePolicy != admissionv1.Ignore {
+		deployment, err := clientset.AppsV1().Deployments(config.DeploymentNamespace).Get(ctx, config.DeploymentName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if rollingOut(deployment) {
+			ignore := admissionv1.Ignore
+			failurePolicy = &ignore
+		}
+	}
+
+	webhookCfg, err := clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, config.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	var patches []patch
+	for i, webhook := range webhookCfg.Webhooks {
+		if failurePolicy != nil && (webhook.FailurePolicy == nil || *webhook.FailurePolicy != *failurePolicy) {
+			log.Info("Setting webhook failure policy", "mutatingwebhookconfig", config.Name, "webhook", webhook.Name, "failurePolicy", *failurePolicy)
+			patches = append(patches, patch{Op: "add", Path: fmt.Sprintf("/webhooks/%d/failurePolicy", i), Value: *failurePolicy})
+		}
+		if config.TimeoutSeconds != nil && (webhook.TimeoutSeconds == nil || *webhook.TimeoutSeconds != *config.TimeoutSeconds) {
+			patches = append(patches, patch{Op: "add", Path: fmt.Sprintf("/webhooks/%d/timeoutSeconds", i), Value: *config.TimeoutSeconds})
+		}
+		if config.NamespaceSelector != nil && !reflect.DeepEqual(webhook.NamespaceSelector, config.NamespaceSelector) {
+			patches = append(patches, patch{Op: "add", Path: fmt.Sprintf("/webhooks/%d/namespaceSelector", i), Value: config.NamespaceSelector})
+		}
+		if config.ObjectSelector != nil && !reflect.DeepEqual(webhook.ObjectSelector, config.ObjectSelector) {
+			patches = append(patches, patch{Op: "add", Path: fmt.Sprintf("/webhooks/%d/objectSelector", i), Value: config.ObjectSelector})
+		}
+	}
+	if len(patches) == 0 {
+		return nil
+	}
+	patchesJson, err := json.Marshal(patches)
+	if err != nil {
+		return err
+	}
+	_, err = clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().Patch(ctx, config.Name, types.JSONPatchType, patchesJson, metav1.PatchOptions{})
+	return err
+}
+
+// rollingOut returns true if the deployment has not finished rolling out its
+// latest revision, using the same conditions as `kubectl rollout status`.
+func rollingOut(deployment *appsv1.Deployment) bool {
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		return true
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	return deployment.Status.UpdatedReplicas < replicas ||
+		deployment.Status.Replicas > deployment.Status.UpdatedReplicas ||
+		deployment.Status.AvailableReplicas < deployment.Status.UpdatedReplicas
+}
+
 type webhookConfig struct {
 	Name            string   `json:"name,omitempty"`
 	TLSAutoHosts    []string `json:"tlsAutoHosts,omitempty"`
 	SecretName      string   `json:"secretName,omitempty"`
 	SecretNamespace string   `json:"secretNamespace,omitempty"`
+
+	// FailurePolicy, TimeoutSeconds, NamespaceSelector and ObjectSelector are
+	// set on every webhook of the MutatingWebhookConfiguration if they are set.
+	FailurePolicy     *admissionv1.FailurePolicyType `json:"failurePolicy,omitempty"`
+	TimeoutSeconds    *int32                         `json:"timeoutSeconds,omitempty"`
+	NamespaceSelector *metav1.LabelSelector          `json:"namespaceSelector,omitempty"`
+	ObjectSelector    *metav1.LabelSelector          `json:"objectSelector,omitempty"`
+
+	// FailOpenDuringRollout sets the failure policy of the webhooks to Ignore
+	// while the deployment serving them is rolled out.
+	FailOpenDuringRollout bool   `json:"failOpenDuringRollout,omitempty"`
+	DeploymentName        string `json:"deploymentName,omitempty"`
+	DeploymentNamespace   string `json:"deploymentNamespace,omitempty"`
+}
+
+// hasWebhookSettings returns true if the config sets any of the settings of
+// the webhooks.
+func (c webhookConfig) hasWebhookSettings() bool {
+	return c.FailurePolicy != nil || c.TimeoutSeconds != nil || c.NamespaceSelector != nil || c.ObjectSelector != nil
 }
 
 func (c webhookConfig) validate(ctx context.Context, client kubernetes.Interface) error {
@@ -382,6 +545,15 @@ func (c webhookConfig) validate(ctx context.Context, client kubernetes.Interface
 	if c.SecretNamespace == "" {
 		err = multierror.Append(err, errors.New(`config.SecretNameSpace cannot be ""`))
 	}
+	if c.FailurePolicy != nil && *c.FailurePolicy != admissionv1.Fail && *c.FailurePolicy != admissionv1.Ignore {
+		err = multierror.Append(err, fmt.Errorf("config.FailurePolicy must be %q or %q", admissionv1.Fail, admissionv1.Ignore))
+	}
+	if c.TimeoutSeconds != nil && (*c.TimeoutSeconds < 1 || *c.TimeoutSeconds > 30) {
+		err = multierror.Append(err, errors.New("config.TimeoutSeconds must be between 1 and 30"))
+	}
+	if c.FailOpenDuringRollout && (c.DeploymentName == "" || c.DeploymentNamespace == "") {
+		err = multierror.Append(err, errors.New("config.DeploymentName and config.DeploymentNamespace must be set with config.FailOpenDuringRollout"))
+	}
 
 	if err != nil {
 		err.ErrorFormat = func(errs []error) string {
@@ -397,9 +569,9 @@ func (c webhookConfig) validate(ctx context.Context, client kubernetes.Interface
 }
 
 type patch struct {
-	Op    string `json:"op,omitempty"`
-	Path  string `json:"path,omitempty"`
-	Value string `json:"value,omitempty"`
+	Op    string      `json:"op,omitempty"`
+	Path  string      `json:"path,omitempty"`
+	Value interface{} `json:"value,omitempty"`
 }
 
 func (c *Command) Help() string {
@@ -2,7 +2,9 @@ package webhookcertmanager
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"syscall"
 	"testing"
@@ -10,7 +12,9 @@ import (
 
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/webhook-cert-manager/mocks"
+	"github.com/hashicorp/consul/sdk/freeport"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/hashicorp/go-hclog"
 	"github.com/mitchellh/cli"
 	"github.com/stretchr/testify/require"
 	admissionv1 "k8s.io/api/admissionregistration/v1"
@@ -252,6 +256,64 @@ func TestRun_SecretDoesNotExist(t *testing.T) {
 	})
 }
 
+// Test that the expiry of the webhook certificates is served as metrics.
+func TestRun_ServesCertificateExpiryMetrics(t *testing.T) {
+	t.Parallel()
+	deploymentName := "deployment"
+	deploymentNamespace := "deploy-ns"
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      deploymentName,
+			Namespace: deploymentNamespace,
+		},
+	}
+	webhook := &admissionv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "webhookOne",
+		},
+		Webhooks: []admissionv1.MutatingWebhook{
+			{
+				Name: "webhook-under-test",
+			},
+		},
+	}
+
+	k8s := fake.NewSimpleClientset(webhook, deployment)
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI:        ui,
+		clientset: k8s,
+	}
+	cmd.init()
+
+	file, err := ioutil.TempFile("", "config.json")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+
+	_, err = file.Write([]byte(configFileUpdates))
+	require.NoError(t, err)
+
+	metricsAddr := fmt.Sprintf("127.0.0.1:%d", freeport.GetN(t, 1)[0])
+	exitCh := runCommandAsynchronously(&cmd, []string{
+		"-config-file", file.Name(),
+		"-deployment-name", deploymentName,
+		"-deployment-namespace", deploymentNamespace,
+		"-metrics-addr", metricsAddr,
+	})
+	defer stopCommand(t, &cmd, exitCh)
+
+	timer := &retry.Timer{Timeout: 10 * time.Second, Wait: 500 * time.Millisecond}
+	retry.RunWith(timer, t, func(r *retry.R) {
+		resp, err := http.Get(fmt.Sprintf("http://%s/metrics", metricsAddr))
+		require.NoError(r, err)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(r, err)
+		require.Contains(r, string(body), `consul_k8s_certificate_expiry_timestamp_seconds{secret_name="secret-deploy-1",secret_namespace="default"}`)
+	})
+}
+
 func TestRun_SecretExists(t *testing.T) {
 	t.Parallel()
 	deploymentName := "deployment"
@@ -675,6 +737,8 @@ func TestValidate(t *testing.T) {
 		},
 	}
 	client := fake.NewSimpleClientset(webhook)
+	invalidFailurePolicy := admissionv1.FailurePolicyType("Retry")
+	invalidTimeout := int32(60)
 
 	cases := map[string]struct {
 		config    webhookConfig
@@ -721,6 +785,36 @@ func TestValidate(t *testing.T) {
 			clientset: client,
 			expErr:    `config.SecretNameSpace cannot be ""`,
 		},
+		"failurePolicy": {
+			config: webhookConfig{
+				Name:            "webhook-config-name",
+				SecretName:      "secret-name",
+				SecretNamespace: "default",
+				FailurePolicy:   &invalidFailurePolicy,
+			},
+			clientset: client,
+			expErr:    `config.FailurePolicy must be "Fail" or "Ignore"`,
+		},
+		"timeoutSeconds": {
+			config: webhookConfig{
+				Name:            "webhook-config-name",
+				SecretName:      "secret-name",
+				SecretNamespace: "default",
+				TimeoutSeconds:  &invalidTimeout,
+			},
+			clientset: client,
+			expErr:    "config.TimeoutSeconds must be between 1 and 30",
+		},
+		"failOpenDuringRollout": {
+			config: webhookConfig{
+				Name:                  "webhook-config-name",
+				SecretName:            "secret-name",
+				SecretNamespace:       "default",
+				FailOpenDuringRollout: true,
+			},
+			clientset: client,
+			expErr:    "config.DeploymentName and config.DeploymentNamespace must be set with config.FailOpenDuringRollout",
+		},
 		"multi-error": {
 			config: webhookConfig{
 				Name:            "",
@@ -740,6 +834,168 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+// Test that the settings of the webhooks are reset to the config and that the
+// failure policy is Ignore while the deployment serving them is rolled out.
+func TestReconcileWebhookSettings(t *testing.T) {
+	t.Parallel()
+
+	fail := admissionv1.Fail
+	timeout := int32(5)
+	namespaceSelector := &metav1.LabelSelector{MatchLabels: map[string]string{"inject": "true"}}
+	config := webhookConfig{
+		Name:                  "webhookOne",
+		FailurePolicy:         &fail,
+		TimeoutSeconds:        &timeout,
+		NamespaceSelector:     namespaceSelector,
+		FailOpenDuringRollout: true,
+		DeploymentName:        "connect-injector",
+		DeploymentNamespace:   "default",
+	}
+	webhook := &admissionv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhookOne"},
+		Webhooks:   []admissionv1.MutatingWebhook{{Name: "one"}, {Name: "two"}},
+	}
+	replicas := int32(2)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "connect-injector", Namespace: "default", Generation: 1},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+	}
+	k8s := fake.NewSimpleClientset(webhook, deployment)
+	ctx := context.Background()
+	cmd := Command{UI: cli.NewMockUi()}
+	log := hclog.New(nil)
+
+	failurePolicies := func() []admissionv1.FailurePolicyType {
+		webhookCfg, err := k8s.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, "webhookOne", metav1.GetOptions{})
+		require.NoError(t, err)
+		var policies []admissionv1.FailurePolicyType
+		for _, w := range webhookCfg.Webhooks {
+			require.Equal(t, timeout, *w.TimeoutSeconds)
+			require.Equal(t, namespaceSelector, w.NamespaceSelector)
+			policies = append(policies, *w.FailurePolicy)
+		}
+		return policies
+	}
+
+	require.NoError(t, cmd.reconcileWebhookSettings(ctx, k8s, config, log))
+	require.Equal(t, []admissionv1.FailurePolicyType{admissionv1.Fail, admissionv1.Fail}, failurePolicies())
+
+	// Start a rollout of the deployment.
+	deployment.Generation = 2
+	_, err := k8s.AppsV1().Deployments("default").Update(ctx, deployment, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, cmd.reconcileWebhookSettings(ctx, k8s, config, log))
+	require.Equal(t, []admissionv1.FailurePolicyType{admissionv1.Ignore, admissionv1.Ignore}, failurePolicies())
+
+	// Finish the rollout.
+	deployment.Status.ObservedGeneration = 2
+	_, err = k8s.AppsV1().Deployments("default").Update(ctx, deployment, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, cmd.reconcileWebhookSettings(ctx, k8s, config, log))
+	require.Equal(t, []admissionv1.FailurePolicyType{admissionv1.Fail, admissionv1.Fail}, failurePolicies())
+}
+
+// Test that the settings watcher sets the failure policy to Ignore when the
+// deployment serving the webhook starts rolling out and back once it's done,
+// without any other change to the MutatingWebhookConfiguration.
+func TestSettingsWatcher_FailsOpenDuringRollout(t *testing.T) {
+	t.Parallel()
+
+	fail := admissionv1.Fail
+	config := webhookConfig{
+		Name:                  "webhookOne",
+		FailurePolicy:         &fail,
+		FailOpenDuringRollout: true,
+		DeploymentName:        "connect-injector",
+		DeploymentNamespace:   "default",
+	}
+	webhook := &admissionv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhookOne"},
+		Webhooks:   []admissionv1.MutatingWebhook{{Name: "one"}},
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "connect-injector", Namespace: "default", Generation: 1},
+		Status:     appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+	}
+	k8s := fake.NewSimpleClientset(webhook, deployment)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cmd := Command{UI: cli.NewMockUi()}
+	go cmd.settingsWatcher(ctx, config, k8s, hclog.New(nil))
+
+	requirePolicy := func(expected admissionv1.FailurePolicyType) {
+		timer := &retry.Timer{Timeout: 10 * time.Second, Wait: 100 * time.Millisecond}
+		retry.RunWith(timer, t, func(r *retry.R) {
+			webhookCfg, err := k8s.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, "webhookOne", metav1.GetOptions{})
+			require.NoError(r, err)
+			require.NotNil(r, webhookCfg.Webhooks[0].FailurePolicy)
+			require.Equal(r, expected, *webhookCfg.Webhooks[0].FailurePolicy)
+		})
+	}
+	requirePolicy(admissionv1.Fail)
+
+	// Start a rollout of the deployment.
+	deployment.Generation = 2
+	_, err := k8s.AppsV1().Deployments("default").Update(ctx, deployment, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	requirePolicy(admissionv1.Ignore)
+
+	// Finish the rollout.
+	deployment.Status.ObservedGeneration = 2
+	_, err = k8s.AppsV1().Deployments("default").Update(ctx, deployment, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	requirePolicy(admissionv1.Fail)
+}
+
+func TestRollingOut(t *testing.T) {
+	t.Parallel()
+
+	replicas := int32(2)
+	cases := map[string]struct {
+		generation int64
+		status     appsv1.DeploymentStatus
+		exp        bool
+	}{
+		"rolled out": {
+			generation: 1,
+			status:     appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+			exp:        false,
+		},
+		"new generation not observed": {
+			generation: 2,
+			status:     appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+			exp:        true,
+		},
+		"replicas not updated": {
+			generation: 1,
+			status:     appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 1},
+			exp:        true,
+		},
+		"old replicas not terminated": {
+			generation: 1,
+			status:     appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 3, UpdatedReplicas: 2, AvailableReplicas: 2},
+			exp:        true,
+		},
+		"updated replicas not available": {
+			generation: 1,
+			status:     appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1},
+			exp:        true,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Generation: c.generation},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				Status:     c.status,
+			}
+			require.Equal(t, c.exp, rollingOut(deployment))
+		})
+	}
+}
+
 // This function starts the command asynchronously and returns a non-blocking chan.
 // When finished, the command will send its exit code to the channel.
 // Note that it's the responsibility of the caller to terminate the command by calling stopCommand,
@@ -0,0 +1,146 @@
+// Package tproxy generates the traffic redirection rules of transparent proxies
+// and hands them from the connect-inject init container to the node helper,
+// which applies them on behalf of init containers that cannot run iptables.
+package tproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/iptables"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	// ConfigFile is the name of the file in the shared volume of the pod the
+	// init container writes the traffic redirection config to.
+	ConfigFile = "redirect-traffic.json"
+
+	// AppliedFile is the name of the file in the shared volume of the pod the
+	// node helper creates once it has applied the traffic redirection config.
+	AppliedFile = "redirect-traffic-applied"
+
+	// FailedFile is the name of the file in the shared volume of the pod the
+	// node helper writes the error to if it fails to apply the traffic
+	// redirection config.
+	FailedFile = "redirect-traffic-failed"
+)
+
+// proxyConfig is the part of the opaque proxy config relevant to traffic
+// redirection.
+type proxyConfig struct {
+	BindPort           int    `mapstructure:"bind_port"`
+	PrometheusBindAddr string `mapstructure:"envoy_prometheus_bind_addr"`
+	StatsBindAddr      string `mapstructure:"envoy_stats_bind_addr"`
+}
+
+// ConfigFromProxy returns the traffic redirection config for the proxy
+// registered with the given ID. It matches the config the consul connect
+// redirect-traffic command generates, except that the proxy user ID, the
+// Consul DNS IP and any user provided exclusions are left to the caller.
+func ConfigFromProxy(client *api.Client, proxyID string) (iptables.Config, error) {
+	cfg := iptables.Config{
+		ProxyOutboundPort: iptables.DefaultTProxyOutboundPort,
+	}
+
+	svc, _, err := client.Agent().Service(proxyID, nil)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to fetch proxy service %q from Consul: %s", proxyID, err)
+	}
+	if svc.Proxy == nil {
+		return cfg, fmt.Errorf("service %q is not a proxy service", proxyID)
+	}
+
+	cfg.ProxyInboundPort = svc.Port
+	var pc proxyConfig
+	if err := mapstructure.WeakDecode(svc.Proxy.Config, &pc); err != nil {
+		return cfg, fmt.Errorf("failed parsing proxy config of %q: %s", proxyID, err)
+	}
+	if pc.BindPort != 0 {
+		cfg.ProxyInboundPort = pc.BindPort
+	}
+	if svc.Proxy.TransparentProxy != nil && svc.Proxy.TransparentProxy.OutboundListenerPort != 0 {
+		cfg.ProxyOutboundPort = svc.Proxy.TransparentProxy.OutboundListenerPort
+	}
+
+	// Exclude the ports of the metrics listeners and of the exposed paths
+	// from inbound traffic redirection.
+	for _, addr := range []string{pc.PrometheusBindAddr, pc.StatsBindAddr} {
+		if addr == "" {
+			continue
+		}
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return cfg, fmt.Errorf("failed parsing host and port from %q: %s", addr, err)
+		}
+		cfg.ExcludeInboundPorts = append(cfg.ExcludeInboundPorts, port)
+	}
+	for _, path := range svc.Proxy.Expose.Paths {
+		if path.ListenerPort != 0 {
+			cfg.ExcludeInboundPorts = append(cfg.ExcludeInboundPorts, strconv.Itoa(path.ListenerPort))
+		}
+	}
+	if svc.Proxy.Expose.Checks {
+		checks, err := client.Agent().ChecksWithFilter(fmt.Sprintf("ServiceName == %q", svc.Proxy.DestinationServiceName))
+		if err != nil {
+			return cfg, fmt.Errorf("failed to fetch the checks of %q from Consul: %s", svc.Proxy.DestinationServiceName, err)
+		}
+		for _, check := range checks {
+			if check.ExposedPort != 0 {
+				cfg.ExcludeInboundPorts = append(cfg.ExcludeInboundPorts, strconv.Itoa(check.ExposedPort))
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// WriteConfig writes the traffic redirection config to the ConfigFile in dir.
+// The file is written atomically so that the node helper never reads a
+// partial config.
+func WriteConfig(dir string, cfg iptables.Config) error {
+	cfg.IptablesProvider = nil
+	cfg.NetNS = ""
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ConfigFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0444); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, ConfigFile))
+}
+
+// ReadConfig reads the traffic redirection config from the ConfigFile in dir.
+func ReadConfig(dir string) (iptables.Config, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, ConfigFile))
+	if err != nil {
+		return iptables.Config{}, err
+	}
+	return ParseConfig(raw)
+}
+
+// ParseConfig parses the contents of a ConfigFile.
+func ParseConfig(raw []byte) (iptables.Config, error) {
+	var cfg iptables.Config
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid traffic redirection config: %s", err)
+	}
+	return cfg, nil
+}
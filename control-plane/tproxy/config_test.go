@@ -0,0 +1,116 @@
+package tproxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/iptables"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFromProxy(t *testing.T) {
+	cases := map[string]struct {
+		service string
+		checks  string
+		exp     iptables.Config
+		expErr  string
+	}{
+		"defaults": {
+			service: `{"ID": "web-sidecar-proxy", "Port": 20000, "Proxy": {"DestinationServiceName": "web"}}`,
+			exp: iptables.Config{
+				ProxyInboundPort:  20000,
+				ProxyOutboundPort: iptables.DefaultTProxyOutboundPort,
+			},
+		},
+		"proxy config": {
+			service: `{"ID": "web-sidecar-proxy", "Port": 20000, "Proxy": {
+  "DestinationServiceName": "web",
+  "TransparentProxy": {"OutboundListenerPort": 15002},
+  "Config": {"bind_port": "21000", "envoy_prometheus_bind_addr": "0.0.0.0:20200", "envoy_stats_bind_addr": "0.0.0.0:20300"},
+  "Expose": {"Paths": [{"ListenerPort": 21500, "Path": "/health"}]}
+}}`,
+			exp: iptables.Config{
+				ProxyInboundPort:    21000,
+				ProxyOutboundPort:   15002,
+				ExcludeInboundPorts: []string{"20200", "20300", "21500"},
+			},
+		},
+		"exposed checks": {
+			service: `{"ID": "web-sidecar-proxy", "Port": 20000, "Proxy": {"DestinationServiceName": "web", "Expose": {"Checks": true}}}`,
+			checks:  `{"web-check": {"CheckID": "web-check", "ServiceName": "web", "ExposedPort": 21600}}`,
+			exp: iptables.Config{
+				ProxyInboundPort:    20000,
+				ProxyOutboundPort:   iptables.DefaultTProxyOutboundPort,
+				ExcludeInboundPorts: []string{"21600"},
+			},
+		},
+		"not a proxy": {
+			service: `{"ID": "web-sidecar-proxy", "Port": 20000}`,
+			expErr:  `service "web-sidecar-proxy" is not a proxy service`,
+		},
+		"invalid metrics address": {
+			service: `{"ID": "web-sidecar-proxy", "Port": 20000, "Proxy": {"Config": {"envoy_prometheus_bind_addr": "20200"}}}`,
+			expErr:  `failed parsing host and port from "20200"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/agent/service/web-sidecar-proxy":
+					fmt.Fprint(w, c.service)
+				case "/v1/agent/checks":
+					require.Equal(t, `ServiceName == "web"`, r.URL.Query().Get("filter"))
+					fmt.Fprint(w, c.checks)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+			client, err := api.NewClient(&api.Config{Address: server.URL})
+			require.NoError(t, err)
+
+			cfg, err := ConfigFromProxy(client, "web-sidecar-proxy")
+			if c.expErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, cfg)
+		})
+	}
+}
+
+func TestWriteConfig_ReadConfig(t *testing.T) {
+	dir := t.TempDir()
+	_, err := ReadConfig(dir)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	cfg := iptables.Config{
+		ProxyUserID:          "5995",
+		ProxyInboundPort:     20000,
+		ProxyOutboundPort:    15001,
+		ExcludeOutboundCIDRs: []string{"10.0.0.0/8"},
+		NetNS:                "/proc/1/ns/net",
+	}
+	require.NoError(t, WriteConfig(dir, cfg))
+
+	info, err := os.Stat(filepath.Join(dir, ConfigFile))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0444), info.Mode().Perm())
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "temporary file was not cleaned up")
+
+	actual, err := ReadConfig(dir)
+	require.NoError(t, err)
+	// The network namespace is up to the reader.
+	cfg.NetNS = ""
+	require.Equal(t, cfg, actual)
+}
@@ -0,0 +1,21 @@
+package tproxy
+
+import "strconv"
+
+// NodeProxyChain is the chain of the nat table that redirects the outbound
+// traffic of pods in node proxy mode to the node proxy.
+const NodeProxyChain = "CONSUL_NODE_PROXY_OUTPUT"
+
+// NodeProxyRules returns the iptables arguments that redirect the outbound TCP
+// traffic of a pod in node proxy mode to the node proxy listening on port of
+// the node with hostIP. Traffic to localhost and to the node itself is not
+// redirected, so that the kubelet probes and the node proxy keep working.
+func NodeProxyRules(hostIP string, port int) [][]string {
+	return [][]string{
+		{"-t", "nat", "-N", NodeProxyChain},
+		{"-t", "nat", "-A", NodeProxyChain, "-d", "127.0.0.0/8", "-j", "RETURN"},
+		{"-t", "nat", "-A", NodeProxyChain, "-d", hostIP + "/32", "-j", "RETURN"},
+		{"-t", "nat", "-A", NodeProxyChain, "-p", "tcp", "-j", "DNAT", "--to-destination", hostIP + ":" + strconv.Itoa(port)},
+		{"-t", "nat", "-A", "OUTPUT", "-p", "tcp", "-j", NodeProxyChain},
+	}
+}
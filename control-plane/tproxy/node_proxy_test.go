@@ -0,0 +1,16 @@
+package tproxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeProxyRules(t *testing.T) {
+	rules := NodeProxyRules("10.0.0.5", 15101)
+	require.Equal(t, []string{"-t", "nat", "-N", NodeProxyChain}, rules[0])
+	require.Equal(t, []string{"-t", "nat", "-A", NodeProxyChain, "-d", "10.0.0.5/32", "-j", "RETURN"}, rules[2])
+	require.Equal(t, []string{"-t", "nat", "-A", NodeProxyChain, "-p", "tcp", "-j", "DNAT", "--to-destination", "10.0.0.5:15101"}, rules[3])
+	// The chain is only jumped to once it is complete.
+	require.Equal(t, []string{"-t", "nat", "-A", "OUTPUT", "-p", "tcp", "-j", NodeProxyChain}, rules[len(rules)-1])
+}
@@ -0,0 +1,269 @@
+package main
+
+// This script generates the Values struct of the CLI's helm package out of the
+// values.yaml file. The CLI validates the values that users set against the
+// struct before the chart is rendered.
+//
+// Usage: make gen-helm-values [-validate]
+//        If -validate is set, the generated struct isn't written. Instead the
+//        script fails if cli/helm/values.go isn't up to date.
+//        This is useful in CI to ensure values.go is regenerated when values.yaml changes.
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	valuesYAMLPath = "../../charts/consul/values.yaml"
+	valuesGoPath   = "../../cli/helm/values.go"
+
+	header = `// Code generated by hack/helm-values-gen from charts/consul/values.yaml. DO NOT EDIT.
+
+package helm
+
+// Values is the Helm values that may be set for the Consul Helm Chart.
+`
+)
+
+var (
+	// typeAnnotation matches the @type annotation. It captures the value of @type.
+	typeAnnotation = regexp.MustCompile(`(?m).*@type: (.*)$`)
+
+	// recurseAnnotation matches the @recurse annotation. It captures the value of @recurse.
+	recurseAnnotation = regexp.MustCompile(`(?m).*@recurse: (.*)$`)
+
+	// identifier matches the keys that can be converted to Go names.
+	identifier = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9]*$`)
+
+	// initialisms are the words of keys that are upper cased in Go names.
+	initialisms = map[string]bool{
+		"acl":   true,
+		"api":   true,
+		"cpu":   true,
+		"dns":   true,
+		"http":  true,
+		"https": true,
+		"id":    true,
+		"ip":    true,
+		"json":  true,
+		"k8s":   true,
+		"rpc":   true,
+		"tls":   true,
+		"ui":    true,
+		"url":   true,
+	}
+)
+
+func main() {
+	validateFlag := flag.Bool("validate", false, "only validate that cli/helm/values.go is up to date, don't write it")
+	flag.Parse()
+
+	inputBytes, err := ioutil.ReadFile(valuesYAMLPath)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	out, err := GenerateValues(string(inputBytes))
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	if *validateFlag {
+		current, err := ioutil.ReadFile(valuesGoPath)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		if !bytes.Equal(current, []byte(out)) {
+			fmt.Println("cli/helm/values.go is out of date, run make gen-helm-values")
+			os.Exit(1)
+		}
+		fmt.Println("Validation successful")
+		os.Exit(0)
+	}
+
+	if err := ioutil.WriteFile(valuesGoPath, []byte(out), 0644); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	fmt.Println("Generated cli/helm/values.go")
+}
+
+// GenerateValues returns the Go source of the Values struct for the
+// values.yaml input.
+//
+// Every map with keys in values.yaml is a struct. Its type is named after its
+// key, prefixed with the name of its parent's type if another map with
+// different keys already has that name, e.g. global.metrics is GlobalMetrics.
+// Maps with the same keys share a type.
+//
+// The types of the other keys are taken from their @type annotation or else
+// from their default value. Keys that default to "-" inherit their value
+// from another key, e.g. server.enabled from global.enabled, and can be set
+// to anything. So can keys that default to null and have no @type.
+func GenerateValues(input string) (string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(input), &doc); err != nil {
+		return "", err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return "", errors.New("values.yaml must be a map")
+	}
+
+	g := &generator{bodies: make(map[string]string)}
+	root, err := g.structBody("Values", doc.Content[0])
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	out.WriteString(header)
+	fmt.Fprintf(&out, "type Values %s\n", root)
+	for _, name := range g.order {
+		fmt.Fprintf(&out, "\ntype %s %s\n", name, g.bodies[name])
+	}
+
+	formatted, err := format.Source([]byte(out.String()))
+	if err != nil {
+		return "", err
+	}
+	return string(formatted), nil
+}
+
+type generator struct {
+	// bodies are the struct bodies by type name.
+	bodies map[string]string
+	// order is the order in which the types were first seen.
+	order []string
+}
+
+// structBody returns the struct body of the map node. typeName is the name of
+// the struct and is used to name the types of nested maps.
+func (g *generator) structBody(typeName string, node *yaml.Node) (string, error) {
+	var body strings.Builder
+	body.WriteString("struct {\n")
+	for i := 0; i < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if !identifier.MatchString(key.Value) {
+			return "", fmt.Errorf("key %q on line %d can't be a struct field, annotate its parent with @type: map and @recurse: false if its keys are arbitrary", key.Value, key.Line)
+		}
+		fieldType, err := g.fieldType(typeName, key, value)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&body, "\t%s %s `yaml:\"%s\"`\n", goName(key.Value), fieldType, key.Value)
+	}
+	body.WriteString("}")
+	return body.String(), nil
+}
+
+func (g *generator) fieldType(parentTypeName string, key, value *yaml.Node) (string, error) {
+	var typeAnn string
+	if match := typeAnnotation.FindStringSubmatch(key.HeadComment); len(match) > 0 {
+		typeAnn = strings.TrimSpace(match[1])
+	}
+	recurse := true
+	if match := recurseAnnotation.FindStringSubmatch(key.HeadComment); len(match) > 0 && strings.TrimSpace(match[1]) == "false" {
+		recurse = false
+	}
+
+	if value.Kind == yaml.ScalarNode && value.Tag == "!!str" && value.Value == "-" {
+		return "interface{}", nil
+	}
+	if value.Kind == yaml.MappingNode && len(value.Content) > 0 && recurse && (typeAnn == "" || typeAnn == "map") {
+		return g.structType(parentTypeName, key, value)
+	}
+
+	switch typeAnn {
+	case "string":
+		return "string", nil
+	case "boolean":
+		return "bool", nil
+	case "integer", "int":
+		return "int", nil
+	case "map":
+		return "map[string]interface{}", nil
+	case "array<string>":
+		return "[]string", nil
+	case "array<map>":
+		return "[]map[string]interface{}", nil
+	case "":
+	default:
+		return "", fmt.Errorf("unsupported @type %q of key %q on line %d", typeAnn, key.Value, key.Line)
+	}
+
+	switch value.Kind {
+	case yaml.MappingNode:
+		return "map[string]interface{}", nil
+	case yaml.SequenceNode:
+		return "[]interface{}", nil
+	}
+	switch value.Tag {
+	case "!!bool":
+		return "bool", nil
+	case "!!int":
+		return "int", nil
+	case "!!float":
+		return "float64", nil
+	case "!!str":
+		return "string", nil
+	}
+	return "interface{}", nil
+}
+
+// structType returns the name of the struct type of the map node.
+func (g *generator) structType(parentTypeName string, key, node *yaml.Node) (string, error) {
+	name := goName(key.Value)
+	body, err := g.structBody(name, node)
+	if err != nil {
+		return "", err
+	}
+
+	for _, candidate := range []string{name, parentTypeName + name} {
+		existing, ok := g.bodies[candidate]
+		if !ok {
+			g.bodies[candidate] = body
+			g.order = append(g.order, candidate)
+			return candidate, nil
+		}
+		if existing == body {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("cannot name the type of key %q on line %d: %s and %s%s are taken", key.Value, key.Line, name, parentTypeName, name)
+}
+
+// goName returns the exported Go name of the key, e.g. k8sAuthMethodHost
+// is K8SAuthMethodHost.
+func goName(key string) string {
+	var name strings.Builder
+	start := 0
+	for i := 1; i <= len(key); i++ {
+		if i < len(key) && !(isUpper(key[i]) && !isUpper(key[i-1])) {
+			continue
+		}
+		word := key[start:i]
+		if initialisms[strings.ToLower(word)] {
+			name.WriteString(strings.ToUpper(word))
+		} else {
+			name.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+		start = i
+	}
+	return name.String()
+}
+
+func isUpper(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
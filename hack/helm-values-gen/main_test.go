@@ -0,0 +1,180 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateValues(t *testing.T) {
+	cases := map[string]struct {
+		Input string
+		Exp   string
+	}{
+		"scalars": {
+			Input: `---
+name: consul
+replicas: 3
+ratio: 0.5
+enabled: true
+# @type: string
+image: null
+# @type: integer
+port: null
+unknown: null
+`,
+			Exp: `type Values struct {
+	Name     string      $yaml:"name"$
+	Replicas int         $yaml:"replicas"$
+	Ratio    float64     $yaml:"ratio"$
+	Enabled  bool        $yaml:"enabled"$
+	Image    string      $yaml:"image"$
+	Port     int         $yaml:"port"$
+	Unknown  interface{} $yaml:"unknown"$
+}
+`,
+		},
+		"inherited values": {
+			Input: `---
+# @type: boolean
+enabled: "-"
+`,
+			Exp: `type Values struct {
+	Enabled interface{} $yaml:"enabled"$
+}
+`,
+		},
+		"arrays and maps": {
+			Input: `---
+# @type: array<string>
+hosts: []
+# @type: array<map>
+volumes: null
+extraArgs: []
+extraLabels: {}
+# @type: map
+# @recurse: false
+resources:
+  requests:
+    memory: "100Mi"
+`,
+			Exp: `type Values struct {
+	Hosts       []string                 $yaml:"hosts"$
+	Volumes     []map[string]interface{} $yaml:"volumes"$
+	ExtraArgs   []interface{}            $yaml:"extraArgs"$
+	ExtraLabels map[string]interface{}   $yaml:"extraLabels"$
+	Resources   map[string]interface{}   $yaml:"resources"$
+}
+`,
+		},
+		"nested maps": {
+			Input: `---
+global:
+  tls:
+    enabled: false
+  metrics:
+    enabled: false
+    port: 20200
+server:
+  tls:
+    enabled: true
+  metrics:
+    path: /metrics
+`,
+			Exp: `type Values struct {
+	Global Global $yaml:"global"$
+	Server Server $yaml:"server"$
+}
+
+type TLS struct {
+	Enabled bool $yaml:"enabled"$
+}
+
+type Metrics struct {
+	Enabled bool $yaml:"enabled"$
+	Port    int  $yaml:"port"$
+}
+
+type Global struct {
+	TLS     TLS     $yaml:"tls"$
+	Metrics Metrics $yaml:"metrics"$
+}
+
+type ServerMetrics struct {
+	Path string $yaml:"path"$
+}
+
+type Server struct {
+	TLS     TLS           $yaml:"tls"$
+	Metrics ServerMetrics $yaml:"metrics"$
+}
+`,
+		},
+		"initialisms": {
+			Input: `---
+k8sAuthMethodHost: ""
+apiGateway: ""
+logJSON: false
+serverAdditionalDNSSANs: ""
+`,
+			Exp: `type Values struct {
+	K8SAuthMethodHost       string $yaml:"k8sAuthMethodHost"$
+	APIGateway              string $yaml:"apiGateway"$
+	LogJSON                 bool   $yaml:"logJSON"$
+	ServerAdditionalDNSSANs string $yaml:"serverAdditionalDNSSANs"$
+}
+`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			out, err := GenerateValues(c.Input)
+			require.NoError(t, err)
+			require.Equal(t, header+strings.Replace(c.Exp, "$", "`", -1), out)
+		})
+	}
+}
+
+func TestGenerateValues_Errors(t *testing.T) {
+	cases := map[string]struct {
+		Input  string
+		ExpErr string
+	}{
+		"unsupported type": {
+			Input:  "# @type: float\nratio: null\n",
+			ExpErr: `unsupported @type "float" of key "ratio" on line 2`,
+		},
+		"arbitrary keys": {
+			Input:  "nodeMeta:\n  pod-name: foo\n",
+			ExpErr: `key "pod-name" on line 2 can't be a struct field`,
+		},
+		"type name collision": {
+			Input:  "tls:\n  x: 1\nserverTLS:\n  y: 1\nserver:\n  tls:\n    z: 1\n",
+			ExpErr: `cannot name the type of key "tls" on line 6: TLS and ServerTLS are taken`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := GenerateValues(c.Input)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), c.ExpErr)
+		})
+	}
+}
+
+// Test that the values.yaml of the chart can be converted and that the
+// generated struct is up to date.
+func TestGenerateValues_ValuesYAML(t *testing.T) {
+	input, err := ioutil.ReadFile(valuesYAMLPath)
+	require.NoError(t, err)
+	out, err := GenerateValues(string(input))
+	require.NoError(t, err)
+
+	current, err := ioutil.ReadFile(valuesGoPath)
+	require.NoError(t, err)
+	require.Equal(t, string(current), out, "cli/helm/values.go is out of date, run make gen-helm-values")
+}